@@ -0,0 +1,43 @@
+#!/bin/bash
+# Runs the go benchmarks for bid decisions, capacity tracking and event
+# processing with fixed settings, so that runs on different commits can be
+# compared with benchstat.
+#
+# Usage: benchmark/go_bench.sh [baseline results file]
+#
+# The results are written to ${BENCH_OUTPUT_DIR}/<commit>.txt, and compared
+# against the baseline when one is given and benchstat is installed
+# (go install golang.org/x/perf/cmd/benchstat@latest).
+set -euo pipefail
+
+BENCH_PACKAGES=${BENCH_PACKAGES:-"./pkg/compute/bidstrategy/... ./pkg/compute/capacity/... ./pkg/localdb/..."}
+BENCH_PATTERN=${BENCH_PATTERN:-.}
+# a fixed number of iterations rather than a duration, so that every run
+# does the same work whatever the speed of the machine
+BENCH_TIME=${BENCH_TIME:-1000x}
+# benchstat needs several samples to tell a change from noise
+BENCH_COUNT=${BENCH_COUNT:-6}
+BENCH_CPU=${BENCH_CPU:-1,4}
+BENCH_OUTPUT_DIR=${BENCH_OUTPUT_DIR:-benchmark/results}
+
+cd "$(git rev-parse --show-toplevel)"
+commit=$(git rev-parse --short HEAD)
+if [[ -n "$(git status --porcelain --untracked-files=no)" ]]; then
+  commit="${commit}-dirty"
+fi
+mkdir -p "${BENCH_OUTPUT_DIR}"
+output="${BENCH_OUTPUT_DIR}/${commit}.txt"
+
+# shellcheck disable=SC2086
+go test -run '^$' -bench "${BENCH_PATTERN}" -benchmem \
+  -benchtime "${BENCH_TIME}" -count "${BENCH_COUNT}" -cpu "${BENCH_CPU}" \
+  -timeout 60m ${BENCH_PACKAGES} | tee "${output}"
+echo "benchmark results written to ${output}"
+
+if [[ $# -gt 0 ]]; then
+  if command -v benchstat >/dev/null; then
+    benchstat "$1" "${output}"
+  else
+    echo "benchstat is not installed, so the results were not compared with $1"
+  fi
+fi
@@ -0,0 +1,108 @@
+package bacalhau
+
+import (
+	"fmt"
+
+	"github.com/filecoin-project/bacalhau/pkg/system"
+	"github.com/filecoin-project/bacalhau/pkg/util/templates"
+	"github.com/spf13/cobra"
+	"k8s.io/kubectl/pkg/util/i18n"
+)
+
+var (
+	approveLong = templates.LongDesc(i18n.T(`
+		Approve or reject a job held by a requester node started with
+		--require-approval, which only announces jobs to the network once
+		one of its approvers has approved them. Rejected jobs never run.
+		Without a job ID, list the jobs waiting for approval.
+`))
+
+	approveExample = templates.Examples(i18n.T(`
+		# List the jobs waiting for approval
+		bacalhau approve
+
+		# Approve a job
+		bacalhau approve 51225160
+
+		# Reject a job
+		bacalhau approve 51225160 --reject --reason "reads patient records"
+`))
+)
+
+type ApproveOptions struct {
+	Reject bool   // Whether to reject the job rather than approve it
+	Reason string // Why the job is rejected
+}
+
+func NewApproveOptions() *ApproveOptions {
+	return &ApproveOptions{}
+}
+
+func newApproveCmd() *cobra.Command {
+	OA := NewApproveOptions()
+
+	approveCmd := &cobra.Command{
+		Use:     "approve [id]",
+		Short:   "Approve or reject a job held for approval, or list the jobs waiting for it",
+		Long:    approveLong,
+		Example: approveExample,
+		Args:    cobra.MaximumNArgs(1),
+		PreRun:  applyPorcelainLogLevel,
+		RunE: func(cmd *cobra.Command, cmdArgs []string) error {
+			return approve(cmd, cmdArgs, OA)
+		},
+	}
+
+	approveCmd.PersistentFlags().BoolVar(
+		&OA.Reject, "reject", OA.Reject,
+		`Reject the job rather than approve it`,
+	)
+	approveCmd.PersistentFlags().StringVar(
+		&OA.Reason, "reason", OA.Reason,
+		`Why the job is rejected`,
+	)
+
+	return approveCmd
+}
+
+func approve(cmd *cobra.Command, cmdArgs []string, OA *ApproveOptions) error {
+	cm := system.NewCleanupManager()
+	defer cm.Cleanup()
+	ctx := cmd.Context()
+
+	ctx, rootSpan := system.NewRootSpan(ctx, system.GetTracer(), "cmd/bacalhau/approve")
+	defer rootSpan.End()
+	cm.RegisterCallback(system.CleanupTraceProvider)
+
+	if len(cmdArgs) == 0 {
+		pending, err := GetAPIClient().PendingApprovals(ctx)
+		if err != nil {
+			Fatal(cmd, fmt.Sprintf("Error listing the jobs waiting for approval: %s", err), 1)
+			return nil
+		}
+		for _, jobID := range pending {
+			cmd.Println(jobID)
+		}
+		return nil
+	}
+
+	j, _, err := GetAPIClient().Get(ctx, cmdArgs[0])
+	if err != nil {
+		Fatal(cmd, fmt.Sprintf("Error getting job %s: %s", cmdArgs[0], err), 1)
+		return nil
+	}
+	if OA.Reject {
+		if err = GetAPIClient().Reject(ctx, j.ID, OA.Reason); err != nil {
+			Fatal(cmd, fmt.Sprintf("Error rejecting job %s: %s", j.ID, err), 1)
+			return nil
+		}
+		cmd.Printf("Rejected job %s\n", j.ID)
+		return nil
+	}
+	if err = GetAPIClient().Approve(ctx, j.ID); err != nil {
+		Fatal(cmd, fmt.Sprintf("Error approving job %s: %s", j.ID, err), 1)
+		return nil
+	}
+	cmd.Printf("Approved job %s\n", j.ID)
+	return nil
+}
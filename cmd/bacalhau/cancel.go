@@ -0,0 +1,100 @@
+package bacalhau
+
+import (
+	"fmt"
+
+	"github.com/filecoin-project/bacalhau/pkg/system"
+	"github.com/filecoin-project/bacalhau/pkg/util/templates"
+	"github.com/spf13/cobra"
+	"k8s.io/kubectl/pkg/util/i18n"
+)
+
+var (
+	cancelLong = templates.LongDesc(i18n.T(`
+		Cancel a job, or all the jobs submitted in a batch with a job group.
+		The shards of the jobs that have not completed yet fail, and the
+		compute nodes running them stop. Only the client that submitted the
+		jobs can cancel them.
+`))
+
+	cancelExample = templates.Examples(i18n.T(`
+		# Cancel a job
+		bacalhau cancel 51225160
+
+		# Cancel all the jobs of a job group
+		bacalhau cancel --job-group 1b7f5e2a-4f0e-4a1c-9d3b-9e8f0c6d2a71
+`))
+)
+
+type CancelOptions struct {
+	JobGroup string // The job group to cancel the jobs of
+	Reason   string // Why the jobs are cancelled
+}
+
+func NewCancelOptions() *CancelOptions {
+	return &CancelOptions{}
+}
+
+func newCancelCmd() *cobra.Command {
+	OC := NewCancelOptions()
+
+	cancelCmd := &cobra.Command{
+		Use:     "cancel [id]",
+		Short:   "Cancel a job, or the jobs of a job group",
+		Long:    cancelLong,
+		Example: cancelExample,
+		Args:    cobra.MaximumNArgs(1),
+		PreRun:  applyPorcelainLogLevel,
+		RunE: func(cmd *cobra.Command, cmdArgs []string) error {
+			return cancel(cmd, cmdArgs, OC)
+		},
+	}
+
+	cancelCmd.PersistentFlags().StringVar(
+		&OC.JobGroup, "job-group", OC.JobGroup,
+		`Cancel all the jobs of this job group, rather than a single job`,
+	)
+	cancelCmd.PersistentFlags().StringVar(
+		&OC.Reason, "reason", OC.Reason,
+		`Why the jobs are cancelled, which is reported in their errors`,
+	)
+
+	return cancelCmd
+}
+
+func cancel(cmd *cobra.Command, cmdArgs []string, OC *CancelOptions) error {
+	cm := system.NewCleanupManager()
+	defer cm.Cleanup()
+	ctx := cmd.Context()
+
+	ctx, rootSpan := system.NewRootSpan(ctx, system.GetTracer(), "cmd/bacalhau/cancel")
+	defer rootSpan.End()
+	cm.RegisterCallback(system.CleanupTraceProvider)
+
+	if (len(cmdArgs) == 0) == (OC.JobGroup == "") {
+		Fatal(cmd, "Please give either a job ID or --job-group.", 1)
+		return nil
+	}
+
+	var cancelled []string
+	var err error
+	if OC.JobGroup != "" {
+		cancelled, err = GetAPIClient().CancelJobGroup(ctx, OC.JobGroup, OC.Reason)
+	} else {
+		j, _, getErr := GetAPIClient().Get(ctx, cmdArgs[0])
+		if getErr != nil {
+			Fatal(cmd, fmt.Sprintf("Error getting job %s: %s", cmdArgs[0], getErr), 1)
+			return nil
+		}
+		cancelled, err = GetAPIClient().Cancel(ctx, j.ID, OC.Reason)
+	}
+	if err != nil {
+		Fatal(cmd, fmt.Sprintf("Error cancelling: %s", err), 1)
+		return nil
+	}
+
+	for _, jobID := range cancelled {
+		cmd.Printf("Cancelled job %s\n", jobID)
+	}
+	return nil
+}
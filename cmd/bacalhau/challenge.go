@@ -0,0 +1,101 @@
+package bacalhau
+
+import (
+	"fmt"
+
+	"github.com/filecoin-project/bacalhau/pkg/system"
+	"github.com/filecoin-project/bacalhau/pkg/util/templates"
+	"github.com/spf13/cobra"
+	"k8s.io/kubectl/pkg/util/i18n"
+)
+
+var (
+	challengeLong = templates.LongDesc(i18n.T(`
+		Challenge the results a node published for a shard of a job submitted
+		with --verifier optimistic, whose results are accepted provisionally
+		until the challenge period of the job ends. The requester node
+		re-executes the shard on another node, as a job owned by you, and
+		records whether the challenge was upheld on the challenged job once the
+		re-execution's results are verified, which 'describe' shows.
+`))
+
+	challengeExample = templates.Examples(i18n.T(`
+		# Challenge the results of a node for the first shard of a job
+		bacalhau challenge 51225160 --node QmYgxZiySj3MRkwLSL4X2MF5F9f2PMhAE3LV49XkfNL1o3
+
+		# Challenge the results of a node for the third shard of a job
+		bacalhau challenge 51225160 --shard 2 --node QmYgxZiySj3MRkwLSL4X2MF5F9f2PMhAE3LV49XkfNL1o3 \
+			--reason "the output is truncated"
+`))
+)
+
+type ChallengeOptions struct {
+	ShardIndex int    // The index of the shard whose results are challenged
+	NodeID     string // The node whose results are challenged
+	Reason     string // Why the results are challenged
+}
+
+func NewChallengeOptions() *ChallengeOptions {
+	return &ChallengeOptions{}
+}
+
+func newChallengeCmd() *cobra.Command {
+	OC := NewChallengeOptions()
+
+	challengeCmd := &cobra.Command{
+		Use:     "challenge [id]",
+		Short:   "Challenge the results a node published for a shard of a job verified optimistically",
+		Long:    challengeLong,
+		Example: challengeExample,
+		Args:    cobra.ExactArgs(1),
+		PreRun:  applyPorcelainLogLevel,
+		RunE: func(cmd *cobra.Command, cmdArgs []string) error {
+			return challenge(cmd, cmdArgs, OC)
+		},
+	}
+
+	challengeCmd.PersistentFlags().IntVar(
+		&OC.ShardIndex, "shard", OC.ShardIndex,
+		`The index of the shard whose results are challenged`,
+	)
+	challengeCmd.PersistentFlags().StringVar(
+		&OC.NodeID, "node", OC.NodeID,
+		`The ID of the node whose results are challenged`,
+	)
+	challengeCmd.PersistentFlags().StringVar(
+		&OC.Reason, "reason", OC.Reason,
+		`Why the results are challenged`,
+	)
+
+	return challengeCmd
+}
+
+func challenge(cmd *cobra.Command, cmdArgs []string, OC *ChallengeOptions) error {
+	cm := system.NewCleanupManager()
+	defer cm.Cleanup()
+	ctx := cmd.Context()
+
+	ctx, rootSpan := system.NewRootSpan(ctx, system.GetTracer(), "cmd/bacalhau/challenge")
+	defer rootSpan.End()
+	cm.RegisterCallback(system.CleanupTraceProvider)
+
+	if OC.NodeID == "" {
+		Fatal(cmd, "The node whose results are challenged must be set with --node", 1)
+		return nil
+	}
+
+	j, _, err := GetAPIClient().Get(ctx, cmdArgs[0])
+	if err != nil {
+		Fatal(cmd, fmt.Sprintf("Error getting job %s: %s", cmdArgs[0], err), 1)
+		return nil
+	}
+	reexecution, err := GetAPIClient().Challenge(ctx, j.ID, OC.ShardIndex, OC.NodeID, OC.Reason)
+	if err != nil {
+		Fatal(cmd, fmt.Sprintf("Error challenging the results of node %s for shard %d of job %s: %s",
+			OC.NodeID, OC.ShardIndex, j.ID, err), 1)
+		return nil
+	}
+	cmd.Printf("Challenged the results of node %s for shard %d of job %s, re-executing the shard as job %s\n",
+		OC.NodeID, OC.ShardIndex, j.ID, reexecution.ID)
+	return nil
+}
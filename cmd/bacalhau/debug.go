@@ -0,0 +1,104 @@
+package bacalhau
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/filecoin-project/bacalhau/pkg/system"
+	"github.com/filecoin-project/bacalhau/pkg/util/templates"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+	"k8s.io/kubectl/pkg/util/i18n"
+)
+
+var (
+	debugLong = templates.LongDesc(i18n.T(`
+		Attach a shell to the environment a node has kept of a failed shard of
+		a job, to debug problems with its image or inputs. Nodes only keep the
+		environment of shards of jobs submitted with --debug-on-failure, if they
+		allow it, and only for a while after the shard fails. Only the node that
+		ran the shard has it, so point --api-host at that node. Only the client
+		that submitted the job can debug it.
+`))
+
+	debugExample = templates.Examples(i18n.T(`
+		# Attach a shell to the first shard of a job
+		bacalhau debug 51225160
+
+		# Attach a shell to the third shard of a job, on the node that ran it
+		bacalhau debug 51225160 --shard 2 --api-host 10.0.0.12
+`))
+)
+
+type DebugOptions struct {
+	ShardIndex int // The index of the failed shard to debug
+}
+
+func NewDebugOptions() *DebugOptions {
+	return &DebugOptions{}
+}
+
+func newDebugCmd() *cobra.Command {
+	OD := NewDebugOptions()
+
+	debugCmd := &cobra.Command{
+		Use:     "debug [id]",
+		Short:   "Attach a shell to the environment of a failed shard of a job",
+		Long:    debugLong,
+		Example: debugExample,
+		Args:    cobra.ExactArgs(1),
+		PreRun:  applyPorcelainLogLevel,
+		RunE: func(cmd *cobra.Command, cmdArgs []string) error {
+			return debug(cmd, cmdArgs, OD)
+		},
+	}
+
+	debugCmd.PersistentFlags().IntVar(
+		&OD.ShardIndex, "shard", OD.ShardIndex,
+		`The index of the failed shard to debug`,
+	)
+
+	return debugCmd
+}
+
+func debug(cmd *cobra.Command, cmdArgs []string, OD *DebugOptions) error {
+	cm := system.NewCleanupManager()
+	defer cm.Cleanup()
+	ctx := cmd.Context()
+
+	ctx, rootSpan := system.NewRootSpan(ctx, system.GetTracer(), "cmd/bacalhau/debug")
+	defer rootSpan.End()
+	cm.RegisterCallback(system.CleanupTraceProvider)
+
+	j, _, err := GetAPIClient().Get(ctx, cmdArgs[0])
+	if err != nil {
+		Fatal(cmd, fmt.Sprintf("Error getting job %s: %s", cmdArgs[0], err), 1)
+		return nil
+	}
+
+	err = func() error {
+		// the shell runs in a terminal on the node, so the local one passes
+		// keys through to it as they are typed
+		if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+			state, err := term.MakeRaw(fd) //nolint:govet // ignore err shadowing
+			if err != nil {
+				return err
+			}
+			defer func() { _ = term.Restore(fd, state) }()
+		}
+		return GetAPIClient().DebugShard(ctx, j.ID, OD.ShardIndex, cmd.InOrStdin(), cmd.OutOrStdout())
+	}()
+	if err != nil {
+		nodes := ""
+		if state, stateErr := GetAPIClient().GetJobState(ctx, j.ID); stateErr == nil {
+			for nodeID, nodeState := range state.Nodes {
+				if _, ok := nodeState.Shards[OD.ShardIndex]; ok {
+					nodes += fmt.Sprintf("\nShard %d ran on node %s.", OD.ShardIndex, nodeID)
+				}
+			}
+		}
+		Fatal(cmd, fmt.Sprintf("Error debugging shard %d of job %s: %s%s", OD.ShardIndex, j.ID, err, nodes), 1)
+		return nil
+	}
+	return nil
+}
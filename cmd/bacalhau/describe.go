@@ -4,18 +4,18 @@ import (
 	"fmt"
 
 	"github.com/filecoin-project/bacalhau/pkg/bacerrors"
-	"github.com/filecoin-project/bacalhau/pkg/model"
+	jobutils "github.com/filecoin-project/bacalhau/pkg/job"
 	"github.com/filecoin-project/bacalhau/pkg/system"
 	"github.com/filecoin-project/bacalhau/pkg/util/templates"
 	"github.com/spf13/cobra"
 	"k8s.io/kubectl/pkg/util/i18n"
-	"sigs.k8s.io/yaml"
 )
 
 var (
 	//nolint:lll // Documentation
 	describeLong = templates.LongDesc(i18n.T(`
-		Full description of a job, in yaml format. Use 'bacalhau list' to get a list of all ids. Short form and long form of the job id are accepted.
+		Full description of a job, in yaml or json format. Use 'bacalhau list' to get a list of all ids. Short form and long form of the job id are accepted.
+		BidDeclines lists why compute nodes declined to bid on the job, for those that report it with --job-selection-report-declines.
 `))
 	//nolint:lll // Documentation
 	describeExample = templates.Examples(i18n.T(`
@@ -27,6 +27,12 @@ var (
 
 		# Describe a job and include all server and local events
 		bacalhau describe --include-events b6ad164a 
+
+		# Describe a job in json, e.g. to query it with jq
+		bacalhau describe --output json b6ad164a | jq .State
+
+		# Watch the states of the shards of a job until it finishes
+		bacalhau describe --follow b6ad164a
 `))
 )
 
@@ -34,12 +40,15 @@ type DescribeOptions struct {
 	Filename      string // Filename for job (can be .json or .yaml)
 	IncludeEvents bool   // Include events in the description
 	OutputSpec    bool   // Print Just the jobspec to stdout
+	Follow        bool   // Show the live states of the shards until the job finishes
+	OutputFormat  string // The output format for the description (yaml or json)
 }
 
 func NewDescribeOptions() *DescribeOptions {
 	return &DescribeOptions{
 		IncludeEvents: false,
 		OutputSpec:    false,
+		OutputFormat:  YAMLFormat,
 	}
 }
 
@@ -66,6 +75,14 @@ func newDescribeCmd() *cobra.Command {
 		&OD.IncludeEvents, "include-events", OD.IncludeEvents,
 		`Include events in the description (could be noisy)`,
 	)
+	describeCmd.PersistentFlags().BoolVarP(
+		&OD.Follow, "follow", "f", OD.Follow,
+		`Show the states of the shards of the job as they change, until it finishes`,
+	)
+	describeCmd.PersistentFlags().StringVar(
+		&OD.OutputFormat, "output", OD.OutputFormat,
+		`The output format for the description of the job (yaml or json)`,
+	)
 
 	return describeCmd
 }
@@ -79,6 +96,11 @@ func describe(cmd *cobra.Command, cmdArgs []string, OD *DescribeOptions) error {
 	defer rootSpan.End()
 	cm.RegisterCallback(system.CleanupTraceProvider)
 
+	if err := validateOutputFormat(OD.OutputFormat); err != nil {
+		Fatal(cmd, err.Error(), 1)
+		return nil
+	}
+
 	var err error
 	inputJobID := cmdArgs[0]
 	if inputJobID == "" {
@@ -94,7 +116,10 @@ func describe(cmd *cobra.Command, cmdArgs []string, OD *DescribeOptions) error {
 	j, foundJob, err := GetAPIClient().Get(ctx, inputJobID)
 
 	if err != nil {
-		if er, ok := err.(*bacerrors.ErrorResponse); ok {
+		if er, ok := err.(bacerrors.BacalhauErrorInterface); ok {
+			Fatal(cmd, er.GetMessage(), 1)
+			return nil
+		} else if er, ok := err.(*bacerrors.ErrorResponse); ok {
 			Fatal(cmd, er.Message, 1)
 			return nil
 		} else {
@@ -108,6 +133,13 @@ func describe(cmd *cobra.Command, cmdArgs []string, OD *DescribeOptions) error {
 		Fatal(cmd, "", 1)
 	}
 
+	if OD.Follow {
+		if err = followJob(ctx, GetAPIClient(), cmd.OutOrStdout(), j); err != nil {
+			Fatal(cmd, fmt.Sprintf("Failure following job '%s': %s\n", j.ID, err), 1)
+		}
+		return nil
+	}
+
 	shardStates, err := GetAPIClient().GetJobState(ctx, j.ID)
 	if err != nil {
 		Fatal(cmd, fmt.Sprintf("Failure retrieving job states '%s': %s\n", j.ID, err), 1)
@@ -125,24 +157,16 @@ func describe(cmd *cobra.Command, cmdArgs []string, OD *DescribeOptions) error {
 
 	jobDesc := j
 	jobDesc.State = shardStates
+	jobDesc.BidDeclines = jobutils.GetBidDeclines(jobEvents)
 
 	if OD.IncludeEvents {
 		jobDesc.Events = jobEvents
 		jobDesc.LocalEvents = localEvents
 	}
 
-	b, err := model.JSONMarshalWithMax(jobDesc)
-	if err != nil {
+	if err = printFormatted(cmd, OD.OutputFormat, jobDesc); err != nil {
 		Fatal(cmd, fmt.Sprintf("Failure marshaling job description '%s': %s\n", j.ID, err), 1)
 	}
 
-	// Convert Json to Yaml
-	y, err := yaml.JSONToYAML(b)
-	if err != nil {
-		Fatal(cmd, fmt.Sprintf("Able to marshal to YAML but not JSON whatttt '%s': %s\n", j.ID, err), 1)
-	}
-
-	cmd.Print(string(y))
-
 	return nil
 }
@@ -0,0 +1,129 @@
+package bacalhau
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/filecoin-project/bacalhau/pkg/job"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/publicapi"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/mattn/go-isatty"
+)
+
+// followView renders the live states of the shards of a job.
+type followView struct {
+	job *model.Job
+	// the nodes that have bid on each shard
+	bids map[int]map[string]bool
+	// whether the view is redrawn in place, rather than printed again
+	interactive bool
+	// the last rendered view
+	last []byte
+}
+
+// followJob renders a view of the shards of a job, updated as events arrive
+// on the event stream, until every shard has reached a terminal state.
+func followJob(ctx context.Context, client *publicapi.APIClient, out io.Writer, j *model.Job) error {
+	view := &followView{
+		job:         j,
+		bids:        map[int]map[string]bool{},
+		interactive: isTerminal(out),
+	}
+	isFinished := job.WaitForTerminalStates(job.GetJobTotalExecutionCount(j))
+
+	update := func() (bool, error) {
+		state, err := client.GetJobState(ctx, j.ID)
+		if err != nil {
+			return false, err
+		}
+		if err = view.render(out, state); err != nil {
+			return false, err
+		}
+		return isFinished(state)
+	}
+
+	if finished, err := update(); finished || err != nil {
+		return err
+	}
+	return client.StreamEvents(ctx, j.ID, func(event model.JobEvent) (bool, error) {
+		if event.EventName == model.JobEventBid {
+			if view.bids[event.ShardIndex] == nil {
+				view.bids[event.ShardIndex] = map[string]bool{}
+			}
+			view.bids[event.ShardIndex][event.SourceNodeID] = true
+		}
+		return update()
+	})
+}
+
+func (v *followView) render(out io.Writer, state model.JobState) error {
+	shardStates := job.FlattenShardStates(state)
+	sort.Slice(shardStates, func(i, j int) bool {
+		if shardStates[i].ShardIndex != shardStates[j].ShardIndex {
+			return shardStates[i].ShardIndex < shardStates[j].ShardIndex
+		}
+		return shardStates[i].NodeID < shardStates[j].NodeID
+	})
+
+	completedShards := map[int]bool{}
+	bids := 0
+	for _, shardState := range shardStates {
+		if shardState.State == model.JobStateCompleted {
+			completedShards[shardState.ShardIndex] = true
+		}
+	}
+	for _, nodes := range v.bids {
+		bids += len(nodes)
+	}
+
+	jobWithState := *v.job
+	jobWithState.State = state
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Job %s: %s, %d/%d shards completed, %d bids\n",
+		shortID(false, v.job.ID),
+		job.ComputeStateSummary(&jobWithState),
+		len(completedShards),
+		job.GetJobTotalShards(v.job),
+		bids,
+	)
+
+	tw := table.NewWriter()
+	tw.SetOutputMirror(&buf)
+	tw.AppendHeader(table.Row{"shard", "node", "state", "status"})
+	for _, shardState := range shardStates {
+		tw.AppendRow(table.Row{
+			shardState.ShardIndex,
+			shortID(false, shardState.NodeID),
+			shardState.State.String(),
+			shortenString(false, shardState.Status),
+		})
+	}
+	tw.SetStyle(table.StyleLight)
+	tw.Render()
+
+	rendered := buf.Bytes()
+	if bytes.Equal(rendered, v.last) {
+		return nil
+	}
+	if v.interactive && v.last != nil {
+		// move the cursor back up to the previous view and clear it
+		fmt.Fprintf(out, "\033[%dA\033[J", bytes.Count(v.last, []byte("\n")))
+	} else if v.last != nil {
+		fmt.Fprintln(out)
+	}
+	v.last = rendered
+	_, err := out.Write(rendered)
+	return err
+}
+
+func isTerminal(out io.Writer) bool {
+	file, ok := out.(*os.File)
+	return ok && isatty.IsTerminal(file.Fd()) && !strings.EqualFold(os.Getenv("TERM"), "dumb")
+}
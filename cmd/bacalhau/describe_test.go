@@ -7,13 +7,19 @@ import (
 	"fmt"
 	"net"
 	"net/url"
+	"strings"
 	"testing"
 
 	"github.com/filecoin-project/bacalhau/pkg/bacerrors"
+	"github.com/filecoin-project/bacalhau/pkg/devstack"
+	noop_executor "github.com/filecoin-project/bacalhau/pkg/executor/noop"
 	"github.com/filecoin-project/bacalhau/pkg/logger"
 	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/node"
 	"github.com/filecoin-project/bacalhau/pkg/publicapi"
+	"github.com/filecoin-project/bacalhau/pkg/requesternode"
 	"github.com/filecoin-project/bacalhau/pkg/system"
+	testutils "github.com/filecoin-project/bacalhau/pkg/test/utils"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
@@ -127,6 +133,19 @@ func (suite *DescribeSuite) TestDescribeJob() {
 					returnedJob.Spec.Docker.Entrypoint[0],
 					fmt.Sprintf("Submitted job entrypoints not the same as the description. %d - %d - %s - %d", tc.numberOfAcceptNodes, tc.numberOfRejectNodes, tc.jobState, n.numOfJobs))
 
+				// Json output
+				_, out, err = ExecuteTestCobraCommand(suite.T(), "describe",
+					"--api-host", host,
+					"--api-port", port,
+					"--output", "json",
+					submittedJob.ID,
+				)
+
+				require.NoError(suite.T(), err, "Error in describing job: %+v", err)
+				returnedJob = &model.Job{}
+				err = model.JSONUnmarshalWithMax([]byte(out), returnedJob)
+				require.NoError(suite.T(), err, "Error in unmarshalling description: %+v", err)
+				require.Equal(suite.T(), submittedJob.ID, returnedJob.ID, "IDs do not match.")
 			}()
 		}
 	}
@@ -258,6 +277,35 @@ func (s *DescribeSuite) TestDescribeJobEdgeCases() {
 
 }
 
+func (suite *DescribeSuite) TestDescribeFollow() {
+	ctx := context.Background()
+	stack := testutils.SetupTestWithNoopExecutor(ctx, suite.T(),
+		devstack.DevStackOptions{NumberOfNodes: 1},
+		node.NewComputeConfigWithDefaults(),
+		requesternode.NewDefaultRequesterNodeConfig(),
+		&noop_executor.ExecutorConfig{},
+	)
+	apiServer := stack.Nodes[0].APIServer
+	c := publicapi.NewAPIClient(apiServer.GetURI())
+
+	submittedJob, err := c.Submit(ctx, publicapi.MakeNoopJob(), nil)
+	require.NoError(suite.T(), err)
+
+	_, out, err := ExecuteTestCobraCommand(suite.T(), "describe",
+		"--api-host", apiServer.Host,
+		"--api-port", fmt.Sprintf("%d", apiServer.Port),
+		"--follow",
+		submittedJob.ID,
+	)
+	require.NoError(suite.T(), err)
+
+	// the last view shows the finished job
+	views := strings.Split(strings.TrimSpace(out), "\n\n")
+	lastView := views[len(views)-1]
+	require.Contains(suite.T(), lastView, "1/1 shards completed")
+	require.Contains(suite.T(), lastView, model.JobStateCompleted.String())
+}
+
 // In order for 'go test' to run this suite, we need to create
 // a normal test function and pass our suite to suite.Run
 func TestDescribeSuite(t *testing.T) {
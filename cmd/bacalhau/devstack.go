@@ -80,6 +80,10 @@ func newDevStackCmd() *cobra.Command {
 		&ODs.SimulatorURL, "simulator-url", ODs.SimulatorURL,
 		`Use the simulator transport at the given URL`,
 	)
+	devstackCmd.PersistentFlags().StringSliceVar(
+		&ODs.AllowedLocalPaths, "allow-local-paths", ODs.AllowedLocalPaths,
+		`Host paths that jobs can read directories under as local:///PATH inputs, e.g. a dataset being developed`,
+	)
 
 	setupJobSelectionCLIFlags(devstackCmd, OS)
 	setupCapacityManagerCLIFlags(devstackCmd, OS)
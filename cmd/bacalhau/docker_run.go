@@ -3,12 +3,19 @@ package bacalhau
 import (
 	"context"
 	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/filecoin-project/bacalhau/pkg/bacerrors"
+	"github.com/filecoin-project/bacalhau/pkg/compute/frontend"
 	"github.com/filecoin-project/bacalhau/pkg/ipfs"
 	jobutils "github.com/filecoin-project/bacalhau/pkg/job"
 	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/storage/inline"
 	"github.com/filecoin-project/bacalhau/pkg/system"
 	"github.com/filecoin-project/bacalhau/pkg/util/templates"
 	"github.com/filecoin-project/bacalhau/pkg/version"
@@ -32,41 +39,82 @@ var (
 			dpokidov/imagemagick:7.1.0-47-ubuntu \
 			-- magick mogrify -resize 100x100 -quality 100 -path /outputs '/input_images/*.jpg'
 			
+		# Pass a small file to the job on its stdin, like with docker run --interactive
+		cat data.csv | bacalhau docker run --stdin ubuntu wc -l
+
 		# Dry Run: Check the job specification before submitting it to the bacalhau network
 		bacalhau docker run --dry-run ubuntu echo hello
 
 		saving the job specification to a yaml file
 		bacalhau docker run --dry-run ubuntu echo hello > job.yaml
+
+		# Dry Run: Explain whether the node would bid on the job, and why not
+		bacalhau docker run --dry-run --explain --gpu 1 ubuntu nvidia-smi
 		`))
 )
 
 // DockerRunOptions declares the arguments accepted by the `docker run` command
 type DockerRunOptions struct {
-	Engine           string   // Executor - executor.Executor
-	Verifier         string   // Verifier - verifier.Verifier
-	Publisher        string   // Publisher - publisher.Publisher
-	Inputs           []string // Array of input CIDs
-	InputUrls        []string // Array of input URLs (will be copied to IPFS)
-	InputVolumes     []string // Array of input volumes in 'CID:mount point' form
-	OutputVolumes    []string // Array of output volumes in 'name:mount point' form
-	Env              []string // Array of environment variables
-	IDOnly           bool     // Only print the job ID
-	Concurrency      int      // Number of concurrent jobs to run
-	Confidence       int      // Minimum number of nodes that must agree on a verification result
-	MinBids          int      // Minimum number of bids before they will be accepted (at random)
-	Timeout          float64  // Job execution timeout in seconds
-	CPU              string
-	Memory           string
-	GPU              string
-	WorkingDirectory string   // Working directory for docker
-	Labels           []string // Labels for the job on the Bacalhau network (for searching)
+	Engine            string   // Executor - executor.Executor
+	Verifier          string   // Verifier - verifier.Verifier
+	Publisher         string   // Publisher - publisher.Publisher
+	Inputs            []string // Array of input CIDs
+	InputUrls         []string // Array of input URLs (will be copied to IPFS)
+	InputVolumes      []string // Array of input volumes in 'CID:mount point' form
+	OutputVolumes     []string // Array of output volumes in 'name:mount point' form
+	Env               []string // Array of environment variables
+	IDOnly            bool     // Only print the job ID
+	Concurrency       int      // Number of concurrent jobs to run
+	Confidence        int      // Minimum number of nodes that must agree on a verification result
+	MinBids           int      // Minimum number of bids before they will be accepted (at random)
+	TargetNodes       []string // IDs of the nodes to send the job to directly instead of gossiping it
+	ShardAntiAffinity bool     // Whether no two shards may run on the same node
+	MinZones          int      // The number of distinct zones the nodes running each shard must be in
+	PreferGreenNodes  bool     // Whether to favor nodes running on greener energy
+	Timeout           float64  // Job execution timeout in seconds
+	CPU               string
+	Memory            string
+	GPU               string
+	WorkingDirectory  string   // Working directory for docker
+	User              string   // The user to run the container as, a name or UID with an optional group
+	Labels            []string // Labels for the job on the Bacalhau network (for searching)
+
+	Deadline time.Duration // How long after submission the job must have completed by
+	Spot     bool          // Whether compute nodes may evict the job to make room for standard jobs
+	Scratch  []string      // PATH:SIZE of writable volumes kept on disk while the job runs
+	Tmpfs    []string      // PATH:SIZE of writable volumes kept in memory while the job runs
+	// OUTPUT:GLOB[:NAME] of the files of outputs to publish
+	Artifacts []string
+	// [ENV=]NAME of secrets for compute nodes to inject into the job
+	Secrets []string
+	// Whether to only run on nodes that harden their containers
+	RequireHardenedNodes bool
+	// Whether to only upload the blocks of the results not uploaded before
+	PublishIncrementally bool
+	// Whether shards complete before their results are published
+	PublishAsynchronously bool
+	// FILE[:PATH] of small local files to embed in the spec as inputs
+	InlineInputs []string
+	// Whether to pass the stdin of the client to the entrypoint of the job
+	Stdin bool
+	// Whether nodes that allow it keep the environment of failed shards
+	DebugOnFailure bool
+	// The endpoint that accepts or rejects the published results, for the webhook verifier
+	VerificationWebhook string
+	// How long to wait for the verification webhook to decide
+	VerificationWebhookTimeout time.Duration
+	// Whether to send the verification webhook where to download the results from
+	VerificationDownloadURLs bool
+	// How long the results can be challenged for, for the optimistic verifier
+	ChallengePeriod time.Duration
 
 	Image      string   // Image to execute
 	Entrypoint []string // Entrypoint to the docker image
 
 	SkipSyntaxChecking bool // Verify the syntax using shellcheck
 
-	DryRun bool // Don't submit the jobspec, print it to STDOUT
+	DryRun  bool // Don't submit the jobspec, print it to STDOUT
+	Explain bool // With DryRun, explain whether the node would bid on the job instead
 
 	RunTimeSettings RunTimeSettings // Settings for running the job
 
@@ -90,12 +138,17 @@ func NewDockerRunOptions() *DockerRunOptions {
 		Concurrency:        1,
 		Confidence:         0,
 		MinBids:            0, // 0 means no minimum before bidding
+		TargetNodes:        []string{},
+		ShardAntiAffinity:  false,
+		MinZones:           0,
+		PreferGreenNodes:   false,
 		Timeout:            DefaultTimeout.Seconds(),
 		CPU:                "",
 		Memory:             "",
 		GPU:                "",
 		SkipSyntaxChecking: false,
 		WorkingDirectory:   "",
+		User:               "",
 		Labels:             []string{},
 		DownloadFlags:      *ipfs.NewIPFSDownloadSettings(),
 		RunTimeSettings:    *NewRunTimeSettings(),
@@ -150,10 +203,38 @@ func newDockerRunCmd() *cobra.Command { //nolint:funlen
 		&ODR.Verifier, "verifier", ODR.Verifier,
 		`What verification engine to use to run the job`,
 	)
+	dockerRunCmd.PersistentFlags().StringVar(
+		&ODR.VerificationWebhook, "verification-webhook", ODR.VerificationWebhook,
+		`The URL the webhook verifier POSTs the published results of each shard to, to accept or reject them `+
+			`(with --verifier webhook)`,
+	)
+	dockerRunCmd.PersistentFlags().DurationVar(
+		&ODR.VerificationWebhookTimeout, "verification-webhook-timeout", ODR.VerificationWebhookTimeout,
+		`How long to wait for the verification webhook to decide, after which the shard fails (default 5m)`,
+	)
+	dockerRunCmd.PersistentFlags().BoolVar(
+		&ODR.VerificationDownloadURLs, "verification-download-urls", ODR.VerificationDownloadURLs,
+		`Send the verification webhook a URL to download each of the published results from`,
+	)
+	dockerRunCmd.PersistentFlags().DurationVar(
+		&ODR.ChallengePeriod, "challenge-period", ODR.ChallengePeriod,
+		`How long after the results of a shard are provisionally accepted they can be challenged with `+
+			`'bacalhau challenge' (with --verifier optimistic, the requester node's default if not set)`,
+	)
 	dockerRunCmd.PersistentFlags().StringVar(
 		&ODR.Publisher, "publisher", ODR.Publisher,
 		`What publisher engine to use to publish the job results`,
 	)
+	dockerRunCmd.PersistentFlags().BoolVar(
+		&ODR.PublishIncrementally, "publish-incrementally", ODR.PublishIncrementally,
+		`Only upload the blocks of the results that the publisher hasn't uploaded before, e.g. when the job runs `+
+			`again over a growing dataset (see 'serve --estuary-uploaded-blocks')`,
+	)
+	dockerRunCmd.PersistentFlags().BoolVar(
+		&ODR.PublishAsynchronously, "publish-async", ODR.PublishAsynchronously,
+		`Complete shards as soon as their results are ready on the compute node, and publish them in the `+
+			`background, which 'describe' reports the progress of`,
+	)
 	dockerRunCmd.PersistentFlags().StringSliceVarP(
 		&ODR.Inputs, "inputs", "i", ODR.Inputs,
 		`CIDs to use on the job. Mounts them at '/inputs' in the execution.`,
@@ -164,7 +245,27 @@ func newDockerRunCmd() *cobra.Command { //nolint:funlen
 		&ODR.InputUrls, "input-urls", "u", ODR.InputUrls,
 		`URL of the input data volumes downloaded from a URL source. Mounts data at '/inputs' (e.g. '-u https://example.com/bar.tar.gz'
 		mounts 'bar.tar.gz' at '/inputs/bar.tar.gz'). URL accept any valid URL supported by the 'wget' command,
-		and supports both HTTP and HTTPS.`,
+		and supports both HTTP and HTTPS. Objects in Google Cloud Storage and Azure Blob Storage can be mounted with
+		gs://bucket/path and az://account/container/path URLs, which mount a single object or every object under the path,
+		snapshots of Hugging Face models with hf://org/model@revision URLs, and the directories compute nodes share
+		as local://name URLs, so that the job only runs on those nodes.`,
+	)
+	dockerRunCmd.PersistentFlags().StringSliceVar(
+		&ODR.InlineInputs, "inline-inputs", ODR.InlineInputs,
+		fmt.Sprintf(`FILE[:PATH] of small local files, e.g. scripts or configs of up to %s, to embed in the job `+
+			`spec instead of uploading to IPFS. Mounts them at PATH, or '/inputs/<name of the file>' by default.`,
+			inline.MaxSize.HumanReadable()),
+	)
+	dockerRunCmd.PersistentFlags().BoolVar(
+		&ODR.DebugOnFailure, "debug-on-failure", ODR.DebugOnFailure,
+		`Ask the nodes to keep the environment of shards that fail, so that you can attach a shell to it with `+
+			`'bacalhau debug'. Only nodes that allow it keep it, for as long as they allow.`,
+	)
+	dockerRunCmd.PersistentFlags().BoolVar(
+		&ODR.Stdin, "stdin", ODR.Stdin,
+		fmt.Sprintf(`Pass what is piped into this command, up to %s, to the entrypoint of the job on its stdin, `+
+			`e.g. 'cat data.csv | bacalhau docker run --stdin ubuntu wc -l'. It is also mounted at '%s'.`,
+			inline.MaxSize.HumanReadable(), stdinPath),
 	)
 	dockerRunCmd.PersistentFlags().StringSliceVarP(
 		&ODR.InputVolumes, "input-volumes", "v", ODR.InputVolumes,
@@ -190,6 +291,55 @@ func newDockerRunCmd() *cobra.Command { //nolint:funlen
 		&ODR.MinBids, "min-bids", ODR.MinBids,
 		`Minimum number of bids that must be received before concurrency-many bids will be accepted (at random)`,
 	)
+	dockerRunCmd.PersistentFlags().StringSliceVar(
+		&ODR.TargetNodes, "target-nodes", ODR.TargetNodes,
+		`IDs of the nodes to send the job to directly, instead of offering it to the whole network`,
+	)
+	dockerRunCmd.PersistentFlags().BoolVar(
+		&ODR.ShardAntiAffinity, "shard-anti-affinity", ODR.ShardAntiAffinity,
+		`Run no two shards of the job on the same node, so that losing a node loses at most one shard`,
+	)
+	dockerRunCmd.PersistentFlags().IntVar(
+		&ODR.MinZones, "min-zones", ODR.MinZones,
+		`Spread the nodes running each shard across at least this many zones (see 'serve --zone'), `+
+			`nodes without a zone counting as their own`,
+	)
+	dockerRunCmd.PersistentFlags().BoolVar(
+		&ODR.PreferGreenNodes, "prefer-green-nodes", ODR.PreferGreenNodes,
+		`Favor nodes that run on renewable power, then those whose power has a lower carbon intensity`,
+	)
+	dockerRunCmd.PersistentFlags().BoolVar(
+		&ODR.RequireHardenedNodes, "require-hardened-nodes", ODR.RequireHardenedNodes,
+		`Only run on nodes that run containers with a seccomp or AppArmor profile of their own and a pids limit `+
+			`(see 'serve --docker-seccomp-profile')`,
+	)
+	dockerRunCmd.PersistentFlags().DurationVar(
+		&ODR.Deadline, "deadline", ODR.Deadline,
+		`If set, the job fails if it hasn't been scheduled and completed within this long of being submitted (e.g. 1h)`,
+	)
+	dockerRunCmd.PersistentFlags().BoolVar(
+		&ODR.Spot, "spot", ODR.Spot,
+		`Run as a spot job, on spare capacity that compute nodes may reclaim at any time by evicting the job`,
+	)
+	dockerRunCmd.PersistentFlags().StringSliceVar(
+		&ODR.Scratch, "scratch", ODR.Scratch,
+		`PATH:SIZE of a writable volume the job can use while it runs, which is thrown away afterwards `+
+			`(e.g. '--scratch /scratch:10Gb'). Input volumes are always read-only`,
+	)
+	dockerRunCmd.PersistentFlags().StringSliceVar(
+		&ODR.Tmpfs, "tmpfs", ODR.Tmpfs,
+		`PATH:SIZE of a writable volume kept in memory while the job runs (e.g. '--tmpfs /tmp:500Mb')`,
+	)
+	dockerRunCmd.PersistentFlags().StringSliceVar(
+		&ODR.Secrets, "secret", ODR.Secrets,
+		`[ENV=]NAME of a secret that compute nodes allowing it fetch from their secrets backend and set as an environment `+
+			`variable, which is never part of the job spec (e.g. '--secret API_TOKEN' or '--secret AWS_KEY=aws/key')`,
+	)
+	dockerRunCmd.PersistentFlags().StringSliceVar(
+		&ODR.Artifacts, "artifact", ODR.Artifacts,
+		`OUTPUT:GLOB[:NAME] of files of an output volume to publish, optionally under another path. If an output `+
+			`has any artifacts only they are published (e.g. '--artifact outputs:**/*.csv --artifact outputs:model.bin:weights.bin')`,
+	)
 	dockerRunCmd.PersistentFlags().Float64Var(
 		&ODR.Timeout, "timeout", ODR.Timeout,
 		`Job execution timeout in seconds (e.g. 300 for 5 minutes and 0.1 for 100ms)`,
@@ -204,7 +354,8 @@ func newDockerRunCmd() *cobra.Command { //nolint:funlen
 	)
 	dockerRunCmd.PersistentFlags().StringVar(
 		&ODR.GPU, "gpu", ODR.GPU,
-		`Job GPU requirement (e.g. 1, 2, 8).`,
+		`Job GPU requirement (e.g. 1, 2, 8). A fraction of a GPU (e.g. 500m or 0.5) runs on a MIG slice `+
+			`of a GPU or shares one with other jobs.`,
 	)
 	dockerRunCmd.PersistentFlags().BoolVar(
 		&ODR.SkipSyntaxChecking, "skip-syntax-checking", ODR.SkipSyntaxChecking,
@@ -215,11 +366,20 @@ func newDockerRunCmd() *cobra.Command { //nolint:funlen
 		&ODR.DryRun, "dry-run", ODR.DryRun,
 		`Do not submit the job, but instead print out what will be submitted`,
 	)
+	dockerRunCmd.PersistentFlags().BoolVar(
+		&ODR.Explain, "explain", ODR.Explain,
+		`With --dry-run, explain whether the node would bid on the job, and why not, rather than printing the job`,
+	)
 
 	dockerRunCmd.PersistentFlags().StringVarP(
 		&ODR.WorkingDirectory, "workdir", "w", ODR.WorkingDirectory,
 		`Working directory inside the container. Overrides the working directory shipped with the image (e.g. via WORKDIR in Dockerfile).`,
 	)
+	dockerRunCmd.PersistentFlags().StringVar(
+		&ODR.User, "user", ODR.User,
+		`The user to run the container as, a name or UID with an optional group, e.g. 1000:1000. `+
+			`Overrides the user of the image. Compute nodes may run jobs that would run as root as a non-root user.`,
+	)
 
 	dockerRunCmd.PersistentFlags().StringSliceVarP(
 		&ODR.Labels, "labels", "l", ODR.Labels,
@@ -261,6 +421,16 @@ func dockerRun(cmd *cobra.Command, cmdArgs []string, ODR *DockerRunOptions) erro
 		Fatal(cmd, fmt.Sprintf("Error creating job: %s", err), 1)
 		return nil
 	}
+	if ODR.Stdin {
+		var stdin model.StorageSpec
+		stdin, err = buildStdinInput(cmd.InOrStdin())
+		if err != nil {
+			Fatal(cmd, fmt.Sprintf("Error reading stdin: %s", err), 1)
+			return nil
+		}
+		j.Spec.Inputs = append(j.Spec.Inputs, stdin)
+		j.Spec.Docker.Stdin = stdin.Path
+	}
 	err = jobutils.VerifyJob(ctx, j)
 	if err != nil {
 		if _, ok := err.(*bacerrors.ImageNotFound); ok {
@@ -271,6 +441,19 @@ func dockerRun(cmd *cobra.Command, cmdArgs []string, ODR *DockerRunOptions) erro
 			return nil
 		}
 	}
+	if ODR.Explain && !ODR.DryRun {
+		Fatal(cmd, "--explain can only be used with --dry-run", 1)
+		return nil
+	}
+	if ODR.Explain {
+		explanation, explainErr := GetAPIClient().DryRun(ctx, j)
+		if explainErr != nil {
+			Fatal(cmd, fmt.Sprintf("Error explaining job: %s", explainErr), 1)
+			return nil
+		}
+		printBidExplanation(cmd, explanation)
+		return nil
+	}
 	if ODR.DryRun {
 		// Converting job to yaml
 		var yamlBytes []byte
@@ -306,7 +489,7 @@ func CreateJob(ctx context.Context,
 	swarmAddresses := odr.DownloadFlags.IPFSSwarmAddrs
 
 	if swarmAddresses == "" {
-		swarmAddresses = strings.Join(system.Envs[system.Production].IPFSSwarmAddresses, ",")
+		swarmAddresses = strings.Join(selectedNetwork.IPFSSwarmAddresses, ",")
 	}
 
 	odr.DownloadFlags = ipfs.IPFSDownloadSettings{
@@ -370,6 +553,136 @@ func CreateJob(ctx context.Context,
 	if err != nil {
 		return &model.Job{}, errors.Wrap(err, "CreateJobSpecAndDeal")
 	}
+	if len(odr.TargetNodes) > 0 {
+		j.Deal.TargetNodes = odr.TargetNodes
+	}
+	j.Spec.Docker.User = odr.User
+	j.Spec.PublishIncrementally = odr.PublishIncrementally
+	j.Spec.PublishAsynchronously = odr.PublishAsynchronously
+	j.Spec.DebugOnFailure = odr.DebugOnFailure
+	j.Spec.VerificationWebhook = model.VerificationWebhook{
+		URL:                odr.VerificationWebhook,
+		Timeout:            odr.VerificationWebhookTimeout.Seconds(),
+		IncludeDownloadURL: odr.VerificationDownloadURLs,
+	}
+	j.Spec.ChallengePeriod = odr.ChallengePeriod.Seconds()
+	j.Deal.ShardAntiAffinity = odr.ShardAntiAffinity
+	j.Deal.MinZones = odr.MinZones
+	j.Deal.PreferGreenNodes = odr.PreferGreenNodes
+	j.Deal.RequireHardenedNodes = odr.RequireHardenedNodes
+	if odr.Deadline > 0 {
+		j.Deal.Deadline = time.Now().Add(odr.Deadline)
+	}
+	if odr.Spot {
+		j.Deal.Class = model.JobClassSpot
+	}
+	for _, flags := range []struct {
+		values []string
+		tmpfs  bool
+	}{{odr.Scratch, false}, {odr.Tmpfs, true}} {
+		for _, value := range flags.values {
+			path, size, ok := strings.Cut(value, ":")
+			if !ok {
+				return &model.Job{}, fmt.Errorf("invalid scratch volume %q, must be PATH:SIZE", value)
+			}
+			j.Spec.Scratch = append(j.Spec.Scratch, model.ScratchVolume{Path: path, Size: size, Tmpfs: flags.tmpfs})
+		}
+	}
+	for _, value := range odr.InlineInputs {
+		spec, err := buildInlineInput(value)
+		if err != nil {
+			return &model.Job{}, err
+		}
+		j.Spec.Inputs = append(j.Spec.Inputs, spec)
+	}
+	for _, value := range odr.Secrets {
+		secret := model.SecretSpec{Name: value}
+		if env, name, ok := strings.Cut(value, "="); ok {
+			secret = model.SecretSpec{Name: name, Env: env}
+		}
+		j.Spec.Secrets = append(j.Spec.Secrets, secret)
+	}
+	for _, value := range odr.Artifacts {
+		parts := strings.Split(value, ":")
+		if len(parts) < 2 || len(parts) > 3 {
+			return &model.Job{}, fmt.Errorf("invalid artifact %q, must be OUTPUT:GLOB[:NAME]", value)
+		}
+		artifact := model.OutputArtifact{Glob: parts[1]}
+		if len(parts) == 3 {
+			artifact.Name = parts[2]
+		}
+		found := false
+		for i := range j.Spec.Outputs {
+			if j.Spec.Outputs[i].Name == parts[0] {
+				j.Spec.Outputs[i].Artifacts = append(j.Spec.Outputs[i].Artifacts, artifact)
+				found = true
+			}
+		}
+		if !found {
+			return &model.Job{}, fmt.Errorf("invalid artifact %q, there is no output volume named %s", value, parts[0])
+		}
+	}
 
 	return j, nil
 }
+
+// buildInlineInput reads a local file given as FILE[:PATH] and returns the
+// spec that embeds it in the job.
+func buildInlineInput(value string) (model.StorageSpec, error) {
+	filePath, mountPath, ok := strings.Cut(value, ":")
+	if !ok {
+		mountPath = path.Join("/inputs", filepath.Base(filePath))
+	}
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return model.StorageSpec{}, fmt.Errorf("invalid inline input %q: %w", value, err)
+	}
+	if info.Size() > int64(inline.MaxSize.Bytes()) {
+		return model.StorageSpec{}, fmt.Errorf("inline input %s is larger than the %s allowed, use IPFS instead",
+			filePath, inline.MaxSize.HumanReadable())
+	}
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return model.StorageSpec{}, fmt.Errorf("invalid inline input %q: %w", value, err)
+	}
+	return model.StorageSpec{
+		StorageSource: model.StorageSourceInline,
+		URL:           inline.NewURL(content),
+		Path:          mountPath,
+	}, nil
+}
+
+// stdinPath is where the stdin of the client is mounted in jobs, outside of
+// /inputs so that it can't clash with the other inputs.
+const stdinPath = "/stdin"
+
+// buildStdinInput reads what was piped into the client and returns the
+// spec that embeds it in the job.
+func buildStdinInput(reader io.Reader) (model.StorageSpec, error) {
+	content, err := io.ReadAll(io.LimitReader(reader, int64(inline.MaxSize.Bytes())+1))
+	if err != nil {
+		return model.StorageSpec{}, err
+	}
+	if uint64(len(content)) > inline.MaxSize.Bytes() {
+		return model.StorageSpec{}, fmt.Errorf("stdin is larger than the %s allowed, use IPFS instead",
+			inline.MaxSize.HumanReadable())
+	}
+	return model.StorageSpec{
+		StorageSource: model.StorageSourceInline,
+		URL:           inline.NewURL(content),
+		Path:          stdinPath,
+	}, nil
+}
+
+// printBidExplanation prints whether a node would bid on a job, and why not.
+func printBidExplanation(cmd *cobra.Command, explanation frontend.ExplainBidResponse) {
+	if explanation.Bid {
+		cmd.Printf("Node %s would bid on the job.\n", explanation.NodeID)
+	} else {
+		cmd.Printf("Node %s would not bid on the job:\n", explanation.NodeID)
+		for _, reason := range explanation.Reasons {
+			cmd.Printf("  - %s\n", reason)
+		}
+	}
+	cmd.Println("Only the node serving the API was asked, other nodes may have different resources and policies.")
+}
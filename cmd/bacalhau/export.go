@@ -0,0 +1,153 @@
+package bacalhau
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/filecoin-project/bacalhau/pkg/localdb"
+	"github.com/filecoin-project/bacalhau/pkg/system"
+	"github.com/filecoin-project/bacalhau/pkg/util/templates"
+	"github.com/spf13/cobra"
+	"k8s.io/kubectl/pkg/util/i18n"
+)
+
+var (
+	exportLong = templates.LongDesc(i18n.T(`
+		Export the jobs or the job events of the requester node as CSV or
+		Parquet, to analyze the utilization of the network and the success
+		rate of jobs in other tools. Jobs are selected by when they were
+		created and events by when they happened.
+`))
+
+	exportExample = templates.Examples(i18n.T(`
+		# Export all the jobs as CSV
+		bacalhau export > jobs.csv
+
+		# Export the events of the last week as Parquet
+		bacalhau export --kind events --format parquet --since 168h -o events.parquet
+
+		# Export the jobs created in October
+		bacalhau export --since 2022-10-01T00:00:00Z --until 2022-11-01T00:00:00Z
+`))
+)
+
+type ExportOptions struct {
+	Kind       string // What to export, jobs or events
+	Format     string // The file format to export in, csv or parquet
+	Since      string // An RFC 3339 time or a duration ago to export from
+	Until      string // An RFC 3339 time or a duration ago to export until
+	OutputFile string // Where to write the export, stdout if not set
+}
+
+func NewExportOptions() *ExportOptions {
+	return &ExportOptions{
+		Kind:   string(localdb.ExportJobs),
+		Format: string(localdb.ExportCSV),
+	}
+}
+
+func newExportCmd() *cobra.Command {
+	OE := NewExportOptions()
+
+	exportCmd := &cobra.Command{
+		Use:     "export",
+		Short:   "Export jobs or events as CSV or Parquet",
+		Long:    exportLong,
+		Example: exportExample,
+		Args:    cobra.NoArgs,
+		PreRun:  applyPorcelainLogLevel,
+		RunE: func(cmd *cobra.Command, cmdArgs []string) error {
+			return export(cmd, OE)
+		},
+	}
+
+	exportCmd.PersistentFlags().StringVar(
+		&OE.Kind, "kind", OE.Kind,
+		`What to export: jobs or events`,
+	)
+	exportCmd.PersistentFlags().StringVar(
+		&OE.Format, "format", OE.Format,
+		`The file format to export in: csv or parquet`,
+	)
+	exportCmd.PersistentFlags().StringVar(
+		&OE.Since, "since", OE.Since,
+		`Export from this RFC 3339 time, or this long ago (e.g. 24h)`,
+	)
+	exportCmd.PersistentFlags().StringVar(
+		&OE.Until, "until", OE.Until,
+		`Export until this RFC 3339 time, or this long ago (e.g. 1h)`,
+	)
+	exportCmd.PersistentFlags().StringVarP(
+		&OE.OutputFile, "output-file", "o", OE.OutputFile,
+		`The file to write the export to, stdout if not set`,
+	)
+
+	return exportCmd
+}
+
+func export(cmd *cobra.Command, OE *ExportOptions) error {
+	cm := system.NewCleanupManager()
+	defer cm.Cleanup()
+	ctx := cmd.Context()
+
+	ctx, rootSpan := system.NewRootSpan(ctx, system.GetTracer(), "cmd/bacalhau/export")
+	defer rootSpan.End()
+	cm.RegisterCallback(system.CleanupTraceProvider)
+
+	query := localdb.ExportQuery{
+		Kind:   localdb.ExportKind(OE.Kind),
+		Format: localdb.ExportFormat(OE.Format),
+	}
+	var err error
+	now := time.Now()
+	if query.Since, err = parseExportTime(OE.Since, now); err != nil {
+		Fatal(cmd, fmt.Sprintf("Invalid --since: %s", err), 1)
+		return nil
+	}
+	if query.Until, err = parseExportTime(OE.Until, now); err != nil {
+		Fatal(cmd, fmt.Sprintf("Invalid --until: %s", err), 1)
+		return nil
+	}
+	if err = query.Validate(); err != nil {
+		Fatal(cmd, err.Error(), 1)
+		return nil
+	}
+
+	if OE.OutputFile == "" {
+		if err = GetAPIClient().Export(ctx, query, cmd.OutOrStdout()); err != nil {
+			Fatal(cmd, fmt.Sprintf("Error exporting %s: %s", query.Kind, err), 1)
+		}
+		return nil
+	}
+
+	file, err := os.Create(OE.OutputFile)
+	if err != nil {
+		Fatal(cmd, fmt.Sprintf("Error creating %s: %s", OE.OutputFile, err), 1)
+		return nil
+	}
+	err = GetAPIClient().Export(ctx, query, file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		Fatal(cmd, fmt.Sprintf("Error exporting %s: %s", query.Kind, err), 1)
+	}
+	return nil
+}
+
+// parseExportTime parses an RFC 3339 time, or a duration before now. It
+// returns the zero time, for no bound, if value is empty.
+func parseExportTime(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is neither an RFC 3339 time nor a duration", value)
+	}
+	return t, nil
+}
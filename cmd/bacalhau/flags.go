@@ -4,8 +4,8 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/filecoin-project/bacalhau/pkg/job"
 	"github.com/filecoin-project/bacalhau/pkg/model"
-	"github.com/filecoin-project/bacalhau/pkg/storage/url/urldownload"
 	"github.com/spf13/pflag"
 )
 
@@ -179,15 +179,7 @@ func NewIPFSStorageSpecArrayFlag(value *[]model.StorageSpec) *ArrayValueFlag[mod
 }
 
 func parseURLStorageSpec(inputURL string) (model.StorageSpec, error) {
-	u, err := urldownload.IsURLSupported(inputURL)
-	if err != nil {
-		return model.StorageSpec{}, err
-	}
-	return model.StorageSpec{
-		StorageSource: model.StorageSourceURLDownload,
-		URL:           u.String(),
-		Path:          "/inputs",
-	}, nil
+	return job.ParseInputURL(inputURL)
 }
 
 func NewURLStorageSpecArrayFlag(value *[]model.StorageSpec) *ArrayValueFlag[model.StorageSpec] {
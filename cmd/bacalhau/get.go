@@ -1,7 +1,12 @@
 package bacalhau
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/filecoin-project/bacalhau/pkg/ipfs"
 	"github.com/filecoin-project/bacalhau/pkg/system"
@@ -23,11 +28,28 @@ var (
 
 		# Get the results of a job, with a short ID.
 		bacalhau get ebd9bf2f
+
+		# Get the results of a job, and print where they were written as json
+		bacalhau get --output json ebd9bf2f
+
+		# Print the stdout of a job, rather than downloading its results to a directory.
+		bacalhau get ebd9bf2f --output-file - | jq .
+
+		# Write a single file of the results of a job to a file.
+		bacalhau get ebd9bf2f --path outputs/report.csv --output-file report.csv
+
+		# Get the results of the first two shards of a job, which can be done
+		# as soon as they have completed, before the rest of the job has.
+		bacalhau get ebd9bf2f --shards 0,1
 `))
 )
 
 type GetOptions struct {
 	IPFSDownloadSettings ipfs.IPFSDownloadSettings
+	OutputFormat         string // The output format for the downloaded results (yaml or json)
+	OutputFile           string // Where to write a single result file to, "-" for stdout
+	Path                 string // The path of the result file to write, relative to the combined results
+	ShardIndexes         []int  // The shards to get the results of, all of them if empty
 }
 
 func NewGetOptions() *GetOptions {
@@ -37,6 +59,8 @@ func NewGetOptions() *GetOptions {
 			OutputDir:      "",
 			IPFSSwarmAddrs: "",
 		},
+		Path:         ipfs.DownloadFilenameStdout,
+		ShardIndexes: []int{},
 	}
 }
 
@@ -56,6 +80,25 @@ func newGetCmd() *cobra.Command {
 	}
 
 	getCmd.PersistentFlags().AddFlagSet(NewIPFSDownloadFlags(&OG.IPFSDownloadSettings))
+	getCmd.PersistentFlags().StringVar(
+		&OG.OutputFormat, "output", OG.OutputFormat,
+		`Print where the results were downloaded to in a machine readable format (yaml or json), rather than just the directory`,
+	)
+	getCmd.PersistentFlags().StringVar(
+		&OG.OutputFile, "output-file", OG.OutputFile,
+		`Write a single result file to this path rather than downloading the results to a directory, `+
+			`'-' writes it to stdout. See --path for which file is written`,
+	)
+	getCmd.PersistentFlags().StringVar(
+		&OG.Path, "path", OG.Path,
+		`The path of the result file written with --output-file, relative to the combined results `+
+			`(e.g. 'stdout', 'stderr' or 'outputs/report.csv')`,
+	)
+	getCmd.PersistentFlags().IntSliceVar(
+		&OG.ShardIndexes, "shards", OG.ShardIndexes,
+		`Only get the results of these shards (e.g. '--shards 0,1'), which have completed. `+
+			`Gets the results of all the completed shards if not set`,
+	)
 
 	return getCmd
 }
@@ -69,6 +112,17 @@ func get(cmd *cobra.Command, cmdArgs []string, OG *GetOptions) error {
 	defer span.End()
 	cm.RegisterCallback(system.CleanupTraceProvider)
 
+	if OG.OutputFormat != "" {
+		if err := validateOutputFormat(OG.OutputFormat); err != nil {
+			Fatal(cmd, err.Error(), 1)
+			return nil
+		}
+	}
+	if OG.OutputFile != "" && (OG.OutputFormat != "" || OG.IPFSDownloadSettings.OutputDir != "") {
+		Fatal(cmd, "--output-file cannot be used with --output or --output-dir", 1)
+		return nil
+	}
+
 	var err error
 
 	jobID := cmdArgs[0]
@@ -82,17 +136,88 @@ func get(cmd *cobra.Command, cmdArgs []string, OG *GetOptions) error {
 		jobID = string(byteResult)
 	}
 
-	err = downloadResultsHandler(
-		ctx,
-		cm,
-		cmd,
-		jobID,
-		OG.IPFSDownloadSettings,
-	)
+	if OG.OutputFile != "" {
+		return getResultFile(ctx, cm, cmd, jobID, OG)
+	}
+
+	if OG.OutputFormat == "" {
+		err = downloadResultsHandler(
+			ctx,
+			cm,
+			cmd,
+			jobID,
+			OG.IPFSDownloadSettings,
+			OG.ShardIndexes,
+		)
+		if err != nil {
+			return errors.Wrap(err, "error downloading job")
+		}
+		return nil
+	}
+
+	downloaded, err := downloadResults(ctx, cm, cmd, jobID, OG.IPFSDownloadSettings, OG.ShardIndexes)
+	if err != nil {
+		return errors.Wrap(err, "error downloading job")
+	}
+	return printFormatted(cmd, OG.OutputFormat, downloaded)
+}
 
+// getResultFile downloads the results of a job to a temporary directory and
+// writes a single file of them to the output file, or stdout.
+func getResultFile(ctx context.Context, cm *system.CleanupManager, cmd *cobra.Command, jobID string, OG *GetOptions) error {
+	resultsDir, err := os.MkdirTemp("", "bacalhau-get-*")
 	if err != nil {
+		return err
+	}
+	cm.RegisterCallback(func() error {
+		return os.RemoveAll(resultsDir)
+	})
+
+	settings := OG.IPFSDownloadSettings
+	settings.OutputDir = resultsDir
+	if _, err = downloadResults(ctx, cm, cmd, jobID, settings, OG.ShardIndexes); err != nil {
 		return errors.Wrap(err, "error downloading job")
 	}
 
-	return nil
+	if OG.OutputFile == "-" {
+		return writeResultFile(cmd.OutOrStdout(), resultsDir, OG.Path)
+	}
+
+	file, err := os.Create(OG.OutputFile)
+	if err != nil {
+		return err
+	}
+	if err = writeResultFile(file, resultsDir, OG.Path); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// writeResultFile copies the file at path in the combined results of a job
+// downloaded to resultsDir to w.
+func writeResultFile(w io.Writer, resultsDir, path string) error {
+	filePath := filepath.Join(resultsDir, ipfs.DownloadVolumesFolderName, filepath.FromSlash(path))
+	if relative, err := filepath.Rel(resultsDir, filePath); err != nil || strings.HasPrefix(relative, "..") {
+		return fmt.Errorf("%s is not a path in the results", path)
+	}
+
+	info, err := os.Stat(filePath)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%s is not in the results of the job", path)
+	} else if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is a directory, set --path to a file in it", path)
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = io.Copy(w, file)
+	return err
 }
@@ -269,3 +269,32 @@ func (s *GetSuite) TestGetWriteToJobFolderNamedDownload() {
 	testDownloadOutput(s.T(), getOutput, jobID, tempDir)
 	testResultsFolderStructure(s.T(), tempDir, hostID)
 }
+
+func TestWriteResultFile(t *testing.T) {
+	resultsDir := t.TempDir()
+	combinedDir := filepath.Join(resultsDir, ipfs.DownloadVolumesFolderName)
+	require.NoError(t, os.MkdirAll(filepath.Join(combinedDir, "outputs"), ipfs.DownloadFolderPerm))
+	require.NoError(t, os.WriteFile(filepath.Join(combinedDir, ipfs.DownloadFilenameStdout), []byte(`{"a":1}`), ipfs.DownloadFilePerm))
+	require.NoError(t, os.WriteFile(filepath.Join(combinedDir, "outputs", "report.csv"), []byte("a,b"), ipfs.DownloadFilePerm))
+
+	var buf strings.Builder
+	require.NoError(t, writeResultFile(&buf, resultsDir, ipfs.DownloadFilenameStdout))
+	require.Equal(t, `{"a":1}`, buf.String())
+
+	buf.Reset()
+	require.NoError(t, writeResultFile(&buf, resultsDir, "outputs/report.csv"))
+	require.Equal(t, "a,b", buf.String())
+
+	require.Error(t, writeResultFile(&buf, resultsDir, "outputs"))
+	require.Error(t, writeResultFile(&buf, resultsDir, "missing"))
+	require.Error(t, writeResultFile(&buf, resultsDir, "../../etc/passwd"))
+}
+
+func TestGetOutputFlags(t *testing.T) {
+	getCmd := newGetCmd()
+	require.NotNil(t, getCmd.PersistentFlags().Lookup("output"))
+	require.NotNil(t, getCmd.PersistentFlags().Lookup("output-file"))
+	// -o is the shorthand for --output elsewhere (e.g. bacalhau version), so
+	// it isn't taken for --output-file
+	require.Nil(t, getCmd.PersistentFlags().ShorthandLookup("o"))
+}
@@ -0,0 +1,146 @@
+package bacalhau
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/filecoin-project/bacalhau/pkg/system"
+	"github.com/filecoin-project/bacalhau/pkg/util/templates"
+	"github.com/spf13/cobra"
+	"k8s.io/kubectl/pkg/util/i18n"
+)
+
+var (
+	keyShowLong = templates.LongDesc(i18n.T(`
+		Show the client ID, public key and fingerprint of your user ID key, e.g.
+		to have compute nodes allowlist your client ID. With --node, show those of
+		the node the client talks to instead.
+`))
+
+	keyShowExample = templates.Examples(i18n.T(`
+		# Show your client ID and the fingerprint of your key
+		bacalhau key show
+
+		# Show the key of the node
+		bacalhau key show --node --output json
+`))
+
+	keyRotateLong = templates.LongDesc(i18n.T(`
+		Replace your user ID key with a new one. Your client ID stays the same, so
+		you keep managing the jobs you submitted and stay on the allowlists you
+		are on. Requests signed with the old key, e.g. by other machines holding
+		a copy of it, are still accepted until the grace period is over.
+`))
+
+	keyRotateExample = templates.Examples(i18n.T(`
+		# Rotate your key, accepting the old one for another day
+		bacalhau key rotate --grace-period 24h
+`))
+)
+
+type KeyOptions struct {
+	Node         bool          // Whether to show the key of the node rather than of the user
+	GracePeriod  time.Duration // How long the rotated out key is still accepted for
+	OutputFormat string        // The output format of the key
+}
+
+func NewKeyOptions() *KeyOptions {
+	return &KeyOptions{
+		GracePeriod:  time.Hour,
+		OutputFormat: YAMLFormat,
+	}
+}
+
+func newKeyCmd() *cobra.Command {
+	OK := NewKeyOptions()
+
+	keyCmd := &cobra.Command{
+		Use:   "key",
+		Short: "Show or rotate your user ID key",
+	}
+
+	showCmd := &cobra.Command{
+		Use:     "show",
+		Short:   "Show your client ID, public key and its fingerprint",
+		Long:    keyShowLong,
+		Example: keyShowExample,
+		Args:    cobra.NoArgs,
+		PreRun:  applyPorcelainLogLevel,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return showKey(cmd, OK)
+		},
+	}
+	showCmd.PersistentFlags().BoolVar(
+		&OK.Node, "node", OK.Node,
+		`Show the key of the node rather than yours`,
+	)
+	showCmd.PersistentFlags().StringVar(
+		&OK.OutputFormat, "output", OK.OutputFormat,
+		`The output format for the key (yaml or json)`,
+	)
+
+	rotateCmd := &cobra.Command{
+		Use:     "rotate",
+		Short:   "Replace your user ID key, keeping your client ID",
+		Long:    keyRotateLong,
+		Example: keyRotateExample,
+		Args:    cobra.NoArgs,
+		PreRun:  applyPorcelainLogLevel,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return rotateKey(cmd, OK)
+		},
+	}
+	rotateCmd.PersistentFlags().DurationVar(
+		&OK.GracePeriod, "grace-period", OK.GracePeriod,
+		`How long requests signed with the old key are still accepted for`,
+	)
+	rotateCmd.PersistentFlags().StringVar(
+		&OK.OutputFormat, "output", OK.OutputFormat,
+		`The output format for the new key (yaml or json)`,
+	)
+
+	keyCmd.AddCommand(showCmd, rotateCmd)
+	return keyCmd
+}
+
+func showKey(cmd *cobra.Command, OK *KeyOptions) error {
+	cm := system.NewCleanupManager()
+	defer cm.Cleanup()
+	ctx := cmd.Context()
+
+	ctx, rootSpan := system.NewRootSpan(ctx, system.GetTracer(), "cmd/bacalhau/key/show")
+	defer rootSpan.End()
+	cm.RegisterCallback(system.CleanupTraceProvider)
+
+	if err := validateOutputFormat(OK.OutputFormat); err != nil {
+		Fatal(cmd, err.Error(), 1)
+		return nil
+	}
+
+	info := system.GetClientKeyInfo()
+	if OK.Node {
+		var err error
+		if info, err = GetAPIClient().ClientKey(ctx); err != nil {
+			Fatal(cmd, fmt.Sprintf("Error getting the key of the node: %s", err), 1)
+			return nil
+		}
+	}
+	return printFormatted(cmd, OK.OutputFormat, info)
+}
+
+func rotateKey(cmd *cobra.Command, OK *KeyOptions) error {
+	if err := validateOutputFormat(OK.OutputFormat); err != nil {
+		Fatal(cmd, err.Error(), 1)
+		return nil
+	}
+	if OK.GracePeriod < 0 {
+		Fatal(cmd, "--grace-period must not be negative", 1)
+		return nil
+	}
+
+	if err := system.RotateUserIDKey(OK.GracePeriod); err != nil {
+		Fatal(cmd, fmt.Sprintf("Error rotating your key: %s", err), 1)
+		return nil
+	}
+	return printFormatted(cmd, OK.OutputFormat, system.GetClientKeyInfo())
+}
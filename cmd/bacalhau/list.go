@@ -27,7 +27,13 @@ var (
 		bacalhau list
 
 		# List jobs and output as json
-		bacalhau list --output json`))
+		bacalhau list --output json
+
+		# List the IDs of the jobs with jq
+		bacalhau list --output json | jq -r '.[].ID'
+
+		# List all the jobs submitted together in a batch
+		bacalhau list --job-group 1b7f5e2a-4f0e-4a1c-9d3b-9e8f0c6d2a71`))
 )
 
 type ListOptions struct {
@@ -35,11 +41,12 @@ type ListOptions struct {
 	IDFilter     string     // Filter by Job List to IDs matching substring.
 	NoStyle      bool       // Remove all styling from table output.
 	MaxJobs      int        // Print the first NUM jobs instead of the first 10.
-	OutputFormat string     // The output format for the list of jobs (json or text)
+	OutputFormat string     // The output format for the list of jobs (text, yaml or json)
 	SortReverse  bool       // Reverse order of table - for time sorting, this will be newest first.
 	SortBy       ColumnEnum // Sort by field, defaults to creation time, with newest first [Allowed "id", "created_at"].
 	OutputWide   bool       // Print full values in the table results
 	ReturnAll    bool       // Return all jobs, not just those that belong to the user
+	JobGroup     string     // List all the jobs of this job group, rather than the latest jobs
 }
 
 func NewListOptions() *ListOptions {
@@ -48,11 +55,12 @@ func NewListOptions() *ListOptions {
 		IDFilter:     "",
 		NoStyle:      false,
 		MaxJobs:      10,
-		OutputFormat: "text",
+		OutputFormat: TextFormat,
 		SortReverse:  true,
 		SortBy:       ColumnCreatedAt,
 		OutputWide:   false,
 		ReturnAll:    false,
+		JobGroup:     "",
 	}
 }
 
@@ -80,7 +88,7 @@ func newListCmd() *cobra.Command {
 	)
 	listCmd.PersistentFlags().StringVar(
 		&OL.OutputFormat, "output", OL.OutputFormat,
-		`The output format for the list of jobs (json or text)`,
+		`The output format for the list of jobs (text, yaml or json)`,
 	)
 	listCmd.PersistentFlags().BoolVar(&OL.SortReverse, "reverse", OL.SortReverse,
 		//nolint:lll // Documentation
@@ -102,6 +110,10 @@ func newListCmd() *cobra.Command {
 		//nolint:lll // Documentation
 		`Fetch all jobs from the network (default is to filter those belonging to the user). This option may take a long time to return, please use with caution.`,
 	)
+	listCmd.PersistentFlags().StringVar(
+		&OL.JobGroup, "job-group", OL.JobGroup,
+		`List all the jobs submitted in a batch with this job group, ignoring --number and --id-filter.`,
+	)
 
 	return listCmd
 }
@@ -153,7 +165,19 @@ func list(cmd *cobra.Command, OL *ListOptions) error {
 	log.Debug().Msgf("Found no-style header flag set to: %t", OL.NoStyle)
 	log.Debug().Msgf("Found output wide flag set to: %t", OL.OutputWide)
 
-	jobs, err := GetAPIClient().List(ctx, OL.IDFilter, OL.MaxJobs, OL.ReturnAll, OL.SortBy.String(), OL.SortReverse)
+	if err := validateOutputFormat(OL.OutputFormat, TextFormat); err != nil {
+		Fatal(cmd, err.Error(), 1)
+		return nil
+	}
+
+	var jobs []*model.Job
+	var err error
+	if OL.JobGroup != "" {
+		jobs, err = GetAPIClient().ListJobGroup(ctx, OL.JobGroup)
+		OL.MaxJobs = len(jobs)
+	} else {
+		jobs, err = GetAPIClient().List(ctx, OL.IDFilter, OL.MaxJobs, OL.ReturnAll, OL.SortBy.String(), OL.SortReverse)
+	}
 	if err != nil {
 		Fatal(cmd, fmt.Sprintf("Error listing jobs: %s", err), 1)
 	}
@@ -161,13 +185,10 @@ func list(cmd *cobra.Command, OL *ListOptions) error {
 	numberInTable := system.Min(OL.MaxJobs, len(jobs))
 	log.Debug().Msgf("Number of jobs printing: %d", numberInTable)
 
-	var msgBytes []byte
-	if OL.OutputFormat == JSONFormat {
-		msgBytes, err = model.JSONMarshalWithMax(jobs)
-		if err != nil {
-			Fatal(cmd, fmt.Sprintf("Error marshaling jobs to JSON: %s", err), 1)
+	if OL.OutputFormat != TextFormat {
+		if err = printFormatted(cmd, OL.OutputFormat, jobs); err != nil {
+			Fatal(cmd, fmt.Sprintf("Error marshaling jobs: %s", err), 1)
 		}
-		cmd.Printf("%s\n", msgBytes)
 	} else {
 		tw := table.NewWriter()
 		tw.SetOutputMirror(cmd.OutOrStderr())
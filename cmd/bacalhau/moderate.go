@@ -0,0 +1,132 @@
+package bacalhau
+
+import (
+	"fmt"
+
+	"github.com/filecoin-project/bacalhau/pkg/system"
+	"github.com/filecoin-project/bacalhau/pkg/util/templates"
+	"github.com/spf13/cobra"
+	"k8s.io/kubectl/pkg/util/i18n"
+)
+
+var (
+	moderateLong = templates.LongDesc(i18n.T(`
+		Flag a client or a job as abusive, which adds it to the denylist of the
+		requester node. Compute nodes started with --job-selection-denylist-url
+		don't take on the flagged jobs, nor any job of the flagged clients.
+		Only the --moderator of the requester node can flag.
+		Without a client or job, list the denylist.
+`))
+
+	moderateExample = templates.Examples(i18n.T(`
+		# List the flagged clients and jobs
+		bacalhau moderate
+
+		# Flag a client
+		bacalhau moderate --client ac13188e93c97a9c --reason "cryptomining"
+
+		# Flag a job
+		bacalhau moderate --job 51225160 --reason "port scanning"
+
+		# Unflag a client
+		bacalhau moderate --client ac13188e93c97a9c --unflag
+`))
+)
+
+type ModerateOptions struct {
+	ClientID string // The client to flag
+	JobID    string // The job to flag
+	Reason   string // Why the client or job is flagged
+	Unflag   bool   // Whether to unflag the client or job rather than flag it
+}
+
+func NewModerateOptions() *ModerateOptions {
+	return &ModerateOptions{}
+}
+
+func newModerateCmd() *cobra.Command {
+	OM := NewModerateOptions()
+
+	moderateCmd := &cobra.Command{
+		Use:     "moderate",
+		Short:   "Flag a client or a job as abusive, or list the flagged ones",
+		Long:    moderateLong,
+		Example: moderateExample,
+		Args:    cobra.NoArgs,
+		PreRun:  applyPorcelainLogLevel,
+		RunE: func(cmd *cobra.Command, cmdArgs []string) error {
+			return moderate(cmd, OM)
+		},
+	}
+
+	moderateCmd.PersistentFlags().StringVar(
+		&OM.ClientID, "client", OM.ClientID,
+		`The ID of the client to flag`,
+	)
+	moderateCmd.PersistentFlags().StringVar(
+		&OM.JobID, "job", OM.JobID,
+		`The ID of the job to flag`,
+	)
+	moderateCmd.PersistentFlags().StringVar(
+		&OM.Reason, "reason", OM.Reason,
+		`Why the client or job is flagged`,
+	)
+	moderateCmd.PersistentFlags().BoolVar(
+		&OM.Unflag, "unflag", OM.Unflag,
+		`Remove the client or job from the denylist rather than add it`,
+	)
+
+	return moderateCmd
+}
+
+func moderate(cmd *cobra.Command, OM *ModerateOptions) error {
+	cm := system.NewCleanupManager()
+	defer cm.Cleanup()
+	ctx := cmd.Context()
+
+	ctx, rootSpan := system.NewRootSpan(ctx, system.GetTracer(), "cmd/bacalhau/moderate")
+	defer rootSpan.End()
+	cm.RegisterCallback(system.CleanupTraceProvider)
+
+	if OM.ClientID == "" && OM.JobID == "" {
+		denylist, err := GetAPIClient().Denylist(ctx)
+		if err != nil {
+			Fatal(cmd, fmt.Sprintf("Error listing the denylist: %s", err), 1)
+			return nil
+		}
+		for _, entry := range denylist.Clients {
+			cmd.Printf("client\t%s\t%s\n", entry.ID, entry.Reason)
+		}
+		for _, entry := range denylist.Jobs {
+			cmd.Printf("job\t%s\t%s\n", entry.ID, entry.Reason)
+		}
+		return nil
+	}
+
+	jobID := OM.JobID
+	if jobID != "" {
+		// short job IDs are resolved when the requester node knows the job,
+		// as compute nodes match the denylist on full IDs
+		if j, found, err := GetAPIClient().Get(ctx, jobID); err == nil && found {
+			jobID = j.ID
+		}
+	}
+	target := "client " + OM.ClientID
+	if jobID != "" {
+		target = "job " + jobID
+	}
+	if OM.Unflag {
+		if err := GetAPIClient().Unflag(ctx, OM.ClientID, jobID); err != nil {
+			Fatal(cmd, fmt.Sprintf("Error unflagging %s: %s", target, err), 1)
+			return nil
+		}
+		cmd.Printf("Unflagged %s\n", target)
+		return nil
+	}
+	if err := GetAPIClient().Flag(ctx, OM.ClientID, jobID, OM.Reason); err != nil {
+		Fatal(cmd, fmt.Sprintf("Error flagging %s: %s", target, err), 1)
+		return nil
+	}
+	cmd.Printf("Flagged %s\n", target)
+	return nil
+}
@@ -0,0 +1,108 @@
+package bacalhau
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/filecoin-project/bacalhau/pkg/system"
+	"github.com/filecoin-project/bacalhau/pkg/transport/libp2p"
+	"github.com/filecoin-project/bacalhau/pkg/util/templates"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/spf13/cobra"
+	"k8s.io/kubectl/pkg/util/i18n"
+)
+
+var (
+	natStatusLong = templates.LongDesc(i18n.T(`
+		Show whether the node is reachable from the rest of the network.
+
+		Nodes behind a NAT that are not publicly reachable will often not hear
+		back from requester nodes. Consider running them with --auto-relay and
+		--hole-punching.
+`))
+
+	natStatusExample = templates.Examples(i18n.T(`
+		# Show the reachability of the node the client is connected to
+		bacalhau nat-status
+
+		# Output the reachability as json
+		bacalhau nat-status --output json`))
+)
+
+type NATStatusOptions struct {
+	OutputFormat string // The output format (json or text)
+}
+
+func NewNATStatusOptions() *NATStatusOptions {
+	return &NATStatusOptions{
+		OutputFormat: "text",
+	}
+}
+
+func newNATStatusCmd() *cobra.Command {
+	ONS := NewNATStatusOptions()
+
+	natStatusCmd := &cobra.Command{
+		Use:     "nat-status",
+		Short:   "Show whether the node is reachable from the rest of the network",
+		Long:    natStatusLong,
+		Example: natStatusExample,
+		PreRun:  applyPorcelainLogLevel,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return natStatus(cmd, ONS)
+		},
+	}
+
+	natStatusCmd.PersistentFlags().StringVar(
+		&ONS.OutputFormat, "output", ONS.OutputFormat,
+		`The output format (json or text)`,
+	)
+
+	return natStatusCmd
+}
+
+func natStatus(cmd *cobra.Command, ONS *NATStatusOptions) error {
+	cm := system.NewCleanupManager()
+	defer cm.Cleanup()
+	ctx := cmd.Context()
+
+	ctx, rootSpan := system.NewRootSpan(ctx, system.GetTracer(), "cmd/bacalhau/natStatus")
+	defer rootSpan.End()
+	cm.RegisterCallback(system.CleanupTraceProvider)
+
+	debugInfo, err := GetAPIClient().Debug(ctx)
+	if err != nil {
+		Fatal(cmd, fmt.Sprintf("Error getting debug info from node: %s", err), 1)
+		return nil
+	}
+
+	info, ok := debugInfo[libp2p.NATDebugInfoComponent]
+	if !ok {
+		Fatal(cmd, "The node did not report its reachability, it may not be using the libp2p transport.", 1)
+		return nil
+	}
+
+	if ONS.OutputFormat == JSONFormat {
+		cmd.Println(info)
+		return nil
+	}
+
+	status := libp2p.NATStatus{}
+	if err = json.Unmarshal([]byte(info), &status); err != nil {
+		Fatal(cmd, fmt.Sprintf("Error reading reachability: %s", err), 1)
+		return nil
+	}
+
+	cmd.Printf("Reachability:     %s\n", status.Reachability)
+	cmd.Printf("Relayed:          %t\n", status.Relayed)
+	cmd.Printf("Connected peers:  %d\n", status.ConnectedPeers)
+	cmd.Printf("Listen addresses: %s\n", strings.Join(status.ListenAddrs, ", "))
+	cmd.Printf("Advertised:       %s\n", strings.Join(status.AdvertisedAddrs, ", "))
+	if status.Reachability == network.ReachabilityUnknown.String() {
+		cmd.Println()
+		cmd.Println("Reachability is worked out by asking peers running the NAT service to dial back.")
+		cmd.Println("It stays Unknown until enough of them have answered, or if none of the peers run it (--nat-service).")
+	}
+	return nil
+}
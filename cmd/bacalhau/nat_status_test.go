@@ -0,0 +1,105 @@
+//go:build unit || !integration
+
+package bacalhau
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/filecoin-project/bacalhau/pkg/logger"
+	"github.com/filecoin-project/bacalhau/pkg/transport/libp2p"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestNATStatusSuite(t *testing.T) {
+	suite.Run(t, new(NATStatusSuite))
+}
+
+type NATStatusSuite struct {
+	suite.Suite
+}
+
+// Before each test
+func (suite *NATStatusSuite) SetupTest() {
+	logger.ConfigureTestLogging(suite.T())
+}
+
+// serveDebugInfo starts an API server that only answers /debug with the
+// given NAT status and returns its host and port.
+func (suite *NATStatusSuite) serveDebugInfo(status libp2p.NATStatus) (string, string) {
+	info, err := json.Marshal(status)
+	require.NoError(suite.T(), err)
+
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		require.Equal(suite.T(), "/debug", req.URL.Path)
+		require.NoError(suite.T(), json.NewEncoder(res).Encode(map[string]string{
+			libp2p.NATDebugInfoComponent: string(info),
+		}))
+	}))
+	suite.T().Cleanup(server.Close)
+
+	parsedURL, err := url.Parse(server.URL)
+	require.NoError(suite.T(), err)
+	host, port, err := net.SplitHostPort(parsedURL.Host)
+	require.NoError(suite.T(), err)
+	return host, port
+}
+
+func (suite *NATStatusSuite) TestNATStatus() {
+	host, port := suite.serveDebugInfo(libp2p.NATStatus{
+		Reachability:    "Private",
+		ListenAddrs:     []string{"/ip4/10.0.0.2/tcp/1235"},
+		AdvertisedAddrs: []string{"/ip4/1.2.3.4/tcp/4001/p2p/QmRelay/p2p-circuit"},
+		Relayed:         true,
+		ConnectedPeers:  3,
+	})
+
+	_, out, err := ExecuteTestCobraCommand(suite.T(), "nat-status",
+		"--api-host", host,
+		"--api-port", port,
+	)
+	require.NoError(suite.T(), err)
+	require.Contains(suite.T(), out, "Private")
+	require.Contains(suite.T(), out, "/ip4/10.0.0.2/tcp/1235")
+	require.Contains(suite.T(), out, "p2p-circuit")
+	require.NotContains(suite.T(), out, "--nat-service")
+}
+
+func (suite *NATStatusSuite) TestNATStatusUnknown() {
+	host, port := suite.serveDebugInfo(libp2p.NATStatus{Reachability: "Unknown"})
+
+	_, out, err := ExecuteTestCobraCommand(suite.T(), "nat-status",
+		"--api-host", host,
+		"--api-port", port,
+	)
+	require.NoError(suite.T(), err)
+	require.Contains(suite.T(), out, "--nat-service")
+}
+
+func (suite *NATStatusSuite) TestNATStatusJSON() {
+	expected := libp2p.NATStatus{
+		Reachability:    "Public",
+		ListenAddrs:     []string{"/ip4/1.2.3.4/tcp/1235"},
+		AdvertisedAddrs: []string{"/ip4/1.2.3.4/tcp/1235"},
+		ConnectedPeers:  5,
+	}
+	host, port := suite.serveDebugInfo(expected)
+
+	_, out, err := ExecuteTestCobraCommand(suite.T(), "nat-status",
+		"--api-host", host,
+		"--api-port", port,
+		"--output", JSONFormat,
+	)
+	require.NoError(suite.T(), err)
+
+	actual := libp2p.NATStatus{}
+	require.NoError(suite.T(), json.Unmarshal([]byte(out), &actual))
+	require.Equal(suite.T(), expected, actual)
+}
@@ -21,9 +21,15 @@ var Fatal = FatalErrorHandler
 var defaultAPIHost string
 var defaultAPIPort int
 
+// selectedNetwork is the network selected with --network, BACALHAU_NETWORK or
+// the config file, whose API servers and peers are used unless others are
+// given.
+var selectedNetwork = system.Envs[system.Production]
+
 func init() { //nolint:gochecknoinits
 	defaultAPIHost = system.Envs[system.Production].APIHost
 	defaultAPIPort = system.Envs[system.Production].APIPort
+	cobra.OnInitialize(applyNetwork)
 
 	if config.GetAPIHost() != "" {
 		defaultAPIHost = config.GetAPIHost()
@@ -73,14 +79,41 @@ func NewRootCmd() *cobra.Command {
 	// List jobs
 	RootCmd.AddCommand(newListCmd())
 
+	// Wait for jobs to finish
+	RootCmd.AddCommand(newWaitCmd())
+
+	// Export jobs and events for analytics
+	RootCmd.AddCommand(newExportCmd())
+
+	// ====== Manage jobs
+	// Cancel jobs
+	RootCmd.AddCommand(newCancelCmd())
+	// Debug failed shards
+	RootCmd.AddCommand(newDebugCmd())
+	// Approve jobs held for approval
+	RootCmd.AddCommand(newApproveCmd())
+	// Flag abusive clients and jobs
+	RootCmd.AddCommand(newModerateCmd())
+	// Challenge results accepted provisionally
+	RootCmd.AddCommand(newChallengeCmd())
+	// Show or rotate the user ID key
+	RootCmd.AddCommand(newKeyCmd())
+
 	// ====== Run a server
 
 	// Serve commands
 	RootCmd.AddCommand(newServeCmd())
 	RootCmd.AddCommand(newSimulatorCmd())
 	RootCmd.AddCommand(newIDCmd())
+	RootCmd.AddCommand(newNATStatusCmd())
 	RootCmd.AddCommand(newDevStackCmd())
 
+	RootCmd.PersistentFlags().String(
+		"network", system.DefaultNetwork,
+		`The network to use: production, staging, development or one defined under "networks" in the config file. `+
+			`Defaults to the BACALHAU_NETWORK environment variable, or "network" in the config file.`,
+	)
+	_ = viper.BindPFlag(system.NetworkConfigKey, RootCmd.PersistentFlags().Lookup("network"))
 	RootCmd.PersistentFlags().StringVar(
 		&apiHost, "api-host", defaultAPIHost,
 		`The host for the client and server to communicate on (via REST).
@@ -143,3 +176,19 @@ func Execute() {
 		Fatal(RootCmd, err.Error(), 1)
 	}
 }
+
+// applyNetwork looks up the selected network once the flags are parsed, and
+// talks to its API servers unless others were given.
+func applyNetwork() {
+	var err error
+	selectedNetwork, err = system.SelectedNetwork()
+	if err != nil {
+		log.Fatal().Msgf("%s", err)
+	}
+	if apiHost == defaultAPIHost && selectedNetwork.APIHost != "" {
+		apiHost = selectedNetwork.APIHost
+	}
+	if apiPort == defaultAPIPort && selectedNetwork.APIPort != 0 {
+		apiPort = selectedNetwork.APIPort
+	}
+}
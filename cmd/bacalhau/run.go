@@ -23,6 +23,10 @@ func newRunCmd() *cobra.Command {
 			return nil
 		},
 	}
-	runCmd.AddCommand(newRunPythonCmd())
+	runCmd.AddCommand(
+		newRunPythonCmd(),
+		newRunRCmd(),
+		newRunSQLCmd(),
+	)
 	return runCmd
 }
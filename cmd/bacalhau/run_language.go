@@ -0,0 +1,278 @@
+package bacalhau
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/filecoin-project/bacalhau/pkg/ipfs"
+	"github.com/filecoin-project/bacalhau/pkg/job"
+	"github.com/filecoin-project/bacalhau/pkg/system"
+	"github.com/filecoin-project/bacalhau/pkg/util/targzip"
+	"github.com/filecoin-project/bacalhau/pkg/util/templates"
+	"github.com/spf13/cobra"
+	"k8s.io/kubectl/pkg/util/i18n"
+)
+
+var (
+	languageRunLong = templates.LongDesc(i18n.T(`
+		Runs a job by compiling language file to WASM on the node.
+		`))
+
+	languageRunExample = templates.Examples(i18n.T(`
+		TBD`))
+
+	runLanguageLong = templates.LongDesc(i18n.T(`
+		Runs a %[1]s script on the network. The script and the rest of the
+		context path are uploaded with the job, the inputs are mounted at
+		'/inputs', and the stdout of the script is printed once the job has
+		completed.
+		`))
+)
+
+// LanguageRunOptions declares the arguments accepted by the `'language' run` command
+type LanguageRunOptions struct {
+	Deterministic bool     // Execute this job deterministically
+	Inputs        []string // Array of input CIDs
+	InputUrls     []string // Array of input URLs (will be copied to IPFS)
+	InputVolumes  []string // Array of input volumes in 'CID:mount point' form
+	OutputVolumes []string // Array of output volumes in 'name:mount point' form
+	Env           []string // Array of environment variables
+	Concurrency   int      // Number of concurrent jobs to run
+	Confidence    int      // Minimum number of nodes that must agree on a verification result
+	MinBids       int      // Minimum number of bids that must be received before any are accepted (at random)
+	Timeout       float64  // Job execution timeout in seconds
+	Labels        []string // Labels for the job on the Bacalhau network (for searching)
+
+	Command          string // Command to execute
+	RequirementsPath string // Path for requirements.txt for executing with Python
+	ContextPath      string // ContextPath (code) for executing with Python
+
+	// CPU string
+	// Memory string
+	// GPU string
+	// WorkingDir string // Working directory for docker
+
+	RuntimeSettings  RunTimeSettings
+	DownloadSettings ipfs.IPFSDownloadSettings
+
+	// ShardingGlobPattern string
+	// ShardingBasePath string
+	// ShardingBatchSize int
+}
+
+func NewLanguageRunOptions() *LanguageRunOptions {
+	runtimeSettings := NewRunTimeSettings()
+	runtimeSettings.PrintStdout = true
+	return &LanguageRunOptions{
+		Deterministic:    true,
+		Inputs:           []string{},
+		InputUrls:        []string{},
+		InputVolumes:     []string{},
+		OutputVolumes:    []string{},
+		Env:              []string{},
+		Concurrency:      1,
+		Confidence:       0,
+		MinBids:          0, // 0 means no minimum before bidding
+		Timeout:          DefaultTimeout.Seconds(),
+		Labels:           []string{},
+		Command:          "",
+		RequirementsPath: "",
+		ContextPath:      ".",
+		RuntimeSettings:  *runtimeSettings,
+		DownloadSettings: *ipfs.NewIPFSDownloadSettings(),
+	}
+}
+
+// runLanguage describes a language that jobs can be run in with a `run`
+// subcommand.
+type runLanguage struct {
+	Language string // the language, as in JobSpecLanguage
+	Version  string // the version of the language the jobs are run with
+	Name     string // the name of the language for humans
+	Example  string
+	// whether the language can run in the deterministic wasm runtime
+	Deterministic bool
+	// whether the language has a requirements file of packages to install
+	Requirements bool
+}
+
+// TODO: move the adapter code (from wasm to docker) into a wasm executor, so
+// that the compute node can verify the job knowing that it was run properly,
+// rather than doing the translation in, and thereby trusting, the client (to
+// set up the wasm environment to be determinstic)
+
+func newRunLanguageCmd(language runLanguage) *cobra.Command {
+	OLR := NewLanguageRunOptions()
+	OLR.Deterministic = language.Deterministic
+
+	runLanguageCmd := &cobra.Command{
+		Use:     language.Language,
+		Short:   fmt.Sprintf("Run a %s job on the network", language.Name),
+		Long:    fmt.Sprintf(runLanguageLong, language.Name),
+		Example: language.Example,
+		Args:    cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, cmdArgs []string) error { //nolint
+			return runLanguageJob(cmd, cmdArgs, language, OLR)
+		},
+	}
+
+	if language.Deterministic {
+		// determinism flag
+		runLanguageCmd.PersistentFlags().BoolVar(
+			&OLR.Deterministic, "deterministic", OLR.Deterministic,
+			`Enforce determinism: run job in a single-threaded wasm runtime with `+
+				`no sources of entropy. NB: this will make the python runtime execute `+
+				`in an environment where only some libraries are supported, see `+
+				`https://pyodide.org/en/stable/usage/packages-in-pyodide.html. `+
+				`Set to false to run the job in docker with the full runtime`,
+		)
+	}
+	runLanguageCmd.PersistentFlags().StringSliceVarP(
+		&OLR.Inputs, "inputs", "i", OLR.Inputs,
+		`CIDs to use on the job. Mounts them at '/inputs' in the execution.`,
+	)
+	runLanguageCmd.PersistentFlags().StringSliceVarP(
+		&OLR.InputUrls, "input-urls", "u", OLR.InputUrls,
+		`URL of the input data volumes downloaded from a URL source. Mounts data at '/inputs' (e.g. '-u https://example.com/bar.tar.gz'
+		mounts 'bar.tar.gz' at '/inputs/bar.tar.gz'). URL accept any valid URL supported by the 'wget' command,
+		and supports both HTTP and HTTPS. Objects in Google Cloud Storage and Azure Blob Storage can be mounted with
+		gs://bucket/path and az://account/container/path URLs, which mount a single object or every object under the path,
+		snapshots of Hugging Face models with hf://org/model@revision URLs, and the directories compute nodes share
+		as local://name URLs, so that the job only runs on those nodes.`,
+	)
+	runLanguageCmd.PersistentFlags().StringSliceVarP(
+		&OLR.InputVolumes, "input-volumes", "v", OLR.InputVolumes,
+		`CID:path of the input data volumes`,
+	)
+	runLanguageCmd.PersistentFlags().StringSliceVarP(
+		&OLR.OutputVolumes, "output-volumes", "o", OLR.OutputVolumes,
+		`name:path of the output data volumes`,
+	)
+	runLanguageCmd.PersistentFlags().StringSliceVarP(
+		&OLR.Env, "env", "e", OLR.Env,
+		`The environment variables to supply to the job (e.g. --env FOO=bar --env BAR=baz)`,
+	)
+	// TODO: concurrency should be factored out (at least up to run, maybe
+	// shared with docker and wasm raw commands too)
+	runLanguageCmd.PersistentFlags().IntVar(
+		&OLR.Concurrency, "concurrency", OLR.Concurrency,
+		`How many nodes should run the job`,
+	)
+	runLanguageCmd.PersistentFlags().IntVar(
+		&OLR.Confidence, "confidence", OLR.Confidence,
+		`The minimum number of nodes that must agree on a verification result`,
+	)
+	runLanguageCmd.PersistentFlags().IntVar(
+		&OLR.MinBids, "min-bids", OLR.MinBids,
+		`Minimum number of bids that must be received before concurrency-many bids will be accepted (at random)`,
+	)
+	runLanguageCmd.PersistentFlags().Float64Var(
+		&OLR.Timeout, "timeout", OLR.Timeout,
+		`Job execution timeout in seconds (e.g. 300 for 5 minutes and 0.1 for 100ms)`,
+	)
+	runLanguageCmd.PersistentFlags().StringVarP(
+		&OLR.Command, "command", "c", OLR.Command,
+		fmt.Sprintf(`Program passed in as string (like %s)`, language.Language),
+	)
+	if language.Requirements {
+		runLanguageCmd.PersistentFlags().StringVarP(
+			&OLR.RequirementsPath, "requirement", "r", OLR.RequirementsPath,
+			`Install from the given requirements file. (like pip)`, // TODO: This option can be used multiple times.
+		)
+	}
+	runLanguageCmd.PersistentFlags().StringVar(
+		// TODO: consider replacing this with context-glob, default to
+		// "./**/*.py|./requirements.txt", OR .bacalhau_ignore
+		&OLR.ContextPath, "context-path", OLR.ContextPath,
+		"Path to context (e.g. python code) to send to server (via public IPFS network) "+
+			"for execution (max 10MiB). Set to empty string to disable",
+	)
+	runLanguageCmd.PersistentFlags().StringSliceVarP(
+		&OLR.Labels, "labels", "l", OLR.Labels,
+		`List of labels for the job. Enter multiple in the format '-l a -l 2'. All characters not matching /a-zA-Z0-9_:|-/ and all emojis will be stripped.`, //nolint:lll // Documentation, ok if long.
+	)
+	runLanguageCmd.PersistentFlags().BoolVar(
+		&OLR.RuntimeSettings.PrintStdout, "stdout", OLR.RuntimeSettings.PrintStdout,
+		`Print the stdout of the job once it has completed, rather than a summary of the results.`,
+	)
+
+	runLanguageCmd.PersistentFlags().AddFlagSet(NewRunTimeSettingsFlags(&OLR.RuntimeSettings))
+	runLanguageCmd.PersistentFlags().AddFlagSet(NewIPFSDownloadFlags(&OLR.DownloadSettings))
+	return runLanguageCmd
+}
+
+func runLanguageJob(cmd *cobra.Command, cmdArgs []string, language runLanguage, OLR *LanguageRunOptions) error {
+	cm := system.NewCleanupManager()
+	defer cm.Cleanup()
+	ctx := cmd.Context()
+
+	ctx, rootSpan := system.NewRootSpan(ctx, system.GetTracer(), "cmd/bacalhau/run/"+language.Language)
+	defer rootSpan.End()
+	cm.RegisterCallback(system.CleanupTraceProvider)
+
+	var programPath string
+	if len(cmdArgs) > 0 {
+		programPath = cmdArgs[0]
+	}
+
+	if OLR.Command == "" && programPath == "" {
+		Fatal(cmd, fmt.Sprintf("Please specify an inline command or a path to a %s file.", language.Name), 1)
+	}
+
+	for _, i := range OLR.Inputs {
+		OLR.InputVolumes = append(OLR.InputVolumes, fmt.Sprintf("%s:/inputs", i))
+	}
+
+	// TODO: #450 These two code paths make me nervous - the fact that we
+	// have ConstructLanguageJob and ConstructDockerJob as separate means
+	// manually keeping them in sync.
+	j, err := job.ConstructLanguageJob(
+		OLR.InputVolumes,
+		OLR.InputUrls,
+		OLR.OutputVolumes,
+		[]string{}, // no env vars (yet)
+		OLR.Concurrency,
+		OLR.Confidence,
+		OLR.MinBids,
+		OLR.Timeout,
+		language.Language,
+		language.Version,
+		OLR.Command,
+		programPath,
+		OLR.RequirementsPath,
+		OLR.ContextPath,
+		OLR.Deterministic,
+		OLR.Labels,
+		doNotTrack,
+	)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+
+	if OLR.ContextPath == "." && OLR.RequirementsPath == "" && programPath == "" {
+		cmd.Println("no program or requirements specified, not uploading context - set --context-path to full path to force context upload")
+		OLR.ContextPath = ""
+	}
+
+	if OLR.ContextPath != "" {
+		// construct a tar file from the contextPath directory
+		// tar + gzip
+		cmd.Printf("Uploading %s to server to execute command in context, press Ctrl+C to cancel\n", OLR.ContextPath)
+		time.Sleep(1 * time.Second)
+		err = targzip.Compress(ctx, OLR.ContextPath, &buf)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = ExecuteJob(ctx, cm, cmd, j, OLR.RuntimeSettings, OLR.DownloadSettings, &buf)
+	if err != nil {
+		Fatal(cmd, fmt.Sprintf("Error executing job: %s", err), 1)
+		return nil
+	}
+
+	return nil
+}
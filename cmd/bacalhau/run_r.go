@@ -0,0 +1,25 @@
+package bacalhau
+
+import (
+	"github.com/filecoin-project/bacalhau/pkg/util/templates"
+	"github.com/spf13/cobra"
+	"k8s.io/kubectl/pkg/util/i18n"
+)
+
+var (
+	rRunExample = templates.Examples(i18n.T(`
+		# Run an R script on the files of a CID
+		bacalhau run r analysis.R -i QmeZRGhe4PmjctYVSVHuEiA9oSXnqmYa4kQubSHgWbjv72
+
+		# Run inline R on a file downloaded from a URL
+		bacalhau run r -u https://example.com/data.csv -c "summary(read.csv('/inputs/data.csv'))"`))
+)
+
+func newRunRCmd() *cobra.Command {
+	return newRunLanguageCmd(runLanguage{
+		Language: "r",
+		Version:  "4.2",
+		Name:     "R",
+		Example:  rRunExample,
+	})
+}
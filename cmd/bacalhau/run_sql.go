@@ -0,0 +1,25 @@
+package bacalhau
+
+import (
+	"github.com/filecoin-project/bacalhau/pkg/util/templates"
+	"github.com/spf13/cobra"
+	"k8s.io/kubectl/pkg/util/i18n"
+)
+
+var (
+	sqlRunExample = templates.Examples(i18n.T(`
+		# Run a SQLite script, e.g. one that imports CSV files from /inputs
+		bacalhau run sql query.sql -i QmeZRGhe4PmjctYVSVHuEiA9oSXnqmYa4kQubSHgWbjv72
+
+		# Run an inline query
+		bacalhau run sql -c "select sqlite_version();"`))
+)
+
+func newRunSQLCmd() *cobra.Command {
+	return newRunLanguageCmd(runLanguage{
+		Language: "sql",
+		Version:  "3",
+		Name:     "SQL",
+		Example:  sqlRunExample,
+	})
+}
@@ -0,0 +1,103 @@
+package bacalhau
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/c2h5oh/datasize"
+	"github.com/filecoin-project/bacalhau/pkg/config"
+	executor_util "github.com/filecoin-project/bacalhau/pkg/executor/util"
+	"github.com/filecoin-project/bacalhau/pkg/ipfs"
+	"github.com/filecoin-project/bacalhau/pkg/selftest"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// jobs pull their images from Docker Hub unless they say otherwise
+	selfTestRegistryAddress = "registry-1.docker.io:443"
+	selfTestClockURL        = "https://www.cloudflare.com"
+	selfTestClockWarnSkew   = 5 * time.Second
+	selfTestClockMaxSkew    = time.Minute
+	selfTestMinFreeDisk     = 1 * datasize.GB
+	selfTestWarnFreeDisk    = 10 * datasize.GB
+)
+
+// getSelfTestChecks returns the checks of what a node needs from its
+// environment to run jobs.
+func getSelfTestChecks(OS *ServeOptions, ipfsClient *ipfs.Client, peers []multiaddr.Multiaddr) []selftest.Check {
+	var checks []selftest.Check
+	// jobs only need the Docker daemon when they run on it, or it pulls the
+	// images of their Firecracker VMs
+	backends, _ := getDockerBackends(OS)
+	for _, backend := range backends {
+		if backend == executor_util.DockerBackendDocker || backend == executor_util.DockerBackendFirecracker {
+			checks = append(checks, selftest.NewDockerCheck())
+			break
+		}
+	}
+	checks = append(checks,
+		selftest.NewIPFSCheck(ipfsClient),
+		selftest.NewDiskSpaceCheck(config.GetStoragePath(), selfTestMinFreeDisk.Bytes(), selfTestWarnFreeDisk.Bytes()),
+	)
+	if tempDir := os.TempDir(); tempDir != config.GetStoragePath() {
+		checks = append(checks, selftest.NewDiskSpaceCheck(tempDir, selfTestMinFreeDisk.Bytes(), selfTestWarnFreeDisk.Bytes()))
+	}
+
+	addresses := []string{selfTestRegistryAddress}
+	for _, addr := range peers {
+		if addr == nil {
+			continue
+		}
+		transportAddr, _ := peer.SplitAddr(addr)
+		netAddr, err := manet.ToNetAddr(transportAddr)
+		if err != nil {
+			// not a plain TCP or UDP address, e.g. a DNS name
+			continue
+		}
+		if netAddr.Network() == "tcp" {
+			addresses = append(addresses, netAddr.String())
+		}
+	}
+	return append(checks,
+		selftest.NewOutboundCheck(addresses),
+		selftest.NewClockSkewCheck(selfTestClockURL, selfTestClockWarnSkew, selfTestClockMaxSkew),
+	)
+}
+
+// selfTest runs the checks, prints the diagnosis and exits with an error if
+// any of them failed.
+func selfTest(cmd *cobra.Command, OS *ServeOptions, checks []selftest.Check) {
+	report := selftest.Run(cmd.Context(), selftest.DefaultCheckTimeout, checks)
+
+	if OS.SelfTestOutput == JSONFormat {
+		output, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			Fatal(cmd, fmt.Sprintf("Error marshaling the self test report: %s", err), 1)
+			return
+		}
+		cmd.Println(string(output))
+	} else {
+		for _, result := range report.Checks {
+			cmd.Printf("%-8s %s: %s\n", result.Status, result.Name, result.Message)
+			if result.Remedy != "" {
+				cmd.Printf("%-8s %s\n", "", result.Remedy)
+			}
+		}
+	}
+
+	if !report.Healthy {
+		var failedChecks []string
+		for _, result := range report.Checks {
+			if result.Status == selftest.StatusFailed {
+				failedChecks = append(failedChecks, result.Name)
+			}
+		}
+		Fatal(cmd, fmt.Sprintf("Self test failed: %s", strings.Join(failedChecks, ", ")), 1)
+	}
+}
@@ -1,25 +1,49 @@
 package bacalhau
 
 import (
+	"context"
 	"fmt"
+	"net"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/filecoin-project/bacalhau/pkg/compute/capacity"
+	"github.com/filecoin-project/bacalhau/pkg/compute/pubsub"
+	"github.com/filecoin-project/bacalhau/pkg/compute/schedule"
+	"github.com/filecoin-project/bacalhau/pkg/compute/secrets"
+	"github.com/filecoin-project/bacalhau/pkg/config"
+	"github.com/filecoin-project/bacalhau/pkg/docker"
+	"github.com/filecoin-project/bacalhau/pkg/executor"
+	dockerexecutor "github.com/filecoin-project/bacalhau/pkg/executor/docker"
+	"github.com/filecoin-project/bacalhau/pkg/executor/firecracker"
+	"github.com/filecoin-project/bacalhau/pkg/executor/kubernetes"
+	"github.com/filecoin-project/bacalhau/pkg/executor/nomad"
+	"github.com/filecoin-project/bacalhau/pkg/executor/slurm"
+	executor_util "github.com/filecoin-project/bacalhau/pkg/executor/util"
 	"github.com/filecoin-project/bacalhau/pkg/logger"
 	filecoinlotus "github.com/filecoin-project/bacalhau/pkg/publisher/filecoin_lotus"
+	ipfscluster "github.com/filecoin-project/bacalhau/pkg/publisher/ipfs_cluster"
 
 	"github.com/filecoin-project/bacalhau/pkg/localdb/inmemory"
 
 	"github.com/filecoin-project/bacalhau/pkg/ipfs"
 	"github.com/filecoin-project/bacalhau/pkg/node"
 	"github.com/filecoin-project/bacalhau/pkg/requesternode"
+	"github.com/filecoin-project/bacalhau/pkg/storage/cloud/azureblob"
+	"github.com/filecoin-project/bacalhau/pkg/storage/cloud/gcs"
+	filecoinretrieval "github.com/filecoin-project/bacalhau/pkg/storage/filecoin_retrieval"
+	"github.com/filecoin-project/bacalhau/pkg/storage/huggingface"
+	"github.com/filecoin-project/bacalhau/pkg/storage/localdirectory"
 
 	"github.com/filecoin-project/bacalhau/pkg/model"
 	"github.com/filecoin-project/bacalhau/pkg/system"
 	"github.com/filecoin-project/bacalhau/pkg/transport/libp2p"
 	"github.com/filecoin-project/bacalhau/pkg/util/templates"
+	"github.com/google/uuid"
 	"github.com/multiformats/go-multiaddr"
 
 	"github.com/rs/zerolog/log"
@@ -27,25 +51,93 @@ import (
 	"k8s.io/kubectl/pkg/util/i18n"
 )
 
-var DefaultBootstrapAddresses = []string{
-	"/ip4/35.245.115.191/tcp/1235/p2p/QmdZQ7ZbhnvWY1J12XYKGHApJ6aufKyLNSvf8jZBrBaAVL",
-	"/ip4/35.245.61.251/tcp/1235/p2p/QmXaXu9N5GNetatsvwnTfQqNtSeKAD6uCmarbh3LMRYAcF",
-	"/ip4/35.245.251.239/tcp/1235/p2p/QmYgxZiySj3MRkwLSL4X2MF5F9f2PMhAE3LV49XkfNL1o3",
-}
 var DefaultSwarmPort = 1235
 
+// Kubernetes kills pods 30 seconds after sending them SIGTERM by default, so
+// drain for a little less than that.
+const defaultDrainTimeout = 25 * time.Second
+
+// Crashed executions are cleaned up after on startup and every hour, and
+// temporary directories once they have been left for an hour.
+const (
+	defaultGarbageCollectionInterval = time.Hour
+	defaultGarbageCollectionMinAge   = time.Hour
+)
+
 var (
 	serveLong = templates.LongDesc(i18n.T(`
 		Start the bacalhau campute node.
+
+		Use --self-test to check that Docker and IPFS can be reached, that
+		there is disk space, that peers and Docker Hub can be connected to and
+		that the clock is right, without starting the node. The same checks
+		are run by GET /healthz?deep=true on a running node.
+
+		On SIGTERM the node stops bidding on jobs, reports that it is not
+		ready on /readyz and waits up to --drain-timeout for its running jobs
+		to finish before exiting. Keep --drain-timeout below the
+		terminationGracePeriodSeconds of the pod when running in Kubernetes.
+
+		Use --kubernetes-executor to run docker jobs as pods through the
+		Kubernetes API instead of on the Docker daemon. Job pods run on the
+		same Kubernetes node as the compute node, set with
+		--kubernetes-node-name or $NODE_NAME, and mount the inputs it
+		prepares through hostPath volumes. So the storage path and temp
+		directory of the compute node must be hostPath volumes mounted at the
+		same paths as on the Kubernetes node.
+
+		Use --nomad-executor to run docker jobs as Nomad batch jobs instead.
+		They are constrained to the Nomad client the compute node runs on,
+		set with --nomad-node-name, and bind mount the inputs it prepares, so
+		the docker driver of that client must have volumes enabled. The ACL
+		token to talk to Nomad with is read from $NOMAD_TOKEN.
+
+		Use --slurm-executor to run docker jobs as Slurm batch jobs instead,
+		submitted with sbatch. By default the batch script of a job runs its
+		image with Apptainer, and --slurm-script-template replaces it. The
+		storage path and temp directory of the compute node must be on a
+		filesystem shared with the Slurm compute nodes, at the same paths.
+
+		Use --firecracker-executor to run docker jobs in Firecracker microVMs
+		booted from --firecracker-kernel, for stronger isolation than
+		containers. Images are still pulled with the Docker daemon and need
+		/bin/sh and mount, and e2fsprogs must be installed on the node.
+
+		Use --docker-executors to fall back through several runtimes, e.g.
+		docker,podman. Each job runs on the first of them that is up when it
+		starts, and the node bids on docker jobs while any of them is up.
 		`))
 
 	serveExample = templates.Examples(i18n.T(`
-		TBD`))
+		# Check the environment of a compute node before starting it
+		bacalhau serve --ipfs-connect /ip4/127.0.0.1/tcp/5001 --self-test
+
+		# Output the diagnosis as json
+		bacalhau serve --ipfs-connect /ip4/127.0.0.1/tcp/5001 --self-test --self-test-output json
+
+		# Run docker jobs as pods in the jobs namespace of the cluster the node runs in
+		bacalhau serve --ipfs-connect /ip4/127.0.0.1/tcp/5001 --kubernetes-executor --kubernetes-namespace jobs
+
+		# Run docker jobs on the local Nomad client
+		bacalhau serve --ipfs-connect /ip4/127.0.0.1/tcp/5001 --nomad-executor --nomad-address http://127.0.0.1:4646
+
+		# Run docker jobs in the batch partition of a Slurm cluster
+		BACALHAU_STORAGE_PATH=/shared/bacalhau TMPDIR=/shared/bacalhau \
+		bacalhau serve --ipfs-connect /ip4/127.0.0.1/tcp/5001 --slurm-executor --slurm-partition batch
+
+		# Run docker jobs in Firecracker microVMs
+		bacalhau serve --ipfs-connect /ip4/127.0.0.1/tcp/5001 --firecracker-executor --firecracker-kernel /var/lib/firecracker/vmlinux
+
+		# Run docker jobs on podman when the Docker daemon is down
+		bacalhau serve --ipfs-connect /ip4/127.0.0.1/tcp/5001 --docker-executors docker,podman`))
 )
 
 type ServeOptions struct {
 	PeerConnect                     string        // The libp2p multiaddress to connect to.
-	IPFSConnect                     string        // The IPFS multiaddress to connect to.
+	IPFSConnect                     string        // The IPFS multiaddresses to connect to, comma-separated.
+	IPFSEmbedded                    bool          // Whether to run an in-process IPFS node instead of connecting to one.
+	IPFSRepo                        string        // The repo of the in-process IPFS node.
+	IPFSSwarmAddresses              []string      // The IPFS multiaddresses the in-process IPFS node peers with.
 	FilecoinUnsealedPath            string        // The go template that can turn a filecoin CID into a local filepath with the unsealed data.
 	EstuaryAPIKey                   string        // The API key used when using the estuary API.
 	HostAddress                     string        // The host address to listen on.
@@ -53,32 +145,180 @@ type ServeOptions struct {
 	JobSelectionDataLocality        string        // The data locality to use for job selection.
 	JobSelectionDataRejectStateless bool          // Whether to reject jobs that don't specify any data.
 	JobSelectionProbeHTTP           string        // The HTTP URL to use for job selection.
+	JobSelectionReportDeclines      bool          // Whether to tell requesters why jobs were declined.
+	JobSelectionAllowClients        []string      // IDs or public keys of the only clients whose jobs to accept.
+	JobSelectionDenyClients         []string      // IDs or public keys of clients whose jobs to reject.
+	JobSelectionRejectAnonymous     bool          // Whether to reject jobs that don't say which client submitted them.
+	JobSelectionDenylistURL         string        // The URL of a denylist of flagged clients and jobs to reject.
+	JobSelectionAvailability        []string      // Windows of time to run jobs in, as cron expressions and durations.
+	JobSelectionBlackout            []string      // Windows of time not to run jobs in, as cron expressions and durations.
+	Zone                            string        // The zone the node is in, for jobs to spread across.
+	Renewable                       bool          // Whether the node runs on renewable power.
+	CarbonIntensity                 float64       // The node's carbon intensity in gCO2eq/kWh, negative if unknown.
+	CarbonIntensityURL              string        // An API to fetch the current carbon intensity of the node's power from.
+	SecretsBackend                  string        // Where to fetch the secrets of jobs from: env, file or vault.
+	SecretsEnvPrefix                string        // What the environment variables of secrets start with.
+	SecretsDir                      string        // The directory the files of secrets are in.
+	VaultAddress                    string        // The address of the Vault server to fetch secrets from.
+	VaultMount                      string        // The mount of the Vault KV secrets engine the secrets are in.
+	VaultPath                       string        // The path under the Vault mount that the secrets are in.
+	SecretsGCPProject               string        // The Google Cloud project the secrets are in.
+	SecretsGCPCredentialsFile       string        // The Google credentials file to read secrets with.
+	SecretsRefreshInterval          time.Duration // How often to fetch the credentials of the node again.
+	AllowedSecrets                  []string      // The secrets jobs may request, as NAME or NAME=CLIENT_ID.
+	EstuaryAPIKeySecret             string        // The secret of the Estuary API key.
+	IPFSClusterBasicAuthSecret      string        // The secret of the user:password credentials of the ipfs-cluster.
+	AzureSASTokenSecret             string        // The secret of the Azure SAS token.
+	HuggingFaceTokenSecret          string        // The secret of the Hugging Face access token.
 	JobSelectionProbeExec           string        // The executable to use for job selection.
 	MetricsPort                     int           // The port to listen on for metrics.
+	APIGRPCPort                     int           // The port to serve the gRPC API on, disabled if 0.
+	CallbackAllowedNetworks         []string      // The private networks webhooks and callbacks can be posted to.
 	LimitTotalCPU                   string        // The total amount of CPU the system can be using at one time.
 	LimitTotalMemory                string        // The total amount of memory the system can be using at one time.
 	LimitTotalGPU                   string        // The total amount of GPU the system can be using at one time.
 	LimitJobCPU                     string        // The amount of CPU the system can be using at one time for a single job.
 	LimitJobMemory                  string        // The amount of memory the system can be using at one time for a single job.
 	LimitJobGPU                     string        // The amount of GPU the system can be using at one time for a single job.
+	LimitSpotCPU                    string        // The amount of CPU spot jobs can be using at one time.
+	LimitSpotMemory                 string        // The amount of memory spot jobs can be using at one time.
+	LimitSpotGPU                    string        // The amount of GPU spot jobs can be using at one time.
+	LimitJobCount                   int           // The number of jobs the system can be running at one time.
+	DefaultJobResources             []string      // The resources jobs run with by engine and image, when they don't say.
+	MaxInlineResults                string        // The most the outputs of a job can add up to for them to be included in its state.
+	LimitIPFSIngress                string        // The most data fetched from IPFS per second.
+	LimitIPFSEgress                 string        // The most data added to IPFS per second.
 	LotusFilecoinStorageDuration    time.Duration // How long deals should be for the Lotus Filecoin publisher
 	LotusFilecoinPathDirectory      string        // The location of the Lotus configuration directory which contains config.toml, etc
 	LotusFilecoinUploadDirectory    string        // Directory to put files when uploading to Lotus (optional)
 	LotusFilecoinMaximumPing        time.Duration // The maximum ping allowed when selecting a Filecoin miner
+	LotusRetrieval                  bool          // Whether to retrieve inputs that aren't available over IPFS from Filecoin via Lotus.
+	LotusRetrievalDirectory         string        // Directory Lotus exports retrieved inputs to (optional)
+	LotusRetrievalMaxPrice          string        // The most to pay for retrieving an input from Filecoin, in attoFIL
+	IPFSClusterAPI                  string        // The URL of the ipfs-cluster REST API to pin results on.
+	IPFSClusterBasicAuth            string        // The user:password credentials of the ipfs-cluster REST API.
+	IPFSClusterReplicationMin       int           // The fewest cluster peers that should pin each result.
+	IPFSClusterReplicationMax       int           // The most cluster peers that should pin each result.
+	AzureSASToken                   string        // The SAS token to read Azure Blob Storage inputs with.
+	AzureManagedIdentity            bool          // Whether to read Azure Blob Storage inputs with the VM's managed identity.
+	AzureManagedIdentityClientID    string        // The client ID of the user-assigned managed identity to use.
+	GCSCredentialsFile              string        // The Google credentials file to read Cloud Storage inputs with.
+	GCSAnonymous                    bool          // Whether to read Cloud Storage inputs without credentials.
+	HuggingFaceToken                string        // The access token to download private and gated Hugging Face models with.
+	HuggingFaceCacheDir             string        // Where to cache the Hugging Face models jobs use.
+	HuggingFaceCacheSize            string        // The most the cached Hugging Face models can take up.
+	EventLogPath                    string        // The directory to keep the job event log in, which is replayed on restart.
+	EventLogSnapshotInterval        uint64        // How many events to record between snapshots of the job state.
+	GossipBatchSize                 int           // The maximum number of job events to send in a single gossip message.
+	GossipBatchInterval             time.Duration // How long to wait for a gossip batch to fill up before sending it.
+	SubscribeEngines                []string      // The engines to receive job announcements for, all of them if empty.
+	LegacyJobAnnouncements          bool          // Whether to also announce new jobs on the topic older nodes listen on.
+	EventQueueSize                  int           // The most events of each priority waiting to be handled.
+	EventWorkers                    int           // How many job announcements to handle at once.
+	EventDropPolicy                 string        // Which job announcements to drop when too many are waiting.
+	MaxAnnouncementRate             float64       // The most job announcements to handle per second.
+	EnableRelay                     bool          // Whether to accept and make connections through circuit relays.
+	EnableRelayService              bool          // Whether to act as a circuit relay for other nodes.
+	EnableAutoRelay                 bool          // Whether to advertise a relayed address when not publicly reachable.
+	StaticRelays                    []string      // The relays to use for auto relay, the bootstrap peers if empty.
+	EnableHolePunching              bool          // Whether to upgrade relayed connections using hole punching.
+	EnableNATPortMap                bool          // Whether to open a port on the router using UPnP or NAT-PMP.
+	EnableNATService                bool          // Whether to help other nodes find out if they are reachable.
+	ConnectionsLowWater             int           // The number of connections to prune down to.
+	ConnectionsHighWater            int           // The number of connections above which connections are pruned.
+	ConnectionsGracePeriod          time.Duration // How long new connections are exempt from pruning.
+	BootstrapRefreshURL             string        // Where to fetch more bootstrap peers from, the environment's if --peer isn't set.
+	BootstrapRefreshInterval        time.Duration // How often to fetch the bootstrap peers again.
+	RequesterBidWindow              time.Duration // How long the requester collects bids for before selecting from them.
+	RequireApproval                 bool          // Whether to hold submitted jobs until an approver approves them.
+	Approvers                       []string      // The IDs of the clients that can approve the jobs held for approval.
+	Moderators                      []string      // The IDs of the clients that can flag clients and jobs as abusive.
+	SelfTest                        bool          // Whether to check the node's environment and exit instead of serving.
+	SelfTestOutput                  string        // The output format of the self test (json or text).
+	DrainTimeout                    time.Duration // How long to wait for running jobs to finish on SIGTERM before exiting.
+	GarbageCollectionInterval       time.Duration // How often to remove what crashed executions left behind.
+	GarbageCollectionMinAge         time.Duration // How long temporary directories are left before they are removed.
+	KubernetesExecutor              bool          // Whether to run docker jobs as Kubernetes pods instead of on the Docker daemon.
+	KubernetesNamespace             string        // The namespace to run job pods in, the one the node runs in if empty.
+	KubernetesNodeName              string        // The Kubernetes node the compute node runs on, which job pods run on.
+	Kubeconfig                      string        // The kubeconfig to talk to the cluster with, in-cluster config if empty.
+	NomadExecutor                   bool          // Whether to run docker jobs as Nomad jobs instead of on the Docker daemon.
+	NomadAddress                    string        // The address of the HTTP API of a Nomad agent.
+	NomadNamespace                  string        // The Nomad namespace to run jobs in.
+	NomadRegion                     string        // The Nomad region to run jobs in.
+	NomadDatacenters                []string      // The Nomad datacenters jobs can run in.
+	NomadNodeName                   string        // The name of the Nomad client the compute node runs on, which jobs run on.
+	NomadMHzPerCPU                  int           // How many MHz each CPU a job asks for is reserved as in Nomad.
+	SlurmExecutor                   bool          // Whether to run docker jobs as Slurm batch jobs instead of on the Docker daemon.
+	SlurmPartition                  string        // The Slurm partition to submit jobs to.
+	SlurmAccount                    string        // The Slurm account to charge jobs to.
+	SlurmSbatchArgs                 []string      // More sbatch options to submit jobs with.
+	SlurmScriptTemplate             string        // The path of a template for the batch script of jobs.
+	FirecrackerExecutor             bool          // Whether to run docker jobs in Firecracker microVMs instead of containers.
+	FirecrackerBinary               string        // The path or name of the firecracker binary.
+	FirecrackerKernel               string        // The uncompressed Linux kernel that VMs boot.
+	FirecrackerOutputVolumeSize     string        // How much a job can write to each of its output volumes.
+	DockerExecutors                 []string      // The runtimes docker jobs run on, in order of preference.
+	PodmanHost                      string        // The socket of the Docker compatible API of podman.
+	DockerNonRootUser               string        // The UID[:GID] that docker jobs which would run as root run as.
+	DockerSeccompProfile            string        // The seccomp profile in JSON that job containers run with.
+	DockerAppArmorProfile           string        // The AppArmor profile that job containers run with.
+	DockerPidsLimit                 int64         // The most processes a job container can run.
+	DockerUlimits                   []string      // The ulimits of job containers, as NAME=SOFT[:HARD].
+	EstuaryUploadedBlocks           string        // The file to record the blocks uploaded to Estuary in.
+	EstuaryCollection               string        // The UUID of the Estuary collection to add results to.
+	EstuaryReplication              int           // How many storage deals Estuary makes for results.
+	EstuaryRegion                   string        // The region Estuary should prefer to store results in.
+	LocalDirectories                []string      // The directories jobs can read, as NAME=PATH.
+	AllowedLocalPaths               []string      // The host paths jobs can read directories under.
+	RestrictOutputsMaxSize          string        // The most the results of jobs over local directories can add up to.
+	RestrictOutputsMaxRows          int           // The most lines each file of the results of those jobs can have.
+	RestrictOutputsFilter           string        // A program that must approve the results of those jobs.
+	DockerDebugSessionTimeout       time.Duration // How long the environment of failed shards is kept to debug.
 }
 
 func NewServeOptions() *ServeOptions {
 	return &ServeOptions{
 		PeerConnect:                     "",
 		IPFSConnect:                     "",
+		IPFSEmbedded:                    false,
+		IPFSRepo:                        "",
+		IPFSSwarmAddresses:              []string{},
 		FilecoinUnsealedPath:            "",
 		EstuaryAPIKey:                   os.Getenv("ESTUARY_API_KEY"),
 		HostAddress:                     "0.0.0.0",
 		SwarmPort:                       DefaultSwarmPort,
 		MetricsPort:                     2112,
+		APIGRPCPort:                     0,
+		CallbackAllowedNetworks:         []string{},
 		JobSelectionDataLocality:        "local",
 		JobSelectionDataRejectStateless: false,
 		JobSelectionProbeHTTP:           "",
+		JobSelectionReportDeclines:      false,
+		JobSelectionAllowClients:        []string{},
+		JobSelectionDenyClients:         []string{},
+		JobSelectionRejectAnonymous:     false,
+		JobSelectionDenylistURL:         "",
+		JobSelectionAvailability:        []string{},
+		JobSelectionBlackout:            []string{},
+		Zone:                            "",
+		Renewable:                       false,
+		CarbonIntensity:                 -1,
+		CarbonIntensityURL:              "",
+		SecretsBackend:                  "",
+		SecretsEnvPrefix:                secrets.DefaultEnvPrefix,
+		SecretsDir:                      "",
+		VaultAddress:                    os.Getenv("VAULT_ADDR"),
+		VaultMount:                      secrets.DefaultVaultMount,
+		VaultPath:                       "",
+		SecretsGCPProject:               "",
+		SecretsGCPCredentialsFile:       "",
+		SecretsRefreshInterval:          secrets.DefaultRefreshInterval,
+		AllowedSecrets:                  []string{},
+		EstuaryAPIKeySecret:             "",
+		IPFSClusterBasicAuthSecret:      "",
+		AzureSASTokenSecret:             "",
+		HuggingFaceTokenSecret:          "",
 		JobSelectionProbeExec:           "",
 		LimitTotalCPU:                   "",
 		LimitTotalMemory:                "",
@@ -86,8 +326,99 @@ func NewServeOptions() *ServeOptions {
 		LimitJobCPU:                     "",
 		LimitJobMemory:                  "",
 		LimitJobGPU:                     "",
+		LimitSpotCPU:                    "",
+		LimitSpotMemory:                 "",
+		LimitSpotGPU:                    "",
+		LimitJobCount:                   0,
+		DefaultJobResources:             []string{},
+		MaxInlineResults:                "1Kb",
+		LimitIPFSIngress:                "",
+		LimitIPFSEgress:                 "",
 		LotusFilecoinPathDirectory:      os.Getenv("LOTUS_PATH"),
 		LotusFilecoinMaximumPing:        2 * time.Second,
+		LotusRetrieval:                  false,
+		LotusRetrievalDirectory:         "",
+		LotusRetrievalMaxPrice:          "",
+		IPFSClusterAPI:                  "",
+		IPFSClusterBasicAuth:            os.Getenv("IPFS_CLUSTER_BASIC_AUTH"),
+		IPFSClusterReplicationMin:       0,
+		IPFSClusterReplicationMax:       0,
+		AzureSASToken:                   os.Getenv("AZURE_STORAGE_SAS_TOKEN"),
+		AzureManagedIdentity:            false,
+		AzureManagedIdentityClientID:    "",
+		GCSCredentialsFile:              os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"),
+		GCSAnonymous:                    false,
+		HuggingFaceToken:                os.Getenv("HF_TOKEN"),
+		HuggingFaceCacheDir:             "",
+		HuggingFaceCacheSize:            "",
+		EventLogPath:                    "",
+		EventLogSnapshotInterval:        1000,
+		GossipBatchSize:                 libp2p.DefaultBatchingConfig.MaxBatchSize,
+		GossipBatchInterval:             libp2p.DefaultBatchingConfig.FlushInterval,
+		SubscribeEngines:                []string{},
+		LegacyJobAnnouncements:          true,
+		EventQueueSize:                  node.DefaultComputeConfig.EventQueueSize,
+		EventWorkers:                    node.DefaultComputeConfig.EventWorkers,
+		EventDropPolicy:                 string(node.DefaultComputeConfig.EventDropPolicy),
+		MaxAnnouncementRate:             0,
+		EnableRelay:                     libp2p.DefaultNATConfig.EnableRelay,
+		EnableRelayService:              libp2p.DefaultNATConfig.EnableRelayService,
+		EnableAutoRelay:                 libp2p.DefaultNATConfig.EnableAutoRelay,
+		StaticRelays:                    []string{},
+		EnableHolePunching:              libp2p.DefaultNATConfig.EnableHolePunching,
+		EnableNATPortMap:                libp2p.DefaultNATConfig.EnableNATPortMap,
+		EnableNATService:                libp2p.DefaultNATConfig.EnableNATService,
+		ConnectionsLowWater:             libp2p.DefaultConnectionManagerConfig.LowWater,
+		ConnectionsHighWater:            libp2p.DefaultConnectionManagerConfig.HighWater,
+		ConnectionsGracePeriod:          libp2p.DefaultConnectionManagerConfig.GracePeriod,
+		BootstrapRefreshURL:             "",
+		BootstrapRefreshInterval:        libp2p.DefaultBootstrapConfig.RefreshInterval,
+		RequesterBidWindow:              0,
+		RequireApproval:                 false,
+		Approvers:                       []string{},
+		Moderators:                      []string{},
+		SelfTest:                        false,
+		SelfTestOutput:                  "text",
+		DrainTimeout:                    defaultDrainTimeout,
+		GarbageCollectionInterval:       defaultGarbageCollectionInterval,
+		GarbageCollectionMinAge:         defaultGarbageCollectionMinAge,
+		KubernetesExecutor:              false,
+		KubernetesNamespace:             "",
+		KubernetesNodeName:              os.Getenv("NODE_NAME"),
+		Kubeconfig:                      os.Getenv("KUBECONFIG"),
+		NomadExecutor:                   false,
+		NomadAddress:                    os.Getenv("NOMAD_ADDR"),
+		NomadNamespace:                  os.Getenv("NOMAD_NAMESPACE"),
+		NomadRegion:                     os.Getenv("NOMAD_REGION"),
+		NomadDatacenters:                []string{"dc1"},
+		NomadNodeName:                   "",
+		NomadMHzPerCPU:                  nomad.DefaultMHzPerCPU,
+		SlurmExecutor:                   false,
+		SlurmPartition:                  "",
+		SlurmAccount:                    "",
+		SlurmSbatchArgs:                 []string{},
+		SlurmScriptTemplate:             "",
+		FirecrackerExecutor:             false,
+		FirecrackerBinary:               firecracker.DefaultBinary,
+		FirecrackerKernel:               "",
+		FirecrackerOutputVolumeSize:     "10Gi",
+		DockerExecutors:                 []string{},
+		PodmanHost:                      os.Getenv("CONTAINER_HOST"),
+		DockerNonRootUser:               "",
+		DockerSeccompProfile:            "",
+		DockerAppArmorProfile:           "",
+		DockerPidsLimit:                 0,
+		DockerUlimits:                   []string{},
+		EstuaryUploadedBlocks:           "",
+		EstuaryCollection:               "",
+		EstuaryReplication:              0,
+		EstuaryRegion:                   "",
+		LocalDirectories:                []string{},
+		AllowedLocalPaths:               []string{},
+		RestrictOutputsMaxSize:          "",
+		RestrictOutputsMaxRows:          0,
+		RestrictOutputsFilter:           "",
+		DockerDebugSessionTimeout:       0,
 	}
 }
 
@@ -104,6 +435,37 @@ func setupJobSelectionCLIFlags(cmd *cobra.Command, OS *ServeOptions) {
 		&OS.JobSelectionProbeHTTP, "job-selection-probe-http", OS.JobSelectionProbeHTTP,
 		`Use the result of a HTTP POST to decide if we should take on the job.`,
 	)
+	cmd.PersistentFlags().StringSliceVar(
+		&OS.JobSelectionAllowClients, "job-selection-allow-clients", OS.JobSelectionAllowClients,
+		`IDs or base64 public keys of the only clients whose jobs we take on, e.g. for a private cluster.`,
+	)
+	cmd.PersistentFlags().StringSliceVar(
+		&OS.JobSelectionDenyClients, "job-selection-deny-clients", OS.JobSelectionDenyClients,
+		`IDs or base64 public keys of clients whose jobs we don't take on.`,
+	)
+	cmd.PersistentFlags().BoolVar(
+		&OS.JobSelectionRejectAnonymous, "job-selection-reject-anonymous", OS.JobSelectionRejectAnonymous,
+		`Reject jobs that don't say which client submitted them.`,
+	)
+	cmd.PersistentFlags().StringVar(
+		&OS.JobSelectionDenylistURL, "job-selection-denylist-url", OS.JobSelectionDenylistURL,
+		`The URL of a denylist of clients and jobs flagged as abusive, such as the /denylist of a requester node, `+
+			`whose jobs we don't take on.`,
+	)
+	cmd.PersistentFlags().StringArrayVar(
+		&OS.JobSelectionAvailability, "job-selection-availability", OS.JobSelectionAvailability,
+		`A window of time to take on jobs in, e.g. off-peak, as a cron expression of when it starts and how long it `+
+			`lasts in local time (e.g. '0 18 * * mon-fri 14h'). Jobs are only taken on if they can finish inside a window.`,
+	)
+	cmd.PersistentFlags().StringArrayVar(
+		&OS.JobSelectionBlackout, "job-selection-blackout", OS.JobSelectionBlackout,
+		`A window of time not to run jobs in, e.g. for maintenance, written as for --job-selection-availability `+
+			`(e.g. '0 2 * * sun 4h'). Jobs that could run into a window aren't taken on, so the node drains before it.`,
+	)
+	cmd.PersistentFlags().BoolVar(
+		&OS.JobSelectionReportDeclines, "job-selection-report-declines", OS.JobSelectionReportDeclines,
+		`Tell requesters why we declined to bid on their jobs, so their owners can see why they aren't running.`,
+	)
 	cmd.PersistentFlags().StringVar(
 		&OS.JobSelectionProbeExec, "job-selection-probe-exec", OS.JobSelectionProbeExec,
 		`Use the result of a exec an external program to decide if we should take on the job.`,
@@ -117,11 +479,11 @@ func setupCapacityManagerCLIFlags(cmd *cobra.Command, OS *ServeOptions) {
 	)
 	cmd.PersistentFlags().StringVar(
 		&OS.LimitTotalMemory, "limit-total-memory", OS.LimitTotalMemory,
-		`Total Memory limit to run all jobs  (e.g. 500Mb, 2Gb, 8Gb).`,
+		`Total Memory limit to run all jobs  (e.g. 500Mi, 2Gi, 8Gi).`,
 	)
 	cmd.PersistentFlags().StringVar(
 		&OS.LimitTotalGPU, "limit-total-gpu", OS.LimitTotalGPU,
-		`Total GPU limit to run all jobs (e.g. 1, 2, or 8).`,
+		`Total GPU limit to run all jobs (e.g. 1, 2, 8 or 500m).`,
 	)
 	cmd.PersistentFlags().StringVar(
 		&OS.LimitJobCPU, "limit-job-cpu", OS.LimitJobCPU,
@@ -129,18 +491,118 @@ func setupCapacityManagerCLIFlags(cmd *cobra.Command, OS *ServeOptions) {
 	)
 	cmd.PersistentFlags().StringVar(
 		&OS.LimitJobMemory, "limit-job-memory", OS.LimitJobMemory,
-		`Job Memory limit for single job  (e.g. 500Mb, 2Gb, 8Gb).`,
+		`Job Memory limit for single job  (e.g. 500Mi, 2Gi, 8Gi).`,
 	)
 	cmd.PersistentFlags().StringVar(
 		&OS.LimitJobGPU, "limit-job-gpu", OS.LimitJobGPU,
-		`Job GPU limit for single job (e.g. 1, 2, or 8).`,
+		`Job GPU limit for single job (e.g. 1, 2, 8 or 500m).`,
+	)
+	cmd.PersistentFlags().StringVar(
+		&OS.LimitSpotCPU, "limit-spot-cpu", OS.LimitSpotCPU,
+		`Total CPU core limit to run spot jobs, keeping the rest for standard jobs (e.g. 500m, 2, 8).`,
+	)
+	cmd.PersistentFlags().StringVar(
+		&OS.LimitSpotMemory, "limit-spot-memory", OS.LimitSpotMemory,
+		`Total Memory limit to run spot jobs, keeping the rest for standard jobs (e.g. 500Mi, 2Gi, 8Gi).`,
+	)
+	cmd.PersistentFlags().StringVar(
+		&OS.LimitSpotGPU, "limit-spot-gpu", OS.LimitSpotGPU,
+		`Total GPU limit to run spot jobs, keeping the rest for standard jobs (e.g. 1, 2, 8 or 500m).`,
+	)
+	cmd.PersistentFlags().IntVar(
+		&OS.LimitJobCount, "limit-job-count", OS.LimitJobCount,
+		`Maximum number of jobs to run at once whatever their resource usage, e.g. for I/O heavy workloads (0 for no limit).`,
+	)
+	cmd.PersistentFlags().StringArrayVar(
+		&OS.DefaultJobResources, "default-job-resources", OS.DefaultJobResources,
+		`The resources jobs of an engine, and optionally of the docker images matching a pattern, run with when they `+
+			`don't ask for them, as ENGINE[:IMAGE-PATTERN]=RESOURCE=VALUE,... (e.g. docker:pytorch/*=cpu=2,memory=8Gi,gpu=1). `+
+			`Can be repeated, the first that matches a job applies.`,
+	)
+	cmd.PersistentFlags().StringVar(
+		&OS.Zone, "zone", OS.Zone,
+		`The zone the node is in, e.g. a region or datacenter, so that jobs with --min-zones can spread across zones.`,
+	)
+	cmd.PersistentFlags().BoolVar(
+		&OS.Renewable, "renewable", OS.Renewable,
+		`Say that the node runs on renewable power, so that jobs preferring green nodes favor it.`,
+	)
+	cmd.PersistentFlags().Float64Var(
+		&OS.CarbonIntensity, "carbon-intensity", OS.CarbonIntensity,
+		`The carbon intensity of the power of the node in gCO2eq/kWh, so that jobs preferring green nodes can `+
+			`favor it (negative if unknown).`,
+	)
+	cmd.PersistentFlags().StringVar(
+		&OS.CarbonIntensityURL, "carbon-intensity-url", OS.CarbonIntensityURL,
+		`A URL that returns the current carbon intensity of the power of the node, as a number of gCO2eq/kWh or `+
+			`JSON like {"carbon_intensity": 120.5, "renewable": false}, fetched every 15 minutes instead of --carbon-intensity.`,
+	)
+	cmd.PersistentFlags().StringVar(
+		&OS.SecretsBackend, "secrets-backend", OS.SecretsBackend,
+		`Where to fetch the secrets of jobs and the credentials of the node from: env, file, vault or gcp. `+
+			`Jobs with secrets are not run if not set.`,
+	)
+	cmd.PersistentFlags().StringVar(
+		&OS.SecretsEnvPrefix, "secrets-env-prefix", OS.SecretsEnvPrefix,
+		`What the environment variables of secrets start with, for the env secrets backend.`,
+	)
+	cmd.PersistentFlags().StringVar(
+		&OS.SecretsDir, "secrets-dir", OS.SecretsDir,
+		`The directory with a file per secret, for the file secrets backend.`,
+	)
+	cmd.PersistentFlags().StringVar(
+		&OS.VaultAddress, "vault-address", OS.VaultAddress,
+		`The address of the Vault server, for the vault secrets backend. The token is read from $VAULT_TOKEN.`,
+	)
+	cmd.PersistentFlags().StringVar(
+		&OS.VaultMount, "vault-mount", OS.VaultMount,
+		`The mount of the KV version 2 secrets engine the secrets are in, for the vault secrets backend.`,
+	)
+	cmd.PersistentFlags().StringVar(
+		&OS.VaultPath, "vault-path", OS.VaultPath,
+		`The path under the mount that the secrets are in, for the vault secrets backend. Each secret is `+
+			`an entry with its value under the "value" key.`,
+	)
+	cmd.PersistentFlags().StringVar(
+		&OS.SecretsGCPProject, "secrets-gcp-project", OS.SecretsGCPProject,
+		`The Google Cloud project the secrets are in, for the gcp secrets backend.`,
+	)
+	cmd.PersistentFlags().StringVar(
+		&OS.SecretsGCPCredentialsFile, "secrets-gcp-credentials-file", OS.SecretsGCPCredentialsFile,
+		`The Google credentials file to read Secret Manager secrets with, for the gcp secrets backend. Defaults `+
+			`to $GOOGLE_APPLICATION_CREDENTIALS, then the service account of the GCE VM.`,
+	)
+	cmd.PersistentFlags().DurationVar(
+		&OS.SecretsRefreshInterval, "secrets-refresh-interval", OS.SecretsRefreshInterval,
+		`How long to use the credentials of the node fetched from the secrets backend for before fetching them `+
+			`again, so that they can be rotated without a restart.`,
+	)
+	cmd.PersistentFlags().StringSliceVar(
+		&OS.AllowedSecrets, "allow-job-secrets", OS.AllowedSecrets,
+		`The secrets of the secrets backend that jobs may request, as NAME to allow the jobs of any client or `+
+			`NAME=CLIENT_ID to allow the jobs of one client, given once per client. Jobs may request no secrets if not set, `+
+			`and never the secrets of the credentials of the node, such as --estuary-api-key-secret.`,
+	)
+	cmd.PersistentFlags().StringVar(
+		&OS.MaxInlineResults, "max-inline-results", OS.MaxInlineResults,
+		`The most the output files of a job can add up to for their contents to be shown in the job state, `+
+			`without fetching the results (e.g. 1Kb).`,
+	)
+	cmd.PersistentFlags().StringVar(
+		&OS.LimitIPFSIngress, "limit-ipfs-ingress", OS.LimitIPFSIngress,
+		`The most data to fetch from IPFS per second, such as job inputs (e.g. 500Kb, 10Mb), no limit if not set.`,
+	)
+	cmd.PersistentFlags().StringVar(
+		&OS.LimitIPFSEgress, "limit-ipfs-egress", OS.LimitIPFSEgress,
+		`The most data to add to IPFS per second, such as published results (e.g. 500Kb, 10Mb), no limit if not set.`,
 	)
 }
 
 func setupLibp2pCLIFlags(cmd *cobra.Command, OS *ServeOptions) {
 	cmd.PersistentFlags().StringVar(
 		&OS.PeerConnect, "peer", OS.PeerConnect,
-		`The libp2p multiaddress to connect to.`,
+		`The libp2p multiaddress to connect to. /dnsaddr addresses are looked up each time they are connected to. `+
+			`Defaults to the bootstrap peers of --network.`,
 	)
 	cmd.PersistentFlags().StringVar(
 		&OS.HostAddress, "host", OS.HostAddress,
@@ -150,6 +612,111 @@ func setupLibp2pCLIFlags(cmd *cobra.Command, OS *ServeOptions) {
 		&OS.SwarmPort, "swarm-port", OS.SwarmPort,
 		`The port to listen on for swarm connections.`,
 	)
+	cmd.PersistentFlags().IntVar(
+		&OS.GossipBatchSize, "gossip-batch-size", OS.GossipBatchSize,
+		`Maximum number of job events to send in a single gossip message (1 disables batching).`,
+	)
+	cmd.PersistentFlags().DurationVar(
+		&OS.GossipBatchInterval, "gossip-batch-interval", OS.GossipBatchInterval,
+		`How long to wait for a gossip batch to fill up before sending it.`,
+	)
+	cmd.PersistentFlags().StringSliceVar(
+		&OS.SubscribeEngines, "subscribe-engines", OS.SubscribeEngines,
+		fmt.Sprintf(`Only receive jobs for these engines (%s). Jobs for other engines will not be listed by this node.`,
+			strings.Join(model.EngineNames(), ", ")),
+	)
+	cmd.PersistentFlags().BoolVar(
+		&OS.LegacyJobAnnouncements, "legacy-job-announcements", OS.LegacyJobAnnouncements,
+		`Also announce new jobs on the topic that nodes from before per-engine announcements listen on. `+
+			`Only disable once every node in the network has been upgraded.`,
+	)
+	cmd.PersistentFlags().IntVar(
+		&OS.EventQueueSize, "event-queue-size", OS.EventQueueSize,
+		`Maximum number of events of each priority waiting to be handled, so that a storm of gossiped events `+
+			`doesn't hold up the jobs the node runs.`,
+	)
+	cmd.PersistentFlags().IntVar(
+		&OS.EventWorkers, "event-workers", OS.EventWorkers,
+		`Number of job announcements to handle at once. Bids and results are handled first, one at a time.`,
+	)
+	cmd.PersistentFlags().StringVar(
+		&OS.EventDropPolicy, "event-drop-policy", OS.EventDropPolicy,
+		fmt.Sprintf(`Which job announcements to drop when --event-queue-size of them are waiting (%s, %s or %s).`,
+			pubsub.DropNewest, pubsub.DropOldest, pubsub.Block),
+	)
+	cmd.PersistentFlags().Float64Var(
+		&OS.MaxAnnouncementRate, "max-announcement-rate", OS.MaxAnnouncementRate,
+		`Maximum number of job announcements to handle per second (0 for no limit).`,
+	)
+	cmd.PersistentFlags().BoolVar(
+		&OS.EnableRelay, "relay", OS.EnableRelay,
+		`Accept and make connections through circuit relays.`,
+	)
+	cmd.PersistentFlags().BoolVar(
+		&OS.EnableRelayService, "relay-service", OS.EnableRelayService,
+		`Act as a circuit relay for other nodes when publicly reachable.`,
+	)
+	cmd.PersistentFlags().BoolVar(
+		&OS.EnableAutoRelay, "auto-relay", OS.EnableAutoRelay,
+		`Advertise an address through a relay when this node is not publicly reachable (e.g. behind a NAT).`,
+	)
+	cmd.PersistentFlags().StringSliceVar(
+		&OS.StaticRelays, "static-relays", OS.StaticRelays,
+		`The libp2p multiaddresses of the relays to use with --auto-relay. Defaults to the bootstrap peers.`,
+	)
+	cmd.PersistentFlags().BoolVar(
+		&OS.EnableHolePunching, "hole-punching", OS.EnableHolePunching,
+		`Upgrade relayed connections to direct connections using hole punching.`,
+	)
+	cmd.PersistentFlags().BoolVar(
+		&OS.EnableNATPortMap, "nat-port-map", OS.EnableNATPortMap,
+		`Ask the router to open a port for this node using UPnP or NAT-PMP.`,
+	)
+	cmd.PersistentFlags().BoolVar(
+		&OS.EnableNATService, "nat-service", OS.EnableNATService,
+		`Help other nodes find out whether they are publicly reachable.`,
+	)
+	cmd.PersistentFlags().IntVar(
+		&OS.ConnectionsLowWater, "connections-low-water", OS.ConnectionsLowWater,
+		`The number of peer connections to prune down to once there are more than --connections-high-water.`,
+	)
+	cmd.PersistentFlags().IntVar(
+		&OS.ConnectionsHighWater, "connections-high-water", OS.ConnectionsHighWater,
+		`The number of peer connections above which the least useful ones are closed. `+
+			`Connections to peers running a shard with this node are never closed.`,
+	)
+	cmd.PersistentFlags().DurationVar(
+		&OS.ConnectionsGracePeriod, "connections-grace-period", OS.ConnectionsGracePeriod,
+		`How long new peer connections are exempt from being closed.`,
+	)
+	cmd.PersistentFlags().StringVar(
+		&OS.BootstrapRefreshURL, "bootstrap-refresh-url", OS.BootstrapRefreshURL,
+		`A URL to fetch more peers to connect to from, one libp2p multiaddress per line. `+
+			`Defaults to that of the --network when --peer isn't set.`,
+	)
+	cmd.PersistentFlags().DurationVar(
+		&OS.BootstrapRefreshInterval, "bootstrap-refresh-interval", OS.BootstrapRefreshInterval,
+		`How often to fetch the peers at --bootstrap-refresh-url again.`,
+	)
+}
+
+// getIPFSClient connects to the IPFS daemon at --ipfs-connect, or starts an
+// in-process IPFS node that is shut down by the cleanup manager.
+func getIPFSClient(ctx context.Context, cm *system.CleanupManager, OS *ServeOptions) (*ipfs.Client, error) {
+	if !OS.IPFSEmbedded {
+		return ipfs.NewClient(OS.IPFSConnect)
+	}
+
+	repoPath := OS.IPFSRepo
+	if repoPath == "" {
+		repoPath = filepath.Join(config.GetConfigPath(), "ipfs")
+	}
+	node, err := ipfs.NewNodeWithRepo(ctx, cm, repoPath, OS.IPFSSwarmAddresses)
+	if err != nil {
+		return nil, fmt.Errorf("error starting in-process IPFS node: %w", err)
+	}
+	node.LogDetails()
+	return node.Client()
 }
 
 func getPeers(OS *ServeOptions) []multiaddr.Multiaddr {
@@ -157,7 +724,7 @@ func getPeers(OS *ServeOptions) []multiaddr.Multiaddr {
 	if OS.PeerConnect == "none" {
 		peersStrings = []string{}
 	} else if OS.PeerConnect == "" {
-		peersStrings = DefaultBootstrapAddresses
+		peersStrings = selectedNetwork.BootstrapAddresses
 	} else {
 		peersStrings = strings.Split(OS.PeerConnect, ",")
 	}
@@ -169,6 +736,65 @@ func getPeers(OS *ServeOptions) []multiaddr.Multiaddr {
 	return peers
 }
 
+func getTransportConfig(OS *ServeOptions) (libp2p.TransportConfig, error) {
+	natConfig, err := getNATConfig(OS)
+	if err != nil {
+		return libp2p.TransportConfig{}, err
+	}
+	return libp2p.TransportConfig{
+		NAT: natConfig,
+		ConnectionManager: libp2p.ConnectionManagerConfig{
+			LowWater:    OS.ConnectionsLowWater,
+			HighWater:   OS.ConnectionsHighWater,
+			GracePeriod: OS.ConnectionsGracePeriod,
+		},
+		Bootstrap: getBootstrapConfig(OS),
+	}, nil
+}
+
+func getBootstrapConfig(OS *ServeOptions) libp2p.BootstrapConfig {
+	refreshURL := OS.BootstrapRefreshURL
+	if refreshURL == "" && OS.PeerConnect == "" {
+		refreshURL = selectedNetwork.BootstrapRefreshURL
+	}
+	return libp2p.BootstrapConfig{
+		RefreshURL:      refreshURL,
+		RefreshInterval: OS.BootstrapRefreshInterval,
+	}
+}
+
+func getNATConfig(OS *ServeOptions) (libp2p.NATConfig, error) {
+	staticRelays := make([]multiaddr.Multiaddr, 0, len(OS.StaticRelays))
+	for _, relay := range OS.StaticRelays {
+		addr, err := multiaddr.NewMultiaddr(relay)
+		if err != nil {
+			return libp2p.NATConfig{}, fmt.Errorf("invalid --static-relays %q: %w", relay, err)
+		}
+		staticRelays = append(staticRelays, addr)
+	}
+	return libp2p.NATConfig{
+		EnableRelay:        OS.EnableRelay,
+		EnableRelayService: OS.EnableRelayService,
+		EnableAutoRelay:    OS.EnableAutoRelay,
+		StaticRelays:       staticRelays,
+		EnableHolePunching: OS.EnableHolePunching,
+		EnableNATPortMap:   OS.EnableNATPortMap,
+		EnableNATService:   OS.EnableNATService,
+	}, nil
+}
+
+func getSubscribeEngines(OS *ServeOptions) ([]model.Engine, error) {
+	engines := make([]model.Engine, 0, len(OS.SubscribeEngines))
+	for _, name := range OS.SubscribeEngines {
+		engine, err := model.ParseEngine(name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --subscribe-engines: %w", err)
+		}
+		engines = append(engines, engine)
+	}
+	return engines, nil
+}
+
 func getJobSelectionConfig(OS *ServeOptions) model.JobSelectionPolicy {
 	// construct the job selection policy from the CLI args
 	typedJobSelectionDataLocality := model.Anywhere
@@ -182,6 +808,12 @@ func getJobSelectionConfig(OS *ServeOptions) model.JobSelectionPolicy {
 		RejectStatelessJobs: OS.JobSelectionDataRejectStateless,
 		ProbeHTTP:           OS.JobSelectionProbeHTTP,
 		ProbeExec:           OS.JobSelectionProbeExec,
+		AllowedClients:      OS.JobSelectionAllowClients,
+		DeniedClients:       OS.JobSelectionDenyClients,
+		RejectAnonymousJobs: OS.JobSelectionRejectAnonymous,
+		DenylistURL:         OS.JobSelectionDenylistURL,
+		AvailabilityWindows: OS.JobSelectionAvailability,
+		BlackoutWindows:     OS.JobSelectionBlackout,
 	}
 
 	return jobSelectionPolicy
@@ -190,6 +822,14 @@ func getJobSelectionConfig(OS *ServeOptions) model.JobSelectionPolicy {
 func getComputeConfig(OS *ServeOptions) node.ComputeConfig {
 	return node.NewComputeConfigWith(node.ComputeConfigParams{
 		JobSelectionPolicy: getJobSelectionConfig(OS),
+		ReportBidDeclines:  OS.JobSelectionReportDeclines,
+		Zone:               OS.Zone,
+		Renewable:          OS.Renewable,
+		CarbonIntensity:    getCarbonIntensity(OS),
+		CarbonIntensityURL: OS.CarbonIntensityURL,
+		Secrets:            getSecretsParams(OS),
+		AllowedSecrets:     OS.AllowedSecrets,
+		CredentialSecrets:  getCredentialSecrets(OS),
 		TotalResourceLimits: capacity.ParseResourceUsageConfig(model.ResourceUsageConfig{
 			CPU:    OS.LimitTotalCPU,
 			Memory: OS.LimitTotalMemory,
@@ -200,10 +840,72 @@ func getComputeConfig(OS *ServeOptions) node.ComputeConfig {
 			Memory: OS.LimitJobMemory,
 			GPU:    OS.LimitJobGPU,
 		}),
+		SpotResourceLimits: capacity.ParseResourceUsageConfig(model.ResourceUsageConfig{
+			CPU:    OS.LimitSpotCPU,
+			Memory: OS.LimitSpotMemory,
+			GPU:    OS.LimitSpotGPU,
+		}),
+		DefaultJobResourceProfiles:   getDefaultJobResourceProfiles(OS),
+		MaxConcurrentJobs:            OS.LimitJobCount,
+		GarbageCollectionInterval:    OS.GarbageCollectionInterval,
+		GarbageCollectionMinAge:      OS.GarbageCollectionMinAge,
+		EventQueueSize:               OS.EventQueueSize,
+		EventWorkers:                 OS.EventWorkers,
+		EventDropPolicy:              pubsub.EventDropPolicy(OS.EventDropPolicy),
+		AnnouncementRate:             OS.MaxAnnouncementRate,
+		MaxInlineResultsSize:         capacity.ConvertBytesString(OS.MaxInlineResults),
+		OutputPolicy:                 getOutputPolicy(OS),
 		IgnorePhysicalResourceLimits: os.Getenv("BACALHAU_CAPACITY_MANAGER_OVER_COMMIT") != "",
 	})
 }
 
+// validateResourceLimits checks that the resource limits of the node parse,
+// as a limit that doesn't would silently mean no limit.
+func validateResourceLimits(OS *ServeOptions) error {
+	cores := []struct{ flag, value string }{
+		{"--limit-total-cpu", OS.LimitTotalCPU},
+		{"--limit-job-cpu", OS.LimitJobCPU},
+		{"--limit-spot-cpu", OS.LimitSpotCPU},
+		{"--limit-total-gpu", OS.LimitTotalGPU},
+		{"--limit-job-gpu", OS.LimitJobGPU},
+		{"--limit-spot-gpu", OS.LimitSpotGPU},
+	}
+	for _, limit := range cores {
+		if _, err := capacity.ParseCPUString(limit.value); err != nil {
+			return fmt.Errorf("invalid %s: %w", limit.flag, err)
+		}
+	}
+	sizes := []struct{ flag, value string }{
+		{"--limit-total-memory", OS.LimitTotalMemory},
+		{"--limit-job-memory", OS.LimitJobMemory},
+		{"--limit-spot-memory", OS.LimitSpotMemory},
+		{"--max-inline-results", OS.MaxInlineResults},
+	}
+	for _, limit := range sizes {
+		if _, err := capacity.ParseBytesString(limit.value); err != nil {
+			return fmt.Errorf("invalid %s: %w", limit.flag, err)
+		}
+	}
+	for _, value := range OS.DefaultJobResources {
+		if _, err := capacity.ParseResourceProfile(value); err != nil {
+			return fmt.Errorf("invalid --default-job-resources: %w", err)
+		}
+	}
+	return nil
+}
+
+// getDefaultJobResourceProfiles returns the resource profiles of the node,
+// skipping those that don't parse, which validateResourceLimits rejects.
+func getDefaultJobResourceProfiles(OS *ServeOptions) capacity.ResourceProfiles {
+	var profiles capacity.ResourceProfiles
+	for _, value := range OS.DefaultJobResources {
+		if profile, err := capacity.ParseResourceProfile(value); err == nil {
+			profiles = append(profiles, profile)
+		}
+	}
+	return profiles
+}
+
 func newServeCmd() *cobra.Command {
 	OS := NewServeOptions()
 
@@ -219,7 +921,19 @@ func newServeCmd() *cobra.Command {
 
 	serveCmd.PersistentFlags().StringVar(
 		&OS.IPFSConnect, "ipfs-connect", OS.IPFSConnect,
-		`The ipfs host multiaddress to connect to.`,
+		`The ipfs host multiaddress to connect to, or a comma-separated list of them to fail over between.`,
+	)
+	serveCmd.PersistentFlags().BoolVar(
+		&OS.IPFSEmbedded, "ipfs-embedded", OS.IPFSEmbedded,
+		`Run an in-process IPFS node rather than connecting to an ipfs daemon with --ipfs-connect.`,
+	)
+	serveCmd.PersistentFlags().StringVar(
+		&OS.IPFSRepo, "ipfs-repo", OS.IPFSRepo,
+		`The repo of the in-process IPFS node, which is kept between restarts. Defaults to ipfs in the bacalhau config directory.`,
+	)
+	serveCmd.PersistentFlags().StringSliceVar(
+		&OS.IPFSSwarmAddresses, "ipfs-swarm-addrs", OS.IPFSSwarmAddresses,
+		`The IPFS multiaddresses the in-process IPFS node peers with, in addition to the public IPFS network.`,
 	)
 	serveCmd.PersistentFlags().StringVar(
 		&OS.FilecoinUnsealedPath, "filecoin-unsealed-path", OS.FilecoinUnsealedPath,
@@ -229,10 +943,41 @@ func newServeCmd() *cobra.Command {
 		&OS.EstuaryAPIKey, "estuary-api-key", OS.EstuaryAPIKey,
 		`The API key used when using the estuary API.`,
 	)
+	serveCmd.PersistentFlags().StringVar(
+		&OS.EstuaryAPIKeySecret, "estuary-api-key-secret", OS.EstuaryAPIKeySecret,
+		`The name of the secret to fetch the estuary API key from with --secrets-backend, instead of --estuary-api-key.`,
+	)
+	serveCmd.PersistentFlags().StringVar(
+		&OS.EstuaryUploadedBlocks, "estuary-uploaded-blocks", OS.EstuaryUploadedBlocks,
+		`The file to record the blocks uploaded to Estuary in, so that jobs that publish incrementally only upload `+
+			`new blocks. Results are uploaded whole if not set.`,
+	)
+	serveCmd.PersistentFlags().StringVar(
+		&OS.EstuaryCollection, "estuary-collection", OS.EstuaryCollection,
+		`The UUID of the Estuary collection to add published results to, rather than the root of the account.`,
+	)
+	serveCmd.PersistentFlags().IntVar(
+		&OS.EstuaryReplication, "estuary-replication", OS.EstuaryReplication,
+		`How many storage deals Estuary makes for published results (0 for the default of Estuary).`,
+	)
+	serveCmd.PersistentFlags().StringVar(
+		&OS.EstuaryRegion, "estuary-region", OS.EstuaryRegion,
+		`The region Estuary should prefer to store published results in. Only a hint, which Estuary may not follow.`,
+	)
 	serveCmd.PersistentFlags().IntVar(
 		&OS.MetricsPort, "metrics-port", OS.MetricsPort,
 		`The port to serve prometheus metrics on.`,
 	)
+	serveCmd.PersistentFlags().IntVar(
+		&OS.APIGRPCPort, "api-grpc-port", OS.APIGRPCPort,
+		`The port to serve the gRPC API on, next to the REST API. Not served if 0.`,
+	)
+	serveCmd.PersistentFlags().StringSliceVar(
+		&OS.CallbackAllowedNetworks, "callback-allowed-networks", OS.CallbackAllowedNetworks,
+		`The networks, in CIDR notation, that webhooks and wait callbacks can be posted to although they are `+
+			`loopback, link-local or private, e.g. 10.0.0.0/8. Callbacks to any other such address are refused, `+
+			`so that clients can't make the node post to its own network.`,
+	)
 	serveCmd.PersistentFlags().DurationVar(
 		&OS.LotusFilecoinStorageDuration, "lotus-storage-duration", OS.LotusFilecoinStorageDuration,
 		"Duration to store data in Lotus Filecoin for.",
@@ -249,6 +994,278 @@ func newServeCmd() *cobra.Command {
 		&OS.LotusFilecoinMaximumPing, "lotus-max-ping", OS.LotusFilecoinMaximumPing,
 		"The highest ping a Filecoin miner could have when selecting.",
 	)
+	serveCmd.PersistentFlags().BoolVar(
+		&OS.LotusRetrieval, "lotus-retrieval", OS.LotusRetrieval,
+		"Retrieve inputs that can't be found over IPFS from Filecoin storage providers, using the Lotus node in --lotus-path-directory.",
+	)
+	serveCmd.PersistentFlags().StringVar(
+		&OS.LotusRetrievalDirectory, "lotus-retrieval-directory", OS.LotusRetrievalDirectory,
+		"Directory the Lotus node exports retrieved inputs to, which it must be able to write to.",
+	)
+	serveCmd.PersistentFlags().StringVar(
+		&OS.LotusRetrievalMaxPrice, "lotus-retrieval-max-price", OS.LotusRetrievalMaxPrice,
+		"The most to pay for retrieving an input from Filecoin, in attoFIL. Only free retrievals are made if not set.",
+	)
+	serveCmd.PersistentFlags().StringVar(
+		&OS.IPFSClusterAPI, "ipfs-cluster-api", OS.IPFSClusterAPI,
+		`The URL of an ipfs-cluster REST API to also pin results published to IPFS on, e.g. http://127.0.0.1:9094.`,
+	)
+	serveCmd.PersistentFlags().StringVar(
+		&OS.IPFSClusterBasicAuth, "ipfs-cluster-basic-auth", OS.IPFSClusterBasicAuth,
+		`The user:password credentials of the ipfs-cluster REST API. Defaults to $IPFS_CLUSTER_BASIC_AUTH.`,
+	)
+	serveCmd.PersistentFlags().StringVar(
+		&OS.IPFSClusterBasicAuthSecret, "ipfs-cluster-basic-auth-secret", OS.IPFSClusterBasicAuthSecret,
+		`The name of the secret to fetch the user:password credentials of the ipfs-cluster REST API from with `+
+			`--secrets-backend, instead of --ipfs-cluster-basic-auth.`,
+	)
+	serveCmd.PersistentFlags().IntVar(
+		&OS.IPFSClusterReplicationMin, "ipfs-cluster-replication-min", OS.IPFSClusterReplicationMin,
+		`The fewest ipfs-cluster peers that should pin each result (0 for the cluster's default, -1 for every peer).`,
+	)
+	serveCmd.PersistentFlags().IntVar(
+		&OS.IPFSClusterReplicationMax, "ipfs-cluster-replication-max", OS.IPFSClusterReplicationMax,
+		`The most ipfs-cluster peers that should pin each result (0 for the cluster's default, -1 for every peer).`,
+	)
+	serveCmd.PersistentFlags().StringVar(
+		&OS.AzureSASToken, "azure-sas-token", OS.AzureSASToken,
+		`A shared access signature to read az:// inputs from Azure Blob Storage with. Defaults to $AZURE_STORAGE_SAS_TOKEN.`,
+	)
+	serveCmd.PersistentFlags().StringVar(
+		&OS.AzureSASTokenSecret, "azure-sas-token-secret", OS.AzureSASTokenSecret,
+		`The name of the secret to fetch the shared access signature from with --secrets-backend, `+
+			`instead of --azure-sas-token.`,
+	)
+	serveCmd.PersistentFlags().BoolVar(
+		&OS.AzureManagedIdentity, "azure-managed-identity", OS.AzureManagedIdentity,
+		`Read az:// inputs with the managed identity of the Azure VM the node runs on, when no SAS token is given.`,
+	)
+	serveCmd.PersistentFlags().StringVar(
+		&OS.AzureManagedIdentityClientID, "azure-managed-identity-client-id", OS.AzureManagedIdentityClientID,
+		`The client ID of the user-assigned managed identity to use with --azure-managed-identity.`,
+	)
+	serveCmd.PersistentFlags().StringVar(
+		&OS.GCSCredentialsFile, "gcs-credentials-file", OS.GCSCredentialsFile,
+		`A service account key or authorized user credentials file to read gs:// inputs from Google Cloud Storage with. `+
+			`Defaults to $GOOGLE_APPLICATION_CREDENTIALS, or else the service account of the GCE VM the node runs on.`,
+	)
+	serveCmd.PersistentFlags().BoolVar(
+		&OS.GCSAnonymous, "gcs-anonymous", OS.GCSAnonymous,
+		`Read gs:// inputs from public Google Cloud Storage buckets without credentials.`,
+	)
+	serveCmd.PersistentFlags().StringVar(
+		&OS.HuggingFaceToken, "huggingface-token", OS.HuggingFaceToken,
+		`An access token to download private and gated hf:// models from the Hugging Face Hub with. Defaults to $HF_TOKEN.`,
+	)
+	serveCmd.PersistentFlags().StringVar(
+		&OS.HuggingFaceTokenSecret, "huggingface-token-secret", OS.HuggingFaceTokenSecret,
+		`The name of the secret to fetch the Hugging Face access token from with --secrets-backend, `+
+			`instead of --huggingface-token.`,
+	)
+	serveCmd.PersistentFlags().StringVar(
+		&OS.HuggingFaceCacheDir, "huggingface-cache-dir", OS.HuggingFaceCacheDir,
+		`Where to cache the hf:// models jobs use, so each revision is only downloaded once. `+
+			`Defaults to a directory in $BACALHAU_STORAGE_PATH.`,
+	)
+	serveCmd.PersistentFlags().StringVar(
+		&OS.HuggingFaceCacheSize, "huggingface-cache-size", OS.HuggingFaceCacheSize,
+		`The most the hf:// models in --huggingface-cache-dir can take up, e.g. 100Gb. The least recently used `+
+			`models no job is using are removed to make room for new ones. Defaults to 50Gb.`,
+	)
+	serveCmd.PersistentFlags().StringSliceVar(
+		&OS.LocalDirectories, "local-directories", OS.LocalDirectories,
+		`Directories on this node that jobs can read as local://NAME inputs, as NAME=PATH, e.g. private datasets `+
+			`that never leave the node. See --restrict-outputs-max-size for what their jobs can publish.`,
+	)
+	serveCmd.PersistentFlags().StringSliceVar(
+		&OS.AllowedLocalPaths, "allow-local-paths", OS.AllowedLocalPaths,
+		`Host paths that jobs can read directories under as local:///PATH inputs, read-only like --local-directories.`,
+	)
+	serveCmd.PersistentFlags().StringVar(
+		&OS.RestrictOutputsMaxSize, "restrict-outputs-max-size", OS.RestrictOutputsMaxSize,
+		`The most the results of jobs that read --local-directories, including stdout and stderr, can add up to `+
+			`for them to be published, e.g. 10Kb. No limit if not set.`,
+	)
+	serveCmd.PersistentFlags().IntVar(
+		&OS.RestrictOutputsMaxRows, "restrict-outputs-max-rows", OS.RestrictOutputsMaxRows,
+		`The most lines each file of the results of jobs that read --local-directories can have for them to be `+
+			`published. No limit if 0.`,
+	)
+	serveCmd.PersistentFlags().StringVar(
+		&OS.RestrictOutputsFilter, "restrict-outputs-filter", OS.RestrictOutputsFilter,
+		`A program that must exit with 0 for the results of jobs that read --local-directories to be published, `+
+			`run with the results directory as its argument.`,
+	)
+
+	serveCmd.PersistentFlags().StringVar(
+		&OS.EventLogPath, "event-log-path", OS.EventLogPath,
+		`Keep an event log of job state in this directory and rebuild the state from it on restart. The /denylist is kept there too.`,
+	)
+	serveCmd.PersistentFlags().Uint64Var(
+		&OS.EventLogSnapshotInterval, "event-log-snapshot-interval", OS.EventLogSnapshotInterval,
+		`Number of events between snapshots of the job state (0 to disable snapshots).`,
+	)
+	serveCmd.PersistentFlags().DurationVar(
+		&OS.RequesterBidWindow, "requester-bid-window", OS.RequesterBidWindow,
+		`How long to collect bids for a job before selecting the best of them (0 to select as soon as the job's minimum bids arrive).`,
+	)
+	serveCmd.PersistentFlags().BoolVar(
+		&OS.RequireApproval, "require-approval", OS.RequireApproval,
+		`Hold submitted jobs until one of the --approver approves them, rather than announcing them to the network straight away.`,
+	)
+	serveCmd.PersistentFlags().StringSliceVar(
+		&OS.Approvers, "approver", OS.Approvers,
+		`The ID of a client that can approve or reject the jobs held with --require-approval. Can be repeated.`,
+	)
+	serveCmd.PersistentFlags().StringSliceVar(
+		&OS.Moderators, "moderator", OS.Moderators,
+		`The ID of a client that can flag clients and jobs as abusive, adding them to the /denylist. Can be repeated.`,
+	)
+
+	serveCmd.PersistentFlags().BoolVar(
+		&OS.SelfTest, "self-test", OS.SelfTest,
+		`Check Docker, IPFS, disk space, outbound connectivity and clock skew, print a diagnosis and exit.`,
+	)
+	serveCmd.PersistentFlags().StringVar(
+		&OS.SelfTestOutput, "self-test-output", OS.SelfTestOutput,
+		`The output format of --self-test (json or text)`,
+	)
+
+	serveCmd.PersistentFlags().DurationVar(
+		&OS.DrainTimeout, "drain-timeout", OS.DrainTimeout,
+		`How long to wait for running jobs to finish on SIGTERM before exiting (0 to exit straight away).`,
+	)
+	serveCmd.PersistentFlags().DurationVar(
+		&OS.GarbageCollectionInterval, "garbage-collection-interval", OS.GarbageCollectionInterval,
+		`How often to remove the containers and temporary directories left behind by executions that crashed, `+
+			`after doing so on startup (0 to never remove them).`,
+	)
+	serveCmd.PersistentFlags().DurationVar(
+		&OS.GarbageCollectionMinAge, "garbage-collection-min-age", OS.GarbageCollectionMinAge,
+		`How long temporary directories left behind by crashed executions are left untouched before they are removed, `+
+			`so that those of other nodes on the same host aren't.`,
+	)
+	serveCmd.PersistentFlags().BoolVar(
+		&OS.KubernetesExecutor, "kubernetes-executor", OS.KubernetesExecutor,
+		`Run docker jobs as pods through the Kubernetes API instead of on the Docker daemon.`,
+	)
+	serveCmd.PersistentFlags().StringVar(
+		&OS.KubernetesNamespace, "kubernetes-namespace", OS.KubernetesNamespace,
+		`The namespace to run job pods in, the namespace the node runs in if not set.`,
+	)
+	serveCmd.PersistentFlags().StringVar(
+		&OS.KubernetesNodeName, "kubernetes-node-name", OS.KubernetesNodeName,
+		`The Kubernetes node the compute node runs on, which job pods are scheduled on. Defaults to $NODE_NAME.`,
+	)
+	serveCmd.PersistentFlags().StringVar(
+		&OS.Kubeconfig, "kubeconfig", OS.Kubeconfig,
+		`The kubeconfig to talk to the cluster with when the node runs outside of it. Defaults to $KUBECONFIG.`,
+	)
+	serveCmd.PersistentFlags().BoolVar(
+		&OS.NomadExecutor, "nomad-executor", OS.NomadExecutor,
+		`Run docker jobs as Nomad jobs instead of on the Docker daemon.`,
+	)
+	serveCmd.PersistentFlags().StringVar(
+		&OS.NomadAddress, "nomad-address", OS.NomadAddress,
+		`The address of the HTTP API of a Nomad agent. Defaults to $NOMAD_ADDR, or `+nomad.DefaultAddress+` if not set.`,
+	)
+	serveCmd.PersistentFlags().StringVar(
+		&OS.NomadNamespace, "nomad-namespace", OS.NomadNamespace,
+		`The Nomad namespace to run jobs in. Defaults to $NOMAD_NAMESPACE.`,
+	)
+	serveCmd.PersistentFlags().StringVar(
+		&OS.NomadRegion, "nomad-region", OS.NomadRegion,
+		`The Nomad region to run jobs in. Defaults to $NOMAD_REGION.`,
+	)
+	serveCmd.PersistentFlags().StringSliceVar(
+		&OS.NomadDatacenters, "nomad-datacenters", OS.NomadDatacenters,
+		`The Nomad datacenters jobs can run in.`,
+	)
+	serveCmd.PersistentFlags().StringVar(
+		&OS.NomadNodeName, "nomad-node-name", OS.NomadNodeName,
+		`The name of the Nomad client the compute node runs on, which jobs are constrained to. Defaults to the hostname.`,
+	)
+	serveCmd.PersistentFlags().IntVar(
+		&OS.NomadMHzPerCPU, "nomad-mhz-per-cpu", OS.NomadMHzPerCPU,
+		`How many MHz of CPU Nomad reserves for each CPU a job asks for.`,
+	)
+	serveCmd.PersistentFlags().BoolVar(
+		&OS.SlurmExecutor, "slurm-executor", OS.SlurmExecutor,
+		`Run docker jobs as Slurm batch jobs instead of on the Docker daemon.`,
+	)
+	serveCmd.PersistentFlags().StringVar(
+		&OS.SlurmPartition, "slurm-partition", OS.SlurmPartition,
+		`The Slurm partition to submit jobs to. Defaults to the default partition of the cluster.`,
+	)
+	serveCmd.PersistentFlags().StringVar(
+		&OS.SlurmAccount, "slurm-account", OS.SlurmAccount,
+		`The Slurm account to charge jobs to. Defaults to the default account of the user.`,
+	)
+	serveCmd.PersistentFlags().StringSliceVar(
+		&OS.SlurmSbatchArgs, "slurm-sbatch-args", OS.SlurmSbatchArgs,
+		`More options to submit jobs to sbatch with, e.g. --qos=bacalhau.`,
+	)
+	serveCmd.PersistentFlags().StringVar(
+		&OS.SlurmScriptTemplate, "slurm-script-template", OS.SlurmScriptTemplate,
+		`The path of a Go text/template for the batch script of jobs, which runs their image with Apptainer by default.`,
+	)
+	serveCmd.PersistentFlags().BoolVar(
+		&OS.FirecrackerExecutor, "firecracker-executor", OS.FirecrackerExecutor,
+		`Run docker jobs in Firecracker microVMs instead of containers.`,
+	)
+	serveCmd.PersistentFlags().StringVar(
+		&OS.FirecrackerBinary, "firecracker-binary", OS.FirecrackerBinary,
+		`The path or name of the firecracker binary.`,
+	)
+	serveCmd.PersistentFlags().StringVar(
+		&OS.FirecrackerKernel, "firecracker-kernel", OS.FirecrackerKernel,
+		`The uncompressed Linux kernel that VMs boot, with virtio block devices, ext4 and devtmpfs built in.`,
+	)
+	serveCmd.PersistentFlags().StringVar(
+		&OS.FirecrackerOutputVolumeSize, "firecracker-output-volume-size", OS.FirecrackerOutputVolumeSize,
+		`How much a job running in Firecracker can write to each of its output volumes.`,
+	)
+	serveCmd.PersistentFlags().StringSliceVar(
+		&OS.DockerExecutors, "docker-executors", OS.DockerExecutors,
+		fmt.Sprintf(`The runtimes to run docker jobs on in order of preference, of %v. `+
+			`Jobs run on the first one that is up when they start.`, executor_util.DockerBackends()),
+	)
+	serveCmd.PersistentFlags().StringVar(
+		&OS.PodmanHost, "podman-host", OS.PodmanHost,
+		`The socket of the Docker compatible API of podman. Defaults to $CONTAINER_HOST, or `+
+			docker.DefaultPodmanHost+` if not set.`,
+	)
+	serveCmd.PersistentFlags().StringVar(
+		&OS.DockerNonRootUser, "docker-non-root-user", OS.DockerNonRootUser,
+		`The UID[:GID] to run docker jobs as when they would run as root, for operators who don't allow containers `+
+			`to run as root. Jobs that name a non-root user run as it.`,
+	)
+	serveCmd.PersistentFlags().StringVar(
+		&OS.DockerSeccompProfile, "docker-seccomp-profile", OS.DockerSeccompProfile,
+		`The path of a seccomp profile in JSON to run the containers of docker jobs with, `+
+			`or the default of the runtime if not set. This and the other hardening flags apply on the Docker daemon `+
+			`and podman, and the node says which it applies when it bids if docker jobs only run on them, so that `+
+			`jobs can require hardened nodes.`,
+	)
+	serveCmd.PersistentFlags().StringVar(
+		&OS.DockerAppArmorProfile, "docker-apparmor-profile", OS.DockerAppArmorProfile,
+		`The AppArmor profile, loaded on the host, to run the containers of docker jobs with, `+
+			`or the default of the runtime if not set.`,
+	)
+	serveCmd.PersistentFlags().Int64Var(
+		&OS.DockerPidsLimit, "docker-pids-limit", OS.DockerPidsLimit,
+		`The most processes the container of a docker job can run, or no limit if 0.`,
+	)
+	serveCmd.PersistentFlags().StringSliceVar(
+		&OS.DockerUlimits, "docker-ulimits", OS.DockerUlimits,
+		`The ulimits of the containers of docker jobs, as NAME=SOFT[:HARD], e.g. nofile=1024:2048.`,
+	)
+	serveCmd.PersistentFlags().DurationVar(
+		&OS.DockerDebugSessionTimeout, "debug-session-timeout", OS.DockerDebugSessionTimeout,
+		`How long to keep the environment of failed shards of docker jobs that ask for it, on the Docker daemon, `+
+			`for their clients to attach a shell to with 'bacalhau debug'. Not kept if 0. Kept environments have `+
+			`the memory and CPU limits of their jobs, but aren't counted against the capacity of the node.`,
+	)
 
 	setupLibp2pCLIFlags(serveCmd, OS)
 	setupJobSelectionCLIFlags(serveCmd, OS)
@@ -269,32 +1286,157 @@ func serve(cmd *cobra.Command, OS *ServeOptions) error {
 	ctx, cancel := system.WithSignalShutdown(ctx)
 	defer cancel()
 
+	// SIGTERM is how Kubernetes and systemd ask the node to stop, which it
+	// does once it has drained its jobs
+	terminate := make(chan os.Signal, 1)
+	signal.Notify(terminate, syscall.SIGTERM)
+	defer signal.Stop(terminate)
+
 	ctx, rootSpan := system.NewRootSpan(ctx, system.GetTracer(), "cmd/bacalhau/serve")
 	defer rootSpan.End()
 	cm.RegisterCallback(system.CleanupTraceProvider)
 
-	if OS.IPFSConnect == "" {
-		Fatal(cmd, "You must specify --ipfs-connect.", 1)
+	if OS.IPFSConnect == "" && !OS.IPFSEmbedded {
+		Fatal(cmd, "You must specify --ipfs-connect or --ipfs-embedded.", 1)
+	}
+	if OS.IPFSConnect != "" && OS.IPFSEmbedded {
+		Fatal(cmd, "You cannot specify both --ipfs-connect and --ipfs-embedded.", 1)
 	}
 
 	if OS.JobSelectionDataLocality != "local" && OS.JobSelectionDataLocality != "anywhere" {
 		Fatal(cmd, "--job-selection-data-locality must be either 'local' or 'anywhere'", 1)
 	}
+	for _, windows := range [][]string{OS.JobSelectionAvailability, OS.JobSelectionBlackout} {
+		if _, err := schedule.ParseWindows(windows); err != nil {
+			Fatal(cmd, err.Error(), 1)
+			return nil
+		}
+	}
+	if err := validateResourceLimits(OS); err != nil {
+		Fatal(cmd, err.Error(), 1)
+		return nil
+	}
+	nodeHardening, err := getDockerHardening(OS).NodeHardening()
+	if err != nil {
+		Fatal(cmd, fmt.Sprintf("Invalid docker hardening: %s", err), 1)
+		return nil
+	}
+	localDirectories, err := getLocalDirectories(OS)
+	if err != nil {
+		Fatal(cmd, fmt.Sprintf("Invalid --local-directories: %s", err), 1)
+		return nil
+	}
+	// an invalid size must not leave the outputs of private data unrestricted
+	if OS.RestrictOutputsMaxSize != "" && getOutputPolicy(OS).MaxSize == 0 {
+		Fatal(cmd, fmt.Sprintf("Invalid --restrict-outputs-max-size: %q", OS.RestrictOutputsMaxSize), 1)
+		return nil
+	}
+	if OS.HuggingFaceCacheSize != "" && capacity.ConvertBytesString(OS.HuggingFaceCacheSize) == 0 {
+		Fatal(cmd, fmt.Sprintf("Invalid --huggingface-cache-size: %q", OS.HuggingFaceCacheSize), 1)
+		return nil
+	}
+	if OS.RestrictOutputsMaxRows < 0 {
+		Fatal(cmd, "--restrict-outputs-max-rows must not be negative", 1)
+		return nil
+	}
+	if OS.EstuaryCollection != "" {
+		if _, err := uuid.Parse(OS.EstuaryCollection); err != nil {
+			Fatal(cmd, fmt.Sprintf("Invalid --estuary-collection: %s", err), 1)
+			return nil
+		}
+	}
+	if OS.EstuaryReplication < 0 {
+		Fatal(cmd, "--estuary-replication must not be negative", 1)
+		return nil
+	}
+	if _, err := pubsub.ParseEventDropPolicy(OS.EventDropPolicy); err != nil {
+		Fatal(cmd, fmt.Sprintf("Invalid --event-drop-policy: %s", err), 1)
+		return nil
+	}
+	if OS.EventQueueSize < 1 || OS.EventWorkers < 1 || OS.MaxAnnouncementRate < 0 {
+		Fatal(cmd, "--event-queue-size and --event-workers must be positive, and --max-announcement-rate not negative", 1)
+		return nil
+	}
+	if OS.RequireApproval && len(OS.Approvers) == 0 {
+		Fatal(cmd, "--require-approval needs at least one --approver to approve the jobs", 1)
+		return nil
+	}
+	hasLocalDirectories := len(localDirectories.Directories) > 0 || len(localDirectories.AllowedPaths) > 0
+	if hasLocalDirectories && !getOutputPolicy(OS).IsSet() {
+		log.Ctx(ctx).Warn().Msg("The results of jobs that read local directories are published whole, " +
+			"as none of the --restrict-outputs flags are set")
+	}
+	secretsProvider, err := secrets.NewProvider(ctx, getSecretsParams(OS))
+	if err != nil {
+		Fatal(cmd, fmt.Sprintf("Invalid --secrets-backend: %s", err), 1)
+		return nil
+	}
+	if _, err = secrets.ParsePolicy(OS.AllowedSecrets, getCredentialSecrets(OS)); err != nil {
+		Fatal(cmd, fmt.Sprintf("Invalid --allow-job-secrets: %s", err), 1)
+		return nil
+	}
+	var estuaryAPIKey, ipfsClusterBasicAuth, azureSASToken, huggingFaceToken *secrets.Credential
+	for _, credential := range []struct {
+		flag, name string
+		credential **secrets.Credential
+	}{
+		{"estuary-api-key-secret", OS.EstuaryAPIKeySecret, &estuaryAPIKey},
+		{"ipfs-cluster-basic-auth-secret", OS.IPFSClusterBasicAuthSecret, &ipfsClusterBasicAuth},
+		{"azure-sas-token-secret", OS.AzureSASTokenSecret, &azureSASToken},
+		{"huggingface-token-secret", OS.HuggingFaceTokenSecret, &huggingFaceToken},
+	} {
+		if *credential.credential, err = getCredential(OS, secretsProvider, credential.flag, credential.name); err != nil {
+			Fatal(cmd, err.Error(), 1)
+			return nil
+		}
+	}
 
 	// Establishing p2p connection
 	peers := getPeers(OS)
+
+	if OS.SelfTest {
+		ipfs, err := getIPFSClient(ctx, cm, OS)
+		if err != nil {
+			Fatal(cmd, fmt.Sprintf("Error creating IPFS client: %s", err), 1)
+			return nil
+		}
+		selfTest(cmd, OS, getSelfTestChecks(OS, ipfs, peers))
+		return nil
+	}
+
 	log.Debug().Msgf("libp2p connecting to: %s", peers)
 
-	transport, err := libp2p.NewTransport(ctx, cm, OS.SwarmPort, peers)
+	transportConfig, err := getTransportConfig(OS)
+	if err != nil {
+		Fatal(cmd, err.Error(), 1)
+	}
+
+	transport, err := libp2p.NewTransportWithConfig(ctx, cm, OS.SwarmPort, peers, transportConfig)
 	if err != nil {
 		Fatal(cmd, fmt.Sprintf("Error creating libp2p transport: %s", err), 1)
 	}
+	subscribeEngines, err := getSubscribeEngines(OS)
+	if err != nil {
+		Fatal(cmd, err.Error(), 1)
+	}
+	if !OS.LegacyJobAnnouncements {
+		transport.DisableLegacyAnnouncements()
+	}
+	transport.EnableBatching(libp2p.BatchingConfig{
+		MaxBatchSize:  OS.GossipBatchSize,
+		MaxBatchBytes: libp2p.DefaultBatchingConfig.MaxBatchBytes,
+		FlushInterval: OS.GossipBatchInterval,
+	})
 
 	// add nodeID to logging context
 	ctx = logger.ContextWithNodeIDLogger(ctx, transport.HostID())
 
 	// Establishing IPFS connection
-	ipfs, err := ipfs.NewClient(OS.IPFSConnect)
+	ipfs.SetBandwidthLimits(ipfs.BandwidthLimits{
+		Ingress: int(capacity.ConvertBytesString(OS.LimitIPFSIngress)),
+		Egress:  int(capacity.ConvertBytesString(OS.LimitIPFSEgress)),
+	})
+	ipfs, err := getIPFSClient(ctx, cm, OS)
 	if err != nil {
 		Fatal(cmd, fmt.Sprintf("Error creating IPFS client: %s", err), 1)
 	}
@@ -303,20 +1445,154 @@ func serve(cmd *cobra.Command, OS *ServeOptions) error {
 	if err != nil {
 		Fatal(cmd, fmt.Sprintf("Error creating in memory datastore: %s", err), 1)
 	}
+	transport.SubscribeToEngines(subscribeEngines, datastore)
+
+	requesterNodeConfig := requesternode.NewDefaultRequesterNodeConfig()
+	requesterNodeConfig.BidWindow = OS.RequesterBidWindow
+	requesterNodeConfig.ApprovalRequired = OS.RequireApproval
+	requesterNodeConfig.Approvers = OS.Approvers
+	requesterNodeConfig.Moderators = OS.Moderators
+	if OS.EventLogPath != "" {
+		// keep the denylist along with the job state, for it to survive restarts too
+		requesterNodeConfig.DenylistPath = filepath.Join(OS.EventLogPath, "denylist.json")
+	}
 
 	// Create node config from cmd arguments
 	nodeConfig := node.NodeConfig{
-		IPFSClient:           ipfs,
-		CleanupManager:       cm,
-		LocalDB:              datastore,
-		Transport:            transport,
-		FilecoinUnsealedPath: OS.FilecoinUnsealedPath,
-		EstuaryAPIKey:        OS.EstuaryAPIKey,
-		HostAddress:          OS.HostAddress,
-		APIPort:              apiPort,
-		MetricsPort:          OS.MetricsPort,
-		ComputeConfig:        getComputeConfig(OS),
-		RequesterNodeConfig:  requesternode.NewDefaultRequesterNodeConfig(),
+		IPFSClient:               ipfs,
+		CleanupManager:           cm,
+		LocalDB:                  datastore,
+		Transport:                transport,
+		FilecoinUnsealedPath:     OS.FilecoinUnsealedPath,
+		EstuaryAPIKey:            OS.EstuaryAPIKey,
+		EstuaryAPIKeySecret:      estuaryAPIKey,
+		HostAddress:              OS.HostAddress,
+		APIPort:                  apiPort,
+		APIGRPCPort:              OS.APIGRPCPort,
+		MetricsPort:              OS.MetricsPort,
+		ComputeConfig:            getComputeConfig(OS),
+		RequesterNodeConfig:      requesterNodeConfig,
+		EventLogPath:             OS.EventLogPath,
+		EventLogSnapshotInterval: OS.EventLogSnapshotInterval,
+		AzureBlobConfig: azureblob.Config{
+			SASToken:                OS.AzureSASToken,
+			SASTokenSecret:          azureSASToken,
+			UseManagedIdentity:      OS.AzureManagedIdentity,
+			ManagedIdentityClientID: OS.AzureManagedIdentityClientID,
+		},
+		GCSConfig: gcs.Config{
+			CredentialsFile: OS.GCSCredentialsFile,
+			Anonymous:       OS.GCSAnonymous,
+		},
+		HuggingFaceConfig: huggingface.StorageConfig{
+			Token:       OS.HuggingFaceToken,
+			TokenSecret: huggingFaceToken,
+			CacheDir:    OS.HuggingFaceCacheDir,
+			CacheSize:   capacity.ConvertBytesString(OS.HuggingFaceCacheSize),
+		},
+		SelfTestChecks:         getSelfTestChecks(OS, ipfs, peers),
+		LocalDirectoriesConfig: localDirectories,
+	}
+
+	dockerBackends, err := getDockerBackends(OS)
+	if err != nil {
+		Fatal(cmd, err.Error(), 1)
+		return nil
+	}
+	usesBackend := func(backend executor_util.DockerBackend) bool {
+		for _, b := range dockerBackends {
+			if b == backend {
+				return true
+			}
+		}
+		return false
+	}
+	nodeConfig.DockerBackends = dockerBackends
+	nodeConfig.PodmanHost = OS.PodmanHost
+	nodeConfig.EstuaryUploadedBlocksPath = OS.EstuaryUploadedBlocks
+	nodeConfig.EstuaryCollectionUUID = OS.EstuaryCollection
+	nodeConfig.EstuaryReplication = OS.EstuaryReplication
+	nodeConfig.EstuaryRegion = OS.EstuaryRegion
+	if OS.DockerNonRootUser != "" {
+		if err = executor.VerifyNonRootUser(OS.DockerNonRootUser); err != nil {
+			Fatal(cmd, fmt.Sprintf("Invalid --docker-non-root-user: %s", err), 1)
+			return nil
+		}
+		nodeConfig.DockerNonRootUser = OS.DockerNonRootUser
+	}
+	nodeConfig.DockerHardening = getDockerHardening(OS)
+	if OS.DockerDebugSessionTimeout < 0 {
+		Fatal(cmd, "Invalid --debug-session-timeout: must not be negative", 1)
+		return nil
+	}
+	nodeConfig.DockerDebugSessionTimeout = OS.DockerDebugSessionTimeout
+	for _, cidr := range OS.CallbackAllowedNetworks {
+		_, network, parseErr := net.ParseCIDR(cidr)
+		if parseErr != nil {
+			Fatal(cmd, fmt.Sprintf("Invalid --callback-allowed-networks: %s", parseErr), 1)
+			return nil
+		}
+		nodeConfig.CallbackAllowedNetworks = append(nodeConfig.CallbackAllowedNetworks, network)
+	}
+	if !usesBackend(executor_util.DockerBackendKubernetes) && !usesBackend(executor_util.DockerBackendNomad) &&
+		!usesBackend(executor_util.DockerBackendSlurm) && !usesBackend(executor_util.DockerBackendFirecracker) {
+		nodeConfig.ComputeConfig.Hardening = nodeHardening
+	}
+
+	if usesBackend(executor_util.DockerBackendNomad) {
+		nodeName := OS.NomadNodeName
+		if nodeName == "" {
+			// Nomad clients are named after their host unless told otherwise
+			nodeName, err = os.Hostname()
+			if err != nil {
+				Fatal(cmd, fmt.Sprintf("Error getting the hostname for --nomad-node-name: %s", err), 1)
+				return nil
+			}
+		}
+		nodeConfig.NomadExecutorConfig = &nomad.Config{
+			Address:     OS.NomadAddress,
+			Token:       os.Getenv("NOMAD_TOKEN"),
+			Namespace:   OS.NomadNamespace,
+			Region:      OS.NomadRegion,
+			Datacenters: OS.NomadDatacenters,
+			NodeName:    nodeName,
+			MHzPerCPU:   OS.NomadMHzPerCPU,
+		}
+	}
+	if usesBackend(executor_util.DockerBackendSlurm) {
+		var scriptTemplate []byte
+		if OS.SlurmScriptTemplate != "" {
+			scriptTemplate, err = os.ReadFile(OS.SlurmScriptTemplate)
+			if err != nil {
+				Fatal(cmd, fmt.Sprintf("Error reading --slurm-script-template: %s", err), 1)
+				return nil
+			}
+		}
+		nodeConfig.SlurmExecutorConfig = &slurm.Config{
+			Partition:      OS.SlurmPartition,
+			Account:        OS.SlurmAccount,
+			ExtraArgs:      OS.SlurmSbatchArgs,
+			ScriptTemplate: string(scriptTemplate),
+		}
+	}
+	if usesBackend(executor_util.DockerBackendFirecracker) {
+		outputVolumeSize := capacity.ConvertBytesString(OS.FirecrackerOutputVolumeSize)
+		if outputVolumeSize == 0 {
+			Fatal(cmd, fmt.Sprintf("Invalid --firecracker-output-volume-size: %s", OS.FirecrackerOutputVolumeSize), 1)
+			return nil
+		}
+		nodeConfig.FirecrackerExecutorConfig = &firecracker.Config{
+			Binary:           OS.FirecrackerBinary,
+			KernelImage:      OS.FirecrackerKernel,
+			OutputVolumeSize: outputVolumeSize,
+		}
+	}
+	if usesBackend(executor_util.DockerBackendKubernetes) {
+		nodeConfig.KubernetesExecutorConfig = &kubernetes.Config{
+			Namespace:  OS.KubernetesNamespace,
+			NodeName:   OS.KubernetesNodeName,
+			Kubeconfig: OS.Kubeconfig,
+		}
 	}
 
 	if OS.LotusFilecoinStorageDuration != time.Duration(0) &&
@@ -330,6 +1606,29 @@ func serve(cmd *cobra.Command, OS *ServeOptions) error {
 		}
 	}
 
+	if OS.LotusRetrieval {
+		if OS.LotusFilecoinPathDirectory == "" {
+			Fatal(cmd, "--lotus-retrieval requires --lotus-path-directory or $LOTUS_PATH.", 1)
+		}
+		nodeConfig.FilecoinRetrievalConfig = &filecoinretrieval.StorageConfig{
+			PathDir:     OS.LotusFilecoinPathDirectory,
+			DownloadDir: OS.LotusRetrievalDirectory,
+			MaxPrice:    OS.LotusRetrievalMaxPrice,
+		}
+	}
+
+	if OS.IPFSClusterAPI != "" {
+		username, password, _ := strings.Cut(OS.IPFSClusterBasicAuth, ":")
+		nodeConfig.IPFSClusterConfig = &ipfscluster.PublisherConfig{
+			APIAddress:      OS.IPFSClusterAPI,
+			Username:        username,
+			Password:        password,
+			BasicAuthSecret: ipfsClusterBasicAuth,
+			ReplicationMin:  OS.IPFSClusterReplicationMin,
+			ReplicationMax:  OS.IPFSClusterReplicationMax,
+		}
+	}
+
 	// Create node
 	node, err := node.NewStandardNode(ctx, nodeConfig)
 	if err != nil {
@@ -348,6 +1647,167 @@ func serve(cmd *cobra.Command, OS *ServeOptions) error {
 		Fatal(cmd, fmt.Sprintf("Error starting node: %s", err), 1)
 	}
 
-	<-ctx.Done() // block until killed
+	// block until killed
+	select {
+	case <-ctx.Done():
+	case <-terminate:
+		drain(ctx, node, OS.DrainTimeout)
+	}
 	return nil
 }
+
+// drain waits for the jobs running on the node to finish, up to the timeout,
+// before the node shuts down.
+func drain(ctx context.Context, n *node.Node, timeout time.Duration) {
+	if timeout <= 0 {
+		return
+	}
+	log.Ctx(ctx).Info().Msgf("Received SIGTERM, draining for up to %s before shutting down", timeout)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	if err := n.ComputeNode.Drain(ctx); err != nil {
+		log.Ctx(ctx).Warn().Err(err).Msg("Shutting down before all jobs finished")
+		return
+	}
+	log.Ctx(ctx).Info().Msg("Drained all jobs, shutting down")
+}
+
+// getLocalDirectories returns the directories jobs can read, by name and under
+// the allowed paths.
+func getLocalDirectories(OS *ServeOptions) (localdirectory.StorageConfig, error) {
+	config := localdirectory.StorageConfig{Directories: map[string]string{}, AllowedPaths: OS.AllowedLocalPaths}
+	for _, value := range OS.LocalDirectories {
+		name, path, ok := strings.Cut(value, "=")
+		if !ok || name == "" || path == "" || strings.Contains(name, "/") {
+			return config, fmt.Errorf("%q must be NAME=PATH", value)
+		}
+		if _, exists := config.Directories[name]; exists {
+			return config, fmt.Errorf("there is more than one directory called %s", name)
+		}
+		config.Directories[name] = path
+	}
+	return config, nil
+}
+
+// getOutputPolicy returns what the results of jobs that read local
+// directories are restricted to.
+func getOutputPolicy(OS *ServeOptions) executor.OutputPolicy {
+	policy := executor.OutputPolicy{
+		MaxRows: OS.RestrictOutputsMaxRows,
+		Filter:  OS.RestrictOutputsFilter,
+	}
+	if OS.RestrictOutputsMaxSize != "" {
+		policy.MaxSize = capacity.ConvertBytesString(OS.RestrictOutputsMaxSize)
+	}
+	return policy
+}
+
+// getDockerBackends returns the runtimes to run docker jobs on, in order of
+// preference.
+func getDockerBackends(OS *ServeOptions) ([]executor_util.DockerBackend, error) {
+	var backends []executor_util.DockerBackend
+	if len(OS.DockerExecutors) > 0 {
+		for _, name := range OS.DockerExecutors {
+			backend, err := executor_util.ParseDockerBackend(name)
+			if err != nil {
+				return nil, err
+			}
+			backends = append(backends, backend)
+		}
+		return backends, nil
+	}
+
+	for _, option := range []struct {
+		set     bool
+		backend executor_util.DockerBackend
+	}{
+		{OS.KubernetesExecutor, executor_util.DockerBackendKubernetes},
+		{OS.NomadExecutor, executor_util.DockerBackendNomad},
+		{OS.SlurmExecutor, executor_util.DockerBackendSlurm},
+		{OS.FirecrackerExecutor, executor_util.DockerBackendFirecracker},
+	} {
+		if option.set {
+			backends = append(backends, option.backend)
+		}
+	}
+	if len(backends) > 1 {
+		return nil, fmt.Errorf("you can only specify one of --kubernetes-executor, --nomad-executor, --slurm-executor " +
+			"and --firecracker-executor, or the order to fall back through them with --docker-executors")
+	}
+	if len(backends) == 0 {
+		backends = append(backends, executor_util.DockerBackendDocker)
+	}
+	return backends, nil
+}
+
+// getDockerHardening returns the hardening of the containers of docker jobs.
+func getDockerHardening(OS *ServeOptions) dockerexecutor.HardeningConfig {
+	return dockerexecutor.HardeningConfig{
+		SeccompProfile:  OS.DockerSeccompProfile,
+		AppArmorProfile: OS.DockerAppArmorProfile,
+		PidsLimit:       OS.DockerPidsLimit,
+		Ulimits:         OS.DockerUlimits,
+	}
+}
+
+// getCarbonIntensity returns the static carbon intensity of the node, or nil
+// if it isn't known.
+func getCarbonIntensity(OS *ServeOptions) *float64 {
+	if OS.CarbonIntensity < 0 {
+		return nil
+	}
+	intensity := OS.CarbonIntensity
+	return &intensity
+}
+
+func getSecretsParams(OS *ServeOptions) secrets.Params {
+	return secrets.Params{
+		Backend:   OS.SecretsBackend,
+		EnvPrefix: OS.SecretsEnvPrefix,
+		Dir:       OS.SecretsDir,
+		Vault: secrets.VaultParams{
+			Address: OS.VaultAddress,
+			Token:   os.Getenv("VAULT_TOKEN"),
+			Mount:   OS.VaultMount,
+			Path:    OS.VaultPath,
+		},
+		GCP: secrets.GCPParams{
+			Project:         OS.SecretsGCPProject,
+			CredentialsFile: OS.SecretsGCPCredentialsFile,
+		},
+	}
+}
+
+// getCredentialSecrets returns the names of the secrets the credentials of the
+// node are fetched from, which jobs may never request as they share the
+// secrets backend.
+func getCredentialSecrets(OS *ServeOptions) []string {
+	var names []string
+	for _, name := range []string{
+		OS.EstuaryAPIKeySecret,
+		OS.IPFSClusterBasicAuthSecret,
+		OS.AzureSASTokenSecret,
+		OS.HuggingFaceTokenSecret,
+	} {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// getCredential returns the credential of the node fetched from the secrets
+// backend with the name given to the flag, if one was given.
+func getCredential(OS *ServeOptions, provider secrets.Provider, flag, name string) (*secrets.Credential, error) {
+	if name == "" {
+		return nil, nil
+	}
+	if provider == nil {
+		return nil, fmt.Errorf("--%s needs a --secrets-backend", flag)
+	}
+	return secrets.NewCredential(secrets.CredentialParams{
+		Provider:        provider,
+		Name:            name,
+		RefreshInterval: OS.SecretsRefreshInterval,
+	}), nil
+}
@@ -36,6 +36,7 @@ import (
 const (
 	JSONFormat                         string = "json"
 	YAMLFormat                         string = "yaml"
+	TextFormat                         string = "text"
 	DefaultDockerRunWaitSeconds               = 600
 	PrintoutCanceledButRunningNormally string = "printout canceled but running normally"
 	// what permissions do we give to a folder we create when downloading results
@@ -240,6 +241,7 @@ type RunTimeSettings struct {
 	WaitForJobTimeoutSecs int  // Timeout for waiting for the job to finish
 	PrintJobIDOnly        bool // Only print the Job ID as output
 	PrintNodeDetails      bool // Print the node details as output
+	PrintStdout           bool // Print the stdout of the job, rather than a summary, as output
 }
 
 func NewRunTimeSettings() *RunTimeSettings {
@@ -251,6 +253,7 @@ func NewRunTimeSettings() *RunTimeSettings {
 		IsLocal:               false,
 		PrintJobIDOnly:        false,
 		PrintNodeDetails:      false,
+		PrintStdout:           false,
 	}
 }
 
@@ -359,6 +362,25 @@ func ExecuteJob(ctx context.Context,
 	}
 	sort.Strings(nodeIndexes)
 
+	if runtimeSettings.PrintStdout && !quiet {
+		// print the output of the job as if it had been run locally
+		for _, nodeID := range nodeIndexes {
+			shards := js.Nodes[nodeID].Shards
+			shardIndexes := make([]int, 0, len(shards))
+			for shardIndex := range shards {
+				shardIndexes = append(shardIndexes, shardIndex)
+			}
+			sort.Ints(shardIndexes)
+			for _, shardIndex := range shardIndexes {
+				if runOutput := shards[shardIndex].RunOutput; runOutput != nil {
+					cmd.Print(runOutput.STDERR)
+					fmt.Fprint(cmd.OutOrStdout(), runOutput.STDOUT)
+				}
+			}
+		}
+		quiet = true
+	}
+
 	printOut := "%s" // We only know this at the end, we'll fill it in there.
 	printOut += "Job Results By Node:\n"
 	indentOne := "  "
@@ -393,6 +415,14 @@ func ExecuteJob(ctx context.Context,
 					}
 					printResults("Stdout", s.RunOutput.STDOUT, s.RunOutput.StdoutTruncated)
 					printResults("Stderr", s.RunOutput.STDERR, s.RunOutput.StderrTruncated)
+					outputPaths := make([]string, 0, len(s.RunOutput.Outputs))
+					for outputPath := range s.RunOutput.Outputs {
+						outputPaths = append(outputPaths, outputPath)
+					}
+					sort.Strings(outputPaths)
+					for _, outputPath := range outputPaths {
+						printResults(outputPath, s.RunOutput.Outputs[outputPath], false)
+					}
 				}
 			}
 		}
@@ -421,6 +451,7 @@ To get more details about the run, execute:
 			cmd,
 			j.ID,
 			downloadSettings,
+			nil,
 		)
 		if err != nil {
 			return err
@@ -435,30 +466,59 @@ func downloadResultsHandler(
 	cmd *cobra.Command,
 	jobID string,
 	downloadSettings ipfs.IPFSDownloadSettings,
+	shardIndexes []int,
 ) error {
+	downloaded, err := downloadResults(ctx, cm, cmd, jobID, downloadSettings, shardIndexes)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(cmd.ErrOrStderr(), "Results for job '%s' have been written to...\n", jobID)
+	fmt.Fprintf(cmd.OutOrStdout(), "%s\n", downloaded.OutputDir)
+
+	return nil
+}
+
+// downloadedResults describes the results of a job that have been downloaded.
+type downloadedResults struct {
+	JobID     string                  `json:"JobID"`
+	OutputDir string                  `json:"OutputDir"`
+	Results   []model.PublishedResult `json:"Results"`
+}
+
+// downloadResults downloads the results of the given shards of a job, or of
+// all of its shards if none are given.
+func downloadResults(
+	ctx context.Context,
+	cm *system.CleanupManager,
+	cmd *cobra.Command,
+	jobID string,
+	downloadSettings ipfs.IPFSDownloadSettings,
+	shardIndexes []int,
+) (*downloadedResults, error) {
 	fmt.Fprintf(cmd.ErrOrStderr(), "Fetching results of job '%s'...\n", jobID)
 	j, _, err := GetAPIClient().Get(ctx, jobID)
 
 	if err != nil {
 		if _, ok := err.(*bacerrors.JobNotFound); ok {
-			return err
+			return nil, err
 		} else {
 			Fatal(cmd, fmt.Sprintf("Unknown error trying to get job (ID: %s): %+v", jobID, err), 1)
 		}
 	}
 
-	results, err := GetAPIClient().GetResults(ctx, j.ID)
+	results, err := getResults(ctx, j.ID, shardIndexes)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if len(results) == 0 {
-		return fmt.Errorf("no results found")
+		return nil, fmt.Errorf("no results found")
 	}
 
 	processedDownloadSettings, err := processDownloadSettings(downloadSettings, j.ID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	err = ipfs.DownloadJob(
@@ -470,13 +530,31 @@ func downloadResultsHandler(
 	)
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	fmt.Fprintf(cmd.ErrOrStderr(), "Results for job '%s' have been written to...\n", jobID)
-	fmt.Fprintf(cmd.OutOrStdout(), "%s\n", processedDownloadSettings.OutputDir)
+	return &downloadedResults{
+		JobID:     j.ID,
+		OutputDir: processedDownloadSettings.OutputDir,
+		Results:   results,
+	}, nil
+}
 
-	return nil
+// getResults returns the published results of the given shards of a job, or
+// of all of its shards if none are given.
+func getResults(ctx context.Context, jobID string, shardIndexes []int) ([]model.PublishedResult, error) {
+	if len(shardIndexes) == 0 {
+		return GetAPIClient().GetResults(ctx, jobID)
+	}
+	shards, err := GetAPIClient().GetShards(ctx, jobID, shardIndexes, true)
+	if err != nil {
+		return nil, err
+	}
+	results := []model.PublishedResult{}
+	for _, shard := range shards {
+		results = append(results, shard.Results...)
+	}
+	return results, nil
 }
 
 func submitJob(ctx context.Context,
@@ -697,6 +775,39 @@ func printingUpdateForEvent(cmd *cobra.Command, pe map[model.JobEventType]*print
 		pe[jet].printed = true
 	}
 }
+
+// validateOutputFormat checks that an --output flag is one of the machine
+// readable formats, or one of the other formats the command supports.
+func validateOutputFormat(format string, otherFormats ...string) error {
+	formats := append([]string{YAMLFormat, JSONFormat}, otherFormats...)
+	for _, f := range formats {
+		if format == f {
+			return nil
+		}
+	}
+	return fmt.Errorf("--output must be one of %s", strings.Join(formats, ", "))
+}
+
+// printFormatted prints a value in a machine readable format, either
+// YAMLFormat or JSONFormat.
+func printFormatted(cmd *cobra.Command, format string, v interface{}) error {
+	var marshaled []byte
+	var err error
+	switch format {
+	case YAMLFormat:
+		marshaled, err = model.YAMLMarshalWithMax(v)
+	case JSONFormat:
+		marshaled, err = model.JSONMarshalWithMax(v)
+	default:
+		return fmt.Errorf("unknown output format %q", format)
+	}
+	if err != nil {
+		return err
+	}
+	cmd.Println(strings.TrimSuffix(string(marshaled), "\n"))
+	return nil
+}
+
 func FatalErrorHandler(cmd *cobra.Command, msg string, code int) {
 	if len(msg) > 0 {
 		// add newline if needed
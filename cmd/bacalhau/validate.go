@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/filecoin-project/bacalhau/pkg/job"
 	"github.com/filecoin-project/bacalhau/pkg/model"
 	"github.com/filecoin-project/bacalhau/pkg/util/templates"
 	"github.com/invopop/jsonschema"
@@ -23,7 +24,9 @@ var (
 	validateLong = templates.LongDesc(i18n.T(`
 		Validate a job from a file
 
-		JSON and YAML formats are accepted.
+		JSON and YAML formats are accepted. The job is checked against the
+		schema of a job, and then against the same rules the requester node
+		checks submitted jobs with.
 `))
 
 	//nolint:lll // Documentation
@@ -34,6 +37,9 @@ var (
 		# Validate a job using stdin
 		cat job.yaml | bacalhau validate
 
+		# Validate a job in a pipeline, printing the result as json
+		bacalhau validate --output json ./job.yaml
+
 		# Output the jsonschema for a bacalhau job
 		bacalhau validate --output-schema
 `))
@@ -49,7 +55,7 @@ type ValidateOptions struct {
 func NewValidateOptions() *ValidateOptions {
 	return &ValidateOptions{
 		Filename:        "",
-		OutputFormat:    "yaml",
+		OutputFormat:    "",
 		OutputSchema:    false,
 		OutputDirectory: "",
 	}
@@ -73,6 +79,10 @@ func newValidateCmd() *cobra.Command {
 		&OV.OutputSchema, "output-schema", OV.OutputSchema,
 		`Output the JSON schema for a Job to stdout then exit`,
 	)
+	validateCmd.PersistentFlags().StringVar(
+		&OV.OutputFormat, "output", OV.OutputFormat,
+		`Print the result of the validation in a machine readable format (yaml or json)`,
+	)
 
 	return validateCmd
 }
@@ -90,6 +100,13 @@ func validate(cmd *cobra.Command, cmdArgs []string, OV *ValidateOptions) error {
 		return nil
 	}
 
+	if OV.OutputFormat != "" {
+		if err = validateOutputFormat(OV.OutputFormat); err != nil {
+			Fatal(cmd, err.Error(), 1)
+			return nil
+		}
+	}
+
 	if len(cmdArgs) == 0 {
 		_ = cmd.Usage()
 		Fatal(cmd, "You must specify a filename or provide the content to be validated via stdin.", 1)
@@ -108,6 +125,10 @@ func validate(cmd *cobra.Command, cmdArgs []string, OV *ValidateOptions) error {
 			// Can you ever get here?
 			Fatal(cmd, "No filename provided.", 1)
 		}
+		err = model.YAMLUnmarshalWithMax(byteResult, &j)
+		if err != nil {
+			Fatal(cmd, fmt.Sprintf("Error unmarshaling yaml from stdin: %s", err), 1)
+		}
 	} else {
 		var file *os.File
 		fileextension := filepath.Ext(OV.Filename)
@@ -150,11 +171,33 @@ func validate(cmd *cobra.Command, cmdArgs []string, OV *ValidateOptions) error {
 		Fatal(cmd, fmt.Sprintf("Error validating json: %s", err), 1)
 	}
 
-	if result.Valid() {
+	validation := &validationResult{Valid: result.Valid()}
+	for _, desc := range result.Errors() {
+		validation.Errors = append(validation.Errors, desc.String())
+	}
+	if validation.Valid {
+		// only check the rules of a job once it has the right shape
+		if err = job.VerifyJob(cmd.Context(), j); err != nil {
+			validation.Valid = false
+			validation.Errors = append(validation.Errors, err.Error())
+		}
+	}
+
+	if OV.OutputFormat != "" {
+		if err = printFormatted(cmd, OV.OutputFormat, validation); err != nil {
+			return err
+		}
+		if !validation.Valid {
+			Fatal(cmd, "", 1)
+		}
+		return nil
+	}
+
+	if validation.Valid {
 		cmd.Println("The Job is valid")
 	} else {
 		msg := "The Job is not valid. See errors:\n"
-		for _, desc := range result.Errors() {
+		for _, desc := range validation.Errors {
 			msg += fmt.Sprintf("- %s\n", desc)
 		}
 		Fatal(cmd, msg, 1)
@@ -162,6 +205,12 @@ func validate(cmd *cobra.Command, cmdArgs []string, OV *ValidateOptions) error {
 	return nil
 }
 
+// validationResult is the machine readable result of validating a job.
+type validationResult struct {
+	Valid  bool     `json:"Valid"`
+	Errors []string `json:"Errors,omitempty"`
+}
+
 func GenerateJobJSONSchema() ([]byte, error) {
 	s := jsonschema.Reflect(&model.Job{})
 	// Find key in a json document in Golang
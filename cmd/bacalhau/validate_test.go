@@ -6,9 +6,13 @@ import (
 	"fmt"
 	"net"
 	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/filecoin-project/bacalhau/pkg/logger"
+	"github.com/filecoin-project/bacalhau/pkg/model"
 	"github.com/filecoin-project/bacalhau/pkg/publicapi"
 	"github.com/filecoin-project/bacalhau/pkg/system"
 	testutils "github.com/filecoin-project/bacalhau/pkg/test/utils"
@@ -71,3 +75,41 @@ func (s *ValidateSuite) TestValidate() {
 
 	}
 }
+
+func (s *ValidateSuite) TestValidateOutput() {
+	Fatal = FakeFatalErrorHandler
+
+	jobSpec, err := os.ReadFile("../../testdata/job.yaml")
+	require.NoError(s.T(), err)
+	// valid against the schema, but not a deal a requester node would accept
+	badDeal := strings.Replace(string(jobSpec), "Confidence: 0", "Confidence: 2", 1)
+	badDealFile := filepath.Join(s.T().TempDir(), "job.yaml")
+	require.NoError(s.T(), os.WriteFile(badDealFile, []byte(badDeal), 0644))
+
+	tests := map[string]struct {
+		testFile string
+		valid    bool
+		errors   []string
+	}{
+		"validJobFile":   {testFile: "../../testdata/job.yaml", valid: true},
+		"InvalidJobFile": {testFile: "../../testdata/job-invalid.yml", errors: []string{"APIVersion is required"}},
+		"InvalidDeal":    {testFile: badDealFile, errors: []string{"the deal confidence cannot be higher than the concurrency"}},
+	}
+	for name, test := range tests {
+		_, out, err := ExecuteTestCobraCommand(s.T(), "validate",
+			"--output", "json",
+			test.testFile,
+		)
+		require.NoError(s.T(), err)
+
+		// a failed validation also exits with an error
+		result := &validationResult{}
+		firstLine := strings.SplitN(out, "\n", 2)[0]
+		require.NoError(s.T(), model.JSONUnmarshalWithMax([]byte(firstLine), result), name)
+		require.Equal(s.T(), test.valid, result.Valid, name)
+		require.Equal(s.T(), len(test.errors), len(result.Errors), name)
+		for i, expected := range test.errors {
+			require.Contains(s.T(), result.Errors[i], expected, name)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package bacalhau
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/filecoin-project/bacalhau/pkg/publicapi"
+	"github.com/filecoin-project/bacalhau/pkg/system"
+	"github.com/filecoin-project/bacalhau/pkg/util/templates"
+	"github.com/spf13/cobra"
+	"k8s.io/kubectl/pkg/util/i18n"
+)
+
+var (
+	waitLong = templates.LongDesc(i18n.T(`
+		Wait for a job, or all the jobs submitted in a batch with a job group,
+		to finish, and print where they got to and their results.
+`))
+
+	waitExample = templates.Examples(i18n.T(`
+		# Wait for a job to finish
+		bacalhau wait 51225160
+
+		# Wait for all the jobs of a job group to finish, for up to an hour
+		bacalhau wait --job-group 1b7f5e2a-4f0e-4a1c-9d3b-9e8f0c6d2a71 --timeout 1h
+`))
+)
+
+type WaitOptions struct {
+	JobGroup     string        // The job group to wait for the jobs of
+	Timeout      time.Duration // How long to wait for, 0 for no limit
+	OutputFormat string        // The output format for the outcomes of the jobs (yaml or json)
+}
+
+func NewWaitOptions() *WaitOptions {
+	return &WaitOptions{
+		OutputFormat: YAMLFormat,
+	}
+}
+
+func newWaitCmd() *cobra.Command {
+	OW := NewWaitOptions()
+
+	waitCmd := &cobra.Command{
+		Use:     "wait [id]",
+		Short:   "Wait for a job, or the jobs of a job group, to finish",
+		Long:    waitLong,
+		Example: waitExample,
+		Args:    cobra.MaximumNArgs(1),
+		PreRun:  applyPorcelainLogLevel,
+		RunE: func(cmd *cobra.Command, cmdArgs []string) error {
+			return wait(cmd, cmdArgs, OW)
+		},
+	}
+
+	waitCmd.PersistentFlags().StringVar(
+		&OW.JobGroup, "job-group", OW.JobGroup,
+		`Wait for all the jobs of this job group, rather than a single job`,
+	)
+	waitCmd.PersistentFlags().DurationVar(
+		&OW.Timeout, "timeout", OW.Timeout,
+		`How long to wait for the jobs to finish, no limit if 0`,
+	)
+	waitCmd.PersistentFlags().StringVar(
+		&OW.OutputFormat, "output", OW.OutputFormat,
+		`The output format for the outcomes of the jobs (yaml or json)`,
+	)
+
+	return waitCmd
+}
+
+func wait(cmd *cobra.Command, cmdArgs []string, OW *WaitOptions) error {
+	cm := system.NewCleanupManager()
+	defer cm.Cleanup()
+	ctx := cmd.Context()
+
+	ctx, rootSpan := system.NewRootSpan(ctx, system.GetTracer(), "cmd/bacalhau/wait")
+	defer rootSpan.End()
+	cm.RegisterCallback(system.CleanupTraceProvider)
+
+	if err := validateOutputFormat(OW.OutputFormat); err != nil {
+		Fatal(cmd, err.Error(), 1)
+		return nil
+	}
+	if (len(cmdArgs) == 0) == (OW.JobGroup == "") {
+		Fatal(cmd, "Please give either a job ID or --job-group.", 1)
+		return nil
+	}
+
+	if OW.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, OW.Timeout)
+		defer cancel()
+	}
+
+	var responses []*publicapi.WaitResponse
+	var err error
+	if OW.JobGroup != "" {
+		responses, err = GetAPIClient().WaitJobGroup(ctx, OW.JobGroup)
+	} else {
+		var response *publicapi.WaitResponse
+		response, err = waitForJob(ctx, cmdArgs[0])
+		responses = append(responses, response)
+	}
+	if err != nil {
+		Fatal(cmd, fmt.Sprintf("Error waiting: %s", err), 1)
+		return nil
+	}
+
+	return printFormatted(cmd, OW.OutputFormat, responses)
+}
+
+// waitForJob waits for a job, by its full or short ID, to finish.
+func waitForJob(ctx context.Context, jobID string) (*publicapi.WaitResponse, error) {
+	j, _, err := GetAPIClient().Get(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+	return GetAPIClient().WaitUntilFinished(ctx, j.ID)
+}
@@ -106,6 +106,10 @@ func newRunWasmCmd() *cobra.Command {
 		&wasmJob.Deal.MinBids, "min-bids", wasmJob.Deal.MinBids,
 		`Minimum number of bids that must be received before concurrency-many bids will be accepted (at random)`,
 	)
+	runWasmCommand.PersistentFlags().StringSliceVar(
+		&wasmJob.Deal.TargetNodes, "target-nodes", wasmJob.Deal.TargetNodes,
+		`IDs of the nodes to send the job to directly, instead of offering it to the whole network`,
+	)
 	runWasmCommand.PersistentFlags().Float64Var(
 		&wasmJob.Spec.Timeout, "timeout", wasmJob.Spec.Timeout,
 		`Job execution timeout in seconds (e.g. 300 for 5 minutes and 0.1 for 100ms)`,
@@ -119,7 +123,10 @@ func newRunWasmCmd() *cobra.Command {
 		NewURLStorageSpecArrayFlag(&wasmJob.Spec.Inputs), "input-urls", "u",
 		`URL of the input data volumes downloaded from a URL source. Mounts data at '/inputs' (e.g. '-u http://foo.com/bar.tar.gz'
 		mounts 'bar.tar.gz' at '/inputs/bar.tar.gz'). URL accept any valid URL supported by the 'wget' command,
-		and supports both HTTP and HTTPS.`,
+		and supports both HTTP and HTTPS. Objects in Google Cloud Storage and Azure Blob Storage can be mounted with
+		gs://bucket/path and az://account/container/path URLs, which mount a single object or every object under the path,
+		snapshots of Hugging Face models with hf://org/model@revision URLs, and the directories compute nodes share
+		as local://name URLs, so that the job only runs on those nodes.`,
 	)
 	runWasmCommand.PersistentFlags().VarP(
 		NewIPFSStorageSpecArrayFlag(&wasmJob.Spec.Inputs), "input-volumes", "v",
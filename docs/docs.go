@@ -106,12 +106,26 @@ const docTemplate = `{
                     "Health"
                 ],
                 "operationId": "apiServer/healthz",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "Also check Docker, IPFS, disk space, outbound connectivity and clock skew",
+                        "name": "deep",
+                        "in": "query"
+                    }
+                ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
                             "$ref": "#/definitions/types.HealthInfo"
                         }
+                    },
+                    "503": {
+                        "description": "A check of the node's environment failed",
+                        "schema": {
+                            "$ref": "#/definitions/types.HealthInfo"
+                        }
                     }
                 }
             }
@@ -198,9 +212,23 @@ const docTemplate = `{
                     "Health"
                 ],
                 "operationId": "apiServer/livez",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "List the status of each dependency",
+                        "name": "verbose",
+                        "in": "query"
+                    }
+                ],
                 "responses": {
                     "200": {
-                        "description": "TODO",
+                        "description": "OK",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "503": {
+                        "description": "A dependency the node can't recover without a restart is broken",
                         "schema": {
                             "type": "string"
                         }
@@ -316,12 +344,26 @@ const docTemplate = `{
                     "Health"
                 ],
                 "operationId": "apiServer/readyz",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "List the status of each dependency",
+                        "name": "verbose",
+                        "in": "query"
+                    }
+                ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
                             "type": "string"
                         }
+                    },
+                    "503": {
+                        "description": "A dependency of the node, such as its IPFS daemon, is unavailable",
+                        "schema": {
+                            "type": "string"
+                        }
                     }
                 }
             }
@@ -971,7 +1013,7 @@ const docTemplate = `{
                     "type": "string"
                 },
                 "GPU": {
-                    "description": "unsigned integer string",
+                    "description": "https://github.com/BTBurke/k8sresource string, e.g. 1, 2 or 500m",
                     "type": "string"
                 },
                 "Memory": {
@@ -1287,6 +1329,43 @@ const docTemplate = `{
                 }
             }
         },
+        "selftest.Report": {
+            "type": "object",
+            "properties": {
+                "CheckedAt": {
+                    "type": "string"
+                },
+                "Checks": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/selftest.Result"
+                    }
+                },
+                "Healthy": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "selftest.Result": {
+            "type": "object",
+            "properties": {
+                "Duration": {
+                    "type": "integer"
+                },
+                "Message": {
+                    "type": "string"
+                },
+                "Name": {
+                    "type": "string"
+                },
+                "Remedy": {
+                    "type": "string"
+                },
+                "Status": {
+                    "type": "string"
+                }
+            }
+        },
         "types.FreeSpace": {
             "type": "object",
             "properties": {
@@ -1306,6 +1385,28 @@ const docTemplate = `{
             "properties": {
                 "FreeSpace": {
                     "$ref": "#/definitions/types.FreeSpace"
+                },
+                "IPFS": {
+                    "description": "Nil if the node doesn't use an IPFS daemon",
+                    "$ref": "#/definitions/types.IPFSStatus"
+                },
+                "SelfTest": {
+                    "description": "The checks of the node's environment, only run when asked for with\n/healthz?deep=true",
+                    "$ref": "#/definitions/selftest.Report"
+                }
+            }
+        },
+        "types.IPFSStatus": {
+            "type": "object",
+            "properties": {
+                "Available": {
+                    "type": "boolean"
+                },
+                "Error": {
+                    "type": "string"
+                },
+                "LastChecked": {
+                    "type": "string"
                 }
             }
         },
@@ -0,0 +1,6 @@
+"""Airflow provider for submitting and waiting on Bacalhau jobs."""
+
+from bacalhau_airflow.hooks import BacalhauHook
+from bacalhau_airflow.operators import BacalhauSubmitJobOperator, BacalhauWaitJobOperator
+
+__all__ = ["BacalhauHook", "BacalhauSubmitJobOperator", "BacalhauWaitJobOperator"]
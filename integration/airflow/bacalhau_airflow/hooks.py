@@ -0,0 +1,78 @@
+"""Hook talking to a Bacalhau requester node."""
+
+import json
+import subprocess
+import time
+import urllib.request
+
+from airflow.exceptions import AirflowException
+from airflow.hooks.base import BaseHook
+
+# The longest the node holds a /wait request open for.
+MAX_WAIT_SECONDS = 10
+
+
+class BacalhauHook(BaseHook):
+    """Submits jobs with the bacalhau CLI and follows them through the REST API.
+
+    Jobs are submitted with the CLI as submissions have to be signed with the
+    client's key, which the CLI manages. The connection's host and port are
+    those of the requester node's API.
+    """
+
+    conn_name_attr = "bacalhau_conn_id"
+    default_conn_name = "bacalhau_default"
+    conn_type = "bacalhau"
+    hook_name = "Bacalhau"
+
+    def __init__(self, bacalhau_conn_id=default_conn_name, bacalhau_binary="bacalhau"):
+        super().__init__()
+        conn = self.get_connection(bacalhau_conn_id)
+        self.host = conn.host or "bootstrap.production.bacalhau.org"
+        self.port = conn.port or 1234
+        self.binary = bacalhau_binary
+
+    def submit(self, args):
+        """Runs `bacalhau <args> --id-only`, e.g. `docker run ubuntu echo hi`
+        or `create job.yaml`, and returns the ID of the submitted job."""
+        cmd = [
+            self.binary,
+            "--api-host", self.host,
+            "--api-port", str(self.port),
+            *args,
+            "--id-only",
+        ]
+        self.log.info("Submitting job: %s", " ".join(cmd))
+        proc = subprocess.run(cmd, capture_output=True, text=True, check=False)
+        if proc.returncode != 0:
+            raise AirflowException(f"bacalhau failed to submit the job: {proc.stderr.strip()}")
+        return proc.stdout.strip().splitlines()[-1]
+
+    def wait(self, job_id, timeout=None, poll_seconds=MAX_WAIT_SECONDS):
+        """Waits for the job to finish and returns the last /wait response.
+
+        Raises if the job hasn't finished within timeout seconds."""
+        deadline = None if timeout is None else time.monotonic() + timeout
+        while True:
+            res = self._post("wait", {"job_id": job_id, "timeout_seconds": poll_seconds})
+            if res["finished"]:
+                return res
+            if deadline is not None and time.monotonic() > deadline:
+                raise AirflowException(f"job {job_id} has not finished, it is {res['state']}")
+
+    def get_states(self, job_id):
+        return self._post("states", {"job_id": job_id})["state"]
+
+    def get_results(self, job_id):
+        return self._post("results", {"job_id": job_id})["results"]
+
+    def _post(self, endpoint, data):
+        req = urllib.request.Request(
+            f"http://{self.host}:{self.port}/{endpoint}",
+            data=json.dumps(data).encode(),
+            headers={"Content-type": "application/json"},
+            method="POST",
+        )
+        # leave room for the node to hold /wait requests open
+        with urllib.request.urlopen(req, timeout=MAX_WAIT_SECONDS + 20) as res:
+            return json.load(res)
@@ -0,0 +1,53 @@
+"""Operators running Bacalhau jobs from Airflow DAGs."""
+
+from airflow.exceptions import AirflowException
+from airflow.models import BaseOperator
+
+from bacalhau_airflow.hooks import BacalhauHook
+
+# Job states, summarised over all shards, in which the job produced no results.
+FAILED_STATES = ("Error", "Cancelled")
+
+
+class BacalhauSubmitJobOperator(BaseOperator):
+    """Submits a job and pushes its ID to XCom as the return value.
+
+    `command` holds the bacalhau CLI arguments that submit the job, e.g.
+    ["docker", "run", "ubuntu", "echo", "hello"] or ["create", "job.yaml"].
+    """
+
+    template_fields = ("command",)
+
+    def __init__(self, *, command, bacalhau_conn_id=BacalhauHook.default_conn_name, **kwargs):
+        super().__init__(**kwargs)
+        self.command = command
+        self.bacalhau_conn_id = bacalhau_conn_id
+
+    def execute(self, context):
+        job_id = BacalhauHook(self.bacalhau_conn_id).submit(self.command)
+        self.log.info("Submitted job %s", job_id)
+        return job_id
+
+
+class BacalhauWaitJobOperator(BaseOperator):
+    """Waits for a job to finish and pushes the CIDs of its results to XCom
+    as the return value. The job's state and full results are pushed under
+    the "state" and "results" keys."""
+
+    template_fields = ("job_id",)
+
+    def __init__(self, *, job_id, wait_timeout=None, bacalhau_conn_id=BacalhauHook.default_conn_name, **kwargs):
+        super().__init__(**kwargs)
+        self.job_id = job_id
+        self.wait_timeout = wait_timeout
+        self.bacalhau_conn_id = bacalhau_conn_id
+
+    def execute(self, context):
+        res = BacalhauHook(self.bacalhau_conn_id).wait(self.job_id, timeout=self.wait_timeout)
+        results = res["results"] or []
+        ti = context["ti"]
+        ti.xcom_push(key="state", value=res["state"])
+        ti.xcom_push(key="results", value=results)
+        if res["state"] in FAILED_STATES:
+            raise AirflowException(f"job {self.job_id} finished in state {res['state']}")
+        return [result["Data"]["CID"] for result in results]
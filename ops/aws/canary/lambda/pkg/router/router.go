@@ -2,6 +2,7 @@ package router
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/filecoin-project/bacalhau/ops/aws/canary/pkg/models"
 	"github.com/filecoin-project/bacalhau/ops/aws/canary/pkg/scenarios"
@@ -15,6 +16,8 @@ var TestcasesMap = map[string]Handler{
 	"submitAndGet":          scenarios.SubmitAndGet,
 	"submitAndDescribe":     scenarios.SubmitAnDescribe,
 	"submitWithConcurrency": scenarios.SubmitWithConcurrency,
+	"submitWithSharding":    scenarios.SubmitWithSharding,
+	"computeNodeLostMidJob": scenarios.ComputeNodeLostMidJob,
 }
 
 func init() {
@@ -31,6 +34,10 @@ func Route(ctx context.Context, event models.Event) error {
 		return fmt.Errorf("no handler found for action: %s", event.Action)
 	}
 	err := handler(ctx)
+	if errors.Is(err, scenarios.ErrSkipped) {
+		log.Info().Msgf("testcase %s %s", event.Action, err)
+		return nil
+	}
 	if err != nil {
 		return fmt.Errorf("testcase %s failed: %s", event.Action, err)
 	}
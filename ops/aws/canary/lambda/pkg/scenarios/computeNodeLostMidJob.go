@@ -0,0 +1,184 @@
+package scenarios
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/filecoin-project/bacalhau/pkg/devstack"
+	noop_executor "github.com/filecoin-project/bacalhau/pkg/executor/noop"
+	"github.com/filecoin-project/bacalhau/pkg/job"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/node"
+	"github.com/filecoin-project/bacalhau/pkg/publicapi"
+	"github.com/filecoin-project/bacalhau/pkg/requesternode"
+	"github.com/filecoin-project/bacalhau/pkg/system"
+	"github.com/rs/zerolog/log"
+)
+
+// how many compute nodes run alongside the requester, enough for the job to
+// run on another one once a node running it is lost
+const lostNodeComputeNodes = 3
+
+// how long the shards of the job run for, so that there is time to lose the
+// node running one before it completes
+const lostNodeJobDuration = 10 * time.Second
+
+// how long the requester has to notice that a node running a shard was lost
+const lostNodeGracePeriod = 15 * time.Second
+
+// ComputeNodeLostMidJob cuts a compute node off from the network while it is
+// running a shard, and checks that the shard is run somewhere else so that
+// the job still completes. Unlike the other scenarios it needs control over
+// the nodes, so it runs noop jobs on devstacks of its own rather than
+// against the network the canary watches. It is skipped if the requester
+// never notices that the node was lost, as it doesn't re-schedule the shards
+// of lost nodes yet.
+func ComputeNodeLostMidJob(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// the requester and each compute node run in a devstack of their own, so
+	// that a compute node can be lost by cleaning up its devstack
+	requesterCM := system.NewCleanupManager()
+	defer requesterCM.Cleanup()
+	requesterStack, err := newLostNodeDevStack(ctx, requesterCM, "")
+	if err != nil {
+		return err
+	}
+	requester := requesterStack.Nodes[0]
+	requesterAddrs, err := requester.Transport.HostAddrs()
+	if err != nil {
+		return err
+	}
+
+	computeCMs := map[string]*system.CleanupManager{}
+	defer func() {
+		for _, cm := range computeCMs {
+			cm.Cleanup()
+		}
+	}()
+	for i := 0; i < lostNodeComputeNodes; i++ {
+		cm := system.NewCleanupManager()
+		stack, stackErr := newLostNodeDevStack(ctx, cm, requesterAddrs[0].String())
+		if stackErr != nil {
+			cm.Cleanup()
+			return stackErr
+		}
+		computeCMs[stack.Nodes[0].HostID] = cm
+	}
+
+	client := publicapi.NewAPIClient(fmt.Sprintf("http://%s:%d", requester.APIServer.Host, requester.APIServer.Port))
+
+	j := &model.Job{}
+	j.Spec = model.Spec{
+		Engine:    model.EngineNoop,
+		Verifier:  model.VerifierNoop,
+		Publisher: model.PublisherNoop,
+	}
+	j.Deal = model.Deal{Concurrency: 2}
+	submittedJob, err := client.Submit(ctx, j, nil)
+	if err != nil {
+		return err
+	}
+	log.Info().Msgf("submitted job: %s", submittedJob.ID)
+
+	// wait for a node other than the requester to start running the job
+	var lostNodeID string
+	resolver := client.GetJobStateResolver()
+	totalShards := job.GetJobTotalExecutionCount(submittedJob)
+	err = resolver.Wait(ctx, submittedJob.ID, totalShards, func(state model.JobState) (bool, error) {
+		for nodeID, nodeState := range state.Nodes {
+			if nodeID != requester.HostID && isRunning(nodeState) {
+				lostNodeID = nodeID
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	computeCMs[lostNodeID].Cleanup()
+	delete(computeCMs, lostNodeID)
+	log.Info().Msgf("lost node %s while it was running the job", lostNodeID)
+
+	noticeCtx, cancelNotice := context.WithTimeout(ctx, lostNodeGracePeriod)
+	defer cancelNotice()
+	// the other shards may all be done by then, e.g. the bids that were rejected
+	err = resolver.WaitWithOptions(noticeCtx, job.WaitOptions{
+		JobID:            submittedJob.ID,
+		TotalShards:      totalShards,
+		AllowAllTerminal: true,
+	}, func(state model.JobState) (bool, error) {
+		return !isRunning(state.Nodes[lostNodeID]), nil
+	})
+	if noticeCtx.Err() != nil && ctx.Err() == nil {
+		return fmt.Errorf("%w: the requester doesn't notice that compute nodes are lost", ErrSkipped)
+	}
+	if err != nil {
+		return err
+	}
+
+	err = resolver.Wait(
+		ctx,
+		submittedJob.ID,
+		totalShards,
+		job.WaitForJobStates(map[model.JobStateType]int{
+			model.JobStateCompleted: totalShards,
+		}),
+	)
+	if err != nil {
+		return err
+	}
+
+	state, err := client.GetJobState(ctx, submittedJob.ID)
+	if err != nil {
+		return err
+	}
+	for _, shardState := range state.Nodes[lostNodeID].Shards {
+		if shardState.State == model.JobStateCompleted {
+			return fmt.Errorf("lost node %s completed shard %d", lostNodeID, shardState.ShardIndex)
+		}
+	}
+	return nil
+}
+
+// newLostNodeDevStack starts a devstack of a single node that runs noop jobs
+// for lostNodeJobDuration, and that joins the node at peer if there is one.
+func newLostNodeDevStack(ctx context.Context, cm *system.CleanupManager, peer string) (*devstack.DevStack, error) {
+	injector := devstack.NewNoopNodeDependencyInjector()
+	injector.ExecutorsFactory = devstack.NewNoopExecutorsFactoryWithConfig(noop_executor.ExecutorConfig{
+		ExternalHooks: noop_executor.ExecutorConfigExternalHooks{
+			JobHandler: func(ctx context.Context, _ model.JobShard, _ string) (*model.RunCommandResult, error) {
+				select {
+				case <-time.After(lostNodeJobDuration):
+					return &model.RunCommandResult{}, nil
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				}
+			},
+		},
+	})
+	return devstack.NewDevStack(
+		ctx,
+		cm,
+		devstack.DevStackOptions{NumberOfNodes: 1, Peer: peer},
+		node.NewComputeConfigWithDefaults(),
+		requesternode.NewDefaultRequesterNodeConfig(),
+		injector,
+	)
+}
+
+// isRunning returns whether the node was assigned a shard and has yet to
+// send its results. Requesters report such shards as waiting, or as running
+// if the node has told them it started.
+func isRunning(nodeState model.JobNodeState) bool {
+	for _, shardState := range nodeState.Shards {
+		if shardState.State == model.JobStateWaiting || shardState.State == model.JobStateRunning {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,145 @@
+package scenarios
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/filecoin-project/bacalhau/cmd/bacalhau"
+	"github.com/filecoin-project/bacalhau/pkg/ipfs"
+	"github.com/filecoin-project/bacalhau/pkg/job"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/publicapi"
+	"github.com/filecoin-project/bacalhau/pkg/system"
+	"github.com/rs/zerolog/log"
+)
+
+// the directory created by `ipfs init`, which is pinned by most IPFS nodes
+const shardedInputCID = "QmS4ustL54uo8FzR9455qaxZwuMiUhyvMcX9Ba8nUH4uVv"
+
+var shardedInputFiles = []string{
+	"about",
+	"contact",
+	"help",
+	"ping",
+	"quick-start",
+	"readme",
+	"security-notes",
+}
+
+// SubmitWithSharding runs a job with one shard per file of a known directory,
+// and checks that each shard processed exactly one file and that all files
+// were processed.
+func SubmitWithSharding(ctx context.Context) error {
+	// intentionally delay creation of the client so a new client is created for each
+	// scenario to mimic the behavior of bacalhau cli.
+	client := bacalhau.GetAPIClient()
+
+	j := getSampleDockerJob()
+	j.Spec.Docker.Entrypoint = []string{
+		"bash", "-c",
+		`for f in /inputs/*; do basename $f; done`,
+	}
+	downloadSettings, err := getIPFSDownloadSettings()
+	if err != nil {
+		return err
+	}
+	return RunShardedJob(ctx, client, j, shardedInputCID, shardedInputFiles, downloadSettings)
+}
+
+// RunShardedJob runs the job with one shard per file of the directory at
+// inputCID, mounted at /inputs, and checks that it has a shard per file of
+// inputFiles, that each shard printed the name of the one file it processed,
+// and that all files were processed. The results are downloaded with the
+// download settings.
+func RunShardedJob(
+	ctx context.Context,
+	client *publicapi.APIClient,
+	j *model.Job,
+	inputCID string,
+	inputFiles []string,
+	downloadSettings *ipfs.IPFSDownloadSettings,
+) error {
+	cm := system.NewCleanupManager()
+	defer cm.Cleanup()
+
+	j.Spec.Inputs = []model.StorageSpec{
+		{
+			StorageSource: model.StorageSourceIPFS,
+			CID:           inputCID,
+			Path:          "/inputs",
+		},
+	}
+	j.Spec.Sharding = model.JobShardingConfig{
+		GlobPattern: "/inputs/*",
+		BatchSize:   1,
+	}
+	submittedJob, err := client.Submit(ctx, j, nil)
+	if err != nil {
+		return err
+	}
+
+	log.Info().Msgf("submitted job: %s", submittedJob.ID)
+
+	totalShards := job.GetJobTotalShards(submittedJob)
+	if totalShards != len(inputFiles) {
+		return fmt.Errorf("expected %d shards but got %d", len(inputFiles), totalShards)
+	}
+
+	err = waitUntilCompleted(ctx, client, submittedJob)
+	if err != nil {
+		return err
+	}
+
+	results, err := client.GetResults(ctx, submittedJob.ID)
+	if err != nil {
+		return err
+	}
+
+	if len(results) != totalShards {
+		return fmt.Errorf("expected results for %d shards but got %d", totalShards, len(results))
+	}
+
+	outputDir, err := os.MkdirTemp(os.TempDir(), "submitWithSharding")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(outputDir)
+
+	downloadSettings.OutputDir = outputDir
+
+	err = ipfs.DownloadJob(ctx, cm, submittedJob.Spec.Outputs, results, *downloadSettings)
+	if err != nil {
+		return err
+	}
+
+	shardStdouts, err := filepath.Glob(filepath.Join(outputDir, ipfs.DownloadShardsFolderName, "*", ipfs.DownloadFilenameStdout))
+	if err != nil {
+		return err
+	}
+	if len(shardStdouts) != totalShards {
+		return fmt.Errorf("expected outputs for %d shards but got %d", totalShards, len(shardStdouts))
+	}
+
+	var processedFiles []string
+	for _, shardStdout := range shardStdouts {
+		body, err := os.ReadFile(shardStdout)
+		if err != nil {
+			return err
+		}
+		files := strings.Fields(string(body))
+		if len(files) != 1 {
+			return fmt.Errorf("expected shard %s to process 1 file but it processed %v",
+				filepath.Base(filepath.Dir(shardStdout)), files)
+		}
+		processedFiles = append(processedFiles, files[0])
+	}
+
+	sort.Strings(processedFiles)
+	expectedFiles := append([]string{}, inputFiles...)
+	sort.Strings(expectedFiles)
+	return compareOutput([]byte(strings.Join(processedFiles, ",")), strings.Join(expectedFiles, ","))
+}
@@ -2,6 +2,7 @@ package scenarios
 
 import (
 	"context"
+	"errors"
 )
 
 type Event struct {
@@ -9,3 +10,7 @@ type Event struct {
 }
 
 type Handler func(ctx context.Context) error
+
+// ErrSkipped is returned by scenarios that can't check what they are meant to,
+// e.g. because the nodes lack a capability the scenario tests.
+var ErrSkipped = errors.New("skipped")
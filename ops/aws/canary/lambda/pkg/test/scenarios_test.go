@@ -4,12 +4,24 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/filecoin-project/bacalhau/ops/aws/canary/pkg/models"
 	"github.com/filecoin-project/bacalhau/ops/aws/canary/pkg/router"
+	"github.com/filecoin-project/bacalhau/ops/aws/canary/pkg/scenarios"
+	"github.com/filecoin-project/bacalhau/pkg/devstack"
+	noop_executor "github.com/filecoin-project/bacalhau/pkg/executor/noop"
+	executor_util "github.com/filecoin-project/bacalhau/pkg/executor/util"
+	"github.com/filecoin-project/bacalhau/pkg/ipfs"
+	"github.com/filecoin-project/bacalhau/pkg/job"
+	"github.com/filecoin-project/bacalhau/pkg/model"
 	"github.com/filecoin-project/bacalhau/pkg/node"
+	"github.com/filecoin-project/bacalhau/pkg/publicapi"
 	"github.com/filecoin-project/bacalhau/pkg/requesternode"
+	"github.com/filecoin-project/bacalhau/pkg/storage"
+	"github.com/filecoin-project/bacalhau/pkg/system"
 	testutils "github.com/filecoin-project/bacalhau/pkg/test/utils"
 	"github.com/stretchr/testify/require"
 )
@@ -33,3 +45,78 @@ func TestScenarios(t *testing.T) {
 		})
 	}
 }
+
+func TestSubmitWithSharding(t *testing.T) {
+	ctx := context.Background()
+
+	// each shard prints the names of the files it was given, like the
+	// entrypoint of the docker job of the scenario
+	var storageProvider storage.StorageProvider
+	stack := testutils.SetupTestWithNoopExecutor(ctx, t,
+		devstack.DevStackOptions{NumberOfNodes: 1},
+		node.NewComputeConfigWithDefaults(),
+		requesternode.NewDefaultRequesterNodeConfig(),
+		&noop_executor.ExecutorConfig{
+			ExternalHooks: noop_executor.ExecutorConfigExternalHooks{
+				JobHandler: func(ctx context.Context, shard model.JobShard, resultsDir string) (*model.RunCommandResult, error) {
+					inputs, err := job.GetShardStorageSpec(ctx, shard, storageProvider)
+					if err != nil {
+						return nil, err
+					}
+					var stdout strings.Builder
+					for _, input := range inputs {
+						fmt.Fprintln(&stdout, filepath.Base(input.Path))
+					}
+					err = os.WriteFile(filepath.Join(resultsDir, ipfs.DownloadFilenameStdout), []byte(stdout.String()), os.ModePerm)
+					if err != nil {
+						return nil, err
+					}
+					return &model.RunCommandResult{STDOUT: stdout.String()}, nil
+				},
+			},
+		},
+	)
+
+	cm := system.NewCleanupManager()
+	t.Cleanup(cm.Cleanup)
+	ipfsClient := stack.IPFSClients()[0]
+	var err error
+	storageProvider, err = executor_util.NewStandardStorageProvider(ctx, cm, executor_util.StandardStorageProviderOptions{
+		IPFSMultiaddress: ipfsClient.APIAddress(),
+	})
+	require.NoError(t, err)
+
+	inputFiles := []string{"a", "b", "c"}
+	inputDir := t.TempDir()
+	for _, name := range inputFiles {
+		require.NoError(t, os.WriteFile(filepath.Join(inputDir, name), []byte(name), os.ModePerm))
+	}
+	inputCID, err := ipfs.AddFileToNodes(ctx, inputDir, ipfsClient)
+	require.NoError(t, err)
+
+	swarmAddresses, err := ipfsClient.SwarmAddresses(ctx)
+	require.NoError(t, err)
+	client := publicapi.NewAPIClient(stack.Nodes[0].APIServer.GetURI())
+	newJob := func() *model.Job {
+		return &model.Job{
+			Spec: model.Spec{
+				Engine:    model.EngineNoop,
+				Verifier:  model.VerifierNoop,
+				Publisher: model.PublisherIpfs,
+			},
+			Deal: model.Deal{Concurrency: 1},
+		}
+	}
+	downloadSettings := func() *ipfs.IPFSDownloadSettings {
+		return &ipfs.IPFSDownloadSettings{
+			TimeoutSecs:    10,
+			IPFSSwarmAddrs: strings.Join(swarmAddresses, ","),
+		}
+	}
+
+	err = scenarios.RunShardedJob(ctx, client, newJob(), inputCID, inputFiles, downloadSettings())
+	require.NoError(t, err)
+
+	err = scenarios.RunShardedJob(ctx, client, newJob(), inputCID, inputFiles[1:], downloadSettings())
+	require.ErrorContains(t, err, "expected 2 shards but got 3")
+}
@@ -40,6 +40,8 @@ export class CanaryStack extends cdk.Stack {
         this.createLambdaScenarioFunc({action: "submitAndGet", timeoutMinutes: 1, rateMinutes: 2, memorySize: 1024});
         this.createLambdaScenarioFunc({action: "submitAndDescribe", timeoutMinutes: 1, rateMinutes: 2, memorySize: 256});
         this.createLambdaScenarioFunc({action: "submitWithConcurrency", timeoutMinutes: 1, rateMinutes: 2, memorySize: 256});
+        this.createLambdaScenarioFunc({action: "submitWithSharding", timeoutMinutes: 2, rateMinutes: 5, memorySize: 1024});
+        this.createLambdaScenarioFunc({action: "computeNodeLostMidJob", timeoutMinutes: 5, rateMinutes: 60, memorySize: 2048});
         this.createOperatorGroup(id)
     }
 
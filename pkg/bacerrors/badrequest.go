@@ -0,0 +1,53 @@
+package bacerrors
+
+import (
+	"fmt"
+)
+
+// BadRequest is returned for a request the server can't read.
+type BadRequest GenericError
+
+func NewBadRequest(err error) *BadRequest {
+	var e BadRequest
+	e.Code = ErrorCodeBadRequest
+	e.Message = fmt.Sprintf(ErrorMessageBadRequest, err)
+	e.Details = make(map[string]interface{})
+	e.SetError(err)
+	return &e
+}
+
+func (e *BadRequest) GetMessage() string {
+	return e.Message
+}
+func (e *BadRequest) SetMessage(s string) {
+	e.Message = s
+}
+
+func (e *BadRequest) Error() string {
+	return e.GetError().Error()
+}
+func (e *BadRequest) GetError() error {
+	return e.Err
+}
+func (e *BadRequest) SetError(err error) {
+	e.Err = err
+}
+
+func (e *BadRequest) GetCode() string {
+	return ErrorCodeBadRequest
+}
+func (e *BadRequest) SetCode(string) {
+	e.Code = ErrorCodeBadRequest
+}
+
+func (e *BadRequest) GetDetails() map[string]interface{} {
+	return e.Details
+}
+
+const (
+	ErrorCodeBadRequest = "error-bad-request"
+
+	ErrorMessageBadRequest = "Bad request: %s"
+)
+
+var _ BacalhauErrorInterface = (*BadRequest)(nil)
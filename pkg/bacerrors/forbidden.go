@@ -0,0 +1,53 @@
+package bacerrors
+
+import (
+	"fmt"
+)
+
+// Forbidden is returned for a request the client isn't allowed to make.
+type Forbidden GenericError
+
+func NewForbidden(err error) *Forbidden {
+	var e Forbidden
+	e.Code = ErrorCodeForbidden
+	e.Message = fmt.Sprintf(ErrorMessageForbidden, err)
+	e.Details = make(map[string]interface{})
+	e.SetError(err)
+	return &e
+}
+
+func (e *Forbidden) GetMessage() string {
+	return e.Message
+}
+func (e *Forbidden) SetMessage(s string) {
+	e.Message = s
+}
+
+func (e *Forbidden) Error() string {
+	return e.GetError().Error()
+}
+func (e *Forbidden) GetError() error {
+	return e.Err
+}
+func (e *Forbidden) SetError(err error) {
+	e.Err = err
+}
+
+func (e *Forbidden) GetCode() string {
+	return ErrorCodeForbidden
+}
+func (e *Forbidden) SetCode(string) {
+	e.Code = ErrorCodeForbidden
+}
+
+func (e *Forbidden) GetDetails() map[string]interface{} {
+	return e.Details
+}
+
+const (
+	ErrorCodeForbidden = "error-forbidden"
+
+	ErrorMessageForbidden = "Forbidden: %s"
+)
+
+var _ BacalhauErrorInterface = (*Forbidden)(nil)
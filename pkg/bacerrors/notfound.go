@@ -0,0 +1,53 @@
+package bacerrors
+
+import (
+	"fmt"
+)
+
+// NotFound is returned for a request for something other than a job that doesn't exist.
+type NotFound GenericError
+
+func NewNotFound(err error) *NotFound {
+	var e NotFound
+	e.Code = ErrorCodeNotFound
+	e.Message = fmt.Sprintf(ErrorMessageNotFound, err)
+	e.Details = make(map[string]interface{})
+	e.SetError(err)
+	return &e
+}
+
+func (e *NotFound) GetMessage() string {
+	return e.Message
+}
+func (e *NotFound) SetMessage(s string) {
+	e.Message = s
+}
+
+func (e *NotFound) Error() string {
+	return e.GetError().Error()
+}
+func (e *NotFound) GetError() error {
+	return e.Err
+}
+func (e *NotFound) SetError(err error) {
+	e.Err = err
+}
+
+func (e *NotFound) GetCode() string {
+	return ErrorCodeNotFound
+}
+func (e *NotFound) SetCode(string) {
+	e.Code = ErrorCodeNotFound
+}
+
+func (e *NotFound) GetDetails() map[string]interface{} {
+	return e.Details
+}
+
+const (
+	ErrorCodeNotFound = "error-not-found"
+
+	ErrorMessageNotFound = "Not found: %s"
+)
+
+var _ BacalhauErrorInterface = (*NotFound)(nil)
@@ -0,0 +1,53 @@
+package bacerrors
+
+import (
+	"fmt"
+)
+
+// NotImplemented is returned for a request for something the node doesn't do.
+type NotImplemented GenericError
+
+func NewNotImplemented(err error) *NotImplemented {
+	var e NotImplemented
+	e.Code = ErrorCodeNotImplemented
+	e.Message = fmt.Sprintf(ErrorMessageNotImplemented, err)
+	e.Details = make(map[string]interface{})
+	e.SetError(err)
+	return &e
+}
+
+func (e *NotImplemented) GetMessage() string {
+	return e.Message
+}
+func (e *NotImplemented) SetMessage(s string) {
+	e.Message = s
+}
+
+func (e *NotImplemented) Error() string {
+	return e.GetError().Error()
+}
+func (e *NotImplemented) GetError() error {
+	return e.Err
+}
+func (e *NotImplemented) SetError(err error) {
+	e.Err = err
+}
+
+func (e *NotImplemented) GetCode() string {
+	return ErrorCodeNotImplemented
+}
+func (e *NotImplemented) SetCode(string) {
+	e.Code = ErrorCodeNotImplemented
+}
+
+func (e *NotImplemented) GetDetails() map[string]interface{} {
+	return e.Details
+}
+
+const (
+	ErrorCodeNotImplemented = "error-not-implemented"
+
+	ErrorMessageNotImplemented = "Not implemented: %s"
+)
+
+var _ BacalhauErrorInterface = (*NotImplemented)(nil)
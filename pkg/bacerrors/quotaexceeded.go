@@ -0,0 +1,53 @@
+package bacerrors
+
+import (
+	"fmt"
+)
+
+// QuotaExceeded is returned for a request over the quota of its client.
+type QuotaExceeded GenericError
+
+func NewQuotaExceeded(err error) *QuotaExceeded {
+	var e QuotaExceeded
+	e.Code = ErrorCodeQuotaExceeded
+	e.Message = fmt.Sprintf(ErrorMessageQuotaExceeded, err)
+	e.Details = make(map[string]interface{})
+	e.SetError(err)
+	return &e
+}
+
+func (e *QuotaExceeded) GetMessage() string {
+	return e.Message
+}
+func (e *QuotaExceeded) SetMessage(s string) {
+	e.Message = s
+}
+
+func (e *QuotaExceeded) Error() string {
+	return e.GetError().Error()
+}
+func (e *QuotaExceeded) GetError() error {
+	return e.Err
+}
+func (e *QuotaExceeded) SetError(err error) {
+	e.Err = err
+}
+
+func (e *QuotaExceeded) GetCode() string {
+	return ErrorCodeQuotaExceeded
+}
+func (e *QuotaExceeded) SetCode(string) {
+	e.Code = ErrorCodeQuotaExceeded
+}
+
+func (e *QuotaExceeded) GetDetails() map[string]interface{} {
+	return e.Details
+}
+
+const (
+	ErrorCodeQuotaExceeded = "error-quota-exceeded"
+
+	ErrorMessageQuotaExceeded = "Quota exceeded: %s"
+)
+
+var _ BacalhauErrorInterface = (*QuotaExceeded)(nil)
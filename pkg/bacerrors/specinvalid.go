@@ -0,0 +1,53 @@
+package bacerrors
+
+import (
+	"fmt"
+)
+
+// SpecInvalid is returned for an invalid job spec.
+type SpecInvalid GenericError
+
+func NewSpecInvalid(err error) *SpecInvalid {
+	var e SpecInvalid
+	e.Code = ErrorCodeSpecInvalid
+	e.Message = fmt.Sprintf(ErrorMessageSpecInvalid, err)
+	e.Details = make(map[string]interface{})
+	e.SetError(err)
+	return &e
+}
+
+func (e *SpecInvalid) GetMessage() string {
+	return e.Message
+}
+func (e *SpecInvalid) SetMessage(s string) {
+	e.Message = s
+}
+
+func (e *SpecInvalid) Error() string {
+	return e.GetError().Error()
+}
+func (e *SpecInvalid) GetError() error {
+	return e.Err
+}
+func (e *SpecInvalid) SetError(err error) {
+	e.Err = err
+}
+
+func (e *SpecInvalid) GetCode() string {
+	return ErrorCodeSpecInvalid
+}
+func (e *SpecInvalid) SetCode(string) {
+	e.Code = ErrorCodeSpecInvalid
+}
+
+func (e *SpecInvalid) GetDetails() map[string]interface{} {
+	return e.Details
+}
+
+const (
+	ErrorCodeSpecInvalid = "error-spec-invalid"
+
+	ErrorMessageSpecInvalid = "The job spec is invalid: %s"
+)
+
+var _ BacalhauErrorInterface = (*SpecInvalid)(nil)
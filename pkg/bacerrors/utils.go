@@ -1,6 +1,8 @@
 package bacerrors
 
 import (
+	"errors"
+
 	"github.com/filecoin-project/bacalhau/pkg/model"
 	"github.com/rs/zerolog/log"
 )
@@ -12,6 +14,8 @@ type ErrorResponse struct {
 	Message string                 `json:"Message"`
 	Details map[string]interface{} `json:"Details"`
 	Err     string                 `json:"Err"`
+	// The ID of the request that failed, to quote when reporting the error.
+	RequestID string `json:"RequestID,omitempty"`
 }
 
 func NewResponseUnknownError(err error) *ErrorResponse {
@@ -27,6 +31,59 @@ func (e *ErrorResponse) Error() string {
 	return e.Message
 }
 
+// ToError returns the typed error for the code of the response, so that
+// clients can tell the errors of the server apart by their type. Responses
+// with codes the client doesn't know are returned as they are.
+func (e *ErrorResponse) ToError() error {
+	cause := e.Err
+	if cause == "" {
+		cause = e.Message
+	}
+	err := errors.New(cause)
+	details := e.Details
+	if details == nil {
+		details = map[string]interface{}{}
+	}
+	if e.RequestID != "" {
+		details["requestid"] = e.RequestID
+	}
+
+	switch e.Code {
+	case ErrorCodeJobNotFound:
+		id, _ := details["id"].(string)
+		return NewJobNotFound(id)
+	case ErrorCodeImageNotFound:
+		imageName, _ := details["imagename"].(string)
+		return NewImageNotFound(imageName)
+	case ErrorCodeSpecInvalid:
+		typed := NewSpecInvalid(err)
+		typed.Message, typed.Details = e.Message, details
+		return typed
+	case ErrorCodeQuotaExceeded:
+		typed := NewQuotaExceeded(err)
+		typed.Message, typed.Details = e.Message, details
+		return typed
+	case ErrorCodeForbidden:
+		typed := NewForbidden(err)
+		typed.Message, typed.Details = e.Message, details
+		return typed
+	case ErrorCodeBadRequest:
+		typed := NewBadRequest(err)
+		typed.Message, typed.Details = e.Message, details
+		return typed
+	case ErrorCodeNotFound:
+		typed := NewNotFound(err)
+		typed.Message, typed.Details = e.Message, details
+		return typed
+	case ErrorCodeNotImplemented:
+		typed := NewNotImplemented(err)
+		typed.Message, typed.Details = e.Message, details
+		return typed
+	default:
+		return e
+	}
+}
+
 func ErrorToErrorResponse(err error) string {
 	e := ErrorToErrorResponseObject(err)
 	return ConvertErrorToText(e)
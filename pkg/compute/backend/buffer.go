@@ -2,17 +2,28 @@ package backend
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/filecoin-project/bacalhau/pkg/compute/capacity"
 	"github.com/filecoin-project/bacalhau/pkg/compute/store"
+	"github.com/filecoin-project/bacalhau/pkg/model"
 	sync "github.com/lukemarsden/golang-mutex-tracer"
+	"github.com/rs/zerolog/log"
 )
 
+// ErrEvicted is the error spot executions fail with when they are evicted to make room for a standard job.
+var ErrEvicted = errors.New("spot job evicted to make room for a standard job")
+
 type bufferTask struct {
 	execution  store.Execution
 	enqueuedAt time.Time
+	startedAt  time.Time
+	// cancels the execution while it is running
+	cancel  context.CancelFunc
+	evicted bool
 }
 
 func newBufferTask(execution store.Execution) *bufferTask {
@@ -28,6 +39,10 @@ type ServiceBufferParams struct {
 	RunningCapacityTracker     capacity.Tracker
 	DefaultJobExecutionTimeout time.Duration
 	BackoffDuration            time.Duration
+	// The total resources spot jobs can use at once, so that the rest is kept for standard jobs. No limit if zero.
+	SpotResourceLimits model.ResourceUsageData
+	// The maximum number of executions to run at once, whatever their resource usage. No limit if zero.
+	MaxConcurrentJobs int
 }
 
 // ServiceBuffer is a backend.Service implementation that buffers executions locally until enough capacity is
@@ -36,6 +51,9 @@ type ServiceBufferParams struct {
 // they were enqueued. However, an execution with high resource usage requirements might be skipped if there are newer
 // jobs with lower resource usage requirements that can be executed immediately. This is done to improve utilization
 // of compute nodes, though it might result in starvation and should be re-evaluated in the future.
+//
+// Spot executions only run within the spot resource limits, and the most recently started ones are evicted when a
+// standard execution can't otherwise get enough capacity.
 type ServiceBuffer struct {
 	runningCapacity            capacity.Tracker
 	delegateService            Service
@@ -46,6 +64,9 @@ type ServiceBuffer struct {
 	defaultJobExecutionTimeout time.Duration
 	backoffDuration            time.Duration
 	backoffUntil               time.Time
+	spotResourceLimits         model.ResourceUsageData
+	spotUsage                  model.ResourceUsageData
+	maxConcurrentJobs          int
 	mu                         sync.Mutex
 }
 
@@ -59,6 +80,8 @@ func NewServiceBuffer(params ServiceBufferParams) *ServiceBuffer {
 		enqueuedList:               make([]string, 0),
 		defaultJobExecutionTimeout: params.DefaultJobExecutionTimeout,
 		backoffDuration:            params.BackoffDuration,
+		spotResourceLimits:         params.SpotResourceLimits,
+		maxConcurrentJobs:          params.MaxConcurrentJobs,
 	}
 
 	r.mu.EnableTracerWithOpts(sync.Opts{
@@ -117,7 +140,13 @@ func (s *ServiceBuffer) doRun(ctx context.Context, task *bufferTask) {
 
 	select {
 	case <-ctx.Done():
-		s.callback.OnRunFailure(ctx, task.execution.ID, ctx.Err())
+		err := ctx.Err()
+		s.mu.Lock()
+		if task.evicted {
+			err = ErrEvicted
+		}
+		s.mu.Unlock()
+		s.callback.OnRunFailure(ctx, task.execution.ID, err)
 	case runError := <-ch:
 		if runError != nil {
 			s.callback.OnRunFailure(ctx, task.execution.ID, runError)
@@ -127,6 +156,9 @@ func (s *ServiceBuffer) doRun(ctx context.Context, task *bufferTask) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.runningCapacity.Remove(ctx, task.execution.ResourceUsage)
+	if task.execution.Shard.Job.Deal.IsSpot() {
+		s.spotUsage = s.spotUsage.Sub(task.execution.ResourceUsage)
+	}
 	delete(s.running, task.execution.ID)
 	s.deque()
 }
@@ -148,12 +180,34 @@ func (s *ServiceBuffer) deque() {
 
 	for _, executionID := range s.enqueuedList {
 		task := s.enqueued[executionID]
+		spot := task.execution.Shard.Job.Deal.IsSpot()
 
+		// some workloads, such as I/O heavy ones, saturate the node long before its resources are used up
+		if s.maxConcurrentJobs > 0 && len(s.running) >= s.maxConcurrentJobs {
+			if !spot {
+				s.evictFor(ctx, task.execution.ResourceUsage)
+			}
+			remainingEnqueuedList = append(remainingEnqueuedList, executionID)
+			continue
+		}
+		if spot && !s.withinSpotLimits(task.execution.ResourceUsage) {
+			remainingEnqueuedList = append(remainingEnqueuedList, executionID)
+			continue
+		}
 		if s.runningCapacity.AddIfHasCapacity(ctx, task.execution.ResourceUsage) {
+			if spot {
+				s.spotUsage = s.spotUsage.Add(task.execution.ResourceUsage)
+			}
 			delete(s.enqueued, executionID)
 			s.running[executionID] = task
-			go s.doRun(ctx, task)
+			runCtx, cancel := context.WithCancel(ctx)
+			task.startedAt = time.Now()
+			task.cancel = cancel
+			go s.doRun(runCtx, task)
 		} else {
+			if !spot {
+				s.evictFor(ctx, task.execution.ResourceUsage)
+			}
 			remainingEnqueuedList = append(remainingEnqueuedList, executionID)
 		}
 	}
@@ -161,6 +215,77 @@ func (s *ServiceBuffer) deque() {
 	s.backoffUntil = time.Now().Add(s.backoffDuration)
 }
 
+func (s *ServiceBuffer) withinSpotLimits(usage model.ResourceUsageData) bool {
+	if s.spotResourceLimits.IsZero() {
+		return true
+	}
+	return s.spotUsage.Add(usage).LessThanEq(s.spotResourceLimits)
+}
+
+// evictFor evicts running spot executions, most recently started first, until there is enough capacity for usage, and
+// an execution slot if the number of concurrent executions is limited, once they stop. Nothing is evicted if that
+// wouldn't free up enough. The capacity and slot are freed, and the waiting execution started, when the evicted
+// executions stop.
+func (s *ServiceBuffer) evictFor(ctx context.Context, usage model.ResourceUsageData) {
+	var candidates []*bufferTask
+	freed := s.runningCapacity.AvailableCapacity(ctx)
+	stopping := 0
+	for _, task := range s.running {
+		if !task.execution.Shard.Job.Deal.IsSpot() {
+			continue
+		}
+		if task.evicted {
+			// already stopping, so its capacity and slot will be freed
+			freed = freed.Add(task.execution.ResourceUsage)
+			stopping++
+		} else {
+			candidates = append(candidates, task)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].startedAt.After(candidates[j].startedAt)
+	})
+
+	enough := func() bool {
+		hasSlot := s.maxConcurrentJobs == 0 || len(s.running)-stopping < s.maxConcurrentJobs
+		return hasSlot && usage.LessThanEq(freed)
+	}
+	var evict []*bufferTask
+	for _, task := range candidates {
+		if enough() {
+			break
+		}
+		evict = append(evict, task)
+		freed = freed.Add(task.execution.ResourceUsage)
+		stopping++
+	}
+	if !enough() {
+		return
+	}
+	for _, task := range evict {
+		log.Ctx(ctx).Info().Msgf("evicting spot execution %s to make room for a standard job", task.execution.ID)
+		task.evicted = true
+		task.cancel()
+	}
+}
+
+// UsedSpotCapacity returns the capacity used by the running spot executions.
+func (s *ServiceBuffer) UsedSpotCapacity(context.Context) model.ResourceUsageData {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.spotUsage
+}
+
+// AvailableSpotCapacity returns the capacity more spot executions can use within the spot resource limits.
+func (s *ServiceBuffer) AvailableSpotCapacity(ctx context.Context) model.ResourceUsageData {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.spotResourceLimits.IsZero() {
+		return s.runningCapacity.AvailableCapacity(ctx)
+	}
+	return s.spotResourceLimits.Sub(s.spotUsage)
+}
+
 func (s *ServiceBuffer) Publish(ctx context.Context, execution store.Execution) error {
 	return s.delegateService.Publish(ctx, execution)
 }
@@ -174,6 +299,13 @@ func (s *ServiceBuffer) RunningExecutions() []store.Execution {
 	return s.mapValues(s.running)
 }
 
+// ExecutionCount returns the number of executions that are running or enqueued
+func (s *ServiceBuffer) ExecutionCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.running) + len(s.enqueued)
+}
+
 // EnqueuedExecutions return list of enqueued executions
 func (s *ServiceBuffer) EnqueuedExecutions() []store.Execution {
 	return s.mapValues(s.enqueued)
@@ -191,3 +323,4 @@ func (s *ServiceBuffer) mapValues(m map[string]*bufferTask) []store.Execution {
 
 // compile-time interface check
 var _ Service = (*ServiceBuffer)(nil)
+var _ capacity.SpotTracker = (*ServiceBuffer)(nil)
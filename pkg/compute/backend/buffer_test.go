@@ -0,0 +1,137 @@
+//go:build unit || !integration
+
+package backend
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/bacalhau/pkg/compute/capacity"
+	"github.com/filecoin-project/bacalhau/pkg/compute/store"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingService runs executions until they are released.
+type blockingService struct {
+	started chan string
+	release chan struct{}
+}
+
+func newBlockingService() *blockingService {
+	return &blockingService{
+		started: make(chan string, 10),
+		release: make(chan struct{}, 10),
+	}
+}
+
+func (s *blockingService) Run(ctx context.Context, execution store.Execution) error {
+	s.started <- execution.ID
+	select {
+	case <-s.release:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *blockingService) Publish(context.Context, store.Execution) error {
+	return nil
+}
+
+func (s *blockingService) Cancel(context.Context, store.Execution) error {
+	return nil
+}
+
+func newTestServiceBuffer(delegate Service, maxConcurrentJobs int) *ServiceBuffer {
+	return NewServiceBuffer(ServiceBufferParams{
+		DelegateService: delegate,
+		Callback:        NewChainedCallback(ChainedCallbackParams{}),
+		RunningCapacityTracker: capacity.NewLocalTracker(capacity.LocalTrackerParams{
+			MaxCapacity: model.ResourceUsageData{CPU: 100, Memory: 100},
+		}),
+		DefaultJobExecutionTimeout: time.Minute,
+		MaxConcurrentJobs:          maxConcurrentJobs,
+	})
+}
+
+func testExecution(id string) store.Execution {
+	return store.Execution{
+		ID:            id,
+		Shard:         model.JobShard{Job: &model.Job{ID: id}},
+		ResourceUsage: model.ResourceUsageData{CPU: 1, Memory: 1},
+	}
+}
+
+func TestServiceBufferMaxConcurrentJobs(t *testing.T) {
+	ctx := context.Background()
+	delegate := newBlockingService()
+	buffer := newTestServiceBuffer(delegate, 2)
+	defer close(delegate.release)
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, buffer.Run(ctx, testExecution(fmt.Sprintf("execution-%d", i))))
+	}
+	<-delegate.started
+	<-delegate.started
+
+	// the third execution waits although there is capacity for it
+	require.Never(t, func() bool { return len(delegate.started) > 0 }, 100*time.Millisecond, 10*time.Millisecond)
+	require.Len(t, buffer.RunningExecutions(), 2)
+	require.Len(t, buffer.EnqueuedExecutions(), 1)
+	require.Equal(t, 3, buffer.ExecutionCount())
+
+	// and starts once one of the running executions is done
+	delegate.release <- struct{}{}
+	select {
+	case id := <-delegate.started:
+		require.Equal(t, "execution-2", id)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the enqueued execution was not started")
+	}
+	require.Eventually(t, func() bool { return buffer.ExecutionCount() == 2 }, 5*time.Second, 10*time.Millisecond)
+	require.Empty(t, buffer.EnqueuedExecutions())
+}
+
+func TestServiceBufferNoMaxConcurrentJobs(t *testing.T) {
+	ctx := context.Background()
+	delegate := newBlockingService()
+	buffer := newTestServiceBuffer(delegate, 0)
+	defer close(delegate.release)
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, buffer.Run(ctx, testExecution(fmt.Sprintf("execution-%d", i))))
+	}
+	for i := 0; i < 3; i++ {
+		<-delegate.started
+	}
+	require.Len(t, buffer.RunningExecutions(), 3)
+	require.Empty(t, buffer.EnqueuedExecutions())
+}
+
+func TestServiceBufferEvictsSpotForExecutionSlot(t *testing.T) {
+	ctx := context.Background()
+	delegate := newBlockingService()
+	buffer := newTestServiceBuffer(delegate, 1)
+	defer close(delegate.release)
+
+	spot := testExecution("spot")
+	spot.Shard.Job.Deal.Class = model.JobClassSpot
+	require.NoError(t, buffer.Run(ctx, spot))
+	require.Equal(t, "spot", <-delegate.started)
+
+	// there is capacity for the standard execution, but not a slot
+	require.NoError(t, buffer.Run(ctx, testExecution("standard")))
+	select {
+	case id := <-delegate.started:
+		require.Equal(t, "standard", id)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the spot execution was not evicted for the standard one")
+	}
+	require.Eventually(t, func() bool {
+		running := buffer.RunningExecutions()
+		return len(running) == 1 && running[0].ID == "standard"
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -41,6 +41,12 @@ func (c ChainedCallback) OnPublishFailure(ctx context.Context, executionID strin
 	}
 }
 
+func (c ChainedCallback) OnPublishProgress(ctx context.Context, executionID string, progress PublishProgress) {
+	for _, callback := range c.callbacks {
+		callback.OnPublishProgress(ctx, executionID, progress)
+	}
+}
+
 func (c ChainedCallback) OnCancelSuccess(ctx context.Context, executionID string, result CancelResult) {
 	for _, callback := range c.callbacks {
 		callback.OnCancelSuccess(ctx, executionID, result)
@@ -71,6 +71,10 @@ func (s StateUpdateCallback) OnPublishFailure(ctx context.Context, executionID s
 	}
 }
 
+func (s StateUpdateCallback) OnPublishProgress(ctx context.Context, executionID string, progress PublishProgress) {
+	// the execution completed when its results were ready to be published
+}
+
 func (s StateUpdateCallback) OnCancelSuccess(ctx context.Context, executionID string, result CancelResult) {
 	err := s.executionStore.UpdateExecutionState(ctx, store.UpdateExecutionStateRequest{
 		ExecutionID: executionID,
@@ -0,0 +1,58 @@
+package backend
+
+import (
+	"context"
+	"time"
+
+	"github.com/filecoin-project/bacalhau/pkg/compute/store"
+	"github.com/filecoin-project/bacalhau/pkg/logger"
+	"github.com/filecoin-project/bacalhau/pkg/publisher"
+	"github.com/rs/zerolog/log"
+)
+
+// How many times the results of jobs that publish asynchronously are tried
+// to be published, and how long to wait before the first retry by default,
+// which doubles after every failed attempt.
+const (
+	asyncPublishAttempts            = 5
+	defaultAsyncPublishRetryBackoff = 30 * time.Second
+)
+
+// publishAsynchronously publishes the results of an execution that has
+// already completed in the background, retrying failed attempts, and reports
+// how it goes through the callback. The results stay where the verifier put
+// them until the node shuts down.
+func (s BaseService) publishAsynchronously(
+	execution store.Execution,
+	jobPublisher publisher.Publisher,
+	resultFolder string,
+) {
+	// publishing outlives the handling of the event that triggered it
+	ctx := logger.ContextWithJobShardLogger(context.Background(), execution.Shard.Job.ID, execution.Shard.Index)
+	backoff := s.asyncPublishRetryBackoff
+	for attempt := 1; ; attempt++ {
+		publishedResult, err := jobPublisher.PublishShardResult(ctx, execution.Shard, s.ID, resultFolder)
+		if err == nil {
+			log.Ctx(ctx).Debug().Msgf("Published execution %s in the background", execution.ID)
+			s.callback.OnPublishProgress(ctx, execution.ID, PublishProgress{
+				Attempt:       attempt,
+				Done:          true,
+				PublishResult: publishedResult,
+			})
+			return
+		}
+
+		done := attempt >= asyncPublishAttempts
+		log.Ctx(ctx).Warn().Err(err).Bool("GivingUp", done).Msgf("Failed to publish execution %s", execution.ID)
+		s.callback.OnPublishProgress(ctx, execution.ID, PublishProgress{
+			Attempt: attempt,
+			Err:     err,
+			Done:    done,
+		})
+		if done {
+			return
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
@@ -0,0 +1,134 @@
+//go:build unit || !integration
+
+package backend
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/bacalhau/pkg/compute/store"
+	"github.com/filecoin-project/bacalhau/pkg/compute/store/inmemory"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/publisher"
+	"github.com/filecoin-project/bacalhau/pkg/system"
+	"github.com/filecoin-project/bacalhau/pkg/verifier/noop"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyPublisher fails to publish the results the first failures times.
+type flakyPublisher struct {
+	failures int
+	attempts int
+}
+
+func (p *flakyPublisher) IsInstalled(context.Context) (bool, error) {
+	return true, nil
+}
+
+func (p *flakyPublisher) PublishShardResult(context.Context, model.JobShard, string, string) (model.StorageSpec, error) {
+	p.attempts++
+	if p.attempts <= p.failures {
+		return model.StorageSpec{}, errors.New("publisher unavailable")
+	}
+	return model.StorageSpec{StorageSource: model.StorageSourceIPFS, CID: "cid"}, nil
+}
+
+// publishCallback records how the publishing of the results goes.
+type publishCallback struct {
+	ChainedCallback
+	published chan PublishResult
+	progress  chan PublishProgress
+}
+
+func (c publishCallback) OnPublishSuccess(_ context.Context, _ string, result PublishResult) {
+	c.published <- result
+}
+
+func (c publishCallback) OnPublishProgress(_ context.Context, _ string, progress PublishProgress) {
+	c.progress <- progress
+}
+
+func newAsyncPublishService(t *testing.T, jobPublisher publisher.Publisher) (*BaseService, publishCallback, store.Execution) {
+	ctx := context.Background()
+	cm := system.NewCleanupManager()
+	t.Cleanup(cm.Cleanup)
+	noopVerifier, err := noop.NewNoopVerifier(ctx, cm, nil)
+	require.NoError(t, err)
+
+	callback := publishCallback{
+		published: make(chan PublishResult, 1),
+		progress:  make(chan PublishProgress, asyncPublishAttempts),
+	}
+	executionStore := inmemory.NewStore()
+	service := NewBaseService(BaseServiceParams{
+		ID:        "node-id",
+		Callback:  callback,
+		Store:     executionStore,
+		Verifiers: noop.NewNoopVerifierProvider(noopVerifier),
+		Publishers: publisher.NewMappedPublisherProvider(map[model.Publisher]publisher.Publisher{
+			model.PublisherNoop: jobPublisher,
+		}),
+		AsyncPublishRetryBackoff: time.Millisecond,
+	})
+
+	execution := *store.NewExecution("execution-id", model.JobShard{Job: &model.Job{
+		ID: "job-id",
+		Spec: model.Spec{
+			Verifier:              model.VerifierNoop,
+			Publisher:             model.PublisherNoop,
+			PublishAsynchronously: true,
+		},
+	}}, model.ResourceUsageData{})
+	require.NoError(t, executionStore.CreateExecution(ctx, execution))
+	require.NoError(t, executionStore.UpdateExecutionState(ctx, store.UpdateExecutionStateRequest{
+		ExecutionID: execution.ID,
+		NewState:    store.ExecutionStateResultAccepted,
+	}))
+	return service, callback, execution
+}
+
+func nextProgress(t *testing.T, callback publishCallback) PublishProgress {
+	select {
+	case progress := <-callback.progress:
+		return progress
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "no progress was reported")
+		return PublishProgress{}
+	}
+}
+
+func TestPublishAsynchronouslyRetries(t *testing.T) {
+	jobPublisher := &flakyPublisher{failures: 1}
+	service, callback, execution := newAsyncPublishService(t, jobPublisher)
+
+	require.NoError(t, service.Publish(context.Background(), execution))
+	// the shard completes before the results are published
+	require.True(t, (<-callback.published).Asynchronous)
+
+	progress := nextProgress(t, callback)
+	require.Equal(t, 1, progress.Attempt)
+	require.ErrorContains(t, progress.Err, "publisher unavailable")
+	require.False(t, progress.Done)
+
+	progress = nextProgress(t, callback)
+	require.Equal(t, 2, progress.Attempt)
+	require.NoError(t, progress.Err)
+	require.True(t, progress.Done)
+	require.Equal(t, "cid", progress.PublishResult.CID)
+}
+
+func TestPublishAsynchronouslyGivesUp(t *testing.T) {
+	jobPublisher := &flakyPublisher{failures: asyncPublishAttempts}
+	service, callback, execution := newAsyncPublishService(t, jobPublisher)
+
+	service.publishAsynchronously(execution, jobPublisher, t.TempDir())
+	require.Equal(t, asyncPublishAttempts, jobPublisher.attempts)
+	for attempt := 1; attempt <= asyncPublishAttempts; attempt++ {
+		progress := nextProgress(t, callback)
+		require.Equal(t, attempt, progress.Attempt)
+		require.Error(t, progress.Err)
+		require.Equal(t, attempt == asyncPublishAttempts, progress.Done)
+	}
+}
@@ -2,11 +2,19 @@ package backend
 
 import (
 	"context"
+	"fmt"
 	"strconv"
+	"time"
 
+	"github.com/c2h5oh/datasize"
+	"github.com/filecoin-project/bacalhau/pkg/compute/capacity/disk"
+	"github.com/filecoin-project/bacalhau/pkg/compute/secrets"
 	"github.com/filecoin-project/bacalhau/pkg/compute/store"
 	"github.com/filecoin-project/bacalhau/pkg/executor"
+	"github.com/filecoin-project/bacalhau/pkg/logger"
 	"github.com/filecoin-project/bacalhau/pkg/publisher"
+	"github.com/filecoin-project/bacalhau/pkg/storage"
+	"github.com/filecoin-project/bacalhau/pkg/util/manifest"
 	"github.com/filecoin-project/bacalhau/pkg/verifier"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rs/zerolog/log"
@@ -19,6 +27,19 @@ type BaseServiceParams struct {
 	Executors  executor.ExecutorProvider
 	Verifiers  verifier.VerifierProvider
 	Publishers publisher.PublisherProvider
+	// where the secrets of jobs are fetched from when they run, if anywhere
+	Secrets secrets.Provider
+	// which of the secrets jobs may request
+	SecretsPolicy secrets.Policy
+	// the most the outputs of an execution can add up to for their contents
+	// to be included in its result. None are included if zero.
+	MaxInlineResultsSize uint64
+	// what the results of jobs that read the local directories of the node
+	// are restricted to before they are published
+	OutputPolicy executor.OutputPolicy
+	// how long to wait before retrying to publish results in the background
+	// the first time, 30 seconds if zero
+	AsyncPublishRetryBackoff time.Duration
 }
 
 // BaseService is the base implementation for backend service.
@@ -30,9 +51,21 @@ type BaseService struct {
 	executors  executor.ExecutorProvider
 	verifiers  verifier.VerifierProvider
 	publishers publisher.PublisherProvider
+	secrets    secrets.Provider
+	// which of the secrets jobs may request
+	secretsPolicy secrets.Policy
+	// the most the outputs of an execution can add up to for their contents
+	// to be included in its result
+	maxInlineResultsSize     uint64
+	outputPolicy             executor.OutputPolicy
+	asyncPublishRetryBackoff time.Duration
 }
 
 func NewBaseService(params BaseServiceParams) *BaseService {
+	asyncPublishRetryBackoff := params.AsyncPublishRetryBackoff
+	if asyncPublishRetryBackoff == 0 {
+		asyncPublishRetryBackoff = defaultAsyncPublishRetryBackoff
+	}
 	return &BaseService{
 		ID:         params.ID,
 		callback:   params.Callback,
@@ -40,11 +73,18 @@ func NewBaseService(params BaseServiceParams) *BaseService {
 		executors:  params.Executors,
 		verifiers:  params.Verifiers,
 		publishers: params.Publishers,
+		secrets:    params.Secrets,
+
+		secretsPolicy:            params.SecretsPolicy,
+		maxInlineResultsSize:     params.MaxInlineResultsSize,
+		outputPolicy:             params.OutputPolicy,
+		asyncPublishRetryBackoff: asyncPublishRetryBackoff,
 	}
 }
 
 // Run the execution of a shard after it has been accepted, and propose a result to the requester to be verified.
 func (s BaseService) Run(ctx context.Context, execution store.Execution) (err error) {
+	ctx = logger.ContextWithJobShardLogger(ctx, execution.Shard.Job.ID, execution.Shard.Index)
 	defer func() {
 		if err != nil {
 			s.callback.OnRunFailure(ctx, execution.ID, err)
@@ -75,7 +115,18 @@ func (s BaseService) Run(ctx context.Context, execution store.Execution) (err er
 	if err != nil {
 		return
 	}
-	runCommandResult, err := jobExecutor.RunShard(ctx, execution.Shard, resultFolder)
+	if err = s.checkInputsSize(ctx, execution); err != nil {
+		return
+	}
+	// nor may transforming the inputs, e.g. decompressing them, take up more
+	ctx = storage.ContextWithDiskBudget(ctx, storage.NewDiskBudget(execution.ResourceUsage.Disk))
+	// the environment is only added to the shard the executor runs, so that
+	// secrets don't end up in the store or in results
+	env, err := secrets.Env(ctx, s.secrets, s.secretsPolicy, execution.Shard.Job)
+	if err != nil {
+		return
+	}
+	runCommandResult, err := jobExecutor.RunShard(ctx, executor.WithEnv(execution.Shard, env), resultFolder)
 	if err != nil {
 		jobsFailed.With(prometheus.Labels{
 			"node_id":     s.ID,
@@ -94,6 +145,32 @@ func (s BaseService) Run(ctx context.Context, execution store.Execution) (err er
 		return
 	}
 
+	// only the selected artifacts of the outputs are verified and published
+	if err = executor.SelectOutputArtifacts(resultFolder, execution.Shard.Job.Spec.Outputs); err != nil {
+		return
+	}
+	// and only if the owner of the local directories the job read allows it
+	if s.outputPolicy.Applies(execution.Shard.Job.Spec) {
+		if err = s.outputPolicy.Check(ctx, resultFolder); err != nil {
+			return
+		}
+	}
+	if s.maxInlineResultsSize > 0 && runCommandResult != nil {
+		runCommandResult.Outputs, err = executor.ReadInlineOutputs(
+			resultFolder, execution.Shard.Job.Spec.Outputs, s.maxInlineResultsSize)
+		if err != nil {
+			return
+		}
+	}
+	// the manifest is verified and published with the results
+	manifestFiles, err := manifest.Write(resultFolder)
+	if err != nil {
+		return
+	}
+	if runCommandResult != nil {
+		runCommandResult.Manifest = manifestFiles
+	}
+
 	shardProposal, err := jobVerifier.GetShardProposal(ctx, execution.Shard, resultFolder)
 	if err != nil {
 		return
@@ -106,8 +183,26 @@ func (s BaseService) Run(ctx context.Context, execution store.Execution) (err er
 	return err
 }
 
+// checkInputsSize fails fast, before any input is fetched, if the inputs no longer fit in the disk space reserved for
+// the execution, e.g. because their size wasn't known in time when bidding.
+func (s BaseService) checkInputsSize(ctx context.Context, execution store.Execution) error {
+	if execution.ResourceUsage.Disk == 0 || len(execution.Shard.Job.Spec.Inputs) == 0 {
+		return nil
+	}
+	inputsSize, err := disk.GetShardInputsSize(ctx, s.executors, *execution.Shard.Job)
+	if err != nil {
+		return fmt.Errorf("error getting the size of the inputs: %w", err)
+	}
+	if inputsSize > execution.ResourceUsage.Disk {
+		return fmt.Errorf("inputs take up %s, more than the %s of disk reserved for the job",
+			datasize.ByteSize(inputsSize).HumanReadable(), datasize.ByteSize(execution.ResourceUsage.Disk).HumanReadable())
+	}
+	return nil
+}
+
 // Publish the result of a shard execution after it has been verified.
 func (s BaseService) Publish(ctx context.Context, execution store.Execution) (err error) {
+	ctx = logger.ContextWithJobShardLogger(ctx, execution.Shard.Job.ID, execution.Shard.Index)
 	defer func() {
 		if err != nil {
 			s.callback.OnPublishFailure(ctx, execution.ID, err)
@@ -134,6 +229,12 @@ func (s BaseService) Publish(ctx context.Context, execution store.Execution) (er
 	if err != nil {
 		return
 	}
+	if execution.Shard.Job.Spec.PublishAsynchronously {
+		// the shard completes now, with the results ready on this node
+		s.callback.OnPublishSuccess(ctx, execution.ID, PublishResult{Asynchronous: true})
+		go s.publishAsynchronously(execution, jobPublisher, resultFolder)
+		return nil
+	}
 	publishedResult, err := jobPublisher.PublishShardResult(ctx, execution.Shard, s.ID, resultFolder)
 	if err != nil {
 		return
@@ -146,6 +247,7 @@ func (s BaseService) Publish(ctx context.Context, execution store.Execution) (er
 
 // Cancel the execution of a running shard.
 func (s BaseService) Cancel(ctx context.Context, execution store.Execution) (err error) {
+	ctx = logger.ContextWithJobShardLogger(ctx, execution.Shard.Job.ID, execution.Shard.Index)
 	defer func() {
 		if err != nil {
 			s.callback.OnCancelFailure(ctx, execution.ID, err)
@@ -24,6 +24,7 @@ type Callback interface {
 	OnRunFailure(ctx context.Context, executionID string, err error)
 	OnPublishSuccess(ctx context.Context, executionID string, result PublishResult)
 	OnPublishFailure(ctx context.Context, executionID string, err error)
+	OnPublishProgress(ctx context.Context, executionID string, progress PublishProgress)
 	OnCancelSuccess(ctx context.Context, executionID string, result CancelResult)
 	OnCancelFailure(ctx context.Context, executionID string, err error)
 }
@@ -37,6 +38,20 @@ type RunResult struct {
 // PublishResult Result of a job publish that is returned to the caller through a Callback.
 type PublishResult struct {
 	PublishResult model.StorageSpec
+	// Whether the results are ready to be published in the background, rather than published.
+	Asynchronous bool
+}
+
+// PublishProgress Progress of publishing the results of a job in the background, after the execution has completed,
+// that is returned to the caller through a Callback.
+type PublishProgress struct {
+	// The attempt to publish the results, from 1.
+	Attempt int
+	// Why the attempt failed, if it did.
+	Err error
+	// Whether there are no more attempts, either because the results were published or because they never will be.
+	Done          bool
+	PublishResult model.StorageSpec
 }
 
 // CancelResult Result of a job cancel that is returned to the caller through a Callback.
@@ -0,0 +1,88 @@
+//go:build unit || !integration
+
+package bidstrategy
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/bacalhau/pkg/compute/capacity"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+)
+
+// the number of jobs already running on the node in the benchmarks
+var benchmarkConcurrentJobs = []int{1000, 10000}
+
+var benchmarkJobUsage = model.ResourceUsageData{CPU: 0.1, Memory: 1024 * 1024}
+
+// newBenchmarkStrategy returns the strategies a compute node asks before
+// bidding, with the given number of jobs already holding capacity and room
+// left for more.
+func newBenchmarkStrategy(b *testing.B, concurrentJobs int) BidStrategy {
+	ctx := context.Background()
+	tracker := capacity.NewLocalTracker(capacity.LocalTrackerParams{
+		MaxCapacity: benchmarkJobUsage.Multi(float64(2 * concurrentJobs)),
+	})
+	for i := 0; i < concurrentJobs; i++ {
+		if !tracker.AddIfHasCapacity(ctx, benchmarkJobUsage) {
+			b.Fatalf("could not reserve capacity for job %d", i)
+		}
+	}
+	return NewChainedBidStrategy(
+		NewMaxCapacityStrategy(MaxCapacityStrategyParams{
+			MaxJobRequirements: benchmarkJobUsage.Multi(10),
+		}),
+		NewAvailableCapacityStrategy(AvailableCapacityStrategyParams{
+			CapacityTracker: tracker,
+			CommitFactor:    1,
+		}),
+		NewTimeoutStrategy(TimeoutStrategyParams{
+			MaxJobExecutionTimeout: time.Hour,
+		}),
+		NewStatelessJobStrategy(StatelessJobStrategyParams{}),
+	)
+}
+
+func shouldBid(b *testing.B, strategy BidStrategy, request BidStrategyRequest) {
+	ctx := context.Background()
+	response, err := strategy.ShouldBid(ctx, request)
+	if err != nil || !response.ShouldBid {
+		b.Fatalf("expected to bid, got %+v, %v", response, err)
+	}
+	response, err = strategy.ShouldBidBasedOnUsage(ctx, request, benchmarkJobUsage)
+	if err != nil || !response.ShouldBid {
+		b.Fatalf("expected to bid based on usage, got %+v, %v", response, err)
+	}
+}
+
+// BenchmarkBidDecision measures how long a compute node takes to decide
+// whether to bid on a job, one job at a time and with many jobs asked about
+// at once.
+func BenchmarkBidDecision(b *testing.B) {
+	for _, concurrentJobs := range benchmarkConcurrentJobs {
+		request := getBidStrategyRequest()
+		request.Job.Spec.Timeout = time.Minute.Seconds()
+
+		b.Run(fmt.Sprintf("jobs=%d", concurrentJobs), func(b *testing.B) {
+			strategy := newBenchmarkStrategy(b, concurrentJobs)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				shouldBid(b, strategy, request)
+			}
+		})
+
+		b.Run(fmt.Sprintf("jobs=%d/parallel", concurrentJobs), func(b *testing.B) {
+			strategy := newBenchmarkStrategy(b, concurrentJobs)
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					shouldBid(b, strategy, request)
+				}
+			})
+		})
+	}
+}
@@ -10,17 +10,21 @@ import (
 type AvailableCapacityStrategyParams struct {
 	CapacityTracker capacity.Tracker
 	CommitFactor    float64
+	// Optional, to count the capacity used by spot jobs as available to standard jobs, which can evict them.
+	SpotTracker capacity.SpotTracker
 }
 
 type AvailableCapacityStrategy struct {
 	capacityTracker capacity.Tracker
 	commitFactor    float64
+	spotTracker     capacity.SpotTracker
 }
 
 func NewAvailableCapacityStrategy(params AvailableCapacityStrategyParams) *AvailableCapacityStrategy {
 	return &AvailableCapacityStrategy{
 		capacityTracker: params.CapacityTracker,
 		commitFactor:    params.CommitFactor,
+		spotTracker:     params.SpotTracker,
 	}
 }
 
@@ -32,8 +36,20 @@ func (s *AvailableCapacityStrategy) ShouldBid(
 func (s *AvailableCapacityStrategy) ShouldBidBasedOnUsage(
 	ctx context.Context, request BidStrategyRequest, usage model.ResourceUsageData) (BidStrategyResponse, error) {
 	// skip bidding if we don't have enough capacity available
-	availableCapacity := s.capacityTracker.AvailableCapacity(ctx).Multi(s.commitFactor)
-	if !usage.LessThanEq(availableCapacity) {
+	availableCapacity := s.capacityTracker.AvailableCapacity(ctx)
+	if s.spotTracker != nil {
+		if request.Job.Deal.IsSpot() {
+			if !usage.LessThanEq(s.spotTracker.AvailableSpotCapacity(ctx).Multi(s.commitFactor)) {
+				return BidStrategyResponse{
+					ShouldBid: false,
+					Reason:    "not enough capacity available for spot jobs",
+				}, nil
+			}
+		} else {
+			availableCapacity = availableCapacity.Add(s.spotTracker.UsedSpotCapacity(ctx))
+		}
+	}
+	if !usage.LessThanEq(availableCapacity.Multi(s.commitFactor)) {
 		return BidStrategyResponse{
 			ShouldBid: false,
 			Reason:    "not enough capacity available",
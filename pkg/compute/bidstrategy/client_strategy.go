@@ -0,0 +1,75 @@
+package bidstrategy
+
+import (
+	"context"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/system"
+)
+
+type ClientStrategyParams struct {
+	// IDs or base64-encoded public keys of the only clients to bid on the
+	// jobs of, any client if empty.
+	AllowedClients []string
+	// IDs or base64-encoded public keys of clients not to bid on the jobs of.
+	DeniedClients []string
+	// Whether not to bid on jobs that don't say which client submitted them.
+	RejectAnonymousJobs bool
+}
+
+// ClientStrategy only bids on the jobs of some clients, so that private
+// clusters don't have to rely on network isolation alone to keep other
+// clients' jobs off their nodes.
+type ClientStrategy struct {
+	allowed         map[string]bool
+	denied          map[string]bool
+	rejectAnonymous bool
+}
+
+func NewClientStrategy(params ClientStrategyParams) *ClientStrategy {
+	return &ClientStrategy{
+		allowed:         clientIDs(params.AllowedClients),
+		denied:          clientIDs(params.DeniedClients),
+		rejectAnonymous: params.RejectAnonymousJobs,
+	}
+}
+
+func (s *ClientStrategy) ShouldBid(_ context.Context, request BidStrategyRequest) (BidStrategyResponse, error) {
+	clientID := request.Job.ClientID
+	if clientID == "" {
+		if s.rejectAnonymous || len(s.allowed) > 0 {
+			return BidStrategyResponse{
+				ShouldBid: false,
+				Reason:    "jobs that don't say which client submitted them are not accepted",
+			}, nil
+		}
+		return newShouldBidResponse(), nil
+	}
+	if s.denied[clientID] {
+		return BidStrategyResponse{ShouldBid: false, Reason: "jobs of client " + clientID + " are not accepted"}, nil
+	}
+	if len(s.allowed) > 0 && !s.allowed[clientID] {
+		return BidStrategyResponse{ShouldBid: false, Reason: "only the jobs of allowed clients are accepted"}, nil
+	}
+	return newShouldBidResponse(), nil
+}
+
+func (s *ClientStrategy) ShouldBidBasedOnUsage(
+	_ context.Context, _ BidStrategyRequest, _ model.ResourceUsageData) (BidStrategyResponse, error) {
+	return newShouldBidResponse(), nil
+}
+
+// clientIDs returns the client IDs of a list of client IDs and public keys.
+func clientIDs(clients []string) map[string]bool {
+	ids := make(map[string]bool, len(clients))
+	for _, client := range clients {
+		if id, err := system.ClientIDFromPublicKey(client); err == nil {
+			client = id
+		}
+		ids[client] = true
+	}
+	return ids
+}
+
+// Compile-time check to ensure ClientStrategy implements the BidStrategy interface.
+var _ BidStrategy = (*ClientStrategy)(nil)
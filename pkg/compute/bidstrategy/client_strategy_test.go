@@ -0,0 +1,59 @@
+//go:build unit || !integration
+
+package bidstrategy
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"testing"
+
+	"github.com/filecoin-project/bacalhau/pkg/system"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientStrategy(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	publicKey := base64.StdEncoding.EncodeToString(x509.MarshalPKCS1PublicKey(&key.PublicKey))
+	keyClientID, err := system.ClientIDFromPublicKey(publicKey)
+	require.NoError(t, err)
+
+	allow := func(clients ...string) ClientStrategyParams { return ClientStrategyParams{AllowedClients: clients} }
+	deny := func(clients ...string) ClientStrategyParams { return ClientStrategyParams{DeniedClients: clients} }
+	rejectAnonymous := ClientStrategyParams{RejectAnonymousJobs: true}
+
+	for _, testCase := range []struct {
+		name     string
+		params   ClientStrategyParams
+		clientID string
+		bid      bool
+	}{
+		{name: "no policy", clientID: "client", bid: true},
+		{name: "no policy anonymous", bid: true},
+		{name: "reject anonymous", params: rejectAnonymous},
+		{name: "reject anonymous client", params: rejectAnonymous, clientID: "client", bid: true},
+		{name: "allowed by id", params: allow("client"), clientID: "client", bid: true},
+		{name: "allowed by key", params: allow(publicKey), clientID: keyClientID, bid: true},
+		{name: "not allowed", params: allow(publicKey), clientID: "client"},
+		{name: "anonymous not allowed", params: allow("client")},
+		{name: "denied by id", params: deny("client"), clientID: "client"},
+		{name: "denied by key", params: deny(publicKey), clientID: keyClientID},
+		{name: "not denied", params: deny(publicKey), clientID: "client", bid: true},
+		{
+			name:     "denied and allowed",
+			params:   ClientStrategyParams{AllowedClients: []string{"client"}, DeniedClients: []string{"client"}},
+			clientID: "client",
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			request := getBidStrategyRequest()
+			request.Job.ClientID = testCase.clientID
+			response, err := NewClientStrategy(testCase.params).ShouldBid(context.Background(), request)
+			require.NoError(t, err)
+			require.Equal(t, testCase.bid, response.ShouldBid, response.Reason)
+		})
+	}
+}
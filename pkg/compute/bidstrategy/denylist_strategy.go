@@ -0,0 +1,137 @@
+package bidstrategy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/util/closer"
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultDenylistRefreshInterval is how often the denylist is fetched again.
+const DefaultDenylistRefreshInterval = time.Minute
+
+type DenylistStrategyParams struct {
+	// The URL of the denylist to subscribe to, such as the /denylist
+	// endpoint of a requester node, none if empty.
+	URL string
+	// How often the denylist is fetched again, every minute if zero.
+	RefreshInterval time.Duration
+}
+
+// DenylistStrategy doesn't bid on the jobs, or any job of the clients, that
+// the moderators of a network have flagged as abusive. The denylist is
+// fetched when a job is to be bid on, at most once per refresh interval, and
+// the last one fetched is kept when it can't be, so that jobs are still bid
+// on when the denylist is unreachable.
+type DenylistStrategy struct {
+	url             string
+	refreshInterval time.Duration
+	client          *http.Client
+
+	mutex     sync.Mutex
+	fetchedAt time.Time
+	clients   map[string]string
+	jobs      map[string]string
+}
+
+func NewDenylistStrategy(params DenylistStrategyParams) *DenylistStrategy {
+	refreshInterval := params.RefreshInterval
+	if refreshInterval == 0 {
+		refreshInterval = DefaultDenylistRefreshInterval
+	}
+	return &DenylistStrategy{
+		url:             params.URL,
+		refreshInterval: refreshInterval,
+		client:          &http.Client{Timeout: 10 * time.Second},
+		clients:         map[string]string{},
+		jobs:            map[string]string{},
+	}
+}
+
+func (s *DenylistStrategy) ShouldBid(ctx context.Context, request BidStrategyRequest) (BidStrategyResponse, error) {
+	if s.url == "" {
+		return newShouldBidResponse(), nil
+	}
+
+	s.mutex.Lock()
+	due := time.Since(s.fetchedAt) >= s.refreshInterval
+	if due {
+		// try again after the interval either way, rather than on every bid
+		s.fetchedAt = time.Now()
+	}
+	s.mutex.Unlock()
+
+	// fetch without holding the lock, so that other bids aren't held up by it
+	if due {
+		clients, jobs, err := s.fetch(ctx)
+		if err != nil {
+			log.Ctx(ctx).Warn().Err(err).Msgf("could not fetch the denylist from %s, keeping the last one", s.url)
+		} else {
+			s.mutex.Lock()
+			s.clients, s.jobs = clients, jobs
+			s.mutex.Unlock()
+		}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if reason, ok := s.jobs[request.Job.ID]; ok {
+		return BidStrategyResponse{ShouldBid: false, Reason: flaggedReason("job "+request.Job.ID, reason)}, nil
+	}
+	clientID := request.Job.ClientID
+	if reason, ok := s.clients[clientID]; ok && clientID != "" {
+		return BidStrategyResponse{ShouldBid: false, Reason: flaggedReason("client "+clientID, reason)}, nil
+	}
+	return newShouldBidResponse(), nil
+}
+
+func (s *DenylistStrategy) ShouldBidBasedOnUsage(
+	_ context.Context, _ BidStrategyRequest, _ model.ResourceUsageData) (BidStrategyResponse, error) {
+	return newShouldBidResponse(), nil
+}
+
+// fetch returns the reasons the clients and jobs of the denylist are flagged.
+func (s *DenylistStrategy) fetch(ctx context.Context) (clients, jobs map[string]string, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	res, err := s.client.Do(req) //nolint:bodyclose // closed below
+	if err != nil {
+		return nil, nil, err
+	}
+	defer closer.DrainAndCloseWithLogOnError(ctx, "denylist response", res.Body)
+	if res.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("%s returned %d status code", s.url, res.StatusCode)
+	}
+
+	var denylist model.Denylist
+	if err = json.NewDecoder(res.Body).Decode(&denylist); err != nil {
+		return nil, nil, fmt.Errorf("invalid denylist: %w", err)
+	}
+	return denylistReasons(denylist.Clients), denylistReasons(denylist.Jobs), nil
+}
+
+func denylistReasons(entries []model.DenylistEntry) map[string]string {
+	reasons := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		reasons[entry.ID] = entry.Reason
+	}
+	return reasons
+}
+
+func flaggedReason(target, reason string) string {
+	if reason == "" {
+		return target + " is flagged as abusive"
+	}
+	return fmt.Sprintf("%s is flagged as abusive: %s", target, reason)
+}
+
+// Compile-time check to ensure DenylistStrategy implements the BidStrategy interface.
+var _ BidStrategy = (*DenylistStrategy)(nil)
@@ -0,0 +1,95 @@
+//go:build unit || !integration
+
+package bidstrategy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDenylistStrategy(t *testing.T) {
+	var fail atomic.Bool
+	var fetches atomic.Int32
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		if fail.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(model.Denylist{
+			Clients: []model.DenylistEntry{{ID: "miner", Reason: "cryptomining"}},
+			Jobs:    []model.DenylistEntry{{ID: "scan"}},
+		})
+	}))
+	defer svr.Close()
+
+	strategy := NewDenylistStrategy(DenylistStrategyParams{URL: svr.URL, RefreshInterval: time.Hour})
+	shouldBid := func(jobID, clientID string) BidStrategyResponse {
+		request := BidStrategyRequest{}
+		request.Job.ID = jobID
+		request.Job.ClientID = clientID
+		response, err := strategy.ShouldBid(context.Background(), request)
+		require.NoError(t, err)
+		return response
+	}
+
+	require.True(t, shouldBid("job", "client").ShouldBid)
+	require.False(t, shouldBid("scan", "client").ShouldBid)
+	response := shouldBid("job", "miner")
+	require.False(t, response.ShouldBid)
+	require.Contains(t, response.Reason, "cryptomining")
+	require.Equal(t, int32(1), fetches.Load(), "the denylist is fetched once per refresh interval")
+
+	// the last denylist is kept when it can't be fetched again
+	fail.Store(true)
+	strategy.fetchedAt = time.Time{}
+	require.False(t, shouldBid("job", "miner").ShouldBid)
+	require.Equal(t, int32(2), fetches.Load())
+}
+
+func TestDenylistStrategyUnreachable(t *testing.T) {
+	strategy := NewDenylistStrategy(DenylistStrategyParams{URL: "http://127.0.0.1:0/denylist"})
+	response, err := strategy.ShouldBid(context.Background(), BidStrategyRequest{})
+	require.NoError(t, err)
+	require.True(t, response.ShouldBid)
+}
+
+func TestDenylistStrategyBidsWhileFetching(t *testing.T) {
+	fetching := make(chan struct{})
+	release := make(chan struct{})
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(fetching)
+		<-release
+		_ = json.NewEncoder(w).Encode(model.Denylist{})
+	}))
+	defer svr.Close()
+	defer close(release)
+
+	strategy := NewDenylistStrategy(DenylistStrategyParams{URL: svr.URL, RefreshInterval: time.Hour})
+	go func() {
+		_, _ = strategy.ShouldBid(context.Background(), BidStrategyRequest{})
+	}()
+	<-fetching
+
+	// other bids use the last denylist rather than waiting for the fetch
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		response, err := strategy.ShouldBid(context.Background(), BidStrategyRequest{})
+		require.NoError(t, err)
+		require.True(t, response.ShouldBid)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("bidding waited for the denylist to be fetched")
+	}
+}
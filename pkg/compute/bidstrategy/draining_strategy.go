@@ -0,0 +1,45 @@
+package bidstrategy
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+)
+
+// DrainingStrategy skips bidding once the node has started draining before it shuts down, so that it finishes the
+// jobs it already has without taking on new ones.
+type DrainingStrategy struct {
+	draining atomic.Bool
+}
+
+func NewDrainingStrategy() *DrainingStrategy {
+	return &DrainingStrategy{}
+}
+
+// Drain stops the node bidding on jobs.
+func (s *DrainingStrategy) Drain() {
+	s.draining.Store(true)
+}
+
+func (s *DrainingStrategy) IsDraining() bool {
+	return s.draining.Load()
+}
+
+func (s *DrainingStrategy) ShouldBid(ctx context.Context, request BidStrategyRequest) (BidStrategyResponse, error) {
+	if s.IsDraining() {
+		return BidStrategyResponse{
+			ShouldBid: false,
+			Reason:    "node is draining before shutting down",
+		}, nil
+	}
+	return newShouldBidResponse(), nil
+}
+
+func (s *DrainingStrategy) ShouldBidBasedOnUsage(
+	ctx context.Context, request BidStrategyRequest, usage model.ResourceUsageData) (BidStrategyResponse, error) {
+	return s.ShouldBid(ctx, request)
+}
+
+// compile-time interface check
+var _ BidStrategy = (*DrainingStrategy)(nil)
@@ -0,0 +1,33 @@
+//go:build unit || !integration
+
+package bidstrategy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDrainingStrategy(t *testing.T) {
+	ctx := context.Background()
+	request := getBidStrategyRequest()
+	strategy := NewDrainingStrategy()
+
+	result, err := strategy.ShouldBid(ctx, request)
+	require.NoError(t, err)
+	require.True(t, result.ShouldBid)
+
+	strategy.Drain()
+	require.True(t, strategy.IsDraining())
+
+	result, err = strategy.ShouldBid(ctx, request)
+	require.NoError(t, err)
+	require.False(t, result.ShouldBid)
+
+	// a bid that was started before draining is not accepted after it
+	result, err = strategy.ShouldBidBasedOnUsage(ctx, request, model.ResourceUsageData{})
+	require.NoError(t, err)
+	require.False(t, result.ShouldBid)
+}
@@ -0,0 +1,48 @@
+package bidstrategy
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+)
+
+// Explain asks a strategy whether it should bid on a job, and returns every
+// reason it should not. Unlike ShouldBid, the strategies of a chain are all
+// asked, rather than stopping at the first that should not bid, so that all
+// the problems with a job can be reported at once.
+func Explain(
+	ctx context.Context, strategy BidStrategy, request BidStrategyRequest, usage model.ResourceUsageData) ([]string, error) {
+	if chained, ok := strategy.(*ChainedBidStrategy); ok {
+		var reasons []string
+		for _, s := range chained.Strategies {
+			strategyReasons, err := Explain(ctx, s, request, usage)
+			if err != nil {
+				return nil, err
+			}
+			reasons = append(reasons, strategyReasons...)
+		}
+		return reasons, nil
+	}
+
+	var reasons []string
+	for _, shouldBid := range []func() (BidStrategyResponse, error){
+		func() (BidStrategyResponse, error) { return strategy.ShouldBid(ctx, request) },
+		func() (BidStrategyResponse, error) { return strategy.ShouldBidBasedOnUsage(ctx, request, usage) },
+	} {
+		response, err := shouldBid()
+		if err != nil {
+			return nil, fmt.Errorf("error asking bidding strategy %s if we should bid: %w",
+				reflect.TypeOf(strategy).String(), err)
+		}
+		if !response.ShouldBid {
+			reason := response.Reason
+			if reason == "" {
+				reason = fmt.Sprintf("rejected by %s", reflect.TypeOf(strategy).String())
+			}
+			reasons = append(reasons, reason)
+		}
+	}
+	return reasons, nil
+}
@@ -0,0 +1,33 @@
+//go:build unit || !integration
+
+package bidstrategy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplain(t *testing.T) {
+	ctx := context.Background()
+	request := getBidStrategyRequest()
+
+	strategy := NewChainedBidStrategy(
+		NewMaxCapacityStrategy(MaxCapacityStrategyParams{
+			MaxJobRequirements: model.ResourceUsageData{CPU: 1},
+		}),
+		NewStatelessJobStrategy(StatelessJobStrategyParams{RejectStatelessJobs: true}),
+		NewTimeoutStrategy(TimeoutStrategyParams{}),
+	)
+
+	// every strategy is asked, not just up to the first that rejects the job
+	reasons, err := Explain(ctx, strategy, request, model.ResourceUsageData{CPU: 2})
+	require.NoError(t, err)
+	require.Len(t, reasons, 2)
+
+	reasons, err = Explain(ctx, strategy, getBidStrategyRequestWithInput(), model.ResourceUsageData{CPU: 1})
+	require.NoError(t, err)
+	require.Empty(t, reasons)
+}
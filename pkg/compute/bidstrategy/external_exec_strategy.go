@@ -3,10 +3,10 @@ package bidstrategy
 import (
 	"context"
 	"fmt"
-	"os/exec"
 	"strings"
 
 	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/system"
 	"github.com/rs/zerolog/log"
 )
 
@@ -29,8 +29,6 @@ func (s *ExternalCommandStrategy) ShouldBid(ctx context.Context, request BidStra
 		return newShouldBidResponse(), nil
 	}
 
-	// TODO: Use context to trace exec call
-
 	data := getJobSelectionPolicyProbeData(request)
 	jsonData, err := model.JSONMarshalWithMax(data)
 
@@ -39,18 +37,20 @@ func (s *ExternalCommandStrategy) ShouldBid(ctx context.Context, request BidStra
 			fmt.Errorf("ExternalCommandStrategy: error marshaling job selection policy probe data: %w", err)
 	}
 
-	cmd := exec.Command("bash", "-c", s.command) //nolint:gosec
-	cmd.Env = []string{
-		"BACALHAU_JOB_SELECTION_PROBE_DATA=" + string(jsonData),
-	}
-	cmd.Stdin = strings.NewReader(string(jsonData))
-	err = cmd.Run()
+	result, err := system.RunCommand(ctx, system.Command{
+		Name: "bash",
+		Args: []string{"-c", s.command},
+		Env: []string{
+			"BACALHAU_JOB_SELECTION_PROBE_DATA=" + string(jsonData),
+		},
+		Stdin: strings.NewReader(string(jsonData)),
+	})
 	if err != nil {
 		// we ignore this error because it might be the script exiting 1 on purpose
 		log.Ctx(ctx).Debug().Msgf("We got an error back from a job selection probe exec: %s %s", s.command, err.Error())
 	}
 
-	exitCode := cmd.ProcessState.ExitCode()
+	exitCode := result.ExitCode
 	if exitCode == 0 {
 		return newShouldBidResponse(), nil
 	}
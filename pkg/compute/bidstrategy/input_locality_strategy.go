@@ -26,9 +26,20 @@ func NewInputLocalityStrategy(params InputLocalityStrategyParams) *InputLocality
 }
 
 func (s *InputLocalityStrategy) ShouldBid(ctx context.Context, request BidStrategyRequest) (BidStrategyResponse, error) {
-	// if we have an "anywhere" policy for the data then we accept the job
+	// if we have an "anywhere" policy for the data then we accept the job,
+	// unless it reads local directories, which only the nodes that have
+	// them can run
+	inputs := request.Job.Spec.Inputs
 	if s.locality == model.Anywhere {
-		return newShouldBidResponse(), nil
+		inputs = nil
+		for _, input := range request.Job.Spec.Inputs {
+			if input.StorageSource == model.StorageSourceLocalDirectory {
+				inputs = append(inputs, input)
+			}
+		}
+		if len(inputs) == 0 {
+			return newShouldBidResponse(), nil
+		}
 	}
 
 	// otherwise we are checking that all of the named inputs in the job
@@ -40,7 +51,7 @@ func (s *InputLocalityStrategy) ShouldBid(ctx context.Context, request BidStrate
 
 	foundInputs := 0
 
-	for _, input := range request.Job.Spec.Inputs {
+	for _, input := range inputs {
 		// see if the storage engine reports that we have the resource locally
 		hasStorage, err := e.HasStorageLocally(ctx, input)
 		if err != nil {
@@ -51,7 +62,7 @@ func (s *InputLocalityStrategy) ShouldBid(ctx context.Context, request BidStrate
 		}
 	}
 
-	if foundInputs >= len(request.Job.Spec.Inputs) {
+	if foundInputs >= len(inputs) {
 		return newShouldBidResponse(), nil
 	}
 	return BidStrategyResponse{ShouldBid: false, Reason: "not all inputs are local"}, nil
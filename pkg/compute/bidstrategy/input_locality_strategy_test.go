@@ -13,13 +13,21 @@ import (
 
 type InputLocalityStrategySuite struct {
 	suite.Suite
-	statelessJob BidStrategyRequest
-	statefulJob  BidStrategyRequest
+	statelessJob      BidStrategyRequest
+	statefulJob       BidStrategyRequest
+	localDirectoryJob BidStrategyRequest
 }
 
 func (s *InputLocalityStrategySuite) SetupSuite() {
 	s.statelessJob = getBidStrategyRequest()
 	s.statefulJob = getBidStrategyRequestWithInput()
+	s.localDirectoryJob = getBidStrategyRequest()
+	s.localDirectoryJob.Job.Spec.Inputs = []model.StorageSpec{
+		{
+			StorageSource: model.StorageSourceLocalDirectory,
+			URL:           "local://census",
+		},
+	}
 }
 
 func (s *InputLocalityStrategySuite) TestInputLocality() {
@@ -83,6 +91,24 @@ func (s *InputLocalityStrategySuite) TestInputLocality() {
 			true,
 			s.statelessJob,
 		},
+
+		// we are anywhere - we do have the local directory - we should accept
+		{
+			"anywhere mode -> have local directory -> should accept",
+			model.Anywhere,
+			true,
+			true,
+			s.localDirectoryJob,
+		},
+
+		// we are anywhere - we don't have the local directory - we should reject
+		{
+			"anywhere mode -> don't have local directory -> should reject",
+			model.Anywhere,
+			false,
+			false,
+			s.localDirectoryJob,
+		},
 	}
 
 	for _, test := range testCases {
@@ -0,0 +1,56 @@
+package bidstrategy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/c2h5oh/datasize"
+	"github.com/filecoin-project/bacalhau/pkg/compute/capacity"
+	"github.com/filecoin-project/bacalhau/pkg/compute/capacity/disk"
+	"github.com/filecoin-project/bacalhau/pkg/executor"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+)
+
+type InputSizeStrategyParams struct {
+	Executors executor.ExecutorProvider
+}
+
+// InputSizeStrategy skips bidding on jobs whose inputs are bigger than the disk space the job declared it needs, as
+// they would otherwise only fail once the download runs out of space.
+type InputSizeStrategy struct {
+	executors executor.ExecutorProvider
+}
+
+func NewInputSizeStrategy(params InputSizeStrategyParams) *InputSizeStrategy {
+	return &InputSizeStrategy{
+		executors: params.Executors,
+	}
+}
+
+func (s *InputSizeStrategy) ShouldBid(ctx context.Context, request BidStrategyRequest) (BidStrategyResponse, error) {
+	declaredDisk := capacity.ParseResourceUsageConfig(request.Job.Spec.Resources).Disk
+	if declaredDisk == 0 || len(request.Job.Spec.Inputs) == 0 {
+		return newShouldBidResponse(), nil
+	}
+
+	inputsSize, err := disk.GetShardInputsSize(ctx, s.executors, request.Job)
+	if err != nil {
+		return BidStrategyResponse{}, fmt.Errorf("InputSizeStrategy: failed to get the size of the inputs: %w", err)
+	}
+	if inputsSize > declaredDisk {
+		return BidStrategyResponse{
+			ShouldBid: false,
+			Reason: fmt.Sprintf("inputs take up %s, more than the %s of disk the job declared",
+				datasize.ByteSize(inputsSize).HumanReadable(), datasize.ByteSize(declaredDisk).HumanReadable()),
+		}, nil
+	}
+	return newShouldBidResponse(), nil
+}
+
+func (s *InputSizeStrategy) ShouldBidBasedOnUsage(
+	ctx context.Context, request BidStrategyRequest, usage model.ResourceUsageData) (BidStrategyResponse, error) {
+	return newShouldBidResponse(), nil
+}
+
+// compile-time interface check
+var _ BidStrategy = (*InputSizeStrategy)(nil)
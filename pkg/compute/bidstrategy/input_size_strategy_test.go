@@ -0,0 +1,47 @@
+//go:build unit || !integration
+
+package bidstrategy
+
+import (
+	"context"
+	"testing"
+
+	noop_executor "github.com/filecoin-project/bacalhau/pkg/executor/noop"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInputSizeStrategy(t *testing.T) {
+	testCases := []struct {
+		name              string
+		declaredDisk      string
+		inputSize         uint64
+		request           BidStrategyRequest
+		expectedShouldBid bool
+	}{
+		{"no declared disk -> should accept", "", 2 * 1024 * 1024, getBidStrategyRequestWithInput(), true},
+		{"no inputs -> should accept", "1Mb", 2 * 1024 * 1024, getBidStrategyRequest(), true},
+		{"inputs fit -> should accept", "1Mb", 1024, getBidStrategyRequestWithInput(), true},
+		{"inputs too big -> should reject", "1Mb", 2 * 1024 * 1024, getBidStrategyRequestWithInput(), false},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			executor := noop_executor.NewNoopExecutorWithConfig(noop_executor.ExecutorConfig{
+				ExternalHooks: noop_executor.ExecutorConfigExternalHooks{
+					GetVolumeSize: func(ctx context.Context, volume model.StorageSpec) (uint64, error) {
+						return test.inputSize, nil
+					},
+				},
+			})
+			strategy := NewInputSizeStrategy(InputSizeStrategyParams{
+				Executors: noop_executor.NewNoopExecutorProvider(executor),
+			})
+			request := test.request
+			request.Job.Spec.Resources.Disk = test.declaredDisk
+			result, err := strategy.ShouldBid(context.Background(), request)
+			require.NoError(t, err)
+			require.Equal(t, test.expectedShouldBid, result.ShouldBid, result.Reason)
+		})
+	}
+}
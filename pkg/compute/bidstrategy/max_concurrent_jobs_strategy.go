@@ -0,0 +1,61 @@
+package bidstrategy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/filecoin-project/bacalhau/pkg/compute/backend"
+	"github.com/filecoin-project/bacalhau/pkg/compute/store"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+)
+
+type MaxConcurrentJobsStrategyParams struct {
+	// No limit if zero.
+	MaxConcurrentJobs int
+	BackendBuffer     *backend.ServiceBuffer
+	ExecutionStore    store.ExecutionStore
+}
+
+// MaxConcurrentJobsStrategy skips bidding when the node already has as many executions running or enqueued, or bid
+// on and waiting for the bid to be accepted, as it is allowed to run at once. Unlike the available capacity, the count
+// is not over committed, so that the node never accepts more jobs than it is configured to run. As each shard bid on
+// counts, the strategy is to be checked again for each shard of a job, see frontend.BaseServiceParams.
+type MaxConcurrentJobsStrategy struct {
+	maxConcurrentJobs int
+	backendBuffer     *backend.ServiceBuffer
+	executionStore    store.ExecutionStore
+}
+
+func NewMaxConcurrentJobsStrategy(params MaxConcurrentJobsStrategyParams) *MaxConcurrentJobsStrategy {
+	return &MaxConcurrentJobsStrategy{
+		maxConcurrentJobs: params.MaxConcurrentJobs,
+		backendBuffer:     params.BackendBuffer,
+		executionStore:    params.ExecutionStore,
+	}
+}
+
+func (s *MaxConcurrentJobsStrategy) ShouldBid(ctx context.Context, request BidStrategyRequest) (BidStrategyResponse, error) {
+	if s.maxConcurrentJobs <= 0 {
+		return newShouldBidResponse(), nil
+	}
+	pending, err := s.executionStore.GetExecutionCount(ctx, store.ExecutionStateCreated)
+	if err != nil {
+		return BidStrategyResponse{}, fmt.Errorf("error counting the executions bid on: %w", err)
+	}
+	if count := s.backendBuffer.ExecutionCount() + pending; count >= s.maxConcurrentJobs {
+		return BidStrategyResponse{
+			ShouldBid: false,
+			Reason: fmt.Sprintf("node already has %d jobs running, queued or bid on, and runs at most %d at once",
+				count, s.maxConcurrentJobs),
+		}, nil
+	}
+	return newShouldBidResponse(), nil
+}
+
+func (s *MaxConcurrentJobsStrategy) ShouldBidBasedOnUsage(
+	ctx context.Context, request BidStrategyRequest, usage model.ResourceUsageData) (BidStrategyResponse, error) {
+	return newShouldBidResponse(), nil
+}
+
+// compile-time interface check
+var _ BidStrategy = (*MaxConcurrentJobsStrategy)(nil)
@@ -0,0 +1,96 @@
+//go:build unit || !integration
+
+package bidstrategy
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/bacalhau/pkg/compute/backend"
+	"github.com/filecoin-project/bacalhau/pkg/compute/capacity"
+	"github.com/filecoin-project/bacalhau/pkg/compute/store"
+	"github.com/filecoin-project/bacalhau/pkg/compute/store/inmemory"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/stretchr/testify/require"
+)
+
+// waitingService runs executions until the context is done.
+type waitingService struct{}
+
+func (waitingService) Run(ctx context.Context, _ store.Execution) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (waitingService) Publish(context.Context, store.Execution) error {
+	return nil
+}
+
+func (waitingService) Cancel(context.Context, store.Execution) error {
+	return nil
+}
+
+func TestMaxConcurrentJobsStrategy(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	buffer := backend.NewServiceBuffer(backend.ServiceBufferParams{
+		DelegateService: waitingService{},
+		Callback:        backend.NewChainedCallback(backend.ChainedCallbackParams{}),
+		RunningCapacityTracker: capacity.NewLocalTracker(capacity.LocalTrackerParams{
+			MaxCapacity: model.ResourceUsageData{CPU: 100, Memory: 100},
+		}),
+		DefaultJobExecutionTimeout: time.Minute,
+		MaxConcurrentJobs:          2,
+	})
+	executionStore := inmemory.NewStore()
+	strategy := NewMaxConcurrentJobsStrategy(MaxConcurrentJobsStrategyParams{
+		MaxConcurrentJobs: 3,
+		BackendBuffer:     buffer,
+		ExecutionStore:    executionStore,
+	})
+	request := getBidStrategyRequest()
+
+	for i := 0; i < 2; i++ {
+		result, err := strategy.ShouldBid(ctx, request)
+		require.NoError(t, err)
+		require.True(t, result.ShouldBid)
+
+		execution := store.Execution{
+			ID:            fmt.Sprintf("execution-%d", i),
+			Shard:         model.JobShard{Job: &request.Job},
+			ResourceUsage: model.ResourceUsageData{CPU: 1, Memory: 1},
+		}
+		require.NoError(t, buffer.Run(ctx, execution))
+	}
+
+	// bids waiting to be accepted count too
+	result, err := strategy.ShouldBid(ctx, request)
+	require.NoError(t, err)
+	require.True(t, result.ShouldBid)
+	pending := store.NewExecution("pending", model.JobShard{Job: &request.Job}, model.ResourceUsageData{CPU: 1, Memory: 1})
+	require.NoError(t, executionStore.CreateExecution(ctx, *pending))
+
+	// exactly as many jobs as configured are accepted
+	result, err = strategy.ShouldBid(ctx, request)
+	require.NoError(t, err)
+	require.False(t, result.ShouldBid)
+	require.Contains(t, result.Reason, "runs at most 3 at once")
+
+	// until a bid is rejected
+	require.NoError(t, executionStore.UpdateExecutionState(ctx, store.UpdateExecutionStateRequest{
+		ExecutionID: pending.ID,
+		NewState:    store.ExecutionStateCancelled,
+	}))
+	result, err = strategy.ShouldBid(ctx, request)
+	require.NoError(t, err)
+	require.True(t, result.ShouldBid)
+}
+
+func TestMaxConcurrentJobsStrategyNoLimit(t *testing.T) {
+	strategy := NewMaxConcurrentJobsStrategy(MaxConcurrentJobsStrategyParams{})
+	result, err := strategy.ShouldBid(context.Background(), getBidStrategyRequest())
+	require.NoError(t, err)
+	require.True(t, result.ShouldBid)
+}
@@ -0,0 +1,76 @@
+package bidstrategy
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/filecoin-project/bacalhau/pkg/compute/schedule"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+)
+
+type ScheduleStrategyParams struct {
+	// AvailabilityWindows are when the node runs jobs, any time if empty.
+	AvailabilityWindows []schedule.Window
+	// BlackoutWindows are when the node doesn't run jobs, e.g. for
+	// maintenance.
+	BlackoutWindows []schedule.Window
+	// DefaultJobExecutionTimeout is how long jobs without a timeout can run.
+	DefaultJobExecutionTimeout time.Duration
+}
+
+// ScheduleStrategy only bids on jobs that can run to their timeout inside
+// the availability windows of the node and outside of its blackout windows,
+// so that nodes donating off-peak capacity have drained by the time the peak
+// comes.
+type ScheduleStrategy struct {
+	availabilityWindows        []schedule.Window
+	blackoutWindows            []schedule.Window
+	defaultJobExecutionTimeout time.Duration
+	now                        func() time.Time
+}
+
+func NewScheduleStrategy(params ScheduleStrategyParams) *ScheduleStrategy {
+	return &ScheduleStrategy{
+		availabilityWindows:        params.AvailabilityWindows,
+		blackoutWindows:            params.BlackoutWindows,
+		defaultJobExecutionTimeout: params.DefaultJobExecutionTimeout,
+		now:                        time.Now,
+	}
+}
+
+func (s *ScheduleStrategy) ShouldBid(ctx context.Context, request BidStrategyRequest) (BidStrategyResponse, error) {
+	if len(s.availabilityWindows) == 0 && len(s.blackoutWindows) == 0 {
+		return newShouldBidResponse(), nil
+	}
+	timeout := request.Job.Spec.GetTimeout()
+	if request.Job.Spec.Timeout <= 0 {
+		timeout = s.defaultJobExecutionTimeout
+	}
+	now := s.now()
+	end := now.Add(timeout)
+
+	if len(s.availabilityWindows) > 0 && !schedule.Covers(s.availabilityWindows, now, end) {
+		reason := "the node is outside of its availability windows"
+		if schedule.Covers(s.availabilityWindows, now, now) {
+			reason = fmt.Sprintf("the job's timeout of %s runs past the end of the node's availability window", timeout)
+		}
+		return BidStrategyResponse{ShouldBid: false, Reason: reason}, nil
+	}
+	if schedule.Overlaps(s.blackoutWindows, now, end) {
+		reason := fmt.Sprintf("the job's timeout of %s runs into the node's next blackout window", timeout)
+		if schedule.Overlaps(s.blackoutWindows, now, now) {
+			reason = "the node is in a blackout window"
+		}
+		return BidStrategyResponse{ShouldBid: false, Reason: reason}, nil
+	}
+	return newShouldBidResponse(), nil
+}
+
+func (s *ScheduleStrategy) ShouldBidBasedOnUsage(
+	_ context.Context, _ BidStrategyRequest, _ model.ResourceUsageData) (BidStrategyResponse, error) {
+	return newShouldBidResponse(), nil
+}
+
+// Compile-time check to ensure ScheduleStrategy implements the BidStrategy interface.
+var _ BidStrategy = (*ScheduleStrategy)(nil)
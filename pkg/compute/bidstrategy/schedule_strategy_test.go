@@ -0,0 +1,57 @@
+//go:build unit || !integration
+
+package bidstrategy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/bacalhau/pkg/compute/schedule"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduleStrategy(t *testing.T) {
+	// weeknights from 6pm to 8am, and no jobs from 2am to 4am on Tuesdays
+	availability, err := schedule.ParseWindows([]string{"0 18 * * mon-fri 14h"})
+	require.NoError(t, err)
+	blackout, err := schedule.ParseWindows([]string{"0 2 * * tue 2h"})
+	require.NoError(t, err)
+	monday := time.Date(2023, time.January, 2, 0, 0, 0, 0, time.Local)
+
+	for _, testCase := range []struct {
+		name    string
+		now     time.Time
+		timeout time.Duration
+		bid     bool
+	}{
+		{name: "in a window", now: monday.Add(18 * time.Hour), timeout: time.Hour, bid: true},
+		{name: "out of a window", now: monday.Add(12 * time.Hour), timeout: time.Hour},
+		{name: "runs past the window", now: monday.Add(18 * time.Hour), timeout: 15 * time.Hour},
+		{name: "default timeout", now: monday.Add(18 * time.Hour), bid: true},
+		{name: "in the blackout", now: monday.Add(27 * time.Hour), timeout: time.Minute},
+		{name: "runs into the blackout", now: monday.Add(25 * time.Hour), timeout: 2 * time.Hour},
+		{name: "after the blackout", now: monday.Add(28 * time.Hour), timeout: 2 * time.Hour, bid: true},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			strategy := NewScheduleStrategy(ScheduleStrategyParams{
+				AvailabilityWindows:        availability,
+				BlackoutWindows:            blackout,
+				DefaultJobExecutionTimeout: 10 * time.Minute,
+			})
+			strategy.now = func() time.Time { return testCase.now }
+			request := getBidStrategyRequest()
+			request.Job.Spec.Timeout = testCase.timeout.Seconds()
+
+			response, err := strategy.ShouldBid(context.Background(), request)
+			require.NoError(t, err)
+			require.Equal(t, testCase.bid, response.ShouldBid, response.Reason)
+		})
+	}
+}
+
+func TestScheduleStrategyWithoutWindows(t *testing.T) {
+	response, err := NewScheduleStrategy(ScheduleStrategyParams{}).ShouldBid(context.Background(), getBidStrategyRequest())
+	require.NoError(t, err)
+	require.True(t, response.ShouldBid)
+}
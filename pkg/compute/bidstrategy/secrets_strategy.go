@@ -0,0 +1,59 @@
+package bidstrategy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/filecoin-project/bacalhau/pkg/compute/secrets"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+)
+
+type SecretsStrategyParams struct {
+	// Where the node fetches the secrets of jobs from, if anywhere.
+	Provider secrets.Provider
+	// Which of the secrets jobs may request.
+	Policy secrets.Policy
+}
+
+// SecretsStrategy only bids on jobs whose secrets the node has and lets them
+// request, so that they don't fail when they run and can't read the secrets
+// that aren't meant for them.
+type SecretsStrategy struct {
+	provider secrets.Provider
+	policy   secrets.Policy
+}
+
+func NewSecretsStrategy(params SecretsStrategyParams) *SecretsStrategy {
+	return &SecretsStrategy{provider: params.Provider, policy: params.Policy}
+}
+
+func (s *SecretsStrategy) ShouldBid(ctx context.Context, request BidStrategyRequest) (BidStrategyResponse, error) {
+	if len(request.Job.Spec.Secrets) == 0 {
+		return newShouldBidResponse(), nil
+	}
+	if s.provider == nil {
+		return BidStrategyResponse{ShouldBid: false, Reason: "the node has no secrets backend"}, nil
+	}
+	for _, secret := range request.Job.Spec.Secrets {
+		if err := s.policy.Check(request.Job.ClientID, secret.Name); err != nil {
+			return BidStrategyResponse{ShouldBid: false, Reason: err.Error()}, nil
+		}
+		_, err := s.provider.GetSecret(ctx, secret.Name)
+		if errors.Is(err, secrets.ErrSecretNotFound) {
+			return BidStrategyResponse{ShouldBid: false, Reason: "the node does not have secret " + secret.Name}, nil
+		}
+		if err != nil {
+			return BidStrategyResponse{}, fmt.Errorf("could not get secret %s: %w", secret.Name, err)
+		}
+	}
+	return newShouldBidResponse(), nil
+}
+
+func (s *SecretsStrategy) ShouldBidBasedOnUsage(
+	_ context.Context, _ BidStrategyRequest, _ model.ResourceUsageData) (BidStrategyResponse, error) {
+	return newShouldBidResponse(), nil
+}
+
+// Compile-time check to ensure SecretsStrategy implements the BidStrategy interface.
+var _ BidStrategy = (*SecretsStrategy)(nil)
@@ -0,0 +1,65 @@
+//go:build unit || !integration
+
+package bidstrategy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/bacalhau/pkg/compute/secrets"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecretsStrategy(t *testing.T) {
+	t.Setenv("BACALHAU_SECRET_TOKEN", "s3cret")
+	t.Setenv("BACALHAU_SECRET_OWN", "s3cret")
+	t.Setenv("BACALHAU_SECRET_NODE", "s3cret")
+	provider := secrets.NewEnvProvider("")
+	policy := secrets.Policy{Allowed: map[string][]string{"TOKEN": nil, "KEY": nil, "OWN": {"client"}}}
+
+	for _, testCase := range []struct {
+		name     string
+		provider secrets.Provider
+		clientID string
+		secrets  []model.SecretSpec
+		bid      bool
+	}{
+		{name: "no secrets", bid: true},
+		{name: "no secrets or backend", provider: provider, bid: true},
+		{name: "no backend", secrets: []model.SecretSpec{{Name: "TOKEN"}}},
+		{name: "has secret", provider: provider, secrets: []model.SecretSpec{{Name: "TOKEN"}}, bid: true},
+		{name: "missing secret", provider: provider, secrets: []model.SecretSpec{{Name: "TOKEN"}, {Name: "KEY"}}},
+		{name: "secret not allowed", provider: provider, secrets: []model.SecretSpec{{Name: "NODE"}}},
+		{name: "client allowed", provider: provider, clientID: "client", secrets: []model.SecretSpec{{Name: "OWN"}}, bid: true},
+		{name: "client not allowed", provider: provider, clientID: "other", secrets: []model.SecretSpec{{Name: "OWN"}}},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			request := getBidStrategyRequest()
+			request.Job.ClientID = testCase.clientID
+			request.Job.Spec.Secrets = testCase.secrets
+			strategy := NewSecretsStrategy(SecretsStrategyParams{Provider: testCase.provider, Policy: policy})
+			response, err := strategy.ShouldBid(context.Background(), request)
+			require.NoError(t, err)
+			require.Equal(t, testCase.bid, response.ShouldBid, response.Reason)
+			require.NotContains(t, response.Reason, "s3cret")
+		})
+	}
+}
+
+func TestSecretsStrategyRefusesCredentials(t *testing.T) {
+	t.Setenv("BACALHAU_SECRET_ESTUARY_KEY", "s3cret")
+	policy, err := secrets.ParsePolicy(nil, []string{"ESTUARY_KEY"})
+	require.NoError(t, err)
+	strategy := NewSecretsStrategy(SecretsStrategyParams{Provider: secrets.NewEnvProvider(""), Policy: policy})
+
+	// the node has the secret, as it publishes with it, but jobs can't
+	// request it
+	request := getBidStrategyRequest()
+	request.Job.Spec.Secrets = []model.SecretSpec{{Name: "ESTUARY_KEY"}}
+	response, err := strategy.ShouldBid(context.Background(), request)
+	require.NoError(t, err)
+	require.False(t, response.ShouldBid)
+	require.Contains(t, response.Reason, "credential of the node")
+	require.NotContains(t, response.Reason, "s3cret")
+}
@@ -0,0 +1,61 @@
+//go:build unit || !integration
+
+package capacity
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+)
+
+// BenchmarkLocalTracker measures how many jobs a second the capacity tracker
+// can reserve and release capacity for, with the given number of jobs
+// already holding capacity on the node.
+func BenchmarkLocalTracker(b *testing.B) {
+	ctx := context.Background()
+	usage := model.ResourceUsageData{CPU: 0.1, Memory: 1024 * 1024, Disk: 1024 * 1024}
+
+	for _, concurrentJobs := range []int{1000, 10000} {
+		newTracker := func(b *testing.B) *LocalTracker {
+			tracker := NewLocalTracker(LocalTrackerParams{
+				MaxCapacity: usage.Multi(float64(2 * concurrentJobs)),
+			})
+			for i := 0; i < concurrentJobs; i++ {
+				if !tracker.AddIfHasCapacity(ctx, usage) {
+					b.Fatalf("could not reserve capacity for job %d", i)
+				}
+			}
+			return tracker
+		}
+
+		b.Run(fmt.Sprintf("jobs=%d", concurrentJobs), func(b *testing.B) {
+			tracker := newTracker(b)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if !tracker.AddIfHasCapacity(ctx, usage) {
+					b.Fatal("expected the tracker to have capacity")
+				}
+				tracker.AvailableCapacity(ctx)
+				tracker.Remove(ctx, usage)
+			}
+		})
+
+		// jobs finishing and being bid on at the same time contend for the
+		// tracker, so some reservations fail when it is full
+		b.Run(fmt.Sprintf("jobs=%d/parallel", concurrentJobs), func(b *testing.B) {
+			tracker := newTracker(b)
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if tracker.AddIfHasCapacity(ctx, usage) {
+						tracker.Remove(ctx, usage)
+					}
+				}
+			})
+		})
+	}
+}
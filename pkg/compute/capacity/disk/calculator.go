@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/filecoin-project/bacalhau/pkg/compute/capacity"
 	"github.com/filecoin-project/bacalhau/pkg/executor"
 	"github.com/filecoin-project/bacalhau/pkg/model"
 )
@@ -26,19 +27,39 @@ func (c *DiskUsageCalculator) Calculate(
 	ctx context.Context, job model.Job, parsedUsage model.ResourceUsageData) (model.ResourceUsageData, error) {
 	requirements := model.ResourceUsageData{}
 
-	e, err := c.executors.GetExecutor(ctx, job.Spec.Engine)
+	inputsSize, err := GetShardInputsSize(ctx, c.executors, job)
 	if err != nil {
 		return model.ResourceUsageData{}, fmt.Errorf("error getting job disk space requirements: %w", err)
 	}
 
-	var totalDiskRequirements uint64 = 0
+	// update the job requirements disk space with what we calculated, and the scratch volumes kept on disk
+	requirements.Disk = inputsSize
+	for _, scratch := range job.Spec.Scratch {
+		if !scratch.Tmpfs {
+			requirements.Disk += capacity.ConvertBytesString(scratch.Size)
+		}
+	}
+
+	return requirements, nil
+}
+
+// GetShardInputsSize returns how much disk space the inputs of each shard of the job take up, from the cumulative size
+// of the inputs reported by their storage providers (e.g. an IPFS object stat). Inputs whose size is not known in time
+// count as empty.
+func GetShardInputsSize(ctx context.Context, executors executor.ExecutorProvider, job model.Job) (uint64, error) {
+	e, err := executors.GetExecutor(ctx, job.Spec.Engine)
+	if err != nil {
+		return 0, err
+	}
+
+	var totalInputsSize uint64 = 0
 
 	for _, input := range job.Spec.Inputs {
 		volumeSize, err := e.GetVolumeSize(ctx, input)
 		if err != nil {
-			return model.ResourceUsageData{}, fmt.Errorf("error getting job disk space requirements: %w", err)
+			return 0, err
 		}
-		totalDiskRequirements += volumeSize
+		totalInputsSize += volumeSize
 	}
 
 	// TODO: think about the fact that each shard might be different sizes
@@ -47,8 +68,5 @@ func (c *DiskUsageCalculator) Calculate(
 	if totalShards == 0 {
 		totalShards = 1
 	}
-	// update the job requirements disk space with what we calculated
-	requirements.Disk = totalDiskRequirements / uint64(totalShards)
-
-	return requirements, nil
+	return totalInputsSize / uint64(totalShards), nil
 }
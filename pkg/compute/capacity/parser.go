@@ -1,14 +1,18 @@
 package capacity
 
 import (
+	"fmt"
+	"math"
+	"regexp"
 	"strconv"
 	"strings"
 
-	"github.com/BTBurke/k8sresource"
-	"github.com/c2h5oh/datasize"
 	"github.com/filecoin-project/bacalhau/pkg/model"
 )
 
+// ParseResourceUsageConfig parses the resources of a config, treating those
+// that don't parse as not set. Configs should have been checked with
+// ParseResourceUsageConfigStrict first.
 func ParseResourceUsageConfig(usage model.ResourceUsageConfig) model.ResourceUsageData {
 	return model.ResourceUsageData{
 		CPU:    ConvertCPUString(usage.CPU),
@@ -17,58 +21,182 @@ func ParseResourceUsageConfig(usage model.ResourceUsageConfig) model.ResourceUsa
 		GPU:    ConvertGPUString(usage.GPU),
 	}
 }
-func ConvertCPUString(val string) float64 {
-	ret, err := convertCPUStringWithError(val)
+
+// ParseResourceUsageConfigStrict parses the resources of a config, returning
+// an error naming the first resource that doesn't parse.
+func ParseResourceUsageConfigStrict(usage model.ResourceUsageConfig) (model.ResourceUsageData, error) {
+	var data model.ResourceUsageData
+	var err error
+	if data.CPU, err = ParseCPUString(usage.CPU); err != nil {
+		return data, fmt.Errorf("invalid CPU: %w", err)
+	}
+	if data.Memory, err = ParseBytesString(usage.Memory); err != nil {
+		return data, fmt.Errorf("invalid memory: %w", err)
+	}
+	if data.Disk, err = ParseBytesString(usage.Disk); err != nil {
+		return data, fmt.Errorf("invalid disk: %w", err)
+	}
+	if data.GPU, err = ParseGPUString(usage.GPU); err != nil {
+		return data, fmt.Errorf("invalid GPU: %w", err)
+	}
+	return data, nil
+}
+
+// NormalizeResourceUsageConfig parses the resources of a config and returns
+// them in their canonical form, so that configs asking for the same
+// resources compare equal whatever units they were written in.
+func NormalizeResourceUsageConfig(usage model.ResourceUsageConfig) (model.ResourceUsageConfig, error) {
+	data, err := ParseResourceUsageConfigStrict(usage)
 	if err != nil {
-		return 0
+		return usage, err
 	}
-	return ret
+	normalized := FormatResourceUsageData(data)
+	// resources that weren't set stay unset rather than becoming "0"
+	if usage.CPU == "" {
+		normalized.CPU = ""
+	}
+	if usage.Memory == "" {
+		normalized.Memory = ""
+	}
+	if usage.Disk == "" {
+		normalized.Disk = ""
+	}
+	if usage.GPU == "" {
+		normalized.GPU = ""
+	}
+	return normalized, nil
 }
 
-func ConvertBytesString(val string) uint64 {
-	ret, err := convertBytesStringWithError(val)
+// FormatResourceUsageData returns the canonical config of resources: CPU and
+// GPU in whole units, like "2", or in millicores otherwise, like "1500m", and
+// memory and disk in bytes. Parsing it
+// returns the same resources.
+func FormatResourceUsageData(data model.ResourceUsageData) model.ResourceUsageConfig {
+	return model.ResourceUsageConfig{
+		CPU:    formatMillis(data.CPU),
+		Memory: strconv.FormatUint(data.Memory, 10),
+		Disk:   strconv.FormatUint(data.Disk, 10),
+		GPU:    formatMillis(data.GPU),
+	}
+}
+
+// ConvertCPUString is ParseCPUString returning 0 if val doesn't parse.
+func ConvertCPUString(val string) float64 {
+	ret, err := ParseCPUString(val)
 	if err != nil {
 		return 0
 	}
 	return ret
 }
 
-func ConvertGPUString(val string) uint64 {
-	ret, err := strconv.ParseUint(val, 10, 64) //nolint:gomnd
+// ConvertBytesString is ParseBytesString returning 0 if val doesn't parse.
+func ConvertBytesString(val string) uint64 {
+	ret, err := ParseBytesString(val)
 	if err != nil {
 		return 0
 	}
 	return ret
 }
 
-func convertCPUStringWithError(val string) (float64, error) {
+// ConvertGPUString parses GPU units like CPU units, so that a job can ask
+// for a fraction of a GPU such as "500m" or "0.5".
+func ConvertGPUString(val string) float64 {
+	return ConvertCPUString(val)
+}
+
+var quantityRegex = regexp.MustCompile(`^([0-9]+(?:\.[0-9]*)?|\.[0-9]+)\s*([A-Za-z]*)$`)
+
+// ParseCPUString parses a number of cores, either as a decimal like "2" or
+// "0.5" or in millicores like "500m". An empty string is 0 cores.
+func ParseCPUString(val string) (float64, error) {
+	val = strings.TrimSpace(val)
 	if val == "" {
 		return 0, nil
 	}
-	cpu, err := k8sresource.NewCPUFromString(sanitizeBytesString(val))
+	number, unit, err := splitQuantity(val)
 	if err != nil {
 		return 0, err
 	}
-	return cpu.ToFloat64(), nil
+	var millis float64
+	switch unit {
+	case "":
+		millis = number * 1000
+	case "m":
+		millis = number
+	default:
+		return 0, fmt.Errorf("unknown unit %q in %q, expected cores like 2 or 0.5, or millicores like 500m", unit, val)
+	}
+	// allow for the error of the float multiplication
+	rounded := math.Round(millis)
+	if math.Abs(millis-rounded) > 1e-6 {
+		return 0, fmt.Errorf("%q is more precise than a millicore", val)
+	}
+	return rounded / 1000, nil
+}
+
+// ParseGPUString parses a number of GPUs like ParseCPUString.
+func ParseGPUString(val string) (float64, error) {
+	return ParseCPUString(val)
+}
+
+// byteUnits are the multiples of bytes sizes can be given in. Units are
+// binary whichever way they are written, so 1Gi, 1GiB, 1GB and 1G are all
+// 1024^3 bytes, as sizes have always been parsed.
+var byteUnits = map[string]uint64{
+	"":  1,
+	"k": 1 << 10,
+	"m": 1 << 20,
+	"g": 1 << 30,
+	"t": 1 << 40,
+	"p": 1 << 50,
 }
 
-func convertBytesStringWithError(val string) (uint64, error) {
+// ParseBytesString parses a size in bytes, like "1073741824", "1Gi", "1GiB"
+// or "512MB", rounded to a whole number of bytes. An empty string is 0 bytes.
+func ParseBytesString(val string) (uint64, error) {
+	val = strings.TrimSpace(val)
 	if val == "" {
 		return 0, nil
 	}
-	mem, err := datasize.ParseString(sanitizeBytesString(val))
+	number, unit, err := splitQuantity(val)
 	if err != nil {
 		return 0, err
 	}
-	return mem.Bytes(), nil
+	// Gi, GiB, GB and G all have the prefix g
+	prefix := strings.TrimSuffix(strings.ToLower(unit), "b")
+	if len(prefix) == 2 {
+		prefix = strings.TrimSuffix(prefix, "i")
+	}
+	multiple, ok := byteUnits[prefix]
+	if !ok {
+		return 0, fmt.Errorf("unknown unit %q in %q, expected bytes or one of Ki, Mi, Gi, Ti or Pi", unit, val)
+	}
+	bytes := math.Round(number * float64(multiple))
+	if bytes >= math.MaxUint64 {
+		return 0, fmt.Errorf("%q is too large", val)
+	}
+	return uint64(bytes), nil
 }
 
-// allow Mi, Gi to mean Mb, Gb
-// remove spaces
-// lowercase
-func sanitizeBytesString(st string) string {
-	st = strings.ToLower(st)
-	st = strings.ReplaceAll(st, "i", "b")
-	st = strings.ReplaceAll(st, " ", "")
-	return st
+// splitQuantity splits a quantity into its non-negative number and its unit.
+func splitQuantity(val string) (float64, string, error) {
+	matches := quantityRegex.FindStringSubmatch(val)
+	if matches == nil {
+		return 0, "", fmt.Errorf("%q is not a non-negative number with an optional unit", val)
+	}
+	number, err := strconv.ParseFloat(matches[1], 64)
+	if err != nil {
+		return 0, "", fmt.Errorf("%q is not a valid number: %w", val, err)
+	}
+	return number, matches[2], nil
+}
+
+// formatMillis formats whole units as they are, e.g. "2", and others in
+// thousandths, e.g. "1500m".
+func formatMillis(units float64) string {
+	millis := int64(math.Round(units * 1000))
+	if millis%1000 == 0 {
+		return strconv.FormatInt(millis/1000, 10)
+	}
+	return fmt.Sprintf("%dm", millis)
 }
@@ -26,6 +26,22 @@ func TestResourceUsageConfigParser(t *testing.T) {
 				GPU:    0,
 			},
 		},
+		{
+			model.ResourceUsageConfig{
+				GPU: "500m",
+			},
+			model.ResourceUsageData{
+				GPU: 0.5,
+			},
+		},
+		{
+			model.ResourceUsageConfig{
+				GPU: "2",
+			},
+			model.ResourceUsageData{
+				GPU: 2,
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -33,3 +49,72 @@ func TestResourceUsageConfigParser(t *testing.T) {
 		require.Equal(t, tc.expectedData, data)
 	}
 }
+
+func TestParseBytesString(t *testing.T) {
+	for _, tc := range []struct {
+		val      string
+		expected uint64
+	}{
+		{"", 0},
+		{"1073741824", 1 << 30},
+		{"1Gi", 1 << 30},
+		{"1GiB", 1 << 30},
+		{"1GB", 1 << 30},
+		{"1gb", 1 << 30},
+		{"1 G", 1 << 30},
+		{"1.5Ki", 1536},
+		{"500Mi", 500 << 20},
+		{"10b", 10},
+	} {
+		bytes, err := ParseBytesString(tc.val)
+		require.NoError(t, err, tc.val)
+		require.Equal(t, tc.expected, bytes, tc.val)
+	}
+
+	for _, val := range []string{"-1Gi", "1Gx", "Gi", "1i", "1ib", "1.2.3", "1e3"} {
+		_, err := ParseBytesString(val)
+		require.Error(t, err, val)
+	}
+}
+
+func TestParseCPUString(t *testing.T) {
+	for _, tc := range []struct {
+		val      string
+		expected float64
+	}{
+		{"", 0},
+		{"2", 2},
+		{"0.5", 0.5},
+		{"500m", 0.5},
+		{"1.001", 1.001},
+		{"2500m", 2.5},
+	} {
+		cpu, err := ParseCPUString(tc.val)
+		require.NoError(t, err, tc.val)
+		require.Equal(t, tc.expected, cpu, tc.val)
+	}
+
+	for _, val := range []string{"-1", "1Gi", "0.0001", "0.5m", "m"} {
+		_, err := ParseCPUString(val)
+		require.Error(t, err, val)
+	}
+}
+
+func TestNormalizeResourceUsageConfig(t *testing.T) {
+	normalized, err := NormalizeResourceUsageConfig(model.ResourceUsageConfig{CPU: "0.5", Memory: "1Gb", GPU: "1"})
+	require.NoError(t, err)
+	require.Equal(t, model.ResourceUsageConfig{CPU: "500m", Memory: "1073741824", GPU: "1"}, normalized)
+
+	// the canonical form round trips
+	again, err := NormalizeResourceUsageConfig(normalized)
+	require.NoError(t, err)
+	require.Equal(t, normalized, again)
+
+	data := model.ResourceUsageData{CPU: 1.25, Memory: 3 << 20, Disk: 7, GPU: 0.5}
+	parsed, err := ParseResourceUsageConfigStrict(FormatResourceUsageData(data))
+	require.NoError(t, err)
+	require.Equal(t, data, parsed)
+
+	_, err = NormalizeResourceUsageConfig(model.ResourceUsageConfig{Memory: "1Gx"})
+	require.ErrorContains(t, err, "invalid memory")
+}
@@ -0,0 +1,111 @@
+package capacity
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+)
+
+// ResourceProfile is the resources a compute node gives the jobs of an
+// engine, and optionally of the images that match a pattern, for the
+// resources the jobs don't ask for.
+type ResourceProfile struct {
+	Engine model.Engine
+	// A path.Match pattern of the docker images of the jobs, e.g.
+	// pytorch/*, or empty for all the jobs of the engine.
+	ImagePattern string
+	Resources    model.ResourceUsageData
+}
+
+// ParseResourceProfile parses a profile of the form
+// ENGINE[:IMAGE-PATTERN]=RESOURCE=VALUE,..., e.g.
+// docker:pytorch/*=cpu=2,memory=8Gi,gpu=1, where resources are cpu, memory,
+// disk and gpu.
+func ParseResourceProfile(value string) (ResourceProfile, error) {
+	var profile ResourceProfile
+	selector, resources, ok := strings.Cut(value, "=")
+	if !ok || selector == "" || resources == "" {
+		return profile, fmt.Errorf("%q must be ENGINE[:IMAGE-PATTERN]=RESOURCE=VALUE,...", value)
+	}
+
+	engine, pattern, _ := strings.Cut(selector, ":")
+	var err error
+	if profile.Engine, err = model.ParseEngine(engine); err != nil {
+		return profile, err
+	}
+	if pattern != "" {
+		if _, err = path.Match(pattern, ""); err != nil {
+			return profile, fmt.Errorf("invalid image pattern %q: %w", pattern, err)
+		}
+		profile.ImagePattern = pattern
+	}
+
+	var config model.ResourceUsageConfig
+	for _, resource := range strings.Split(resources, ",") {
+		name, quantity, ok := strings.Cut(resource, "=")
+		if !ok {
+			return profile, fmt.Errorf("%q must be RESOURCE=VALUE", resource)
+		}
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "cpu":
+			config.CPU = quantity
+		case "memory":
+			config.Memory = quantity
+		case "disk":
+			config.Disk = quantity
+		case "gpu":
+			config.GPU = quantity
+		default:
+			return profile, fmt.Errorf("unknown resource %q, expected cpu, memory, disk or gpu", name)
+		}
+	}
+	if profile.Resources, err = ParseResourceUsageConfigStrict(config); err != nil {
+		return profile, err
+	}
+	return profile, nil
+}
+
+// Matches returns whether the profile applies to the job.
+func (p ResourceProfile) Matches(job model.Job) bool {
+	if job.Spec.Engine != p.Engine {
+		return false
+	}
+	if p.ImagePattern == "" {
+		return true
+	}
+	matched, err := path.Match(p.ImagePattern, job.Spec.Docker.Image)
+	return err == nil && matched
+}
+
+// ResourceProfiles are the profiles of a compute node, of which the first
+// that matches a job applies to it.
+type ResourceProfiles []ResourceProfile
+
+// Apply returns the resources of the job, with those it doesn't ask for set
+// to those of the first profile that matches it, so that the job runs with
+// the resources it is accounted for.
+func (profiles ResourceProfiles) Apply(job model.Job) model.ResourceUsageConfig {
+	resources := job.Spec.Resources
+	for _, profile := range profiles {
+		if !profile.Matches(job) {
+			continue
+		}
+		defaults := FormatResourceUsageData(profile.Resources)
+		if resources.CPU == "" && profile.Resources.CPU > 0 {
+			resources.CPU = defaults.CPU
+		}
+		if resources.Memory == "" && profile.Resources.Memory > 0 {
+			resources.Memory = defaults.Memory
+		}
+		if resources.Disk == "" && profile.Resources.Disk > 0 {
+			resources.Disk = defaults.Disk
+		}
+		if resources.GPU == "" && profile.Resources.GPU > 0 {
+			resources.GPU = defaults.GPU
+		}
+		break
+	}
+	return resources
+}
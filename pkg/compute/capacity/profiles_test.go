@@ -0,0 +1,79 @@
+package capacity
+
+import (
+	"testing"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseResourceProfile(t *testing.T) {
+	profile, err := ParseResourceProfile("docker:pytorch/pytorch:2.*=cpu=2,memory=8Gi,gpu=1")
+	require.NoError(t, err)
+	require.Equal(t, ResourceProfile{
+		Engine:       model.EngineDocker,
+		ImagePattern: "pytorch/pytorch:2.*",
+		Resources:    model.ResourceUsageData{CPU: 2, Memory: 8 << 30, GPU: 1},
+	}, profile)
+
+	profile, err = ParseResourceProfile("wasm=memory=512Mi")
+	require.NoError(t, err)
+	require.Equal(t, ResourceProfile{
+		Engine:    model.EngineWasm,
+		Resources: model.ResourceUsageData{Memory: 512 << 20},
+	}, profile)
+
+	for _, value := range []string{
+		"docker",
+		"docker=",
+		"unknown=cpu=1",
+		"docker:[=cpu=1",
+		"docker=cpu",
+		"docker=network=1",
+		"docker=memory=1Gx",
+	} {
+		_, err = ParseResourceProfile(value)
+		require.Error(t, err, value)
+	}
+}
+
+func TestResourceProfilesApply(t *testing.T) {
+	profiles := ResourceProfiles{
+		{Engine: model.EngineDocker, ImagePattern: "pytorch/*", Resources: model.ResourceUsageData{CPU: 2, GPU: 1}},
+		{Engine: model.EngineDocker, Resources: model.ResourceUsageData{CPU: 0.5, Memory: 1 << 30}},
+	}
+	job := func(engine model.Engine, image string, resources model.ResourceUsageConfig) model.Job {
+		return model.Job{Spec: model.Spec{Engine: engine, Docker: model.JobSpecDocker{Image: image}, Resources: resources}}
+	}
+
+	for _, testCase := range []struct {
+		name     string
+		job      model.Job
+		expected model.ResourceUsageConfig
+	}{
+		{
+			name:     "first matching profile",
+			job:      job(model.EngineDocker, "pytorch/pytorch:2.0", model.ResourceUsageConfig{}),
+			expected: model.ResourceUsageConfig{CPU: "2", GPU: "1"},
+		},
+		{
+			name:     "engine profile",
+			job:      job(model.EngineDocker, "ubuntu", model.ResourceUsageConfig{}),
+			expected: model.ResourceUsageConfig{CPU: "500m", Memory: "1073741824"},
+		},
+		{
+			name:     "asked for resources are kept",
+			job:      job(model.EngineDocker, "ubuntu", model.ResourceUsageConfig{CPU: "4"}),
+			expected: model.ResourceUsageConfig{CPU: "4", Memory: "1073741824"},
+		},
+		{
+			name:     "no matching profile",
+			job:      job(model.EngineWasm, "", model.ResourceUsageConfig{}),
+			expected: model.ResourceUsageConfig{},
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			require.Equal(t, testCase.expected, profiles.Apply(testCase.job))
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package system
+
+import (
+	"bufio"
+	"bytes"
+	"io/fs"
+	"math"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// cgroupRoot is where cgroups are mounted, relative to the root of the
+// filesystem.
+const cgroupRoot = "sys/fs/cgroup"
+
+// cgroupLimits are the CPU and memory limits of the cgroup the node runs in,
+// which are lower than those of the machine when it runs in a container. A
+// zero limit is no limit.
+type cgroupLimits struct {
+	// cpu units
+	CPU float64
+	// bytes
+	Memory uint64
+}
+
+// getCgroupLimits returns the limits of the cgroup of this process, or no
+// limits if they can't be read, e.g. when not on Linux.
+func getCgroupLimits() cgroupLimits {
+	return readCgroupLimits(os.DirFS("/"))
+}
+
+// readCgroupLimits reads the limits of the cgroup of this process from the
+// filesystem, using cgroup v2 if it is mounted and v1 otherwise. The limits
+// of the parents of the cgroup apply to it too, so the lowest are returned.
+func readCgroupLimits(fsys fs.FS) cgroupLimits {
+	procCgroup, err := fs.ReadFile(fsys, "proc/self/cgroup")
+	if err != nil {
+		return cgroupLimits{}
+	}
+	paths := parseProcCgroup(procCgroup)
+
+	if _, err = fs.Stat(fsys, path.Join(cgroupRoot, "cgroup.controllers")); err == nil {
+		var limits cgroupLimits
+		for _, dir := range cgroupDirs(fsys, cgroupRoot, paths[""]) {
+			limits = limits.min(cgroupLimits{
+				CPU:    readCPUMax(fsys, path.Join(dir, "cpu.max")),
+				Memory: readBytesLimit(fsys, path.Join(dir, "memory.max")),
+			})
+		}
+		return limits
+	}
+
+	var limits cgroupLimits
+	for _, controller := range []string{"cpu,cpuacct", "cpu"} {
+		cgroupPath, ok := paths[controller]
+		if !ok {
+			continue
+		}
+		for _, dir := range cgroupDirs(fsys, path.Join(cgroupRoot, controller), cgroupPath) {
+			limits = limits.min(cgroupLimits{
+				CPU: readCFSQuota(fsys, dir),
+			})
+		}
+		break
+	}
+	if cgroupPath, ok := paths["memory"]; ok {
+		for _, dir := range cgroupDirs(fsys, path.Join(cgroupRoot, "memory"), cgroupPath) {
+			limits = limits.min(cgroupLimits{
+				Memory: readBytesLimit(fsys, path.Join(dir, "memory.limit_in_bytes")),
+			})
+		}
+	}
+	return limits
+}
+
+// parseProcCgroup parses /proc/self/cgroup into the cgroup path of each
+// controller list, which is empty for the unified cgroup v2 hierarchy:
+//
+//	0::/system.slice/bacalhau.service
+//	4:cpu,cpuacct:/docker/0e8a6e8b
+func parseProcCgroup(data []byte) map[string]string {
+	paths := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		paths[fields[1]] = fields[2]
+		for _, controller := range strings.Split(fields[1], ",") {
+			if _, ok := paths[controller]; !ok {
+				paths[controller] = fields[2]
+			}
+		}
+	}
+	return paths
+}
+
+// cgroupDirs returns the directories of the cgroup at cgroupPath under the
+// mount and of its parents that are visible. In a container with its own
+// cgroup namespace the mount is the cgroup of the container, and without one
+// the cgroup path of the host isn't mounted in the container, so the mount
+// itself is all there is.
+func cgroupDirs(fsys fs.FS, mount, cgroupPath string) []string {
+	dirs := []string{mount}
+	cgroupPath = strings.Trim(path.Clean("/"+cgroupPath), "/")
+	if cgroupPath == "" {
+		return dirs
+	}
+	dir := mount
+	for _, part := range strings.Split(cgroupPath, "/") {
+		dir = path.Join(dir, part)
+		if info, err := fs.Stat(fsys, dir); err != nil || !info.IsDir() {
+			break
+		}
+		dirs = append(dirs, dir)
+	}
+	return dirs
+}
+
+// readCPUMax reads the cores a cgroup v2 cpu.max allows, which is
+// "<quota> <period>" in microseconds, or "max <period>" for no limit.
+func readCPUMax(fsys fs.FS, name string) float64 {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return 0
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) != 2 || fields[0] == "max" {
+		return 0
+	}
+	return cpuQuota(fields[0], fields[1])
+}
+
+// readCFSQuota reads the cores the cgroup v1 CFS quota of a cgroup allows,
+// where a quota of -1 is no limit.
+func readCFSQuota(fsys fs.FS, dir string) float64 {
+	quota, err := fs.ReadFile(fsys, path.Join(dir, "cpu.cfs_quota_us"))
+	if err != nil {
+		return 0
+	}
+	period, err := fs.ReadFile(fsys, path.Join(dir, "cpu.cfs_period_us"))
+	if err != nil {
+		return 0
+	}
+	return cpuQuota(strings.TrimSpace(string(quota)), strings.TrimSpace(string(period)))
+}
+
+func cpuQuota(quota, period string) float64 {
+	q, err := strconv.ParseInt(quota, 10, 64)
+	if err != nil || q <= 0 {
+		return 0
+	}
+	p, err := strconv.ParseInt(period, 10, 64)
+	if err != nil || p <= 0 {
+		return 0
+	}
+	return float64(q) / float64(p)
+}
+
+// readBytesLimit reads a memory limit in bytes, which is "max" for no limit
+// in cgroup v2 and a number near the largest int64 in cgroup v1.
+func readBytesLimit(fsys fs.FS, name string) uint64 {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return 0
+	}
+	limit, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil || limit >= math.MaxInt64/4096*4096 {
+		return 0
+	}
+	return limit
+}
+
+// min returns the lowest of each limit, where zero is no limit.
+func (l cgroupLimits) min(other cgroupLimits) cgroupLimits {
+	if other.CPU > 0 && (l.CPU == 0 || other.CPU < l.CPU) {
+		l.CPU = other.CPU
+	}
+	if other.Memory > 0 && (l.Memory == 0 || other.Memory < l.Memory) {
+		l.Memory = other.Memory
+	}
+	return l
+}
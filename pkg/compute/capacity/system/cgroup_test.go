@@ -0,0 +1,85 @@
+//go:build unit || !integration
+
+package system
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func cgroupFile(data string) *fstest.MapFile {
+	return &fstest.MapFile{Data: []byte(data)}
+}
+
+func TestReadCgroupLimits(t *testing.T) {
+	for _, testCase := range []struct {
+		name     string
+		fsys     fstest.MapFS
+		expected cgroupLimits
+	}{
+		{
+			name:     "not linux",
+			fsys:     fstest.MapFS{},
+			expected: cgroupLimits{},
+		},
+		{
+			name: "v2 container with its own namespace",
+			fsys: fstest.MapFS{
+				"proc/self/cgroup":                   cgroupFile("0::/\n"),
+				"sys/fs/cgroup/cgroup.controllers":   cgroupFile("cpu memory io\n"),
+				"sys/fs/cgroup/cpu.max":              cgroupFile("150000 100000\n"),
+				"sys/fs/cgroup/memory.max":           cgroupFile("2147483648\n"),
+				"sys/fs/cgroup/system.slice/cpu.max": cgroupFile("max 100000\n"),
+			},
+			expected: cgroupLimits{CPU: 1.5, Memory: 2 << 30},
+		},
+		{
+			name: "v2 systemd unit limited by its parent",
+			fsys: fstest.MapFS{
+				"proc/self/cgroup":                                       cgroupFile("0::/system.slice/bacalhau.service\n"),
+				"sys/fs/cgroup/cgroup.controllers":                       cgroupFile("cpu memory io\n"),
+				"sys/fs/cgroup/system.slice/cpu.max":                     cgroupFile("400000 100000\n"),
+				"sys/fs/cgroup/system.slice/memory.max":                  cgroupFile("max\n"),
+				"sys/fs/cgroup/system.slice/bacalhau.service/cpu.max":    cgroupFile("max 100000\n"),
+				"sys/fs/cgroup/system.slice/bacalhau.service/memory.max": cgroupFile("1073741824\n"),
+			},
+			expected: cgroupLimits{CPU: 4, Memory: 1 << 30},
+		},
+		{
+			name: "v2 without limits",
+			fsys: fstest.MapFS{
+				"proc/self/cgroup":                 cgroupFile("0::/\n"),
+				"sys/fs/cgroup/cgroup.controllers": cgroupFile("cpu memory io\n"),
+				"sys/fs/cgroup/cpu.max":            cgroupFile("max 100000\n"),
+				"sys/fs/cgroup/memory.max":         cgroupFile("max\n"),
+			},
+			expected: cgroupLimits{},
+		},
+		{
+			name: "v1 docker container",
+			fsys: fstest.MapFS{
+				"proc/self/cgroup":                            cgroupFile("12:memory:/docker/0e8a6e8b\n4:cpu,cpuacct:/docker/0e8a6e8b\n"),
+				"sys/fs/cgroup/cpu,cpuacct/cpu.cfs_quota_us":  cgroupFile("50000\n"),
+				"sys/fs/cgroup/cpu,cpuacct/cpu.cfs_period_us": cgroupFile("100000\n"),
+				"sys/fs/cgroup/memory/memory.limit_in_bytes":  cgroupFile("536870912\n"),
+			},
+			expected: cgroupLimits{CPU: 0.5, Memory: 512 << 20},
+		},
+		{
+			name: "v1 without limits",
+			fsys: fstest.MapFS{
+				"proc/self/cgroup":                            cgroupFile("12:memory:/\n4:cpu,cpuacct:/\n"),
+				"sys/fs/cgroup/cpu,cpuacct/cpu.cfs_quota_us":  cgroupFile("-1\n"),
+				"sys/fs/cgroup/cpu,cpuacct/cpu.cfs_period_us": cgroupFile("100000\n"),
+				"sys/fs/cgroup/memory/memory.limit_in_bytes":  cgroupFile("9223372036854771712\n"),
+			},
+			expected: cgroupLimits{},
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			require.Equal(t, testCase.expected, readCgroupLimits(testCase.fsys))
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package system
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	bacsystem "github.com/filecoin-project/bacalhau/pkg/system"
+)
+
+// NvidiaCLI is the path to the Nvidia helper binary
+const NvidiaCLI = "nvidia-container-cli"
+
+// NvidiaSMI lists the MIG devices GPUs are partitioned into
+const NvidiaSMI = "nvidia-smi"
+
+// nvidiaToolTimeout is how long the NVIDIA tools may take to list the GPUs,
+// which they can hang doing when the driver is in a bad state.
+const nvidiaToolTimeout = 30 * time.Second
+
+// GPU is an NVIDIA GPU of this machine, or a MIG slice of one.
+type GPU struct {
+	// The index of the GPU, as used by nvidia-smi and Docker, which is
+	// <gpu>:<mig device> for a MIG slice.
+	Index string
+	// The UUID of the GPU, which identifies it however the GPUs of a
+	// container are numbered.
+	UUID  string
+	Model string
+	// The MIG profile of a MIG slice, e.g. 1g.5gb.
+	MIGProfile string
+	// The share of a whole GPU this is, 1 unless it's a MIG slice.
+	Fraction float64
+}
+
+// GetSystemGPUs wraps nvidia-container-cli to list the GPUs of this machine,
+// with GPUs that are partitioned with MIG replaced by their MIG slices.
+// There are no GPUs if the NVIDIA CLI is not installed.
+func GetSystemGPUs() ([]GPU, error) {
+	resp, err := runNvidiaTool(NvidiaCLI, "info", "--csv")
+	if err != nil || resp == "" {
+		return nil, err
+	}
+	gpus, err := parseNvidiaInfo(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err = runNvidiaTool(NvidiaSMI, "-L")
+	if err != nil || resp == "" {
+		// without nvidia-smi we can't tell whether any GPU is partitioned, so
+		// treat them all as whole GPUs
+		return gpus, nil //nolint:nilerr
+	}
+	return withMIGDevices(gpus, parseMIGDevices(resp)), nil
+}
+
+// runNvidiaTool returns the output of the tool, or nothing if it isn't
+// installed.
+func runNvidiaTool(tool string, args ...string) (string, error) {
+	toolPath, err := exec.LookPath(tool)
+	if err != nil {
+		// If the NVIDIA CLI is not installed, we can't know the number of GPUs, assume zero
+		if (err.(*exec.Error)).Unwrap() == exec.ErrNotFound {
+			return "", nil
+		}
+		return "", err
+	}
+	result, err := bacsystem.RunCommand(context.Background(), bacsystem.Command{
+		Name:    toolPath,
+		Args:    args,
+		Timeout: nvidiaToolTimeout,
+	})
+	if err != nil {
+		return "", err
+	}
+	return result.Stdout, nil
+}
+
+// parseNvidiaInfo parses the device table of `nvidia-container-cli info --csv`:
+//
+//	Device Index,Device Minor,Model,Brand,GPU UUID,Bus Location,Architecture
+//	0,0,Tesla T4,Nvidia,GPU-4c2ba2a5-8a1d-6c2e-4c1b-3d6bd25b1b6d,00000000:00:04.0,7.5
+func parseNvidiaInfo(info string) ([]GPU, error) {
+	var gpus []GPU
+	var columns map[string]int
+	for _, line := range strings.Split(info, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		fields := strings.Split(line, ",")
+		if strings.HasPrefix(line, "Device Index") {
+			columns = map[string]int{}
+			for i, field := range fields {
+				columns[strings.TrimSpace(field)] = i
+			}
+			continue
+		}
+		if columns == nil {
+			continue
+		}
+		if len(fields) != len(columns) {
+			return nil, fmt.Errorf("unexpected %s device line: %q", NvidiaCLI, line)
+		}
+		field := func(name string) string {
+			if i, ok := columns[name]; ok {
+				return strings.TrimSpace(fields[i])
+			}
+			return ""
+		}
+		gpus = append(gpus, GPU{
+			Index:    field("Device Index"),
+			UUID:     field("GPU UUID"),
+			Model:    field("Model"),
+			Fraction: 1,
+		})
+	}
+	return gpus, nil
+}
+
+var (
+	gpuLineRegex = regexp.MustCompile(`^GPU (\d+): .* \(UUID: (\S+)\)$`)
+	migLineRegex = regexp.MustCompile(`^MIG (\S+)\s+Device\s+(\d+): \(UUID: (\S+)\)$`)
+	// the compute slices of a MIG profile, e.g. 3 for 3g.20gb
+	migSlicesRegex = regexp.MustCompile(`^(\d+)g\.`)
+)
+
+// parseMIGDevices parses the MIG devices out of `nvidia-smi -L`, by the
+// index of the GPU they partition:
+//
+//	GPU 0: NVIDIA A100-SXM4-40GB (UUID: GPU-5d5ba0d6-d33d-2b2c-524d-9e3d8d2b8a77)
+//	  MIG 3g.20gb     Device  0: (UUID: MIG-c6d4f1ef-42e4-5de3-91c7-45d71c87eb3f)
+func parseMIGDevices(list string) map[string][]GPU {
+	devices := map[string][]GPU{}
+	gpuIndex := ""
+	for _, line := range strings.Split(list, "\n") {
+		line = strings.TrimSpace(line)
+		if match := gpuLineRegex.FindStringSubmatch(line); match != nil {
+			gpuIndex = match[1]
+			continue
+		}
+		match := migLineRegex.FindStringSubmatch(line)
+		if match == nil || gpuIndex == "" {
+			continue
+		}
+		devices[gpuIndex] = append(devices[gpuIndex], GPU{
+			Index:      gpuIndex + ":" + match[2],
+			UUID:       match[3],
+			MIGProfile: match[1],
+		})
+	}
+	return devices
+}
+
+// withMIGDevices replaces the GPUs that are partitioned with their MIG
+// devices, each of which is the share of the GPU its compute slices are.
+func withMIGDevices(gpus []GPU, migDevices map[string][]GPU) []GPU {
+	var res []GPU
+	for _, gpu := range gpus {
+		devices, ok := migDevices[gpu.Index]
+		if !ok {
+			res = append(res, gpu)
+			continue
+		}
+		for _, device := range devices {
+			device.Model = gpu.Model
+			device.Fraction = migFraction(gpu.Model, device.MIGProfile)
+			res = append(res, device)
+		}
+	}
+	return res
+}
+
+func migFraction(model, profile string) float64 {
+	// A30s have 4 compute slices, and the other MIG capable GPUs 7
+	totalSlices := 7.0
+	if strings.Contains(model, "A30") {
+		totalSlices = 4
+	}
+	match := migSlicesRegex.FindStringSubmatch(profile)
+	if match == nil {
+		return 1 / totalSlices
+	}
+	slices, err := strconv.ParseFloat(match[1], 64)
+	if err != nil || slices > totalSlices {
+		return 1
+	}
+	return slices / totalSlices
+}
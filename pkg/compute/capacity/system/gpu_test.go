@@ -0,0 +1,49 @@
+//go:build unit || !integration
+
+package system
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseNvidiaInfo(t *testing.T) {
+	gpus, err := parseNvidiaInfo(`NVRM version,CUDA version
+470.57.02,11.4
+
+Device Index,Device Minor,Model,Brand,GPU UUID,Bus Location,Architecture
+0,0,Tesla T4,Nvidia,GPU-4c2ba2a5-8a1d-6c2e-4c1b-3d6bd25b1b6d,00000000:00:04.0,7.5
+1,1,Tesla T4,Nvidia,GPU-9d5e3b0c-1f2a-4b3c-8d4e-5f6a7b8c9d0e,00000000:00:05.0,7.5
+`)
+	require.NoError(t, err)
+	require.Equal(t, []GPU{
+		{Index: "0", UUID: "GPU-4c2ba2a5-8a1d-6c2e-4c1b-3d6bd25b1b6d", Model: "Tesla T4", Fraction: 1},
+		{Index: "1", UUID: "GPU-9d5e3b0c-1f2a-4b3c-8d4e-5f6a7b8c9d0e", Model: "Tesla T4", Fraction: 1},
+	}, gpus)
+
+	gpus, err = parseNvidiaInfo("NVRM version,CUDA version\n470.57.02,11.4\n")
+	require.NoError(t, err)
+	require.Empty(t, gpus)
+}
+
+func TestMIGDevices(t *testing.T) {
+	gpus := []GPU{
+		{Index: "0", UUID: "GPU-a", Model: "NVIDIA A100-SXM4-40GB", Fraction: 1},
+		{Index: "1", UUID: "GPU-b", Model: "NVIDIA A100-SXM4-40GB", Fraction: 1},
+	}
+	migDevices := parseMIGDevices(`GPU 0: NVIDIA A100-SXM4-40GB (UUID: GPU-a)
+  MIG 3g.20gb     Device  0: (UUID: MIG-c)
+  MIG 1g.5gb      Device  1: (UUID: MIG-d)
+GPU 1: NVIDIA A100-SXM4-40GB (UUID: GPU-b)
+`)
+
+	// the partitioned GPU is replaced by its slices
+	require.Equal(t, []GPU{
+		{Index: "0:0", UUID: "MIG-c", Model: "NVIDIA A100-SXM4-40GB", MIGProfile: "3g.20gb", Fraction: 3.0 / 7},
+		{Index: "0:1", UUID: "MIG-d", Model: "NVIDIA A100-SXM4-40GB", MIGProfile: "1g.5gb", Fraction: 1.0 / 7},
+		gpus[1],
+	}, withMIGDevices(gpus, migDevices))
+
+	require.Equal(t, 0.5, migFraction("NVIDIA A30", "2g.12gb"))
+}
@@ -3,20 +3,16 @@ package system
 import (
 	"context"
 	"fmt"
-	"os/exec"
 	"runtime"
-	"strings"
 
 	"github.com/filecoin-project/bacalhau/pkg/compute/capacity"
 	"github.com/filecoin-project/bacalhau/pkg/config"
 	"github.com/filecoin-project/bacalhau/pkg/model"
 	"github.com/pbnjay/memory"
 	"github.com/ricochet2200/go-disk-usage/du"
+	"github.com/rs/zerolog/log"
 )
 
-// NvidiaCLI is the path to the Nvidia helper binary
-const NvidiaCLI = "nvidia-container-cli"
-
 type PhysicalCapacityProvider struct {
 }
 
@@ -34,10 +30,22 @@ func (p *PhysicalCapacityProvider) AvailableCapacity(ctx context.Context) (model
 		return model.ResourceUsageData{}, err
 	}
 
-	// the actual resources we have
+	// the actual resources we have, which are those of the cgroup when the
+	// node runs in a container or a limited systemd unit
+	cpu := float64(runtime.NumCPU())
+	totalMemory := memory.TotalMemory()
+	limits := getCgroupLimits()
+	if limits.CPU > 0 && limits.CPU < cpu {
+		log.Ctx(ctx).Debug().Msgf("limiting CPU to the %.2f cores of the cgroup of the node", limits.CPU)
+		cpu = limits.CPU
+	}
+	if limits.Memory > 0 && limits.Memory < totalMemory {
+		log.Ctx(ctx).Debug().Msgf("limiting memory to the %d bytes of the cgroup of the node", limits.Memory)
+		totalMemory = limits.Memory
+	}
 	return model.ResourceUsageData{
-		CPU:    float64(runtime.NumCPU()) * 0.8,
-		Memory: memory.TotalMemory() * 80 / 100,
+		CPU:    cpu * 0.8,
+		Memory: totalMemory * 80 / 100,
 		Disk:   diskSpace * 80 / 100,
 		GPU:    gpus,
 	}, nil
@@ -53,45 +61,18 @@ func getFreeDiskSpace(path string) (uint64, error) {
 	return usage.Free(), nil
 }
 
-// numSystemGPUs wraps nvidia-container-cli to get the number of GPUs
-func numSystemGPUs() (uint64, error) {
-	nvidiaPath, err := exec.LookPath(NvidiaCLI)
+// numSystemGPUs returns the number of NVIDIA GPUs on this machine, where
+// each MIG slice counts as its share of a GPU
+func numSystemGPUs() (float64, error) {
+	gpus, err := GetSystemGPUs()
 	if err != nil {
-		// If the NVIDIA CLI is not installed, we can't know the number of GPUs, assume zero
-		if (err.(*exec.Error)).Unwrap() == exec.ErrNotFound {
-			return 0, nil
-		}
 		return 0, err
 	}
-	args := []string{
-		"info",
-		"--csv",
+	var total float64
+	for _, gpu := range gpus {
+		total += gpu.Fraction
 	}
-	cmd := exec.Command(nvidiaPath, args...)
-	resp, err := cmd.Output()
-	if err != nil {
-		return 0, err
-	}
-
-	// Parse output of nvidia-container-cli command
-	lines := strings.Split(string(resp), "\n")
-	deviceInfoFlag := false
-	numDevices := uint64(0)
-	for _, line := range lines {
-		if strings.TrimSpace(line) == "" {
-			continue
-		}
-		if strings.HasPrefix(line, "Device Index") {
-			deviceInfoFlag = true
-			continue
-		}
-		if deviceInfoFlag {
-			numDevices += 1
-		}
-	}
-
-	fmt.Println(numDevices)
-	return numDevices, nil
+	return total, nil
 }
 
 // compile-time check that the provider implements the interface
@@ -21,6 +21,14 @@ type Tracker interface {
 	Remove(ctx context.Context, usage model.ResourceUsageData)
 }
 
+// SpotTracker keeps track of the capacity used by spot jobs, which can be evicted to make room for standard jobs.
+type SpotTracker interface {
+	// UsedSpotCapacity returns the capacity used by the running spot jobs, which standard jobs can claim.
+	UsedSpotCapacity(ctx context.Context) model.ResourceUsageData
+	// AvailableSpotCapacity returns the capacity more spot jobs can use within the limits set for spot jobs.
+	AvailableSpotCapacity(ctx context.Context) model.ResourceUsageData
+}
+
 // UsageCalculator calculates the resource usage of a job.
 // Can also be used to populate the resource usage of a job with default values if not defined
 type UsageCalculator interface {
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"strconv"
+	"sync"
 
 	"github.com/filecoin-project/bacalhau/pkg/compute/backend"
 	"github.com/filecoin-project/bacalhau/pkg/compute/bidstrategy"
@@ -21,26 +22,40 @@ type BaseServiceParams struct {
 	ID              string
 	ExecutionStore  store.ExecutionStore
 	UsageCalculator capacity.UsageCalculator
-	BidStrategy     bidstrategy.BidStrategy
-	Backend         backend.Service
+	// ResourceProfiles set the resources jobs don't ask for
+	ResourceProfiles capacity.ResourceProfiles
+	BidStrategy      bidstrategy.BidStrategy
+	// ShardBidStrategy is checked again before bidding on each shard of a
+	// job, for the strategies that count the shards the node has bid on, as
+	// bidding on a shard changes what they decide for the next one
+	ShardBidStrategy bidstrategy.BidStrategy
+	Backend          backend.Service
 }
 
 // Base implementation of Service
 type BaseService struct {
-	id              string
-	executionStore  store.ExecutionStore
-	usageCalculator capacity.UsageCalculator
-	bidStrategy     bidstrategy.BidStrategy
-	backend         backend.Service
+	id               string
+	executionStore   store.ExecutionStore
+	usageCalculator  capacity.UsageCalculator
+	resourceProfiles capacity.ResourceProfiles
+	bidStrategy      bidstrategy.BidStrategy
+	shardBidStrategy bidstrategy.BidStrategy
+	// held while checking the shard bid strategy and bidding on a shard, so
+	// that concurrent requests don't bid on shards the other has counted
+	shardBidMutex *sync.Mutex
+	backend       backend.Service
 }
 
 func NewBaseService(params BaseServiceParams) BaseService {
 	return BaseService{
-		id:              params.ID,
-		executionStore:  params.ExecutionStore,
-		usageCalculator: params.UsageCalculator,
-		bidStrategy:     params.BidStrategy,
-		backend:         params.Backend,
+		id:               params.ID,
+		executionStore:   params.ExecutionStore,
+		usageCalculator:  params.UsageCalculator,
+		resourceProfiles: params.ResourceProfiles,
+		bidStrategy:      params.BidStrategy,
+		shardBidStrategy: params.ShardBidStrategy,
+		shardBidMutex:    &sync.Mutex{},
+		backend:          params.Backend,
 	}
 }
 
@@ -54,6 +69,9 @@ func (s BaseService) AskForBid(ctx context.Context, request AskForBidRequest) (A
 	log.Ctx(ctx).Debug().Msgf("job created: %s", request.Job.ID)
 	jobsReceived.With(prometheus.Labels{"node_id": s.id, "client_id": request.Job.ClientID}).Inc()
 
+	// the job is bid on, and run, with the resources of its profile
+	request.Job.Spec.Resources = s.resourceProfiles.Apply(request.Job)
+
 	// ask the bidding strategy if we should bid on this job
 	// TODO: we should check at the shard level, not the job level
 	bidStrategyRequest := bidstrategy.BidStrategyRequest{
@@ -112,6 +130,22 @@ func (s BaseService) prepareAskForBidShardResponse(
 	shardIndex int,
 	shardRequirements model.ResourceUsageData,
 	bidStrategyResponse bidstrategy.BidStrategyResponse) (AskForBidShardResponse, error) {
+	if bidStrategyResponse.ShouldBid && s.shardBidStrategy != nil {
+		s.shardBidMutex.Lock()
+		defer s.shardBidMutex.Unlock()
+		var err error
+		bidStrategyResponse, err = s.shardBidStrategy.ShouldBid(ctx, bidstrategy.BidStrategyRequest{
+			NodeID: s.id,
+			Job:    request.Job,
+		})
+		if err != nil {
+			return AskForBidShardResponse{
+				ShardIndex: shardIndex,
+				Accepted:   false,
+				Reason:     "error asking bidding strategy if we should bid on the shard",
+			}, err
+		}
+	}
 	if !bidStrategyResponse.ShouldBid {
 		return AskForBidShardResponse{
 			ShardIndex: shardIndex,
@@ -252,6 +286,36 @@ func (s BaseService) CancelJob(ctx context.Context, request CancelJobRequest) (C
 	return CancelJobResult{}, nil
 }
 
+func (s BaseService) ExplainBid(ctx context.Context, request ExplainBidRequest) (ExplainBidResponse, error) {
+	ctx, span := s.newSpan(ctx, "ExplainBid")
+	defer span.End()
+
+	request.Job.Spec.Resources = s.resourceProfiles.Apply(request.Job)
+
+	var reasons []string
+	shardRequirements, err := s.usageCalculator.Calculate(
+		ctx, request.Job, capacity.ParseResourceUsageConfig(request.Job.Spec.Resources))
+	if err != nil {
+		// still explain the other reasons not to bid
+		reasons = append(reasons, fmt.Sprintf("error calculating job requirements: %s", err))
+	}
+
+	strategyReasons, err := bidstrategy.Explain(ctx, s.bidStrategy, bidstrategy.BidStrategyRequest{
+		NodeID: s.id,
+		Job:    request.Job,
+	}, shardRequirements)
+	if err != nil {
+		return ExplainBidResponse{}, err
+	}
+	reasons = append(reasons, strategyReasons...)
+
+	return ExplainBidResponse{
+		NodeID:  s.id,
+		Bid:     len(reasons) == 0,
+		Reasons: reasons,
+	}, nil
+}
+
 func (s BaseService) newSpan(ctx context.Context, name string) (context.Context, trace.Span) {
 	return system.Span(ctx, "pkg/compute/node", name,
 		trace.WithSpanKind(trace.SpanKindInternal),
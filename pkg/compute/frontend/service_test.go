@@ -0,0 +1,60 @@
+//go:build unit || !integration
+
+package frontend
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/bacalhau/pkg/compute/backend"
+	"github.com/filecoin-project/bacalhau/pkg/compute/bidstrategy"
+	"github.com/filecoin-project/bacalhau/pkg/compute/capacity"
+	"github.com/filecoin-project/bacalhau/pkg/compute/store/inmemory"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAskForBidMaxConcurrentJobs(t *testing.T) {
+	ctx := context.Background()
+	executionStore := inmemory.NewStore()
+	buffer := backend.NewServiceBuffer(backend.ServiceBufferParams{
+		Callback: backend.NewChainedCallback(backend.ChainedCallbackParams{}),
+		RunningCapacityTracker: capacity.NewLocalTracker(capacity.LocalTrackerParams{
+			MaxCapacity: model.ResourceUsageData{CPU: 100, Memory: 100},
+		}),
+		DefaultJobExecutionTimeout: time.Minute,
+	})
+	strategy := bidstrategy.NewMaxConcurrentJobsStrategy(bidstrategy.MaxConcurrentJobsStrategyParams{
+		MaxConcurrentJobs: 2,
+		BackendBuffer:     buffer,
+		ExecutionStore:    executionStore,
+	})
+	service := NewBaseService(BaseServiceParams{
+		ID:             "node-id",
+		ExecutionStore: executionStore,
+		UsageCalculator: capacity.NewDefaultsUsageCalculator(capacity.DefaultsUsageCalculatorParams{
+			Defaults: model.ResourceUsageData{CPU: 1, Memory: 1},
+		}),
+		BidStrategy:      strategy,
+		ShardBidStrategy: strategy,
+		Backend:          buffer,
+	})
+
+	job := model.Job{ID: "job-id", Spec: model.Spec{Engine: model.EngineNoop}}
+	response, err := service.AskForBid(ctx, AskForBidRequest{Job: job, ShardIndexes: []int{0, 1, 2}})
+	require.NoError(t, err)
+	require.Len(t, response.ShardResponse, 3)
+
+	// the node bids on no more shards than it runs at once
+	require.True(t, response.ShardResponse[0].Accepted)
+	require.True(t, response.ShardResponse[1].Accepted)
+	require.False(t, response.ShardResponse[2].Accepted)
+	require.Contains(t, response.ShardResponse[2].Reason, "runs at most 2 at once")
+
+	// nor on other jobs while the bids are pending
+	job.ID = "other-job-id"
+	response, err = service.AskForBid(ctx, AskForBidRequest{Job: job, ShardIndexes: []int{0}})
+	require.NoError(t, err)
+	require.False(t, response.ShardResponse[0].Accepted)
+}
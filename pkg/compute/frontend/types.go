@@ -24,6 +24,8 @@ type Service interface {
 	ResultRejected(context.Context, ResultRejectedRequest) (ResultRejectedResult, error)
 	// CancelJob cancels a job for a given executionID.
 	CancelJob(context.Context, CancelJobRequest) (CancelJobResult, error)
+	// ExplainBid explains whether the node would bid on a job, and why not, without bidding on it.
+	ExplainBid(context.Context, ExplainBidRequest) (ExplainBidResponse, error)
 }
 
 type AskForBidRequest struct {
@@ -82,3 +84,16 @@ type CancelJobRequest struct {
 
 type CancelJobResult struct {
 }
+
+type ExplainBidRequest struct {
+	// Job specifies the job that would be bid on.
+	Job model.Job
+}
+
+type ExplainBidResponse struct {
+	NodeID string
+	// Bid is whether the node would bid on the job.
+	Bid bool
+	// Reasons are all the reasons the node would not bid on the job.
+	Reasons []string `json:",omitempty"`
+}
@@ -2,6 +2,7 @@ package pubsub
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/filecoin-project/bacalhau/pkg/compute/backend"
@@ -75,6 +76,9 @@ func (p BackendCallback) OnPublishSuccess(
 		return
 	}
 	ev.PublishedResult = result.PublishResult
+	if result.Asynchronous {
+		ev.Status = "results are ready, publishing them in the background"
+	}
 	p.publishEventSilently(ctx, ev)
 }
 
@@ -82,6 +86,25 @@ func (p BackendCallback) OnPublishFailure(ctx context.Context, executionID strin
 	log.Ctx(ctx).Error().Msgf("error publishing execution %s: %s", executionID, err)
 }
 
+func (p BackendCallback) OnPublishProgress(ctx context.Context, executionID string, progress backend.PublishProgress) {
+	eventName := model.JobEventPublishProgress
+	if progress.Done && progress.Err == nil {
+		eventName = model.JobEventPublishCompleted
+	} else if progress.Done {
+		eventName = model.JobEventPublishFailed
+	}
+	ev, err := p.constructEvent(ctx, executionID, eventName)
+	if err != nil {
+		log.Ctx(ctx).Error().Msgf("error constructing event: %s", err.Error())
+		return
+	}
+	ev.PublishedResult = progress.PublishResult
+	if progress.Err != nil {
+		ev.Status = fmt.Sprintf("attempt %d to publish the results failed: %s", progress.Attempt, progress.Err)
+	}
+	p.publishEventSilently(ctx, ev)
+}
+
 func (p BackendCallback) OnCancelSuccess(ctx context.Context, executionID string, result backend.CancelResult) {
 	log.Ctx(ctx).Info().Msgf("execution %s canceled successfully", executionID)
 }
@@ -0,0 +1,56 @@
+//go:build unit || !integration
+
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/filecoin-project/bacalhau/pkg/compute/backend"
+	"github.com/filecoin-project/bacalhau/pkg/compute/store"
+	"github.com/filecoin-project/bacalhau/pkg/compute/store/inmemory"
+	"github.com/filecoin-project/bacalhau/pkg/eventhandler"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackendCallbackPublishProgress(t *testing.T) {
+	ctx := context.Background()
+	executionStore := inmemory.NewStore()
+	execution := *store.NewExecution("execution-id", model.JobShard{Job: &model.Job{ID: "job-id"}, Index: 1},
+		model.ResourceUsageData{})
+	require.NoError(t, executionStore.CreateExecution(ctx, execution))
+
+	var events []model.JobEvent
+	callback := NewBackendCallback(BackendCallbackParams{
+		NodeID:         "node-id",
+		ExecutionStore: executionStore,
+		JobEventPublisher: eventhandler.JobEventHandlerFunc(func(_ context.Context, event model.JobEvent) error {
+			events = append(events, event)
+			return nil
+		}),
+	})
+
+	publishErr := errors.New("publisher unavailable")
+	callback.OnPublishProgress(ctx, execution.ID, backend.PublishProgress{Attempt: 1, Err: publishErr})
+	callback.OnPublishProgress(ctx, execution.ID, backend.PublishProgress{Attempt: 2, Err: publishErr, Done: true})
+
+	require.Len(t, events, 2)
+	require.Equal(t, model.JobEventPublishProgress, events[0].EventName)
+	require.Equal(t, "attempt 1 to publish the results failed: publisher unavailable", events[0].Status)
+	require.Equal(t, model.JobEventPublishFailed, events[1].EventName)
+	require.Equal(t, "attempt 2 to publish the results failed: publisher unavailable", events[1].Status)
+	require.Equal(t, "job-id", events[1].JobID)
+	require.Equal(t, 1, events[1].ShardIndex)
+	require.Equal(t, "node-id", events[1].SourceNodeID)
+
+	callback.OnPublishProgress(ctx, execution.ID, backend.PublishProgress{
+		Attempt:       3,
+		Done:          true,
+		PublishResult: model.StorageSpec{CID: "cid"},
+	})
+	require.Len(t, events, 3)
+	require.Equal(t, model.JobEventPublishCompleted, events[2].EventName)
+	require.Equal(t, "cid", events[2].PublishedResult.CID)
+}
@@ -0,0 +1,183 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/filecoin-project/bacalhau/pkg/eventhandler"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/time/rate"
+)
+
+// EventDropPolicy is what an EventQueue does with job announcements when it
+// already holds as many of them as it can.
+type EventDropPolicy string
+
+const (
+	// DropNewest drops the announcement that arrived, keeping the queue as is.
+	DropNewest EventDropPolicy = "newest"
+	// DropOldest drops the announcement that has waited the longest, as its
+	// job is the most likely to have found enough nodes already.
+	DropOldest EventDropPolicy = "oldest"
+	// Block drops nothing, and holds up the transport until there's room.
+	Block EventDropPolicy = "block"
+)
+
+// ParseEventDropPolicy returns the drop policy with the name.
+func ParseEventDropPolicy(name string) (EventDropPolicy, error) {
+	switch policy := EventDropPolicy(name); policy {
+	case DropNewest, DropOldest, Block:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown event drop policy %q, must be %q, %q or %q", name, DropNewest, DropOldest, Block)
+	}
+}
+
+type EventQueueParams struct {
+	NodeID  string
+	Handler eventhandler.JobEventHandler
+	// Size is the most events of each priority held waiting to be handled.
+	Size int
+	// Workers is how many job announcements are handled at once.
+	Workers int
+	// DropPolicy is what happens to announcements that arrive when the queue
+	// already holds Size of them.
+	DropPolicy EventDropPolicy
+	// AnnouncementRate is the most announcements handled per second, or no
+	// limit if zero.
+	AnnouncementRate float64
+}
+
+// queuedEvent is an event waiting to be handled, with the context it
+// arrived with.
+type queuedEvent struct {
+	ctx   context.Context
+	event model.JobEvent
+}
+
+// EventQueue handles the job events from the transport in the background,
+// so that a storm of gossiped events doesn't hold up the executions of the
+// node. Events about bids and results of the node are handled first, in the
+// order they arrive, and are never dropped. Announcements of new jobs, which
+// the node bids on, are handled after them by a pool of workers, at a
+// limited rate, and are dropped by the DropPolicy when too many of them are
+// waiting.
+type EventQueue struct {
+	nodeID        string
+	handler       eventhandler.JobEventHandler
+	dropPolicy    EventDropPolicy
+	executions    chan queuedEvent
+	announcements chan queuedEvent
+	limiter       *rate.Limiter
+}
+
+func NewEventQueue(ctx context.Context, params EventQueueParams) *EventQueue {
+	limit := rate.Inf
+	if params.AnnouncementRate > 0 {
+		limit = rate.Limit(params.AnnouncementRate)
+	}
+	q := &EventQueue{
+		nodeID:        params.NodeID,
+		handler:       params.Handler,
+		dropPolicy:    params.DropPolicy,
+		executions:    make(chan queuedEvent, params.Size),
+		announcements: make(chan queuedEvent, params.Size),
+		limiter:       rate.NewLimiter(limit, 1),
+	}
+	go q.handleExecutions(ctx)
+	for i := 0; i < params.Workers; i++ {
+		go q.handleAnnouncements(ctx)
+	}
+	return q
+}
+
+// HandleJobEvent queues the event to be handled. Events the node doesn't act
+// on are handled straight away.
+func (q *EventQueue) HandleJobEvent(ctx context.Context, event model.JobEvent) error {
+	queued := queuedEvent{ctx: ctx, event: event}
+	switch event.EventName {
+	case model.JobEventBidAccepted, model.JobEventBidRejected, model.JobEventResultsAccepted,
+		model.JobEventResultsRejected, model.JobEventError:
+		q.push(q.executions, queued, Block, "high")
+	case model.JobEventCreated:
+		q.push(q.announcements, queued, q.dropPolicy, "low")
+	default:
+		return q.handler.HandleJobEvent(ctx, event)
+	}
+	return nil
+}
+
+func (q *EventQueue) push(queue chan queuedEvent, queued queuedEvent, dropPolicy EventDropPolicy, priority string) {
+	depth := eventQueueDepth.With(prometheus.Labels{"node_id": q.nodeID, "priority": priority})
+	for {
+		select {
+		case queue <- queued:
+			depth.Inc()
+			return
+		default:
+		}
+		switch dropPolicy {
+		case DropNewest:
+			q.drop(queued)
+			return
+		case DropOldest:
+			select {
+			case oldest := <-queue:
+				depth.Dec()
+				q.drop(oldest)
+			default:
+			}
+		default:
+			queue <- queued
+			depth.Inc()
+			return
+		}
+	}
+}
+
+func (q *EventQueue) drop(queued queuedEvent) {
+	eventsDropped.With(prometheus.Labels{"node_id": q.nodeID, "event": queued.event.EventName.String()}).Inc()
+	log.Ctx(queued.ctx).Warn().Msgf("dropping %s event for job %s, as too many events are waiting to be handled",
+		queued.event.EventName, queued.event.JobID)
+}
+
+func (q *EventQueue) handleExecutions(ctx context.Context) {
+	depth := eventQueueDepth.With(prometheus.Labels{"node_id": q.nodeID, "priority": "high"})
+	for {
+		select {
+		case queued := <-q.executions:
+			depth.Dec()
+			q.handle(queued)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (q *EventQueue) handleAnnouncements(ctx context.Context) {
+	depth := eventQueueDepth.With(prometheus.Labels{"node_id": q.nodeID, "priority": "low"})
+	for {
+		select {
+		case queued := <-q.announcements:
+			depth.Dec()
+			if err := q.limiter.Wait(ctx); err != nil {
+				return
+			}
+			q.handle(queued)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (q *EventQueue) handle(queued queuedEvent) {
+	if err := q.handler.HandleJobEvent(queued.ctx, queued.event); err != nil {
+		log.Ctx(queued.ctx).Error().Err(err).Msgf("error handling %s event for job %s",
+			queued.event.EventName, queued.event.JobID)
+	}
+}
+
+// Compile-time check that EventQueue implements the correct interface:
+var _ eventhandler.JobEventHandler = (*EventQueue)(nil)
@@ -0,0 +1,94 @@
+//go:build unit || !integration
+
+package pubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingHandler records the jobs of the events it handles, blocking on
+// release before each one so that events pile up in the queue.
+type recordingHandler struct {
+	release chan struct{}
+	handled chan string
+}
+
+func (h *recordingHandler) HandleJobEvent(ctx context.Context, event model.JobEvent) error {
+	<-h.release
+	h.handled <- event.JobID
+	return nil
+}
+
+func newTestQueue(t *testing.T, size int, dropPolicy EventDropPolicy) (*EventQueue, *recordingHandler) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	handler := &recordingHandler{release: make(chan struct{}), handled: make(chan string, 16)}
+	return NewEventQueue(ctx, EventQueueParams{
+		NodeID:     "node",
+		Handler:    handler,
+		Size:       size,
+		Workers:    1,
+		DropPolicy: dropPolicy,
+	}), handler
+}
+
+func announce(t *testing.T, q *EventQueue, jobIDs ...string) {
+	for _, jobID := range jobIDs {
+		require.NoError(t, q.HandleJobEvent(context.Background(), model.JobEvent{JobID: jobID, EventName: model.JobEventCreated}))
+	}
+}
+
+func handled(t *testing.T, h *recordingHandler, count int) []string {
+	var jobIDs []string
+	for i := 0; i < count; i++ {
+		h.release <- struct{}{}
+		select {
+		case jobID := <-h.handled:
+			jobIDs = append(jobIDs, jobID)
+		case <-time.After(time.Second):
+			require.Fail(t, "event was not handled")
+		}
+	}
+	return jobIDs
+}
+
+func TestEventQueueDropPolicies(t *testing.T) {
+	for _, testCase := range []struct {
+		policy   EventDropPolicy
+		expected []string
+	}{
+		{DropNewest, []string{"first", "second", "third"}},
+		{DropOldest, []string{"first", "third", "fourth"}},
+	} {
+		t.Run(string(testCase.policy), func(t *testing.T) {
+			q, h := newTestQueue(t, 2, testCase.policy)
+			announce(t, q, "first")
+			// wait for the worker to pick up the first event, so that the
+			// rest fill up the queue behind it
+			require.Eventually(t, func() bool { return len(q.announcements) == 0 }, time.Second, time.Millisecond)
+			announce(t, q, "second", "third", "fourth")
+			require.Equal(t, testCase.expected, handled(t, h, 3))
+		})
+	}
+}
+
+func TestEventQueueHandlesUnqueuedEventsInline(t *testing.T) {
+	q, h := newTestQueue(t, 1, DropNewest)
+	go func() { h.release <- struct{}{} }()
+	require.NoError(t, q.HandleJobEvent(context.Background(), model.JobEvent{JobID: "job", EventName: model.JobEventBid}))
+	require.Equal(t, "job", <-h.handled)
+}
+
+func TestParseEventDropPolicy(t *testing.T) {
+	policy, err := ParseEventDropPolicy("block")
+	require.NoError(t, err)
+	require.Equal(t, Block, policy)
+
+	_, err = ParseEventDropPolicy("random")
+	require.Error(t, err)
+}
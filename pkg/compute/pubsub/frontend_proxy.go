@@ -25,6 +25,16 @@ type FrontendEventProxyParams struct {
 	JobStore          localdb.LocalDB
 	ExecutionStore    store.ExecutionStore
 	JobEventPublisher eventhandler.JobEventHandler
+	// ReportBidDeclines publishes why the node declined to bid on a job.
+	ReportBidDeclines bool
+	// Zone is the zone the node says it is in when it bids.
+	Zone string
+	// NodeEnergy returns what the node says about the energy it runs on
+	// when it bids, if anything.
+	NodeEnergy func() *model.NodeEnergy
+	// NodeHardening is what the node says about the hardening of its
+	// containers when it bids, if anything.
+	NodeHardening *model.NodeHardening
 }
 
 // FrontendEventProxy listens to events from GossipSub and forwards them to the frontend.
@@ -36,6 +46,10 @@ type FrontendEventProxy struct {
 	jobStore          localdb.LocalDB
 	executionStore    store.ExecutionStore
 	jobEventPublisher eventhandler.JobEventHandler
+	reportBidDeclines bool
+	zone              string
+	nodeEnergy        func() *model.NodeEnergy
+	nodeHardening     *model.NodeHardening
 }
 
 // NewFrontendEventProxy create a new FrontendEventProxy from FrontendEventProxyParams
@@ -46,6 +60,10 @@ func NewFrontendEventProxy(params FrontendEventProxyParams) *FrontendEventProxy
 		jobStore:          params.JobStore,
 		executionStore:    params.ExecutionStore,
 		jobEventPublisher: params.JobEventPublisher,
+		reportBidDeclines: params.ReportBidDeclines,
+		zone:              params.Zone,
+		nodeEnergy:        params.NodeEnergy,
+		nodeHardening:     params.NodeHardening,
 	}
 }
 
@@ -95,6 +113,18 @@ func (p FrontendEventProxy) subscriptionEventCreated(ctx context.Context, event
 						shardResponse.ExecutionID, cancelError.Error())
 				}
 			}
+		} else if p.reportBidDeclines && shardResponse.Reason != "" {
+			declineErr := p.jobEventPublisher.HandleJobEvent(ctx, model.JobEvent{
+				SourceNodeID: p.nodeID,
+				JobID:        job.ID,
+				ShardIndex:   shardResponse.ShardIndex,
+				EventName:    model.JobEventBidDeclined,
+				Status:       shardResponse.Reason,
+				EventTime:    time.Now(),
+			})
+			if declineErr != nil {
+				log.Ctx(ctx).Warn().Err(declineErr).Msgf("error publishing bid decline for job %s", job.ID)
+			}
 		}
 	}
 	return nil
@@ -138,7 +168,8 @@ func (p FrontendEventProxy) triggerStateTransition(ctx context.Context, event mo
 		_, err = p.frontend.ResultAccepted(ctx, request)
 	case model.JobEventResultsRejected:
 		request := frontend.ResultRejectedRequest{
-			ExecutionID: activeExecution.ID,
+			ExecutionID:   activeExecution.ID,
+			Justification: event.Status,
 		}
 		_, err = p.frontend.ResultRejected(ctx, request)
 	case model.JobEventInvalidRequest, model.JobEventError:
@@ -180,7 +211,13 @@ func (p FrontendEventProxy) processBidJob(ctx context.Context, executionID strin
 		}
 	}
 
-	return p.jobEventPublisher.HandleJobEvent(ctx, p.constructEvent(ctx, execution, model.JobEventBid))
+	event := p.constructEvent(ctx, execution, model.JobEventBid)
+	event.SourceNodeZone = p.zone
+	if p.nodeEnergy != nil {
+		event.SourceNodeEnergy = p.nodeEnergy()
+	}
+	event.SourceNodeHardening = p.nodeHardening
+	return p.jobEventPublisher.HandleJobEvent(ctx, event)
 }
 
 func (p FrontendEventProxy) constructEvent(ctx context.Context, execution store.Execution, eventName model.JobEventType) model.JobEvent {
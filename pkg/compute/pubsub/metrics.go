@@ -0,0 +1,25 @@
+package pubsub
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Prometheus metrics for monitoring the events handled by compute nodes:
+var (
+	eventQueueDepth = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "compute_event_queue_depth",
+			Help: "Number of events waiting to be handled by the compute node.",
+		},
+		[]string{"node_id", "priority"},
+	)
+
+	eventsDropped = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "compute_events_dropped",
+			Help: "Number of events dropped by the compute node as too many were waiting to be handled.",
+		},
+		[]string{"node_id", "event"},
+	)
+)
@@ -0,0 +1,191 @@
+package reconciler
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/bacalhau/pkg/compute/store"
+	"github.com/filecoin-project/bacalhau/pkg/executor"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/rs/zerolog/log"
+	"go.uber.org/multierr"
+)
+
+// TempDirPatterns are the temporary directories that nodes make for the
+// executions they run, and the storage and verifiers they run them with,
+// which they remove when they are done with them unless they crash.
+var TempDirPatterns = []string{
+	"bacalhau-estuary-publisher*",
+	"bacalhau-filecoin-retrieval*",
+	"bacalhau-firecracker-*",
+	"bacalhau-inline*",
+	"bacalhau-ipfs*",
+	"bacalhau-pin-context-*",
+	"bacalhau-results*",
+	"bacalhau-scratch-*",
+	"bacalhau-slurm-*",
+	"bacalhau-transform*",
+	"bacalhau-url*",
+}
+
+// processStart is when this process started, before it made any temporary
+// directory of its own.
+var processStart = time.Now()
+
+// Executions are the executions a compute node is running or about to run,
+// whose containers and directories are not to be removed.
+type Executions interface {
+	RunningExecutions() []store.Execution
+	EnqueuedExecutions() []store.Execution
+}
+
+type ReconcilerParams struct {
+	Executors  executor.ExecutorProvider
+	Executions Executions
+	// The directories to look for the temporary directories of TempDirPatterns in.
+	TempDirs []string
+	// How long temporary directories are left untouched before they are
+	// removed, so that those of other nodes running on the same host aren't.
+	MinTempDirAge time.Duration
+	// How often to reconcile after the first time.
+	Interval time.Duration
+}
+
+// Reconciler removes what compute nodes that crashed left behind: the
+// containers of the jobs they no longer run, and the temporary directories
+// made before the node started. It reconciles when the node starts, and
+// periodically after.
+type Reconciler struct {
+	executors     executor.ExecutorProvider
+	executions    Executions
+	tempDirs      []string
+	minTempDirAge time.Duration
+	interval      time.Duration
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+func NewReconciler(params ReconcilerParams) *Reconciler {
+	return &Reconciler{
+		executors:     params.Executors,
+		executions:    params.Executions,
+		tempDirs:      params.TempDirs,
+		minTempDirAge: params.MinTempDirAge,
+		interval:      params.Interval,
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+}
+
+// Start reconciles straight away, and then every interval until the context
+// is done or the reconciler is stopped.
+func (r *Reconciler) Start(ctx context.Context) {
+	defer close(r.done)
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		removed, err := r.Reconcile(ctx)
+		if err != nil {
+			log.Ctx(ctx).Warn().Err(err).Msg("Failed to remove some of what crashed executions left behind")
+		}
+		if removed > 0 {
+			log.Ctx(ctx).Info().Msgf("Removed %d containers and temporary directories left behind by crashed executions", removed)
+		}
+		select {
+		case <-ticker.C:
+		case <-r.stop:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Stop stops the reconciler and waits for a reconciliation in progress to
+// finish, as a callback of the cleanup manager of the node.
+func (r *Reconciler) Stop() error {
+	r.stopOnce.Do(func() { close(r.stop) })
+	<-r.done
+	return nil
+}
+
+// Reconcile removes the containers of the jobs the node doesn't run and the
+// temporary directories left behind by earlier runs of nodes, and returns how
+// many it removed.
+func (r *Reconciler) Reconcile(ctx context.Context) (int, error) {
+	running := map[string]bool{}
+	for _, execution := range append(r.executions.RunningExecutions(), r.executions.EnqueuedExecutions()...) {
+		running[execution.Shard.Job.ID] = true
+	}
+	isRunning := func(jobID string) bool { return running[jobID] }
+
+	removed := 0
+	var errs error
+	// the same executor may run several engines
+	collected := map[executor.GarbageCollector]bool{}
+	for _, engine := range model.EngineTypes() {
+		if !r.executors.HasExecutor(ctx, engine) {
+			continue
+		}
+		e, err := r.executors.GetExecutor(ctx, engine)
+		if err != nil {
+			continue
+		}
+		collector, ok := e.(executor.GarbageCollector)
+		if !ok || collected[collector] {
+			continue
+		}
+		collected[collector] = true
+		count, err := collector.CollectGarbage(ctx, isRunning)
+		removed += count
+		errs = multierr.Append(errs, err)
+	}
+
+	count, err := r.removeTempDirs(ctx)
+	removed += count
+	errs = multierr.Append(errs, err)
+	return removed, errs
+}
+
+// removeTempDirs removes the temporary directories made before this process
+// started that haven't changed for the minimum age, which this process can't
+// be using.
+func (r *Reconciler) removeTempDirs(ctx context.Context) (int, error) {
+	removed := 0
+	var errs error
+	seen := map[string]bool{}
+	for _, dir := range r.tempDirs {
+		dir = filepath.Clean(dir)
+		if seen[dir] {
+			continue
+		}
+		seen[dir] = true
+		for _, pattern := range TempDirPatterns {
+			paths, err := filepath.Glob(filepath.Join(dir, pattern))
+			if err != nil {
+				return removed, err
+			}
+			for _, path := range paths {
+				info, err := os.Lstat(path)
+				if err != nil || !info.IsDir() {
+					continue
+				}
+				if !info.ModTime().Before(processStart) || time.Since(info.ModTime()) < r.minTempDirAge {
+					continue
+				}
+				if err = os.RemoveAll(path); err != nil {
+					errs = multierr.Append(errs, err)
+					continue
+				}
+				log.Ctx(ctx).Debug().Msgf("Removed orphaned temporary directory %s", path)
+				removed++
+			}
+		}
+	}
+	return removed, errs
+}
@@ -0,0 +1,100 @@
+//go:build unit || !integration
+
+package reconciler
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/bacalhau/pkg/compute/store"
+	"github.com/filecoin-project/bacalhau/pkg/executor"
+	noop_executor "github.com/filecoin-project/bacalhau/pkg/executor/noop"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeExecutions struct {
+	running []store.Execution
+}
+
+func (f fakeExecutions) RunningExecutions() []store.Execution  { return f.running }
+func (f fakeExecutions) EnqueuedExecutions() []store.Execution { return nil }
+
+// collectingExecutor is an executor that has left behind the containers of
+// some jobs.
+type collectingExecutor struct {
+	*noop_executor.NoopExecutor
+	containers map[string]bool
+}
+
+func (e *collectingExecutor) CollectGarbage(_ context.Context, running func(jobID string) bool) (int, error) {
+	removed := 0
+	for jobID := range e.containers {
+		if !running(jobID) {
+			delete(e.containers, jobID)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+func makeTempDir(t *testing.T, path string, modTime time.Time) {
+	require.NoError(t, os.MkdirAll(filepath.Join(path, "inputs"), 0700))
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+func TestReconcile(t *testing.T) {
+	collector := &collectingExecutor{
+		NoopExecutor: noop_executor.NewNoopExecutor(),
+		containers:   map[string]bool{"crashed": true, "running": true},
+	}
+	running := store.Execution{Shard: model.JobShard{Job: &model.Job{ID: "running"}}}
+
+	tempDir := t.TempDir()
+	stale := processStart.Add(-2 * time.Hour)
+	makeTempDir(t, filepath.Join(tempDir, "bacalhau-scratch-123"), stale)
+	makeTempDir(t, filepath.Join(tempDir, "bacalhau-estuary-publisher456"), stale)
+	// recent enough to be another node's
+	makeTempDir(t, filepath.Join(tempDir, "bacalhau-results789"), processStart.Add(-time.Minute))
+	// made by this process
+	makeTempDir(t, filepath.Join(tempDir, "bacalhau-ipfs000"), time.Now())
+	// not ours
+	makeTempDir(t, filepath.Join(tempDir, "other-scratch-123"), stale)
+
+	r := NewReconciler(ReconcilerParams{
+		Executors: executor.NewTypeExecutorProvider(map[model.Engine]executor.Executor{
+			model.EngineDocker: collector,
+			model.EngineNoop:   collector,
+		}),
+		Executions:    fakeExecutions{running: []store.Execution{running}},
+		TempDirs:      []string{tempDir, tempDir + "/"},
+		MinTempDirAge: time.Hour,
+		Interval:      time.Hour,
+	})
+	removed, err := r.Reconcile(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 3, removed)
+	require.Equal(t, map[string]bool{"running": true}, collector.containers)
+
+	entries, err := os.ReadDir(tempDir)
+	require.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	require.ElementsMatch(t, []string{"bacalhau-results789", "bacalhau-ipfs000", "other-scratch-123"}, names)
+}
+
+func TestReconcilerStop(t *testing.T) {
+	r := NewReconciler(ReconcilerParams{
+		Executors:  executor.NewTypeExecutorProvider(map[model.Engine]executor.Executor{}),
+		Executions: fakeExecutions{},
+		Interval:   time.Hour,
+	})
+	go r.Start(context.Background())
+	require.NoError(t, r.Stop())
+	require.NoError(t, r.Stop())
+}
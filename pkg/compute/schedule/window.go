@@ -0,0 +1,221 @@
+// Package schedule parses and checks the recurring windows of time that a
+// compute node is available to run jobs in, or is down for maintenance in.
+package schedule
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxWindowDuration is the longest a window can last.
+const MaxWindowDuration = 31 * 24 * time.Hour
+
+// Window is a recurring window of time. It starts at the times matched by a
+// cron expression, in the local time of the node, and lasts for a duration,
+// e.g. "0 18 * * mon-fri 14h" is every weeknight from 6pm to 8am.
+type Window struct {
+	spec     string
+	minute   field
+	hour     field
+	dom      field
+	month    field
+	dow      field
+	duration time.Duration
+}
+
+// field is the values of a time field a cron expression matches, and
+// whether it matches all of them.
+type field struct {
+	values map[int]bool
+	any    bool
+}
+
+var (
+	monthNames = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+	dayNames   = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+)
+
+// ParseWindow parses a window written as the five fields of a cron
+// expression, minute hour day-of-month month day-of-week, followed by how
+// long the window lasts. Fields can be *, numbers, names of months and days,
+// ranges, lists and steps, e.g. "*/30 9-17 * jan,jul 1-5 15m".
+func ParseWindow(spec string) (Window, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != 6 {
+		return Window{}, fmt.Errorf(
+			"invalid window %q, must be a cron expression and a duration, e.g. '0 18 * * mon-fri 14h'", spec)
+	}
+	window := Window{spec: spec}
+	var err error
+	for _, f := range []struct {
+		value    string
+		parsed   *field
+		min, max int
+		names    []string
+	}{
+		{fields[0], &window.minute, 0, 59, nil},
+		{fields[1], &window.hour, 0, 23, nil},
+		{fields[2], &window.dom, 1, 31, nil},
+		{fields[3], &window.month, 1, 12, monthNames},
+		{fields[4], &window.dow, 0, 7, dayNames},
+	} {
+		if *f.parsed, err = parseField(f.value, f.min, f.max, f.names); err != nil {
+			return Window{}, fmt.Errorf("invalid window %q: %w", spec, err)
+		}
+	}
+	// Sunday is both 0 and 7
+	if window.dow.values[7] {
+		window.dow.values[0] = true
+	}
+
+	window.duration, err = time.ParseDuration(fields[5])
+	if err != nil {
+		return Window{}, fmt.Errorf("invalid window %q: %w", spec, err)
+	}
+	if window.duration < time.Minute || window.duration > MaxWindowDuration {
+		return Window{}, fmt.Errorf("invalid window %q: must last between 1m and %s", spec, MaxWindowDuration)
+	}
+	return window, nil
+}
+
+// ParseWindows parses each of the windows, see ParseWindow.
+func ParseWindows(specs []string) ([]Window, error) {
+	windows := make([]Window, 0, len(specs))
+	for _, spec := range specs {
+		window, err := ParseWindow(spec)
+		if err != nil {
+			return nil, err
+		}
+		windows = append(windows, window)
+	}
+	return windows, nil
+}
+
+func parseField(value string, min, max int, names []string) (field, error) {
+	parsed := field{values: map[int]bool{}}
+	for _, part := range strings.Split(value, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepPart); err != nil || step <= 0 {
+				return field{}, fmt.Errorf("invalid step %q", stepPart)
+			}
+		}
+
+		var from, to int
+		if rangePart == "*" {
+			from, to = min, max
+			parsed.any = parsed.any || !hasStep
+		} else {
+			lowPart, highPart, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if from, err = parseValue(lowPart, min, max, names); err != nil {
+				return field{}, err
+			}
+			to = from
+			if isRange {
+				if to, err = parseValue(highPart, min, max, names); err != nil {
+					return field{}, err
+				}
+			} else if hasStep {
+				to = max
+			}
+			if to < from {
+				return field{}, fmt.Errorf("invalid range %q", rangePart)
+			}
+		}
+		for v := from; v <= to; v += step {
+			parsed.values[v] = true
+		}
+	}
+	return parsed, nil
+}
+
+func parseValue(value string, min, max int, names []string) (int, error) {
+	for i, name := range names {
+		if strings.EqualFold(value, name) {
+			// months are numbered from one
+			return i + min, nil
+		}
+	}
+	v, err := strconv.Atoi(value)
+	if err != nil || v < min || v > max {
+		return 0, fmt.Errorf("invalid value %q, must be between %d and %d", value, min, max)
+	}
+	return v, nil
+}
+
+func (w Window) String() string {
+	return w.spec
+}
+
+// startsAt returns true if the window starts at the minute of t.
+func (w Window) startsAt(t time.Time) bool {
+	if !w.minute.values[t.Minute()] || !w.hour.values[t.Hour()] || !w.month.values[int(t.Month())] {
+		return false
+	}
+	// as in cron, if both days are restricted either of them matches
+	domMatches, dowMatches := w.dom.values[t.Day()], w.dow.values[int(t.Weekday())]
+	if w.dom.any || w.dow.any {
+		return domMatches && dowMatches
+	}
+	return domMatches || dowMatches
+}
+
+type interval struct {
+	start, end time.Time
+}
+
+// intervals returns when the windows are open between from and to, merged
+// and in order.
+func intervals(windows []Window, from, to time.Time) []interval {
+	var open []interval
+	for _, window := range windows {
+		for t := from.Add(-window.duration).Truncate(time.Minute); !t.After(to); t = t.Add(time.Minute) {
+			if !window.startsAt(t) {
+				continue
+			}
+			end := t.Add(window.duration)
+			if !end.After(from) {
+				continue
+			}
+			open = append(open, interval{start: t, end: end})
+		}
+	}
+
+	sort.Slice(open, func(i, j int) bool {
+		return open[i].start.Before(open[j].start)
+	})
+	merged := make([]interval, 0, len(open))
+	for _, next := range open {
+		if last := len(merged) - 1; last >= 0 && !next.start.After(merged[last].end) {
+			if next.end.After(merged[last].end) {
+				merged[last].end = next.end
+			}
+			continue
+		}
+		merged = append(merged, next)
+	}
+	return merged
+}
+
+// Covers returns true if the windows are open for all of the time between
+// from and to.
+func Covers(windows []Window, from, to time.Time) bool {
+	for _, open := range intervals(windows, from, to) {
+		if !open.start.After(from) && !open.end.Before(to) {
+			return true
+		}
+	}
+	return false
+}
+
+// Overlaps returns true if any of the windows is open at any time between
+// from and to.
+func Overlaps(windows []Window, from, to time.Time) bool {
+	return len(intervals(windows, from, to)) > 0
+}
@@ -0,0 +1,88 @@
+//go:build unit || !integration
+
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// a Monday
+var monday = time.Date(2023, time.January, 2, 0, 0, 0, 0, time.Local)
+
+func at(day, hour, minute int) time.Time {
+	return monday.AddDate(0, 0, day).Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute)
+}
+
+func TestParseWindow(t *testing.T) {
+	for _, spec := range []string{
+		"0 18 * * mon-fri 14h",
+		"*/15 9-17 * jan,jul 1-5 15m",
+		"0 0 1 * * 48h",
+		"30 2 * * 7 1h30m",
+	} {
+		_, err := ParseWindow(spec)
+		require.NoError(t, err, spec)
+	}
+	for _, spec := range []string{
+		"",
+		"0 18 * * mon-fri",
+		"60 18 * * * 1h",
+		"0 18 * * fri-mon 1h",
+		"0 18 * foo * 1h",
+		"*/0 * * * * 1h",
+		"0 18 * * * 30s",
+		"0 18 * * * 800h",
+		"0 18 * * * soon",
+	} {
+		_, err := ParseWindow(spec)
+		require.Error(t, err, spec)
+	}
+}
+
+func TestCovers(t *testing.T) {
+	// weeknights from 6pm to 8am
+	windows, err := ParseWindows([]string{"0 18 * * mon-fri 14h"})
+	require.NoError(t, err)
+
+	require.True(t, Covers(windows, at(0, 18, 0), at(0, 18, 0)))
+	require.True(t, Covers(windows, at(0, 23, 0), at(1, 7, 59)))
+	require.True(t, Covers(windows, at(0, 18, 0), at(1, 8, 0)))
+	require.False(t, Covers(windows, at(0, 17, 59), at(0, 17, 59)))
+	require.False(t, Covers(windows, at(0, 23, 0), at(1, 8, 1)))
+	require.False(t, Covers(windows, at(1, 8, 0), at(1, 8, 0)))
+	// Friday night runs into Saturday, but there is no window on Saturday
+	require.True(t, Covers(windows, at(5, 7, 0), at(5, 7, 0)))
+	require.False(t, Covers(windows, at(5, 20, 0), at(5, 20, 0)))
+}
+
+func TestCoversAcrossWindows(t *testing.T) {
+	// windows that run into each other are one window
+	windows, err := ParseWindows([]string{"0 0 * * * 12h", "0 12 * * * 12h"})
+	require.NoError(t, err)
+	require.True(t, Covers(windows, at(0, 6, 0), at(2, 6, 0)))
+}
+
+func TestOverlaps(t *testing.T) {
+	// Sundays from 2am to 6am
+	windows, err := ParseWindows([]string{"0 2 * * sun 4h"})
+	require.NoError(t, err)
+
+	require.True(t, Overlaps(windows, at(6, 3, 0), at(6, 3, 0)))
+	require.True(t, Overlaps(windows, at(6, 1, 0), at(6, 2, 30)))
+	require.False(t, Overlaps(windows, at(6, 0, 0), at(6, 1, 59)))
+	require.False(t, Overlaps(windows, at(6, 6, 0), at(6, 12, 0)))
+	require.False(t, Overlaps(windows, at(0, 0, 0), at(5, 23, 0)))
+	require.False(t, Overlaps(nil, at(0, 0, 0), at(6, 23, 0)))
+}
+
+func TestDayOfMonthOrWeek(t *testing.T) {
+	// as in cron, the 1st of the month or Mondays
+	windows, err := ParseWindows([]string{"0 0 1 * mon 1h"})
+	require.NoError(t, err)
+	require.True(t, Covers(windows, at(0, 0, 30), at(0, 0, 30)))
+	require.True(t, Covers(windows, at(-1, 0, 30), at(-1, 0, 30)))
+	require.False(t, Covers(windows, at(1, 0, 30), at(1, 0, 30)))
+}
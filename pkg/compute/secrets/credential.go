@@ -0,0 +1,73 @@
+package secrets
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultRefreshInterval is how long credentials are used for by default
+// before they are fetched again, so that rotating them doesn't need a
+// restart of the node.
+const DefaultRefreshInterval = 5 * time.Minute
+
+type CredentialParams struct {
+	// Where the credential is fetched from.
+	Provider Provider
+	// The name of the secret of the credential.
+	Name string
+	// How long to use the credential for before fetching it again.
+	RefreshInterval time.Duration
+}
+
+// Credential is a credential of the node, such as the API key of a
+// publisher, that is fetched from a secrets backend when it's needed and
+// refreshed periodically. A nil Credential is an empty credential.
+type Credential struct {
+	provider        Provider
+	name            string
+	refreshInterval time.Duration
+
+	mu      sync.Mutex
+	value   string
+	fetched time.Time
+}
+
+func NewCredential(params CredentialParams) *Credential {
+	if params.RefreshInterval <= 0 {
+		params.RefreshInterval = DefaultRefreshInterval
+	}
+	return &Credential{
+		provider:        params.Provider,
+		name:            params.Name,
+		refreshInterval: params.RefreshInterval,
+	}
+}
+
+// Get returns the credential, fetching it again if it was fetched more than
+// the refresh interval ago. The last value fetched keeps being used while
+// the backend can't be reached.
+func (c *Credential) Get(ctx context.Context) (string, error) {
+	if c == nil {
+		return "", nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.fetched.IsZero() && time.Since(c.fetched) < c.refreshInterval {
+		return c.value, nil
+	}
+
+	value, err := c.provider.GetSecret(ctx, c.name)
+	if err != nil {
+		if c.fetched.IsZero() {
+			return "", err
+		}
+		log.Ctx(ctx).Warn().Err(err).Msgf("Could not refresh credential %s, using the last one fetched", c.name)
+		return c.value, nil
+	}
+	c.value = value
+	c.fetched = time.Now()
+	return value, nil
+}
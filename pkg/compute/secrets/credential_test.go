@@ -0,0 +1,67 @@
+//go:build unit || !integration
+
+package secrets
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeProvider struct {
+	values map[string]string
+	err    error
+	calls  int
+}
+
+func (p *fakeProvider) GetSecret(_ context.Context, name string) (string, error) {
+	p.calls++
+	if p.err != nil {
+		return "", p.err
+	}
+	value, ok := p.values[name]
+	if !ok {
+		return "", ErrSecretNotFound
+	}
+	return value, nil
+}
+
+func TestCredential(t *testing.T) {
+	ctx := context.Background()
+	var nilCredential *Credential
+	value, err := nilCredential.Get(ctx)
+	require.NoError(t, err)
+	require.Empty(t, value)
+
+	provider := &fakeProvider{values: map[string]string{"key": "v1"}}
+	credential := NewCredential(CredentialParams{Provider: provider, Name: "key", RefreshInterval: time.Hour})
+	for i := 0; i < 2; i++ {
+		value, err = credential.Get(ctx)
+		require.NoError(t, err)
+		require.Equal(t, "v1", value)
+	}
+	require.Equal(t, 1, provider.calls, "the credential is cached")
+
+	// the credential is rotated
+	provider.values["key"] = "v2"
+	credential.fetched = time.Now().Add(-2 * time.Hour)
+	value, err = credential.Get(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "v2", value)
+
+	// the backend goes down
+	provider.err = errors.New("unreachable")
+	credential.fetched = time.Now().Add(-2 * time.Hour)
+	value, err = credential.Get(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "v2", value)
+
+	_, err = NewCredential(CredentialParams{Provider: provider, Name: "key"}).Get(ctx)
+	require.ErrorContains(t, err, "unreachable")
+	provider.err = nil
+	_, err = NewCredential(CredentialParams{Provider: provider, Name: "missing"}).Get(ctx)
+	require.ErrorIs(t, err, ErrSecretNotFound)
+}
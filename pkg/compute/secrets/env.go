@@ -0,0 +1,31 @@
+package secrets
+
+import (
+	"context"
+	"os"
+	"strings"
+)
+
+// EnvProvider reads secrets from the environment variables of the node,
+// named by the secret after a prefix, with slashes as underscores.
+type EnvProvider struct {
+	prefix string
+}
+
+func NewEnvProvider(prefix string) *EnvProvider {
+	if prefix == "" {
+		prefix = DefaultEnvPrefix
+	}
+	return &EnvProvider{prefix: prefix}
+}
+
+func (p *EnvProvider) GetSecret(_ context.Context, name string) (string, error) {
+	value, ok := os.LookupEnv(p.prefix + strings.ReplaceAll(name, "/", "_"))
+	if !ok {
+		return "", ErrSecretNotFound
+	}
+	return value, nil
+}
+
+// Compile-time interface check:
+var _ Provider = (*EnvProvider)(nil)
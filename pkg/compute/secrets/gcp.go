@@ -0,0 +1,106 @@
+package secrets
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/filecoin-project/bacalhau/pkg/storage/cloud/gcs"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// DefaultGCPEndpoint is the endpoint of the Secret Manager API.
+	DefaultGCPEndpoint = "https://secretmanager.googleapis.com"
+
+	gcpScope = "https://www.googleapis.com/auth/cloud-platform"
+)
+
+type GCPParams struct {
+	// The project the secrets are in.
+	Project string
+	// A service account key or authorized user credentials file, defaults to
+	// $GOOGLE_APPLICATION_CREDENTIALS. Without one, the credentials of the
+	// GCE VM the node runs on are used.
+	CredentialsFile string
+	// The endpoint of the Secret Manager API, defaults to DefaultGCPEndpoint.
+	Endpoint string
+}
+
+// GCPProvider reads the latest versions of secrets from Google Cloud Secret
+// Manager. Secret Manager doesn't allow slashes in names, so they are read
+// as dashes.
+type GCPProvider struct {
+	project  string
+	endpoint string
+	client   *http.Client
+}
+
+func NewGCPProvider(ctx context.Context, params GCPParams) (*GCPProvider, error) {
+	if params.Project == "" {
+		return nil, fmt.Errorf("the gcp secrets backend needs a project")
+	}
+	if params.CredentialsFile == "" {
+		params.CredentialsFile = os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	}
+	tokenSource, err := gcs.NewTokenSource(ctx, params.CredentialsFile, gcpScope)
+	if err != nil {
+		return nil, err
+	}
+	return newGCPProvider(params, oauth2.NewClient(ctx, tokenSource)), nil
+}
+
+func newGCPProvider(params GCPParams, client *http.Client) *GCPProvider {
+	if params.Endpoint == "" {
+		params.Endpoint = DefaultGCPEndpoint
+	}
+	return &GCPProvider{
+		project:  params.Project,
+		endpoint: strings.TrimSuffix(params.Endpoint, "/"),
+		client:   client,
+	}
+}
+
+func (p *GCPProvider) GetSecret(ctx context.Context, name string) (string, error) {
+	url := fmt.Sprintf("%s/v1/projects/%s/secrets/%s/versions/latest:access",
+		p.endpoint, p.project, strings.ReplaceAll(name, "/", "-"))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	res, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return "", ErrSecretNotFound
+	}
+	if res.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return "", fmt.Errorf("secret manager returned %s: %s", res.Status, strings.TrimSpace(string(body)))
+	}
+
+	var version struct {
+		Payload struct {
+			Data string `json:"data"`
+		} `json:"payload"`
+	}
+	if err = json.NewDecoder(res.Body).Decode(&version); err != nil {
+		return "", fmt.Errorf("could not decode the secret manager response: %w", err)
+	}
+	value, err := base64.StdEncoding.DecodeString(version.Payload.Data)
+	if err != nil {
+		return "", fmt.Errorf("could not decode secret %s: %w", name, err)
+	}
+	return string(value), nil
+}
+
+// Compile-time interface check:
+var _ Provider = (*GCPProvider)(nil)
@@ -0,0 +1,75 @@
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+// ErrSecretNotAllowed is returned for secrets the node doesn't let a job
+// request.
+var ErrSecretNotAllowed = errors.New("secret not allowed")
+
+// Policy is which of the secrets of the backend jobs may request, and the
+// clients whose jobs may request each of them. Any job can name any secret,
+// and the backend can hold secrets that aren't meant for jobs, so jobs may
+// only request the secrets the node allows.
+type Policy struct {
+	// Allowed maps the names of the secrets jobs may request to the IDs of
+	// the clients whose jobs may request them, or to no IDs if the jobs of
+	// any client may. Jobs may request no secrets if empty.
+	Allowed map[string][]string
+	// Reserved are the names of the secrets of the credentials of the node
+	// itself, such as the API key of a publisher, which jobs may never
+	// request.
+	Reserved []string
+}
+
+// ParsePolicy returns the policy allowing the secrets, each given as NAME to
+// allow the jobs of any client to request it, or as NAME=CLIENT_ID to allow
+// the jobs of the client. A name can be given once per client. None of the
+// reserved secrets can be allowed.
+func ParsePolicy(allowed, reserved []string) (Policy, error) {
+	policy := Policy{Allowed: map[string][]string{}, Reserved: reserved}
+	anyClient := map[string]bool{}
+	for _, secret := range allowed {
+		name, clientID, hasClient := strings.Cut(secret, "=")
+		if name == "" || (hasClient && clientID == "") {
+			return Policy{}, fmt.Errorf("invalid allowed secret %q, must be NAME or NAME=CLIENT_ID", secret)
+		}
+		if slices.Contains(reserved, name) {
+			return Policy{}, fmt.Errorf("secret %s is a credential of the node, which jobs can't be allowed to request", name)
+		}
+		if !hasClient {
+			anyClient[name] = true
+		}
+		clientIDs := policy.Allowed[name]
+		if hasClient && !slices.Contains(clientIDs, clientID) {
+			clientIDs = append(clientIDs, clientID)
+		}
+		policy.Allowed[name] = clientIDs
+	}
+	for name := range anyClient {
+		policy.Allowed[name] = nil
+	}
+	return policy, nil
+}
+
+// Check returns an error wrapping ErrSecretNotAllowed if the jobs of the
+// client may not request the secret.
+func (p Policy) Check(clientID, name string) error {
+	if slices.Contains(p.Reserved, name) {
+		return fmt.Errorf("%w: secret %s is a credential of the node", ErrSecretNotAllowed, name)
+	}
+	clientIDs, ok := p.Allowed[name]
+	if !ok {
+		return fmt.Errorf("%w: the node does not let jobs request secret %s", ErrSecretNotAllowed, name)
+	}
+	if len(clientIDs) > 0 && !slices.Contains(clientIDs, clientID) {
+		return fmt.Errorf("%w: the node does not let the jobs of client %s request secret %s",
+			ErrSecretNotAllowed, clientID, name)
+	}
+	return nil
+}
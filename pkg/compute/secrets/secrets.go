@@ -0,0 +1,99 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+)
+
+// ErrSecretNotFound is returned by providers for secrets they don't have.
+var ErrSecretNotFound = errors.New("secret not found")
+
+// Provider fetches the values of the secrets of jobs on compute nodes, so
+// that they are never part of the job spec.
+type Provider interface {
+	GetSecret(ctx context.Context, name string) (string, error)
+}
+
+const (
+	BackendEnv   = "env"
+	BackendFile  = "file"
+	BackendVault = "vault"
+	BackendGCP   = "gcp"
+)
+
+// DefaultEnvPrefix is what the environment variables of secrets start with by
+// default, so that jobs can't read any variable of the node.
+const DefaultEnvPrefix = "BACALHAU_SECRET_"
+
+type Params struct {
+	// Backend is where secrets are fetched from: env, file, vault or gcp.
+	// Jobs with secrets can't run if empty.
+	Backend string
+	// EnvPrefix is what the environment variables of secrets start with.
+	EnvPrefix string
+	// Dir is the directory the files of secrets are in.
+	Dir string
+	// Vault is how to reach the Vault server.
+	Vault VaultParams
+	// GCP is where the secrets are in Google Cloud Secret Manager.
+	GCP GCPParams
+}
+
+// NewProvider returns the provider of the backend, or nil if there is none.
+func NewProvider(ctx context.Context, params Params) (Provider, error) {
+	switch params.Backend {
+	case "":
+		return nil, nil
+	case BackendEnv:
+		return NewEnvProvider(params.EnvPrefix), nil
+	case BackendFile:
+		provider, err := NewFileProvider(params.Dir)
+		if err != nil {
+			return nil, err
+		}
+		return provider, nil
+	case BackendVault:
+		provider, err := NewVaultProvider(params.Vault)
+		if err != nil {
+			return nil, err
+		}
+		return provider, nil
+	case BackendGCP:
+		provider, err := NewGCPProvider(ctx, params.GCP)
+		if err != nil {
+			return nil, err
+		}
+		return provider, nil
+	default:
+		return nil, fmt.Errorf("unknown secrets backend %q, must be one of %s, %s, %s or %s",
+			params.Backend, BackendEnv, BackendFile, BackendVault, BackendGCP)
+	}
+}
+
+// Env returns the environment variables to run the job with: those of its
+// spec, and its secrets fetched from the provider if the policy allows the
+// job to request them.
+func Env(ctx context.Context, provider Provider, policy Policy, j *model.Job) (map[string]string, error) {
+	spec := j.Spec
+	env := make(map[string]string, len(spec.Env)+len(spec.Secrets))
+	for name, value := range spec.Env {
+		env[name] = value
+	}
+	if len(spec.Secrets) > 0 && provider == nil {
+		return nil, fmt.Errorf("the job has secrets but the node has no secrets backend")
+	}
+	for _, secret := range spec.Secrets {
+		if err := policy.Check(j.ClientID, secret.Name); err != nil {
+			return nil, err
+		}
+		value, err := provider.GetSecret(ctx, secret.Name)
+		if err != nil {
+			return nil, fmt.Errorf("could not get secret %s: %w", secret.Name, err)
+		}
+		env[secret.EnvName()] = value
+	}
+	return env, nil
+}
@@ -0,0 +1,188 @@
+//go:build unit || !integration
+
+package secrets
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvProvider(t *testing.T) {
+	t.Setenv("BACALHAU_SECRET_TOKEN", "s3cret")
+	t.Setenv("BACALHAU_SECRET_aws_key", "key")
+	t.Setenv("TOKEN", "not a secret")
+	provider, err := NewProvider(context.Background(), Params{Backend: BackendEnv})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	value, err := provider.GetSecret(ctx, "TOKEN")
+	require.NoError(t, err)
+	require.Equal(t, "s3cret", value)
+	value, err = provider.GetSecret(ctx, "aws/key")
+	require.NoError(t, err)
+	require.Equal(t, "key", value)
+	_, err = provider.GetSecret(ctx, "PATH")
+	require.ErrorIs(t, err, ErrSecretNotFound)
+}
+
+func TestFileProvider(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "aws"), os.ModePerm))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "TOKEN"), []byte("s3cret\n"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "aws", "key"), []byte("key"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(filepath.Dir(dir), "outside"), []byte("outside"), 0600))
+
+	_, err := NewProvider(context.Background(), Params{Backend: BackendFile})
+	require.Error(t, err)
+	provider, err := NewProvider(context.Background(), Params{Backend: BackendFile, Dir: dir})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	value, err := provider.GetSecret(ctx, "TOKEN")
+	require.NoError(t, err)
+	require.Equal(t, "s3cret", value)
+	value, err = provider.GetSecret(ctx, "aws/key")
+	require.NoError(t, err)
+	require.Equal(t, "key", value)
+	_, err = provider.GetSecret(ctx, "missing")
+	require.ErrorIs(t, err, ErrSecretNotFound)
+	_, err = provider.GetSecret(ctx, "../outside")
+	require.Error(t, err)
+	require.NotErrorIs(t, err, ErrSecretNotFound)
+}
+
+func TestVaultProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/kv/data/bacalhau/TOKEN":
+			_, _ = w.Write([]byte(`{"data":{"data":{"value":"s3cret"},"metadata":{"version":1}}}`))
+		case "/v1/kv/data/bacalhau/other":
+			_, _ = w.Write([]byte(`{"data":{"data":{"password":"s3cret"}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	_, err := NewProvider(context.Background(), Params{Backend: BackendVault, Vault: VaultParams{Address: server.URL}})
+	require.Error(t, err, "no token")
+	provider, err := NewProvider(context.Background(), Params{Backend: BackendVault, Vault: VaultParams{
+		Address: server.URL + "/",
+		Token:   "token",
+		Mount:   "kv",
+		Path:    "/bacalhau/",
+	}})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	value, err := provider.GetSecret(ctx, "TOKEN")
+	require.NoError(t, err)
+	require.Equal(t, "s3cret", value)
+	_, err = provider.GetSecret(ctx, "other")
+	require.ErrorIs(t, err, ErrSecretNotFound)
+	_, err = provider.GetSecret(ctx, "missing")
+	require.ErrorIs(t, err, ErrSecretNotFound)
+
+	provider, err = NewVaultProvider(VaultParams{Address: server.URL, Token: "wrong"})
+	require.NoError(t, err)
+	_, err = provider.GetSecret(ctx, "TOKEN")
+	require.ErrorContains(t, err, "permission denied")
+}
+
+func TestGCPProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/projects/project/secrets/aws-key/versions/latest:access":
+			_, _ = w.Write([]byte(`{"name":"aws-key","payload":{"data":"czNjcmV0"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	_, err := NewProvider(context.Background(), Params{Backend: BackendGCP})
+	require.Error(t, err, "no project")
+	provider := newGCPProvider(GCPParams{Project: "project", Endpoint: server.URL}, server.Client())
+
+	ctx := context.Background()
+	value, err := provider.GetSecret(ctx, "aws/key")
+	require.NoError(t, err)
+	require.Equal(t, "s3cret", value)
+	_, err = provider.GetSecret(ctx, "missing")
+	require.ErrorIs(t, err, ErrSecretNotFound)
+}
+
+func TestEnv(t *testing.T) {
+	t.Setenv("BACALHAU_SECRET_TOKEN", "s3cret")
+	ctx := context.Background()
+	policy := Policy{Allowed: map[string][]string{"TOKEN": nil, "MISSING": nil}}
+	j := &model.Job{ClientID: "client", Spec: model.Spec{
+		Env:     map[string]string{"FOO": "bar"},
+		Secrets: []model.SecretSpec{{Name: "TOKEN", Env: "API_TOKEN"}},
+	}}
+
+	_, err := Env(ctx, nil, policy, j)
+	require.Error(t, err)
+	env, err := Env(ctx, NewEnvProvider(""), policy, j)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"FOO": "bar", "API_TOKEN": "s3cret"}, env)
+
+	env, err = Env(ctx, nil, Policy{}, &model.Job{Spec: model.Spec{Env: j.Spec.Env}})
+	require.NoError(t, err)
+	require.Equal(t, j.Spec.Env, env)
+
+	_, err = Env(ctx, NewEnvProvider(""), policy, &model.Job{Spec: model.Spec{Secrets: []model.SecretSpec{{Name: "MISSING"}}}})
+	require.ErrorIs(t, err, ErrSecretNotFound)
+
+	// the node has the secret, but doesn't let jobs request it
+	_, err = Env(ctx, NewEnvProvider(""), Policy{}, j)
+	require.ErrorIs(t, err, ErrSecretNotAllowed)
+
+	_, err = NewProvider(context.Background(), Params{Backend: "keychain"})
+	require.Error(t, err)
+}
+
+func TestParsePolicy(t *testing.T) {
+	policy, err := ParsePolicy([]string{"TOKEN", "aws/key=a", "aws/key=b", "aws/key=a", "shared=a", "shared"}, nil)
+	require.NoError(t, err)
+	require.Equal(t, map[string][]string{"TOKEN": nil, "aws/key": {"a", "b"}, "shared": nil}, policy.Allowed)
+
+	require.NoError(t, policy.Check("any", "TOKEN"))
+	require.NoError(t, policy.Check("b", "aws/key"))
+	require.ErrorIs(t, policy.Check("c", "aws/key"), ErrSecretNotAllowed)
+	require.ErrorIs(t, policy.Check("", "aws/key"), ErrSecretNotAllowed)
+	require.NoError(t, policy.Check("c", "shared"))
+	require.ErrorIs(t, policy.Check("a", "other"), ErrSecretNotAllowed)
+
+	for _, invalid := range []string{"", "=a", "TOKEN="} {
+		_, err = ParsePolicy([]string{invalid}, nil)
+		require.Error(t, err, invalid)
+	}
+}
+
+func TestPolicyReservedSecrets(t *testing.T) {
+	// the credentials of the node can't be allowed
+	_, err := ParsePolicy([]string{"TOKEN", "estuary/key=a"}, []string{"estuary/key"})
+	require.ErrorContains(t, err, "credential of the node")
+
+	policy, err := ParsePolicy([]string{"TOKEN"}, []string{"estuary/key"})
+	require.NoError(t, err)
+	require.NoError(t, policy.Check("a", "TOKEN"))
+	require.ErrorIs(t, policy.Check("a", "estuary/key"), ErrSecretNotAllowed)
+
+	// nor requested even if the policy was put together by hand
+	policy = Policy{Allowed: map[string][]string{"estuary/key": nil}, Reserved: []string{"estuary/key"}}
+	require.ErrorIs(t, policy.Check("a", "estuary/key"), ErrSecretNotAllowed)
+}
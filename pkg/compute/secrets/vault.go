@@ -0,0 +1,109 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultVaultMount is the mount of the KV secrets engine that secrets are
+	// read from by default.
+	DefaultVaultMount = "secret"
+	// VaultValueKey is the key of the value of a secret in its Vault entry.
+	VaultValueKey = "value"
+
+	vaultTimeout = 10 * time.Second
+)
+
+type VaultParams struct {
+	// Address of the Vault server, e.g. https://vault.example.com:8200.
+	Address string
+	// Token to authenticate to Vault with.
+	Token string
+	// Mount of the KV version 2 secrets engine the secrets are in.
+	Mount string
+	// Path under the mount that the secrets are in, if any.
+	Path string
+}
+
+// VaultProvider reads secrets from the KV version 2 secrets engine of a
+// HashiCorp Vault server. Each secret is an entry named by the secret under
+// the path, with the value under the "value" key.
+type VaultProvider struct {
+	address string
+	token   string
+	mount   string
+	path    string
+	client  *http.Client
+}
+
+func NewVaultProvider(params VaultParams) (*VaultProvider, error) {
+	if params.Address == "" {
+		return nil, fmt.Errorf("the vault secrets backend needs the address of the vault server")
+	}
+	if params.Token == "" {
+		return nil, fmt.Errorf("the vault secrets backend needs a vault token")
+	}
+	if params.Mount == "" {
+		params.Mount = DefaultVaultMount
+	}
+	return &VaultProvider{
+		address: strings.TrimSuffix(params.Address, "/"),
+		token:   params.Token,
+		mount:   strings.Trim(params.Mount, "/"),
+		path:    strings.Trim(params.Path, "/"),
+		client:  &http.Client{Timeout: vaultTimeout},
+	}, nil
+}
+
+func (p *VaultProvider) GetSecret(ctx context.Context, name string) (string, error) {
+	entry := name
+	if p.path != "" {
+		entry = p.path + "/" + name
+	}
+	url := fmt.Sprintf("%s/v1/%s/data/%s", p.address, p.mount, entry)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", p.token)
+	res, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return "", ErrSecretNotFound
+	}
+	if res.StatusCode != http.StatusOK {
+		// the body of errors is safe to read, it never has secrets in it
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return "", fmt.Errorf("vault returned %s: %s", res.Status, strings.TrimSpace(string(body)))
+	}
+
+	var secret struct {
+		Data struct {
+			Data map[string]interface{} `json:"data"`
+		} `json:"data"`
+	}
+	if err = json.NewDecoder(res.Body).Decode(&secret); err != nil {
+		return "", fmt.Errorf("could not decode the vault response: %w", err)
+	}
+	value, ok := secret.Data.Data[VaultValueKey]
+	if !ok {
+		return "", ErrSecretNotFound
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	return "", fmt.Errorf("the %q of vault entry %s is not a string", VaultValueKey, entry)
+}
+
+// Compile-time interface check:
+var _ Provider = (*VaultProvider)(nil)
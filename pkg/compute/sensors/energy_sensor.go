@@ -0,0 +1,141 @@
+package sensors
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultEnergyRefreshInterval is how often the carbon intensity is fetched
+// from the external API by default.
+const DefaultEnergyRefreshInterval = 15 * time.Minute
+
+type EnergySensorParams struct {
+	Name string
+	// Renewable whether the node runs on renewable power.
+	Renewable bool
+	// CarbonIntensity the carbon intensity of the power of the node in gCO2eq/kWh, if known.
+	CarbonIntensity *float64
+	// URL of an API that returns the current carbon intensity of the power of the node, either as a bare number or
+	// as JSON like {"carbon_intensity": 120.5, "renewable": false}. It overrides the static values.
+	URL string
+	// RefreshInterval how often to fetch from the URL.
+	RefreshInterval time.Duration
+}
+
+// EnergySensor keeps track of what the node says about the energy it runs on, so that jobs can prefer greener
+// nodes.
+type EnergySensor struct {
+	name            string
+	url             string
+	refreshInterval time.Duration
+
+	mu     sync.RWMutex
+	energy *model.NodeEnergy
+}
+
+func NewEnergySensor(params EnergySensorParams) *EnergySensor {
+	s := &EnergySensor{
+		name:            params.Name,
+		url:             params.URL,
+		refreshInterval: params.RefreshInterval,
+	}
+	if s.refreshInterval <= 0 {
+		s.refreshInterval = DefaultEnergyRefreshInterval
+	}
+	if params.Renewable || params.CarbonIntensity != nil {
+		s.energy = &model.NodeEnergy{Renewable: params.Renewable, CarbonIntensity: params.CarbonIntensity}
+	}
+	return s
+}
+
+// Energy returns what the node says about the energy it runs on, or nil if it doesn't say.
+func (s *EnergySensor) Energy() *model.NodeEnergy {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.energy
+}
+
+// Start fetches the carbon intensity from the URL periodically, until ctx is done.
+func (s *EnergySensor) Start(ctx context.Context) {
+	if s.url == "" {
+		return
+	}
+	ticker := time.NewTicker(s.refreshInterval)
+	defer ticker.Stop()
+	for {
+		s.sense(ctx)
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *EnergySensor) sense(ctx context.Context) {
+	energy, err := s.fetch(ctx)
+	if err != nil {
+		// keep what we last knew rather than dropping out of green jobs on a blip
+		log.Ctx(ctx).Warn().Err(err).Msgf("failed to fetch carbon intensity from %s", s.url)
+		return
+	}
+	s.mu.Lock()
+	s.energy = energy
+	s.mu.Unlock()
+}
+
+func (s *EnergySensor) fetch(ctx context.Context) (*model.NodeEnergy, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("returned %d status code", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	return parseEnergy(body)
+}
+
+func parseEnergy(body []byte) (*model.NodeEnergy, error) {
+	if intensity, err := strconv.ParseFloat(strings.TrimSpace(string(body)), 64); err == nil {
+		return &model.NodeEnergy{CarbonIntensity: &intensity}, nil
+	}
+	var response struct {
+		CarbonIntensity *float64 `json:"carbon_intensity"`
+		Renewable       bool     `json:"renewable"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("invalid response, must be a number or JSON: %w", err)
+	}
+	return &model.NodeEnergy{Renewable: response.Renewable, CarbonIntensity: response.CarbonIntensity}, nil
+}
+
+func (s *EnergySensor) GetDebugInfo() (model.DebugInfo, error) {
+	return model.DebugInfo{
+		Component: s.name,
+		Info:      s.Energy().String(),
+	}, nil
+}
+
+// compile-time check that we implement the interface
+var _ model.DebugInfoProvider = (*EnergySensor)(nil)
@@ -0,0 +1,70 @@
+//go:build unit || !integration
+
+package sensors
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnergySensorStatic(t *testing.T) {
+	require.Nil(t, NewEnergySensor(EnergySensorParams{}).Energy())
+
+	intensity := 50.0
+	energy := NewEnergySensor(EnergySensorParams{Renewable: true, CarbonIntensity: &intensity}).Energy()
+	require.Equal(t, &model.NodeEnergy{Renewable: true, CarbonIntensity: &intensity}, energy)
+}
+
+func TestEnergySensorURL(t *testing.T) {
+	response := "120.5\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if response == "" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(response))
+	}))
+	defer server.Close()
+
+	static := 300.0
+	sensor := NewEnergySensor(EnergySensorParams{CarbonIntensity: &static, URL: server.URL})
+	ctx := context.Background()
+
+	sensor.sense(ctx)
+	require.Equal(t, 120.5, *sensor.Energy().CarbonIntensity)
+
+	response = `{"carbon_intensity": 20, "renewable": true}`
+	sensor.sense(ctx)
+	require.True(t, sensor.Energy().Renewable)
+	require.Equal(t, 20.0, *sensor.Energy().CarbonIntensity)
+
+	// the last known energy is kept when the API fails
+	response = ""
+	sensor.sense(ctx)
+	require.Equal(t, 20.0, *sensor.Energy().CarbonIntensity)
+
+	response = "not a number"
+	sensor.sense(ctx)
+	require.Equal(t, 20.0, *sensor.Energy().CarbonIntensity)
+}
+
+func TestNodeEnergyGreener(t *testing.T) {
+	low, high := 10.0, 400.0
+	renewable := &model.NodeEnergy{Renewable: true}
+	lowCarbon := &model.NodeEnergy{CarbonIntensity: &low}
+	highCarbon := &model.NodeEnergy{CarbonIntensity: &high}
+	unknown := &model.NodeEnergy{}
+
+	for i, greener := range []*model.NodeEnergy{renewable, lowCarbon, highCarbon, unknown, nil} {
+		for _, other := range []*model.NodeEnergy{renewable, lowCarbon, highCarbon, unknown, nil}[i+1:] {
+			require.True(t, greener.Greener(other), "%s is greener than %s", greener, other)
+			require.False(t, other.Greener(greener), "%s is not greener than %s", other, greener)
+		}
+		require.False(t, greener.Greener(greener))
+	}
+}
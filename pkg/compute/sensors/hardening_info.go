@@ -0,0 +1,34 @@
+package sensors
+
+import (
+	"github.com/filecoin-project/bacalhau/pkg/model"
+)
+
+type HardeningDebugInfoProviderParams struct {
+	Name      string
+	Hardening *model.NodeHardening
+}
+
+// HardeningDebugInfoProvider is a debug info provider that returns the hardening the node applies to the containers
+// of its jobs.
+type HardeningDebugInfoProvider struct {
+	name      string
+	hardening *model.NodeHardening
+}
+
+func NewHardeningDebugInfoProvider(params HardeningDebugInfoProviderParams) *HardeningDebugInfoProvider {
+	return &HardeningDebugInfoProvider{
+		name:      params.Name,
+		hardening: params.Hardening,
+	}
+}
+
+func (r HardeningDebugInfoProvider) GetDebugInfo() (model.DebugInfo, error) {
+	return model.DebugInfo{
+		Component: r.name,
+		Info:      r.hardening.String(),
+	}, nil
+}
+
+// compile-time check that we implement the interface
+var _ model.DebugInfoProvider = (*HardeningDebugInfoProvider)(nil)
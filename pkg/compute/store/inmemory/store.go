@@ -55,6 +55,18 @@ func (s *Store) GetExecutions(ctx context.Context, shardID string) ([]store.Exec
 	return executions, nil
 }
 
+func (s *Store) GetExecutionCount(ctx context.Context, state store.ExecutionState) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	count := 0
+	for _, execution := range s.executionMap {
+		if execution.State == state {
+			count++
+		}
+	}
+	return count, nil
+}
+
 func (s *Store) GetExecutionHistory(ctx context.Context, id string) ([]store.ExecutionHistory, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
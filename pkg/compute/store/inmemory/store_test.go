@@ -88,6 +88,24 @@ func (s *Suite) TestGetExecutions_DoesntExist() {
 	s.ErrorAs(err, &store.ErrExecutionsNotFoundForShard{})
 }
 
+func (s *Suite) TestGetExecutionCount() {
+	ctx := context.Background()
+	s.NoError(s.executionStore.CreateExecution(ctx, s.execution))
+	anotherExecution := newExecution()
+	s.NoError(s.executionStore.CreateExecution(ctx, anotherExecution))
+	s.NoError(s.executionStore.UpdateExecutionState(ctx, store.UpdateExecutionStateRequest{
+		ExecutionID: anotherExecution.ID,
+		NewState:    store.ExecutionStateCancelled,
+	}))
+
+	count, err := s.executionStore.GetExecutionCount(ctx, store.ExecutionStateCreated)
+	s.NoError(err)
+	s.Equal(1, count)
+	count, err = s.executionStore.GetExecutionCount(ctx, store.ExecutionStateRunning)
+	s.NoError(err)
+	s.Equal(0, count)
+}
+
 func (s *Suite) TestUpdateExecution() {
 	ctx := context.Background()
 	err := s.executionStore.CreateExecution(ctx, s.execution)
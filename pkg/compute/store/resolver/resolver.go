@@ -2,6 +2,7 @@ package resolver
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -47,10 +48,15 @@ func (r *StateResolver) Wait(
 		Name:        "Wait for execution state",
 		MaxAttempts: r.maxWaitAttempts,
 		Delay:       r.waitDelay,
-		Handler: func() (bool, error) {
+		HandlerWithContext: func(ctx context.Context) (bool, error) {
 			execution, err := r.executionStore.GetExecution(ctx, executionID)
 			if err != nil {
-				return false, err
+				// the execution won't appear by waiting for it, but the
+				// store may be failing for a while
+				if errors.As(err, new(store.ErrExecutionNotFound)) {
+					return false, err
+				}
+				return false, system.NewRetryableError(err)
 			}
 
 			allOK := true
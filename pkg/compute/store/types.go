@@ -77,6 +77,8 @@ type ExecutionStore interface {
 	GetExecution(ctx context.Context, id string) (Execution, error)
 	// GetExecutions returns all the executions for a given shard
 	GetExecutions(ctx context.Context, sharedID string) ([]Execution, error)
+	// GetExecutionCount returns the number of executions in a given state
+	GetExecutionCount(ctx context.Context, state ExecutionState) (int, error)
 	// GetExecutionHistory returns the history of an execution
 	GetExecutionHistory(ctx context.Context, id string) ([]ExecutionHistory, error)
 	// CreateExecution creates a new execution for a given shard
@@ -24,6 +24,7 @@ import (
 	"github.com/filecoin-project/bacalhau/pkg/model"
 	"github.com/filecoin-project/bacalhau/pkg/node"
 	"github.com/filecoin-project/bacalhau/pkg/requesternode"
+	"github.com/filecoin-project/bacalhau/pkg/storage/localdirectory"
 	"github.com/filecoin-project/bacalhau/pkg/system"
 	"github.com/filecoin-project/bacalhau/pkg/transport/libp2p"
 	"github.com/filecoin-project/bacalhau/pkg/transport/simulator"
@@ -41,6 +42,8 @@ type DevStackOptions struct {
 	FilecoinUnsealedPath string
 	EstuaryAPIKey        string
 	SimulatorURL         string // if this is set, we will use the simulator transport
+	// Host paths that jobs can read under as local:///PATH inputs
+	AllowedLocalPaths []string
 }
 type DevStack struct {
 	Nodes []*node.Node
@@ -51,7 +54,7 @@ func NewDevStackForRunLocal(
 	ctx context.Context,
 	cm *system.CleanupManager,
 	count int,
-	jobGPU uint64, //nolint:unparam // Incorrectly assumed as unused
+	jobGPU float64, //nolint:unparam // Incorrectly assumed as unused
 ) (*DevStack, error) {
 	options := DevStackOptions{
 		NumberOfNodes:  count,
@@ -251,6 +254,8 @@ func NewDevStack(
 			IsBadActor:           isBadActor,
 		}
 
+		nodeConfig.LocalDirectoriesConfig = localdirectory.StorageConfig{AllowedPaths: options.AllowedLocalPaths}
+
 		if lotus != nil {
 			nodeConfig.LotusConfig = &filecoinlotus.PublisherConfig{
 				StorageDuration: 24 * 24 * time.Hour,
@@ -0,0 +1,66 @@
+package docker
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/docker/distribution/reference"
+	dockerclient "github.com/docker/docker/client"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/rs/zerolog/log"
+)
+
+// attestationsTimeout bounds looking up the attestations of an image in its
+// registry, so that unreachable registries don't hold up jobs.
+const attestationsTimeout = 10 * time.Second
+
+// GetImageProvenance returns the provenance of an image present on the node:
+// its ID, digests and layers, and the SBOMs and other attestations attached
+// to it in its registry. Attestations are looked up best effort, as nodes
+// may not reach the registry of images they already have.
+func GetImageProvenance(
+	ctx context.Context, dockerClient *dockerclient.Client, image string) (*model.ImageProvenance, error) {
+	inspect, _, err := dockerClient.ImageInspectWithRaw(ctx, image)
+	if err != nil {
+		return nil, err
+	}
+	imagePlatform := platform{OS: inspect.Os, Architecture: inspect.Architecture, Variant: inspect.Variant}
+	provenance := &model.ImageProvenance{
+		Image:       image,
+		ImageID:     inspect.ID,
+		RepoDigests: inspect.RepoDigests,
+		Platform:    imagePlatform.String(),
+		Layers:      inspect.RootFS.Layers,
+	}
+
+	repoDigest := imageRepoDigest(image, inspect.RepoDigests)
+	if repoDigest == "" {
+		// built locally, so not in any registry
+		return provenance, nil
+	}
+	lookupCtx, cancel := context.WithTimeout(ctx, attestationsTimeout)
+	defer cancel()
+	registry := &registryClient{client: http.DefaultClient}
+	provenance.ManifestDigest, provenance.Attestations, err = registry.attestations(lookupCtx, repoDigest, imagePlatform)
+	if err != nil {
+		log.Ctx(ctx).Debug().Err(err).Msgf("could not look up the attestations of image %s", image)
+	}
+	return provenance, nil
+}
+
+// imageRepoDigest returns the repo digest of the repository the image was
+// pulled from, of those the image is known by.
+func imageRepoDigest(image string, repoDigests []string) string {
+	if len(repoDigests) == 0 {
+		return ""
+	}
+	if ref, err := reference.ParseNormalizedNamed(image); err == nil {
+		for _, repoDigest := range repoDigests {
+			if digestRef, err := reference.ParseNormalizedNamed(repoDigest); err == nil && digestRef.Name() == ref.Name() {
+				return repoDigest
+			}
+		}
+	}
+	return repoDigests[0]
+}
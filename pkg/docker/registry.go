@@ -0,0 +1,245 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/docker/distribution/reference"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/util/closer"
+)
+
+const (
+	mediaTypeOCIIndex           = "application/vnd.oci.image.index.v1+json"
+	mediaTypeDockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+	mediaTypeOCIManifest        = "application/vnd.oci.image.manifest.v1+json"
+	mediaTypeDockerManifest     = "application/vnd.docker.distribution.manifest.v2+json"
+
+	// the annotations buildx puts on the attestation manifests of an index,
+	// and on their layers
+	annotationReferenceType   = "vnd.docker.reference.type"
+	annotationReferenceDigest = "vnd.docker.reference.digest"
+	annotationPredicateType   = "in-toto.io/predicate-type"
+	referenceTypeAttestation  = "attestation-manifest"
+
+	// maxManifestSize bounds the manifests read from registries.
+	maxManifestSize = 4 * 1024 * 1024
+)
+
+var manifestMediaTypes = strings.Join([]string{
+	mediaTypeOCIIndex, mediaTypeDockerManifestList, mediaTypeOCIManifest, mediaTypeDockerManifest,
+}, ", ")
+
+var authParamRegexp = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+type descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Platform    *platform         `json:"platform,omitempty"`
+}
+
+type platform struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+func (p platform) String() string {
+	s := p.OS + "/" + p.Architecture
+	if p.Variant != "" {
+		s += "/" + p.Variant
+	}
+	return s
+}
+
+// matches returns whether the platforms are the same, ignoring the variant
+// if either doesn't say.
+func (p platform) matches(other platform) bool {
+	return p.OS == other.OS && p.Architecture == other.Architecture &&
+		(p.Variant == "" || other.Variant == "" || p.Variant == other.Variant)
+}
+
+// manifest is the part of image indexes and manifests that attestations are
+// found from, whether in OCI or Docker format.
+type manifest struct {
+	Manifests []descriptor `json:"manifests,omitempty"`
+	Layers    []descriptor `json:"layers,omitempty"`
+}
+
+// registryClient reads the manifests of images from their registry, with
+// anonymous pull tokens where the registry requires them.
+type registryClient struct {
+	client *http.Client
+	token  string
+}
+
+// attestations returns the digest of the manifest of the image for the
+// platform, and the attestations attached to it, of an image given by a
+// repo digest. Images whose digest is of a single manifest have neither.
+func (r *registryClient) attestations(
+	ctx context.Context, repoDigest string, imagePlatform platform) (string, []model.ImageAttestation, error) {
+	ref, err := reference.ParseNormalizedNamed(repoDigest)
+	if err != nil {
+		return "", nil, err
+	}
+	canonical, ok := ref.(reference.Canonical)
+	if !ok {
+		return "", nil, fmt.Errorf("%s is not a repo digest", repoDigest)
+	}
+	repoURL := registryURL(reference.Domain(ref)) + "/v2/" + reference.Path(ref)
+
+	var index manifest
+	mediaType, err := r.getManifest(ctx, repoURL, canonical.Digest().String(), &index)
+	if err != nil {
+		return "", nil, err
+	}
+	if mediaType != mediaTypeOCIIndex && mediaType != mediaTypeDockerManifestList {
+		return "", nil, nil
+	}
+
+	var manifestDigest string
+	for _, m := range index.Manifests {
+		if m.Platform != nil && m.Annotations[annotationReferenceType] == "" && m.Platform.matches(imagePlatform) {
+			manifestDigest = m.Digest
+			break
+		}
+	}
+	if manifestDigest == "" {
+		return "", nil, nil
+	}
+
+	var attestations []model.ImageAttestation
+	for _, m := range index.Manifests {
+		if m.Annotations[annotationReferenceType] != referenceTypeAttestation ||
+			m.Annotations[annotationReferenceDigest] != manifestDigest {
+			continue
+		}
+		var attestationManifest manifest
+		if _, err = r.getManifest(ctx, repoURL, m.Digest, &attestationManifest); err != nil {
+			return manifestDigest, nil, err
+		}
+		for _, layer := range attestationManifest.Layers {
+			attestations = append(attestations, model.ImageAttestation{
+				PredicateType: layer.Annotations[annotationPredicateType],
+				Digest:        layer.Digest,
+				MediaType:     layer.MediaType,
+				Size:          layer.Size,
+			})
+		}
+	}
+	return manifestDigest, attestations, nil
+}
+
+// getManifest decodes the manifest with the digest into v, and returns its
+// media type.
+func (r *registryClient) getManifest(ctx context.Context, repoURL, digest string, v interface{}) (string, error) {
+	manifestURL := repoURL + "/manifests/" + digest
+	res, err := r.get(ctx, manifestURL)
+	if err != nil {
+		return "", err
+	}
+	if res.StatusCode == http.StatusUnauthorized && r.token == "" {
+		closer.DrainAndCloseWithLogOnError(ctx, "registry response", res.Body)
+		if r.token, err = r.fetchToken(ctx, res.Header.Get("WWW-Authenticate")); err != nil {
+			return "", err
+		}
+		if res, err = r.get(ctx, manifestURL); err != nil {
+			return "", err
+		}
+	}
+	defer closer.DrainAndCloseWithLogOnError(ctx, "registry response", res.Body)
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s returned %d status code", manifestURL, res.StatusCode)
+	}
+	if err = json.NewDecoder(io.LimitReader(res.Body, maxManifestSize)).Decode(v); err != nil {
+		return "", fmt.Errorf("invalid manifest %s: %w", digest, err)
+	}
+	mediaType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	return mediaType, nil
+}
+
+func (r *registryClient) get(ctx context.Context, rawURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", manifestMediaTypes)
+	if r.token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.token)
+	}
+	return r.client.Do(req)
+}
+
+// fetchToken gets an anonymous pull token from the authorization server of
+// a registry, as the challenge of its unauthorized response says.
+func (r *registryClient) fetchToken(ctx context.Context, challenge string) (string, error) {
+	if !strings.HasPrefix(strings.ToLower(challenge), "bearer ") {
+		return "", fmt.Errorf("unsupported registry authentication %q", challenge)
+	}
+	params := map[string]string{}
+	for _, match := range authParamRegexp.FindAllStringSubmatch(challenge, -1) {
+		params[match[1]] = match[2]
+	}
+	realm, err := url.Parse(params["realm"])
+	if err != nil || realm.Host == "" {
+		return "", fmt.Errorf("invalid registry authentication realm %q", params["realm"])
+	}
+	query := realm.Query()
+	for _, key := range []string{"service", "scope"} {
+		if params[key] != "" {
+			query.Set(key, params[key])
+		}
+	}
+	realm.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	res, err := r.client.Do(req) //nolint:bodyclose // closed below
+	if err != nil {
+		return "", err
+	}
+	defer closer.DrainAndCloseWithLogOnError(ctx, "registry token response", res.Body)
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s returned %d status code", realm.Host, res.StatusCode)
+	}
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err = json.NewDecoder(io.LimitReader(res.Body, maxManifestSize)).Decode(&token); err != nil {
+		return "", err
+	}
+	if token.Token != "" {
+		return token.Token, nil
+	}
+	return token.AccessToken, nil
+}
+
+// registryURL returns the URL of the registry API of a domain of image
+// references. Like the Docker daemon, registries on the loopback interface
+// are spoken to over plain HTTP.
+func registryURL(domain string) string {
+	if domain == "docker.io" {
+		return "https://registry-1.docker.io"
+	}
+	host := domain
+	if h, _, err := net.SplitHostPort(domain); err == nil {
+		host = h
+	}
+	if host == "localhost" || net.ParseIP(host).IsLoopback() {
+		return "http://" + domain
+	}
+	return "https://" + domain
+}
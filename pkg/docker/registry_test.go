@@ -0,0 +1,121 @@
+//go:build unit || !integration
+
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testIndexDigest       = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+	testAmd64Digest       = "sha256:2222222222222222222222222222222222222222222222222222222222222222"
+	testArm64Digest       = "sha256:3333333333333333333333333333333333333333333333333333333333333333"
+	testAttestationDigest = "sha256:4444444444444444444444444444444444444444444444444444444444444444"
+	testSBOMDigest        = "sha256:5555555555555555555555555555555555555555555555555555555555555555"
+)
+
+// newTestRegistry serves an index with an image for two platforms and the
+// attestations of the amd64 one, to clients with the pull token.
+func newTestRegistry(t *testing.T) *httptest.Server {
+	manifests := map[string]struct {
+		mediaType string
+		body      manifest
+	}{
+		testIndexDigest: {mediaTypeOCIIndex, manifest{Manifests: []descriptor{
+			{Digest: testAmd64Digest, Platform: &platform{OS: "linux", Architecture: "amd64"}},
+			{Digest: testArm64Digest, Platform: &platform{OS: "linux", Architecture: "arm64", Variant: "v8"}},
+			{Digest: testAttestationDigest, Platform: &platform{OS: "unknown", Architecture: "unknown"}, Annotations: map[string]string{
+				annotationReferenceType:   referenceTypeAttestation,
+				annotationReferenceDigest: testAmd64Digest,
+			}},
+		}}},
+		testAmd64Digest: {mediaTypeOCIManifest, manifest{}},
+		testAttestationDigest: {mediaTypeOCIManifest, manifest{Layers: []descriptor{{
+			MediaType:   "application/vnd.in-toto+json",
+			Digest:      testSBOMDigest,
+			Size:        1234,
+			Annotations: map[string]string{annotationPredicateType: "https://spdx.dev/Document"},
+		}}}},
+	}
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			require.Equal(t, "repository:team/tool:pull", r.URL.Query().Get("scope"))
+			_ = json.NewEncoder(w).Encode(map[string]string{"token": "pull-token"})
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer pull-token" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(
+				`Bearer realm="%s/token",service="test",scope="repository:team/tool:pull"`, server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		m, ok := manifests[strings.TrimPrefix(r.URL.Path, "/v2/team/tool/manifests/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", m.mediaType)
+		_ = json.NewEncoder(w).Encode(m.body)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestRegistryAttestations(t *testing.T) {
+	server := newTestRegistry(t)
+	repo := strings.TrimPrefix(server.URL, "http://") + "/team/tool"
+	ctx := context.Background()
+
+	registry := &registryClient{client: server.Client()}
+	manifestDigest, attestations, err := registry.attestations(
+		ctx, repo+"@"+testIndexDigest, platform{OS: "linux", Architecture: "amd64"})
+	require.NoError(t, err)
+	require.Equal(t, testAmd64Digest, manifestDigest)
+	require.Equal(t, []model.ImageAttestation{{
+		PredicateType: "https://spdx.dev/Document",
+		Digest:        testSBOMDigest,
+		MediaType:     "application/vnd.in-toto+json",
+		Size:          1234,
+	}}, attestations)
+
+	manifestDigest, attestations, err = registry.attestations(
+		ctx, repo+"@"+testIndexDigest, platform{OS: "linux", Architecture: "arm64"})
+	require.NoError(t, err)
+	require.Equal(t, testArm64Digest, manifestDigest, "the variant is ignored when the image doesn't say")
+	require.Empty(t, attestations)
+
+	// a single manifest has no platforms nor attestations
+	manifestDigest, attestations, err = registry.attestations(
+		ctx, repo+"@"+testAmd64Digest, platform{OS: "linux", Architecture: "amd64"})
+	require.NoError(t, err)
+	require.Empty(t, manifestDigest)
+	require.Empty(t, attestations)
+
+	_, _, err = registry.attestations(ctx, repo+":latest", platform{OS: "linux", Architecture: "amd64"})
+	require.Error(t, err)
+}
+
+func TestImageRepoDigest(t *testing.T) {
+	repoDigests := []string{"mirror.example.com/ubuntu@" + testAmd64Digest, "ubuntu@" + testIndexDigest}
+	require.Equal(t, "ubuntu@"+testIndexDigest, imageRepoDigest("ubuntu:22.04", repoDigests))
+	require.Equal(t, repoDigests[0], imageRepoDigest("other", repoDigests))
+	require.Empty(t, imageRepoDigest("ubuntu", nil))
+}
+
+func TestRegistryURL(t *testing.T) {
+	require.Equal(t, "https://registry-1.docker.io", registryURL("docker.io"))
+	require.Equal(t, "https://ghcr.io", registryURL("ghcr.io"))
+	require.Equal(t, "http://localhost:5000", registryURL("localhost:5000"))
+	require.Equal(t, "http://127.0.0.1:5000", registryURL("127.0.0.1:5000"))
+}
@@ -25,10 +25,21 @@ import (
 // delete, or has already deleted, the given container.
 var ErrContainerMarkedForRemoval = fmt.Errorf("docker container marked for removal")
 
+// DefaultPodmanHost is the socket of the Docker compatible API of a
+// rootful podman service.
+const DefaultPodmanHost = "unix:///run/podman/podman.sock"
+
 func NewDockerClient() (*dockerclient.Client, error) {
 	return dockerclient.NewClientWithOpts(dockerclient.FromEnv, dockerclient.WithAPIVersionNegotiation())
 }
 
+// NewDockerClientWithHost returns a client of the Docker API at the host,
+// such as the one podman serves.
+func NewDockerClientWithHost(host string) (*dockerclient.Client, error) {
+	return dockerclient.NewClientWithOpts(
+		dockerclient.FromEnv, dockerclient.WithHost(host), dockerclient.WithAPIVersionNegotiation())
+}
+
 func IsInstalled(ctx context.Context, dockerClient *dockerclient.Client) bool {
 	_, err := dockerClient.Info(ctx)
 	return err == nil
@@ -0,0 +1,152 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"unicode/utf8"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/storage/util"
+)
+
+// SelectOutputArtifacts keeps only the files of the outputs in the results
+// directory that are to be published, under the paths they are to be
+// published with. Outputs without artifacts are left as they are.
+func SelectOutputArtifacts(resultsDir string, outputs []model.StorageSpec) error {
+	for _, output := range outputs {
+		if len(output.Artifacts) == 0 {
+			continue
+		}
+		if err := selectArtifacts(filepath.Join(resultsDir, output.Name), output.Artifacts); err != nil {
+			return fmt.Errorf("error selecting the artifacts of output %s: %w", output.Name, err)
+		}
+	}
+	return nil
+}
+
+func selectArtifacts(outputDir string, artifacts []model.OutputArtifact) error {
+	if _, err := os.Stat(outputDir); os.IsNotExist(err) {
+		// the job didn't produce this output
+		return nil
+	}
+
+	selectedDir, err := os.MkdirTemp(filepath.Dir(outputDir), "."+filepath.Base(outputDir)+"-*")
+	if err != nil {
+		return err
+	}
+	err = filepath.WalkDir(outputDir, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		relative, err := filepath.Rel(outputDir, filePath)
+		if err != nil {
+			return err
+		}
+		relative = filepath.ToSlash(relative)
+
+		// files are published by the first artifact that matches them
+		for _, artifact := range artifacts {
+			matched, err := artifact.Matches(relative)
+			if err != nil {
+				return err
+			}
+			if !matched {
+				continue
+			}
+			target := artifactPath(artifact, relative)
+			destination := filepath.Join(selectedDir, filepath.FromSlash(target))
+			if _, err = os.Lstat(destination); err == nil {
+				return fmt.Errorf("more than one file would be published as %s", target)
+			}
+			if err = os.MkdirAll(filepath.Dir(destination), util.OS_ALL_R|util.OS_ALL_X|util.OS_USER_W); err != nil {
+				return err
+			}
+			return os.Rename(filePath, destination)
+		}
+		return nil
+	})
+	if err == nil {
+		err = os.Chmod(selectedDir, util.OS_ALL_R|util.OS_ALL_X|util.OS_USER_W)
+	}
+	if err == nil {
+		err = os.RemoveAll(outputDir)
+	}
+	if err != nil {
+		_ = os.RemoveAll(selectedDir)
+		return err
+	}
+	return os.Rename(selectedDir, outputDir)
+}
+
+// artifactPath returns the slash-separated path, relative to the output
+// volume, that a file matched by an artifact is published under.
+func artifactPath(artifact model.OutputArtifact, relative string) string {
+	switch {
+	case artifact.Name == "":
+		return relative
+	case artifact.IsPlainPath():
+		return path.Clean(artifact.Name)
+	default:
+		return path.Join(artifact.Name, relative)
+	}
+}
+
+// ReadInlineOutputs returns the contents of the files of the outputs in the
+// results directory by their slash-separated path in it, if they are text and
+// add up to no more than limit bytes. It returns nothing otherwise.
+func ReadInlineOutputs(resultsDir string, outputs []model.StorageSpec, limit uint64) (map[string]string, error) {
+	var paths []string
+	var size uint64
+	for _, output := range outputs {
+		outputDir := filepath.Join(resultsDir, output.Name)
+		if _, err := os.Stat(outputDir); os.IsNotExist(err) {
+			continue
+		}
+		err := filepath.WalkDir(outputDir, func(filePath string, entry fs.DirEntry, err error) error {
+			if err != nil || !entry.Type().IsRegular() {
+				return err
+			}
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			size += uint64(info.Size())
+			if size > limit {
+				return errOutputsTooLarge
+			}
+			paths = append(paths, filePath)
+			return nil
+		})
+		if err == errOutputsTooLarge {
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	if len(paths) == 0 {
+		return nil, nil
+	}
+
+	contents := make(map[string]string, len(paths))
+	for _, filePath := range paths {
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			return nil, err
+		}
+		if !utf8.Valid(data) {
+			return nil, nil
+		}
+		relative, err := filepath.Rel(resultsDir, filePath)
+		if err != nil {
+			return nil, err
+		}
+		contents[filepath.ToSlash(relative)] = string(data)
+	}
+	return contents, nil
+}
+
+var errOutputsTooLarge = errors.New("outputs are too large to inline")
@@ -0,0 +1,122 @@
+//go:build unit || !integration
+
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/stretchr/testify/require"
+)
+
+func writeOutputFiles(t *testing.T, dir string, files ...string) {
+	for _, file := range files {
+		path := filepath.Join(dir, filepath.FromSlash(file))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(file), 0644))
+	}
+}
+
+func listOutputFiles(t *testing.T, dir string) []string {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		relative, err := filepath.Rel(dir, path)
+		files = append(files, filepath.ToSlash(relative))
+		return err
+	})
+	require.NoError(t, err)
+	sort.Strings(files)
+	return files
+}
+
+func TestSelectOutputArtifacts(t *testing.T) {
+	for _, testCase := range []struct {
+		name      string
+		artifacts []model.OutputArtifact
+		expected  []string
+	}{
+		{
+			name:     "everything",
+			expected: []string{"model/weights.bin", "report.csv", "scratch/big.tmp", "scratch/nested/other.csv"},
+		},
+		{
+			name:      "glob",
+			artifacts: []model.OutputArtifact{{Glob: "*.csv"}},
+			expected:  []string{"report.csv"},
+		},
+		{
+			name:      "any directory",
+			artifacts: []model.OutputArtifact{{Glob: "**/*.csv"}},
+			expected:  []string{"report.csv", "scratch/nested/other.csv"},
+		},
+		{
+			name:      "renamed file",
+			artifacts: []model.OutputArtifact{{Glob: "model/weights.bin", Name: "weights"}},
+			expected:  []string{"weights"},
+		},
+		{
+			name:      "renamed directory",
+			artifacts: []model.OutputArtifact{{Glob: "model/*", Name: "published"}, {Glob: "*.csv"}},
+			expected:  []string{"published/model/weights.bin", "report.csv"},
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			resultsDir := t.TempDir()
+			writeOutputFiles(t, filepath.Join(resultsDir, "outputs"),
+				"report.csv", "model/weights.bin", "scratch/big.tmp", "scratch/nested/other.csv")
+			writeOutputFiles(t, resultsDir, "stdout")
+
+			err := SelectOutputArtifacts(resultsDir, []model.StorageSpec{
+				{Name: "outputs", Path: "/outputs", Artifacts: testCase.artifacts},
+				{Name: "missing", Path: "/missing", Artifacts: testCase.artifacts},
+			})
+			require.NoError(t, err)
+			require.Equal(t, testCase.expected, listOutputFiles(t, filepath.Join(resultsDir, "outputs")))
+			require.FileExists(t, filepath.Join(resultsDir, "stdout"))
+		})
+	}
+}
+
+func TestSelectOutputArtifactsCollision(t *testing.T) {
+	resultsDir := t.TempDir()
+	writeOutputFiles(t, filepath.Join(resultsDir, "outputs"), "a.csv", "b.csv")
+
+	err := SelectOutputArtifacts(resultsDir, []model.StorageSpec{{
+		Name:      "outputs",
+		Path:      "/outputs",
+		Artifacts: []model.OutputArtifact{{Glob: "a.csv", Name: "out.csv"}, {Glob: "b.csv", Name: "out.csv"}},
+	}})
+	require.Error(t, err)
+}
+
+func TestReadInlineOutputs(t *testing.T) {
+	outputs := []model.StorageSpec{{Name: "outputs", Path: "/outputs"}, {Name: "missing", Path: "/missing"}}
+
+	resultsDir := t.TempDir()
+	writeOutputFiles(t, filepath.Join(resultsDir, "outputs"), "answer.txt", "nested/other.txt")
+	writeOutputFiles(t, resultsDir, "stdout")
+
+	contents, err := ReadInlineOutputs(resultsDir, outputs, 1024)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"outputs/answer.txt":       "answer.txt",
+		"outputs/nested/other.txt": "nested/other.txt",
+	}, contents)
+
+	// too large
+	contents, err = ReadInlineOutputs(resultsDir, outputs, 16)
+	require.NoError(t, err)
+	require.Nil(t, contents)
+
+	// not text
+	require.NoError(t, os.WriteFile(filepath.Join(resultsDir, "outputs", "binary"), []byte{0xff, 0xfe}, 0644))
+	contents, err = ReadInlineOutputs(resultsDir, outputs, 1024)
+	require.NoError(t, err)
+	require.Nil(t, contents)
+}
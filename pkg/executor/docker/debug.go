@@ -0,0 +1,155 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	dockertypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/network"
+	dockerclient "github.com/docker/docker/client"
+	"github.com/filecoin-project/bacalhau/pkg/docker"
+	"github.com/filecoin-project/bacalhau/pkg/executor"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/rs/zerolog/log"
+)
+
+// debugShell is the shell attached to the environments kept for debugging,
+// which images without it can't be debugged with.
+var debugShell = []string{"/bin/sh"}
+
+func debugContainerName(executorID, jobID string, shardIndex int) string {
+	return fmt.Sprintf("bacalhau-debug-%s-%s-%d", executorID, jobID, shardIndex)
+}
+
+func isDebugContainer(container dockertypes.Container) bool {
+	for _, name := range container.Names {
+		if strings.HasPrefix(strings.TrimPrefix(name, "/"), "bacalhau-debug-") {
+			return true
+		}
+	}
+	return false
+}
+
+// keepForDebugging starts a container with the image, environment and input
+// volumes of a failed shard that sleeps for the debug session timeout, for
+// the client of the job to attach a shell to. Its output volumes are
+// read-only, so that debugging can't change the results the shard has
+// published, and it has no scratch volumes or GPUs, which are released once
+// the shard ends.
+func (e *Executor) keepForDebugging(
+	ctx context.Context,
+	shard model.JobShard,
+	containerConfig container.Config,
+	hostConfig container.HostConfig,
+) {
+	scratch := map[string]bool{}
+	for _, volume := range shard.Job.Spec.Scratch {
+		scratch[filepath.Clean(volume.Path)] = true
+	}
+	mounts := make([]mount.Mount, 0, len(hostConfig.Mounts))
+	for _, volumeMount := range hostConfig.Mounts {
+		if !scratch[filepath.Clean(volumeMount.Target)] {
+			volumeMount.ReadOnly = true
+			mounts = append(mounts, volumeMount)
+		}
+	}
+	hostConfig.Mounts = mounts
+	hostConfig.Resources.DeviceRequests = nil
+
+	containerConfig.Entrypoint = []string{"sleep", strconv.Itoa(int(e.DebugSessionTimeout.Seconds()))}
+	containerConfig.AttachStdin = false
+	containerConfig.OpenStdin = false
+	containerConfig.StdinOnce = false
+	containerConfig.Labels = e.jobContainerLabels(shard.Job)
+
+	// the shard may have failed on this node before
+	name := debugContainerName(e.ID, shard.Job.ID, shard.Index)
+	if err := docker.RemoveContainer(ctx, e.Client, name); err != nil {
+		log.Ctx(ctx).Warn().Err(err).Msg("Failed to remove the environment kept of an earlier run of the shard")
+	}
+	debugContainer, err := e.Client.ContainerCreate(ctx, &containerConfig, &hostConfig, &network.NetworkingConfig{}, nil, name)
+	if err != nil {
+		log.Ctx(ctx).Warn().Err(err).Msg("Failed to keep the environment of the shard for debugging")
+		return
+	}
+	if err = e.Client.ContainerStart(ctx, debugContainer.ID, dockertypes.ContainerStartOptions{}); err != nil {
+		log.Ctx(ctx).Warn().Err(err).Msg("Failed to keep the environment of the shard for debugging")
+		e.removeDebugContainer(debugContainer.ID)
+		return
+	}
+
+	log.Ctx(ctx).Info().
+		Str("JobID", shard.Job.ID).
+		Int("ShardIndex", shard.Index).
+		Dur("Timeout", e.DebugSessionTimeout).
+		Msg("Keeping the environment of the failed shard for debugging")
+	time.AfterFunc(e.DebugSessionTimeout, func() {
+		e.removeDebugContainer(debugContainer.ID)
+	})
+}
+
+func (e *Executor) removeDebugContainer(id string) {
+	// the context of the shard is done by the time the session ends
+	if err := docker.RemoveContainer(context.Background(), e.Client, id); err != nil {
+		log.Warn().Err(err).Str("ContainerID", id).Msg("Failed to remove the environment kept for debugging")
+	}
+}
+
+// DebugSessions attaches shells to the environments the docker executor with
+// the ID keeps of failed shards.
+type DebugSessions struct {
+	client     *dockerclient.Client
+	executorID string
+}
+
+func NewDebugSessions(client *dockerclient.Client, executorID string) *DebugSessions {
+	return &DebugSessions{client: client, executorID: executorID}
+}
+
+func (d *DebugSessions) AttachShell(ctx context.Context, jobID string, shardIndex int, terminal io.ReadWriter) error {
+	debugContainer, err := docker.GetContainer(ctx, d.client, debugContainerName(d.executorID, jobID, shardIndex))
+	if err != nil {
+		return err
+	}
+	if debugContainer == nil || debugContainer.State != "running" {
+		return fmt.Errorf("this node has not kept the environment of shard %d of job %s, "+
+			"it is only kept on the node that ran the shard, for a while after it fails", shardIndex, jobID)
+	}
+
+	exec, err := d.client.ContainerExecCreate(ctx, debugContainer.ID, dockertypes.ExecConfig{
+		Cmd:          debugShell,
+		Tty:          true,
+		AttachStdin:  true,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to start a shell: %w", err)
+	}
+	attachment, err := d.client.ContainerExecAttach(ctx, exec.ID, dockertypes.ExecStartCheck{Tty: true})
+	if err != nil {
+		return fmt.Errorf("failed to attach to the shell: %w", err)
+	}
+	defer attachment.Close()
+
+	go func() {
+		// the shell exits once its stdin is closed
+		_, _ = io.Copy(attachment.Conn, terminal)
+		_ = attachment.CloseWrite()
+	}()
+	if _, err = io.Copy(terminal, attachment.Reader); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// Compile time interface check:
+var _ executor.DebugSessions = (*DebugSessions)(nil)
@@ -5,11 +5,12 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
-	"path/filepath"
 	"runtime/debug"
 	"strings"
+	"time"
 
 	"github.com/filecoin-project/bacalhau/pkg/compute/capacity"
+	capacitysystem "github.com/filecoin-project/bacalhau/pkg/compute/capacity/system"
 	"github.com/pkg/errors"
 	"go.uber.org/multierr"
 
@@ -24,21 +25,33 @@ import (
 	jobutils "github.com/filecoin-project/bacalhau/pkg/job"
 	"github.com/filecoin-project/bacalhau/pkg/model"
 	"github.com/filecoin-project/bacalhau/pkg/storage"
-	"github.com/filecoin-project/bacalhau/pkg/storage/util"
 	"github.com/filecoin-project/bacalhau/pkg/system"
 	"github.com/rs/zerolog/log"
 )
 
 const NanoCPUCoefficient = 1000000000
 
+// CleanupName is the name the docker executors register their cleanup with,
+// for other cleanups to be ordered against.
+const CleanupName = "docker-executor"
+
 type Executor struct {
 	// used to allow multiple docker executors to run against the same docker server
 	ID string
 
-	// the storage providers we can implement for a job
-	StorageProvider storage.StorageProvider
+	executor.StorageExecutor
 
 	Client *dockerclient.Client
+
+	// the GPUs of the node that aren't being used by a job
+	gpus *gpuAllocator
+
+	// what is applied to every job container
+	hardening *hardening
+
+	// how long the environment of failed shards of jobs that ask for it is
+	// kept for their clients to debug, not kept if 0
+	DebugSessionTimeout time.Duration
 }
 
 func NewExecutor(
@@ -46,56 +59,55 @@ func NewExecutor(
 	cm *system.CleanupManager,
 	id string,
 	storageProvider storage.StorageProvider,
+	hardeningConfig HardeningConfig,
 ) (*Executor, error) {
 	dockerClient, err := docker.NewDockerClient()
 	if err != nil {
 		return nil, err
 	}
+	return NewExecutorWithClient(ctx, cm, id, storageProvider, hardeningConfig, dockerClient)
+}
+
+// NewExecutorWithClient returns an executor that runs jobs through the
+// client, e.g. one of the Docker compatible API of podman.
+func NewExecutorWithClient(
+	ctx context.Context,
+	cm *system.CleanupManager,
+	id string,
+	storageProvider storage.StorageProvider,
+	hardeningConfig HardeningConfig,
+	dockerClient *dockerclient.Client,
+) (*Executor, error) {
+	hardening, err := newHardening(hardeningConfig)
+	if err != nil {
+		return nil, err
+	}
+	gpus, err := capacitysystem.GetSystemGPUs()
+	if err != nil {
+		log.Ctx(ctx).Warn().Err(err).Msg("Unable to list the GPUs of this node, jobs will not be given GPUs")
+	}
 
 	de := &Executor{
 		ID:              id,
-		StorageProvider: storageProvider,
+		StorageExecutor: executor.StorageExecutor{StorageProvider: storageProvider},
 		Client:          dockerClient,
+		gpus:            newGPUAllocator(gpus),
+		hardening:       hardening,
 	}
 
-	cm.RegisterCallback(func() error {
-		de.cleanupAll(ctx)
+	cm.RegisterCallbackWithContext(func(cleanupCtx context.Context) error {
+		de.cleanupAll(cleanupCtx)
 		return nil
-	})
+	}, system.WithName(CleanupName))
 
 	return de, nil
 }
 
-func (e *Executor) getStorage(ctx context.Context, engine model.StorageSourceType) (storage.Storage, error) {
-	return e.StorageProvider.GetStorage(ctx, engine)
-}
-
 // IsInstalled checks if docker itself is installed.
 func (e *Executor) IsInstalled(ctx context.Context) (bool, error) {
 	return docker.IsInstalled(ctx, e.Client), nil
 }
 
-func (e *Executor) HasStorageLocally(ctx context.Context, volume model.StorageSpec) (bool, error) {
-	//nolint:ineffassign,staticcheck
-	ctx, span := system.GetTracer().Start(ctx, "pkg/executor/docker/Executor.HasStorageLocally")
-	defer span.End()
-
-	s, err := e.getStorage(ctx, volume.StorageSource)
-	if err != nil {
-		return false, err
-	}
-
-	return s.HasStorageLocally(ctx, volume)
-}
-
-func (e *Executor) GetVolumeSize(ctx context.Context, volume model.StorageSpec) (uint64, error) {
-	storageProvider, err := e.getStorage(ctx, volume.StorageSource)
-	if err != nil {
-		return 0, err
-	}
-	return storageProvider.GetVolumeSize(ctx, volume)
-}
-
 //nolint:funlen,gocyclo // will clean up
 func (e *Executor) RunShard(
 	ctx context.Context,
@@ -108,96 +120,82 @@ func (e *Executor) RunShard(
 	system.AddJobIDFromBaggageToSpan(ctx, span)
 	system.AddNodeIDFromBaggageToSpan(ctx, span)
 
-	shardStorageSpec, err := jobutils.GetShardStorageSpec(ctx, shard, e.StorageProvider)
-	if err != nil {
-		return &model.RunCommandResult{}, err
+	if err := jobutils.VerifyDockerSpec(shard.Job.Spec.Docker); err != nil {
+		return &model.RunCommandResult{ErrorMsg: err.Error()}, err
 	}
 
-	inputStorageSpecs := []model.StorageSpec{}
-	inputStorageSpecs = append(inputStorageSpecs, shard.Job.Spec.Contexts...)
-	inputStorageSpecs = append(inputStorageSpecs, shardStorageSpec...)
-
-	inputVolumes, err := storage.ParallelPrepareStorage(ctx, e.StorageProvider, inputStorageSpecs)
+	inputs, err := e.PrepareInputs(ctx, shard)
 	if err != nil {
 		return &model.RunCommandResult{}, err
 	}
+	outputs, err := executor.PrepareOutputs(ctx, shard, jobResultsDir)
+	if err != nil {
+		return &model.RunCommandResult{ErrorMsg: err.Error()}, err
+	}
 
 	// the actual mounts we will give to the container
 	// these are paths for both input and output data
 	mounts := []mount.Mount{}
-	for spec, volumeMount := range inputVolumes {
-		if volumeMount.Type == storage.StorageVolumeConnectorBind {
-			log.Ctx(ctx).Trace().Msgf("Input Volume: %+v %+v", spec, volumeMount)
-			mounts = append(mounts, mount.Mount{
-				Type: mount.TypeBind,
-				// this is an input volume so is read only
-				ReadOnly: true,
-				Source:   volumeMount.Source,
-				Target:   volumeMount.Target,
-			})
-		} else {
-			return &model.RunCommandResult{}, fmt.Errorf("unknown storage volume type: %s", volumeMount.Type)
-		}
+	for _, m := range append(inputs, outputs...) {
+		mounts = append(mounts, mount.Mount{
+			Type:     mount.TypeBind,
+			ReadOnly: m.ReadOnly,
+			Source:   m.Source,
+			Target:   m.Target,
+		})
 	}
 
-	// for this phase of the outputs we ignore the engine because it's just about collecting the
-	// data from the job and keeping it locally
-	// the engine property of the output storage spec is how we will "publish" the output volume
-	// if and when the deal is settled
-	for _, output := range shard.Job.Spec.Outputs {
-		if output.Name == "" {
-			err = fmt.Errorf("output volume has no name: %+v", output)
-			return &model.RunCommandResult{ErrorMsg: err.Error()}, err
-		}
-
-		if output.Path == "" {
-			err = fmt.Errorf("output volume has no path: %+v", output)
-			return &model.RunCommandResult{ErrorMsg: err.Error()}, err
-		}
-
-		srcd := filepath.Join(jobResultsDir, output.Name)
-		err = os.Mkdir(srcd, util.OS_ALL_R|util.OS_ALL_X|util.OS_USER_W)
-		if err != nil {
-			return &model.RunCommandResult{ErrorMsg: err.Error()}, err
+	scratchVolumes, err := executor.PrepareScratchVolumes(shard.Job.Spec.Scratch, "")
+	defer func() {
+		if cleanupErr := executor.CleanupScratchVolumes(scratchVolumes); cleanupErr != nil {
+			log.Ctx(ctx).Warn().Err(cleanupErr).Msg("failed to remove scratch volumes")
 		}
-
-		log.Ctx(ctx).Trace().Msgf("Output Volume: %+v", output)
-
-		// create a mount so the output data does not need to be copied back to the host
-		mounts = append(mounts, mount.Mount{
-
-			Type: mount.TypeBind,
-			// this is an output volume so can be written to
-			ReadOnly: false,
-
-			// we create a named folder in the job results folder for this output
-			Source: srcd,
-
-			// the path of the output volume is from the perspective of inside the container
-			Target: output.Path,
-		})
+	}()
+	if err != nil {
+		return &model.RunCommandResult{ErrorMsg: err.Error()}, err
 	}
+	mounts = append(mounts, scratchMounts(scratchVolumes)...)
 
 	if os.Getenv("SKIP_IMAGE_PULL") == "" {
 		if err := docker.PullImage(ctx, e.Client, shard.Job.Spec.Docker.Image); err != nil { //nolint:govet // ignore err shadowing
 			//nolint:stylecheck // Error message for user
 			err = fmt.Errorf(`Could not pull image - could be due to repo/image not existing,
  or registry needing authorization. %s: %s`, shard.Job.Spec.Docker.Image, err)
-			return returnStdErrWithErr(ctx, err.Error(), err), err
+			return executor.ErrorResult(ctx, err.Error(), err), err
 		}
 	}
+	// recorded with the results, so that the owners of the data can tell
+	// what exactly ran against it
+	provenance, err := docker.GetImageProvenance(ctx, e.Client, shard.Job.Spec.Docker.Image)
+	if err != nil {
+		log.Ctx(ctx).Warn().Err(err).Msgf("could not get the provenance of image %s", shard.Job.Spec.Docker.Image)
+	}
 
 	// json the job spec and pass it into all containers
 	// TODO: check if this will overwrite a user supplied version of this value
 	// (which is what we actually want to happen)
-	log.Ctx(ctx).Debug().Msgf("Job Spec: %+v", shard.Job.Spec)
+	log.Ctx(ctx).Debug().Msgf("Job Spec: %+v", executor.RedactEnv(shard.Job.Spec))
 	jsonJobSpec, err := model.JSONMarshalWithMax(shard.Job.Spec)
 	if err != nil {
 		return &model.RunCommandResult{ErrorMsg: err.Error()}, err
 	}
-	log.Ctx(ctx).Debug().Msgf("Job Spec JSON: %s", jsonJobSpec)
 
-	useEnv := append(shard.Job.Spec.Docker.EnvironmentVariables, fmt.Sprintf("BACALHAU_JOB_SPEC=%s", string(jsonJobSpec))) //nolint:gocritic
+	resourceRequirements := capacity.ParseResourceUsageConfig(shard.Job.Spec.Resources)
+
+	// give the job GPUs that no other job is using
+	var gpus []capacitysystem.GPU
+	if resourceRequirements.GPU > 0 {
+		var releaseGPUs func()
+		gpus, releaseGPUs, err = e.gpus.allocate(resourceRequirements.GPU)
+		if err != nil {
+			return executor.ErrorResult(ctx, "failed to allocate GPUs: ", err), err
+		}
+		defer releaseGPUs()
+		log.Ctx(ctx).Debug().Msgf("Adding GPUs %+v to request", gpus)
+	}
+
+	useEnv := gpuEnv(shard.Job.Spec.Docker.EnvironmentVariables, gpus)
+	useEnv = append(useEnv, fmt.Sprintf("BACALHAU_JOB_SPEC=%s", string(jsonJobSpec)))
 
 	containerConfig := &container.Config{
 		Image:           shard.Job.Spec.Docker.Image,
@@ -207,41 +205,48 @@ func (e *Executor) RunShard(
 		Labels:          e.jobContainerLabels(shard.Job),
 		NetworkDisabled: true,
 		WorkingDir:      shard.Job.Spec.Docker.WorkingDirectory,
+		User:            shard.Job.Spec.Docker.User,
+	}
+	var stdinPath string
+	if shard.Job.Spec.Docker.Stdin != "" {
+		stdinPath, err = stdinHostPath(inputs, shard.Job.Spec.Docker.Stdin)
+		if err != nil {
+			return &model.RunCommandResult{ErrorMsg: err.Error()}, err
+		}
+		containerConfig.AttachStdin = true
+		containerConfig.OpenStdin = true
+		containerConfig.StdinOnce = true
 	}
 
-	log.Ctx(ctx).Trace().Msgf("Container: %+v %+v", containerConfig, mounts)
-
-	resourceRequirements := capacity.ParseResourceUsageConfig(shard.Job.Spec.Resources)
+	log.Ctx(ctx).Trace().Msgf("Container: %s %v as %q %+v",
+		containerConfig.Image, containerConfig.Entrypoint, containerConfig.User, mounts)
 
-	// Create GPU request if the job requests it
-	var deviceRequests []container.DeviceRequest
-	if resourceRequirements.GPU > 0 {
-		deviceRequests = append(deviceRequests,
-			container.DeviceRequest{
-				DeviceIDs:    []string{"0"}, // TODO: how do we know which device ID to use?
-				Capabilities: [][]string{{"gpu"}},
-			},
-		)
-		log.Ctx(ctx).Trace().Msgf("Adding %d GPUs to request", resourceRequirements.GPU)
+	hostConfig := &container.HostConfig{
+		Mounts: mounts,
+		Resources: container.Resources{
+			Memory:         int64(resourceRequirements.Memory),
+			NanoCPUs:       int64(resourceRequirements.CPU * NanoCPUCoefficient),
+			DeviceRequests: gpuDeviceRequests(gpus),
+		},
 	}
+	e.hardening.apply(hostConfig)
 
 	jobContainer, err := e.Client.ContainerCreate(
 		ctx,
 		containerConfig,
-		&container.HostConfig{
-			Mounts: mounts,
-			Resources: container.Resources{
-				Memory:         int64(resourceRequirements.Memory),
-				NanoCPUs:       int64(resourceRequirements.CPU * NanoCPUCoefficient),
-				DeviceRequests: deviceRequests,
-			},
-		},
+		hostConfig,
 		&network.NetworkingConfig{},
 		nil,
 		e.jobContainerName(shard),
 	)
 	if err != nil {
-		return returnStdErrWithErr(ctx, "failed to create container: ", err), err
+		return executor.ErrorResult(ctx, "failed to create container: ", err), err
+	}
+
+	if stdinPath != "" {
+		if err = attachStdin(ctx, e.Client, jobContainer.ID, stdinPath); err != nil {
+			return executor.ErrorResult(ctx, err.Error(), err), err
+		}
 	}
 
 	containerStartError := e.Client.ContainerStart(
@@ -257,7 +262,7 @@ func (e *Executor) RunShard(
 			internalContainerStartErrorMsg = "Executable file not found: " + containerStartError.Error()
 		}
 		internalContainerStartError := fmt.Errorf(internalContainerStartErrorMsg)
-		return returnStdErrWithErr(ctx, internalContainerStartError.Error(),
+		return executor.ErrorResult(ctx, internalContainerStartError.Error(),
 				internalContainerStartError),
 			internalContainerStartError
 	}
@@ -298,31 +303,28 @@ func (e *Executor) RunShard(
 		}
 	}()
 
-	var exitCodeErr error
-	if containerExitStatusCode != 0 {
-		exitCodeErr = fmt.Errorf("exit code was not zero: %d", containerExitStatusCode)
+	if (containerExitStatusCode != 0 || containerError != nil) && shard.Job.Spec.DebugOnFailure && e.DebugSessionTimeout > 0 {
+		e.keepForDebugging(ctx, shard, *containerConfig, *hostConfig)
 	}
+	scratchErr := executor.CheckScratchVolumes(scratchVolumes)
 
-	return executor.WriteJobResults(
+	result, err := executor.WriteShardResults(
 		jobResultsDir,
 		stdoutPipe,
 		stderrPipe,
 		int(containerExitStatusCode),
-		multierr.Combine(containerError, startErr, stdoutErr, stderrErr, exitCodeErr),
+		multierr.Combine(containerError, startErr, stdoutErr, stderrErr, scratchErr),
 	)
+	if result != nil {
+		result.Provenance = provenance
+	}
+	return result, err
 }
 
 func (e *Executor) CancelShard(ctx context.Context, shard model.JobShard) error {
 	return docker.StopContainer(ctx, e.Client, e.jobContainerName(shard))
 }
 
-func returnStdErrWithErr(ctx context.Context, msg string, err error) *model.RunCommandResult {
-	log.Ctx(ctx).Debug().Str("msg", msg).Err(err).Msg("Returning error")
-	return &model.RunCommandResult{
-		STDERR:   err.Error(),
-		ErrorMsg: errors.Wrap(err, msg).Error(),
-	}
-}
 func (e *Executor) cleanupJob(ctx context.Context, shard model.JobShard) {
 	if config.ShouldKeepStack() {
 		return
@@ -340,9 +342,9 @@ func (e *Executor) cleanupAll(ctx context.Context) {
 		return
 	}
 
-	// We have to use a separate context, rather than the one passed in to `NewExecutor`, as it may have already been
+	// ctx is the context of the cleanup rather than the one passed in to `NewExecutor`, as that may have already been
 	// canceled and so would prevent us from performing any cleanup work.
-	safeCtx := context.Background()
+	safeCtx := ctx
 
 	log.Ctx(ctx).Debug().Msgf("Cleaning up all bacalhau containers for executor %s...", e.ID)
 	containersWithLabel, err := docker.GetContainersWithLabel(safeCtx, e.Client, "bacalhau-executor", e.ID)
@@ -358,6 +360,38 @@ func (e *Executor) cleanupAll(ctx context.Context) {
 	log.Ctx(ctx).Debug().Msgf("Finished cleaning up all bacalhau containers for executor %s", e.ID)
 }
 
+// CollectGarbage implements executor.GarbageCollector, removing the
+// containers of this executor whose jobs no longer run on the node. The
+// environments kept of failed shards for debugging are kept until their
+// session times out.
+func (e *Executor) CollectGarbage(ctx context.Context, running func(jobID string) bool) (int, error) {
+	if config.ShouldKeepStack() {
+		return 0, nil
+	}
+	containers, err := docker.GetContainersWithLabel(ctx, e.Client, "bacalhau-executor", e.ID)
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	var errs error
+	//nolint:gocritic // containers are small enough to copy
+	for _, container := range containers {
+		if running(container.Labels["bacalhau-jobID"]) {
+			continue
+		}
+		if isDebugContainer(container) && time.Since(time.Unix(container.Created, 0)) < e.DebugSessionTimeout {
+			continue
+		}
+		if err = docker.RemoveContainer(ctx, e.Client, container.ID); err != nil {
+			errs = multierr.Append(errs, err)
+			continue
+		}
+		log.Ctx(ctx).Debug().Msgf("Removed orphaned container %s of job %s", container.ID, container.Labels["bacalhau-jobID"])
+		removed++
+	}
+	return removed, errs
+}
+
 func (e *Executor) jobContainerName(shard model.JobShard) string {
 	return fmt.Sprintf("bacalhau-%s-%s-%d", e.ID, shard.Job.ID, shard.Index)
 }
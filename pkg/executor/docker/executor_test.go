@@ -32,6 +32,7 @@ func (suite *ExecutorTestSuite) SetupSuite() {
 		suite.cm,
 		"bacalhau-executor-unittest",
 		storage.NewMappedStorageProvider(map[model.StorageSourceType]storage.Storage{}),
+		HardeningConfig{},
 	)
 	require.NoError(suite.T(), err)
 }
@@ -0,0 +1,200 @@
+package docker
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/docker/docker/api/types/container"
+	capacitysystem "github.com/filecoin-project/bacalhau/pkg/compute/capacity/system"
+)
+
+// the environment variables that say which GPUs a container can use - the
+// NVIDIA container runtime reads the first, and CUDA the second
+const (
+	nvidiaVisibleDevicesEnv = "NVIDIA_VISIBLE_DEVICES"
+	cudaVisibleDevicesEnv   = "CUDA_VISIBLE_DEVICES"
+)
+
+// the allocator counts GPUs in thousandths, like CPU millicores, so that
+// fractions of GPUs add up exactly
+const milliGPUsPerGPU = 1000
+
+// gpuAllocator hands out the GPUs of the node to jobs, so that jobs running
+// at the same time on a node with several GPUs never share one unless they
+// each asked for a fraction of a GPU.
+type gpuAllocator struct {
+	mutex   sync.Mutex
+	devices []*gpuDevice
+}
+
+type gpuDevice struct {
+	gpu capacitysystem.GPU
+	// how much of a GPU the device is, and how much of that is in use
+	capacity int
+	used     int
+}
+
+// a MIG slice is a device of its own, so is only used by one job at a time -
+// whereas jobs asking for fractions of a whole GPU take turns on it
+func (d *gpuDevice) isMIG() bool {
+	return d.gpu.MIGProfile != ""
+}
+
+func newGPUAllocator(gpus []capacitysystem.GPU) *gpuAllocator {
+	a := &gpuAllocator{}
+	for _, gpu := range gpus {
+		fraction := gpu.Fraction
+		if fraction <= 0 {
+			fraction = 1
+		}
+		a.devices = append(a.devices, &gpuDevice{
+			gpu:      gpu,
+			capacity: int(math.Round(fraction * milliGPUsPerGPU)),
+		})
+	}
+	return a
+}
+
+// allocate reserves GPUs for a job that asked for the given GPU units until
+// the returned release function is called. A job either gets whole GPUs, or
+// for a fraction of a GPU the smallest free MIG slice that is big enough, or
+// else a share of a whole GPU.
+func (a *gpuAllocator) allocate(units float64) ([]capacitysystem.GPU, func(), error) {
+	request := int(math.Round(units * milliGPUsPerGPU))
+	if request <= 0 {
+		return nil, func() {}, nil
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	var allocated []*gpuDevice
+	var reserved []int
+	if request < milliGPUsPerGPU {
+		device := a.bestFractionalDevice(request)
+		if device == nil {
+			return nil, nil, fmt.Errorf("job needs %.3f of a GPU but no GPU of this node has that much free", units)
+		}
+		allocated, reserved = []*gpuDevice{device}, []int{request}
+		if device.isMIG() {
+			reserved[0] = device.capacity
+		}
+	} else {
+		if request%milliGPUsPerGPU != 0 {
+			return nil, nil, fmt.Errorf("job needs %.3f GPUs, but more than one GPU can only be asked for in whole GPUs", units)
+		}
+		count := request / milliGPUsPerGPU
+		for _, device := range a.devices {
+			if len(allocated) == count {
+				break
+			}
+			if !device.isMIG() && device.used == 0 {
+				allocated = append(allocated, device)
+				reserved = append(reserved, device.capacity)
+			}
+		}
+		if len(allocated) < count {
+			return nil, nil, fmt.Errorf("job needs %d GPUs but only %d of the %d GPUs of this node are free",
+				count, len(allocated), a.countWholeGPUs())
+		}
+	}
+
+	var gpus []capacitysystem.GPU
+	for i, device := range allocated {
+		device.used += reserved[i]
+		gpus = append(gpus, device.gpu)
+	}
+	release := func() {
+		a.mutex.Lock()
+		defer a.mutex.Unlock()
+		for i, device := range allocated {
+			device.used -= reserved[i]
+		}
+	}
+	return gpus, release, nil
+}
+
+// bestFractionalDevice returns the smallest free MIG slice the request fits
+// in, or else the busiest whole GPU it fits on, so that whole GPUs are kept
+// free for jobs that need them.
+func (a *gpuAllocator) bestFractionalDevice(request int) *gpuDevice {
+	var best *gpuDevice
+	for _, device := range a.devices {
+		if device.isMIG() && device.used == 0 && device.capacity >= request &&
+			(best == nil || device.capacity < best.capacity) {
+			best = device
+		}
+	}
+	if best != nil {
+		return best
+	}
+	for _, device := range a.devices {
+		if !device.isMIG() && device.capacity-device.used >= request &&
+			(best == nil || device.used > best.used) {
+			best = device
+		}
+	}
+	return best
+}
+
+func (a *gpuAllocator) countWholeGPUs() int {
+	count := 0
+	for _, device := range a.devices {
+		if !device.isMIG() {
+			count++
+		}
+	}
+	return count
+}
+
+// gpuDeviceRequests returns the device requests that attach the GPUs to a
+// container.
+func gpuDeviceRequests(gpus []capacitysystem.GPU) []container.DeviceRequest {
+	if len(gpus) == 0 {
+		return nil
+	}
+	var deviceIDs []string
+	for _, gpu := range gpus {
+		deviceIDs = append(deviceIDs, gpu.Index)
+	}
+	return []container.DeviceRequest{{
+		Driver:       "nvidia",
+		DeviceIDs:    deviceIDs,
+		Capabilities: [][]string{{"gpu"}},
+	}}
+}
+
+// gpuEnv replaces any GPU visibility variables the job sets with ones that
+// only expose its GPUs, so that a job can't reach the GPUs of other jobs
+// through the NVIDIA container runtime.
+func gpuEnv(env []string, gpus []capacitysystem.GPU) []string {
+	var res []string
+	for _, variable := range env {
+		name, _, _ := strings.Cut(variable, "=")
+		if name != nvidiaVisibleDevicesEnv && name != cudaVisibleDevicesEnv {
+			res = append(res, variable)
+		}
+	}
+	if len(gpus) == 0 {
+		return append(res, nvidiaVisibleDevicesEnv+"=void")
+	}
+
+	var indexes, cudaDevices []string
+	for i, gpu := range gpus {
+		indexes = append(indexes, gpu.Index)
+		// the GPUs of a container are numbered from zero, so refer to them by
+		// UUID when we can so that the numbering doesn't matter
+		if gpu.UUID != "" {
+			cudaDevices = append(cudaDevices, gpu.UUID)
+		} else {
+			cudaDevices = append(cudaDevices, strconv.Itoa(i))
+		}
+	}
+	return append(res,
+		nvidiaVisibleDevicesEnv+"="+strings.Join(indexes, ","),
+		cudaVisibleDevicesEnv+"="+strings.Join(cudaDevices, ","),
+	)
+}
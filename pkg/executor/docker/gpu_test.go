@@ -0,0 +1,97 @@
+//go:build unit || !integration
+
+package docker
+
+import (
+	"testing"
+
+	capacitysystem "github.com/filecoin-project/bacalhau/pkg/compute/capacity/system"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGPUAllocator(t *testing.T) {
+	allocator := newGPUAllocator([]capacitysystem.GPU{{Index: "0"}, {Index: "1"}, {Index: "2"}})
+
+	first, releaseFirst, err := allocator.allocate(2)
+	require.NoError(t, err)
+	require.Equal(t, []capacitysystem.GPU{{Index: "0"}, {Index: "1"}}, first)
+
+	// concurrent jobs never share a GPU
+	second, releaseSecond, err := allocator.allocate(1)
+	require.NoError(t, err)
+	require.Equal(t, []capacitysystem.GPU{{Index: "2"}}, second)
+
+	_, _, err = allocator.allocate(1)
+	require.ErrorContains(t, err, "only 0 of the 3 GPUs of this node are free")
+
+	releaseFirst()
+	third, releaseThird, err := allocator.allocate(2)
+	require.NoError(t, err)
+	require.Equal(t, first, third)
+	releaseSecond()
+	releaseThird()
+
+	_, _, err = allocator.allocate(4)
+	require.Error(t, err)
+}
+
+func TestGPUAllocatorFractions(t *testing.T) {
+	allocator := newGPUAllocator([]capacitysystem.GPU{
+		{Index: "0", Fraction: 1},
+		{Index: "1:0", MIGProfile: "3g.20gb", Fraction: 3.0 / 7},
+		{Index: "1:1", MIGProfile: "1g.5gb", Fraction: 1.0 / 7},
+	})
+
+	// the smallest MIG slice that is big enough is used
+	gpus, releaseSmall, err := allocator.allocate(0.1)
+	require.NoError(t, err)
+	require.Equal(t, "1:1", gpus[0].Index)
+	gpus, releaseLarge, err := allocator.allocate(0.1)
+	require.NoError(t, err)
+	require.Equal(t, "1:0", gpus[0].Index)
+
+	// once the slices are taken, jobs share the whole GPU
+	gpus, releaseFirstShare, err := allocator.allocate(0.5)
+	require.NoError(t, err)
+	require.Equal(t, "0", gpus[0].Index)
+	gpus, releaseSecondShare, err := allocator.allocate(0.5)
+	require.NoError(t, err)
+	require.Equal(t, "0", gpus[0].Index)
+	_, _, err = allocator.allocate(0.001)
+	require.Error(t, err)
+
+	// a whole GPU can't be taken while it's shared
+	releaseFirstShare()
+	_, _, err = allocator.allocate(1)
+	require.Error(t, err)
+	releaseSecondShare()
+	gpus, releaseWhole, err := allocator.allocate(1)
+	require.NoError(t, err)
+	require.Equal(t, "0", gpus[0].Index)
+	releaseWhole()
+
+	_, _, err = allocator.allocate(1.5)
+	require.ErrorContains(t, err, "whole GPUs")
+
+	releaseSmall()
+	releaseLarge()
+}
+
+func TestGPUEnv(t *testing.T) {
+	userEnv := []string{"FOO=bar", "NVIDIA_VISIBLE_DEVICES=all", "CUDA_VISIBLE_DEVICES=0,1"}
+
+	// jobs without GPUs can't ask the NVIDIA runtime for them
+	require.Equal(t, []string{"FOO=bar", "NVIDIA_VISIBLE_DEVICES=void"}, gpuEnv(userEnv, nil))
+
+	gpus := []capacitysystem.GPU{{Index: "1", UUID: "GPU-a"}, {Index: "3", UUID: "GPU-b"}}
+	require.Equal(t, []string{"FOO=bar", "NVIDIA_VISIBLE_DEVICES=1,3", "CUDA_VISIBLE_DEVICES=GPU-a,GPU-b"}, gpuEnv(userEnv, gpus))
+
+	// without UUIDs CUDA gets the indexes of the GPUs within the container
+	gpus = []capacitysystem.GPU{{Index: "1"}, {Index: "3"}}
+	require.Equal(t, []string{"NVIDIA_VISIBLE_DEVICES=1,3", "CUDA_VISIBLE_DEVICES=0,1"}, gpuEnv(nil, gpus))
+
+	requests := gpuDeviceRequests(gpus)
+	require.Len(t, requests, 1)
+	require.Equal(t, []string{"1", "3"}, requests[0].DeviceIDs)
+	require.Nil(t, gpuDeviceRequests(nil))
+}
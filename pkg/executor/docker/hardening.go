@@ -0,0 +1,95 @@
+package docker
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/go-units"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+)
+
+// HardeningConfig is the hardening node operators apply to every job
+// container, on top of the defaults of the runtime.
+type HardeningConfig struct {
+	// The path of a seccomp profile in JSON to run containers with, or the
+	// default profile of the runtime if empty.
+	SeccompProfile string
+	// The AppArmor profile to run containers with, which must be loaded on
+	// the host, or the default profile of the runtime if empty.
+	AppArmorProfile string
+	// The most processes a container can run, or no limit if 0.
+	PidsLimit int64
+	// The ulimits of containers, as NAME=SOFT[:HARD], e.g. nofile=1024:2048.
+	Ulimits []string
+}
+
+// hardening is the HardeningConfig in the form of the Docker API.
+type hardening struct {
+	securityOpts []string
+	pidsLimit    *int64
+	ulimits      []*units.Ulimit
+	info         *model.NodeHardening
+}
+
+func newHardening(config HardeningConfig) (*hardening, error) {
+	h := &hardening{info: &model.NodeHardening{
+		AppArmorProfile: config.AppArmorProfile,
+		PidsLimit:       config.PidsLimit,
+	}}
+	if config.SeccompProfile != "" {
+		profile, err := os.ReadFile(config.SeccompProfile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read the seccomp profile: %w", err)
+		}
+		// the Docker API takes the profile inline, as the CLI sends it
+		var compacted bytes.Buffer
+		if err = json.Compact(&compacted, profile); err != nil {
+			return nil, fmt.Errorf("the seccomp profile %s is not JSON: %w", config.SeccompProfile, err)
+		}
+		h.securityOpts = append(h.securityOpts, "seccomp="+compacted.String())
+		h.info.SeccompProfile = strings.TrimSuffix(filepath.Base(config.SeccompProfile), filepath.Ext(config.SeccompProfile))
+	}
+	if config.AppArmorProfile != "" {
+		h.securityOpts = append(h.securityOpts, "apparmor="+config.AppArmorProfile)
+	}
+	if config.PidsLimit < 0 {
+		return nil, fmt.Errorf("the pids limit must be positive, or 0 for no limit")
+	}
+	if config.PidsLimit > 0 {
+		h.pidsLimit = &config.PidsLimit
+	}
+	for _, value := range config.Ulimits {
+		ulimit, err := units.ParseUlimit(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ulimit %q, must be NAME=SOFT[:HARD]: %w", value, err)
+		}
+		h.ulimits = append(h.ulimits, ulimit)
+		h.info.Ulimits = append(h.info.Ulimits, ulimit.String())
+	}
+	return h, nil
+}
+
+// apply hardens the host config of a job container.
+func (h *hardening) apply(hostConfig *container.HostConfig) {
+	hostConfig.SecurityOpt = append(hostConfig.SecurityOpt, h.securityOpts...)
+	hostConfig.Resources.PidsLimit = h.pidsLimit
+	hostConfig.Resources.Ulimits = append(hostConfig.Resources.Ulimits, h.ulimits...)
+}
+
+// NodeHardening returns what the node says about the hardening of its
+// containers, or nil if the config doesn't harden them.
+func (c HardeningConfig) NodeHardening() (*model.NodeHardening, error) {
+	h, err := newHardening(c)
+	if err != nil {
+		return nil, err
+	}
+	if len(h.securityOpts) == 0 && h.pidsLimit == nil && len(h.ulimits) == 0 {
+		return nil, nil
+	}
+	return h.info, nil
+}
@@ -0,0 +1,80 @@
+//go:build unit || !integration
+
+package docker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/go-units"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHardening(t *testing.T) {
+	profile := filepath.Join(t.TempDir(), "strict.json")
+	require.NoError(t, os.WriteFile(profile, []byte("{\n  \"defaultAction\": \"SCMP_ACT_ERRNO\"\n}\n"), 0600))
+
+	h, err := newHardening(HardeningConfig{
+		SeccompProfile:  profile,
+		AppArmorProfile: "bacalhau-job",
+		PidsLimit:       512,
+		Ulimits:         []string{"nofile=1024:2048", "nproc=256"},
+	})
+	require.NoError(t, err)
+
+	hostConfig := &container.HostConfig{}
+	h.apply(hostConfig)
+	require.Equal(t, []string{`seccomp={"defaultAction":"SCMP_ACT_ERRNO"}`, "apparmor=bacalhau-job"}, hostConfig.SecurityOpt)
+	require.Equal(t, int64(512), *hostConfig.PidsLimit)
+	require.Equal(t, []*units.Ulimit{
+		{Name: "nofile", Soft: 1024, Hard: 2048},
+		{Name: "nproc", Soft: 256, Hard: 256},
+	}, hostConfig.Ulimits)
+
+	require.Equal(t, &model.NodeHardening{
+		SeccompProfile:  "strict",
+		AppArmorProfile: "bacalhau-job",
+		PidsLimit:       512,
+		Ulimits:         []string{"nofile=1024:2048", "nproc=256:256"},
+	}, h.info)
+	require.True(t, h.info.Hardened())
+}
+
+func TestHardeningDefaults(t *testing.T) {
+	h, err := newHardening(HardeningConfig{})
+	require.NoError(t, err)
+	hostConfig := &container.HostConfig{}
+	h.apply(hostConfig)
+	require.Empty(t, hostConfig.SecurityOpt)
+	require.Nil(t, hostConfig.PidsLimit)
+	require.Empty(t, hostConfig.Ulimits)
+
+	nodeHardening, err := HardeningConfig{}.NodeHardening()
+	require.NoError(t, err)
+	require.Nil(t, nodeHardening)
+	require.False(t, nodeHardening.Hardened())
+
+	nodeHardening, err = HardeningConfig{Ulimits: []string{"nofile=1024"}}.NodeHardening()
+	require.NoError(t, err)
+	require.False(t, nodeHardening.Hardened())
+}
+
+func TestHardeningInvalid(t *testing.T) {
+	for _, config := range []HardeningConfig{
+		{SeccompProfile: filepath.Join(t.TempDir(), "missing.json")},
+		{PidsLimit: -1},
+		{Ulimits: []string{"nofile"}},
+		{Ulimits: []string{"unknown=1"}},
+	} {
+		_, err := newHardening(config)
+		require.Error(t, err, "%+v", config)
+	}
+
+	profile := filepath.Join(t.TempDir(), "profile.json")
+	require.NoError(t, os.WriteFile(profile, []byte("not json"), 0600))
+	_, err := newHardening(HardeningConfig{SeccompProfile: profile})
+	require.Error(t, err)
+}
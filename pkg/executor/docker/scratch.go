@@ -0,0 +1,31 @@
+package docker
+
+import (
+	"github.com/docker/docker/api/types/mount"
+	"github.com/filecoin-project/bacalhau/pkg/executor"
+)
+
+// scratchMounts returns the mounts of the scratch volumes of a job, which
+// are tmpfs mounts whose size the kernel enforces or bind mounts of the
+// directories that back them.
+func scratchMounts(volumes []executor.ScratchVolume) []mount.Mount {
+	mounts := make([]mount.Mount, 0, len(volumes))
+	for _, volume := range volumes {
+		if volume.Dir == "" {
+			mounts = append(mounts, mount.Mount{
+				Type:         mount.TypeTmpfs,
+				Target:       volume.Spec.Path,
+				TmpfsOptions: &mount.TmpfsOptions{SizeBytes: int64(volume.Size)},
+			})
+			continue
+		}
+		mounts = append(mounts, mount.Mount{
+			Type: mount.TypeBind,
+			// scratch volumes are private to the job, so can be written to
+			ReadOnly: false,
+			Source:   volume.Dir,
+			Target:   volume.Spec.Path,
+		})
+	}
+	return mounts
+}
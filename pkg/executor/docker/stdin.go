@@ -0,0 +1,77 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	dockertypes "github.com/docker/docker/api/types"
+	dockerclient "github.com/docker/docker/client"
+	"github.com/filecoin-project/bacalhau/pkg/executor"
+	"github.com/rs/zerolog/log"
+)
+
+// stdinHostPath returns the path on the host of the file a job reads on
+// stdin, which is in one of the inputs the job has mounted. Symlinks
+// are resolved, as the file is read on the host and not in the container,
+// so that inputs can't link to files elsewhere on the host.
+func stdinHostPath(inputs []executor.Mount, stdin string) (string, error) {
+	stdin = path.Clean(stdin)
+	for _, volume := range inputs {
+		target := path.Clean(volume.Target)
+		relative := strings.TrimPrefix(stdin, strings.TrimSuffix(target, "/")+"/")
+		if stdin == target {
+			relative = "."
+		} else if relative == stdin {
+			continue
+		}
+
+		source, err := filepath.EvalSymlinks(volume.Source)
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve stdin: %w", err)
+		}
+		hostPath, err := filepath.EvalSymlinks(filepath.Join(source, filepath.FromSlash(relative)))
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve stdin: %w", err)
+		}
+		if hostPath != source && !strings.HasPrefix(hostPath, source+string(filepath.Separator)) {
+			return "", fmt.Errorf("stdin %s links outside of its input volume", stdin)
+		}
+		return hostPath, nil
+	}
+	return "", fmt.Errorf("stdin %s is not in any of the input volumes", stdin)
+}
+
+// attachStdin attaches to the stdin of a container before it starts and
+// writes the file to it, like docker start --attach --interactive does. The
+// stdin of the container is closed once the whole file is written, so that
+// the entrypoint sees the end of it like with a pipe.
+func attachStdin(ctx context.Context, client *dockerclient.Client, containerID string, hostPath string) error {
+	file, err := os.Open(hostPath)
+	if err != nil {
+		return fmt.Errorf("failed to open stdin: %w", err)
+	}
+	attachment, err := client.ContainerAttach(ctx, containerID, dockertypes.ContainerAttachOptions{
+		Stream: true,
+		Stdin:  true,
+	})
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to attach to stdin: %w", err)
+	}
+	go func() {
+		defer file.Close()
+		defer attachment.Close()
+		if _, err := io.Copy(attachment.Conn, file); err != nil {
+			log.Ctx(ctx).Warn().Err(err).Msg("failed to write stdin to the container")
+		}
+		if err := attachment.CloseWrite(); err != nil {
+			log.Ctx(ctx).Warn().Err(err).Msg("failed to close the stdin of the container")
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,42 @@
+//go:build unit || !integration
+
+package docker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/filecoin-project/bacalhau/pkg/executor"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStdinHostPath(t *testing.T) {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+	file := filepath.Join(dir, "stdin")
+	inputs := filepath.Join(dir, "inputs")
+	require.NoError(t, os.WriteFile(file, []byte("a,b\n"), os.ModePerm))
+	require.NoError(t, os.MkdirAll(filepath.Join(inputs, "data"), os.ModePerm))
+	require.NoError(t, os.WriteFile(filepath.Join(inputs, "data", "people.csv"), []byte("a,b\n"), os.ModePerm))
+	require.NoError(t, os.Symlink("/etc/hostname", filepath.Join(inputs, "hostname")))
+
+	volumes := []executor.Mount{
+		{Source: file, Target: "/stdin", ReadOnly: true},
+		{Source: inputs, Target: "/inputs", ReadOnly: true},
+	}
+
+	hostPath, err := stdinHostPath(volumes, "/stdin")
+	require.NoError(t, err)
+	require.Equal(t, file, hostPath)
+
+	hostPath, err = stdinHostPath(volumes, "/inputs/data/../data/people.csv")
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(inputs, "data", "people.csv"), hostPath)
+
+	_, err = stdinHostPath(volumes, "/inputs/hostname")
+	require.Error(t, err)
+
+	_, err = stdinHostPath(volumes, "/inputsx/people.csv")
+	require.Error(t, err)
+}
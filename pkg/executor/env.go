@@ -0,0 +1,64 @@
+package executor
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+)
+
+// WithEnv returns a copy of the shard whose job runs with the environment
+// variables as well as its own, whatever its engine, the variables taking
+// precedence. The copy is only for running the shard, as the variables may
+// include secrets that must not be stored or published with the job.
+func WithEnv(shard model.JobShard, env map[string]string) model.JobShard {
+	if len(env) == 0 {
+		return shard
+	}
+	job := *shard.Job
+	shard.Job = &job
+
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	dockerEnv := make([]string, 0, len(job.Spec.Docker.EnvironmentVariables)+len(env))
+	dockerEnv = append(dockerEnv, job.Spec.Docker.EnvironmentVariables...)
+	wasmEnv := make(map[string]string, len(job.Spec.Wasm.EnvironmentVariables)+len(env))
+	for name, value := range job.Spec.Wasm.EnvironmentVariables {
+		wasmEnv[name] = value
+	}
+	for _, name := range names {
+		dockerEnv = append(dockerEnv, name+"="+env[name])
+		wasmEnv[name] = env[name]
+	}
+	job.Spec.Docker.EnvironmentVariables = dockerEnv
+	job.Spec.Wasm.EnvironmentVariables = wasmEnv
+	return shard
+}
+
+const redacted = "<redacted>"
+
+// RedactEnv returns a copy of the spec without the values of its environment
+// variables, for logging, as they may be secrets.
+func RedactEnv(spec model.Spec) model.Spec {
+	dockerEnv := make([]string, 0, len(spec.Docker.EnvironmentVariables))
+	for _, variable := range spec.Docker.EnvironmentVariables {
+		name, _, _ := strings.Cut(variable, "=")
+		dockerEnv = append(dockerEnv, name+"="+redacted)
+	}
+	spec.Docker.EnvironmentVariables = dockerEnv
+	wasmEnv := make(map[string]string, len(spec.Wasm.EnvironmentVariables))
+	for name := range spec.Wasm.EnvironmentVariables {
+		wasmEnv[name] = redacted
+	}
+	spec.Wasm.EnvironmentVariables = wasmEnv
+	env := make(map[string]string, len(spec.Env))
+	for name := range spec.Env {
+		env[name] = redacted
+	}
+	spec.Env = env
+	return spec
+}
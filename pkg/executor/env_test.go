@@ -0,0 +1,43 @@
+//go:build unit || !integration
+
+package executor
+
+import (
+	"testing"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithEnv(t *testing.T) {
+	job := &model.Job{ID: "job", Spec: model.Spec{
+		Docker: model.JobSpecDocker{EnvironmentVariables: []string{"A=1", "B=2"}},
+		Wasm:   model.JobSpecWasm{EnvironmentVariables: map[string]string{"A": "1"}},
+	}}
+	shard := model.JobShard{Job: job, Index: 1}
+
+	require.Equal(t, shard, WithEnv(shard, nil))
+
+	withEnv := WithEnv(shard, map[string]string{"C": "3", "B": "secret"})
+	require.Equal(t, 1, withEnv.Index)
+	require.Equal(t, []string{"A=1", "B=2", "B=secret", "C=3"}, withEnv.Job.Spec.Docker.EnvironmentVariables)
+	require.Equal(t, map[string]string{"A": "1", "B": "secret", "C": "3"}, withEnv.Job.Spec.Wasm.EnvironmentVariables)
+
+	// the job of the shard is left alone
+	require.Equal(t, []string{"A=1", "B=2"}, job.Spec.Docker.EnvironmentVariables)
+	require.Equal(t, map[string]string{"A": "1"}, job.Spec.Wasm.EnvironmentVariables)
+}
+
+func TestRedactEnv(t *testing.T) {
+	spec := model.Spec{
+		Env:    map[string]string{"A": "1"},
+		Docker: model.JobSpecDocker{Image: "ubuntu", EnvironmentVariables: []string{"B=secret", "C"}},
+		Wasm:   model.JobSpecWasm{EnvironmentVariables: map[string]string{"D": "secret"}},
+	}
+	redactedSpec := RedactEnv(spec)
+	require.Equal(t, "ubuntu", redactedSpec.Docker.Image)
+	require.Equal(t, map[string]string{"A": redacted}, redactedSpec.Env)
+	require.Equal(t, []string{"B=" + redacted, "C=" + redacted}, redactedSpec.Docker.EnvironmentVariables)
+	require.Equal(t, map[string]string{"D": redacted}, redactedSpec.Wasm.EnvironmentVariables)
+	require.Equal(t, []string{"B=secret", "C"}, spec.Docker.EnvironmentVariables)
+}
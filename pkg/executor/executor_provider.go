@@ -36,8 +36,9 @@ func (p *MappedExecutorProvider) GetExecutor(ctx context.Context, engineType mod
 			"no matching executor found on this server: %s", engineType)
 	}
 
-	// cache it being installed so we're not hammering it
-	// TODO: we should evict the cache in case an installed executor gets uninstalled, or vice versa
+	// cache it being installed so we're not hammering it, but keep checking
+	// executors that aren't, so that a runtime that comes up later is found
+	// TODO: we should evict the cache in case an installed executor gets uninstalled
 	installed, ok := p.executorsInstalledCache[engineType]
 	var err error
 	if !ok {
@@ -45,7 +46,9 @@ func (p *MappedExecutorProvider) GetExecutor(ctx context.Context, engineType mod
 		if err != nil {
 			return nil, err
 		}
-		p.executorsInstalledCache[engineType] = installed
+		if installed {
+			p.executorsInstalledCache[engineType] = installed
+		}
 	}
 
 	if !installed {
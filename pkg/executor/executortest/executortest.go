@@ -0,0 +1,31 @@
+// Package executortest has the fixtures of the tests of executors.
+package executortest
+
+import (
+	"testing"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/storage"
+	"github.com/filecoin-project/bacalhau/pkg/system"
+)
+
+// CleanupManager returns a cleanup manager that cleans up when the test
+// finishes.
+func CleanupManager(t testing.TB) *system.CleanupManager {
+	cm := system.NewCleanupManager()
+	t.Cleanup(cm.Cleanup)
+	return cm
+}
+
+// StorageProvider returns a storage provider without any storage, for
+// shards without inputs.
+func StorageProvider() storage.StorageProvider {
+	return storage.NewMappedStorageProvider(map[model.StorageSourceType]storage.Storage{})
+}
+
+// DockerShard returns the first shard of a docker job with the spec.
+func DockerShard(spec model.Spec) model.JobShard {
+	spec.Engine = model.EngineDocker
+	spec.Verifier = model.VerifierNoop
+	return model.JobShard{Job: &model.Job{ID: "job-id", Spec: spec}}
+}
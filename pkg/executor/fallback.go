@@ -0,0 +1,113 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/rs/zerolog/log"
+)
+
+// NamedExecutor is an executor in a FallbackExecutor, named for logs and
+// errors, e.g. "podman".
+type NamedExecutor struct {
+	Name     string
+	Executor Executor
+}
+
+// FallbackExecutor runs jobs on the first of its executors that is
+// installed, so that a node keeps running jobs of an engine when its
+// preferred runtime is down, e.g. docker and then podman.
+type FallbackExecutor struct {
+	executors []NamedExecutor
+
+	// the executors that running shards were started on, by shard ID
+	mu      sync.Mutex
+	running map[string]Executor
+}
+
+func NewFallbackExecutor(executors ...NamedExecutor) *FallbackExecutor {
+	return &FallbackExecutor{
+		executors: executors,
+		running:   map[string]Executor{},
+	}
+}
+
+// IsInstalled returns true if any of the executors is installed.
+func (e *FallbackExecutor) IsInstalled(ctx context.Context) (bool, error) {
+	_, err := e.installed(ctx)
+	return err == nil, nil
+}
+
+func (e *FallbackExecutor) HasStorageLocally(ctx context.Context, volume model.StorageSpec) (bool, error) {
+	executor, err := e.installed(ctx)
+	if err != nil {
+		return false, err
+	}
+	return executor.Executor.HasStorageLocally(ctx, volume)
+}
+
+func (e *FallbackExecutor) GetVolumeSize(ctx context.Context, volume model.StorageSpec) (uint64, error) {
+	executor, err := e.installed(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return executor.Executor.GetVolumeSize(ctx, volume)
+}
+
+// RunShard runs the shard on the first executor that is installed when it
+// starts. A shard that fails on one executor isn't retried on the next, as
+// it may have run.
+func (e *FallbackExecutor) RunShard(
+	ctx context.Context,
+	shard model.JobShard,
+	resultsDir string,
+) (*model.RunCommandResult, error) {
+	executor, err := e.installed(ctx)
+	if err != nil {
+		return &model.RunCommandResult{ErrorMsg: err.Error()}, err
+	}
+	log.Ctx(ctx).Debug().Str("executor", executor.Name).Msgf("Running shard %s", shard)
+
+	e.mu.Lock()
+	e.running[shard.ID()] = executor.Executor
+	e.mu.Unlock()
+	defer func() {
+		e.mu.Lock()
+		delete(e.running, shard.ID())
+		e.mu.Unlock()
+	}()
+
+	return executor.Executor.RunShard(ctx, shard, resultsDir)
+}
+
+func (e *FallbackExecutor) CancelShard(ctx context.Context, shard model.JobShard) error {
+	e.mu.Lock()
+	executor, ok := e.running[shard.ID()]
+	e.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	return executor.CancelShard(ctx, shard)
+}
+
+// installed returns the first of the executors that is installed.
+func (e *FallbackExecutor) installed(ctx context.Context) (NamedExecutor, error) {
+	names := make([]string, 0, len(e.executors))
+	for _, executor := range e.executors {
+		installed, err := executor.Executor.IsInstalled(ctx)
+		if err != nil {
+			log.Ctx(ctx).Debug().Err(err).Str("executor", executor.Name).Msg("Error checking if executor is installed")
+		}
+		if installed {
+			return executor, nil
+		}
+		names = append(names, executor.Name)
+	}
+	return NamedExecutor{}, fmt.Errorf("none of the executors are installed: %s", strings.Join(names, ", "))
+}
+
+// Compile-time interface check:
+var _ Executor = (*FallbackExecutor)(nil)
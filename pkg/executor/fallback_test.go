@@ -0,0 +1,85 @@
+//go:build unit || !integration
+
+package executor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeExecutor struct {
+	installed bool
+	ran       []string
+	cancelled []string
+}
+
+func (e *fakeExecutor) IsInstalled(context.Context) (bool, error) {
+	return e.installed, nil
+}
+
+func (e *fakeExecutor) HasStorageLocally(context.Context, model.StorageSpec) (bool, error) {
+	return true, nil
+}
+
+func (e *fakeExecutor) GetVolumeSize(context.Context, model.StorageSpec) (uint64, error) {
+	return 0, nil
+}
+
+func (e *fakeExecutor) RunShard(_ context.Context, shard model.JobShard, _ string) (*model.RunCommandResult, error) {
+	e.ran = append(e.ran, shard.ID())
+	return &model.RunCommandResult{}, nil
+}
+
+func (e *fakeExecutor) CancelShard(_ context.Context, shard model.JobShard) error {
+	e.cancelled = append(e.cancelled, shard.ID())
+	return nil
+}
+
+func TestFallbackExecutor(t *testing.T) {
+	ctx := context.Background()
+	docker, podman := &fakeExecutor{installed: true}, &fakeExecutor{installed: true}
+	e := NewFallbackExecutor(NamedExecutor{Name: "docker", Executor: docker}, NamedExecutor{Name: "podman", Executor: podman})
+	shard := model.JobShard{Job: &model.Job{ID: "job"}}
+
+	_, err := e.RunShard(ctx, shard, t.TempDir())
+	require.NoError(t, err)
+	require.Len(t, docker.ran, 1)
+	require.Empty(t, podman.ran)
+
+	// the Docker daemon goes down
+	docker.installed = false
+	installed, err := e.IsInstalled(ctx)
+	require.NoError(t, err)
+	require.True(t, installed)
+	_, err = e.RunShard(ctx, shard, t.TempDir())
+	require.NoError(t, err)
+	require.Len(t, docker.ran, 1)
+	require.Len(t, podman.ran, 1)
+
+	podman.installed = false
+	installed, err = e.IsInstalled(ctx)
+	require.NoError(t, err)
+	require.False(t, installed)
+	_, err = e.RunShard(ctx, shard, t.TempDir())
+	require.ErrorContains(t, err, "none of the executors are installed: docker, podman")
+}
+
+func TestFallbackExecutorCancelsWhereShardRuns(t *testing.T) {
+	ctx := context.Background()
+	docker, podman := &fakeExecutor{}, &fakeExecutor{installed: true}
+	e := NewFallbackExecutor(NamedExecutor{Name: "docker", Executor: docker}, NamedExecutor{Name: "podman", Executor: podman})
+	shard := model.JobShard{Job: &model.Job{ID: "job"}}
+
+	// not running anywhere
+	require.NoError(t, e.CancelShard(ctx, shard))
+	require.Empty(t, podman.cancelled)
+
+	e.running[shard.ID()] = podman
+	docker.installed = true
+	require.NoError(t, e.CancelShard(ctx, shard))
+	require.Empty(t, docker.cancelled)
+	require.Equal(t, []string{shard.ID()}, podman.cancelled)
+}
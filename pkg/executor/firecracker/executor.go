@@ -0,0 +1,595 @@
+// Package firecracker runs docker jobs in Firecracker microVMs, which isolate
+// jobs from the node with a hypervisor rather than the kernel namespaces of
+// containers. It suits nodes on the public network that accept jobs from
+// anyone.
+//
+// Images are still pulled through the Docker daemon, and their filesystem is
+// exported into an ext4 image that is cached and copied for each VM. Jobs get
+// their inputs, outputs and scratch volumes as extra ext4 drives, built and
+// read back with e2fsprogs, and have no network device. The image of a job
+// needs /bin/sh and mount, which the init of the VM runs.
+package firecracker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	dockerclient "github.com/docker/docker/client"
+	"github.com/filecoin-project/bacalhau/pkg/compute/capacity"
+	"github.com/filecoin-project/bacalhau/pkg/config"
+	"github.com/filecoin-project/bacalhau/pkg/docker"
+	"github.com/filecoin-project/bacalhau/pkg/executor"
+	jobutils "github.com/filecoin-project/bacalhau/pkg/job"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/storage"
+	"github.com/filecoin-project/bacalhau/pkg/system"
+	"github.com/rs/zerolog/log"
+	"go.uber.org/multierr"
+)
+
+const (
+	DefaultBinary = "firecracker"
+	// DefaultOutputVolumeSize is how much a job can write to each of its
+	// output volumes by default.
+	DefaultOutputVolumeSize = 10 << 30
+
+	labelExecutorName = "bacalhau-executor"
+
+	defaultMemoryMiB = 512
+	maxVCPUs         = 32
+	kvmDevice        = "/dev/kvm"
+
+	stdoutFile   = "stdout"
+	stderrFile   = "stderr"
+	exitCodeFile = "exitcode"
+)
+
+type Config struct {
+	// The path or name of the firecracker binary, DefaultBinary if empty.
+	Binary string
+	// The uncompressed Linux kernel VMs boot, which needs virtio block
+	// devices, ext4 and devtmpfs built in.
+	KernelImage string
+	// Where the rootfs of images is cached, a directory in the storage path
+	// if empty.
+	CacheDir string
+	// How much a job can write to each of its output volumes,
+	// DefaultOutputVolumeSize if zero.
+	OutputVolumeSize uint64
+}
+
+type Executor struct {
+	// used to label the containers that images are exported with
+	ID string
+
+	executor.StorageExecutor
+
+	config   Config
+	cacheDir string
+	client   *dockerclient.Client
+
+	// rootfs images are built one at a time
+	rootfsMu sync.Mutex
+
+	// the firecracker processes of the jobs that are running, by job name
+	mu  sync.Mutex
+	vms map[string]*exec.Cmd
+}
+
+func NewExecutor(
+	ctx context.Context,
+	cm *system.CleanupManager,
+	id string,
+	storageProvider storage.StorageProvider,
+	executorConfig Config,
+) (*Executor, error) {
+	if executorConfig.KernelImage == "" {
+		return nil, fmt.Errorf("the Firecracker executor needs a kernel image to boot")
+	}
+	if executorConfig.Binary == "" {
+		executorConfig.Binary = DefaultBinary
+	}
+	if executorConfig.OutputVolumeSize == 0 {
+		executorConfig.OutputVolumeSize = DefaultOutputVolumeSize
+	}
+	cacheDir := executorConfig.CacheDir
+	if cacheDir == "" {
+		cacheDir = filepath.Join(config.GetStoragePath(), "firecracker-rootfs")
+	}
+	if err := os.MkdirAll(cacheDir, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	dockerClient, err := docker.NewDockerClient()
+	if err != nil {
+		return nil, err
+	}
+
+	e := &Executor{
+		ID:              id,
+		StorageExecutor: executor.StorageExecutor{StorageProvider: storageProvider},
+		config:          executorConfig,
+		cacheDir:        cacheDir,
+		client:          dockerClient,
+		vms:             map[string]*exec.Cmd{},
+	}
+
+	cm.RegisterCallback(func() error {
+		e.cleanupAll(ctx)
+		return nil
+	})
+
+	return e, nil
+}
+
+// IsInstalled checks that VMs can be started and images pulled.
+func (e *Executor) IsInstalled(ctx context.Context) (bool, error) {
+	if _, err := exec.LookPath(e.config.Binary); err != nil {
+		return false, nil
+	}
+	if _, err := os.Stat(e.config.KernelImage); err != nil {
+		return false, nil
+	}
+	kvm, err := os.OpenFile(kvmDevice, os.O_RDWR, 0)
+	if err != nil {
+		return false, nil
+	}
+	_ = kvm.Close()
+	return docker.IsInstalled(ctx, e.client), nil
+}
+
+//nolint:funlen,gocyclo
+func (e *Executor) RunShard(
+	ctx context.Context,
+	shard model.JobShard,
+	jobResultsDir string,
+) (*model.RunCommandResult, error) {
+	ctx, span := system.GetTracer().Start(ctx, "pkg/executor/firecracker.RunShard")
+	defer span.End()
+	system.AddJobIDFromBaggageToSpan(ctx, span)
+	system.AddNodeIDFromBaggageToSpan(ctx, span)
+
+	if err := jobutils.VerifyDockerSpec(shard.Job.Spec.Docker); err != nil {
+		return &model.RunCommandResult{ErrorMsg: err.Error()}, err
+	}
+	if shard.Job.Spec.Docker.Stdin != "" {
+		err := fmt.Errorf("jobs can't read stdin in Firecracker")
+		return &model.RunCommandResult{ErrorMsg: err.Error()}, err
+	}
+	// the VM is the sandbox, and jobs run as root in it
+	if shard.Job.Spec.Docker.User != "" {
+		err := fmt.Errorf("jobs can't choose their user in Firecracker")
+		return &model.RunCommandResult{ErrorMsg: err.Error()}, err
+	}
+	resourceRequirements := capacity.ParseResourceUsageConfig(shard.Job.Spec.Resources)
+	if resourceRequirements.GPU > 0 {
+		err := fmt.Errorf("jobs can't use GPUs in Firecracker")
+		return &model.RunCommandResult{ErrorMsg: err.Error()}, err
+	}
+
+	inputs, err := e.PrepareInputs(ctx, shard)
+	if err != nil {
+		return &model.RunCommandResult{}, err
+	}
+	outputs, err := executor.PrepareOutputs(ctx, shard, jobResultsDir)
+	if err != nil {
+		return &model.RunCommandResult{ErrorMsg: err.Error()}, err
+	}
+
+	if os.Getenv("SKIP_IMAGE_PULL") == "" {
+		if err = docker.PullImage(ctx, e.client, shard.Job.Spec.Docker.Image); err != nil {
+			//nolint:stylecheck // Error message for user
+			err = fmt.Errorf(`Could not pull image - could be due to repo/image not existing,
+ or registry needing authorization. %s: %s`, shard.Job.Spec.Docker.Image, err)
+			return executor.ErrorResult(ctx, err.Error(), err), err
+		}
+	}
+	image, _, err := e.client.ImageInspectWithRaw(ctx, shard.Job.Spec.Docker.Image)
+	if err != nil {
+		return executor.ErrorResult(ctx, "failed to inspect image: ", err), err
+	}
+	baseRootfs, err := e.buildRootfs(ctx, image)
+	if err != nil {
+		return executor.ErrorResult(ctx, "failed to build the rootfs of the image: ", err), err
+	}
+
+	vmDir, err := os.MkdirTemp(config.GetStoragePath(), "bacalhau-firecracker-")
+	if err != nil {
+		return &model.RunCommandResult{ErrorMsg: err.Error()}, err
+	}
+	defer func() {
+		if removeErr := os.RemoveAll(vmDir); removeErr != nil {
+			log.Ctx(ctx).Warn().Err(removeErr).Msg("failed to remove Firecracker VM directory")
+		}
+	}()
+
+	vm, err := e.prepareVM(ctx, shard, image, baseRootfs, inputs, outputs, vmDir)
+	if err != nil {
+		return executor.ErrorResult(ctx, "failed to prepare the VM: ", err), err
+	}
+
+	console, err := e.runVM(ctx, shard, vm, vmDir)
+	if err != nil {
+		return executor.ErrorResult(ctx, "failed to run the VM: ", err), err
+	}
+
+	var jobError error
+	exitCode := 0
+	data, err := readDriveFile(ctx, vm.jobDrive, "/"+exitCodeFile)
+	if err == nil {
+		exitCode, err = parseExitCode(data)
+	}
+	if err != nil {
+		// the job didn't run to completion, e.g. it ran out of memory
+		jobError = fmt.Errorf("the VM stopped before the job finished: %s", lastLines(console, 10))
+	}
+
+	var outputErr error
+	for dir, drive := range vm.outputDrives {
+		outputErr = multierr.Append(outputErr, readDrive(ctx, drive, dir))
+	}
+
+	stdout, stdoutErr := readDriveFile(ctx, vm.jobDrive, "/"+stdoutFile)
+	stderr, stderrErr := readDriveFile(ctx, vm.jobDrive, "/"+stderrFile)
+	if jobError == nil {
+		jobError = multierr.Combine(stdoutErr, stderrErr)
+	}
+
+	return executor.WriteShardResults(
+		jobResultsDir,
+		bytes.NewReader(stdout),
+		bytes.NewReader(stderr),
+		exitCode,
+		multierr.Combine(jobError, outputErr),
+	)
+}
+
+func (e *Executor) CancelShard(ctx context.Context, shard model.JobShard) error {
+	e.mu.Lock()
+	vm, ok := e.vms[e.jobName(shard)]
+	e.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	return vm.Process.Kill()
+}
+
+// preparedVM is what a VM is started with.
+type preparedVM struct {
+	config   vmConfig
+	jobDrive string
+	// the drives of the output volumes of the job, by the directory in the
+	// results directory they are read back into
+	outputDrives map[string]string
+}
+
+// The configuration of a VM that firecracker is started with. See
+// https://github.com/firecracker-microvm/firecracker/blob/main/tests/framework/vm_config.json
+type vmConfig struct {
+	BootSource    vmBootSource    `json:"boot-source"`
+	Drives        []vmDrive       `json:"drives"`
+	MachineConfig vmMachineConfig `json:"machine-config"`
+}
+
+type vmBootSource struct {
+	KernelImagePath string `json:"kernel_image_path"`
+	BootArgs        string `json:"boot_args"`
+}
+
+type vmDrive struct {
+	DriveID      string `json:"drive_id"`
+	PathOnHost   string `json:"path_on_host"`
+	IsRootDevice bool   `json:"is_root_device"`
+	IsReadOnly   bool   `json:"is_read_only"`
+}
+
+type vmMachineConfig struct {
+	VCPUCount  int `json:"vcpu_count"`
+	MemSizeMiB int `json:"mem_size_mib"`
+}
+
+// prepareVM creates the drives of the job in the VM directory, and the
+// script that mounts them and runs the job.
+//
+//nolint:funlen
+func (e *Executor) prepareVM(
+	ctx context.Context,
+	shard model.JobShard,
+	image types.ImageInspect,
+	baseRootfs string,
+	inputs []executor.Mount,
+	outputs []executor.Mount,
+	vmDir string,
+) (*preparedVM, error) {
+	resourceRequirements := capacity.ParseResourceUsageConfig(shard.Job.Spec.Resources)
+	imageConfig := image.Config
+	if imageConfig == nil {
+		imageConfig = &container.Config{}
+	}
+	vm := &preparedVM{
+		config: vmConfig{
+			BootSource: vmBootSource{
+				KernelImagePath: e.config.KernelImage,
+				// the VM powers off when the init exits
+				BootArgs: "console=ttyS0 reboot=k panic=1 pci=off init=" + guestInit,
+			},
+			MachineConfig: vmMachineConfig{
+				VCPUCount:  vcpus(resourceRequirements.CPU),
+				MemSizeMiB: memoryMiB(resourceRequirements.Memory),
+			},
+		},
+		outputDrives: map[string]string{},
+	}
+	script := &jobScript{}
+	addDrive := func(drivePath string, readOnly bool) string {
+		vm.config.Drives = append(vm.config.Drives, vmDrive{
+			DriveID:      fmt.Sprintf("drive%d", len(vm.config.Drives)),
+			PathOnHost:   drivePath,
+			IsRootDevice: len(vm.config.Drives) == 0,
+			IsReadOnly:   readOnly,
+		})
+		return "/dev/" + driveName(len(vm.config.Drives)-1)
+	}
+
+	rootfs := filepath.Join(vmDir, "rootfs.ext4")
+	if _, err := run(ctx, "cp", "--sparse=always", "--reflink=auto", baseRootfs, rootfs); err != nil {
+		return nil, err
+	}
+	addDrive(rootfs, false)
+	// the init of the VM expects the job drive to be the second one, and
+	// the script to be on it, so it is written last
+	vm.jobDrive = filepath.Join(vmDir, "job.ext4")
+	addDrive(vm.jobDrive, false)
+
+	for _, volume := range inputs {
+		info, err := os.Stat(volume.Source)
+		if err != nil {
+			return nil, err
+		}
+		contents := volume.Source
+		if !info.IsDir() {
+			// drives hold directories, so files are put in one
+			contents = filepath.Join(vmDir, fmt.Sprintf("input%d", len(vm.config.Drives)))
+			if err = os.Mkdir(contents, os.ModePerm); err != nil {
+				return nil, err
+			}
+			if err = linkOrCopy(ctx, volume.Source, filepath.Join(contents, filepath.Base(volume.Source))); err != nil {
+				return nil, err
+			}
+		}
+
+		drivePath := filepath.Join(vmDir, fmt.Sprintf("drive%d.ext4", len(vm.config.Drives)))
+		if err = makeDrive(ctx, drivePath, contents, 0); err != nil {
+			return nil, err
+		}
+		device := addDrive(drivePath, true)
+		if info.IsDir() {
+			script.mount(device, volume.Target, "ro")
+		} else {
+			script.mountFile(device, filepath.Base(volume.Source), volume.Target)
+		}
+	}
+
+	for _, output := range outputs {
+		drivePath := filepath.Join(vmDir, fmt.Sprintf("drive%d.ext4", len(vm.config.Drives)))
+		if err := makeDrive(ctx, drivePath, "", e.config.OutputVolumeSize); err != nil {
+			return nil, err
+		}
+		vm.outputDrives[output.Source] = drivePath
+		script.mount(addDrive(drivePath, false), output.Target, "rw")
+	}
+
+	for _, scratch := range shard.Job.Spec.Scratch {
+		size, err := executor.ScratchVolumeSize(scratch)
+		if err != nil {
+			return nil, err
+		}
+		if scratch.Tmpfs {
+			script.tmpfs(scratch.Path, size)
+			continue
+		}
+		// the drive is the size of the volume, so jobs can't write more to it
+		drivePath := filepath.Join(vmDir, fmt.Sprintf("drive%d.ext4", len(vm.config.Drives)))
+		if err := makeDrive(ctx, drivePath, "", size); err != nil {
+			return nil, err
+		}
+		script.mount(addDrive(drivePath, false), scratch.Path, "rw")
+	}
+
+	jsonJobSpec, err := model.JSONMarshalWithMax(shard.Job.Spec)
+	if err != nil {
+		return nil, err
+	}
+	script.env = append(script.env, imageConfig.Env...)
+	script.env = append(script.env, shard.Job.Spec.Docker.EnvironmentVariables...)
+	script.env = append(script.env, fmt.Sprintf("BACALHAU_JOB_SPEC=%s", string(jsonJobSpec)))
+
+	// like docker, the entrypoint of a job replaces both the entrypoint and
+	// command of its image
+	script.command = shard.Job.Spec.Docker.Entrypoint
+	if len(script.command) == 0 {
+		script.command = append(append(script.command, imageConfig.Entrypoint...), imageConfig.Cmd...)
+	}
+	if len(script.command) == 0 {
+		return nil, fmt.Errorf("the job has no entrypoint and its image has no command")
+	}
+	script.workingDirectory = shard.Job.Spec.Docker.WorkingDirectory
+	if script.workingDirectory == "" {
+		script.workingDirectory = imageConfig.WorkingDir
+	}
+
+	jobContents := filepath.Join(vmDir, "job")
+	if err = os.Mkdir(jobContents, os.ModePerm); err != nil {
+		return nil, err
+	}
+	if err = os.WriteFile(filepath.Join(jobContents, guestRunFile), []byte(script.String()), os.ModePerm); err != nil {
+		return nil, err
+	}
+	// stdout and stderr are written to the job drive, so it has room for them
+	if err = makeDrive(ctx, vm.jobDrive, jobContents, e.config.OutputVolumeSize); err != nil {
+		return nil, err
+	}
+	return vm, nil
+}
+
+// runVM boots the VM and waits for it to power off, returning what it wrote
+// to its console.
+func (e *Executor) runVM(ctx context.Context, shard model.JobShard, vm *preparedVM, vmDir string) (string, error) {
+	configPath := filepath.Join(vmDir, "vm.json")
+	data, err := json.Marshal(vm.config)
+	if err != nil {
+		return "", err
+	}
+	if err = os.WriteFile(configPath, data, os.ModePerm); err != nil {
+		return "", err
+	}
+
+	var console bytes.Buffer
+	jobName := e.jobName(shard)
+	cmd := exec.CommandContext(ctx, e.config.Binary, "--no-api", "--config-file", configPath, "--id", jobName)
+	cmd.Dir = vmDir
+	cmd.Stdout = &console
+	cmd.Stderr = &console
+
+	e.mu.Lock()
+	if err = cmd.Start(); err != nil {
+		e.mu.Unlock()
+		return "", err
+	}
+	e.vms[jobName] = cmd
+	e.mu.Unlock()
+
+	err = cmd.Wait()
+	e.mu.Lock()
+	delete(e.vms, jobName)
+	e.mu.Unlock()
+	if ctx.Err() != nil {
+		return console.String(), ctx.Err()
+	}
+	if err != nil {
+		return console.String(), fmt.Errorf("%w: %s", err, lastLines(console.String(), 10))
+	}
+	log.Ctx(ctx).Trace().Msgf("Firecracker console: %s", console.String())
+	return console.String(), nil
+}
+
+func (e *Executor) cleanupAll(ctx context.Context) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for name, vm := range e.vms {
+		if err := vm.Process.Kill(); err != nil {
+			log.Ctx(ctx).Debug().Err(err).Msgf("Non-critical error stopping VM %s", name)
+		}
+	}
+}
+
+func (e *Executor) jobName(shard model.JobShard) string {
+	return fmt.Sprintf("%s-%s-%d", e.ID, shard.Job.ID, shard.Index)
+}
+
+// jobScript is the script that runs the job inside of the VM.
+type jobScript struct {
+	mounts           []string
+	env              []string
+	command          []string
+	workingDirectory string
+}
+
+func (s *jobScript) mount(device, target, mode string) {
+	s.mounts = append(s.mounts,
+		fmt.Sprintf("mkdir -p %s", quote(target)),
+		fmt.Sprintf("mount -t ext4 -o %s %s %s", mode, device, quote(target)),
+	)
+}
+
+// mountFile mounts the drive and bind mounts the file on it at the target.
+func (s *jobScript) mountFile(device, name, target string) {
+	source := path.Join(guestDir, strings.TrimPrefix(device, "/dev/"))
+	s.mount(device, source, "ro")
+	s.mounts = append(s.mounts,
+		fmt.Sprintf("mkdir -p %s", quote(path.Dir(target))),
+		fmt.Sprintf("touch %s", quote(target)),
+		fmt.Sprintf("mount --bind %s %s", quote(path.Join(source, name)), quote(target)),
+	)
+}
+
+func (s *jobScript) tmpfs(target string, size uint64) {
+	s.mounts = append(s.mounts,
+		fmt.Sprintf("mkdir -p %s", quote(target)),
+		fmt.Sprintf("mount -t tmpfs -o size=%d tmpfs %s", size, quote(target)),
+	)
+}
+
+func (s *jobScript) String() string {
+	var b strings.Builder
+	b.WriteString("#!/bin/sh\nset -e\n")
+	for _, line := range s.mounts {
+		b.WriteString(line + "\n")
+	}
+	for _, env := range s.env {
+		b.WriteString("export " + quote(env) + "\n")
+	}
+	if s.workingDirectory != "" {
+		b.WriteString(fmt.Sprintf("mkdir -p %s\ncd %s\n", quote(s.workingDirectory), quote(s.workingDirectory)))
+	}
+	b.WriteString("set +e\n")
+	for _, arg := range s.command {
+		b.WriteString(quote(arg) + " ")
+	}
+	b.WriteString(fmt.Sprintf("> %s 2> %s\n", quote(path.Join(guestJobDir, stdoutFile)), quote(path.Join(guestJobDir, stderrFile))))
+	b.WriteString(fmt.Sprintf("echo $? > %s\n", quote(path.Join(guestJobDir, exitCodeFile))))
+	return b.String()
+}
+
+func vcpus(cpu float64) int {
+	count := int(math.Ceil(cpu))
+	if count < 1 {
+		return 1
+	}
+	if count > maxVCPUs {
+		return maxVCPUs
+	}
+	return count
+}
+
+func memoryMiB(memory uint64) int {
+	if memory == 0 {
+		return defaultMemoryMiB
+	}
+	return int(math.Ceil(float64(memory) / (1 << 20)))
+}
+
+func linkOrCopy(ctx context.Context, source, target string) error {
+	if err := os.Link(source, target); err == nil {
+		return nil
+	}
+	_, err := run(ctx, "cp", "--reflink=auto", source, target)
+	return err
+}
+
+// quote quotes the string for the shell.
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func lastLines(s string, n int) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Compile-time interface check:
+var _ executor.Executor = (*Executor)(nil)
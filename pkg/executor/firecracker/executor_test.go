@@ -0,0 +1,186 @@
+//go:build unit || (!integration && !windows)
+
+package firecracker
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/filecoin-project/bacalhau/pkg/executor"
+	"github.com/filecoin-project/bacalhau/pkg/executor/executortest"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/storage"
+	"github.com/filecoin-project/bacalhau/pkg/system"
+	"github.com/stretchr/testify/require"
+)
+
+type tarEntry struct {
+	header  tar.Header
+	content string
+}
+
+func writeTar(t *testing.T, entries ...tarEntry) *bytes.Buffer {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, entry := range entries {
+		header := entry.header
+		header.Size = int64(len(entry.content))
+		if header.Mode == 0 {
+			header.Mode = 0755
+		}
+		require.NoError(t, tw.WriteHeader(&header))
+		_, err := tw.Write([]byte(entry.content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	return &buf
+}
+
+func requireE2fsprogs(t *testing.T) {
+	for _, command := range []string{"mkfs.ext4", "debugfs"} {
+		if _, err := exec.LookPath(command); err != nil {
+			t.Skipf("%s is not installed", command)
+		}
+	}
+}
+
+func TestNewExecutorNeedsKernel(t *testing.T) {
+	_, err := NewExecutor(context.Background(), system.NewCleanupManager(), "id",
+		storage.NewMappedStorageProvider(nil), Config{})
+	require.Error(t, err)
+}
+
+func TestExtractTar(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "root")
+	err := extractTar(writeTar(t,
+		tarEntry{header: tar.Header{Name: "bin/", Typeflag: tar.TypeDir}},
+		tarEntry{header: tar.Header{Name: "bin/busybox", Typeflag: tar.TypeReg}, content: "busybox"},
+		tarEntry{header: tar.Header{Name: "bin/sh", Typeflag: tar.TypeSymlink, Linkname: "busybox"}},
+		tarEntry{header: tar.Header{Name: "bin/mount", Typeflag: tar.TypeLink, Linkname: "bin/busybox"}},
+		tarEntry{header: tar.Header{Name: "../../escape", Typeflag: tar.TypeReg}, content: "contained"},
+		tarEntry{header: tar.Header{Name: "dev/null", Typeflag: tar.TypeChar}},
+	), dir)
+	require.NoError(t, err)
+
+	link, err := os.Readlink(filepath.Join(dir, "bin", "sh"))
+	require.NoError(t, err)
+	require.Equal(t, "busybox", link)
+	content, err := os.ReadFile(filepath.Join(dir, "bin", "mount"))
+	require.NoError(t, err)
+	require.Equal(t, "busybox", string(content))
+	content, err = os.ReadFile(filepath.Join(dir, "escape"))
+	require.NoError(t, err)
+	require.Equal(t, "contained", string(content))
+	require.NoFileExists(t, filepath.Join(dir, "dev", "null"))
+}
+
+func TestExtractTarThroughSymlink(t *testing.T) {
+	outside := t.TempDir()
+	err := extractTar(writeTar(t,
+		tarEntry{header: tar.Header{Name: "etc", Typeflag: tar.TypeSymlink, Linkname: outside}},
+		tarEntry{header: tar.Header{Name: "etc/passwd", Typeflag: tar.TypeReg}, content: "root"},
+	), filepath.Join(t.TempDir(), "root"))
+	require.Error(t, err)
+	require.NoFileExists(t, filepath.Join(outside, "passwd"))
+}
+
+func TestDriveName(t *testing.T) {
+	require.Equal(t, "vda", driveName(0))
+	require.Equal(t, "vdb", driveName(1))
+	require.Equal(t, "vdz", driveName(25))
+	require.Equal(t, "vdaa", driveName(26))
+}
+
+func TestDrive(t *testing.T) {
+	requireE2fsprogs(t)
+	ctx := context.Background()
+	contents := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(contents, "sub"), os.ModePerm))
+	require.NoError(t, os.WriteFile(filepath.Join(contents, "sub", "file"), []byte("hello"), os.ModePerm))
+
+	drive := filepath.Join(t.TempDir(), "drive.ext4")
+	require.NoError(t, makeDrive(ctx, drive, contents, 0))
+
+	data, err := readDriveFile(ctx, drive, "/sub/file")
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(data))
+	_, err = readDriveFile(ctx, drive, "/missing")
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	out := t.TempDir()
+	require.NoError(t, readDrive(ctx, drive, out))
+	entries, err := os.ReadDir(out)
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "lost+found is removed")
+	require.FileExists(t, filepath.Join(out, "sub", "file"))
+}
+
+func TestPrepareVM(t *testing.T) {
+	requireE2fsprogs(t)
+	t.Setenv("BACALHAU_STORAGE_PATH", t.TempDir())
+	e, err := NewExecutor(context.Background(), executortest.CleanupManager(t), "bacalhau-node",
+		executortest.StorageProvider(), Config{KernelImage: "/vmlinux", OutputVolumeSize: 1 << 20})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	vmDir := t.TempDir()
+	baseRootfs := filepath.Join(t.TempDir(), "rootfs.ext4")
+	require.NoError(t, makeDrive(ctx, baseRootfs, "", 1<<20))
+	inputDir := t.TempDir()
+	inputFile := filepath.Join(t.TempDir(), "data.csv")
+	require.NoError(t, os.WriteFile(inputFile, []byte("a,b"), os.ModePerm))
+
+	shard := executortest.DockerShard(model.Spec{
+		Docker:    model.JobSpecDocker{Image: "ubuntu", EnvironmentVariables: []string{"A=it's"}},
+		Resources: model.ResourceUsageConfig{CPU: "1500m", Memory: "1Gi"},
+		Scratch: []model.ScratchVolume{
+			{Path: "/tmp", Size: "64Mi", Tmpfs: true},
+			{Path: "/scratch", Size: "1Mi"},
+		},
+	})
+	image := types.ImageInspect{Config: &container.Config{
+		Env:        []string{"PATH=/usr/bin:/bin"},
+		Cmd:        []string{"python", "main.py"},
+		WorkingDir: "/app",
+	}}
+
+	outputDir := filepath.Join(t.TempDir(), "outputs")
+	vm, err := e.prepareVM(ctx, shard, image, baseRootfs, []executor.Mount{
+		{Source: inputDir, Target: "/inputs", ReadOnly: true},
+		{Source: inputFile, Target: "/data/data.csv", ReadOnly: true},
+	}, []executor.Mount{
+		{Source: outputDir, Target: "/outputs"},
+	}, vmDir)
+	require.NoError(t, err)
+
+	require.Equal(t, 2, vm.config.MachineConfig.VCPUCount)
+	require.Equal(t, 1024, vm.config.MachineConfig.MemSizeMiB)
+	require.Contains(t, vm.config.BootSource.BootArgs, "init="+guestInit)
+	require.Len(t, vm.config.Drives, 6)
+	require.True(t, vm.config.Drives[0].IsRootDevice)
+	require.Equal(t, vm.jobDrive, vm.config.Drives[1].PathOnHost)
+	require.True(t, vm.config.Drives[2].IsReadOnly)
+	require.True(t, vm.config.Drives[3].IsReadOnly)
+	require.False(t, vm.config.Drives[4].IsReadOnly)
+	require.Equal(t, vm.config.Drives[4].PathOnHost, vm.outputDrives[outputDir])
+
+	data, err := readDriveFile(ctx, vm.jobDrive, "/"+guestRunFile)
+	require.NoError(t, err)
+	script := string(data)
+	require.Contains(t, script, "mount -t ext4 -o ro /dev/vdc '/inputs'")
+	require.Contains(t, script, "mount --bind '/.bacalhau/vdd/data.csv' '/data/data.csv'")
+	require.Contains(t, script, "mount -t ext4 -o rw /dev/vde '/outputs'")
+	require.Contains(t, script, "mount -t tmpfs -o size=67108864 tmpfs '/tmp'")
+	require.Contains(t, script, "mount -t ext4 -o rw /dev/vdf '/scratch'")
+	require.Contains(t, script, "export 'PATH=/usr/bin:/bin'\nexport 'A=it'\\''s'\n")
+	require.Contains(t, script, "cd '/app'")
+	require.True(t, strings.Contains(script, "'python' 'main.py' > '/.bacalhau/job/stdout' 2> '/.bacalhau/job/stderr'"), script)
+}
@@ -0,0 +1,363 @@
+package firecracker
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/filecoin-project/bacalhau/pkg/docker"
+	"github.com/filecoin-project/bacalhau/pkg/storage/util"
+	"github.com/filecoin-project/bacalhau/pkg/system"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// where the init script and job drive live in the rootfs of a VM
+	guestDir     = "/.bacalhau"
+	guestInit    = guestDir + "/init"
+	guestJobDir  = guestDir + "/job"
+	guestRunFile = "run.sh"
+
+	// how much free space the rootfs of a VM has for the job to write to
+	rootfsFreeSpace = 1 << 30
+	// how much bigger than their contents drives are, for the ext4 metadata
+	driveOverhead = 16 << 20
+	// how long copying, making or dumping a drive may take
+	rootfsCommandTimeout = 10 * time.Minute
+)
+
+// initScript is the init of every VM. It mounts the job drive and runs the
+// script of the job from it, and the kernel powers the VM off when it exits.
+const initScript = `#!/bin/sh
+export PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin
+mount -t proc proc /proc
+mount -t sysfs sysfs /sys
+mount -t devtmpfs devtmpfs /dev 2> /dev/null
+mount -t ext4 /dev/vdb ` + guestJobDir + `
+/bin/sh ` + guestJobDir + "/" + guestRunFile + `
+sync
+umount ` + guestJobDir + `
+`
+
+// buildRootfs returns the path of an ext4 image of the filesystem of the
+// docker image, building it if it isn't cached.
+func (e *Executor) buildRootfs(ctx context.Context, image types.ImageInspect) (string, error) {
+	e.rootfsMu.Lock()
+	defer e.rootfsMu.Unlock()
+
+	rootfsPath := filepath.Join(e.cacheDir, strings.TrimPrefix(image.ID, "sha256:")+".ext4")
+	if _, err := os.Stat(rootfsPath); err == nil {
+		return rootfsPath, nil
+	}
+
+	log.Ctx(ctx).Debug().Str("image", image.ID).Msg("Building the rootfs of image")
+	buildDir, err := os.MkdirTemp(e.cacheDir, "build-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(buildDir)
+
+	root := filepath.Join(buildDir, "root")
+	if err = e.exportImage(ctx, image.ID, root); err != nil {
+		return "", fmt.Errorf("could not export the filesystem of the image: %w", err)
+	}
+	// the init and the script of the job are shell scripts
+	if _, err = os.Lstat(filepath.Join(root, "bin", "sh")); err != nil {
+		return "", fmt.Errorf("the image needs a /bin/sh to run in Firecracker")
+	}
+	if err = os.MkdirAll(filepath.Join(root, guestJobDir), util.OS_USER_RWX); err != nil {
+		return "", err
+	}
+	for _, dir := range []string{"proc", "sys", "dev"} {
+		if err = os.MkdirAll(filepath.Join(root, dir), util.OS_ALL_R|util.OS_ALL_X|util.OS_USER_W); err != nil {
+			return "", err
+		}
+	}
+	if err = os.WriteFile(filepath.Join(root, guestInit), []byte(initScript), util.OS_USER_RWX); err != nil {
+		return "", err
+	}
+
+	buildPath := filepath.Join(buildDir, "rootfs.ext4")
+	if err = makeDrive(ctx, buildPath, root, rootfsFreeSpace); err != nil {
+		return "", err
+	}
+	if err = os.Rename(buildPath, rootfsPath); err != nil {
+		return "", err
+	}
+	return rootfsPath, nil
+}
+
+// exportImage extracts the filesystem of the image into the directory.
+func (e *Executor) exportImage(ctx context.Context, imageID, dir string) error {
+	created, err := e.client.ContainerCreate(ctx, &container.Config{
+		Image: imageID,
+		// the container is never started, but images without a command can't
+		// be created without one
+		Entrypoint:      []string{"/bin/sh"},
+		NetworkDisabled: true,
+		Labels:          map[string]string{labelExecutorName: e.ID},
+	}, nil, nil, nil, "")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if removeErr := docker.RemoveContainer(ctx, e.client, created.ID); removeErr != nil {
+			log.Ctx(ctx).Debug().Err(removeErr).Msg("failed to remove export container")
+		}
+	}()
+
+	export, err := e.client.ContainerExport(ctx, created.ID)
+	if err != nil {
+		return err
+	}
+	defer export.Close()
+	return extractTar(export, dir)
+}
+
+// extractTar extracts the tarball into the directory. Images can be written
+// by anyone, so entries that would be written outside of the directory,
+// including through symlinks, are an error.
+//
+//nolint:gocyclo
+func extractTar(reader io.Reader, dir string) error {
+	if err := os.MkdirAll(dir, util.OS_ALL_R|util.OS_ALL_X|util.OS_USER_W); err != nil {
+		return err
+	}
+	canChown := os.Geteuid() == 0
+
+	tr := tar.NewReader(reader)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		target, err := securePath(dir, header.Name)
+		if err != nil {
+			return err
+		}
+		if target == dir {
+			continue
+		}
+		// replace what is there rather than writing through it
+		if info, statErr := os.Lstat(target); statErr == nil && !(info.IsDir() && header.Typeflag == tar.TypeDir) {
+			if err = os.RemoveAll(target); err != nil {
+				return err
+			}
+		}
+
+		mode := header.FileInfo().Mode()
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err = os.MkdirAll(target, mode.Perm()); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			var file *os.File
+			file, err = os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, mode.Perm())
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(file, tr) //nolint:gosec // images can be as big as they are
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			// symlinks are only followed inside the VM
+			if err = os.Symlink(header.Linkname, target); err != nil {
+				return err
+			}
+		case tar.TypeLink:
+			var source string
+			source, err = securePath(dir, header.Linkname)
+			if err != nil {
+				return err
+			}
+			if err = os.Link(source, target); err != nil {
+				return err
+			}
+		default:
+			// devices come from the devtmpfs of the VM
+			continue
+		}
+
+		if canChown {
+			if err = os.Lchown(target, header.Uid, header.Gid); err != nil {
+				return err
+			}
+		}
+		// chmod after chown, which clears the setuid and setgid bits
+		if header.Typeflag != tar.TypeSymlink {
+			if err = os.Chmod(target, mode&(os.ModePerm|os.ModeSetuid|os.ModeSetgid|os.ModeSticky)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// securePath returns where the entry of a tarball goes in the directory, and
+// an error if any of its parents is a symlink.
+func securePath(dir, name string) (string, error) {
+	relative := filepath.Clean(string(filepath.Separator) + name)
+	target := filepath.Join(dir, relative)
+	parent := dir
+	for _, part := range strings.Split(filepath.Dir(relative), string(filepath.Separator)) {
+		if part == "" {
+			continue
+		}
+		parent = filepath.Join(parent, part)
+		info, err := os.Lstat(parent)
+		if errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return "", fmt.Errorf("%s is written through the symlink %s", name, relative)
+		}
+	}
+	return target, nil
+}
+
+// makeDrive creates an ext4 image at the path with the contents of the
+// directory, or empty if there is none, and the given free space.
+func makeDrive(ctx context.Context, path, contents string, freeSpace uint64) error {
+	size := freeSpace + driveOverhead
+	if contents != "" {
+		used, err := dirSize(contents)
+		if err != nil {
+			return err
+		}
+		size += used + used/4
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	// the image is sparse, so only takes up what is written to it
+	err = file.Truncate(int64(size))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	args := []string{"-q", "-F", "-t", "ext4"}
+	if contents != "" {
+		args = append(args, "-d", contents)
+	}
+	args = append(args, path)
+	if _, err = run(ctx, "mkfs.ext4", args...); err != nil {
+		return err
+	}
+	// so jobs don't see it in their volumes
+	_, err = debugfs(ctx, path, true, "rmdir /lost+found")
+	return err
+}
+
+// readDrive copies the contents of the ext4 image into the directory.
+func readDrive(ctx context.Context, path, dir string) error {
+	_, err := debugfs(ctx, path, false, "rdump / "+dir)
+	return err
+}
+
+// readDriveFile returns the contents of a file in the ext4 image, and
+// os.ErrNotExist if it isn't there.
+func readDriveFile(ctx context.Context, path, name string) ([]byte, error) {
+	file, err := os.CreateTemp("", "bacalhau-firecracker-")
+	if err != nil {
+		return nil, err
+	}
+	_ = file.Close()
+	defer os.Remove(file.Name())
+
+	if _, err = debugfs(ctx, path, false, "stat "+name); err != nil {
+		return nil, os.ErrNotExist
+	}
+	if _, err = debugfs(ctx, path, false, fmt.Sprintf("dump %s %s", name, file.Name())); err != nil {
+		return nil, err
+	}
+	return os.ReadFile(file.Name())
+}
+
+// debugfs runs the command against the ext4 image. debugfs exits with zero
+// when commands fail, so anything it writes to stderr other than its banner
+// is an error.
+func debugfs(ctx context.Context, path string, write bool, command string) (string, error) {
+	args := []string{"-R", command}
+	if write {
+		args = append(args, "-w")
+	}
+	result, err := system.RunCommand(ctx, system.Command{
+		Name:    "debugfs",
+		Args:    append(args, path),
+		Timeout: rootfsCommandTimeout,
+	})
+	var messages []string
+	for _, line := range strings.Split(result.Stderr, "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "debugfs ") {
+			messages = append(messages, line)
+		}
+	}
+	if err == nil && len(messages) > 0 {
+		err = errors.New(strings.Join(messages, "; "))
+	}
+	if err != nil {
+		return "", fmt.Errorf("debugfs %q failed: %w", command, err)
+	}
+	return result.Stdout, nil
+}
+
+func run(ctx context.Context, name string, args ...string) (string, error) {
+	result, err := system.RunCommand(ctx, system.Command{
+		Name:    name,
+		Args:    args,
+		Timeout: rootfsCommandTimeout,
+	})
+	if err != nil {
+		return "", err
+	}
+	return result.Stdout, nil
+}
+
+func dirSize(dir string) (uint64, error) {
+	var size uint64
+	err := filepath.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+		if err == nil {
+			size += uint64(info.Size())
+		}
+		return err
+	})
+	return size, err
+}
+
+// driveName returns the name of the nth virtio block device of a VM, as
+// vda, vdb, ... vdz, vdaa.
+func driveName(index int) string {
+	name := ""
+	for index++; index > 0; index = (index - 1) / 26 {
+		name = string(rune('a'+(index-1)%26)) + name
+	}
+	return "vd" + name
+}
+
+func parseExitCode(data []byte) (int, error) {
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
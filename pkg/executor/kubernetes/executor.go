@@ -0,0 +1,421 @@
+// Package kubernetes runs docker jobs as pods through the Kubernetes API, for
+// compute nodes that run in a cluster and can't use a Docker socket.
+//
+// The compute node prepares the inputs of a job and collects its outputs on
+// its own disk, as it does for the docker executor, and job pods mount them
+// with hostPath volumes. So job pods are scheduled on the Kubernetes node the
+// compute node runs on, and the storage path and temp directory of the compute
+// node must be hostPath volumes mounted at the same paths as on that node.
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/filecoin-project/bacalhau/pkg/compute/capacity"
+	"github.com/filecoin-project/bacalhau/pkg/config"
+	"github.com/filecoin-project/bacalhau/pkg/executor"
+	jobutils "github.com/filecoin-project/bacalhau/pkg/job"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/storage"
+	"github.com/filecoin-project/bacalhau/pkg/system"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"go.uber.org/multierr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientset "k8s.io/client-go/kubernetes"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const (
+	// the namespace of the service account a pod runs as
+	serviceAccountNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+	defaultNamespace            = "default"
+	defaultPollInterval         = time.Second
+
+	jobContainerName = "job"
+	gpuResourceName  = corev1.ResourceName("nvidia.com/gpu")
+)
+
+// the reasons a job container waits that it won't recover from
+var imageErrorReasons = map[string]bool{
+	"ErrImagePull":      true,
+	"ImagePullBackOff":  true,
+	"InvalidImageName":  true,
+	"ErrImageNeverPull": true,
+}
+
+type Config struct {
+	// The namespace job pods are created in. Defaults to the namespace the
+	// compute node runs in.
+	Namespace string
+	// The Kubernetes node the compute node runs on, which job pods are
+	// scheduled on. Usually set from spec.nodeName with the downward API.
+	NodeName string
+	// The kubeconfig to talk to the cluster with, when the compute node runs
+	// outside of it.
+	Kubeconfig string
+}
+
+type Executor struct {
+	// used to tell apart the pods of compute nodes sharing a namespace
+	ID string
+
+	executor.StorageExecutor
+
+	Client clientset.Interface
+
+	namespace    string
+	nodeName     string
+	pollInterval time.Duration
+}
+
+func NewExecutor(
+	ctx context.Context,
+	cm *system.CleanupManager,
+	id string,
+	storageProvider storage.StorageProvider,
+	executorConfig Config,
+) (*Executor, error) {
+	var restConfig *rest.Config
+	var err error
+	if executorConfig.Kubeconfig != "" {
+		restConfig, err = clientcmd.BuildConfigFromFlags("", executorConfig.Kubeconfig)
+	} else {
+		restConfig, err = rest.InClusterConfig()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not load the Kubernetes client config: %w", err)
+	}
+	client, err := clientset.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+	return newExecutorWithClient(ctx, cm, id, storageProvider, executorConfig, client)
+}
+
+func newExecutorWithClient(
+	ctx context.Context,
+	cm *system.CleanupManager,
+	id string,
+	storageProvider storage.StorageProvider,
+	executorConfig Config,
+	client clientset.Interface,
+) (*Executor, error) {
+	if executorConfig.NodeName == "" {
+		return nil, errors.New("the Kubernetes executor needs the name of the node the compute node runs on, " +
+			"so that job pods can mount the inputs it prepares")
+	}
+	namespace := executorConfig.Namespace
+	if namespace == "" {
+		namespace = defaultNamespace
+		if data, err := os.ReadFile(serviceAccountNamespaceFile); err == nil {
+			namespace = strings.TrimSpace(string(data))
+		}
+	}
+
+	e := &Executor{
+		ID:              id,
+		StorageExecutor: executor.StorageExecutor{StorageProvider: storageProvider},
+		Client:          client,
+		namespace:       namespace,
+		nodeName:        executorConfig.NodeName,
+		pollInterval:    defaultPollInterval,
+	}
+
+	cm.RegisterCallback(func() error {
+		e.cleanupAll(ctx)
+		return nil
+	})
+
+	return e, nil
+}
+
+// IsInstalled checks if the Kubernetes API can be reached.
+func (e *Executor) IsInstalled(ctx context.Context) (bool, error) {
+	_, err := e.Client.Discovery().ServerVersion()
+	return err == nil, nil
+}
+
+func (e *Executor) RunShard(
+	ctx context.Context,
+	shard model.JobShard,
+	jobResultsDir string,
+) (*model.RunCommandResult, error) {
+	ctx, span := system.GetTracer().Start(ctx, "pkg/executor/kubernetes.RunShard")
+	defer span.End()
+	system.AddJobIDFromBaggageToSpan(ctx, span)
+	system.AddNodeIDFromBaggageToSpan(ctx, span)
+
+	if err := jobutils.VerifyDockerSpec(shard.Job.Spec.Docker); err != nil {
+		return &model.RunCommandResult{ErrorMsg: err.Error()}, err
+	}
+	if shard.Job.Spec.Docker.Stdin != "" {
+		err := fmt.Errorf("jobs can't read stdin in Kubernetes")
+		return &model.RunCommandResult{ErrorMsg: err.Error()}, err
+	}
+
+	inputs, err := e.PrepareInputs(ctx, shard)
+	if err != nil {
+		return &model.RunCommandResult{}, err
+	}
+	outputs, err := executor.PrepareOutputs(ctx, shard, jobResultsDir)
+	if err != nil {
+		return &model.RunCommandResult{ErrorMsg: err.Error()}, err
+	}
+
+	pod, err := e.jobPod(shard, append(inputs, outputs...))
+	if err != nil {
+		return &model.RunCommandResult{ErrorMsg: err.Error()}, err
+	}
+
+	log.Ctx(ctx).Trace().Msgf("Pod: %+v", pod)
+	if _, err = e.pods().Create(ctx, pod, metav1.CreateOptions{}); err != nil {
+		return executor.ErrorResult(ctx, "failed to create pod: ", err), err
+	}
+	defer e.cleanupJob(ctx, shard)
+
+	pod, err = e.waitForPod(ctx, pod.Name)
+	if err != nil {
+		return executor.ErrorResult(ctx, "failed to run pod: ", err), err
+	}
+
+	var podError error
+	exitCode := 0
+	if terminated := jobContainerState(pod).Terminated; terminated != nil {
+		exitCode = int(terminated.ExitCode)
+	} else if pod.Status.Phase == corev1.PodFailed {
+		// e.g. the pod was evicted for using more scratch space than it asked for
+		podError = fmt.Errorf("pod failed: %s %s", pod.Status.Reason, pod.Status.Message)
+	}
+
+	// pod logs interleave stdout and stderr, so they all end up in stdout
+	log.Ctx(ctx).Debug().Msgf("Capturing logs for pod %s", pod.Name)
+	logs, err := e.pods().GetLogs(pod.Name, &corev1.PodLogOptions{Container: jobContainerName}).Stream(ctx)
+	if err != nil {
+		return executor.WriteShardResults(jobResultsDir, strings.NewReader(""), strings.NewReader(err.Error()),
+			exitCode, multierr.Combine(podError, err))
+	}
+	defer logs.Close()
+
+	return executor.WriteShardResults(jobResultsDir, logs, strings.NewReader(""), exitCode, podError)
+}
+
+func (e *Executor) CancelShard(ctx context.Context, shard model.JobShard) error {
+	return e.deletePod(ctx, e.jobPodName(shard))
+}
+
+// jobPod returns the pod that runs the shard with the mounts.
+//
+//nolint:funlen
+func (e *Executor) jobPod(shard model.JobShard, mounts []executor.Mount) (*corev1.Pod, error) {
+	var volumes []corev1.Volume
+	var volumeMounts []corev1.VolumeMount
+	for i, mount := range mounts {
+		name := fmt.Sprintf("mount-%d", i)
+		volumes = append(volumes, corev1.Volume{
+			Name: name,
+			VolumeSource: corev1.VolumeSource{
+				HostPath: &corev1.HostPathVolumeSource{Path: mount.Source},
+			},
+		})
+		volumeMounts = append(volumeMounts, corev1.VolumeMount{Name: name, MountPath: mount.Target, ReadOnly: mount.ReadOnly})
+	}
+
+	// the kubelet evicts pods that write more to an emptyDir than its size
+	for i, scratch := range shard.Job.Spec.Scratch {
+		size, err := executor.ScratchVolumeSize(scratch)
+		if err != nil {
+			return nil, err
+		}
+		emptyDir := &corev1.EmptyDirVolumeSource{SizeLimit: resource.NewQuantity(int64(size), resource.BinarySI)}
+		if scratch.Tmpfs {
+			emptyDir.Medium = corev1.StorageMediumMemory
+		}
+		name := fmt.Sprintf("scratch-%d", i)
+		volumes = append(volumes, corev1.Volume{Name: name, VolumeSource: corev1.VolumeSource{EmptyDir: emptyDir}})
+		volumeMounts = append(volumeMounts, corev1.VolumeMount{Name: name, MountPath: scratch.Path})
+	}
+
+	jsonJobSpec, err := model.JSONMarshalWithMax(shard.Job.Spec)
+	if err != nil {
+		return nil, err
+	}
+	env := []corev1.EnvVar{}
+	for _, variable := range shard.Job.Spec.Docker.EnvironmentVariables {
+		name, value, _ := strings.Cut(variable, "=")
+		env = append(env, corev1.EnvVar{Name: name, Value: value})
+	}
+	env = append(env, corev1.EnvVar{Name: "BACALHAU_JOB_SPEC", Value: string(jsonJobSpec)})
+
+	resourceRequirements := capacity.ParseResourceUsageConfig(shard.Job.Spec.Resources)
+	limits := corev1.ResourceList{}
+	if resourceRequirements.CPU > 0 {
+		limits[corev1.ResourceCPU] = *resource.NewMilliQuantity(int64(resourceRequirements.CPU*1000), resource.DecimalSI)
+	}
+	if resourceRequirements.Memory > 0 {
+		limits[corev1.ResourceMemory] = *resource.NewQuantity(int64(resourceRequirements.Memory), resource.BinarySI)
+	}
+	if resourceRequirements.GPU > 0 {
+		limits[gpuResourceName] = *resource.NewQuantity(int64(resourceRequirements.GPU), resource.DecimalSI)
+	}
+
+	securityContext, err := userSecurityContext(shard.Job.Spec.Docker.User)
+	if err != nil {
+		return nil, err
+	}
+
+	pullPolicy := corev1.PullAlways
+	if os.Getenv("SKIP_IMAGE_PULL") != "" {
+		pullPolicy = corev1.PullIfNotPresent
+	}
+	automountToken := false
+
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      e.jobPodName(shard),
+			Namespace: e.namespace,
+			Labels:    e.jobPodLabels(shard.Job),
+		},
+		Spec: corev1.PodSpec{
+			NodeName:      e.nodeName,
+			RestartPolicy: corev1.RestartPolicyNever,
+			// jobs have no business with the Kubernetes API
+			AutomountServiceAccountToken: &automountToken,
+			Volumes:                      volumes,
+			Containers: []corev1.Container{{
+				Name:            jobContainerName,
+				Image:           shard.Job.Spec.Docker.Image,
+				ImagePullPolicy: pullPolicy,
+				Command:         shard.Job.Spec.Docker.Entrypoint,
+				WorkingDir:      shard.Job.Spec.Docker.WorkingDirectory,
+				Env:             env,
+				VolumeMounts:    volumeMounts,
+				Resources:       corev1.ResourceRequirements{Limits: limits},
+				SecurityContext: securityContext,
+			}},
+		},
+	}, nil
+}
+
+// userSecurityContext returns the security context that runs the container
+// of a job as the user, which Kubernetes only takes as a UID and GID.
+func userSecurityContext(user string) (*corev1.SecurityContext, error) {
+	if user == "" {
+		return nil, nil
+	}
+	uid, gid, hasGroup := strings.Cut(user, ":")
+	securityContext := &corev1.SecurityContext{}
+	id, err := strconv.ParseInt(uid, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("kubernetes runs containers as a UID, not the user %q", uid)
+	}
+	securityContext.RunAsUser = &id
+	if hasGroup {
+		groupID, err := strconv.ParseInt(gid, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("kubernetes runs containers as a GID, not the group %q", gid)
+		}
+		securityContext.RunAsGroup = &groupID
+	}
+	return securityContext, nil
+}
+
+// waitForPod returns the pod once it has finished, or an error if it can't
+// start.
+func (e *Executor) waitForPod(ctx context.Context, name string) (*corev1.Pod, error) {
+	ticker := time.NewTicker(e.pollInterval)
+	defer ticker.Stop()
+	for {
+		pod, err := e.pods().Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			return pod, nil
+		}
+		if waiting := jobContainerState(pod).Waiting; waiting != nil && imageErrorReasons[waiting.Reason] {
+			//nolint:stylecheck // Error message for user
+			return nil, fmt.Errorf(`Could not pull image - could be due to repo/image not existing,
+ or registry needing authorization. %s: %s`, pod.Spec.Containers[0].Image, waiting.Message)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func jobContainerState(pod *corev1.Pod) corev1.ContainerState {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Name == jobContainerName {
+			return status.State
+		}
+	}
+	return corev1.ContainerState{}
+}
+
+func (e *Executor) pods() typedcorev1.PodInterface {
+	return e.Client.CoreV1().Pods(e.namespace)
+}
+
+func (e *Executor) deletePod(ctx context.Context, name string) error {
+	return e.pods().Delete(ctx, name, metav1.DeleteOptions{})
+}
+
+func (e *Executor) cleanupJob(ctx context.Context, shard model.JobShard) {
+	if config.ShouldKeepStack() {
+		return
+	}
+
+	// the job context may be done, e.g. when the job timed out
+	if err := e.deletePod(context.Background(), e.jobPodName(shard)); err != nil {
+		log.Ctx(ctx).Error().Msgf("Kubernetes delete pod error: %s", err.Error())
+	}
+}
+
+func (e *Executor) cleanupAll(ctx context.Context) {
+	if config.ShouldKeepStack() {
+		return
+	}
+
+	// We have to use a separate context, rather than the one passed in to `NewExecutor`, as it may have already been
+	// canceled and so would prevent us from performing any cleanup work.
+	safeCtx := context.Background()
+
+	log.Ctx(ctx).Debug().Msgf("Cleaning up all bacalhau pods for executor %s...", e.ID)
+	err := e.pods().DeleteCollection(safeCtx, metav1.DeleteOptions{}, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("bacalhau-executor=%s", e.ID),
+	})
+	if err != nil {
+		log.Ctx(ctx).Err(err).Msgf("Non-critical error cleaning up pods")
+		return
+	}
+	log.Ctx(ctx).Debug().Msgf("Finished cleaning up all bacalhau pods for executor %s", e.ID)
+}
+
+// jobPodName returns the name of the pod of the shard, which is lower case as
+// pod names must be DNS labels.
+func (e *Executor) jobPodName(shard model.JobShard) string {
+	return strings.ToLower(fmt.Sprintf("%s-%s-%d", e.ID, shard.Job.ID, shard.Index))
+}
+
+func (e *Executor) jobPodLabels(job *model.Job) map[string]string {
+	return map[string]string{
+		"bacalhau-executor": e.ID,
+		"bacalhau-jobID":    job.ID,
+	}
+}
+
+// Compile-time interface check:
+var _ executor.Executor = (*Executor)(nil)
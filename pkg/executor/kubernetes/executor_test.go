@@ -0,0 +1,191 @@
+//go:build unit || !integration
+
+package kubernetes
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/bacalhau/pkg/executor"
+	"github.com/filecoin-project/bacalhau/pkg/executor/executortest"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/storage"
+	"github.com/filecoin-project/bacalhau/pkg/system"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const testNamespace = "bacalhau"
+
+func newTestExecutor(t *testing.T) (*Executor, *fake.Clientset) {
+	client := fake.NewSimpleClientset()
+	e, err := newExecutorWithClient(
+		context.Background(),
+		executortest.CleanupManager(t),
+		"bacalhau-12D3KooWExecutor",
+		executortest.StorageProvider(),
+		Config{Namespace: testNamespace, NodeName: "worker-1"},
+		client,
+	)
+	require.NoError(t, err)
+	e.pollInterval = 10 * time.Millisecond
+	return e, client
+}
+
+// finishPod waits for the pod to be created and then gives it the status.
+func finishPod(t *testing.T, client *fake.Clientset, name string, status corev1.PodStatus) {
+	pods := client.CoreV1().Pods(testNamespace)
+	require.Eventually(t, func() bool {
+		_, err := pods.Get(context.Background(), name, metav1.GetOptions{})
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	pod, err := pods.Get(context.Background(), name, metav1.GetOptions{})
+	require.NoError(t, err)
+	pod.Status = status
+	_, err = pods.UpdateStatus(context.Background(), pod, metav1.UpdateOptions{})
+	require.NoError(t, err)
+}
+
+func terminatedStatus(phase corev1.PodPhase, exitCode int32) corev1.PodStatus {
+	return corev1.PodStatus{
+		Phase: phase,
+		ContainerStatuses: []corev1.ContainerStatus{{
+			Name:  jobContainerName,
+			State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: exitCode}},
+		}},
+	}
+}
+
+func TestNewExecutorNeedsNodeName(t *testing.T) {
+	_, err := newExecutorWithClient(context.Background(), system.NewCleanupManager(), "id",
+		storage.NewMappedStorageProvider(nil), Config{}, fake.NewSimpleClientset())
+	require.Error(t, err)
+}
+
+func TestJobPod(t *testing.T) {
+	e, _ := newTestExecutor(t)
+	shard := executortest.DockerShard(model.Spec{
+		Docker: model.JobSpecDocker{
+			Image:                "ubuntu",
+			Entrypoint:           []string{"echo", "hello"},
+			EnvironmentVariables: []string{"A=1", "B=x=y"},
+		},
+		Resources: model.ResourceUsageConfig{CPU: "500m", Memory: "1Gi", GPU: "1"},
+		Outputs:   []model.StorageSpec{{Name: "outputs", Path: "/outputs"}},
+		Scratch:   []model.ScratchVolume{{Path: "/scratch", Size: "1Gi", Tmpfs: true}},
+	})
+
+	pod, err := e.jobPod(shard, []executor.Mount{
+		{Source: "/data/input", Target: "/inputs", ReadOnly: true},
+		{Source: "/results/outputs", Target: "/outputs"},
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, "bacalhau-12d3koowexecutor-job-id-0", pod.Name)
+	require.Equal(t, testNamespace, pod.Namespace)
+	require.Equal(t, "bacalhau-12D3KooWExecutor", pod.Labels["bacalhau-executor"])
+	require.Equal(t, "worker-1", pod.Spec.NodeName)
+	require.Equal(t, corev1.RestartPolicyNever, pod.Spec.RestartPolicy)
+
+	container := pod.Spec.Containers[0]
+	require.Equal(t, "ubuntu", container.Image)
+	require.Equal(t, []string{"echo", "hello"}, container.Command)
+	require.Contains(t, container.Env, corev1.EnvVar{Name: "A", Value: "1"})
+	require.Contains(t, container.Env, corev1.EnvVar{Name: "B", Value: "x=y"})
+	require.Equal(t, "500m", container.Resources.Limits.Cpu().String())
+	require.Equal(t, "1Gi", container.Resources.Limits.Memory().String())
+	require.Equal(t, "1", container.Resources.Limits.Name(gpuResourceName, "").String())
+
+	volumes := map[string]corev1.Volume{}
+	for _, volume := range pod.Spec.Volumes {
+		volumes[volume.Name] = volume
+	}
+	mounts := map[string]corev1.VolumeMount{}
+	for _, mount := range container.VolumeMounts {
+		mounts[mount.MountPath] = mount
+	}
+	require.Len(t, mounts, 3)
+
+	input := mounts["/inputs"]
+	require.True(t, input.ReadOnly)
+	require.Equal(t, "/data/input", volumes[input.Name].HostPath.Path)
+
+	output := mounts["/outputs"]
+	require.False(t, output.ReadOnly)
+	require.Equal(t, "/results/outputs", volumes[output.Name].HostPath.Path)
+
+	scratch := volumes[mounts["/scratch"].Name].EmptyDir
+	require.Equal(t, corev1.StorageMediumMemory, scratch.Medium)
+	require.Equal(t, "1Gi", scratch.SizeLimit.String())
+}
+
+func TestUserSecurityContext(t *testing.T) {
+	securityContext, err := userSecurityContext("")
+	require.NoError(t, err)
+	require.Nil(t, securityContext)
+
+	securityContext, err = userSecurityContext("1000")
+	require.NoError(t, err)
+	require.Equal(t, int64(1000), *securityContext.RunAsUser)
+	require.Nil(t, securityContext.RunAsGroup)
+
+	securityContext, err = userSecurityContext("1000:100")
+	require.NoError(t, err)
+	require.Equal(t, int64(1000), *securityContext.RunAsUser)
+	require.Equal(t, int64(100), *securityContext.RunAsGroup)
+
+	_, err = userSecurityContext("nobody")
+	require.Error(t, err)
+	_, err = userSecurityContext("1000:users")
+	require.Error(t, err)
+}
+
+func TestRunShard(t *testing.T) {
+	e, client := newTestExecutor(t)
+	shard := executortest.DockerShard(model.Spec{Docker: model.JobSpecDocker{Image: "ubuntu"}})
+
+	go finishPod(t, client, e.jobPodName(shard), terminatedStatus(corev1.PodSucceeded, 0))
+	result, err := e.RunShard(context.Background(), shard, t.TempDir())
+	require.NoError(t, err)
+	require.Equal(t, 0, result.ExitCode)
+	require.Equal(t, "fake logs", result.STDOUT)
+
+	// the pod is deleted once the job has finished
+	pods, err := client.CoreV1().Pods(testNamespace).List(context.Background(), metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Empty(t, pods.Items)
+}
+
+func TestRunShardExitCode(t *testing.T) {
+	e, client := newTestExecutor(t)
+	shard := executortest.DockerShard(model.Spec{Docker: model.JobSpecDocker{Image: "ubuntu"}})
+
+	go finishPod(t, client, e.jobPodName(shard), terminatedStatus(corev1.PodFailed, 3))
+	result, err := e.RunShard(context.Background(), shard, t.TempDir())
+	require.Error(t, err)
+	require.Equal(t, 3, result.ExitCode)
+	require.Contains(t, result.ErrorMsg, "exit code was not zero: 3")
+}
+
+func TestRunShardImagePullError(t *testing.T) {
+	e, client := newTestExecutor(t)
+	shard := executortest.DockerShard(model.Spec{Docker: model.JobSpecDocker{Image: "missing"}})
+
+	go finishPod(t, client, e.jobPodName(shard), corev1.PodStatus{
+		Phase: corev1.PodPending,
+		ContainerStatuses: []corev1.ContainerStatus{{
+			Name: jobContainerName,
+			State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{
+				Reason:  "ImagePullBackOff",
+				Message: "pull access denied",
+			}},
+		}},
+	})
+	result, err := e.RunShard(context.Background(), shard, t.TempDir())
+	require.Error(t, err)
+	require.Contains(t, result.ErrorMsg, "Could not pull image")
+	require.Contains(t, result.ErrorMsg, "pull access denied")
+}
@@ -8,6 +8,8 @@ docker executor, depending on whether determinism is required.
 import (
 	"context"
 	"fmt"
+	"path"
+	"strings"
 
 	"github.com/rs/zerolog/log"
 
@@ -31,6 +33,41 @@ var supportedVersions = map[LanguageSpec]model.Engine{
 	{"wasm", "2.0"}:    model.EngineWasm,
 }
 
+// dockerRuntime is how jobs of a language that don't need to be
+// deterministic are run in docker.
+type dockerRuntime struct {
+	Image string
+	// the entrypoint that runs an inline program
+	Command []string
+	// the entrypoint that runs a program file
+	Program []string
+	// the entrypoint that installs a requirements file
+	Requirements []string
+}
+
+// contextPath is where the context of a job is mounted
+const contextPath = "/job"
+
+var dockerRuntimes = map[LanguageSpec]dockerRuntime{
+	{"python", "3.10"}: {
+		Image:        "python:3.10-slim",
+		Command:      []string{"python", "-c"},
+		Program:      []string{"python"},
+		Requirements: []string{"pip", "install", "--quiet", "-r"},
+	},
+	{"r", "4.2"}: {
+		Image:   "r-base:4.2.2",
+		Command: []string{"Rscript", "-e"},
+		Program: []string{"Rscript"},
+	},
+	{"sql", "3"}: {
+		Image:   "keinos/sqlite3:3.40.0",
+		Command: []string{"sqlite3", ":memory:"},
+		// sqlite3 reads a script from stdin
+		Program: []string{"sh", "-c", `exec sqlite3 :memory: < "$0"`},
+	},
+}
+
 func NewExecutor(
 	ctx context.Context,
 	cm *system.CleanupManager,
@@ -80,26 +117,65 @@ func (e *Executor) getDelegateExecutor(ctx context.Context, shard model.JobShard
 		Version:  shard.Job.Spec.Language.LanguageVersion,
 	}
 
+	if !shard.Job.Spec.Language.Deterministic {
+		runtime, exists := dockerRuntimes[requiredLang]
+		if !exists {
+			return nil, fmt.Errorf("non-deterministic %v not supported yet", requiredLang)
+		}
+		log.Ctx(ctx).Debug().Msgf("Running non-deterministic %v in docker", requiredLang)
+		if err := translateToDocker(shard.Job, runtime); err != nil {
+			return nil, err
+		}
+		return e.executors.GetExecutor(ctx, model.EngineDocker)
+	}
+
 	engineKey, exists := supportedVersions[requiredLang]
 	if !exists {
 		err := fmt.Errorf("%v is not supported", requiredLang)
 		return nil, err
 	}
 
-	if shard.Job.Spec.Language.Deterministic {
-		log.Ctx(ctx).Debug().Msgf("Running deterministic %v", requiredLang)
-		// Instantiate a python_wasm
-		// TODO: mutate job as needed?
-		executor, err := e.executors.GetExecutor(ctx, engineKey)
-		if err != nil {
-			return nil, err
-		}
-		return executor, nil
-	} else {
-		err := fmt.Errorf("non-deterministic %v not supported yet", requiredLang)
-		// TODO: Instantiate a docker with python:3.10 image
+	log.Ctx(ctx).Debug().Msgf("Running deterministic %v", requiredLang)
+	// Instantiate a python_wasm
+	// TODO: mutate job as needed?
+	executor, err := e.executors.GetExecutor(ctx, engineKey)
+	if err != nil {
 		return nil, err
 	}
+	return executor, nil
+}
+
+// translateToDocker turns a language job into a docker job that runs it with
+// the runtime of the language.
+func translateToDocker(j *model.Job, runtime dockerRuntime) error {
+	language := j.Spec.Language
+
+	var entrypoint []string
+	switch {
+	case language.Command != "":
+		entrypoint = append(append(entrypoint, runtime.Command...), language.Command)
+	case language.ProgramPath != "":
+		entrypoint = append(append(entrypoint, runtime.Program...), path.Join(contextPath, language.ProgramPath))
+	default:
+		return fmt.Errorf("one of a command or a program path must be specified")
+	}
+
+	if language.RequirementsPath != "" {
+		if runtime.Requirements == nil {
+			return fmt.Errorf("%s does not support a requirements file", language.Language)
+		}
+		// install the requirements, then exec the program with the remaining
+		// arguments so that nothing needs quoting
+		script := strings.Join(runtime.Requirements, " ") + ` "$1" && shift && exec "$@"`
+		entrypoint = append([]string{
+			"sh", "-c", script, "sh", path.Join(contextPath, language.RequirementsPath),
+		}, entrypoint...)
+	}
+
+	j.Spec.Engine = model.EngineDocker
+	j.Spec.Docker.Image = runtime.Image
+	j.Spec.Docker.Entrypoint = entrypoint
+	return nil
 }
 
 // Compile-time check that Executor implements the Executor interface.
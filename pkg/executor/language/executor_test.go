@@ -0,0 +1,65 @@
+//go:build unit || !integration
+
+package language
+
+import (
+	"testing"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTranslateToDocker(t *testing.T) {
+	for _, testCase := range []struct {
+		name       string
+		language   model.JobSpecLanguage
+		entrypoint []string
+	}{
+		{
+			name:       "python command",
+			language:   model.JobSpecLanguage{Language: "python", LanguageVersion: "3.10", Command: "print(1+1)"},
+			entrypoint: []string{"python", "-c", "print(1+1)"},
+		},
+		{
+			name: "python program with requirements",
+			language: model.JobSpecLanguage{
+				Language: "python", LanguageVersion: "3.10", ProgramPath: "main.py", RequirementsPath: "requirements.txt",
+			},
+			entrypoint: []string{
+				"sh", "-c", `pip install --quiet -r "$1" && shift && exec "$@"`, "sh", "/job/requirements.txt",
+				"python", "/job/main.py",
+			},
+		},
+		{
+			name:       "r program",
+			language:   model.JobSpecLanguage{Language: "r", LanguageVersion: "4.2", ProgramPath: "analysis.R"},
+			entrypoint: []string{"Rscript", "/job/analysis.R"},
+		},
+		{
+			name:       "sql program",
+			language:   model.JobSpecLanguage{Language: "sql", LanguageVersion: "3", ProgramPath: "query.sql"},
+			entrypoint: []string{"sh", "-c", `exec sqlite3 :memory: < "$0"`, "/job/query.sql"},
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			j := &model.Job{Spec: model.Spec{Engine: model.EngineLanguage, Language: testCase.language}}
+			runtime := dockerRuntimes[LanguageSpec{testCase.language.Language, testCase.language.LanguageVersion}]
+
+			require.NoError(t, translateToDocker(j, runtime))
+			require.Equal(t, model.EngineDocker, j.Spec.Engine)
+			require.Equal(t, runtime.Image, j.Spec.Docker.Image)
+			require.Equal(t, testCase.entrypoint, j.Spec.Docker.Entrypoint)
+		})
+	}
+}
+
+func TestTranslateToDockerErrors(t *testing.T) {
+	// nothing to run
+	j := &model.Job{Spec: model.Spec{Language: model.JobSpecLanguage{Language: "r", LanguageVersion: "4.2"}}}
+	require.Error(t, translateToDocker(j, dockerRuntimes[LanguageSpec{"r", "4.2"}]))
+
+	// no package manager
+	j.Spec.Language.ProgramPath = "analysis.R"
+	j.Spec.Language.RequirementsPath = "requirements.txt"
+	require.Error(t, translateToDocker(j, dockerRuntimes[LanguageSpec{"r", "4.2"}]))
+}
@@ -0,0 +1,208 @@
+package nomad
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// The parts of the Nomad API objects the executor reads and writes. See
+// https://developer.hashicorp.com/nomad/api-docs/json-jobs
+type nomadJob struct {
+	ID          string
+	Name        string
+	Type        string
+	Namespace   string `json:",omitempty"`
+	Region      string `json:",omitempty"`
+	Datacenters []string
+	Meta        map[string]string
+	Constraints []nomadConstraint `json:",omitempty"`
+	TaskGroups  []nomadTaskGroup
+}
+
+type nomadConstraint struct {
+	LTarget string
+	RTarget string
+	Operand string
+}
+
+type nomadTaskGroup struct {
+	Name             string
+	Count            int
+	RestartPolicy    nomadRestartPolicy
+	ReschedulePolicy nomadReschedulePolicy
+	Tasks            []nomadTask
+}
+
+type nomadRestartPolicy struct {
+	Attempts int
+	Mode     string
+}
+
+type nomadReschedulePolicy struct {
+	Attempts  int
+	Unlimited bool
+}
+
+type nomadTask struct {
+	Name      string
+	Driver    string
+	User      string `json:",omitempty"`
+	Config    map[string]interface{}
+	Env       map[string]string
+	Resources nomadResources
+}
+
+type nomadResources struct {
+	CPU      int           `json:",omitempty"`
+	MemoryMB int           `json:",omitempty"`
+	Devices  []nomadDevice `json:",omitempty"`
+}
+
+type nomadDevice struct {
+	Name  string
+	Count uint64
+}
+
+type nomadAllocation struct {
+	ID           string
+	ClientStatus string
+	TaskStates   map[string]nomadTaskState
+}
+
+type nomadTaskState struct {
+	State  string
+	Failed bool
+	Events []nomadTaskEvent
+}
+
+type nomadTaskEvent struct {
+	Type           string
+	ExitCode       int
+	DisplayMessage string
+	DriverError    string
+}
+
+type nomadEvaluation struct {
+	ID             string
+	Status         string
+	FailedTGAllocs map[string]nomadAllocationMetric
+}
+
+type nomadAllocationMetric struct {
+	NodesEvaluated     int
+	ConstraintFiltered map[string]int
+	DimensionExhausted map[string]int
+}
+
+type nomadJobStub struct {
+	ID string
+}
+
+// client talks to the HTTP API of a Nomad agent.
+type client struct {
+	address    string
+	token      string
+	namespace  string
+	region     string
+	httpClient *http.Client
+}
+
+func (c *client) register(ctx context.Context, job *nomadJob) error {
+	return c.do(ctx, http.MethodPut, "/v1/jobs", nil, map[string]interface{}{"Job": job}, nil)
+}
+
+func (c *client) deregister(ctx context.Context, jobID string) error {
+	query := url.Values{"purge": {"true"}}
+	return c.do(ctx, http.MethodDelete, "/v1/job/"+url.PathEscape(jobID), query, nil, nil)
+}
+
+func (c *client) jobs(ctx context.Context, prefix string) ([]nomadJobStub, error) {
+	var jobs []nomadJobStub
+	err := c.do(ctx, http.MethodGet, "/v1/jobs", url.Values{"prefix": {prefix}}, nil, &jobs)
+	return jobs, err
+}
+
+func (c *client) allocations(ctx context.Context, jobID string) ([]nomadAllocation, error) {
+	var allocations []nomadAllocation
+	err := c.do(ctx, http.MethodGet, "/v1/job/"+url.PathEscape(jobID)+"/allocations", nil, nil, &allocations)
+	return allocations, err
+}
+
+func (c *client) evaluations(ctx context.Context, jobID string) ([]nomadEvaluation, error) {
+	var evaluations []nomadEvaluation
+	err := c.do(ctx, http.MethodGet, "/v1/job/"+url.PathEscape(jobID)+"/evaluations", nil, nil, &evaluations)
+	return evaluations, err
+}
+
+// logs returns the stdout or stderr of the task of the allocation.
+func (c *client) logs(ctx context.Context, allocationID, task, logType string) (io.ReadCloser, error) {
+	query := url.Values{"task": {task}, "type": {logType}, "origin": {"start"}, "plain": {"true"}}
+	res, err := c.request(ctx, http.MethodGet, "/v1/client/fs/logs/"+url.PathEscape(allocationID), query, nil)
+	if err != nil {
+		return nil, err
+	}
+	return res.Body, nil
+}
+
+func (c *client) leader(ctx context.Context) error {
+	var leader string
+	return c.do(ctx, http.MethodGet, "/v1/status/leader", nil, nil, &leader)
+}
+
+func (c *client) do(ctx context.Context, method, path string, query url.Values, body, out interface{}) error {
+	res, err := c.request(ctx, method, path, query, body)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(out)
+}
+
+func (c *client) request(
+	ctx context.Context, method, path string, query url.Values, body interface{}) (*http.Response, error) {
+	if query == nil {
+		query = url.Values{}
+	}
+	if c.namespace != "" {
+		query.Set("namespace", c.namespace)
+	}
+	if c.region != "" {
+		query.Set("region", c.region)
+	}
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(c.address, "/")+path+"?"+query.Encode(), reader)
+	if err != nil {
+		return nil, err
+	}
+	if c.token != "" {
+		req.Header.Set("X-Nomad-Token", c.token)
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		defer res.Body.Close()
+		message, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return nil, fmt.Errorf("nomad %s %s returned %s: %s", method, path, res.Status, strings.TrimSpace(string(message)))
+	}
+	return res, nil
+}
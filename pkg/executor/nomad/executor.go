@@ -0,0 +1,415 @@
+// Package nomad runs docker jobs as Nomad batch jobs, for operators who
+// already run their workloads on a Nomad cluster.
+//
+// The compute node prepares the inputs of a job and collects its outputs on
+// its own disk, as it does for the docker executor, and the Nomad job bind
+// mounts them. So Nomad jobs are constrained to the Nomad client the compute
+// node runs on, and the docker driver of that client must have volumes
+// enabled.
+package nomad
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/c2h5oh/datasize"
+	"github.com/filecoin-project/bacalhau/pkg/compute/capacity"
+	"github.com/filecoin-project/bacalhau/pkg/config"
+	"github.com/filecoin-project/bacalhau/pkg/executor"
+	jobutils "github.com/filecoin-project/bacalhau/pkg/job"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/storage"
+	"github.com/filecoin-project/bacalhau/pkg/system"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"go.uber.org/multierr"
+)
+
+const (
+	DefaultAddress    = "http://127.0.0.1:4646"
+	DefaultMHzPerCPU  = 1000
+	defaultDatacenter = "dc1"
+
+	defaultPollInterval = time.Second
+	jobTaskName         = "job"
+	gpuDeviceName       = "nvidia/gpu"
+	// Nomad won't run a task with less memory than this
+	minMemoryMB = 10
+)
+
+type Config struct {
+	// The address of the HTTP API of a Nomad agent.
+	Address string
+	// The ACL token to talk to Nomad with.
+	Token     string
+	Namespace string
+	Region    string
+	// The datacenters jobs can run in.
+	Datacenters []string
+	// The name of the Nomad client the compute node runs on, which jobs are
+	// constrained to.
+	NodeName string
+	// Nomad reserves CPU in MHz, so each CPU a job asks for is reserved as
+	// this many MHz.
+	MHzPerCPU int
+}
+
+type Executor struct {
+	// used to tell apart the jobs of compute nodes sharing a Nomad cluster
+	ID string
+
+	executor.StorageExecutor
+
+	client       *client
+	datacenters  []string
+	nodeName     string
+	mhzPerCPU    int
+	pollInterval time.Duration
+}
+
+func NewExecutor(
+	ctx context.Context,
+	cm *system.CleanupManager,
+	id string,
+	storageProvider storage.StorageProvider,
+	executorConfig Config,
+) (*Executor, error) {
+	if executorConfig.NodeName == "" {
+		return nil, errors.New("the Nomad executor needs the name of the Nomad client the compute node runs on, " +
+			"so that jobs can mount the inputs it prepares")
+	}
+	if executorConfig.Address == "" {
+		executorConfig.Address = DefaultAddress
+	}
+	if len(executorConfig.Datacenters) == 0 {
+		executorConfig.Datacenters = []string{defaultDatacenter}
+	}
+	if executorConfig.MHzPerCPU <= 0 {
+		executorConfig.MHzPerCPU = DefaultMHzPerCPU
+	}
+
+	e := &Executor{
+		ID:              id,
+		StorageExecutor: executor.StorageExecutor{StorageProvider: storageProvider},
+		client: &client{
+			address:    executorConfig.Address,
+			token:      executorConfig.Token,
+			namespace:  executorConfig.Namespace,
+			region:     executorConfig.Region,
+			httpClient: http.DefaultClient,
+		},
+		datacenters:  executorConfig.Datacenters,
+		nodeName:     executorConfig.NodeName,
+		mhzPerCPU:    executorConfig.MHzPerCPU,
+		pollInterval: defaultPollInterval,
+	}
+
+	cm.RegisterCallback(func() error {
+		e.cleanupAll(ctx)
+		return nil
+	})
+
+	return e, nil
+}
+
+// IsInstalled checks if the Nomad API can be reached and has a leader.
+func (e *Executor) IsInstalled(ctx context.Context) (bool, error) {
+	return e.client.leader(ctx) == nil, nil
+}
+
+func (e *Executor) RunShard(
+	ctx context.Context,
+	shard model.JobShard,
+	jobResultsDir string,
+) (*model.RunCommandResult, error) {
+	ctx, span := system.GetTracer().Start(ctx, "pkg/executor/nomad.RunShard")
+	defer span.End()
+	system.AddJobIDFromBaggageToSpan(ctx, span)
+	system.AddNodeIDFromBaggageToSpan(ctx, span)
+
+	if err := jobutils.VerifyDockerSpec(shard.Job.Spec.Docker); err != nil {
+		return &model.RunCommandResult{ErrorMsg: err.Error()}, err
+	}
+	if shard.Job.Spec.Docker.Stdin != "" {
+		err := fmt.Errorf("jobs can't read stdin in Nomad")
+		return &model.RunCommandResult{ErrorMsg: err.Error()}, err
+	}
+
+	inputs, err := e.PrepareInputs(ctx, shard)
+	if err != nil {
+		return &model.RunCommandResult{}, err
+	}
+	outputs, err := executor.PrepareOutputs(ctx, shard, jobResultsDir)
+	if err != nil {
+		return &model.RunCommandResult{ErrorMsg: err.Error()}, err
+	}
+	scratchVolumes, err := executor.PrepareScratchVolumes(shard.Job.Spec.Scratch, "")
+	defer func() {
+		if cleanupErr := executor.CleanupScratchVolumes(scratchVolumes); cleanupErr != nil {
+			log.Ctx(ctx).Warn().Err(cleanupErr).Msg("failed to remove scratch volumes")
+		}
+	}()
+	if err != nil {
+		return &model.RunCommandResult{ErrorMsg: err.Error()}, err
+	}
+
+	job, err := e.nomadJob(shard, append(inputs, outputs...), scratchVolumes)
+	if err != nil {
+		return &model.RunCommandResult{ErrorMsg: err.Error()}, err
+	}
+
+	log.Ctx(ctx).Trace().Msgf("Nomad job: %+v", job)
+	if err = e.client.register(ctx, job); err != nil {
+		return executor.ErrorResult(ctx, "failed to register Nomad job: ", err), err
+	}
+	defer e.cleanupJob(ctx, shard)
+
+	allocation, err := e.waitForAllocation(ctx, job.ID)
+	if err != nil {
+		return executor.ErrorResult(ctx, "failed to run Nomad job: ", err), err
+	}
+
+	taskState := allocation.TaskStates[jobTaskName]
+	var taskError error
+	exitCode := 0
+	for _, event := range taskState.Events {
+		switch event.Type {
+		case "Terminated":
+			exitCode = event.ExitCode
+		case "Driver Failure":
+			taskError = fmt.Errorf("driver failure: %s", event.DriverError)
+		}
+	}
+	if taskError == nil && allocation.ClientStatus != "complete" && exitCode == 0 {
+		// e.g. the Nomad client the job ran on was lost
+		taskError = fmt.Errorf("nomad allocation %s is %s", allocation.ID, allocation.ClientStatus)
+	}
+	// Nomad doesn't enforce the size of scratch volumes on disk
+	scratchErr := executor.CheckScratchVolumes(scratchVolumes)
+
+	log.Ctx(ctx).Debug().Msgf("Capturing stdout/stderr for Nomad allocation %s", allocation.ID)
+	stdout, stdoutErr := e.client.logs(ctx, allocation.ID, jobTaskName, "stdout")
+	if stdoutErr != nil {
+		stdout = io.NopCloser(strings.NewReader(""))
+	}
+	defer stdout.Close()
+	stderr, stderrErr := e.client.logs(ctx, allocation.ID, jobTaskName, "stderr")
+	if stderrErr != nil {
+		stderr = io.NopCloser(strings.NewReader(""))
+	}
+	defer stderr.Close()
+
+	return executor.WriteShardResults(
+		jobResultsDir,
+		stdout,
+		stderr,
+		exitCode,
+		multierr.Combine(taskError, scratchErr, stdoutErr, stderrErr),
+	)
+}
+
+func (e *Executor) CancelShard(ctx context.Context, shard model.JobShard) error {
+	return e.client.deregister(ctx, e.jobID(shard))
+}
+
+// nomadJob returns the Nomad job that runs the shard with the mounts and
+// scratch volumes.
+//
+//nolint:funlen
+func (e *Executor) nomadJob(
+	shard model.JobShard,
+	mounts []executor.Mount,
+	scratchVolumes []executor.ScratchVolume,
+) (*nomadJob, error) {
+	driverMounts := []map[string]interface{}{}
+	bind := func(source, target string, readOnly bool) {
+		driverMounts = append(driverMounts, map[string]interface{}{
+			"type":     "bind",
+			"source":   source,
+			"target":   target,
+			"readonly": readOnly,
+		})
+	}
+	for _, mount := range mounts {
+		bind(mount.Source, mount.Target, mount.ReadOnly)
+	}
+	for _, scratch := range scratchVolumes {
+		if scratch.Dir != "" {
+			bind(scratch.Dir, scratch.Spec.Path, false)
+			continue
+		}
+		driverMounts = append(driverMounts, map[string]interface{}{
+			"type":          "tmpfs",
+			"target":        scratch.Spec.Path,
+			"tmpfs_options": map[string]interface{}{"size": scratch.Size},
+		})
+	}
+
+	jsonJobSpec, err := model.JSONMarshalWithMax(shard.Job.Spec)
+	if err != nil {
+		return nil, err
+	}
+	env := map[string]string{}
+	for _, variable := range shard.Job.Spec.Docker.EnvironmentVariables {
+		name, value, _ := strings.Cut(variable, "=")
+		env[name] = value
+	}
+	env["BACALHAU_JOB_SPEC"] = string(jsonJobSpec)
+
+	driverConfig := map[string]interface{}{
+		"image":        shard.Job.Spec.Docker.Image,
+		"network_mode": "none",
+		"mounts":       driverMounts,
+		"force_pull":   os.Getenv("SKIP_IMAGE_PULL") == "",
+	}
+	if len(shard.Job.Spec.Docker.Entrypoint) > 0 {
+		driverConfig["entrypoint"] = shard.Job.Spec.Docker.Entrypoint
+	}
+	if shard.Job.Spec.Docker.WorkingDirectory != "" {
+		driverConfig["work_dir"] = shard.Job.Spec.Docker.WorkingDirectory
+	}
+
+	resourceRequirements := capacity.ParseResourceUsageConfig(shard.Job.Spec.Resources)
+	resources := nomadResources{}
+	if resourceRequirements.CPU > 0 {
+		resources.CPU = int(resourceRequirements.CPU * float64(e.mhzPerCPU))
+	}
+	if resourceRequirements.Memory > 0 {
+		resources.MemoryMB = int(datasize.ByteSize(resourceRequirements.Memory).MBytes())
+		if resources.MemoryMB < minMemoryMB {
+			resources.MemoryMB = minMemoryMB
+		}
+	}
+	if resourceRequirements.GPU > 0 {
+		resources.Devices = []nomadDevice{{Name: gpuDeviceName, Count: uint64(resourceRequirements.GPU)}}
+	}
+
+	jobID := e.jobID(shard)
+	return &nomadJob{
+		ID:          jobID,
+		Name:        jobID,
+		Type:        "batch",
+		Namespace:   e.client.namespace,
+		Region:      e.client.region,
+		Datacenters: e.datacenters,
+		Meta: map[string]string{
+			"bacalhau-executor": e.ID,
+			"bacalhau-jobID":    shard.Job.ID,
+		},
+		Constraints: []nomadConstraint{{LTarget: "${node.unique.name}", RTarget: e.nodeName, Operand: "="}},
+		TaskGroups: []nomadTaskGroup{{
+			Name:  jobTaskName,
+			Count: 1,
+			// the requester retries jobs on other nodes, so Nomad shouldn't
+			RestartPolicy:    nomadRestartPolicy{Attempts: 0, Mode: "fail"},
+			ReschedulePolicy: nomadReschedulePolicy{Attempts: 0, Unlimited: false},
+			Tasks: []nomadTask{{
+				Name:      jobTaskName,
+				Driver:    "docker",
+				User:      shard.Job.Spec.Docker.User,
+				Config:    driverConfig,
+				Env:       env,
+				Resources: resources,
+			}},
+		}},
+	}, nil
+}
+
+// waitForAllocation returns the allocation of the job once it has finished,
+// or an error if Nomad can't place it.
+func (e *Executor) waitForAllocation(ctx context.Context, jobID string) (nomadAllocation, error) {
+	ticker := time.NewTicker(e.pollInterval)
+	defer ticker.Stop()
+	for {
+		allocations, err := e.client.allocations(ctx, jobID)
+		if err != nil {
+			return nomadAllocation{}, err
+		}
+		for _, allocation := range allocations {
+			switch allocation.ClientStatus {
+			case "complete", "failed", "lost":
+				return allocation, nil
+			}
+		}
+		if len(allocations) == 0 {
+			if err = e.placementError(ctx, jobID); err != nil {
+				return nomadAllocation{}, err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nomadAllocation{}, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// placementError returns why Nomad couldn't place the job, if it couldn't.
+func (e *Executor) placementError(ctx context.Context, jobID string) error {
+	evaluations, err := e.client.evaluations(ctx, jobID)
+	if err != nil {
+		return err
+	}
+	for _, evaluation := range evaluations {
+		for _, metric := range evaluation.FailedTGAllocs {
+			var reasons []string
+			for reason, count := range metric.ConstraintFiltered {
+				reasons = append(reasons, fmt.Sprintf("%s filtered %d nodes", reason, count))
+			}
+			for dimension, count := range metric.DimensionExhausted {
+				reasons = append(reasons, fmt.Sprintf("%s exhausted on %d nodes", dimension, count))
+			}
+			sort.Strings(reasons)
+			return fmt.Errorf("nomad could not place the job on node %s: %s", e.nodeName, strings.Join(reasons, ", "))
+		}
+	}
+	return nil
+}
+
+func (e *Executor) cleanupJob(ctx context.Context, shard model.JobShard) {
+	if config.ShouldKeepStack() {
+		return
+	}
+
+	// the job context may be done, e.g. when the job timed out
+	if err := e.client.deregister(context.Background(), e.jobID(shard)); err != nil {
+		log.Ctx(ctx).Error().Msgf("Nomad deregister job error: %s", err.Error())
+	}
+}
+
+func (e *Executor) cleanupAll(ctx context.Context) {
+	if config.ShouldKeepStack() {
+		return
+	}
+
+	// We have to use a separate context, rather than the one passed in to `NewExecutor`, as it may have already been
+	// canceled and so would prevent us from performing any cleanup work.
+	safeCtx := context.Background()
+
+	log.Ctx(ctx).Debug().Msgf("Cleaning up all bacalhau Nomad jobs for executor %s...", e.ID)
+	jobs, err := e.client.jobs(safeCtx, e.ID+"-")
+	if err != nil {
+		log.Ctx(ctx).Error().Msgf("Nomad executor stop error: %s", err.Error())
+		return
+	}
+	for _, job := range jobs {
+		if err := e.client.deregister(safeCtx, job.ID); err != nil { //nolint:govet // ignore err shadowing
+			log.Ctx(ctx).Err(err).Msgf("Non-critical error cleaning up Nomad job")
+		}
+	}
+	log.Ctx(ctx).Debug().Msgf("Finished cleaning up all bacalhau Nomad jobs for executor %s", e.ID)
+}
+
+func (e *Executor) jobID(shard model.JobShard) string {
+	return fmt.Sprintf("%s-%s-%d", e.ID, shard.Job.ID, shard.Index)
+}
+
+// Compile-time interface check:
+var _ executor.Executor = (*Executor)(nil)
@@ -0,0 +1,213 @@
+//go:build unit || !integration
+
+package nomad
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/bacalhau/pkg/executor"
+	"github.com/filecoin-project/bacalhau/pkg/executor/executortest"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/storage"
+	"github.com/filecoin-project/bacalhau/pkg/system"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeNomad is a Nomad agent that finishes every job it is given with the
+// allocation, or fails to place it if there is none.
+type fakeNomad struct {
+	mu          sync.Mutex
+	jobs        map[string]nomadJob
+	allocation  *nomadAllocation
+	evaluations []nomadEvaluation
+}
+
+func (f *fakeNomad) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	path := req.URL.Path
+	switch {
+	case req.Method == http.MethodPut && path == "/v1/jobs":
+		var body struct{ Job nomadJob }
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.jobs[body.Job.ID] = body.Job
+		_, _ = res.Write([]byte(`{"EvalID":"eval"}`))
+	case req.Method == http.MethodDelete && strings.HasPrefix(path, "/v1/job/"):
+		delete(f.jobs, strings.TrimPrefix(path, "/v1/job/"))
+		_, _ = res.Write([]byte(`{}`))
+	case strings.HasSuffix(path, "/allocations"):
+		var allocations []nomadAllocation
+		if f.allocation != nil {
+			allocations = append(allocations, *f.allocation)
+		}
+		_ = json.NewEncoder(res).Encode(allocations)
+	case strings.HasSuffix(path, "/evaluations"):
+		_ = json.NewEncoder(res).Encode(f.evaluations)
+	case strings.HasPrefix(path, "/v1/client/fs/logs/"):
+		_, _ = res.Write([]byte(req.URL.Query().Get("type") + " of " + strings.TrimPrefix(path, "/v1/client/fs/logs/")))
+	case path == "/v1/status/leader":
+		_, _ = res.Write([]byte(`"127.0.0.1:4647"`))
+	default:
+		http.NotFound(res, req)
+	}
+}
+
+func newTestExecutor(t *testing.T, nomad *fakeNomad) *Executor {
+	nomad.jobs = map[string]nomadJob{}
+	server := httptest.NewServer(nomad)
+	t.Cleanup(server.Close)
+
+	e, err := NewExecutor(
+		context.Background(),
+		executortest.CleanupManager(t),
+		"bacalhau-node",
+		executortest.StorageProvider(),
+		Config{Address: server.URL, NodeName: "client-1"},
+	)
+	require.NoError(t, err)
+	e.pollInterval = 10 * time.Millisecond
+	return e
+}
+
+func finishedAllocation(status string, events ...nomadTaskEvent) *nomadAllocation {
+	return &nomadAllocation{
+		ID:           "alloc-1",
+		ClientStatus: status,
+		TaskStates:   map[string]nomadTaskState{jobTaskName: {State: "dead", Events: events}},
+	}
+}
+
+func TestNewExecutorNeedsNodeName(t *testing.T) {
+	_, err := NewExecutor(context.Background(), system.NewCleanupManager(), "id",
+		storage.NewMappedStorageProvider(nil), Config{})
+	require.Error(t, err)
+}
+
+func TestNomadJob(t *testing.T) {
+	e := newTestExecutor(t, &fakeNomad{})
+	shard := executortest.DockerShard(model.Spec{
+		Docker: model.JobSpecDocker{
+			Image:                "ubuntu",
+			Entrypoint:           []string{"echo", "hello"},
+			EnvironmentVariables: []string{"A=1"},
+			User:                 "1000:1000",
+		},
+		Resources: model.ResourceUsageConfig{CPU: "500m", Memory: "1Gi", GPU: "1"},
+		Outputs:   []model.StorageSpec{{Name: "outputs", Path: "/outputs"}},
+		Scratch: []model.ScratchVolume{
+			{Path: "/tmp", Size: "1Gi", Tmpfs: true},
+			{Path: "/scratch", Size: "1Gi"},
+		},
+	})
+
+	job, err := e.nomadJob(shard, []executor.Mount{
+		{Source: "/data/input", Target: "/inputs", ReadOnly: true},
+		{Source: "/results/outputs", Target: "/outputs"},
+	}, []executor.ScratchVolume{
+		{Spec: shard.Job.Spec.Scratch[0], Size: 1 << 30},
+		{Spec: shard.Job.Spec.Scratch[1], Size: 1 << 30, Dir: "/tmp/bacalhau-scratch-1"},
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, "bacalhau-node-job-id-0", job.ID)
+	require.Equal(t, "batch", job.Type)
+	require.Equal(t, []string{defaultDatacenter}, job.Datacenters)
+	require.Equal(t, "client-1", job.Constraints[0].RTarget)
+	require.Equal(t, 0, job.TaskGroups[0].RestartPolicy.Attempts)
+
+	task := job.TaskGroups[0].Tasks[0]
+	require.Equal(t, "docker", task.Driver)
+	require.Equal(t, "1000:1000", task.User)
+	require.Equal(t, "ubuntu", task.Config["image"])
+	require.Equal(t, "none", task.Config["network_mode"])
+	require.Equal(t, []string{"echo", "hello"}, task.Config["entrypoint"])
+	require.Equal(t, "1", task.Env["A"])
+	require.Contains(t, task.Env, "BACALHAU_JOB_SPEC")
+	require.Equal(t, 500, task.Resources.CPU)
+	require.Equal(t, 1024, task.Resources.MemoryMB)
+	require.Equal(t, []nomadDevice{{Name: gpuDeviceName, Count: 1}}, task.Resources.Devices)
+
+	mounts := map[string]map[string]interface{}{}
+	for _, mount := range task.Config["mounts"].([]map[string]interface{}) {
+		mounts[mount["target"].(string)] = mount
+	}
+	require.Len(t, mounts, 4)
+	require.Equal(t, "/data/input", mounts["/inputs"]["source"])
+	require.Equal(t, true, mounts["/inputs"]["readonly"])
+	require.Equal(t, "/results/outputs", mounts["/outputs"]["source"])
+	require.Equal(t, false, mounts["/outputs"]["readonly"])
+	require.Equal(t, "tmpfs", mounts["/tmp"]["type"])
+	require.Equal(t, uint64(1<<30), mounts["/tmp"]["tmpfs_options"].(map[string]interface{})["size"])
+	require.Equal(t, "/tmp/bacalhau-scratch-1", mounts["/scratch"]["source"])
+}
+
+func TestRunShard(t *testing.T) {
+	nomad := &fakeNomad{allocation: finishedAllocation("complete", nomadTaskEvent{Type: "Terminated", ExitCode: 0})}
+	e := newTestExecutor(t, nomad)
+
+	result, err := e.RunShard(context.Background(), executortest.DockerShard(model.Spec{Docker: model.JobSpecDocker{Image: "ubuntu"}}), t.TempDir())
+	require.NoError(t, err)
+	require.Equal(t, 0, result.ExitCode)
+	require.Equal(t, "stdout of alloc-1", result.STDOUT)
+	require.Equal(t, "stderr of alloc-1", result.STDERR)
+	// the job is purged once it has finished
+	require.Empty(t, nomad.jobs)
+}
+
+func TestRunShardExitCode(t *testing.T) {
+	nomad := &fakeNomad{allocation: finishedAllocation("failed", nomadTaskEvent{Type: "Terminated", ExitCode: 3})}
+	e := newTestExecutor(t, nomad)
+
+	result, err := e.RunShard(context.Background(), executortest.DockerShard(model.Spec{Docker: model.JobSpecDocker{Image: "ubuntu"}}), t.TempDir())
+	require.Error(t, err)
+	require.Equal(t, 3, result.ExitCode)
+	require.Contains(t, result.ErrorMsg, "exit code was not zero: 3")
+}
+
+func TestRunShardDriverFailure(t *testing.T) {
+	nomad := &fakeNomad{allocation: finishedAllocation("failed",
+		nomadTaskEvent{Type: "Driver Failure", DriverError: "failed to pull missing:latest"})}
+	e := newTestExecutor(t, nomad)
+
+	result, err := e.RunShard(context.Background(), executortest.DockerShard(model.Spec{Docker: model.JobSpecDocker{Image: "missing"}}), t.TempDir())
+	require.Error(t, err)
+	require.Contains(t, result.ErrorMsg, "failed to pull missing:latest")
+}
+
+func TestRunShardPlacementFailure(t *testing.T) {
+	nomad := &fakeNomad{evaluations: []nomadEvaluation{{
+		ID:     "eval",
+		Status: "complete",
+		FailedTGAllocs: map[string]nomadAllocationMetric{jobTaskName: {
+			ConstraintFiltered: map[string]int{"${node.unique.name} = client-1": 3},
+		}},
+	}}}
+	e := newTestExecutor(t, nomad)
+
+	result, err := e.RunShard(context.Background(), executortest.DockerShard(model.Spec{Docker: model.JobSpecDocker{Image: "ubuntu"}}), t.TempDir())
+	require.Error(t, err)
+	require.Contains(t, result.ErrorMsg, "could not place the job on node client-1")
+	require.Contains(t, result.ErrorMsg, "filtered 3 nodes")
+}
+
+func TestIsInstalled(t *testing.T) {
+	e := newTestExecutor(t, &fakeNomad{})
+	installed, err := e.IsInstalled(context.Background())
+	require.NoError(t, err)
+	require.True(t, installed)
+
+	e.client.address = "http://127.0.0.1:1"
+	installed, err = e.IsInstalled(context.Background())
+	require.NoError(t, err)
+	require.False(t, installed)
+}
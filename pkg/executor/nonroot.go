@@ -0,0 +1,61 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/filecoin-project/bacalhau/pkg/job"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+)
+
+// IsRootUser returns whether a container runs as root when it's run as the
+// user, a name or UID with an optional group. Containers run as the user of
+// their image when the user is empty, which is root unless it says otherwise.
+func IsRootUser(user string) bool {
+	name, _, _ := strings.Cut(user, ":")
+	return name == "" || name == "root" || strings.Trim(name, "0") == ""
+}
+
+// NonRootExecutor runs the docker jobs that would run as root as a non-root
+// user instead, for nodes whose operators don't allow containers to run as
+// root. Jobs that name a non-root user run as it.
+type NonRootExecutor struct {
+	Executor
+	user string
+}
+
+// VerifyNonRootUser checks that the user is a non-root user that containers
+// can run as.
+func VerifyNonRootUser(user string) error {
+	if err := job.VerifyDockerSpec(model.JobSpecDocker{User: user}); err != nil {
+		return err
+	}
+	if IsRootUser(user) {
+		return fmt.Errorf("%q is not a non-root user", user)
+	}
+	return nil
+}
+
+func NewNonRootExecutor(executor Executor, user string) (*NonRootExecutor, error) {
+	if err := VerifyNonRootUser(user); err != nil {
+		return nil, err
+	}
+	return &NonRootExecutor{Executor: executor, user: user}, nil
+}
+
+func (e *NonRootExecutor) RunShard(
+	ctx context.Context,
+	shard model.JobShard,
+	resultsDir string,
+) (*model.RunCommandResult, error) {
+	if IsRootUser(shard.Job.Spec.Docker.User) {
+		nonRootJob := *shard.Job
+		nonRootJob.Spec.Docker.User = e.user
+		shard.Job = &nonRootJob
+	}
+	return e.Executor.RunShard(ctx, shard, resultsDir)
+}
+
+// Compile-time interface check:
+var _ Executor = (*NonRootExecutor)(nil)
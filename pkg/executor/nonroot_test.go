@@ -0,0 +1,50 @@
+//go:build unit || !integration
+
+package executor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/stretchr/testify/require"
+)
+
+type userExecutor struct {
+	fakeExecutor
+	users []string
+}
+
+func (e *userExecutor) RunShard(_ context.Context, shard model.JobShard, _ string) (*model.RunCommandResult, error) {
+	e.users = append(e.users, shard.Job.Spec.Docker.User)
+	return &model.RunCommandResult{}, nil
+}
+
+func TestIsRootUser(t *testing.T) {
+	for _, user := range []string{"", "root", "0", "00", "root:root", "0:1000", ":1000"} {
+		require.True(t, IsRootUser(user), user)
+	}
+	for _, user := range []string{"nobody", "1000", "1000:0", "10", "rootless"} {
+		require.False(t, IsRootUser(user), user)
+	}
+}
+
+func TestNonRootExecutor(t *testing.T) {
+	_, err := NewNonRootExecutor(&userExecutor{}, "root")
+	require.Error(t, err)
+	_, err = NewNonRootExecutor(&userExecutor{}, "1000:")
+	require.Error(t, err)
+
+	ctx := context.Background()
+	delegate := &userExecutor{}
+	e, err := NewNonRootExecutor(delegate, "65534:65534")
+	require.NoError(t, err)
+	job := &model.Job{ID: "job"}
+	for _, user := range []string{"", "0:0", "1000"} {
+		job.Spec.Docker.User = user
+		_, err = e.RunShard(ctx, model.JobShard{Job: job}, t.TempDir())
+		require.NoError(t, err)
+	}
+	require.Equal(t, []string{"65534:65534", "65534:65534", "1000"}, delegate.users)
+	require.Equal(t, "1000", job.Spec.Docker.User)
+}
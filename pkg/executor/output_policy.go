@@ -0,0 +1,141 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/c2h5oh/datasize"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/system"
+	"github.com/rs/zerolog/log"
+)
+
+// OutputPolicy restricts the results of jobs that read the local directories
+// of a node, e.g. the private datasets of its owner, so that only aggregates
+// of the data are published and never the data itself.
+type OutputPolicy struct {
+	// The most the files of the results can add up to, no limit if 0.
+	MaxSize uint64
+	// The most lines each file of the results can have, no limit if 0.
+	MaxRows int
+	// A program that the owner of the data approves results with, run with
+	// the results directory as its argument. Results are only published if
+	// it exits with 0.
+	Filter string
+}
+
+// IsSet returns true if the policy restricts anything.
+func (p OutputPolicy) IsSet() bool {
+	return p.MaxSize > 0 || p.MaxRows > 0 || p.Filter != ""
+}
+
+// Applies returns true if the policy restricts the results of the job,
+// which is if it reads any local directory.
+func (p OutputPolicy) Applies(spec model.Spec) bool {
+	if !p.IsSet() {
+		return false
+	}
+	for _, volumes := range [][]model.StorageSpec{spec.Inputs, spec.Contexts} {
+		for _, volume := range volumes {
+			if volume.StorageSource == model.StorageSourceLocalDirectory {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Check returns an error if the results directory, including the stdout and
+// stderr of the job, can't be published under the policy.
+func (p OutputPolicy) Check(ctx context.Context, resultsDir string) error {
+	var size uint64
+	err := filepath.WalkDir(resultsDir, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		relative, err := filepath.Rel(resultsDir, filePath)
+		if err != nil {
+			return err
+		}
+		if !entry.Type().IsRegular() {
+			return fmt.Errorf("%s is not a regular file", filepath.ToSlash(relative))
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		size += uint64(info.Size())
+		if p.MaxSize > 0 && size > p.MaxSize {
+			return fmt.Errorf("the results are larger than the %s allowed",
+				datasize.ByteSize(p.MaxSize).HumanReadable())
+		}
+		if p.MaxRows > 0 {
+			rows, err := countRows(filePath)
+			if err != nil {
+				return err
+			}
+			if rows > p.MaxRows {
+				return fmt.Errorf("%s has %d rows, more than the %d allowed", filepath.ToSlash(relative), rows, p.MaxRows)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("results of jobs over local directories can't be published: %w", err)
+	}
+
+	if p.Filter != "" {
+		// the output of the filter may quote the results, so it is only logged
+		result, err := system.RunCommand(ctx, system.Command{
+			Name: p.Filter,
+			Args: []string{resultsDir},
+		})
+		log.Ctx(ctx).Debug().Err(err).Str("Stdout", result.Stdout).Str("Stderr", result.Stderr).Msg("Ran the output filter")
+		var commandErr *system.CommandError
+		if errors.As(err, &commandErr) {
+			// not the error itself, which quotes the stderr of the filter
+			err = commandErr.Err
+		}
+		if err != nil {
+			return fmt.Errorf("results of jobs over local directories can't be published: "+
+				"rejected by the output filter: %w", err)
+		}
+	}
+	return nil
+}
+
+// countRows returns how many lines a file has, counting a last line without
+// a newline.
+func countRows(filePath string) (int, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	rows := 0
+	last := byte('\n')
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := file.Read(buf)
+		if n > 0 {
+			rows += bytes.Count(buf[:n], []byte{'\n'})
+			last = buf[n-1]
+		}
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return 0, err
+		}
+	}
+	if last != '\n' {
+		rows++
+	}
+	return rows, nil
+}
@@ -0,0 +1,42 @@
+//go:build unit || !integration
+
+package executor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutputPolicyApplies(t *testing.T) {
+	local := model.StorageSpec{StorageSource: model.StorageSourceLocalDirectory, URL: "local://census"}
+	ipfs := model.StorageSpec{StorageSource: model.StorageSourceIPFS, CID: "QmTVmC7JBD2ES2qGPqBNVWnX1KeEPNrPGb7rJ8cpFgtefe"}
+	policy := OutputPolicy{MaxRows: 10}
+
+	require.True(t, policy.Applies(model.Spec{Inputs: []model.StorageSpec{ipfs, local}}))
+	require.True(t, policy.Applies(model.Spec{Contexts: []model.StorageSpec{local}}))
+	require.False(t, policy.Applies(model.Spec{Inputs: []model.StorageSpec{ipfs}}))
+	require.False(t, OutputPolicy{}.Applies(model.Spec{Inputs: []model.StorageSpec{local}}))
+}
+
+func TestOutputPolicyCheck(t *testing.T) {
+	ctx := context.Background()
+	resultsDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(resultsDir, "stdout"), []byte("mean age: 41\n"), os.ModePerm))
+	require.NoError(t, os.MkdirAll(filepath.Join(resultsDir, "outputs"), os.ModePerm))
+	require.NoError(t, os.WriteFile(filepath.Join(resultsDir, "outputs", "ages.csv"), []byte("age,count\n30,2\n40,1"), os.ModePerm))
+
+	require.NoError(t, OutputPolicy{MaxSize: 1024, MaxRows: 3}.Check(ctx, resultsDir))
+	require.ErrorContains(t, OutputPolicy{MaxSize: 16}.Check(ctx, resultsDir), "larger than the 16 B allowed")
+	require.ErrorContains(t, OutputPolicy{MaxRows: 2}.Check(ctx, resultsDir), "outputs/ages.csv has 3 rows")
+
+	accept := filepath.Join(t.TempDir(), "accept")
+	require.NoError(t, os.WriteFile(accept, []byte("#!/bin/sh\ntest -f \"$1/outputs/ages.csv\"\n"), 0700))
+	require.NoError(t, OutputPolicy{Filter: accept}.Check(ctx, resultsDir))
+	require.NoError(t, os.Remove(filepath.Join(resultsDir, "outputs", "ages.csv")))
+	require.ErrorContains(t, OutputPolicy{Filter: accept}.Check(ctx, resultsDir), "rejected by the output filter")
+}
@@ -1,6 +1,7 @@
 package executor
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -11,6 +12,8 @@ import (
 	"github.com/filecoin-project/bacalhau/pkg/ipfs"
 	"github.com/filecoin-project/bacalhau/pkg/model"
 	"github.com/filecoin-project/bacalhau/pkg/system"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
 	"go.ptx.dk/multierrgroup"
 	"go.uber.org/multierr"
 )
@@ -122,3 +125,22 @@ func WriteJobResults(resultsDir string, stdout, stderr io.Reader, exitcode int,
 	result.ExitCode = exitcode
 	return result, err
 }
+
+// WriteShardResults writes the results of a shard whose job exited with the
+// exit code like WriteJobResults, failing the shard if the code isn't zero.
+func WriteShardResults(resultsDir string, stdout, stderr io.Reader, exitCode int, err error) (*model.RunCommandResult, error) {
+	if exitCode != 0 {
+		err = multierr.Append(err, fmt.Errorf("exit code was not zero: %d", exitCode))
+	}
+	return WriteJobResults(resultsDir, stdout, stderr, exitCode, err)
+}
+
+// ErrorResult returns the result of a shard that failed to run, with the
+// error as its stderr.
+func ErrorResult(ctx context.Context, msg string, err error) *model.RunCommandResult {
+	log.Ctx(ctx).Debug().Str("msg", msg).Err(err).Msg("Returning error")
+	return &model.RunCommandResult{
+		STDERR:   err.Error(),
+		ErrorMsg: errors.Wrap(err, msg).Error(),
+	}
+}
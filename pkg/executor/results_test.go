@@ -124,3 +124,15 @@ func TestJobResult(t *testing.T) {
 		require.Equal(t, expectedContents, string(actualContents))
 	}
 }
+
+func TestShardResult(t *testing.T) {
+	result, err := WriteShardResults(t.TempDir(), strings.NewReader(""), strings.NewReader(""), 0, nil)
+	require.NoError(t, err)
+	require.Equal(t, "", result.ErrorMsg)
+
+	result, err = WriteShardResults(t.TempDir(), strings.NewReader(""), strings.NewReader("oops"), 3, nil)
+	require.Error(t, err)
+	require.Equal(t, 3, result.ExitCode)
+	require.Equal(t, "oops", result.STDERR)
+	require.Contains(t, result.ErrorMsg, "exit code was not zero: 3")
+}
@@ -0,0 +1,106 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/c2h5oh/datasize"
+	"github.com/filecoin-project/bacalhau/pkg/compute/capacity"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/storage/util"
+)
+
+// ScratchVolume is a scratch volume of a job along with the directory on the
+// node that backs it, if it isn't tmpfs.
+type ScratchVolume struct {
+	Spec model.ScratchVolume
+	// bytes
+	Size uint64
+	Dir  string
+}
+
+// ScratchVolumeSize returns the size of the scratch volume in bytes.
+func ScratchVolumeSize(spec model.ScratchVolume) (uint64, error) {
+	size, err := capacity.ParseBytesString(spec.Size)
+	if err != nil || size == 0 {
+		return 0, fmt.Errorf("scratch volume %s has an invalid size %q", spec.Path, spec.Size)
+	}
+	return size, nil
+}
+
+// PrepareScratchVolumes creates the directories in parentDir that back the
+// scratch volumes of a job that aren't tmpfs, or in the default directory for
+// temporary files if parentDir is empty. The directories are removed by
+// CleanupScratchVolumes, which must be called even if this returns an error.
+func PrepareScratchVolumes(specs []model.ScratchVolume, parentDir string) ([]ScratchVolume, error) {
+	volumes := make([]ScratchVolume, 0, len(specs))
+	for _, spec := range specs {
+		size, err := ScratchVolumeSize(spec)
+		if err != nil {
+			return volumes, err
+		}
+		volume := ScratchVolume{Spec: spec, Size: size}
+		if !spec.Tmpfs {
+			volume.Dir, err = os.MkdirTemp(parentDir, "bacalhau-scratch-")
+			if err != nil {
+				return volumes, err
+			}
+			if err = os.Chmod(volume.Dir, util.OS_ALL_RWX); err != nil {
+				return append(volumes, volume), err
+			}
+		}
+		volumes = append(volumes, volume)
+	}
+	return volumes, nil
+}
+
+// CheckScratchVolumes returns an error if the job wrote more to a scratch
+// volume backed by a directory than its size. Unlike tmpfs volumes, whose
+// size the kernel enforces, they can only be checked once the job has
+// finished.
+func CheckScratchVolumes(volumes []ScratchVolume) error {
+	for _, volume := range volumes {
+		if volume.Dir == "" {
+			continue
+		}
+		used, err := DirSize(volume.Dir)
+		if err != nil {
+			return fmt.Errorf("error checking the size of scratch volume %s: %w", volume.Spec.Path, err)
+		}
+		if used > volume.Size {
+			return fmt.Errorf("the job wrote %s to scratch volume %s, more than its size of %s",
+				datasize.ByteSize(used).HumanReadable(), volume.Spec.Path, datasize.ByteSize(volume.Size).HumanReadable())
+		}
+	}
+	return nil
+}
+
+// CleanupScratchVolumes removes the directories that back the scratch
+// volumes.
+func CleanupScratchVolumes(volumes []ScratchVolume) error {
+	var err error
+	for _, volume := range volumes {
+		if volume.Dir != "" {
+			if removeErr := os.RemoveAll(volume.Dir); removeErr != nil && err == nil {
+				err = removeErr
+			}
+		}
+	}
+	return err
+}
+
+// DirSize returns the total size of the files in the directory.
+func DirSize(dir string) (uint64, error) {
+	var size uint64
+	err := filepath.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			size += uint64(info.Size())
+		}
+		return nil
+	})
+	return size, err
+}
@@ -0,0 +1,47 @@
+//go:build unit || !integration
+
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScratchVolumes(t *testing.T) {
+	parentDir := t.TempDir()
+	volumes, err := PrepareScratchVolumes([]model.ScratchVolume{
+		{Path: "/tmp", Size: "1Mi", Tmpfs: true},
+		{Path: "/scratch", Size: "1Ki"},
+	}, parentDir)
+	require.NoError(t, err)
+	require.Len(t, volumes, 2)
+	require.Empty(t, volumes[0].Dir)
+	require.Equal(t, uint64(1<<20), volumes[0].Size)
+	require.Equal(t, parentDir, filepath.Dir(volumes[1].Dir))
+	require.Equal(t, uint64(1<<10), volumes[1].Size)
+
+	require.NoError(t, os.WriteFile(filepath.Join(volumes[1].Dir, "data"), make([]byte, 1<<10), os.ModePerm))
+	require.NoError(t, CheckScratchVolumes(volumes))
+	require.NoError(t, os.WriteFile(filepath.Join(volumes[1].Dir, "more"), []byte("x"), os.ModePerm))
+	require.ErrorContains(t, CheckScratchVolumes(volumes), "more than its size")
+
+	require.NoError(t, CleanupScratchVolumes(volumes))
+	require.NoDirExists(t, volumes[1].Dir)
+}
+
+func TestPrepareScratchVolumesInvalidSize(t *testing.T) {
+	for _, size := range []string{"", "0", "1Gx"} {
+		volumes, err := PrepareScratchVolumes([]model.ScratchVolume{
+			{Path: "/a", Size: "1Ki"},
+			{Path: "/scratch", Size: size},
+		}, t.TempDir())
+		require.ErrorContains(t, err, "scratch volume /scratch has an invalid size", size)
+		// the volumes prepared before the invalid one are returned to be cleaned up
+		require.Len(t, volumes, 1)
+		require.NoError(t, CleanupScratchVolumes(volumes))
+	}
+}
@@ -0,0 +1,448 @@
+// Package slurm runs docker jobs as Slurm batch jobs, so that HPC centers
+// can offer their clusters to the network without running Docker on their
+// compute nodes.
+//
+// Each shard is submitted with sbatch as a script rendered from a template,
+// which by default runs the image of the job with Apptainer. The compute node
+// prepares the inputs of a job and collects its outputs on its own disk, so
+// its storage path and temp directory must be on a filesystem that is shared
+// with the Slurm compute nodes, at the same paths.
+package slurm
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/c2h5oh/datasize"
+	"github.com/filecoin-project/bacalhau/pkg/compute/capacity"
+	"github.com/filecoin-project/bacalhau/pkg/config"
+	"github.com/filecoin-project/bacalhau/pkg/executor"
+	jobutils "github.com/filecoin-project/bacalhau/pkg/job"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/storage"
+	"github.com/filecoin-project/bacalhau/pkg/storage/util"
+	"github.com/filecoin-project/bacalhau/pkg/system"
+	"github.com/rs/zerolog/log"
+	"go.uber.org/multierr"
+)
+
+const (
+	defaultPollInterval = 5 * time.Second
+
+	stdoutFile   = "stdout"
+	stderrFile   = "stderr"
+	exitCodeFile = "exitcode"
+)
+
+// DefaultScriptTemplate runs the image of the job with Apptainer, which can
+// run docker images without root or a daemon.
+const DefaultScriptTemplate = `#!/bin/bash
+apptainer {{if .Entrypoint}}exec{{else}}run{{end}} --containall --cleanenv \
+{{- range .Env}}
+  --env {{quote .}} \
+{{- end}}
+{{- range .Mounts}}
+  --bind {{quote .Source}}:{{quote .Target}}{{if .ReadOnly}}:ro{{end}} \
+{{- end}}
+{{- if .WorkingDirectory}}
+  --pwd {{quote .WorkingDirectory}} \
+{{- end}}
+  {{quote (print "docker://" .Image)}}{{range .Entrypoint}} {{quote .}}{{end}}
+echo $? > {{quote .ExitCodeFile}}
+`
+
+type Config struct {
+	// The partition to submit jobs to, the default partition if empty.
+	Partition string
+	// The account to charge jobs to, the default account if empty.
+	Account string
+	// More sbatch options to submit jobs with, e.g. --qos=bacalhau.
+	ExtraArgs []string
+	// The text/template of the batch script of a job, DefaultScriptTemplate
+	// if empty. It is given a ScriptData.
+	ScriptTemplate string
+}
+
+// ScriptData is what the script template of a job is rendered with.
+type ScriptData struct {
+	Image            string
+	Entrypoint       []string
+	WorkingDirectory string
+	// KEY=VALUE environment variables of the job.
+	Env    []string
+	Mounts []executor.Mount
+	// The file the script must write the exit code of the job to, as Slurm
+	// doesn't keep it unless accounting is set up.
+	ExitCodeFile string
+}
+
+type Executor struct {
+	// used to tell apart the jobs of compute nodes sharing a Slurm cluster
+	ID string
+
+	executor.StorageExecutor
+
+	config       Config
+	template     *template.Template
+	pollInterval time.Duration
+
+	// the Slurm job IDs of the jobs that have been submitted, by job name
+	mu   sync.Mutex
+	jobs map[string]string
+}
+
+func NewExecutor(
+	ctx context.Context,
+	cm *system.CleanupManager,
+	id string,
+	storageProvider storage.StorageProvider,
+	executorConfig Config,
+) (*Executor, error) {
+	scriptTemplate := executorConfig.ScriptTemplate
+	if scriptTemplate == "" {
+		scriptTemplate = DefaultScriptTemplate
+	}
+	tmpl, err := template.New("sbatch").Funcs(template.FuncMap{"quote": quote}).Parse(scriptTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Slurm script template: %w", err)
+	}
+
+	e := &Executor{
+		ID:              id,
+		StorageExecutor: executor.StorageExecutor{StorageProvider: storageProvider},
+		config:          executorConfig,
+		template:        tmpl,
+		pollInterval:    defaultPollInterval,
+		jobs:            map[string]string{},
+	}
+
+	cm.RegisterCallback(func() error {
+		e.cleanupAll(ctx)
+		return nil
+	})
+
+	return e, nil
+}
+
+// IsInstalled checks if the Slurm controller can be reached.
+func (e *Executor) IsInstalled(ctx context.Context) (bool, error) {
+	_, err := e.run(ctx, "squeue", "--noheader", "--me")
+	return err == nil, nil
+}
+
+//nolint:funlen
+func (e *Executor) RunShard(
+	ctx context.Context,
+	shard model.JobShard,
+	jobResultsDir string,
+) (*model.RunCommandResult, error) {
+	ctx, span := system.GetTracer().Start(ctx, "pkg/executor/slurm.RunShard")
+	defer span.End()
+	system.AddJobIDFromBaggageToSpan(ctx, span)
+	system.AddNodeIDFromBaggageToSpan(ctx, span)
+
+	if err := jobutils.VerifyDockerSpec(shard.Job.Spec.Docker); err != nil {
+		return &model.RunCommandResult{ErrorMsg: err.Error()}, err
+	}
+	if shard.Job.Spec.Docker.Stdin != "" {
+		err := fmt.Errorf("jobs can't read stdin in Slurm")
+		return &model.RunCommandResult{ErrorMsg: err.Error()}, err
+	}
+	// apptainer runs containers as the user that submits the batch job
+	if shard.Job.Spec.Docker.User != "" {
+		err := fmt.Errorf("jobs run as the Slurm user, not %q", shard.Job.Spec.Docker.User)
+		return &model.RunCommandResult{ErrorMsg: err.Error()}, err
+	}
+
+	inputs, err := e.PrepareInputs(ctx, shard)
+	if err != nil {
+		return &model.RunCommandResult{}, err
+	}
+	outputs, err := executor.PrepareOutputs(ctx, shard, jobResultsDir)
+	if err != nil {
+		return &model.RunCommandResult{ErrorMsg: err.Error()}, err
+	}
+
+	// the script and logs of the job are kept out of the results directory,
+	// on the storage path that the Slurm nodes share
+	jobDir, err := os.MkdirTemp(config.GetStoragePath(), "bacalhau-slurm-")
+	if err != nil {
+		return &model.RunCommandResult{ErrorMsg: err.Error()}, err
+	}
+	defer func() {
+		if removeErr := os.RemoveAll(jobDir); removeErr != nil {
+			log.Ctx(ctx).Warn().Err(removeErr).Msg("failed to remove Slurm job directory")
+		}
+	}()
+	if err = os.Chmod(jobDir, util.OS_ALL_RWX); err != nil {
+		return &model.RunCommandResult{ErrorMsg: err.Error()}, err
+	}
+
+	// there is no tmpfs to give jobs, so all scratch volumes are on the
+	// storage path that the Slurm nodes share
+	scratchSpecs := make([]model.ScratchVolume, 0, len(shard.Job.Spec.Scratch))
+	for _, scratch := range shard.Job.Spec.Scratch {
+		scratch.Tmpfs = false
+		scratchSpecs = append(scratchSpecs, scratch)
+	}
+	scratchVolumes, err := executor.PrepareScratchVolumes(scratchSpecs, config.GetStoragePath())
+	defer func() {
+		if cleanupErr := executor.CleanupScratchVolumes(scratchVolumes); cleanupErr != nil {
+			log.Ctx(ctx).Warn().Err(cleanupErr).Msg("failed to remove scratch volumes")
+		}
+	}()
+	if err != nil {
+		return &model.RunCommandResult{ErrorMsg: err.Error()}, err
+	}
+	mounts := inputs
+	mounts = append(mounts, outputs...)
+	for _, scratch := range scratchVolumes {
+		mounts = append(mounts, executor.Mount{Source: scratch.Dir, Target: scratch.Spec.Path})
+	}
+
+	scriptPath, err := e.writeScript(shard, mounts, jobDir)
+	if err != nil {
+		return &model.RunCommandResult{ErrorMsg: err.Error()}, err
+	}
+
+	jobName := e.jobName(shard)
+	slurmJobID, err := e.submit(ctx, shard, jobName, jobDir, scriptPath)
+	if err != nil {
+		return executor.ErrorResult(ctx, "failed to submit Slurm job: ", err), err
+	}
+	defer e.cleanupJob(ctx, jobName)
+
+	lastState, err := e.waitForJob(ctx, slurmJobID)
+	if err != nil {
+		return executor.ErrorResult(ctx, "failed to run Slurm job: ", err), err
+	}
+
+	var jobError error
+	exitCode := 0
+	data, err := os.ReadFile(filepath.Join(jobDir, exitCodeFile))
+	if err == nil {
+		exitCode, err = strconv.Atoi(strings.TrimSpace(string(data)))
+	}
+	if err != nil {
+		// the script didn't finish, e.g. it ran out of time or was cancelled
+		jobError = fmt.Errorf("slurm job %s did not run to completion, it was last %s", slurmJobID, lastState)
+	}
+	// Slurm doesn't enforce the size of scratch volumes
+	scratchErr := executor.CheckScratchVolumes(scratchVolumes)
+
+	stdout, stdoutErr := os.Open(filepath.Join(jobDir, stdoutFile))
+	if stdoutErr != nil {
+		return &model.RunCommandResult{ErrorMsg: stdoutErr.Error()}, stdoutErr
+	}
+	defer stdout.Close()
+	stderr, stderrErr := os.Open(filepath.Join(jobDir, stderrFile))
+	if stderrErr != nil {
+		return &model.RunCommandResult{ErrorMsg: stderrErr.Error()}, stderrErr
+	}
+	defer stderr.Close()
+
+	return executor.WriteShardResults(
+		jobResultsDir,
+		stdout,
+		stderr,
+		exitCode,
+		multierr.Combine(jobError, scratchErr),
+	)
+}
+
+func (e *Executor) CancelShard(ctx context.Context, shard model.JobShard) error {
+	_, err := e.run(ctx, "scancel", "--name", e.jobName(shard))
+	return err
+}
+
+// writeScript writes the batch script of the shard with the mounts to the
+// job directory, and returns its path.
+func (e *Executor) writeScript(shard model.JobShard, mounts []executor.Mount, jobDir string) (string, error) {
+	data := ScriptData{
+		Image:            shard.Job.Spec.Docker.Image,
+		Entrypoint:       shard.Job.Spec.Docker.Entrypoint,
+		WorkingDirectory: shard.Job.Spec.Docker.WorkingDirectory,
+		Mounts:           mounts,
+		ExitCodeFile:     filepath.Join(jobDir, exitCodeFile),
+	}
+
+	jsonJobSpec, err := model.JSONMarshalWithMax(shard.Job.Spec)
+	if err != nil {
+		return "", err
+	}
+	data.Env = append(data.Env, shard.Job.Spec.Docker.EnvironmentVariables...)
+	data.Env = append(data.Env, fmt.Sprintf("BACALHAU_JOB_SPEC=%s", string(jsonJobSpec)))
+
+	var script bytes.Buffer
+	if err = e.template.Execute(&script, data); err != nil {
+		return "", fmt.Errorf("could not render the Slurm script template: %w", err)
+	}
+	scriptPath := filepath.Join(jobDir, "job.sh")
+	if err = os.WriteFile(scriptPath, script.Bytes(), util.OS_USER_RWX); err != nil {
+		return "", err
+	}
+	return scriptPath, nil
+}
+
+// submit submits the script with sbatch and returns the Slurm job ID.
+func (e *Executor) submit(ctx context.Context, shard model.JobShard, jobName, jobDir, scriptPath string) (string, error) {
+	args := []string{
+		"--parsable",
+		"--job-name", jobName,
+		"--output", filepath.Join(jobDir, stdoutFile),
+		"--error", filepath.Join(jobDir, stderrFile),
+		"--chdir", jobDir,
+		// the requester retries jobs on other nodes, so Slurm shouldn't
+		"--no-requeue",
+	}
+	if e.config.Partition != "" {
+		args = append(args, "--partition", e.config.Partition)
+	}
+	if e.config.Account != "" {
+		args = append(args, "--account", e.config.Account)
+	}
+
+	resourceRequirements := capacity.ParseResourceUsageConfig(shard.Job.Spec.Resources)
+	if resourceRequirements.CPU > 0 {
+		args = append(args, "--cpus-per-task", strconv.Itoa(int(math.Ceil(resourceRequirements.CPU))))
+	}
+	if resourceRequirements.Memory > 0 {
+		megabytes := int(math.Ceil(datasize.ByteSize(resourceRequirements.Memory).MBytes()))
+		args = append(args, "--mem", fmt.Sprintf("%dM", megabytes))
+	}
+	if resourceRequirements.GPU > 0 {
+		args = append(args, "--gres", fmt.Sprintf("gpu:%d", uint64(resourceRequirements.GPU)))
+	}
+	if timeout := shard.Job.Spec.GetTimeout(); timeout > 0 {
+		args = append(args, "--time", strconv.Itoa(int(math.Ceil(timeout.Minutes()))))
+	}
+	args = append(args, e.config.ExtraArgs...)
+	args = append(args, scriptPath)
+
+	output, err := e.run(ctx, "sbatch", args...)
+	if err != nil {
+		return "", err
+	}
+	// the job ID is followed by the cluster name on federated clusters
+	slurmJobID, _, _ := strings.Cut(strings.TrimSpace(output), ";")
+	if slurmJobID == "" {
+		return "", fmt.Errorf("sbatch did not return a job ID")
+	}
+
+	e.mu.Lock()
+	e.jobs[jobName] = slurmJobID
+	e.mu.Unlock()
+	return slurmJobID, nil
+}
+
+// waitForJob waits for the job to leave the queue and returns the last state
+// it was seen in.
+func (e *Executor) waitForJob(ctx context.Context, slurmJobID string) (string, error) {
+	ticker := time.NewTicker(e.pollInterval)
+	defer ticker.Stop()
+	lastState := "PENDING"
+	for {
+		output, err := e.run(ctx, "squeue", "--noheader", "--jobs", slurmJobID, "--format", "%T")
+		// squeue fails for jobs that have left the queue on some versions
+		state := strings.TrimSpace(output)
+		if err != nil || state == "" {
+			return lastState, nil
+		}
+		lastState = state
+
+		select {
+		case <-ctx.Done():
+			return lastState, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (e *Executor) run(ctx context.Context, name string, args ...string) (string, error) {
+	result, err := system.RunCommand(ctx, system.Command{
+		Name:       name,
+		Args:       args,
+		InheritEnv: slurmEnv(),
+	})
+	return result.Stdout, err
+}
+
+// slurmEnvPrefixes are the prefixes of the variables that configure Slurm
+// and Apptainer, such as SLURM_CONF, which the Slurm commands are run with.
+var slurmEnvPrefixes = []string{"SLURM_", "APPTAINER_", "SINGULARITY_"}
+
+// slurmEnv returns the names of the variables the Slurm commands are run
+// with. sbatch passes them on to the batch scripts, so the rest of the
+// environment of the node, e.g. its credentials, is left out.
+func slurmEnv() []string {
+	env := append([]string{}, system.DefaultCommandEnv...)
+	for _, variable := range os.Environ() {
+		name, _, _ := strings.Cut(variable, "=")
+		for _, prefix := range slurmEnvPrefixes {
+			if strings.HasPrefix(name, prefix) {
+				env = append(env, name)
+				break
+			}
+		}
+	}
+	return env
+}
+
+func (e *Executor) cleanupJob(ctx context.Context, jobName string) {
+	e.mu.Lock()
+	slurmJobID := e.jobs[jobName]
+	delete(e.jobs, jobName)
+	e.mu.Unlock()
+	if config.ShouldKeepStack() {
+		return
+	}
+
+	// the job may still be queued or running if the job context is done, e.g.
+	// when the job timed out, so cancel it with a context that isn't
+	if _, err := e.run(context.Background(), "scancel", slurmJobID); err != nil {
+		log.Ctx(ctx).Debug().Err(err).Msgf("Slurm cancel job error")
+	}
+}
+
+func (e *Executor) cleanupAll(ctx context.Context) {
+	if config.ShouldKeepStack() {
+		return
+	}
+
+	e.mu.Lock()
+	slurmJobIDs := make([]string, 0, len(e.jobs))
+	for _, slurmJobID := range e.jobs {
+		slurmJobIDs = append(slurmJobIDs, slurmJobID)
+	}
+	e.mu.Unlock()
+	if len(slurmJobIDs) == 0 {
+		return
+	}
+
+	log.Ctx(ctx).Debug().Msgf("Cleaning up all bacalhau Slurm jobs for executor %s...", e.ID)
+	if _, err := e.run(context.Background(), "scancel", slurmJobIDs...); err != nil {
+		log.Ctx(ctx).Err(err).Msgf("Non-critical error cleaning up Slurm jobs")
+	}
+	log.Ctx(ctx).Debug().Msgf("Finished cleaning up all bacalhau Slurm jobs for executor %s", e.ID)
+}
+
+func (e *Executor) jobName(shard model.JobShard) string {
+	return fmt.Sprintf("%s-%s-%d", e.ID, shard.Job.ID, shard.Index)
+}
+
+// quote quotes the string for the shell.
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// Compile-time interface check:
+var _ executor.Executor = (*Executor)(nil)
@@ -0,0 +1,199 @@
+//go:build unit || (!integration && !windows)
+
+package slurm
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/filecoin-project/bacalhau/pkg/executor"
+	"github.com/filecoin-project/bacalhau/pkg/executor/executortest"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/storage"
+	"github.com/filecoin-project/bacalhau/pkg/system"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSlurm puts sbatch, squeue and scancel commands on the PATH that run
+// batch scripts straight away, and record how they were called in the
+// returned directory.
+func fakeSlurm(t *testing.T) (string, string) {
+	dir, records := t.TempDir(), t.TempDir()
+	commands := map[string]string{
+		"sbatch": `echo "$@" > "$SLURM_FAKE_RECORDS/sbatch"
+while [ $# -gt 1 ]; do
+  case "$1" in
+    --output) out=$2; shift;;
+    --error) err=$2; shift;;
+  esac
+  shift
+done
+sh "$1" > "$out" 2> "$err"
+echo "42;cluster"`,
+		"squeue":  `cat "$SLURM_FAKE_RECORDS/squeue" 2> /dev/null || true`,
+		"scancel": `echo "$@" >> "$SLURM_FAKE_RECORDS/scancel"`,
+	}
+	for name, script := range commands {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script+"\n"), 0755))
+	}
+	t.Setenv("SLURM_FAKE_RECORDS", records)
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("BACALHAU_STORAGE_PATH", t.TempDir())
+	return dir, records
+}
+
+func newTestExecutor(t *testing.T, executorConfig Config) *Executor {
+	e, err := NewExecutor(
+		context.Background(),
+		executortest.CleanupManager(t),
+		"bacalhau-node",
+		executortest.StorageProvider(),
+		executorConfig,
+	)
+	require.NoError(t, err)
+	return e
+}
+
+func readFile(t *testing.T, path string) string {
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return strings.TrimSpace(string(data))
+}
+
+func TestNewExecutorInvalidTemplate(t *testing.T) {
+	_, err := NewExecutor(context.Background(), system.NewCleanupManager(), "id",
+		storage.NewMappedStorageProvider(nil), Config{ScriptTemplate: "{{.Missing"})
+	require.Error(t, err)
+}
+
+func TestDefaultScript(t *testing.T) {
+	e := newTestExecutor(t, Config{})
+	jobDir := t.TempDir()
+	shard := executortest.DockerShard(model.Spec{
+		Docker: model.JobSpecDocker{
+			Image:                "ubuntu",
+			Entrypoint:           []string{"echo", "it's"},
+			EnvironmentVariables: []string{"A=1"},
+			WorkingDirectory:     "/work",
+		},
+	})
+
+	scriptPath, err := e.writeScript(shard, []executor.Mount{
+		{Source: "/data/input", Target: "/inputs", ReadOnly: true},
+		{Source: "/results/outputs", Target: "/outputs"},
+	}, jobDir)
+	require.NoError(t, err)
+
+	script := readFile(t, scriptPath)
+	require.Contains(t, script, "apptainer exec --containall --cleanenv")
+	require.Contains(t, script, "--env 'A=1'")
+	require.Contains(t, script, "--env 'BACALHAU_JOB_SPEC=")
+	require.Contains(t, script, "--bind '/data/input':'/inputs':ro")
+	require.Contains(t, script, "--bind '/results/outputs':'/outputs' ")
+	require.Contains(t, script, "--pwd '/work'")
+	require.Contains(t, script, `'docker://ubuntu' 'echo' 'it'\''s'`)
+	require.Contains(t, script, "echo $? > '"+filepath.Join(jobDir, exitCodeFile)+"'")
+}
+
+func TestRunShard(t *testing.T) {
+	_, records := fakeSlurm(t)
+	e := newTestExecutor(t, Config{
+		Partition:      "batch",
+		Account:        "science",
+		ExtraArgs:      []string{"--qos=bacalhau"},
+		ScriptTemplate: "echo hello {{.Image}}\necho world >&2\necho 0 > {{quote .ExitCodeFile}}\n",
+	})
+
+	result, err := e.RunShard(context.Background(), executortest.DockerShard(model.Spec{
+		Docker:    model.JobSpecDocker{Image: "ubuntu"},
+		Resources: model.ResourceUsageConfig{CPU: "500m", Memory: "1Gi", GPU: "2"},
+		Timeout:   90,
+	}), t.TempDir())
+	require.NoError(t, err)
+	require.Equal(t, 0, result.ExitCode)
+	require.Equal(t, "hello ubuntu\n", result.STDOUT)
+	require.Equal(t, "world\n", result.STDERR)
+
+	sbatch := readFile(t, filepath.Join(records, "sbatch"))
+	for _, arg := range []string{
+		"--parsable", "--job-name bacalhau-node-job-id-0", "--no-requeue",
+		"--partition batch", "--account science", "--cpus-per-task 1", "--mem 1024M",
+		"--gres gpu:2", "--time 2", "--qos=bacalhau",
+	} {
+		require.Contains(t, sbatch, arg)
+	}
+	// the job is cancelled in case it is still queued
+	require.Equal(t, "42", readFile(t, filepath.Join(records, "scancel")))
+	require.Empty(t, e.jobs)
+}
+
+func TestRunShardExitCode(t *testing.T) {
+	fakeSlurm(t)
+	e := newTestExecutor(t, Config{ScriptTemplate: "echo 3 > {{quote .ExitCodeFile}}\n"})
+
+	result, err := e.RunShard(context.Background(), executortest.DockerShard(model.Spec{Docker: model.JobSpecDocker{Image: "ubuntu"}}), t.TempDir())
+	require.Error(t, err)
+	require.Equal(t, 3, result.ExitCode)
+	require.Contains(t, result.ErrorMsg, "exit code was not zero: 3")
+}
+
+func TestRunShardNotCompleted(t *testing.T) {
+	fakeSlurm(t)
+	e := newTestExecutor(t, Config{ScriptTemplate: "exit 1\n"})
+
+	result, err := e.RunShard(context.Background(), executortest.DockerShard(model.Spec{Docker: model.JobSpecDocker{Image: "ubuntu"}}), t.TempDir())
+	require.Error(t, err)
+	require.Contains(t, result.ErrorMsg, "slurm job 42 did not run to completion, it was last PENDING")
+}
+
+func TestRunShardScratchTooLarge(t *testing.T) {
+	fakeSlurm(t)
+	e := newTestExecutor(t, Config{
+		ScriptTemplate: "{{range .Mounts}}head -c 2048 /dev/zero > {{quote .Source}}/data\n{{end}}" +
+			"echo 0 > {{quote .ExitCodeFile}}\n",
+	})
+
+	// tmpfs volumes are on disk too, as Slurm has no tmpfs to give jobs
+	result, err := e.RunShard(context.Background(), executortest.DockerShard(model.Spec{
+		Docker:  model.JobSpecDocker{Image: "ubuntu"},
+		Scratch: []model.ScratchVolume{{Path: "/scratch", Size: "1Ki", Tmpfs: true}},
+	}), t.TempDir())
+	require.Error(t, err)
+	require.Equal(t, 0, result.ExitCode)
+	require.Contains(t, result.ErrorMsg, "more than its size")
+}
+
+func TestRunShardSubmitFailure(t *testing.T) {
+	slurmDir, _ := fakeSlurm(t)
+	require.NoError(t, os.WriteFile(filepath.Join(slurmDir, "sbatch"),
+		[]byte("#!/bin/sh\necho 'invalid partition specified' >&2\nexit 1\n"), 0755))
+	e := newTestExecutor(t, Config{Partition: "missing"})
+
+	result, err := e.RunShard(context.Background(), executortest.DockerShard(model.Spec{Docker: model.JobSpecDocker{Image: "ubuntu"}}), t.TempDir())
+	require.Error(t, err)
+	require.Contains(t, result.ErrorMsg, "invalid partition specified")
+}
+
+func TestCancelShard(t *testing.T) {
+	_, records := fakeSlurm(t)
+	e := newTestExecutor(t, Config{})
+
+	require.NoError(t, e.CancelShard(context.Background(), executortest.DockerShard(model.Spec{})))
+	require.Equal(t, "--name bacalhau-node-job-id-0", readFile(t, filepath.Join(records, "scancel")))
+}
+
+func TestIsInstalled(t *testing.T) {
+	slurmDir, _ := fakeSlurm(t)
+	e := newTestExecutor(t, Config{})
+	installed, err := e.IsInstalled(context.Background())
+	require.NoError(t, err)
+	require.True(t, installed)
+
+	require.NoError(t, os.WriteFile(filepath.Join(slurmDir, "squeue"), []byte("#!/bin/sh\nexit 1\n"), 0755))
+	installed, err = e.IsInstalled(context.Background())
+	require.NoError(t, err)
+	require.False(t, installed)
+}
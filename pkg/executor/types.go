@@ -2,6 +2,7 @@ package executor
 
 import (
 	"context"
+	"io"
 
 	"github.com/filecoin-project/bacalhau/pkg/model"
 )
@@ -42,3 +43,21 @@ type Executor interface {
 		shard model.JobShard,
 	) error
 }
+
+// GarbageCollector is implemented by executors that leave something of the
+// shards they run behind when the node stops without cleaning up after them,
+// such as when it crashes while running them.
+type GarbageCollector interface {
+	// CollectGarbage removes what is left of the shards of jobs the node no
+	// longer runs, which running says of a job ID, and returns how much it
+	// removed.
+	CollectGarbage(ctx context.Context, running func(jobID string) bool) (int, error)
+}
+
+// DebugSessions attaches shells to the environments that executors keep of
+// the failed shards of jobs that ask for it, so that clients can debug them.
+type DebugSessions interface {
+	// AttachShell runs a shell in the environment kept of the shard, which
+	// reads from and writes to the terminal until either of them exits.
+	AttachShell(ctx context.Context, jobID string, shardIndex int, terminal io.ReadWriter) error
+}
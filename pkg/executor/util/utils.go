@@ -2,19 +2,37 @@ package util
 
 import (
 	"context"
+	"fmt"
+	"strings"
+	"time"
 
+	"github.com/filecoin-project/bacalhau/pkg/config"
+	dockerutils "github.com/filecoin-project/bacalhau/pkg/docker"
 	"github.com/filecoin-project/bacalhau/pkg/executor"
 	"github.com/filecoin-project/bacalhau/pkg/executor/docker"
+	"github.com/filecoin-project/bacalhau/pkg/executor/firecracker"
+	"github.com/filecoin-project/bacalhau/pkg/executor/kubernetes"
 	"github.com/filecoin-project/bacalhau/pkg/executor/language"
+	"github.com/filecoin-project/bacalhau/pkg/executor/nomad"
 	noop_executor "github.com/filecoin-project/bacalhau/pkg/executor/noop"
 	pythonwasm "github.com/filecoin-project/bacalhau/pkg/executor/python_wasm"
+	"github.com/filecoin-project/bacalhau/pkg/executor/slurm"
 	"github.com/filecoin-project/bacalhau/pkg/executor/wasm"
+	"github.com/filecoin-project/bacalhau/pkg/ipfs"
 	"github.com/filecoin-project/bacalhau/pkg/model"
 	"github.com/filecoin-project/bacalhau/pkg/storage"
+	"github.com/filecoin-project/bacalhau/pkg/storage/cloud"
+	"github.com/filecoin-project/bacalhau/pkg/storage/cloud/azureblob"
+	"github.com/filecoin-project/bacalhau/pkg/storage/cloud/gcs"
 	"github.com/filecoin-project/bacalhau/pkg/storage/combo"
+	filecoinretrieval "github.com/filecoin-project/bacalhau/pkg/storage/filecoin_retrieval"
 	filecoinunsealed "github.com/filecoin-project/bacalhau/pkg/storage/filecoin_unsealed"
+	"github.com/filecoin-project/bacalhau/pkg/storage/huggingface"
+	"github.com/filecoin-project/bacalhau/pkg/storage/inline"
 	apicopy "github.com/filecoin-project/bacalhau/pkg/storage/ipfs_apicopy"
+	"github.com/filecoin-project/bacalhau/pkg/storage/localdirectory"
 	noop_storage "github.com/filecoin-project/bacalhau/pkg/storage/noop"
+	"github.com/filecoin-project/bacalhau/pkg/storage/transform"
 	"github.com/filecoin-project/bacalhau/pkg/storage/url/urldownload"
 	"github.com/filecoin-project/bacalhau/pkg/system"
 )
@@ -23,12 +41,80 @@ type StandardStorageProviderOptions struct {
 	IPFSMultiaddress     string
 	FilecoinUnsealedPath string
 	DownloadPath         string
+	// Retrieves inputs from Filecoin storage providers when set.
+	FilecoinRetrieval *filecoinretrieval.StorageConfig
+	// How to authenticate to Azure Blob Storage and Google Cloud Storage.
+	AzureBlob azureblob.Config
+	GCS       gcs.Config
+	// How to download and cache models from the Hugging Face Hub.
+	HuggingFace huggingface.StorageConfig
+	// Decrypts the data keys of inputs with the decrypt transform.
+	Decrypter transform.Decrypter
+	// The directories on the node that jobs can read, e.g. private datasets.
+	LocalDirectories localdirectory.StorageConfig
+}
+
+// DockerBackend is a runtime that docker jobs can run on.
+type DockerBackend string
+
+const (
+	DockerBackendDocker      DockerBackend = "docker"
+	DockerBackendPodman      DockerBackend = "podman"
+	DockerBackendKubernetes  DockerBackend = "kubernetes"
+	DockerBackendNomad       DockerBackend = "nomad"
+	DockerBackendSlurm       DockerBackend = "slurm"
+	DockerBackendFirecracker DockerBackend = "firecracker"
+)
+
+func DockerBackends() []DockerBackend {
+	return []DockerBackend{
+		DockerBackendDocker,
+		DockerBackendPodman,
+		DockerBackendKubernetes,
+		DockerBackendNomad,
+		DockerBackendSlurm,
+		DockerBackendFirecracker,
+	}
+}
+
+func ParseDockerBackend(s string) (DockerBackend, error) {
+	for _, backend := range DockerBackends() {
+		if string(backend) == strings.ToLower(strings.TrimSpace(s)) {
+			return backend, nil
+		}
+	}
+	return "", fmt.Errorf("unknown docker executor %q, expected one of %v", s, DockerBackends())
 }
 
 type StandardExecutorOptions struct {
 	DockerID   string
 	IsBadActor bool
 	Storage    StandardStorageProviderOptions
+	// Runs docker jobs as Kubernetes pods instead of on the Docker daemon when set.
+	Kubernetes *kubernetes.Config
+	// Runs docker jobs as Nomad jobs instead of on the Docker daemon when set.
+	Nomad *nomad.Config
+	// Runs docker jobs as Slurm batch jobs instead of on the Docker daemon when set.
+	Slurm *slurm.Config
+	// Runs docker jobs in Firecracker microVMs instead of containers when set.
+	Firecracker *firecracker.Config
+	// The socket of the Docker compatible API of podman, docker.DefaultPodmanHost
+	// if empty.
+	PodmanHost string
+	// The runtimes docker jobs run on, in order of preference. Jobs run on the
+	// first one that is installed when they start. If empty, jobs run on the
+	// one whose config is set, or the Docker daemon.
+	DockerBackends []DockerBackend
+	// The user, a UID with an optional GID, that docker jobs which would run
+	// as root run as instead when set. Jobs on Slurm run as the Slurm user,
+	// and jobs in Firecracker as root in their VM.
+	DockerNonRootUser string
+	// The seccomp and AppArmor profiles, pids limit and ulimits of the
+	// containers of docker jobs on the Docker daemon and podman.
+	DockerHardening docker.HardeningConfig
+	// How long the environment of failed shards of docker jobs that ask for
+	// it is kept for their clients to debug, on the Docker daemon only.
+	DockerDebugSessionTimeout time.Duration
 }
 
 func NewStandardStorageProvider(
@@ -51,6 +137,35 @@ func NewStandardStorageProvider(
 		return nil, err
 	}
 
+	azureBlobStorage, err := cloud.NewStorage(cm, "azureblob", azureblob.NewStore(options.AzureBlob))
+	if err != nil {
+		return nil, err
+	}
+
+	gcsStore, err := gcs.NewStore(ctx, options.GCS)
+	if err != nil {
+		return nil, err
+	}
+	gcsStorage, err := cloud.NewStorage(cm, "gcs", gcsStore)
+	if err != nil {
+		return nil, err
+	}
+
+	huggingFaceStorage, err := huggingface.NewStorage(options.HuggingFace)
+	if err != nil {
+		return nil, err
+	}
+
+	localDirectoryStorage, err := localdirectory.NewStorage(options.LocalDirectories)
+	if err != nil {
+		return nil, err
+	}
+
+	inlineStorage, err := inline.NewStorage(cm)
+	if err != nil {
+		return nil, err
+	}
+
 	var useIPFSDriver storage.Storage = ipfsAPICopyStorage
 
 	// if we are using a FilecoinUnsealedPath then construct a combo
@@ -88,11 +203,71 @@ func NewStandardStorageProvider(
 		useIPFSDriver = comboDriver
 	}
 
-	return storage.NewMappedStorageProvider(map[model.StorageSourceType]storage.Storage{
-		model.StorageSourceIPFS:             useIPFSDriver,
+	storages := map[model.StorageSourceType]storage.Storage{
 		model.StorageSourceURLDownload:      urlDownloadStorage,
 		model.StorageSourceFilecoinUnsealed: filecoinUnsealedStorage,
-	}), nil
+		model.StorageSourceAzureBlob:        azureBlobStorage,
+		model.StorageSourceGCS:              gcsStorage,
+		model.StorageSourceHuggingFace:      huggingFaceStorage,
+		model.StorageSourceLocalDirectory:   localDirectoryStorage,
+		model.StorageSourceInline:           inlineStorage,
+	}
+
+	// if we can retrieve from Filecoin then inputs that can't be found over
+	// IPFS are retrieved from the storage providers that store them
+	if options.FilecoinRetrieval != nil {
+		filecoinRetrievalStorage, err := filecoinretrieval.NewStorage(ctx, cm, *options.FilecoinRetrieval)
+		if err != nil {
+			return nil, err
+		}
+		storages[model.StorageSourceFilecoin] = filecoinRetrievalStorage
+
+		ipfsDriver := useIPFSDriver
+		comboDriver, err := combo.NewStorage(
+			cm,
+			func(ctx context.Context) ([]storage.Storage, error) {
+				return []storage.Storage{
+					ipfsDriver,
+					filecoinRetrievalStorage,
+				}, nil
+			},
+			func(ctx context.Context, spec model.StorageSpec) (storage.Storage, error) {
+				retrieved, err := filecoinRetrievalStorage.HasStorageLocally(ctx, spec)
+				if err != nil {
+					return ipfsDriver, err
+				}
+				if retrieved || !availableOverIPFS(ctx, ipfsAPICopyStorage.IPFSClient, spec.CID) {
+					return filecoinRetrievalStorage, nil
+				}
+				return ipfsDriver, nil
+			},
+			func(ctx context.Context) (storage.Storage, error) {
+				return ipfsDriver, nil
+			},
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		useIPFSDriver = comboDriver
+	}
+	storages[model.StorageSourceIPFS] = useIPFSDriver
+
+	// apply the transforms of inputs once they have been fetched
+	return transform.NewStorageProvider(
+		cm,
+		storage.NewMappedStorageProvider(storages),
+		options.Decrypter,
+	)
+}
+
+// availableOverIPFS returns whether the CID can be found over IPFS before the
+// volume size request timeout.
+func availableOverIPFS(ctx context.Context, client *ipfs.Client, cid string) bool {
+	ctx, cancel := context.WithTimeout(ctx, config.GetVolumeSizeRequestTimeout())
+	defer cancel()
+	_, err := client.Stat(ctx, cid)
+	return err == nil
 }
 
 func NewNoopStorageProvider(
@@ -117,7 +292,7 @@ func NewStandardExecutorProvider(
 		return nil, err
 	}
 
-	dockerExecutor, err := docker.NewExecutor(ctx, cm, executorOptions.DockerID, storageProvider)
+	dockerExecutor, err := newDockerEngineExecutor(ctx, cm, storageProvider, executorOptions)
 	if err != nil {
 		return nil, err
 	}
@@ -159,3 +334,110 @@ func NewNoopExecutors(config noop_executor.ExecutorConfig) executor.ExecutorProv
 	noopExecutor := noop_executor.NewNoopExecutorWithConfig(config)
 	return noop_executor.NewNoopExecutorProvider(noopExecutor)
 }
+
+// newDockerEngineExecutor returns the executor of docker jobs, which falls
+// back through the backends of the options.
+func newDockerEngineExecutor(
+	ctx context.Context,
+	cm *system.CleanupManager,
+	storageProvider storage.StorageProvider,
+	options StandardExecutorOptions,
+) (executor.Executor, error) {
+	backends := options.DockerBackends
+	if len(backends) == 0 {
+		configured := map[DockerBackend]bool{
+			DockerBackendKubernetes:  options.Kubernetes != nil,
+			DockerBackendNomad:       options.Nomad != nil,
+			DockerBackendSlurm:       options.Slurm != nil,
+			DockerBackendFirecracker: options.Firecracker != nil,
+		}
+		for _, backend := range DockerBackends() {
+			if configured[backend] {
+				backends = append(backends, backend)
+			}
+		}
+		if len(backends) > 1 {
+			return nil, fmt.Errorf("docker jobs can run on one of Kubernetes, Nomad, Slurm or Firecracker, " +
+				"unless the order to fall back through them is given")
+		}
+		if len(backends) == 0 {
+			backends = []DockerBackend{DockerBackendDocker}
+		}
+	}
+
+	executors := make([]executor.NamedExecutor, 0, len(backends))
+	seen := map[DockerBackend]bool{}
+	for _, backend := range backends {
+		if seen[backend] {
+			return nil, fmt.Errorf("docker executor %s is given more than once", backend)
+		}
+		seen[backend] = true
+
+		dockerExecutor, err := newDockerBackendExecutor(ctx, cm, storageProvider, options, backend)
+		if err != nil {
+			return nil, err
+		}
+		if options.DockerNonRootUser != "" && backend != DockerBackendSlurm && backend != DockerBackendFirecracker {
+			dockerExecutor, err = executor.NewNonRootExecutor(dockerExecutor, options.DockerNonRootUser)
+			if err != nil {
+				return nil, err
+			}
+		}
+		executors = append(executors, executor.NamedExecutor{Name: string(backend), Executor: dockerExecutor})
+	}
+	if len(executors) == 1 {
+		return executors[0].Executor, nil
+	}
+	return executor.NewFallbackExecutor(executors...), nil
+}
+
+func newDockerBackendExecutor(
+	ctx context.Context,
+	cm *system.CleanupManager,
+	storageProvider storage.StorageProvider,
+	options StandardExecutorOptions,
+	backend DockerBackend,
+) (executor.Executor, error) {
+	notConfigured := fmt.Errorf("docker executor %s is not configured", backend)
+	switch backend {
+	case DockerBackendDocker:
+		dockerExecutor, err := docker.NewExecutor(ctx, cm, options.DockerID, storageProvider, options.DockerHardening)
+		if err != nil {
+			return nil, err
+		}
+		dockerExecutor.DebugSessionTimeout = options.DockerDebugSessionTimeout
+		return dockerExecutor, nil
+	case DockerBackendPodman:
+		host := options.PodmanHost
+		if host == "" {
+			host = dockerutils.DefaultPodmanHost
+		}
+		client, err := dockerutils.NewDockerClientWithHost(host)
+		if err != nil {
+			return nil, err
+		}
+		return docker.NewExecutorWithClient(ctx, cm, options.DockerID, storageProvider, options.DockerHardening, client)
+	case DockerBackendKubernetes:
+		if options.Kubernetes == nil {
+			return nil, notConfigured
+		}
+		return kubernetes.NewExecutor(ctx, cm, options.DockerID, storageProvider, *options.Kubernetes)
+	case DockerBackendNomad:
+		if options.Nomad == nil {
+			return nil, notConfigured
+		}
+		return nomad.NewExecutor(ctx, cm, options.DockerID, storageProvider, *options.Nomad)
+	case DockerBackendSlurm:
+		if options.Slurm == nil {
+			return nil, notConfigured
+		}
+		return slurm.NewExecutor(ctx, cm, options.DockerID, storageProvider, *options.Slurm)
+	case DockerBackendFirecracker:
+		if options.Firecracker == nil {
+			return nil, notConfigured
+		}
+		return firecracker.NewExecutor(ctx, cm, options.DockerID, storageProvider, *options.Firecracker)
+	default:
+		return nil, fmt.Errorf("unknown docker executor %q", backend)
+	}
+}
@@ -0,0 +1,105 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	jobutils "github.com/filecoin-project/bacalhau/pkg/job"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/storage"
+	"github.com/filecoin-project/bacalhau/pkg/storage/util"
+	"github.com/filecoin-project/bacalhau/pkg/system"
+	"github.com/rs/zerolog/log"
+)
+
+// Mount is a file or directory on the node that is mounted in a job.
+type Mount struct {
+	Source   string
+	Target   string
+	ReadOnly bool
+}
+
+// StorageExecutor implements the storage methods of Executor with a storage
+// provider, for the executors that prepare the inputs of jobs on the node to
+// embed.
+type StorageExecutor struct {
+	// the storage providers we can implement for a job
+	StorageProvider storage.StorageProvider
+}
+
+func (e StorageExecutor) HasStorageLocally(ctx context.Context, volume model.StorageSpec) (bool, error) {
+	//nolint:ineffassign,staticcheck
+	ctx, span := system.GetTracer().Start(ctx, "pkg/executor/StorageExecutor.HasStorageLocally")
+	defer span.End()
+
+	s, err := e.StorageProvider.GetStorage(ctx, volume.StorageSource)
+	if err != nil {
+		return false, err
+	}
+
+	return s.HasStorageLocally(ctx, volume)
+}
+
+func (e StorageExecutor) GetVolumeSize(ctx context.Context, volume model.StorageSpec) (uint64, error) {
+	s, err := e.StorageProvider.GetStorage(ctx, volume.StorageSource)
+	if err != nil {
+		return 0, err
+	}
+	return s.GetVolumeSize(ctx, volume)
+}
+
+// PrepareInputs prepares the contexts and the inputs of the shard on the
+// node, and returns their read only mounts.
+func (e StorageExecutor) PrepareInputs(ctx context.Context, shard model.JobShard) ([]Mount, error) {
+	shardStorageSpec, err := jobutils.GetShardStorageSpec(ctx, shard, e.StorageProvider)
+	if err != nil {
+		return nil, err
+	}
+
+	inputStorageSpecs := []model.StorageSpec{}
+	inputStorageSpecs = append(inputStorageSpecs, shard.Job.Spec.Contexts...)
+	inputStorageSpecs = append(inputStorageSpecs, shardStorageSpec...)
+
+	inputVolumes, err := storage.ParallelPrepareStorage(ctx, e.StorageProvider, inputStorageSpecs)
+	if err != nil {
+		return nil, err
+	}
+
+	mounts := make([]Mount, 0, len(inputVolumes))
+	for spec, volume := range inputVolumes {
+		if volume.Type != storage.StorageVolumeConnectorBind {
+			return nil, fmt.Errorf("unknown storage volume type: %s", volume.Type)
+		}
+		log.Ctx(ctx).Trace().Msgf("Input Volume: %+v %+v", spec, volume)
+		mounts = append(mounts, Mount{Source: volume.Source, Target: volume.Target, ReadOnly: true})
+	}
+	return mounts, nil
+}
+
+// PrepareOutputs creates the directories in the results directory that the
+// outputs of the shard are written to, and returns their mounts.
+//
+// The engine of an output is ignored here, as this is only about collecting
+// the data of the job on the node. It is how the output is published once
+// the shard has finished.
+func PrepareOutputs(ctx context.Context, shard model.JobShard, resultsDir string) ([]Mount, error) {
+	mounts := make([]Mount, 0, len(shard.Job.Spec.Outputs))
+	for _, output := range shard.Job.Spec.Outputs {
+		if output.Name == "" {
+			return nil, fmt.Errorf("output volume has no name: %+v", output)
+		}
+		if output.Path == "" {
+			return nil, fmt.Errorf("output volume has no path: %+v", output)
+		}
+
+		srcd := filepath.Join(resultsDir, output.Name)
+		if err := os.Mkdir(srcd, util.OS_ALL_R|util.OS_ALL_X|util.OS_USER_W); err != nil {
+			return nil, err
+		}
+		log.Ctx(ctx).Trace().Msgf("Output Volume: %+v", output)
+		mounts = append(mounts, Mount{Source: srcd, Target: output.Path})
+	}
+	return mounts, nil
+}
@@ -0,0 +1,34 @@
+//go:build unit || !integration
+
+package executor
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrepareOutputs(t *testing.T) {
+	resultsDir := t.TempDir()
+	shard := model.JobShard{Job: &model.Job{Spec: model.Spec{
+		Outputs: []model.StorageSpec{{Name: "outputs", Path: "/outputs"}, {Name: "logs", Path: "/var/log"}},
+	}}}
+
+	mounts, err := PrepareOutputs(context.Background(), shard, resultsDir)
+	require.NoError(t, err)
+	require.Equal(t, []Mount{
+		{Source: filepath.Join(resultsDir, "outputs"), Target: "/outputs"},
+		{Source: filepath.Join(resultsDir, "logs"), Target: "/var/log"},
+	}, mounts)
+	require.DirExists(t, filepath.Join(resultsDir, "outputs"))
+	require.DirExists(t, filepath.Join(resultsDir, "logs"))
+
+	for _, output := range []model.StorageSpec{{Path: "/outputs"}, {Name: "outputs"}} {
+		shard.Job.Spec.Outputs = []model.StorageSpec{output}
+		_, err = PrepareOutputs(context.Background(), shard, t.TempDir())
+		require.Error(t, err)
+	}
+}
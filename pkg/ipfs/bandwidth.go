@@ -0,0 +1,93 @@
+package ipfs
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+// BandwidthLimits caps how fast the IPFS clients of this process move data
+// to and from their IPFS nodes, in bytes per second, so that fetching job
+// inputs and publishing results doesn't saturate the host's network. Zero
+// means no limit.
+type BandwidthLimits struct {
+	Ingress int // data fetched from IPFS, such as job inputs and results
+	Egress  int // data added to IPFS, such as published results
+}
+
+// the limiters are shared by every client, so that the limits hold for the
+// whole process however many clients there are
+var (
+	bandwidthLimitersMutex sync.RWMutex
+	ingressLimiter         *rate.Limiter
+	egressLimiter          *rate.Limiter
+)
+
+// SetBandwidthLimits sets the bandwidth limits of every IPFS client of this
+// process, including the ones that have already been created.
+func SetBandwidthLimits(limits BandwidthLimits) {
+	bandwidthLimitersMutex.Lock()
+	defer bandwidthLimitersMutex.Unlock()
+	ingressLimiter = newBandwidthLimiter(limits.Ingress)
+	egressLimiter = newBandwidthLimiter(limits.Egress)
+}
+
+func getBandwidthLimiters() (ingress, egress *rate.Limiter) {
+	bandwidthLimitersMutex.RLock()
+	defer bandwidthLimitersMutex.RUnlock()
+	return ingressLimiter, egressLimiter
+}
+
+// newBandwidthLimiter returns a token bucket that fills at bytesPerSecond
+// and holds up to a second's worth of bytes, or nil for no limit.
+func newBandwidthLimiter(bytesPerSecond int) *rate.Limiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+	return rate.NewLimiter(rate.Limit(bytesPerSecond), bytesPerSecond)
+}
+
+// throttledTransport limits the rate at which request bodies are sent to,
+// and response bodies are read from, the IPFS API.
+type throttledTransport struct {
+	base http.RoundTripper
+}
+
+func (t *throttledTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ingress, egress := getBandwidthLimiters()
+	if egress != nil && req.Body != nil && req.Body != http.NoBody {
+		req = req.Clone(req.Context())
+		req.Body = &throttledReadCloser{ctx: req.Context(), ReadCloser: req.Body, limiter: egress}
+	}
+
+	res, err := t.base.RoundTrip(req)
+	if err != nil || ingress == nil {
+		return res, err
+	}
+	res.Body = &throttledReadCloser{ctx: req.Context(), ReadCloser: res.Body, limiter: ingress}
+	return res, nil
+}
+
+// throttledReadCloser waits for the limiter to allow each read.
+type throttledReadCloser struct {
+	io.ReadCloser
+	ctx     context.Context
+	limiter *rate.Limiter
+}
+
+func (r *throttledReadCloser) Read(p []byte) (int, error) {
+	// a read can't take more tokens than the bucket holds
+	if burst := r.limiter.Burst(); len(p) > burst {
+		p = p[:burst]
+	}
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		if waitErr := r.limiter.WaitN(r.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,52 @@
+//go:build unit || !integration
+
+package ipfs
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestThrottledTransport(t *testing.T) {
+	const size = 150 * 1024
+	var received atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		received.Store(int64(len(body)))
+		_, _ = res.Write(bytes.Repeat([]byte("a"), size))
+	}))
+	defer server.Close()
+	client := &http.Client{Transport: &throttledTransport{base: http.DefaultTransport}}
+
+	roundTrip := func() time.Duration {
+		start := time.Now()
+		res, err := client.Post(server.URL, "text/plain", strings.NewReader(strings.Repeat("b", size)))
+		require.NoError(t, err)
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		require.Len(t, body, size)
+		require.Equal(t, int64(size), received.Load())
+		return time.Since(start)
+	}
+
+	defer SetBandwidthLimits(BandwidthLimits{})
+
+	// the first 100Kb fill the bucket, and the other 50Kb take half a second
+	SetBandwidthLimits(BandwidthLimits{Ingress: 100 * 1024})
+	require.GreaterOrEqual(t, roundTrip(), 400*time.Millisecond)
+
+	SetBandwidthLimits(BandwidthLimits{Egress: 100 * 1024})
+	require.GreaterOrEqual(t, roundTrip(), 400*time.Millisecond)
+
+	SetBandwidthLimits(BandwidthLimits{})
+	require.Less(t, roundTrip(), 400*time.Millisecond)
+}
@@ -0,0 +1,61 @@
+package car
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-car/v2"
+	"github.com/ipld/go-car/v2/blockstore"
+)
+
+// FilterCar writes the blocks of the CAR file at inputFile that skip returns
+// false for to a CARv1 file at outputFile with the same roots, e.g. to only
+// upload the blocks of a DAG that the destination doesn't have yet. It
+// returns the CIDs of all of the blocks of the input and how many of them
+// were skipped.
+func FilterCar(
+	ctx context.Context,
+	inputFile string,
+	outputFile string,
+	skip func(cid.Cid) bool,
+) ([]cid.Cid, int, error) {
+	input, err := os.Open(inputFile)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer input.Close()
+
+	reader, err := car.NewBlockReader(input)
+	if err != nil {
+		return nil, 0, err
+	}
+	output, err := blockstore.OpenReadWrite(outputFile, reader.Roots, blockstore.WriteAsCarV1(true))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var cids []cid.Cid
+	skipped := 0
+	for {
+		block, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			output.Discard()
+			return nil, 0, err
+		}
+		cids = append(cids, block.Cid())
+		if skip(block.Cid()) {
+			skipped++
+			continue
+		}
+		if err = output.Put(ctx, block); err != nil {
+			output.Discard()
+			return nil, 0, err
+		}
+	}
+	return cids, skipped, output.Finalize()
+}
@@ -0,0 +1,73 @@
+//go:build unit || !integration
+
+package car
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-car/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterCar(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	results := filepath.Join(dir, "results")
+	require.NoError(t, os.MkdirAll(filepath.Join(results, "outputs"), os.ModePerm))
+	require.NoError(t, os.WriteFile(filepath.Join(results, "stdout"), []byte("hello"), os.ModePerm))
+	require.NoError(t, os.WriteFile(filepath.Join(results, "outputs", "data.csv"), []byte("a,b\n1,2\n"), os.ModePerm))
+
+	carFile := filepath.Join(dir, "results.car")
+	root, err := CreateCar(ctx, results, carFile, 1)
+	require.NoError(t, err)
+
+	all := filepath.Join(dir, "all.car")
+	cids, skipped, err := FilterCar(ctx, carFile, all, func(cid.Cid) bool { return false })
+	require.NoError(t, err)
+	require.Zero(t, skipped)
+	require.Len(t, cids, 4) // the root, outputs and the two files
+	require.Equal(t, blockCIDs(t, carFile), blockCIDs(t, all))
+
+	// the files were uploaded before, so only the directories are left
+	filtered := filepath.Join(dir, "filtered.car")
+	known := map[string]bool{}
+	for _, c := range cids {
+		if c.String() != root {
+			known[c.String()] = true
+		}
+	}
+	cids, skipped, err = FilterCar(ctx, carFile, filtered, func(c cid.Cid) bool { return known[c.String()] })
+	require.NoError(t, err)
+	require.Len(t, cids, 4)
+	require.Equal(t, 3, skipped)
+	require.Equal(t, []string{root}, blockCIDs(t, filtered))
+
+	reader, err := car.OpenReader(filtered)
+	require.NoError(t, err)
+	defer reader.Close()
+	roots, err := reader.Roots()
+	require.NoError(t, err)
+	require.Equal(t, root, roots[0].String())
+}
+
+func blockCIDs(t *testing.T, carFile string) []string {
+	file, err := os.Open(carFile)
+	require.NoError(t, err)
+	defer file.Close()
+	reader, err := car.NewBlockReader(file)
+	require.NoError(t, err)
+
+	var cids []string
+	for {
+		block, err := reader.Next()
+		if err != nil {
+			break
+		}
+		cids = append(cids, block.Cid().String())
+	}
+	return cids
+}
@@ -2,8 +2,16 @@ package ipfs
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net"
+	"net/http"
 	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/filecoin-project/bacalhau/pkg/system"
@@ -21,32 +29,163 @@ import (
 
 // Client is a front-end for an ipfs node's API endpoints. You can create
 // Client instances manually by connecting to an ipfs node's API multiaddr,
-// or automatically from an active Node instance.
+// or automatically from an active Node instance. A client can be given
+// several API multiaddrs, such as a local daemon and a cluster, in which
+// case calls fail over to the next one when a node can't be reached.
 type Client struct {
+	// API is the API of the first ipfs node the client was created with.
 	API  icore.CoreAPI
 	addr string
+
+	config    ClientConfig
+	endpoints []*endpoint
+	// the index of the endpoint that last worked, which calls start from
+	current atomic.Int32
+
+	healthMutex sync.RWMutex
+	health      Health
+}
+
+type endpoint struct {
+	api        icore.CoreAPI
+	addr       string
+	httpClient *http.Client
+}
+
+// ClientConfig configures how a client talks to its ipfs node.
+type ClientConfig struct {
+	// RequestTimeout bounds each call that doesn't transfer file data, such
+	// as resolving or stating a CID, so that a daemon that has stopped
+	// responding doesn't block the caller forever. Calls that fetch or add
+	// files are only bounded by the context they are passed. Zero means no
+	// timeout.
+	RequestTimeout time.Duration
+
+	// HealthCheckInterval is how often StartHealthChecks checks that the
+	// ipfs node can be reached. Zero disables health checks.
+	HealthCheckInterval time.Duration
+
+	// MaxIdleConnections is how many connections to the ipfs node are kept
+	// open to be reused by later calls.
+	MaxIdleConnections int
+}
+
+var DefaultClientConfig = ClientConfig{
+	RequestTimeout:      30 * time.Second,
+	HealthCheckInterval: 30 * time.Second,
+	MaxIdleConnections:  16,
 }
 
-// NewClient creates an API client for the given ipfs node API multiaddress.
+// NewClient creates an API client for the given ipfs node API multiaddress,
+// or comma-separated list of multiaddresses to fail over between.
 // NOTE: the API address is _not_ the same as the swarm address
 func NewClient(apiAddr string) (*Client, error) {
+	return NewClientWithConfig(apiAddr, DefaultClientConfig)
+}
+
+// NewClientWithConfig creates an API client for the given ipfs node API
+// multiaddress, or comma-separated list of them, with the given
+// configuration.
+func NewClientWithConfig(apiAddr string, config ClientConfig) (*Client, error) {
+	var endpoints []*endpoint
+	for _, endpointAddr := range strings.Split(apiAddr, ",") {
+		endpointAddr = strings.TrimSpace(endpointAddr)
+		if endpointAddr == "" {
+			continue
+		}
+		e, err := newEndpoint(endpointAddr, config)
+		if err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, e)
+	}
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("no ipfs api address given")
+	}
+
+	log.Debug().Msgf("Created IPFS client for node API address: %s", apiAddr)
+	return &Client{
+		API:       endpoints[0].api,
+		addr:      apiAddr,
+		config:    config,
+		endpoints: endpoints,
+	}, nil
+}
+
+func newEndpoint(apiAddr string, config ClientConfig) (*endpoint, error) {
 	addr, err := ma.NewMultiaddr(apiAddr)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse api address '%s': %w", apiAddr, err)
 	}
 
-	api, err := httpapi.NewApi(addr)
+	// connections are pooled between calls, and throttled by the bandwidth
+	// limits
+	httpClient := &http.Client{
+		Transport: &throttledTransport{
+			base: &http.Transport{
+				Proxy:               http.ProxyFromEnvironment,
+				MaxIdleConns:        config.MaxIdleConnections,
+				MaxIdleConnsPerHost: config.MaxIdleConnections,
+				DisableKeepAlives:   config.MaxIdleConnections <= 0,
+				IdleConnTimeout:     90 * time.Second,
+			},
+		},
+	}
+	api, err := httpapi.NewApiWithClient(addr, httpClient)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to '%s': %w", apiAddr, err)
 	}
-
-	log.Debug().Msgf("Created IPFS client for node API address: %s", apiAddr)
-	return &Client{
-		API:  api,
-		addr: apiAddr,
+	return &endpoint{
+		api:        api,
+		addr:       apiAddr,
+		httpClient: httpClient,
 	}, nil
 }
 
+// withFailover calls f with the API of the endpoint that last worked, and
+// then with each of the others in turn for as long as the ipfs node can't
+// be reached, returning the error of the last call.
+func (cl *Client) withFailover(ctx context.Context, f func(api icore.CoreAPI) error) error {
+	if len(cl.endpoints) == 0 {
+		return fmt.Errorf("ipfs client has no api address")
+	}
+	start := int(cl.current.Load())
+	var err error
+	for i := range cl.endpoints {
+		index := (start + i) % len(cl.endpoints)
+		err = f(cl.endpoints[index].api)
+		if err == nil {
+			cl.current.Store(int32(index))
+			return nil
+		}
+		if ctx.Err() != nil || !isUnreachable(err) {
+			return err
+		}
+		if len(cl.endpoints) > 1 {
+			log.Ctx(ctx).Warn().Msgf("IPFS node at %s is unreachable, failing over: %s", cl.endpoints[index].addr, err)
+		}
+	}
+	return err
+}
+
+// isUnreachable returns whether an error means the ipfs node couldn't be
+// reached, rather than that it failed the call.
+func isUnreachable(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET)
+}
+
+// withRequestTimeout bounds a call by the client's request timeout.
+func (cl *Client) withRequestTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if cl.config.RequestTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, cl.config.RequestTimeout)
+}
+
 // WaitUntilAvailable blocks the current goroutine until the client is able
 // to successfully make requests to the server. Useful for setting up local
 // test networks. WaitUntilAvailable will respect context deadlines/cancels,
@@ -74,7 +213,20 @@ func (cl *Client) WaitUntilAvailable(ctx context.Context) error {
 
 // ID returns the node's ipfs ID.
 func (cl *Client) ID(ctx context.Context) (string, error) {
-	key, err := cl.API.Key().Self(ctx)
+	var id string
+	err := cl.withFailover(ctx, func(api icore.CoreAPI) error {
+		var err error
+		id, err = cl.nodeID(ctx, api)
+		return err
+	})
+	return id, err
+}
+
+func (cl *Client) nodeID(ctx context.Context, api icore.CoreAPI) (string, error) {
+	ctx, cancel := cl.withRequestTimeout(ctx)
+	defer cancel()
+
+	key, err := api.Key().Self(ctx)
 	if err != nil {
 		return "", err
 	}
@@ -82,7 +234,8 @@ func (cl *Client) ID(ctx context.Context) (string, error) {
 	return key.ID().String(), nil
 }
 
-// APIAddress returns Api address that was used to connect to the node.
+// APIAddress returns Api address that was used to connect to the node, which
+// is a comma-separated list if the client fails over between several.
 func (cl *Client) APIAddress() string {
 	return cl.addr
 }
@@ -92,19 +245,29 @@ func (cl *Client) SwarmAddresses(ctx context.Context) ([]string, error) {
 	ctx, span := system.GetTracer().Start(ctx, "pkg/ipfs.SwarmAddresses")
 	defer span.End()
 
-	id, err := cl.ID(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("error fetching node's ipfs id: %w", err)
-	}
+	var res []string
+	err := cl.withFailover(ctx, func(api icore.CoreAPI) error {
+		id, err := cl.nodeID(ctx, api)
+		if err != nil {
+			return fmt.Errorf("error fetching node's ipfs id: %w", err)
+		}
 
-	addrs, err := cl.API.Swarm().LocalAddrs(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("error fetching node's swarm addresses: %w", err)
-	}
+		ctx, cancel := cl.withRequestTimeout(ctx)
+		defer cancel()
 
-	var res []string
-	for _, addr := range addrs {
-		res = append(res, fmt.Sprintf("%s/p2p/%s", addr.String(), id))
+		addrs, err := api.Swarm().LocalAddrs(ctx)
+		if err != nil {
+			return fmt.Errorf("error fetching node's swarm addresses: %w", err)
+		}
+
+		res = nil
+		for _, addr := range addrs {
+			res = append(res, fmt.Sprintf("%s/p2p/%s", addr.String(), id))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return res, nil
@@ -124,16 +287,81 @@ func (cl *Client) Get(ctx context.Context, cid, outputPath string) error {
 		return fmt.Errorf("output path '%s' already exists", outputPath)
 	}
 
-	node, err := cl.API.Unixfs().Get(ctx, icorepath.New(cid))
-	if err != nil {
-		return fmt.Errorf("failed to get ipfs cid '%s': %w", cid, err)
-	}
+	return cl.withFailover(ctx, func(api icore.CoreAPI) error {
+		node, err := api.Unixfs().Get(ctx, icorepath.New(cid))
+		if err != nil {
+			return fmt.Errorf("failed to get ipfs cid '%s': %w", cid, err)
+		}
+
+		if err := files.WriteTo(node, outputPath); err != nil {
+			// don't leave a partial download in the way of the next attempt
+			_ = os.RemoveAll(outputPath)
+			return fmt.Errorf("failed to write to '%s': %w", outputPath, err)
+		}
+
+		return nil
+	})
+}
+
+// GetFile returns the file at the slash-separated path in the directory of
+// the cid, which the caller must close. The file can seek, so that ranges of
+// it can be read without fetching the rest of it.
+func (cl *Client) GetFile(ctx context.Context, cid, filePath string) (files.File, error) {
+	ctx, span := system.GetTracer().Start(ctx, "pkg/ipfs.GetFile")
+	defer span.End()
+
+	var file files.File
+	err := cl.withFailover(ctx, func(api icore.CoreAPI) error {
+		node, err := api.Unixfs().Get(ctx, icorepath.Join(icorepath.New(cid), filePath))
+		if err != nil {
+			return fmt.Errorf("failed to get '%s' of ipfs cid '%s': %w", filePath, cid, err)
+		}
+		var ok bool
+		if file, ok = node.(files.File); !ok {
+			node.Close()
+			return fmt.Errorf("'%s' of ipfs cid '%s' is not a file", filePath, cid)
+		}
+		return nil
+	})
+	return file, err
+}
+
+// GetTar writes a tar archive of a directory to w, in which each of the
+// CIDs is fetched from the ipfs network to the path it is keyed by. Once
+// the archive has started being written it is not failed over, as w can't
+// be rewound.
+func (cl *Client) GetTar(ctx context.Context, w io.Writer, dirName string, cids map[string]string) error {
+	ctx, span := system.GetTracer().Start(ctx, "pkg/ipfs.GetTar")
+	defer span.End()
 
-	if err := files.WriteTo(node, outputPath); err != nil {
-		return fmt.Errorf("failed to write to '%s': %w", outputPath, err)
+	nodes := make(map[string]files.Node, len(cids))
+	defer func() {
+		for _, node := range nodes {
+			node.Close()
+		}
+	}()
+	for name, cid := range cids {
+		err := cl.withFailover(ctx, func(api icore.CoreAPI) error {
+			node, err := api.Unixfs().Get(ctx, icorepath.New(cid))
+			if err != nil {
+				return fmt.Errorf("failed to get ipfs cid '%s': %w", cid, err)
+			}
+			nodes[name] = node
+			return nil
+		})
+		if err != nil {
+			return err
+		}
 	}
 
-	return nil
+	tw, err := files.NewTarWriter(w)
+	if err != nil {
+		return err
+	}
+	if err = tw.WriteFile(files.NewMapDirectory(nodes), dirName); err != nil {
+		return fmt.Errorf("failed to write tar archive: %w", err)
+	}
+	return tw.Close()
 }
 
 // Put uploads and pins a file or directory to the ipfs network. Timeouts and
@@ -147,23 +375,28 @@ func (cl *Client) Put(ctx context.Context, inputPath string) (string, error) {
 		return "", fmt.Errorf("failed to stat file '%s': %w", inputPath, err)
 	}
 
-	node, err := files.NewSerialFile(inputPath, false, st)
-	if err != nil {
-		return "", fmt.Errorf("failed to create ipfs node: %w", err)
-	}
-
 	// Pin uploaded file/directory to local storage to prevent deletion by GC.
 	addOptions := []icoreoptions.UnixfsAddOption{
 		icoreoptions.Unixfs.Pin(true),
 	}
 
-	ipfsPath, err := cl.API.Unixfs().Add(ctx, node, addOptions...)
-	if err != nil {
-		return "", fmt.Errorf("failed to add file '%s': %w", inputPath, err)
-	}
+	var cid string
+	err = cl.withFailover(ctx, func(api icore.CoreAPI) error {
+		node, err := files.NewSerialFile(inputPath, false, st)
+		if err != nil {
+			return fmt.Errorf("failed to create ipfs node: %w", err)
+		}
+		defer node.Close()
+
+		ipfsPath, err := api.Unixfs().Add(ctx, node, addOptions...)
+		if err != nil {
+			return fmt.Errorf("failed to add file '%s': %w", inputPath, err)
+		}
 
-	cid := ipfsPath.Cid().String()
-	return cid, nil
+		cid = ipfsPath.Cid().String()
+		return nil
+	})
+	return cid, err
 }
 
 type IPLDType int
@@ -183,7 +416,15 @@ func (cl *Client) Stat(ctx context.Context, cid string) (*StatResult, error) {
 	ctx, span := system.GetTracer().Start(ctx, "kg/ipfs.Stat")
 	defer span.End()
 
-	node, err := cl.API.ResolveNode(ctx, icorepath.New(cid))
+	var node ipld.Node
+	err := cl.withFailover(ctx, func(api icore.CoreAPI) error {
+		ctx, cancel := cl.withRequestTimeout(ctx)
+		defer cancel()
+
+		var err error
+		node, err = api.ResolveNode(ctx, icorepath.New(cid))
+		return err
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to resolve node '%s': %w", cid, err)
 	}
@@ -202,7 +443,15 @@ func (cl *Client) GetCidSize(ctx context.Context, cid string) (uint64, error) {
 	ctx, span := system.GetTracer().Start(ctx, "pkg/ipfs.GetCidSize")
 	defer span.End()
 
-	stat, err := cl.API.Object().Stat(ctx, icorepath.New(cid))
+	var stat *icore.ObjectStat
+	err := cl.withFailover(ctx, func(api icore.CoreAPI) error {
+		ctx, cancel := cl.withRequestTimeout(ctx)
+		defer cancel()
+
+		var err error
+		stat, err = api.Object().Stat(ctx, icorepath.New(cid))
+		return err
+	})
 	if err != nil {
 		return 0, err
 	}
@@ -215,14 +464,24 @@ func (cl *Client) NodesWithCID(ctx context.Context, cid string) ([]string, error
 	ctx, span := system.GetTracer().Start(ctx, "pkg/ipfs.NodesWithCID")
 	defer span.End()
 
-	ch, err := cl.API.Dht().FindProviders(ctx, icorepath.New(cid))
-	if err != nil {
-		return nil, fmt.Errorf("error finding providers of '%s': %w", cid, err)
-	}
-
 	var res []string
-	for info := range ch {
-		res = append(res, info.ID.String())
+	err := cl.withFailover(ctx, func(api icore.CoreAPI) error {
+		ctx, cancel := cl.withRequestTimeout(ctx)
+		defer cancel()
+
+		ch, err := api.Dht().FindProviders(ctx, icorepath.New(cid))
+		if err != nil {
+			return fmt.Errorf("error finding providers of '%s': %w", cid, err)
+		}
+
+		res = nil
+		for info := range ch {
+			res = append(res, info.ID.String())
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return res, nil
@@ -256,12 +515,20 @@ func (cl *Client) GetTreeNode(ctx context.Context, cid string) (IPLDTreeNode, er
 	ctx, span := system.GetTracer().Start(ctx, "pkg/ipfs.GetTreeNode")
 	defer span.End()
 
-	ipldNode, err := cl.API.ResolveNode(ctx, icorepath.New(cid))
-	if err != nil {
-		return IPLDTreeNode{}, fmt.Errorf("failed to resolve node '%s': %w", cid, err)
-	}
+	var treeNode IPLDTreeNode
+	err := cl.withFailover(ctx, func(api icore.CoreAPI) error {
+		resolveCtx, cancel := cl.withRequestTimeout(ctx)
+		defer cancel()
 
-	return GetTreeNode(ctx, ipld.NewNavigableIPLDNode(ipldNode, cl.API.Dag()), []string{})
+		ipldNode, err := api.ResolveNode(resolveCtx, icorepath.New(cid))
+		if err != nil {
+			return fmt.Errorf("failed to resolve node '%s': %w", cid, err)
+		}
+
+		treeNode, err = GetTreeNode(ctx, ipld.NewNavigableIPLDNode(ipldNode, api.Dag()), []string{})
+		return err
+	})
+	return treeNode, err
 }
 
 func getNodeType(node ipld.Node) (IPLDType, error) {
@@ -0,0 +1,82 @@
+//go:build unit || !integration
+
+package ipfs
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/phayes/freeport"
+	"github.com/stretchr/testify/require"
+)
+
+const testPeerID = "QmdZQ7ZbhnvWY1J12XYKGHApJ6aufKyLNSvf8jZBrBaAVL"
+
+// newTestDaemon serves the id call of the ipfs API, counting the calls.
+func newTestDaemon(t *testing.T, calls *atomic.Int32) string {
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		calls.Add(1)
+		res.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(res, `{"ID": %q}`, testPeerID)
+	}))
+	t.Cleanup(server.Close)
+
+	_, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	require.NoError(t, err)
+	return fmt.Sprintf("/ip4/127.0.0.1/tcp/%s", port)
+}
+
+func TestFailover(t *testing.T) {
+	port, err := freeport.GetFreePort()
+	require.NoError(t, err)
+	var calls atomic.Int32
+	addr := fmt.Sprintf("/ip4/127.0.0.1/tcp/%d, %s", port, newTestDaemon(t, &calls))
+
+	cl, err := NewClient(addr)
+	require.NoError(t, err)
+	require.Equal(t, addr, cl.APIAddress())
+
+	// the first node can't be reached so the second one is used, and then
+	// keeps being used
+	for i := 1; i <= 2; i++ {
+		id, err := cl.ID(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, testPeerID, id)
+		require.Equal(t, int32(i), calls.Load())
+		require.Equal(t, int32(1), cl.current.Load())
+	}
+}
+
+func TestNoFailoverOnCallErrors(t *testing.T) {
+	var failed, calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		failed.Add(1)
+		res.Header().Set("Content-Type", "application/json")
+		res.WriteHeader(http.StatusInternalServerError)
+		_, _ = res.Write([]byte(`{"Message": "merkledag: not found", "Code": 0, "Type": "error"}`))
+	}))
+	defer server.Close()
+	_, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	require.NoError(t, err)
+
+	cl, err := NewClient(fmt.Sprintf("/ip4/127.0.0.1/tcp/%s,%s", port, newTestDaemon(t, &calls)))
+	require.NoError(t, err)
+
+	// the first node failed the call, which the second one won't do better
+	_, err = cl.ID(context.Background())
+	require.ErrorContains(t, err, "merkledag: not found")
+	require.Equal(t, int32(1), failed.Load())
+	require.Zero(t, calls.Load())
+}
+
+func TestNewClientWithoutAddress(t *testing.T) {
+	_, err := NewClient(" , ")
+	require.Error(t, err)
+	_, err = NewClient("/ip4/127.0.0.1/tcp/5001,not-a-multiaddr")
+	require.Error(t, err)
+}
@@ -12,6 +12,7 @@ import (
 
 	"github.com/filecoin-project/bacalhau/pkg/model"
 	"github.com/filecoin-project/bacalhau/pkg/system"
+	"github.com/filecoin-project/bacalhau/pkg/util/manifest"
 	cp "github.com/n-marshall/go-cp"
 	"github.com/rs/zerolog/log"
 )
@@ -33,6 +34,7 @@ var SpecialFiles = map[string]bool{
 	DownloadFilenameStdout:   true,
 	DownloadFilenameStderr:   true,
 	DownloadFilenameExitCode: false,
+	manifest.FileName:        false,
 }
 
 type IPFSDownloadSettings struct {
@@ -92,7 +94,11 @@ func DownloadJob( //nolint:funlen,gocyclo
 
 	switch system.GetEnvironment() {
 	case system.EnvironmentProd:
-		settings.IPFSSwarmAddrs = strings.Join(system.Envs[system.Production].IPFSSwarmAddresses, ",")
+		network, err := system.SelectedNetwork()
+		if err != nil {
+			return err
+		}
+		settings.IPFSSwarmAddrs = strings.Join(network.IPFSSwarmAddresses, ",")
 	case system.EnvironmentTest:
 		if os.Getenv("BACALHAU_IPFS_SWARM_ADDRESSES") != "" {
 			log.Ctx(ctx).Warn().Msg("No action (don't use BACALHAU_IPFS_SWARM_ADDRESSES")
@@ -168,7 +174,7 @@ func DownloadJob( //nolint:funlen,gocyclo
 		shardDir := filepath.Join(
 			resultsOutputDir,
 			DownloadShardsFolderName,
-			fmt.Sprintf("%d_node_%s", shardResult.ShardIndex, system.GetShortID(shardResult.NodeID)),
+			shardDirName(shardResult),
 		)
 		shardContexts = append(shardContexts, shardCIDContext{
 			result:         shardResult,
@@ -205,6 +211,31 @@ func DownloadJob( //nolint:funlen,gocyclo
 	return nil
 }
 
+// WriteResultsTar writes a tar archive of the published results of a job's
+// shards to w, with the same per shard directories as DownloadJob.
+func WriteResultsTar(
+	ctx context.Context,
+	cl *Client,
+	publishedShardResults []model.PublishedResult,
+	w io.Writer,
+) error {
+	ctx, span := system.GetTracer().Start(ctx, "pkg/ipfs.WriteResultsTar")
+	defer span.End()
+
+	cids := make(map[string]string, len(publishedShardResults))
+	for _, shardResult := range publishedShardResults {
+		if shardResult.Data.CID == "" {
+			return fmt.Errorf("result of shard %d on node %s has no CID", shardResult.ShardIndex, shardResult.NodeID)
+		}
+		cids[shardDirName(shardResult)] = shardResult.Data.CID
+	}
+	return cl.GetTar(ctx, w, DownloadShardsFolderName, cids)
+}
+
+func shardDirName(shardResult model.PublishedResult) string {
+	return fmt.Sprintf("%d_node_%s", shardResult.ShardIndex, system.GetShortID(shardResult.NodeID))
+}
+
 func spinUpIPFSNode(
 	ctx context.Context,
 	cm *system.CleanupManager,
@@ -251,6 +282,9 @@ func fetchResult(
 
 		return err
 	}
+	if err = manifest.Verify(shardContext.cidDownloadDir); err != nil {
+		return fmt.Errorf("result CID %s of shard %d: %w", shardContext.result.Data.CID, shardContext.result.ShardIndex, err)
+	}
 	return nil
 }
 
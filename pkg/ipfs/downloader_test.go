@@ -3,8 +3,11 @@
 package ipfs
 
 import (
+	"archive/tar"
+	"bytes"
 	"context"
 	"crypto/rand"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -308,3 +311,48 @@ func (ds *DownloaderSuite) TestCustomVolumeNames() {
 
 	requireFileExists(ds, DownloadVolumesFolderName, "secrets", "private.pem")
 }
+
+func (ds *DownloaderSuite) TestWriteResultsTar() {
+	var stdout, hello []byte
+	cid := mockShardOutput(ds, func(dir string) {
+		stdout = mockFile(ds, dir, DownloadFilenameStdout)
+		hello = mockFile(ds, dir, "outputs", "hello.txt")
+	})
+
+	var buf bytes.Buffer
+	err := WriteResultsTar(
+		context.Background(),
+		ds.client,
+		[]model.PublishedResult{
+			{
+				NodeID:     "testnode",
+				ShardIndex: 0,
+				Data: model.StorageSpec{
+					StorageSource: model.StorageSourceIPFS,
+					Name:          "shard-0",
+					CID:           cid,
+				},
+			},
+		},
+		&buf,
+	)
+	require.NoError(ds.T(), err)
+
+	contents := map[string][]byte{}
+	tr := tar.NewReader(&buf)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(ds.T(), err)
+		data, err := io.ReadAll(tr)
+		require.NoError(ds.T(), err)
+		contents[header.Name] = data
+	}
+	require.Equal(ds.T(), stdout, contents[DownloadShardsFolderName+"/0_node_testnode/stdout"])
+	require.Equal(ds.T(), hello, contents[DownloadShardsFolderName+"/0_node_testnode/outputs/hello.txt"])
+
+	err = WriteResultsTar(context.Background(), ds.client, []model.PublishedResult{{NodeID: "testnode"}}, &buf)
+	require.Error(ds.T(), err)
+}
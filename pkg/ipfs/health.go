@@ -0,0 +1,88 @@
+package ipfs
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Health is whether the IPFS node behind a client could be reached when it
+// was last checked.
+type Health struct {
+	// Whether the last health check reached the IPFS node. A client that
+	// hasn't been checked yet is assumed to be available.
+	Available bool
+	// When the IPFS node was last checked, zero if it hasn't been yet.
+	LastChecked time.Time
+	// Why the last health check failed.
+	Error string
+}
+
+// Health returns the result of the client's last health check.
+func (cl *Client) Health() Health {
+	cl.healthMutex.RLock()
+	defer cl.healthMutex.RUnlock()
+	if cl.health.LastChecked.IsZero() {
+		return Health{Available: true}
+	}
+	return cl.health
+}
+
+// CheckHealth checks that the IPFS node can be reached, and drops the
+// client's pooled connections if it can't so that the next request
+// reconnects rather than reusing a connection to a daemon that has gone.
+func (cl *Client) CheckHealth(ctx context.Context) Health {
+	_, err := cl.ID(ctx)
+	if err != nil && ctx.Err() != nil {
+		// we're shutting down, which says nothing about the IPFS node
+		return cl.Health()
+	}
+	health := Health{
+		Available:   err == nil,
+		LastChecked: time.Now(),
+	}
+	if err != nil {
+		health.Error = err.Error()
+		cl.closeIdleConnections()
+	}
+
+	cl.healthMutex.Lock()
+	wasAvailable := cl.health.LastChecked.IsZero() || cl.health.Available
+	cl.health = health
+	cl.healthMutex.Unlock()
+
+	if wasAvailable && !health.Available {
+		log.Ctx(ctx).Warn().Msgf("IPFS node at %s is unavailable: %s", cl.addr, health.Error)
+	} else if !wasAvailable && health.Available {
+		log.Ctx(ctx).Info().Msgf("IPFS node at %s is available again", cl.addr)
+	}
+	return health
+}
+
+// StartHealthChecks checks the health of the IPFS node every
+// HealthCheckInterval until ctx is done.
+func (cl *Client) StartHealthChecks(ctx context.Context) {
+	interval := cl.config.HealthCheckInterval
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			cl.CheckHealth(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (cl *Client) closeIdleConnections() {
+	for _, e := range cl.endpoints {
+		e.httpClient.CloseIdleConnections()
+	}
+}
@@ -0,0 +1,57 @@
+//go:build unit || !integration
+
+package ipfs
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/phayes/freeport"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckHealth(t *testing.T) {
+	port, err := freeport.GetFreePort()
+	require.NoError(t, err)
+	cl, err := NewClient(fmt.Sprintf("/ip4/127.0.0.1/tcp/%d", port))
+	require.NoError(t, err)
+
+	// no daemon is listening on the port
+	require.True(t, cl.Health().Available)
+	health := cl.CheckHealth(context.Background())
+	require.False(t, health.Available)
+	require.NotEmpty(t, health.Error)
+	require.Equal(t, health, cl.Health())
+
+	// a cancelled check doesn't change the health
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	cl.CheckHealth(ctx)
+	require.Equal(t, health, cl.Health())
+}
+
+func TestRequestTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	_, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	require.NoError(t, err)
+	cl, err := NewClientWithConfig(fmt.Sprintf("/ip4/127.0.0.1/tcp/%s", port), ClientConfig{
+		RequestTimeout: 100 * time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	start := time.Now()
+	_, err = cl.ID(context.Background())
+	require.Error(t, err)
+	require.Less(t, time.Since(start), 5*time.Second)
+}
@@ -10,6 +10,7 @@ import (
 	"strconv"
 	"sync"
 
+	"github.com/filecoin-project/bacalhau/pkg/storage/util"
 	"github.com/filecoin-project/bacalhau/pkg/system"
 	"github.com/hashicorp/go-multierror"
 	icore "github.com/ipfs/interface-go-ipfs-core"
@@ -91,6 +92,11 @@ type Config struct {
 	// KeypairSize is the number of bits to use for the node's repo keypair. If
 	// nil, then a default value of 2048 is used.
 	KeypairSize *int
+
+	// RepoPath is the directory of the node's repo, which is created if it
+	// doesn't exist yet and kept when the node shuts down. If empty, then a
+	// temporary repo is created and removed when the node shuts down.
+	RepoPath string
 }
 
 func (cfg *Config) getKeypairSize() int {
@@ -113,17 +119,32 @@ func (cfg *Config) getPeerAddrs() []string {
 // repo in a temporary directory, uses the public libp2p nodes as peers and
 // generates a repo keypair with 2048 bits.
 func NewNode(ctx context.Context, cm *system.CleanupManager, peerAddrs []string) (*Node, error) {
-	// filter out any empty peer addresses
+	return tryCreateNode(ctx, cm, Config{
+		Mode:      ModeDefault,
+		PeerAddrs: filterPeerAddrs(peerAddrs),
+	})
+}
+
+// NewNodeWithRepo creates a new IPFS node in default mode, like NewNode, but
+// keeps its repo in repoPath so that its data and identity survive restarts.
+// It lets a bacalhau node run without a separate IPFS daemon.
+func NewNodeWithRepo(ctx context.Context, cm *system.CleanupManager, repoPath string, peerAddrs []string) (*Node, error) {
+	return tryCreateNode(ctx, cm, Config{
+		Mode:      ModeDefault,
+		PeerAddrs: filterPeerAddrs(peerAddrs),
+		RepoPath:  repoPath,
+	})
+}
+
+// filterPeerAddrs filters out any empty peer addresses.
+func filterPeerAddrs(peerAddrs []string) []string {
 	filteredPeerAddrs := make([]string, 0, len(peerAddrs))
 	for _, addr := range peerAddrs {
 		if addr != "" {
 			filteredPeerAddrs = append(filteredPeerAddrs, addr)
 		}
 	}
-	return tryCreateNode(ctx, cm, Config{
-		Mode:      ModeDefault,
-		PeerAddrs: filteredPeerAddrs,
-	})
+	return filteredPeerAddrs
 }
 
 // NewLocalNode creates a new local IPFS node in local mode, which can be used
@@ -298,9 +319,17 @@ func (n *Node) Client() (*Client, error) {
 	return NewClient(addrs[0])
 }
 
-// createNode spawns a new IPFS node using a temporary repo path.
+// createNode spawns a new IPFS node using the configured repo path, or a
+// temporary one.
 func createNode(ctx context.Context, cm *system.CleanupManager, cfg Config) (icore.CoreAPI, *core.IpfsNode, string, error) {
-	repoPath, err := os.MkdirTemp("", "ipfs-tmp")
+	temporary := cfg.RepoPath == ""
+	repoPath := cfg.RepoPath
+	var err error
+	if temporary {
+		repoPath, err = os.MkdirTemp("", "ipfs-tmp")
+	} else {
+		err = os.MkdirAll(repoPath, util.OS_USER_RWX)
+	}
 	if err != nil {
 		return nil, nil, "", fmt.Errorf("failed to create repo dir: %w", err)
 	}
@@ -317,19 +346,32 @@ func createNode(ctx context.Context, cm *system.CleanupManager, cfg Config) (ico
 				errs = multierror.Append(errs, fmt.Errorf("failed to close repo: %w", err))
 			}
 		}
+		if !temporary {
+			return errs
+		}
 		if err := os.RemoveAll(repoPath); err != nil { //nolint:govet
 			errs = multierror.Append(errs, fmt.Errorf("failed to clean up repo directory: %w", err))
 		}
 		return errs
 	})
 
-	if err = createRepo(repoPath, cfg.getMode(), cfg.getKeypairSize()); err != nil {
-		return nil, nil, "", fmt.Errorf("failed to create repo: %w", err)
+	reuseRepo := !temporary && fsrepo.IsInitialized(repoPath)
+	if !reuseRepo {
+		if err = createRepo(repoPath, cfg.getMode(), cfg.getKeypairSize()); err != nil {
+			return nil, nil, "", fmt.Errorf("failed to create repo: %w", err)
+		}
 	}
 
 	repo, err = fsrepo.Open(repoPath)
 	if err != nil {
-		return nil, nil, "", fmt.Errorf("failed to open temp repo: %w", err)
+		return nil, nil, "", fmt.Errorf("failed to open repo: %w", err)
+	}
+
+	if reuseRepo {
+		// the API port the repo was created with may have been taken since
+		if err = setFreeAPIPort(repo); err != nil {
+			return nil, nil, "", err
+		}
 	}
 
 	nodeOptions := &core.BuildCfg{
@@ -529,6 +571,21 @@ func createRepo(path string, mode NodeMode, keypairSize int) error {
 	return nil
 }
 
+// setFreeAPIPort points the API address of an existing repo at a free port.
+func setFreeAPIPort(repo kuboRepo.Repo) error {
+	apiPort, err := freeport.GetFreePort()
+	if err != nil {
+		return fmt.Errorf("could not create port for api: %w", err)
+	}
+	err = repo.SetConfigKey("Addresses.API", []string{
+		fmt.Sprintf("/ip4/127.0.0.1/tcp/%d", apiPort),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set api address: %w", err)
+	}
+	return nil
+}
+
 // loadPlugins initializes and injects the standard set of ipfs plugins.
 func loadPlugins() error {
 	plugins, err := loader.NewPluginLoader("")
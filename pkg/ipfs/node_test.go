@@ -96,6 +96,40 @@ func (suite *NodeSuite) TestFunctionality() {
 	require.Equal(suite.T(), testString, string(data))
 }
 
+// TestRepoPath tests that a node with a repo path keeps its repo, and so its
+// identity and data, across restarts.
+func (suite *NodeSuite) TestRepoPath() {
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(10*time.Second))
+	defer cancel()
+
+	repoPath := filepath.Join(suite.T().TempDir(), "ipfs")
+	start := func() (*Node, *Client, *system.CleanupManager) {
+		cm := system.NewCleanupManager()
+		n, err := tryCreateNode(ctx, cm, Config{Mode: ModeLocal, RepoPath: repoPath})
+		require.NoError(suite.T(), err)
+		cl, err := n.Client()
+		require.NoError(suite.T(), err)
+		require.NoError(suite.T(), cl.WaitUntilAvailable(ctx))
+		return n, cl, cm
+	}
+
+	n, cl, cm := start()
+	nodeID := n.ID()
+	filePath := filepath.Join(suite.T().TempDir(), "test.txt")
+	require.NoError(suite.T(), os.WriteFile(filePath, []byte(testString), 0600))
+	cid, err := cl.Put(ctx, filePath)
+	require.NoError(suite.T(), err)
+	cm.Cleanup()
+	require.DirExists(suite.T(), repoPath)
+
+	n, cl, cm = start()
+	defer cm.Cleanup()
+	require.Equal(suite.T(), nodeID, n.ID())
+	_, isPinned, err := cl.API.Pin().IsPinned(ctx, icorepath.New(cid))
+	require.NoError(suite.T(), err)
+	require.True(suite.T(), isPinned)
+}
+
 // a normal test function and pass our suite to suite.Run
 func TestNodeSuite(t *testing.T) {
 	suite.Run(t, new(NodeSuite))
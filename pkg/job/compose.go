@@ -0,0 +1,400 @@
+package job
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/c2h5oh/datasize"
+	"github.com/docker/go-units"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"sigs.k8s.io/yaml"
+)
+
+// defaultTmpfsSize is the size of the scratch volumes made from tmpfs mounts
+// that don't say how big they are, which Bacalhau requires.
+const defaultTmpfsSize = "64Mb"
+
+// composeKeys are the keys of a docker-compose service that
+// JobFromCompose translates. Any other key is warned about.
+var composeKeys = map[string]bool{
+	"image": true, "entrypoint": true, "command": true, "environment": true,
+	"working_dir": true, "user": true, "volumes": true, "tmpfs": true,
+	"cpus": true, "mem_limit": true, "labels": true, "deploy": true,
+	// names of the container, which mean nothing to a job
+	"container_name": true, "hostname": true,
+}
+
+type composeFile struct {
+	Services map[string]json.RawMessage `json:"services"`
+}
+
+type composeService struct {
+	Image       string          `json:"image"`
+	Entrypoint  composeCommand  `json:"entrypoint"`
+	Command     composeCommand  `json:"command"`
+	Environment composeMapping  `json:"environment"`
+	WorkingDir  string          `json:"working_dir"`
+	User        string          `json:"user"`
+	Volumes     []composeVolume `json:"volumes"`
+	Tmpfs       composeCommand  `json:"tmpfs"`
+	CPUs        composeScalar   `json:"cpus"`
+	MemLimit    composeScalar   `json:"mem_limit"`
+	Labels      composeMapping  `json:"labels"`
+	Deploy      struct {
+		Replicas  int `json:"replicas"`
+		Resources struct {
+			Limits struct {
+				CPUs   composeScalar `json:"cpus"`
+				Memory composeScalar `json:"memory"`
+			} `json:"limits"`
+			Reservations struct {
+				Devices []struct {
+					Capabilities []string      `json:"capabilities"`
+					Count        composeScalar `json:"count"`
+				} `json:"devices"`
+			} `json:"reservations"`
+		} `json:"resources"`
+	} `json:"deploy"`
+}
+
+// composeCommand is a command, or list of paths, given either as a string or
+// as a list of strings.
+type composeCommand struct {
+	words []string
+}
+
+func (c *composeCommand) UnmarshalJSON(data []byte) error {
+	var words []string
+	if err := json.Unmarshal(data, &words); err == nil {
+		c.words = words
+		return nil
+	}
+	var line string
+	if err := json.Unmarshal(data, &line); err != nil {
+		return fmt.Errorf("expected a string or a list of strings, got %s", data)
+	}
+	words, err := splitShellWords(line)
+	if err != nil {
+		return err
+	}
+	c.words = words
+	return nil
+}
+
+// composeMapping is a map given either as a map or as a list of KEY=value.
+// Keys given without a value, which take that of the host, map to nil.
+type composeMapping map[string]*string
+
+func (m *composeMapping) UnmarshalJSON(data []byte) error {
+	*m = composeMapping{}
+	var list []string
+	if err := json.Unmarshal(data, &list); err == nil {
+		for _, item := range list {
+			key, value, ok := strings.Cut(item, "=")
+			if ok {
+				(*m)[key] = &value
+			} else {
+				(*m)[key] = nil
+			}
+		}
+		return nil
+	}
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("expected a map or a list of KEY=value, got %s", data)
+	}
+	for key, raw := range values {
+		if string(raw) == "null" {
+			(*m)[key] = nil
+			continue
+		}
+		var value composeScalar
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return err
+		}
+		str := string(value)
+		(*m)[key] = &str
+	}
+	return nil
+}
+
+// composeScalar is a string, number or boolean, as a string.
+type composeScalar string
+
+func (s *composeScalar) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err == nil {
+		*s = composeScalar(str)
+		return nil
+	}
+	if string(data) == "null" {
+		*s = ""
+		return nil
+	}
+	*s = composeScalar(data)
+	return nil
+}
+
+// composeVolume is a volume mount, given either in the short SOURCE:TARGET:MODE
+// syntax or in the long one.
+type composeVolume struct {
+	Type     string `json:"type"`
+	Source   string `json:"source"`
+	Target   string `json:"target"`
+	ReadOnly bool   `json:"read_only"`
+	Tmpfs    struct {
+		Size composeScalar `json:"size"`
+	} `json:"tmpfs"`
+}
+
+func (v *composeVolume) UnmarshalJSON(data []byte) error {
+	var short string
+	if err := json.Unmarshal(data, &short); err != nil {
+		type long composeVolume
+		return json.Unmarshal(data, (*long)(v))
+	}
+	parts := strings.Split(short, ":")
+	switch len(parts) {
+	case 1:
+		v.Target = parts[0]
+	case 2, 3:
+		v.Source, v.Target = parts[0], parts[1]
+		if len(parts) == 3 {
+			for _, option := range strings.Split(parts[2], ",") {
+				v.ReadOnly = v.ReadOnly || option == "ro"
+			}
+		}
+	default:
+		return fmt.Errorf("invalid volume %q", short)
+	}
+	return nil
+}
+
+// JobFromCompose translates a service of a docker-compose file into a docker
+// job. The service can be left empty if the file only has one. What the job
+// can't do like the service would is returned as warnings, e.g. publishing
+// ports or mounting host directories, which have to be given as inputs.
+func JobFromCompose(data []byte, service string) (*model.Job, []string, error) {
+	var file composeFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, nil, fmt.Errorf("error parsing docker-compose file: %w", err)
+	}
+	names := make([]string, 0, len(file.Services))
+	for name := range file.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if service == "" {
+		if len(names) != 1 {
+			return nil, nil, fmt.Errorf("the docker-compose file has services %s, pick one", strings.Join(names, ", "))
+		}
+		service = names[0]
+	}
+	raw, ok := file.Services[service]
+	if !ok {
+		return nil, nil, fmt.Errorf("the docker-compose file has no service %q, only %s", service, strings.Join(names, ", "))
+	}
+
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &keys); err != nil {
+		return nil, nil, fmt.Errorf("error parsing service %s: %w", service, err)
+	}
+	var s composeService
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return nil, nil, fmt.Errorf("error parsing service %s: %w", service, err)
+	}
+	if s.Image == "" {
+		return nil, nil, fmt.Errorf("service %s has no image, services that are built can't be run as jobs", service)
+	}
+
+	var warnings []string
+	for key := range keys {
+		if !composeKeys[key] {
+			warnings = append(warnings, fmt.Sprintf("%s is not supported and was ignored", key))
+		}
+	}
+
+	j, err := model.NewJobWithSaneProductionDefaults()
+	if err != nil {
+		return nil, nil, err
+	}
+	j.Spec.Docker = model.JobSpecDocker{
+		Image:            s.Image,
+		WorkingDirectory: s.WorkingDir,
+		User:             s.User,
+	}
+	j.Spec.Docker.Entrypoint, warnings = convertEntrypoint(s.Entrypoint.words, s.Command.words, "command", warnings)
+
+	for _, key := range sortedKeys(s.Environment) {
+		if value := s.Environment[key]; value != nil {
+			j.Spec.Docker.EnvironmentVariables = append(j.Spec.Docker.EnvironmentVariables, key+"="+*value)
+		} else {
+			warnings = append(warnings, fmt.Sprintf("environment variable %s takes its value from the host and was ignored", key))
+		}
+	}
+	for _, key := range sortedKeys(s.Labels) {
+		value := ""
+		if s.Labels[key] != nil {
+			value = *s.Labels[key]
+		}
+		j.Spec.Annotations, warnings = convertLabel(j.Spec.Annotations, key, value, warnings)
+	}
+
+	cpus, memory := s.CPUs, s.MemLimit
+	if limits := s.Deploy.Resources.Limits; limits.CPUs != "" || limits.Memory != "" {
+		cpus, memory = limits.CPUs, limits.Memory
+	}
+	if cpus != "" {
+		cpu, err := strconv.ParseFloat(string(cpus), 64)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid cpus %q: %w", cpus, err)
+		}
+		j.Spec.Resources.CPU = fmt.Sprintf("%dm", int64(cpu*1000))
+	}
+	if memory != "" {
+		bytes, err := units.RAMInBytes(string(memory))
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid memory %q: %w", memory, err)
+		}
+		j.Spec.Resources.Memory = datasize.ByteSize(bytes).String()
+	}
+	for _, device := range s.Deploy.Resources.Reservations.Devices {
+		for _, capability := range device.Capabilities {
+			if capability != "gpu" {
+				continue
+			}
+			switch device.Count {
+			case "", "all":
+				j.Spec.Resources.GPU = "1"
+				warnings = append(warnings, "all GPUs were requested, the job asks for one")
+			default:
+				j.Spec.Resources.GPU = string(device.Count)
+			}
+		}
+	}
+	if s.Deploy.Replicas > 1 {
+		warnings = append(warnings, fmt.Sprintf(
+			"the service has %d replicas, the job runs once: use sharding to split work between shards", s.Deploy.Replicas))
+	}
+
+	var outputs []string
+	for _, volume := range s.Volumes {
+		switch {
+		case volume.Type == "tmpfs":
+			j.Spec.Scratch, warnings = convertTmpfs(j.Spec.Scratch, volume.Target, string(volume.Tmpfs.Size), warnings)
+		case volume.ReadOnly:
+			warnings = append(warnings, fmt.Sprintf(
+				"read-only volume %s was ignored, give the data mounted at %s as an input", volume.Source, volume.Target))
+		default:
+			outputs = append(outputs, outputName(volume.Target)+":"+volume.Target)
+		}
+	}
+	for _, target := range s.Tmpfs.words {
+		j.Spec.Scratch, warnings = convertTmpfs(j.Spec.Scratch, target, "", warnings)
+	}
+	if j.Spec.Outputs, err = buildJobOutputs(outputs); err != nil {
+		return nil, nil, err
+	}
+
+	sort.Strings(warnings)
+	return j, warnings, nil
+}
+
+// convertEntrypoint returns the entrypoint a job runs for a container with
+// the entrypoint and command. The entrypoint of a job replaces that of the
+// image, so containers that only override the command run it as the
+// entrypoint.
+func convertEntrypoint(entrypoint, command []string, commandKey string, warnings []string) ([]string, []string) {
+	if len(entrypoint) == 0 && len(command) > 0 {
+		warnings = append(warnings, fmt.Sprintf(
+			"%s is run as the entrypoint of the job, which replaces that of the image", commandKey))
+	}
+	return append(append([]string{}, entrypoint...), command...), warnings
+}
+
+// convertLabel adds a label of a container to the annotations of a job, as
+// key:value, if it is a safe annotation.
+func convertLabel(annotations []string, key, value string, warnings []string) ([]string, []string) {
+	annotation := key
+	if value != "" {
+		annotation += ":" + value
+	}
+	if !IsSafeAnnotation(annotation) {
+		return annotations, append(warnings, fmt.Sprintf(
+			"label %s can't be an annotation and was ignored, annotations must match /%s/", key, RegexString))
+	}
+	return append(annotations, annotation), warnings
+}
+
+func convertTmpfs(scratch []model.ScratchVolume, target, size string, warnings []string) ([]model.ScratchVolume, []string) {
+	if size == "" {
+		size = defaultTmpfsSize
+		warnings = append(warnings, fmt.Sprintf("tmpfs %s has no size, it is limited to %s", target, defaultTmpfsSize))
+	} else if bytes, err := units.RAMInBytes(size); err == nil {
+		size = datasize.ByteSize(bytes).String()
+	}
+	return append(scratch, model.ScratchVolume{Path: target, Size: size, Tmpfs: true}), warnings
+}
+
+// outputName returns the name of the output volume mounted at the path.
+func outputName(target string) string {
+	name := SafeStringStripper(path.Base(path.Clean(target)))
+	if name == "" || name == "." {
+		return "outputs"
+	}
+	return name
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// splitShellWords splits a command line into words like a shell would,
+// without expanding anything.
+func splitShellWords(line string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord, quote, escaped := false, rune(0), false
+	for _, r := range line {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, inWord = true, true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inWord = r, true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, fmt.Errorf("unterminated quote or escape in %q", line)
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
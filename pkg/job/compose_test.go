@@ -0,0 +1,102 @@
+//go:build unit || !integration
+
+package job
+
+import (
+	"testing"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/stretchr/testify/require"
+)
+
+const testComposeFile = `
+services:
+  web:
+    image: nginx
+    ports: ["80:80"]
+  train:
+    image: pytorch/pytorch:latest
+    command: python "train model.py" --epochs 3
+    environment:
+      EPOCHS: 3
+      TOKEN:
+    working_dir: /work
+    user: "1000:1000"
+    labels:
+      - team=ml
+    volumes:
+      - ./data:/data:ro
+      - results:/results
+      - type: tmpfs
+        target: /cache
+        tmpfs:
+          size: 100m
+    tmpfs: /run
+    restart: always
+    deploy:
+      resources:
+        limits:
+          cpus: "0.5"
+          memory: 2g
+        reservations:
+          devices:
+            - capabilities: [gpu]
+              count: 1
+`
+
+func TestJobFromCompose(t *testing.T) {
+	j, warnings, err := JobFromCompose([]byte(testComposeFile), "train")
+	require.NoError(t, err)
+
+	require.Equal(t, model.EngineDocker, j.Spec.Engine)
+	require.Equal(t, model.JobSpecDocker{
+		Image:                "pytorch/pytorch:latest",
+		Entrypoint:           []string{"python", "train model.py", "--epochs", "3"},
+		EnvironmentVariables: []string{"EPOCHS=3"},
+		WorkingDirectory:     "/work",
+		User:                 "1000:1000",
+	}, j.Spec.Docker)
+	require.Equal(t, model.ResourceUsageConfig{CPU: "500m", Memory: "2GB", GPU: "1"}, j.Spec.Resources)
+	require.Equal(t, []string{"team:ml"}, j.Spec.Annotations)
+	require.Equal(t, []model.ScratchVolume{
+		{Path: "/cache", Size: "100MB", Tmpfs: true},
+		{Path: "/run", Size: defaultTmpfsSize, Tmpfs: true},
+	}, j.Spec.Scratch)
+	require.ElementsMatch(t, []model.StorageSpec{
+		{StorageSource: model.StorageSourceIPFS, Name: "results", Path: "/results"},
+		{StorageSource: model.StorageSourceIPFS, Name: "outputs", Path: "/outputs"},
+	}, j.Spec.Outputs)
+
+	require.Equal(t, []string{
+		"command is run as the entrypoint of the job, which replaces that of the image",
+		"environment variable TOKEN takes its value from the host and was ignored",
+		"read-only volume ./data was ignored, give the data mounted at /data as an input",
+		"restart is not supported and was ignored",
+		"tmpfs /run has no size, it is limited to " + defaultTmpfsSize,
+	}, warnings)
+}
+
+func TestJobFromComposeServices(t *testing.T) {
+	_, _, err := JobFromCompose([]byte(testComposeFile), "")
+	require.ErrorContains(t, err, "train, web")
+
+	_, _, err = JobFromCompose([]byte(testComposeFile), "db")
+	require.ErrorContains(t, err, `no service "db"`)
+
+	j, warnings, err := JobFromCompose([]byte("services:\n  app:\n    image: ubuntu\n"), "")
+	require.NoError(t, err)
+	require.Equal(t, "ubuntu", j.Spec.Docker.Image)
+	require.Empty(t, warnings)
+
+	_, _, err = JobFromCompose([]byte("services:\n  app:\n    build: .\n"), "")
+	require.ErrorContains(t, err, "no image")
+}
+
+func TestSplitShellWords(t *testing.T) {
+	words, err := splitShellWords(`sh -c 'echo "$HOME"' a\ b ""`)
+	require.NoError(t, err)
+	require.Equal(t, []string{"sh", "-c", `echo "$HOME"`, "a b", ""}, words)
+
+	_, err = splitShellWords(`echo "unterminated`)
+	require.Error(t, err)
+}
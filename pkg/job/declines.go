@@ -0,0 +1,62 @@
+package job
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+)
+
+// GetBidDeclines groups the bid declined events of a job by their reason,
+// with the reasons given by the most nodes first.
+func GetBidDeclines(events []model.JobEvent) []model.BidDecline {
+	var declines []model.BidDecline
+	byReason := map[string]int{}
+	seen := map[[2]string]bool{}
+	for _, event := range events {
+		if event.EventName != model.JobEventBidDeclined {
+			continue
+		}
+		// nodes decline every shard of a job for the same reason
+		key := [2]string{event.Status, event.SourceNodeID}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		i, ok := byReason[event.Status]
+		if !ok {
+			i = len(declines)
+			byReason[event.Status] = i
+			declines = append(declines, model.BidDecline{Reason: event.Status})
+		}
+		declines[i].NodeIDs = append(declines[i].NodeIDs, event.SourceNodeID)
+	}
+	sort.SliceStable(declines, func(i, j int) bool {
+		return len(declines[i].NodeIDs) > len(declines[j].NodeIDs)
+	})
+	return declines
+}
+
+// SummarizeBidDeclines describes why nodes declined to bid on a job in a
+// line, e.g. "3 nodes declined to bid: not enough GPUs (2), image not
+// allowed (1)", or is empty if no node said.
+func SummarizeBidDeclines(declines []model.BidDecline) string {
+	if len(declines) == 0 {
+		return ""
+	}
+	nodes := map[string]bool{}
+	reasons := make([]string, 0, len(declines))
+	for _, decline := range declines {
+		for _, nodeID := range decline.NodeIDs {
+			nodes[nodeID] = true
+		}
+		reasons = append(reasons, fmt.Sprintf("%s (%d)", decline.Reason, len(decline.NodeIDs)))
+	}
+	noun := "nodes"
+	if len(nodes) == 1 {
+		noun = "node"
+	}
+	return fmt.Sprintf("%d %s declined to bid: %s", len(nodes), noun, strings.Join(reasons, ", "))
+}
@@ -0,0 +1,39 @@
+//go:build unit || !integration
+
+package job
+
+import (
+	"testing"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBidDeclines(t *testing.T) {
+	decline := func(nodeID string, shardIndex int, reason string) model.JobEvent {
+		return model.JobEvent{
+			EventName:    model.JobEventBidDeclined,
+			SourceNodeID: nodeID,
+			ShardIndex:   shardIndex,
+			Status:       reason,
+		}
+	}
+	events := []model.JobEvent{
+		{EventName: model.JobEventCreated, SourceNodeID: "requester"},
+		decline("node-1", 0, "image not allowed"),
+		decline("node-2", 0, "not enough GPUs"),
+		decline("node-2", 1, "not enough GPUs"),
+		{EventName: model.JobEventBid, SourceNodeID: "node-3"},
+		decline("node-4", 0, "not enough GPUs"),
+	}
+
+	declines := GetBidDeclines(events)
+	require.Equal(t, []model.BidDecline{
+		{Reason: "not enough GPUs", NodeIDs: []string{"node-2", "node-4"}},
+		{Reason: "image not allowed", NodeIDs: []string{"node-1"}},
+	}, declines)
+	require.Equal(t, "3 nodes declined to bid: not enough GPUs (2), image not allowed (1)", SummarizeBidDeclines(declines))
+
+	require.Equal(t, "1 node declined to bid: image not allowed (1)", SummarizeBidDeclines(declines[1:]))
+	require.Empty(t, SummarizeBidDeclines(GetBidDeclines(events[:1])))
+}
@@ -0,0 +1,196 @@
+package job
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/c2h5oh/datasize"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/yaml"
+)
+
+// kubernetesGPUResource is the resource NVIDIA's device plugin exposes GPUs as.
+const kubernetesGPUResource corev1.ResourceName = "nvidia.com/gpu"
+
+// JobFromKubernetesJob translates a container of a Kubernetes Job manifest
+// into a docker job. The container can be left empty if the pod only has
+// one. What the job can't do like the pod would is returned as warnings,
+// e.g. mounting persistent volume claims, which have to be given as inputs.
+func JobFromKubernetesJob(data []byte, containerName string) (*model.Job, []string, error) { //nolint:funlen,gocyclo
+	var kubeJob batchv1.Job
+	if err := yaml.Unmarshal(data, &kubeJob); err != nil {
+		return nil, nil, fmt.Errorf("error parsing Kubernetes manifest: %w", err)
+	}
+	if kubeJob.Kind != "Job" {
+		return nil, nil, fmt.Errorf("expected a Kubernetes Job, got a %q", kubeJob.Kind)
+	}
+	pod := kubeJob.Spec.Template.Spec
+
+	var names []string
+	var container *corev1.Container
+	for i := range pod.Containers {
+		names = append(names, pod.Containers[i].Name)
+		if pod.Containers[i].Name == containerName {
+			container = &pod.Containers[i]
+		}
+	}
+	if containerName == "" {
+		if len(pod.Containers) != 1 {
+			return nil, nil, fmt.Errorf("the pod has containers %s, pick one", strings.Join(names, ", "))
+		}
+		container = &pod.Containers[0]
+	}
+	if container == nil {
+		return nil, nil, fmt.Errorf("the pod has no container %q, only %s", containerName, strings.Join(names, ", "))
+	}
+	if container.Image == "" {
+		return nil, nil, fmt.Errorf("container %s has no image", container.Name)
+	}
+
+	var warnings []string
+	if len(pod.Containers) > 1 {
+		warnings = append(warnings, fmt.Sprintf("only container %s is run, the others were ignored", container.Name))
+	}
+	if len(pod.InitContainers) > 0 {
+		warnings = append(warnings, "init containers are not supported and were ignored")
+	}
+	if parallelism := kubeJob.Spec.Parallelism; parallelism != nil && *parallelism > 1 {
+		warnings = append(warnings, fmt.Sprintf(
+			"the job has a parallelism of %d, it runs once: use sharding to split work between shards", *parallelism))
+	}
+	if completions := kubeJob.Spec.Completions; completions != nil && *completions > 1 {
+		warnings = append(warnings, fmt.Sprintf("the job has %d completions, it runs once", *completions))
+	}
+	if len(container.Ports) > 0 {
+		warnings = append(warnings, "ports are not supported and were ignored, jobs have no network")
+	}
+	if len(container.EnvFrom) > 0 {
+		warnings = append(warnings, "envFrom is not supported and was ignored")
+	}
+
+	j, err := model.NewJobWithSaneProductionDefaults()
+	if err != nil {
+		return nil, nil, err
+	}
+	j.Spec.Docker = model.JobSpecDocker{
+		Image:            container.Image,
+		WorkingDirectory: container.WorkingDir,
+		User:             kubernetesUser(pod.SecurityContext, container.SecurityContext),
+	}
+	j.Spec.Docker.Entrypoint, warnings = convertEntrypoint(container.Command, container.Args, "args", warnings)
+	if deadline := kubeJob.Spec.ActiveDeadlineSeconds; deadline != nil {
+		j.Spec.Timeout = float64(*deadline)
+	}
+
+	for _, env := range container.Env {
+		switch {
+		case env.ValueFrom == nil:
+			j.Spec.Docker.EnvironmentVariables = append(j.Spec.Docker.EnvironmentVariables, env.Name+"="+env.Value)
+		case env.ValueFrom.SecretKeyRef != nil:
+			ref := env.ValueFrom.SecretKeyRef
+			j.Spec.Secrets = append(j.Spec.Secrets, model.SecretSpec{Name: ref.Key, Env: env.Name})
+			warnings = append(warnings, fmt.Sprintf(
+				"environment variable %s is injected from secret %s of the compute node, rather than key %s of secret %s",
+				env.Name, ref.Key, ref.Key, ref.Name))
+		default:
+			warnings = append(warnings, fmt.Sprintf("environment variable %s is not a value or a secret and was ignored", env.Name))
+		}
+	}
+
+	labels := kubeJob.Labels
+	for _, key := range sortedKeys(labels) {
+		j.Spec.Annotations, warnings = convertLabel(j.Spec.Annotations, key, labels[key], warnings)
+	}
+
+	// Bacalhau reserves what a job asks for, which is closer to the limits
+	// of a container than to what it requests
+	resources := corev1.ResourceList{}
+	for name, quantity := range container.Resources.Requests {
+		resources[name] = quantity
+	}
+	for name, quantity := range container.Resources.Limits {
+		resources[name] = quantity
+	}
+	if cpu, ok := resources[corev1.ResourceCPU]; ok {
+		j.Spec.Resources.CPU = cpu.String()
+	}
+	if memory, ok := resources[corev1.ResourceMemory]; ok {
+		j.Spec.Resources.Memory = kubernetesBytes(memory)
+	}
+	if disk, ok := resources[corev1.ResourceEphemeralStorage]; ok {
+		j.Spec.Resources.Disk = kubernetesBytes(disk)
+	}
+	if gpu, ok := resources[kubernetesGPUResource]; ok {
+		j.Spec.Resources.GPU = gpu.String()
+	}
+
+	volumes := map[string]corev1.Volume{}
+	for _, volume := range pod.Volumes {
+		volumes[volume.Name] = volume
+	}
+	var outputs []string
+	for _, mount := range container.VolumeMounts {
+		volume := volumes[mount.Name]
+		switch {
+		case volume.EmptyDir != nil:
+			size := ""
+			if volume.EmptyDir.SizeLimit != nil {
+				size = kubernetesBytes(*volume.EmptyDir.SizeLimit)
+			}
+			if volume.EmptyDir.Medium == corev1.StorageMediumMemory {
+				j.Spec.Scratch, warnings = convertTmpfs(j.Spec.Scratch, mount.MountPath, size, warnings)
+			} else {
+				if size == "" {
+					size = defaultTmpfsSize
+					warnings = append(warnings, fmt.Sprintf("emptyDir %s has no size limit, it is limited to %s", mount.Name, size))
+				}
+				j.Spec.Scratch = append(j.Spec.Scratch, model.ScratchVolume{Path: mount.MountPath, Size: size})
+			}
+		case mount.ReadOnly:
+			warnings = append(warnings, fmt.Sprintf(
+				"read-only volume %s was ignored, give the data mounted at %s as an input", mount.Name, mount.MountPath))
+		default:
+			outputs = append(outputs, outputName(mount.MountPath)+":"+mount.MountPath)
+		}
+	}
+	if j.Spec.Outputs, err = buildJobOutputs(outputs); err != nil {
+		return nil, nil, err
+	}
+
+	sort.Strings(warnings)
+	return j, warnings, nil
+}
+
+// kubernetesUser returns the user a container runs as, as UID:GID, with the
+// security context of the container overriding that of the pod.
+func kubernetesUser(pod *corev1.PodSecurityContext, container *corev1.SecurityContext) string {
+	var user, group *int64
+	if pod != nil {
+		user, group = pod.RunAsUser, pod.RunAsGroup
+	}
+	if container != nil {
+		if container.RunAsUser != nil {
+			user = container.RunAsUser
+		}
+		if container.RunAsGroup != nil {
+			group = container.RunAsGroup
+		}
+	}
+	if user == nil {
+		return ""
+	}
+	if group == nil {
+		return fmt.Sprint(*user)
+	}
+	return fmt.Sprintf("%d:%d", *user, *group)
+}
+
+// kubernetesBytes returns a quantity of bytes such as 512Mi in the units
+// Bacalhau reads.
+func kubernetesBytes(quantity resource.Quantity) string {
+	return datasize.ByteSize(quantity.Value()).String()
+}
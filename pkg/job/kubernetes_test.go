@@ -0,0 +1,111 @@
+//go:build unit || !integration
+
+package job
+
+import (
+	"testing"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/stretchr/testify/require"
+)
+
+const testKubernetesJob = `
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: pi
+  labels:
+    app: pi
+    app.kubernetes.io/name: pi
+spec:
+  activeDeadlineSeconds: 600
+  parallelism: 4
+  template:
+    spec:
+      securityContext:
+        runAsUser: 1000
+        runAsGroup: 100
+      restartPolicy: Never
+      containers:
+      - name: pi
+        image: perl:5.34.0
+        command: ["perl", "-Mbignum=bpi", "-wle"]
+        args: ["print bpi(2000)"]
+        workingDir: /work
+        env:
+        - name: DIGITS
+          value: "2000"
+        - name: API_KEY
+          valueFrom:
+            secretKeyRef:
+              name: creds
+              key: api-key
+        - name: NODE
+          valueFrom:
+            fieldRef:
+              fieldPath: spec.nodeName
+        resources:
+          requests:
+            cpu: 250m
+            memory: 64Mi
+          limits:
+            memory: 512Mi
+            nvidia.com/gpu: 1
+        volumeMounts:
+        - name: scratch
+          mountPath: /scratch
+        - name: dataset
+          mountPath: /data
+          readOnly: true
+        - name: results
+          mountPath: /results
+      volumes:
+      - name: scratch
+        emptyDir:
+          medium: Memory
+          sizeLimit: 1Gi
+      - name: dataset
+        persistentVolumeClaim:
+          claimName: dataset
+      - name: results
+        persistentVolumeClaim:
+          claimName: results
+`
+
+func TestJobFromKubernetesJob(t *testing.T) {
+	j, warnings, err := JobFromKubernetesJob([]byte(testKubernetesJob), "")
+	require.NoError(t, err)
+
+	require.Equal(t, model.JobSpecDocker{
+		Image:                "perl:5.34.0",
+		Entrypoint:           []string{"perl", "-Mbignum=bpi", "-wle", "print bpi(2000)"},
+		EnvironmentVariables: []string{"DIGITS=2000"},
+		WorkingDirectory:     "/work",
+		User:                 "1000:100",
+	}, j.Spec.Docker)
+	require.Equal(t, []model.SecretSpec{{Name: "api-key", Env: "API_KEY"}}, j.Spec.Secrets)
+	require.Equal(t, model.ResourceUsageConfig{CPU: "250m", Memory: "512MB", GPU: "1"}, j.Spec.Resources)
+	require.Equal(t, 600.0, j.Spec.Timeout)
+	require.Equal(t, []string{"app:pi"}, j.Spec.Annotations)
+	require.Equal(t, []model.ScratchVolume{{Path: "/scratch", Size: "1GB", Tmpfs: true}}, j.Spec.Scratch)
+	require.ElementsMatch(t, []model.StorageSpec{
+		{StorageSource: model.StorageSourceIPFS, Name: "results", Path: "/results"},
+		{StorageSource: model.StorageSourceIPFS, Name: "outputs", Path: "/outputs"},
+	}, j.Spec.Outputs)
+
+	require.Equal(t, []string{
+		"environment variable API_KEY is injected from secret api-key of the compute node, rather than key api-key of secret creds",
+		"environment variable NODE is not a value or a secret and was ignored",
+		"label app.kubernetes.io/name can't be an annotation and was ignored, annotations must match /" + RegexString + "/",
+		"read-only volume dataset was ignored, give the data mounted at /data as an input",
+		"the job has a parallelism of 4, it runs once: use sharding to split work between shards",
+	}, warnings)
+}
+
+func TestJobFromKubernetesJobErrors(t *testing.T) {
+	_, _, err := JobFromKubernetesJob([]byte("apiVersion: v1\nkind: Pod\n"), "")
+	require.ErrorContains(t, err, `got a "Pod"`)
+
+	_, _, err = JobFromKubernetesJob([]byte(testKubernetesJob), "sidecar")
+	require.ErrorContains(t, err, `no container "sidecar"`)
+}
@@ -6,6 +6,7 @@ import (
 	"math"
 	"time"
 
+	"github.com/filecoin-project/bacalhau/pkg/bacerrors"
 	"github.com/filecoin-project/bacalhau/pkg/model"
 	"github.com/filecoin-project/bacalhau/pkg/system"
 	"github.com/rs/zerolog/log"
@@ -14,15 +15,40 @@ import (
 type JobLoader func(ctx context.Context, id string) (*model.Job, error)
 type StateLoader func(ctx context.Context, id string) (model.JobState, error)
 
+// StateSubscriber subscribes to the changes of a job. The returned channel
+// is sent to whenever the state of the job may have changed, and is closed
+// once ctx is done or the subscription is lost.
+type StateSubscriber func(ctx context.Context, id string) (<-chan struct{}, error)
+
 // a function that is given a map of nodeid -> job states
 // and will throw an error if anything about that is wrong
 type CheckStatesFunction func(model.JobState) (bool, error)
 
+// waitJitter is the fraction by which the delay between polls of the state
+// of a job is randomized, so that many waiters don't poll at once.
+const waitJitter = 0.1
+
+// DefaultWaitBackoff and DefaultMaxWaitDelay are how much the clients and the
+// requester grow the delay between polls of the state of a job, and up to
+// what, so that jobs that take a while aren't polled as often as those that
+// complete quickly.
+const (
+	DefaultWaitBackoff  = 1.5
+	DefaultMaxWaitDelay = 2 * time.Second
+)
+
 type StateResolver struct {
 	jobLoader       JobLoader
 	stateLoader     StateLoader
+	stateSubscriber StateSubscriber
 	maxWaitAttempts int
 	waitDelay       time.Duration
+	// how much the wait delay grows after each poll, and up to what
+	waitBackoff  float64
+	maxWaitDelay time.Duration
+	// how often the state is polled while subscribed, in case a change is
+	// missed
+	subscribedPollDelay time.Duration
 }
 
 func NewStateResolver(
@@ -50,6 +76,25 @@ func (resolver *StateResolver) SetWaitTime(maxWaitAttempts int, delay time.Durat
 	resolver.waitDelay = delay
 }
 
+// SetWaitBackoff makes Wait multiply the delay between polls of the state of
+// the job by backoff after each poll, up to maxDelay, for waits on jobs that
+// take a while not to poll them as often as those that complete quickly.
+func (resolver *StateResolver) SetWaitBackoff(backoff float64, maxDelay time.Duration) {
+	resolver.waitBackoff = backoff
+	resolver.maxWaitDelay = maxDelay
+}
+
+// SetStateSubscriber makes Wait check the state of the job whenever the
+// subscriber says it may have changed, rather than polling it every wait
+// delay. The state is still polled about every pollDelay, and from about
+// every wait delay with backoff if the subscription is lost, with jitter so
+// that many waiters don't poll at once. Waits still give up after max attempts times the
+// wait delay.
+func (resolver *StateResolver) SetStateSubscriber(subscriber StateSubscriber, pollDelay time.Duration) {
+	resolver.stateSubscriber = subscriber
+	resolver.subscribedPollDelay = pollDelay
+}
+
 func (resolver *StateResolver) GetShards(ctx context.Context, jobID string) ([]model.JobShardState, error) {
 	jobState, err := resolver.stateLoader(ctx, jobID)
 	if err != nil {
@@ -159,51 +204,119 @@ func (resolver *StateResolver) WaitWithOptions(
 	options WaitOptions,
 	checkJobStateFunctions ...CheckStatesFunction,
 ) error {
-	waiter := &system.FunctionWaiter{
-		Name:        "wait for job",
-		MaxAttempts: resolver.maxWaitAttempts,
-		Delay:       resolver.waitDelay,
-		Handler: func() (bool, error) {
-			jobState, err := resolver.stateLoader(ctx, options.JobID)
-			if err != nil {
+	check := func() (bool, error) {
+		jobState, err := resolver.stateLoader(ctx, options.JobID)
+		if err != nil {
+			// a job that doesn't exist won't appear by waiting for it, but
+			// the requester may be unreachable for a while
+			if _, ok := err.(*bacerrors.JobNotFound); ok {
 				return false, err
 			}
+			return false, system.NewRetryableError(err)
+		}
 
-			allOk := true
-			for _, checkFunction := range checkJobStateFunctions {
-				stepOk, checkErr := checkFunction(jobState)
-				if checkErr != nil {
-					return false, checkErr
-				}
-				if !stepOk {
-					allOk = false
-				}
+		allOk := true
+		for _, checkFunction := range checkJobStateFunctions {
+			stepOk, checkErr := checkFunction(jobState)
+			if checkErr != nil {
+				return false, checkErr
 			}
-
-			if allOk {
-				return allOk, nil
+			if !stepOk {
+				allOk = false
 			}
+		}
 
-			// some of the check functions returned false
-			// let's see if we can quit early because all expected states are
-			// in terminal state
-			allTerminal, err := WaitForTerminalStates(options.TotalShards)(jobState)
-			if err != nil {
-				return false, err
-			}
+		if allOk {
+			return allOk, nil
+		}
 
-			// If all the jobs are in terminal states, then nothing is going
-			// to change if we keep polling, so we should exit early.
-			if allTerminal && !options.AllowAllTerminal {
-				return false, fmt.Errorf("all jobs are in terminal states and conditions aren't met")
-			}
-			return false, nil
-		},
+		// some of the check functions returned false
+		// let's see if we can quit early because all expected states are
+		// in terminal state
+		allTerminal, err := WaitForTerminalStates(options.TotalShards)(jobState)
+		if err != nil {
+			return false, err
+		}
+
+		// If all the jobs are in terminal states, then nothing is going
+		// to change if we keep polling, so we should exit early.
+		if allTerminal && !options.AllowAllTerminal {
+			return false, fmt.Errorf("all jobs are in terminal states and conditions aren't met")
+		}
+		return false, nil
 	}
 
+	if resolver.stateSubscriber != nil {
+		return resolver.waitWithSubscription(ctx, options.JobID, check)
+	}
+
+	waiter := &system.FunctionWaiter{
+		Name:        "wait for job",
+		MaxAttempts: resolver.maxWaitAttempts,
+		Delay:       resolver.waitDelay,
+		Backoff:     resolver.waitBackoff,
+		MaxDelay:    resolver.maxWaitDelay,
+		Jitter:      waitJitter,
+		Handler:     check,
+	}
 	return waiter.Wait(ctx)
 }
 
+// waitWithSubscription calls check whenever the state subscriber says the
+// job may have changed, and every jittered poll delay, or jittered and backed
+// off wait delay once the subscription is lost, until check is done.
+func (resolver *StateResolver) waitWithSubscription(ctx context.Context, jobID string, check func() (bool, error)) error {
+	maxWait := time.Duration(resolver.maxWaitAttempts) * resolver.waitDelay
+	ctx, cancel := context.WithTimeout(ctx, maxWait)
+	defer cancel()
+
+	// subscribe before checking so that no change is missed in between
+	changed, err := resolver.stateSubscriber(ctx, jobID)
+	if err != nil {
+		log.Ctx(ctx).Debug().Err(err).Msgf("error subscribing to job %s, polling instead", jobID)
+		changed = nil
+	}
+
+	fallbackDelay := resolver.waitDelay
+	for {
+		done, err := check()
+		if err != nil && !system.IsRetryableError(err) {
+			return err
+		}
+		if err == nil && done {
+			return nil
+		}
+		if err != nil {
+			log.Ctx(ctx).Debug().Err(err).Msgf("error checking job %s, retrying", jobID)
+		}
+
+		pollDelay := resolver.subscribedPollDelay
+		if changed == nil {
+			pollDelay = fallbackDelay
+			fallbackDelay = system.NextDelay(fallbackDelay, resolver.waitBackoff, resolver.maxWaitDelay)
+		}
+		timer := time.NewTimer(system.Jitter(pollDelay, waitJitter))
+		select {
+		case _, ok := <-changed:
+			if !ok {
+				log.Ctx(ctx).Debug().Msgf("subscription to job %s ended, polling instead", jobID)
+				changed = nil
+			}
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			if ctx.Err() == context.DeadlineExceeded {
+				if err != nil {
+					return fmt.Errorf("wait for job timed out after %s: %w", maxWait, err)
+				}
+				return fmt.Errorf("wait for job timed out after %s", maxWait)
+			}
+			return ctx.Err()
+		}
+		timer.Stop()
+	}
+}
+
 // this is an auto wait where we auto calculate how many shard
 // states we expect to see and we use that to pass to WaitForJobStates
 func (resolver *StateResolver) WaitUntilComplete(ctx context.Context, jobID string) error {
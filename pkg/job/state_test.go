@@ -0,0 +1,173 @@
+//go:build unit || !integration
+
+package job
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/bacalhau/pkg/bacerrors"
+	"github.com/filecoin-project/bacalhau/pkg/logger"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestStateResolverSuite(t *testing.T) {
+	suite.Run(t, new(StateResolverSuite))
+}
+
+type StateResolverSuite struct {
+	suite.Suite
+	state atomic.Value // model.JobStateType of the only shard of the job
+	loads atomic.Int32
+}
+
+func (s *StateResolverSuite) SetupTest() {
+	logger.ConfigureTestLogging(s.T())
+	s.state.Store(model.JobStateRunning)
+	s.loads.Store(0)
+}
+
+func (s *StateResolverSuite) newResolver() *StateResolver {
+	jobLoader := func(ctx context.Context, id string) (*model.Job, error) {
+		return &model.Job{ID: id}, nil
+	}
+	stateLoader := func(ctx context.Context, id string) (model.JobState, error) {
+		s.loads.Add(1)
+		return model.JobState{
+			Nodes: map[string]model.JobNodeState{
+				"node": {Shards: map[int]model.JobShardState{
+					0: {NodeID: "node", State: s.state.Load().(model.JobStateType)},
+				}},
+			},
+		}, nil
+	}
+	return NewStateResolver(jobLoader, stateLoader)
+}
+
+func (s *StateResolverSuite) waitForCompleted(resolver *StateResolver) error {
+	return resolver.Wait(context.Background(), "job", 1, WaitForJobStates(map[model.JobStateType]int{
+		model.JobStateCompleted: 1,
+	}))
+}
+
+func (s *StateResolverSuite) TestWaitWithSubscription() {
+	changed := make(chan struct{}, 1)
+	resolver := s.newResolver()
+	resolver.SetStateSubscriber(func(ctx context.Context, id string) (<-chan struct{}, error) {
+		return changed, nil
+	}, time.Hour)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		s.state.Store(model.JobStateCompleted)
+		changed <- struct{}{}
+	}()
+
+	s.Require().NoError(s.waitForCompleted(resolver))
+	// once before the change and once after it, with no polling in between
+	s.Require().Equal(int32(2), s.loads.Load())
+}
+
+func (s *StateResolverSuite) TestWaitFallsBackToPolling() {
+	resolver := s.newResolver()
+	resolver.SetWaitTime(100, 10*time.Millisecond)
+	resolver.SetStateSubscriber(func(ctx context.Context, id string) (<-chan struct{}, error) {
+		changed := make(chan struct{})
+		close(changed)
+		return changed, nil
+	}, time.Hour)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		s.state.Store(model.JobStateCompleted)
+	}()
+
+	s.Require().NoError(s.waitForCompleted(resolver))
+}
+
+func (s *StateResolverSuite) TestWaitWithSubscriptionTimesOut() {
+	resolver := s.newResolver()
+	resolver.SetWaitTime(5, 10*time.Millisecond)
+	resolver.SetStateSubscriber(func(ctx context.Context, id string) (<-chan struct{}, error) {
+		return make(chan struct{}), nil
+	}, time.Hour)
+
+	err := s.waitForCompleted(resolver)
+	s.Require().Error(err)
+	s.Require().Contains(err.Error(), "timed out")
+}
+
+func (s *StateResolverSuite) TestWaitBacksOff() {
+	resolver := s.newResolver()
+	resolver.SetWaitTime(5, 10*time.Millisecond)
+	resolver.SetWaitBackoff(2, 40*time.Millisecond)
+
+	// the delays between the polls grow to 20ms, 40ms and 40ms, rather than
+	// staying at 10ms
+	start := time.Now()
+	err := s.waitForCompleted(resolver)
+	s.Require().ErrorContains(err, "max attempts reached")
+	s.Require().Equal(int32(5), s.loads.Load())
+	s.Require().GreaterOrEqual(time.Since(start), 90*time.Millisecond)
+}
+
+func (s *StateResolverSuite) TestWaitBacksOffAfterSubscriptionLost() {
+	resolver := s.newResolver()
+	resolver.SetWaitTime(20, 10*time.Millisecond)
+	resolver.SetWaitBackoff(2, 80*time.Millisecond)
+	resolver.SetStateSubscriber(func(ctx context.Context, id string) (<-chan struct{}, error) {
+		changed := make(chan struct{})
+		close(changed)
+		return changed, nil
+	}, time.Hour)
+
+	// the delays between the polls grow to 20ms, 40ms and 80ms, so the 200ms
+	// wait polls about 6 times rather than 20
+	err := s.waitForCompleted(resolver)
+	s.Require().ErrorContains(err, "timed out")
+	s.Require().LessOrEqual(s.loads.Load(), int32(8))
+}
+
+func (s *StateResolverSuite) TestWaitRetriesLoadErrors() {
+	jobLoader := func(ctx context.Context, id string) (*model.Job, error) {
+		return &model.Job{ID: id}, nil
+	}
+	loads := 0
+	resolver := NewStateResolver(jobLoader, func(ctx context.Context, id string) (model.JobState, error) {
+		loads++
+		if loads < 3 {
+			return model.JobState{}, errors.New("connection refused")
+		}
+		return model.JobState{
+			Nodes: map[string]model.JobNodeState{
+				"node": {Shards: map[int]model.JobShardState{
+					0: {NodeID: "node", State: model.JobStateCompleted},
+				}},
+			},
+		}, nil
+	})
+	resolver.SetWaitTime(10, time.Millisecond)
+
+	s.Require().NoError(s.waitForCompleted(resolver))
+	s.Require().Equal(3, loads)
+}
+
+func (s *StateResolverSuite) TestWaitFailsOnJobNotFound() {
+	jobLoader := func(ctx context.Context, id string) (*model.Job, error) {
+		return &model.Job{ID: id}, nil
+	}
+	loads := 0
+	resolver := NewStateResolver(jobLoader, func(ctx context.Context, id string) (model.JobState, error) {
+		loads++
+		return model.JobState{}, bacerrors.NewJobNotFound(id)
+	})
+	resolver.SetWaitTime(10, time.Millisecond)
+
+	err := s.waitForCompleted(resolver)
+	s.Require().ErrorAs(err, new(*bacerrors.JobNotFound))
+	s.Require().Equal(1, loads)
+}
@@ -4,10 +4,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/url"
 	"regexp"
 	"strings"
 
 	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/storage/huggingface"
+	"github.com/filecoin-project/bacalhau/pkg/storage/localdirectory"
 	"github.com/filecoin-project/bacalhau/pkg/storage/url/urldownload"
 	"github.com/rs/zerolog/log"
 )
@@ -43,21 +46,72 @@ func NewNoopStateLoader() StateLoader {
 	return stateLoader
 }
 
+// ParseInputURL returns the storage spec of an input URL, which is either a
+// gs:// or az:// URL of cloud storage objects, an hf:// URL of a Hugging Face
+// model, a local:// URL of a directory on the compute nodes, or else an
+// HTTP(S) URL of a file to download.
+func ParseInputURL(inputURL string) (model.StorageSpec, error) {
+	inputURL = strings.Trim(inputURL, " '\"")
+	var source model.StorageSourceType
+	switch {
+	case strings.HasPrefix(inputURL, "hf://"):
+		if _, _, err := huggingface.ParseModelURL(inputURL); err != nil {
+			return model.StorageSpec{}, err
+		}
+		return model.StorageSpec{
+			StorageSource: model.StorageSourceHuggingFace,
+			URL:           inputURL,
+			Path:          "/inputs",
+		}, nil
+	case strings.HasPrefix(inputURL, localdirectory.URLScheme):
+		if _, _, err := localdirectory.ParseURL(inputURL); err != nil {
+			return model.StorageSpec{}, err
+		}
+		return model.StorageSpec{
+			StorageSource: model.StorageSourceLocalDirectory,
+			URL:           inputURL,
+			Path:          "/inputs",
+		}, nil
+	case strings.HasPrefix(inputURL, "gs://"):
+		source = model.StorageSourceGCS
+	case strings.HasPrefix(inputURL, "az://"):
+		source = model.StorageSourceAzureBlob
+	default:
+		u, err := urldownload.IsURLSupported(inputURL)
+		if err != nil {
+			return model.StorageSpec{}, err
+		}
+		return model.StorageSpec{
+			StorageSource: model.StorageSourceURLDownload,
+			URL:           u.String(),
+			Path:          "/inputs",
+		}, nil
+	}
+
+	u, err := url.Parse(inputURL)
+	if err != nil {
+		return model.StorageSpec{}, fmt.Errorf("invalid URL: %s", err)
+	}
+	if u.Host == "" {
+		return model.StorageSpec{}, fmt.Errorf("URL %s has no bucket or account", inputURL)
+	}
+	return model.StorageSpec{
+		StorageSource: source,
+		URL:           u.String(),
+		Path:          "/inputs",
+	}, nil
+}
+
 func buildJobInputs(inputVolumes, inputUrls []string) ([]model.StorageSpec, error) {
 	jobInputs := []model.StorageSpec{}
 
 	// We expect the input URLs to be of the form `url:pathToMountInTheContainer` or `url`
 	for _, inputURL := range inputUrls {
-		// should loop through all available storage providers?
-		u, err := urldownload.IsURLSupported(inputURL)
+		spec, err := ParseInputURL(inputURL)
 		if err != nil {
 			return []model.StorageSpec{}, err
 		}
-		jobInputs = append(jobInputs, model.StorageSpec{
-			StorageSource: model.StorageSourceURLDownload,
-			URL:           u.String(),
-			Path:          "/inputs",
-		})
+		jobInputs = append(jobInputs, spec)
 	}
 
 	for _, inputVolume := range inputVolumes {
@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	"github.com/filecoin-project/bacalhau/pkg/logger"
+	"github.com/filecoin-project/bacalhau/pkg/model"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 )
@@ -94,3 +95,46 @@ func (s *JobUtilSuite) TestRun_URLs() {
 		}
 	}
 }
+
+func (s *JobUtilSuite) TestRun_CloudStorageURLs() {
+	spec, err := ParseInputURL("gs://bucket/data/")
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), model.StorageSourceGCS, spec.StorageSource)
+	require.Equal(s.T(), "gs://bucket/data/", spec.URL)
+	require.Equal(s.T(), "/inputs", spec.Path)
+
+	spec, err = ParseInputURL("'az://account/container/data.csv'")
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), model.StorageSourceAzureBlob, spec.StorageSource)
+	require.Equal(s.T(), "az://account/container/data.csv", spec.URL)
+
+	_, err = ParseInputURL("gs:///data")
+	require.Error(s.T(), err)
+}
+
+func (s *JobUtilSuite) TestRun_HuggingFaceURLs() {
+	spec, err := ParseInputURL("hf://org/model@v1.0")
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), model.StorageSourceHuggingFace, spec.StorageSource)
+	require.Equal(s.T(), "hf://org/model@v1.0", spec.URL)
+	require.Equal(s.T(), "/inputs", spec.Path)
+
+	_, err = ParseInputURL("hf://org/model@")
+	require.Error(s.T(), err)
+}
+
+func (s *JobUtilSuite) TestRun_LocalDirectoryURLs() {
+	spec, err := ParseInputURL("local://census")
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), model.StorageSourceLocalDirectory, spec.StorageSource)
+	require.Equal(s.T(), "local://census", spec.URL)
+	require.Equal(s.T(), "/inputs", spec.Path)
+
+	spec, err = ParseInputURL("local:///data/census")
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), model.StorageSourceLocalDirectory, spec.StorageSource)
+	require.Equal(s.T(), "local:///data/census", spec.URL)
+
+	_, err = ParseInputURL("local://census/2020")
+	require.Error(s.T(), err)
+}
@@ -3,9 +3,18 @@ package job
 import (
 	"context"
 	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
 	"reflect"
+	"regexp"
+	"strings"
+	"time"
 
+	"github.com/filecoin-project/bacalhau/pkg/compute/capacity"
 	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/storage/inline"
+	"github.com/libp2p/go-libp2p/core/peer"
 )
 
 func VerifyJob(ctx context.Context, j *model.Job) error {
@@ -33,6 +42,22 @@ func VerifyJob(ctx context.Context, j *model.Job) error {
 		return fmt.Errorf("invalid verifier type: %s", j.Spec.Verifier.String())
 	}
 
+	if err := verifyVerificationWebhook(j.Spec); err != nil {
+		return err
+	}
+
+	if j.Spec.ChallengePeriod < 0 {
+		return fmt.Errorf("the challenge period cannot be negative")
+	}
+
+	if j.Spec.Challenge != (model.JobChallenge{}) {
+		return fmt.Errorf("only requester nodes submit jobs that re-execute challenged results")
+	}
+
+	if _, err := capacity.ParseResourceUsageConfigStrict(j.Spec.Resources); err != nil {
+		return fmt.Errorf("invalid job resources: %w", err)
+	}
+
 	if !model.IsValidPublisher(j.Spec.Publisher) {
 		return fmt.Errorf("invalid publisher type: %s", j.Spec.Publisher.String())
 	}
@@ -41,11 +66,272 @@ func VerifyJob(ctx context.Context, j *model.Job) error {
 		return fmt.Errorf("the deal confidence cannot be higher than the concurrency")
 	}
 
+	if len(j.Deal.TargetNodes) > 0 && j.Deal.Concurrency > len(j.Deal.TargetNodes) {
+		return fmt.Errorf("the deal concurrency cannot be higher than the number of target nodes")
+	}
+
+	if j.Deal.MinZones < 0 {
+		return fmt.Errorf("the deal min zones cannot be negative")
+	}
+
+	if j.Deal.MinZones > j.Deal.Concurrency {
+		return fmt.Errorf("the deal min zones cannot be higher than the concurrency")
+	}
+
+	if !j.Deal.Deadline.IsZero() && !j.Deal.Deadline.After(time.Now()) {
+		return fmt.Errorf("the deal deadline %s has already passed", j.Deal.Deadline.Format(time.RFC3339))
+	}
+
+	switch j.Deal.Class {
+	case "", model.JobClassStandard, model.JobClassSpot:
+	default:
+		return fmt.Errorf("invalid job class %q, must be %q or %q", j.Deal.Class, model.JobClassStandard, model.JobClassSpot)
+	}
+
+	targetNodes := map[string]bool{}
+	for _, nodeID := range j.Deal.TargetNodes {
+		if _, err := peer.Decode(nodeID); err != nil {
+			return fmt.Errorf("invalid target node %q: %w", nodeID, err)
+		}
+		if targetNodes[nodeID] {
+			return fmt.Errorf("target node %s is listed more than once", nodeID)
+		}
+		targetNodes[nodeID] = true
+	}
+
 	for _, inputVolume := range j.Spec.Inputs {
 		if !model.IsValidStorageSourceType(inputVolume.StorageSource) {
 			return fmt.Errorf("invalid input volume type: %s", inputVolume.StorageSource.String())
 		}
+		for _, transform := range inputVolume.Transforms {
+			if _, err := model.ParseInputTransform(string(transform)); err != nil {
+				return fmt.Errorf("invalid transform for input volume %s: %w", inputVolume.Path, err)
+			}
+		}
+	}
+
+	for _, volumes := range [][]model.StorageSpec{j.Spec.Inputs, j.Spec.Contexts} {
+		for _, volume := range volumes {
+			if volume.StorageSource != model.StorageSourceInline {
+				continue
+			}
+			if _, err := inline.Decode(volume.URL); err != nil {
+				return fmt.Errorf("input volume %s: %w", volume.Path, err)
+			}
+			if !path.IsAbs(volume.Path) || path.Base(volume.Path) == "/" {
+				return fmt.Errorf("inline input volume path %q must be the absolute path of a file", volume.Path)
+			}
+		}
+	}
+
+	for _, inputVolume := range j.Spec.Inputs {
+		if len(inputVolume.Artifacts) > 0 {
+			return fmt.Errorf("input volume %s can't have artifacts, they only apply to outputs", inputVolume.Path)
+		}
+	}
+
+	for _, outputVolume := range j.Spec.Outputs {
+		if len(outputVolume.Transforms) > 0 {
+			return fmt.Errorf("output volume %s can't have transforms, they only apply to inputs", outputVolume.Name)
+		}
+		for _, artifact := range outputVolume.Artifacts {
+			if err := verifyOutputArtifact(artifact); err != nil {
+				return fmt.Errorf("invalid artifact for output volume %s: %w", outputVolume.Name, err)
+			}
+		}
+	}
+
+	if err := verifyEnv(j.Spec); err != nil {
+		return err
+	}
+
+	if err := VerifyDockerSpec(j.Spec.Docker); err != nil {
+		return err
+	}
+
+	if err := verifyStdin(j.Spec); err != nil {
+		return err
+	}
+
+	return verifyVolumePaths(j.Spec)
+}
+
+var (
+	envNameRegex    = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	secretNameRegex = regexp.MustCompile(`^[A-Za-z0-9_.-]+(/[A-Za-z0-9_.-]+)*$`)
+	// a user or group name, or a numeric ID
+	userRegex = regexp.MustCompile(`^([a-z_][a-z0-9_.-]*|[0-9]+)$`)
+)
+
+// VerifyDockerSpec checks that the environment variables of a docker job
+// are all NAME=value, that its working directory and stdin are absolute and
+// that its user is a name or ID with an optional group. Executors check it
+// again, as compute nodes can't trust that requesters did.
+func VerifyDockerSpec(spec model.JobSpecDocker) error {
+	for _, variable := range spec.EnvironmentVariables {
+		name, _, ok := strings.Cut(variable, "=")
+		if !ok {
+			return fmt.Errorf("environment variable %q must be NAME=value", name)
+		}
+		if !envNameRegex.MatchString(name) {
+			return fmt.Errorf("invalid environment variable name %q", name)
+		}
+	}
+	if spec.WorkingDirectory != "" && !path.IsAbs(spec.WorkingDirectory) {
+		return fmt.Errorf("working directory %q must be an absolute path", spec.WorkingDirectory)
+	}
+	if spec.Stdin != "" && !path.IsAbs(spec.Stdin) {
+		return fmt.Errorf("stdin %q must be an absolute path", spec.Stdin)
+	}
+	if spec.User != "" {
+		user, group, hasGroup := strings.Cut(spec.User, ":")
+		if !userRegex.MatchString(user) || (hasGroup && !userRegex.MatchString(group)) {
+			return fmt.Errorf("invalid user %q, must be a name or ID with an optional group, e.g. 1000:1000", spec.User)
+		}
+	}
+	return nil
+}
+
+// verifyEnv checks that the environment variables and secrets of the job
+// have valid names, and that no two of them set the same variable.
+func verifyEnv(spec model.Spec) error {
+	for name := range spec.Env {
+		if !envNameRegex.MatchString(name) {
+			return fmt.Errorf("invalid environment variable name %q", name)
+		}
+	}
+	secretEnv := map[string]bool{}
+	for _, secret := range spec.Secrets {
+		if !secretNameRegex.MatchString(secret.Name) {
+			return fmt.Errorf("invalid secret name %q", secret.Name)
+		}
+		for _, segment := range strings.Split(secret.Name, "/") {
+			if segment == "." || segment == ".." {
+				return fmt.Errorf("invalid secret name %q", secret.Name)
+			}
+		}
+		env := secret.EnvName()
+		if !envNameRegex.MatchString(env) {
+			return fmt.Errorf("invalid environment variable name %q for secret %s", env, secret.Name)
+		}
+		if _, ok := spec.Env[env]; ok || secretEnv[env] {
+			return fmt.Errorf("environment variable %s is set more than once", env)
+		}
+		secretEnv[env] = true
+	}
+	return nil
+}
+
+// verifyVerificationWebhook checks that jobs verified by the webhook
+// verifier have an HTTP(S) endpoint for it, and that others don't have one.
+func verifyVerificationWebhook(spec model.Spec) error {
+	webhook := spec.VerificationWebhook
+	if spec.Verifier != model.VerifierWebhook {
+		if webhook.URL != "" {
+			return fmt.Errorf("the verification webhook is only used by the %s verifier", model.VerifierWebhook)
+		}
+		return nil
+	}
+	if webhook.URL == "" {
+		return fmt.Errorf("the %s verifier needs a verification webhook", model.VerifierWebhook)
+	}
+	u, err := url.Parse(webhook.URL)
+	if err != nil {
+		return fmt.Errorf("invalid verification webhook: %w", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("verification webhook %q must be an http or https URL", webhook.URL)
+	}
+	if webhook.Timeout < 0 {
+		return fmt.Errorf("the verification webhook timeout cannot be negative")
+	}
+	return nil
+}
+
+// verifyStdin checks that the file a docker job reads on stdin is in one of
+// its input volumes.
+func verifyStdin(spec model.Spec) error {
+	if spec.Docker.Stdin == "" {
+		return nil
+	}
+	stdin := path.Clean(spec.Docker.Stdin)
+	for _, volumes := range [][]model.StorageSpec{spec.Inputs, spec.Contexts} {
+		for _, volume := range volumes {
+			volumePath := path.Clean(volume.Path)
+			if stdin == volumePath || strings.HasPrefix(stdin, strings.TrimSuffix(volumePath, "/")+"/") {
+				return nil
+			}
+		}
 	}
+	return fmt.Errorf("stdin %s is not in any of the input volumes", spec.Docker.Stdin)
+}
 
+// verifyOutputArtifact checks that an artifact's glob is well-formed, and
+// that it can't select or publish files outside of its output volume.
+func verifyOutputArtifact(artifact model.OutputArtifact) error {
+	if artifact.Glob == "" {
+		return fmt.Errorf("the glob is empty")
+	}
+	for _, p := range []string{artifact.Glob, artifact.Name} {
+		if path.IsAbs(p) {
+			return fmt.Errorf("%q must be relative to the output volume", p)
+		}
+		for _, segment := range strings.Split(p, "/") {
+			if segment == ".." {
+				return fmt.Errorf("%q must not leave the output volume", p)
+			}
+		}
+	}
+	for _, segment := range strings.Split(artifact.Glob, "/") {
+		if _, err := path.Match(segment, ""); err != nil {
+			return fmt.Errorf("malformed glob %q: %w", artifact.Glob, err)
+		}
+	}
+	return nil
+}
+
+// verifyVolumePaths checks that scratch volumes are well-formed, and that
+// writable volumes don't share a path with any other volume, so that they
+// can't hide a read-only input.
+func verifyVolumePaths(spec model.Spec) error {
+	readOnly := map[string]bool{}
+	for _, volumes := range [][]model.StorageSpec{spec.Inputs, spec.Contexts} {
+		for _, volume := range volumes {
+			readOnly[filepath.Clean(volume.Path)] = true
+		}
+	}
+
+	writable := map[string]bool{}
+	addWritable := func(kind, path string) error {
+		path = filepath.Clean(path)
+		if readOnly[path] || writable[path] {
+			return fmt.Errorf("%s volume path %s is already used by another volume", kind, path)
+		}
+		writable[path] = true
+		return nil
+	}
+	for _, volume := range spec.Outputs {
+		if volume.Path == "" {
+			continue
+		}
+		if err := addWritable("output", volume.Path); err != nil {
+			return err
+		}
+	}
+	for _, volume := range spec.Scratch {
+		if !filepath.IsAbs(volume.Path) {
+			return fmt.Errorf("scratch volume path %q must be absolute", volume.Path)
+		}
+		size, err := capacity.ParseBytesString(volume.Size)
+		if err != nil {
+			return fmt.Errorf("invalid size of scratch volume %s: %w", volume.Path, err)
+		}
+		if size == 0 {
+			return fmt.Errorf("scratch volume %s must have a size, e.g. 500Mi", volume.Path)
+		}
+		if err := addWritable("scratch", volume.Path); err != nil {
+			return err
+		}
+	}
 	return nil
 }
@@ -0,0 +1,527 @@
+//go:build unit || !integration
+
+package job
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/storage/inline"
+	"github.com/libp2p/go-libp2p/core/test"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyJobTargetNodes(t *testing.T) {
+	nodeA := test.RandPeerIDFatal(t).String()
+	nodeB := test.RandPeerIDFatal(t).String()
+
+	for _, testCase := range []struct {
+		name        string
+		concurrency int
+		targetNodes []string
+		valid       bool
+	}{
+		{name: "no target nodes", concurrency: 3, valid: true},
+		{name: "one per node", concurrency: 2, targetNodes: []string{nodeA, nodeB}, valid: true},
+		{name: "more than the nodes", concurrency: 3, targetNodes: []string{nodeA, nodeB}},
+		{name: "invalid node id", concurrency: 1, targetNodes: []string{"not-a-node"}},
+		{name: "duplicate node", concurrency: 1, targetNodes: []string{nodeA, nodeA}},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			j := &model.Job{
+				Spec: model.Spec{
+					Engine:    model.EngineNoop,
+					Verifier:  model.VerifierNoop,
+					Publisher: model.PublisherNoop,
+				},
+				Deal: model.Deal{
+					Concurrency: testCase.concurrency,
+					TargetNodes: testCase.targetNodes,
+				},
+			}
+			err := VerifyJob(context.Background(), j)
+			if testCase.valid {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestVerifyJobDeadline(t *testing.T) {
+	for _, testCase := range []struct {
+		name     string
+		deadline time.Time
+		valid    bool
+	}{
+		{name: "no deadline", valid: true},
+		{name: "future deadline", deadline: time.Now().Add(time.Hour), valid: true},
+		{name: "past deadline", deadline: time.Now().Add(-time.Minute)},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			j := &model.Job{
+				Spec: model.Spec{
+					Engine:    model.EngineNoop,
+					Verifier:  model.VerifierNoop,
+					Publisher: model.PublisherNoop,
+				},
+				Deal: model.Deal{
+					Concurrency: 1,
+					Deadline:    testCase.deadline,
+				},
+			}
+			err := VerifyJob(context.Background(), j)
+			if testCase.valid {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestVerifyJobMinZones(t *testing.T) {
+	for _, testCase := range []struct {
+		name        string
+		concurrency int
+		minZones    int
+		valid       bool
+	}{
+		{name: "no constraint", concurrency: 1, valid: true},
+		{name: "a zone per node", concurrency: 3, minZones: 3, valid: true},
+		{name: "fewer zones than nodes", concurrency: 3, minZones: 2, valid: true},
+		{name: "more zones than nodes", concurrency: 2, minZones: 3},
+		{name: "negative", concurrency: 1, minZones: -1},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			j := &model.Job{
+				Spec: model.Spec{
+					Engine:    model.EngineNoop,
+					Verifier:  model.VerifierNoop,
+					Publisher: model.PublisherNoop,
+				},
+				Deal: model.Deal{
+					Concurrency: testCase.concurrency,
+					MinZones:    testCase.minZones,
+				},
+			}
+			err := VerifyJob(context.Background(), j)
+			if testCase.valid {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestVerifyJobClass(t *testing.T) {
+	for _, testCase := range []struct {
+		class model.JobClass
+		valid bool
+	}{
+		{class: "", valid: true},
+		{class: model.JobClassStandard, valid: true},
+		{class: model.JobClassSpot, valid: true},
+		{class: "preemptible"},
+	} {
+		t.Run(string(testCase.class), func(t *testing.T) {
+			j := &model.Job{
+				Spec: model.Spec{
+					Engine:    model.EngineNoop,
+					Verifier:  model.VerifierNoop,
+					Publisher: model.PublisherNoop,
+				},
+				Deal: model.Deal{
+					Concurrency: 1,
+					Class:       testCase.class,
+				},
+			}
+			err := VerifyJob(context.Background(), j)
+			if testCase.valid {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestVerifyJobScratchVolumes(t *testing.T) {
+	for _, testCase := range []struct {
+		name    string
+		scratch []model.ScratchVolume
+		valid   bool
+	}{
+		{name: "no scratch", valid: true},
+		{name: "disk scratch", scratch: []model.ScratchVolume{{Path: "/scratch", Size: "1Gb"}}, valid: true},
+		{name: "tmpfs scratch", scratch: []model.ScratchVolume{{Path: "/tmp", Size: "500Mb", Tmpfs: true}}, valid: true},
+		{name: "no size", scratch: []model.ScratchVolume{{Path: "/scratch"}}},
+		{name: "invalid size", scratch: []model.ScratchVolume{{Path: "/scratch", Size: "1Gx"}}},
+		{name: "relative path", scratch: []model.ScratchVolume{{Path: "scratch", Size: "1Gb"}}},
+		{name: "over an input", scratch: []model.ScratchVolume{{Path: "/inputs/", Size: "1Gb"}}},
+		{name: "over an output", scratch: []model.ScratchVolume{{Path: "/outputs", Size: "1Gb"}}},
+		{name: "twice", scratch: []model.ScratchVolume{{Path: "/scratch", Size: "1Gb"}, {Path: "/scratch", Size: "1Gb"}}},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			j := &model.Job{
+				Spec: model.Spec{
+					Engine:    model.EngineNoop,
+					Verifier:  model.VerifierNoop,
+					Publisher: model.PublisherNoop,
+					Inputs:    []model.StorageSpec{{StorageSource: model.StorageSourceIPFS, CID: "QmTest", Path: "/inputs"}},
+					Outputs:   []model.StorageSpec{{Name: "outputs", Path: "/outputs"}},
+					Scratch:   testCase.scratch,
+				},
+				Deal: model.Deal{
+					Concurrency: 1,
+				},
+			}
+			err := VerifyJob(context.Background(), j)
+			if testCase.valid {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestVerifyJobResources(t *testing.T) {
+	for _, testCase := range []struct {
+		name      string
+		resources model.ResourceUsageConfig
+		valid     bool
+	}{
+		{name: "no resources", valid: true},
+		{
+			name:      "cores and binary units",
+			resources: model.ResourceUsageConfig{CPU: "0.5", Memory: "2Gi", Disk: "100Mb", GPU: "1"},
+			valid:     true,
+		},
+		{name: "unknown unit", resources: model.ResourceUsageConfig{Memory: "2Gx"}},
+		{name: "negative cpu", resources: model.ResourceUsageConfig{CPU: "-1"}},
+		{name: "sub-millicore gpu", resources: model.ResourceUsageConfig{GPU: "0.0001"}},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			j := &model.Job{
+				Spec: model.Spec{
+					Engine:    model.EngineNoop,
+					Verifier:  model.VerifierNoop,
+					Publisher: model.PublisherNoop,
+					Resources: testCase.resources,
+				},
+				Deal: model.Deal{
+					Concurrency: 1,
+				},
+			}
+			err := VerifyJob(context.Background(), j)
+			if testCase.valid {
+				require.NoError(t, err)
+				// verifying a job doesn't change it
+				require.Equal(t, testCase.resources, j.Spec.Resources)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestVerifyJobInputTransforms(t *testing.T) {
+	for _, testCase := range []struct {
+		name       string
+		transforms []model.InputTransform
+		valid      bool
+	}{
+		{name: "no transforms", valid: true},
+		{name: "gunzip then untar", transforms: []model.InputTransform{model.InputTransformGunzip, model.InputTransformUntar}, valid: true},
+		{name: "decrypt", transforms: []model.InputTransform{model.InputTransformDecrypt}, valid: true},
+		{name: "unknown", transforms: []model.InputTransform{"unrar"}},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			j := &model.Job{
+				Spec: model.Spec{
+					Engine:    model.EngineNoop,
+					Verifier:  model.VerifierNoop,
+					Publisher: model.PublisherNoop,
+					Inputs: []model.StorageSpec{{
+						StorageSource: model.StorageSourceIPFS,
+						CID:           "QmTest",
+						Path:          "/inputs",
+						Transforms:    testCase.transforms,
+					}},
+				},
+				Deal: model.Deal{
+					Concurrency: 1,
+				},
+			}
+			err := VerifyJob(context.Background(), j)
+			if testCase.valid {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestVerifyJobInlineInputs(t *testing.T) {
+	for _, testCase := range []struct {
+		name  string
+		url   string
+		path  string
+		valid bool
+	}{
+		{name: "script", url: inline.NewURL([]byte("echo hello")), path: "/inputs/hello.sh", valid: true},
+		{name: "relative path", url: inline.NewURL([]byte("echo hello")), path: "hello.sh"},
+		{name: "root", url: inline.NewURL([]byte("echo hello")), path: "/"},
+		{name: "invalid URL", url: "data:;base64,???", path: "/inputs/hello.sh"},
+		{name: "too large", url: inline.NewURL(make([]byte, inline.MaxSize.Bytes()+1)), path: "/inputs/data"},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			j := &model.Job{
+				Spec: model.Spec{
+					Engine:    model.EngineNoop,
+					Verifier:  model.VerifierNoop,
+					Publisher: model.PublisherNoop,
+					Inputs: []model.StorageSpec{{
+						StorageSource: model.StorageSourceInline,
+						URL:           testCase.url,
+						Path:          testCase.path,
+					}},
+				},
+				Deal: model.Deal{
+					Concurrency: 1,
+				},
+			}
+			err := VerifyJob(context.Background(), j)
+			if testCase.valid {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestVerifyJobStdin(t *testing.T) {
+	for _, testCase := range []struct {
+		name  string
+		stdin string
+		valid bool
+	}{
+		{name: "no stdin", valid: true},
+		{name: "input file", stdin: "/stdin", valid: true},
+		{name: "file in an input directory", stdin: "/inputs/data.csv", valid: true},
+		{name: "relative", stdin: "stdin"},
+		{name: "not an input", stdin: "/outputs/data.csv"},
+		{name: "sibling of an input", stdin: "/inputsx/data.csv"},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			j := &model.Job{
+				Spec: model.Spec{
+					Engine:    model.EngineNoop,
+					Verifier:  model.VerifierNoop,
+					Publisher: model.PublisherNoop,
+					Docker:    model.JobSpecDocker{Stdin: testCase.stdin},
+					Inputs: []model.StorageSpec{
+						{StorageSource: model.StorageSourceIPFS, CID: "QmTest", Path: "/inputs"},
+						{StorageSource: model.StorageSourceInline, URL: inline.NewURL([]byte("a,b\n")), Path: "/stdin"},
+					},
+				},
+				Deal: model.Deal{
+					Concurrency: 1,
+				},
+			}
+			err := VerifyJob(context.Background(), j)
+			if testCase.valid {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestVerifyJobOutputArtifacts(t *testing.T) {
+	for _, testCase := range []struct {
+		name      string
+		artifacts []model.OutputArtifact
+		valid     bool
+	}{
+		{name: "no artifacts", valid: true},
+		{name: "globs", artifacts: []model.OutputArtifact{{Glob: "*.csv"}, {Glob: "models/**/*.bin", Name: "models"}}, valid: true},
+		{name: "renamed file", artifacts: []model.OutputArtifact{{Glob: "model.bin", Name: "weights.bin"}}, valid: true},
+		{name: "empty glob", artifacts: []model.OutputArtifact{{Name: "all"}}},
+		{name: "malformed glob", artifacts: []model.OutputArtifact{{Glob: "[a-"}}},
+		{name: "absolute glob", artifacts: []model.OutputArtifact{{Glob: "/etc/*"}}},
+		{name: "outside the output", artifacts: []model.OutputArtifact{{Glob: "*.csv", Name: "../csvs"}}},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			j := &model.Job{
+				Spec: model.Spec{
+					Engine:    model.EngineNoop,
+					Verifier:  model.VerifierNoop,
+					Publisher: model.PublisherNoop,
+					Outputs:   []model.StorageSpec{{Name: "outputs", Path: "/outputs", Artifacts: testCase.artifacts}},
+				},
+				Deal: model.Deal{
+					Concurrency: 1,
+				},
+			}
+			err := VerifyJob(context.Background(), j)
+			if testCase.valid {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestVerifyJobEnv(t *testing.T) {
+	for _, testCase := range []struct {
+		name    string
+		env     map[string]string
+		secrets []model.SecretSpec
+		valid   bool
+	}{
+		{name: "no env", valid: true},
+		{name: "env", env: map[string]string{"FOO": "bar", "_BAZ1": ""}, valid: true},
+		{name: "secrets", secrets: []model.SecretSpec{{Name: "TOKEN"}, {Name: "aws/key", Env: "AWS_KEY"}}, valid: true},
+		{name: "invalid env name", env: map[string]string{"FOO=BAR": "baz"}},
+		{name: "env name starts with digit", env: map[string]string{"1FOO": "bar"}},
+		{name: "secret name not a variable", secrets: []model.SecretSpec{{Name: "aws/key"}}},
+		{name: "empty secret name", secrets: []model.SecretSpec{{Env: "KEY"}}},
+		{name: "secret name leaves its path", secrets: []model.SecretSpec{{Name: "../key", Env: "KEY"}}},
+		{name: "secret overrides env", env: map[string]string{"KEY": "v"}, secrets: []model.SecretSpec{{Name: "KEY"}}},
+		{name: "secret twice", secrets: []model.SecretSpec{{Name: "a", Env: "KEY"}, {Name: "b", Env: "KEY"}}},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			j := &model.Job{
+				Spec: model.Spec{
+					Engine:    model.EngineNoop,
+					Verifier:  model.VerifierNoop,
+					Publisher: model.PublisherNoop,
+					Env:       testCase.env,
+					Secrets:   testCase.secrets,
+				},
+				Deal: model.Deal{
+					Concurrency: 1,
+				},
+			}
+			err := VerifyJob(context.Background(), j)
+			if testCase.valid {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestVerifyJobVerificationWebhook(t *testing.T) {
+	for _, testCase := range []struct {
+		name     string
+		verifier model.Verifier
+		url      string
+		timeout  float64
+		valid    bool
+	}{
+		{name: "no webhook", verifier: model.VerifierNoop, valid: true},
+		{name: "webhook", verifier: model.VerifierWebhook, url: "https://example.com/verify", timeout: 60, valid: true},
+		{name: "webhook without url", verifier: model.VerifierWebhook},
+		{name: "url without webhook verifier", verifier: model.VerifierNoop, url: "https://example.com"},
+		{name: "not http", verifier: model.VerifierWebhook, url: "ftp://example.com"},
+		{name: "no host", verifier: model.VerifierWebhook, url: "https:///verify"},
+		{name: "negative timeout", verifier: model.VerifierWebhook, url: "http://example.com", timeout: -1},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			j := &model.Job{
+				Spec: model.Spec{
+					Engine:              model.EngineNoop,
+					Verifier:            testCase.verifier,
+					VerificationWebhook: model.VerificationWebhook{URL: testCase.url, Timeout: testCase.timeout},
+					Publisher:           model.PublisherNoop,
+				},
+				Deal: model.Deal{
+					Concurrency: 1,
+				},
+			}
+			err := VerifyJob(context.Background(), j)
+			if testCase.valid {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestVerifyJobChallenge(t *testing.T) {
+	for _, testCase := range []struct {
+		name            string
+		challengePeriod float64
+		challenge       model.JobChallenge
+		valid           bool
+	}{
+		{name: "no challenge period", valid: true},
+		{name: "challenge period", challengePeriod: 3600, valid: true},
+		{name: "negative challenge period", challengePeriod: -1},
+		{name: "challenge", challenge: model.JobChallenge{JobID: "job", NodeID: "node"}},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			j := &model.Job{
+				Spec: model.Spec{
+					Engine:          model.EngineNoop,
+					Verifier:        model.VerifierOptimistic,
+					Publisher:       model.PublisherNoop,
+					ChallengePeriod: testCase.challengePeriod,
+					Challenge:       testCase.challenge,
+				},
+				Deal: model.Deal{
+					Concurrency: 1,
+				},
+			}
+			err := VerifyJob(context.Background(), j)
+			if testCase.valid {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestVerifyDockerSpec(t *testing.T) {
+	for _, testCase := range []struct {
+		name  string
+		spec  model.JobSpecDocker
+		valid bool
+	}{
+		{name: "empty", valid: true},
+		{name: "env", spec: model.JobSpecDocker{EnvironmentVariables: []string{"A=1", "B=x=y", "C="}}, valid: true},
+		{name: "env without value", spec: model.JobSpecDocker{EnvironmentVariables: []string{"HOME"}}},
+		{name: "invalid env name", spec: model.JobSpecDocker{EnvironmentVariables: []string{"A B=1"}}},
+		{name: "working directory", spec: model.JobSpecDocker{WorkingDirectory: "/app"}, valid: true},
+		{name: "relative working directory", spec: model.JobSpecDocker{WorkingDirectory: "app"}},
+		{name: "user name", spec: model.JobSpecDocker{User: "nobody"}, valid: true},
+		{name: "uid", spec: model.JobSpecDocker{User: "1000"}, valid: true},
+		{name: "uid and gid", spec: model.JobSpecDocker{User: "1000:1000"}, valid: true},
+		{name: "user and group", spec: model.JobSpecDocker{User: "app:staff"}, valid: true},
+		{name: "empty group", spec: model.JobSpecDocker{User: "1000:"}},
+		{name: "invalid user", spec: model.JobSpecDocker{User: "-1"}},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := VerifyDockerSpec(testCase.spec)
+			if testCase.valid {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
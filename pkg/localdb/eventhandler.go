@@ -11,11 +11,13 @@ import (
 // An event handler that listens to both job and local events, and updates the LocalDB instance accordingly
 type LocalDBEventHandler struct {
 	localDB LocalDB
+	marks   *sequenceMarks
 }
 
 func NewLocalDBEventHandler(localDB LocalDB) *LocalDBEventHandler {
 	return &LocalDBEventHandler{
 		localDB: localDB,
+		marks:   newSequenceMarks(),
 	}
 }
 
@@ -24,6 +26,7 @@ func (h *LocalDBEventHandler) HandleLocalEvent(ctx context.Context, event model.
 	case model.JobLocalEventBidAccepted,
 		model.JobLocalEventBidRejected,
 		model.JobLocalEventVerified,
+		model.JobLocalEventIdempotencyKey,
 		model.JobLocalEventSelected,
 		model.JobLocalEventBid:
 		return h.localDB.AddLocalEvent(ctx, event.JobID, event)
@@ -51,6 +54,20 @@ func (h *LocalDBEventHandler) HandleJobEvent(ctx context.Context, event model.Jo
 		return err
 	}
 
+	// events from the same node can arrive out of order, so don't move the
+	// shard back to a state it has already left
+	stale, err := h.marks.isStale(event, func() ([]model.JobEvent, error) {
+		return h.localDB.GetJobEvents(ctx, event.JobID)
+	})
+	if err != nil {
+		return err
+	}
+	if stale {
+		log.Ctx(ctx).Debug().Msgf("not updating shard state from stale %s event %d of node %s",
+			event.EventName, event.SequenceNumber, event.SourceNodeID)
+		return nil
+	}
+
 	executionState := model.GetStateFromEvent(event.EventName)
 
 	// in most cases - the source node is the id of the state
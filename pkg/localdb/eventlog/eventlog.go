@@ -0,0 +1,476 @@
+package eventlog
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/filecoin-project/bacalhau/pkg/localdb"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/storage/util"
+	"github.com/filecoin-project/bacalhau/pkg/system"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	segmentFilePrefix = "events-"
+	segmentFileSuffix = ".jsonl"
+	snapshotFileName  = "snapshot.json"
+
+	// the longest line we expect to find in the log - job events carry the
+	// full spec and run output so they can get fairly large
+	maxRecordSize = 64 * 1024 * 1024
+)
+
+// A Record is a single entry of the event log. Exactly one of JobEvent and
+// LocalEvent is set.
+type Record struct {
+	Sequence   uint64               `json:"sequence"`
+	JobEvent   *model.JobEvent      `json:"job_event,omitempty"`
+	LocalEvent *model.JobLocalEvent `json:"local_event,omitempty"`
+}
+
+type EventLogParams struct {
+	// Directory holding the log segments and snapshot. Created if it does
+	// not exist.
+	Path string
+	// Snapshot the LocalDB every SnapshotInterval records. Zero disables
+	// snapshotting, in which case restarts replay the full log.
+	SnapshotInterval uint64
+	// The LocalDB the events are applied to.
+	LocalDB localdb.LocalDB
+}
+
+// EventLog is an append-only log of every job and local event a node has
+// applied to its LocalDB. The state held in the LocalDB is derived from the
+// log, so it can be rebuilt after a restart by replaying the log on top of
+// the latest snapshot.
+//
+// The log is split into segments, each named after the sequence of its
+// first record. A new segment is started whenever a snapshot is taken, and
+// the segments the snapshot covers are removed once it has been written, so
+// a restart only reads the snapshot and the records appended after it.
+//
+// EventLog replaces the LocalDBEventHandler in the event handler chains: it
+// records each event and then applies it to the LocalDB while holding its
+// lock, so that snapshots always line up with a record boundary.
+type EventLog struct {
+	path             string
+	snapshotInterval uint64
+	localDB          localdb.LocalDB
+	handler          *localdb.LocalDBEventHandler
+
+	mtx           sync.Mutex
+	file          *os.File
+	sequence      uint64
+	sinceSnapshot uint64
+	// set while a snapshot is being written in the background
+	snapshotting bool
+	snapshotWg   sync.WaitGroup
+}
+
+func NewEventLog(params EventLogParams) (*EventLog, error) {
+	if err := os.MkdirAll(params.Path, util.OS_USER_RWX); err != nil {
+		return nil, fmt.Errorf("failed to create event log directory: %w", err)
+	}
+
+	if params.SnapshotInterval > 0 {
+		if _, ok := params.LocalDB.(localdb.Snapshotter); !ok {
+			return nil, fmt.Errorf("event log snapshots are not supported by %T", params.LocalDB)
+		}
+	}
+
+	eventLog := &EventLog{
+		path:             params.Path,
+		snapshotInterval: params.SnapshotInterval,
+		localDB:          params.LocalDB,
+		handler:          localdb.NewLocalDBEventHandler(params.LocalDB),
+	}
+
+	segments, err := eventLog.segments()
+	if err != nil {
+		return nil, err
+	}
+	if len(segments) == 0 {
+		// start after the snapshot, if there is one left from a log whose
+		// segments were all removed
+		snapshot, snapshotErr := eventLog.loadSnapshot()
+		if snapshotErr != nil {
+			return nil, snapshotErr
+		}
+		if snapshot != nil {
+			eventLog.sequence = snapshot.Sequence
+		}
+		if err = eventLog.openSegment(eventLog.sequence + 1); err != nil {
+			return nil, err
+		}
+		return eventLog, nil
+	}
+
+	// only the last segment can hold the last sequence or a torn record
+	last := segments[len(segments)-1]
+	eventLog.sequence = last - 1
+	size, err := eventLog.scanSegment(context.Background(), last, func(record Record) error {
+		eventLog.sequence = record.Sequence
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err = eventLog.openSegment(last); err != nil {
+		return nil, err
+	}
+
+	// make sure new records are not appended to a torn one left at the end
+	info, err := eventLog.file.Stat()
+	if err == nil && info.Size() > size {
+		err = eventLog.file.Truncate(size)
+	} else if err == nil && info.Size() < size {
+		// the last record is intact but lost its trailing newline
+		_, err = eventLog.file.Write([]byte{'\n'})
+	}
+	if err != nil {
+		eventLog.file.Close()
+		return nil, fmt.Errorf("failed to repair event log: %w", err)
+	}
+	return eventLog, nil
+}
+
+// Replay rebuilds the LocalDB from the latest snapshot (if any) and the
+// records that were appended after it. It must be called before the event
+// log is registered with any event handler chain.
+func (l *EventLog) Replay(ctx context.Context) error {
+	ctx, span := system.GetTracer().Start(ctx, "pkg/localdb/eventlog/EventLog.Replay")
+	defer span.End()
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	snapshot, err := l.loadSnapshot()
+	if err != nil {
+		return err
+	}
+	var from uint64
+	if snapshot != nil {
+		snapshotter, ok := l.localDB.(localdb.Snapshotter)
+		if !ok {
+			return fmt.Errorf("found an event log snapshot but %T cannot be restored from it", l.localDB)
+		}
+		if err = snapshotter.Restore(ctx, *snapshot); err != nil {
+			return fmt.Errorf("failed to restore event log snapshot: %w", err)
+		}
+		from = snapshot.Sequence
+	}
+
+	replayed := 0
+	err = l.records(ctx, from, func(record Record) error {
+		if record.Sequence <= from {
+			return nil
+		}
+		replayed++
+		// errors are expected here, in the same places they happened when the
+		// event was first applied, so we log them instead of failing the replay
+		if applyErr := l.apply(ctx, record); applyErr != nil {
+			log.Ctx(ctx).Debug().Err(applyErr).Msgf("error replaying event log record %d", record.Sequence)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	l.sinceSnapshot = uint64(replayed)
+	log.Ctx(ctx).Info().Msgf("replayed %d event log records on top of snapshot at sequence %d", replayed, from)
+	return nil
+}
+
+// Records calls fn for every record still held in the log, oldest first.
+// Records covered by a snapshot are removed along with their segment. It is
+// meant for replay and for tools that need to inspect the history of a node.
+func (l *EventLog) Records(ctx context.Context, fn func(Record) error) error {
+	return l.records(ctx, 0, fn)
+}
+
+// records calls fn for the records of every segment that can hold records
+// after the from sequence.
+func (l *EventLog) records(ctx context.Context, from uint64, fn func(Record) error) error {
+	segments, err := l.segments()
+	if err != nil {
+		return err
+	}
+	for i, start := range segments {
+		if i+1 < len(segments) && segments[i+1] <= from+1 {
+			// every record in this segment is covered by the snapshot
+			continue
+		}
+		if _, err = l.scanSegment(ctx, start, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// segments returns the first sequence of each log segment, in order.
+func (l *EventLog) segments() ([]uint64, error) {
+	entries, err := os.ReadDir(l.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list event log segments: %w", err)
+	}
+	segments := []uint64{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, segmentFilePrefix) || !strings.HasSuffix(name, segmentFileSuffix) {
+			continue
+		}
+		start, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(name, segmentFilePrefix), segmentFileSuffix), 10, 64)
+		if err != nil {
+			log.Warn().Msgf("ignoring unexpected file %s in the event log directory", name)
+			continue
+		}
+		segments = append(segments, start)
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i] < segments[j] })
+	return segments, nil
+}
+
+// scanSegment reads a segment and returns the size in bytes of its intact
+// prefix.
+func (l *EventLog) scanSegment(ctx context.Context, start uint64, fn func(Record) error) (int64, error) {
+	file, err := os.Open(l.segmentPath(start))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to open event log segment: %w", err)
+	}
+	defer file.Close()
+
+	var size int64
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), maxRecordSize)
+	for scanner.Scan() {
+		if ctx.Err() != nil {
+			return size, ctx.Err()
+		}
+		var record Record
+		if err = json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			// a torn write at the end of the log is expected if the node died
+			// mid-append, anything else is corruption
+			if scanner.Scan() || scanner.Err() != nil {
+				return size, fmt.Errorf("failed to decode event log record: %w", err)
+			}
+			log.Ctx(ctx).Warn().Err(err).Msg("ignoring truncated record at the end of the event log")
+			return size, nil
+		}
+		if err = fn(record); err != nil {
+			return size, err
+		}
+		size += int64(len(scanner.Bytes())) + 1
+	}
+	return size, scanner.Err()
+}
+
+// HandleJobEvent implements eventhandler.JobEventHandler
+func (l *EventLog) HandleJobEvent(ctx context.Context, event model.JobEvent) error {
+	return l.append(ctx, Record{JobEvent: &event})
+}
+
+// HandleLocalEvent implements eventhandler.LocalEventHandler
+func (l *EventLog) HandleLocalEvent(ctx context.Context, event model.JobLocalEvent) error {
+	return l.append(ctx, Record{LocalEvent: &event})
+}
+
+// Close closes the log and waits for any snapshot being written.
+func (l *EventLog) Close() error {
+	l.mtx.Lock()
+	var err error
+	if l.file != nil {
+		err = l.file.Close()
+		l.file = nil
+	}
+	l.mtx.Unlock()
+
+	// no new snapshot can be started once the file is closed
+	l.snapshotWg.Wait()
+	return err
+}
+
+func (l *EventLog) append(ctx context.Context, record Record) error {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	if l.file == nil {
+		return fmt.Errorf("event log is closed")
+	}
+
+	record.Sequence = l.sequence + 1
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if _, err = l.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to append to event log: %w", err)
+	}
+	if err = l.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync event log: %w", err)
+	}
+	l.sequence = record.Sequence
+	l.sinceSnapshot++
+
+	// the event is recorded even if applying it fails so that a replay runs
+	// into exactly the same failures
+	err = l.apply(ctx, record)
+
+	if l.snapshotInterval > 0 && l.sinceSnapshot >= l.snapshotInterval && !l.snapshotting {
+		if snapshotErr := l.startSnapshot(ctx); snapshotErr != nil {
+			log.Ctx(ctx).Error().Err(snapshotErr).Msg("failed to snapshot event log")
+		}
+	}
+	return err
+}
+
+func (l *EventLog) apply(ctx context.Context, record Record) error {
+	if record.JobEvent != nil {
+		return l.handler.HandleJobEvent(ctx, *record.JobEvent)
+	}
+	if record.LocalEvent != nil {
+		return l.handler.HandleLocalEvent(ctx, *record.LocalEvent)
+	}
+	return nil
+}
+
+// startSnapshot copies the LocalDB and starts a new segment while holding
+// mtx, then writes the snapshot and removes the segments it covers in the
+// background so that event handling is not held up by the disk.
+func (l *EventLog) startSnapshot(ctx context.Context) error {
+	snapshot, err := l.localDB.(localdb.Snapshotter).Snapshot(ctx)
+	if err != nil {
+		return err
+	}
+	snapshot.Sequence = l.sequence
+
+	if err = l.file.Close(); err != nil {
+		return err
+	}
+	if err = l.openSegment(l.sequence + 1); err != nil {
+		l.file = nil
+		return err
+	}
+
+	l.sinceSnapshot = 0
+	l.snapshotting = true
+	l.snapshotWg.Add(1)
+	go func() {
+		defer l.snapshotWg.Done()
+		if err := l.writeSnapshot(ctx, snapshot); err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("failed to write event log snapshot")
+		}
+		l.mtx.Lock()
+		l.snapshotting = false
+		l.mtx.Unlock()
+	}()
+	return nil
+}
+
+func (l *EventLog) writeSnapshot(ctx context.Context, snapshot localdb.Snapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	// write to a temporary file first so a crash never leaves a half
+	// written snapshot behind
+	tmpPath := l.snapshotPath() + ".tmp"
+	if err = writeFileSync(tmpPath, data); err != nil {
+		return err
+	}
+	if err = os.Rename(tmpPath, l.snapshotPath()); err != nil {
+		return err
+	}
+	if err = syncDir(l.path); err != nil {
+		return err
+	}
+	log.Ctx(ctx).Debug().Msgf("wrote event log snapshot at sequence %d", snapshot.Sequence)
+
+	// the snapshot is safely on disk so the segments it covers can go
+	segments, err := l.segments()
+	if err != nil {
+		return err
+	}
+	for _, start := range segments {
+		if start > snapshot.Sequence {
+			break
+		}
+		if err = os.Remove(l.segmentPath(start)); err != nil {
+			return fmt.Errorf("failed to remove event log segment: %w", err)
+		}
+	}
+	return nil
+}
+
+func (l *EventLog) loadSnapshot() (*localdb.Snapshot, error) {
+	data, err := os.ReadFile(l.snapshotPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read event log snapshot: %w", err)
+	}
+	snapshot := &localdb.Snapshot{}
+	if err = json.Unmarshal(data, snapshot); err != nil {
+		return nil, fmt.Errorf("failed to decode event log snapshot: %w", err)
+	}
+	return snapshot, nil
+}
+
+// openSegment opens the segment starting at start for appending, creating
+// it if needed.
+func (l *EventLog) openSegment(start uint64) error {
+	file, err := os.OpenFile(l.segmentPath(start), os.O_CREATE|os.O_WRONLY|os.O_APPEND, util.OS_USER_RW)
+	if err != nil {
+		return fmt.Errorf("failed to open event log segment: %w", err)
+	}
+	l.file = file
+	return nil
+}
+
+func (l *EventLog) segmentPath(start uint64) string {
+	// zero padded so that segments sort by name too
+	return filepath.Join(l.path, fmt.Sprintf("%s%020d%s", segmentFilePrefix, start, segmentFileSuffix))
+}
+
+func (l *EventLog) snapshotPath() string {
+	return filepath.Join(l.path, snapshotFileName)
+}
+
+func writeFileSync(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, util.OS_USER_RW)
+	if err != nil {
+		return err
+	}
+	if _, err = file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err = file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func syncDir(path string) error {
+	dir, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
@@ -0,0 +1,162 @@
+//go:build unit || !integration
+
+package eventlog
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/filecoin-project/bacalhau/pkg/localdb/inmemory"
+	_ "github.com/filecoin-project/bacalhau/pkg/logger"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testJobID  = "12345678-abcd"
+	testNodeID = "node-1"
+)
+
+func recordTestEvents(t *testing.T, eventLog *EventLog) {
+	ctx := context.Background()
+	events := []model.JobEvent{
+		{JobID: testJobID, EventName: model.JobEventCreated, Spec: model.Spec{Engine: model.EngineNoop}},
+		{JobID: testJobID, SourceNodeID: testNodeID, EventName: model.JobEventBid},
+		{JobID: testJobID, TargetNodeID: testNodeID, EventName: model.JobEventBidAccepted},
+		{JobID: testJobID, SourceNodeID: testNodeID, EventName: model.JobEventResultsProposed, Status: "done"},
+	}
+	for _, event := range events {
+		require.NoError(t, eventLog.HandleJobEvent(ctx, event))
+	}
+	require.NoError(t, eventLog.HandleLocalEvent(ctx, model.JobLocalEvent{
+		JobID:     testJobID,
+		EventName: model.JobLocalEventSelected,
+	}))
+}
+
+func TestReplay(t *testing.T) {
+	for _, interval := range []uint64{0, 2} {
+		t.Run(fmt.Sprintf("snapshot interval %d", interval), func(t *testing.T) {
+			dir := t.TempDir()
+			ctx := context.Background()
+
+			db, err := inmemory.NewInMemoryDatastore()
+			require.NoError(t, err)
+			eventLog, err := NewEventLog(EventLogParams{Path: dir, SnapshotInterval: interval, LocalDB: db})
+			require.NoError(t, err)
+			recordTestEvents(t, eventLog)
+			require.NoError(t, eventLog.Close())
+
+			// a fresh datastore rebuilt from the log must match the original one
+			restoredDB, restoredLog := replayTestLog(t, dir, interval)
+			expectedState, err := db.GetJobState(ctx, testJobID)
+			require.NoError(t, err)
+			actualState, err := restoredDB.GetJobState(ctx, testJobID)
+			require.NoError(t, err)
+			require.Equal(t, expectedState, actualState)
+
+			shardState := actualState.Nodes[testNodeID].Shards[0]
+			require.Equal(t, model.JobStateVerifying, shardState.State)
+			require.Equal(t, "done", shardState.Status)
+
+			events, err := restoredDB.GetJobEvents(ctx, testJobID)
+			require.NoError(t, err)
+			require.Len(t, events, 4)
+
+			localEvents, err := restoredDB.GetJobLocalEvents(ctx, testJobID)
+			require.NoError(t, err)
+			require.Len(t, localEvents, 1)
+
+			// new records carry on from the existing sequence
+			require.NoError(t, restoredLog.HandleJobEvent(ctx, model.JobEvent{
+				JobID:        testJobID,
+				SourceNodeID: testNodeID,
+				EventName:    model.JobEventResultsPublished,
+			}))
+			sequences := recordSequences(t, restoredLog)
+			if interval == 0 {
+				require.Equal(t, []uint64{1, 2, 3, 4, 5, 6}, sequences)
+			} else {
+				// records covered by a snapshot are removed from the log
+				require.NotContains(t, sequences, uint64(1))
+				require.Equal(t, uint64(6), sequences[len(sequences)-1])
+			}
+			require.NoError(t, restoredLog.Close())
+		})
+	}
+}
+
+func TestReplaySkipsRecordsCoveredBySnapshot(t *testing.T) {
+	dir := t.TempDir()
+	db, err := inmemory.NewInMemoryDatastore()
+	require.NoError(t, err)
+	eventLog, err := NewEventLog(EventLogParams{Path: dir, SnapshotInterval: 2, LocalDB: db})
+	require.NoError(t, err)
+	recordTestEvents(t, eventLog)
+	require.NoError(t, eventLog.Close())
+
+	// put back the first segment, as if the node died before removing it,
+	// with content that would fail the replay if it was read
+	require.NoError(t, os.WriteFile(eventLog.segmentPath(1), []byte("not a record\nnot a record\n"), 0600))
+
+	restoredDB, restoredLog := replayTestLog(t, dir, 2)
+	defer restoredLog.Close()
+	localEvents, err := restoredDB.GetJobLocalEvents(context.Background(), testJobID)
+	require.NoError(t, err)
+	require.Len(t, localEvents, 1)
+}
+
+func TestRecoverTornTail(t *testing.T) {
+	for name, tail := range map[string]func([]byte) []byte{
+		"torn record": func(data []byte) []byte {
+			return append(data, []byte(`{"sequence":6,"job_ev`)...)
+		},
+		"missing newline": func(data []byte) []byte {
+			return data[:len(data)-1]
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			db, err := inmemory.NewInMemoryDatastore()
+			require.NoError(t, err)
+			eventLog, err := NewEventLog(EventLogParams{Path: dir, LocalDB: db})
+			require.NoError(t, err)
+			recordTestEvents(t, eventLog)
+			require.NoError(t, eventLog.Close())
+
+			path := eventLog.segmentPath(1)
+			data, err := os.ReadFile(path)
+			require.NoError(t, err)
+			require.NoError(t, os.WriteFile(path, tail(data), 0600))
+
+			_, restoredLog := replayTestLog(t, dir, 0)
+			defer restoredLog.Close()
+			require.NoError(t, restoredLog.HandleJobEvent(context.Background(), model.JobEvent{
+				JobID:        testJobID,
+				SourceNodeID: testNodeID,
+				EventName:    model.JobEventResultsPublished,
+			}))
+			require.Equal(t, []uint64{1, 2, 3, 4, 5, 6}, recordSequences(t, restoredLog))
+		})
+	}
+}
+
+func replayTestLog(t *testing.T, dir string, interval uint64) (*inmemory.InMemoryDatastore, *EventLog) {
+	db, err := inmemory.NewInMemoryDatastore()
+	require.NoError(t, err)
+	eventLog, err := NewEventLog(EventLogParams{Path: dir, SnapshotInterval: interval, LocalDB: db})
+	require.NoError(t, err)
+	require.NoError(t, eventLog.Replay(context.Background()))
+	return db, eventLog
+}
+
+func recordSequences(t *testing.T, eventLog *EventLog) []uint64 {
+	var sequences []uint64
+	require.NoError(t, eventLog.Records(context.Background(), func(record Record) error {
+		sequences = append(sequences, record.Sequence)
+		return nil
+	}))
+	return sequences
+}
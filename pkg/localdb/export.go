@@ -0,0 +1,275 @@
+package localdb
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	jobutils "github.com/filecoin-project/bacalhau/pkg/job"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// ExportFormat is the file format jobs and events are exported in.
+type ExportFormat string
+
+const (
+	ExportCSV     ExportFormat = "csv"
+	ExportParquet ExportFormat = "parquet"
+)
+
+// ExportKind is what is exported, one row each.
+type ExportKind string
+
+const (
+	ExportJobs   ExportKind = "jobs"
+	ExportEvents ExportKind = "events"
+)
+
+// parquetParallelism is how many goroutines encode the row groups of a
+// parquet export.
+const parquetParallelism = 4
+
+// ExportQuery selects the jobs or events to export. Jobs are selected by
+// when they were created and events by when they happened, from Since and
+// before Until. Either can be zero for no bound.
+type ExportQuery struct {
+	Kind   ExportKind
+	Format ExportFormat
+	Since  time.Time
+	Until  time.Time
+}
+
+// Validate returns an error if the query can't be exported.
+func (q ExportQuery) Validate() error {
+	if q.Kind != ExportJobs && q.Kind != ExportEvents {
+		return fmt.Errorf("unknown export kind %q, expected %s or %s", q.Kind, ExportJobs, ExportEvents)
+	}
+	if q.Format != ExportCSV && q.Format != ExportParquet {
+		return fmt.Errorf("unknown export format %q, expected %s or %s", q.Format, ExportCSV, ExportParquet)
+	}
+	if !q.Since.IsZero() && !q.Until.IsZero() && !q.Until.After(q.Since) {
+		return fmt.Errorf("the end of the export time range must be after its start")
+	}
+	return nil
+}
+
+func (q ExportQuery) contains(t time.Time) bool {
+	return (q.Since.IsZero() || !t.Before(q.Since)) && (q.Until.IsZero() || t.Before(q.Until))
+}
+
+// JobRow is what is exported of a job, for analyzing the utilization of the
+// network and the success rate of jobs.
+type JobRow struct {
+	JobID           string `parquet:"name=job_id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	ClientID        string `parquet:"name=client_id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	RequesterNodeID string `parquet:"name=requester_node_id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	CreatedAt       int64  `parquet:"name=created_at, type=INT64, convertedtype=TIMESTAMP_MILLIS"`
+	// when the last event of the job happened
+	UpdatedAt   int64  `parquet:"name=updated_at, type=INT64, convertedtype=TIMESTAMP_MILLIS"`
+	Engine      string `parquet:"name=engine, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Image       string `parquet:"name=image, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Verifier    string `parquet:"name=verifier, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Publisher   string `parquet:"name=publisher, type=BYTE_ARRAY, convertedtype=UTF8"`
+	CPU         string `parquet:"name=cpu, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Memory      string `parquet:"name=memory, type=BYTE_ARRAY, convertedtype=UTF8"`
+	GPU         string `parquet:"name=gpu, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Concurrency int32  `parquet:"name=concurrency, type=INT32"`
+	Shards      int32  `parquet:"name=shards, type=INT32"`
+	// how many executions of the shards of the job are in each state
+	Executions int32 `parquet:"name=executions, type=INT32"`
+	Completed  int32 `parquet:"name=completed, type=INT32"`
+	Failed     int32 `parquet:"name=failed, type=INT32"`
+	Cancelled  int32 `parquet:"name=cancelled, type=INT32"`
+}
+
+// EventRow is what is exported of an event of a job.
+type EventRow struct {
+	JobID        string `parquet:"name=job_id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	ShardIndex   int32  `parquet:"name=shard_index, type=INT32"`
+	EventName    string `parquet:"name=event_name, type=BYTE_ARRAY, convertedtype=UTF8"`
+	EventTime    int64  `parquet:"name=event_time, type=INT64, convertedtype=TIMESTAMP_MILLIS"`
+	ClientID     string `parquet:"name=client_id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	SourceNodeID string `parquet:"name=source_node_id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	TargetNodeID string `parquet:"name=target_node_id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Status       string `parquet:"name=status, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+var jobColumns = []string{
+	"job_id", "client_id", "requester_node_id", "created_at", "updated_at", "engine", "image", "verifier",
+	"publisher", "cpu", "memory", "gpu", "concurrency", "shards", "executions", "completed", "failed", "cancelled",
+}
+
+func (r JobRow) record() []string {
+	return []string{
+		r.JobID, r.ClientID, r.RequesterNodeID, formatMillis(r.CreatedAt), formatMillis(r.UpdatedAt), r.Engine, r.Image,
+		r.Verifier, r.Publisher, r.CPU, r.Memory, r.GPU, formatInt(r.Concurrency), formatInt(r.Shards),
+		formatInt(r.Executions), formatInt(r.Completed), formatInt(r.Failed), formatInt(r.Cancelled),
+	}
+}
+
+var eventColumns = []string{
+	"job_id", "shard_index", "event_name", "event_time", "client_id", "source_node_id", "target_node_id", "status",
+}
+
+func (r EventRow) record() []string {
+	return []string{
+		r.JobID, formatInt(r.ShardIndex), r.EventName, formatMillis(r.EventTime),
+		r.ClientID, r.SourceNodeID, r.TargetNodeID, r.Status,
+	}
+}
+
+// ExportRows are the jobs or events of the database that an export query
+// selects, oldest first.
+type ExportRows struct {
+	query  ExportQuery
+	jobs   []JobRow
+	events []EventRow
+}
+
+// CollectExportRows gets the jobs or events of the database that the query
+// selects, so that any error getting them is known before they are written.
+func CollectExportRows(ctx context.Context, db LocalDB, query ExportQuery) (*ExportRows, error) {
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
+	jobs, err := db.GetJobs(ctx, JobQuery{ReturnAll: true, Limit: math.MaxInt})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(jobs, func(i, j int) bool { return jobs[i].CreatedAt.Before(jobs[j].CreatedAt) })
+
+	rows := &ExportRows{query: query}
+	if query.Kind == ExportJobs {
+		for _, j := range jobs {
+			if !query.contains(j.CreatedAt) {
+				continue
+			}
+			row, err := jobRow(ctx, db, j)
+			if err != nil {
+				return nil, err
+			}
+			rows.jobs = append(rows.jobs, row)
+		}
+		return rows, nil
+	}
+
+	for _, j := range jobs {
+		events, err := db.GetJobEvents(ctx, j.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, event := range events {
+			if query.contains(event.EventTime) {
+				rows.events = append(rows.events, eventRow(event))
+			}
+		}
+	}
+	sort.SliceStable(rows.events, func(i, j int) bool { return rows.events[i].EventTime < rows.events[j].EventTime })
+	return rows, nil
+}
+
+// Write writes the rows to w, in the format of the query that selected them.
+func (r *ExportRows) Write(w io.Writer) error {
+	if r.query.Kind == ExportJobs {
+		return writeRows(w, r.query.Format, r.jobs, jobColumns, new(JobRow))
+	}
+	return writeRows(w, r.query.Format, r.events, eventColumns, new(EventRow))
+}
+
+func jobRow(ctx context.Context, db LocalDB, j *model.Job) (JobRow, error) {
+	state, err := db.GetJobState(ctx, j.ID)
+	if err != nil {
+		return JobRow{}, err
+	}
+	events, err := db.GetJobEvents(ctx, j.ID)
+	if err != nil {
+		return JobRow{}, err
+	}
+	row := JobRow{
+		JobID:           j.ID,
+		ClientID:        j.ClientID,
+		RequesterNodeID: j.RequesterNodeID,
+		CreatedAt:       j.CreatedAt.UnixMilli(),
+		UpdatedAt:       j.CreatedAt.UnixMilli(),
+		Engine:          j.Spec.Engine.String(),
+		Image:           j.Spec.Docker.Image,
+		Verifier:        j.Spec.Verifier.String(),
+		Publisher:       j.Spec.Publisher.String(),
+		CPU:             j.Spec.Resources.CPU,
+		Memory:          j.Spec.Resources.Memory,
+		GPU:             j.Spec.Resources.GPU,
+		Concurrency:     int32(j.Deal.Concurrency),
+		Shards:          int32(j.ExecutionPlan.TotalShards),
+	}
+	for _, event := range events {
+		if updated := event.EventTime.UnixMilli(); updated > row.UpdatedAt {
+			row.UpdatedAt = updated
+		}
+	}
+	for _, shard := range jobutils.FlattenShardStates(state) {
+		row.Executions++
+		switch shard.State {
+		case model.JobStateCompleted:
+			row.Completed++
+		case model.JobStateError:
+			row.Failed++
+		case model.JobStateCancelled:
+			row.Cancelled++
+		}
+	}
+	return row, nil
+}
+
+func eventRow(event model.JobEvent) EventRow {
+	return EventRow{
+		JobID:        event.JobID,
+		ShardIndex:   int32(event.ShardIndex),
+		EventName:    event.EventName.String(),
+		EventTime:    event.EventTime.UnixMilli(),
+		ClientID:     event.ClientID,
+		SourceNodeID: event.SourceNodeID,
+		TargetNodeID: event.TargetNodeID,
+		Status:       event.Status,
+	}
+}
+
+func writeRows[R interface{ record() []string }](w io.Writer, format ExportFormat, rows []R, columns []string, schema *R) error {
+	if format == ExportCSV {
+		csvWriter := csv.NewWriter(w)
+		if err := csvWriter.Write(columns); err != nil {
+			return err
+		}
+		for _, row := range rows {
+			if err := csvWriter.Write(row.record()); err != nil {
+				return err
+			}
+		}
+		csvWriter.Flush()
+		return csvWriter.Error()
+	}
+
+	parquetWriter, err := writer.NewParquetWriterFromWriter(w, schema, parquetParallelism)
+	if err != nil {
+		return fmt.Errorf("error creating parquet writer: %w", err)
+	}
+	for _, row := range rows {
+		if err = parquetWriter.Write(row); err != nil {
+			return fmt.Errorf("error writing parquet row: %w", err)
+		}
+	}
+	return parquetWriter.WriteStop()
+}
+
+func formatMillis(millis int64) string {
+	return time.UnixMilli(millis).UTC().Format(time.RFC3339Nano)
+}
+
+func formatInt(i int32) string {
+	return strconv.Itoa(int(i))
+}
@@ -0,0 +1,82 @@
+//go:build unit || !integration
+
+package inmemory
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/bacalhau/pkg/localdb"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/rs/zerolog"
+)
+
+const (
+	benchmarkRequesterNodeID = "requester-node"
+	benchmarkComputeNodeID   = "compute-node"
+)
+
+// benchmarkJobEvents returns the events of a job from being created to its
+// results being published.
+func benchmarkJobEvents(jobID string) []model.JobEvent {
+	events := []model.JobEvent{
+		{EventName: model.JobEventCreated, SourceNodeID: benchmarkRequesterNodeID},
+		{EventName: model.JobEventBid, SourceNodeID: benchmarkComputeNodeID},
+		{EventName: model.JobEventBidAccepted, SourceNodeID: benchmarkRequesterNodeID, TargetNodeID: benchmarkComputeNodeID},
+		{EventName: model.JobEventRunning, SourceNodeID: benchmarkComputeNodeID},
+		{EventName: model.JobEventResultsProposed, SourceNodeID: benchmarkComputeNodeID},
+		{EventName: model.JobEventResultsAccepted, SourceNodeID: benchmarkRequesterNodeID, TargetNodeID: benchmarkComputeNodeID},
+		{EventName: model.JobEventResultsPublished, SourceNodeID: benchmarkComputeNodeID},
+	}
+	for i := range events {
+		events[i].JobID = jobID
+		events[i].EventTime = time.Now()
+	}
+	return events
+}
+
+func handleJobEvents(b *testing.B, handler *localdb.LocalDBEventHandler, events []model.JobEvent) {
+	for _, event := range events {
+		if err := handler.HandleJobEvent(context.Background(), event); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkEventHandler measures how many job events a second a node can
+// store, with the given number of jobs already in flight.
+func BenchmarkEventHandler(b *testing.B) {
+	// the handler logs every event at debug level
+	level := zerolog.GlobalLevel()
+	zerolog.SetGlobalLevel(zerolog.InfoLevel)
+	defer zerolog.SetGlobalLevel(level)
+
+	for _, concurrentJobs := range []int{1000, 10000} {
+		b.Run(fmt.Sprintf("jobs=%d", concurrentJobs), func(b *testing.B) {
+			store, err := NewInMemoryDatastore()
+			if err != nil {
+				b.Fatal(err)
+			}
+			handler := localdb.NewLocalDBEventHandler(store)
+			// the jobs in flight have been accepted but not finished
+			for i := 0; i < concurrentJobs; i++ {
+				handleJobEvents(b, handler, benchmarkJobEvents(fmt.Sprintf("running-%d", i))[:4])
+			}
+
+			jobs := make([][]model.JobEvent, b.N)
+			for i := range jobs {
+				jobs[i] = benchmarkJobEvents(fmt.Sprintf("job-%d", i))
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			start := time.Now()
+			for _, events := range jobs {
+				handleJobEvents(b, handler, events)
+			}
+			b.ReportMetric(float64(b.N*len(jobs[0]))/time.Since(start).Seconds(), "events/s")
+		})
+	}
+}
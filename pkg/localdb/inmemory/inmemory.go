@@ -111,15 +111,27 @@ func (d *InMemoryDatastore) GetJobs(ctx context.Context, query localdb.JobQuery)
 		}
 		result = append(result, j)
 	} else {
+		if err := localdb.ValidateSearch(query); err != nil {
+			return nil, err
+		}
 		if query.ReturnAll {
 			log.Ctx(ctx).Debug().Msgf("querying for all jobs, limit %d", query.Limit)
 			for _, j := range d.jobs {
-				result = append(result, j)
+				if d.matches(j, query) {
+					result = append(result, j)
+				}
 			}
 		} else if query.ClientID != "" {
 			log.Ctx(ctx).Debug().Msgf("querying for jobs with filter ClientID %s", query.ClientID)
 			for _, j := range d.jobs {
-				if j.ClientID == query.ClientID {
+				if j.ClientID == query.ClientID && d.matches(j, query) {
+					result = append(result, j)
+				}
+			}
+		} else if query.JobGroup != "" || query.IsSearch() {
+			log.Ctx(ctx).Debug().Msgf("querying for jobs with filters %+v", query)
+			for _, j := range d.jobs {
+				if d.matches(j, query) {
 					result = append(result, j)
 				}
 			}
@@ -154,6 +166,18 @@ func (d *InMemoryDatastore) GetJobs(ctx context.Context, query localdb.JobQuery)
 	return result, nil
 }
 
+// matches returns whether the job is in the job group of the query and
+// matches its search filters.
+func (d *InMemoryDatastore) matches(j *model.Job, query localdb.JobQuery) bool {
+	return inJobGroup(j, query.JobGroup) && localdb.MatchesSearch(j, d.states[j.ID], query)
+}
+
+// inJobGroup returns whether the job is in the job group, or true if no job
+// group is given.
+func inJobGroup(j *model.Job, jobGroup string) bool {
+	return jobGroup == "" || j.Spec.JobGroup == jobGroup
+}
+
 func (d *InMemoryDatastore) HasLocalEvent(ctx context.Context, jobID string, eventFilter localdb.LocalEventFilter) (bool, error) {
 	jobLocalEvents, err := d.GetJobLocalEvents(ctx, jobID)
 	if err != nil {
@@ -202,8 +226,7 @@ func (d *InMemoryDatastore) AddEvent(ctx context.Context, jobID string, ev model
 	if !ok {
 		eventArr = []model.JobEvent{}
 	}
-	eventArr = append(eventArr, ev)
-	d.events[jobID] = eventArr
+	d.events[jobID] = localdb.InsertEvent(eventArr, ev)
 	return nil
 }
 
@@ -332,6 +355,81 @@ func (d *InMemoryDatastore) UpdateShardState(
 	return nil
 }
 
+// Snapshot returns a copy of the full contents of the datastore. The
+// sequence number is left for the caller to fill in.
+func (d *InMemoryDatastore) Snapshot(ctx context.Context) (localdb.Snapshot, error) {
+	//nolint:ineffassign,staticcheck
+	ctx, span := system.GetTracer().Start(ctx, "pkg/localdb/inmemory/InMemoryDatastore.Snapshot")
+	defer span.End()
+
+	d.mtx.RLock()
+	defer d.mtx.RUnlock()
+	snapshot := localdb.Snapshot{
+		CreatedAt:   time.Now(),
+		Jobs:        make([]*model.Job, 0, len(d.jobs)),
+		States:      make(map[string]model.JobState, len(d.states)),
+		Events:      make(map[string][]model.JobEvent, len(d.events)),
+		LocalEvents: make(map[string][]model.JobLocalEvent, len(d.localEvents)),
+	}
+	for _, j := range d.jobs {
+		jobCopy := *j
+		snapshot.Jobs = append(snapshot.Jobs, &jobCopy)
+	}
+	for id, state := range d.states {
+		snapshot.States[id] = copyJobState(*state)
+	}
+	for id, events := range d.events {
+		snapshot.Events[id] = append([]model.JobEvent{}, events...)
+	}
+	for id, events := range d.localEvents {
+		snapshot.LocalEvents[id] = append([]model.JobLocalEvent{}, events...)
+	}
+	return snapshot, nil
+}
+
+// Restore replaces the contents of the datastore with the given snapshot.
+func (d *InMemoryDatastore) Restore(ctx context.Context, snapshot localdb.Snapshot) error {
+	//nolint:ineffassign,staticcheck
+	ctx, span := system.GetTracer().Start(ctx, "pkg/localdb/inmemory/InMemoryDatastore.Restore")
+	defer span.End()
+
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	d.jobs = make(map[string]*model.Job, len(snapshot.Jobs))
+	d.states = make(map[string]*model.JobState, len(snapshot.States))
+	d.events = make(map[string][]model.JobEvent, len(snapshot.Events))
+	d.localEvents = make(map[string][]model.JobLocalEvent, len(snapshot.LocalEvents))
+	for _, j := range snapshot.Jobs {
+		jobCopy := *j
+		d.jobs[j.ID] = &jobCopy
+	}
+	for id, state := range snapshot.States {
+		stateCopy := copyJobState(state)
+		d.states[id] = &stateCopy
+	}
+	for id, events := range snapshot.Events {
+		d.events[id] = append([]model.JobEvent{}, events...)
+	}
+	for id, events := range snapshot.LocalEvents {
+		d.localEvents[id] = append([]model.JobLocalEvent{}, events...)
+	}
+	return nil
+}
+
+func copyJobState(state model.JobState) model.JobState {
+	result := model.JobState{
+		Nodes: make(map[string]model.JobNodeState, len(state.Nodes)),
+	}
+	for nodeID, nodeState := range state.Nodes {
+		shards := make(map[int]model.JobShardState, len(nodeState.Shards))
+		for shardIndex, shardState := range nodeState.Shards {
+			shards[shardIndex] = shardState
+		}
+		result.Nodes[nodeID] = model.JobNodeState{Shards: shards}
+	}
+	return result
+}
+
 // helper method to read a single job from memory. This is used by both GetJob and GetJobs.
 // It is important that we don't attempt to acquire a lock inside this method to avoid deadlocks since
 // the callers are expected to be holding a lock, and golang doesn't support reentrant locks.
@@ -362,3 +460,4 @@ func (d *InMemoryDatastore) getJob(id string) (*model.Job, error) {
 
 // Static check to ensure that Transport implements Transport:
 var _ localdb.LocalDB = (*InMemoryDatastore)(nil)
+var _ localdb.Snapshotter = (*InMemoryDatastore)(nil)
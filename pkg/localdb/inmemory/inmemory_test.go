@@ -6,6 +6,7 @@ import (
 	"context"
 	"testing"
 
+	"github.com/filecoin-project/bacalhau/pkg/localdb"
 	_ "github.com/filecoin-project/bacalhau/pkg/logger"
 	"github.com/filecoin-project/bacalhau/pkg/model"
 	"github.com/stretchr/testify/require"
@@ -77,3 +78,86 @@ func TestInMemoryDataStore(t *testing.T) {
 	require.Equal(t, model.JobStateBidding, shardState.State)
 	require.Equal(t, "hello", shardState.Status)
 }
+
+func TestGetJobsInJobGroup(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewInMemoryDatastore()
+	require.NoError(t, err)
+
+	for _, j := range []*model.Job{
+		{ID: "group-a-1", ClientID: "client-1", Spec: model.Spec{JobGroup: "a"}},
+		{ID: "group-a-2", ClientID: "client-2", Spec: model.Spec{JobGroup: "a"}},
+		{ID: "group-b-1", ClientID: "client-1", Spec: model.Spec{JobGroup: "b"}},
+		{ID: "no-group", ClientID: "client-1"},
+	} {
+		require.NoError(t, store.AddJob(ctx, j))
+	}
+
+	for _, testCase := range []struct {
+		query    localdb.JobQuery
+		expected []string
+	}{
+		{query: localdb.JobQuery{JobGroup: "a"}, expected: []string{"group-a-1", "group-a-2"}},
+		{query: localdb.JobQuery{JobGroup: "a", ClientID: "client-1"}, expected: []string{"group-a-1"}},
+		{query: localdb.JobQuery{JobGroup: "b", ReturnAll: true}, expected: []string{"group-b-1"}},
+		{query: localdb.JobQuery{ClientID: "client-1"}, expected: []string{"group-a-1", "group-b-1", "no-group"}},
+	} {
+		testCase.query.Limit = 10
+		testCase.query.SortBy = "id"
+		jobs, err := store.GetJobs(ctx, testCase.query)
+		require.NoError(t, err)
+		var ids []string
+		for _, j := range jobs {
+			ids = append(ids, j.ID)
+		}
+		require.Equal(t, testCase.expected, ids, "%+v", testCase.query)
+	}
+}
+
+func TestSearchJobs(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewInMemoryDatastore()
+	require.NoError(t, err)
+
+	for _, j := range []*model.Job{
+		{ID: "train", ClientID: "alice", Spec: model.Spec{
+			Docker:      model.JobSpecDocker{Image: "pytorch/pytorch:latest"},
+			Annotations: []string{"team=ml", "nightly"},
+			Inputs:      []model.StorageSpec{{CID: "QmInput"}},
+		}},
+		{ID: "render", ClientID: "bob", Spec: model.Spec{
+			Docker:      model.JobSpecDocker{Image: "blender"},
+			Annotations: []string{"team=art"},
+		}},
+	} {
+		require.NoError(t, store.AddJob(ctx, j))
+	}
+	require.NoError(t, store.UpdateShardState(ctx, "render", "node", 0, model.JobShardState{
+		NodeID:          "node",
+		State:           model.JobStateCompleted,
+		PublishedResult: model.StorageSpec{StorageSource: model.StorageSourceIPFS, CID: "QmOutput"},
+	}))
+
+	search := func(query localdb.JobQuery) []string {
+		query.Limit = 10
+		query.SortBy = "id"
+		jobs, err := store.GetJobs(ctx, query)
+		require.NoError(t, err)
+		ids := []string{}
+		for _, j := range jobs {
+			ids = append(ids, j.ID)
+		}
+		return ids
+	}
+
+	require.Equal(t, []string{"train"}, search(localdb.JobQuery{Image: "pytorch"}))
+	require.Equal(t, []string{"train"}, search(localdb.JobQuery{Labels: []string{"team=ml", "nightly"}}))
+	require.Equal(t, []string{"render"}, search(localdb.JobQuery{Labels: []string{"!nightly"}}))
+	require.Equal(t, []string{"render", "train"}, search(localdb.JobQuery{Labels: []string{"team"}}))
+	require.Equal(t, []string{"train"}, search(localdb.JobQuery{InputCID: "QmInput"}))
+	require.Equal(t, []string{"render"}, search(localdb.JobQuery{OutputCID: "QmOutput"}))
+	require.Empty(t, search(localdb.JobQuery{ClientID: "alice", OutputCID: "QmOutput"}))
+
+	_, err = store.GetJobs(ctx, localdb.JobQuery{Labels: []string{"=ml"}, Limit: 10})
+	require.Error(t, err)
+}
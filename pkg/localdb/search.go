@@ -0,0 +1,121 @@
+package localdb
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+)
+
+// LabelSelector matches the labels of jobs, which are their annotations.
+// Annotations of the form key=value are labels with a value, others labels
+// without one.
+type LabelSelector struct {
+	Key string
+	// The value the label must have, if any.
+	Value    string
+	HasValue bool
+	// Whether jobs must not match the rest of the selector.
+	Negated bool
+}
+
+// ParseLabelSelector reads a selector of the form key, !key, key=value or
+// key!=value.
+func ParseLabelSelector(selector string) (LabelSelector, error) {
+	selector = strings.TrimSpace(selector)
+	var s LabelSelector
+	switch {
+	case strings.Contains(selector, "!="):
+		s.Key, s.Value, _ = strings.Cut(selector, "!=")
+		s.HasValue, s.Negated = true, true
+	case strings.Contains(selector, "="):
+		s.Key, s.Value, _ = strings.Cut(selector, "=")
+		s.HasValue = true
+	case strings.HasPrefix(selector, "!"):
+		s.Key = strings.TrimPrefix(selector, "!")
+		s.Negated = true
+	default:
+		s.Key = selector
+	}
+	s.Key, s.Value = strings.TrimSpace(s.Key), strings.TrimSpace(s.Value)
+	if s.Key == "" {
+		return LabelSelector{}, fmt.Errorf("invalid label selector %q: no label", selector)
+	}
+	return s, nil
+}
+
+// Matches returns whether the annotations of a job match the selector.
+func (s LabelSelector) Matches(annotations []string) bool {
+	found := false
+	for _, annotation := range annotations {
+		key, value, hasValue := strings.Cut(annotation, "=")
+		if key != s.Key {
+			continue
+		}
+		if !s.HasValue || (hasValue && value == s.Value) {
+			found = true
+			break
+		}
+	}
+	return found != s.Negated
+}
+
+// ValidateSearch returns an error if the search filters of the query can't
+// be used.
+func ValidateSearch(query JobQuery) error {
+	for _, selector := range query.Labels {
+		if _, err := ParseLabelSelector(selector); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MatchesSearch returns whether the job matches all the search filters of the
+// query: its docker image contains Image, its annotations match all the
+// Labels selectors, one of its inputs or contexts is InputCID, and one of its
+// shards published its results to OutputCID. The state is only needed when
+// searching by OutputCID.
+func MatchesSearch(j *model.Job, state *model.JobState, query JobQuery) bool {
+	if query.Image != "" && !strings.Contains(j.Spec.Docker.Image, query.Image) {
+		return false
+	}
+	for _, selector := range query.Labels {
+		s, err := ParseLabelSelector(selector)
+		if err != nil || !s.Matches(j.Spec.Annotations) {
+			return false
+		}
+	}
+	if query.InputCID != "" && !hasInputCID(j, query.InputCID) {
+		return false
+	}
+	if query.OutputCID != "" && !hasOutputCID(state, query.OutputCID) {
+		return false
+	}
+	return true
+}
+
+func hasInputCID(j *model.Job, cid string) bool {
+	for _, specs := range [][]model.StorageSpec{j.Spec.Inputs, j.Spec.Contexts} {
+		for _, spec := range specs {
+			if spec.CID == cid {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func hasOutputCID(state *model.JobState, cid string) bool {
+	if state == nil {
+		return false
+	}
+	for _, node := range state.Nodes {
+		for _, shard := range node.Shards {
+			if shard.PublishedResult.CID == cid {
+				return true
+			}
+		}
+	}
+	return false
+}
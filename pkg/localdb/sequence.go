@@ -0,0 +1,122 @@
+package localdb
+
+import (
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+)
+
+// markRetention is how long the sequence marks of a job are kept after its
+// last event. They are loaded from the events of the job again if it gets
+// another one.
+const markRetention = 10 * time.Minute
+
+// markKey is the shard state the events of a source node update: that of the
+// target node if there is one, e.g. for the bids the requester node accepts,
+// or that of the source node itself.
+type markKey struct {
+	sourceNodeID string
+	targetNodeID string
+	shardIndex   int
+}
+
+// sequence is where an event is in the sequence of the events its source node
+// sent for its job.
+type sequence struct {
+	epoch  int64
+	number uint64
+}
+
+func sequenceOf(event model.JobEvent) sequence {
+	return sequence{epoch: event.SequenceEpoch, number: event.SequenceNumber}
+}
+
+// before returns whether the source node sent the event of s before that of
+// other, going by the epochs and then the numbers of their sequences.
+func (s sequence) before(other sequence) bool {
+	if s.epoch != other.epoch {
+		return s.epoch < other.epoch
+	}
+	return s.number < other.number
+}
+
+type jobMarks struct {
+	latest    map[markKey]sequence
+	lastEvent time.Time
+}
+
+// sequenceMarks holds the latest event each node sent about each shard state
+// of the jobs that had events recently, so that stale events can be told apart
+// without going through all the events of their job.
+type sequenceMarks struct {
+	mutex     sync.Mutex
+	jobs      map[string]*jobMarks
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func newSequenceMarks() *sequenceMarks {
+	return &sequenceMarks{
+		jobs: map[string]*jobMarks{},
+		now:  time.Now,
+	}
+}
+
+// isStale returns whether the source node of the event has already sent a
+// later event about the same shard state, in which case the state the event
+// moves the shard to has been left behind already. The event is the latest
+// otherwise. load returns the events of the job, including this one, to find
+// the latest events of the jobs whose marks aren't held.
+func (m *sequenceMarks) isStale(event model.JobEvent, load func() ([]model.JobEvent, error)) (bool, error) {
+	if event.SequenceNumber == 0 {
+		return false, nil
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	now := m.now()
+	m.sweep(now)
+
+	marks, ok := m.jobs[event.JobID]
+	if !ok {
+		events, err := load()
+		if err != nil {
+			return false, err
+		}
+		marks = &jobMarks{latest: map[markKey]sequence{}}
+		for i := range events {
+			marks.record(events[i])
+		}
+		m.jobs[event.JobID] = marks
+	}
+	marks.lastEvent = now
+	return marks.record(event), nil
+}
+
+// record returns whether the event is stale, and records it as the latest
+// event about its shard state otherwise.
+func (j *jobMarks) record(event model.JobEvent) (stale bool) {
+	if event.SequenceNumber == 0 {
+		return false
+	}
+	key := markKey{sourceNodeID: event.SourceNodeID, targetNodeID: event.TargetNodeID, shardIndex: event.ShardIndex}
+	current := sequenceOf(event)
+	if latest, ok := j.latest[key]; ok && current.before(latest) {
+		return true
+	}
+	j.latest[key] = current
+	return false
+}
+
+// sweep drops the marks of the jobs that had no events for the retention.
+func (m *sequenceMarks) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < markRetention {
+		return
+	}
+	m.lastSweep = now
+	for jobID, marks := range m.jobs {
+		if now.Sub(marks.lastEvent) >= markRetention {
+			delete(m.jobs, jobID)
+		}
+	}
+}
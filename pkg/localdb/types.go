@@ -2,6 +2,7 @@ package localdb
 
 import (
 	"context"
+	"time"
 
 	"github.com/filecoin-project/bacalhau/pkg/model"
 )
@@ -9,10 +10,22 @@ import (
 type JobQuery struct {
 	ID          string `json:"id"`
 	ClientID    string `json:"clientID"`
+	JobGroup    string `json:"job_group"`
 	Limit       int    `json:"limit"`
 	ReturnAll   bool   `json:"return_all"`
 	SortBy      string `json:"sort_by"`
 	SortReverse bool   `json:"sort_reverse"`
+
+	// Search filters, which jobs must match all of. See MatchesSearch.
+	Image     string   `json:"image,omitempty"`
+	Labels    []string `json:"labels,omitempty"`
+	InputCID  string   `json:"input_cid,omitempty"`
+	OutputCID string   `json:"output_cid,omitempty"`
+}
+
+// IsSearch returns whether the query filters jobs by their contents.
+func (q JobQuery) IsSearch() bool {
+	return q.Image != "" || len(q.Labels) > 0 || q.InputCID != "" || q.OutputCID != ""
 }
 
 type LocalEventFilter func(ev model.JobLocalEvent) bool
@@ -42,3 +55,24 @@ type LocalDB interface {
 		state model.JobShardState,
 	) error
 }
+
+// A Snapshot is a point in time copy of everything held by a LocalDB. The
+// Sequence is the number of the last event log record that was applied
+// before the snapshot was taken, so replay can resume right after it.
+type Snapshot struct {
+	Sequence    uint64                           `json:"sequence"`
+	CreatedAt   time.Time                        `json:"created_at"`
+	Jobs        []*model.Job                     `json:"jobs"`
+	States      map[string]model.JobState        `json:"states"`
+	Events      map[string][]model.JobEvent      `json:"events"`
+	LocalEvents map[string][]model.JobLocalEvent `json:"local_events"`
+}
+
+// A Snapshotter is a LocalDB that can export its full contents and later be
+// restored from that export. It is used by the event log to avoid replaying
+// the whole history on every restart. The returned snapshot must not share
+// state with the LocalDB, as it is written out while events keep coming in.
+type Snapshotter interface {
+	Snapshot(ctx context.Context) (Snapshot, error)
+	Restore(ctx context.Context, snapshot Snapshot) error
+}
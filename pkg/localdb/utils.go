@@ -2,11 +2,41 @@ package localdb
 
 import (
 	jobutils "github.com/filecoin-project/bacalhau/pkg/job"
+	"github.com/filecoin-project/bacalhau/pkg/model"
 )
 
 func GetStateResolver(db LocalDB) *jobutils.StateResolver {
-	return jobutils.NewStateResolver(
+	resolver := jobutils.NewStateResolver(
 		db.GetJob,
 		db.GetJobState,
 	)
+	resolver.SetWaitBackoff(jobutils.DefaultWaitBackoff, jobutils.DefaultMaxWaitDelay)
+	return resolver
+}
+
+// InsertEvent returns the events of a job with the event added after the
+// events its source node sent before it, going by their sequences rather than
+// the clocks of the nodes. Events without a sequence number are appended, as
+// are events from other nodes, and events that are already held are dropped.
+func InsertEvent(events []model.JobEvent, event model.JobEvent) []model.JobEvent {
+	if event.SequenceNumber == 0 {
+		return append(events, event)
+	}
+	position := len(events)
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i].SourceNodeID != event.SourceNodeID || events[i].SequenceNumber == 0 {
+			continue
+		}
+		if events[i].SequenceEpoch == event.SequenceEpoch && events[i].SequenceNumber == event.SequenceNumber {
+			return events
+		}
+		if sequenceOf(events[i]).before(sequenceOf(event)) {
+			break
+		}
+		position = i
+	}
+	events = append(events, model.JobEvent{})
+	copy(events[position+1:], events[position:])
+	events[position] = event
+	return events
 }
@@ -0,0 +1,106 @@
+//go:build unit || !integration
+
+package localdb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/stretchr/testify/require"
+)
+
+func event(node string, sequence uint64) model.JobEvent {
+	return model.JobEvent{SourceNodeID: node, SequenceNumber: sequence}
+}
+
+func TestInsertEvent(t *testing.T) {
+	var events []model.JobEvent
+	for _, ev := range []model.JobEvent{event("a", 1), event("b", 1), event("a", 3), event("b", 2), event("a", 2), event("a", 3), event("c", 0)} {
+		events = InsertEvent(events, ev)
+	}
+	require.Equal(t, []model.JobEvent{
+		event("a", 1), event("b", 1), event("a", 2), event("a", 3), event("b", 2), event("c", 0),
+	}, events)
+}
+
+func TestInsertEventAfterRestart(t *testing.T) {
+	first, second, third := withEpoch(event("a", 1), 1), withEpoch(event("a", 2), 1), withEpoch(event("a", 3), 1)
+	restarted := withEpoch(event("a", 1), 2)
+	var events []model.JobEvent
+	for _, ev := range []model.JobEvent{first, second, restarted, third, restarted} {
+		events = InsertEvent(events, ev)
+	}
+	// the numbering started over after the restart, but with a later epoch
+	require.Equal(t, []model.JobEvent{first, second, third, restarted}, events)
+}
+
+func withEpoch(ev model.JobEvent, epoch int64) model.JobEvent {
+	ev.SequenceEpoch = epoch
+	return ev
+}
+
+// isStale checks the event against the marks, loading the events of its job
+// from held if the marks of the job aren't held
+func isStale(t *testing.T, marks *sequenceMarks, held []model.JobEvent, ev model.JobEvent) bool {
+	stale, err := marks.isStale(ev, func() ([]model.JobEvent, error) {
+		return append(held, ev), nil
+	})
+	require.NoError(t, err)
+	return stale
+}
+
+func TestSequenceMarks(t *testing.T) {
+	marks := newSequenceMarks()
+	require.False(t, isStale(t, marks, nil, event("a", 1)))
+	require.False(t, isStale(t, marks, nil, event("a", 3)))
+	require.True(t, isStale(t, marks, nil, event("a", 2)))
+	require.False(t, isStale(t, marks, nil, event("a", 4)))
+	require.False(t, isStale(t, marks, nil, event("b", 1)))
+	require.False(t, isStale(t, marks, nil, event("a", 0)))
+
+	// events about other nodes don't make it stale, e.g. the bids the
+	// requester node accepts for each of them
+	bidAccepted := func(target string, sequence uint64) model.JobEvent {
+		ev := event("requester", sequence)
+		ev.TargetNodeID = target
+		return ev
+	}
+	require.False(t, isStale(t, marks, nil, bidAccepted("y", 3)))
+	require.False(t, isStale(t, marks, nil, bidAccepted("x", 2)))
+	require.False(t, isStale(t, marks, nil, bidAccepted("x", 4)))
+	require.True(t, isStale(t, marks, nil, bidAccepted("x", 3)))
+
+	// the numbering of a node starts over after it restarts, with a later
+	// epoch
+	require.False(t, isStale(t, marks, nil, withEpoch(event("a", 1), 1)))
+	require.False(t, isStale(t, marks, nil, withEpoch(event("a", 2), 1)))
+	require.True(t, isStale(t, marks, nil, event("a", 5)))
+}
+
+func TestSequenceMarksLoadsEvents(t *testing.T) {
+	now := time.Now()
+	marks := newSequenceMarks()
+	marks.now = func() time.Time { return now }
+
+	// e.g. after restoring a snapshot, the marks are taken from the events
+	// already held
+	held := []model.JobEvent{event("a", 1), event("a", 3)}
+	require.True(t, isStale(t, marks, held, event("a", 2)))
+
+	loaded := 0
+	load := func() ([]model.JobEvent, error) {
+		loaded++
+		return nil, nil
+	}
+	_, err := marks.isStale(event("a", 4), load)
+	require.NoError(t, err)
+	require.Equal(t, 0, loaded, "the events of the job are only loaded once")
+
+	// the marks of jobs with no events for a while are dropped, and loaded
+	// again
+	now = now.Add(markRetention)
+	_, err = marks.isStale(event("a", 5), load)
+	require.NoError(t, err)
+	require.Equal(t, 1, loaded)
+}
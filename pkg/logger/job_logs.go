@@ -0,0 +1,131 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+)
+
+// The number of job log lines kept for querying.
+const jobLogsSize = 10000
+
+// The number of log lines a subscriber can fall behind by before it is dropped.
+const jobLogSubscriberBufferSize = 256
+
+var jobLogs = &jobLogBuffer{
+	lines:       make([]jobLogLine, jobLogsSize),
+	subscribers: map[string][]chan json.RawMessage{},
+}
+
+type jobLogLine struct {
+	jobID string
+	line  json.RawMessage
+}
+
+// jobLogBuffer keeps the most recent JSON log lines that have a job ID, and
+// hands new ones to the subscribers to their job.
+type jobLogBuffer struct {
+	mu    sync.Mutex
+	lines []jobLogLine
+	next  int
+	full  bool
+	// jobId -> subscribers
+	subscribers map[string][]chan json.RawMessage
+}
+
+func (b *jobLogBuffer) Write(p []byte) (int, error) {
+	if !bytes.Contains(p, []byte(`"`+jobIDFieldName+`":`)) {
+		return len(p), nil
+	}
+	var fields struct {
+		JobID string `json:"JobID"`
+	}
+	if err := json.Unmarshal(p, &fields); err != nil || fields.JobID == "" {
+		return len(p), nil
+	}
+
+	// zerolog reuses p once Write returns
+	line := make(json.RawMessage, len(p))
+	copy(line, p)
+	line = bytes.TrimSpace(line)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.lines[b.next] = jobLogLine{jobID: fields.JobID, line: line}
+	b.next = (b.next + 1) % len(b.lines)
+	b.full = b.full || b.next == 0
+
+	// subscribers that have fallen behind are dropped rather than slowing
+	// the logging down
+	subscribers := b.subscribers[fields.JobID]
+	kept := subscribers[:0]
+	for _, subscriber := range subscribers {
+		select {
+		case subscriber <- line:
+			kept = append(kept, subscriber)
+		default:
+			close(subscriber)
+		}
+	}
+	if len(kept) == 0 {
+		delete(b.subscribers, fields.JobID)
+	} else {
+		b.subscribers[fields.JobID] = kept
+	}
+	return len(p), nil
+}
+
+// JobLogs returns the most recent log lines of this node for a job, oldest
+// first, as JSON objects.
+func JobLogs(jobID string) []json.RawMessage {
+	jobLogs.mu.Lock()
+	defer jobLogs.mu.Unlock()
+	return jobLogs.recent(jobID)
+}
+
+// SubscribeJobLogs returns the most recent log lines of this node for a job,
+// like JobLogs, and a channel that receives the lines logged for the job
+// after them. The channel is closed if the subscriber falls too far behind.
+// unsubscribe must be called once the subscriber stops reading.
+func SubscribeJobLogs(jobID string) (recent []json.RawMessage, lines <-chan json.RawMessage, unsubscribe func()) {
+	jobLogs.mu.Lock()
+	defer jobLogs.mu.Unlock()
+
+	subscriber := make(chan json.RawMessage, jobLogSubscriberBufferSize)
+	jobLogs.subscribers[jobID] = append(jobLogs.subscribers[jobID], subscriber)
+	unsubscribe = func() {
+		jobLogs.mu.Lock()
+		defer jobLogs.mu.Unlock()
+		subscribers := jobLogs.subscribers[jobID]
+		for i, s := range subscribers {
+			if s == subscriber {
+				jobLogs.subscribers[jobID] = append(subscribers[:i], subscribers[i+1:]...)
+				break
+			}
+		}
+		if len(jobLogs.subscribers[jobID]) == 0 {
+			delete(jobLogs.subscribers, jobID)
+		}
+	}
+	return jobLogs.recent(jobID), subscriber, unsubscribe
+}
+
+// recent returns the log lines kept for a job, oldest first. The caller must
+// hold mu.
+func (b *jobLogBuffer) recent(jobID string) []json.RawMessage {
+	start := 0
+	if b.full {
+		start = b.next
+	}
+	var lines []json.RawMessage
+	for i := 0; i < len(b.lines); i++ {
+		entry := b.lines[(start+i)%len(b.lines)]
+		if entry.line == nil {
+			break
+		}
+		if entry.jobID == jobID {
+			lines = append(lines, entry.line)
+		}
+	}
+	return lines
+}
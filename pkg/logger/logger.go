@@ -19,6 +19,8 @@ import (
 )
 
 var nodeIDFieldName = "NodeID"
+var jobIDFieldName = "JobID"
+var shardIndexFieldName = "ShardIndex"
 
 func init() { //nolint:gochecknoinits // init with zerolog is idiomatic
 	configureLogging()
@@ -128,6 +130,9 @@ func configureLogging(loggingOptions ...func(w *zerolog.ConsoleWriter)) {
 		useLogWriter = io.Discard
 	}
 
+	// keep recent job logs so they can be queried by job ID
+	useLogWriter = zerolog.MultiLevelWriter(useLogWriter, jobLogs)
+
 	log.Logger = zerolog.New(useLogWriter).With().Timestamp().Caller().Logger()
 	// While the normal flow will use ContextWithNodeIDLogger, this won't be so for tests.
 	// Tests will use the DefaultContextLogger instead
@@ -149,6 +154,26 @@ func ContextWithNodeIDLogger(ctx context.Context, nodeID string) context.Context
 	return l.WithContext(ctx)
 }
 
+type jobShardContextKey struct{}
+
+type jobShard struct {
+	jobID      string
+	shardIndex int
+}
+
+// ContextWithJobShardLogger will return a context with the job ID and shard
+// index added to the logging context, so that the logs of a job can be
+// correlated across subsystems and queried with JobLogs.
+func ContextWithJobShardLogger(ctx context.Context, jobID string, shardIndex int) context.Context {
+	current := jobShard{jobID: jobID, shardIndex: shardIndex}
+	if existing, ok := ctx.Value(jobShardContextKey{}).(jobShard); ok && existing == current {
+		// don't add the same fields twice
+		return ctx
+	}
+	l := zerolog.Ctx(ctx).With().Str(jobIDFieldName, jobID).Int(shardIndexFieldName, shardIndex).Logger()
+	return context.WithValue(l.WithContext(ctx), jobShardContextKey{}, current)
+}
+
 type zerologWriteSyncer struct {
 	l zerolog.Logger
 }
@@ -0,0 +1,33 @@
+package model
+
+import "time"
+
+// ClientKeyRotation is the endorsement of a new ID key of a client by the key
+// it replaced, so that the client keeps its client ID, which is derived from
+// its first key, and the jobs it owns when it rotates its key.
+type ClientKeyRotation struct {
+	// The base64-encoded public key that was rotated out.
+	PreviousPublicKey string `json:"PreviousPublicKey"`
+
+	// The base64-encoded public key that replaced it.
+	PublicKey string `json:"PublicKey"`
+
+	// Until when signatures of the previous key are still accepted.
+	Expires time.Time `json:"Expires"`
+
+	// A base64-encoded signature of the new key and the expiry, signed by the
+	// previous key.
+	Signature string `json:"Signature"`
+}
+
+// ClientKeyInfo describes the ID key of a client, e.g. to allowlist it.
+type ClientKeyInfo struct {
+	// The id of the client, which stays the same when it rotates its key.
+	ClientID string `json:"ClientID"`
+
+	// The base64-encoded public key the client currently signs with.
+	PublicKey string `json:"PublicKey"`
+
+	// The fingerprint of the public key, for checking it out of band.
+	Fingerprint string `json:"Fingerprint"`
+}
@@ -18,6 +18,24 @@ type RunCommandResult struct {
 
 	// Runner error
 	ErrorMsg string `json:"runnerError"`
+
+	// contents of the files of the output volumes by their path in the
+	// results, if they are small enough to show without fetching the results.
+	Outputs map[string]string `json:"outputs,omitempty"`
+
+	// the files of the results, as in the manifest published with them.
+	Manifest []ResultFile `json:"manifest,omitempty"`
+
+	// the image the shard ran, for executors that run images.
+	Provenance *ImageProvenance `json:"provenance,omitempty"`
+}
+
+// ResultFile is a file in the published results of a shard, by its
+// slash-separated path in them, so consumers can verify what they download.
+type ResultFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
 }
 
 func NewRunCommandResult() *RunCommandResult {
@@ -1,6 +1,8 @@
 package model
 
 import (
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/imdario/mergo"
@@ -44,6 +46,9 @@ type Job struct {
 
 	// All local events associated with the job
 	LocalEvents []JobLocalEvent `json:"LocalJobEvents,omitempty"`
+
+	// Why compute nodes declined to bid on the job, for those that report it
+	BidDeclines []BidDecline `json:"BidDeclines,omitempty"`
 }
 
 func (job Job) String() string {
@@ -79,6 +84,95 @@ func NewJobWithSaneProductionDefaults() (*Job, error) {
 	return j, nil
 }
 
+// NodeEnergy is what a compute node says about the energy it runs on, so
+// that jobs can favor greener nodes.
+type NodeEnergy struct {
+	// Whether the node runs on renewable power.
+	Renewable bool `json:"Renewable,omitempty"`
+	// The carbon intensity of the power of the node in gCO2eq/kWh, if known.
+	CarbonIntensity *float64 `json:"CarbonIntensity,omitempty"`
+}
+
+func (e *NodeEnergy) String() string {
+	if e == nil {
+		return "unknown energy"
+	}
+	power := "non-renewable power"
+	if e.Renewable {
+		power = "renewable power"
+	}
+	if e.CarbonIntensity == nil {
+		return power + " of unknown carbon intensity"
+	}
+	return fmt.Sprintf("%s of %g gCO2eq/kWh", power, *e.CarbonIntensity)
+}
+
+// Greener returns true if the node runs on greener energy than the other:
+// renewable power first, then lower carbon intensity, and unknown last.
+func (e *NodeEnergy) Greener(other *NodeEnergy) bool {
+	if e == nil || other == nil {
+		return e != nil && other == nil
+	}
+	if e.Renewable != other.Renewable {
+		return e.Renewable
+	}
+	if e.CarbonIntensity == nil || other.CarbonIntensity == nil {
+		return e.CarbonIntensity != nil && other.CarbonIntensity == nil
+	}
+	return *e.CarbonIntensity < *other.CarbonIntensity
+}
+
+// NodeHardening is what a compute node says about the hardening it applies
+// to every job container, so that jobs can require hardened nodes.
+type NodeHardening struct {
+	// The name of the seccomp profile containers run with, or empty for the
+	// default of the runtime.
+	SeccompProfile string `json:"SeccompProfile,omitempty"`
+	// The AppArmor profile containers run with, or empty for the default of
+	// the runtime.
+	AppArmorProfile string `json:"AppArmorProfile,omitempty"`
+	// The most processes a container can run, or 0 for no limit.
+	PidsLimit int64 `json:"PidsLimit,omitempty"`
+	// The ulimits of containers, as NAME=SOFT[:HARD].
+	Ulimits []string `json:"Ulimits,omitempty"`
+}
+
+// Hardened returns true if the node runs containers with a seccomp or
+// AppArmor profile of its own and a pids limit.
+func (h *NodeHardening) Hardened() bool {
+	return h != nil && (h.SeccompProfile != "" || h.AppArmorProfile != "") && h.PidsLimit > 0
+}
+
+func (h *NodeHardening) String() string {
+	if h == nil {
+		return "no hardening"
+	}
+	var parts []string
+	if h.SeccompProfile != "" {
+		parts = append(parts, "seccomp profile "+h.SeccompProfile)
+	}
+	if h.AppArmorProfile != "" {
+		parts = append(parts, "AppArmor profile "+h.AppArmorProfile)
+	}
+	if h.PidsLimit > 0 {
+		parts = append(parts, fmt.Sprintf("pids limit %d", h.PidsLimit))
+	}
+	if len(h.Ulimits) > 0 {
+		parts = append(parts, "ulimits "+strings.Join(h.Ulimits, ", "))
+	}
+	if len(parts) == 0 {
+		return "no hardening"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// BidDecline is a reason compute nodes gave for declining to bid on a job,
+// and the nodes that gave it.
+type BidDecline struct {
+	Reason  string   `json:"Reason"`
+	NodeIDs []string `json:"NodeIDs"`
+}
+
 // JobWithInfo is the job request + the result of attempting to run it on the network
 type JobWithInfo struct {
 	Job            Job             `json:"Job,omitempty"`
@@ -184,6 +278,50 @@ type Deal struct {
 	// jobs will be spread evenly across the network (assuming that this value
 	// is some large proportion of the size of the network).
 	MinBids int `json:"MinBids,omitempty"`
+	// The IDs of the nodes the job should be offered to. When set, the job is
+	// sent directly to these nodes instead of being gossiped to the whole
+	// network, which is useful for private or latency-sensitive workloads.
+	TargetNodes []string `json:"TargetNodes,omitempty"`
+	// The time by which the job must have completed. If it can't be
+	// scheduled or completed before then, the requester node fails it rather
+	// than leaving it pending. Zero means no deadline.
+	Deadline time.Time `json:"Deadline,omitempty"`
+	// The class of the job, which sets how strongly it is guaranteed to keep
+	// running once scheduled. Empty means JobClassStandard.
+	Class JobClass `json:"Class,omitempty"`
+	// Whether no two shards of the job may run on the same node, so that
+	// losing a node loses at most one shard.
+	ShardAntiAffinity bool `json:"ShardAntiAffinity,omitempty"`
+	// The number of distinct zones the concurrency-many nodes running each
+	// shard must be spread across, so that verification by replication
+	// survives losing a zone. Nodes that don't say what zone they are in
+	// count as a zone of their own, so that without zones this is the number
+	// of distinct nodes. Zero or one means no constraint.
+	MinZones int `json:"MinZones,omitempty"`
+	// Whether to favor the bids of nodes that run on renewable power, then of
+	// those whose power has a lower carbon intensity.
+	PreferGreenNodes bool `json:"PreferGreenNodes,omitempty"`
+	// Whether to only accept the bids of nodes that run containers with a
+	// seccomp or AppArmor profile of their own and a pids limit.
+	RequireHardenedNodes bool `json:"RequireHardenedNodes,omitempty"`
+}
+
+// JobClass is how strongly a job is guaranteed to keep running once a
+// compute node has started it.
+type JobClass string
+
+const (
+	// JobClassStandard jobs run until they complete, fail or time out.
+	JobClassStandard JobClass = "standard"
+	// JobClassSpot jobs are best-effort work on spare capacity, which
+	// compute nodes may evict at any time, for example to make room for
+	// standard jobs.
+	JobClassSpot JobClass = "spot"
+)
+
+// IsSpot returns true if the job can be evicted by compute nodes.
+func (d Deal) IsSpot() bool {
+	return d.Class == JobClassSpot
 }
 
 // Spec is a complete specification of a job that can be run on some
@@ -194,9 +332,38 @@ type Spec struct {
 
 	Verifier Verifier `json:"Verifier,omitempty"`
 
+	// The endpoint that decides whether to accept the published results of
+	// the shards, if the job is verified by the webhook verifier
+	VerificationWebhook VerificationWebhook `json:"VerificationWebhook,omitempty"`
+
+	// How long in seconds after the results of a shard are provisionally
+	// accepted by the optimistic verifier that they can be challenged. The
+	// requester node's default if zero.
+	ChallengePeriod float64 `json:"ChallengePeriod,omitempty"`
+
+	// The results this job re-executes a shard to check, if it was
+	// submitted by a requester node for a challenge
+	Challenge JobChallenge `json:"Challenge,omitempty"`
+
 	// there can be multiple publishers for the job
 	Publisher Publisher `json:"Publisher,omitempty"`
 
+	// Only upload the blocks of the results that the publisher has not
+	// uploaded before, e.g. for a job run again over a growing dataset. The
+	// published CID is the same, as unchanged files have the same blocks.
+	PublishIncrementally bool `json:"PublishIncrementally,omitempty"`
+
+	// Keep the environment of shards that fail, on nodes that allow it, so
+	// that the client can attach a shell to it to debug the job for as long
+	// as the node keeps it.
+	DebugOnFailure bool `json:"DebugOnFailure,omitempty"`
+
+	// Complete shards as soon as their results are ready on the compute
+	// node, rather than once they are published, and publish them in the
+	// background, retrying failed attempts. The progress of publishing is
+	// reported by PublishProgress, PublishCompleted and PublishFailed events.
+	PublishAsynchronously bool `json:"PublishAsynchronously,omitempty"`
+
 	// executor specific data
 	Docker   JobSpecDocker   `json:"Docker,omitempty"`
 	Language JobSpecLanguage `json:"Language,omitempty"`
@@ -223,6 +390,17 @@ type Spec struct {
 	// for example "write the results to ipfs"
 	Outputs []StorageSpec `json:"outputs,omitempty"`
 
+	// Writable volumes the job can use while it runs, which are not published
+	Scratch []ScratchVolume `json:"Scratch,omitempty"`
+
+	// Environment variables to run the job with, whatever its engine
+	Env map[string]string `json:"Env,omitempty"`
+
+	// Secrets to run the job with as environment variables. Only their names
+	// are in the spec, so that their values are never gossiped: compute nodes
+	// fetch the values from their own secrets backend when they run the job.
+	Secrets []SecretSpec `json:"Secrets,omitempty"`
+
 	// Annotations on the job - could be user or machine assigned
 	Annotations []string `json:"Annotations,omitempty"`
 
@@ -232,6 +410,13 @@ type Spec struct {
 
 	// Do not track specified by the client
 	DoNotTrack bool `json:"DoNotTrack,omitempty"`
+
+	// The ID of the job this job is a resubmission of, if any
+	ResubmittedFrom string `json:"ResubmittedFrom,omitempty"`
+
+	// The ID of the group of jobs this job was submitted in a batch with, if
+	// any, by which the jobs can be listed, cancelled and waited on together
+	JobGroup string `json:"JobGroup,omitempty"`
 }
 
 // Return timeout duration
@@ -239,16 +424,70 @@ func (s *Spec) GetTimeout() time.Duration {
 	return time.Duration(s.Timeout * float64(time.Second))
 }
 
+// VerificationWebhook is an endpoint the requester node POSTs the results
+// each node published for a shard to once all of them are published, and
+// that accepts or rejects each of them, e.g. after checking their schema.
+type VerificationWebhook struct {
+	URL string `json:"URL,omitempty"`
+
+	// How long in seconds to wait for the endpoint to decide, after which
+	// the shard fails. The verifier's default if zero.
+	Timeout float64 `json:"Timeout,omitempty"`
+
+	// Whether to send a URL that each of the results can be downloaded from
+	IncludeDownloadURL bool `json:"IncludeDownloadURL,omitempty"`
+}
+
+// Return timeout duration
+func (w VerificationWebhook) GetTimeout() time.Duration {
+	return time.Duration(w.Timeout * float64(time.Second))
+}
+
+// Return challenge period duration
+func (s *Spec) GetChallengePeriod() time.Duration {
+	return time.Duration(s.ChallengePeriod * float64(time.Second))
+}
+
+// JobChallenge is the results a node published for a shard of a job that
+// were challenged.
+type JobChallenge struct {
+	JobID      string `json:"JobID,omitempty"`
+	ShardIndex int    `json:"ShardIndex,omitempty"`
+	NodeID     string `json:"NodeID,omitempty"`
+}
+
+// SecretSpec is a secret that compute nodes inject into a job.
+type SecretSpec struct {
+	// The name of the secret in the secrets backend of the compute node.
+	Name string `json:"Name"`
+	// The environment variable to inject the secret as, the name if empty.
+	Env string `json:"Env,omitempty"`
+}
+
+// EnvName returns the environment variable the secret is injected as.
+func (s SecretSpec) EnvName() string {
+	if s.Env != "" {
+		return s.Env
+	}
+	return s.Name
+}
+
 // for VM style executors
 type JobSpecDocker struct {
 	// this should be pullable by docker
 	Image string `json:"Image,omitempty"`
 	// optionally override the default entrypoint
 	Entrypoint []string `json:"Entrypoint,omitempty"`
-	// a map of env to run the container with
+	// the environment variables to run the container with, as NAME=value
 	EnvironmentVariables []string `json:"EnvironmentVariables,omitempty"`
 	// working directory inside the container
 	WorkingDirectory string `json:"WorkingDirectory,omitempty"`
+	// the user to run the container as, a name or UID with an optional group
+	// such as 1000:1000, or the user of the image if empty
+	User string `json:"User,omitempty"`
+	// the path of a file in one of the input volumes to pass to the
+	// entrypoint on its stdin, e.g. what was piped into the client
+	Stdin string `json:"Stdin,omitempty"`
 }
 
 // for language style executors (can target docker or wasm)
@@ -294,10 +533,11 @@ type JobSpecWasm struct {
 // can keep state against a job without broadcasting it
 // to the rest of the network
 type JobLocalEvent struct {
-	EventName    JobLocalEventType `json:"EventName,omitempty"`
-	JobID        string            `json:"JobID,omitempty"`
-	ShardIndex   int               `json:"ShardIndex,omitempty"`
-	TargetNodeID string            `json:"TargetNodeID,omitempty"`
+	EventName      JobLocalEventType `json:"EventName,omitempty"`
+	JobID          string            `json:"JobID,omitempty"`
+	ShardIndex     int               `json:"ShardIndex,omitempty"`
+	TargetNodeID   string            `json:"TargetNodeID,omitempty"`
+	IdempotencyKey string            `json:"IdempotencyKey,omitempty"`
 }
 
 // we emit these to other nodes so they update their
@@ -317,6 +557,15 @@ type JobEvent struct {
 	// e.g. "AcceptJobBid" was emitted by Requester but it targeting compute node
 	TargetNodeID string       `json:"TargetNodeID,omitempty" example:"QmdZQ7ZbhnvWY1J12XYKGHApJ6aufKyLNSvf8jZBrBaAVL"`
 	EventName    JobEventType `json:"EventName,omitempty"`
+	// the zone the source node is in, if it says, this is only defined in
+	// "bid" events
+	SourceNodeZone string `json:"SourceNodeZone,omitempty"`
+	// what the source node says about the energy it runs on, this is only
+	// defined in "bid" events
+	SourceNodeEnergy *NodeEnergy `json:"SourceNodeEnergy,omitempty"`
+	// what the source node says about the hardening of its containers, this
+	// is only defined in "bid" events
+	SourceNodeHardening *NodeHardening `json:"SourceNodeHardening,omitempty"`
 	// this is only defined in "create" events
 	Spec Spec `json:"Spec,omitempty"`
 	// this is only defined in "create" events
@@ -328,7 +577,16 @@ type JobEvent struct {
 	VerificationResult   VerificationResult `json:"VerificationResult,omitempty"`
 	PublishedResult      StorageSpec        `json:"PublishedResult,omitempty"`
 
-	EventTime       time.Time `json:"EventTime,omitempty" example:"2022-11-17T13:32:55.756658941Z"`
+	EventTime time.Time `json:"EventTime,omitempty" example:"2022-11-17T13:32:55.756658941Z"`
+	// the position of this event among those the source node sent for the
+	// job since the SequenceEpoch, starting at 1. Zero for events from nodes
+	// that don't number them.
+	SequenceNumber uint64 `json:"SequenceNumber,omitempty" example:"3"`
+	// when the source node started numbering the events of the job, in
+	// nanoseconds since the Unix epoch. The numbering starts over with a later
+	// epoch, e.g. after the node restarts, so events are ordered by epoch
+	// before number.
+	SequenceEpoch   int64     `json:"SequenceEpoch,omitempty" example:"1668692575756658941"`
 	SenderPublicKey PublicKey `json:"SenderPublicKey,omitempty"`
 
 	// RunOutput of the job
@@ -355,4 +613,128 @@ type JobCreatePayload struct {
 	// mounted as storage for the job. Not part of the spec so we don't
 	// flood the transport layer with it (potentially very large).
 	Context string `json:"Context,omitempty" validate:"optional"`
+
+	// Optional key chosen by the client to make the submission idempotent.
+	// Submitting again with the same key returns the job created by the
+	// first submission instead of creating another one.
+	IdempotencyKey string `json:"IdempotencyKey,omitempty" validate:"optional"`
+}
+
+// JobBatchCreatePayload is the payload of a request to submit many jobs at
+// once.
+type JobBatchCreatePayload struct {
+	// the id of the client that is submitting the jobs
+	ClientID string `json:"ClientID,omitempty" validate:"required"`
+
+	// The job specifications:
+	Jobs []*Job `json:"Jobs,omitempty" validate:"required"`
+
+	// Whether to submit none of the jobs if any of them is invalid or fails
+	// to submit, rather than submitting the others.
+	Atomic bool `json:"Atomic,omitempty"`
+}
+
+// WebhookPayload is the payload of a request to register or unregister a
+// webhook that is called when jobs change state.
+type WebhookPayload struct {
+	// the id of the client the webhook belongs to
+	ClientID string `json:"ClientID,omitempty" validate:"required"`
+
+	// The job to call the webhook for. If empty, the webhook is called for
+	// every job of the client.
+	JobID string `json:"JobID,omitempty" validate:"optional"`
+
+	// The URL the state changes are posted to.
+	URL string `json:"URL,omitempty" validate:"required"`
+
+	// The key the posted payloads are signed with, using HMAC-SHA256. Only
+	// needed to register the webhook.
+	Secret string `json:"Secret,omitempty" validate:"optional"`
+
+	// When the request was made, and a random value unique to it, so that a
+	// captured request can't be replayed to register or unregister the
+	// webhook again.
+	CreatedAt time.Time `json:"CreatedAt" validate:"required"`
+	Nonce     string    `json:"Nonce" validate:"required"`
+}
+
+func (p WebhookPayload) GetTimestamp() time.Time {
+	return p.CreatedAt
+}
+
+func (p WebhookPayload) GetNonce() string {
+	return p.Nonce
+}
+
+// JobCancelPayload is the payload of a request to cancel a job, or all the
+// jobs of a job group.
+type JobCancelPayload struct {
+	// the id of the client that submitted the jobs
+	ClientID string `json:"ClientID,omitempty" validate:"required"`
+
+	// The job to cancel. One of JobID or JobGroup must be set.
+	JobID string `json:"JobID,omitempty" validate:"optional"`
+
+	// The job group to cancel the jobs of.
+	JobGroup string `json:"JobGroup,omitempty" validate:"optional"`
+
+	// Why the jobs are cancelled, reported in their errors.
+	Reason string `json:"Reason,omitempty" validate:"optional"`
+}
+
+// JobDebugPayload is the payload of a request to attach a shell to the
+// environment a node has kept of a failed shard.
+type JobDebugPayload struct {
+	// the id of the client that submitted the job
+	ClientID string `json:"ClientID,omitempty" validate:"required"`
+
+	// The job and the index of the shard to debug.
+	JobID      string `json:"JobID,omitempty" validate:"required"`
+	ShardIndex int    `json:"ShardIndex,omitempty" validate:"optional"`
+
+	// When the request was made, as signed requests are only accepted for a
+	// short while so that they can't be replayed to open shells later, and a
+	// random value unique to it, so that it can't be replayed at all.
+	CreatedAt time.Time `json:"CreatedAt" validate:"required"`
+	Nonce     string    `json:"Nonce" validate:"required"`
+}
+
+func (p JobDebugPayload) GetTimestamp() time.Time {
+	return p.CreatedAt
+}
+
+func (p JobDebugPayload) GetNonce() string {
+	return p.Nonce
+}
+
+// JobChallengePayload is the payload of a request to challenge the results
+// a node published for a shard of a job verified optimistically.
+type JobChallengePayload struct {
+	// the id of the client that is challenging the results
+	ClientID string `json:"ClientID,omitempty" validate:"required"`
+
+	// The job, shard and node of the results to challenge.
+	JobID      string `json:"JobID,omitempty" validate:"required"`
+	ShardIndex int    `json:"ShardIndex,omitempty" validate:"optional"`
+	NodeID     string `json:"NodeID,omitempty" validate:"required"`
+
+	// Why the results are challenged.
+	Reason string `json:"Reason,omitempty" validate:"optional"`
+}
+
+// JobApprovalPayload is the payload of a request to approve or reject a job
+// that a requester node holds until it is approved.
+type JobApprovalPayload struct {
+	// the id of the client that is deciding on the job, which must be one of
+	// the approvers of the requester node
+	ClientID string `json:"ClientID,omitempty" validate:"required"`
+
+	// The job to approve or reject.
+	JobID string `json:"JobID,omitempty" validate:"required"`
+
+	// Whether the job is approved, rather than rejected.
+	Approved bool `json:"Approved,omitempty" validate:"optional"`
+
+	// Why the job is rejected, which later requests to approve it report.
+	Reason string `json:"Reason,omitempty" validate:"optional"`
 }
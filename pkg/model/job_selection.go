@@ -21,6 +21,21 @@ type JobSelectionPolicy struct {
 	// if either of these are given they will override the data locality settings
 	ProbeHTTP string `json:"probe_http,omitempty"`
 	ProbeExec string `json:"probe_exec,omitempty"`
+	// the IDs or public keys of the only clients whose jobs we accept,
+	// any client if empty
+	AllowedClients []string `json:"allowed_clients,omitempty"`
+	// the IDs or public keys of clients whose jobs we reject
+	DeniedClients []string `json:"denied_clients,omitempty"`
+	// should we reject jobs that don't say which client submitted them
+	RejectAnonymousJobs bool `json:"reject_anonymous_jobs,omitempty"`
+	// the URL of a denylist of clients and jobs flagged as abusive, such as
+	// the /denylist of a requester node, whose jobs we reject
+	DenylistURL string `json:"denylist_url,omitempty"`
+	// the windows of time we run jobs in, any time if empty, and the
+	// windows we don't, each a cron expression of when it starts and how
+	// long it lasts, e.g. "0 18 * * mon-fri 14h"
+	AvailabilityWindows []string `json:"availability_windows,omitempty"`
+	BlackoutWindows     []string `json:"blackout_windows,omitempty"`
 }
 
 // generate a default empty job selection policy
@@ -146,9 +146,13 @@ func GetStateFromEvent(eventType JobEventType) JobStateType {
 	case JobEventResultsRejected:
 		return JobStateError
 
-	case JobEventResultsPublished:
+	case JobEventResultsPublished, JobEventPublishCompleted:
 		return JobStateCompleted
 
+	// the results of the shard never made it to the publisher
+	case JobEventPublishFailed:
+		return JobStateError
+
 	default:
 		return jobStateUnknown
 	}
@@ -66,6 +66,24 @@ const (
 	// not hear back it will be stuck in reserving the resources for the job
 	JobEventInvalidRequest
 
+	// a compute node declined to bid on a job, with the reason why
+	JobEventBidDeclined
+
+	// for jobs that publish asynchronously, a compute node failed an
+	// attempt to publish the results of a shard it completed and will
+	// retry, or it published them, or it gave up on publishing them
+	JobEventPublishProgress
+	JobEventPublishCompleted
+	JobEventPublishFailed
+
+	// for jobs verified optimistically, a client challenged the results a
+	// node published for a shard, and the requester node is re-executing
+	// the shard on another node, which either disproved the results or
+	// agreed with them
+	JobEventChallenged
+	JobEventChallengeUpheld
+	JobEventChallengeRejected
+
 	jobEventDone // must be last
 )
 
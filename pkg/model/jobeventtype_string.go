@@ -24,12 +24,19 @@ func _() {
 	_ = x[JobEventResultsPublished-13]
 	_ = x[JobEventError-14]
 	_ = x[JobEventInvalidRequest-15]
-	_ = x[jobEventDone-16]
+	_ = x[JobEventBidDeclined-16]
+	_ = x[JobEventPublishProgress-17]
+	_ = x[JobEventPublishCompleted-18]
+	_ = x[JobEventPublishFailed-19]
+	_ = x[JobEventChallenged-20]
+	_ = x[JobEventChallengeUpheld-21]
+	_ = x[JobEventChallengeRejected-22]
+	_ = x[jobEventDone-23]
 }
 
-const _JobEventType_name = "jobEventUnknownInitialSubmissionCreatedDealUpdatedBidBidAcceptedBidRejectedBidCancelledRunningComputeErrorResultsProposedResultsAcceptedResultsRejectedResultsPublishedErrorInvalidRequestjobEventDone"
+const _JobEventType_name = "jobEventUnknownInitialSubmissionCreatedDealUpdatedBidBidAcceptedBidRejectedBidCancelledRunningComputeErrorResultsProposedResultsAcceptedResultsRejectedResultsPublishedErrorInvalidRequestBidDeclinedPublishProgressPublishCompletedPublishFailedChallengedChallengeUpheldChallengeRejectedjobEventDone"
 
-var _JobEventType_index = [...]uint8{0, 15, 32, 39, 50, 53, 64, 75, 87, 94, 106, 121, 136, 151, 167, 172, 186, 198}
+var _JobEventType_index = [...]uint16{0, 15, 32, 39, 50, 53, 64, 75, 87, 94, 106, 121, 136, 151, 167, 172, 186, 197, 212, 228, 241, 251, 266, 283, 295}
 
 func (i JobEventType) String() string {
 	if i < 0 || i >= JobEventType(len(_JobEventType_index)-1) {
@@ -29,6 +29,12 @@ const (
 	// flag a job as having already had it's verification done
 	JobLocalEventVerified
 
+	// requester node
+	// the idempotency key a job was submitted with, so
+	// that retries of the submission still get the job
+	// back after the node restarts
+	JobLocalEventIdempotencyKey
+
 	jobLocalEventDone // must be last
 )
 
@@ -14,12 +14,13 @@ func _() {
 	_ = x[JobLocalEventBidAccepted-3]
 	_ = x[JobLocalEventBidRejected-4]
 	_ = x[JobLocalEventVerified-5]
-	_ = x[jobLocalEventDone-6]
+	_ = x[JobLocalEventIdempotencyKey-6]
+	_ = x[jobLocalEventDone-7]
 }
 
-const _JobLocalEventType_name = "jobLocalEventUnknownSelectedBidBidAcceptedBidRejectedVerifiedjobLocalEventDone"
+const _JobLocalEventType_name = "jobLocalEventUnknownSelectedBidBidAcceptedBidRejectedVerifiedIdempotencyKeyjobLocalEventDone"
 
-var _JobLocalEventType_index = [...]uint8{0, 20, 28, 31, 42, 53, 61, 78}
+var _JobLocalEventType_index = [...]uint8{0, 20, 28, 31, 42, 53, 61, 75, 92}
 
 func (i JobLocalEventType) String() string {
 	if i < 0 || i >= JobLocalEventType(len(_JobLocalEventType_index)-1) {
@@ -0,0 +1,55 @@
+package model
+
+import "time"
+
+// ModerationPayload is the payload of a request to flag a client or a job as
+// abusive, which adds it to the denylist of the requester node, or to unflag
+// it.
+type ModerationPayload struct {
+	// the id of the client that is flagging, which must be one of the
+	// moderators of the requester node
+	ClientID string `json:"ClientID,omitempty" validate:"required"`
+
+	// The client to flag, if it is a client that is flagged.
+	TargetClientID string `json:"TargetClientID,omitempty" validate:"optional"`
+
+	// The job to flag, if it is a job that is flagged.
+	TargetJobID string `json:"TargetJobID,omitempty" validate:"optional"`
+
+	// Whether to remove the client or job from the denylist, rather than add it.
+	Unflag bool `json:"Unflag,omitempty" validate:"optional"`
+
+	// Why the client or job is flagged, which the denylist reports.
+	Reason string `json:"Reason,omitempty" validate:"optional"`
+
+	// When the payload was signed, and a random value unique to it, so that
+	// the requester node can refuse the payload when it is sent again.
+	Timestamp time.Time `json:"Timestamp" validate:"required"`
+	Nonce     string    `json:"Nonce" validate:"required"`
+}
+
+func (p ModerationPayload) GetTimestamp() time.Time {
+	return p.Timestamp
+}
+
+func (p ModerationPayload) GetNonce() string {
+	return p.Nonce
+}
+
+// DenylistEntry is a client or job flagged as abusive.
+type DenylistEntry struct {
+	// The id of the client or job.
+	ID string `json:"ID"`
+	// Why it was flagged.
+	Reason string `json:"Reason,omitempty"`
+	// The id of the moderator that flagged it.
+	FlaggedBy string    `json:"FlaggedBy"`
+	FlaggedAt time.Time `json:"FlaggedAt"`
+}
+
+// Denylist is the clients and jobs the moderators of a requester node have
+// flagged as abusive, which compute nodes can refuse to bid on.
+type Denylist struct {
+	Clients []DenylistEntry `json:"Clients"`
+	Jobs    []DenylistEntry `json:"Jobs"`
+}
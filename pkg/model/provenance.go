@@ -0,0 +1,34 @@
+package model
+
+// ImageProvenance records what exactly a shard ran, so that the owners of
+// the data it read can tell which image it was down to the layers, and find
+// the SBOMs and attestations published with the image.
+type ImageProvenance struct {
+	// The image as the job spec gave it.
+	Image string `json:"image"`
+	// The ID of the image on the compute node, the digest of its config.
+	ImageID string `json:"imageID"`
+	// The digests the image is known by in registries, as repo@sha256:...
+	RepoDigests []string `json:"repoDigests,omitempty"`
+	// The digest of the manifest of the image for the platform it ran on,
+	// if the registry publishes one per platform.
+	ManifestDigest string `json:"manifestDigest,omitempty"`
+	// The platform the image ran on, as os/architecture.
+	Platform string `json:"platform,omitempty"`
+	// The digests of the layers of the image, bottom first.
+	Layers []string `json:"layers,omitempty"`
+	// The SBOMs and other attestations attached to the image in its registry.
+	Attestations []ImageAttestation `json:"attestations,omitempty"`
+}
+
+// ImageAttestation is an in-toto attestation attached to an image, such as
+// an SBOM or a SLSA provenance, which can be fetched from the registry of
+// the image by its digest.
+type ImageAttestation struct {
+	// The type of the predicate of the attestation, e.g.
+	// https://spdx.dev/Document for an SPDX SBOM.
+	PredicateType string `json:"predicateType"`
+	Digest        string `json:"digest"`
+	MediaType     string `json:"mediaType,omitempty"`
+	Size          int64  `json:"size,omitempty"`
+}
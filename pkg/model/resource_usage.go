@@ -9,15 +9,14 @@ import (
 // a record for the "amount" of compute resources an entity has / can consume / is using
 
 type ResourceUsageConfig struct {
-	// https://github.com/BTBurke/k8sresource string
+	// cores, e.g. 2, 0.5 or 500m, stored in whole cores or millicores once the job is submitted
 	CPU string `json:"CPU,omitempty"`
-	// github.com/c2h5oh/datasize string
+	// bytes, e.g. 512Mi or 2Gi, stored in bytes once the job is submitted
 	Memory string `json:"Memory,omitempty"`
-	// github.com/c2h5oh/datasize string
-
+	// bytes, like Memory
 	Disk string `json:"Disk,omitempty"`
-	GPU  string `json:"GPU"` // unsigned integer string
-
+	// GPUs, like CPU, e.g. 1, 2 or 500m
+	GPU string `json:"GPU"`
 }
 
 // these are the numeric values in bytes for ResourceUsageConfig
@@ -28,7 +27,9 @@ type ResourceUsageData struct {
 	Memory uint64 `json:"Memory,omitempty" example:"27487790694"`
 	// bytes
 	Disk uint64 `json:"Disk,omitempty" example:"212663867801"`
-	GPU  uint64 `json:"GPU,omitempty" example:"1"` //nolint:lll // Support whole GPUs only, like https://kubernetes.io/docs/tasks/manage-gpus/scheduling-gpus/
+	// gpu units - a fraction of a GPU is either a MIG slice of it or a share
+	// of its time
+	GPU float64 `json:"GPU,omitempty" example:"1"`
 }
 
 func (r ResourceUsageData) Add(other ResourceUsageData) ResourceUsageData {
@@ -73,7 +74,7 @@ func (r ResourceUsageData) Multi(factor float64) ResourceUsageData {
 		CPU:    r.CPU * factor,
 		Memory: uint64(float64(r.Memory) * factor),
 		Disk:   uint64(float64(r.Disk) * factor),
-		GPU:    uint64(float64(r.GPU) * factor),
+		GPU:    r.GPU * factor,
 	}
 }
 
@@ -121,7 +122,7 @@ func (r ResourceUsageData) IsZero() bool {
 
 // return string representation of ResourceUsageData
 func (r ResourceUsageData) String() string {
-	return fmt.Sprintf("{CPU: %f, Memory: %d, Disk: %d, GPU: %d}", r.CPU, r.Memory, r.Disk, r.GPU)
+	return fmt.Sprintf("{CPU: %f, Memory: %d, Disk: %d, GPU: %f}", r.CPU, r.Memory, r.Disk, r.GPU)
 }
 
 type ResourceUsageProfile struct {
@@ -19,6 +19,11 @@ const (
 	StorageSourceFilecoinUnsealed
 	StorageSourceFilecoin
 	StorageSourceEstuary
+	StorageSourceAzureBlob
+	StorageSourceGCS
+	StorageSourceHuggingFace
+	StorageSourceLocalDirectory
+	StorageSourceInline
 	storageSourceDone // must be last
 )
 
@@ -1,5 +1,11 @@
 package model
 
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
 // StorageSpec represents some data on a storage engine. Storage engines are
 // specific to particular execution engines, as different execution engines
 // will mount data in different ways.
@@ -28,6 +34,74 @@ type StorageSpec struct {
 
 	// Additional properties specific to each driver
 	Metadata map[string]string `json:"Metadata,omitempty"`
+
+	// Transforms applied in order to the data of an input once it has been
+	// fetched and before it is mounted, e.g. gunzip then untar.
+	Transforms []InputTransform `json:"Transforms,omitempty"`
+
+	// The files of an output volume to publish, and the names to publish them
+	// under. All of the files of the output are published if there are none.
+	Artifacts []OutputArtifact `json:"Artifacts,omitempty"`
+}
+
+// OutputArtifact selects files of an output volume to publish, so that jobs
+// that leave large intermediate files behind don't have them pinned too.
+type OutputArtifact struct {
+	// Glob matched against the paths of files relative to the output volume,
+	// e.g. *.csv or models/**/*.bin. ** matches any number of directories.
+	Glob string `json:"Glob"`
+	// The path to publish the matched files under, relative to the output
+	// volume. If the glob is a plain path it is the new path of the file,
+	// otherwise it is a directory the matched files are put in. The files
+	// keep their paths if it isn't set.
+	Name string `json:"Name,omitempty"`
+}
+
+// InputTransform is a step that turns the data fetched for an input into the
+// data mounted into the job, so that jobs don't have to bundle the tools to
+// do it themselves. Transforms apply to each file of the input.
+type InputTransform string
+
+const (
+	// Decompresses gzip files, dropping any .gz suffix from their names.
+	InputTransformGunzip InputTransform = "gunzip"
+	// Decompresses zstd files, dropping any .zst suffix from their names.
+	InputTransformUnzstd InputTransform = "unzstd"
+	// Extracts tar files into a directory named after the file, without any
+	// .tar suffix.
+	InputTransformUntar InputTransform = "untar"
+	// Decrypts files sealed with a data key that is wrapped with the public
+	// key of the compute node running the job, dropping any .enc suffix from
+	// their names.
+	InputTransformDecrypt InputTransform = "decrypt"
+)
+
+func ParseInputTransform(str string) (InputTransform, error) {
+	for _, transform := range []InputTransform{
+		InputTransformGunzip,
+		InputTransformUnzstd,
+		InputTransformUntar,
+		InputTransformDecrypt,
+	} {
+		if strings.EqualFold(str, string(transform)) {
+			return transform, nil
+		}
+	}
+	return "", fmt.Errorf("unknown input transform %q", str)
+}
+
+// ScratchVolume is writable space a job can use while it runs, which is
+// thrown away once the job finishes. Unlike input volumes, which are always
+// mounted read-only so that jobs can't change data cached for other jobs,
+// and output volumes, which are published, scratch volumes are private to
+// each job.
+type ScratchVolume struct {
+	// The path to mount the volume on inside the job.
+	Path string `json:"Path"`
+	// The most data the job can write to the volume, e.g. 500Mb.
+	Size string `json:"Size"`
+	// Whether to keep the volume in memory rather than on disk.
+	Tmpfs bool `json:"Tmpfs,omitempty"`
 }
 
 // PublishedStorageSpec is a wrapper for a StorageSpec that has been published
@@ -38,3 +112,42 @@ type PublishedResult struct {
 	ShardIndex int         `json:"ShardIndex,omitempty"`
 	Data       StorageSpec `json:"Data,omitempty"`
 }
+
+// IsPlainPath returns whether the glob of the artifact matches a single path.
+func (a OutputArtifact) IsPlainPath() bool {
+	return !strings.ContainsAny(a.Glob, `*?[\`)
+}
+
+// Matches returns whether the artifact's glob matches the slash-separated
+// path of a file, relative to the output volume. It only returns an error if
+// the glob is malformed.
+func (a OutputArtifact) Matches(filePath string) (bool, error) {
+	return matchGlobSegments(
+		strings.Split(strings.Trim(a.Glob, "/"), "/"),
+		strings.Split(strings.Trim(filePath, "/"), "/"),
+	)
+}
+
+func matchGlobSegments(patterns, segments []string) (bool, error) {
+	for len(patterns) > 0 {
+		if patterns[0] == "**" {
+			// try matching the rest of the glob against every suffix
+			for i := 0; i <= len(segments); i++ {
+				matched, err := matchGlobSegments(patterns[1:], segments[i:])
+				if matched || err != nil {
+					return matched, err
+				}
+			}
+			return false, nil
+		}
+		if len(segments) == 0 {
+			return false, nil
+		}
+		matched, err := path.Match(patterns[0], segments[0])
+		if !matched || err != nil {
+			return false, err
+		}
+		patterns, segments = patterns[1:], segments[1:]
+	}
+	return len(segments) == 0, nil
+}
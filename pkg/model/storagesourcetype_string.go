@@ -14,12 +14,17 @@ func _() {
 	_ = x[StorageSourceFilecoinUnsealed-3]
 	_ = x[StorageSourceFilecoin-4]
 	_ = x[StorageSourceEstuary-5]
-	_ = x[storageSourceDone-6]
+	_ = x[StorageSourceAzureBlob-6]
+	_ = x[StorageSourceGCS-7]
+	_ = x[StorageSourceHuggingFace-8]
+	_ = x[StorageSourceLocalDirectory-9]
+	_ = x[StorageSourceInline-10]
+	_ = x[storageSourceDone-11]
 }
 
-const _StorageSourceType_name = "storageSourceUnknownIPFSURLDownloadFilecoinUnsealedFilecoinEstuarystorageSourceDone"
+const _StorageSourceType_name = "storageSourceUnknownIPFSURLDownloadFilecoinUnsealedFilecoinEstuaryAzureBlobGCSHuggingFaceLocalDirectoryInlinestorageSourceDone"
 
-var _StorageSourceType_index = [...]uint8{0, 20, 24, 35, 51, 59, 66, 83}
+var _StorageSourceType_index = [...]uint8{0, 20, 24, 35, 51, 59, 66, 75, 78, 89, 103, 109, 126}
 
 func (i StorageSourceType) String() string {
 	if i < 0 || i >= StorageSourceType(len(_StorageSourceType_index)-1) {
@@ -11,6 +11,8 @@ const (
 	verifierUnknown Verifier = iota // must be first
 	VerifierNoop
 	VerifierDeterministic
+	VerifierWebhook
+	VerifierOptimistic
 	verifierDone // must be last
 )
 
@@ -11,12 +11,14 @@ func _() {
 	_ = x[verifierUnknown-0]
 	_ = x[VerifierNoop-1]
 	_ = x[VerifierDeterministic-2]
-	_ = x[verifierDone-3]
+	_ = x[VerifierWebhook-3]
+	_ = x[VerifierOptimistic-4]
+	_ = x[verifierDone-5]
 }
 
-const _Verifier_name = "verifierUnknownNoopDeterministicverifierDone"
+const _Verifier_name = "verifierUnknownNoopDeterministicWebhookOptimisticverifierDone"
 
-var _Verifier_index = [...]uint8{0, 15, 19, 32, 44}
+var _Verifier_index = [...]uint8{0, 15, 19, 32, 39, 49, 61}
 
 func (i Verifier) String() string {
 	if i < 0 || i >= Verifier(len(_Verifier_index)-1) {
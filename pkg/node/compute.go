@@ -2,6 +2,8 @@ package node
 
 import (
 	"context"
+	"fmt"
+	"os"
 	"time"
 
 	"github.com/filecoin-project/bacalhau/pkg/compute/backend"
@@ -10,15 +12,18 @@ import (
 	"github.com/filecoin-project/bacalhau/pkg/compute/capacity/disk"
 	"github.com/filecoin-project/bacalhau/pkg/compute/frontend"
 	"github.com/filecoin-project/bacalhau/pkg/compute/pubsub"
+	"github.com/filecoin-project/bacalhau/pkg/compute/reconciler"
 	"github.com/filecoin-project/bacalhau/pkg/compute/sensors"
 	"github.com/filecoin-project/bacalhau/pkg/compute/store"
 	"github.com/filecoin-project/bacalhau/pkg/compute/store/inmemory"
+	pkgconfig "github.com/filecoin-project/bacalhau/pkg/config"
 	"github.com/filecoin-project/bacalhau/pkg/eventhandler"
 	"github.com/filecoin-project/bacalhau/pkg/executor"
 	"github.com/filecoin-project/bacalhau/pkg/localdb"
 	"github.com/filecoin-project/bacalhau/pkg/model"
 	"github.com/filecoin-project/bacalhau/pkg/publisher"
 	"github.com/filecoin-project/bacalhau/pkg/verifier"
+	"github.com/rs/zerolog/log"
 )
 
 type Compute struct {
@@ -26,10 +31,16 @@ type Compute struct {
 	Frontend           frontend.Service
 	nodeID             string
 	ExecutionStore     store.ExecutionStore
-	frontendProxy      pubsub.FrontendEventProxy
+	eventQueue         *pubsub.EventQueue
 	debugInfoProviders []model.DebugInfoProvider
+	drainingStrategy   *bidstrategy.DrainingStrategy
+	backendBuffer      *backend.ServiceBuffer
+	reconciler         *reconciler.Reconciler
 }
 
+// how often Drain checks whether the executions of the node have finished
+const drainPollInterval = time.Second
+
 //nolint:funlen
 func NewComputeNode(
 	ctx context.Context,
@@ -71,6 +82,11 @@ func NewComputeNode(
 		Executors:  executors,
 		Verifiers:  verifiers,
 		Publishers: publishers,
+		Secrets:    config.SecretsProvider,
+
+		SecretsPolicy:        config.SecretsPolicy,
+		MaxInlineResultsSize: config.MaxInlineResultsSize,
+		OutputPolicy:         config.OutputPolicy,
 	})
 
 	bufferRunner := backend.NewServiceBuffer(backend.ServiceBufferParams{
@@ -79,6 +95,8 @@ func NewComputeNode(
 		RunningCapacityTracker:     capacityTracker,
 		DefaultJobExecutionTimeout: config.DefaultJobExecutionTimeout,
 		BackoffDuration:            50 * time.Millisecond,
+		SpotResourceLimits:         config.SpotResourceLimits,
+		MaxConcurrentJobs:          config.MaxConcurrentJobs,
 	})
 	runningInfoProvider := sensors.NewRunningExecutionsInfoProvider(sensors.RunningExecutionsInfoProviderParams{
 		BackendBuffer: bufferRunner,
@@ -92,6 +110,30 @@ func NewComputeNode(
 		go loggingSensor.Start(ctx)
 	}
 
+	var garbageCollector *reconciler.Reconciler
+	if config.GarbageCollectionInterval > 0 {
+		garbageCollector = reconciler.NewReconciler(reconciler.ReconcilerParams{
+			Executors:     executors,
+			Executions:    bufferRunner,
+			TempDirs:      []string{os.TempDir(), pkgconfig.GetStoragePath()},
+			MinTempDirAge: config.GarbageCollectionMinAge,
+			Interval:      config.GarbageCollectionInterval,
+		})
+		go garbageCollector.Start(ctx)
+	}
+
+	energySensor := sensors.NewEnergySensor(sensors.EnergySensorParams{
+		Renewable:       config.Renewable,
+		CarbonIntensity: config.CarbonIntensity,
+		URL:             config.CarbonIntensityURL,
+	})
+	debugInfoProviders = append(debugInfoProviders, energySensor)
+	go energySensor.Start(ctx)
+	hardeningInfoProvider := sensors.NewHardeningDebugInfoProvider(sensors.HardeningDebugInfoProviderParams{
+		Hardening: config.Hardening,
+	})
+	debugInfoProviders = append(debugInfoProviders, hardeningInfoProvider)
+
 	// frontend
 	capacityCalculator := capacity.NewChainedUsageCalculator(capacity.ChainedUsageCalculatorParams{
 		Calculators: []capacity.UsageCalculator{
@@ -104,14 +146,41 @@ func NewComputeNode(
 		},
 	})
 
+	drainingStrategy := bidstrategy.NewDrainingStrategy()
+	maxConcurrentJobsStrategy := bidstrategy.NewMaxConcurrentJobsStrategy(bidstrategy.MaxConcurrentJobsStrategyParams{
+		MaxConcurrentJobs: config.MaxConcurrentJobs,
+		BackendBuffer:     bufferRunner,
+		ExecutionStore:    executionStore,
+	})
 	biddingStrategy := bidstrategy.NewChainedBidStrategy(
+		drainingStrategy,
+		// before the probes, so that they only see the jobs of clients we accept
+		bidstrategy.NewClientStrategy(bidstrategy.ClientStrategyParams{
+			AllowedClients:      config.JobSelectionPolicy.AllowedClients,
+			DeniedClients:       config.JobSelectionPolicy.DeniedClients,
+			RejectAnonymousJobs: config.JobSelectionPolicy.RejectAnonymousJobs,
+		}),
+		bidstrategy.NewDenylistStrategy(bidstrategy.DenylistStrategyParams{
+			URL: config.JobSelectionPolicy.DenylistURL,
+		}),
+		bidstrategy.NewScheduleStrategy(bidstrategy.ScheduleStrategyParams{
+			AvailabilityWindows:        config.AvailabilityWindows,
+			BlackoutWindows:            config.BlackoutWindows,
+			DefaultJobExecutionTimeout: config.DefaultJobExecutionTimeout,
+		}),
+		bidstrategy.NewSecretsStrategy(bidstrategy.SecretsStrategyParams{
+			Provider: config.SecretsProvider,
+			Policy:   config.SecretsPolicy,
+		}),
 		bidstrategy.NewMaxCapacityStrategy(bidstrategy.MaxCapacityStrategyParams{
 			MaxJobRequirements: config.JobResourceLimits,
 		}),
 		bidstrategy.NewAvailableCapacityStrategy(bidstrategy.AvailableCapacityStrategyParams{
 			CapacityTracker: capacityTracker,
 			CommitFactor:    config.OverCommitResourcesFactor,
+			SpotTracker:     bufferRunner,
 		}),
+		maxConcurrentJobsStrategy,
 		// TODO XXX: don't hardcode networkSize, calculate this dynamically from
 		//  libp2p instead somehow. https://github.com/filecoin-project/bacalhau/issues/512
 		bidstrategy.NewDistanceDelayStrategy(bidstrategy.DistanceDelayStrategyParams{
@@ -131,6 +200,9 @@ func NewComputeNode(
 			Locality:  config.JobSelectionPolicy.Locality,
 			Executors: executors,
 		}),
+		bidstrategy.NewInputSizeStrategy(bidstrategy.InputSizeStrategyParams{
+			Executors: executors,
+		}),
 		bidstrategy.NewStatelessJobStrategy(bidstrategy.StatelessJobStrategyParams{
 			RejectStatelessJobs: config.JobSelectionPolicy.RejectStatelessJobs,
 		}),
@@ -141,11 +213,13 @@ func NewComputeNode(
 	)
 
 	frontendNode := frontend.NewBaseService(frontend.BaseServiceParams{
-		ID:              nodeID,
-		ExecutionStore:  executionStore,
-		UsageCalculator: capacityCalculator,
-		BidStrategy:     biddingStrategy,
-		Backend:         bufferRunner,
+		ID:               nodeID,
+		ExecutionStore:   executionStore,
+		UsageCalculator:  capacityCalculator,
+		ResourceProfiles: config.DefaultJobResourceProfiles,
+		BidStrategy:      biddingStrategy,
+		ShardBidStrategy: maxConcurrentJobsStrategy,
+		Backend:          bufferRunner,
 	})
 
 	frontendProxy := *pubsub.NewFrontendEventProxy(pubsub.FrontendEventProxyParams{
@@ -154,13 +228,58 @@ func NewComputeNode(
 		JobStore:          jobStore,
 		ExecutionStore:    executionStore,
 		JobEventPublisher: jobEventPublisher,
+		ReportBidDeclines: config.ReportBidDeclines,
+		Zone:              config.Zone,
+		NodeEnergy:        energySensor.Energy,
+		NodeHardening:     config.Hardening,
+	})
+
+	// handle the events from the transport in the background, by priority, so
+	// that a storm of job announcements doesn't hold up executions
+	eventQueue := pubsub.NewEventQueue(ctx, pubsub.EventQueueParams{
+		NodeID:           nodeID,
+		Handler:          frontendProxy,
+		Size:             config.EventQueueSize,
+		Workers:          config.EventWorkers,
+		DropPolicy:       config.EventDropPolicy,
+		AnnouncementRate: config.AnnouncementRate,
 	})
 
 	return &Compute{
 		nodeID:             nodeID,
 		Frontend:           frontendNode,
 		ExecutionStore:     executionStore,
-		frontendProxy:      frontendProxy,
+		eventQueue:         eventQueue,
 		debugInfoProviders: debugInfoProviders,
+		drainingStrategy:   drainingStrategy,
+		backendBuffer:      bufferRunner,
+		reconciler:         garbageCollector,
 	}
 }
+
+// Drain stops the node bidding on new jobs and waits for the executions it has
+// running or queued to finish, or for the context to be done. Executions that
+// are waiting for their results to be verified are not waited for.
+func (c *Compute) Drain(ctx context.Context) error {
+	c.drainingStrategy.Drain()
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for {
+		count := c.backendBuffer.ExecutionCount()
+		if count == 0 {
+			return nil
+		}
+		log.Ctx(ctx).Info().Msgf("Draining: waiting for %d executions to finish", count)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%d executions did not finish while draining: %w", count, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// IsDraining returns whether the node has stopped bidding on new jobs before
+// shutting down.
+func (c *Compute) IsDraining() bool {
+	return c.drainingStrategy.IsDraining()
+}
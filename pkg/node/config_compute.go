@@ -6,6 +6,10 @@ import (
 	"time"
 
 	"github.com/filecoin-project/bacalhau/pkg/compute/capacity"
+	"github.com/filecoin-project/bacalhau/pkg/compute/pubsub"
+	"github.com/filecoin-project/bacalhau/pkg/compute/schedule"
+	"github.com/filecoin-project/bacalhau/pkg/compute/secrets"
+	"github.com/filecoin-project/bacalhau/pkg/executor"
 	"github.com/filecoin-project/bacalhau/pkg/model"
 )
 
@@ -14,6 +18,9 @@ type ComputeConfigParams struct {
 	TotalResourceLimits          model.ResourceUsageData
 	JobResourceLimits            model.ResourceUsageData
 	DefaultJobResourceLimits     model.ResourceUsageData
+	DefaultJobResourceProfiles   capacity.ResourceProfiles
+	SpotResourceLimits           model.ResourceUsageData
+	MaxConcurrentJobs            int
 	PhysicalResourcesProvider    capacity.Provider
 	OverCommitResourcesFactor    float64
 	IgnorePhysicalResourceLimits bool
@@ -26,18 +33,59 @@ type ComputeConfigParams struct {
 
 	// Bid strategies config
 	JobSelectionPolicy model.JobSelectionPolicy
+	ReportBidDeclines  bool
+
+	// Placement config
+	Zone string
+
+	// Energy config
+	Renewable          bool
+	CarbonIntensity    *float64
+	CarbonIntensityURL string
+
+	// Hardening config
+	Hardening *model.NodeHardening
+
+	// Secrets config
+	Secrets secrets.Params
+	// AllowedSecrets the secrets jobs may request, as NAME or NAME=CLIENT_ID
+	AllowedSecrets []string
+	// CredentialSecrets the secrets of the credentials of the node, which jobs may never request
+	CredentialSecrets []string
 
 	// logging running executions
 	LogRunningExecutionsInterval time.Duration
+
+	// Garbage collection config
+	GarbageCollectionInterval time.Duration
+	GarbageCollectionMinAge   time.Duration
+
+	// Results config
+	MaxInlineResultsSize uint64
+	OutputPolicy         executor.OutputPolicy
+
+	// Event processing config
+	EventQueueSize   int
+	EventWorkers     int
+	EventDropPolicy  pubsub.EventDropPolicy
+	AnnouncementRate float64
 }
 
 type ComputeConfig struct {
 	// Capacity config
-	TotalResourceLimits          model.ResourceUsageData
-	JobResourceLimits            model.ResourceUsageData
-	DefaultJobResourceLimits     model.ResourceUsageData
+	TotalResourceLimits      model.ResourceUsageData
+	JobResourceLimits        model.ResourceUsageData
+	DefaultJobResourceLimits model.ResourceUsageData
+	// DefaultJobResourceProfiles the resources jobs run with, by engine and image, for the resources they don't ask
+	// for. Unlike DefaultJobResourceLimits, which only account for jobs, the executors enforce them.
+	DefaultJobResourceProfiles   capacity.ResourceProfiles
 	OverCommitResourcesFactor    float64
 	IgnorePhysicalResourceLimits bool
+	// SpotResourceLimits the total resources spot jobs can use at once, keeping the rest as headroom for standard jobs.
+	SpotResourceLimits model.ResourceUsageData
+	// MaxConcurrentJobs the maximum number of jobs to run at once, enforced alongside the resource limits. No limit if
+	// zero.
+	MaxConcurrentJobs int
 
 	// JobNegotiationTimeout default timeout value to hold a bid for a job
 	JobNegotiationTimeout time.Duration
@@ -53,9 +101,61 @@ type ComputeConfig struct {
 
 	// Bid strategies config
 	JobSelectionPolicy model.JobSelectionPolicy
+	// AvailabilityWindows and BlackoutWindows are the parsed windows of the job selection policy.
+	AvailabilityWindows []schedule.Window
+	BlackoutWindows     []schedule.Window
+	// ReportBidDeclines whether to tell requesters why this node declined to bid on their jobs, so their owners can
+	// see why the network won't run them.
+	ReportBidDeclines bool
+
+	// Zone the zone the node says it is in when it bids on jobs, e.g. a region or datacenter, so that requesters can
+	// spread the nodes running each shard of a job across zones.
+	Zone string
+
+	// Renewable whether the node runs on renewable power, which it says when it bids so that jobs can favor greener
+	// nodes.
+	Renewable bool
+	// CarbonIntensity the carbon intensity of the power of the node in gCO2eq/kWh, if known.
+	CarbonIntensity *float64
+	// CarbonIntensityURL an API to fetch the current carbon intensity of the power of the node from instead.
+	CarbonIntensityURL string
+
+	// Hardening what the node applies to the containers of its jobs, which it says when it bids so that jobs can
+	// require hardened nodes.
+	Hardening *model.NodeHardening
+
+	// SecretsProvider where the node fetches the secrets of jobs from when it runs them. Jobs with secrets are not bid
+	// on if nil.
+	SecretsProvider secrets.Provider
+	// SecretsPolicy which of the secrets of the provider jobs may request, and from which clients.
+	SecretsPolicy secrets.Policy
 
 	// logging running executions
 	LogRunningExecutionsInterval time.Duration
+
+	// GarbageCollectionInterval how often to remove the containers and temporary directories left behind by executions
+	// that crashed, after doing so on startup. Not done if zero.
+	GarbageCollectionInterval time.Duration
+	// GarbageCollectionMinAge how long temporary directories are left untouched before they are removed, so that those
+	// of other nodes on the same host aren't.
+	GarbageCollectionMinAge time.Duration
+
+	// MaxInlineResultsSize the most the output files of a job can add up to for their contents to be included in the
+	// job state, so that they can be seen without fetching the results.
+	MaxInlineResultsSize uint64
+	// OutputPolicy what the results of jobs that read the local directories of the node are restricted to, so that
+	// only aggregates of private data are published.
+	OutputPolicy executor.OutputPolicy
+
+	// EventQueueSize the most events of each priority waiting to be handled, so that a storm of gossiped events
+	// doesn't hold up the executions of the node.
+	EventQueueSize int
+	// EventWorkers how many job announcements are handled at once.
+	EventWorkers int
+	// EventDropPolicy what happens to job announcements that arrive when EventQueueSize of them are waiting.
+	EventDropPolicy pubsub.EventDropPolicy
+	// AnnouncementRate the most job announcements handled per second. No limit if zero.
+	AnnouncementRate float64
 }
 
 func NewComputeConfigWithDefaults() ComputeConfig {
@@ -85,6 +185,18 @@ func NewComputeConfigWith(params ComputeConfigParams) (config ComputeConfig) {
 	if params.LogRunningExecutionsInterval == 0 {
 		params.LogRunningExecutionsInterval = DefaultComputeConfig.LogRunningExecutionsInterval
 	}
+	if params.MaxInlineResultsSize == 0 {
+		params.MaxInlineResultsSize = DefaultComputeConfig.MaxInlineResultsSize
+	}
+	if params.EventQueueSize == 0 {
+		params.EventQueueSize = DefaultComputeConfig.EventQueueSize
+	}
+	if params.EventWorkers == 0 {
+		params.EventWorkers = DefaultComputeConfig.EventWorkers
+	}
+	if params.EventDropPolicy == "" {
+		params.EventDropPolicy = DefaultComputeConfig.EventDropPolicy
+	}
 
 	// Get available physical resources in the host
 	physicalResourcesProvider := params.PhysicalResourcesProvider
@@ -109,14 +221,38 @@ func NewComputeConfigWith(params ComputeConfigParams) (config ComputeConfig) {
 	defaultJobResourceLimits := params.DefaultJobResourceLimits.
 		Intersect(DefaultComputeConfig.DefaultJobResourceLimits)
 
+	// populate spot resource limits with the total resource limits if not set
+	spotResourceLimits := params.SpotResourceLimits.
+		Intersect(totalResourceLimits)
+
 	if params.OverCommitResourcesFactor == 0 {
 		params.OverCommitResourcesFactor = DefaultComputeConfig.OverCommitResourcesFactor
 	}
 
+	availabilityWindows, err := schedule.ParseWindows(params.JobSelectionPolicy.AvailabilityWindows)
+	if err != nil {
+		return
+	}
+	blackoutWindows, err := schedule.ParseWindows(params.JobSelectionPolicy.BlackoutWindows)
+	if err != nil {
+		return
+	}
+	secretsProvider, err := secrets.NewProvider(context.Background(), params.Secrets)
+	if err != nil {
+		return
+	}
+	secretsPolicy, err := secrets.ParsePolicy(params.AllowedSecrets, params.CredentialSecrets)
+	if err != nil {
+		return
+	}
+
 	config = ComputeConfig{
 		TotalResourceLimits:          totalResourceLimits,
 		JobResourceLimits:            jobResourceLimits,
 		DefaultJobResourceLimits:     defaultJobResourceLimits,
+		DefaultJobResourceProfiles:   params.DefaultJobResourceProfiles,
+		SpotResourceLimits:           spotResourceLimits,
+		MaxConcurrentJobs:            params.MaxConcurrentJobs,
 		OverCommitResourcesFactor:    params.OverCommitResourcesFactor,
 		IgnorePhysicalResourceLimits: params.IgnorePhysicalResourceLimits,
 
@@ -125,9 +261,33 @@ func NewComputeConfigWith(params ComputeConfigParams) (config ComputeConfig) {
 		MaxJobExecutionTimeout:     params.MaxJobExecutionTimeout,
 		DefaultJobExecutionTimeout: params.DefaultJobExecutionTimeout,
 
-		JobSelectionPolicy: params.JobSelectionPolicy,
+		JobSelectionPolicy:  params.JobSelectionPolicy,
+		AvailabilityWindows: availabilityWindows,
+		BlackoutWindows:     blackoutWindows,
+		ReportBidDeclines:   params.ReportBidDeclines,
+		Zone:                params.Zone,
+
+		Renewable:          params.Renewable,
+		CarbonIntensity:    params.CarbonIntensity,
+		CarbonIntensityURL: params.CarbonIntensityURL,
+
+		Hardening: params.Hardening,
+
+		SecretsProvider: secretsProvider,
+		SecretsPolicy:   secretsPolicy,
 
 		LogRunningExecutionsInterval: params.LogRunningExecutionsInterval,
+
+		GarbageCollectionInterval: params.GarbageCollectionInterval,
+		GarbageCollectionMinAge:   params.GarbageCollectionMinAge,
+
+		MaxInlineResultsSize: params.MaxInlineResultsSize,
+		OutputPolicy:         params.OutputPolicy,
+
+		EventQueueSize:   params.EventQueueSize,
+		EventWorkers:     params.EventWorkers,
+		EventDropPolicy:  params.EventDropPolicy,
+		AnnouncementRate: params.AnnouncementRate,
 	}
 
 	validateConfig(config, physicalResources)
@@ -152,9 +312,37 @@ func validateConfig(config ComputeConfig, physicalResources model.ResourceUsageD
 		return
 	}
 
+	if config.MaxConcurrentJobs < 0 {
+		err = fmt.Errorf("max concurrent jobs %d must not be negative", config.MaxConcurrentJobs)
+		return
+	}
+
+	if config.EventQueueSize < 0 || config.EventWorkers < 0 || config.AnnouncementRate < 0 {
+		err = fmt.Errorf("event queue size %d, event workers %d and announcement rate %f must not be negative",
+			config.EventQueueSize, config.EventWorkers, config.AnnouncementRate)
+		return
+	}
+
+	if _, err = pubsub.ParseEventDropPolicy(string(config.EventDropPolicy)); err != nil {
+		return
+	}
+
+	if !config.SpotResourceLimits.LessThanEq(config.TotalResourceLimits) {
+		err = fmt.Errorf("spot resource limits %+v exceed total resource limits %+v", config.SpotResourceLimits, config.TotalResourceLimits)
+		return
+	}
+
 	if !config.DefaultJobResourceLimits.LessThanEq(config.JobResourceLimits) {
 		err = fmt.Errorf("default job resource limits %+v exceed job resource limits %+v",
 			config.DefaultJobResourceLimits, config.JobResourceLimits)
 		return
 	}
+
+	for _, profile := range config.DefaultJobResourceProfiles {
+		if !profile.Resources.LessThanEq(config.JobResourceLimits) {
+			err = fmt.Errorf("default job resources %+v of %s jobs exceed job resource limits %+v",
+				profile.Resources, profile.Engine, config.JobResourceLimits)
+			return
+		}
+	}
 }
@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/filecoin-project/bacalhau/pkg/compute/capacity/system"
+	"github.com/filecoin-project/bacalhau/pkg/compute/pubsub"
 	"github.com/filecoin-project/bacalhau/pkg/model"
 )
 
@@ -21,4 +22,10 @@ var DefaultComputeConfig = ComputeConfigParams{
 	DefaultJobExecutionTimeout: 10 * time.Minute,
 
 	LogRunningExecutionsInterval: 10 * time.Second,
+
+	MaxInlineResultsSize: 1024, // 1Kb
+
+	EventQueueSize:  1024,
+	EventWorkers:    4,
+	EventDropPolicy: pubsub.DropOldest,
 }
@@ -9,6 +9,7 @@ import (
 	"github.com/filecoin-project/bacalhau/pkg/executor"
 	executor_util "github.com/filecoin-project/bacalhau/pkg/executor/util"
 	"github.com/filecoin-project/bacalhau/pkg/publisher"
+	"github.com/filecoin-project/bacalhau/pkg/publisher/estuary"
 	publisher_util "github.com/filecoin-project/bacalhau/pkg/publisher/util"
 	"github.com/filecoin-project/bacalhau/pkg/storage"
 	"github.com/filecoin-project/bacalhau/pkg/verifier"
@@ -81,6 +82,12 @@ func (f *StandardStorageProvidersFactory) Get(
 		executor_util.StandardStorageProviderOptions{
 			IPFSMultiaddress:     nodeConfig.IPFSClient.APIAddress(),
 			FilecoinUnsealedPath: nodeConfig.FilecoinUnsealedPath,
+			Decrypter:            nodeConfig.Transport.Decrypt,
+			FilecoinRetrieval:    nodeConfig.FilecoinRetrievalConfig,
+			AzureBlob:            nodeConfig.AzureBlobConfig,
+			GCS:                  nodeConfig.GCSConfig,
+			HuggingFace:          nodeConfig.HuggingFaceConfig,
+			LocalDirectories:     nodeConfig.LocalDirectoriesConfig,
 		},
 	)
 }
@@ -98,16 +105,38 @@ func (f *StandardExecutorsFactory) Get(
 		ctx,
 		nodeConfig.CleanupManager,
 		executor_util.StandardExecutorOptions{
-			DockerID:   fmt.Sprintf("bacalhau-%s", nodeConfig.HostID),
-			IsBadActor: nodeConfig.IsBadActor,
+			DockerID:          dockerExecutorID(nodeConfig),
+			IsBadActor:        nodeConfig.IsBadActor,
+			Kubernetes:        nodeConfig.KubernetesExecutorConfig,
+			Nomad:             nodeConfig.NomadExecutorConfig,
+			Slurm:             nodeConfig.SlurmExecutorConfig,
+			Firecracker:       nodeConfig.FirecrackerExecutorConfig,
+			PodmanHost:        nodeConfig.PodmanHost,
+			DockerBackends:    nodeConfig.DockerBackends,
+			DockerNonRootUser: nodeConfig.DockerNonRootUser,
+			DockerHardening:   nodeConfig.DockerHardening,
+
+			DockerDebugSessionTimeout: nodeConfig.DockerDebugSessionTimeout,
 			Storage: executor_util.StandardStorageProviderOptions{
 				IPFSMultiaddress:     nodeConfig.IPFSClient.APIAddress(),
 				FilecoinUnsealedPath: nodeConfig.FilecoinUnsealedPath,
+				Decrypter:            nodeConfig.Transport.Decrypt,
+				FilecoinRetrieval:    nodeConfig.FilecoinRetrievalConfig,
+				AzureBlob:            nodeConfig.AzureBlobConfig,
+				GCS:                  nodeConfig.GCSConfig,
+				HuggingFace:          nodeConfig.HuggingFaceConfig,
+				LocalDirectories:     nodeConfig.LocalDirectoriesConfig,
 			},
 		},
 	)
 }
 
+// dockerExecutorID is the ID of the docker executor of the node, which its
+// containers are named and labelled with.
+func dockerExecutorID(nodeConfig NodeConfig) string {
+	return fmt.Sprintf("bacalhau-%s", nodeConfig.HostID)
+}
+
 func NewStandardExecutorsFactory() *StandardExecutorsFactory {
 	return &StandardExecutorsFactory{}
 }
@@ -139,8 +168,16 @@ func (f *StandardPublishersFactory) Get(
 		ctx,
 		nodeConfig.CleanupManager,
 		nodeConfig.IPFSClient.APIAddress(),
-		nodeConfig.EstuaryAPIKey,
+		estuary.EstuaryPublisherConfig{
+			APIKey:             nodeConfig.EstuaryAPIKey,
+			APIKeySecret:       nodeConfig.EstuaryAPIKeySecret,
+			UploadedBlocksPath: nodeConfig.EstuaryUploadedBlocksPath,
+			CollectionUUID:     nodeConfig.EstuaryCollectionUUID,
+			Replication:        nodeConfig.EstuaryReplication,
+			Region:             nodeConfig.EstuaryRegion,
+		},
 		nodeConfig.LotusConfig,
+		nodeConfig.IPFSClusterConfig,
 	)
 }
 
@@ -2,14 +2,35 @@ package node
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
 
+	"github.com/filecoin-project/bacalhau/pkg/compute/secrets"
+	dockerutils "github.com/filecoin-project/bacalhau/pkg/docker"
 	"github.com/filecoin-project/bacalhau/pkg/eventhandler"
 	"github.com/filecoin-project/bacalhau/pkg/executor"
+	"github.com/filecoin-project/bacalhau/pkg/executor/docker"
+	"github.com/filecoin-project/bacalhau/pkg/executor/firecracker"
+	"github.com/filecoin-project/bacalhau/pkg/executor/kubernetes"
+	"github.com/filecoin-project/bacalhau/pkg/executor/nomad"
+	"github.com/filecoin-project/bacalhau/pkg/executor/slurm"
+	executor_util "github.com/filecoin-project/bacalhau/pkg/executor/util"
 	"github.com/filecoin-project/bacalhau/pkg/ipfs"
 	"github.com/filecoin-project/bacalhau/pkg/localdb"
+	"github.com/filecoin-project/bacalhau/pkg/localdb/eventlog"
+	"github.com/filecoin-project/bacalhau/pkg/model"
 	"github.com/filecoin-project/bacalhau/pkg/publicapi"
 	filecoinlotus "github.com/filecoin-project/bacalhau/pkg/publisher/filecoin_lotus"
+	ipfscluster "github.com/filecoin-project/bacalhau/pkg/publisher/ipfs_cluster"
 	"github.com/filecoin-project/bacalhau/pkg/requesternode"
+	"github.com/filecoin-project/bacalhau/pkg/selftest"
+	"github.com/filecoin-project/bacalhau/pkg/storage/cloud/azureblob"
+	"github.com/filecoin-project/bacalhau/pkg/storage/cloud/gcs"
+	filecoinretrieval "github.com/filecoin-project/bacalhau/pkg/storage/filecoin_retrieval"
+	"github.com/filecoin-project/bacalhau/pkg/storage/huggingface"
+	"github.com/filecoin-project/bacalhau/pkg/storage/localdirectory"
 	"github.com/filecoin-project/bacalhau/pkg/system"
 	"github.com/filecoin-project/bacalhau/pkg/transport"
 	"github.com/rs/zerolog/log"
@@ -26,11 +47,72 @@ type NodeConfig struct {
 	HostAddress          string
 	HostID               string
 	APIPort              int
+	APIGRPCPort          int
 	MetricsPort          int
 	IsBadActor           bool
 	ComputeConfig        ComputeConfig
 	RequesterNodeConfig  requesternode.RequesterNodeConfig
 	LotusConfig          *filecoinlotus.PublisherConfig
+	// When set, the Estuary API key is fetched from a secrets backend instead
+	// of using EstuaryAPIKey.
+	EstuaryAPIKeySecret *secrets.Credential
+	// When set, the blocks uploaded to Estuary are recorded in this file, so
+	// that jobs that publish incrementally don't upload them again.
+	EstuaryUploadedBlocksPath string
+	// The collection, replication and region hint of the results published
+	// to Estuary, the defaults of the account if empty.
+	EstuaryCollectionUUID string
+	EstuaryReplication    int
+	EstuaryRegion         string
+	// When set, published results are also pinned on this ipfs-cluster.
+	IPFSClusterConfig *ipfscluster.PublisherConfig
+	// When set, inputs are retrieved from Filecoin storage providers when
+	// they can't be found over IPFS.
+	FilecoinRetrievalConfig *filecoinretrieval.StorageConfig
+	// How to authenticate to Azure Blob Storage and Google Cloud Storage.
+	AzureBlobConfig azureblob.Config
+	GCSConfig       gcs.Config
+	// How to download and cache models from the Hugging Face Hub.
+	HuggingFaceConfig huggingface.StorageConfig
+	// The directories on the node that jobs can read by name, e.g. private
+	// datasets that never leave the node.
+	LocalDirectoriesConfig localdirectory.StorageConfig
+	// When set, job state is derived from an event log kept in this directory
+	// and is rebuilt from it when the node starts.
+	EventLogPath             string
+	EventLogSnapshotInterval uint64
+	// The checks of the node's environment that /healthz?deep=true runs.
+	SelfTestChecks []selftest.Check
+	// When set, docker jobs run as pods through the Kubernetes API rather
+	// than on the Docker daemon.
+	KubernetesExecutorConfig *kubernetes.Config
+	// When set, docker jobs run as Nomad jobs rather than on the Docker
+	// daemon.
+	NomadExecutorConfig *nomad.Config
+	// When set, docker jobs run as Slurm batch jobs rather than on the Docker
+	// daemon.
+	SlurmExecutorConfig *slurm.Config
+	// When set, docker jobs run in Firecracker microVMs rather than in
+	// containers.
+	FirecrackerExecutorConfig *firecracker.Config
+	// The socket of the Docker compatible API of podman, for the podman
+	// docker backend.
+	PodmanHost string
+	// The runtimes docker jobs run on, in order of preference. If empty, the
+	// one whose executor config is set, or the Docker daemon.
+	DockerBackends []executor_util.DockerBackend
+	// When set, docker jobs that would run as root run as this UID[:GID].
+	DockerNonRootUser string
+	// The seccomp and AppArmor profiles, pids limit and ulimits of the
+	// containers of docker jobs on the Docker daemon and podman.
+	DockerHardening docker.HardeningConfig
+	// How long the environment of failed shards of docker jobs that ask for
+	// it is kept for their clients to attach a shell to, on the Docker daemon
+	// only. Not kept if 0.
+	DockerDebugSessionTimeout time.Duration
+	// The networks webhooks and wait callbacks can be posted to although
+	// their addresses are loopback, link-local or private.
+	CallbackAllowedNetworks []*net.IPNet
 }
 
 // Lazy node dependency injector that generate instances of different
@@ -51,6 +133,12 @@ func NewStandardNodeDependencyInjector() NodeDependencyInjector {
 	}
 }
 
+// The component responsible for applying events to the LocalDB
+type localDBEventApplier interface {
+	eventhandler.JobEventHandler
+	eventhandler.LocalEventHandler
+}
+
 type Node struct {
 	// Visible for testing
 	APIServer      *publicapi.APIServer
@@ -73,6 +161,18 @@ func (n *Node) Start(ctx context.Context) error {
 		}
 	}(ctx)
 
+	if n.APIServer.GRPCPort != 0 {
+		go func(ctx context.Context) {
+			if err := n.APIServer.ListenAndServeGRPC(ctx, n.CleanupManager); err != nil {
+				log.Ctx(ctx).Error().Msgf("gRPC API server can't run: %v", err)
+			}
+		}(ctx)
+	}
+
+	if n.IPFSClient != nil {
+		n.IPFSClient.StartHealthChecks(ctx)
+	}
+
 	go func(ctx context.Context) {
 		if err := system.ListenAndServeMetrics(ctx, n.CleanupManager, n.metricsPort); err != nil {
 			log.Ctx(ctx).Error().Msgf("Cannot serve metrics: %v", err)
@@ -152,6 +252,21 @@ func NewNode(
 		jobEventPublisher,
 	)
 
+	if computeNode.reconciler != nil {
+		// so that it doesn't look for containers while the executors remove them
+		config.CleanupManager.RegisterCallback(computeNode.reconciler.Stop, system.Before(docker.CleanupName))
+	}
+
+	debugInfoProviders := computeNode.debugInfoProviders
+	debugInfoProviders = append(debugInfoProviders, requesterNode)
+	debugInfoProviders = append(debugInfoProviders, newNodeInfoProvider(config.HostID, RoleRequester, RoleCompute))
+	if transportDebugInfoProvider, ok := config.Transport.(model.DebugInfoProvider); ok {
+		debugInfoProviders = append(debugInfoProviders, transportDebugInfoProvider)
+	}
+	if versionReporter, ok := config.Transport.(transport.VersionReporter); ok {
+		debugInfoProviders = append(debugInfoProviders, versionReporter.PeerVersions())
+	}
+
 	apiServer := publicapi.NewServer(
 		ctx,
 		config.HostAddress,
@@ -159,10 +274,23 @@ func NewNode(
 		config.LocalDB,
 		config.Transport,
 		requesterNode,
-		computeNode.debugInfoProviders,
+		debugInfoProviders,
 		publishers,
 		storageProviders,
 	)
+	apiServer.GRPCPort = config.APIGRPCPort
+	apiServer.Compute = computeNode.Frontend
+	apiServer.IPFSClient = config.IPFSClient
+	apiServer.SelfTestChecks = config.SelfTestChecks
+	apiServer.CallbackAllowedNetworks = config.CallbackAllowedNetworks
+	apiServer.LivenessChecks, apiServer.ReadinessChecks = dependencyChecks(ctx, config, computeNode, executors)
+	if config.DockerDebugSessionTimeout > 0 {
+		dockerClient, err := dockerutils.NewDockerClient()
+		if err != nil {
+			return nil, err
+		}
+		apiServer.DebugSessions = docker.NewDebugSessions(dockerClient, dockerExecutorID(config))
+	}
 
 	eventTracer, err := eventhandler.NewTracer()
 	if err != nil {
@@ -172,7 +300,24 @@ func NewNode(
 
 	// Register event handlers
 	lifecycleEventHandler := system.NewJobLifecycleEventHandler(config.HostID)
-	localDBEventHandler := localdb.NewLocalDBEventHandler(config.LocalDB)
+	var localDBEventHandler localDBEventApplier = localdb.NewLocalDBEventHandler(config.LocalDB)
+	if config.EventLogPath != "" {
+		eventLog, err := eventlog.NewEventLog(eventlog.EventLogParams{
+			Path:             config.EventLogPath,
+			SnapshotInterval: config.EventLogSnapshotInterval,
+			LocalDB:          config.LocalDB,
+		})
+		if err != nil {
+			return nil, err
+		}
+		config.CleanupManager.RegisterCallback(eventLog.Close)
+
+		// rebuild the local view of the world before we start handling new events
+		if err = eventLog.Replay(ctx); err != nil {
+			return nil, err
+		}
+		localDBEventHandler = eventLog
+	}
 
 	// order of event handlers is important as triggering some handlers might depend on the state of others.
 	jobEventConsumer.AddHandlers(
@@ -186,8 +331,8 @@ func NewNode(
 		localDBEventHandler,
 		// handles bid and result proposals
 		requesterNode,
-		// handles job execution
-		computeNode.frontendProxy,
+		// queues events for job execution, by priority
+		computeNode.eventQueue,
 		// dispatches events to listening websockets
 		apiServer,
 	)
@@ -222,3 +367,63 @@ func NewNode(
 
 	return node, nil
 }
+
+// dependencyChecks returns the checks of the dependencies of the node that
+// /livez and /readyz report on. Only a wedged datastore needs a restart, as
+// the transport reconnects to peers and the other dependencies are outside
+// of the node. A draining node is no longer ready, so that it is taken out
+// of load balancing while it finishes its jobs.
+func dependencyChecks(
+	ctx context.Context,
+	config NodeConfig,
+	computeNode *Compute,
+	executors executor.ExecutorProvider,
+) (liveness, readiness []selftest.Check) {
+	datastoreCheck := selftest.NewCheck("datastore", func(ctx context.Context) error {
+		_, err := config.LocalDB.GetJobs(ctx, localdb.JobQuery{Limit: 1})
+		return err
+	})
+	liveness = []selftest.Check{datastoreCheck}
+	readiness = []selftest.Check{
+		datastoreCheck,
+		selftest.NewCheck("draining", func(ctx context.Context) error {
+			if computeNode.IsDraining() {
+				return errors.New("the node is draining before shutting down")
+			}
+			return nil
+		}),
+	}
+
+	if connectionChecker, ok := config.Transport.(transport.ConnectionChecker); ok {
+		readiness = append(readiness, selftest.NewCheck("transport", connectionChecker.CheckConnection))
+	}
+	if executors.HasExecutor(ctx, model.EngineDocker) {
+		readiness = append(readiness, selftest.NewCheck("docker", func(ctx context.Context) error {
+			dockerExecutor, err := executors.GetExecutor(ctx, model.EngineDocker)
+			if err != nil {
+				return err
+			}
+			installed, err := dockerExecutor.IsInstalled(ctx)
+			if err != nil {
+				return err
+			}
+			switch {
+			case installed:
+				return nil
+			case len(config.DockerBackends) > 1:
+				return fmt.Errorf("none of the docker executors %v can run jobs", config.DockerBackends)
+			case config.KubernetesExecutorConfig != nil:
+				return errors.New("the Kubernetes API can't be reached")
+			case config.NomadExecutorConfig != nil:
+				return errors.New("the Nomad API can't be reached")
+			case config.SlurmExecutorConfig != nil:
+				return errors.New("the Slurm controller can't be reached")
+			case config.FirecrackerExecutorConfig != nil:
+				return errors.New("the firecracker binary, its kernel, /dev/kvm or the Docker daemon is missing")
+			default:
+				return errors.New("the Docker daemon can't be reached")
+			}
+		}))
+	}
+	return liveness, readiness
+}
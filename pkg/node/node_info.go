@@ -0,0 +1,52 @@
+package node
+
+import (
+	"encoding/json"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/version"
+)
+
+// The name under which a node describes itself in the node debug info.
+const NodeInfoDebugInfoComponent = "NodeInfo"
+
+// The roles a node can play in the network.
+const (
+	RoleRequester = "requester"
+	RoleCompute   = "compute"
+)
+
+// NodeInfo describes a node to the tools that map the network.
+type NodeInfo struct {
+	ID      string   `json:"ID"`
+	Roles   []string `json:"Roles"`
+	Version string   `json:"Version"`
+}
+
+type nodeInfoProvider struct {
+	info NodeInfo
+}
+
+func newNodeInfoProvider(nodeID string, roles ...string) *nodeInfoProvider {
+	return &nodeInfoProvider{
+		info: NodeInfo{
+			ID:      nodeID,
+			Roles:   roles,
+			Version: version.Get().GitVersion,
+		},
+	}
+}
+
+// GetDebugInfo implements model.DebugInfoProvider
+func (p *nodeInfoProvider) GetDebugInfo() (model.DebugInfo, error) {
+	info, err := json.Marshal(p.info)
+	if err != nil {
+		return model.DebugInfo{}, err
+	}
+	return model.DebugInfo{
+		Component: NodeInfoDebugInfoComponent,
+		Info:      string(info),
+	}, nil
+}
+
+var _ model.DebugInfoProvider = (*nodeInfoProvider)(nil)
@@ -0,0 +1,28 @@
+//go:build unit || !integration
+
+package node
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/filecoin-project/bacalhau/pkg/version"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNodeInfoDebugInfo(t *testing.T) {
+	provider := newNodeInfoProvider("QmNode", RoleRequester, RoleCompute)
+
+	debugInfo, err := provider.GetDebugInfo()
+	require.NoError(t, err)
+	require.Equal(t, NodeInfoDebugInfoComponent, debugInfo.Component)
+
+	// the info is published as a JSON string, which is what the map reads
+	info := NodeInfo{}
+	require.NoError(t, json.Unmarshal([]byte(debugInfo.Info), &info))
+	require.Equal(t, NodeInfo{
+		ID:      "QmNode",
+		Roles:   []string{RoleRequester, RoleCompute},
+		Version: version.Get().GitVersion,
+	}, info)
+}
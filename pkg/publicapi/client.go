@@ -8,15 +8,22 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"reflect"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/filecoin-project/bacalhau/pkg/bacerrors"
+	"github.com/filecoin-project/bacalhau/pkg/compute/frontend"
 	"github.com/filecoin-project/bacalhau/pkg/job"
+	"github.com/filecoin-project/bacalhau/pkg/localdb"
 	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/publicapi/handlerwrapper"
 	"github.com/filecoin-project/bacalhau/pkg/system"
 	"github.com/filecoin-project/bacalhau/pkg/util/closer"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
 	"github.com/rs/zerolog/log"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"go.opentelemetry.io/otel/attribute"
@@ -37,7 +44,7 @@ func NewAPIClient(baseURI string) *APIClient {
 
 		client: &http.Client{
 			Timeout: 300 * time.Second,
-			Transport: otelhttp.NewTransport(nil,
+			Transport: otelhttp.NewTransport(requestIDTransport{next: http.DefaultTransport},
 				otelhttp.WithSpanOptions(
 					trace.WithAttributes(
 						attribute.String("clientID", system.GetClientID()),
@@ -48,6 +55,23 @@ func NewAPIClient(baseURI string) *APIClient {
 	}
 }
 
+// requestIDTransport sends every request with its own X-Request-ID, so that
+// the requests of the client can be found in the logs of the server.
+type requestIDTransport struct {
+	next http.RoundTripper
+}
+
+func (t requestIDTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get(handlerwrapper.HTTPHeaderRequestID) == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set(handlerwrapper.HTTPHeaderRequestID, uuid.NewString())
+	}
+	log.Ctx(req.Context()).Debug().
+		Str("RequestID", req.Header.Get(handlerwrapper.HTTPHeaderRequestID)).
+		Msgf("%s %s", req.Method, req.URL)
+	return t.next.RoundTrip(req)
+}
+
 // Alive calls the node's API server health check.
 func (apiClient *APIClient) Alive(ctx context.Context) (bool, error) {
 	ctx, span := system.GetTracer().Start(ctx, "pkg/publicapi.Alive")
@@ -91,6 +115,65 @@ func (apiClient *APIClient) List(ctx context.Context, idFilter string, maxJobs i
 	return res.Jobs, nil
 }
 
+// JobSearch filters the jobs returned by Search. Jobs must match all the
+// filters that are set, as described by localdb.MatchesSearch.
+type JobSearch struct {
+	ClientID  string
+	Image     string
+	Labels    []string
+	InputCID  string
+	OutputCID string
+}
+
+// Search returns the jobs on the network that match the search, sorted by
+// sortBy.
+func (apiClient *APIClient) Search(ctx context.Context, search JobSearch, maxJobs int, sortBy string, sortReverse bool) (
+	[]*model.Job, error) {
+	ctx, span := system.GetTracer().Start(ctx, "pkg/publicapi.Search")
+	defer span.End()
+
+	req := searchRequest{
+		ClientID:    search.ClientID,
+		Image:       search.Image,
+		Labels:      search.Labels,
+		InputCID:    search.InputCID,
+		OutputCID:   search.OutputCID,
+		MaxJobs:     maxJobs,
+		SortBy:      sortBy,
+		SortReverse: sortReverse,
+	}
+
+	var res listResponse
+	if err := apiClient.post(ctx, "search", req, &res); err != nil {
+		return nil, err
+	}
+	return res.Jobs, nil
+}
+
+// Export writes the jobs or events the query selects, exported by the
+// requester node in the format of the query, to w.
+func (apiClient *APIClient) Export(ctx context.Context, query localdb.ExportQuery, w io.Writer) error {
+	ctx, span := system.GetTracer().Start(ctx, "pkg/publicapi.Export")
+	defer span.End()
+
+	addr := fmt.Sprintf("%s/export?%s", apiClient.BaseURI, exportQueryValues(query).Encode())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, addr, nil)
+	if err != nil {
+		return bacerrors.NewResponseUnknownError(fmt.Errorf("publicapi: error creating get request: %v", err))
+	}
+	res, err := apiClient.client.Do(req) //nolint:bodyclose // golangcilint is dumb - this is closed
+	if err != nil {
+		return bacerrors.NewResponseUnknownError(fmt.Errorf("publicapi: after get request: %v", err))
+	}
+	defer closer.DrainAndCloseWithLogOnError(ctx, "apiClient response", res.Body)
+
+	if res.StatusCode != http.StatusOK {
+		return responseError(res, "exporting "+string(query.Kind))
+	}
+	_, err = io.Copy(w, res.Body)
+	return err
+}
+
 // Get returns job data for a particular job ID. If no match is found, Get returns false with a nil error.
 func (apiClient *APIClient) Get(ctx context.Context, jobID string) (*model.Job, bool, error) {
 	ctx, span := system.GetTracer().Start(ctx, "pkg/publicapi.Get")
@@ -133,6 +216,215 @@ func (apiClient *APIClient) GetJobState(ctx context.Context, jobID string) (mode
 	return res.State, nil
 }
 
+// GetJobLogs returns the recent log lines of the node for a job, as JSON
+// objects.
+func (apiClient *APIClient) GetJobLogs(ctx context.Context, jobID string) ([]json.RawMessage, error) {
+	ctx, span := system.GetTracer().Start(ctx, "pkg/publicapi.GetJobLogs")
+	defer span.End()
+
+	if jobID == "" {
+		return nil, fmt.Errorf("jobID must be non-empty in a GetJobLogs call")
+	}
+
+	req := jobLogsRequest{
+		ClientID: system.GetClientID(),
+		JobID:    jobID,
+	}
+
+	var res jobLogsResponse
+	if err := apiClient.post(ctx, "job_logs", req, &res); err != nil {
+		return nil, err
+	}
+
+	return res.Logs, nil
+}
+
+// DownloadResults writes a gzipped tarball of the results of a job, fetched
+// by the requester node, to w.
+func (apiClient *APIClient) DownloadResults(ctx context.Context, jobID string, w io.Writer) error {
+	return apiClient.DownloadShardResults(ctx, jobID, nil, w)
+}
+
+// DownloadShardResults is DownloadResults for the results of some of the
+// shards of a job, so that the results of the shards that have completed
+// can be fetched before the whole job has finished.
+func (apiClient *APIClient) DownloadShardResults(ctx context.Context, jobID string, shardIndexes []int, w io.Writer) error {
+	ctx, span := system.GetTracer().Start(ctx, "pkg/publicapi.DownloadResults")
+	defer span.End()
+
+	if jobID == "" {
+		return fmt.Errorf("jobID must be non-empty in a DownloadResults call")
+	}
+
+	addr := fmt.Sprintf("%s%s%s%s", apiClient.BaseURI, jobPathPrefix, jobID, resultsDownloadPathEnd)
+	if len(shardIndexes) > 0 {
+		shards := make([]string, len(shardIndexes))
+		for i, shardIndex := range shardIndexes {
+			shards[i] = strconv.Itoa(shardIndex)
+		}
+		addr += "?shards=" + strings.Join(shards, ",")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, addr, nil)
+	if err != nil {
+		return bacerrors.NewResponseUnknownError(fmt.Errorf("publicapi: error creating get request: %v", err))
+	}
+	res, err := apiClient.client.Do(req) //nolint:bodyclose // golangcilint is dumb - this is closed
+	if err != nil {
+		return bacerrors.NewResponseUnknownError(fmt.Errorf("publicapi: after get request: %v", err))
+	}
+	defer closer.DrainAndCloseWithLogOnError(ctx, "apiClient response", res.Body)
+
+	if res.StatusCode != http.StatusOK {
+		return responseError(res, "downloading results")
+	}
+	_, err = io.Copy(w, res.Body)
+	return err
+}
+
+// ReadResultsFile writes the file at the slash-separated path in the results
+// of a shard of a job, fetched by the requester node, to w. If length is
+// positive, only that many bytes of it from offset are written, so large
+// outputs can be previewed without downloading them.
+func (apiClient *APIClient) ReadResultsFile(
+	ctx context.Context, jobID string, shardIndex int, filePath string, offset, length int64, w io.Writer) error {
+	ctx, span := system.GetTracer().Start(ctx, "pkg/publicapi.ReadResultsFile")
+	defer span.End()
+
+	if jobID == "" {
+		return fmt.Errorf("jobID must be non-empty in a ReadResultsFile call")
+	}
+
+	query := url.Values{"path": {filePath}, "shard": {strconv.Itoa(shardIndex)}}
+	addr := fmt.Sprintf("%s%s%s%s?%s", apiClient.BaseURI, jobPathPrefix, jobID, resultsFilePathEnd, query.Encode())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, addr, nil)
+	if err != nil {
+		return bacerrors.NewResponseUnknownError(fmt.Errorf("publicapi: error creating get request: %v", err))
+	}
+	if length > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+	} else if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	res, err := apiClient.client.Do(req) //nolint:bodyclose // golangcilint is dumb - this is closed
+	if err != nil {
+		return bacerrors.NewResponseUnknownError(fmt.Errorf("publicapi: after get request: %v", err))
+	}
+	defer closer.DrainAndCloseWithLogOnError(ctx, "apiClient response", res.Body)
+
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusPartialContent {
+		return responseError(res, "reading results file")
+	}
+	_, err = io.Copy(w, res.Body)
+	return err
+}
+
+// DebugShard attaches a shell to the environment the node has kept of a
+// failed shard of a job, which reads from stdin and writes to stdout until
+// either of them ends. Only the node that ran the shard keeps it, and only if
+// the job asked it to and the node allows it.
+func (apiClient *APIClient) DebugShard(
+	ctx context.Context,
+	jobID string,
+	shardIndex int,
+	stdin io.Reader,
+	stdout io.Writer,
+) error {
+	ctx, span := system.GetTracer().Start(ctx, "pkg/publicapi.DebugShard")
+	defer span.End()
+
+	data := model.JobDebugPayload{
+		ClientID:   system.GetClientID(),
+		JobID:      jobID,
+		ShardIndex: shardIndex,
+		CreatedAt:  time.Now(),
+		Nonce:      uuid.NewString(),
+	}
+	jsonData, err := model.JSONMarshalWithMax(data)
+	if err != nil {
+		return err
+	}
+	signature, err := system.SignForClient(jsonData)
+	if err != nil {
+		return err
+	}
+
+	debugURL := "ws" + strings.TrimPrefix(apiClient.BaseURI, "http") + "/debug_session"
+	conn, res, err := websocket.DefaultDialer.DialContext(ctx, debugURL, nil)
+	if res != nil && res.StatusCode != http.StatusSwitchingProtocols {
+		defer res.Body.Close()
+		return responseError(res, "debugging shard")
+	}
+	if err != nil {
+		return bacerrors.NewResponseUnknownError(fmt.Errorf("publicapi: error connecting to debug shard: %v", err))
+	}
+	defer conn.Close()
+
+	err = conn.WriteJSON(debugSessionRequest{
+		Data:               data,
+		ClientSignature:    signature,
+		ClientPublicKey:    system.GetClientPublicKey(),
+		ClientKeyRotations: system.GetClientKeyRotations(),
+	})
+	if err != nil {
+		return err
+	}
+
+	terminal := &websocketTerminal{conn: conn}
+	go func() {
+		if _, err := io.Copy(terminal, stdin); err != nil {
+			log.Ctx(ctx).Debug().Err(err).Msg("Failed to send stdin to the shell")
+		}
+		_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	}()
+	_, err = io.Copy(stdout, terminal)
+	return err
+}
+
+// GetShards returns where each of the given shards of a job has got to, and
+// their results, or all of the shards if none are given. If completedOnly is
+// set, only the shards with published results are returned.
+func (apiClient *APIClient) GetShards(ctx context.Context, jobID string, shardIndexes []int, completedOnly bool) ([]ShardSummary, error) {
+	ctx, span := system.GetTracer().Start(ctx, "pkg/publicapi.GetShards")
+	defer span.End()
+
+	if jobID == "" {
+		return nil, fmt.Errorf("jobID must be non-empty in a GetShards call")
+	}
+
+	req := shardsRequest{
+		ClientID:      system.GetClientID(),
+		JobID:         jobID,
+		ShardIndexes:  shardIndexes,
+		CompletedOnly: completedOnly,
+	}
+
+	var res shardsResponse
+	if err := apiClient.post(ctx, "shards", req, &res); err != nil {
+		return nil, err
+	}
+
+	return res.Shards, nil
+}
+
+// DryRun explains whether the compute node of the API server would bid on a
+// job, without submitting it.
+func (apiClient *APIClient) DryRun(ctx context.Context, j *model.Job) (frontend.ExplainBidResponse, error) {
+	ctx, span := system.GetTracer().Start(ctx, "pkg/publicapi.DryRun")
+	defer span.End()
+
+	req := dryRunRequest{
+		ClientID: system.GetClientID(),
+		Job:      *j,
+	}
+
+	var res dryRunResponse
+	if err := apiClient.post(ctx, "dry_run", req, &res); err != nil {
+		return frontend.ExplainBidResponse{}, err
+	}
+
+	return res.Explanation, nil
+}
+
 func (apiClient *APIClient) GetJobStateResolver() *job.StateResolver {
 	jobLoader := func(ctx context.Context, jobID string) (*model.Job, error) {
 		j, _, err := apiClient.Get(ctx, jobID)
@@ -144,7 +436,35 @@ func (apiClient *APIClient) GetJobStateResolver() *job.StateResolver {
 	stateLoader := func(ctx context.Context, jobID string) (model.JobState, error) {
 		return apiClient.GetJobState(ctx, jobID)
 	}
-	return job.NewStateResolver(jobLoader, stateLoader)
+	resolver := job.NewStateResolver(jobLoader, stateLoader)
+	resolver.SetWaitBackoff(job.DefaultWaitBackoff, job.DefaultMaxWaitDelay)
+	resolver.SetStateSubscriber(apiClient.subscribeToJob, subscribedPollDelay)
+	return resolver
+}
+
+// How often a job state resolver polls the state of a job that it is
+// subscribed to, in case an event is missed.
+const subscribedPollDelay = 10 * time.Second
+
+// subscribeToJob is a job.StateSubscriber that is told about each event of
+// the job from the event stream.
+func (apiClient *APIClient) subscribeToJob(ctx context.Context, jobID string) (<-chan struct{}, error) {
+	changed := make(chan struct{}, 1)
+	go func() {
+		defer close(changed)
+		err := apiClient.StreamEvents(ctx, jobID, func(model.JobEvent) (bool, error) {
+			select {
+			case changed <- struct{}{}:
+			default:
+				// the waiter has yet to check the last change
+			}
+			return false, nil
+		})
+		if err != nil && ctx.Err() == nil {
+			log.Ctx(ctx).Debug().Err(err).Msgf("error streaming the events of job %s", jobID)
+		}
+	}()
+	return changed, nil
 }
 
 func (apiClient *APIClient) GetEvents(ctx context.Context, jobID string) (events []model.JobEvent, err error) {
@@ -217,18 +537,174 @@ func (apiClient *APIClient) GetResults(ctx context.Context, jobID string) (resul
 	return res.Results, nil
 }
 
+// Wait waits up to timeout, capped by the server at MaxWaitTimeout, for a job
+// to finish and returns where it has got to. Callers can poll it until
+// Finished is set.
+func (apiClient *APIClient) Wait(ctx context.Context, jobID string, timeout time.Duration) (*WaitResponse, error) {
+	return apiClient.wait(ctx, jobID, timeout, "")
+}
+
+// WaitWithCallback asks the node to post the WaitResponse of a job to
+// callbackURL once it has finished, and returns where the job has got to now.
+func (apiClient *APIClient) WaitWithCallback(ctx context.Context, jobID, callbackURL string) (*WaitResponse, error) {
+	return apiClient.wait(ctx, jobID, 0, callbackURL)
+}
+
+func (apiClient *APIClient) wait(ctx context.Context, jobID string, timeout time.Duration, callbackURL string) (*WaitResponse, error) {
+	ctx, span := system.GetTracer().Start(ctx, "pkg/publicapi.Wait")
+	defer span.End()
+
+	if jobID == "" {
+		return nil, fmt.Errorf("jobID must be non-empty in a Wait call")
+	}
+
+	req := waitRequest{
+		ClientID:       system.GetClientID(),
+		JobID:          jobID,
+		TimeoutSeconds: timeout.Seconds(),
+		CallbackURL:    callbackURL,
+	}
+
+	var res WaitResponse
+	if err := apiClient.post(ctx, "wait", req, &res); err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+// LongPollWait blocks until the job has finished or the timeout has passed,
+// and returns where the job has got to. Unlike Wait, the timeout is only
+// capped by how long the node can hold a request open for, which is
+// MaxJobWaitTimeout by default. A timeout of 0 waits for as long as the node
+// allows.
+func (apiClient *APIClient) LongPollWait(ctx context.Context, jobID string, timeout time.Duration) (*WaitResponse, error) {
+	ctx, span := system.GetTracer().Start(ctx, "pkg/publicapi.LongPollWait")
+	defer span.End()
+
+	if jobID == "" {
+		return nil, fmt.Errorf("jobID must be non-empty in a LongPollWait call")
+	}
+
+	addr := fmt.Sprintf("%s%s%s%s", apiClient.BaseURI, jobPathPrefix, jobID, jobWaitPathEnd)
+	if timeout > 0 {
+		addr += "?timeout=" + url.QueryEscape(timeout.String())
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, addr, nil)
+	if err != nil {
+		return nil, bacerrors.NewResponseUnknownError(fmt.Errorf("publicapi: error creating get request: %v", err))
+	}
+	res, err := apiClient.client.Do(req) //nolint:bodyclose // golangcilint is dumb - this is closed
+	if err != nil {
+		return nil, bacerrors.NewResponseUnknownError(fmt.Errorf("publicapi: after get request: %v", err))
+	}
+	defer closer.DrainAndCloseWithLogOnError(ctx, "apiClient response", res.Body)
+
+	if res.StatusCode != http.StatusOK {
+		return nil, responseError(res, "waiting for job")
+	}
+	var waitRes WaitResponse
+	if err = json.NewDecoder(res.Body).Decode(&waitRes); err != nil {
+		return nil, bacerrors.NewResponseUnknownError(fmt.Errorf("publicapi: error decoding wait response: %v", err))
+	}
+	return &waitRes, nil
+}
+
+// WaitUntilFinished long polls the node until the job has finished or ctx is
+// done, and returns where the job has got to.
+func (apiClient *APIClient) WaitUntilFinished(ctx context.Context, jobID string) (*WaitResponse, error) {
+	for {
+		waitRes, err := apiClient.LongPollWait(ctx, jobID, 0)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, err
+		}
+		if waitRes.Finished {
+			return waitRes, nil
+		}
+		if ctx.Err() != nil {
+			return waitRes, ctx.Err()
+		}
+	}
+}
+
+// RegisterWebhook registers a URL that is posted a WebhookEvent, signed with
+// secret, whenever one of the client's jobs changes state. If jobID is set,
+// it is only called for that job.
+func (apiClient *APIClient) RegisterWebhook(ctx context.Context, jobID, url, secret string) error {
+	return apiClient.postWebhook(ctx, "register_webhook", model.WebhookPayload{
+		ClientID:  system.GetClientID(),
+		JobID:     jobID,
+		URL:       url,
+		Secret:    secret,
+		CreatedAt: time.Now(),
+		Nonce:     uuid.NewString(),
+	})
+}
+
+// UnregisterWebhook unregisters a URL registered with RegisterWebhook.
+func (apiClient *APIClient) UnregisterWebhook(ctx context.Context, jobID, url string) error {
+	return apiClient.postWebhook(ctx, "unregister_webhook", model.WebhookPayload{
+		ClientID:  system.GetClientID(),
+		JobID:     jobID,
+		URL:       url,
+		CreatedAt: time.Now(),
+		Nonce:     uuid.NewString(),
+	})
+}
+
+func (apiClient *APIClient) postWebhook(ctx context.Context, endpoint string, data model.WebhookPayload) error {
+	ctx, span := system.GetTracer().Start(ctx, "pkg/publicapi."+endpoint)
+	defer span.End()
+
+	jsonData, err := model.JSONMarshalWithMax(data)
+	if err != nil {
+		return err
+	}
+
+	signature, err := system.SignForClient(jsonData)
+	if err != nil {
+		return err
+	}
+
+	req := webhookRequest{
+		Data:               data,
+		ClientSignature:    signature,
+		ClientPublicKey:    system.GetClientPublicKey(),
+		ClientKeyRotations: system.GetClientKeyRotations(),
+	}
+	var res webhookResponse
+	return apiClient.post(ctx, endpoint, req, &res)
+}
+
 // Submit submits a new job to the node's transport.
 func (apiClient *APIClient) Submit(
 	ctx context.Context,
 	j *model.Job,
 	buildContext *bytes.Buffer,
+) (*model.Job, error) {
+	return apiClient.SubmitIdempotent(ctx, j, buildContext, "")
+}
+
+// SubmitIdempotent submits a new job to the node's transport, unless a job
+// was already submitted with the same idempotency key, in which case that
+// job is returned. This makes it safe to retry a submission whose response
+// was lost.
+func (apiClient *APIClient) SubmitIdempotent(
+	ctx context.Context,
+	j *model.Job,
+	buildContext *bytes.Buffer,
+	idempotencyKey string,
 ) (*model.Job, error) {
 	ctx, span := system.GetTracer().Start(ctx, "pkg/publicapi.Submit")
 	defer span.End()
 
 	data := model.JobCreatePayload{
-		ClientID: system.GetClientID(),
-		Job:      j,
+		ClientID:       system.GetClientID(),
+		Job:            j,
+		IdempotencyKey: idempotencyKey,
 	}
 
 	if buildContext != nil {
@@ -247,9 +723,10 @@ func (apiClient *APIClient) Submit(
 
 	var res submitResponse
 	req := submitRequest{
-		Data:            data,
-		ClientSignature: signature,
-		ClientPublicKey: system.GetClientPublicKey(),
+		Data:               data,
+		ClientSignature:    signature,
+		ClientPublicKey:    system.GetClientPublicKey(),
+		ClientKeyRotations: system.GetClientKeyRotations(),
 	}
 
 	err = apiClient.post(ctx, "submit", req, &res)
@@ -260,6 +737,366 @@ func (apiClient *APIClient) Submit(
 	return res.Job, nil
 }
 
+// ResubmitOverrides are the parts of a job's spec to change when it is
+// resubmitted. Empty fields are left as they were.
+type ResubmitOverrides struct {
+	Image     string                     // the docker image to run instead
+	ImageTag  string                     // the tag to run the docker image with instead
+	Inputs    []model.StorageSpec        // the input volumes to use instead
+	Resources *model.ResourceUsageConfig // the resources to require instead
+}
+
+// Resubmit submits a copy of an existing job as a new job, with the
+// overrides applied to its spec. The new job is linked to the original by its
+// Spec.ResubmittedFrom. The deadline of the original job is not copied.
+func (apiClient *APIClient) Resubmit(ctx context.Context, jobID string, overrides ResubmitOverrides) (*model.Job, error) {
+	ctx, span := system.GetTracer().Start(ctx, "pkg/publicapi.Resubmit")
+	defer span.End()
+
+	original, _, err := apiClient.Get(ctx, jobID)
+	if err != nil {
+		return &model.Job{}, err
+	}
+
+	j := model.NewJob()
+	j.Spec = original.Spec
+	j.Spec.ResubmittedFrom = original.ID
+	j.Spec.Challenge = model.JobChallenge{}
+	j.Deal = original.Deal
+	j.Deal.Deadline = time.Time{}
+
+	if overrides.Image != "" {
+		j.Spec.Docker.Image = overrides.Image
+	}
+	if overrides.ImageTag != "" {
+		j.Spec.Docker.Image = withImageTag(j.Spec.Docker.Image, overrides.ImageTag)
+	}
+	if overrides.Inputs != nil {
+		j.Spec.Inputs = overrides.Inputs
+	}
+	if overrides.Resources != nil {
+		j.Spec.Resources = *overrides.Resources
+	}
+
+	return apiClient.Submit(ctx, j, nil)
+}
+
+// withImageTag replaces the tag or digest of a docker image reference.
+func withImageTag(image, tag string) string {
+	if i := strings.Index(image, "@"); i >= 0 {
+		image = image[:i]
+	}
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		image = image[:i]
+	}
+	return image + ":" + tag
+}
+
+// SubmitBatch submits many jobs to the node's transport in one request, and
+// returns the job group they were submitted in. The results are in the same
+// order as jobs. If atomic is set, none of the jobs are submitted if any of
+// them is invalid, and those already submitted are cancelled if one fails to
+// submit.
+func (apiClient *APIClient) SubmitBatch(ctx context.Context, jobs []*model.Job, atomic bool) (string, []SubmitBatchResult, error) {
+	ctx, span := system.GetTracer().Start(ctx, "pkg/publicapi.SubmitBatch")
+	defer span.End()
+
+	data := model.JobBatchCreatePayload{
+		ClientID: system.GetClientID(),
+		Jobs:     jobs,
+		Atomic:   atomic,
+	}
+
+	jsonData, err := model.JSONMarshalWithMax(data)
+	if err != nil {
+		return "", nil, err
+	}
+
+	signature, err := system.SignForClient(jsonData)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var res submitBatchResponse
+	req := submitBatchRequest{
+		Data:               data,
+		ClientSignature:    signature,
+		ClientPublicKey:    system.GetClientPublicKey(),
+		ClientKeyRotations: system.GetClientKeyRotations(),
+	}
+
+	err = apiClient.post(ctx, "submit_batch", req, &res)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return res.JobGroup, res.Results, nil
+}
+
+// ListJobGroup returns the jobs the client submitted in a job group.
+func (apiClient *APIClient) ListJobGroup(ctx context.Context, jobGroup string) ([]*model.Job, error) {
+	ctx, span := system.GetTracer().Start(ctx, "pkg/publicapi.ListJobGroup")
+	defer span.End()
+
+	if jobGroup == "" {
+		return nil, fmt.Errorf("jobGroup must be non-empty in a ListJobGroup call")
+	}
+
+	req := listRequest{
+		ClientID: system.GetClientID(),
+		JobGroup: jobGroup,
+		MaxJobs:  MaxJobsPerBatch,
+		SortBy:   "created_at",
+	}
+
+	var res listResponse
+	if err := apiClient.post(ctx, "list", req, &res); err != nil {
+		return nil, err
+	}
+
+	return res.Jobs, nil
+}
+
+// Cancel cancels a job, and returns the IDs of the jobs that were cancelled.
+func (apiClient *APIClient) Cancel(ctx context.Context, jobID, reason string) ([]string, error) {
+	return apiClient.cancel(ctx, model.JobCancelPayload{
+		ClientID: system.GetClientID(),
+		JobID:    jobID,
+		Reason:   reason,
+	})
+}
+
+// CancelJobGroup cancels all the jobs the client submitted in a job group,
+// and returns the IDs of the jobs that were cancelled.
+func (apiClient *APIClient) CancelJobGroup(ctx context.Context, jobGroup, reason string) ([]string, error) {
+	return apiClient.cancel(ctx, model.JobCancelPayload{
+		ClientID: system.GetClientID(),
+		JobGroup: jobGroup,
+		Reason:   reason,
+	})
+}
+
+func (apiClient *APIClient) cancel(ctx context.Context, data model.JobCancelPayload) ([]string, error) {
+	ctx, span := system.GetTracer().Start(ctx, "pkg/publicapi.Cancel")
+	defer span.End()
+
+	jsonData, err := model.JSONMarshalWithMax(data)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := system.SignForClient(jsonData)
+	if err != nil {
+		return nil, err
+	}
+
+	req := cancelRequest{
+		Data:               data,
+		ClientSignature:    signature,
+		ClientPublicKey:    system.GetClientPublicKey(),
+		ClientKeyRotations: system.GetClientKeyRotations(),
+	}
+	var res cancelResponse
+	if err = apiClient.post(ctx, "cancel", req, &res); err != nil {
+		return nil, err
+	}
+
+	return res.JobIDs, nil
+}
+
+// Approve approves a job held by the requester node until it is approved,
+// which announces it to the network. The client must be an approver of the
+// requester node.
+func (apiClient *APIClient) Approve(ctx context.Context, jobID string) error {
+	return apiClient.approve(ctx, model.JobApprovalPayload{
+		ClientID: system.GetClientID(),
+		JobID:    jobID,
+		Approved: true,
+	})
+}
+
+// Reject rejects a job held by the requester node until it is approved, so
+// that it never runs.
+func (apiClient *APIClient) Reject(ctx context.Context, jobID, reason string) error {
+	return apiClient.approve(ctx, model.JobApprovalPayload{
+		ClientID: system.GetClientID(),
+		JobID:    jobID,
+		Reason:   reason,
+	})
+}
+
+func (apiClient *APIClient) approve(ctx context.Context, data model.JobApprovalPayload) error {
+	ctx, span := system.GetTracer().Start(ctx, "pkg/publicapi.Approve")
+	defer span.End()
+
+	jsonData, err := model.JSONMarshalWithMax(data)
+	if err != nil {
+		return err
+	}
+
+	signature, err := system.SignForClient(jsonData)
+	if err != nil {
+		return err
+	}
+
+	req := approveRequest{
+		Data:               data,
+		ClientSignature:    signature,
+		ClientPublicKey:    system.GetClientPublicKey(),
+		ClientKeyRotations: system.GetClientKeyRotations(),
+	}
+	return apiClient.post(ctx, "approve", req, &struct{}{})
+}
+
+// PendingApprovals returns the IDs of the jobs the requester node holds
+// until they are approved.
+func (apiClient *APIClient) PendingApprovals(ctx context.Context) ([]string, error) {
+	ctx, span := system.GetTracer().Start(ctx, "pkg/publicapi.PendingApprovals")
+	defer span.End()
+
+	var res approvalsResponse
+	if err := apiClient.post(ctx, "approvals", struct{}{}, &res); err != nil {
+		return nil, err
+	}
+	return res.JobIDs, nil
+}
+
+// Flag flags a client or a job as abusive, which adds it to the denylist of
+// the requester node. Exactly one of clientID and jobID must be given, and the
+// client must be a moderator of the requester node.
+func (apiClient *APIClient) Flag(ctx context.Context, clientID, jobID, reason string) error {
+	return apiClient.moderate(ctx, model.ModerationPayload{
+		ClientID:       system.GetClientID(),
+		TargetClientID: clientID,
+		TargetJobID:    jobID,
+		Reason:         reason,
+	})
+}
+
+// Unflag removes a client or a job from the denylist of the requester node.
+func (apiClient *APIClient) Unflag(ctx context.Context, clientID, jobID string) error {
+	return apiClient.moderate(ctx, model.ModerationPayload{
+		ClientID:       system.GetClientID(),
+		TargetClientID: clientID,
+		TargetJobID:    jobID,
+		Unflag:         true,
+	})
+}
+
+func (apiClient *APIClient) moderate(ctx context.Context, data model.ModerationPayload) error {
+	ctx, span := system.GetTracer().Start(ctx, "pkg/publicapi.Moderate")
+	defer span.End()
+
+	data.Timestamp = time.Now().UTC()
+	data.Nonce = uuid.NewString()
+	jsonData, err := model.JSONMarshalWithMax(data)
+	if err != nil {
+		return err
+	}
+
+	signature, err := system.SignForClient(jsonData)
+	if err != nil {
+		return err
+	}
+
+	req := moderateRequest{
+		Data:               data,
+		ClientSignature:    signature,
+		ClientPublicKey:    system.GetClientPublicKey(),
+		ClientKeyRotations: system.GetClientKeyRotations(),
+	}
+	return apiClient.post(ctx, "moderate", req, &struct{}{})
+}
+
+// Denylist returns the clients and jobs flagged as abusive by the moderators
+// of the requester node.
+func (apiClient *APIClient) Denylist(ctx context.Context) (model.Denylist, error) {
+	ctx, span := system.GetTracer().Start(ctx, "pkg/publicapi.Denylist")
+	defer span.End()
+
+	var res model.Denylist
+	if err := apiClient.get(ctx, "denylist", &res); err != nil {
+		return model.Denylist{}, err
+	}
+	return res, nil
+}
+
+// ClientKey returns the client ID, public key and fingerprint of the ID key of
+// the node.
+func (apiClient *APIClient) ClientKey(ctx context.Context) (model.ClientKeyInfo, error) {
+	ctx, span := system.GetTracer().Start(ctx, "pkg/publicapi.ClientKey")
+	defer span.End()
+
+	var res model.ClientKeyInfo
+	if err := apiClient.post(ctx, "client_key", struct{}{}, &res); err != nil {
+		return model.ClientKeyInfo{}, err
+	}
+	return res, nil
+}
+
+// Challenge challenges the results a node published for a shard of a job
+// verified optimistically, and returns the job that re-executes the shard
+// on another node to check them.
+func (apiClient *APIClient) Challenge(
+	ctx context.Context,
+	jobID string,
+	shardIndex int,
+	nodeID, reason string,
+) (*model.Job, error) {
+	ctx, span := system.GetTracer().Start(ctx, "pkg/publicapi.Challenge")
+	defer span.End()
+
+	data := model.JobChallengePayload{
+		ClientID:   system.GetClientID(),
+		JobID:      jobID,
+		ShardIndex: shardIndex,
+		NodeID:     nodeID,
+		Reason:     reason,
+	}
+	jsonData, err := model.JSONMarshalWithMax(data)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := system.SignForClient(jsonData)
+	if err != nil {
+		return nil, err
+	}
+
+	var res challengeResponse
+	req := challengeRequest{
+		Data:               data,
+		ClientSignature:    signature,
+		ClientPublicKey:    system.GetClientPublicKey(),
+		ClientKeyRotations: system.GetClientKeyRotations(),
+	}
+	if err = apiClient.post(ctx, "challenge", req, &res); err != nil {
+		return nil, err
+	}
+	return res.Job, nil
+}
+
+// WaitJobGroup waits for all the jobs the client submitted in a job group to
+// finish, or for ctx to be done, and returns where each of them has got to.
+func (apiClient *APIClient) WaitJobGroup(ctx context.Context, jobGroup string) ([]*WaitResponse, error) {
+	ctx, span := system.GetTracer().Start(ctx, "pkg/publicapi.WaitJobGroup")
+	defer span.End()
+
+	jobs, err := apiClient.ListJobGroup(ctx, jobGroup)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]*WaitResponse, len(jobs))
+	for i, j := range jobs {
+		if responses[i], err = apiClient.WaitUntilFinished(ctx, j.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	return responses, nil
+}
+
 // Submit submits a new job to the node's transport.
 func (apiClient *APIClient) Version(ctx context.Context) (*model.BuildVersionInfo, error) {
 	ctx, span := system.GetTracer().Start(ctx, "pkg/publicapi.Version")
@@ -277,6 +1114,19 @@ func (apiClient *APIClient) Version(ctx context.Context) (*model.BuildVersionInf
 	return res.VersionInfo, nil
 }
 
+// Debug returns the debug information of the node, keyed by component.
+func (apiClient *APIClient) Debug(ctx context.Context) (map[string]string, error) {
+	ctx, span := system.GetTracer().Start(ctx, "pkg/publicapi.Debug")
+	defer span.End()
+
+	res := map[string]string{}
+	if err := apiClient.post(ctx, "debug", struct{}{}, &res); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
 func (apiClient *APIClient) post(ctx context.Context, api string, reqData, resData interface{}) error {
 	ctx, span := system.GetTracer().Start(ctx, "pkg/publicapi.post")
 	defer span.End()
@@ -328,7 +1178,7 @@ func (apiClient *APIClient) post(ctx context.Context, api string, reqData, resDa
 		}
 
 		if !reflect.DeepEqual(serverError, bacerrors.BacalhauErrorInterface(nil)) {
-			return serverError
+			return serverError.ToError()
 		}
 	}
 
@@ -343,3 +1193,41 @@ func (apiClient *APIClient) post(ctx context.Context, api string, reqData, resDa
 
 	return nil
 }
+
+// get fetches an endpoint that takes no query, such as those compute nodes
+// poll, and decodes its response into resData.
+func (apiClient *APIClient) get(ctx context.Context, api string, resData interface{}) error {
+	ctx, span := system.GetTracer().Start(ctx, "pkg/publicapi.get")
+	defer span.End()
+
+	addr := fmt.Sprintf("%s/%s", apiClient.BaseURI, api)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, addr, nil)
+	if err != nil {
+		return bacerrors.NewResponseUnknownError(fmt.Errorf("publicapi: error creating get request: %v", err))
+	}
+	res, err := apiClient.client.Do(req) //nolint:bodyclose // golangcilint is dumb - this is closed
+	if err != nil {
+		return bacerrors.NewResponseUnknownError(fmt.Errorf("publicapi: after get request: %v", err))
+	}
+	defer closer.DrainAndCloseWithLogOnError(ctx, "apiClient response", res.Body)
+
+	if res.StatusCode != http.StatusOK {
+		return responseError(res, "getting "+api)
+	}
+	if err = json.NewDecoder(res.Body).Decode(resData); err != nil {
+		return bacerrors.NewResponseUnknownError(fmt.Errorf("publicapi: error decoding response body: %v", err))
+	}
+	return nil
+}
+
+// responseError returns the error the server replied to a request with, typed
+// by its code so that callers can tell errors apart, or with the body of the
+// reply if it isn't a bacerrors.ErrorResponse.
+func responseError(res *http.Response, doing string) error {
+	body, _ := io.ReadAll(res.Body)
+	var serverError *bacerrors.ErrorResponse
+	if err := model.JSONUnmarshalWithMax(body, &serverError); err == nil && serverError != nil && serverError.Code != "" {
+		return serverError.ToError()
+	}
+	return fmt.Errorf("publicapi: error %s (%d): %s", doing, res.StatusCode, strings.TrimSpace(string(body)))
+}
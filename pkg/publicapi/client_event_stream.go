@@ -0,0 +1,124 @@
+package publicapi
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/system"
+	"github.com/rs/zerolog/log"
+)
+
+// The largest event the client reads from an event stream.
+const maxStreamedEventSize = 10 * 1024 * 1024
+
+// StreamEventsHandler is called with each event of a job, and returns true
+// once it doesn't need any more events.
+type StreamEventsHandler func(event model.JobEvent) (bool, error)
+
+// StreamEvents calls handler with the events of a job from the event stream,
+// starting with the events that have already happened, until the handler is
+// done, returns an error, or ctx is done. The stream is reconnected to when
+// the server ends it, resuming from the last event received, so the handler
+// may be called more than once with the same event.
+func (apiClient *APIClient) StreamEvents(ctx context.Context, jobID string, handler StreamEventsHandler) error {
+	ctx, span := system.GetTracer().Start(ctx, "pkg/publicapi.StreamEvents")
+	defer span.End()
+
+	if jobID == "" {
+		return fmt.Errorf("jobID must be non-empty in a StreamEvents call")
+	}
+
+	// streams are held open for longer than the client's timeout
+	streamClient := *apiClient.client
+	streamClient.Timeout = 0
+
+	var lastEventID string
+	for {
+		done, err := apiClient.streamEvents(ctx, &streamClient, jobID, &lastEventID, handler)
+		if done || err != nil {
+			return err
+		}
+		log.Ctx(ctx).Debug().Msgf("event stream for job %s ended, reconnecting", jobID)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(eventStreamRetryMillis * time.Millisecond):
+		}
+	}
+}
+
+// streamEvents reads the event stream until it ends, and returns whether the
+// handler is done.
+func (apiClient *APIClient) streamEvents(
+	ctx context.Context,
+	client *http.Client,
+	jobID string,
+	lastEventID *string,
+	handler StreamEventsHandler,
+) (bool, error) {
+	addr := fmt.Sprintf("%s/event_stream?job_id=%s", apiClient.BaseURI, url.QueryEscape(jobID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, addr, nil)
+	if err != nil {
+		return false, err
+	}
+	if *lastEventID != "" {
+		req.Header.Set("Last-Event-ID", *lastEventID)
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+		// the server may be restarting
+		log.Ctx(ctx).Debug().Err(err).Msgf("error connecting to the event stream of job %s", jobID)
+		return false, nil
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(res.Body)
+		return false, fmt.Errorf("publicapi: error streaming events: %s", strings.TrimSpace(string(body)))
+	}
+
+	scanner := bufio.NewScanner(res.Body)
+	scanner.Buffer(nil, maxStreamedEventSize)
+	var id, data string
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if data == "" {
+				continue
+			}
+			var event model.JobEvent
+			if err = model.JSONUnmarshalWithMax([]byte(data), &event); err != nil {
+				return false, fmt.Errorf("publicapi: error decoding streamed event: %w", err)
+			}
+			if id != "" {
+				*lastEventID = id
+			}
+			id, data = "", ""
+
+			done, handlerErr := handler(event)
+			if done || handlerErr != nil {
+				return done, handlerErr
+			}
+		case strings.HasPrefix(line, "id: "):
+			id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+	if ctx.Err() != nil {
+		return false, ctx.Err()
+	}
+	return false, nil
+}
@@ -0,0 +1,70 @@
+package publicapi
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+)
+
+// clientKeys are the keys clients have rotated out, as learned from the
+// rotations sent with their requests, the key each was rotated to and until
+// when each is accepted. A client holding only a retired key sends no
+// rotation past it, so the requests it signs with the key once it has expired
+// are only refused if a later request has told of the rotation.
+type clientKeys struct {
+	mutex   sync.Mutex
+	retired map[string]map[string]retiredClientKey
+}
+
+// retiredClientKey is a key a client has rotated out.
+type retiredClientKey struct {
+	// the key it was rotated to
+	next string
+	// the earliest expiry any rotation of it was sent with, so that whoever
+	// still holds the key can't extend it by signing the rotation again
+	expires time.Time
+}
+
+func newClientKeys() *clientKeys {
+	return &clientKeys{
+		retired: map[string]map[string]retiredClientKey{},
+	}
+}
+
+// check records the keys the rotations retire, and returns an error if the
+// rotations lead a retired key anywhere but to the key it was rotated to, or
+// if the public key of the client is one of those it has retired, and has
+// expired.
+func (k *clientKeys) check(clientID, publicKey string, rotations []model.ClientKeyRotation) error {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	retired, ok := k.retired[clientID]
+	if !ok {
+		if len(rotations) == 0 {
+			return nil
+		}
+		retired = map[string]retiredClientKey{}
+		k.retired[clientID] = retired
+	}
+	for _, rotation := range rotations {
+		if key, ok := retired[rotation.PreviousPublicKey]; ok && key.next != rotation.PublicKey {
+			return fmt.Errorf("client's key was already rotated to another key")
+		}
+	}
+	for _, rotation := range rotations {
+		key, ok := retired[rotation.PreviousPublicKey]
+		if !ok || rotation.Expires.Before(key.expires) {
+			retired[rotation.PreviousPublicKey] = retiredClientKey{
+				next:    rotation.PublicKey,
+				expires: rotation.Expires,
+			}
+		}
+	}
+
+	if key, ok := retired[publicKey]; ok && time.Now().After(key.expires) {
+		return fmt.Errorf("client's public key was rotated out and expired at %s", key.expires)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+//go:build unit || !integration
+
+package publicapi
+
+import (
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientKeys(t *testing.T) {
+	keys := newClientKeys()
+	require.NoError(t, keys.check("client", "first", nil))
+
+	// the first key is accepted until it expires, whether or not the request
+	// says it was rotated out
+	rotations := []model.ClientKeyRotation{
+		{PreviousPublicKey: "first", PublicKey: "second", Expires: time.Now().Add(time.Hour)},
+	}
+	require.NoError(t, keys.check("client", "second", rotations))
+	require.NoError(t, keys.check("client", "first", nil))
+
+	rotations = append(rotations, model.ClientKeyRotation{
+		PreviousPublicKey: "second", PublicKey: "third", Expires: time.Now().Add(-time.Minute),
+	})
+	require.NoError(t, keys.check("client", "third", rotations))
+	require.ErrorContains(t, keys.check("client", "second", rotations[:1]), "rotated out")
+	require.NoError(t, keys.check("client", "first", nil))
+
+	// keys of other clients are unaffected
+	require.NoError(t, keys.check("other", "second", nil))
+}
+
+func TestClientKeysRefuseRetiredKeyResigningItsRotation(t *testing.T) {
+	keys := newClientKeys()
+	rotations := []model.ClientKeyRotation{
+		{PreviousPublicKey: "first", PublicKey: "second", Expires: time.Now().Add(-time.Minute)},
+	}
+	require.NoError(t, keys.check("client", "second", rotations))
+
+	// whoever still holds the first key signs its rotation again, with a
+	// later expiry, but the earliest expiry stands
+	extended := []model.ClientKeyRotation{
+		{PreviousPublicKey: "first", PublicKey: "second", Expires: time.Now().Add(time.Hour)},
+	}
+	require.NoError(t, keys.check("client", "second", extended))
+	require.ErrorContains(t, keys.check("client", "first", nil), "rotated out")
+
+	// or rotates it to a key of their own
+	forked := []model.ClientKeyRotation{
+		{PreviousPublicKey: "first", PublicKey: "stolen", Expires: time.Now().Add(time.Hour)},
+	}
+	require.ErrorContains(t, keys.check("client", "stolen", forked), "another key")
+	require.ErrorContains(t, keys.check("client", "first", nil), "rotated out")
+}
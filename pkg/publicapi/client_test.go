@@ -3,12 +3,30 @@
 package publicapi
 
 import (
+	"bytes"
 	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/filecoin-project/bacalhau/pkg/bacerrors"
+	"github.com/filecoin-project/bacalhau/pkg/ipfs"
+	"github.com/filecoin-project/bacalhau/pkg/localdb"
+	"github.com/filecoin-project/bacalhau/pkg/localdb/inmemory"
 	"github.com/filecoin-project/bacalhau/pkg/logger"
 	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/requesternode"
 	"github.com/filecoin-project/bacalhau/pkg/system"
+	"github.com/google/uuid"
+	"github.com/phayes/freeport"
+	"github.com/rs/zerolog/log"
 	"github.com/stretchr/testify/require"
 )
 
@@ -37,3 +55,751 @@ func TestGet(t *testing.T) {
 	require.True(t, ok)
 	require.Equal(t, job2.ID, j.ID)
 }
+
+func TestSubmitBatch(t *testing.T) {
+	logger.ConfigureTestLogging(t)
+
+	c, cm := SetupRequesterNodeForTests(t, false)
+	defer cm.Cleanup()
+	ctx := context.Background()
+
+	invalidJob := MakeNoopJob()
+	invalidJob.Deal.Concurrency = -1
+	jobs := []*model.Job{MakeNoopJob(), invalidJob, MakeNoopJob()}
+
+	for _, atomic := range []bool{false, true} {
+		t.Run(fmt.Sprintf("atomic=%t", atomic), func(t *testing.T) {
+			jobGroup, results, err := c.SubmitBatch(ctx, jobs, atomic)
+			require.NoError(t, err)
+			require.NotEmpty(t, jobGroup)
+			require.Len(t, results, len(jobs))
+
+			require.Nil(t, results[1].Job)
+			require.Contains(t, results[1].Error, "concurrency")
+			for _, i := range []int{0, 2} {
+				if atomic {
+					require.Nil(t, results[i].Job)
+					require.NotEmpty(t, results[i].Error)
+				} else {
+					require.Empty(t, results[i].Error)
+					require.Equal(t, jobGroup, results[i].Job.Spec.JobGroup)
+					_, ok, err := c.Get(ctx, results[i].Job.ID)
+					require.NoError(t, err)
+					require.True(t, ok)
+				}
+			}
+		})
+	}
+}
+
+func TestSubmitBatchAtomicSubmissionFails(t *testing.T) {
+	logger.ConfigureTestLogging(t)
+
+	c, cm := SetupRequesterNodeForTests(t, true)
+	defer cm.Cleanup()
+	ctx := context.Background()
+
+	// the job is valid, but has no shards to run so it fails to submit
+	unshardable := MakeNoopJob()
+	unshardable.Spec.Sharding.GlobPattern = "/missing/*"
+	jobs := []*model.Job{MakeNoopJob(), MakeNoopJob(), unshardable, MakeNoopJob()}
+
+	jobGroup, results, err := c.SubmitBatch(ctx, jobs, true)
+	require.NoError(t, err)
+	require.Len(t, results, len(jobs))
+	for _, result := range results {
+		require.Nil(t, result.Job)
+	}
+	require.Contains(t, results[0].Error, "cancelled as job 2 of the batch failed to submit")
+	require.Contains(t, results[1].Error, "cancelled as job 2 of the batch failed to submit")
+	require.Contains(t, results[2].Error, "no sharding atoms")
+	require.Equal(t, "not submitted as job 2 of the batch failed to submit", results[3].Error)
+
+	// the jobs submitted before the failure don't run
+	waitCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	responses, err := c.WaitJobGroup(waitCtx, jobGroup)
+	require.NoError(t, err)
+	require.Len(t, responses, 2)
+	for _, response := range responses {
+		require.True(t, response.Finished)
+		require.Equal(t, model.JobStateError.String(), response.State)
+	}
+}
+
+func TestSubmitBatchTooLarge(t *testing.T) {
+	logger.ConfigureTestLogging(t)
+
+	c, cm := SetupRequesterNodeForTests(t, false)
+	defer cm.Cleanup()
+
+	jobs := make([]*model.Job, MaxJobsPerBatch+1)
+	for i := range jobs {
+		jobs[i] = MakeNoopJob()
+	}
+	_, _, err := c.SubmitBatch(context.Background(), jobs, false)
+	require.IsType(t, &bacerrors.BadRequest{}, err)
+
+	_, _, err = c.SubmitBatch(context.Background(), nil, false)
+	require.IsType(t, &bacerrors.BadRequest{}, err)
+}
+
+func TestSubmitIdempotent(t *testing.T) {
+	logger.ConfigureTestLogging(t)
+
+	c, cm := SetupRequesterNodeForTests(t, false)
+	defer cm.Cleanup()
+	ctx := context.Background()
+
+	first, err := c.SubmitIdempotent(ctx, MakeNoopJob(), nil, "key-1")
+	require.NoError(t, err)
+
+	// retrying with the same key returns the same job
+	retried, err := c.SubmitIdempotent(ctx, MakeNoopJob(), nil, "key-1")
+	require.NoError(t, err)
+	require.Equal(t, first.ID, retried.ID)
+
+	other, err := c.SubmitIdempotent(ctx, MakeNoopJob(), nil, "key-2")
+	require.NoError(t, err)
+	require.NotEqual(t, first.ID, other.ID)
+
+	jobs, err := c.List(ctx, "", 10, true, "created_at", false)
+	require.NoError(t, err)
+	require.Len(t, jobs, 2)
+}
+
+func TestWait(t *testing.T) {
+	logger.ConfigureTestLogging(t)
+
+	c, cm := SetupRequesterNodeForTests(t, false)
+	defer cm.Cleanup()
+	ctx := context.Background()
+
+	j, err := c.Submit(ctx, MakeNoopJob(), nil)
+	require.NoError(t, err)
+
+	// there are no compute nodes to run the job, so it never finishes
+	res, err := c.Wait(ctx, j.ID, 100*time.Millisecond)
+	require.NoError(t, err)
+	require.Equal(t, j.ID, res.JobID)
+	require.False(t, res.Finished)
+	require.Empty(t, res.Results)
+
+	_, err = c.WaitWithCallback(ctx, j.ID, "not a url")
+	require.Error(t, err)
+}
+
+func TestLongPollWait(t *testing.T) {
+	logger.ConfigureTestLogging(t)
+
+	c, cm := SetupRequesterNodeForTests(t, false)
+	defer cm.Cleanup()
+	ctx := context.Background()
+
+	j, err := c.Submit(ctx, MakeNoopJob(), nil)
+	require.NoError(t, err)
+
+	// there are no compute nodes to run the job, so it never finishes
+	res, err := c.LongPollWait(ctx, j.ID, 100*time.Millisecond)
+	require.NoError(t, err)
+	require.Equal(t, j.ID, res.JobID)
+	require.False(t, res.Finished)
+
+	_, err = c.LongPollWait(ctx, "not-a-job", 100*time.Millisecond)
+	require.IsType(t, &bacerrors.JobNotFound{}, err)
+
+	waitCtx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer cancel()
+	_, err = c.WaitUntilFinished(waitCtx, j.ID)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestParseJobWaitTimeout(t *testing.T) {
+	apiServer := &APIServer{Config: &APIServerConfig{WriteTimeout: 20 * time.Second}}
+	for _, tc := range []struct {
+		value    string
+		expected time.Duration
+	}{
+		{value: "", expected: 18 * time.Second},
+		{value: "5s", expected: 5 * time.Second},
+		{value: "1.5", expected: 1500 * time.Millisecond},
+		{value: "1h", expected: 18 * time.Second},
+	} {
+		timeout, err := apiServer.parseJobWaitTimeout(tc.value)
+		require.NoError(t, err, tc.value)
+		require.Equal(t, tc.expected, timeout, tc.value)
+	}
+
+	for _, value := range []string{"soon", "-5s", "0"} {
+		_, err := apiServer.parseJobWaitTimeout(value)
+		require.Error(t, err, value)
+	}
+
+	apiServer.Config.WriteTimeout = 0
+	timeout, err := apiServer.parseJobWaitTimeout("")
+	require.NoError(t, err)
+	require.Equal(t, MaxJobWaitTimeout, timeout)
+}
+
+func TestGetShards(t *testing.T) {
+	logger.ConfigureTestLogging(t)
+
+	c, cm := SetupRequesterNodeForTests(t, false)
+	defer cm.Cleanup()
+	ctx := context.Background()
+
+	j, err := c.Submit(ctx, MakeNoopJob(), nil)
+	require.NoError(t, err)
+
+	// there are no compute nodes to run the job, so no shard has started
+	shards, err := c.GetShards(ctx, j.ID, nil, false)
+	require.NoError(t, err)
+	require.Len(t, shards, 1)
+	require.Equal(t, 0, shards[0].ShardIndex)
+	require.False(t, shards[0].Finished)
+	require.Empty(t, shards[0].Results)
+
+	shards, err = c.GetShards(ctx, j.ID, nil, true)
+	require.NoError(t, err)
+	require.Empty(t, shards)
+
+	_, err = c.GetShards(ctx, j.ID, []int{1}, false)
+	require.Error(t, err)
+}
+
+func TestGetJobLogs(t *testing.T) {
+	logger.ConfigureTestLogging(t)
+
+	c, cm := SetupRequesterNodeForTests(t, false)
+	defer cm.Cleanup()
+	ctx := context.Background()
+
+	jobCtx := logger.ContextWithJobShardLogger(ctx, "job-with-logs", 1)
+	log.Ctx(jobCtx).Info().Msg("first")
+	log.Ctx(logger.ContextWithJobShardLogger(ctx, "other-job", 0)).Info().Msg("other")
+	log.Ctx(jobCtx).Info().Msg("second")
+
+	logs, err := c.GetJobLogs(ctx, "job-with-logs")
+	require.NoError(t, err)
+	require.Len(t, logs, 2)
+	for i, message := range []string{"first", "second"} {
+		var line struct {
+			JobID      string
+			ShardIndex int
+			Message    string `json:"message"`
+		}
+		require.NoError(t, json.Unmarshal(logs[i], &line))
+		require.Equal(t, "job-with-logs", line.JobID)
+		require.Equal(t, 1, line.ShardIndex)
+		require.Equal(t, message, line.Message)
+	}
+}
+
+func TestDownloadResults(t *testing.T) {
+	logger.ConfigureTestLogging(t)
+
+	port, err := freeport.GetFreePort()
+	require.NoError(t, err)
+	s, c, cm := setupRequesterNodeForTests(t, port, 0, DefaultAPIServerConfig, false)
+	defer cm.Cleanup()
+	ctx := context.Background()
+
+	var buf bytes.Buffer
+	err = c.DownloadResults(ctx, "some-job", &buf)
+	require.IsType(t, &bacerrors.NotImplemented{}, err)
+
+	// the IPFS client isn't used until there are results to fetch
+	s.IPFSClient = &ipfs.Client{}
+	err = c.DownloadResults(ctx, "some-job", &buf)
+	require.IsType(t, &bacerrors.JobNotFound{}, err)
+
+	j, err := c.Submit(ctx, MakeNoopJob(), nil)
+	require.NoError(t, err)
+	err = c.DownloadResults(ctx, j.ID, &buf)
+	require.ErrorContains(t, err, "has no results yet")
+	require.Zero(t, buf.Len())
+}
+
+func TestReadResultsFile(t *testing.T) {
+	logger.ConfigureTestLogging(t)
+
+	port, err := freeport.GetFreePort()
+	require.NoError(t, err)
+	s, c, cm := setupRequesterNodeForTests(t, port, 0, DefaultAPIServerConfig, false)
+	defer cm.Cleanup()
+	ctx := context.Background()
+
+	var buf bytes.Buffer
+	for _, filePath := range []string{"", "/", "../secret", "outputs/../stdout", "outputs//data.csv"} {
+		err = c.ReadResultsFile(ctx, "some-job", 0, filePath, 0, 0, &buf)
+		require.IsType(t, &bacerrors.BadRequest{}, err, filePath)
+	}
+	err = c.ReadResultsFile(ctx, "some-job", 0, "stdout", 0, 0, &buf)
+	require.IsType(t, &bacerrors.NotImplemented{}, err)
+
+	// the IPFS client isn't used until there are results to fetch
+	s.IPFSClient = &ipfs.Client{}
+	err = c.ReadResultsFile(ctx, "some-job", 0, "stdout", 0, 1024, &buf)
+	require.IsType(t, &bacerrors.JobNotFound{}, err)
+
+	j, err := c.Submit(ctx, MakeNoopJob(), nil)
+	require.NoError(t, err)
+	err = c.ReadResultsFile(ctx, j.ID, 0, "outputs/data.csv", 10, 1024, &buf)
+	require.IsType(t, &bacerrors.NotFound{}, err)
+	require.ErrorContains(t, err, "has no results yet")
+	require.Zero(t, buf.Len())
+}
+
+// echoDebugSessions is a shell that echoes its input.
+type echoDebugSessions struct{}
+
+func (echoDebugSessions) AttachShell(_ context.Context, _ string, _ int, terminal io.ReadWriter) error {
+	_, err := io.Copy(terminal, terminal)
+	return err
+}
+
+func TestDebugShard(t *testing.T) {
+	logger.ConfigureTestLogging(t)
+
+	port, err := freeport.GetFreePort()
+	require.NoError(t, err)
+	s, c, cm := setupRequesterNodeForTests(t, port, 0, DefaultAPIServerConfig, false)
+	defer cm.Cleanup()
+	ctx := context.Background()
+
+	var buf bytes.Buffer
+	err = c.DebugShard(ctx, "some-job", 0, strings.NewReader("echo hello\n"), &buf)
+	require.IsType(t, &bacerrors.NotImplemented{}, err)
+
+	s.DebugSessions = echoDebugSessions{}
+	err = c.DebugShard(ctx, "some-job", 0, strings.NewReader("echo hello\n"), &buf)
+	require.Error(t, err)
+	require.Zero(t, buf.Len())
+
+	j, err := c.Submit(ctx, MakeNoopJob(), nil)
+	require.NoError(t, err)
+	err = c.DebugShard(ctx, j.ID, 0, strings.NewReader("echo hello\n"), &buf)
+	require.NoError(t, err)
+	require.Equal(t, "echo hello\n", buf.String())
+
+	// nor more than once
+	data := model.JobDebugPayload{
+		ClientID:  system.GetClientID(),
+		JobID:     j.ID,
+		CreatedAt: time.Now(),
+		Nonce:     uuid.NewString(),
+	}
+	jsonData, err := model.JSONMarshalWithMax(data)
+	require.NoError(t, err)
+	signature, err := system.SignForClient(jsonData)
+	require.NoError(t, err)
+	debugReq := debugSessionRequest{Data: data, ClientSignature: signature, ClientPublicKey: system.GetClientPublicKey()}
+	require.NoError(t, s.verifyDebugSessionRequest(ctx, debugReq))
+	require.ErrorContains(t, s.verifyDebugSessionRequest(ctx, debugReq), "already used")
+
+	// signed requests can't be replayed later on
+	defer func(age time.Duration) { MaxDebugSessionRequestAge = age }(MaxDebugSessionRequestAge)
+	MaxDebugSessionRequestAge = 0
+	buf.Reset()
+	err = c.DebugShard(ctx, j.ID, 0, strings.NewReader("echo hello\n"), &buf)
+	require.ErrorContains(t, err, "expired")
+	require.Zero(t, buf.Len())
+}
+
+func TestResubmit(t *testing.T) {
+	logger.ConfigureTestLogging(t)
+
+	c, cm := SetupRequesterNodeForTests(t, false)
+	defer cm.Cleanup()
+	ctx := context.Background()
+
+	j := MakeNoopJob()
+	j.Spec.Inputs = []model.StorageSpec{{StorageSource: model.StorageSourceIPFS, CID: "QmOriginal", Path: "/inputs"}}
+	j.Deal.Deadline = time.Now().Add(time.Hour)
+	original, err := c.Submit(ctx, j, nil)
+	require.NoError(t, err)
+
+	resubmitted, err := c.Resubmit(ctx, original.ID, ResubmitOverrides{
+		ImageTag:  "22.04",
+		Resources: &model.ResourceUsageConfig{CPU: "1"},
+	})
+	require.NoError(t, err)
+	require.NotEqual(t, original.ID, resubmitted.ID)
+	require.Equal(t, original.ID, resubmitted.Spec.ResubmittedFrom)
+	require.Equal(t, "ubuntu:22.04", resubmitted.Spec.Docker.Image)
+	require.Equal(t, "1", resubmitted.Spec.Resources.CPU)
+	require.Equal(t, original.Spec.Inputs, resubmitted.Spec.Inputs)
+	require.Equal(t, original.Deal.Concurrency, resubmitted.Deal.Concurrency)
+	require.True(t, resubmitted.Deal.Deadline.IsZero())
+
+	_, err = c.Resubmit(ctx, "not-a-job", ResubmitOverrides{})
+	require.Error(t, err)
+}
+
+func TestWithImageTag(t *testing.T) {
+	for image, expected := range map[string]string{
+		"ubuntu":                           "ubuntu:v2",
+		"ubuntu:latest":                    "ubuntu:v2",
+		"localhost:5000/ubuntu":            "localhost:5000/ubuntu:v2",
+		"localhost:5000/ubuntu:20.04":      "localhost:5000/ubuntu:v2",
+		"ubuntu@sha256:0123456789abcdef":   "ubuntu:v2",
+		"ubuntu:20.04@sha256:0123456789ab": "ubuntu:v2",
+	} {
+		require.Equal(t, expected, withImageTag(image, "v2"), image)
+	}
+}
+
+func TestJobGroup(t *testing.T) {
+	logger.ConfigureTestLogging(t)
+
+	c, cm := SetupRequesterNodeForTests(t, true)
+	defer cm.Cleanup()
+	ctx := context.Background()
+
+	jobGroup, results, err := c.SubmitBatch(ctx, []*model.Job{MakeNoopJob(), MakeNoopJob()}, true)
+	require.NoError(t, err)
+	_, err = c.Submit(ctx, MakeNoopJob(), nil)
+	require.NoError(t, err)
+
+	jobs, err := c.ListJobGroup(ctx, jobGroup)
+	require.NoError(t, err)
+	require.Len(t, jobs, 2)
+	var jobIDs []string
+	for _, result := range results {
+		jobIDs = append(jobIDs, result.Job.ID)
+	}
+
+	// there are no compute nodes to run the jobs, so they only finish once
+	// they are cancelled
+	cancelled, err := c.CancelJobGroup(ctx, jobGroup, "testing")
+	require.NoError(t, err)
+	require.ElementsMatch(t, jobIDs, cancelled)
+
+	waitCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	responses, err := c.WaitJobGroup(waitCtx, jobGroup)
+	require.NoError(t, err)
+	require.Len(t, responses, 2)
+	for _, response := range responses {
+		require.True(t, response.Finished)
+		require.Equal(t, model.JobStateError.String(), response.State)
+	}
+
+	_, err = c.CancelJobGroup(ctx, "not-a-job-group", "testing")
+	require.Error(t, err)
+	_, err = c.Cancel(ctx, "", "testing")
+	require.Error(t, err)
+}
+
+func TestApproveJob(t *testing.T) {
+	logger.ConfigureTestLogging(t)
+
+	port, err := freeport.GetFreePort()
+	require.NoError(t, err)
+	require.NoError(t, system.InitConfigForTesting(t))
+	requesterConfig := requesternode.NewDefaultRequesterNodeConfig()
+	requesterConfig.ApprovalRequired = true
+	requesterConfig.Approvers = []string{system.GetClientID()}
+	server, c, cm := setupRequesterNodeWithConfigForTests(t, port, 0, DefaultAPIServerConfig, requesterConfig, true)
+	defer cm.Cleanup()
+	ctx := context.Background()
+
+	approved, err := c.Submit(ctx, MakeNoopJob(), nil)
+	require.NoError(t, err)
+	rejected, err := c.Submit(ctx, MakeNoopJob(), nil)
+	require.NoError(t, err)
+
+	pending, err := c.PendingApprovals(ctx)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{approved.ID, rejected.ID}, pending)
+	events, err := c.GetEvents(ctx, approved.ID)
+	require.NoError(t, err)
+	require.Empty(t, events, "jobs are not announced until they are approved")
+
+	require.NoError(t, c.Approve(ctx, approved.ID))
+	require.NoError(t, c.Reject(ctx, rejected.ID, "reads the wrong dataset"))
+	require.ErrorContains(t, c.Approve(ctx, approved.ID), "not waiting for approval")
+	require.ErrorContains(t, c.Approve(ctx, rejected.ID), "reads the wrong dataset")
+
+	pending, err = c.PendingApprovals(ctx)
+	require.NoError(t, err)
+	require.Empty(t, pending)
+	require.Eventually(t, func() bool {
+		events, err = c.GetEvents(ctx, approved.ID)
+		return err == nil && len(events) > 0
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Eventually(t, func() bool {
+		events, err = c.GetEvents(ctx, rejected.ID)
+		return err == nil && len(events) > 0
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, model.JobEventError, events[0].EventName)
+	require.Contains(t, events[0].Status, "reads the wrong dataset")
+	state, err := c.GetJobState(ctx, rejected.ID)
+	require.NoError(t, err)
+	require.Equal(t, model.JobStateError, state.Nodes[server.Requester.ID].Shards[0].State)
+}
+
+func TestApproveJobNotApprover(t *testing.T) {
+	logger.ConfigureTestLogging(t)
+
+	port, err := freeport.GetFreePort()
+	require.NoError(t, err)
+	require.NoError(t, system.InitConfigForTesting(t))
+	requesterConfig := requesternode.NewDefaultRequesterNodeConfig()
+	requesterConfig.ApprovalRequired = true
+	_, c, cm := setupRequesterNodeWithConfigForTests(t, port, 0, DefaultAPIServerConfig, requesterConfig, true)
+	defer cm.Cleanup()
+	ctx := context.Background()
+
+	j, err := c.Submit(ctx, MakeNoopJob(), nil)
+	require.NoError(t, err)
+	require.ErrorContains(t, c.Approve(ctx, j.ID), "is not an approver")
+
+	pending, err := c.PendingApprovals(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []string{j.ID}, pending)
+}
+
+func TestModerate(t *testing.T) {
+	logger.ConfigureTestLogging(t)
+
+	port, err := freeport.GetFreePort()
+	require.NoError(t, err)
+	require.NoError(t, system.InitConfigForTesting(t))
+	requesterConfig := requesternode.NewDefaultRequesterNodeConfig()
+	requesterConfig.Moderators = []string{system.GetClientID()}
+	_, c, cm := setupRequesterNodeWithConfigForTests(t, port, 0, DefaultAPIServerConfig, requesterConfig, true)
+	defer cm.Cleanup()
+	ctx := context.Background()
+
+	require.NoError(t, c.Flag(ctx, "miner", "", "cryptomining"))
+	require.NoError(t, c.Flag(ctx, "", "job", "port scanning"))
+	require.NoError(t, c.Flag(ctx, "spammer", "", ""))
+	require.ErrorContains(t, c.Flag(ctx, "client", "job", ""), "exactly one")
+	require.ErrorContains(t, c.Flag(ctx, "", "", ""), "exactly one")
+
+	denylist, err := c.Denylist(ctx)
+	require.NoError(t, err)
+	require.Len(t, denylist.Clients, 2)
+	require.Equal(t, "miner", denylist.Clients[0].ID)
+	require.Equal(t, "cryptomining", denylist.Clients[0].Reason)
+	require.Equal(t, system.GetClientID(), denylist.Clients[0].FlaggedBy)
+	require.Len(t, denylist.Jobs, 1)
+	require.Equal(t, "job", denylist.Jobs[0].ID)
+
+	require.NoError(t, c.Unflag(ctx, "miner", ""))
+	denylist, err = c.Denylist(ctx)
+	require.NoError(t, err)
+	require.Len(t, denylist.Clients, 1)
+	require.Equal(t, "spammer", denylist.Clients[0].ID)
+
+	// compute nodes poll the denylist, and get a 304 while it hasn't changed
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.BaseURI+"/denylist", nil)
+	require.NoError(t, err)
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	req, err = http.NewRequestWithContext(ctx, http.MethodGet, c.BaseURI+"/denylist", nil)
+	require.NoError(t, err)
+	req.Header.Set("If-None-Match", res.Header.Get("ETag"))
+	res, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+	require.Equal(t, http.StatusNotModified, res.StatusCode)
+}
+
+func TestModerateNotModerator(t *testing.T) {
+	logger.ConfigureTestLogging(t)
+
+	port, err := freeport.GetFreePort()
+	require.NoError(t, err)
+	require.NoError(t, system.InitConfigForTesting(t))
+	_, c, cm := setupRequesterNodeForTests(t, port, 0, DefaultAPIServerConfig, false)
+	defer cm.Cleanup()
+	ctx := context.Background()
+
+	err = c.Flag(ctx, "miner", "", "cryptomining")
+	require.IsType(t, &bacerrors.Forbidden{}, err)
+	require.ErrorContains(t, err, "is not a moderator")
+	denylist, err := c.Denylist(ctx)
+	require.NoError(t, err)
+	require.Empty(t, denylist.Clients)
+}
+
+func TestModerateReplay(t *testing.T) {
+	logger.ConfigureTestLogging(t)
+
+	port, err := freeport.GetFreePort()
+	require.NoError(t, err)
+	require.NoError(t, system.InitConfigForTesting(t))
+	requesterConfig := requesternode.NewDefaultRequesterNodeConfig()
+	requesterConfig.Moderators = []string{system.GetClientID()}
+	_, c, cm := setupRequesterNodeWithConfigForTests(t, port, 0, DefaultAPIServerConfig, requesterConfig, true)
+	defer cm.Cleanup()
+	ctx := context.Background()
+
+	signed := func(data model.ModerationPayload) moderateRequest {
+		jsonData, err := model.JSONMarshalWithMax(data)
+		require.NoError(t, err)
+		signature, err := system.SignForClient(jsonData)
+		require.NoError(t, err)
+		return moderateRequest{Data: data, ClientSignature: signature, ClientPublicKey: system.GetClientPublicKey()}
+	}
+
+	flag := signed(model.ModerationPayload{
+		ClientID:       system.GetClientID(),
+		TargetClientID: "miner",
+		Timestamp:      time.Now().UTC(),
+		Nonce:          uuid.NewString(),
+	})
+	require.NoError(t, c.post(ctx, "moderate", flag, &struct{}{}))
+	require.NoError(t, c.Unflag(ctx, "miner", ""))
+
+	// the flag can't be sent again to undo the unflag
+	err = c.post(ctx, "moderate", flag, &struct{}{})
+	require.IsType(t, &bacerrors.BadRequest{}, err)
+	require.ErrorContains(t, err, "already used")
+
+	stale := signed(model.ModerationPayload{
+		ClientID:       system.GetClientID(),
+		TargetClientID: "miner",
+		Timestamp:      time.Now().UTC().Add(-time.Hour),
+		Nonce:          uuid.NewString(),
+	})
+	require.ErrorContains(t, c.post(ctx, "moderate", stale, &struct{}{}), "more than")
+
+	denylist, err := c.Denylist(ctx)
+	require.NoError(t, err)
+	require.Empty(t, denylist.Clients)
+}
+
+func TestRotatedClientKey(t *testing.T) {
+	logger.ConfigureTestLogging(t)
+
+	c, cm := SetupRequesterNodeForTests(t, false)
+	defer cm.Cleanup()
+	ctx := context.Background()
+
+	j, err := c.Submit(ctx, MakeNoopJob(), nil)
+	require.NoError(t, err)
+	clientID := system.GetClientID()
+	require.NoError(t, system.RotateUserIDKey(time.Hour))
+
+	// the client keeps managing the jobs it submitted with its old key
+	cancelled, err := c.Cancel(ctx, j.ID, "rotated")
+	require.NoError(t, err)
+	require.Equal(t, []string{j.ID}, cancelled)
+
+	info, err := c.ClientKey(ctx)
+	require.NoError(t, err)
+	require.Equal(t, clientID, info.ClientID)
+	require.Equal(t, system.GetClientPublicKey(), info.PublicKey)
+}
+
+func TestChallengeNotOptimistic(t *testing.T) {
+	logger.ConfigureTestLogging(t)
+
+	c, cm := SetupRequesterNodeForTests(t, false)
+	defer cm.Cleanup()
+	ctx := context.Background()
+
+	j, err := c.Submit(ctx, MakeNoopJob(), nil)
+	require.NoError(t, err)
+	_, err = c.Challenge(ctx, j.ID, 0, "node", "wrong results")
+	require.ErrorContains(t, err, "can't be challenged")
+
+	events, err := c.GetEvents(ctx, j.ID)
+	require.NoError(t, err)
+	for _, event := range events {
+		require.NotEqual(t, model.JobEventChallenged, event.EventName)
+	}
+}
+
+func TestSubmitInvalidSpec(t *testing.T) {
+	logger.ConfigureTestLogging(t)
+
+	c, cm := SetupRequesterNodeForTests(t, false)
+	defer cm.Cleanup()
+
+	j := MakeNoopJob()
+	j.Deal.Confidence = -1
+	_, err := c.Submit(context.Background(), j, nil)
+	require.IsType(t, &bacerrors.SpecInvalid{}, err)
+	require.ErrorContains(t, err, "confidence must be >= 0")
+}
+
+func TestSearch(t *testing.T) {
+	logger.ConfigureTestLogging(t)
+
+	c, cm := SetupRequesterNodeForTests(t, false)
+	defer cm.Cleanup()
+
+	ctx := context.Background()
+	j := MakeNoopJob()
+	j.Spec.Annotations = []string{"team=ml"}
+	submitted, err := c.Submit(ctx, j, nil)
+	require.NoError(t, err)
+	_, err = c.Submit(ctx, MakeNoopJob(), nil)
+	require.NoError(t, err)
+
+	jobs, err := c.Search(ctx, JobSearch{Labels: []string{"team=ml"}}, 10, "created_at", false)
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	require.Equal(t, submitted.ID, jobs[0].ID)
+
+	_, err = c.Search(ctx, JobSearch{}, 10, "created_at", false)
+	require.IsType(t, &bacerrors.BadRequest{}, err)
+}
+
+func TestExport(t *testing.T) {
+	logger.ConfigureTestLogging(t)
+
+	c, cm := SetupRequesterNodeForTests(t, false)
+	defer cm.Cleanup()
+
+	ctx := context.Background()
+	submitted, err := c.Submit(ctx, MakeNoopJob(), nil)
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	err = c.Export(ctx, localdb.ExportQuery{Kind: localdb.ExportJobs, Format: localdb.ExportCSV}, &out)
+	require.NoError(t, err)
+	records, err := csv.NewReader(&out).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	require.Equal(t, "job_id", records[0][0])
+	require.Equal(t, submitted.ID, records[1][0])
+
+	out.Reset()
+	err = c.Export(ctx, localdb.ExportQuery{Kind: localdb.ExportEvents, Format: localdb.ExportParquet}, &out)
+	require.NoError(t, err)
+	require.Equal(t, "PAR1", out.String()[:4])
+
+	err = c.Export(ctx, localdb.ExportQuery{Kind: "nodes", Format: localdb.ExportCSV}, io.Discard)
+	require.IsType(t, &bacerrors.BadRequest{}, err)
+}
+
+// stateErrorDB is a local db whose job states can't be read.
+type stateErrorDB struct {
+	localdb.LocalDB
+}
+
+func (stateErrorDB) GetJobState(context.Context, string) (model.JobState, error) {
+	return model.JobState{}, errors.New("state unavailable")
+}
+
+func TestExportFailsBeforeWritingRows(t *testing.T) {
+	logger.ConfigureTestLogging(t)
+
+	db, err := inmemory.NewInMemoryDatastore()
+	require.NoError(t, err)
+	require.NoError(t, db.AddJob(context.Background(), &model.Job{ID: "job", CreatedAt: time.Now()}))
+	apiServer := &APIServer{localdb: stateErrorDB{db}}
+
+	res := httptest.NewRecorder()
+	apiServer.export(res, httptest.NewRequest(http.MethodGet, "/export?kind=jobs", nil))
+	require.Equal(t, http.StatusInternalServerError, res.Code)
+	require.Empty(t, res.Header().Get("Content-Disposition"))
+	require.Contains(t, res.Body.String(), "state unavailable")
+}
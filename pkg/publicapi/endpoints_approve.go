@@ -0,0 +1,93 @@
+package publicapi
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/filecoin-project/bacalhau/pkg/bacerrors"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/publicapi/handlerwrapper"
+	"github.com/filecoin-project/bacalhau/pkg/system"
+	"github.com/rs/zerolog/log"
+)
+
+type approveRequest struct {
+	// The job to approve or reject:
+	Data model.JobApprovalPayload `json:"data" validate:"required"`
+
+	// A base64-encoded signature of the data, signed by the client:
+	ClientSignature string `json:"signature" validate:"required"`
+
+	// The base64-encoded public key of the client:
+	ClientPublicKey string `json:"client_public_key" validate:"required"`
+
+	// The rotations of the client's key, from the key its ID derives from to
+	// the public key, if it has rotated its key:
+	ClientKeyRotations []model.ClientKeyRotation `json:"client_key_rotations,omitempty"`
+}
+
+type approvalsResponse struct {
+	// The IDs of the jobs waiting for approval:
+	JobIDs []string `json:"job_ids"`
+}
+
+// approve godoc
+// @ID                   pkg/apiServer.approve
+// @Summary              Approves or rejects a job held until it is approved.
+// @Description.markdown endpoints_approve
+// @Tags                 Job
+// @Accept               json
+// @Param                approveRequest body approveRequest true " "
+// @Success              200
+// @Failure              400 {object} bacerrors.ErrorResponse
+// @Router               /approve [post]
+func (apiServer *APIServer) approve(res http.ResponseWriter, req *http.Request) {
+	ctx, span := system.GetSpanFromRequest(req, "pkg/apiServer.approve")
+	defer span.End()
+
+	var approveReq approveRequest
+	if err := json.NewDecoder(req.Body).Decode(&approveReq); err != nil {
+		log.Ctx(ctx).Debug().Msgf("====> Decode approveReq error: %s", err)
+		httpError(res, req, bacerrors.NewBadRequest(err), http.StatusBadRequest)
+		return
+	}
+	data := approveReq.Data
+	res.Header().Set(handlerwrapper.HTTPHeaderClientID, data.ClientID)
+	res.Header().Set(handlerwrapper.HTTPHeaderJobID, data.JobID)
+
+	if err := apiServer.verifySignedPayload(data.ClientID, data, approveReq.ClientSignature, approveReq.ClientPublicKey, approveReq.ClientKeyRotations); err != nil {
+		log.Ctx(ctx).Debug().Msgf("====> VerifyApproveRequest error: %s", err)
+		httpError(res, req, bacerrors.NewBadRequest(err), http.StatusBadRequest)
+		return
+	}
+
+	err := apiServer.Requester.ApproveJob(ctx, data.ClientID, data.JobID, data.Approved, data.Reason)
+	if err != nil {
+		httpError(res, req, err, http.StatusBadRequest)
+		return
+	}
+	res.WriteHeader(http.StatusOK)
+}
+
+// approvals godoc
+// @ID                   pkg/apiServer.approvals
+// @Summary              Returns the IDs of the jobs held until they are approved.
+// @Description.markdown endpoints_approve
+// @Tags                 Job
+// @Produce              json
+// @Success              200 {object} approvalsResponse
+// @Failure              500 {object} bacerrors.ErrorResponse
+// @Router               /approvals [post]
+func (apiServer *APIServer) approvals(res http.ResponseWriter, req *http.Request) {
+	_, span := system.GetSpanFromRequest(req, "pkg/apiServer.approvals")
+	defer span.End()
+
+	res.WriteHeader(http.StatusOK)
+	err := json.NewEncoder(res).Encode(approvalsResponse{
+		JobIDs: apiServer.Requester.PendingApprovals(),
+	})
+	if err != nil {
+		httpError(res, req, err, http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,121 @@
+package publicapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/filecoin-project/bacalhau/pkg/bacerrors"
+	"github.com/filecoin-project/bacalhau/pkg/localdb"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/publicapi/handlerwrapper"
+	"github.com/filecoin-project/bacalhau/pkg/system"
+	"github.com/rs/zerolog/log"
+)
+
+type cancelRequest struct {
+	// The job or job group to cancel:
+	Data model.JobCancelPayload `json:"data" validate:"required"`
+
+	// A base64-encoded signature of the data, signed by the client:
+	ClientSignature string `json:"signature" validate:"required"`
+
+	// The base64-encoded public key of the client:
+	ClientPublicKey string `json:"client_public_key" validate:"required"`
+
+	// The rotations of the client's key, from the key its ID derives from to
+	// the public key, if it has rotated its key:
+	ClientKeyRotations []model.ClientKeyRotation `json:"client_key_rotations,omitempty"`
+}
+
+type cancelResponse struct {
+	// The IDs of the jobs that were cancelled:
+	JobIDs []string `json:"job_ids"`
+}
+
+// cancel godoc
+// @ID                   pkg/apiServer.cancel
+// @Summary              Cancels a job, or all the jobs of a job group.
+// @Description.markdown endpoints_cancel
+// @Tags                 Job
+// @Accept               json
+// @Produce              json
+// @Param                cancelRequest body     cancelRequest true " "
+// @Success              200           {object} cancelResponse
+// @Failure              400           {object} bacerrors.ErrorResponse
+// @Failure              500           {object} bacerrors.ErrorResponse
+// @Router               /cancel [post]
+func (apiServer *APIServer) cancel(res http.ResponseWriter, req *http.Request) {
+	ctx, span := system.GetSpanFromRequest(req, "pkg/apiServer.cancel")
+	defer span.End()
+
+	var cancelReq cancelRequest
+	if err := json.NewDecoder(req.Body).Decode(&cancelReq); err != nil {
+		log.Ctx(ctx).Debug().Msgf("====> Decode cancelReq error: %s", err)
+		httpError(res, req, bacerrors.NewBadRequest(err), http.StatusBadRequest)
+		return
+	}
+	data := cancelReq.Data
+	res.Header().Set(handlerwrapper.HTTPHeaderClientID, data.ClientID)
+	res.Header().Set(handlerwrapper.HTTPHeaderJobID, data.JobID)
+
+	if err := apiServer.verifySignedPayload(data.ClientID, data, cancelReq.ClientSignature, cancelReq.ClientPublicKey, cancelReq.ClientKeyRotations); err != nil {
+		log.Ctx(ctx).Debug().Msgf("====> VerifyCancelRequest error: %s", err)
+		httpError(res, req, bacerrors.NewBadRequest(err), http.StatusBadRequest)
+		return
+	}
+
+	jobIDs, err := apiServer.getJobsToCancel(ctx, data)
+	if err != nil {
+		httpError(res, req, err, http.StatusBadRequest)
+		return
+	}
+	for _, jobID := range jobIDs {
+		if err = apiServer.Requester.CancelJob(ctx, jobID, data.Reason); err != nil {
+			httpError(res, req, err, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	res.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(res).Encode(cancelResponse{
+		JobIDs: jobIDs,
+	})
+	if err != nil {
+		httpError(res, req, err, http.StatusInternalServerError)
+		return
+	}
+}
+
+// getJobsToCancel returns the IDs of the jobs a cancel request is for, which
+// must all have been created by the client.
+func (apiServer *APIServer) getJobsToCancel(ctx context.Context, data model.JobCancelPayload) ([]string, error) {
+	if (data.JobID == "") == (data.JobGroup == "") {
+		return nil, errors.New("one of a job ID or a job group must be given")
+	}
+
+	if data.JobID != "" {
+		if err := apiServer.checkJobOwner(ctx, data.ClientID, data.JobID); err != nil {
+			return nil, err
+		}
+		return []string{data.JobID}, nil
+	}
+
+	jobs, err := apiServer.localdb.GetJobs(ctx, localdb.JobQuery{
+		ClientID: data.ClientID,
+		JobGroup: data.JobGroup,
+		Limit:    MaxJobsPerBatch,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(jobs) == 0 {
+		return nil, errors.New("the client has no jobs in the job group")
+	}
+	jobIDs := make([]string, 0, len(jobs))
+	for _, j := range jobs {
+		jobIDs = append(jobIDs, j.ID)
+	}
+	return jobIDs, nil
+}
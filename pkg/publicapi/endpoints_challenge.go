@@ -0,0 +1,79 @@
+package publicapi
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/filecoin-project/bacalhau/pkg/bacerrors"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/publicapi/handlerwrapper"
+	"github.com/filecoin-project/bacalhau/pkg/system"
+	"github.com/rs/zerolog/log"
+)
+
+type challengeRequest struct {
+	// The results to challenge:
+	Data model.JobChallengePayload `json:"data" validate:"required"`
+
+	// A base64-encoded signature of the data, signed by the client:
+	ClientSignature string `json:"signature" validate:"required"`
+
+	// The base64-encoded public key of the client:
+	ClientPublicKey string `json:"client_public_key" validate:"required"`
+
+	// The rotations of the client's key, from the key its ID derives from to
+	// the public key, if it has rotated its key:
+	ClientKeyRotations []model.ClientKeyRotation `json:"client_key_rotations,omitempty"`
+}
+
+type challengeResponse struct {
+	// The job that re-executes the shard of the challenged results:
+	Job *model.Job `json:"job"`
+}
+
+// challenge godoc
+// @ID                   pkg/apiServer.challenge
+// @Summary              Challenges the results a node published for a shard of a job verified optimistically.
+// @Description.markdown endpoints_challenge
+// @Tags                 Job
+// @Accept               json
+// @Produce              json
+// @Param                challengeRequest body challengeRequest true " "
+// @Success              200 {object} challengeResponse
+// @Failure              400 {object} bacerrors.ErrorResponse
+// @Router               /challenge [post]
+func (apiServer *APIServer) challenge(res http.ResponseWriter, req *http.Request) {
+	ctx, span := system.GetSpanFromRequest(req, "pkg/apiServer.challenge")
+	defer span.End()
+
+	var challengeReq challengeRequest
+	if err := json.NewDecoder(req.Body).Decode(&challengeReq); err != nil {
+		log.Ctx(ctx).Debug().Msgf("====> Decode challengeReq error: %s", err)
+		httpError(res, req, bacerrors.NewBadRequest(err), http.StatusBadRequest)
+		return
+	}
+	data := challengeReq.Data
+	res.Header().Set(handlerwrapper.HTTPHeaderClientID, data.ClientID)
+	res.Header().Set(handlerwrapper.HTTPHeaderJobID, data.JobID)
+
+	if err := apiServer.verifySignedPayload(data.ClientID, data, challengeReq.ClientSignature, challengeReq.ClientPublicKey, challengeReq.ClientKeyRotations); err != nil {
+		log.Ctx(ctx).Debug().Msgf("====> VerifyChallengeRequest error: %s", err)
+		httpError(res, req, bacerrors.NewBadRequest(err), http.StatusBadRequest)
+		return
+	}
+
+	j, err := apiServer.Requester.ChallengeResult(ctx, data)
+	if err != nil {
+		httpError(res, req, err, http.StatusBadRequest)
+		return
+	}
+
+	res.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(res).Encode(challengeResponse{
+		Job: j,
+	})
+	if err != nil {
+		httpError(res, req, err, http.StatusInternalServerError)
+		return
+	}
+}
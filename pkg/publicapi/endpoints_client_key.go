@@ -0,0 +1,30 @@
+package publicapi
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/filecoin-project/bacalhau/pkg/system"
+)
+
+// clientKey godoc
+// @ID                   pkg/apiServer.clientKey
+// @Summary              Returns the client ID, public key and fingerprint of the ID key of the node.
+// @Description.markdown endpoints_client_key
+// @Tags                 Misc
+// @Produce              json
+// @Success              200 {object} model.ClientKeyInfo
+// @Failure              500 {object} bacerrors.ErrorResponse
+// @Router               /client_key [get]
+func (apiServer *APIServer) clientKey(res http.ResponseWriter, req *http.Request) {
+	_, span := system.GetSpanFromRequest(req, "pkg/apiServer.clientKey")
+	defer span.End()
+
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusOK)
+	err := json.NewEncoder(res).Encode(system.GetClientKeyInfo())
+	if err != nil {
+		httpError(res, req, err, http.StatusInternalServerError)
+		return
+	}
+}
@@ -34,6 +34,6 @@ func (apiServer *APIServer) debug(res http.ResponseWriter, req *http.Request) {
 	res.WriteHeader(http.StatusOK)
 	err := json.NewEncoder(res).Encode(debugInfoMap)
 	if err != nil {
-		http.Error(res, err.Error(), http.StatusInternalServerError)
+		httpError(res, req, err, http.StatusInternalServerError)
 	}
 }
@@ -0,0 +1,143 @@
+package publicapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/filecoin-project/bacalhau/pkg/bacerrors"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog/log"
+)
+
+// MaxDebugSessionRequestAge is how long after it is signed that a request to
+// debug a shard is accepted, so that requests can't be replayed later on.
+var MaxDebugSessionRequestAge = time.Minute
+
+// debugSessionRequest is the first message a client sends on a debug session.
+type debugSessionRequest struct {
+	// The shard to debug:
+	Data model.JobDebugPayload `json:"data" validate:"required"`
+
+	// A base64-encoded signature of the data, signed by the client:
+	ClientSignature string `json:"signature" validate:"required"`
+
+	// The base64-encoded public key of the client:
+	ClientPublicKey string `json:"client_public_key" validate:"required"`
+
+	// The rotations of the client's key, from the key its ID derives from to
+	// the public key, if it has rotated its key:
+	ClientKeyRotations []model.ClientKeyRotation `json:"client_key_rotations,omitempty"`
+}
+
+// debugSession godoc
+// @ID                   pkg/publicapi/debugSession
+// @Summary              Attaches a shell to the environment this node has kept of a failed shard, over a websocket.
+// @Description.markdown endpoints_debug_session
+// @Tags                 Job
+// @Success              101
+// @Failure              501 {object} bacerrors.ErrorResponse
+// @Router               /debug_session [get]
+func (apiServer *APIServer) debugSession(res http.ResponseWriter, req *http.Request) {
+	if apiServer.DebugSessions == nil {
+		httpError(res, req, bacerrors.NewNotImplemented(
+			fmt.Errorf("this node does not keep the environment of failed shards for debugging")), http.StatusNotImplemented)
+		return
+	}
+	conn, err := upgrader.Upgrade(res, req, nil)
+	if err != nil {
+		// the upgrader has replied with the error
+		return
+	}
+	defer conn.Close()
+	// sessions last for as long as the shell does, whatever the timeouts of
+	// the server are
+	_ = conn.UnderlyingConn().SetDeadline(time.Time{})
+
+	ctx := req.Context()
+	err = func() error {
+		var debugReq debugSessionRequest
+		if err := conn.ReadJSON(&debugReq); err != nil { //nolint:govet // ignore err shadowing
+			return err
+		}
+		if err := apiServer.verifyDebugSessionRequest(ctx, debugReq); err != nil { //nolint:govet
+			return err
+		}
+		log.Ctx(ctx).Info().
+			Str("ClientID", debugReq.Data.ClientID).
+			Str("JobID", debugReq.Data.JobID).
+			Int("ShardIndex", debugReq.Data.ShardIndex).
+			Msg("Attaching a shell to the environment of a failed shard")
+		terminal := &websocketTerminal{conn: conn}
+		return apiServer.DebugSessions.AttachShell(ctx, debugReq.Data.JobID, debugReq.Data.ShardIndex, terminal)
+	}()
+	if err != nil {
+		log.Ctx(ctx).Debug().Err(err).Msg("Debug session failed")
+		_ = conn.WriteMessage(websocket.TextMessage, []byte(err.Error()))
+	}
+	_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+}
+
+// verifyDebugSessionRequest checks that a request to debug a shard was made
+// recently by the client that created the job, and wasn't made before.
+func (apiServer *APIServer) verifyDebugSessionRequest(ctx context.Context, debugReq debugSessionRequest) error {
+	data := debugReq.Data
+	if err := apiServer.verifySignedPayload(data.ClientID, data, debugReq.ClientSignature, debugReq.ClientPublicKey, debugReq.ClientKeyRotations); err != nil {
+		return err
+	}
+	if age := time.Since(data.CreatedAt); age > MaxDebugSessionRequestAge || age < -MaxDebugSessionRequestAge {
+		return errors.New("the request to debug the shard has expired, check the clock of the client")
+	}
+	if data.JobID == "" {
+		return errors.New("the request to debug a shard must have a job ID")
+	}
+	return apiServer.checkJobOwner(ctx, data.ClientID, data.JobID)
+}
+
+// websocketTerminal reads and writes the binary messages of a websocket as
+// a stream, for the input and output of a shell. Text messages are errors.
+type websocketTerminal struct {
+	conn   *websocket.Conn
+	reader io.Reader
+}
+
+func (t *websocketTerminal) Read(p []byte) (int, error) {
+	for {
+		if t.reader == nil {
+			messageType, reader, err := t.conn.NextReader()
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+				return 0, io.EOF
+			} else if err != nil {
+				return 0, err
+			}
+			if messageType == websocket.TextMessage {
+				message, err := io.ReadAll(reader)
+				if err != nil {
+					return 0, err
+				}
+				return 0, errors.New(string(message))
+			}
+			t.reader = reader
+		}
+		n, err := t.reader.Read(p)
+		if errors.Is(err, io.EOF) {
+			t.reader = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+func (t *websocketTerminal) Write(p []byte) (int, error) {
+	if err := t.conn.WriteMessage(websocket.BinaryMessage, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,70 @@
+package publicapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/filecoin-project/bacalhau/pkg/bacerrors"
+	"github.com/filecoin-project/bacalhau/pkg/compute/frontend"
+	"github.com/filecoin-project/bacalhau/pkg/job"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/publicapi/handlerwrapper"
+	"github.com/filecoin-project/bacalhau/pkg/system"
+)
+
+type dryRunRequest struct {
+	ClientID string    `json:"client_id" example:"ac13188e93c97a9c2e7cf8e86c7313156a73436036f30da1ececc2ce79f9ea51"`
+	Job      model.Job `json:"job"`
+}
+
+type dryRunResponse struct {
+	Explanation frontend.ExplainBidResponse `json:"explanation"`
+}
+
+// dryRun godoc
+// @ID                   pkg/publicapi/dryRun
+// @Summary              Explains whether the compute node of this node would bid on a job, without submitting it.
+// @Description.markdown endpoints_dry_run
+// @Tags                 Job
+// @Accept               json
+// @Produce              json
+// @Param                dryRunRequest body     dryRunRequest true " "
+// @Success              200           {object} dryRunResponse
+// @Failure              400           {object} bacerrors.ErrorResponse
+// @Failure              500           {object} bacerrors.ErrorResponse
+// @Failure              501           {object} bacerrors.ErrorResponse
+// @Router               /dry_run [post]
+func (apiServer *APIServer) dryRun(res http.ResponseWriter, req *http.Request) {
+	ctx, span := system.GetSpanFromRequest(req, "pkg/publicapi/dryRun")
+	defer span.End()
+
+	var dryRunReq dryRunRequest
+	if err := json.NewDecoder(req.Body).Decode(&dryRunReq); err != nil {
+		httpError(res, req, bacerrors.NewBadRequest(err), http.StatusBadRequest)
+		return
+	}
+	res.Header().Set(handlerwrapper.HTTPHeaderClientID, dryRunReq.ClientID)
+
+	if apiServer.Compute == nil {
+		httpError(res, req, bacerrors.NewNotImplemented(fmt.Errorf("this node does not run jobs")), http.StatusNotImplemented)
+		return
+	}
+	if err := job.VerifyJob(ctx, &dryRunReq.Job); err != nil {
+		httpError(res, req, bacerrors.NewSpecInvalid(err), http.StatusBadRequest)
+		return
+	}
+
+	explanation, err := apiServer.Compute.ExplainBid(ctx, frontend.ExplainBidRequest{Job: dryRunReq.Job})
+	if err != nil {
+		httpError(res, req, err, http.StatusInternalServerError)
+		return
+	}
+
+	res.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(res).Encode(dryRunResponse{Explanation: explanation})
+	if err != nil {
+		httpError(res, req, err, http.StatusInternalServerError)
+		return
+	}
+}
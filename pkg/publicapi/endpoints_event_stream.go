@@ -0,0 +1,159 @@
+package publicapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/filecoin-project/bacalhau/pkg/bacerrors"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/rs/zerolog/log"
+)
+
+// MaxEventStreamDuration is the longest an event stream is kept open for
+// when the server has no write timeout. Streams otherwise end before the
+// write timeout, and clients reconnect and resume from the last event they
+// received.
+var MaxEventStreamDuration = 5 * time.Minute
+
+// How long clients wait before reconnecting to an event stream, in
+// milliseconds.
+const eventStreamRetryMillis = 1000
+
+// eventStream godoc
+// @ID                   pkg/publicapi/eventStream
+// @Summary              Streams job events as server-sent events.
+// @Description.markdown endpoints_event_stream
+// @Tags                 Job
+// @Produce              text/event-stream
+// @Param                job_id        query    string false "The job to stream the events of, all jobs if not set"
+// @Param                Last-Event-ID header   string false "The ID of the last event received, to resume from"
+// @Success              200           {object} model.JobEvent
+// @Failure              400           {object} bacerrors.ErrorResponse
+// @Router               /event_stream [get]
+func (apiServer *APIServer) eventStream(res http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	flusher, ok := res.(http.Flusher)
+	if !ok {
+		httpError(res, req, fmt.Errorf("streaming is not supported"), http.StatusInternalServerError)
+		return
+	}
+
+	// NB: jobID == "" is the case for subscriptions to "all events"
+	jobID := req.URL.Query().Get("job_id")
+	lastEventID := req.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		// for clients that can't set headers
+		lastEventID = req.URL.Query().Get("last_event_id")
+	}
+	var lastID uint64
+	resume := lastEventID != ""
+	if resume {
+		var err error
+		if lastID, err = strconv.ParseUint(lastEventID, 10, 64); err != nil {
+			httpError(res, req, bacerrors.NewBadRequest(fmt.Errorf("invalid Last-Event-ID %q", lastEventID)), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// subscribe before reading the stored events so that none are missed
+	events, missed, complete := apiServer.eventSubscribers.subscribeAfter(jobID, lastID, resume)
+	defer apiServer.eventSubscribers.unsubscribe(jobID, events)
+
+	res.Header().Set("Content-Type", "text/event-stream")
+	res.Header().Set("Cache-Control", "no-cache")
+	res.Header().Set("Connection", "keep-alive")
+	res.Header().Set("X-Accel-Buffering", "no")
+	res.WriteHeader(http.StatusOK)
+
+	err := func() error {
+		if _, err := fmt.Fprintf(res, "retry: %d\n\n", eventStreamRetryMillis); err != nil {
+			return err
+		}
+		if resume && !complete {
+			// tell the client it may have missed events, which it can make up
+			// for with the history of the job that follows, or with /list
+			if _, err := io.WriteString(res, "event: reset\ndata: {}\n\n"); err != nil {
+				return err
+			}
+		}
+
+		seen := map[string]bool{}
+		if jobID != "" && (!resume || !complete) {
+			stored, err := apiServer.localdb.GetJobEvents(ctx, jobID)
+			if err != nil {
+				return err
+			}
+			for _, event := range stored {
+				seen[eventKey(event)] = true
+				// stored events have no ID, as resuming from them replays
+				// the whole history anyway
+				if err = writeServerSentEvent(res, 0, event); err != nil {
+					return err
+				}
+			}
+		}
+		for _, streamed := range missed {
+			if seen[eventKey(streamed.Event)] {
+				continue
+			}
+			if err := writeServerSentEvent(res, streamed.ID, streamed.Event); err != nil {
+				return err
+			}
+		}
+		flusher.Flush()
+
+		timeout := time.NewTimer(apiServer.eventStreamDuration())
+		defer timeout.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-timeout.C:
+				// end the stream before the write timeout does, the client
+				// reconnects and resumes from the last event
+				return nil
+			case streamed, ok := <-events:
+				if !ok {
+					// the client fell behind, it can resume if it reconnects
+					// quickly enough
+					return nil
+				}
+				if seen[eventKey(streamed.Event)] {
+					continue
+				}
+				if err := writeServerSentEvent(res, streamed.ID, streamed.Event); err != nil {
+					return err
+				}
+				flusher.Flush()
+			}
+		}
+	}()
+	if err != nil {
+		log.Ctx(ctx).Debug().Err(err).Msgf("event stream for %q ended", jobID)
+	}
+}
+
+func (apiServer *APIServer) eventStreamDuration() time.Duration {
+	if apiServer.Config.WriteTimeout <= 0 {
+		return MaxEventStreamDuration
+	}
+	return apiServer.Config.WriteTimeout * 3 / 4 //nolint:gomnd
+}
+
+func writeServerSentEvent(w io.Writer, id uint64, event model.JobEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if id != 0 {
+		if _, err = fmt.Fprintf(w, "id: %d\n", id); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "data: %s\n\n", data)
+	return err
+}
@@ -0,0 +1,107 @@
+//go:build unit || !integration
+
+package publicapi
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/bacalhau/pkg/logger"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/stretchr/testify/require"
+)
+
+type serverSentEvent struct {
+	id    string
+	event string
+	data  string
+}
+
+// readServerSentEvents sends the events of a stream to the returned channel
+// until the stream ends.
+func readServerSentEvents(t *testing.T, ctx context.Context, url, lastEventID string) <-chan serverSentEvent {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	require.NoError(t, err)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	res, err := http.DefaultClient.Do(req) //nolint:bodyclose // closed below
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+
+	events := make(chan serverSentEvent, 16)
+	go func() {
+		defer close(events)
+		defer res.Body.Close()
+		scanner := bufio.NewScanner(res.Body)
+		var event serverSentEvent
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case line == "":
+				if event.data != "" {
+					events <- event
+				}
+				event = serverSentEvent{}
+			case strings.HasPrefix(line, "id: "):
+				event.id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "event: "):
+				event.event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				event.data = strings.TrimPrefix(line, "data: ")
+			}
+		}
+	}()
+	return events
+}
+
+func nextServerSentEvent(t *testing.T, events <-chan serverSentEvent) serverSentEvent {
+	select {
+	case event, ok := <-events:
+		require.True(t, ok, "stream ended")
+		return event
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "no event received")
+	}
+	return serverSentEvent{}
+}
+
+func TestEventStream(t *testing.T) {
+	logger.ConfigureTestLogging(t)
+
+	c, cm := SetupRequesterNodeForTests(t, true)
+	defer cm.Cleanup()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	allEvents := readServerSentEvents(t, ctx, c.BaseURI+"/event_stream", "")
+	j, err := c.Submit(ctx, MakeNoopJob(), nil)
+	require.NoError(t, err)
+
+	created := nextServerSentEvent(t, allEvents)
+	require.NotEmpty(t, created.id)
+	var event model.JobEvent
+	require.NoError(t, json.Unmarshal([]byte(created.data), &event))
+	require.Equal(t, j.ID, event.JobID)
+	require.Equal(t, model.JobEventCreated, event.EventName)
+
+	// a job's stream starts with the events it already has
+	jobEvents := readServerSentEvents(t, ctx, fmt.Sprintf("%s/event_stream?job_id=%s", c.BaseURI, j.ID), "")
+	replayed := nextServerSentEvent(t, jobEvents)
+	require.Equal(t, created.data, replayed.data)
+
+	// resuming from before the event sends it again
+	resumed := readServerSentEvents(t, ctx, c.BaseURI+"/event_stream", "0")
+	require.Equal(t, created, nextServerSentEvent(t, resumed))
+
+	// resuming from an unknown event resets the stream
+	reset := readServerSentEvents(t, ctx, c.BaseURI+"/event_stream", "1000000")
+	require.Equal(t, "reset", nextServerSentEvent(t, reset).event)
+}
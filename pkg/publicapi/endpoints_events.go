@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/filecoin-project/bacalhau/pkg/bacerrors"
 	"github.com/filecoin-project/bacalhau/pkg/model"
 	"github.com/filecoin-project/bacalhau/pkg/publicapi/handlerwrapper"
 )
@@ -26,8 +27,8 @@ type eventsResponse struct {
 // @Produce              json
 // @Param                eventsRequest body     eventsRequest true "Request must specify a `client_id`. To retrieve your `client_id`, you can do the following: (1) submit a dummy job to Bacalhau (or use one you created before), (2) run `bacalhau describe <job-id>` and fetch the `ClientID` field."
 // @Success              200           {object} eventsResponse
-// @Failure              400           {object} string
-// @Failure              500           {object} string
+// @Failure              400           {object} bacerrors.ErrorResponse
+// @Failure              500           {object} bacerrors.ErrorResponse
 // @Router               /events [post]
 //
 //nolint:lll
@@ -35,7 +36,7 @@ type eventsResponse struct {
 func (apiServer *APIServer) events(res http.ResponseWriter, req *http.Request) {
 	var eventsReq eventsRequest
 	if err := json.NewDecoder(req.Body).Decode(&eventsReq); err != nil {
-		http.Error(res, err.Error(), http.StatusBadRequest)
+		httpError(res, req, bacerrors.NewBadRequest(err), http.StatusBadRequest)
 		return
 	}
 	res.Header().Set(handlerwrapper.HTTPHeaderClientID, eventsReq.ClientID)
@@ -44,7 +45,7 @@ func (apiServer *APIServer) events(res http.ResponseWriter, req *http.Request) {
 	ctx := req.Context()
 	events, err := apiServer.localdb.GetJobEvents(ctx, eventsReq.JobID)
 	if err != nil {
-		http.Error(res, err.Error(), http.StatusInternalServerError)
+		httpError(res, req, err, http.StatusInternalServerError)
 		return
 	}
 	res.WriteHeader(http.StatusOK)
@@ -52,7 +53,7 @@ func (apiServer *APIServer) events(res http.ResponseWriter, req *http.Request) {
 		Events: events,
 	})
 	if err != nil {
-		http.Error(res, err.Error(), http.StatusInternalServerError)
+		httpError(res, req, err, http.StatusInternalServerError)
 		return
 	}
 }
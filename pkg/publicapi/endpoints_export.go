@@ -0,0 +1,102 @@
+package publicapi
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/filecoin-project/bacalhau/pkg/bacerrors"
+	"github.com/filecoin-project/bacalhau/pkg/localdb"
+	"github.com/filecoin-project/bacalhau/pkg/system"
+	"github.com/rs/zerolog/log"
+)
+
+var exportContentTypes = map[localdb.ExportFormat]string{
+	localdb.ExportCSV:     "text/csv",
+	localdb.ExportParquet: "application/vnd.apache.parquet",
+}
+
+// exportQueryValues encodes an export query into the query string of the
+// /export endpoint.
+func exportQueryValues(query localdb.ExportQuery) url.Values {
+	values := url.Values{
+		"kind":   {string(query.Kind)},
+		"format": {string(query.Format)},
+	}
+	if !query.Since.IsZero() {
+		values.Set("since", query.Since.Format(time.RFC3339))
+	}
+	if !query.Until.IsZero() {
+		values.Set("until", query.Until.Format(time.RFC3339))
+	}
+	return values
+}
+
+// parseExportQuery decodes the query string of the /export endpoint. The kind
+// defaults to jobs and the format to csv.
+func parseExportQuery(values url.Values) (localdb.ExportQuery, error) {
+	query := localdb.ExportQuery{
+		Kind:   localdb.ExportKind(values.Get("kind")),
+		Format: localdb.ExportFormat(values.Get("format")),
+	}
+	if query.Kind == "" {
+		query.Kind = localdb.ExportJobs
+	}
+	if query.Format == "" {
+		query.Format = localdb.ExportCSV
+	}
+	var err error
+	if since := values.Get("since"); since != "" {
+		if query.Since, err = time.Parse(time.RFC3339, since); err != nil {
+			return query, fmt.Errorf("invalid since %q, expected an RFC 3339 time", since)
+		}
+	}
+	if until := values.Get("until"); until != "" {
+		if query.Until, err = time.Parse(time.RFC3339, until); err != nil {
+			return query, fmt.Errorf("invalid until %q, expected an RFC 3339 time", until)
+		}
+	}
+	return query, query.Validate()
+}
+
+// export godoc
+// @ID                   pkg/publicapi/export
+// @Summary              Exports jobs or events as CSV or Parquet.
+// @Description.markdown endpoints_export
+// @Tags                 Job
+// @Produce              text/csv,application/vnd.apache.parquet
+// @Param                kind   query    string false "What to export, jobs (default) or events"
+// @Param                format query    string false "The file format, csv (default) or parquet"
+// @Param                since  query    string false "Export the jobs created, or events that happened, from this RFC 3339 time"
+// @Param                until  query    string false "Export the jobs created, or events that happened, before this RFC 3339 time"
+// @Success              200    {file}   file
+// @Failure              400    {object} bacerrors.ErrorResponse
+// @Failure              500    {object} bacerrors.ErrorResponse
+// @Router               /export [get]
+func (apiServer *APIServer) export(res http.ResponseWriter, req *http.Request) {
+	ctx, span := system.GetSpanFromRequest(req, "pkg/publicapi.export")
+	defer span.End()
+
+	query, err := parseExportQuery(req.URL.Query())
+	if err != nil {
+		httpError(res, req, bacerrors.NewBadRequest(err), http.StatusBadRequest)
+		return
+	}
+
+	rows, err := localdb.CollectExportRows(ctx, apiServer.localdb, query)
+	if err != nil {
+		httpError(res, req, err, http.StatusInternalServerError)
+		return
+	}
+
+	// the rows are encoded as they are written, so errors from here on can
+	// only be reported by cutting the response short
+	res.Header().Set("Content-Type", exportContentTypes[query.Format])
+	res.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, query.Kind, query.Format))
+	res.WriteHeader(http.StatusOK)
+
+	if err = rows.Write(res); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msgf("error exporting %s", query.Kind)
+	}
+}
@@ -27,7 +27,7 @@ func (apiServer *APIServer) id(res http.ResponseWriter, req *http.Request) {
 		res.WriteHeader(http.StatusOK)
 		err := json.NewEncoder(res).Encode(id)
 		if err != nil {
-			http.Error(res, err.Error(), http.StatusInternalServerError)
+			httpError(res, req, err, http.StatusInternalServerError)
 			return
 		}
 		return
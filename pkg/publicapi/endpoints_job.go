@@ -0,0 +1,41 @@
+package publicapi
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/filecoin-project/bacalhau/pkg/bacerrors"
+)
+
+// The endpoints for a single job are under /job/{id}/.
+const jobPathPrefix = "/job/"
+
+// jobRoutes sends requests under /job/{id}/ to the handler for the rest of
+// their path. Only GET requests are served there.
+func (apiServer *APIServer) jobRoutes(res http.ResponseWriter, req *http.Request) {
+	var handler http.HandlerFunc
+	switch {
+	case strings.HasSuffix(req.URL.Path, resultsDownloadPathEnd):
+		handler = apiServer.resultsDownload
+	case strings.HasSuffix(req.URL.Path, resultsFilePathEnd):
+		handler = apiServer.resultsFile
+	case strings.HasSuffix(req.URL.Path, jobWaitPathEnd):
+		handler = apiServer.jobWait
+	default:
+		http.NotFound(res, req)
+		return
+	}
+
+	if req.Method != http.MethodGet {
+		httpError(res, req, bacerrors.NewBadRequest(fmt.Errorf("method %s not allowed", req.Method)), http.StatusMethodNotAllowed)
+		return
+	}
+	handler(res, req)
+}
+
+// jobIDFromPath returns the job ID of a /job/{id}/... request path that ends
+// with pathEnd.
+func jobIDFromPath(req *http.Request, pathEnd string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(req.URL.Path, jobPathPrefix), pathEnd)
+}
@@ -0,0 +1,54 @@
+package publicapi
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/filecoin-project/bacalhau/pkg/bacerrors"
+	"github.com/filecoin-project/bacalhau/pkg/logger"
+	"github.com/filecoin-project/bacalhau/pkg/publicapi/handlerwrapper"
+	"github.com/filecoin-project/bacalhau/pkg/system"
+)
+
+type jobLogsRequest struct {
+	ClientID string `json:"client_id" example:"ac13188e93c97a9c2e7cf8e86c7313156a73436036f30da1ececc2ce79f9ea51"`
+	JobID    string `json:"job_id" example:"9304c616-291f-41ad-b862-54e133c0149e"`
+}
+
+type jobLogsResponse struct {
+	Logs []json.RawMessage `json:"logs"`
+}
+
+// jobLogs godoc
+// @ID                   pkg/publicapi/jobLogs
+// @Summary              Returns the recent logs of this node for the job-id specified in the body payload.
+// @Description.markdown endpoints_job_logs
+// @Tags                 Job
+// @Accept               json
+// @Produce              json
+// @Param                jobLogsRequest body     jobLogsRequest true " "
+// @Success              200            {object} jobLogsResponse
+// @Failure              400            {object} bacerrors.ErrorResponse
+// @Failure              500            {object} bacerrors.ErrorResponse
+// @Router               /job_logs [post]
+func (apiServer *APIServer) jobLogs(res http.ResponseWriter, req *http.Request) {
+	_, span := system.GetSpanFromRequest(req, "pkg/publicapi/jobLogs")
+	defer span.End()
+
+	var logsReq jobLogsRequest
+	if err := json.NewDecoder(req.Body).Decode(&logsReq); err != nil {
+		httpError(res, req, bacerrors.NewBadRequest(err), http.StatusBadRequest)
+		return
+	}
+	res.Header().Set(handlerwrapper.HTTPHeaderClientID, logsReq.ClientID)
+	res.Header().Set(handlerwrapper.HTTPHeaderJobID, logsReq.JobID)
+
+	res.WriteHeader(http.StatusOK)
+	err := json.NewEncoder(res).Encode(jobLogsResponse{
+		Logs: logger.JobLogs(logsReq.JobID),
+	})
+	if err != nil {
+		httpError(res, req, err, http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,113 @@
+package publicapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/filecoin-project/bacalhau/pkg/bacerrors"
+	"github.com/filecoin-project/bacalhau/pkg/publicapi/handlerwrapper"
+	"github.com/filecoin-project/bacalhau/pkg/system"
+)
+
+const jobWaitPathEnd = "/wait"
+
+// MaxJobWaitTimeout is the longest a /job/{id}/wait request is held open
+// for, which stays below the APIClient's request timeout. It is lowered
+// further to stay below the server's write timeout.
+var MaxJobWaitTimeout = 4 * time.Minute
+
+// How long before the server's write timeout a /job/{id}/wait request
+// returns, to leave time to write the response.
+const jobWaitWriteMargin = 2 * time.Second
+
+// jobWait godoc
+// @ID                   pkg/publicapi/jobWait
+// @Summary              Blocks until a job has finished, or the timeout has passed.
+// @Description.markdown endpoints_job_wait
+// @Tags                 Job
+// @Produce              json
+// @Param                id      path     string true  "The ID of the job"
+// @Param                timeout query    string false "How long to wait, as a duration (e.g. 90s) or in seconds"
+// @Success              200     {object} WaitResponse
+// @Failure              400     {object} bacerrors.ErrorResponse
+// @Failure              404     {object} bacerrors.ErrorResponse
+// @Failure              500     {object} bacerrors.ErrorResponse
+// @Router               /job/{id}/wait [get]
+func (apiServer *APIServer) jobWait(res http.ResponseWriter, req *http.Request) {
+	ctx, span := system.GetSpanFromRequest(req, "pkg/publicapi/jobWait")
+	defer span.End()
+
+	jobID := jobIDFromPath(req, jobWaitPathEnd)
+	res.Header().Set(handlerwrapper.HTTPHeaderJobID, jobID)
+	ctx = system.AddJobIDToBaggage(ctx, jobID)
+	system.AddJobIDFromBaggageToSpan(ctx, span)
+
+	timeout, err := apiServer.parseJobWaitTimeout(req.URL.Query().Get("timeout"))
+	if err != nil {
+		httpError(res, req, bacerrors.NewBadRequest(err), http.StatusBadRequest)
+		return
+	}
+
+	j, err := apiServer.localdb.GetJob(ctx, jobID)
+	if err != nil {
+		if _, ok := err.(*bacerrors.JobNotFound); ok {
+			httpError(res, req, err, http.StatusNotFound)
+			return
+		}
+		httpError(res, req, err, http.StatusInternalServerError)
+		return
+	}
+
+	waitRes, err := apiServer.waitForJob(ctx, j, timeout)
+	if err != nil {
+		httpError(res, req, err, http.StatusInternalServerError)
+		return
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(res).Encode(waitRes)
+	if err != nil {
+		httpError(res, req, err, http.StatusInternalServerError)
+		return
+	}
+}
+
+// parseJobWaitTimeout parses the timeout of a /job/{id}/wait request, which
+// is either a duration or a number of seconds, and caps it at the longest
+// the server can hold a request open for. No timeout means waiting for as
+// long as possible.
+func (apiServer *APIServer) parseJobWaitTimeout(value string) (time.Duration, error) {
+	maxTimeout := apiServer.maxJobWaitTimeout()
+	if value == "" {
+		return maxTimeout, nil
+	}
+
+	timeout, err := time.ParseDuration(value)
+	if err != nil {
+		seconds, parseErr := strconv.ParseFloat(value, 64)
+		if parseErr != nil {
+			return 0, fmt.Errorf("invalid timeout %q, expected a duration or a number of seconds", value)
+		}
+		timeout = time.Duration(seconds * float64(time.Second))
+	}
+	if timeout <= 0 {
+		return 0, fmt.Errorf("timeout must be positive, got %q", value)
+	}
+	return system.Min(timeout, maxTimeout), nil
+}
+
+func (apiServer *APIServer) maxJobWaitTimeout() time.Duration {
+	maxTimeout := MaxJobWaitTimeout
+	if writeTimeout := apiServer.Config.WriteTimeout; writeTimeout > 0 {
+		limit := writeTimeout - jobWaitWriteMargin
+		if limit <= 0 {
+			limit = writeTimeout / 2
+		}
+		maxTimeout = system.Min(maxTimeout, limit)
+	}
+	return maxTimeout
+}
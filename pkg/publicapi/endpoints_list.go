@@ -16,6 +16,7 @@ import (
 type listRequest struct {
 	JobID       string `json:"id" example:"9304c616-291f-41ad-b862-54e133c0149e"`
 	ClientID    string `json:"client_id" example:"ac13188e93c97a9c2e7cf8e86c7313156a73436036f30da1ececc2ce79f9ea51"`
+	JobGroup    string `json:"job_group,omitempty" example:"1b7f5e2a-4f0e-4a1c-9d3b-9e8f0c6d2a71"`
 	MaxJobs     int    `json:"max_jobs" example:"10"`
 	ReturnAll   bool   `json:"return_all" `
 	SortBy      string `json:"sort_by" example:"created_at"`
@@ -35,8 +36,8 @@ type listResponse struct {
 // @Produce              json
 // @Param                listRequest body     listRequest true "Set `return_all` to `true` to return all jobs on the network (may degrade performance, use with care!)."
 // @Success              200         {object} listResponse
-// @Failure              400         {object} string
-// @Failure              500         {object} string
+// @Failure              400         {object} bacerrors.ErrorResponse
+// @Failure              500         {object} bacerrors.ErrorResponse
 // @Router               /list [post]
 //
 //nolint:lll
@@ -46,7 +47,7 @@ func (apiServer *APIServer) list(res http.ResponseWriter, req *http.Request) {
 
 	var listReq listRequest
 	if err := json.NewDecoder(req.Body).Decode(&listReq); err != nil {
-		http.Error(res, err.Error(), http.StatusBadRequest)
+		httpError(res, req, bacerrors.NewBadRequest(err), http.StatusBadRequest)
 		return
 	}
 	res.Header().Set(handlerwrapper.HTTPHeaderClientID, listReq.ClientID)
@@ -56,7 +57,7 @@ func (apiServer *APIServer) list(res http.ResponseWriter, req *http.Request) {
 	if err != nil {
 		_, ok := err.(*bacerrors.JobNotFound)
 		if ok {
-			http.Error(res, bacerrors.ErrorToErrorResponse(err), http.StatusBadRequest)
+			httpError(res, req, err, http.StatusBadRequest)
 			return
 		}
 	}
@@ -65,7 +66,7 @@ func (apiServer *APIServer) list(res http.ResponseWriter, req *http.Request) {
 		err = apiServer.getJobStates(ctx, jobList)
 		if err != nil {
 			log.Ctx(ctx).Error().Err(err).Msg("error getting job states")
-			http.Error(res, err.Error(), http.StatusInternalServerError)
+			httpError(res, req, err, http.StatusInternalServerError)
 			return
 		}
 	}
@@ -74,7 +75,7 @@ func (apiServer *APIServer) list(res http.ResponseWriter, req *http.Request) {
 		Jobs: jobList,
 	})
 	if err != nil {
-		http.Error(res, err.Error(), http.StatusInternalServerError)
+		httpError(res, req, err, http.StatusInternalServerError)
 		return
 	}
 }
@@ -86,6 +87,7 @@ func (apiServer *APIServer) getJobsList(ctx context.Context, listReq listRequest
 	list, err := apiServer.localdb.GetJobs(ctx, localdb.JobQuery{
 		ClientID:    listReq.ClientID,
 		ID:          listReq.JobID,
+		JobGroup:    listReq.JobGroup,
 		Limit:       listReq.MaxJobs,
 		ReturnAll:   listReq.ReturnAll,
 		SortBy:      listReq.SortBy,
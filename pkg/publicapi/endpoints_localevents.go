@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/filecoin-project/bacalhau/pkg/bacerrors"
 	"github.com/filecoin-project/bacalhau/pkg/model"
 	"github.com/filecoin-project/bacalhau/pkg/publicapi/handlerwrapper"
 )
@@ -26,15 +27,15 @@ type localEventsResponse struct {
 // @Produce     json
 // @Param       localEventsRequest body     localEventsRequest true " "
 // @Success     200                {object} localEventsResponse
-// @Failure     400                {object} string
-// @Failure     500                {object} string
+// @Failure     400                {object} bacerrors.ErrorResponse
+// @Failure     500                {object} bacerrors.ErrorResponse
 // @Router      /local_events [post]
 //
 //nolint:dupl
 func (apiServer *APIServer) localEvents(res http.ResponseWriter, req *http.Request) {
 	var eventsReq localEventsRequest
 	if err := json.NewDecoder(req.Body).Decode(&eventsReq); err != nil {
-		http.Error(res, err.Error(), http.StatusBadRequest)
+		httpError(res, req, bacerrors.NewBadRequest(err), http.StatusBadRequest)
 		return
 	}
 	res.Header().Set(handlerwrapper.HTTPHeaderClientID, eventsReq.ClientID)
@@ -42,7 +43,7 @@ func (apiServer *APIServer) localEvents(res http.ResponseWriter, req *http.Reque
 
 	events, err := apiServer.localdb.GetJobLocalEvents(req.Context(), eventsReq.JobID)
 	if err != nil {
-		http.Error(res, err.Error(), http.StatusInternalServerError)
+		httpError(res, req, err, http.StatusInternalServerError)
 		return
 	}
 
@@ -51,7 +52,7 @@ func (apiServer *APIServer) localEvents(res http.ResponseWriter, req *http.Reque
 		LocalEvents: events,
 	})
 	if err != nil {
-		http.Error(res, err.Error(), http.StatusInternalServerError)
+		httpError(res, req, err, http.StatusInternalServerError)
 		return
 	}
 }
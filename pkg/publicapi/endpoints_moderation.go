@@ -0,0 +1,86 @@
+package publicapi
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/filecoin-project/bacalhau/pkg/bacerrors"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/publicapi/handlerwrapper"
+	"github.com/filecoin-project/bacalhau/pkg/system"
+	"github.com/rs/zerolog/log"
+)
+
+type moderateRequest struct {
+	// The client or job to flag or unflag:
+	Data model.ModerationPayload `json:"data" validate:"required"`
+
+	// A base64-encoded signature of the data, signed by the client:
+	ClientSignature string `json:"signature" validate:"required"`
+
+	// The base64-encoded public key of the client:
+	ClientPublicKey string `json:"client_public_key" validate:"required"`
+
+	// The rotations of the client's key, from the key its ID derives from to
+	// the public key, if it has rotated its key:
+	ClientKeyRotations []model.ClientKeyRotation `json:"client_key_rotations,omitempty"`
+}
+
+// moderate godoc
+// @ID                   pkg/apiServer.moderate
+// @Summary              Flags a client or a job as abusive, or unflags it.
+// @Description.markdown endpoints_moderation
+// @Tags                 Job
+// @Accept               json
+// @Param                moderateRequest body moderateRequest true " "
+// @Success              200
+// @Failure              400 {object} bacerrors.ErrorResponse
+// @Failure              403 {object} bacerrors.ErrorResponse
+// @Router               /moderate [post]
+func (apiServer *APIServer) moderate(res http.ResponseWriter, req *http.Request) {
+	ctx, span := system.GetSpanFromRequest(req, "pkg/apiServer.moderate")
+	defer span.End()
+
+	var moderateReq moderateRequest
+	if err := json.NewDecoder(req.Body).Decode(&moderateReq); err != nil {
+		log.Ctx(ctx).Debug().Msgf("====> Decode moderateReq error: %s", err)
+		httpError(res, req, bacerrors.NewBadRequest(err), http.StatusBadRequest)
+		return
+	}
+	data := moderateReq.Data
+	res.Header().Set(handlerwrapper.HTTPHeaderClientID, data.ClientID)
+
+	if err := apiServer.verifySignedPayload(data.ClientID, data, moderateReq.ClientSignature, moderateReq.ClientPublicKey, moderateReq.ClientKeyRotations); err != nil {
+		log.Ctx(ctx).Debug().Msgf("====> VerifyModerateRequest error: %s", err)
+		httpError(res, req, bacerrors.NewBadRequest(err), http.StatusBadRequest)
+		return
+	}
+
+	if err := apiServer.Requester.Moderate(ctx, data); err != nil {
+		httpError(res, req, err, http.StatusBadRequest)
+		return
+	}
+	res.WriteHeader(http.StatusOK)
+}
+
+// denylist godoc
+// @ID                   pkg/apiServer.denylist
+// @Summary              Returns the clients and jobs flagged as abusive.
+// @Description.markdown endpoints_moderation
+// @Tags                 Job
+// @Produce              json
+// @Success              200 {object} model.Denylist
+// @Failure              500 {object} bacerrors.ErrorResponse
+// @Router               /denylist [get]
+func (apiServer *APIServer) denylist(res http.ResponseWriter, req *http.Request) {
+	_, span := system.GetSpanFromRequest(req, "pkg/apiServer.denylist")
+	defer span.End()
+
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusOK)
+	err := json.NewEncoder(res).Encode(apiServer.Requester.Denylist())
+	if err != nil {
+		httpError(res, req, err, http.StatusInternalServerError)
+		return
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 
+	"github.com/filecoin-project/bacalhau/pkg/bacerrors"
 	"github.com/filecoin-project/bacalhau/pkg/system"
 	"github.com/filecoin-project/bacalhau/pkg/transport/libp2p"
 )
@@ -16,7 +17,7 @@ import (
 // @Tags                 Misc
 // @Produce              json
 // @Success              200 {object} map[string][]string{}
-// @Failure              500 {object} string
+// @Failure              500 {object} bacerrors.ErrorResponse
 // @Router               /peers [get]
 func (apiServer *APIServer) peers(res http.ResponseWriter, req *http.Request) {
 	ctx, span := system.GetSpanFromRequest(req, "apiServer/peers")
@@ -29,17 +30,17 @@ func (apiServer *APIServer) peers(res http.ResponseWriter, req *http.Request) {
 	case *libp2p.LibP2PTransport:
 		peers, err := apiTransport.GetPeers(ctx)
 		if err != nil {
-			http.Error(res, fmt.Sprintf("Error getting peers: %s", err.Error()), http.StatusInternalServerError)
+			httpError(res, req, fmt.Errorf("error getting peers: %w", err), http.StatusInternalServerError)
 			return
 		}
 		// write response to res
 		res.WriteHeader(http.StatusOK)
 		err = json.NewEncoder(res).Encode(peers)
 		if err != nil {
-			http.Error(res, err.Error(), http.StatusInternalServerError)
+			httpError(res, req, err, http.StatusInternalServerError)
 			return
 		}
 		return
 	}
-	http.Error(res, "Not a libp2p transport", http.StatusInternalServerError)
+	httpError(res, req, bacerrors.NewNotImplemented(fmt.Errorf("not a libp2p transport")), http.StatusNotImplemented)
 }
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/filecoin-project/bacalhau/pkg/bacerrors"
 	"github.com/filecoin-project/bacalhau/pkg/localdb"
 	"github.com/filecoin-project/bacalhau/pkg/model"
 	"github.com/filecoin-project/bacalhau/pkg/publicapi/handlerwrapper"
@@ -28,8 +29,8 @@ type resultsResponse struct {
 // @Produce              json
 // @Param                stateRequest body     stateRequest true " "
 // @Success              200          {object} resultsResponse
-// @Failure              400          {object} string
-// @Failure              500          {object} string
+// @Failure              400          {object} bacerrors.ErrorResponse
+// @Failure              500          {object} bacerrors.ErrorResponse
 // @Router               /results [post]
 func (apiServer *APIServer) results(res http.ResponseWriter, req *http.Request) {
 	ctx, span := system.GetSpanFromRequest(req, "pkg/publicapi.results")
@@ -37,7 +38,7 @@ func (apiServer *APIServer) results(res http.ResponseWriter, req *http.Request)
 
 	var stateReq stateRequest
 	if err := json.NewDecoder(req.Body).Decode(&stateReq); err != nil {
-		http.Error(res, err.Error(), http.StatusBadRequest)
+		httpError(res, req, bacerrors.NewBadRequest(err), http.StatusBadRequest)
 		return
 	}
 	res.Header().Set(handlerwrapper.HTTPHeaderClientID, stateReq.ClientID)
@@ -49,7 +50,7 @@ func (apiServer *APIServer) results(res http.ResponseWriter, req *http.Request)
 	stateResolver := localdb.GetStateResolver(apiServer.localdb)
 	results, err := stateResolver.GetResults(ctx, stateReq.JobID)
 	if err != nil {
-		http.Error(res, err.Error(), http.StatusInternalServerError)
+		httpError(res, req, err, http.StatusInternalServerError)
 		return
 	}
 
@@ -58,7 +59,7 @@ func (apiServer *APIServer) results(res http.ResponseWriter, req *http.Request)
 		Results: results,
 	})
 	if err != nil {
-		http.Error(res, err.Error(), http.StatusInternalServerError)
+		httpError(res, req, err, http.StatusInternalServerError)
 		return
 	}
 }
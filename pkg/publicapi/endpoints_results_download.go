@@ -0,0 +1,127 @@
+package publicapi
+
+import (
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/filecoin-project/bacalhau/pkg/bacerrors"
+	"github.com/filecoin-project/bacalhau/pkg/ipfs"
+	"github.com/filecoin-project/bacalhau/pkg/localdb"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/publicapi/handlerwrapper"
+	"github.com/filecoin-project/bacalhau/pkg/system"
+	"github.com/rs/zerolog/log"
+)
+
+const resultsDownloadPathEnd = "/results/download"
+
+// resultsDownload godoc
+// @ID                   pkg/publicapi/resultsDownload
+// @Summary              Downloads the results of a job as a tarball.
+// @Description.markdown endpoints_results_download
+// @Tags                 Job
+// @Produce              application/gzip
+// @Param                id     path     string true  "The ID of the job"
+// @Param                shards query    string false "The shard indexes to download the results of, comma separated, all of them if not set"
+// @Success              200    {file}   file
+// @Failure              400    {object} bacerrors.ErrorResponse
+// @Failure              404    {object} bacerrors.ErrorResponse
+// @Failure              500    {object} bacerrors.ErrorResponse
+// @Failure              501    {object} bacerrors.ErrorResponse
+// @Router               /job/{id}/results/download [get]
+func (apiServer *APIServer) resultsDownload(res http.ResponseWriter, req *http.Request) {
+	ctx, span := system.GetSpanFromRequest(req, "pkg/publicapi.resultsDownload")
+	defer span.End()
+
+	jobID := jobIDFromPath(req, resultsDownloadPathEnd)
+	res.Header().Set(handlerwrapper.HTTPHeaderJobID, jobID)
+
+	ctx = system.AddJobIDToBaggage(ctx, jobID)
+	system.AddJobIDFromBaggageToSpan(ctx, span)
+
+	shardIndexes, err := parseShardIndexes(req.URL.Query().Get("shards"))
+	if err != nil {
+		httpError(res, req, bacerrors.NewBadRequest(err), http.StatusBadRequest)
+		return
+	}
+
+	if apiServer.IPFSClient == nil {
+		httpError(res, req, bacerrors.NewNotImplemented(fmt.Errorf("this node cannot download results")), http.StatusNotImplemented)
+		return
+	}
+
+	if _, err := apiServer.localdb.GetJob(ctx, jobID); err != nil {
+		if _, ok := err.(*bacerrors.JobNotFound); ok {
+			httpError(res, req, err, http.StatusNotFound)
+			return
+		}
+		httpError(res, req, err, http.StatusInternalServerError)
+		return
+	}
+
+	results, err := localdb.GetStateResolver(apiServer.localdb).GetResults(ctx, jobID)
+	if err != nil {
+		httpError(res, req, err, http.StatusInternalServerError)
+		return
+	}
+	results = filterShardResults(results, shardIndexes)
+	if len(results) == 0 {
+		httpError(res, req, bacerrors.NewNotFound(fmt.Errorf("job %s has no results yet", jobID)), http.StatusNotFound)
+		return
+	}
+
+	// the tarball is streamed as the results are fetched from IPFS, so errors
+	// from here on can only be reported by cutting the response short
+	res.Header().Set("Content-Type", "application/gzip")
+	res.Header().Set("Content-Disposition",
+		fmt.Sprintf(`attachment; filename="job-%s.tar.gz"`, system.GetShortID(jobID)))
+	res.WriteHeader(http.StatusOK)
+
+	zw := gzip.NewWriter(res)
+	err = ipfs.WriteResultsTar(ctx, apiServer.IPFSClient, results, zw)
+	if err == nil {
+		err = zw.Close()
+	}
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msgf("error streaming the results of job %s", jobID)
+	}
+}
+
+// parseShardIndexes parses a comma separated list of shard indexes, which is
+// empty for all of the shards.
+func parseShardIndexes(value string) ([]int, error) {
+	if value == "" {
+		return nil, nil
+	}
+	var shardIndexes []int
+	for _, part := range strings.Split(value, ",") {
+		shardIndex, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || shardIndex < 0 {
+			return nil, fmt.Errorf("invalid shard index %q", part)
+		}
+		shardIndexes = append(shardIndexes, shardIndex)
+	}
+	return shardIndexes, nil
+}
+
+// filterShardResults returns the results of the given shards, or all of the
+// results if no shards are given.
+func filterShardResults(results []model.PublishedResult, shardIndexes []int) []model.PublishedResult {
+	if len(shardIndexes) == 0 {
+		return results
+	}
+	wanted := make(map[int]bool, len(shardIndexes))
+	for _, shardIndex := range shardIndexes {
+		wanted[shardIndex] = true
+	}
+	filtered := []model.PublishedResult{}
+	for _, result := range results {
+		if wanted[result.ShardIndex] {
+			filtered = append(filtered, result)
+		}
+	}
+	return filtered
+}
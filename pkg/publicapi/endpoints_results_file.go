@@ -0,0 +1,114 @@
+package publicapi
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/filecoin-project/bacalhau/pkg/bacerrors"
+	"github.com/filecoin-project/bacalhau/pkg/localdb"
+	"github.com/filecoin-project/bacalhau/pkg/publicapi/handlerwrapper"
+	"github.com/filecoin-project/bacalhau/pkg/system"
+	"github.com/rs/zerolog/log"
+)
+
+const resultsFilePathEnd = "/results/file"
+
+// resultsFile godoc
+// @ID                   pkg/publicapi/resultsFile
+// @Summary              Returns a file, or a byte range of it, from the results of a job.
+// @Description.markdown endpoints_results_file
+// @Tags                 Job
+// @Produce              application/octet-stream
+// @Param                id    path     string true  "The ID of the job"
+// @Param                path  query    string true  "The path of the file in the results, e.g. outputs/data.csv"
+// @Param                shard query    int    false "The index of the shard whose results the file is in, 0 if not set"
+// @Param                Range header   string false "The byte range of the file to return, e.g. bytes=0-1023"
+// @Success              200   {file}   file
+// @Success              206   {file}   file
+// @Failure              400   {object} bacerrors.ErrorResponse
+// @Failure              404   {object} bacerrors.ErrorResponse
+// @Failure              416   {object} bacerrors.ErrorResponse
+// @Failure              500   {object} bacerrors.ErrorResponse
+// @Failure              501   {object} bacerrors.ErrorResponse
+// @Router               /job/{id}/results/file [get]
+func (apiServer *APIServer) resultsFile(res http.ResponseWriter, req *http.Request) {
+	ctx, span := system.GetSpanFromRequest(req, "pkg/publicapi.resultsFile")
+	defer span.End()
+
+	jobID := jobIDFromPath(req, resultsFilePathEnd)
+	res.Header().Set(handlerwrapper.HTTPHeaderJobID, jobID)
+
+	ctx = system.AddJobIDToBaggage(ctx, jobID)
+	system.AddJobIDFromBaggageToSpan(ctx, span)
+
+	filePath, err := parseResultsFilePath(req.URL.Query().Get("path"))
+	if err != nil {
+		httpError(res, req, bacerrors.NewBadRequest(err), http.StatusBadRequest)
+		return
+	}
+	shardIndex := 0
+	if value := req.URL.Query().Get("shard"); value != "" {
+		shardIndex, err = strconv.Atoi(value)
+		if err != nil || shardIndex < 0 {
+			httpError(res, req, fmt.Errorf("invalid shard index %q", value), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if apiServer.IPFSClient == nil {
+		httpError(res, req, bacerrors.NewNotImplemented(fmt.Errorf("this node cannot download results")), http.StatusNotImplemented)
+		return
+	}
+
+	if _, err = apiServer.localdb.GetJob(ctx, jobID); err != nil {
+		if _, ok := err.(*bacerrors.JobNotFound); ok {
+			httpError(res, req, err, http.StatusNotFound)
+			return
+		}
+		httpError(res, req, err, http.StatusInternalServerError)
+		return
+	}
+
+	results, err := localdb.GetStateResolver(apiServer.localdb).GetResults(ctx, jobID)
+	if err != nil {
+		httpError(res, req, err, http.StatusInternalServerError)
+		return
+	}
+	results = filterShardResults(results, []int{shardIndex})
+	if len(results) == 0 || results[0].Data.CID == "" {
+		httpError(res, req, bacerrors.NewNotFound(fmt.Errorf("shard %d of job %s has no results yet", shardIndex, jobID)), http.StatusNotFound)
+		return
+	}
+
+	// nodes that agreed on the results published the same files, so the
+	// file is read from the results of the first
+	file, err := apiServer.IPFSClient.GetFile(ctx, results[0].Data.CID, filePath)
+	if err != nil {
+		log.Ctx(ctx).Debug().Err(err).Msgf("error getting %s from the results of job %s", filePath, jobID)
+		httpError(res, req, bacerrors.NewNotFound(fmt.Errorf("%s is not a file in the results of shard %d", filePath, shardIndex)),
+			http.StatusNotFound)
+		return
+	}
+	defer file.Close()
+
+	// the results are immutable, so their CID and the path identify the file
+	res.Header().Set("ETag", strconv.Quote(results[0].Data.CID+"/"+filePath))
+	http.ServeContent(res, req, path.Base(filePath), time.Time{}, file)
+}
+
+// parseResultsFilePath returns the clean, slash-separated path of a file
+// relative to the root of the results.
+func parseResultsFilePath(value string) (string, error) {
+	cleaned := path.Clean("/" + value)
+	if value == "" || cleaned == "/" {
+		return "", fmt.Errorf("the path of a file in the results is required")
+	}
+	if cleaned != "/"+strings.TrimPrefix(value, "/") {
+		return "", fmt.Errorf("invalid path %q, must be a clean path in the results", value)
+	}
+	return strings.TrimPrefix(cleaned, "/"), nil
+}
@@ -0,0 +1,98 @@
+package publicapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/filecoin-project/bacalhau/pkg/bacerrors"
+	"github.com/filecoin-project/bacalhau/pkg/localdb"
+	"github.com/filecoin-project/bacalhau/pkg/publicapi/handlerwrapper"
+	"github.com/filecoin-project/bacalhau/pkg/system"
+	"github.com/rs/zerolog/log"
+)
+
+// defaultSearchMaxJobs is how many jobs a search returns unless asked for
+// another number.
+const defaultSearchMaxJobs = 100
+
+type searchRequest struct {
+	ClientID    string   `json:"client_id,omitempty" example:"ac13188e93c97a9c2e7cf8e86c7313156a73436036f30da1ececc2ce79f9ea51"`
+	Image       string   `json:"image,omitempty" example:"ubuntu"`
+	Labels      []string `json:"labels,omitempty" example:"team=ml,!experiment"`
+	InputCID    string   `json:"input_cid,omitempty" example:"QmTVmC7JBD2ES2qGPqBNVWnX1KeEPNrPGb7rJ8cpFgtefe"`
+	OutputCID   string   `json:"output_cid,omitempty" example:"QmWUXBndMuq2G6B6ndQCmkRHjZ6CvyJ8qLxXBG3YsSFzQG"`
+	MaxJobs     int      `json:"max_jobs,omitempty" example:"10"`
+	SortBy      string   `json:"sort_by,omitempty" example:"created_at"`
+	SortReverse bool     `json:"sort_reverse,omitempty"`
+}
+
+func (r searchRequest) query() localdb.JobQuery {
+	maxJobs := r.MaxJobs
+	if maxJobs <= 0 {
+		maxJobs = defaultSearchMaxJobs
+	}
+	return localdb.JobQuery{
+		ClientID:    r.ClientID,
+		Limit:       maxJobs,
+		SortBy:      r.SortBy,
+		SortReverse: r.SortReverse,
+		Image:       r.Image,
+		Labels:      r.Labels,
+		InputCID:    r.InputCID,
+		OutputCID:   r.OutputCID,
+	}
+}
+
+// search godoc
+// @ID                   pkg/publicapi.search
+// @Summary              Searches jobs by image, client, labels and CIDs.
+// @Description.markdown endpoints_search
+// @Tags                 Job
+// @Accept               json
+// @Produce              json
+// @Param                searchRequest body     searchRequest true "Jobs must match all the filters that are set. At least one must be."
+// @Success              200           {object} listResponse
+// @Failure              400           {object} bacerrors.ErrorResponse
+// @Failure              500           {object} bacerrors.ErrorResponse
+// @Router               /search [post]
+func (apiServer *APIServer) search(res http.ResponseWriter, req *http.Request) {
+	ctx, span := system.GetSpanFromRequest(req, "pkg/publicapi.search")
+	defer span.End()
+
+	var searchReq searchRequest
+	if err := json.NewDecoder(req.Body).Decode(&searchReq); err != nil {
+		httpError(res, req, bacerrors.NewBadRequest(err), http.StatusBadRequest)
+		return
+	}
+	res.Header().Set(handlerwrapper.HTTPHeaderClientID, searchReq.ClientID)
+
+	query := searchReq.query()
+	if query.ClientID == "" && !query.IsSearch() {
+		httpError(res, req, bacerrors.NewBadRequest(errors.New("no search filters given")), http.StatusBadRequest)
+		return
+	}
+	if err := localdb.ValidateSearch(query); err != nil {
+		httpError(res, req, bacerrors.NewBadRequest(err), http.StatusBadRequest)
+		return
+	}
+
+	jobList, err := apiServer.localdb.GetJobs(ctx, query)
+	if err != nil {
+		httpError(res, req, err, http.StatusInternalServerError)
+		return
+	}
+	if err = apiServer.getJobStates(ctx, jobList); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("error getting job states")
+		httpError(res, req, err, http.StatusInternalServerError)
+		return
+	}
+	res.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(res).Encode(listResponse{
+		Jobs: jobList,
+	})
+	if err != nil {
+		httpError(res, req, err, http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,144 @@
+package publicapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/filecoin-project/bacalhau/pkg/bacerrors"
+	"github.com/filecoin-project/bacalhau/pkg/job"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/publicapi/handlerwrapper"
+	"github.com/filecoin-project/bacalhau/pkg/system"
+)
+
+type shardsRequest struct {
+	ClientID string `json:"client_id" example:"ac13188e93c97a9c2e7cf8e86c7313156a73436036f30da1ececc2ce79f9ea51"`
+	JobID    string `json:"job_id" example:"9304c616-291f-41ad-b862-54e133c0149e"`
+	// The shards to return, all of them if empty.
+	ShardIndexes []int `json:"shard_indexes,omitempty" example:"0,1"`
+	// Whether to only return the shards that have published results.
+	CompletedOnly bool `json:"completed_only,omitempty"`
+}
+
+type shardsResponse struct {
+	Shards []ShardSummary `json:"shards"`
+}
+
+// ShardSummary describes where a shard of a job has got to, and its results
+// once they have been published.
+type ShardSummary struct {
+	ShardIndex int `json:"shard_index"`
+	// Whether every node running the shard has reached a terminal state.
+	Finished bool `json:"finished"`
+	// The most advanced state any node running the shard is in.
+	State string `json:"state"`
+	// The state of the shard on each node running it.
+	States []model.JobShardState `json:"states"`
+	// The published results of the shard, which can be pulled by CID.
+	Results []model.PublishedResult `json:"results"`
+}
+
+// shards godoc
+// @ID                   pkg/publicapi/shards
+// @Summary              Returns the state and results of each shard of a job.
+// @Description.markdown endpoints_shards
+// @Tags                 Job
+// @Accept               json
+// @Produce              json
+// @Param                shardsRequest body     shardsRequest true " "
+// @Success              200           {object} shardsResponse
+// @Failure              400           {object} bacerrors.ErrorResponse
+// @Failure              500           {object} bacerrors.ErrorResponse
+// @Router               /shards [post]
+func (apiServer *APIServer) shards(res http.ResponseWriter, req *http.Request) {
+	ctx, span := system.GetSpanFromRequest(req, "pkg/publicapi/shards")
+	defer span.End()
+
+	var shardsReq shardsRequest
+	if err := json.NewDecoder(req.Body).Decode(&shardsReq); err != nil {
+		httpError(res, req, bacerrors.NewBadRequest(err), http.StatusBadRequest)
+		return
+	}
+	res.Header().Set(handlerwrapper.HTTPHeaderClientID, shardsReq.ClientID)
+	res.Header().Set(handlerwrapper.HTTPHeaderJobID, shardsReq.JobID)
+
+	ctx = system.AddJobIDToBaggage(ctx, shardsReq.JobID)
+	system.AddJobIDFromBaggageToSpan(ctx, span)
+
+	j, err := apiServer.localdb.GetJob(ctx, shardsReq.JobID)
+	if err != nil {
+		httpError(res, req, err, http.StatusBadRequest)
+		return
+	}
+	jobState, err := apiServer.localdb.GetJobState(ctx, j.ID)
+	if err != nil {
+		httpError(res, req, err, http.StatusInternalServerError)
+		return
+	}
+
+	totalShards := job.GetJobTotalShards(j)
+	shardIndexes := shardsReq.ShardIndexes
+	if len(shardIndexes) == 0 {
+		for i := 0; i < totalShards; i++ {
+			shardIndexes = append(shardIndexes, i)
+		}
+	}
+	for _, shardIndex := range shardIndexes {
+		if shardIndex < 0 || shardIndex >= totalShards {
+			err = fmt.Errorf("job %s has no shard %d, it has %d shards", j.ID, shardIndex, totalShards)
+			httpError(res, req, bacerrors.NewBadRequest(err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	summaries := []ShardSummary{}
+	for _, shardIndex := range shardIndexes {
+		summary := summarizeShard(j, jobState, shardIndex)
+		if shardsReq.CompletedOnly && len(summary.Results) == 0 {
+			continue
+		}
+		summaries = append(summaries, summary)
+	}
+
+	res.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(res).Encode(shardsResponse{
+		Shards: summaries,
+	})
+	if err != nil {
+		httpError(res, req, err, http.StatusInternalServerError)
+		return
+	}
+}
+
+func summarizeShard(j *model.Job, jobState model.JobState, shardIndex int) ShardSummary {
+	summary := ShardSummary{
+		ShardIndex: shardIndex,
+		States:     job.GetStatesForShardIndex(jobState, shardIndex),
+		Results:    []model.PublishedResult{},
+	}
+
+	var state model.JobStateType
+	terminal := 0
+	for _, shardState := range summary.States { //nolint:gocritic
+		if shardState.State > state {
+			state = shardState.State
+		}
+		if shardState.State.IsTerminal() {
+			terminal++
+		}
+	}
+	summary.State = state.String()
+	summary.Finished = terminal == len(summary.States) && terminal >= job.GetJobConcurrency(j)
+
+	for _, shardState := range job.GetCompletedVerifiedShardStates(jobState) { //nolint:gocritic
+		if shardState.ShardIndex == shardIndex {
+			summary.Results = append(summary.Results, model.PublishedResult{
+				NodeID:     shardState.NodeID,
+				ShardIndex: shardState.ShardIndex,
+				Data:       shardState.PublishedResult,
+			})
+		}
+	}
+	return summary
+}
@@ -0,0 +1,64 @@
+//go:build unit || !integration
+
+package publicapi
+
+import (
+	"testing"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSummarizeShard(t *testing.T) {
+	j := &model.Job{Deal: model.Deal{Concurrency: 2}}
+	completed := model.JobShardState{
+		NodeID:             "node-1",
+		ShardIndex:         0,
+		State:              model.JobStateCompleted,
+		VerificationResult: model.VerificationResult{Complete: true, Result: true},
+		PublishedResult:    model.StorageSpec{CID: "QmResult"},
+	}
+	jobState := model.JobState{Nodes: map[string]model.JobNodeState{
+		"node-1": {Shards: map[int]model.JobShardState{
+			0: completed,
+			1: {NodeID: "node-1", ShardIndex: 1, State: model.JobStateRunning},
+		}},
+		"node-2": {Shards: map[int]model.JobShardState{
+			0: {NodeID: "node-2", ShardIndex: 0, State: model.JobStateError},
+		}},
+	}}
+
+	summary := summarizeShard(j, jobState, 0)
+	require.Equal(t, 0, summary.ShardIndex)
+	require.True(t, summary.Finished)
+	require.Equal(t, model.JobStateCompleted.String(), summary.State)
+	require.Len(t, summary.States, 2)
+	require.Equal(t, []model.PublishedResult{{NodeID: "node-1", ShardIndex: 0, Data: completed.PublishedResult}}, summary.Results)
+
+	summary = summarizeShard(j, jobState, 1)
+	require.False(t, summary.Finished)
+	require.Equal(t, model.JobStateRunning.String(), summary.State)
+	require.Empty(t, summary.Results)
+}
+
+func TestParseShardIndexes(t *testing.T) {
+	shardIndexes, err := parseShardIndexes("")
+	require.NoError(t, err)
+	require.Empty(t, shardIndexes)
+
+	shardIndexes, err = parseShardIndexes("0, 2,5")
+	require.NoError(t, err)
+	require.Equal(t, []int{0, 2, 5}, shardIndexes)
+
+	for _, value := range []string{"a", "1,,2", "-1"} {
+		_, err = parseShardIndexes(value)
+		require.Error(t, err, value)
+	}
+}
+
+func TestFilterShardResults(t *testing.T) {
+	results := []model.PublishedResult{{ShardIndex: 0}, {ShardIndex: 1}, {ShardIndex: 2}}
+	require.Equal(t, results, filterShardResults(results, nil))
+	require.Equal(t, []model.PublishedResult{{ShardIndex: 0}, {ShardIndex: 2}}, filterShardResults(results, []int{2, 0}))
+	require.Empty(t, filterShardResults(results, []int{3}))
+}
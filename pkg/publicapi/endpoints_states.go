@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/filecoin-project/bacalhau/pkg/bacerrors"
 	"github.com/filecoin-project/bacalhau/pkg/model"
 	"github.com/filecoin-project/bacalhau/pkg/publicapi/handlerwrapper"
 	"github.com/filecoin-project/bacalhau/pkg/system"
@@ -28,8 +29,8 @@ type stateResponse struct {
 // @Produce              json
 // @Param                stateRequest body     stateRequest true " "
 // @Success              200          {object} stateResponse
-// @Failure              400          {object} string
-// @Failure              500          {object} string
+// @Failure              400          {object} bacerrors.ErrorResponse
+// @Failure              500          {object} bacerrors.ErrorResponse
 // @Router               /states [post]
 func (apiServer *APIServer) states(res http.ResponseWriter, req *http.Request) {
 	ctx, span := system.GetSpanFromRequest(req, "pkg/publicapi/states")
@@ -37,7 +38,7 @@ func (apiServer *APIServer) states(res http.ResponseWriter, req *http.Request) {
 
 	var stateReq stateRequest
 	if err := json.NewDecoder(req.Body).Decode(&stateReq); err != nil {
-		http.Error(res, err.Error(), http.StatusBadRequest)
+		httpError(res, req, bacerrors.NewBadRequest(err), http.StatusBadRequest)
 		return
 	}
 	res.Header().Set(handlerwrapper.HTTPHeaderClientID, stateReq.ClientID)
@@ -46,7 +47,7 @@ func (apiServer *APIServer) states(res http.ResponseWriter, req *http.Request) {
 
 	js, err := getJobStateFromRequest(ctx, apiServer, stateReq)
 	if err != nil {
-		http.Error(res, err.Error(), http.StatusInternalServerError)
+		httpError(res, req, err, http.StatusInternalServerError)
 		return
 	}
 
@@ -55,7 +56,7 @@ func (apiServer *APIServer) states(res http.ResponseWriter, req *http.Request) {
 		State: js,
 	})
 	if err != nil {
-		http.Error(res, err.Error(), http.StatusInternalServerError)
+		httpError(res, req, err, http.StatusInternalServerError)
 		return
 	}
 }
@@ -2,8 +2,10 @@ package publicapi
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -27,6 +29,10 @@ type submitRequest struct {
 
 	// The base64-encoded public key of the client:
 	ClientPublicKey string `json:"client_public_key" validate:"required"`
+
+	// The rotations of the client's key, from the key its ID derives from to
+	// the public key, if it has rotated its key:
+	ClientKeyRotations []model.ClientKeyRotation `json:"client_key_rotations,omitempty"`
 }
 
 type submitResponse struct {
@@ -42,8 +48,8 @@ type submitResponse struct {
 // @Produce              json
 // @Param                submitRequest body     submitRequest true " "
 // @Success              200           {object} submitResponse
-// @Failure              400           {object} string
-// @Failure              500           {object} string
+// @Failure              400           {object} bacerrors.ErrorResponse
+// @Failure              500           {object} bacerrors.ErrorResponse
 // @Router               /submit [post]
 func (apiServer *APIServer) submit(res http.ResponseWriter, req *http.Request) {
 	ctx, span := system.GetSpanFromRequest(req, "pkg/apiServer.submit")
@@ -52,77 +58,28 @@ func (apiServer *APIServer) submit(res http.ResponseWriter, req *http.Request) {
 	var submitReq submitRequest
 	if err := json.NewDecoder(req.Body).Decode(&submitReq); err != nil {
 		log.Ctx(ctx).Debug().Msgf("====> Decode submitReq error: %s", err)
-		http.Error(res, bacerrors.ErrorToErrorResponse(err), http.StatusBadRequest)
+		httpError(res, req, bacerrors.NewBadRequest(err), http.StatusBadRequest)
 		return
 	}
 	res.Header().Set(handlerwrapper.HTTPHeaderClientID, submitReq.Data.ClientID)
 
-	if err := verifySubmitRequest(&submitReq); err != nil {
+	if err := apiServer.verifySubmitRequest(&submitReq); err != nil {
 		log.Ctx(ctx).Debug().Msgf("====> VerifySubmitRequest error: %s", err)
-		errorResponse := bacerrors.ErrorToErrorResponse(err)
-		http.Error(res, errorResponse, http.StatusBadRequest)
+		httpError(res, req, bacerrors.NewBadRequest(err), http.StatusBadRequest)
 		return
 	}
 
 	if err := job.VerifyJob(ctx, submitReq.Data.Job); err != nil {
 		log.Ctx(ctx).Debug().Msgf("====> VerifyJob error: %s", err)
-		errorResponse := bacerrors.ErrorToErrorResponse(err)
-		http.Error(res, errorResponse, http.StatusBadRequest)
+		httpError(res, req, bacerrors.NewSpecInvalid(err), http.StatusBadRequest)
 		return
 	}
 
 	// If we have a build context, pin it to IPFS and mount it in the job:
-	if submitReq.Data.Context != "" {
-		// TODO: gc pinned contexts
-		decoded, err := base64.StdEncoding.DecodeString(submitReq.Data.Context)
-		if err != nil {
-			log.Ctx(ctx).Debug().Msgf("====> DecodeContext error: %s", err)
-			errorResponse := bacerrors.ErrorToErrorResponse(err)
-			http.Error(res, errorResponse, http.StatusInternalServerError)
-			return
-		}
-
-		tmpDir, err := os.MkdirTemp("", "bacalhau-pin-context-")
-		if err != nil {
-			log.Ctx(ctx).Debug().Msgf("====> Create tmp dir error: %s", err)
-			errorResponse := bacerrors.ErrorToErrorResponse(err)
-			http.Error(res, errorResponse, http.StatusInternalServerError)
-			return
-		}
-
-		tarReader := bytes.NewReader(decoded)
-		err = targzip.Decompress(tarReader, filepath.Join(tmpDir, "context"))
-		if err != nil {
-			log.Ctx(ctx).Debug().Msgf("====> Decompress error: %s", err)
-			errorResponse := bacerrors.ErrorToErrorResponse(err)
-			http.Error(res, errorResponse, http.StatusInternalServerError)
-			return
-		}
-
-		// write the "context" for a job to storage
-		// this is used to upload code files
-		// we presently just fix on ipfs to do this
-		ipfsStorage, err := apiServer.StorageProviders.GetStorage(ctx, model.StorageSourceIPFS)
-		if err != nil {
-			log.Ctx(ctx).Debug().Msgf("====> GetStorage error: %s", err)
-			http.Error(res, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		result, err := ipfsStorage.Upload(ctx, filepath.Join(tmpDir, "context"))
-		if err != nil {
-			log.Ctx(ctx).Debug().Msgf("====> PinContext error: %s", err)
-			errorResponse := bacerrors.ErrorToErrorResponse(err)
-			http.Error(res, errorResponse, http.StatusInternalServerError)
-			return
-		}
-
-		// NOTE(luke): we could do some kind of storage multiaddr here, e.g.:
-		//               --cid ipfs:abc --cid filecoin:efg
-		submitReq.Data.Job.Spec.Contexts = append(submitReq.Data.Job.Spec.Contexts, model.StorageSpec{
-			StorageSource: model.StorageSourceIPFS,
-			CID:           result.CID,
-			Path:          "/job",
-		})
+	if err := apiServer.pinContext(ctx, &submitReq.Data); err != nil {
+		log.Ctx(ctx).Debug().Msgf("====> PinContext error: %s", err)
+		httpError(res, req, err, http.StatusInternalServerError)
+		return
 	}
 
 	j, err := apiServer.Requester.SubmitJob(
@@ -133,7 +90,7 @@ func (apiServer *APIServer) submit(res http.ResponseWriter, req *http.Request) {
 	span.SetAttributes(attribute.String(model.TracerAttributeNameJobID, j.ID))
 
 	if err != nil {
-		http.Error(res, err.Error(), http.StatusInternalServerError)
+		httpError(res, req, err, http.StatusInternalServerError)
 		return
 	}
 
@@ -142,7 +99,53 @@ func (apiServer *APIServer) submit(res http.ResponseWriter, req *http.Request) {
 		Job: j,
 	})
 	if err != nil {
-		http.Error(res, bacerrors.ErrorToErrorResponse(err), http.StatusInternalServerError)
+		httpError(res, req, err, http.StatusInternalServerError)
 		return
 	}
 }
+
+// pinContext pins the build context of a job, if it has one, to IPFS and
+// mounts it in the job.
+func (apiServer *APIServer) pinContext(ctx context.Context, data *model.JobCreatePayload) error {
+	if data.Context == "" {
+		return nil
+	}
+
+	// TODO: gc pinned contexts
+	decoded, err := base64.StdEncoding.DecodeString(data.Context)
+	if err != nil {
+		return fmt.Errorf("error decoding context: %w", err)
+	}
+
+	tmpDir, err := os.MkdirTemp("", "bacalhau-pin-context-")
+	if err != nil {
+		return err
+	}
+
+	tarReader := bytes.NewReader(decoded)
+	err = targzip.Decompress(tarReader, filepath.Join(tmpDir, "context"))
+	if err != nil {
+		return fmt.Errorf("error decompressing context: %w", err)
+	}
+
+	// write the "context" for a job to storage
+	// this is used to upload code files
+	// we presently just fix on ipfs to do this
+	ipfsStorage, err := apiServer.StorageProviders.GetStorage(ctx, model.StorageSourceIPFS)
+	if err != nil {
+		return err
+	}
+	result, err := ipfsStorage.Upload(ctx, filepath.Join(tmpDir, "context"))
+	if err != nil {
+		return err
+	}
+
+	// NOTE(luke): we could do some kind of storage multiaddr here, e.g.:
+	//               --cid ipfs:abc --cid filecoin:efg
+	data.Job.Spec.Contexts = append(data.Job.Spec.Contexts, model.StorageSpec{
+		StorageSource: model.StorageSourceIPFS,
+		CID:           result.CID,
+		Path:          "/job",
+	})
+	return nil
+}
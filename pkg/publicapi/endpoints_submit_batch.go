@@ -0,0 +1,159 @@
+package publicapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/filecoin-project/bacalhau/pkg/bacerrors"
+	"github.com/filecoin-project/bacalhau/pkg/job"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/publicapi/handlerwrapper"
+	"github.com/filecoin-project/bacalhau/pkg/system"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// MaxJobsPerBatch is the largest number of jobs that can be submitted in a
+// single batch. It's a variable to make it overridable during testing.
+var MaxJobsPerBatch = 100
+
+type submitBatchRequest struct {
+	// The jobs to submit:
+	Data model.JobBatchCreatePayload `json:"data" validate:"required"`
+
+	// A base64-encoded signature of the data, signed by the client:
+	ClientSignature string `json:"signature" validate:"required"`
+
+	// The base64-encoded public key of the client:
+	ClientPublicKey string `json:"client_public_key" validate:"required"`
+
+	// The rotations of the client's key, from the key its ID derives from to
+	// the public key, if it has rotated its key:
+	ClientKeyRotations []model.ClientKeyRotation `json:"client_key_rotations,omitempty"`
+}
+
+// SubmitBatchResult is the outcome of submitting one job of a batch. Either
+// Job or Error is set.
+type SubmitBatchResult struct {
+	Job   *model.Job `json:"job,omitempty"`
+	Error string     `json:"error,omitempty"`
+}
+
+type submitBatchResponse struct {
+	// The job group the submitted jobs are in:
+	JobGroup string `json:"job_group"`
+
+	// The results, in the same order as the jobs in the request:
+	Results []SubmitBatchResult `json:"results"`
+}
+
+// submitBatch godoc
+// @ID                   pkg/apiServer.submitBatch
+// @Summary              Submits many jobs to the network at once.
+// @Description.markdown endpoints_submit_batch
+// @Tags                 Job
+// @Accept               json
+// @Produce              json
+// @Param                submitBatchRequest body     submitBatchRequest true " "
+// @Success              200                {object} submitBatchResponse
+// @Failure              400                {object} bacerrors.ErrorResponse
+// @Failure              500                {object} bacerrors.ErrorResponse
+// @Router               /submit_batch [post]
+func (apiServer *APIServer) submitBatch(res http.ResponseWriter, req *http.Request) {
+	ctx, span := system.GetSpanFromRequest(req, "pkg/apiServer.submitBatch")
+	defer span.End()
+
+	var batchReq submitBatchRequest
+	if err := json.NewDecoder(req.Body).Decode(&batchReq); err != nil {
+		log.Ctx(ctx).Debug().Msgf("====> Decode submitBatchReq error: %s", err)
+		httpError(res, req, bacerrors.NewBadRequest(err), http.StatusBadRequest)
+		return
+	}
+	res.Header().Set(handlerwrapper.HTTPHeaderClientID, batchReq.Data.ClientID)
+
+	data := batchReq.Data
+	if err := apiServer.verifySignedPayload(data.ClientID, data, batchReq.ClientSignature, batchReq.ClientPublicKey, batchReq.ClientKeyRotations); err != nil {
+		log.Ctx(ctx).Debug().Msgf("====> VerifySubmitBatchRequest error: %s", err)
+		httpError(res, req, bacerrors.NewBadRequest(err), http.StatusBadRequest)
+		return
+	}
+	if len(data.Jobs) == 0 {
+		err := fmt.Errorf("the batch must contain at least one job")
+		httpError(res, req, bacerrors.NewBadRequest(err), http.StatusBadRequest)
+		return
+	}
+	if len(data.Jobs) > MaxJobsPerBatch {
+		err := fmt.Errorf("the batch contains %d jobs, more than the maximum of %d", len(data.Jobs), MaxJobsPerBatch)
+		httpError(res, req, bacerrors.NewBadRequest(err), http.StatusBadRequest)
+		return
+	}
+	span.SetAttributes(attribute.Int("BatchSize", len(data.Jobs)))
+
+	jobGroup, err := uuid.NewRandom()
+	if err != nil {
+		httpError(res, req, err, http.StatusInternalServerError)
+		return
+	}
+
+	results := make([]SubmitBatchResult, len(data.Jobs))
+	invalid := false
+	for i, j := range data.Jobs {
+		if err := job.VerifyJob(ctx, j); err != nil {
+			results[i].Error = err.Error()
+			invalid = true
+		}
+	}
+
+	failed := -1
+	for i, j := range data.Jobs {
+		if results[i].Error != "" {
+			continue
+		}
+		if invalid && data.Atomic {
+			results[i].Error = "not submitted as other jobs in the batch are invalid"
+			continue
+		}
+		if failed >= 0 && data.Atomic {
+			results[i].Error = fmt.Sprintf("not submitted as job %d of the batch failed to submit", failed)
+			continue
+		}
+
+		j.Spec.JobGroup = jobGroup.String()
+		submitted, err := apiServer.Requester.SubmitJob(ctx, model.JobCreatePayload{
+			ClientID: data.ClientID,
+			Job:      j,
+		})
+		if err != nil {
+			results[i].Error = err.Error()
+			failed = i
+			continue
+		}
+		results[i].Job = submitted
+	}
+
+	// some of the checks only happen as jobs are submitted, so the jobs of an
+	// atomic batch submitted before one fails are cancelled
+	if failed >= 0 && data.Atomic {
+		reason := fmt.Sprintf("job %d of the batch failed to submit", failed)
+		for i := 0; i < failed; i++ {
+			if err = apiServer.Requester.CancelJob(ctx, results[i].Job.ID, reason); err != nil {
+				results[i].Error = fmt.Sprintf("%s, and cancelling job %s failed: %s", reason, results[i].Job.ID, err)
+			} else {
+				results[i].Error = fmt.Sprintf("job %s cancelled as %s", results[i].Job.ID, reason)
+			}
+			results[i].Job = nil
+		}
+	}
+
+	res.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(res).Encode(submitBatchResponse{
+		JobGroup: jobGroup.String(),
+		Results:  results,
+	})
+	if err != nil {
+		httpError(res, req, err, http.StatusInternalServerError)
+		return
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/filecoin-project/bacalhau/pkg/bacerrors"
 	"github.com/filecoin-project/bacalhau/pkg/model"
 	"github.com/filecoin-project/bacalhau/pkg/system"
 	"github.com/filecoin-project/bacalhau/pkg/version"
@@ -26,8 +27,8 @@ type versionResponse struct {
 // @Produce     json
 // @Param       versionRequest body     versionRequest true "Request must specify a `client_id`. To retrieve your `client_id`, you can do the following: (1) submit a dummy job to Bacalhau (or use one you created before), (2) run `bacalhau describe <job-id>` and fetch the `ClientID` field."
 // @Success     200            {object} versionResponse
-// @Failure     400            {object} string
-// @Failure     500            {object} string
+// @Failure     400            {object} bacerrors.ErrorResponse
+// @Failure     500            {object} bacerrors.ErrorResponse
 // @Router      /version [post]
 //
 //nolint:lll
@@ -41,7 +42,7 @@ func (apiServer *APIServer) version(res http.ResponseWriter, req *http.Request)
 	var versionReq versionRequest
 	err := json.NewDecoder(req.Body).Decode(&versionReq)
 	if err != nil {
-		http.Error(res, err.Error(), http.StatusBadRequest)
+		httpError(res, req, bacerrors.NewBadRequest(err), http.StatusBadRequest)
 		return
 	}
 	unMarshallSpan.End()
@@ -52,7 +53,7 @@ func (apiServer *APIServer) version(res http.ResponseWriter, req *http.Request)
 		VersionInfo: version.Get(),
 	})
 	if err != nil {
-		http.Error(res, err.Error(), http.StatusInternalServerError)
+		httpError(res, req, err, http.StatusInternalServerError)
 		return
 	}
 	respondingSpan.End()
@@ -0,0 +1,237 @@
+package publicapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/filecoin-project/bacalhau/pkg/bacerrors"
+	"github.com/filecoin-project/bacalhau/pkg/job"
+	"github.com/filecoin-project/bacalhau/pkg/localdb"
+	"github.com/filecoin-project/bacalhau/pkg/logger"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/publicapi/handlerwrapper"
+	"github.com/filecoin-project/bacalhau/pkg/system"
+	"github.com/rs/zerolog/log"
+)
+
+// MaxWaitTimeout is the longest a /wait request without a callback is held
+// open for. It has to stay below the server's write timeout.
+var MaxWaitTimeout = 10 * time.Second
+
+// MaxCallbackWait is the longest a job is waited for before its callback is
+// called anyway, reporting that the job has not finished.
+var MaxCallbackWait = 24 * time.Hour
+
+// The number of callbacks that can be waiting for their job at once.
+const maxPendingCallbacks = 1000
+
+// How long a callback request may take.
+const callbackTimeout = 30 * time.Second
+
+type waitRequest struct {
+	ClientID string `json:"client_id" example:"ac13188e93c97a9c2e7cf8e86c7313156a73436036f30da1ececc2ce79f9ea51"`
+	JobID    string `json:"job_id" example:"9304c616-291f-41ad-b862-54e133c0149e"`
+	// How long to wait for the job to finish, capped at MaxWaitTimeout.
+	TimeoutSeconds float64 `json:"timeout_seconds" example:"10"`
+	// If set, the request returns straight away and the response is posted
+	// to this URL once the job has finished.
+	CallbackURL string `json:"callback_url" example:"https://example.com/bacalhau/callback"`
+}
+
+// WaitResponse describes where a job has got to, and its results once it
+// has finished.
+type WaitResponse struct {
+	JobID string `json:"job_id"`
+	// Whether every shard of the job has reached a terminal state.
+	Finished bool `json:"finished"`
+	// The most advanced state any shard of the job is in.
+	State string `json:"state"`
+	// The published results of the job, which can be pulled by CID.
+	Results []model.PublishedResult `json:"results"`
+}
+
+// wait godoc
+// @ID                   pkg/publicapi/wait
+// @Summary              Waits for a job to finish and returns its results.
+// @Description.markdown endpoints_wait
+// @Tags                 Job
+// @Accept               json
+// @Produce              json
+// @Param                waitRequest body     waitRequest true " "
+// @Success              200         {object} WaitResponse
+// @Failure              400         {object} bacerrors.ErrorResponse
+// @Failure              500         {object} bacerrors.ErrorResponse
+// @Router               /wait [post]
+func (apiServer *APIServer) wait(res http.ResponseWriter, req *http.Request) {
+	ctx, span := system.GetSpanFromRequest(req, "pkg/publicapi/wait")
+	defer span.End()
+
+	var waitReq waitRequest
+	if err := json.NewDecoder(req.Body).Decode(&waitReq); err != nil {
+		httpError(res, req, bacerrors.NewBadRequest(err), http.StatusBadRequest)
+		return
+	}
+	res.Header().Set(handlerwrapper.HTTPHeaderClientID, waitReq.ClientID)
+	res.Header().Set(handlerwrapper.HTTPHeaderJobID, waitReq.JobID)
+	ctx = system.AddJobIDToBaggage(ctx, waitReq.JobID)
+
+	j, err := apiServer.localdb.GetJob(ctx, waitReq.JobID)
+	if err != nil {
+		httpError(res, req, err, http.StatusBadRequest)
+		return
+	}
+
+	var waitRes WaitResponse
+	if waitReq.CallbackURL != "" {
+		if err = apiServer.waitWithCallback(ctx, j, waitReq.CallbackURL); err != nil {
+			httpError(res, req, err, http.StatusBadRequest)
+			return
+		}
+		waitRes, err = apiServer.getWaitResponse(ctx, j)
+	} else {
+		timeout := time.Duration(waitReq.TimeoutSeconds * float64(time.Second))
+		if timeout <= 0 || timeout > MaxWaitTimeout {
+			timeout = MaxWaitTimeout
+		}
+		waitRes, err = apiServer.waitForJob(ctx, j, timeout)
+	}
+	if err != nil {
+		httpError(res, req, err, http.StatusInternalServerError)
+		return
+	}
+
+	res.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(res).Encode(waitRes)
+	if err != nil {
+		httpError(res, req, err, http.StatusInternalServerError)
+		return
+	}
+}
+
+// waitForJob returns once the job has finished or the timeout has passed,
+// whichever comes first.
+func (apiServer *APIServer) waitForJob(ctx context.Context, j *model.Job, timeout time.Duration) (WaitResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for {
+		// register before checking so that no event is missed in between
+		changed := apiServer.addJobWaiter(j.ID)
+		waitRes, err := apiServer.getWaitResponse(ctx, j)
+		if err != nil || waitRes.Finished {
+			apiServer.removeJobWaiter(j.ID, changed)
+			return waitRes, err
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			apiServer.removeJobWaiter(j.ID, changed)
+			// the state is read with a fresh context as ours has expired
+			return apiServer.getWaitResponse(context.Background(), j)
+		}
+	}
+}
+
+func (apiServer *APIServer) getWaitResponse(ctx context.Context, j *model.Job) (WaitResponse, error) {
+	resolver := localdb.GetStateResolver(apiServer.localdb)
+	jobState, err := resolver.GetJobState(ctx, j.ID)
+	if err != nil {
+		return WaitResponse{}, err
+	}
+	finished, err := job.WaitForTerminalStates(job.GetJobTotalExecutionCount(j))(jobState)
+	if err != nil {
+		return WaitResponse{}, err
+	}
+	state, err := resolver.StateSummary(ctx, j.ID)
+	if err != nil {
+		return WaitResponse{}, err
+	}
+	results, err := resolver.GetResults(ctx, j.ID)
+	if err != nil {
+		return WaitResponse{}, err
+	}
+	return WaitResponse{
+		JobID:    j.ID,
+		Finished: finished,
+		State:    state,
+		Results:  results,
+	}, nil
+}
+
+// waitWithCallback waits for the job in the background and posts the
+// outcome to callbackURL.
+func (apiServer *APIServer) waitWithCallback(ctx context.Context, j *model.Job, callbackURL string) error {
+	if err := apiServer.validateCallbackURL(ctx, callbackURL); err != nil {
+		return err
+	}
+
+	apiServer.jobWaitersMutex.Lock()
+	if apiServer.pendingCallbacks >= maxPendingCallbacks {
+		apiServer.jobWaitersMutex.Unlock()
+		return fmt.Errorf("too many callbacks are pending, try again later")
+	}
+	apiServer.pendingCallbacks++
+	apiServer.jobWaitersMutex.Unlock()
+
+	// the callback outlives the request
+	go func(ctx context.Context) {
+		defer func() {
+			apiServer.jobWaitersMutex.Lock()
+			defer apiServer.jobWaitersMutex.Unlock()
+			apiServer.pendingCallbacks--
+		}()
+
+		waitRes, err := apiServer.waitForJob(ctx, j, MaxCallbackWait)
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Msgf("error waiting for job %s to call %s", j.ID, callbackURL)
+			return
+		}
+		payload, err := json.Marshal(waitRes)
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Msgf("error marshaling callback payload for job %s", j.ID)
+			return
+		}
+		if err = apiServer.postJSON(ctx, callbackURL, payload, nil); err != nil {
+			log.Ctx(ctx).Warn().Err(err).Msgf("error calling back %s for job %s", callbackURL, j.ID)
+		}
+	}(logger.ContextWithNodeIDLogger(context.Background(), apiServer.Requester.ID))
+	return nil
+}
+
+func (apiServer *APIServer) addJobWaiter(jobID string) chan struct{} {
+	apiServer.jobWaitersMutex.Lock()
+	defer apiServer.jobWaitersMutex.Unlock()
+	changed := make(chan struct{})
+	apiServer.jobWaiters[jobID] = append(apiServer.jobWaiters[jobID], changed)
+	return changed
+}
+
+func (apiServer *APIServer) removeJobWaiter(jobID string, changed chan struct{}) {
+	apiServer.jobWaitersMutex.Lock()
+	defer apiServer.jobWaitersMutex.Unlock()
+	waiters := apiServer.jobWaiters[jobID]
+	for i, waiter := range waiters {
+		if waiter == changed {
+			apiServer.jobWaiters[jobID] = append(waiters[:i], waiters[i+1:]...)
+			break
+		}
+	}
+	if len(apiServer.jobWaiters[jobID]) == 0 {
+		delete(apiServer.jobWaiters, jobID)
+	}
+}
+
+// notifyJobWaiters wakes up the requests waiting for a job after one of its
+// events has been applied, so that they check its state again.
+func (apiServer *APIServer) notifyJobWaiters(jobID string) {
+	apiServer.jobWaitersMutex.Lock()
+	defer apiServer.jobWaitersMutex.Unlock()
+	for _, changed := range apiServer.jobWaiters[jobID] {
+		close(changed)
+	}
+	delete(apiServer.jobWaiters, jobID)
+}
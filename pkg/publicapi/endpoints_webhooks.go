@@ -0,0 +1,126 @@
+package publicapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/filecoin-project/bacalhau/pkg/bacerrors"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/publicapi/handlerwrapper"
+	"github.com/filecoin-project/bacalhau/pkg/system"
+	"github.com/rs/zerolog/log"
+)
+
+type webhookRequest struct {
+	// The webhook to register or unregister:
+	Data model.WebhookPayload `json:"data" validate:"required"`
+
+	// A base64-encoded signature of the data, signed by the client:
+	ClientSignature string `json:"signature" validate:"required"`
+
+	// The base64-encoded public key of the client:
+	ClientPublicKey string `json:"client_public_key" validate:"required"`
+
+	// The rotations of the client's key, from the key its ID derives from to
+	// the public key, if it has rotated its key:
+	ClientKeyRotations []model.ClientKeyRotation `json:"client_key_rotations,omitempty"`
+}
+
+type webhookResponse struct{}
+
+// registerWebhook godoc
+// @ID                   pkg/apiServer.registerWebhook
+// @Summary              Registers a webhook that is called when jobs change state.
+// @Description.markdown endpoints_webhooks
+// @Tags                 Job
+// @Accept               json
+// @Produce              json
+// @Param                webhookRequest body     webhookRequest true " "
+// @Success              200            {object} webhookResponse
+// @Failure              400            {object} bacerrors.ErrorResponse
+// @Router               /register_webhook [post]
+func (apiServer *APIServer) registerWebhook(res http.ResponseWriter, req *http.Request) {
+	apiServer.handleWebhook(res, req, "pkg/apiServer.registerWebhook", func(data model.WebhookPayload) error {
+		if err := apiServer.validateCallbackURL(req.Context(), data.URL); err != nil {
+			return err
+		}
+		if data.Secret == "" {
+			return errors.New("a secret to sign the webhook's payloads with is required")
+		}
+		return apiServer.webhooks.register(data.ClientID, data.JobID, webhook{url: data.URL, secret: data.Secret})
+	})
+}
+
+// unregisterWebhook godoc
+// @ID                   pkg/apiServer.unregisterWebhook
+// @Summary              Unregisters a webhook.
+// @Description.markdown endpoints_webhooks
+// @Tags                 Job
+// @Accept               json
+// @Produce              json
+// @Param                webhookRequest body     webhookRequest true " "
+// @Success              200            {object} webhookResponse
+// @Failure              400            {object} bacerrors.ErrorResponse
+// @Router               /unregister_webhook [post]
+func (apiServer *APIServer) unregisterWebhook(res http.ResponseWriter, req *http.Request) {
+	apiServer.handleWebhook(res, req, "pkg/apiServer.unregisterWebhook", func(data model.WebhookPayload) error {
+		return apiServer.webhooks.unregister(data.ClientID, data.JobID, data.URL)
+	})
+}
+
+// handleWebhook checks that a webhook request was signed by its client, and
+// that the client owns the job it is for, before applying it.
+func (apiServer *APIServer) handleWebhook(
+	res http.ResponseWriter, req *http.Request, spanName string, apply func(model.WebhookPayload) error) {
+	ctx, span := system.GetSpanFromRequest(req, spanName)
+	defer span.End()
+
+	var webhookReq webhookRequest
+	if err := json.NewDecoder(req.Body).Decode(&webhookReq); err != nil {
+		log.Ctx(ctx).Debug().Msgf("====> Decode webhookReq error: %s", err)
+		httpError(res, req, bacerrors.NewBadRequest(err), http.StatusBadRequest)
+		return
+	}
+	data := webhookReq.Data
+	res.Header().Set(handlerwrapper.HTTPHeaderClientID, data.ClientID)
+	res.Header().Set(handlerwrapper.HTTPHeaderJobID, data.JobID)
+
+	if err := apiServer.verifySignedPayload(data.ClientID, data, webhookReq.ClientSignature, webhookReq.ClientPublicKey, webhookReq.ClientKeyRotations); err != nil {
+		log.Ctx(ctx).Debug().Msgf("====> VerifyWebhookRequest error: %s", err)
+		httpError(res, req, bacerrors.NewBadRequest(err), http.StatusBadRequest)
+		return
+	}
+	if err := apiServer.checkJobOwner(ctx, data.ClientID, data.JobID); err != nil {
+		httpError(res, req, err, http.StatusBadRequest)
+		return
+	}
+	if err := apply(data); err != nil {
+		httpError(res, req, err, http.StatusBadRequest)
+		return
+	}
+
+	res.WriteHeader(http.StatusOK)
+	err := json.NewEncoder(res).Encode(webhookResponse{})
+	if err != nil {
+		httpError(res, req, err, http.StatusInternalServerError)
+		return
+	}
+}
+
+// checkJobOwner returns an error unless the job was created by the client,
+// or jobID is empty.
+func (apiServer *APIServer) checkJobOwner(ctx context.Context, clientID, jobID string) error {
+	if jobID == "" {
+		return nil
+	}
+	j, err := apiServer.localdb.GetJob(ctx, jobID)
+	if err != nil {
+		return err
+	}
+	if j.ClientID != clientID {
+		return errors.New("the job was created by another client")
+	}
+	return nil
+}
@@ -18,7 +18,7 @@ var upgrader = websocket.Upgrader{
 func (apiServer *APIServer) websocket(res http.ResponseWriter, req *http.Request) {
 	conn, err := upgrader.Upgrade(res, req, nil)
 	if err != nil {
-		http.Error(res, err.Error(), http.StatusInternalServerError)
+		httpError(res, req, err, http.StatusInternalServerError)
 		return
 	}
 	log.Debug().Msgf("New websocket connection.")
@@ -106,5 +106,8 @@ func (apiServer *APIServer) HandleJobEvent(ctx context.Context, event model.JobE
 	}
 	dispatchAndCleanup("")
 	dispatchAndCleanup(event.JobID)
+	apiServer.eventSubscribers.dispatch(event)
+	apiServer.notifyJobWaiters(event.JobID)
+	apiServer.notifyWebhooks(ctx, event)
 	return nil
 }
@@ -0,0 +1,38 @@
+package publicapi
+
+import (
+	"net/http"
+
+	"github.com/filecoin-project/bacalhau/pkg/bacerrors"
+	"github.com/filecoin-project/bacalhau/pkg/publicapi/handlerwrapper"
+)
+
+// errorStatuses are the HTTP statuses of the errors that have one whatever
+// the endpoint that returns them.
+var errorStatuses = map[string]int{
+	bacerrors.ErrorCodeJobNotFound:    http.StatusNotFound,
+	bacerrors.ErrorCodeNotFound:       http.StatusNotFound,
+	bacerrors.ErrorCodeSpecInvalid:    http.StatusBadRequest,
+	bacerrors.ErrorCodeBadRequest:     http.StatusBadRequest,
+	bacerrors.ErrorCodeForbidden:      http.StatusForbidden,
+	bacerrors.ErrorCodeQuotaExceeded:  http.StatusTooManyRequests,
+	bacerrors.ErrorCodeNotImplemented: http.StatusNotImplemented,
+	bacerrors.ErrorCodeImageNotFound:  http.StatusBadRequest,
+}
+
+// httpError replies to the request with the error as a JSON
+// bacerrors.ErrorResponse, so that clients can tell errors apart by their
+// code. The status is that of the code of the error if it has one, or the
+// given status otherwise. The response carries the ID of the request, for
+// clients to quote when reporting the error.
+func httpError(res http.ResponseWriter, req *http.Request, err error, status int) {
+	errorResponse := bacerrors.ErrorToErrorResponseObject(err)
+	errorResponse.RequestID = handlerwrapper.RequestIDFromContext(req.Context())
+	if codeStatus, ok := errorStatuses[errorResponse.Code]; ok {
+		status = codeStatus
+	}
+	res.Header().Set("Content-Type", "application/json")
+	res.Header().Set("X-Content-Type-Options", "nosniff")
+	res.WriteHeader(status)
+	_, _ = res.Write([]byte(bacerrors.ConvertErrorToText(errorResponse) + "\n"))
+}
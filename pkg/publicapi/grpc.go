@@ -0,0 +1,259 @@
+package publicapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+
+	"github.com/filecoin-project/bacalhau/pkg/job"
+	"github.com/filecoin-project/bacalhau/pkg/logger"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/system"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// GRPCServiceName is the name of the gRPC service offered next to the REST API.
+const GRPCServiceName = "bacalhau.API"
+
+// jsonCodec encodes gRPC messages as JSON, so that the gRPC API uses the same
+// request and response types as the REST API and needs no generated code.
+// Clients must use the "json" content subtype.
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+func (jsonCodec) Name() string {
+	return "json"
+}
+
+// grpcAPI is implemented by APIServer and lets grpc check that the server it
+// registers has the handlers of the service.
+type grpcAPI interface {
+	grpcSubmit(ctx context.Context, req *submitRequest) (*submitResponse, error)
+	grpcList(ctx context.Context, req *listRequest) (*listResponse, error)
+	grpcEvents(ctx context.Context, req *eventsRequest) (*eventsResponse, error)
+	grpcSubscribeEvents(req *eventsRequest, stream grpc.ServerStream) error
+	grpcSubscribeLogs(req *jobLogsRequest, stream grpc.ServerStream) error
+}
+
+func unaryHandler[Req any, Res any](
+	method string,
+	call func(api grpcAPI, ctx context.Context, req *Req) (*Res, error),
+) grpc.MethodDesc {
+	return grpc.MethodDesc{
+		MethodName: method,
+		Handler: func(
+			srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor,
+		) (interface{}, error) {
+			req := new(Req)
+			if err := dec(req); err != nil {
+				return nil, err
+			}
+			if interceptor == nil {
+				return call(srv.(grpcAPI), ctx, req)
+			}
+			info := &grpc.UnaryServerInfo{
+				Server:     srv,
+				FullMethod: grpcMethod(method),
+			}
+			return interceptor(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+				return call(srv.(grpcAPI), ctx, req.(*Req))
+			})
+		},
+	}
+}
+
+func streamHandler[Req any](
+	stream string,
+	call func(api grpcAPI, req *Req, stream grpc.ServerStream) error,
+) grpc.StreamDesc {
+	return grpc.StreamDesc{
+		StreamName:    stream,
+		ServerStreams: true,
+		Handler: func(srv interface{}, stream grpc.ServerStream) error {
+			req := new(Req)
+			if err := stream.RecvMsg(req); err != nil {
+				return err
+			}
+			return call(srv.(grpcAPI), req, stream)
+		},
+	}
+}
+
+// grpcServiceDesc describes the gRPC API. Submit, List and Events mirror the
+// REST endpoints of the same name. SubscribeEvents streams the events of a job,
+// or of all jobs if no job ID is given, starting with those already known
+// for the job. SubscribeLogs streams the log lines of this node for a job,
+// starting with the recent ones the job_logs endpoint returns.
+var grpcServiceDesc = grpc.ServiceDesc{
+	ServiceName: GRPCServiceName,
+	HandlerType: (*grpcAPI)(nil),
+	Methods: []grpc.MethodDesc{
+		unaryHandler("Submit", grpcAPI.grpcSubmit),
+		unaryHandler("List", grpcAPI.grpcList),
+		unaryHandler("Events", grpcAPI.grpcEvents),
+	},
+	Streams: []grpc.StreamDesc{
+		streamHandler("SubscribeEvents", grpcAPI.grpcSubscribeEvents),
+		streamHandler("SubscribeLogs", grpcAPI.grpcSubscribeLogs),
+	},
+}
+
+// ListenAndServeGRPC serves the gRPC API on GRPCPort until the cleanup
+// manager shuts it down.
+func (apiServer *APIServer) ListenAndServeGRPC(ctx context.Context, cm *system.CleanupManager) error {
+	srv := grpc.NewServer(
+		grpc.ForceServerCodec(jsonCodec{}),
+		grpc.UnaryInterceptor(func(
+			ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler,
+		) (interface{}, error) {
+			return handler(logger.ContextWithNodeIDLogger(ctx, apiServer.Requester.ID), req)
+		}),
+	)
+	srv.RegisterService(&grpcServiceDesc, apiServer)
+	cm.RegisterCallback(func() error {
+		// event subscriptions never finish on their own, so don't wait for them
+		srv.Stop()
+		return nil
+	})
+
+	listener, err := net.Listen("tcp", fmt.Sprintf("%s:%d", apiServer.Host, apiServer.GRPCPort))
+	if err != nil {
+		return err
+	}
+	log.Ctx(ctx).Debug().Msgf("gRPC API server listening for host %s on %s...", apiServer.Requester.ID, listener.Addr())
+
+	err = srv.Serve(listener)
+	if err == grpc.ErrServerStopped {
+		return nil
+	}
+	return err
+}
+
+func (apiServer *APIServer) grpcSubmit(ctx context.Context, req *submitRequest) (*submitResponse, error) {
+	ctx, span := system.GetTracer().Start(ctx, "pkg/publicapi.grpcSubmit")
+	defer span.End()
+
+	if err := apiServer.verifySubmitRequest(req); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := job.VerifyJob(ctx, req.Data.Job); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := apiServer.pinContext(ctx, &req.Data); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	j, err := apiServer.Requester.SubmitJob(ctx, req.Data)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &submitResponse{Job: j}, nil
+}
+
+func (apiServer *APIServer) grpcList(ctx context.Context, req *listRequest) (*listResponse, error) {
+	jobList, err := apiServer.getJobsList(ctx, *req)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	if err = apiServer.getJobStates(ctx, jobList); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &listResponse{Jobs: jobList}, nil
+}
+
+func (apiServer *APIServer) grpcEvents(ctx context.Context, req *eventsRequest) (*eventsResponse, error) {
+	events, err := apiServer.localdb.GetJobEvents(ctx, req.JobID)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &eventsResponse{Events: events}, nil
+}
+
+func (apiServer *APIServer) grpcSubscribeEvents(req *eventsRequest, stream grpc.ServerStream) error {
+	ctx := stream.Context()
+
+	// subscribe before reading the stored events so that none are missed
+	events := apiServer.eventSubscribers.subscribe(req.JobID)
+	defer apiServer.eventSubscribers.unsubscribe(req.JobID, events)
+
+	seen := map[string]bool{}
+	if req.JobID != "" {
+		stored, err := apiServer.localdb.GetJobEvents(ctx, req.JobID)
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+		for _, event := range stored {
+			seen[eventKey(event)] = true
+			if err = stream.SendMsg(event); err != nil {
+				return err
+			}
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case streamed, ok := <-events:
+			if !ok {
+				return status.Error(codes.ResourceExhausted, "subscriber fell too far behind")
+			}
+			if seen[eventKey(streamed.Event)] {
+				continue
+			}
+			if err := stream.SendMsg(streamed.Event); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (apiServer *APIServer) grpcSubscribeLogs(req *jobLogsRequest, stream grpc.ServerStream) error {
+	ctx := stream.Context()
+	if req.JobID == "" {
+		return status.Error(codes.InvalidArgument, "job ID is required to subscribe to logs")
+	}
+
+	recent, lines, unsubscribe := logger.SubscribeJobLogs(req.JobID)
+	defer unsubscribe()
+	for _, line := range recent {
+		if err := stream.SendMsg(line); err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case line, ok := <-lines:
+			if !ok {
+				return status.Error(codes.ResourceExhausted, "subscriber fell too far behind")
+			}
+			if err := stream.SendMsg(line); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// eventKey identifies an event so that those read from the local DB are not
+// sent again when they are also dispatched to a new subscriber.
+func eventKey(event model.JobEvent) string {
+	bs, err := json.Marshal(event)
+	if err != nil {
+		return ""
+	}
+	return string(bs)
+}
@@ -0,0 +1,179 @@
+package publicapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/system"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// GRPCClient is a utility for interacting with a node's gRPC API.
+type GRPCClient struct {
+	conn *grpc.ClientConn
+}
+
+// NewGRPCClient returns a new client for the gRPC API served at address, in
+// host:port form.
+func NewGRPCClient(ctx context.Context, address string) (*GRPCClient, error) {
+	conn, err := grpc.DialContext(ctx, address,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(jsonCodec{})),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &GRPCClient{conn: conn}, nil
+}
+
+// Close closes the connection to the node.
+func (c *GRPCClient) Close() error {
+	return c.conn.Close()
+}
+
+// Submit submits a new job to the node's transport.
+func (c *GRPCClient) Submit(ctx context.Context, j *model.Job) (*model.Job, error) {
+	ctx, span := system.GetTracer().Start(ctx, "pkg/publicapi.GRPCClient.Submit")
+	defer span.End()
+
+	data := model.JobCreatePayload{
+		ClientID: system.GetClientID(),
+		Job:      j,
+	}
+	jsonData, err := model.JSONMarshalWithMax(data)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := system.SignForClient(jsonData)
+	if err != nil {
+		return nil, err
+	}
+
+	req := submitRequest{
+		Data:               data,
+		ClientSignature:    signature,
+		ClientPublicKey:    system.GetClientPublicKey(),
+		ClientKeyRotations: system.GetClientKeyRotations(),
+	}
+	var res submitResponse
+	if err = c.invoke(ctx, "Submit", &req, &res); err != nil {
+		return nil, err
+	}
+	return res.Job, nil
+}
+
+// List returns the jobs the node knows about, in the same way as
+// APIClient.List.
+func (c *GRPCClient) List(ctx context.Context, idFilter string, maxJobs int, returnAll bool, sortBy string, sortReverse bool) (
+	[]*model.Job, error) {
+	ctx, span := system.GetTracer().Start(ctx, "pkg/publicapi.GRPCClient.List")
+	defer span.End()
+
+	req := listRequest{
+		ClientID:    system.GetClientID(),
+		MaxJobs:     maxJobs,
+		JobID:       idFilter,
+		ReturnAll:   returnAll,
+		SortBy:      sortBy,
+		SortReverse: sortReverse,
+	}
+	var res listResponse
+	if err := c.invoke(ctx, "List", &req, &res); err != nil {
+		return nil, err
+	}
+	return res.Jobs, nil
+}
+
+// GetEvents returns the events of a job.
+func (c *GRPCClient) GetEvents(ctx context.Context, jobID string) ([]model.JobEvent, error) {
+	ctx, span := system.GetTracer().Start(ctx, "pkg/publicapi.GRPCClient.GetEvents")
+	defer span.End()
+
+	if jobID == "" {
+		return nil, fmt.Errorf("jobID must be non-empty in a GetEvents call")
+	}
+
+	req := eventsRequest{
+		ClientID: system.GetClientID(),
+		JobID:    jobID,
+	}
+	var res eventsResponse
+	if err := c.invoke(ctx, "Events", &req, &res); err != nil {
+		return nil, err
+	}
+	return res.Events, nil
+}
+
+// SubscribeEvents calls fn with the events of a job as they happen, starting
+// with those the node already has, or with the events of all new jobs if
+// jobID is empty. It returns when ctx is done, fn returns an error or the
+// stream ends.
+func (c *GRPCClient) SubscribeEvents(ctx context.Context, jobID string, fn func(model.JobEvent) error) error {
+	req := eventsRequest{
+		ClientID: system.GetClientID(),
+		JobID:    jobID,
+	}
+	return subscribe(ctx, c, "SubscribeEvents", &req, fn)
+}
+
+// SubscribeLogs calls fn with the log lines of the node for a job, as JSON
+// objects, as they are logged, starting with the recent ones the node still
+// has. It returns when ctx is done, fn returns an error or the stream ends.
+func (c *GRPCClient) SubscribeLogs(ctx context.Context, jobID string, fn func(json.RawMessage) error) error {
+	if jobID == "" {
+		return fmt.Errorf("jobID must be non-empty in a SubscribeLogs call")
+	}
+	req := jobLogsRequest{
+		ClientID: system.GetClientID(),
+		JobID:    jobID,
+	}
+	return subscribe(ctx, c, "SubscribeLogs", &req, fn)
+}
+
+// subscribe opens the server stream of the method with req, and calls fn
+// with each of the messages it streams.
+func subscribe[Msg any](ctx context.Context, c *GRPCClient, method string, req interface{}, fn func(Msg) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := c.conn.NewStream(ctx, &grpc.StreamDesc{StreamName: method, ServerStreams: true}, grpcMethod(method))
+	if err != nil {
+		return err
+	}
+	if err = stream.SendMsg(req); err != nil {
+		return err
+	}
+	if err = stream.CloseSend(); err != nil {
+		return err
+	}
+
+	for {
+		var msg Msg
+		err = stream.RecvMsg(&msg)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		if err = fn(msg); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *GRPCClient) invoke(ctx context.Context, method string, req, res interface{}) error {
+	return c.conn.Invoke(ctx, grpcMethod(method), req, res)
+}
+
+func grpcMethod(method string) string {
+	return fmt.Sprintf("/%s/%s", GRPCServiceName, method)
+}
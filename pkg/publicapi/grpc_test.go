@@ -0,0 +1,132 @@
+//go:build unit || !integration
+
+package publicapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/bacalhau/pkg/logger"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestGRPCSubmitAndList(t *testing.T) {
+	logger.ConfigureTestLogging(t)
+
+	c, cm := SetupRequesterNodeForGRPCTests(t, true)
+	defer cm.Cleanup()
+	ctx := context.Background()
+
+	j, err := c.Submit(ctx, MakeNoopJob())
+	require.NoError(t, err)
+	require.NotEmpty(t, j.ID)
+
+	jobs, err := c.List(ctx, j.ID, 1, false, "created_at", true)
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	require.Equal(t, j.ID, jobs[0].ID)
+
+	events, err := c.GetEvents(ctx, j.ID)
+	require.NoError(t, err)
+	require.NotEmpty(t, events)
+	require.Equal(t, model.JobEventCreated, events[0].EventName)
+}
+
+func TestGRPCSubmitInvalidJob(t *testing.T) {
+	logger.ConfigureTestLogging(t)
+
+	c, cm := SetupRequesterNodeForGRPCTests(t, true)
+	defer cm.Cleanup()
+
+	j := MakeNoopJob()
+	j.Deal.Concurrency = 0
+	_, err := c.Submit(context.Background(), j)
+	require.Error(t, err)
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestGRPCSubscribeEvents(t *testing.T) {
+	logger.ConfigureTestLogging(t)
+
+	c, cm := SetupRequesterNodeForGRPCTests(t, true)
+	defer cm.Cleanup()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// subscribing to all jobs sees new jobs as they are created
+	allEvents := make(chan model.JobEvent, 16)
+	go func() {
+		_ = c.SubscribeEvents(ctx, "", func(event model.JobEvent) error {
+			allEvents <- event
+			return nil
+		})
+	}()
+
+	var j *model.Job
+	require.Eventually(t, func() bool {
+		// the subscription may not be in place yet, so keep creating jobs
+		// until one is seen
+		var err error
+		j, err = c.Submit(ctx, MakeNoopJob())
+		require.NoError(t, err)
+		select {
+		case event := <-allEvents:
+			return event.EventName == model.JobEventCreated
+		case <-time.After(500 * time.Millisecond):
+			return false
+		}
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// subscribing to a job first replays the events it already has
+	errStop := errors.New("stop")
+	err := c.SubscribeEvents(ctx, j.ID, func(event model.JobEvent) error {
+		require.Equal(t, j.ID, event.JobID)
+		require.Equal(t, model.JobEventCreated, event.EventName)
+		return errStop
+	})
+	require.ErrorIs(t, err, errStop)
+}
+
+func TestGRPCSubscribeLogs(t *testing.T) {
+	logger.ConfigureTestLogging(t)
+
+	c, cm := SetupRequesterNodeForGRPCTests(t, true)
+	defer cm.Cleanup()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	jobCtx := logger.ContextWithJobShardLogger(ctx, "job-with-grpc-logs", 0)
+	log.Ctx(jobCtx).Info().Msg("before")
+
+	lines := make(chan string, 16)
+	go func() {
+		_ = c.SubscribeLogs(ctx, "job-with-grpc-logs", func(line json.RawMessage) error {
+			var fields struct {
+				JobID   string
+				Message string `json:"message"`
+			}
+			require.NoError(t, json.Unmarshal(line, &fields))
+			require.Equal(t, "job-with-grpc-logs", fields.JobID)
+			lines <- fields.Message
+			return nil
+		})
+	}()
+
+	// subscribing first replays the recent lines of the job
+	require.Equal(t, "before", <-lines)
+
+	// and then streams those logged since, but not those of other jobs
+	log.Ctx(logger.ContextWithJobShardLogger(ctx, "other-job", 0)).Info().Msg("other")
+	log.Ctx(jobCtx).Info().Msg("after")
+	require.Equal(t, "after", <-lines)
+
+	err := c.SubscribeLogs(ctx, "", func(json.RawMessage) error { return nil })
+	require.Error(t, err)
+}
@@ -0,0 +1,119 @@
+package handlerwrapper
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// minGzipSize is the smallest response worth compressing. Smaller ones fit in
+// a packet anyway.
+const minGzipSize = 1400
+
+// NewCacheableHandler serves responses that clients poll for, such as lists of
+// jobs, so that they cost as little to transfer as possible when they haven't
+// changed. Successful responses get an ETag from their content, and are
+// replaced by a 304 Not Modified when the client already has them, which it
+// says with If-None-Match. Otherwise, they are gzipped for clients that accept
+// it. Endpoints that only read state take their query as a POST body, so
+// If-None-Match is honoured whatever the method.
+func NewCacheableHandler(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		recorder := &bufferedResponseWriter{header: w.Header(), status: http.StatusOK}
+		handler(recorder, r)
+
+		body := recorder.body.Bytes()
+		if recorder.status != http.StatusOK {
+			w.WriteHeader(recorder.status)
+			_, _ = w.Write(body)
+			return
+		}
+
+		etag := contentETag(body)
+		w.Header().Set("ETag", etag)
+		w.Header().Add("Vary", "Accept-Encoding")
+		if etagMatches(r.Header.Get("If-None-Match"), etag) {
+			w.Header().Del("Content-Type")
+			w.Header().Del("Content-Length")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		if len(body) >= minGzipSize && acceptsGzip(r) && w.Header().Get("Content-Encoding") == "" {
+			var compressed bytes.Buffer
+			zw := gzip.NewWriter(&compressed)
+			if _, err := zw.Write(body); err == nil && zw.Close() == nil {
+				if w.Header().Get("Content-Type") == "" {
+					w.Header().Set("Content-Type", http.DetectContentType(body))
+				}
+				w.Header().Set("Content-Encoding", "gzip")
+				body = compressed.Bytes()
+			}
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(body)
+	}
+}
+
+// contentETag is a weak ETag, as the same content is served both gzipped and
+// not.
+func contentETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `W/"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatches returns whether the If-None-Match header lists the ETag, using
+// the weak comparison RFC 7232 asks for.
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+func acceptsGzip(r *http.Request) bool {
+	for _, encoding := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		encoding, params, _ := strings.Cut(strings.TrimSpace(encoding), ";")
+		if strings.EqualFold(encoding, "gzip") || encoding == "*" {
+			return strings.ReplaceAll(params, " ", "") != "q=0"
+		}
+	}
+	return false
+}
+
+// bufferedResponseWriter holds back the response of a handler, so that it can
+// be replaced once it is complete.
+type bufferedResponseWriter struct {
+	header      http.Header
+	body        bytes.Buffer
+	status      int
+	wroteHeader bool
+}
+
+func (b *bufferedResponseWriter) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedResponseWriter) Write(data []byte) (int, error) {
+	b.wroteHeader = true
+	return b.body.Write(data)
+}
+
+func (b *bufferedResponseWriter) WriteHeader(status int) {
+	if b.wroteHeader {
+		return
+	}
+	b.wroteHeader = true
+	b.status = status
+}
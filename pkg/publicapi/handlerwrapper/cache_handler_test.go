@@ -0,0 +1,69 @@
+//go:build unit || !integration
+
+package handlerwrapper
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func serveCacheable(t *testing.T, body string, status int, headers map[string]string) *http.Response {
+	handler := NewCacheableHandler(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	})
+	req := httptest.NewRequest(http.MethodPost, "/list", nil)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	recorder := httptest.NewRecorder()
+	handler(recorder, req)
+	return recorder.Result()
+}
+
+func TestCacheableHandlerETag(t *testing.T) {
+	res := serveCacheable(t, `{"jobs":[]}`, http.StatusOK, nil)
+	etag := res.Header.Get("ETag")
+	require.NotEmpty(t, etag)
+
+	res = serveCacheable(t, `{"jobs":[]}`, http.StatusOK, map[string]string{"If-None-Match": etag})
+	require.Equal(t, http.StatusNotModified, res.StatusCode)
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	require.Empty(t, body)
+
+	res = serveCacheable(t, `{"jobs":[{}]}`, http.StatusOK, map[string]string{"If-None-Match": etag})
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.NotEqual(t, etag, res.Header.Get("ETag"))
+}
+
+func TestCacheableHandlerGzip(t *testing.T) {
+	large := `{"jobs":"` + strings.Repeat("x", minGzipSize) + `"}`
+
+	res := serveCacheable(t, large, http.StatusOK, map[string]string{"Accept-Encoding": "gzip, deflate"})
+	require.Equal(t, "gzip", res.Header.Get("Content-Encoding"))
+	zr, err := gzip.NewReader(res.Body)
+	require.NoError(t, err)
+	body, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	require.Equal(t, large, string(body))
+
+	res = serveCacheable(t, large, http.StatusOK, nil)
+	require.Empty(t, res.Header.Get("Content-Encoding"))
+
+	res = serveCacheable(t, `{}`, http.StatusOK, map[string]string{"Accept-Encoding": "gzip"})
+	require.Empty(t, res.Header.Get("Content-Encoding"))
+}
+
+func TestCacheableHandlerPassesErrorsThrough(t *testing.T) {
+	res := serveCacheable(t, `{"Code":"error-bad-request"}`, http.StatusBadRequest, nil)
+	require.Equal(t, http.StatusBadRequest, res.StatusCode)
+	require.Empty(t, res.Header.Get("ETag"))
+}
@@ -3,3 +3,10 @@ package handlerwrapper
 var HTTPHeaderClientID = "X-Bacalhau-Client-ID"
 
 var HTTPHeaderJobID = "X-Bacalhau-Job-ID"
+
+// HTTPHeaderRequestID identifies a request in the logs of both the client and
+// the server. Clients can choose it, or the server picks one.
+var HTTPHeaderRequestID = "X-Request-ID"
+
+// HTTPHeaderTraceID is the OpenTelemetry trace of a request.
+var HTTPHeaderTraceID = "X-Bacalhau-Trace-ID"
@@ -26,7 +26,9 @@ func NewHTTPHandlerWrapper(
 
 // An HTTP handler that triggers another handler, capturs info about the request and calls request info handler.
 func (wrapper *HTTPHandlerWrapper) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r = WithRequestID(w, r)
 	ri := &HTTPRequestInfo{
+		RequestID: RequestIDFromContext(r.Context()),
 		Method:    r.Method,
 		URI:       r.URL.String(),
 		Referer:   r.Header.Get("Referer"),
@@ -44,6 +46,7 @@ func (wrapper *HTTPHandlerWrapper) ServeHTTP(w http.ResponseWriter, r *http.Requ
 	ri.Duration = m.Duration.Milliseconds()
 	ri.ClientID = w.Header().Get(HTTPHeaderClientID)
 	ri.JobID = w.Header().Get(HTTPHeaderJobID)
+	ri.TraceID = w.Header().Get(HTTPHeaderTraceID)
 	wrapper.requestInfoHandler.Handle(r.Context(), ri)
 }
 
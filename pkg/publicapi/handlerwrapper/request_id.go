@@ -0,0 +1,76 @@
+package handlerwrapper
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// maxRequestIDLength is the longest request ID taken from a client, so that
+// clients can't fill the logs with them.
+const maxRequestIDLength = 128
+
+type requestIDKey struct{}
+
+// RequestIDFromContext returns the ID of the request being served with the
+// context, or an empty string outside of a request.
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// WithRequestID gives the request the ID the client sent it with, or a new
+// one if it didn't send a usable one, and returns it with the ID in its
+// context and in the logger of its context. The ID is also sent back to the
+// client, so that it can be quoted when reporting failures.
+func WithRequestID(w http.ResponseWriter, r *http.Request) *http.Request {
+	requestID := r.Header.Get(HTTPHeaderRequestID)
+	if !isValidRequestID(requestID) {
+		requestID = uuid.NewString()
+	}
+	w.Header().Set(HTTPHeaderRequestID, requestID)
+
+	ctx := context.WithValue(r.Context(), requestIDKey{}, requestID)
+	ctx = log.Ctx(ctx).With().Str("RequestID", requestID).Logger().WithContext(ctx)
+	return r.WithContext(ctx)
+}
+
+// NewRequestIDHandler gives the requests the handler serves an ID, for
+// handlers that aren't wrapped by a HTTPHandlerWrapper.
+func NewRequestIDHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.ServeHTTP(w, WithRequestID(w, r))
+	})
+}
+
+// NewTraceLinkHandler links the trace of the request, which must have been
+// started before the handler is called, to the ID of the request, so that
+// the traces of the requests clients report failures of can be found.
+func NewTraceLinkHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		span := oteltrace.SpanFromContext(r.Context())
+		if requestID := RequestIDFromContext(r.Context()); requestID != "" {
+			span.SetAttributes(attribute.String("request_id", requestID))
+		}
+		if spanContext := span.SpanContext(); spanContext.HasTraceID() {
+			w.Header().Set(HTTPHeaderTraceID, spanContext.TraceID().String())
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+func isValidRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range requestID {
+		if c < '!' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
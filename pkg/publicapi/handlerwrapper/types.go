@@ -3,8 +3,10 @@ package handlerwrapper
 import "context"
 
 type HTTPRequestInfo struct {
-	JobID      string `json:"JobID,omitempty"` // bacalhau job id
-	URI        string `json:"URI"`             // GET etc.
+	RequestID  string `json:"RequestID"`         // the id of the request, sent back in X-Request-ID
+	TraceID    string `json:"TraceID,omitempty"` // the opentelemetry trace of the request
+	JobID      string `json:"JobID,omitempty"`   // bacalhau job id
+	URI        string `json:"URI"`               // GET etc.
 	Method     string `json:"Method"`
 	StatusCode int    `json:"StatusCode"` // response code, like 200, 404
 	Size       int64  `json:"Size"`       // number of bytes of the response sent
@@ -0,0 +1,61 @@
+package publicapi
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// maxPayloadAge is how long before or after the time of the node a nonced
+// payload can have been signed for it to be accepted.
+const maxPayloadAge = 5 * time.Minute
+
+// noncedPayload is a signed payload that is only accepted once, shortly after
+// it was signed, as replaying it would have the effect of the original.
+type noncedPayload interface {
+	GetTimestamp() time.Time
+	GetNonce() string
+}
+
+// nonces are the nonces of the payloads accepted, which are kept for as long
+// as their payloads would still be accepted.
+type nonces struct {
+	maxAge time.Duration
+
+	mutex sync.Mutex
+	used  map[string]time.Time
+}
+
+func newNonces(maxAge time.Duration) *nonces {
+	return &nonces{
+		maxAge: maxAge,
+		used:   map[string]time.Time{},
+	}
+}
+
+// use returns an error if the payload signed at the timestamp is too old or
+// too far in the future, or if the nonce was already used.
+func (n *nonces) use(nonce string, timestamp time.Time) error {
+	if nonce == "" {
+		return fmt.Errorf("payload must contain a nonce")
+	}
+	now := time.Now()
+	if timestamp.Before(now.Add(-n.maxAge)) || timestamp.After(now.Add(n.maxAge)) {
+		return fmt.Errorf("payload was signed at %s, more than %s from now", timestamp, n.maxAge)
+	}
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	for used, expiresAt := range n.used {
+		if now.After(expiresAt) {
+			delete(n.used, used)
+		}
+	}
+	if _, ok := n.used[nonce]; ok {
+		return fmt.Errorf("payload with nonce %s was already used", nonce)
+	}
+	// payloads signed up to maxAge in the future are accepted until maxAge
+	// after they were signed
+	n.used[nonce] = timestamp.Add(n.maxAge)
+	return nil
+}
@@ -9,12 +9,16 @@ import (
 	"time"
 
 	"github.com/filecoin-project/bacalhau/docs"
+	"github.com/filecoin-project/bacalhau/pkg/compute/frontend"
+	"github.com/filecoin-project/bacalhau/pkg/executor"
+	"github.com/filecoin-project/bacalhau/pkg/ipfs"
 	"github.com/filecoin-project/bacalhau/pkg/localdb"
 	"github.com/filecoin-project/bacalhau/pkg/logger"
 	"github.com/filecoin-project/bacalhau/pkg/model"
 	"github.com/filecoin-project/bacalhau/pkg/publicapi/handlerwrapper"
 	"github.com/filecoin-project/bacalhau/pkg/publisher"
 	"github.com/filecoin-project/bacalhau/pkg/requesternode"
+	"github.com/filecoin-project/bacalhau/pkg/selftest"
 	"github.com/filecoin-project/bacalhau/pkg/storage"
 	"github.com/filecoin-project/bacalhau/pkg/system"
 	"github.com/filecoin-project/bacalhau/pkg/transport"
@@ -35,6 +39,14 @@ import (
 // It's a variable to make this to make overrideble during testing.
 var MaxBytesToReadInBody = 10 * datasize.MB
 
+// CleanupName is the name the API server registers its shutdown with, for
+// other cleanups to be ordered against.
+const CleanupName = "publicapi"
+
+// shutdownTimeout is how long the requests being served are waited for when
+// the server shuts down.
+const shutdownTimeout = 10 * time.Second
+
 type APIServerConfig struct {
 	// These are TCP connection deadlines and not HTTP timeouts. They don't control the time it takes for our handlers
 	// to complete. Deadlines operate on the connection, so our server will fail to return a result only after
@@ -66,10 +78,42 @@ type APIServer struct {
 	StorageProviders   storage.StorageProvider
 	Host               string
 	Port               int
-	Config             *APIServerConfig
+	GRPCPort           int // the port to serve the gRPC API on, not served if 0
+	// the compute node of this node, that explains whether it would bid on
+	// dry run jobs, nil if this node doesn't run jobs
+	Compute frontend.Service
+	// fetches the results of jobs for /job/{id}/results/download and
+	// /job/{id}/results/file, nil if this node has no IPFS client
+	IPFSClient *ipfs.Client
+	// attaches shells to the environments this node keeps of failed shards
+	// for /debug_session, nil if it keeps none
+	DebugSessions executor.DebugSessions
+	// the networks webhooks and /wait callbacks can be posted to although
+	// their addresses are loopback, link-local or private
+	CallbackAllowedNetworks []*net.IPNet
+	// the checks of the node's environment that /healthz?deep=true runs
+	SelfTestChecks []selftest.Check
+	// the dependencies /readyz reports on, and those of them /livez reports
+	// on because the node can't recover from them breaking without a restart
+	ReadinessChecks []selftest.Check
+	LivenessChecks  []selftest.Check
+	Config          *APIServerConfig
 	// jobId or "" (for all events) -> connections for that subscription
 	Websockets      map[string][]*websocket.Conn
 	WebsocketsMutex sync.RWMutex
+	// the gRPC and server-sent event streams
+	eventSubscribers *eventSubscribers
+	// jobId -> channels closed when that job next changes, and the number of
+	// /wait callbacks still waiting for their job
+	jobWaiters       map[string][]chan struct{}
+	pendingCallbacks int
+	jobWaitersMutex  sync.Mutex
+	webhooks         *webhooks
+	callbackClient   *http.Client
+	// the keys clients have rotated out, and the nonces of the payloads
+	// accepted
+	clientKeys *clientKeys
+	nonces     *nonces
 }
 
 func init() { //nolint:gochecknoinits
@@ -128,7 +172,13 @@ func NewServerWithConfig(
 		Port:               port,
 		Config:             config,
 		Websockets:         make(map[string][]*websocket.Conn),
+		eventSubscribers:   newEventSubscribers(),
+		jobWaiters:         make(map[string][]chan struct{}),
+		webhooks:           newWebhooks(),
+		clientKeys:         newClientKeys(),
+		nonces:             newNonces(maxPayloadAge),
 	}
+	a.callbackClient = a.newCallbackClient()
 	return a
 }
 
@@ -158,14 +208,30 @@ func (apiServer *APIServer) ListenAndServe(ctx context.Context, cm *system.Clean
 
 	// TODO: #677 Significant issue, when client returns error to any of these commands, it still submits to server
 	sm := http.NewServeMux()
-	sm.Handle(apiServer.chainHandlers("/list", apiServer.list))
-	sm.Handle(apiServer.chainHandlers("/states", apiServer.states))
+	sm.Handle(apiServer.chainHandlers("/list", handlerwrapper.NewCacheableHandler(apiServer.list)))
+	sm.Handle(apiServer.chainHandlers("/search", handlerwrapper.NewCacheableHandler(apiServer.search)))
+	sm.Handle(apiServer.chainHandlers("/export", apiServer.export))
+	sm.Handle(apiServer.chainHandlers("/states", handlerwrapper.NewCacheableHandler(apiServer.states)))
 	sm.Handle(apiServer.chainHandlers("/results", apiServer.results))
-	sm.Handle(apiServer.chainHandlers("/events", apiServer.events))
-	sm.Handle(apiServer.chainHandlers("/local_events", apiServer.localEvents))
+	sm.Handle(apiServer.chainHandlers("/shards", apiServer.shards))
+	sm.Handle(apiServer.chainHandlers("/events", handlerwrapper.NewCacheableHandler(apiServer.events)))
+	sm.Handle(apiServer.chainHandlers("/local_events", handlerwrapper.NewCacheableHandler(apiServer.localEvents)))
+	sm.Handle(apiServer.chainHandlers("/job_logs", apiServer.jobLogs))
 	sm.Handle(apiServer.chainHandlers("/id", apiServer.id))
 	sm.Handle(apiServer.chainHandlers("/peers", apiServer.peers))
+	sm.Handle(apiServer.chainHandlers("/client_key", apiServer.clientKey))
 	sm.Handle(apiServer.chainHandlers("/submit", apiServer.submit))
+	sm.Handle(apiServer.chainHandlers("/submit_batch", apiServer.submitBatch))
+	sm.Handle(apiServer.chainHandlers("/dry_run", apiServer.dryRun))
+	sm.Handle(apiServer.chainHandlers("/cancel", apiServer.cancel))
+	sm.Handle(apiServer.chainHandlers("/approve", apiServer.approve))
+	sm.Handle(apiServer.chainHandlers("/approvals", apiServer.approvals))
+	sm.Handle(apiServer.chainHandlers("/moderate", apiServer.moderate))
+	sm.Handle(apiServer.chainHandlers("/denylist", handlerwrapper.NewCacheableHandler(apiServer.denylist)))
+	sm.Handle(apiServer.chainHandlers("/challenge", apiServer.challenge))
+	sm.Handle(apiServer.chainHandlers("/wait", apiServer.wait))
+	sm.Handle(apiServer.chainHandlers("/register_webhook", apiServer.registerWebhook))
+	sm.Handle(apiServer.chainHandlers("/unregister_webhook", apiServer.unregisterWebhook))
 	sm.Handle(apiServer.chainHandlers("/version", apiServer.version))
 	sm.Handle(apiServer.chainHandlers("/healthz", apiServer.healthz))
 	sm.Handle(apiServer.chainHandlers("/logz", apiServer.logz))
@@ -173,7 +239,12 @@ func (apiServer *APIServer) ListenAndServe(ctx context.Context, cm *system.Clean
 	sm.Handle(apiServer.chainHandlers("/livez", apiServer.livez))
 	sm.Handle(apiServer.chainHandlers("/readyz", apiServer.readyz))
 	sm.Handle(apiServer.chainHandlers("/debug", apiServer.debug))
-	sm.HandleFunc("/websocket", apiServer.websocket)
+	sm.Handle("/websocket", handlerwrapper.NewRequestIDHandler(http.HandlerFunc(apiServer.websocket)))
+	sm.Handle("/debug_session", handlerwrapper.NewRequestIDHandler(http.HandlerFunc(apiServer.debugSession)))
+	sm.Handle("/event_stream", handlerwrapper.NewRequestIDHandler(http.HandlerFunc(apiServer.eventStream)))
+	// not chained, as the timeout handler would buffer the whole tarball of
+	// a results download and cut long polls of a job wait short
+	sm.Handle(jobPathPrefix, handlerwrapper.NewRequestIDHandler(http.HandlerFunc(apiServer.jobRoutes)))
 	sm.Handle("/metrics", promhttp.Handler())
 	sm.Handle("/swagger/", httpSwagger.WrapHandler)
 
@@ -191,12 +262,10 @@ func (apiServer *APIServer) ListenAndServe(ctx context.Context, cm *system.Clean
 	log.Debug().Msgf(
 		"API server listening for host %s on %s...", hostID, srv.Addr)
 
-	// Cleanup resources when system is done:
-	cm.RegisterCallback(func() error {
-		// We have to use a separate context, rather than the one passed in, as it may have already been
-		// canceled and so would prevent us from performing any cleanup work.
-		return srv.Shutdown(context.Background())
-	})
+	// Cleanup resources when system is done, with the context of the cleanup rather than the one passed in, as it
+	// may have already been canceled and so would prevent us from performing any cleanup work. Requests still being
+	// served, such as long polls, are only waited for until the timeout.
+	cm.RegisterCallbackWithContext(srv.Shutdown, system.WithName(CleanupName), system.WithTimeout(shutdownTimeout))
 
 	err := srv.ListenAndServe()
 	if err == http.ErrServerClosed {
@@ -208,43 +277,55 @@ func (apiServer *APIServer) ListenAndServe(ctx context.Context, cm *system.Clean
 	return err
 }
 
-func verifySubmitRequest(req *submitRequest) error {
-	if req.Data.ClientID == "" {
+func (apiServer *APIServer) verifySubmitRequest(req *submitRequest) error {
+	return apiServer.verifySignedPayload(req.Data.ClientID, req.Data, req.ClientSignature, req.ClientPublicKey, req.ClientKeyRotations)
+}
+
+// verifySignedPayload checks that data was signed by the client with the
+// given ID, with its current key or with a key it has rotated out that has not
+// expired yet. rotations lead from the key the client ID derives from to the
+// public key.
+func (apiServer *APIServer) verifySignedPayload(
+	clientID string, data interface{}, signature, publicKey string, rotations []model.ClientKeyRotation,
+) error {
+	if clientID == "" {
 		return errors.New("job deal must contain a client ID")
 	}
-	if req.ClientSignature == "" {
+	if signature == "" {
 		return errors.New("client's signature is required")
 	}
-	if req.ClientPublicKey == "" {
+	if publicKey == "" {
 		return errors.New("client's public key is required")
 	}
 
-	// Check that the client's public key matches the client ID:
-	ok, err := system.PublicKeyMatchesID(req.ClientPublicKey, req.Data.ClientID)
-	if err != nil {
-		return fmt.Errorf("error verifying client ID: %w", err)
+	// Check that the client's public key is a key of the client ID:
+	if err := system.VerifyClientKey(clientID, publicKey, rotations); err != nil {
+		return err
 	}
-	if !ok {
-		return errors.New("client's public key does not match client ID")
+	if err := apiServer.clientKeys.check(clientID, publicKey, rotations); err != nil {
+		return err
 	}
 
 	// Check that the signature is valid:
-	jsonData, err := model.JSONMarshalWithMax(req.Data)
+	jsonData, err := model.JSONMarshalWithMax(data)
 	if err != nil {
 		return fmt.Errorf("error marshaling job data: %w", err)
 	}
 
-	err = system.Verify(jsonData, req.ClientSignature, req.ClientPublicKey)
+	err = system.Verify(jsonData, signature, publicKey)
 	if err != nil {
 		return fmt.Errorf("client's signature is invalid: %w", err)
 	}
 
+	if payload, ok := data.(noncedPayload); ok {
+		return apiServer.nonces.use(payload.GetNonce(), payload.GetTimestamp())
+	}
 	return nil
 }
 
 func (apiServer *APIServer) chainHandlers(uri string, handlerFunc http.HandlerFunc) (string, http.Handler) {
-	// otel handler
-	handler := otelhttp.NewHandler(handlerFunc, fmt.Sprintf("pkg/publicapi%s", uri))
+	// otel handler, with the trace linked to the request id
+	handler := otelhttp.NewHandler(handlerwrapper.NewTraceLinkHandler(handlerFunc), fmt.Sprintf("pkg/publicapi%s", uri))
 
 	// throttling handler
 	handler = tollbooth.LimitHandler(
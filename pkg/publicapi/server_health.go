@@ -1,15 +1,24 @@
 package publicapi
 
 import (
+	"context"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/selftest"
 	"github.com/filecoin-project/bacalhau/pkg/types"
 	"github.com/rs/zerolog/log"
 )
 
 var LINESOFLOGTOPRINT = 100
 
+// how long each check of /livez and /readyz can take before it fails
+var probeCheckTimeout = 5 * time.Second
+
 func GenerateHealthData() types.HealthInfo {
 	var healthInfo types.HealthInfo
 
@@ -23,21 +32,32 @@ func GenerateHealthData() types.HealthInfo {
 	return healthInfo
 }
 
+// ipfsStatus returns the health of the node's IPFS daemon, or nil if the
+// node doesn't have one.
+func (apiServer *APIServer) ipfsStatus() *types.IPFSStatus {
+	if apiServer.IPFSClient == nil {
+		return nil
+	}
+	health := apiServer.IPFSClient.Health()
+	return &types.IPFSStatus{
+		Available:   health.Available,
+		LastChecked: health.LastChecked,
+		Error:       health.Error,
+	}
+}
+
 // livez godoc
 // @ID      apiServer/livez
 // @Tags    Health
 // @Produce text/plain
-// @Success 200 {object} string "TODO"
+// @Param   verbose query    bool false "List the status of each dependency"
+// @Success 200     {object} string
+// @Failure 503     {object} string "A dependency the node can't recover without a restart is broken"
 // @Router  /livez [get]
 func (apiServer *APIServer) livez(res http.ResponseWriter, req *http.Request) {
 	// Extremely simple liveness check (should be fine to be public / no-auth)
 	log.Debug().Msg("Received OK request")
-	res.Header().Add("Content-Type", "text/plain")
-	res.WriteHeader(http.StatusOK)
-	_, err := res.Write([]byte("OK"))
-	if err != nil {
-		log.Warn().Msg("Error writing body for OK request.")
-	}
+	apiServer.probe(res, req, "OK", "livez", apiServer.LivenessChecks)
 }
 
 // logz godoc
@@ -50,7 +70,7 @@ func (apiServer *APIServer) logz(res http.ResponseWriter, req *http.Request) {
 	log.Debug().Msg("Received logz request")
 	res.Header().Add("Content-Type", "text/plain")
 	res.WriteHeader(http.StatusOK)
-	fileOutput, err := TailFile(LINESOFLOGTOPRINT, "/tmp/ipfs.log")
+	fileOutput, err := TailFile(req.Context(), LINESOFLOGTOPRINT, "/tmp/ipfs.log")
 	if err != nil {
 		missingLogFileMsg := "File not found at /tmp/ipfs.log"
 		log.Warn().Msgf(missingLogFileMsg)
@@ -70,21 +90,55 @@ func (apiServer *APIServer) logz(res http.ResponseWriter, req *http.Request) {
 // @ID      apiServer/readyz
 // @Tags    Health
 // @Produce text/plain
-// @Success 200 {object} string
+// @Param   verbose query    bool false "List the status of each dependency"
+// @Success 200     {object} string
+// @Failure 503     {object} string "A dependency of the node, such as its IPFS daemon, is unavailable"
 // @Router  /readyz [get]
 func (apiServer *APIServer) readyz(res http.ResponseWriter, req *http.Request) {
 	log.Debug().Msg("Received readyz request.")
 	// TODO: Add checker for queue that this node can accept submissions
-	isReady := true
+	var checks []selftest.Check
+	if apiServer.IPFSClient != nil {
+		// jobs can't fetch their inputs or publish their results without IPFS
+		checks = append(checks, selftest.NewCheck("ipfs", func(context.Context) error {
+			if ipfsStatus := apiServer.ipfsStatus(); !ipfsStatus.Available {
+				return fmt.Errorf("the IPFS daemon is unavailable: %s", ipfsStatus.Error)
+			}
+			return nil
+		}))
+	}
+	checks = append(checks, apiServer.ReadinessChecks...)
+	apiServer.probe(res, req, "READY", "readyz", checks)
+}
+
+// probe writes the outcome of a probe's checks in the format of Kubernetes'
+// own probes, listing the status of each check if any failed or if asked to
+// with ?verbose.
+func (apiServer *APIServer) probe(res http.ResponseWriter, req *http.Request, okMessage, name string, checks []selftest.Check) {
+	report := selftest.Run(req.Context(), probeCheckTimeout, checks)
+
+	var body strings.Builder
+	_, verbose := req.URL.Query()["verbose"]
+	if verbose || !report.Healthy {
+		for _, result := range report.Checks {
+			if result.Status == selftest.StatusFailed {
+				fmt.Fprintf(&body, "[-]%s failed: %s\n", result.Name, result.Message)
+			} else {
+				fmt.Fprintf(&body, "[+]%s ok\n", result.Name)
+			}
+		}
+	}
 
 	res.Header().Add("Content-Type", "text/plain")
-	if !isReady {
+	if report.Healthy {
+		res.WriteHeader(http.StatusOK)
+		body.WriteString(okMessage)
+	} else {
 		res.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintf(&body, "%s check failed", name)
 	}
-	res.WriteHeader(http.StatusOK)
-	_, err := res.Write([]byte("READY"))
-	if err != nil {
-		log.Warn().Msg("Error writing body for readyz request.")
+	if _, err := res.Write([]byte(body.String())); err != nil {
+		log.Warn().Msgf("Error writing body for %s request.", name)
 	}
 }
 
@@ -92,18 +146,30 @@ func (apiServer *APIServer) readyz(res http.ResponseWriter, req *http.Request) {
 // @ID      apiServer/healthz
 // @Tags    Health
 // @Produce json
-// @Success 200 {object} types.HealthInfo
+// @Param   deep query    bool false "Also check Docker, IPFS, disk space, outbound connectivity and clock skew"
+// @Success 200  {object} types.HealthInfo
+// @Failure 503  {object} types.HealthInfo "A check of the node's environment failed"
 // @Router  /healthz [get]
 func (apiServer *APIServer) healthz(res http.ResponseWriter, req *http.Request) {
 	// TODO: A list of health information. Should require authing (of some kind)
 	log.Debug().Msg("Received healthz request.")
 	res.Header().Add("Content-Type", "application/json")
-	res.WriteHeader(http.StatusOK)
 
 	// Ideas:
 	// CPU usage
 
 	healthInfo := GenerateHealthData()
+	healthInfo.IPFS = apiServer.ipfsStatus()
+
+	status := http.StatusOK
+	if deep, _ := strconv.ParseBool(req.URL.Query().Get("deep")); deep {
+		report := selftest.Run(req.Context(), selftest.DefaultCheckTimeout, apiServer.SelfTestChecks)
+		healthInfo.SelfTest = &report
+		if !report.Healthy {
+			status = http.StatusServiceUnavailable
+		}
+	}
+	res.WriteHeader(status)
 	healthJSONBlob, _ := model.JSONMarshalWithMax(healthInfo)
 
 	_, err := res.Write(healthJSONBlob)
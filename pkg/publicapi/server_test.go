@@ -4,15 +4,22 @@ package publicapi
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/filecoin-project/bacalhau/pkg/bacerrors"
+	"github.com/filecoin-project/bacalhau/pkg/ipfs"
 	"github.com/filecoin-project/bacalhau/pkg/logger"
 	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/publicapi/handlerwrapper"
+	"github.com/filecoin-project/bacalhau/pkg/selftest"
 	"github.com/filecoin-project/bacalhau/pkg/types"
+	"github.com/phayes/freeport"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 )
@@ -84,6 +91,104 @@ func (s *ServerSuite) TestReadyz() {
 	_ = testEndpoint(s.T(), "/readyz", "READY")
 }
 
+func (s *ServerSuite) TestProbeDependencies() {
+	port, err := freeport.GetFreePort()
+	require.NoError(s.T(), err)
+	server, c, cm := setupRequesterNodeForTests(s.T(), port, 0, DefaultAPIServerConfig, false)
+	defer cm.Cleanup()
+
+	var datastoreErr error
+	datastoreCheck := selftest.NewCheck("datastore", func(context.Context) error { return datastoreErr })
+	server.LivenessChecks = []selftest.Check{datastoreCheck}
+	server.ReadinessChecks = []selftest.Check{
+		datastoreCheck,
+		selftest.NewCheck("transport", func(context.Context) error { return nil }),
+	}
+
+	// the dependencies are only listed when asked for while they all work
+	body := testEndpointWithClient(s.T(), c, "/readyz", "READY")
+	require.Equal(s.T(), "READY", string(body))
+	body = testEndpointWithClient(s.T(), c, "/readyz?verbose", "READY")
+	require.Equal(s.T(), "[+]datastore ok\n[+]transport ok\nREADY", string(body))
+	body = testEndpointWithClient(s.T(), c, "/livez", "OK")
+	require.Equal(s.T(), "OK", string(body))
+
+	datastoreErr = fmt.Errorf("wedged")
+	for endpoint, expected := range map[string]string{
+		"/readyz": "[-]datastore failed: wedged\n[+]transport ok\nreadyz check failed",
+		"/livez":  "[-]datastore failed: wedged\nlivez check failed",
+	} {
+		res, err := http.Get(c.BaseURI + endpoint)
+		require.NoError(s.T(), err)
+		body, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		require.NoError(s.T(), err)
+		require.Equal(s.T(), http.StatusServiceUnavailable, res.StatusCode, endpoint)
+		require.Equal(s.T(), expected, string(body), endpoint)
+	}
+}
+
+func (s *ServerSuite) TestReadyzIPFSUnavailable() {
+	port, err := freeport.GetFreePort()
+	require.NoError(s.T(), err)
+	server, c, cm := setupRequesterNodeForTests(s.T(), port, 0, DefaultAPIServerConfig, false)
+	defer cm.Cleanup()
+
+	ipfsPort, err := freeport.GetFreePort()
+	require.NoError(s.T(), err)
+	server.IPFSClient, err = ipfs.NewClient(fmt.Sprintf("/ip4/127.0.0.1/tcp/%d", ipfsPort))
+	require.NoError(s.T(), err)
+	server.IPFSClient.CheckHealth(context.Background())
+
+	res, err := http.Get(c.BaseURI + "/readyz")
+	require.NoError(s.T(), err)
+	defer res.Body.Close()
+	require.Equal(s.T(), http.StatusServiceUnavailable, res.StatusCode)
+
+	rawHealthData := testEndpointWithClient(s.T(), c, "/healthz", "IPFS")
+	var healthData types.HealthInfo
+	require.NoError(s.T(), model.JSONUnmarshalWithMax(rawHealthData, &healthData))
+	require.NotNil(s.T(), healthData.IPFS)
+	require.False(s.T(), healthData.IPFS.Available)
+}
+
+func (s *ServerSuite) TestHealthzDeep() {
+	port, err := freeport.GetFreePort()
+	require.NoError(s.T(), err)
+	server, c, cm := setupRequesterNodeForTests(s.T(), port, 0, DefaultAPIServerConfig, false)
+	defer cm.Cleanup()
+
+	// the checks are only run when asked for
+	rawHealthData := testEndpointWithClient(s.T(), c, "/healthz", "FreeSpace")
+	require.NotContains(s.T(), string(rawHealthData), "SelfTest")
+
+	ipfsPort, err := freeport.GetFreePort()
+	require.NoError(s.T(), err)
+	ipfsClient, err := ipfs.NewClient(fmt.Sprintf("/ip4/127.0.0.1/tcp/%d", ipfsPort))
+	require.NoError(s.T(), err)
+	server.SelfTestChecks = []selftest.Check{
+		selftest.NewDiskSpaceCheck(s.T().TempDir(), 0, 0),
+		selftest.NewIPFSCheck(ipfsClient),
+	}
+
+	res, err := http.Get(c.BaseURI + "/healthz?deep=true")
+	require.NoError(s.T(), err)
+	defer res.Body.Close()
+	require.Equal(s.T(), http.StatusServiceUnavailable, res.StatusCode)
+
+	var healthData types.HealthInfo
+	body, err := ioutil.ReadAll(res.Body)
+	require.NoError(s.T(), err)
+	require.NoError(s.T(), model.JSONUnmarshalWithMax(body, &healthData))
+	require.NotNil(s.T(), healthData.SelfTest)
+	require.False(s.T(), healthData.SelfTest.Healthy)
+	require.Len(s.T(), healthData.SelfTest.Checks, 2)
+	require.Equal(s.T(), selftest.StatusOK, healthData.SelfTest.Checks[0].Status)
+	require.Equal(s.T(), "ipfs", healthData.SelfTest.Checks[1].Name)
+	require.Equal(s.T(), selftest.StatusFailed, healthData.SelfTest.Checks[1].Status)
+	require.NotEmpty(s.T(), healthData.SelfTest.Checks[1].Remedy)
+}
+
 func (s *ServerSuite) TestVarz() {
 	rawVarZBody := testEndpoint(s.T(), "/varz", "{")
 
@@ -150,10 +255,38 @@ func (s *ServerSuite) TestMaxBodyReader() {
 	}
 }
 
+func (s *ServerSuite) TestRequestID() {
+	c, cm := SetupRequesterNodeForTests(s.T(), false)
+	defer cm.Cleanup()
+
+	// the id the client sent is echoed, and quoted in errors
+	req, err := http.NewRequest(http.MethodPost, c.BaseURI+"/submit", strings.NewReader("not json"))
+	require.NoError(s.T(), err)
+	req.Header.Set(handlerwrapper.HTTPHeaderRequestID, "test-request")
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(s.T(), err)
+	defer res.Body.Close()
+	require.Equal(s.T(), http.StatusBadRequest, res.StatusCode)
+	require.Equal(s.T(), "test-request", res.Header.Get(handlerwrapper.HTTPHeaderRequestID))
+
+	var errorResponse bacerrors.ErrorResponse
+	require.NoError(s.T(), json.NewDecoder(res.Body).Decode(&errorResponse))
+	require.Equal(s.T(), "test-request", errorResponse.RequestID)
+
+	// requests without one are given one
+	res, err = http.Get(c.BaseURI + "/livez")
+	require.NoError(s.T(), err)
+	defer res.Body.Close()
+	require.NotEmpty(s.T(), res.Header.Get(handlerwrapper.HTTPHeaderRequestID))
+}
+
 func testEndpoint(t *testing.T, endpoint string, contentToCheck string) []byte {
 	c, cm := SetupRequesterNodeForTests(t, false)
 	defer cm.Cleanup()
+	return testEndpointWithClient(t, c, endpoint, contentToCheck)
+}
 
+func testEndpointWithClient(t *testing.T, c *APIClient, endpoint string, contentToCheck string) []byte {
 	res, err := http.Get(c.BaseURI + endpoint)
 	require.NoError(t, err, "Could not get %s endpoint.", endpoint)
 	defer res.Body.Close()
@@ -0,0 +1,121 @@
+package publicapi
+
+import (
+	"sync"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/rs/zerolog/log"
+)
+
+// The number of events a subscriber can fall behind by before it is dropped.
+const eventSubscriberBufferSize = 256
+
+// The number of recent events kept so that event streams can resume.
+const eventHistorySize = 1024
+
+// streamedEvent is an event along with its position in the sequence of
+// events handled by the server, which starts again from 1 when it restarts.
+type streamedEvent struct {
+	ID    uint64
+	Event model.JobEvent
+}
+
+// eventSubscribers hands the events of jobs to the streams subscribed to
+// them, other than websockets.
+type eventSubscribers struct {
+	mutex sync.Mutex
+	// jobId or "" (for all events) -> subscribers
+	subscribers map[string][]chan streamedEvent
+	lastID      uint64
+	// the most recent events, oldest first
+	history []streamedEvent
+}
+
+func newEventSubscribers() *eventSubscribers {
+	return &eventSubscribers{
+		subscribers: map[string][]chan streamedEvent{},
+	}
+}
+
+// subscribe returns a channel that receives the events of a job, or of all
+// jobs if jobID is empty. The channel is closed if the subscriber falls too
+// far behind.
+func (s *eventSubscribers) subscribe(jobID string) chan streamedEvent {
+	events, _, _ := s.subscribeAfter(jobID, 0, false)
+	return events
+}
+
+// subscribeAfter subscribes like subscribe and, if resume is set, also
+// returns the events since the one with ID lastID. complete is false if
+// some of them are no longer known.
+func (s *eventSubscribers) subscribeAfter(jobID string, lastID uint64, resume bool) (
+	events chan streamedEvent, missed []streamedEvent, complete bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	events = make(chan streamedEvent, eventSubscriberBufferSize)
+	s.subscribers[jobID] = append(s.subscribers[jobID], events)
+	if !resume {
+		return events, nil, true
+	}
+
+	oldestID := s.lastID + 1
+	if len(s.history) > 0 {
+		oldestID = s.history[0].ID
+	}
+	complete = lastID <= s.lastID && lastID+1 >= oldestID
+	for _, event := range s.history {
+		if event.ID > lastID && (jobID == "" || event.Event.JobID == jobID) {
+			missed = append(missed, event)
+		}
+	}
+	return events, missed, complete
+}
+
+func (s *eventSubscribers) unsubscribe(jobID string, events chan streamedEvent) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	subscribers := s.subscribers[jobID]
+	for i, subscriber := range subscribers {
+		if subscriber == events {
+			s.subscribers[jobID] = append(subscribers[:i], subscribers[i+1:]...)
+			break
+		}
+	}
+	if len(s.subscribers[jobID]) == 0 {
+		delete(s.subscribers, jobID)
+	}
+}
+
+// dispatch hands an event to the streams subscribed to it. Subscribers that
+// have fallen behind are dropped rather than slowing the event handlers down.
+func (s *eventSubscribers) dispatch(event model.JobEvent) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.lastID++
+	streamed := streamedEvent{ID: s.lastID, Event: event}
+	if len(s.history) == eventHistorySize {
+		s.history = append(s.history[:0], s.history[1:]...)
+	}
+	s.history = append(s.history, streamed)
+
+	for _, jobID := range []string{"", event.JobID} {
+		subscribers := s.subscribers[jobID]
+		kept := subscribers[:0]
+		for _, subscriber := range subscribers {
+			select {
+			case subscriber <- streamed:
+				kept = append(kept, subscriber)
+			default:
+				log.Warn().Msgf("event subscriber to %q fell behind, closing its stream", jobID)
+				close(subscriber)
+			}
+		}
+		if len(kept) == 0 {
+			delete(s.subscribers, jobID)
+		} else {
+			s.subscribers[jobID] = kept
+		}
+	}
+}
@@ -3,7 +3,7 @@ package publicapi
 import (
 	"context"
 	"fmt"
-	"os/exec"
+	"net"
 	"strconv"
 	"testing"
 	"time"
@@ -46,17 +46,71 @@ func SetupRequesterNodeForTestsWithConfig(t *testing.T, config *APIServerConfig,
 	return SetupRequesterNodeForTestsWithPortAndConfig(t, port, config, hairpin)
 }
 
+func SetupRequesterNodeForTestsWithPortAndConfig(
+	t *testing.T, port int, config *APIServerConfig, hairpin bool,
+) (*APIClient, *system.CleanupManager) {
+	_, cl, cm := setupRequesterNodeForTests(t, port, 0, config, hairpin)
+	return cl, cm
+}
+
+// SetupRequesterNodeForGRPCTests sets up a client for a requester node's gRPC API, for testing.
+func SetupRequesterNodeForGRPCTests(t *testing.T, hairpin bool) (*GRPCClient, *system.CleanupManager) {
+	port, err := freeport.GetFreePort()
+	require.NoError(t, err)
+	grpcPort, err := freeport.GetFreePort()
+	require.NoError(t, err)
+	s, _, cm := setupRequesterNodeForTests(t, port, grpcPort, DefaultAPIServerConfig, hairpin)
+
+	wait := make(chan struct{})
+	go func() {
+		defer close(wait)
+		require.NoError(t, s.ListenAndServeGRPC(context.Background(), cm))
+	}()
+	cm.RegisterCallback(func() error {
+		<-wait
+		return nil
+	})
+
+	address := fmt.Sprintf("127.0.0.1:%d", grpcPort)
+	require.Eventually(t, func() bool {
+		conn, err := net.Dial("tcp", address)
+		if err != nil {
+			return false
+		}
+		return conn.Close() == nil
+	}, time.Duration(TimeToWaitForServerReply)*time.Second, time.Duration(TimeToWaitForHealthy)*time.Millisecond)
+
+	cl, err := NewGRPCClient(context.Background(), address)
+	require.NoError(t, err)
+	cm.RegisterCallback(cl.Close)
+	return cl, cm
+}
+
 // TODO: we are almost establishing a full node to test the API. Most of these tests should be move to test package,
 // and only keep simple unit tests here.
 //
 //nolint:funlen
-func SetupRequesterNodeForTestsWithPortAndConfig(
-	t *testing.T, port int, config *APIServerConfig, hairpin bool,
-) (*APIClient, *system.CleanupManager) {
+func setupRequesterNodeForTests(
+	t *testing.T, port int, grpcPort int, config *APIServerConfig, hairpin bool,
+) (*APIServer, *APIClient, *system.CleanupManager) {
 	// Setup the system
 	err := system.InitConfigForTesting(t)
 	require.NoError(t, err)
 
+	return setupRequesterNodeWithConfigForTests(
+		t, port, grpcPort, config, requesternode.NewDefaultRequesterNodeConfig(), hairpin)
+}
+
+// setupRequesterNodeWithConfigForTests sets up a requester node with the
+// config, for tests that have set up the system already.
+func setupRequesterNodeWithConfigForTests(
+	t *testing.T,
+	port int,
+	grpcPort int,
+	config *APIServerConfig,
+	requesterConfig requesternode.RequesterNodeConfig, //nolint:gocritic
+	hairpin bool,
+) (*APIServer, *APIClient, *system.CleanupManager) {
 	cm := system.NewCleanupManager()
 	ctx := context.Background()
 
@@ -103,7 +157,7 @@ func SetupRequesterNodeForTestsWithPortAndConfig(
 		jobEventPublisher,
 		noopVerifiers,
 		noopStorageProviders,
-		requesternode.NewDefaultRequesterNodeConfig(),
+		requesterConfig,
 	)
 	require.NoError(t, err)
 
@@ -112,6 +166,11 @@ func SetupRequesterNodeForTestsWithPortAndConfig(
 	host := "0.0.0.0"
 	s := NewServerWithConfig(ctx, host, port, inmemoryDatastore, inprocessTransport,
 		requesterNode, []model.DebugInfoProvider{}, noopPublishers, noopStorageProviders, config)
+	s.GRPCPort = grpcPort
+	// the receivers of the webhooks and callbacks of tests listen on loopback
+	_, loopback, err := net.ParseCIDR("127.0.0.0/8")
+	require.NoError(t, err)
+	s.CallbackAllowedNetworks = []*net.IPNet{loopback}
 
 	// order of event handlers is important as triggering some handlers should depend on the state of others.
 	jobEventConsumer.AddHandlers(
@@ -151,7 +210,7 @@ func SetupRequesterNodeForTestsWithPortAndConfig(
 	})
 	require.NoError(t, waitForHealthy(ctx, cl))
 
-	return cl, cm
+	return s, cl, cm
 }
 
 func waitForHealthy(ctx context.Context, c *APIClient) error {
@@ -189,14 +248,16 @@ func MountUsage(path string) (disk types.MountStatus) {
 }
 
 // use "-1" as count for just last line
-func TailFile(count int, path string) ([]byte, error) {
-	c := exec.Command("tail", strconv.Itoa(count), path) //nolint:gosec // subprocess not at risk
-	output, err := c.Output()
+func TailFile(ctx context.Context, count int, path string) ([]byte, error) {
+	result, err := system.RunCommand(ctx, system.Command{
+		Name: "tail",
+		Args: []string{strconv.Itoa(count), path},
+	})
 	if err != nil {
-		log.Warn().Msgf("Could not find file at %s", path)
+		log.Ctx(ctx).Warn().Msgf("Could not find file at %s", path)
 		return nil, err
 	}
-	return output, nil
+	return []byte(result.Stdout), nil
 }
 
 func MakeGenericJob() *model.Job {
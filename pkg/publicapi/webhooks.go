@@ -0,0 +1,403 @@
+package publicapi
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/filecoin-project/bacalhau/pkg/logger"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/util/closer"
+	"github.com/rs/zerolog/log"
+)
+
+// HTTPHeaderWebhookSignature is the header holding the signature of a
+// webhook's payload, in the form "sha256=<hex encoded HMAC-SHA256>".
+const HTTPHeaderWebhookSignature = "X-Bacalhau-Signature"
+
+// MaxWebhooksPerClient is the largest number of webhooks a client can have
+// registered at once, for its jobs or for itself.
+var MaxWebhooksPerClient = 20
+
+// The number of webhook deliveries that can be in progress at once. Any more
+// are dropped.
+const maxPendingWebhookDeliveries = 1000
+
+// How many times, and how far apart, a failed delivery is attempted.
+const webhookDeliveryAttempts = 3
+const webhookRetryDelay = time.Second
+
+// WebhookEvent is the payload posted to webhooks when a job changes state.
+type WebhookEvent struct {
+	JobID    string `json:"job_id"`
+	ClientID string `json:"client_id"`
+	// The most advanced state any shard of the job is in, before and after
+	// the change.
+	PreviousState string `json:"previous_state"`
+	State         string `json:"state"`
+	// Whether every shard of the job has reached a terminal state.
+	Finished bool `json:"finished"`
+	// The event that changed the state of the job.
+	EventName model.JobEventType `json:"event_name"`
+	EventTime time.Time          `json:"event_time"`
+	// The published results of the job so far.
+	Results []model.PublishedResult `json:"results"`
+}
+
+// SignWebhookPayload returns the signature sent with a webhook payload.
+func SignWebhookPayload(payload []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhookSignature checks the signature sent with a webhook payload,
+// for receivers written in Go.
+func VerifyWebhookSignature(payload []byte, secret, signature string) bool {
+	return hmac.Equal([]byte(SignWebhookPayload(payload, secret)), []byte(signature))
+}
+
+type webhook struct {
+	url    string
+	secret string
+}
+
+// webhookQueueKey identifies the deliveries to a webhook for a job, which are
+// made one after the other so that they arrive in the order of the changes.
+type webhookQueueKey struct {
+	url   string
+	jobID string
+}
+
+type webhookDelivery struct {
+	hook    webhook
+	payload []byte
+}
+
+// webhooks are only kept in memory, so clients have to register them again
+// if the node restarts.
+type webhooks struct {
+	mutex sync.Mutex
+	// clientID -> url -> webhook, called for all the client's jobs
+	byClient map[string]map[string]webhook
+	// jobID -> url -> webhook
+	byJob map[string]map[string]webhook
+	// clientID -> number of webhooks registered for the client and its jobs
+	counts map[string]int
+	// jobID -> state last delivered for that job
+	lastStates map[string]string
+	// the deliveries waiting for the one being made to the same webhook for
+	// the same job, which has a queue here while it is being made
+	queues  map[webhookQueueKey][]webhookDelivery
+	pending int
+}
+
+func newWebhooks() *webhooks {
+	return &webhooks{
+		byClient:   map[string]map[string]webhook{},
+		byJob:      map[string]map[string]webhook{},
+		counts:     map[string]int{},
+		lastStates: map[string]string{},
+		queues:     map[webhookQueueKey][]webhookDelivery{},
+	}
+}
+
+func (w *webhooks) register(clientID, jobID string, hook webhook) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	hooks := w.hooksFor(clientID, jobID)
+	defer w.deleteIfEmpty(clientID, jobID)
+	if _, ok := hooks[hook.url]; !ok {
+		if w.counts[clientID] >= MaxWebhooksPerClient {
+			return fmt.Errorf("client already has the maximum of %d webhooks", MaxWebhooksPerClient)
+		}
+		w.counts[clientID]++
+	}
+	hooks[hook.url] = hook
+	return nil
+}
+
+func (w *webhooks) unregister(clientID, jobID, hookURL string) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	hooks := w.hooksFor(clientID, jobID)
+	defer w.deleteIfEmpty(clientID, jobID)
+	if _, ok := hooks[hookURL]; !ok {
+		return fmt.Errorf("no webhook is registered for %s", hookURL)
+	}
+	delete(hooks, hookURL)
+	w.counts[clientID]--
+	if w.counts[clientID] == 0 {
+		delete(w.counts, clientID)
+	}
+	return nil
+}
+
+// hooksFor returns the webhooks of a job, or of a client if jobID is empty.
+// It must be called with the mutex held.
+func (w *webhooks) hooksFor(clientID, jobID string) map[string]webhook {
+	all, key := w.byClient, clientID
+	if jobID != "" {
+		all, key = w.byJob, jobID
+	}
+	if _, ok := all[key]; !ok {
+		all[key] = map[string]webhook{}
+	}
+	return all[key]
+}
+
+func (w *webhooks) deleteIfEmpty(clientID, jobID string) {
+	if jobID != "" {
+		if len(w.byJob[jobID]) == 0 {
+			delete(w.byJob, jobID)
+		}
+	} else if len(w.byClient[clientID]) == 0 {
+		delete(w.byClient, clientID)
+	}
+}
+
+func (w *webhooks) any() bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return len(w.byClient) > 0 || len(w.byJob) > 0
+}
+
+// transition records the state of a job and returns the webhooks to call if
+// it has changed, along with the previous state. The job's own webhooks are
+// dropped once it has finished.
+func (w *webhooks) transition(clientID, jobID, state string, finished bool) ([]webhook, string) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	hooks := make([]webhook, 0, len(w.byClient[clientID])+len(w.byJob[jobID]))
+	for _, hook := range w.byClient[clientID] {
+		hooks = append(hooks, hook)
+	}
+	for _, hook := range w.byJob[jobID] {
+		hooks = append(hooks, hook)
+	}
+	if len(hooks) == 0 {
+		return nil, ""
+	}
+
+	previous := w.lastStates[jobID]
+	if finished {
+		delete(w.lastStates, jobID)
+		if jobHooks := w.byJob[jobID]; len(jobHooks) > 0 {
+			w.counts[clientID] -= len(jobHooks)
+			if w.counts[clientID] <= 0 {
+				delete(w.counts, clientID)
+			}
+			delete(w.byJob, jobID)
+		}
+	} else {
+		w.lastStates[jobID] = state
+	}
+	if previous == state {
+		return nil, ""
+	}
+	return hooks, previous
+}
+
+// notifyWebhooks calls the webhooks registered for the job of an event, and
+// for the client that owns it, if the event changed the state of the job.
+func (apiServer *APIServer) notifyWebhooks(ctx context.Context, event model.JobEvent) {
+	if !apiServer.webhooks.any() {
+		return
+	}
+
+	j, err := apiServer.localdb.GetJob(ctx, event.JobID)
+	if err != nil {
+		log.Ctx(ctx).Warn().Err(err).Msgf("error getting job %s to call its webhooks", event.JobID)
+		return
+	}
+	waitRes, err := apiServer.getWaitResponse(ctx, j)
+	if err != nil {
+		log.Ctx(ctx).Warn().Err(err).Msgf("error getting state of job %s to call its webhooks", event.JobID)
+		return
+	}
+
+	hooks, previous := apiServer.webhooks.transition(j.ClientID, j.ID, waitRes.State, waitRes.Finished)
+	if len(hooks) == 0 {
+		return
+	}
+	payload, err := json.Marshal(WebhookEvent{
+		JobID:         j.ID,
+		ClientID:      j.ClientID,
+		PreviousState: previous,
+		State:         waitRes.State,
+		Finished:      waitRes.Finished,
+		EventName:     event.EventName,
+		EventTime:     event.EventTime,
+		Results:       waitRes.Results,
+	})
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msgf("error marshaling webhook payload for job %s", j.ID)
+		return
+	}
+
+	for _, hook := range hooks {
+		apiServer.deliverWebhook(j.ID, hook, payload)
+	}
+}
+
+// deliverWebhook posts a payload to a webhook in the background, retrying
+// if it fails. Payloads for the same job are posted to a webhook in the order
+// they are delivered, each once the one before has been posted or given up
+// on.
+func (apiServer *APIServer) deliverWebhook(jobID string, hook webhook, payload []byte) {
+	w := apiServer.webhooks
+	w.mutex.Lock()
+	if w.pending >= maxPendingWebhookDeliveries {
+		w.mutex.Unlock()
+		log.Warn().Msgf("too many webhook deliveries are pending, dropping the one for job %s to %s", jobID, hook.url)
+		return
+	}
+	w.pending++
+	key := webhookQueueKey{url: hook.url, jobID: jobID}
+	queue, delivering := w.queues[key]
+	w.queues[key] = append(queue, webhookDelivery{hook: hook, payload: payload})
+	w.mutex.Unlock()
+
+	if !delivering {
+		go apiServer.drainWebhookQueue(key)
+	}
+}
+
+// drainWebhookQueue posts the payloads queued for a webhook and job until
+// there are none left.
+func (apiServer *APIServer) drainWebhookQueue(key webhookQueueKey) {
+	w := apiServer.webhooks
+	ctx := logger.ContextWithNodeIDLogger(context.Background(), apiServer.Requester.ID)
+	for {
+		w.mutex.Lock()
+		queue := w.queues[key]
+		if len(queue) == 0 {
+			delete(w.queues, key)
+			w.mutex.Unlock()
+			return
+		}
+		w.queues[key] = queue[1:]
+		w.mutex.Unlock()
+
+		apiServer.postWebhook(ctx, key.jobID, queue[0])
+
+		w.mutex.Lock()
+		w.pending--
+		w.mutex.Unlock()
+	}
+}
+
+func (apiServer *APIServer) postWebhook(ctx context.Context, jobID string, delivery webhookDelivery) {
+	header := http.Header{}
+	header.Set(HTTPHeaderWebhookSignature, SignWebhookPayload(delivery.payload, delivery.hook.secret))
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = apiServer.postJSON(ctx, delivery.hook.url, delivery.payload, header); err == nil {
+			return
+		}
+		if attempt == webhookDeliveryAttempts {
+			break
+		}
+		time.Sleep(time.Duration(attempt) * webhookRetryDelay)
+	}
+	log.Ctx(ctx).Warn().Err(err).Msgf("error calling webhook %s for job %s", delivery.hook.url, jobID)
+}
+
+// validateCallbackURL checks that a URL given to call back can be posted to,
+// and that its host doesn't resolve to an address callbacks are refused to.
+func (apiServer *APIServer) validateCallbackURL(ctx context.Context, callbackURL string) error {
+	parsed, err := url.Parse(callbackURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("invalid callback URL %q", callbackURL)
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, parsed.Hostname())
+	if err != nil {
+		return fmt.Errorf("error resolving the host of callback URL %q: %w", callbackURL, err)
+	}
+	for _, addr := range addrs {
+		if err = apiServer.checkCallbackIP(addr.IP); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkCallbackIP returns an error if callbacks can't be posted to the IP:
+// loopback, link-local, private and unspecified addresses are refused, so
+// that clients can't make the node post to its own network, unless they are
+// in one of the networks the operator allows.
+func (apiServer *APIServer) checkCallbackIP(ip net.IP) error {
+	for _, network := range apiServer.CallbackAllowedNetworks {
+		if network.Contains(ip) {
+			return nil
+		}
+	}
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsPrivate() || ip.IsUnspecified() {
+		return fmt.Errorf("callbacks to %s are not allowed", ip)
+	}
+	return nil
+}
+
+// newCallbackClient returns the client callbacks are posted with, which
+// checks each address it connects to, as the host of a callback URL can
+// resolve to another address by the time it is called, or redirect.
+func (apiServer *APIServer) newCallbackClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: callbackTimeout,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil {
+				return fmt.Errorf("invalid callback address %s", address)
+			}
+			return apiServer.checkCallbackIP(ip)
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// the proxy would be checked rather than the host of the callback
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Transport: transport}
+}
+
+// postJSON posts a JSON payload to a callback URL.
+func (apiServer *APIServer) postJSON(ctx context.Context, callbackURL string, payload []byte, header http.Header) error {
+	ctx, cancel := context.WithTimeout(ctx, callbackTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, callbackURL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-type", "application/json")
+
+	res, err := apiServer.callbackClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer closer.DrainAndCloseWithLogOnError(ctx, "callback response", res.Body)
+	if res.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("callback returned status %d", res.StatusCode)
+	}
+	return nil
+}
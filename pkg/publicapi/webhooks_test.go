@@ -0,0 +1,235 @@
+//go:build unit || !integration
+
+package publicapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/bacalhau/pkg/bacerrors"
+	"github.com/filecoin-project/bacalhau/pkg/logger"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/requesternode"
+	"github.com/filecoin-project/bacalhau/pkg/system"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookCalledOnStateChange(t *testing.T) {
+	logger.ConfigureTestLogging(t)
+
+	const secret = "s3cret"
+	events := make(chan WebhookEvent, 16)
+	receiver := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		body, err := io.ReadAll(req.Body)
+		require.NoError(t, err)
+		require.True(t, VerifyWebhookSignature(body, secret, req.Header.Get(HTTPHeaderWebhookSignature)))
+
+		var event WebhookEvent
+		require.NoError(t, json.Unmarshal(body, &event))
+		events <- event
+	}))
+	defer receiver.Close()
+
+	c, cm := SetupRequesterNodeForTests(t, true)
+	defer cm.Cleanup()
+	ctx := context.Background()
+
+	require.NoError(t, c.RegisterWebhook(ctx, "", receiver.URL, secret))
+
+	j, err := c.Submit(ctx, MakeNoopJob(), nil)
+	require.NoError(t, err)
+
+	select {
+	case event := <-events:
+		require.Equal(t, j.ID, event.JobID)
+		require.Equal(t, model.JobEventCreated, event.EventName)
+		require.Empty(t, event.PreviousState)
+		require.False(t, event.Finished)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "webhook was not called")
+	}
+
+	require.NoError(t, c.UnregisterWebhook(ctx, "", receiver.URL))
+	require.Error(t, c.UnregisterWebhook(ctx, "", receiver.URL))
+}
+
+func TestRegisterWebhookValidation(t *testing.T) {
+	logger.ConfigureTestLogging(t)
+
+	c, cm := SetupRequesterNodeForTests(t, false)
+	defer cm.Cleanup()
+	ctx := context.Background()
+
+	require.Error(t, c.RegisterWebhook(ctx, "", "ftp://example.com", "secret"))
+	require.Error(t, c.RegisterWebhook(ctx, "", "https://example.com", ""))
+	require.Error(t, c.RegisterWebhook(ctx, "not-a-job", "https://example.com", "secret"))
+}
+
+func TestWebhooksTransition(t *testing.T) {
+	w := newWebhooks()
+	require.NoError(t, w.register("client", "", webhook{url: "https://a"}))
+	require.NoError(t, w.register("client", "job", webhook{url: "https://b"}))
+
+	hooks, previous := w.transition("client", "job", "Running", false)
+	require.Len(t, hooks, 2)
+	require.Empty(t, previous)
+
+	// no change, no call
+	hooks, _ = w.transition("client", "job", "Running", false)
+	require.Empty(t, hooks)
+
+	hooks, previous = w.transition("client", "job", "Completed", true)
+	require.Len(t, hooks, 2)
+	require.Equal(t, "Running", previous)
+
+	// the job's own webhook is dropped once it has finished
+	require.Equal(t, 1, w.counts["client"])
+	require.Empty(t, w.byJob)
+	hooks, _ = w.transition("client", "other", "Running", false)
+	require.Len(t, hooks, 1)
+}
+
+func TestWebhooksLimit(t *testing.T) {
+	w := newWebhooks()
+	for i := 0; i < MaxWebhooksPerClient; i++ {
+		require.NoError(t, w.register("client", "", webhook{url: fmt.Sprintf("https://%d", i)}))
+	}
+	// re-registering replaces the webhook
+	require.NoError(t, w.register("client", "", webhook{url: "https://0", secret: "new"}))
+	require.Error(t, w.register("client", "", webhook{url: "https://new"}))
+	require.NoError(t, w.register("other", "", webhook{url: "https://new"}))
+}
+
+func TestWebhookRequestReplay(t *testing.T) {
+	logger.ConfigureTestLogging(t)
+
+	c, cm := SetupRequesterNodeForTests(t, false)
+	defer cm.Cleanup()
+	ctx := context.Background()
+
+	signed := func(data model.WebhookPayload) webhookRequest {
+		jsonData, err := model.JSONMarshalWithMax(data)
+		require.NoError(t, err)
+		signature, err := system.SignForClient(jsonData)
+		require.NoError(t, err)
+		return webhookRequest{Data: data, ClientSignature: signature, ClientPublicKey: system.GetClientPublicKey()}
+	}
+
+	const hookURL = "http://127.0.0.1:1"
+	require.NoError(t, c.RegisterWebhook(ctx, "", hookURL, "secret"))
+	unregister := signed(model.WebhookPayload{
+		ClientID:  system.GetClientID(),
+		URL:       hookURL,
+		CreatedAt: time.Now(),
+		Nonce:     uuid.NewString(),
+	})
+	require.NoError(t, c.post(ctx, "unregister_webhook", unregister, &webhookResponse{}))
+	require.NoError(t, c.RegisterWebhook(ctx, "", hookURL, "secret"))
+
+	// the unregister can't be sent again to drop the webhook registered since
+	err := c.post(ctx, "unregister_webhook", unregister, &webhookResponse{})
+	require.IsType(t, &bacerrors.BadRequest{}, err)
+	require.ErrorContains(t, err, "already used")
+
+	stale := signed(model.WebhookPayload{
+		ClientID:  system.GetClientID(),
+		URL:       hookURL,
+		CreatedAt: time.Now().Add(-time.Hour),
+		Nonce:     uuid.NewString(),
+	})
+	require.ErrorContains(t, c.post(ctx, "unregister_webhook", stale, &webhookResponse{}), "more than")
+}
+
+func TestWebhookDeliveriesInOrder(t *testing.T) {
+	logger.ConfigureTestLogging(t)
+
+	var mutex sync.Mutex
+	var received []string
+	calls := 0
+	receiver := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		body, err := io.ReadAll(req.Body)
+		require.NoError(t, err)
+		mutex.Lock()
+		defer mutex.Unlock()
+		calls++
+		// the first delivery is retried, after the second was made
+		if calls == 1 {
+			res.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		received = append(received, string(body))
+	}))
+	defer receiver.Close()
+
+	apiServer := newTestCallbackServer(t)
+	hook := webhook{url: receiver.URL, secret: "s3cret"}
+	apiServer.deliverWebhook("job", hook, []byte(`"Running"`))
+	apiServer.deliverWebhook("job", hook, []byte(`"Completed"`))
+
+	require.Eventually(t, func() bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return len(received) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, []string{`"Running"`, `"Completed"`}, received)
+	require.Eventually(t, func() bool {
+		apiServer.webhooks.mutex.Lock()
+		defer apiServer.webhooks.mutex.Unlock()
+		return apiServer.webhooks.pending == 0 && len(apiServer.webhooks.queues) == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestCallbacksToPrivateAddressesRefused(t *testing.T) {
+	logger.ConfigureTestLogging(t)
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {}))
+	defer receiver.Close()
+
+	apiServer := newTestCallbackServer(t)
+	apiServer.CallbackAllowedNetworks = nil
+	ctx := context.Background()
+
+	for _, callbackURL := range []string{
+		receiver.URL,
+		"http://169.254.169.254/latest/meta-data",
+		"http://10.0.0.1/hook",
+		"http://192.168.1.1/hook",
+		"http://[::1]:8080/hook",
+		"http://0.0.0.0/hook",
+	} {
+		require.ErrorContains(t, apiServer.validateCallbackURL(ctx, callbackURL), "not allowed", callbackURL)
+	}
+	// the address is checked again when the callback is posted, in case the
+	// host resolves to another one by then
+	require.ErrorContains(t, apiServer.postJSON(ctx, receiver.URL, []byte("{}"), nil), "not allowed")
+
+	// unless the operator allows the network
+	_, loopback, err := net.ParseCIDR("127.0.0.0/8")
+	require.NoError(t, err)
+	apiServer.CallbackAllowedNetworks = []*net.IPNet{loopback}
+	require.NoError(t, apiServer.validateCallbackURL(ctx, receiver.URL))
+	require.NoError(t, apiServer.postJSON(ctx, receiver.URL, []byte("{}"), nil))
+}
+
+// newTestCallbackServer returns an API server that only posts webhooks and
+// callbacks, to receivers on loopback.
+func newTestCallbackServer(t *testing.T) *APIServer {
+	_, loopback, err := net.ParseCIDR("127.0.0.0/8")
+	require.NoError(t, err)
+	apiServer := &APIServer{
+		Requester:               &requesternode.RequesterNode{ID: "node"},
+		CallbackAllowedNetworks: []*net.IPNet{loopback},
+		webhooks:                newWebhooks(),
+	}
+	apiServer.callbackClient = apiServer.newCallbackClient()
+	return apiServer
+}
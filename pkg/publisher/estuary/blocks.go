@@ -0,0 +1,80 @@
+package estuary
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/filecoin-project/bacalhau/pkg/storage/util"
+	"github.com/ipfs/go-cid"
+)
+
+// uploadedBlocks is the set of the CIDs of the blocks a node has uploaded
+// to Estuary, kept in a file with a CID per line so that it lasts across
+// restarts of the node.
+type uploadedBlocks struct {
+	path string
+
+	mu   sync.Mutex
+	cids map[string]bool
+}
+
+func newUploadedBlocks(path string) (*uploadedBlocks, error) {
+	b := &uploadedBlocks{path: path, cids: map[string]bool{}}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return b, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("could not read the uploaded blocks: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			b.cids[line] = true
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read the uploaded blocks: %w", err)
+	}
+	return b, nil
+}
+
+func (b *uploadedBlocks) has(c cid.Cid) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.cids[c.String()]
+}
+
+// add records that the blocks have been uploaded.
+func (b *uploadedBlocks) add(cids []cid.Cid) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var lines strings.Builder
+	for _, c := range cids {
+		if !b.cids[c.String()] {
+			lines.WriteString(c.String() + "\n")
+		}
+	}
+	if lines.Len() == 0 {
+		return nil
+	}
+
+	file, err := os.OpenFile(b.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, util.OS_USER_RW)
+	if err != nil {
+		return fmt.Errorf("could not record the uploaded blocks: %w", err)
+	}
+	defer file.Close()
+	if _, err = file.WriteString(lines.String()); err != nil {
+		return fmt.Errorf("could not record the uploaded blocks: %w", err)
+	}
+	for _, c := range cids {
+		b.cids[c.String()] = true
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+//go:build unit || !integration
+
+package estuary
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUploadedBlocks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "uploaded-blocks")
+	first := cid.MustParse("bafkreifzjut3te2nhyekklss27nh3k72ysco7y32koao5eei66wof36n5e")
+	second := cid.MustParse("QmTVmC7JBD2ES2qGPqBNVWnX1KeEPNrPGb7rJ8cpFgtefe")
+
+	blocks, err := newUploadedBlocks(path)
+	require.NoError(t, err)
+	require.False(t, blocks.has(first))
+
+	require.NoError(t, blocks.add([]cid.Cid{first}))
+	require.NoError(t, blocks.add([]cid.Cid{first, second}))
+	require.True(t, blocks.has(first))
+	require.True(t, blocks.has(second))
+
+	// the blocks are still known after the node restarts
+	blocks, err = newUploadedBlocks(path)
+	require.NoError(t, err)
+	require.True(t, blocks.has(first))
+	require.True(t, blocks.has(second))
+	require.Len(t, blocks.cids, 2)
+}
@@ -1,5 +1,42 @@
 package estuary
 
+import (
+	"net/url"
+	"strconv"
+
+	"github.com/filecoin-project/bacalhau/pkg/compute/secrets"
+)
+
 type EstuaryPublisherConfig struct {
 	APIKey string
+	// Where to fetch the API key from instead, so that it can be rotated.
+	APIKeySecret *secrets.Credential
+	// The file the node records the blocks it uploaded in, so that jobs that
+	// publish incrementally don't upload them again. Results are uploaded
+	// whole if empty.
+	UploadedBlocksPath string
+	// The UUID of the collection results are added to, rather than the root
+	// of the account.
+	CollectionUUID string
+	// How many storage deals Estuary makes for results, its default if 0.
+	Replication int
+	// The region Estuary should prefer to store results in. It is only a
+	// hint, which Estuary may not follow.
+	Region string
+}
+
+// uploadQuery returns the query parameters of the requests that upload
+// results, which pass the options of the uploads to Estuary.
+func (c EstuaryPublisherConfig) uploadQuery() url.Values {
+	query := url.Values{}
+	if c.CollectionUUID != "" {
+		query.Set("coluuid", c.CollectionUUID)
+	}
+	if c.Replication > 0 {
+		query.Set("replication", strconv.Itoa(c.Replication))
+	}
+	if c.Region != "" {
+		query.Set("region", c.Region)
+	}
+	return query
 }
@@ -2,6 +2,8 @@ package estuary
 
 import (
 	"context"
+	"net/http"
+	"net/url"
 
 	estuary_client "github.com/application-research/estuary-clients/go"
 )
@@ -23,7 +25,38 @@ func GetGatewayClient(ctx context.Context, apiKey string) *estuary_client.APICli
 	return estuary_client.NewAPIClient(gatewayConfig)
 }
 
-func GetUploadClient(ctx context.Context, apiKey string) *estuary_client.APIClient {
-	config := getAPIConfig(uploadEndpoint, apiKey)
+// GetUploadClient returns a client of the upload API that adds the query
+// parameters to its requests, for the options of uploads that the generated
+// client doesn't support.
+func GetUploadClient(ctx context.Context, apiKey string, query url.Values) *estuary_client.APIClient {
+	return newUploadClient(uploadEndpoint, apiKey, query)
+}
+
+func newUploadClient(baseURL string, apiKey string, query url.Values) *estuary_client.APIClient {
+	config := getAPIConfig(baseURL, apiKey)
+	if len(query) > 0 {
+		config.HTTPClient = &http.Client{
+			Transport: &queryTransport{base: http.DefaultTransport, query: query},
+		}
+	}
 	return estuary_client.NewAPIClient(config)
 }
+
+// queryTransport adds query parameters to the requests it sends.
+type queryTransport struct {
+	base  http.RoundTripper
+	query url.Values
+}
+
+func (t *queryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// a round tripper must not modify the request it is given
+	req = req.Clone(req.Context())
+	query := req.URL.Query()
+	for key, values := range t.query {
+		for _, value := range values {
+			query.Add(key, value)
+		}
+	}
+	req.URL.RawQuery = query.Encode()
+	return t.base.RoundTrip(req)
+}
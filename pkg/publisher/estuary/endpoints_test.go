@@ -0,0 +1,56 @@
+//go:build unit || !integration
+
+package estuary
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/antihax/optional"
+	estuary_client "github.com/application-research/estuary-clients/go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUploadQuery(t *testing.T) {
+	require.Empty(t, EstuaryPublisherConfig{APIKey: "key"}.uploadQuery())
+
+	config := EstuaryPublisherConfig{
+		CollectionUUID: "6c1ac1a4-6c3b-4a4e-9bba-8a5e8f3e3f2c",
+		Replication:    3,
+		Region:         "eu",
+	}
+	require.Equal(t, url.Values{
+		"coluuid":     {"6c1ac1a4-6c3b-4a4e-9bba-8a5e8f3e3f2c"},
+		"replication": {"3"},
+		"region":      {"eu"},
+	}, config.uploadQuery())
+}
+
+func TestUploadClientAddsQuery(t *testing.T) {
+	var received url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.URL.Query()
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(estuary_client.UtilContentAddResponse{Cid: "bafkqaaa"})
+	}))
+	defer server.Close()
+
+	client := newUploadClient(server.URL, "key", url.Values{"coluuid": {"6c1ac1a4"}, "replication": {"3"}})
+	response, httpResponse, err := client.ContentApi.ContentAddCarPost(
+		context.Background(),
+		"car",
+		&estuary_client.ContentApiContentAddCarPostOpts{Filename: optional.NewString("shard")},
+	)
+	require.NoError(t, err)
+	defer httpResponse.Body.Close()
+	require.Equal(t, "bafkqaaa", response.Cid)
+	require.Equal(t, url.Values{
+		"coluuid":     {"6c1ac1a4"},
+		"replication": {"3"},
+		"filename":    {"shard"},
+	}, received)
+}
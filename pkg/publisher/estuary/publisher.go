@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/antihax/optional"
@@ -15,12 +16,18 @@ import (
 	"github.com/filecoin-project/bacalhau/pkg/model"
 	"github.com/filecoin-project/bacalhau/pkg/publisher"
 	"github.com/filecoin-project/bacalhau/pkg/util/closer"
+	"github.com/ipfs/go-cid"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
 )
 
 type estuaryPublisher struct {
 	config EstuaryPublisherConfig
+
+	// the blocks uploaded before, loaded when results are first published
+	blocksOnce sync.Once
+	blocks     *uploadedBlocks
+	blocksErr  error
 }
 
 const publisherTimeout = 5 * time.Minute
@@ -33,7 +40,11 @@ func NewEstuaryPublisher(config EstuaryPublisherConfig) publisher.Publisher {
 
 // IsInstalled implements publisher.Publisher
 func (e *estuaryPublisher) IsInstalled(ctx context.Context) (bool, error) {
-	client := GetGatewayClient(ctx, e.config.APIKey)
+	apiKey, err := e.apiKey(ctx)
+	if err != nil {
+		return false, err
+	}
+	client := GetGatewayClient(ctx, apiKey)
 	_, response, err := client.CollectionsApi.CollectionsGet(ctx) //nolint:bodyclose // golangcilint is dumb - this is closed
 	if response != nil {
 		defer closer.DrainAndCloseWithLogOnError(ctx, "estuary-response", response.Body)
@@ -57,10 +68,32 @@ func (e *estuaryPublisher) PublishShardResult(
 	defer os.RemoveAll(tempDir)
 
 	carFile := filepath.Join(tempDir, "results.car")
-	_, err = car.CreateCar(ctx, shardResultPath, carFile, 1)
+	root, err := car.CreateCar(ctx, shardResultPath, carFile, 1)
+	if err != nil {
+		return model.StorageSpec{}, err
+	}
+
+	blocks, err := e.uploadedBlocks()
 	if err != nil {
 		return model.StorageSpec{}, err
 	}
+	var cids []cid.Cid
+	if blocks != nil {
+		// Estuary completes the DAG from the blocks it already has, so only
+		// the new ones are uploaded. The root is always uploaded so that
+		// the CAR isn't empty when the results haven't changed.
+		skip := func(c cid.Cid) bool {
+			return shard.Job.Spec.PublishIncrementally && c.String() != root && blocks.has(c)
+		}
+		uploadFile := filepath.Join(tempDir, "upload.car")
+		var skipped int
+		cids, skipped, err = car.FilterCar(ctx, carFile, uploadFile, skip)
+		if err != nil {
+			return model.StorageSpec{}, errors.Wrap(err, "error filtering CAR file")
+		}
+		log.Ctx(ctx).Debug().Int("Blocks", len(cids)).Int("Skipped", skipped).Msg("Uploading new blocks to Estuary")
+		carFile = uploadFile
+	}
 
 	carReader, err := os.Open(carFile)
 	if err != nil {
@@ -72,7 +105,11 @@ func (e *estuaryPublisher) PublishShardResult(
 		return model.StorageSpec{}, errors.Wrap(err, "error reading CAR data")
 	}
 
-	client := GetUploadClient(ctx, e.config.APIKey)
+	apiKey, err := e.apiKey(ctx)
+	if err != nil {
+		return model.StorageSpec{}, err
+	}
+	client := GetUploadClient(ctx, apiKey, e.config.uploadQuery())
 	timeout, cancel := context.WithTimeout(ctx, publisherTimeout)
 	defer cancel()
 
@@ -91,10 +128,35 @@ func (e *estuaryPublisher) PublishShardResult(
 	log.Ctx(ctx).Debug().Interface("Response", addCarResponse).Int("StatusCode", httpResponse.StatusCode).Msg("Estuary response")
 	defer closer.DrainAndCloseWithLogOnError(ctx, "estuary-response", httpResponse.Body)
 
+	if blocks != nil {
+		if err = blocks.add(cids); err != nil {
+			log.Ctx(ctx).Warn().Err(err).Msg("Failed to record the blocks uploaded to Estuary")
+		}
+	}
+
 	return model.StorageSpec{
 		StorageSource: model.StorageSourceEstuary,
 		CID:           addCarResponse.Cid,
 	}, nil
 }
 
+// uploadedBlocks returns the blocks uploaded before, or nil if they aren't
+// recorded.
+func (e *estuaryPublisher) uploadedBlocks() (*uploadedBlocks, error) {
+	if e.config.UploadedBlocksPath == "" {
+		return nil, nil
+	}
+	e.blocksOnce.Do(func() {
+		e.blocks, e.blocksErr = newUploadedBlocks(e.config.UploadedBlocksPath)
+	})
+	return e.blocks, e.blocksErr
+}
+
+func (e *estuaryPublisher) apiKey(ctx context.Context) (string, error) {
+	if e.config.APIKeySecret != nil {
+		return e.config.APIKeySecret.Get(ctx)
+	}
+	return e.config.APIKey, nil
+}
+
 var _ publisher.Publisher = (*estuaryPublisher)(nil)
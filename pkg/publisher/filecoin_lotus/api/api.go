@@ -120,10 +120,13 @@ func fetchHostnameFromConfig(file string) (string, error) {
 type Client interface {
 	ClientDealPieceCID(context.Context, cid.Cid) (DataCIDSize, error)
 	ClientExport(context.Context, ExportRef, FileRef) error
+	ClientFindData(context.Context, cid.Cid, *cid.Cid) ([]QueryOffer, error)
 	ClientGetDealUpdates(ctx context.Context) (<-chan DealInfo, error)
 	ClientListImports(context.Context) ([]Import, error)
 	ClientImport(context.Context, FileRef) (*ImportRes, error)
 	ClientQueryAsk(context.Context, peer.ID, address.Address) (*StorageAsk, error)
+	ClientRetrieve(context.Context, RetrievalOrder) (*RestrievalRes, error)
+	ClientRetrieveWait(context.Context, retrievalmarket.DealID) error
 	ClientStartDeal(context.Context, *StartDealParams) (*cid.Cid, error)
 	StateGetNetworkParams(context.Context) (*NetworkParams, error)
 	StateListMiners(context.Context, TipSetKey) ([]address.Address, error)
@@ -141,10 +144,13 @@ type api struct {
 	internal struct {
 		ClientDealPieceCID    func(context.Context, cid.Cid) (DataCIDSize, error)
 		ClientExport          func(context.Context, ExportRef, FileRef) error
+		ClientFindData        func(context.Context, cid.Cid, *cid.Cid) ([]QueryOffer, error)
 		ClientGetDealUpdates  func(ctx context.Context) (<-chan DealInfo, error)
 		ClientListImports     func(context.Context) ([]Import, error)
 		ClientImport          func(context.Context, FileRef) (*ImportRes, error)
 		ClientQueryAsk        func(context.Context, peer.ID, address.Address) (*StorageAsk, error)
+		ClientRetrieve        func(context.Context, RetrievalOrder) (*RestrievalRes, error)
+		ClientRetrieveWait    func(context.Context, retrievalmarket.DealID) error
 		ClientStartDeal       func(context.Context, *StartDealParams) (*cid.Cid, error)
 		StateGetNetworkParams func(context.Context) (*NetworkParams, error)
 		StateListMiners       func(context.Context, TipSetKey) ([]address.Address, error)
@@ -168,6 +174,12 @@ func (a *api) ClientExport(ctx context.Context, exportRef ExportRef, fileRef Fil
 	return a.internal.ClientExport(ctx, exportRef, fileRef)
 }
 
+func (a *api) ClientFindData(ctx context.Context, root cid.Cid, piece *cid.Cid) ([]QueryOffer, error) {
+	ctx, span := system.GetTracer().Start(ctx, "pkg/publisher/filecoin_lotus/api/ClientFindData")
+	defer span.End()
+	return a.internal.ClientFindData(ctx, root, piece)
+}
+
 func (a *api) ClientGetDealUpdates(ctx context.Context) (<-chan DealInfo, error) {
 	ctx, span := system.GetTracer().Start(ctx, "pkg/publisher/filecoin_lotus/api/ClientGetDealUpdates")
 	defer span.End()
@@ -192,6 +204,18 @@ func (a *api) ClientQueryAsk(ctx context.Context, p peer.ID, miner address.Addre
 	return a.internal.ClientQueryAsk(ctx, p, miner)
 }
 
+func (a *api) ClientRetrieve(ctx context.Context, order RetrievalOrder) (*RestrievalRes, error) {
+	ctx, span := system.GetTracer().Start(ctx, "pkg/publisher/filecoin_lotus/api/ClientRetrieve")
+	defer span.End()
+	return a.internal.ClientRetrieve(ctx, order)
+}
+
+func (a *api) ClientRetrieveWait(ctx context.Context, deal retrievalmarket.DealID) error {
+	ctx, span := system.GetTracer().Start(ctx, "pkg/publisher/filecoin_lotus/api/ClientRetrieveWait")
+	defer span.End()
+	return a.internal.ClientRetrieveWait(ctx, deal)
+}
+
 func (a *api) ClientStartDeal(ctx context.Context, params *StartDealParams) (*cid.Cid, error) {
 	ctx, span := system.GetTracer().Start(ctx, "pkg/publisher/filecoin_lotus/api/ClientStartDeal")
 	defer span.End()
@@ -419,3 +443,54 @@ type ExportRef struct {
 	FromLocalCAR string
 	DealID       retrievalmarket.DealID
 }
+
+type QueryOffer struct {
+	Err string
+
+	Root  cid.Cid
+	Piece *cid.Cid
+
+	Size                    uint64
+	MinPrice                big2.Int
+	UnsealPrice             big2.Int
+	PricePerByte            big2.Int
+	PaymentInterval         uint64
+	PaymentIntervalIncrease uint64
+	Miner                   address.Address
+	MinerPeer               retrievalmarket.RetrievalPeer
+}
+
+func (o *QueryOffer) Order(client address.Address) RetrievalOrder {
+	return RetrievalOrder{
+		Root:                    o.Root,
+		Piece:                   o.Piece,
+		Size:                    o.Size,
+		Total:                   o.MinPrice,
+		UnsealPrice:             o.UnsealPrice,
+		PaymentInterval:         o.PaymentInterval,
+		PaymentIntervalIncrease: o.PaymentIntervalIncrease,
+		Client:                  client,
+
+		Miner:     o.Miner,
+		MinerPeer: &o.MinerPeer,
+	}
+}
+
+type RetrievalOrder struct {
+	Root         cid.Cid
+	Piece        *cid.Cid
+	DataSelector *Selector
+
+	Size                    uint64
+	Total                   big2.Int
+	UnsealPrice             big2.Int
+	PaymentInterval         uint64
+	PaymentIntervalIncrease uint64
+	Client                  address.Address
+	Miner                   address.Address
+	MinerPeer               *retrievalmarket.RetrievalPeer
+}
+
+type RestrievalRes struct {
+	DealID retrievalmarket.DealID
+}
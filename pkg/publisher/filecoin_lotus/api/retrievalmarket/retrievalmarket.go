@@ -1,3 +1,15 @@
 package retrievalmarket
 
+import (
+	"github.com/filecoin-project/go-address"
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
 type DealID uint64
+
+type RetrievalPeer struct {
+	Address  address.Address
+	ID       peer.ID
+	PieceCID *cid.Cid
+}
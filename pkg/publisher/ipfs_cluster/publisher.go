@@ -0,0 +1,158 @@
+package ipfscluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/filecoin-project/bacalhau/pkg/compute/secrets"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/publisher"
+	"github.com/filecoin-project/bacalhau/pkg/system"
+	"github.com/filecoin-project/bacalhau/pkg/util/closer"
+	"github.com/rs/zerolog/log"
+)
+
+type PublisherConfig struct {
+	// The URL of the ipfs-cluster REST API, e.g. http://127.0.0.1:9094
+	APIAddress string
+	// Basic auth credentials for the REST API - optional
+	Username string
+	Password string
+	// Where to fetch the user:password credentials from instead, so that
+	// they can be rotated.
+	BasicAuthSecret *secrets.Credential
+	// How many cluster peers should pin each result at least and at most.
+	// Zero uses the cluster's defaults and -1 pins on every peer.
+	ReplicationMin int
+	ReplicationMax int
+}
+
+// Publisher publishes results with another publisher, such as the IPFS
+// publisher, and then pins their CIDs on an ipfs-cluster so that they are
+// replicated on the cluster's peers.
+type Publisher struct {
+	config    PublisherConfig
+	apiURL    *url.URL
+	publisher publisher.Publisher
+	client    *http.Client
+}
+
+const pinTimeout = time.Minute
+
+func NewIPFSClusterPublisher(
+	ctx context.Context,
+	config PublisherConfig,
+	inner publisher.Publisher,
+) (*Publisher, error) {
+	if config.APIAddress == "" {
+		return nil, errors.New("APIAddress is required")
+	}
+	apiURL, err := url.Parse(strings.TrimSuffix(config.APIAddress, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid ipfs-cluster api address '%s': %w", config.APIAddress, err)
+	}
+	if config.ReplicationMax > 0 && config.ReplicationMin > config.ReplicationMax {
+		return nil, fmt.Errorf("ReplicationMin (%d) is more than ReplicationMax (%d)", config.ReplicationMin, config.ReplicationMax)
+	}
+
+	log.Ctx(ctx).Debug().Msgf("IPFS cluster publisher initialized for cluster: %s", apiURL)
+	return &Publisher{
+		config:    config,
+		apiURL:    apiURL,
+		publisher: inner,
+		client:    &http.Client{Timeout: pinTimeout},
+	}, nil
+}
+
+func (p *Publisher) IsInstalled(ctx context.Context) (bool, error) {
+	installed, err := p.publisher.IsInstalled(ctx)
+	if !installed || err != nil {
+		return installed, err
+	}
+
+	res, err := p.do(ctx, http.MethodGet, "/id", nil)
+	if err != nil {
+		return false, err
+	}
+	defer closer.DrainAndCloseWithLogOnError(ctx, "ipfs-cluster-response", res.Body)
+	return res.StatusCode == http.StatusOK, nil
+}
+
+func (p *Publisher) PublishShardResult(
+	ctx context.Context,
+	shard model.JobShard,
+	hostID string,
+	shardResultPath string,
+) (model.StorageSpec, error) {
+	ctx, span := system.GetTracer().Start(ctx, "pkg/publisher/ipfs_cluster.PublishShardResult")
+	defer span.End()
+
+	spec, err := p.publisher.PublishShardResult(ctx, shard, hostID, shardResultPath)
+	if err != nil {
+		return model.StorageSpec{}, err
+	}
+	if spec.CID == "" {
+		return model.StorageSpec{}, fmt.Errorf("shard %s was published without a CID to pin", shard.ID())
+	}
+	if err = p.pin(ctx, spec.CID, shard.ID()); err != nil {
+		return model.StorageSpec{}, err
+	}
+	return spec, nil
+}
+
+// pin asks the cluster to pin the CID, which it does in the background.
+func (p *Publisher) pin(ctx context.Context, cid, name string) error {
+	query := url.Values{}
+	query.Set("name", name)
+	if p.config.ReplicationMin != 0 {
+		query.Set("replication-min", strconv.Itoa(p.config.ReplicationMin))
+	}
+	if p.config.ReplicationMax != 0 {
+		query.Set("replication-max", strconv.Itoa(p.config.ReplicationMax))
+	}
+
+	res, err := p.do(ctx, http.MethodPost, "/pins/"+url.PathEscape(cid), query)
+	if err != nil {
+		return fmt.Errorf("error pinning %s on ipfs-cluster: %w", cid, err)
+	}
+	defer closer.DrainAndCloseWithLogOnError(ctx, "ipfs-cluster-response", res.Body)
+
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusAccepted {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf("error pinning %s on ipfs-cluster (%d): %s", cid, res.StatusCode, strings.TrimSpace(string(body)))
+	}
+	log.Ctx(ctx).Debug().Msgf("Pinned %s on ipfs-cluster %s", cid, p.apiURL)
+	return nil
+}
+
+func (p *Publisher) do(ctx context.Context, method, path string, query url.Values) (*http.Response, error) {
+	u := *p.apiURL
+	u.Path += path
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	username, password := p.config.Username, p.config.Password
+	if p.config.BasicAuthSecret != nil {
+		auth, err := p.config.BasicAuthSecret.Get(ctx)
+		if err != nil {
+			return nil, err
+		}
+		username, password, _ = strings.Cut(auth, ":")
+	}
+	if username != "" {
+		req.SetBasicAuth(username, password)
+	}
+	return p.client.Do(req)
+}
+
+var _ publisher.Publisher = (*Publisher)(nil)
@@ -0,0 +1,124 @@
+//go:build unit || !integration
+
+package ipfscluster
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/filecoin-project/bacalhau/pkg/compute/secrets"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/publisher"
+	"github.com/stretchr/testify/require"
+)
+
+type fakePublisher struct {
+	cid string
+}
+
+func (f *fakePublisher) IsInstalled(context.Context) (bool, error) {
+	return true, nil
+}
+
+func (f *fakePublisher) PublishShardResult(context.Context, model.JobShard, string, string) (model.StorageSpec, error) {
+	return model.StorageSpec{StorageSource: model.StorageSourceIPFS, CID: f.cid}, nil
+}
+
+var _ publisher.Publisher = (*fakePublisher)(nil)
+
+func TestPublishShardResult(t *testing.T) {
+	var pinned []*http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		username, password, _ := req.BasicAuth()
+		if username != "bacalhau" || password != "secret" {
+			res.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case req.Method == http.MethodGet && req.URL.Path == "/id":
+			res.WriteHeader(http.StatusOK)
+		case req.Method == http.MethodPost && req.URL.Path == "/pins/QmResult":
+			pinned = append(pinned, req)
+			res.WriteHeader(http.StatusAccepted)
+		default:
+			http.Error(res, "no such pin", http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	config := PublisherConfig{
+		APIAddress:     server.URL + "/",
+		Username:       "bacalhau",
+		Password:       "secret",
+		ReplicationMin: 2,
+		ReplicationMax: 3,
+	}
+	p, err := NewIPFSClusterPublisher(ctx, config, &fakePublisher{cid: "QmResult"})
+	require.NoError(t, err)
+
+	installed, err := p.IsInstalled(ctx)
+	require.NoError(t, err)
+	require.True(t, installed)
+
+	shard := model.JobShard{Job: &model.Job{ID: "job"}, Index: 1}
+	spec, err := p.PublishShardResult(ctx, shard, "host", t.TempDir())
+	require.NoError(t, err)
+	require.Equal(t, "QmResult", spec.CID)
+	require.Len(t, pinned, 1)
+	require.Equal(t, "2", pinned[0].URL.Query().Get("replication-min"))
+	require.Equal(t, "3", pinned[0].URL.Query().Get("replication-max"))
+	require.Equal(t, shard.ID(), pinned[0].URL.Query().Get("name"))
+
+	// a pin the cluster refuses fails the publish
+	p, err = NewIPFSClusterPublisher(ctx, config, &fakePublisher{cid: "QmOther"})
+	require.NoError(t, err)
+	_, err = p.PublishShardResult(ctx, shard, "host", t.TempDir())
+	require.ErrorContains(t, err, "no such pin")
+
+	config.Password = "wrong"
+	p, err = NewIPFSClusterPublisher(ctx, config, &fakePublisher{cid: "QmResult"})
+	require.NoError(t, err)
+	installed, err = p.IsInstalled(ctx)
+	require.NoError(t, err)
+	require.False(t, installed)
+}
+
+func TestBasicAuthSecret(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if username, password, _ := req.BasicAuth(); username != "bacalhau" || password != "secret" {
+			res.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	t.Setenv("BACALHAU_SECRET_cluster_auth", "bacalhau:secret")
+	ctx := context.Background()
+	p, err := NewIPFSClusterPublisher(ctx, PublisherConfig{
+		APIAddress: server.URL,
+		Username:   "bacalhau",
+		Password:   "wrong",
+		BasicAuthSecret: secrets.NewCredential(secrets.CredentialParams{
+			Provider: secrets.NewEnvProvider(""),
+			Name:     "cluster/auth",
+		}),
+	}, &fakePublisher{})
+	require.NoError(t, err)
+	installed, err := p.IsInstalled(ctx)
+	require.NoError(t, err)
+	require.True(t, installed)
+}
+
+func TestNewIPFSClusterPublisherValidatesConfig(t *testing.T) {
+	ctx := context.Background()
+	_, err := NewIPFSClusterPublisher(ctx, PublisherConfig{}, &fakePublisher{})
+	require.Error(t, err)
+	_, err = NewIPFSClusterPublisher(ctx, PublisherConfig{
+		APIAddress:     "http://127.0.0.1:9094",
+		ReplicationMin: 3,
+		ReplicationMax: 2,
+	}, &fakePublisher{})
+	require.Error(t, err)
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/filecoin-project/bacalhau/pkg/publisher/estuary"
 	filecoinlotus "github.com/filecoin-project/bacalhau/pkg/publisher/filecoin_lotus"
 	"github.com/filecoin-project/bacalhau/pkg/publisher/ipfs"
+	ipfscluster "github.com/filecoin-project/bacalhau/pkg/publisher/ipfs_cluster"
 	"github.com/filecoin-project/bacalhau/pkg/publisher/noop"
 	"github.com/filecoin-project/bacalhau/pkg/system"
 )
@@ -18,22 +19,33 @@ func NewIPFSPublishers(
 	ctx context.Context,
 	cm *system.CleanupManager,
 	ipfsMultiAddress string,
-	estuaryAPIKey string,
+	estuaryConfig estuary.EstuaryPublisherConfig,
 	lotusConfig *filecoinlotus.PublisherConfig,
+	clusterConfig *ipfscluster.PublisherConfig,
 ) (publisher.PublisherProvider, error) {
 	noopPublisher := noop.NewNoopPublisher()
+	var ipfsPublisher publisher.Publisher
 	ipfsPublisher, err := ipfs.NewIPFSPublisher(ctx, cm, ipfsMultiAddress)
 	if err != nil {
 		return nil, err
 	}
 
+	// results published to IPFS are also pinned on the ipfs-cluster, if
+	// there is one, to replicate them
+	if clusterConfig != nil {
+		ipfsPublisher, err = ipfscluster.NewIPFSClusterPublisher(ctx, *clusterConfig, ipfsPublisher)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// we don't want to enforce that every compute node needs to have an estuary API key
 	// and so let's only add the
 	var estuaryPublisher publisher.Publisher = ipfsPublisher
-	if estuaryAPIKey != "" {
+	if estuaryConfig.APIKey != "" || estuaryConfig.APIKeySecret != nil {
 		estuaryPublisher = combo.NewFanoutPublisher(
 			ipfsPublisher,
-			estuary.NewEstuaryPublisher(estuary.EstuaryPublisherConfig{APIKey: estuaryAPIKey}),
+			estuary.NewEstuaryPublisher(estuaryConfig),
 		)
 		if err != nil {
 			return nil, err
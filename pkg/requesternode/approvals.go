@@ -0,0 +1,74 @@
+package requesternode
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+)
+
+// approvals holds the jobs submitted to a requester node that requires an
+// approver to review jobs before they are announced to the network. Jobs
+// are only held in memory, so those still waiting when the node restarts
+// have to be submitted again.
+type approvals struct {
+	approvers map[string]bool
+
+	mutex sync.Mutex
+	// the created events of the jobs waiting for approval, which are
+	// published once they are approved
+	pending map[string]model.JobEvent
+	// why the jobs that were rejected were, for the errors of later requests
+	rejected map[string]string
+}
+
+func newApprovals(approvers []string) *approvals {
+	a := &approvals{
+		approvers: map[string]bool{},
+		pending:   map[string]model.JobEvent{},
+		rejected:  map[string]string{},
+	}
+	for _, approver := range approvers {
+		a.approvers[approver] = true
+	}
+	return a
+}
+
+func (a *approvals) isApprover(clientID string) bool {
+	return a.approvers[clientID]
+}
+
+func (a *approvals) hold(ev model.JobEvent) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.pending[ev.JobID] = ev
+}
+
+// decide removes a job from the ones waiting for approval and returns its
+// created event, or an error if the job is not waiting for approval.
+func (a *approvals) decide(jobID string, approved bool, reason string) (model.JobEvent, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	ev, ok := a.pending[jobID]
+	if !ok {
+		if rejectReason, rejected := a.rejected[jobID]; rejected {
+			return model.JobEvent{}, fmt.Errorf("job %s was rejected: %s", jobID, rejectReason)
+		}
+		return model.JobEvent{}, fmt.Errorf("job %s is not waiting for approval", jobID)
+	}
+	delete(a.pending, jobID)
+	if !approved {
+		a.rejected[jobID] = reason
+	}
+	return ev, nil
+}
+
+func (a *approvals) list() []string {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	jobIDs := make([]string, 0, len(a.pending))
+	for jobID := range a.pending {
+		jobIDs = append(jobIDs, jobID)
+	}
+	return jobIDs
+}
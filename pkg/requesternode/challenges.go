@@ -0,0 +1,236 @@
+package requesternode
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	jobutils "github.com/filecoin-project/bacalhau/pkg/job"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/verifier"
+	"github.com/rs/zerolog/log"
+)
+
+// challenges holds when the challenge period of the results a requester node
+// provisionally accepted ends. They are only held in memory, so results
+// can't be challenged once the node restarts.
+type challenges struct {
+	mutex         sync.Mutex
+	challengeable map[model.JobChallenge]time.Time
+}
+
+func newChallenges() *challenges {
+	return &challenges{
+		challengeable: map[model.JobChallenge]time.Time{},
+	}
+}
+
+// provision lets the results be challenged until the time.
+func (c *challenges) provision(challenge model.JobChallenge, until time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.challengeable[challenge] = until
+	// forget the results whose challenge period has ended
+	now := time.Now()
+	for other, otherUntil := range c.challengeable {
+		if now.After(otherUntil) {
+			delete(c.challengeable, other)
+		}
+	}
+}
+
+// start takes the results out of the ones that can be challenged, so that
+// they are only challenged once, and returns when their challenge period
+// ends, or an error if they can't be challenged.
+func (c *challenges) start(challenge model.JobChallenge) (time.Time, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	until, ok := c.challengeable[challenge]
+	if !ok {
+		return time.Time{}, fmt.Errorf("the results of node %s for shard %d of job %s can't be challenged, "+
+			"as they were already challenged or their challenge period ended", challenge.NodeID, challenge.ShardIndex,
+			challenge.JobID)
+	}
+	delete(c.challengeable, challenge)
+	if time.Now().After(until) {
+		return time.Time{}, fmt.Errorf("the challenge period of the results of node %s for shard %d of job %s "+
+			"ended at %s", challenge.NodeID, challenge.ShardIndex, challenge.JobID, until.Format(time.RFC3339))
+	}
+	return until, nil
+}
+
+// cancel lets the results be challenged again until the time, if starting
+// their challenge failed.
+func (c *challenges) cancel(challenge model.JobChallenge, until time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.challengeable[challenge] = until
+}
+
+// challengeableVerifier returns the verifier of the job if it accepts results
+// provisionally, for them to be challenged.
+func (node *RequesterNode) challengeableVerifier(
+	ctx context.Context,
+	verifierType model.Verifier,
+) (verifier.ChallengeableVerifier, bool) {
+	jobVerifier, err := node.verifiers.GetVerifier(ctx, verifierType)
+	if err != nil {
+		return nil, false
+	}
+	challengeableVerifier, ok := jobVerifier.(verifier.ChallengeableVerifier)
+	return challengeableVerifier, ok
+}
+
+// ChallengeResult challenges the results a node published for a shard of a
+// job verified optimistically, within the challenge period of the job, by
+// submitting a job that re-executes the shard on another node. Its outcome
+// is recorded on the challenged job once the re-execution's results are
+// verified. Returns the re-execution job.
+func (node *RequesterNode) ChallengeResult(ctx context.Context, data model.JobChallengePayload) (*model.Job, error) {
+	j, err := node.localDB.GetJob(ctx, data.JobID)
+	if err != nil {
+		return nil, err
+	}
+	if j.RequesterNodeID != node.ID {
+		return nil, fmt.Errorf("job %s is owned by requester node %s, not this one", data.JobID, j.RequesterNodeID)
+	}
+	if _, ok := node.challengeableVerifier(ctx, j.Spec.Verifier); !ok {
+		return nil, fmt.Errorf("the results of job %s can't be challenged, as its %s verifier doesn't accept "+
+			"them provisionally", j.ID, j.Spec.Verifier)
+	}
+
+	challenge := model.JobChallenge{JobID: j.ID, ShardIndex: data.ShardIndex, NodeID: data.NodeID}
+	until, err := node.challenges.start(challenge)
+	if err != nil {
+		return nil, err
+	}
+	shard := model.JobShard{Job: j, Index: data.ShardIndex}
+	reexecution, err := node.reexecuteShard(ctx, data.ClientID, shard, challenge)
+	if err != nil {
+		node.challenges.cancel(challenge, until)
+		return nil, err
+	}
+
+	reason := data.Reason
+	if reason == "" {
+		reason = "no reason given"
+	}
+	ev := node.constructShardEvent(shard, model.JobEventChallenged)
+	ev.TargetNodeID = data.NodeID
+	ev.Status = fmt.Sprintf("challenged by client %s, re-executing the shard as job %s: %s",
+		data.ClientID, reexecution.ID, reason)
+	if err = node.jobEventPublisher.HandleJobEvent(ctx, ev); err != nil {
+		return nil, err
+	}
+	return reexecution, nil
+}
+
+// reexecuteShard submits a job that runs the shard again over its inputs,
+// on a node other than the challenged one.
+func (node *RequesterNode) reexecuteShard(
+	ctx context.Context,
+	clientID string,
+	shard model.JobShard,
+	challenge model.JobChallenge,
+) (*model.Job, error) {
+	inputs, err := jobutils.GetShardStorageSpec(ctx, shard, node.storageProviders)
+	if err != nil {
+		return nil, fmt.Errorf("error getting the inputs of shard %s: %w", shard, err)
+	}
+
+	reexecution := model.NewJob()
+	reexecution.APIVersion = shard.Job.APIVersion
+	reexecution.Spec = shard.Job.Spec
+	reexecution.Spec.Inputs = inputs
+	reexecution.Spec.Sharding = model.JobShardingConfig{}
+	reexecution.Spec.Challenge = challenge
+	reexecution.Deal = shard.Job.Deal
+	reexecution.Deal.Concurrency = 1
+	reexecution.Deal.Confidence = 0
+	reexecution.Deal.MinBids = 0
+	reexecution.Deal.MinZones = 0
+	reexecution.Deal.TargetNodes = nil
+	reexecution.Deal.Deadline = time.Time{}
+
+	return node.submitJob(ctx, model.JobCreatePayload{ClientID: clientID, Job: reexecution})
+}
+
+// resolveChallenge has the verifier compare the results of a re-execution
+// job with the results it challenged, and records the outcome on the
+// challenged job.
+func (node *RequesterNode) resolveChallenge(
+	ctx context.Context,
+	shard model.JobShard,
+	verifiedResults []verifier.VerifierResult,
+) error {
+	challenge := shard.Job.Spec.Challenge
+	if len(verifiedResults) == 0 {
+		return fmt.Errorf("the re-execution of shard %d of job %s produced no verified results",
+			challenge.ShardIndex, challenge.JobID)
+	}
+	challengedJob, err := node.localDB.GetJob(ctx, challenge.JobID)
+	if err != nil {
+		return err
+	}
+	challengeableVerifier, ok := node.challengeableVerifier(ctx, challengedJob.Spec.Verifier)
+	if !ok {
+		return fmt.Errorf("the %s verifier of job %s can't decide challenges",
+			challengedJob.Spec.Verifier, challengedJob.ID)
+	}
+
+	challenged, err := node.shardResult(ctx, challenge.JobID, challenge.NodeID, challenge.ShardIndex)
+	if err != nil {
+		return err
+	}
+	reexecution, err := node.shardResult(ctx, shard.Job.ID, verifiedResults[0].NodeID, shard.Index)
+	if err != nil {
+		return err
+	}
+	challengedShard := model.JobShard{Job: challengedJob, Index: challenge.ShardIndex}
+	outcome, err := challengeableVerifier.VerifyChallenge(ctx, challengedShard, challenged, reexecution)
+	if err != nil {
+		return err
+	}
+
+	// the re-execution agrees or dissents with the challenged node, like the
+	// nodes of a shard that are compared with each other do
+	node.reputations.record([]verifier.VerifierResult{
+		{NodeID: challenge.NodeID, Verified: !outcome.Upheld},
+		{NodeID: reexecution.NodeID, Verified: true},
+	})
+
+	eventName := model.JobEventChallengeRejected
+	if outcome.Upheld {
+		eventName = model.JobEventChallengeUpheld
+	}
+	log.Ctx(ctx).Info().Msgf("%s for the results of node %s for shard %s: %s",
+		eventName, challenge.NodeID, challengedShard, outcome.Reason)
+	ev := node.constructShardEvent(challengedShard, eventName)
+	ev.TargetNodeID = challenge.NodeID
+	ev.Status = fmt.Sprintf("re-executed as job %s, %s", shard.Job.ID, outcome.Reason)
+	return node.jobEventPublisher.HandleJobEvent(ctx, ev)
+}
+
+// publishedResult returns what the node proposed for a shard of a job.
+func (node *RequesterNode) shardResult(
+	ctx context.Context,
+	jobID, nodeID string,
+	shardIndex int,
+) (verifier.PublishedResult, error) {
+	jobState, err := node.localDB.GetJobState(ctx, jobID)
+	if err != nil {
+		return verifier.PublishedResult{}, err
+	}
+	shardState, ok := jobState.Nodes[nodeID].Shards[shardIndex]
+	if !ok {
+		return verifier.PublishedResult{}, fmt.Errorf("node %s has no state for shard %d of job %s",
+			nodeID, shardIndex, jobID)
+	}
+	return verifier.PublishedResult{
+		NodeID:               nodeID,
+		ShardIndex:           shardIndex,
+		PublishedResult:      shardState.PublishedResult,
+		VerificationProposal: shardState.VerificationProposal,
+	}, nil
+}
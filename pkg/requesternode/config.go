@@ -18,6 +18,12 @@ const DefaultMinJobNegotiationTimeout = 0 * time.Second
 // this value with DefaultJobExecutionTimeout.
 const DefaultMinJobExecutionTimeout = 0 * time.Second
 
+// DefaultIdempotencyKeyTTL how long the job submitted with an idempotency key is returned when the key is reused.
+const DefaultIdempotencyKeyTTL = 24 * time.Hour
+
+// DefaultChallengePeriod how long the results of jobs verified optimistically can be challenged for.
+const DefaultChallengePeriod = time.Hour
+
 // DefaultStateManagerTaskInterval background task interval that periodically checks for expired states among other things.
 const DefaultStateManagerTaskInterval = 30 * time.Second
 
@@ -52,12 +58,39 @@ type RequesterNodeConfig struct {
 
 	// background task interval that periodically checks for expired states among other things.
 	StateManagerBackgroundTaskInterval time.Duration
+
+	// how long the job submitted with an idempotency key is returned when the key is reused.
+	IdempotencyKeyTTL time.Duration
+
+	// how long to collect bids for a shard before selecting from them, instead of selecting as soon as
+	// the deal's MinBids have been received.
+	BidWindow time.Duration
+
+	// whether jobs are held until one of the Approvers approves them, rather than being announced
+	// to the network as soon as they are submitted, for networks over sensitive datasets.
+	ApprovalRequired bool
+
+	// the IDs of the clients that can approve or reject the jobs held for approval.
+	Approvers []string
+
+	// the IDs of the clients that can flag clients and jobs as abusive, which adds them to the
+	// denylist compute nodes can subscribe to.
+	Moderators []string
+
+	// the file the denylist is kept in, so that the clients and jobs flagged by the Moderators stay
+	// flagged when the node restarts. The denylist is only held in memory if it's empty.
+	DenylistPath string
+
+	// how long the results of jobs verified optimistically can be challenged for, if the job doesn't say.
+	DefaultChallengePeriod time.Duration
 }
 
 func NewDefaultRequesterNodeConfig() RequesterNodeConfig {
 	return RequesterNodeConfig{
 		TimeoutConfig:                      NewDefaultRequesterTimeoutConfig(),
 		StateManagerBackgroundTaskInterval: DefaultStateManagerTaskInterval,
+		IdempotencyKeyTTL:                  DefaultIdempotencyKeyTTL,
+		DefaultChallengePeriod:             DefaultChallengePeriod,
 	}
 }
 
@@ -73,6 +106,12 @@ func populateDefaultConfigs(other RequesterNodeConfig) RequesterNodeConfig {
 	if config.StateManagerBackgroundTaskInterval == 0 {
 		config.StateManagerBackgroundTaskInterval = DefaultStateManagerTaskInterval
 	}
+	if config.IdempotencyKeyTTL == 0 {
+		config.IdempotencyKeyTTL = DefaultIdempotencyKeyTTL
+	}
+	if config.DefaultChallengePeriod == 0 {
+		config.DefaultChallengePeriod = DefaultChallengePeriod
+	}
 
 	return config
 }
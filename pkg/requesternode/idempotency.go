@@ -0,0 +1,201 @@
+package requesternode
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/bacalhau/pkg/localdb"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+)
+
+// How often expired keys are pruned.
+const idempotencyKeyPruneInterval = time.Minute
+
+type idempotentSubmission struct {
+	jobID     string
+	expiresAt time.Time
+}
+
+type unrecordedKey struct {
+	key       string
+	expiresAt time.Time
+}
+
+// idempotencyKeys remembers the jobs submitted with an idempotency key, so
+// that a client retrying a submission gets back the job it already created.
+// Keys are scoped to the client that submitted them and are kept for ttl.
+// They are recorded in the local db as local events of their jobs, so that
+// they are restored with it after the node restarts.
+type idempotencyKeys struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mutex       sync.Mutex
+	submissions map[string]idempotentSubmission
+	lastPruned  time.Time
+	// the keys of the jobs yet to be recorded, by job ID. A key is recorded
+	// once the job is created in the local db, so that the event log always
+	// holds the key after the job.
+	unrecorded map[string]unrecordedKey
+	// keys being submitted, so that concurrent retries wait for the first
+	// submission rather than creating another job
+	inFlight map[string]*keyLock
+
+	// the local db is only rebuilt once the node has started, so the keys
+	// recorded in it are restored when the first key is submitted
+	restoreMutex sync.Mutex
+	restored     bool
+}
+
+type keyLock struct {
+	sync.Mutex
+	// the number of submissions holding or waiting for the lock
+	users int
+}
+
+func newIdempotencyKeys(ttl time.Duration) *idempotencyKeys {
+	return &idempotencyKeys{
+		ttl:         ttl,
+		now:         time.Now,
+		submissions: map[string]idempotentSubmission{},
+		unrecorded:  map[string]unrecordedKey{},
+		inFlight:    map[string]*keyLock{},
+	}
+}
+
+func idempotencyMapKey(clientID, key string) string {
+	return clientID + "/" + key
+}
+
+// lock serializes the submissions that use the same key and returns the
+// function that releases it.
+func (k *idempotencyKeys) lock(clientID, key string) func() {
+	mapKey := idempotencyMapKey(clientID, key)
+
+	k.mutex.Lock()
+	l, ok := k.inFlight[mapKey]
+	if !ok {
+		l = &keyLock{}
+		k.inFlight[mapKey] = l
+	}
+	l.users++
+	k.mutex.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		k.mutex.Lock()
+		defer k.mutex.Unlock()
+		l.users--
+		if l.users == 0 {
+			delete(k.inFlight, mapKey)
+		}
+	}
+}
+
+func (k *idempotencyKeys) get(clientID, key string) (string, bool) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	submission, ok := k.submissions[idempotencyMapKey(clientID, key)]
+	if !ok || k.now().After(submission.expiresAt) {
+		return "", false
+	}
+	return submission.jobID, true
+}
+
+func (k *idempotencyKeys) add(clientID, key, jobID string) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	now := k.now()
+	k.prune(now)
+	k.submissions[idempotencyMapKey(clientID, key)] = idempotentSubmission{
+		jobID:     jobID,
+		expiresAt: now.Add(k.ttl),
+	}
+}
+
+// expectCreated holds on to the key a job was submitted with until the job
+// is created in the local db, for it to be recorded then.
+func (k *idempotencyKeys) expectCreated(jobID, key string) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	now := k.now()
+	k.prune(now)
+	k.unrecorded[jobID] = unrecordedKey{
+		key:       key,
+		expiresAt: now.Add(k.ttl),
+	}
+}
+
+// created returns the key the job was submitted with, if it is yet to be
+// recorded, and forgets it.
+func (k *idempotencyKeys) created(jobID string) (string, bool) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	unrecorded, ok := k.unrecorded[jobID]
+	if !ok {
+		return "", false
+	}
+	delete(k.unrecorded, jobID)
+	return unrecorded.key, true
+}
+
+func (k *idempotencyKeys) prune(now time.Time) {
+	if now.Sub(k.lastPruned) < idempotencyKeyPruneInterval {
+		return
+	}
+	for mapKey, submission := range k.submissions {
+		if now.After(submission.expiresAt) {
+			delete(k.submissions, mapKey)
+		}
+	}
+	// the jobs of these keys were never created, e.g. as they weren't approved
+	for jobID, unrecorded := range k.unrecorded {
+		if now.After(unrecorded.expiresAt) {
+			delete(k.unrecorded, jobID)
+		}
+	}
+	k.lastPruned = now
+}
+
+// restore loads the keys recorded in the local db for the jobs of the
+// requester node that were created less than ttl ago, unless they have been
+// loaded already. Keys submitted since the node started are kept.
+func (k *idempotencyKeys) restore(ctx context.Context, localDB localdb.LocalDB, requesterNodeID string) error {
+	k.restoreMutex.Lock()
+	defer k.restoreMutex.Unlock()
+	if k.restored {
+		return nil
+	}
+
+	jobs, err := localDB.GetJobs(ctx, localdb.JobQuery{ReturnAll: true, Limit: math.MaxInt})
+	if err != nil {
+		return err
+	}
+	now := k.now()
+	for _, j := range jobs {
+		expiresAt := j.CreatedAt.Add(k.ttl)
+		if j.RequesterNodeID != requesterNodeID || now.After(expiresAt) {
+			continue
+		}
+		events, err := localDB.GetJobLocalEvents(ctx, j.ID)
+		if err != nil {
+			return err
+		}
+		for _, event := range events {
+			if event.EventName != model.JobLocalEventIdempotencyKey {
+				continue
+			}
+			mapKey := idempotencyMapKey(j.ClientID, event.IdempotencyKey)
+			k.mutex.Lock()
+			if _, ok := k.submissions[mapKey]; !ok {
+				k.submissions[mapKey] = idempotentSubmission{jobID: j.ID, expiresAt: expiresAt}
+			}
+			k.mutex.Unlock()
+		}
+	}
+	k.restored = true
+	return nil
+}
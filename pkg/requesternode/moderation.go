@@ -0,0 +1,147 @@
+package requesternode
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/storage/util"
+)
+
+// moderation holds the clients and jobs the moderators of a requester node
+// have flagged as abusive. The denylist is written to path whenever it
+// changes and loaded from it when the node starts, so that it survives
+// restarts. Without a path, it is only held in memory.
+type moderation struct {
+	moderators map[string]bool
+	path       string
+
+	mutex   sync.Mutex
+	clients map[string]model.DenylistEntry
+	jobs    map[string]model.DenylistEntry
+}
+
+func newModeration(moderators []string, path string) (*moderation, error) {
+	m := &moderation{
+		moderators: map[string]bool{},
+		path:       path,
+		clients:    map[string]model.DenylistEntry{},
+		jobs:       map[string]model.DenylistEntry{},
+	}
+	for _, moderator := range moderators {
+		m.moderators[moderator] = true
+	}
+	if err := m.load(); err != nil {
+		return nil, fmt.Errorf("failed to load the denylist: %w", err)
+	}
+	return m, nil
+}
+
+func (m *moderation) isModerator(clientID string) bool {
+	return m.moderators[clientID]
+}
+
+// flag adds the client or job of the payload to the denylist, or removes it
+// if the payload unflags it. The denylist is left as it was if it can't be
+// saved.
+func (m *moderation) flag(data model.ModerationPayload) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	entries, id := m.clients, data.TargetClientID
+	if data.TargetJobID != "" {
+		entries, id = m.jobs, data.TargetJobID
+	}
+	previous, flagged := entries[id]
+	if data.Unflag {
+		delete(entries, id)
+	} else {
+		entries[id] = model.DenylistEntry{
+			ID:        id,
+			Reason:    data.Reason,
+			FlaggedBy: data.ClientID,
+			FlaggedAt: time.Now(),
+		}
+	}
+
+	if err := m.save(); err != nil {
+		if flagged {
+			entries[id] = previous
+		} else {
+			delete(entries, id)
+		}
+		return fmt.Errorf("failed to save the denylist: %w", err)
+	}
+	return nil
+}
+
+func (m *moderation) denylist() model.Denylist {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.denylistLocked()
+}
+
+func (m *moderation) denylistLocked() model.Denylist {
+	return model.Denylist{
+		Clients: sortedEntries(m.clients),
+		Jobs:    sortedEntries(m.jobs),
+	}
+}
+
+func (m *moderation) load() error {
+	if m.path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(m.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var denylist model.Denylist
+	if err = json.Unmarshal(data, &denylist); err != nil {
+		return err
+	}
+	for _, entry := range denylist.Clients {
+		m.clients[entry.ID] = entry
+	}
+	for _, entry := range denylist.Jobs {
+		m.jobs[entry.ID] = entry
+	}
+	return nil
+}
+
+// save writes the denylist to path, through a temporary file so that a crash
+// never leaves a half written denylist behind. The caller must hold mutex.
+func (m *moderation) save() error {
+	if m.path == "" {
+		return nil
+	}
+	data, err := json.Marshal(m.denylistLocked())
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(m.path), util.OS_USER_RWX); err != nil {
+		return err
+	}
+	tmpPath := m.path + ".tmp"
+	if err = os.WriteFile(tmpPath, data, util.OS_USER_RW); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, m.path)
+}
+
+func sortedEntries(entries map[string]model.DenylistEntry) []model.DenylistEntry {
+	sorted := make([]model.DenylistEntry, 0, len(entries))
+	for _, entry := range entries {
+		sorted = append(sorted, entry)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+	return sorted
+}
@@ -0,0 +1,39 @@
+//go:build unit || !integration
+
+package requesternode
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModerationSurvivesRestarts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "denylist.json")
+	m, err := newModeration([]string{"moderator"}, path)
+	require.NoError(t, err)
+	require.Equal(t, model.Denylist{Clients: []model.DenylistEntry{}, Jobs: []model.DenylistEntry{}}, m.denylist())
+
+	for _, data := range []model.ModerationPayload{
+		{ClientID: "moderator", TargetClientID: "abusive-client", Reason: "spam"},
+		{ClientID: "moderator", TargetJobID: "abusive-job", Reason: "mining"},
+		{ClientID: "moderator", TargetJobID: "other-job"},
+		{ClientID: "moderator", TargetJobID: "other-job", Unflag: true},
+	} {
+		require.NoError(t, m.flag(data))
+	}
+
+	// the node is restarted with the same denylist
+	restarted, err := newModeration([]string{"moderator"}, path)
+	require.NoError(t, err)
+	denylist := restarted.denylist()
+	require.Len(t, denylist.Clients, 1)
+	require.Equal(t, "abusive-client", denylist.Clients[0].ID)
+	require.Equal(t, "spam", denylist.Clients[0].Reason)
+	require.Len(t, denylist.Jobs, 1)
+	require.Equal(t, "abusive-job", denylist.Jobs[0].ID)
+	require.Equal(t, "moderator", denylist.Jobs[0].FlaggedBy)
+	require.Equal(t, m.denylist().Jobs[0].FlaggedAt.UTC(), denylist.Jobs[0].FlaggedAt.UTC())
+}
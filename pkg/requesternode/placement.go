@@ -0,0 +1,73 @@
+package requesternode
+
+import (
+	"fmt"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+)
+
+// place assigns the shard to the node if the placement constraints of the
+// job allow it to run the shard alongside the nodes already accepted for it,
+// and returns why not otherwise.
+func (m *shardStateMachine) place(nodeID string, accepted map[string]struct{}) string {
+	if challenge := m.shard.Job.Spec.Challenge; challenge.JobID != "" && challenge.NodeID == nodeID {
+		return fmt.Sprintf("the job re-executes the results of the node for shard %d of job %s, which were challenged",
+			challenge.ShardIndex, challenge.JobID)
+	}
+	if m.shard.Job.Deal.RequireHardenedNodes && !m.nodeHardening[nodeID].Hardened() {
+		return fmt.Sprintf("the job requires hardened nodes, and the node runs containers with %s",
+			m.nodeHardening[nodeID])
+	}
+	if conflict := m.zoneConflict(nodeID, accepted); conflict != "" {
+		return conflict
+	}
+	return m.assign(nodeID)
+}
+
+// zoneConflict returns why the node can't run the shard if accepting it
+// would leave too few nodes to spread the shard across the job's MinZones.
+func (m *shardStateMachine) zoneConflict(nodeID string, accepted map[string]struct{}) string {
+	deal := m.shard.Job.Deal
+	if deal.MinZones <= 1 {
+		return ""
+	}
+	zones := make(map[string]struct{}, len(accepted))
+	for acceptedNodeID := range accepted {
+		zones[m.zoneOf(acceptedNodeID)] = struct{}{}
+	}
+	if _, ok := zones[m.zoneOf(nodeID)]; !ok {
+		return ""
+	}
+	if remaining := deal.Concurrency - len(accepted) - 1; remaining >= deal.MinZones-len(zones) {
+		return ""
+	}
+	return fmt.Sprintf("the shard must run in %d distinct zones, and already runs in the node's zone", deal.MinZones)
+}
+
+// zoneOf returns the zone the node said it is in, or its ID if it didn't, so
+// that it counts as a zone of its own.
+func (m *shardStateMachine) zoneOf(nodeID string) string {
+	if zone := m.nodeZones[nodeID]; zone != "" {
+		return zone
+	}
+	return "node:" + nodeID
+}
+
+// placedShardLocked returns the index of another shard of the job that was
+// assigned to the node, while the state machine of the shard is kept. The
+// mu of the manager must be held.
+func (m *shardStateMachineManager) placedShardLocked(shard model.JobShard, nodeID string) (int, bool) {
+	for index := 0; index < shard.Job.ExecutionPlan.TotalShards; index++ {
+		if index == shard.Index {
+			continue
+		}
+		other, ok := m.shardStates[model.GetShardID(shard.Job.ID, index)]
+		if !ok {
+			continue
+		}
+		if _, ok = other.placedNodes[nodeID]; ok {
+			return index, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,93 @@
+package requesternode
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/verifier"
+)
+
+// nodeReputation counts how often a node's results agreed with the quorum
+// of the nodes that ran the same shard, and how often they dissented.
+type nodeReputation struct {
+	NodeID    string `json:"node_id"`
+	Agreed    int    `json:"agreed"`
+	Dissented int    `json:"dissented"`
+}
+
+// reputations keeps the reputation of the nodes whose results this
+// requester verified. It is only kept in memory.
+type reputations struct {
+	mutex sync.Mutex
+	nodes map[string]*nodeReputation
+}
+
+func newReputations() *reputations {
+	return &reputations{
+		nodes: map[string]*nodeReputation{},
+	}
+}
+
+// record updates the reputations from the verification of a shard. Nothing
+// is recorded when the results reached no quorum, as then there is nobody
+// to tell apart.
+func (r *reputations) record(results []verifier.VerifierResult) {
+	quorum := false
+	for _, result := range results {
+		quorum = quorum || result.Verified
+	}
+	if !quorum || len(results) < 2 {
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, result := range results {
+		node, ok := r.nodes[result.NodeID]
+		if !ok {
+			node = &nodeReputation{NodeID: result.NodeID}
+			r.nodes[result.NodeID] = node
+		}
+		if result.Verified {
+			node.Agreed++
+		} else {
+			node.Dissented++
+		}
+	}
+}
+
+// dissents returns the number of times a node's results dissented.
+func (r *reputations) dissents(nodeID string) int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if node, ok := r.nodes[nodeID]; ok {
+		return node.Dissented
+	}
+	return 0
+}
+
+func (r *reputations) GetDebugInfo() (model.DebugInfo, error) {
+	r.mutex.Lock()
+	nodes := make([]nodeReputation, 0, len(r.nodes))
+	for _, node := range r.nodes {
+		nodes = append(nodes, *node)
+	}
+	r.mutex.Unlock()
+
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].NodeID < nodes[j].NodeID
+	})
+	info, err := json.Marshal(nodes)
+	if err != nil {
+		return model.DebugInfo{}, err
+	}
+	return model.DebugInfo{
+		Component: "NodeReputation",
+		Info:      string(info),
+	}, nil
+}
+
+// compile-time check that we implement the interface
+var _ model.DebugInfoProvider = (*reputations)(nil)
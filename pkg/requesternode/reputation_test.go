@@ -0,0 +1,57 @@
+//go:build unit || !integration
+
+package requesternode
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/filecoin-project/bacalhau/pkg/verifier"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReputationsRecord(t *testing.T) {
+	r := newReputations()
+	r.record([]verifier.VerifierResult{
+		{NodeID: "a", Verified: true},
+		{NodeID: "b", Verified: true},
+		{NodeID: "c", Verified: false},
+	})
+	r.record([]verifier.VerifierResult{
+		{NodeID: "a", Verified: true},
+		{NodeID: "c", Verified: false},
+	})
+
+	require.Equal(t, 0, r.dissents("a"))
+	require.Equal(t, 0, r.dissents("b"))
+	require.Equal(t, 2, r.dissents("c"))
+	require.Equal(t, 0, r.dissents("unknown"))
+
+	info, err := r.GetDebugInfo()
+	require.NoError(t, err)
+	var nodes []nodeReputation
+	require.NoError(t, json.Unmarshal([]byte(info.Info), &nodes))
+	require.Equal(t, []nodeReputation{
+		{NodeID: "a", Agreed: 2},
+		{NodeID: "b", Agreed: 1},
+		{NodeID: "c", Dissented: 2},
+	}, nodes)
+}
+
+func TestReputationsRecordNoQuorum(t *testing.T) {
+	r := newReputations()
+	// nobody agreed, so there is nobody to tell apart
+	r.record([]verifier.VerifierResult{
+		{NodeID: "a", Verified: false},
+		{NodeID: "b", Verified: false},
+	})
+	// a single result can't dissent
+	r.record([]verifier.VerifierResult{
+		{NodeID: "c", Verified: true},
+	})
+
+	info, err := r.GetDebugInfo()
+	require.NoError(t, err)
+	require.Equal(t, "[]", info.Info)
+	require.Equal(t, 0, r.dissents("a"))
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/filecoin-project/bacalhau/pkg/bacerrors"
+	"github.com/filecoin-project/bacalhau/pkg/compute/capacity"
 	"github.com/filecoin-project/bacalhau/pkg/eventhandler"
 	jobutils "github.com/filecoin-project/bacalhau/pkg/job"
 	"github.com/filecoin-project/bacalhau/pkg/localdb"
@@ -29,6 +31,11 @@ type RequesterNode struct {
 	config             RequesterNodeConfig //nolint:gocritic
 
 	shardStateManager *shardStateMachineManager
+	idempotencyKeys   *idempotencyKeys
+	reputations       *reputations
+	approvals         *approvals
+	challenges        *challenges
+	moderation        *moderation
 }
 
 func NewRequesterNode(
@@ -44,6 +51,10 @@ func NewRequesterNode(
 ) (*RequesterNode, error) {
 	// TODO: instrument with trace
 	useConfig := populateDefaultConfigs(config)
+	nodeModeration, err := newModeration(useConfig.Moderators, useConfig.DenylistPath)
+	if err != nil {
+		return nil, err
+	}
 	requesterNode := &RequesterNode{
 		ID:                 nodeID,
 		localDB:            localDB,
@@ -53,6 +64,11 @@ func NewRequesterNode(
 		storageProviders:   storageProviders,
 		config:             useConfig,
 		shardStateManager:  newShardStateMachineManager(ctx, cm, useConfig),
+		idempotencyKeys:    newIdempotencyKeys(useConfig.IdempotencyKeyTTL),
+		reputations:        newReputations(),
+		approvals:          newApprovals(useConfig.Approvers),
+		challenges:         newChallenges(),
+		moderation:         nodeModeration,
 	}
 	return requesterNode, nil
 }
@@ -69,6 +85,8 @@ func (node *RequesterNode) HandleJobEvent(ctx context.Context, event model.JobEv
 	}
 
 	switch event.EventName {
+	case model.JobEventCreated:
+		return node.recordIdempotencyKey(ctx, event.JobID)
 	case model.JobEventBid, model.JobEventResultsProposed, model.JobEventResultsPublished, model.JobEventComputeError:
 		shard := model.JobShard{Job: j, Index: event.ShardIndex}
 		return node.triggerStateTransition(ctx, event, shard)
@@ -76,7 +94,38 @@ func (node *RequesterNode) HandleJobEvent(ctx context.Context, event model.JobEv
 	return nil
 }
 
+// SubmitJob creates a new job and announces it to the network. If the
+// payload has an idempotency key that the client already submitted a job
+// with, that job is returned instead.
 func (node *RequesterNode) SubmitJob(ctx context.Context, data model.JobCreatePayload) (*model.Job, error) {
+	if data.IdempotencyKey == "" {
+		return node.submitJob(ctx, data)
+	}
+
+	unlock := node.idempotencyKeys.lock(data.ClientID, data.IdempotencyKey)
+	defer unlock()
+
+	if err := node.idempotencyKeys.restore(ctx, node.localDB, node.ID); err != nil {
+		log.Ctx(ctx).Warn().Msgf("could not restore idempotency keys from the local db: %s", err)
+	}
+	if jobID, ok := node.idempotencyKeys.get(data.ClientID, data.IdempotencyKey); ok {
+		j, err := node.localDB.GetJob(ctx, jobID)
+		if err == nil {
+			log.Ctx(ctx).Debug().Msgf("returning job %s submitted earlier with the same idempotency key", jobID)
+			return j, nil
+		}
+		log.Ctx(ctx).Warn().Msgf("job %s submitted with idempotency key could not be loaded, submitting again: %s", jobID, err)
+	}
+
+	j, err := node.submitJob(ctx, data)
+	if err != nil {
+		return j, err
+	}
+	node.idempotencyKeys.add(data.ClientID, data.IdempotencyKey, j.ID)
+	return j, nil
+}
+
+func (node *RequesterNode) submitJob(ctx context.Context, data model.JobCreatePayload) (*model.Job, error) {
 	jobUUID, err := uuid.NewRandom()
 	if err != nil {
 		return &model.Job{}, fmt.Errorf("error creating job id: %w", err)
@@ -107,10 +156,20 @@ func (node *RequesterNode) SubmitJob(ctx context.Context, data model.JobCreatePa
 	ev.Deal = data.Job.Deal
 	ev.JobExecutionPlan = executionPlan
 
+	// resources are compared against the limits of nodes as numbers, so they
+	// are stored in one unit whatever unit they were asked for in
+	ev.Spec.Resources, err = capacity.NormalizeResourceUsageConfig(ev.Spec.Resources)
+	if err != nil {
+		return &model.Job{}, bacerrors.NewSpecInvalid(fmt.Errorf("invalid job resources: %w", err))
+	}
+
 	// set a default timeout value if one is not passed or below an acceptable value
 	if ev.Spec.GetTimeout() <= node.config.TimeoutConfig.MinJobExecutionTimeout {
 		ev.Spec.Timeout = node.config.TimeoutConfig.DefaultJobExecutionTimeout.Seconds()
 	}
+	if _, ok := node.challengeableVerifier(ctx, ev.Spec.Verifier); ok && ev.Spec.ChallengePeriod <= 0 {
+		ev.Spec.ChallengePeriod = node.config.DefaultChallengePeriod.Seconds()
+	}
 
 	job := jobutils.ConstructJobFromEvent(ev)
 	err = node.localDB.AddJob(ctx, job)
@@ -118,14 +177,127 @@ func (node *RequesterNode) SubmitJob(ctx context.Context, data model.JobCreatePa
 		return &model.Job{}, fmt.Errorf("error saving job id: %w", err)
 	}
 
+	if data.IdempotencyKey != "" {
+		node.idempotencyKeys.expectCreated(jobID, data.IdempotencyKey)
+	}
+
+	if node.config.ApprovalRequired {
+		log.Ctx(ctx).Info().Msgf("holding job %s until it is approved", jobID)
+		node.approvals.hold(ev)
+		return job, nil
+	}
+
+	if err = node.announceJob(jobCtx, job, ev); err != nil {
+		return &model.Job{}, err
+	}
+	return job, nil
+}
+
+// recordIdempotencyKey records the idempotency key the job was submitted
+// with, if any, once the job is created in the local db.
+func (node *RequesterNode) recordIdempotencyKey(ctx context.Context, jobID string) error {
+	key, ok := node.idempotencyKeys.created(jobID)
+	if !ok {
+		return nil
+	}
+	return node.localEventConsumer.HandleLocalEvent(ctx, model.JobLocalEvent{
+		EventName:      model.JobLocalEventIdempotencyKey,
+		JobID:          jobID,
+		IdempotencyKey: key,
+	})
+}
+
+// announceJob starts tracking the shards of a job and announces it to the
+// network, for compute nodes to bid on.
+func (node *RequesterNode) announceJob(ctx context.Context, job *model.Job, ev model.JobEvent) error {
 	node.shardStateManager.startShardsState(ctx, job, node)
 
-	err = node.jobEventPublisher.HandleJobEvent(jobCtx, ev)
+	if err := node.jobEventPublisher.HandleJobEvent(ctx, ev); err != nil {
+		return fmt.Errorf("error handling new job event: %s", err)
+	}
+	return nil
+}
+
+// ApproveJob announces a job held for approval to the network, or rejects
+// it so that it never runs. Only the approvers of the requester node can
+// decide on the jobs it holds.
+func (node *RequesterNode) ApproveJob(ctx context.Context, approverID, jobID string, approved bool, reason string) error {
+	if !node.config.ApprovalRequired {
+		return fmt.Errorf("requester node %s does not hold jobs for approval", node.ID)
+	}
+	if !node.approvals.isApprover(approverID) {
+		return fmt.Errorf("client %s is not an approver of requester node %s", approverID, node.ID)
+	}
+
+	ev, err := node.approvals.decide(jobID, approved, reason)
 	if err != nil {
-		return &model.Job{}, fmt.Errorf("error handling new job event: %s", err)
+		return err
+	}
+	if !approved {
+		log.Ctx(ctx).Info().Msgf("job %s rejected by %s: %s", jobID, approverID, reason)
+		return node.failHeldJob(ctx, jobID, fmt.Sprintf("job rejected by the approver: %s", reason))
 	}
 
-	return job, nil
+	j, err := node.localDB.GetJob(ctx, jobID)
+	if err != nil {
+		return err
+	}
+	log.Ctx(ctx).Info().Msgf("job %s approved by %s", jobID, approverID)
+	jobCtx, span := node.newRootSpanForJob(ctx, jobID)
+	defer span.End()
+	return node.announceJob(jobCtx, j, ev)
+}
+
+// failHeldJob records the shards of a job that was held for approval, and so
+// never announced, as failed, for those waiting for the job to see it end.
+func (node *RequesterNode) failHeldJob(ctx context.Context, jobID, reason string) error {
+	j, err := node.localDB.GetJob(ctx, jobID)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < j.ExecutionPlan.TotalShards; i++ {
+		if err = node.notifyShardError(ctx, model.JobShard{Job: j, Index: i}, reason); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PendingApprovals returns the IDs of the jobs held until they are approved.
+func (node *RequesterNode) PendingApprovals() []string {
+	return node.approvals.list()
+}
+
+// Moderate flags a client or a job as abusive, adding it to the denylist
+// that compute nodes can refuse to bid on the jobs of, or unflags it. Only
+// the moderators of the requester node can change its denylist.
+func (node *RequesterNode) Moderate(ctx context.Context, data model.ModerationPayload) error {
+	if !node.moderation.isModerator(data.ClientID) {
+		return bacerrors.NewForbidden(
+			fmt.Errorf("client %s is not a moderator of requester node %s", data.ClientID, node.ID))
+	}
+	if (data.TargetClientID == "") == (data.TargetJobID == "") {
+		return fmt.Errorf("exactly one of a client or a job must be flagged")
+	}
+	if err := node.moderation.flag(data); err != nil {
+		return err
+	}
+	target := "client " + data.TargetClientID
+	if data.TargetJobID != "" {
+		target = "job " + data.TargetJobID
+	}
+	if data.Unflag {
+		log.Ctx(ctx).Info().Msgf("%s unflagged by %s", target, data.ClientID)
+	} else {
+		log.Ctx(ctx).Info().Msgf("%s flagged by %s: %s", target, data.ClientID, data.Reason)
+	}
+	return nil
+}
+
+// Denylist returns the clients and jobs flagged as abusive by the moderators
+// of the requester node.
+func (node *RequesterNode) Denylist() model.Denylist {
+	return node.moderation.denylist()
 }
 
 func (node *RequesterNode) UpdateDeal(ctx context.Context, jobID string, deal model.Deal) error {
@@ -134,6 +306,29 @@ func (node *RequesterNode) UpdateDeal(ctx context.Context, jobID string, deal mo
 	return node.jobEventPublisher.HandleJobEvent(ctx, ev)
 }
 
+// CancelJob fails the shards of a job owned by this requester node that
+// have not completed yet, which makes the compute nodes running them stop.
+func (node *RequesterNode) CancelJob(ctx context.Context, jobID, reason string) error {
+	j, err := node.localDB.GetJob(ctx, jobID)
+	if err != nil {
+		return err
+	}
+	if j.RequesterNodeID != node.ID {
+		return fmt.Errorf("job %s is owned by requester node %s, not this one", jobID, j.RequesterNodeID)
+	}
+
+	if reason == "" {
+		reason = "no reason given"
+	}
+	reason = fmt.Sprintf("job cancelled by the client: %s", reason)
+	// a job that is cancelled before it is approved never runs
+	if _, err = node.approvals.decide(jobID, false, reason); err == nil {
+		return node.failHeldJob(ctx, jobID, reason)
+	}
+	node.shardStateManager.failJob(ctx, j, reason)
+	return nil
+}
+
 // Return list of active jobs in this requester node.
 func (node *RequesterNode) GetActiveJobs(ctx context.Context) []ActiveJob {
 	activeJobs := make([]ActiveJob, 0)
@@ -159,7 +354,7 @@ func (node *RequesterNode) triggerStateTransition(ctx context.Context, event mod
 	if shardState, ok := node.shardStateManager.GetShardState(shard); ok {
 		switch event.EventName {
 		case model.JobEventBid:
-			shardState.bid(ctx, event.SourceNodeID)
+			shardState.bid(ctx, event.SourceNodeID, event.SourceNodeZone, event.SourceNodeEnergy, event.SourceNodeHardening)
 		case model.JobEventResultsProposed:
 			shardState.verifyResult(ctx, event.SourceNodeID)
 		case model.JobEventResultsPublished:
@@ -204,6 +399,8 @@ func (node *RequesterNode) verifyShard(
 	if err != nil {
 		return nil, err
 	}
+	// flag the nodes that dissented from the quorum, if there was one
+	node.reputations.record(verificationResults)
 
 	// we don't fail on first error from the bid queue to avoid a poison pill blocking any progress
 	var firstError error
@@ -226,11 +423,91 @@ func (node *RequesterNode) verifyShard(
 		return nil, err
 	}
 
+	if _, ok := jobVerifier.(verifier.ChallengeableVerifier); ok {
+		// the results were accepted provisionally, and can be challenged
+		// until the end of the challenge period of the job
+		until := time.Now().Add(shard.Job.Spec.GetChallengePeriod())
+		for _, verifiedResult := range verifiedResults {
+			node.challenges.provision(model.JobChallenge{
+				JobID:      shard.Job.ID,
+				ShardIndex: shard.Index,
+				NodeID:     verifiedResult.NodeID,
+			}, until)
+		}
+	}
+	if shard.Job.Spec.Challenge.JobID != "" {
+		if err = node.resolveChallenge(ctx, shard, verifiedResults); err != nil {
+			log.Ctx(ctx).Warn().Err(err).Msgf("failed to decide the challenge re-executed by shard %s", shard)
+		}
+	}
+
 	return verifiedResults, nil
 }
 
-// send a job event to notify the compute node that the bid has been accepted or rejected
-func (node *RequesterNode) notifyBidDecision(ctx context.Context, shard model.JobShard, targetNodeID string, accepted bool) error {
+// send a job event to notify the compute node that the bid has been accepted or rejected, and why
+// verifiesPublishedResults returns true if the verifier of the job also
+// verifies the results of its shards once they are published.
+func (node *RequesterNode) verifiesPublishedResults(ctx context.Context, shard model.JobShard) bool {
+	jobVerifier, err := node.verifiers.GetVerifier(ctx, shard.Job.Spec.Verifier)
+	if err != nil {
+		return false
+	}
+	_, ok := jobVerifier.(verifier.PublishedResultsVerifier)
+	return ok
+}
+
+// verifyPublishedResults has the verifier of the job verify the results
+// the nodes published for a shard, and returns the ones it verified.
+func (node *RequesterNode) verifyPublishedResults(
+	ctx context.Context,
+	shard model.JobShard,
+	nodeIDs []string,
+) ([]verifier.VerifierResult, error) {
+	jobVerifier, err := node.verifiers.GetVerifier(ctx, shard.Job.Spec.Verifier)
+	if err != nil {
+		return nil, err
+	}
+	publishedResultsVerifier, ok := jobVerifier.(verifier.PublishedResultsVerifier)
+	if !ok {
+		return nil, fmt.Errorf("verifier %s does not verify published results", shard.Job.Spec.Verifier)
+	}
+
+	jobState, err := node.localDB.GetJobState(ctx, shard.Job.ID)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]verifier.PublishedResult, 0, len(nodeIDs))
+	for _, nodeID := range nodeIDs {
+		shardState, ok := jobState.Nodes[nodeID].Shards[shard.Index]
+		if !ok {
+			return nil, fmt.Errorf("node %s has no state for shard %s", nodeID, shard)
+		}
+		results = append(results, verifier.PublishedResult{
+			NodeID:               nodeID,
+			ShardIndex:           shard.Index,
+			PublishedResult:      shardState.PublishedResult,
+			VerificationProposal: shardState.VerificationProposal,
+		})
+	}
+
+	verificationResults, err := publishedResultsVerifier.VerifyPublishedResults(ctx, shard, results)
+	if err != nil {
+		return nil, err
+	}
+	var verifiedResults []verifier.VerifierResult
+	for _, verificationResult := range verificationResults {
+		if verificationResult.Verified {
+			verifiedResults = append(verifiedResults, verificationResult)
+		} else {
+			log.Ctx(ctx).Warn().Msgf("published results of node %s for shard %s failed verification: %s",
+				verificationResult.NodeID, shard, verificationResult.Reason)
+		}
+	}
+	return verifiedResults, nil
+}
+
+func (node *RequesterNode) notifyBidDecision(
+	ctx context.Context, shard model.JobShard, targetNodeID string, accepted bool, reason string) error {
 	jobEventName := model.JobEventBidAccepted
 	localEventName := model.JobLocalEventBidAccepted
 	if !accepted {
@@ -255,9 +532,16 @@ func (node *RequesterNode) notifyBidDecision(ctx context.Context, shard model.Jo
 	// function and so knows which node it is accepting/rejecting the bid for
 	jobEvent := node.constructShardEvent(shard, jobEventName)
 	jobEvent.TargetNodeID = targetNodeID
+	jobEvent.Status = reason
 	return node.jobEventPublisher.HandleJobEvent(ctx, jobEvent)
 }
 
+// GetDebugInfo returns the reputation of the nodes whose results the
+// requester verified.
+func (node *RequesterNode) GetDebugInfo() (model.DebugInfo, error) {
+	return node.reputations.GetDebugInfo()
+}
+
 // send a job event to notify the compute node that the verification has been completed
 func (node *RequesterNode) notifyVerificationResult(ctx context.Context, result verifier.VerifierResult) error {
 	jobEventName := model.JobEventResultsAccepted
@@ -271,6 +555,7 @@ func (node *RequesterNode) notifyVerificationResult(ctx context.Context, result
 	jobEvent := node.constructJobEvent(result.JobID, jobEventName)
 	jobEvent.TargetNodeID = result.NodeID
 	jobEvent.ShardIndex = result.ShardIndex
+	jobEvent.Status = result.Reason
 	jobEvent.VerificationResult = model.VerificationResult{
 		Complete: true,
 		Result:   result.Verified,
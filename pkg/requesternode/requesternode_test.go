@@ -0,0 +1,89 @@
+//go:build unit || !integration
+
+package requesternode
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/bacalhau/pkg/bacerrors"
+	"github.com/filecoin-project/bacalhau/pkg/eventhandler"
+	"github.com/filecoin-project/bacalhau/pkg/localdb"
+	"github.com/filecoin-project/bacalhau/pkg/localdb/inmemory"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/system"
+	"github.com/filecoin-project/bacalhau/pkg/verifier"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubmitJobNormalizesResources(t *testing.T) {
+	r := newTestRequester(t, RequesterNodeConfig{}, &testVerifier{})
+	submit := func(resources model.ResourceUsageConfig) (*model.Job, error) {
+		return r.SubmitJob(context.Background(), model.JobCreatePayload{
+			ClientID: "client-id",
+			Job: &model.Job{
+				Spec: model.Spec{Verifier: model.VerifierDeterministic, Resources: resources},
+				Deal: model.Deal{Concurrency: 1},
+			},
+		})
+	}
+
+	j, err := submit(model.ResourceUsageConfig{CPU: "0.5", Memory: "2Gi", Disk: "100Mb", GPU: "1"})
+	require.NoError(t, err)
+	require.Equal(t, model.ResourceUsageConfig{CPU: "500m", Memory: "2147483648", Disk: "104857600", GPU: "1"},
+		j.Spec.Resources)
+
+	_, err = submit(model.ResourceUsageConfig{Memory: "2Gx"})
+	require.IsType(t, &bacerrors.SpecInvalid{}, err)
+}
+
+func TestSubmitJobIdempotencyKeysSurviveRestarts(t *testing.T) {
+	ctx := context.Background()
+	localDB, err := inmemory.NewInMemoryDatastore()
+	require.NoError(t, err)
+	localDBEventHandler := localdb.NewLocalDBEventHandler(localDB)
+
+	// each requester stands for the node after a restart, with the local db
+	// rebuilt from the event log
+	newRequester := func() *RequesterNode {
+		cm := system.NewCleanupManager()
+		t.Cleanup(cm.Cleanup)
+		var node *RequesterNode
+		node, err = NewRequesterNode(
+			ctx,
+			cm,
+			"requester-node-id",
+			localDB,
+			localDBEventHandler,
+			eventhandler.JobEventHandlerFunc(func(ctx context.Context, event model.JobEvent) error {
+				if err := localDBEventHandler.HandleJobEvent(ctx, event); err != nil {
+					return err
+				}
+				return node.HandleJobEvent(ctx, event)
+			}),
+			verifier.NewMappedVerifierProvider(map[model.Verifier]verifier.Verifier{
+				model.VerifierDeterministic: &testVerifier{},
+			}),
+			nil,
+			RequesterNodeConfig{},
+		)
+		require.NoError(t, err)
+		return node
+	}
+	submit := func(r *RequesterNode, clientID string) *model.Job {
+		j, err := r.SubmitJob(ctx, model.JobCreatePayload{
+			ClientID: clientID,
+			Job: &model.Job{
+				Spec: model.Spec{Verifier: model.VerifierDeterministic},
+				Deal: model.Deal{Concurrency: 1},
+			},
+			IdempotencyKey: "idempotency-key",
+		})
+		require.NoError(t, err)
+		return j
+	}
+
+	j := submit(newRequester(), "client-id")
+	require.Equal(t, j.ID, submit(newRequester(), "client-id").ID)
+	require.NotEqual(t, j.ID, submit(newRequester(), "other-client-id").ID)
+}
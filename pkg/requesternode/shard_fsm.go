@@ -4,10 +4,12 @@ import (
 	"context"
 	"fmt"
 	"math/rand"
+	"sort"
 	"time"
 
 	"golang.org/x/exp/maps"
 
+	jobutils "github.com/filecoin-project/bacalhau/pkg/job"
 	"github.com/filecoin-project/bacalhau/pkg/model"
 	"github.com/filecoin-project/bacalhau/pkg/system"
 	sync "github.com/lukemarsden/golang-mutex-tracer"
@@ -45,6 +47,9 @@ type shardStateRequest struct {
 	action       shardStateAction
 	sourceNodeID string // optional field indicating the node that triggered the request
 	reason       string
+	zone         string               // the zone of the node that bid, if it said
+	energy       *model.NodeEnergy    // the energy of the node that bid, if it said
+	hardening    *model.NodeHardening // the hardening of the node that bid, if it said
 }
 
 // types of shard state machines
@@ -71,6 +76,9 @@ const (
 	// Verifier has verified the results, and now the shard is publishing the results to the requester.
 	shardWaitingToPublishResults
 
+	// All the accepted results were published, and now the verifier is verifying where they were published.
+	shardVerifyingPublishedResults
+
 	// The job has failed due to an error.
 	shardError
 
@@ -81,7 +89,7 @@ const (
 func (s shardStateType) String() string {
 	return [...]string{
 		"InitialState", "EnqueuingBids", "SelectingBids", "AcceptingBids", "WaitingForResults",
-		"VerifyingResults", "WaitingToPublishResults", "Error", "Completed"}[s]
+		"VerifyingResults", "WaitingToPublishResults", "VerifyingPublishedResults", "Error", "Completed"}[s]
 }
 
 type shardStateMachineManager struct {
@@ -90,7 +98,12 @@ type shardStateMachineManager struct {
 	shardStates map[string]*shardStateMachine
 	// configure the timeout for each shard state
 	timeoutConfig RequesterTimeoutConfig
-	mu            sync.Mutex
+	// how long to collect bids for before selecting from them
+	bidWindow time.Duration
+	// the number of shards each node has an accepted bid for that are not
+	// completed yet, used to rank bids
+	nodeLoads map[string]int
+	mu        sync.Mutex
 }
 
 func newShardStateMachineManager(
@@ -100,6 +113,8 @@ func newShardStateMachineManager(
 	stateManager := &shardStateMachineManager{
 		shardStates:   make(map[string]*shardStateMachine),
 		timeoutConfig: config.TimeoutConfig,
+		bidWindow:     config.BidWindow,
+		nodeLoads:     make(map[string]int),
 	}
 
 	stateManager.mu.EnableTracerWithOpts(sync.Opts{
@@ -156,10 +171,39 @@ func (m *shardStateMachineManager) backgroundTask() {
 	}
 
 	for _, item := range timeoutShardStates {
-		go item.fail(ctx, fmt.Sprintf("shard timed out while in state %s, see --timeout", item.currentState))
+		reason := fmt.Sprintf("shard timed out while in state %s, see --timeout", item.currentState)
+		if deadline := item.shard.Job.Deal.Deadline; !deadline.IsZero() && !now.Before(deadline) {
+			reason = fmt.Sprintf("shard missed its deadline of %s while in state %s, see --deadline",
+				deadline.Format(time.RFC3339), item.currentState)
+		}
+		// say why nodes declined to bid if none did
+		waitingForBids := item.currentState == shardEnqueuingBids ||
+			(item.currentState == shardAcceptingBids && len(item.biddingNodes) == 0)
+		go item.timeout(ctx, reason, waitingForBids)
 	}
 }
 
+// timeout fails the shard, adding why nodes declined to bid on it if it was
+// waiting for bids.
+func (m *shardStateMachine) timeout(ctx context.Context, reason string, waitingForBids bool) {
+	if waitingForBids {
+		events, err := m.node.localDB.GetJobEvents(ctx, m.shard.Job.ID)
+		if err != nil {
+			log.Ctx(ctx).Debug().Err(err).Msgf("could not get the events of shard %s", m.shard)
+		}
+		var shardEvents []model.JobEvent
+		for _, event := range events {
+			if event.ShardIndex == m.shard.Index {
+				shardEvents = append(shardEvents, event)
+			}
+		}
+		if declines := jobutils.SummarizeBidDeclines(jobutils.GetBidDeclines(shardEvents)); declines != "" {
+			reason += "; " + declines
+		}
+	}
+	m.fail(ctx, reason)
+}
+
 // Start a state machine for all the shards in the job, if they don't exit already
 func (m *shardStateMachineManager) startShardsState(
 	ctx context.Context, job *model.Job, n *RequesterNode) {
@@ -180,6 +224,19 @@ func (m *shardStateMachineManager) startShardsState(
 	}
 }
 
+// failJob fails the shards of the job that have not completed yet.
+func (m *shardStateMachineManager) failJob(ctx context.Context, job *model.Job, reason string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := 0; i < job.ExecutionPlan.TotalShards; i++ {
+		shard := model.JobShard{Job: job, Index: i}
+		if shardState, ok := m.shardStates[shard.ID()]; ok && shardState.currentState != shardCompleted {
+			go shardState.fail(ctx, reason)
+		}
+	}
+}
+
 func (m *shardStateMachineManager) GetShardState(shard model.JobShard) (*shardStateMachine, bool) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -205,10 +262,31 @@ type shardStateMachine struct {
 	// keep track of nodes that have already submitted their result proposals to deduplicate results, and know when
 	// result verification should start.
 	completedNodes map[string]struct{}
+
+	// nodes counted in the manager's nodeLoads for this shard.
+	assignedNodes map[string]struct{}
+
+	// nodes that were assigned the shard, including after they have completed
+	// it, for the shard anti-affinity of the job. Guarded by the manager's mu.
+	placedNodes map[string]struct{}
+
+	// the zones of the nodes that bid on the shard, for those that said.
+	nodeZones map[string]string
+
+	// the energy of the nodes that bid on the shard, for those that said.
+	nodeEnergy map[string]*model.NodeEnergy
+
+	// the hardening of the containers of the nodes that bid on the shard, for
+	// those that said.
+	nodeHardening map[string]*model.NodeHardening
+
+	// nodes whose results agreed with the quorum, and so are the only ones
+	// expected to publish them.
+	acceptedNodes map[string]struct{}
 }
 
 func (m *shardStateMachineManager) newShardStateMachine(ctx context.Context, shard model.JobShard, node *RequesterNode) *shardStateMachine {
-	return &shardStateMachine{
+	shardState := &shardStateMachine{
 		shard:          shard,
 		manager:        m,
 		node:           node,
@@ -216,8 +294,126 @@ func (m *shardStateMachineManager) newShardStateMachine(ctx context.Context, sha
 		currentState:   shardInitialState,
 		biddingNodes:   make(map[string]struct{}),
 		completedNodes: make(map[string]struct{}),
-		timeoutAt:      time.Now().Add(m.timeoutConfig.JobNegotiationTimeout),
+		assignedNodes:  make(map[string]struct{}),
+		placedNodes:    make(map[string]struct{}),
+		nodeZones:      make(map[string]string),
+		nodeEnergy:     make(map[string]*model.NodeEnergy),
+		nodeHardening:  make(map[string]*model.NodeHardening),
+		acceptedNodes:  make(map[string]struct{}),
+	}
+	shardState.timeoutAt = shardState.timeoutAfter(m.timeoutConfig.JobNegotiationTimeout)
+	return shardState
+}
+
+// timeoutAfter returns when the shard times out if it is left waiting for
+// d, which is no later than the deadline of the job.
+func (m *shardStateMachine) timeoutAfter(d time.Duration) time.Time {
+	timeoutAt := time.Now().Add(d)
+	if deadline := m.shard.Job.Deal.Deadline; !deadline.IsZero() && deadline.Before(timeoutAt) {
+		return deadline
+	}
+	return timeoutAt
+}
+
+// assign records that a node's bid for the shard is accepted, so that the
+// node ranks lower for other shards while it works on this one. It returns
+// why the node can't be assigned the shard instead if it already runs
+// another shard of a job with shard anti-affinity.
+func (m *shardStateMachine) assign(nodeID string) string {
+	m.manager.mu.Lock()
+	defer m.manager.mu.Unlock()
+	if m.shard.Job.Deal.ShardAntiAffinity {
+		if index, ok := m.manager.placedShardLocked(m.shard, nodeID); ok {
+			return fmt.Sprintf("the node runs shard %d of the job, and its shards must run on different nodes", index)
+		}
+	}
+	m.placedNodes[nodeID] = struct{}{}
+	if _, ok := m.assignedNodes[nodeID]; !ok {
+		m.assignedNodes[nodeID] = struct{}{}
+		m.manager.nodeLoads[nodeID]++
+	}
+	return ""
+}
+
+// release undoes assign, once the node is no longer working on the shard
+// because its bid fell through or it failed to run it.
+func (m *shardStateMachine) release(nodeID string) {
+	m.manager.mu.Lock()
+	defer m.manager.mu.Unlock()
+	delete(m.placedNodes, nodeID)
+	m.releaseLocked(nodeID)
+}
+
+func (m *shardStateMachine) releaseAll() {
+	m.manager.mu.Lock()
+	defer m.manager.mu.Unlock()
+	for nodeID := range m.assignedNodes {
+		m.releaseLocked(nodeID)
+	}
+}
+
+func (m *shardStateMachine) releaseLocked(nodeID string) {
+	if _, ok := m.assignedNodes[nodeID]; !ok {
+		return
+	}
+	delete(m.assignedNodes, nodeID)
+	m.manager.nodeLoads[nodeID]--
+	if m.manager.nodeLoads[nodeID] <= 0 {
+		delete(m.manager.nodeLoads, nodeID)
+	}
+}
+
+type rankedBid struct {
+	nodeID string
+	// the number of other shards the node is working on for this requester
+	load int
+	// the number of times the node's results dissented from the quorum
+	dissents int
+	// what the node said about the energy it runs on
+	energy *model.NodeEnergy
+}
+
+// rankBids orders the bids received for the shard from best to worst. Nodes
+// whose results dissented from the quorum less often rank first, then, if
+// the job prefers green nodes, nodes running on greener energy, then nodes
+// working on fewer shards for this requester, to spread jobs across the
+// network, and ties are broken at random.
+//
+// Bids used to be selected in a random order. They are ranked so that a
+// node that keeps bidding first doesn't get every job while others idle,
+// which means that selection depends on the load of the nodes, as seen by
+// this requester, rather than on chance.
+func (m *shardStateMachine) rankBids() []rankedBid {
+	candidates := maps.Keys(m.biddingNodes)
+	rand.Shuffle(len(candidates), func(i, j int) {
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	})
+
+	m.manager.mu.Lock()
+	ranked := make([]rankedBid, 0, len(candidates))
+	for _, nodeID := range candidates {
+		ranked = append(ranked, rankedBid{nodeID: nodeID, load: m.manager.nodeLoads[nodeID], energy: m.nodeEnergy[nodeID]})
+	}
+	m.manager.mu.Unlock()
+	for i := range ranked {
+		ranked[i].dissents = m.node.reputations.dissents(ranked[i].nodeID)
 	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].dissents != ranked[j].dissents {
+			return ranked[i].dissents < ranked[j].dissents
+		}
+		if m.shard.Job.Deal.PreferGreenNodes {
+			if ranked[i].energy.Greener(ranked[j].energy) {
+				return true
+			}
+			if ranked[j].energy.Greener(ranked[i].energy) {
+				return false
+			}
+		}
+		return ranked[i].load < ranked[j].load
+	})
+	return ranked
 }
 
 func (m *shardStateMachine) String() string {
@@ -235,8 +431,15 @@ func (m *shardStateMachine) run(ctx context.Context) {
 	close(m.req)
 }
 
-func (m *shardStateMachine) bid(ctx context.Context, sourceNodeID string) {
-	m.sendRequest(ctx, shardStateRequest{action: actionBidReceived, sourceNodeID: sourceNodeID})
+func (m *shardStateMachine) bid(
+	ctx context.Context, sourceNodeID, zone string, energy *model.NodeEnergy, hardening *model.NodeHardening) {
+	m.sendRequest(ctx, shardStateRequest{
+		action:       actionBidReceived,
+		sourceNodeID: sourceNodeID,
+		zone:         zone,
+		energy:       energy,
+		hardening:    hardening,
+	})
 }
 
 func (m *shardStateMachine) computeError(ctx context.Context, sourceNodeID string) {
@@ -295,19 +498,44 @@ func (m *shardStateMachine) transitionedTo(ctx context.Context, newState shardSt
 	m.currentState = newState
 }
 
-// Shard is enqueuing bids waiting Min bids before start accepting/rejecting bids.
+// Shard is enqueuing bids waiting Min bids, and for the bid window to pass, before start accepting/rejecting bids.
 func enqueuedState(ctx context.Context, m *shardStateMachine) stateFn {
 	m.transitionedTo(ctx, shardEnqueuingBids)
 
+	// keep collecting bids until the window has passed, so that the best of
+	// them are selected rather than the first
+	var bidWindowPassed <-chan time.Time
+	if m.manager.bidWindow > 0 {
+		bidWindowTimer := time.NewTimer(m.manager.bidWindow)
+		defer bidWindowTimer.Stop()
+		bidWindowPassed = bidWindowTimer.C
+	}
+	enoughBids := func() bool {
+		return bidWindowPassed == nil && len(m.biddingNodes) > 0 && len(m.biddingNodes) >= m.shard.Job.Deal.MinBids
+	}
+
 	for {
-		req := <-m.req
+		var req shardStateRequest
+		select {
+		case <-bidWindowPassed:
+			bidWindowPassed = nil
+			if enoughBids() {
+				return selectingBidsState
+			}
+			continue
+		case req = <-m.req:
+		}
+
 		switch req.action {
 		case actionBidReceived:
 			if _, ok := m.biddingNodes[req.sourceNodeID]; !ok {
 				m.biddingNodes[req.sourceNodeID] = struct{}{}
+				m.nodeZones[req.sourceNodeID] = req.zone
+				m.nodeEnergy[req.sourceNodeID] = req.energy
+				m.nodeHardening[req.sourceNodeID] = req.hardening
 
 				// we have received enough bids to start the selection process.
-				if len(m.biddingNodes) >= m.shard.Job.Deal.MinBids {
+				if enoughBids() {
 					return selectingBidsState
 				}
 			} else {
@@ -334,28 +562,42 @@ func enqueuedState(ctx context.Context, m *shardStateMachine) stateFn {
 func selectingBidsState(ctx context.Context, m *shardStateMachine) stateFn {
 	m.transitionedTo(ctx, shardSelectingBids)
 
-	// randomize the candidateBids slice before returning it
-	candidateBids := maps.Keys(m.biddingNodes)
-	rand.Shuffle(len(candidateBids), func(i, j int) {
-		candidateBids[i], candidateBids[j] = candidateBids[j], candidateBids[i]
-	})
+	candidateBids := m.rankBids()
 
 	// to hold the bids that were selected and successfully notified.
 	acceptedBids := make(map[string]struct{})
 
-	for _, candidate := range candidateBids {
+	for i, candidate := range candidateBids {
+		// explain the ranking in the bid decision, so users can see why a node got the job
+		reason := fmt.Sprintf("ranked %d of %d bids, the node has %d other shards in progress and dissented "+
+			"from the quorum %d times (fewest dissents then fewest shards first, ties broken at random)",
+			i+1, len(candidateBids), candidate.load, candidate.dissents)
+		if m.shard.Job.Deal.PreferGreenNodes {
+			reason = fmt.Sprintf("ranked %d of %d bids, the node has %d other shards in progress, dissented "+
+				"from the quorum %d times and runs on %s (fewest dissents, then greenest, then fewest shards first, "+
+				"ties broken at random)", i+1, len(candidateBids), candidate.load, candidate.dissents, candidate.energy)
+		}
 		if len(acceptedBids) < m.shard.Job.Deal.Concurrency {
-			err := m.node.notifyBidDecision(ctx, m.shard, candidate, true)
+			if conflict := m.place(candidate.nodeID, acceptedBids); conflict != "" {
+				err := m.node.notifyBidDecision(ctx, m.shard, candidate.nodeID, false, conflict)
+				if err != nil {
+					log.Ctx(ctx).Warn().Err(err).Msgf("%s failed to notify bid rejection to %s", m, candidate.nodeID)
+				}
+				continue
+			}
+			err := m.node.notifyBidDecision(ctx, m.shard, candidate.nodeID, true, reason)
 			if err != nil {
-				log.Ctx(ctx).Error().Err(err).Msgf("%s failed to notify bid acceptance to %s", m, candidate)
+				log.Ctx(ctx).Error().Err(err).Msgf("%s failed to notify bid acceptance to %s", m, candidate.nodeID)
+				m.release(candidate.nodeID)
 				continue
 			} else {
-				acceptedBids[candidate] = struct{}{}
+				acceptedBids[candidate.nodeID] = struct{}{}
 			}
 		} else {
-			err := m.node.notifyBidDecision(ctx, m.shard, candidate, false)
+			reason = fmt.Sprintf("%s, and the job's concurrency of %d was reached", reason, m.shard.Job.Deal.Concurrency)
+			err := m.node.notifyBidDecision(ctx, m.shard, candidate.nodeID, false, reason)
 			if err != nil {
-				log.Ctx(ctx).Warn().Err(err).Msgf("%s failed to notify bid rejection to %s", m, candidate)
+				log.Ctx(ctx).Warn().Err(err).Msgf("%s failed to notify bid rejection to %s", m, candidate.nodeID)
 			}
 		}
 	}
@@ -380,9 +622,21 @@ func acceptingBidsState(ctx context.Context, m *shardStateMachine) stateFn {
 		switch req.action {
 		case actionBidReceived:
 			if _, ok := m.biddingNodes[req.sourceNodeID]; !ok {
-				err := m.node.notifyBidDecision(ctx, m.shard, req.sourceNodeID, true)
+				m.nodeZones[req.sourceNodeID] = req.zone
+				m.nodeEnergy[req.sourceNodeID] = req.energy
+				m.nodeHardening[req.sourceNodeID] = req.hardening
+				if conflict := m.place(req.sourceNodeID, m.biddingNodes); conflict != "" {
+					err := m.node.notifyBidDecision(ctx, m.shard, req.sourceNodeID, false, conflict)
+					if err != nil {
+						log.Ctx(ctx).Warn().Msgf("%s failed to notify bid rejection: %s", m, err)
+					}
+					continue
+				}
+				err := m.node.notifyBidDecision(ctx, m.shard, req.sourceNodeID, true,
+					"accepted on arrival, as the shard needed more nodes")
 				if err != nil {
 					log.Ctx(ctx).Error().Msgf("%s failed to notify bid acceptance. Will wait for more bids: %s", m, err)
+					m.release(req.sourceNodeID)
 				} else {
 					// add the bid to the list of accepted bids.
 					m.biddingNodes[req.sourceNodeID] = struct{}{}
@@ -400,6 +654,7 @@ func acceptingBidsState(ctx context.Context, m *shardStateMachine) stateFn {
 				delete(m.biddingNodes, req.sourceNodeID)
 				// also delete the result from the results map, if any.
 				delete(m.completedNodes, req.sourceNodeID)
+				m.release(req.sourceNodeID)
 			} else {
 				m.notifyInvalidRequest(ctx, req, fmt.Sprintf(
 					"Received %s from node %s that has not bid on this shard", req.action, req.sourceNodeID))
@@ -422,14 +677,15 @@ func acceptingBidsState(ctx context.Context, m *shardStateMachine) stateFn {
 // Shard is waiting for the results from the selected nodes, and reject any more incoming bids.
 func waitingForResultsState(ctx context.Context, m *shardStateMachine) stateFn {
 	m.transitionedTo(ctx, shardWaitingForResults)
-	m.timeoutAt = time.Now().Add(m.shard.Job.Spec.GetTimeout())
+	m.timeoutAt = m.timeoutAfter(m.shard.Job.Spec.GetTimeout())
 
 	for {
 		req := <-m.req
 		switch req.action {
 		case actionBidReceived:
 			// reject all bids at this state
-			err := m.node.notifyBidDecision(ctx, m.shard, req.sourceNodeID, false)
+			err := m.node.notifyBidDecision(ctx, m.shard, req.sourceNodeID, false,
+				"the shard already has enough nodes")
 			if err != nil {
 				log.Ctx(ctx).Warn().Msgf("%s failed to notify bid rejection: %s", m, err)
 			}
@@ -439,6 +695,7 @@ func waitingForResultsState(ctx context.Context, m *shardStateMachine) stateFn {
 				delete(m.biddingNodes, req.sourceNodeID)
 				// also delete the result from the results map, if any.
 				delete(m.completedNodes, req.sourceNodeID)
+				m.release(req.sourceNodeID)
 			} else {
 				m.notifyInvalidRequest(ctx, req, fmt.Sprintf(
 					"Received %s from node %s that has not bid on this shard", req.action, req.sourceNodeID))
@@ -475,6 +732,9 @@ func verifyingResultsState(ctx context.Context, m *shardStateMachine) stateFn {
 		return errorState
 	}
 
+	for _, result := range verifiedResults {
+		m.acceptedNodes[result.NodeID] = struct{}{}
+	}
 	if len(verifiedResults) > 0 {
 		return waitingToPublishResultsState
 	}
@@ -485,13 +745,26 @@ func verifyingResultsState(ctx context.Context, m *shardStateMachine) stateFn {
 func waitingToPublishResultsState(ctx context.Context, m *shardStateMachine) stateFn {
 	m.transitionedTo(ctx, shardWaitingToPublishResults)
 
+	verifiesPublishedResults := m.node.verifiesPublishedResults(ctx, m.shard)
+	publishedNodes := map[string]struct{}{}
 	for {
 		req := <-m.req
 		switch req.action {
 		case actionResultsPublished:
-			// TODO: #831 verify that the published results are the same as the ones we expect, or let the verifier
-			//  publish the result and not all the compute nodes.
-			return completedState
+			// only the nodes that agreed with the quorum publish their results
+			if _, ok := m.acceptedNodes[req.sourceNodeID]; !ok {
+				m.notifyInvalidRequest(ctx, req, "results published by a node whose results were not accepted")
+				continue
+			}
+			if !verifiesPublishedResults {
+				return completedState
+			}
+			// the verifier compares where all the accepted nodes published
+			// their results
+			publishedNodes[req.sourceNodeID] = struct{}{}
+			if len(publishedNodes) >= len(m.acceptedNodes) {
+				return verifyingPublishedResultsState
+			}
 		case actionFail:
 			m.errorMsg = req.reason
 			return errorState
@@ -501,6 +774,22 @@ func waitingToPublishResultsState(ctx context.Context, m *shardStateMachine) sta
 	}
 }
 
+// All the accepted results were published, and we are verifying where they were published.
+func verifyingPublishedResultsState(ctx context.Context, m *shardStateMachine) stateFn {
+	m.transitionedTo(ctx, shardVerifyingPublishedResults)
+
+	verifiedResults, err := m.node.verifyPublishedResults(ctx, m.shard, maps.Keys(m.acceptedNodes))
+	if err != nil {
+		m.errorMsg = fmt.Sprintf("failed to verify published results: %s", err)
+		return errorState
+	}
+	if len(verifiedResults) == 0 {
+		m.errorMsg = "none of the published results were verified"
+		return errorState
+	}
+	return completedState
+}
+
 func errorState(ctx context.Context, m *shardStateMachine) stateFn {
 	m.transitionedTo(ctx, shardError)
 	errMessage := fmt.Sprintf("%s error completing job due to %s", m, m.errorMsg)
@@ -527,6 +816,7 @@ func errorState(ctx context.Context, m *shardStateMachine) stateFn {
 // we always reach this state, whether the job completed successfully or due to a failure.
 func completedState(ctx context.Context, m *shardStateMachine) stateFn {
 	m.transitionedTo(ctx, shardCompleted)
+	m.releaseAll()
 	m.timeoutAt = time.Now().Add(stateEvictionTimeout)
 	return nil
 }
@@ -0,0 +1,385 @@
+//go:build unit || !integration
+
+package requesternode
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/bacalhau/pkg/eventhandler"
+	"github.com/filecoin-project/bacalhau/pkg/localdb/inmemory"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/system"
+	"github.com/filecoin-project/bacalhau/pkg/verifier"
+	"github.com/stretchr/testify/require"
+)
+
+// testVerifier verifies the results of the nodes in verified, and rejects
+// those of the others.
+type testVerifier struct {
+	verified map[string]bool
+}
+
+func (v *testVerifier) IsInstalled(context.Context) (bool, error) {
+	return true, nil
+}
+
+func (v *testVerifier) GetShardResultPath(context.Context, model.JobShard) (string, error) {
+	return "", nil
+}
+
+func (v *testVerifier) GetShardProposal(context.Context, model.JobShard, string) ([]byte, error) {
+	return nil, nil
+}
+
+func (v *testVerifier) IsExecutionComplete(context.Context, model.JobShard) (bool, error) {
+	return true, nil
+}
+
+func (v *testVerifier) VerifyShard(_ context.Context, shard model.JobShard) ([]verifier.VerifierResult, error) {
+	results := make([]verifier.VerifierResult, 0, len(v.verified))
+	for nodeID, verified := range v.verified {
+		results = append(results, verifier.VerifierResult{
+			JobID:      shard.Job.ID,
+			NodeID:     nodeID,
+			ShardIndex: shard.Index,
+			Verified:   verified,
+		})
+	}
+	return results, nil
+}
+
+// publishedResultsVerifier also verifies the published results of the nodes
+// in publishedVerified, and sends the published results it verifies to
+// published.
+type publishedResultsVerifier struct {
+	testVerifier
+	publishedVerified map[string]bool
+	published         chan []verifier.PublishedResult
+}
+
+func (v *publishedResultsVerifier) VerifyPublishedResults(
+	_ context.Context,
+	shard model.JobShard,
+	results []verifier.PublishedResult,
+) ([]verifier.VerifierResult, error) {
+	v.published <- results
+	verifierResults := make([]verifier.VerifierResult, 0, len(results))
+	for _, result := range results {
+		verifierResults = append(verifierResults, verifier.VerifierResult{
+			JobID:      shard.Job.ID,
+			NodeID:     result.NodeID,
+			ShardIndex: result.ShardIndex,
+			Verified:   v.publishedVerified[result.NodeID],
+		})
+	}
+	return verifierResults, nil
+}
+
+// testRequester is a requester node that sends the job events it publishes
+// to events.
+type testRequester struct {
+	*RequesterNode
+	events chan model.JobEvent
+}
+
+func newTestRequester(t *testing.T, config RequesterNodeConfig, jobVerifier verifier.Verifier) *testRequester {
+	localDB, err := inmemory.NewInMemoryDatastore()
+	require.NoError(t, err)
+	cm := system.NewCleanupManager()
+	t.Cleanup(cm.Cleanup)
+
+	events := make(chan model.JobEvent, 100)
+	node, err := NewRequesterNode(
+		context.Background(),
+		cm,
+		"requester-node-id",
+		localDB,
+		eventhandler.LocalEventHandlerFunc(func(context.Context, model.JobLocalEvent) error {
+			return nil
+		}),
+		eventhandler.JobEventHandlerFunc(func(_ context.Context, event model.JobEvent) error {
+			events <- event
+			return nil
+		}),
+		verifier.NewMappedVerifierProvider(map[model.Verifier]verifier.Verifier{
+			model.VerifierDeterministic: jobVerifier,
+		}),
+		nil,
+		config,
+	)
+	require.NoError(t, err)
+	return &testRequester{RequesterNode: node, events: events}
+}
+
+// startShard runs the state machine of the only shard of a job with the deal.
+func (r *testRequester) startShard(t *testing.T, deal model.Deal) *shardStateMachine {
+	j := &model.Job{
+		ID:            "job-id",
+		Spec:          model.Spec{Verifier: model.VerifierDeterministic},
+		Deal:          deal,
+		ExecutionPlan: model.JobExecutionPlan{TotalShards: 1},
+	}
+	r.shardStateManager.startShardsState(context.Background(), j, r.RequesterNode)
+	shardState, ok := r.shardStateManager.GetShardState(model.JobShard{Job: j})
+	require.True(t, ok)
+	return shardState
+}
+
+// nextEvent returns the next job event the requester published.
+func (r *testRequester) nextEvent(t *testing.T) model.JobEvent {
+	select {
+	case event := <-r.events:
+		return event
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "no job event was published")
+		return model.JobEvent{}
+	}
+}
+
+// requireNoEvent checks that the requester publishes no job event for d.
+func (r *testRequester) requireNoEvent(t *testing.T, d time.Duration) {
+	select {
+	case event := <-r.events:
+		require.FailNow(t, "unexpected job event", "%s: %s", event.EventName, event.Status)
+	case <-time.After(d):
+	}
+}
+
+// bidDecisions returns the next n bid decisions, by the node they are for.
+func (r *testRequester) bidDecisions(t *testing.T, n int) map[string]model.JobEvent {
+	decisions := map[string]model.JobEvent{}
+	for i := 0; i < n; i++ {
+		event := r.nextEvent(t)
+		require.Contains(t, []model.JobEventType{model.JobEventBidAccepted, model.JobEventBidRejected}, event.EventName)
+		decisions[event.TargetNodeID] = event
+	}
+	return decisions
+}
+
+// requireReleased checks that the state machine has released the nodes of
+// the shard, as it does when it completes.
+func (r *testRequester) requireReleased(t *testing.T) {
+	require.Eventually(t, func() bool {
+		r.shardStateManager.mu.Lock()
+		defer r.shardStateManager.mu.Unlock()
+		return len(r.shardStateManager.nodeLoads) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestRankBids(t *testing.T) {
+	r := newTestRequester(t, RequesterNodeConfig{}, &testVerifier{})
+	shard := model.JobShard{Job: &model.Job{ID: "job-id", Deal: model.Deal{Concurrency: 1}}}
+	shardState := r.shardStateManager.newShardStateMachine(context.Background(), shard, r.RequesterNode)
+
+	for _, nodeID := range []string{"busy", "idle", "dissenter", "green"} {
+		shardState.biddingNodes[nodeID] = struct{}{}
+	}
+	r.shardStateManager.nodeLoads["busy"] = 3
+	r.shardStateManager.nodeLoads["green"] = 1
+	r.reputations.record([]verifier.VerifierResult{
+		{NodeID: "idle", Verified: true},
+		{NodeID: "dissenter", Verified: false},
+	})
+	shardState.nodeEnergy["green"] = &model.NodeEnergy{Renewable: true}
+
+	rankedIDs := func() []string {
+		var nodeIDs []string
+		for _, bid := range shardState.rankBids() {
+			nodeIDs = append(nodeIDs, bid.nodeID)
+		}
+		return nodeIDs
+	}
+
+	// fewest dissents, then fewest shards first
+	require.Equal(t, []string{"idle", "green", "busy", "dissenter"}, rankedIDs())
+	ranked := shardState.rankBids()
+	require.Equal(t, 0, ranked[0].load)
+	require.Equal(t, 3, ranked[2].load)
+	require.Equal(t, 1, ranked[3].dissents)
+
+	// greenest before fewest shards for jobs that prefer green nodes
+	shard.Job.Deal.PreferGreenNodes = true
+	require.Equal(t, []string{"green", "idle", "busy", "dissenter"}, rankedIDs())
+}
+
+func TestResultsPublishedOutsideQuorum(t *testing.T) {
+	r := newTestRequester(t, RequesterNodeConfig{}, &testVerifier{
+		verified: map[string]bool{"agreed": true, "dissented": false},
+	})
+	ctx := context.Background()
+	shardState := r.startShard(t, model.Deal{Concurrency: 2})
+
+	for _, nodeID := range []string{"agreed", "dissented"} {
+		shardState.bid(ctx, nodeID, "", nil, nil)
+		require.Equal(t, model.JobEventBidAccepted, r.nextEvent(t).EventName)
+	}
+	for _, nodeID := range []string{"agreed", "dissented"} {
+		shardState.verifyResult(ctx, nodeID)
+	}
+	verified := map[string]model.JobEventType{}
+	for i := 0; i < 2; i++ {
+		event := r.nextEvent(t)
+		verified[event.TargetNodeID] = event.EventName
+	}
+	require.Equal(t, map[string]model.JobEventType{
+		"agreed":    model.JobEventResultsAccepted,
+		"dissented": model.JobEventResultsRejected,
+	}, verified)
+
+	// the node whose results dissented can't complete the shard by
+	// publishing them
+	shardState.resultsPublished(ctx, "dissented")
+	event := r.nextEvent(t)
+	require.Equal(t, model.JobEventInvalidRequest, event.EventName)
+	require.Equal(t, "dissented", event.TargetNodeID)
+	require.Equal(t, "results published by a node whose results were not accepted", event.Status)
+
+	shardState.resultsPublished(ctx, "agreed")
+	r.requireReleased(t)
+}
+
+func TestBidWindow(t *testing.T) {
+	const bidWindow = 200 * time.Millisecond
+	r := newTestRequester(t, RequesterNodeConfig{BidWindow: bidWindow}, &testVerifier{})
+	ctx := context.Background()
+	r.shardStateManager.mu.Lock()
+	r.shardStateManager.nodeLoads["busy"] = 1
+	r.shardStateManager.mu.Unlock()
+
+	started := time.Now()
+	shardState := r.startShard(t, model.Deal{Concurrency: 1})
+	shardState.bid(ctx, "busy", "", nil, nil)
+	shardState.bid(ctx, "idle", "", nil, nil)
+
+	// the bids are only decided once the window has passed, and the best
+	// of them rather than the first is accepted
+	decisions := r.bidDecisions(t, 2)
+	require.GreaterOrEqual(t, time.Since(started), bidWindow)
+	require.Equal(t, model.JobEventBidAccepted, decisions["idle"].EventName)
+	require.Equal(t, "ranked 1 of 2 bids, the node has 0 other shards in progress and dissented from the quorum "+
+		"0 times (fewest dissents then fewest shards first, ties broken at random)", decisions["idle"].Status)
+	require.Equal(t, model.JobEventBidRejected, decisions["busy"].EventName)
+	require.Equal(t, "ranked 2 of 2 bids, the node has 1 other shards in progress and dissented from the quorum "+
+		"0 times (fewest dissents then fewest shards first, ties broken at random), and the job's concurrency "+
+		"of 1 was reached", decisions["busy"].Status)
+}
+
+func TestBidWindowPassedWithoutBids(t *testing.T) {
+	const bidWindow = 50 * time.Millisecond
+	r := newTestRequester(t, RequesterNodeConfig{BidWindow: bidWindow}, &testVerifier{})
+	shardState := r.startShard(t, model.Deal{Concurrency: 1})
+	time.Sleep(2 * bidWindow)
+
+	// the first bid after the window is accepted as soon as it arrives
+	shardState.bid(context.Background(), "node", "", nil, nil)
+	event := r.nextEvent(t)
+	require.Equal(t, model.JobEventBidAccepted, event.EventName)
+	require.Contains(t, event.Status, "ranked 1 of 1 bids")
+}
+
+func TestBidWindowWaitsForMinBids(t *testing.T) {
+	const bidWindow = 50 * time.Millisecond
+	r := newTestRequester(t, RequesterNodeConfig{BidWindow: bidWindow}, &testVerifier{})
+	ctx := context.Background()
+	shardState := r.startShard(t, model.Deal{Concurrency: 1, MinBids: 2})
+
+	// the window passes with fewer bids than the deal's MinBids
+	shardState.bid(ctx, "first", "", nil, nil)
+	r.requireNoEvent(t, 4*bidWindow)
+
+	shardState.bid(ctx, "second", "", nil, nil)
+	decisions := r.bidDecisions(t, 2)
+	require.Len(t, decisions, 2)
+	accepted := 0
+	for _, decision := range decisions {
+		if decision.EventName == model.JobEventBidAccepted {
+			accepted++
+		}
+	}
+	require.Equal(t, 1, accepted)
+}
+
+func TestMinBidsWithoutBidWindow(t *testing.T) {
+	r := newTestRequester(t, RequesterNodeConfig{}, &testVerifier{})
+	ctx := context.Background()
+	shardState := r.startShard(t, model.Deal{Concurrency: 2, MinBids: 2})
+
+	shardState.bid(ctx, "first", "", nil, nil)
+	r.requireNoEvent(t, 100*time.Millisecond)
+
+	// a node that can't run the shard no longer counts towards MinBids
+	shardState.computeError(ctx, "first")
+	shardState.bid(ctx, "second", "", nil, nil)
+	r.requireNoEvent(t, 100*time.Millisecond)
+
+	shardState.bid(ctx, "third", "", nil, nil)
+	decisions := r.bidDecisions(t, 2)
+	require.Equal(t, model.JobEventBidAccepted, decisions["second"].EventName)
+	require.Equal(t, model.JobEventBidAccepted, decisions["third"].EventName)
+	require.Contains(t, decisions["second"].Status, "of 2 bids")
+}
+
+// publishAcceptedResults has the nodes bid on the shard, agree on the
+// results and publish them to the CID of their name.
+func (r *testRequester) publishAcceptedResults(t *testing.T, shardState *shardStateMachine, nodeIDs ...string) {
+	ctx := context.Background()
+	require.NoError(t, r.localDB.AddJob(ctx, shardState.shard.Job))
+	for _, nodeID := range nodeIDs {
+		shardState.bid(ctx, nodeID, "", nil, nil)
+		require.Equal(t, model.JobEventBidAccepted, r.nextEvent(t).EventName)
+	}
+	for _, nodeID := range nodeIDs {
+		shardState.verifyResult(ctx, nodeID)
+	}
+	for range nodeIDs {
+		require.Equal(t, model.JobEventResultsAccepted, r.nextEvent(t).EventName)
+	}
+	for _, nodeID := range nodeIDs {
+		require.NoError(t, r.localDB.UpdateShardState(ctx, shardState.shard.Job.ID, nodeID, shardState.shard.Index,
+			model.JobShardState{
+				State:           model.JobStateCompleted,
+				PublishedResult: model.StorageSpec{StorageSource: model.StorageSourceIPFS, CID: nodeID},
+			}))
+		shardState.resultsPublished(ctx, nodeID)
+	}
+}
+
+func TestVerifyPublishedResults(t *testing.T) {
+	jobVerifier := &publishedResultsVerifier{
+		testVerifier:      testVerifier{verified: map[string]bool{"a": true, "b": true}},
+		publishedVerified: map[string]bool{"a": true},
+		published:         make(chan []verifier.PublishedResult, 1),
+	}
+	r := newTestRequester(t, RequesterNodeConfig{}, jobVerifier)
+	shardState := r.startShard(t, model.Deal{Concurrency: 2})
+	r.publishAcceptedResults(t, shardState, "a", "b")
+
+	// the verifier is given where all the accepted nodes published
+	cids := map[string]string{}
+	for _, result := range <-jobVerifier.published {
+		cids[result.NodeID] = result.PublishedResult.CID
+	}
+	require.Equal(t, map[string]string{"a": "a", "b": "b"}, cids)
+
+	// one verified result is enough for the shard to complete
+	r.requireReleased(t)
+	r.requireNoEvent(t, 100*time.Millisecond)
+}
+
+func TestVerifyPublishedResultsNoneVerified(t *testing.T) {
+	jobVerifier := &publishedResultsVerifier{
+		testVerifier: testVerifier{verified: map[string]bool{"a": true, "b": true}},
+		published:    make(chan []verifier.PublishedResult, 1),
+	}
+	r := newTestRequester(t, RequesterNodeConfig{}, jobVerifier)
+	shardState := r.startShard(t, model.Deal{Concurrency: 2})
+	r.publishAcceptedResults(t, shardState, "a", "b")
+	require.Len(t, <-jobVerifier.published, 2)
+
+	event := r.nextEvent(t)
+	require.Equal(t, model.JobEventError, event.EventName)
+	require.Contains(t, event.Status, "none of the published results were verified")
+	r.requireReleased(t)
+}
@@ -0,0 +1,220 @@
+package selftest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/c2h5oh/datasize"
+	"github.com/filecoin-project/bacalhau/pkg/docker"
+	"github.com/filecoin-project/bacalhau/pkg/ipfs"
+	"github.com/ricochet2200/go-disk-usage/du"
+)
+
+type dockerCheck struct{}
+
+// NewDockerCheck checks that the Docker daemon docker jobs run on can be
+// reached.
+func NewDockerCheck() Check {
+	return dockerCheck{}
+}
+
+func (dockerCheck) Name() string {
+	return "docker"
+}
+
+func (dockerCheck) Run(ctx context.Context) Result {
+	remedy := "Start the Docker daemon and check that the user running bacalhau can use it, " +
+		"e.g. by adding them to the docker group, or set DOCKER_HOST to where it listens."
+	client, err := docker.NewDockerClient()
+	if err != nil {
+		return failed("could not create a Docker client: %s", remedy, err)
+	}
+	defer client.Close()
+	info, err := client.Info(ctx)
+	if err != nil {
+		return failed("could not reach the Docker daemon: %s", remedy, err)
+	}
+	return ok("Docker %s is running with the %s storage driver", info.ServerVersion, info.Driver)
+}
+
+type ipfsCheck struct {
+	client *ipfs.Client
+}
+
+// NewIPFSCheck checks that the API of the IPFS node the client talks to can
+// be reached.
+func NewIPFSCheck(client *ipfs.Client) Check {
+	return ipfsCheck{client: client}
+}
+
+func (c ipfsCheck) Name() string {
+	return "ipfs"
+}
+
+func (c ipfsCheck) Run(ctx context.Context) Result {
+	id, err := c.client.ID(ctx)
+	if err != nil {
+		return failed("could not reach the IPFS API at %s: %s",
+			"Start the IPFS daemon, or point --ipfs-connect at the address its API listens on.", c.client.APIAddress(), err)
+	}
+	return ok("the IPFS API at %s is reachable, as peer %s", c.client.APIAddress(), id)
+}
+
+type diskSpaceCheck struct {
+	path     string
+	minFree  uint64
+	warnFree uint64
+}
+
+// NewDiskSpaceCheck checks that there is space left on the disk holding the
+// path, failing if less than minFree bytes are free and warning if less than
+// warnFree bytes are.
+func NewDiskSpaceCheck(path string, minFree, warnFree uint64) Check {
+	return diskSpaceCheck{path: path, minFree: minFree, warnFree: warnFree}
+}
+
+func (c diskSpaceCheck) Name() string {
+	return "disk space " + c.path
+}
+
+func (c diskSpaceCheck) Run(ctx context.Context) Result {
+	if _, err := os.Stat(c.path); err != nil {
+		return failed("could not read %s: %s", "Create the directory, or check that the user running bacalhau can read it.",
+			c.path, err)
+	}
+	usage := du.NewDiskUsage(c.path)
+	free, size := usage.Available(), usage.Size()
+	message := fmt.Sprintf("%s free of %s", datasize.ByteSize(free).HR(), datasize.ByteSize(size).HR())
+	remedy := fmt.Sprintf("Free up space on the disk holding %s, which job inputs and results are written to.", c.path)
+	switch {
+	case free < c.minFree:
+		return failed("%s, less than the %s needed to run jobs", remedy, message, datasize.ByteSize(c.minFree).HR())
+	case free < c.warnFree:
+		return warning("%s, jobs with large inputs or results may fail", remedy, message)
+	default:
+		return ok("%s", message)
+	}
+}
+
+type outboundCheck struct {
+	addresses []string
+}
+
+// NewOutboundCheck checks that the node can open connections to the given
+// host:port addresses, failing if it can reach none of them and warning if
+// it can't reach some of them.
+func NewOutboundCheck(addresses []string) Check {
+	return outboundCheck{addresses: addresses}
+}
+
+func (c outboundCheck) Name() string {
+	return "outbound connectivity"
+}
+
+func (c outboundCheck) Run(ctx context.Context) Result {
+	if len(c.addresses) == 0 {
+		return ok("there are no addresses to check")
+	}
+	var dialer net.Dialer
+	var unreachable []string
+	for _, address := range c.addresses {
+		conn, err := dialer.DialContext(ctx, "tcp", address)
+		if err != nil {
+			unreachable = append(unreachable, address)
+			continue
+		}
+		conn.Close()
+	}
+
+	remedy := "Check that the firewall and any proxy allow outbound TCP connections to these addresses."
+	switch {
+	case len(unreachable) == len(c.addresses):
+		return failed("could not connect to any of %s", remedy, strings.Join(unreachable, ", "))
+	case len(unreachable) > 0:
+		return warning("could not connect to %s", remedy, strings.Join(unreachable, ", "))
+	default:
+		return ok("connected to %s", strings.Join(c.addresses, ", "))
+	}
+}
+
+type clockSkewCheck struct {
+	url      string
+	warnSkew time.Duration
+	maxSkew  time.Duration
+}
+
+// NewClockSkewCheck checks the clock of the node against the Date header of
+// the response of the given URL, failing if they are further apart than
+// maxSkew and warning if they are further apart than warnSkew. Date headers
+// are to the second, so the skew found can be out by a second.
+func NewClockSkewCheck(url string, warnSkew, maxSkew time.Duration) Check {
+	return clockSkewCheck{url: url, warnSkew: warnSkew, maxSkew: maxSkew}
+}
+
+func (c clockSkewCheck) Name() string {
+	return "clock skew"
+}
+
+func (c clockSkewCheck) Run(ctx context.Context) Result {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, c.url, nil)
+	if err != nil {
+		return failed("invalid URL %s: %s", "", c.url, err)
+	}
+	start := time.Now()
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		// the outbound connectivity check reports why the node can't connect
+		return warning("could not get the time from %s: %s", "", c.url, err)
+	}
+	res.Body.Close()
+	end := time.Now()
+
+	remoteTime, err := http.ParseTime(res.Header.Get("Date"))
+	if err != nil {
+		return warning("%s did not return a valid Date header: %s", "", c.url, err)
+	}
+	// the server set the date somewhere between sending the request and
+	// getting the response
+	localTime := start.Add(end.Sub(start) / 2)
+	skew := localTime.Sub(remoteTime).Round(time.Second)
+	if skew < 0 {
+		skew = -skew
+	}
+
+	remedy := "Keep the clock in sync with NTP, e.g. with chrony or systemd-timesyncd."
+	switch {
+	case skew > c.maxSkew:
+		return failed("the clock is %s away from the time at %s", remedy, skew, c.url)
+	case skew > c.warnSkew:
+		return warning("the clock is %s away from the time at %s", remedy, skew, c.url)
+	default:
+		return ok("the clock is within %s of the time at %s", c.warnSkew, c.url)
+	}
+}
+
+type funcCheck struct {
+	name string
+	f    func(ctx context.Context) error
+}
+
+// NewCheck returns a check that fails with the error the function returns,
+// for dependencies that can only say whether they work.
+func NewCheck(name string, f func(ctx context.Context) error) Check {
+	return funcCheck{name: name, f: f}
+}
+
+func (c funcCheck) Name() string {
+	return c.name
+}
+
+func (c funcCheck) Run(ctx context.Context) Result {
+	if err := c.f(ctx); err != nil {
+		return failed("%s", "", err)
+	}
+	return ok("ok")
+}
@@ -0,0 +1,106 @@
+// Package selftest checks that the environment a node runs in has what the
+// node needs to run jobs, so that operators find out why a node is broken
+// before jobs start failing on it.
+package selftest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusOK      Status = "OK"
+	StatusWarning Status = "WARNING"
+	StatusFailed  Status = "FAILED"
+)
+
+// DefaultCheckTimeout is how long a check can take before it fails.
+const DefaultCheckTimeout = 10 * time.Second
+
+// Check is one thing the node needs from its environment.
+type Check interface {
+	Name() string
+	Run(ctx context.Context) Result
+}
+
+// Result is the outcome of a check, and what to do about it if it didn't pass.
+type Result struct {
+	Name     string        `json:"Name"`
+	Status   Status        `json:"Status"`
+	Message  string        `json:"Message"`
+	Remedy   string        `json:"Remedy,omitempty"`
+	Duration time.Duration `json:"Duration"`
+}
+
+// Report is the outcome of all the checks of a node. The node is healthy if
+// none of them failed, even if some of them warned about something.
+type Report struct {
+	Healthy   bool      `json:"Healthy"`
+	CheckedAt time.Time `json:"CheckedAt"`
+	Checks    []Result  `json:"Checks"`
+}
+
+// Run runs the checks at the same time, failing any that take longer than the
+// timeout, and returns their results in the order of the checks.
+func Run(ctx context.Context, timeout time.Duration, checks []Check) Report {
+	report := Report{
+		Healthy:   true,
+		CheckedAt: time.Now(),
+		Checks:    make([]Result, len(checks)),
+	}
+
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			report.Checks[i] = runCheck(ctx, timeout, check)
+		}(i, check)
+	}
+	wg.Wait()
+
+	for _, result := range report.Checks {
+		if result.Status == StatusFailed {
+			report.Healthy = false
+		}
+	}
+	return report
+}
+
+func runCheck(ctx context.Context, timeout time.Duration, check Check) Result {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan Result, 1)
+	go func() {
+		done <- check.Run(ctx)
+	}()
+
+	var result Result
+	select {
+	case result = <-done:
+	case <-ctx.Done():
+		// not all clients give up when their context is done
+		result = failed("the check did not finish within %s", "", timeout)
+	}
+	result.Name = check.Name()
+	result.Duration = time.Since(start)
+	return result
+}
+
+func ok(format string, args ...interface{}) Result {
+	return Result{Status: StatusOK, Message: fmt.Sprintf(format, args...)}
+}
+
+func warning(format, remedy string, args ...interface{}) Result {
+	return Result{Status: StatusWarning, Message: fmt.Sprintf(format, args...), Remedy: remedy}
+}
+
+func failed(format, remedy string, args ...interface{}) Result {
+	return Result{Status: StatusFailed, Message: fmt.Sprintf(format, args...), Remedy: remedy}
+}
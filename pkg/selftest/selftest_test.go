@@ -0,0 +1,108 @@
+//go:build unit || !integration
+
+package selftest
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeCheck struct {
+	name   string
+	result Result
+	delay  time.Duration
+}
+
+func (c fakeCheck) Name() string {
+	return c.name
+}
+
+func (c fakeCheck) Run(ctx context.Context) Result {
+	time.Sleep(c.delay)
+	return c.result
+}
+
+func TestRun(t *testing.T) {
+	ctx := context.Background()
+
+	report := Run(ctx, time.Second, []Check{
+		fakeCheck{name: "a", result: ok("fine")},
+		fakeCheck{name: "b", result: warning("not great", "do something")},
+	})
+	require.True(t, report.Healthy)
+	require.Equal(t, "a", report.Checks[0].Name)
+	require.Equal(t, StatusOK, report.Checks[0].Status)
+	require.Equal(t, "b", report.Checks[1].Name)
+	require.Equal(t, StatusWarning, report.Checks[1].Status)
+	require.Equal(t, "do something", report.Checks[1].Remedy)
+
+	report = Run(ctx, 10*time.Millisecond, []Check{
+		fakeCheck{name: "a", result: ok("fine")},
+		fakeCheck{name: "slow", result: ok("fine"), delay: time.Second},
+	})
+	require.False(t, report.Healthy)
+	require.Equal(t, StatusOK, report.Checks[0].Status)
+	require.Equal(t, "slow", report.Checks[1].Name)
+	require.Equal(t, StatusFailed, report.Checks[1].Status)
+	require.Contains(t, report.Checks[1].Message, "did not finish")
+}
+
+func TestDiskSpaceCheck(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	require.Equal(t, StatusOK, NewDiskSpaceCheck(dir, 0, 0).Run(ctx).Status)
+	require.Equal(t, StatusWarning, NewDiskSpaceCheck(dir, 0, 1<<62).Run(ctx).Status)
+	require.Equal(t, StatusFailed, NewDiskSpaceCheck(dir, 1<<62, 1<<62).Run(ctx).Status)
+	require.Equal(t, StatusFailed, NewDiskSpaceCheck(dir+"/missing", 0, 0).Run(ctx).Status)
+}
+
+func TestOutboundCheck(t *testing.T) {
+	ctx := context.Background()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	// nothing listens on a port that has just been closed
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	require.NoError(t, closed.Close())
+
+	reachable, unreachable := listener.Addr().String(), closed.Addr().String()
+	require.Equal(t, StatusOK, NewOutboundCheck([]string{reachable}).Run(ctx).Status)
+	result := NewOutboundCheck([]string{reachable, unreachable}).Run(ctx)
+	require.Equal(t, StatusWarning, result.Status)
+	require.Contains(t, result.Message, unreachable)
+	require.Equal(t, StatusFailed, NewOutboundCheck([]string{unreachable}).Run(ctx).Status)
+}
+
+func TestClockSkewCheck(t *testing.T) {
+	ctx := context.Background()
+	var serverTime time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.Header().Set("Date", serverTime.UTC().Format(http.TimeFormat))
+	}))
+	defer server.Close()
+
+	check := NewClockSkewCheck(server.URL, 10*time.Second, time.Minute)
+	for skew, status := range map[time.Duration]Status{
+		0:                 StatusOK,
+		-30 * time.Second: StatusWarning,
+		30 * time.Second:  StatusWarning,
+		2 * time.Minute:   StatusFailed,
+	} {
+		serverTime = time.Now().Add(skew)
+		result := check.Run(ctx)
+		require.Equal(t, status, result.Status, "skew %s: %s", skew, result.Message)
+	}
+
+	// a server that can't be reached says nothing about the clock
+	server.Close()
+	require.Equal(t, StatusWarning, check.Run(ctx).Status)
+}
@@ -0,0 +1,238 @@
+package azureblob
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/bacalhau/pkg/compute/secrets"
+	"github.com/filecoin-project/bacalhau/pkg/storage/cloud"
+)
+
+// reads the blobs of Azure Blob Storage containers, which jobs refer to with
+// URLs of the form az://<account>/<container>/<blob name or prefix>
+
+const (
+	DefaultEndpoint = "https://%s.blob.core.windows.net"
+
+	apiVersion = "2021-08-06"
+
+	// the Azure Instance Metadata Service, which hands out tokens for the
+	// managed identities of VMs
+	managedIdentityTokenURL = "http://169.254.169.254/metadata/identity/oauth2/token"
+	storageResource         = "https://storage.azure.com/"
+
+	// fetch a new token this long before the current one expires
+	tokenExpiryMargin = 5 * time.Minute
+)
+
+type Config struct {
+	// A shared access signature that grants read and list access to the
+	// containers jobs use, defaults to $AZURE_STORAGE_SAS_TOKEN.
+	SASToken string
+	// Where to fetch the SAS token from instead, so that it can be rotated.
+	SASTokenSecret *secrets.Credential
+	// Whether to authenticate with the managed identity of the Azure VM the
+	// node runs on, rather than a SAS token.
+	UseManagedIdentity bool
+	// The client ID of the user-assigned managed identity to use, if the VM
+	// has more than one.
+	ManagedIdentityClientID string
+	// The blob service endpoint of a storage account, in which %s is replaced
+	// by the account name. Defaults to DefaultEndpoint.
+	Endpoint string
+}
+
+// Store reads blobs anonymously, with a SAS token or with a managed identity,
+// in that order of preference.
+type Store struct {
+	config     Config
+	httpClient *http.Client
+
+	tokenMutex sync.Mutex
+	token      string
+	tokenExp   time.Time
+}
+
+func NewStore(config Config) *Store {
+	if config.SASToken == "" {
+		config.SASToken = os.Getenv("AZURE_STORAGE_SAS_TOKEN")
+	}
+	config.SASToken = strings.TrimPrefix(config.SASToken, "?")
+	if config.Endpoint == "" {
+		config.Endpoint = DefaultEndpoint
+	}
+	return &Store{
+		config:     config,
+		httpClient: &http.Client{},
+	}
+}
+
+// ParseURL returns "<account>/<container>" as the bucket, since containers
+// are only unique within their storage account.
+func (s *Store) ParseURL(u *url.URL) (string, string, error) {
+	if u.Scheme != "az" {
+		return "", "", fmt.Errorf("unsupported Azure Blob Storage url '%s', expected az://<account>/<container>/<path>", u)
+	}
+	container, prefix, _ := strings.Cut(strings.TrimPrefix(u.Path, "/"), "/")
+	if u.Host == "" || container == "" {
+		return "", "", fmt.Errorf("azure blob storage url '%s' has no account or container, expected az://<account>/<container>/<path>", u)
+	}
+	return u.Host + "/" + container, prefix, nil
+}
+
+type listBlobsResult struct {
+	Blobs []struct {
+		Name       string `xml:"Name"`
+		Properties struct {
+			ContentLength uint64 `xml:"Content-Length"`
+		} `xml:"Properties"`
+	} `xml:"Blobs>Blob"`
+	NextMarker string `xml:"NextMarker"`
+}
+
+func (s *Store) List(ctx context.Context, bucket, prefix string) ([]cloud.Object, error) {
+	var objects []cloud.Object
+	marker := ""
+	for {
+		query := url.Values{
+			"restype": {"container"},
+			"comp":    {"list"},
+			"prefix":  {prefix},
+		}
+		if marker != "" {
+			query.Set("marker", marker)
+		}
+		res, err := s.do(ctx, bucket, "", query)
+		if err != nil {
+			return nil, err
+		}
+		var result listBlobsResult
+		err = xml.NewDecoder(res.Body).Decode(&result)
+		res.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse blob listing: %w", err)
+		}
+
+		for _, blob := range result.Blobs {
+			objects = append(objects, cloud.Object{Name: blob.Name, Size: blob.Properties.ContentLength})
+		}
+		if result.NextMarker == "" {
+			return objects, nil
+		}
+		marker = result.NextMarker
+	}
+}
+
+func (s *Store) Get(ctx context.Context, bucket, name string) (io.ReadCloser, error) {
+	res, err := s.do(ctx, bucket, name, url.Values{})
+	if err != nil {
+		return nil, err
+	}
+	return res.Body, nil
+}
+
+func (s *Store) do(ctx context.Context, bucket, name string, query url.Values) (*http.Response, error) {
+	account, container, _ := strings.Cut(bucket, "/")
+	endpoint := strings.TrimSuffix(fmt.Sprintf(s.config.Endpoint, account), "/")
+	u, err := url.Parse(endpoint + "/" + url.PathEscape(container))
+	if err != nil {
+		return nil, err
+	}
+	if name != "" {
+		u = u.JoinPath(name)
+	}
+	sasToken := s.config.SASToken
+	if s.config.SASTokenSecret != nil {
+		if sasToken, err = s.config.SASTokenSecret.Get(ctx); err != nil {
+			return nil, err
+		}
+		sasToken = strings.TrimPrefix(sasToken, "?")
+	}
+	rawQuery := query.Encode()
+	if sasToken != "" {
+		rawQuery = strings.TrimPrefix(rawQuery+"&"+sasToken, "&")
+	}
+	u.RawQuery = rawQuery
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-ms-version", apiVersion)
+	if sasToken == "" && s.config.UseManagedIdentity {
+		token, err := s.managedIdentityToken(ctx)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	res, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		defer res.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return nil, fmt.Errorf("azure blob storage returned %s: %s", res.Status, strings.TrimSpace(string(body)))
+	}
+	return res, nil
+}
+
+// managedIdentityToken returns an access token for the managed identity of
+// the VM, reusing the last one until it is about to expire.
+func (s *Store) managedIdentityToken(ctx context.Context) (string, error) {
+	s.tokenMutex.Lock()
+	defer s.tokenMutex.Unlock()
+	if s.token != "" && time.Now().Add(tokenExpiryMargin).Before(s.tokenExp) {
+		return s.token, nil
+	}
+
+	query := url.Values{
+		"api-version": {"2018-02-01"},
+		"resource":    {storageResource},
+	}
+	if s.config.ManagedIdentityClientID != "" {
+		query.Set("client_id", s.config.ManagedIdentityClientID)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, managedIdentityTokenURL+"?"+query.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata", "true")
+	res, err := s.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("unable to get a managed identity token: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unable to get a managed identity token: %s", res.Status)
+	}
+
+	var token struct {
+		AccessToken string      `json:"access_token"`
+		ExpiresIn   json.Number `json:"expires_in"`
+	}
+	if err = json.NewDecoder(res.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("unable to parse the managed identity token: %w", err)
+	}
+	expiresIn, err := token.ExpiresIn.Int64()
+	if err != nil {
+		return "", fmt.Errorf("unable to parse the managed identity token expiry: %w", err)
+	}
+	s.token = token.AccessToken
+	s.tokenExp = time.Now().Add(time.Duration(expiresIn) * time.Second)
+	return s.token, nil
+}
+
+// Compile time interface check:
+var _ cloud.ObjectStore = (*Store)(nil)
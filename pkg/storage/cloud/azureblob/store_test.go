@@ -0,0 +1,82 @@
+//go:build unit || !integration
+
+package azureblob
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/filecoin-project/bacalhau/pkg/storage/cloud"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseURL(t *testing.T) {
+	store := NewStore(Config{})
+	for rawURL, expected := range map[string][]string{
+		"az://account/container":              {"account/container", ""},
+		"az://account/container/data/a.csv":   {"account/container", "data/a.csv"},
+		"az://account/container/data/nested/": {"account/container", "data/nested/"},
+	} {
+		u, err := url.Parse(rawURL)
+		require.NoError(t, err)
+		bucket, prefix, err := store.ParseURL(u)
+		require.NoError(t, err, rawURL)
+		require.Equal(t, expected, []string{bucket, prefix}, rawURL)
+	}
+
+	for _, rawURL := range []string{"gs://bucket/data", "az://account", "az:///container/data"} {
+		u, err := url.Parse(rawURL)
+		require.NoError(t, err)
+		_, _, err = store.ParseURL(u)
+		require.Error(t, err, rawURL)
+	}
+}
+
+func TestListAndGet(t *testing.T) {
+	ctx := context.Background()
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Get("sig") != "secret" {
+			res.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if req.URL.Query().Get("comp") == "list" {
+			require.Equal(t, "/account/container", req.URL.Path)
+			require.Equal(t, "data/", req.URL.Query().Get("prefix"))
+			// the listing is split over two pages
+			if req.URL.Query().Get("marker") == "" {
+				fmt.Fprint(res, `<?xml version="1.0" encoding="utf-8"?><EnumerationResults><Blobs>`+
+					`<Blob><Name>data/a.csv</Name><Properties><Content-Length>3</Content-Length></Properties></Blob>`+
+					`</Blobs><NextMarker>page2</NextMarker></EnumerationResults>`)
+			} else {
+				fmt.Fprint(res, `<?xml version="1.0" encoding="utf-8"?><EnumerationResults><Blobs>`+
+					`<Blob><Name>data/b c.csv</Name><Properties><Content-Length>2</Content-Length></Properties></Blob>`+
+					`</Blobs><NextMarker /></EnumerationResults>`)
+			}
+			return
+		}
+		require.Equal(t, "/account/container/data/b c.csv", req.URL.Path)
+		fmt.Fprint(res, "bb")
+	}))
+	defer server.Close()
+
+	store := NewStore(Config{SASToken: "?sig=secret", Endpoint: server.URL + "/%s"})
+	objects, err := store.List(ctx, "account/container", "data/")
+	require.NoError(t, err)
+	require.Equal(t, []cloud.Object{{Name: "data/a.csv", Size: 3}, {Name: "data/b c.csv", Size: 2}}, objects)
+
+	reader, err := store.Get(ctx, "account/container", "data/b c.csv")
+	require.NoError(t, err)
+	defer reader.Close()
+	content, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	require.Equal(t, "bb", string(content))
+
+	// without the SAS token the requests are anonymous, and forbidden
+	_, err = NewStore(Config{Endpoint: server.URL + "/%s"}).List(ctx, "account/container", "data/")
+	require.ErrorContains(t, err, "403")
+}
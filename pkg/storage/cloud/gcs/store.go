@@ -0,0 +1,251 @@
+package gcs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/filecoin-project/bacalhau/pkg/storage/cloud"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/jwt"
+)
+
+// reads the objects of Google Cloud Storage buckets, which jobs refer to with
+// URLs of the form gs://<bucket>/<object name or prefix>
+
+const (
+	DefaultEndpoint = "https://storage.googleapis.com"
+
+	readOnlyScope = "https://www.googleapis.com/auth/devstorage.read_only"
+
+	// the GCE metadata server, which hands out tokens for the service account
+	// of the VM - used when no credentials file is given, as the application
+	// default credentials do
+	metadataTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+)
+
+// where tokens are fetched from for credentials files that don't say
+var googleTokenURL = "https://oauth2.googleapis.com/token"
+
+type Config struct {
+	// A service account key or authorized user credentials file, defaults to
+	// $GOOGLE_APPLICATION_CREDENTIALS. Without one, the credentials of the
+	// GCE VM the node runs on are used.
+	CredentialsFile string
+	// Whether to read public buckets without any credentials.
+	Anonymous bool
+	// The endpoint of the Cloud Storage JSON API, defaults to DefaultEndpoint.
+	Endpoint string
+}
+
+type Store struct {
+	config     Config
+	httpClient *http.Client
+}
+
+func NewStore(ctx context.Context, config Config) (*Store, error) {
+	if config.CredentialsFile == "" {
+		config.CredentialsFile = os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	}
+	if config.Endpoint == "" {
+		config.Endpoint = DefaultEndpoint
+	}
+	config.Endpoint = strings.TrimSuffix(config.Endpoint, "/")
+
+	httpClient := &http.Client{}
+	if !config.Anonymous {
+		tokenSource, err := NewTokenSource(ctx, config.CredentialsFile, readOnlyScope)
+		if err != nil {
+			return nil, err
+		}
+		httpClient = oauth2.NewClient(ctx, tokenSource)
+	}
+	return &Store{
+		config:     config,
+		httpClient: httpClient,
+	}, nil
+}
+
+func (s *Store) ParseURL(u *url.URL) (string, string, error) {
+	if u.Scheme != "gs" || u.Host == "" {
+		return "", "", fmt.Errorf("unsupported Google Cloud Storage url '%s', expected gs://<bucket>/<path>", u)
+	}
+	return u.Host, strings.TrimPrefix(u.Path, "/"), nil
+}
+
+type listObjectsResult struct {
+	Items []struct {
+		Name string `json:"name"`
+		// the API returns 64 bit integers as strings
+		Size string `json:"size"`
+	} `json:"items"`
+	NextPageToken string `json:"nextPageToken"`
+}
+
+func (s *Store) List(ctx context.Context, bucket, prefix string) ([]cloud.Object, error) {
+	var objects []cloud.Object
+	pageToken := ""
+	for {
+		query := url.Values{"prefix": {prefix}}
+		if pageToken != "" {
+			query.Set("pageToken", pageToken)
+		}
+		res, err := s.get(ctx, fmt.Sprintf("%s/storage/v1/b/%s/o?%s", s.config.Endpoint, url.PathEscape(bucket), query.Encode()))
+		if err != nil {
+			return nil, err
+		}
+		var result listObjectsResult
+		err = json.NewDecoder(res.Body).Decode(&result)
+		res.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse object listing: %w", err)
+		}
+
+		for _, item := range result.Items {
+			size, err := strconv.ParseUint(item.Size, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid size of object '%s': %w", item.Name, err)
+			}
+			objects = append(objects, cloud.Object{Name: item.Name, Size: size})
+		}
+		if result.NextPageToken == "" {
+			return objects, nil
+		}
+		pageToken = result.NextPageToken
+	}
+}
+
+func (s *Store) Get(ctx context.Context, bucket, name string) (io.ReadCloser, error) {
+	res, err := s.get(ctx, fmt.Sprintf("%s/storage/v1/b/%s/o/%s?alt=media", s.config.Endpoint, url.PathEscape(bucket), url.PathEscape(name)))
+	if err != nil {
+		return nil, err
+	}
+	return res.Body, nil
+}
+
+func (s *Store) get(ctx context.Context, u string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		defer res.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return nil, fmt.Errorf("google cloud storage returned %s: %s", res.Status, strings.TrimSpace(string(body)))
+	}
+	return res, nil
+}
+
+type credentialsFile struct {
+	Type string `json:"type"`
+
+	// service_account
+	ClientEmail  string `json:"client_email"`
+	PrivateKey   string `json:"private_key"`
+	PrivateKeyID string `json:"private_key_id"`
+	TokenURI     string `json:"token_uri"`
+
+	// authorized_user
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// NewTokenSource returns the source of the tokens of the credentials file
+// for the scopes, or of the service account of the GCE VM the node runs on
+// if there is no file.
+func NewTokenSource(ctx context.Context, path string, scopes ...string) (oauth2.TokenSource, error) {
+	if path == "" {
+		return oauth2.ReuseTokenSource(nil, &metadataTokenSource{ctx: ctx}), nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read google credentials file: %w", err)
+	}
+	var creds credentialsFile
+	if err = json.Unmarshal(data, &creds); err != nil {
+		return nil, fmt.Errorf("unable to parse google credentials file %s: %w", path, err)
+	}
+
+	switch creds.Type {
+	case "service_account":
+		tokenURL := creds.TokenURI
+		if tokenURL == "" {
+			tokenURL = googleTokenURL
+		}
+		config := &jwt.Config{
+			Email:        creds.ClientEmail,
+			PrivateKey:   []byte(creds.PrivateKey),
+			PrivateKeyID: creds.PrivateKeyID,
+			Scopes:       scopes,
+			TokenURL:     tokenURL,
+		}
+		return config.TokenSource(ctx), nil
+	case "authorized_user":
+		config := &oauth2.Config{
+			ClientID:     creds.ClientID,
+			ClientSecret: creds.ClientSecret,
+			Endpoint: oauth2.Endpoint{
+				AuthURL:   "https://accounts.google.com/o/oauth2/auth",
+				TokenURL:  googleTokenURL,
+				AuthStyle: oauth2.AuthStyleInParams,
+			},
+			Scopes: scopes,
+		}
+		return config.TokenSource(ctx, &oauth2.Token{RefreshToken: creds.RefreshToken}), nil
+	default:
+		return nil, fmt.Errorf("unsupported google credentials type '%s' in %s", creds.Type, path)
+	}
+}
+
+// metadataTokenSource fetches the tokens of the VM's service account from
+// the GCE metadata server.
+type metadataTokenSource struct {
+	ctx context.Context
+}
+
+func (ts *metadataTokenSource) Token() (*oauth2.Token, error) {
+	req, err := http.NewRequestWithContext(ts.ctx, http.MethodGet, metadataTokenURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get a token from the GCE metadata server, "+
+			"use --gcs-credentials-file outside of GCE: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to get a token from the GCE metadata server: %s", res.Status)
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+		TokenType   string `json:"token_type"`
+	}
+	if err = json.NewDecoder(res.Body).Decode(&token); err != nil {
+		return nil, fmt.Errorf("unable to parse the GCE metadata server token: %w", err)
+	}
+	return &oauth2.Token{
+		AccessToken: token.AccessToken,
+		TokenType:   token.TokenType,
+		Expiry:      time.Now().Add(time.Duration(token.ExpiresIn) * time.Second),
+	}, nil
+}
+
+// Compile time interface check:
+var _ cloud.ObjectStore = (*Store)(nil)
@@ -0,0 +1,104 @@
+//go:build unit || !integration
+
+package gcs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/filecoin-project/bacalhau/pkg/storage/cloud"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListAndGet(t *testing.T) {
+	ctx := context.Background()
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Get("alt") == "media" {
+			// object names are escaped as a single path segment
+			if req.URL.EscapedPath() != "/storage/v1/b/bucket/o/data%2Fnested%2Fb.csv" {
+				res.WriteHeader(http.StatusNotFound)
+				return
+			}
+			fmt.Fprint(res, "bb")
+			return
+		}
+		require.Equal(t, "/storage/v1/b/bucket/o", req.URL.Path)
+		require.Equal(t, "data/", req.URL.Query().Get("prefix"))
+		// the listing is split over two pages
+		if req.URL.Query().Get("pageToken") == "" {
+			fmt.Fprint(res, `{"items": [{"name": "data/a.csv", "size": "3"}], "nextPageToken": "page2"}`)
+		} else {
+			fmt.Fprint(res, `{"items": [{"name": "data/nested/b.csv", "size": "2"}]}`)
+		}
+	}))
+	defer server.Close()
+
+	store, err := NewStore(ctx, Config{Anonymous: true, Endpoint: server.URL})
+	require.NoError(t, err)
+	objects, err := store.List(ctx, "bucket", "data/")
+	require.NoError(t, err)
+	require.Equal(t, []cloud.Object{{Name: "data/a.csv", Size: 3}, {Name: "data/nested/b.csv", Size: 2}}, objects)
+
+	reader, err := store.Get(ctx, "bucket", "data/nested/b.csv")
+	require.NoError(t, err)
+	defer reader.Close()
+	content, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	require.Equal(t, "bb", string(content))
+
+	_, err = store.Get(ctx, "bucket", "missing")
+	require.ErrorContains(t, err, "404")
+}
+
+func TestAuthorizedUserCredentials(t *testing.T) {
+	ctx := context.Background()
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		require.Equal(t, "Bearer token", req.Header.Get("Authorization"))
+		fmt.Fprint(res, `{"items": []}`)
+	}))
+	defer server.Close()
+
+	// the token is refreshed from a fake token endpoint
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		require.NoError(t, req.ParseForm())
+		require.Equal(t, "refresh", req.PostForm.Get("refresh_token"))
+		res.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(res, `{"access_token": "token", "token_type": "Bearer", "expires_in": 3600}`)
+	}))
+	defer tokenServer.Close()
+
+	defer func(url string) { googleTokenURL = url }(googleTokenURL)
+	googleTokenURL = tokenServer.URL
+
+	store, err := NewStore(ctx, Config{
+		CredentialsFile: writeCredentials(t, map[string]string{
+			"type":          "authorized_user",
+			"client_id":     "id",
+			"client_secret": "secret",
+			"refresh_token": "refresh",
+		}),
+		Endpoint: server.URL,
+	})
+	require.NoError(t, err)
+	objects, err := store.List(ctx, "bucket", "")
+	require.NoError(t, err)
+	require.Empty(t, objects)
+
+	_, err = NewTokenSource(ctx, writeCredentials(t, map[string]string{"type": "external_account"}), readOnlyScope)
+	require.ErrorContains(t, err, "unsupported google credentials type")
+}
+
+func writeCredentials(t *testing.T, creds map[string]string) string {
+	data, err := json.Marshal(creds)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	require.NoError(t, os.WriteFile(path, data, 0600))
+	return path
+}
@@ -0,0 +1,210 @@
+package cloud
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/filecoin-project/bacalhau/pkg/config"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/storage"
+	"github.com/filecoin-project/bacalhau/pkg/system"
+	"github.com/rs/zerolog/log"
+)
+
+// a storage driver that downloads the objects of a cloud storage bucket,
+// either a single object or every object under a prefix, to a local
+// directory in preparation for a job to run - it will remove the folder
+// once complete
+
+// ObjectStore reads the objects of a cloud storage service, such as Azure
+// Blob Storage or Google Cloud Storage.
+type ObjectStore interface {
+	// ParseURL returns the bucket and object name or prefix that a storage
+	// spec's URL refers to.
+	ParseURL(u *url.URL) (bucket string, prefix string, err error)
+	// List returns the objects of the bucket whose names start with prefix.
+	List(ctx context.Context, bucket, prefix string) ([]Object, error)
+	// Get reads an object of the bucket.
+	Get(ctx context.Context, bucket, name string) (io.ReadCloser, error)
+}
+
+type Object struct {
+	Name string
+	Size uint64
+}
+
+type StorageProvider struct {
+	LocalDir string
+	store    ObjectStore
+}
+
+func NewStorage(cm *system.CleanupManager, name string, store ObjectStore) (*StorageProvider, error) {
+	dir, err := os.MkdirTemp(config.GetStoragePath(), "bacalhau-"+name)
+	if err != nil {
+		return nil, err
+	}
+
+	cm.RegisterCallback(func() error {
+		if err := os.RemoveAll(dir); err != nil {
+			return fmt.Errorf("unable to remove storage folder: %w", err)
+		}
+		return nil
+	})
+
+	log.Debug().Msgf("%s storage driver created with output dir: %s", name, dir)
+	return &StorageProvider{
+		LocalDir: dir,
+		store:    store,
+	}, nil
+}
+
+func (sp *StorageProvider) IsInstalled(context.Context) (bool, error) {
+	return true, nil
+}
+
+func (sp *StorageProvider) HasStorageLocally(context.Context, model.StorageSpec) (bool, error) {
+	return false, nil
+}
+
+func (sp *StorageProvider) GetVolumeSize(ctx context.Context, spec model.StorageSpec) (uint64, error) {
+	_, objects, err := sp.listObjects(ctx, spec)
+	if err != nil {
+		return 0, err
+	}
+	var size uint64
+	for _, object := range objects {
+		size += object.Size
+	}
+	return size, nil
+}
+
+func (sp *StorageProvider) PrepareStorage(ctx context.Context, spec model.StorageSpec) (storage.StorageVolume, error) {
+	ctx, span := system.GetTracer().Start(ctx, "pkg/storage/cloud.PrepareStorage")
+	defer span.End()
+
+	bucket, objects, err := sp.listObjects(ctx, spec)
+	if err != nil {
+		return storage.StorageVolume{}, err
+	}
+	if len(objects) == 0 {
+		return storage.StorageVolume{}, fmt.Errorf("no objects found at %s", spec.URL)
+	}
+
+	outputDir, err := os.MkdirTemp(sp.LocalDir, "*")
+	if err != nil {
+		return storage.StorageVolume{}, err
+	}
+	for _, object := range objects {
+		if err = sp.download(ctx, bucket, object.Name, filepath.Join(outputDir, object.relativePath)); err != nil {
+			_ = os.RemoveAll(outputDir)
+			return storage.StorageVolume{}, err
+		}
+	}
+
+	return storage.StorageVolume{
+		Type:   storage.StorageVolumeConnectorBind,
+		Source: outputDir,
+		Target: spec.Path,
+	}, nil
+}
+
+func (sp *StorageProvider) CleanupStorage(_ context.Context, _ model.StorageSpec, volume storage.StorageVolume) error {
+	return os.RemoveAll(volume.Source)
+}
+
+func (sp *StorageProvider) Upload(context.Context, string) (model.StorageSpec, error) {
+	return model.StorageSpec{}, fmt.Errorf("not implemented")
+}
+
+func (sp *StorageProvider) Explode(_ context.Context, spec model.StorageSpec) ([]model.StorageSpec, error) {
+	return []model.StorageSpec{
+		spec,
+	}, nil
+}
+
+type listedObject struct {
+	Object
+	// where the object is downloaded to, relative to the volume
+	relativePath string
+}
+
+// listObjects returns the objects a spec's URL refers to: the object it
+// names, or else the objects in the "directory" it names.
+func (sp *StorageProvider) listObjects(ctx context.Context, spec model.StorageSpec) (string, []listedObject, error) {
+	u, err := url.Parse(spec.URL)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid storage url '%s': %w", spec.URL, err)
+	}
+	bucket, prefix, err := sp.store.ParseURL(u)
+	if err != nil {
+		return "", nil, err
+	}
+
+	objects, err := sp.store.List(ctx, bucket, prefix)
+	if err != nil {
+		return "", nil, fmt.Errorf("unable to list objects at %s: %w", spec.URL, err)
+	}
+
+	var res []listedObject
+	dirPrefix := prefix
+	if dirPrefix != "" && !strings.HasSuffix(dirPrefix, "/") {
+		dirPrefix += "/"
+	}
+	for _, object := range objects {
+		if strings.HasSuffix(object.Name, "/") {
+			// a placeholder for an empty "directory"
+			continue
+		}
+		var relativePath string
+		switch {
+		case object.Name == prefix:
+			relativePath = path.Base(object.Name)
+		case strings.HasPrefix(object.Name, dirPrefix):
+			relativePath = strings.TrimPrefix(object.Name, dirPrefix)
+		default:
+			// e.g. "data2/file" when listing "data"
+			continue
+		}
+		if !isLocalPath(relativePath) {
+			return "", nil, fmt.Errorf("object '%s' would be downloaded outside of the volume", object.Name)
+		}
+		res = append(res, listedObject{Object: object, relativePath: relativePath})
+	}
+	return bucket, res, nil
+}
+
+func isLocalPath(p string) bool {
+	p = filepath.Clean(p)
+	return !filepath.IsAbs(p) && p != ".." && !strings.HasPrefix(p, ".."+string(filepath.Separator))
+}
+
+func (sp *StorageProvider) download(ctx context.Context, bucket, name, outputPath string) error {
+	if err := os.MkdirAll(filepath.Dir(outputPath), os.ModePerm); err != nil {
+		return err
+	}
+
+	reader, err := sp.store.Get(ctx, bucket, name)
+	if err != nil {
+		return fmt.Errorf("unable to get object '%s': %w", name, err)
+	}
+	defer reader.Close()
+
+	file, err := os.Create(outputPath)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(file, reader); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("unable to download object '%s': %w", name, err)
+	}
+	return file.Close()
+}
+
+// Compile time interface check:
+var _ storage.Storage = (*StorageProvider)(nil)
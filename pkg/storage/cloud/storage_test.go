@@ -0,0 +1,115 @@
+//go:build unit || !integration
+
+package cloud
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/filecoin-project/bacalhau/pkg/logger"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/system"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStore holds the objects of a single bucket in memory.
+type fakeStore struct {
+	objects map[string]string
+}
+
+func (f *fakeStore) ParseURL(u *url.URL) (string, string, error) {
+	return u.Host, strings.TrimPrefix(u.Path, "/"), nil
+}
+
+func (f *fakeStore) List(_ context.Context, _, prefix string) ([]Object, error) {
+	var objects []Object
+	for name, content := range f.objects {
+		if strings.HasPrefix(name, prefix) {
+			objects = append(objects, Object{Name: name, Size: uint64(len(content))})
+		}
+	}
+	return objects, nil
+}
+
+func (f *fakeStore) Get(_ context.Context, _, name string) (io.ReadCloser, error) {
+	content, ok := f.objects[name]
+	if !ok {
+		return nil, fmt.Errorf("no object %s", name)
+	}
+	return io.NopCloser(strings.NewReader(content)), nil
+}
+
+func newTestStorage(t *testing.T, objects map[string]string) *StorageProvider {
+	t.Setenv("BACALHAU_STORAGE_PATH", t.TempDir())
+	cm := system.NewCleanupManager()
+	t.Cleanup(cm.Cleanup)
+	driver, err := NewStorage(cm, "test", &fakeStore{objects: objects})
+	require.NoError(t, err)
+	return driver
+}
+
+func TestPrepareStorage(t *testing.T) {
+	logger.ConfigureTestLogging(t)
+	ctx := context.Background()
+	driver := newTestStorage(t, map[string]string{
+		"data/a.csv":        "aaa",
+		"data/nested/b.csv": "bb",
+		"data/empty/":       "",
+		"data2/c.csv":       "c",
+	})
+
+	for _, test := range []struct {
+		url   string
+		size  uint64
+		files map[string]string
+	}{
+		{url: "test://bucket/data", size: 5, files: map[string]string{"a.csv": "aaa", "nested/b.csv": "bb"}},
+		{url: "test://bucket/data/", size: 5, files: map[string]string{"a.csv": "aaa", "nested/b.csv": "bb"}},
+		{url: "test://bucket/data/a.csv", size: 3, files: map[string]string{"a.csv": "aaa"}},
+	} {
+		t.Run(test.url, func(t *testing.T) {
+			spec := model.StorageSpec{StorageSource: model.StorageSourceGCS, URL: test.url, Path: "/inputs"}
+			size, err := driver.GetVolumeSize(ctx, spec)
+			require.NoError(t, err)
+			require.Equal(t, test.size, size)
+
+			volume, err := driver.PrepareStorage(ctx, spec)
+			require.NoError(t, err)
+			require.Equal(t, "/inputs", volume.Target)
+
+			files := map[string]string{}
+			err = filepath.Walk(volume.Source, func(path string, info os.FileInfo, err error) error {
+				if err != nil || info.IsDir() {
+					return err
+				}
+				content, err := os.ReadFile(path)
+				relativePath, _ := filepath.Rel(volume.Source, path)
+				files[filepath.ToSlash(relativePath)] = string(content)
+				return err
+			})
+			require.NoError(t, err)
+			require.Equal(t, test.files, files)
+
+			require.NoError(t, driver.CleanupStorage(ctx, spec, volume))
+			require.NoDirExists(t, volume.Source)
+		})
+	}
+}
+
+func TestPrepareStorageErrors(t *testing.T) {
+	logger.ConfigureTestLogging(t)
+	ctx := context.Background()
+	driver := newTestStorage(t, map[string]string{"data/../../escape": "x"})
+
+	_, err := driver.PrepareStorage(ctx, model.StorageSpec{URL: "test://bucket/missing"})
+	require.ErrorContains(t, err, "no objects found")
+
+	_, err = driver.PrepareStorage(ctx, model.StorageSpec{URL: "test://bucket/data"})
+	require.ErrorContains(t, err, "outside of the volume")
+}
@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/c2h5oh/datasize"
+)
+
+// DiskBudget is how much the storages preparing the inputs of a job may write
+// to the disk of the node beyond what they fetch, e.g. when decompressing an
+// input, so that a small input can't fill the disk past what was reserved for
+// the job. A zero limit means there is no budget to stay within.
+type DiskBudget struct {
+	limit uint64
+	mutex sync.Mutex
+	used  uint64
+}
+
+func NewDiskBudget(limit uint64) *DiskBudget {
+	return &DiskBudget{limit: limit}
+}
+
+// Use takes n bytes from the budget, or returns an error and takes nothing if
+// that would exceed it.
+func (b *DiskBudget) Use(n uint64) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.limit > 0 && b.used+n > b.limit {
+		return fmt.Errorf("inputs take up more than the %s of disk reserved for the job",
+			datasize.ByteSize(b.limit).HumanReadable())
+	}
+	b.used += n
+	return nil
+}
+
+// Release gives back n bytes to the budget, once what used them is removed.
+func (b *DiskBudget) Release(n uint64) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if n > b.used {
+		n = b.used
+	}
+	b.used -= n
+}
+
+// Writer returns a writer that takes what is written to w from the budget, and
+// fails without writing once the budget is exceeded.
+func (b *DiskBudget) Writer(w io.Writer) io.Writer {
+	return &budgetWriter{budget: b, writer: w}
+}
+
+type budgetWriter struct {
+	budget *DiskBudget
+	writer io.Writer
+}
+
+func (w *budgetWriter) Write(p []byte) (int, error) {
+	if err := w.budget.Use(uint64(len(p))); err != nil {
+		return 0, err
+	}
+	n, err := w.writer.Write(p)
+	w.budget.Release(uint64(len(p) - n))
+	return n, err
+}
+
+type diskBudgetKey struct{}
+
+// ContextWithDiskBudget returns a context whose storages prepare inputs within
+// the budget.
+func ContextWithDiskBudget(ctx context.Context, budget *DiskBudget) context.Context {
+	return context.WithValue(ctx, diskBudgetKey{}, budget)
+}
+
+// DiskBudgetFromContext returns the budget of the context, or one without a
+// limit if it has none.
+func DiskBudgetFromContext(ctx context.Context) *DiskBudget {
+	if budget, ok := ctx.Value(diskBudgetKey{}).(*DiskBudget); ok {
+		return budget
+	}
+	return NewDiskBudget(0)
+}
@@ -0,0 +1,247 @@
+package filecoinretrieval
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/filecoin-project/bacalhau/pkg/config"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/publisher/filecoin_lotus/api"
+	"github.com/filecoin-project/bacalhau/pkg/storage"
+	"github.com/filecoin-project/bacalhau/pkg/system"
+	big2 "github.com/filecoin-project/go-state-types/big"
+	"github.com/ipfs/go-cid"
+	"github.com/rs/zerolog/log"
+)
+
+// a storage driver that retrieves the payload CID of a Filecoin deal from
+// the storage providers that store it, through a Lotus node, and exports
+// it to a local directory in preparation for a job to run
+
+type StorageConfig struct {
+	// Location of the Lotus configuration directory - either $LOTUS_PATH or ~/.lotus
+	PathDir string
+	// Directory to export retrieved data to, which the Lotus node must be
+	// able to write to - defaults to the storage path
+	DownloadDir string
+	// The most to pay for retrieving a CID, in attoFIL - only free
+	// retrievals are made if empty
+	MaxPrice string
+}
+
+type StorageProvider struct {
+	LocalDir string
+	client   api.Client
+	maxPrice big2.Int
+}
+
+func NewStorage(ctx context.Context, cm *system.CleanupManager, storageConfig StorageConfig) (*StorageProvider, error) {
+	if storageConfig.PathDir == "" {
+		return nil, errors.New("PathDir is required")
+	}
+
+	client, err := api.NewClientFromConfigDir(ctx, storageConfig.PathDir)
+	if err != nil {
+		return nil, err
+	}
+	cm.RegisterCallback(client.Close)
+
+	return newStorageWithClient(cm, storageConfig, client)
+}
+
+func newStorageWithClient(cm *system.CleanupManager, storageConfig StorageConfig, client api.Client) (*StorageProvider, error) {
+	maxPrice := big2.Zero()
+	if storageConfig.MaxPrice != "" {
+		var err error
+		maxPrice, err = big2.FromString(storageConfig.MaxPrice)
+		if err != nil {
+			return nil, fmt.Errorf("invalid MaxPrice '%s': %w", storageConfig.MaxPrice, err)
+		}
+	}
+
+	downloadDir := storageConfig.DownloadDir
+	if downloadDir == "" {
+		downloadDir = config.GetStoragePath()
+	}
+	dir, err := os.MkdirTemp(downloadDir, "bacalhau-filecoin-retrieval")
+	if err != nil {
+		return nil, err
+	}
+	cm.RegisterCallback(func() error {
+		if err := os.RemoveAll(dir); err != nil {
+			return fmt.Errorf("unable to clean up Filecoin retrieval storage directory: %w", err)
+		}
+		return nil
+	})
+
+	log.Debug().Msgf("Filecoin retrieval driver created with directory: %s", dir)
+	return &StorageProvider{
+		LocalDir: dir,
+		client:   client,
+		maxPrice: maxPrice,
+	}, nil
+}
+
+func (driver *StorageProvider) IsInstalled(ctx context.Context) (bool, error) {
+	ctx, span := system.GetTracer().Start(ctx, "pkg/storage/filecoin_retrieval.IsInstalled")
+	defer span.End()
+
+	if _, err := driver.client.Version(ctx); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// HasStorageLocally returns whether the CID has already been retrieved.
+func (driver *StorageProvider) HasStorageLocally(ctx context.Context, volume model.StorageSpec) (bool, error) {
+	return system.PathExists(driver.getPathToVolume(volume))
+}
+
+func (driver *StorageProvider) GetVolumeSize(ctx context.Context, volume model.StorageSpec) (uint64, error) {
+	ctx, span := system.GetTracer().Start(ctx, "pkg/storage/filecoin_retrieval.GetVolumeSize")
+	defer span.End()
+
+	root, err := cid.Decode(volume.CID)
+	if err != nil {
+		return 0, err
+	}
+	offer, err := driver.findOffer(ctx, root)
+	if err != nil {
+		return 0, err
+	}
+	return offer.Size, nil
+}
+
+func (driver *StorageProvider) PrepareStorage(
+	ctx context.Context,
+	storageSpec model.StorageSpec,
+) (storage.StorageVolume, error) {
+	ctx, span := system.GetTracer().Start(ctx, "pkg/storage/filecoin_retrieval.PrepareStorage")
+	defer span.End()
+
+	outputPath := driver.getPathToVolume(storageSpec)
+	ok, err := system.PathExists(outputPath)
+	if err != nil {
+		return storage.StorageVolume{}, err
+	}
+	if !ok {
+		ctx, cancel := context.WithTimeout(ctx, config.GetDownloadCidRequestTimeout())
+		defer cancel()
+		if err = driver.retrieve(ctx, storageSpec.CID, outputPath); err != nil {
+			return storage.StorageVolume{}, err
+		}
+	}
+
+	return storage.StorageVolume{
+		Type:   storage.StorageVolumeConnectorBind,
+		Source: outputPath,
+		Target: storageSpec.Path,
+	}, nil
+}
+
+// retrieve makes a retrieval deal for the CID with the storage provider with
+// the cheapest offer, and exports the retrieved data to outputPath.
+func (driver *StorageProvider) retrieve(ctx context.Context, cidString, outputPath string) error {
+	root, err := cid.Decode(cidString)
+	if err != nil {
+		return err
+	}
+	offer, err := driver.findOffer(ctx, root)
+	if err != nil {
+		return err
+	}
+	wallet, err := driver.client.WalletDefaultAddress(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to get default wallet address: %w", err)
+	}
+
+	log.Ctx(ctx).Debug().Msgf("Retrieving %s from storage provider %s", cidString, offer.Miner)
+	res, err := driver.client.ClientRetrieve(ctx, offer.Order(wallet))
+	if err != nil {
+		return fmt.Errorf("unable to retrieve %s from %s: %w", cidString, offer.Miner, err)
+	}
+	if err = driver.client.ClientRetrieveWait(ctx, res.DealID); err != nil {
+		return fmt.Errorf("retrieval deal %d for %s failed: %w", res.DealID, cidString, err)
+	}
+
+	// export next to the output path and rename it once finished, so that a
+	// partial export isn't mistaken for a retrieved CID
+	exportPath := outputPath + ".export"
+	defer os.RemoveAll(exportPath)
+	err = driver.client.ClientExport(ctx, api.ExportRef{Root: root, DealID: res.DealID}, api.FileRef{Path: exportPath})
+	if err != nil {
+		return fmt.Errorf("unable to export %s: %w", cidString, err)
+	}
+	return os.Rename(exportPath, outputPath)
+}
+
+// findOffer returns the cheapest offer to retrieve the CID that costs no
+// more than the maximum price.
+func (driver *StorageProvider) findOffer(ctx context.Context, root cid.Cid) (api.QueryOffer, error) {
+	offers, err := driver.client.ClientFindData(ctx, root, nil)
+	if err != nil {
+		return api.QueryOffer{}, fmt.Errorf("unable to find storage providers for %s: %w", root, err)
+	}
+
+	var best *api.QueryOffer
+	for i := range offers {
+		offer := &offers[i]
+		if offer.Err != "" {
+			log.Ctx(ctx).Debug().Msgf("Ignoring retrieval offer for %s from %s: %s", root, offer.Miner, offer.Err)
+			continue
+		}
+		if big2.Cmp(offerPrice(offer), driver.maxPrice) > 0 {
+			continue
+		}
+		if best == nil || big2.Cmp(offerPrice(offer), offerPrice(best)) < 0 {
+			best = offer
+		}
+	}
+	if best == nil {
+		return api.QueryOffer{}, fmt.Errorf(
+			"no storage provider offers to retrieve %s for at most %s attoFIL (%d offers)", root, driver.maxPrice, len(offers))
+	}
+	return *best, nil
+}
+
+func offerPrice(offer *api.QueryOffer) big2.Int {
+	return big2.Add(orZero(offer.MinPrice), orZero(offer.UnsealPrice))
+}
+
+func orZero(i big2.Int) big2.Int {
+	if i.Int == nil {
+		return big2.Zero()
+	}
+	return i
+}
+
+func (driver *StorageProvider) CleanupStorage(
+	ctx context.Context,
+	storageSpec model.StorageSpec,
+	volume storage.StorageVolume,
+) error {
+	return os.RemoveAll(driver.getPathToVolume(storageSpec))
+}
+
+func (driver *StorageProvider) Upload(
+	ctx context.Context,
+	localPath string,
+) (model.StorageSpec, error) {
+	return model.StorageSpec{}, fmt.Errorf("not implemented")
+}
+
+func (driver *StorageProvider) Explode(ctx context.Context, spec model.StorageSpec) ([]model.StorageSpec, error) {
+	return []model.StorageSpec{
+		spec,
+	}, nil
+}
+
+func (driver *StorageProvider) getPathToVolume(volume model.StorageSpec) string {
+	return filepath.Join(driver.LocalDir, volume.CID)
+}
+
+// Compile time interface check:
+var _ storage.Storage = (*StorageProvider)(nil)
@@ -0,0 +1,125 @@
+//go:build unit || !integration
+
+package filecoinretrieval
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/filecoin-project/bacalhau/pkg/logger"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/publisher/filecoin_lotus/api"
+	"github.com/filecoin-project/bacalhau/pkg/publisher/filecoin_lotus/api/retrievalmarket"
+	"github.com/filecoin-project/bacalhau/pkg/system"
+	"github.com/filecoin-project/go-address"
+	big2 "github.com/filecoin-project/go-state-types/big"
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/require"
+)
+
+const testCID = "QmdZQ7ZbhnvWY1J12XYKGHApJ6aufKyLNSvf8jZBrBaAVL"
+
+// fakeClient implements the retrieval calls of the Lotus API.
+type fakeClient struct {
+	api.Client
+	offers    []api.QueryOffer
+	retrieved []api.RetrievalOrder
+}
+
+func (f *fakeClient) ClientFindData(context.Context, cid.Cid, *cid.Cid) ([]api.QueryOffer, error) {
+	return f.offers, nil
+}
+
+func (f *fakeClient) WalletDefaultAddress(context.Context) (address.Address, error) {
+	return address.NewIDAddress(1)
+}
+
+func (f *fakeClient) ClientRetrieve(_ context.Context, order api.RetrievalOrder) (*api.RestrievalRes, error) {
+	f.retrieved = append(f.retrieved, order)
+	return &api.RestrievalRes{DealID: retrievalmarket.DealID(len(f.retrieved))}, nil
+}
+
+func (f *fakeClient) ClientRetrieveWait(context.Context, retrievalmarket.DealID) error {
+	return nil
+}
+
+func (f *fakeClient) ClientExport(_ context.Context, _ api.ExportRef, ref api.FileRef) error {
+	return os.WriteFile(ref.Path, []byte("retrieved"), 0600)
+}
+
+func newOffer(t *testing.T, miner uint64, price int64, size uint64) api.QueryOffer {
+	root, err := cid.Decode(testCID)
+	require.NoError(t, err)
+	minerAddress, err := address.NewIDAddress(miner)
+	require.NoError(t, err)
+	return api.QueryOffer{
+		Root:        root,
+		Size:        size,
+		MinPrice:    big2.NewInt(price),
+		UnsealPrice: big2.Zero(),
+		Miner:       minerAddress,
+	}
+}
+
+func newTestStorage(t *testing.T, maxPrice string, client api.Client) *StorageProvider {
+	cm := system.NewCleanupManager()
+	t.Cleanup(cm.Cleanup)
+	driver, err := newStorageWithClient(cm, StorageConfig{DownloadDir: t.TempDir(), MaxPrice: maxPrice}, client)
+	require.NoError(t, err)
+	return driver
+}
+
+func TestPrepareStorage(t *testing.T) {
+	logger.ConfigureTestLogging(t)
+	ctx := context.Background()
+	failed := newOffer(t, 1, 0, 1)
+	failed.Err = "not found"
+	client := &fakeClient{offers: []api.QueryOffer{failed, newOffer(t, 2, 10, 2), newOffer(t, 3, 5, 3)}}
+	driver := newTestStorage(t, "10", client)
+	spec := model.StorageSpec{StorageSource: model.StorageSourceFilecoin, CID: testCID, Path: "/inputs"}
+
+	// the cheapest offer that worked is taken
+	size, err := driver.GetVolumeSize(ctx, spec)
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), size)
+
+	local, err := driver.HasStorageLocally(ctx, spec)
+	require.NoError(t, err)
+	require.False(t, local)
+
+	volume, err := driver.PrepareStorage(ctx, spec)
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(driver.LocalDir, testCID), volume.Source)
+	require.Equal(t, "/inputs", volume.Target)
+	require.Len(t, client.retrieved, 1)
+	require.Equal(t, client.offers[2].Miner, client.retrieved[0].Miner)
+
+	// retrieved CIDs aren't retrieved again
+	local, err = driver.HasStorageLocally(ctx, spec)
+	require.NoError(t, err)
+	require.True(t, local)
+	_, err = driver.PrepareStorage(ctx, spec)
+	require.NoError(t, err)
+	require.Len(t, client.retrieved, 1)
+
+	require.NoError(t, driver.CleanupStorage(ctx, spec, volume))
+	require.NoFileExists(t, volume.Source)
+}
+
+func TestNoOfferWithinMaxPrice(t *testing.T) {
+	logger.ConfigureTestLogging(t)
+	ctx := context.Background()
+	client := &fakeClient{offers: []api.QueryOffer{newOffer(t, 1, 5, 1)}}
+	spec := model.StorageSpec{StorageSource: model.StorageSourceFilecoin, CID: testCID}
+
+	// only free retrievals are made without a maximum price
+	_, err := newTestStorage(t, "", client).PrepareStorage(ctx, spec)
+	require.ErrorContains(t, err, "no storage provider offers")
+	require.Empty(t, client.retrieved)
+
+	_, err = newTestStorage(t, "5", client).PrepareStorage(ctx, spec)
+	require.NoError(t, err)
+	require.Len(t, client.retrieved, 1)
+}
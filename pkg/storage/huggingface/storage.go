@@ -0,0 +1,547 @@
+package huggingface
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/c2h5oh/datasize"
+	"github.com/filecoin-project/bacalhau/pkg/compute/secrets"
+	"github.com/filecoin-project/bacalhau/pkg/config"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/storage"
+	"github.com/filecoin-project/bacalhau/pkg/system"
+	"github.com/rs/zerolog/log"
+)
+
+// a storage driver that downloads snapshots of models from the Hugging Face
+// Hub, which jobs refer to with URLs of the form hf://<org>/<model>@<revision>
+// - snapshots are kept in a cache that outlives jobs, so a model is only
+// downloaded once per revision however many jobs use it
+// - the cache is kept within a size by evicting the least recently used
+// snapshots that no job is using
+
+const (
+	DefaultEndpoint  = "https://huggingface.co"
+	DefaultCacheSize = uint64(50 * datasize.GB)
+	defaultRevision  = "main"
+	urlScheme        = "hf://"
+)
+
+var commitHashRegex = regexp.MustCompile("^[0-9a-f]{40}$")
+
+type StorageConfig struct {
+	// The Hugging Face Hub to download from, defaults to DefaultEndpoint.
+	Endpoint string
+	// An access token for private and gated models, defaults to $HF_TOKEN.
+	Token string
+	// Where to fetch the access token from instead, so that it can be rotated.
+	TokenSecret *secrets.Credential
+	// Where snapshots are cached, defaults to a directory in the storage path.
+	CacheDir string
+	// The most the cached snapshots may take up, defaults to DefaultCacheSize.
+	CacheSize uint64
+}
+
+type StorageProvider struct {
+	CacheDir   string
+	config     StorageConfig
+	httpClient *http.Client
+
+	// downloads of the same snapshot wait for each other
+	downloadsMutex sync.Mutex
+	downloads      map[string]*sync.Mutex
+
+	// snapshots jobs are using aren't evicted, and the room downloads in
+	// progress will take up is reserved
+	cacheMutex sync.Mutex
+	inUse      map[string]int
+	reserved   uint64
+}
+
+func NewStorage(storageConfig StorageConfig) (*StorageProvider, error) {
+	if storageConfig.Endpoint == "" {
+		storageConfig.Endpoint = DefaultEndpoint
+	}
+	storageConfig.Endpoint = strings.TrimSuffix(storageConfig.Endpoint, "/")
+	if storageConfig.Token == "" {
+		storageConfig.Token = os.Getenv("HF_TOKEN")
+	}
+	if storageConfig.CacheDir == "" {
+		storageConfig.CacheDir = filepath.Join(config.GetStoragePath(), "bacalhau-huggingface")
+	}
+	if storageConfig.CacheSize == 0 {
+		storageConfig.CacheSize = DefaultCacheSize
+	}
+	if err := os.MkdirAll(storageConfig.CacheDir, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("unable to create hugging face cache directory: %w", err)
+	}
+
+	provider := &StorageProvider{
+		CacheDir:   storageConfig.CacheDir,
+		config:     storageConfig,
+		httpClient: &http.Client{},
+		downloads:  map[string]*sync.Mutex{},
+		inUse:      map[string]int{},
+	}
+	// downloads the node was stopped in the middle of are never finished
+	if err := os.RemoveAll(provider.downloadsDir()); err != nil {
+		return nil, fmt.Errorf("unable to remove unfinished hugging face downloads: %w", err)
+	}
+
+	log.Debug().Msgf("Hugging Face storage driver created with cache dir: %s", storageConfig.CacheDir)
+	return provider, nil
+}
+
+func (sp *StorageProvider) IsInstalled(context.Context) (bool, error) {
+	return true, nil
+}
+
+// HasStorageLocally returns whether the snapshot the revision last resolved
+// to is cached, without asking the Hub whether the revision has moved since.
+func (sp *StorageProvider) HasStorageLocally(_ context.Context, spec model.StorageSpec) (bool, error) {
+	repo, revision, err := ParseModelURL(spec.URL)
+	if err != nil {
+		return false, err
+	}
+	commit, ok := sp.cachedCommit(repo, revision)
+	if !ok {
+		return false, nil
+	}
+	_, err = os.Stat(sp.snapshotDir(repo, commit))
+	return err == nil, nil
+}
+
+func (sp *StorageProvider) GetVolumeSize(ctx context.Context, spec model.StorageSpec) (uint64, error) {
+	repo, revision, err := ParseModelURL(spec.URL)
+	if err != nil {
+		return 0, err
+	}
+	info, err := sp.modelInfo(ctx, repo, revision)
+	if err != nil {
+		return 0, err
+	}
+	return info.size(), nil
+}
+
+func (sp *StorageProvider) PrepareStorage(ctx context.Context, spec model.StorageSpec) (storage.StorageVolume, error) {
+	ctx, span := system.GetTracer().Start(ctx, "pkg/storage/huggingface.PrepareStorage")
+	defer span.End()
+
+	repo, revision, err := ParseModelURL(spec.URL)
+	if err != nil {
+		return storage.StorageVolume{}, err
+	}
+	snapshotDir, err := sp.snapshot(ctx, repo, revision)
+	if err != nil {
+		return storage.StorageVolume{}, err
+	}
+	return storage.StorageVolume{
+		Type:   storage.StorageVolumeConnectorBind,
+		Source: snapshotDir,
+		Target: spec.Path,
+	}, nil
+}
+
+// CleanupStorage keeps the snapshot cached for the next job that uses it, but
+// lets it be evicted once no other job is using it.
+func (sp *StorageProvider) CleanupStorage(_ context.Context, _ model.StorageSpec, volume storage.StorageVolume) error {
+	sp.release(volume.Source)
+	return nil
+}
+
+func (sp *StorageProvider) Upload(context.Context, string) (model.StorageSpec, error) {
+	return model.StorageSpec{}, fmt.Errorf("not implemented")
+}
+
+func (sp *StorageProvider) Explode(_ context.Context, spec model.StorageSpec) ([]model.StorageSpec, error) {
+	return []model.StorageSpec{
+		spec,
+	}, nil
+}
+
+// ParseModelURL returns the model repository and revision of a
+// hf://<org>/<model>@<revision> URL. The revision defaults to main.
+func ParseModelURL(modelURL string) (repo string, revision string, err error) {
+	if !strings.HasPrefix(modelURL, urlScheme) {
+		return "", "", fmt.Errorf("unsupported Hugging Face url '%s', expected hf://<org>/<model>@<revision>", modelURL)
+	}
+	repo, revision, found := strings.Cut(strings.TrimPrefix(modelURL, urlScheme), "@")
+	if !found {
+		revision = defaultRevision
+	}
+	repo = strings.Trim(repo, "/")
+	if repo == "" || revision == "" || strings.Count(repo, "/") > 1 || strings.Contains(repo, "..") {
+		return "", "", fmt.Errorf("invalid Hugging Face url '%s', expected hf://<org>/<model>@<revision>", modelURL)
+	}
+	return repo, revision, nil
+}
+
+type modelInfo struct {
+	// the commit the revision resolved to
+	SHA      string `json:"sha"`
+	Siblings []struct {
+		Filename string `json:"rfilename"`
+		Size     uint64 `json:"size"`
+	} `json:"siblings"`
+}
+
+func (info modelInfo) size() uint64 {
+	var size uint64
+	for _, file := range info.Siblings {
+		size += file.Size
+	}
+	return size
+}
+
+func (sp *StorageProvider) modelInfo(ctx context.Context, repo, revision string) (modelInfo, error) {
+	u := fmt.Sprintf("%s/api/models/%s/revision/%s?blobs=true", sp.config.Endpoint, repo, url.PathEscape(revision))
+	res, err := sp.get(ctx, u)
+	if err != nil {
+		return modelInfo{}, fmt.Errorf("unable to get model %s@%s: %w", repo, revision, err)
+	}
+	defer res.Body.Close()
+
+	var info modelInfo
+	if err = json.NewDecoder(res.Body).Decode(&info); err != nil {
+		return modelInfo{}, fmt.Errorf("unable to parse model %s@%s: %w", repo, revision, err)
+	}
+	if !commitHashRegex.MatchString(info.SHA) {
+		return modelInfo{}, fmt.Errorf("model %s@%s resolved to invalid commit '%s'", repo, revision, info.SHA)
+	}
+	return info, nil
+}
+
+// snapshot returns the directory holding the snapshot of the revision of
+// the model, downloading it if it isn't cached yet. The snapshot is in use
+// until it is released.
+func (sp *StorageProvider) snapshot(ctx context.Context, repo, revision string) (string, error) {
+	info, err := sp.modelInfo(ctx, repo, revision)
+	if err != nil {
+		// without the Hub we can still use the snapshot we resolved last time
+		if commit, ok := sp.cachedCommit(repo, revision); ok && sp.acquire(sp.snapshotDir(repo, commit)) {
+			log.Ctx(ctx).Warn().Err(err).Msgf("Using cached snapshot %s of model %s@%s", commit, repo, revision)
+			return sp.snapshotDir(repo, commit), nil
+		}
+		return "", err
+	}
+
+	unlock := sp.lockDownload(repo, info.SHA)
+	defer unlock()
+
+	snapshotDir := sp.snapshotDir(repo, info.SHA)
+	if !sp.acquire(snapshotDir) {
+		log.Ctx(ctx).Info().Msgf("Downloading model %s@%s (%s)", repo, revision, info.SHA)
+		if err = sp.download(ctx, repo, info, snapshotDir); err != nil {
+			return "", err
+		}
+	}
+
+	if err = sp.setCachedCommit(repo, revision, info.SHA); err != nil {
+		sp.release(snapshotDir)
+		return "", err
+	}
+	return snapshotDir, nil
+}
+
+// download fetches every file of the snapshot into a temporary directory,
+// which is only moved into the cache once it is complete. The files are
+// written within the disk budget of the job, and room is made for them in
+// the cache first.
+func (sp *StorageProvider) download(ctx context.Context, repo string, info modelInfo, snapshotDir string) error {
+	size := info.size()
+	if err := sp.reserve(ctx, repo, size); err != nil {
+		return err
+	}
+	defer sp.unreserve(size)
+
+	if err := os.MkdirAll(filepath.Dir(snapshotDir), os.ModePerm); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(sp.downloadsDir(), os.ModePerm); err != nil {
+		return err
+	}
+	tempDir, err := os.MkdirTemp(sp.downloadsDir(), "download-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tempDir)
+
+	// what was written is given back to the budget if the download is removed
+	budget := storage.DiskBudgetFromContext(ctx)
+	var written uint64
+	for _, file := range info.Siblings {
+		outputPath := filepath.Join(tempDir, filepath.FromSlash(file.Filename))
+		if !strings.HasPrefix(outputPath, tempDir+string(filepath.Separator)) {
+			budget.Release(written)
+			return fmt.Errorf("model %s has file '%s' outside of the snapshot", repo, file.Filename)
+		}
+		n, downloadErr := sp.downloadFile(ctx, budget, repo, info.SHA, file.Filename, outputPath)
+		written += n
+		if downloadErr != nil {
+			budget.Release(written)
+			return fmt.Errorf("unable to download '%s' of model %s: %w", file.Filename, repo, downloadErr)
+		}
+	}
+	if err = sp.add(tempDir, snapshotDir); err != nil {
+		budget.Release(written)
+		return err
+	}
+	return nil
+}
+
+// downloadFile returns how much of the file was written, even if it fails.
+func (sp *StorageProvider) downloadFile(
+	ctx context.Context, budget *storage.DiskBudget, repo, commit, filename, outputPath string,
+) (uint64, error) {
+	if err := os.MkdirAll(filepath.Dir(outputPath), os.ModePerm); err != nil {
+		return 0, err
+	}
+	u := fmt.Sprintf("%s/%s/resolve/%s/%s", sp.config.Endpoint, repo, commit, (&url.URL{Path: filename}).EscapedPath())
+	res, err := sp.get(ctx, u)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	file, err := os.Create(outputPath)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(budget.Writer(file), res.Body)
+	if err != nil {
+		_ = file.Close()
+		return uint64(n), err
+	}
+	return uint64(n), file.Close()
+}
+
+type cachedSnapshot struct {
+	dir      string
+	size     uint64
+	lastUsed time.Time
+}
+
+// reserve makes room in the cache for a download of size bytes, evicting
+// the least recently used snapshots that no job is using.
+func (sp *StorageProvider) reserve(ctx context.Context, repo string, size uint64) error {
+	sp.cacheMutex.Lock()
+	defer sp.cacheMutex.Unlock()
+
+	limit := sp.config.CacheSize
+	if size > limit {
+		return fmt.Errorf("model %s takes up %s, more than the %s hugging face cache", repo,
+			datasize.ByteSize(size).HumanReadable(), datasize.ByteSize(limit).HumanReadable())
+	}
+	snapshots, err := sp.cachedSnapshots()
+	if err != nil {
+		return err
+	}
+	used := sp.reserved
+	for _, snapshot := range snapshots {
+		used += snapshot.size
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].lastUsed.Before(snapshots[j].lastUsed)
+	})
+	for _, snapshot := range snapshots {
+		if used+size <= limit {
+			break
+		}
+		if sp.inUse[snapshot.dir] > 0 {
+			continue
+		}
+		log.Ctx(ctx).Info().Msgf("Evicting %s from the hugging face cache", snapshot.dir)
+		if err = os.RemoveAll(snapshot.dir); err != nil {
+			return fmt.Errorf("unable to evict %s from the hugging face cache: %w", snapshot.dir, err)
+		}
+		used -= snapshot.size
+	}
+	if used+size > limit {
+		return fmt.Errorf("no room for model %s in the %s hugging face cache, the models jobs are using take it up",
+			repo, datasize.ByteSize(limit).HumanReadable())
+	}
+	sp.reserved += size
+	return nil
+}
+
+func (sp *StorageProvider) unreserve(size uint64) {
+	sp.cacheMutex.Lock()
+	defer sp.cacheMutex.Unlock()
+	sp.reserved -= size
+}
+
+// cachedSnapshots returns the snapshots in the cache, which were last used
+// when their directory was last modified.
+func (sp *StorageProvider) cachedSnapshots() ([]cachedSnapshot, error) {
+	dirs, err := filepath.Glob(filepath.Join(sp.CacheDir, "models--*", "snapshots", "*"))
+	if err != nil {
+		return nil, err
+	}
+	snapshots := make([]cachedSnapshot, 0, len(dirs))
+	for _, dir := range dirs {
+		// downloads in progress are reserved rather than cached
+		if !commitHashRegex.MatchString(filepath.Base(dir)) {
+			continue
+		}
+		info, statErr := os.Stat(dir)
+		if statErr != nil {
+			return nil, statErr
+		}
+		snapshot := cachedSnapshot{dir: dir, lastUsed: info.ModTime()}
+		walkErr := filepath.WalkDir(dir, func(_ string, entry os.DirEntry, err error) error {
+			if err != nil || !entry.Type().IsRegular() {
+				return err
+			}
+			fileInfo, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			snapshot.size += uint64(fileInfo.Size())
+			return nil
+		})
+		if walkErr != nil {
+			return nil, walkErr
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots, nil
+}
+
+// acquire marks the snapshot as in use until it is released, and as the most
+// recently used, or returns false if it isn't cached.
+func (sp *StorageProvider) acquire(snapshotDir string) bool {
+	sp.cacheMutex.Lock()
+	defer sp.cacheMutex.Unlock()
+	if _, err := os.Stat(snapshotDir); err != nil {
+		return false
+	}
+	sp.use(snapshotDir)
+	return true
+}
+
+// add moves a downloaded snapshot into the cache, in use until it is released.
+func (sp *StorageProvider) add(tempDir, snapshotDir string) error {
+	sp.cacheMutex.Lock()
+	defer sp.cacheMutex.Unlock()
+	if err := os.Rename(tempDir, snapshotDir); err != nil {
+		return err
+	}
+	sp.use(snapshotDir)
+	return nil
+}
+
+func (sp *StorageProvider) use(snapshotDir string) {
+	now := time.Now()
+	if err := os.Chtimes(snapshotDir, now, now); err != nil {
+		log.Warn().Err(err).Msgf("Unable to mark %s as recently used", snapshotDir)
+	}
+	sp.inUse[snapshotDir]++
+}
+
+func (sp *StorageProvider) release(snapshotDir string) {
+	sp.cacheMutex.Lock()
+	defer sp.cacheMutex.Unlock()
+	if sp.inUse[snapshotDir] <= 1 {
+		delete(sp.inUse, snapshotDir)
+	} else {
+		sp.inUse[snapshotDir]--
+	}
+}
+
+func (sp *StorageProvider) get(ctx context.Context, u string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	token := sp.config.Token
+	if sp.config.TokenSecret != nil {
+		if token, err = sp.config.TokenSecret.Get(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	res, err := sp.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		defer res.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return nil, fmt.Errorf("hugging face hub returned %s: %s", res.Status, strings.TrimSpace(string(body)))
+	}
+	return res, nil
+}
+
+// the cache is laid out like the Hugging Face Hub's own cache:
+// models--<org>--<model>/snapshots/<commit> holds the files of each
+// snapshot, and models--<org>--<model>/refs/<revision> the commit each
+// revision last resolved to
+func (sp *StorageProvider) repoDir(repo string) string {
+	return filepath.Join(sp.CacheDir, "models--"+strings.ReplaceAll(repo, "/", "--"))
+}
+
+// downloadsDir holds the snapshots being downloaded, out of the way of the
+// cached ones.
+func (sp *StorageProvider) downloadsDir() string {
+	return filepath.Join(sp.CacheDir, ".downloads")
+}
+
+func (sp *StorageProvider) snapshotDir(repo, commit string) string {
+	return filepath.Join(sp.repoDir(repo), "snapshots", commit)
+}
+
+func (sp *StorageProvider) refPath(repo, revision string) string {
+	return filepath.Join(sp.repoDir(repo), "refs", url.PathEscape(revision))
+}
+
+func (sp *StorageProvider) cachedCommit(repo, revision string) (string, bool) {
+	if commitHashRegex.MatchString(revision) {
+		return revision, true
+	}
+	commit, err := os.ReadFile(sp.refPath(repo, revision))
+	if err != nil || !commitHashRegex.Match(commit) {
+		return "", false
+	}
+	return string(commit), true
+}
+
+func (sp *StorageProvider) setCachedCommit(repo, revision, commit string) error {
+	if revision == commit {
+		return nil
+	}
+	refPath := sp.refPath(repo, revision)
+	if err := os.MkdirAll(filepath.Dir(refPath), os.ModePerm); err != nil {
+		return err
+	}
+	return os.WriteFile(refPath, []byte(commit), 0600)
+}
+
+func (sp *StorageProvider) lockDownload(repo, commit string) func() {
+	key := repo + "@" + commit
+	sp.downloadsMutex.Lock()
+	mutex, ok := sp.downloads[key]
+	if !ok {
+		mutex = &sync.Mutex{}
+		sp.downloads[key] = mutex
+	}
+	sp.downloadsMutex.Unlock()
+
+	mutex.Lock()
+	return mutex.Unlock
+}
+
+// Compile time interface check:
+var _ storage.Storage = (*StorageProvider)(nil)
@@ -0,0 +1,251 @@
+//go:build unit || !integration
+
+package huggingface
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/filecoin-project/bacalhau/pkg/logger"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/storage"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testCommit  = "0123456789abcdef0123456789abcdef01234567"
+	otherCommit = "89abcdef0123456789abcdef0123456789abcdef"
+	thirdCommit = "fedcba9876543210fedcba9876543210fedcba98"
+)
+
+func TestParseModelURL(t *testing.T) {
+	for modelURL, expected := range map[string][]string{
+		"hf://org/model":            {"org/model", "main"},
+		"hf://org/model@v1.0":       {"org/model", "v1.0"},
+		"hf://gpt2@" + testCommit:   {"gpt2", testCommit},
+		"hf://org/model/@refs/pr/1": {"org/model", "refs/pr/1"},
+	} {
+		repo, revision, err := ParseModelURL(modelURL)
+		require.NoError(t, err, modelURL)
+		require.Equal(t, expected, []string{repo, revision}, modelURL)
+	}
+
+	for _, modelURL := range []string{"https://huggingface.co/org/model", "hf://", "hf://org/model@", "hf://a/b/c", "hf://../model"} {
+		_, _, err := ParseModelURL(modelURL)
+		require.Error(t, err, modelURL)
+	}
+}
+
+// fakeHub serves the models org/model, org/other and org/third, each with a config file
+// and a weights file in a subdirectory, and counts the files downloaded. If
+// set, beforeFile is called before each file is served.
+func fakeHub(
+	t *testing.T, available *atomic.Bool, downloads *atomic.Int32, beforeFile func(repo, filename string),
+) *httptest.Server {
+	commits := map[string]string{"org/model": testCommit, "org/other": otherCommit, "org/third": thirdCommit}
+	return httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if !available.Load() {
+			res.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		require.Equal(t, "Bearer token", req.Header.Get("Authorization"))
+		for repo, commit := range commits {
+			switch {
+			case req.URL.Path == "/api/models/"+repo+"/revision/main":
+				fmt.Fprintf(res, `{"sha": "%s", "siblings": [`+
+					`{"rfilename": "config.json", "size": 2}, {"rfilename": "weights/model.bin", "size": 5}]}`, commit)
+				return
+			case strings.HasPrefix(req.URL.Path, "/"+repo+"/resolve/"+commit+"/"):
+				downloads.Add(1)
+				filename := strings.TrimPrefix(req.URL.Path, "/"+repo+"/resolve/"+commit+"/")
+				if beforeFile != nil {
+					beforeFile(repo, filename)
+				}
+				fmt.Fprint(res, map[string]string{"config.json": "{}", "weights/model.bin": "model"}[filename])
+				return
+			}
+		}
+		res.WriteHeader(http.StatusNotFound)
+	}))
+}
+
+func TestPrepareStorage(t *testing.T) {
+	logger.ConfigureTestLogging(t)
+	ctx := context.Background()
+	var available atomic.Bool
+	available.Store(true)
+	var downloads atomic.Int32
+	server := fakeHub(t, &available, &downloads, nil)
+	defer server.Close()
+
+	driver, err := NewStorage(StorageConfig{Endpoint: server.URL, Token: "token", CacheDir: t.TempDir()})
+	require.NoError(t, err)
+	spec := model.StorageSpec{StorageSource: model.StorageSourceHuggingFace, URL: "hf://org/model", Path: "/inputs"}
+
+	size, err := driver.GetVolumeSize(ctx, spec)
+	require.NoError(t, err)
+	require.Equal(t, uint64(7), size)
+
+	local, err := driver.HasStorageLocally(ctx, spec)
+	require.NoError(t, err)
+	require.False(t, local)
+
+	volume, err := driver.PrepareStorage(ctx, spec)
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(driver.CacheDir, "models--org--model", "snapshots", testCommit), volume.Source)
+	require.Equal(t, "/inputs", volume.Target)
+	content, err := os.ReadFile(filepath.Join(volume.Source, "weights", "model.bin"))
+	require.NoError(t, err)
+	require.Equal(t, "model", string(content))
+	require.Equal(t, int32(2), downloads.Load())
+
+	// the snapshot outlives the job, and isn't downloaded again
+	require.NoError(t, driver.CleanupStorage(ctx, spec, volume))
+	local, err = driver.HasStorageLocally(ctx, spec)
+	require.NoError(t, err)
+	require.True(t, local)
+	_, err = driver.PrepareStorage(ctx, spec)
+	require.NoError(t, err)
+	require.Equal(t, int32(2), downloads.Load())
+
+	// the cached snapshot is used when the hub can't be reached
+	available.Store(false)
+	offlineVolume, err := driver.PrepareStorage(ctx, spec)
+	require.NoError(t, err)
+	require.Equal(t, volume.Source, offlineVolume.Source)
+
+	_, err = driver.PrepareStorage(ctx, model.StorageSpec{URL: "hf://org/other"})
+	require.ErrorContains(t, err, "503")
+}
+
+func TestCacheEvictsLeastRecentlyUsedSnapshots(t *testing.T) {
+	logger.ConfigureTestLogging(t)
+	ctx := context.Background()
+	var available atomic.Bool
+	available.Store(true)
+	var downloads atomic.Int32
+	server := fakeHub(t, &available, &downloads, nil)
+	defer server.Close()
+
+	// room for two snapshots of 7 bytes, but not three
+	driver, err := NewStorage(StorageConfig{Endpoint: server.URL, Token: "token", CacheDir: t.TempDir(), CacheSize: 20})
+	require.NoError(t, err)
+	prepare := func(modelURL string) storage.StorageVolume {
+		volume, prepareErr := driver.PrepareStorage(ctx, model.StorageSpec{URL: modelURL})
+		require.NoError(t, prepareErr)
+		return volume
+	}
+	cached := func(volume storage.StorageVolume) bool {
+		_, statErr := os.Stat(volume.Source)
+		return statErr == nil
+	}
+
+	volume := prepare("hf://org/model")
+	otherVolume := prepare("hf://org/other")
+	require.NoError(t, driver.CleanupStorage(ctx, model.StorageSpec{}, otherVolume))
+	require.NoError(t, driver.CleanupStorage(ctx, model.StorageSpec{}, volume))
+
+	// using org/model again leaves org/other as the least recently used
+	require.NoError(t, driver.CleanupStorage(ctx, model.StorageSpec{}, prepare("hf://org/model")))
+	thirdVolume := prepare("hf://org/third")
+	require.True(t, cached(volume))
+	require.False(t, cached(otherVolume))
+	require.True(t, cached(thirdVolume))
+
+	// snapshots jobs are using aren't evicted
+	volume = prepare("hf://org/model")
+	_, err = driver.PrepareStorage(ctx, model.StorageSpec{URL: "hf://org/other"})
+	require.ErrorContains(t, err, "no room for model org/other")
+	require.True(t, cached(volume))
+	require.True(t, cached(thirdVolume))
+
+	// until they are cleaned up
+	require.NoError(t, driver.CleanupStorage(ctx, model.StorageSpec{}, volume))
+	require.NoError(t, driver.CleanupStorage(ctx, model.StorageSpec{}, volume))
+	otherVolume = prepare("hf://org/other")
+	require.False(t, cached(volume))
+	require.True(t, cached(otherVolume))
+
+	driver.config.CacheSize = 5
+	_, err = driver.PrepareStorage(ctx, model.StorageSpec{URL: "hf://org/model"})
+	require.ErrorContains(t, err, "takes up 7 B, more than the 5 B hugging face cache")
+}
+
+func TestDownloadsCountAgainstDiskBudget(t *testing.T) {
+	logger.ConfigureTestLogging(t)
+	var available atomic.Bool
+	available.Store(true)
+	var downloads atomic.Int32
+	server := fakeHub(t, &available, &downloads, nil)
+	defer server.Close()
+
+	driver, err := NewStorage(StorageConfig{Endpoint: server.URL, Token: "token", CacheDir: t.TempDir()})
+	require.NoError(t, err)
+	spec := model.StorageSpec{URL: "hf://org/model"}
+
+	budget := storage.NewDiskBudget(6)
+	_, err = driver.PrepareStorage(storage.ContextWithDiskBudget(context.Background(), budget), spec)
+	require.ErrorContains(t, err, "more than the 6 B of disk reserved for the job")
+	local, err := driver.HasStorageLocally(context.Background(), spec)
+	require.NoError(t, err)
+	require.False(t, local)
+	// the partial download was removed, so the budget is whole again
+	require.NoError(t, budget.Use(6))
+
+	budget = storage.NewDiskBudget(7)
+	_, err = driver.PrepareStorage(storage.ContextWithDiskBudget(context.Background(), budget), spec)
+	require.NoError(t, err)
+	require.Error(t, budget.Use(1))
+}
+
+func TestConcurrentDownloadsAreNotEvicted(t *testing.T) {
+	logger.ConfigureTestLogging(t)
+	ctx := context.Background()
+	var available atomic.Bool
+	available.Store(true)
+	var downloads atomic.Int32
+	// the weights of org/model are only served once org/other is being
+	// downloaded, when the config of org/model is already on disk
+	modelStarted := make(chan struct{})
+	otherStarted := make(chan struct{})
+	server := fakeHub(t, &available, &downloads, func(repo, filename string) {
+		switch {
+		case repo == "org/model" && filename == "weights/model.bin":
+			close(modelStarted)
+			<-otherStarted
+		case repo == "org/other" && filename == "config.json":
+			close(otherStarted)
+		}
+	})
+	defer server.Close()
+
+	// room for the two snapshots of 7 bytes being downloaded, and no more
+	driver, err := NewStorage(StorageConfig{Endpoint: server.URL, Token: "token", CacheDir: t.TempDir(), CacheSize: 14})
+	require.NoError(t, err)
+
+	var volume storage.StorageVolume
+	var modelErr error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		volume, modelErr = driver.PrepareStorage(ctx, model.StorageSpec{URL: "hf://org/model"})
+	}()
+	<-modelStarted
+	otherVolume, err := driver.PrepareStorage(ctx, model.StorageSpec{URL: "hf://org/other"})
+	require.NoError(t, err)
+	<-done
+	require.NoError(t, modelErr)
+
+	for _, source := range []string{volume.Source, otherVolume.Source} {
+		content, readErr := os.ReadFile(filepath.Join(source, "weights", "model.bin"))
+		require.NoError(t, readErr)
+		require.Equal(t, "model", string(content))
+	}
+}
@@ -0,0 +1,152 @@
+package inline
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/c2h5oh/datasize"
+	"github.com/filecoin-project/bacalhau/pkg/config"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/storage"
+	"github.com/filecoin-project/bacalhau/pkg/storage/util"
+	"github.com/filecoin-project/bacalhau/pkg/system"
+	"github.com/rs/zerolog/log"
+)
+
+// URLScheme is the scheme of the data URLs (RFC 2397) that inline inputs
+// are embedded in the spec as, e.g. data:;base64,aGVsbG8= or data:,hello.
+const URLScheme = "data:"
+
+// MaxSize is the most an inline input can be once decoded. Inputs are
+// embedded in the spec, which every node that the job is gossiped to stores,
+// so anything larger belongs in IPFS.
+const MaxSize = 10 * datasize.KB
+
+// NewURL returns the data URL that embeds content in a spec.
+func NewURL(content []byte) string {
+	return URLScheme + ";base64," + base64.StdEncoding.EncodeToString(content)
+}
+
+// Decode returns the content of a data URL, which is base64 encoded if its
+// media type ends with ;base64 and percent-encoded plaintext otherwise.
+func Decode(dataURL string) ([]byte, error) {
+	value := strings.TrimPrefix(dataURL, URLScheme)
+	mediaType, data, ok := strings.Cut(value, ",")
+	if value == dataURL || !ok {
+		return nil, fmt.Errorf("invalid inline input, must be a %s[<mediatype>][;base64],<data> URL", URLScheme)
+	}
+
+	var content []byte
+	if strings.HasSuffix(mediaType, ";base64") {
+		decoded, err := base64.StdEncoding.DecodeString(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid inline input: %w", err)
+		}
+		content = decoded
+	} else {
+		unescaped, err := url.PathUnescape(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid inline input: %w", err)
+		}
+		content = []byte(unescaped)
+	}
+	if uint64(len(content)) > MaxSize.Bytes() {
+		return nil, fmt.Errorf("inline input is %d bytes, more than the %s allowed, use IPFS instead",
+			len(content), MaxSize.HumanReadable())
+	}
+	return content, nil
+}
+
+// StorageProvider writes the inputs embedded in the spec of a job to files,
+// which saves a round trip to IPFS for small scripts and configs.
+type StorageProvider struct {
+	localDir string
+}
+
+func NewStorage(cm *system.CleanupManager) (*StorageProvider, error) {
+	dir, err := os.MkdirTemp(config.GetStoragePath(), "bacalhau-inline")
+	if err != nil {
+		return nil, err
+	}
+	cm.RegisterCallback(func() error {
+		if err := os.RemoveAll(dir); err != nil {
+			return fmt.Errorf("unable to remove storage folder: %w", err)
+		}
+		return nil
+	})
+
+	log.Debug().Msgf("Inline driver created with output dir: %s", dir)
+	return &StorageProvider{localDir: dir}, nil
+}
+
+func (driver *StorageProvider) IsInstalled(context.Context) (bool, error) {
+	return true, nil
+}
+
+// HasStorageLocally returns true, as the content is in the spec itself.
+func (driver *StorageProvider) HasStorageLocally(context.Context, model.StorageSpec) (bool, error) {
+	return true, nil
+}
+
+func (driver *StorageProvider) GetVolumeSize(_ context.Context, volume model.StorageSpec) (uint64, error) {
+	content, err := Decode(volume.URL)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(len(content)), nil
+}
+
+// PrepareStorage writes the content to a file, which is mounted at the path
+// of the spec, e.g. /inputs/script.py.
+func (driver *StorageProvider) PrepareStorage(
+	ctx context.Context,
+	storageSpec model.StorageSpec,
+) (storage.StorageVolume, error) {
+	_, span := system.GetTracer().Start(ctx, "pkg/storage/inline.PrepareStorage")
+	defer span.End()
+
+	content, err := Decode(storageSpec.URL)
+	if err != nil {
+		return storage.StorageVolume{}, err
+	}
+	outputPath, err := os.MkdirTemp(driver.localDir, "*")
+	if err != nil {
+		return storage.StorageVolume{}, err
+	}
+	filePath := filepath.Join(outputPath, path.Base(storageSpec.Path))
+	if err = os.WriteFile(filePath, content, util.OS_USER_RW); err != nil {
+		return storage.StorageVolume{}, fmt.Errorf("failed to write inline input to %s: %w", filePath, err)
+	}
+	return storage.StorageVolume{
+		Type:   storage.StorageVolumeConnectorBind,
+		Source: filePath,
+		Target: storageSpec.Path,
+	}, nil
+}
+
+func (driver *StorageProvider) CleanupStorage(
+	ctx context.Context,
+	_ model.StorageSpec,
+	volume storage.StorageVolume,
+) error {
+	pathToCleanup := filepath.Dir(volume.Source)
+	log.Ctx(ctx).Debug().Str("Path", pathToCleanup).Msg("Cleaning up")
+	return os.RemoveAll(pathToCleanup)
+}
+
+func (driver *StorageProvider) Upload(context.Context, string) (model.StorageSpec, error) {
+	return model.StorageSpec{}, fmt.Errorf("not implemented")
+}
+
+func (driver *StorageProvider) Explode(_ context.Context, spec model.StorageSpec) ([]model.StorageSpec, error) {
+	return []model.StorageSpec{spec}, nil
+}
+
+// Compile time interface check:
+var _ storage.Storage = (*StorageProvider)(nil)
@@ -0,0 +1,77 @@
+//go:build unit || !integration
+
+package inline
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/storage"
+	"github.com/filecoin-project/bacalhau/pkg/system"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecode(t *testing.T) {
+	for _, testCase := range []struct {
+		name    string
+		url     string
+		content string
+		valid   bool
+	}{
+		{name: "base64", url: NewURL([]byte("print('hello')\n")), content: "print('hello')\n", valid: true},
+		{name: "base64 with media type", url: "data:text/plain;base64,aGVsbG8=", content: "hello", valid: true},
+		{name: "plaintext", url: "data:,a%3D1%0Ab%3D2", content: "a=1\nb=2", valid: true},
+		{name: "empty", url: "data:,", content: "", valid: true},
+		{name: "no data", url: "data:text/plain"},
+		{name: "not a data URL", url: "https://example.com/script.py"},
+		{name: "invalid base64", url: "data:;base64,not base64"},
+		{name: "too large", url: NewURL([]byte(strings.Repeat("a", int(MaxSize.Bytes())+1)))},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			content, err := Decode(testCase.url)
+			if testCase.valid {
+				require.NoError(t, err)
+				require.Equal(t, testCase.content, string(content))
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestStorageProvider(t *testing.T) {
+	ctx := context.Background()
+	cm := system.NewCleanupManager()
+	defer cm.Cleanup()
+	t.Setenv("BACALHAU_STORAGE_PATH", t.TempDir())
+
+	driver, err := NewStorage(cm)
+	require.NoError(t, err)
+
+	spec := model.StorageSpec{
+		StorageSource: model.StorageSourceInline,
+		URL:           NewURL([]byte("#!/bin/sh\necho hello\n")),
+		Path:          "/inputs/hello.sh",
+	}
+	hasStorage, err := driver.HasStorageLocally(ctx, spec)
+	require.NoError(t, err)
+	require.True(t, hasStorage)
+
+	size, err := driver.GetVolumeSize(ctx, spec)
+	require.NoError(t, err)
+	require.Equal(t, uint64(21), size)
+
+	volume, err := driver.PrepareStorage(ctx, spec)
+	require.NoError(t, err)
+	require.Equal(t, storage.StorageVolumeConnectorBind, volume.Type)
+	require.Equal(t, "/inputs/hello.sh", volume.Target)
+	content, err := os.ReadFile(volume.Source)
+	require.NoError(t, err)
+	require.Equal(t, "#!/bin/sh\necho hello\n", string(content))
+
+	require.NoError(t, driver.CleanupStorage(ctx, spec, volume))
+	require.NoFileExists(t, volume.Source)
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 	"time"
@@ -40,6 +39,10 @@ const BacalhauIPFSFuseImage string = "binocarlos/bacalhau-ipfs-sidecar-image:v1"
 const BacalhauIPFSFuseMount = "/ipfs_mount"
 const MaxAttemptsForDocker = 5
 
+// fuseMountCheckTimeout is how long checking the fuse mount may take, over
+// the second ls is given, in case sudo hangs.
+const fuseMountCheckTimeout = 5 * time.Second
+
 type StorageProvider struct {
 	// we have a single mutex per storage driver
 	// (multuple of these might exist per docker server in the case of devstack)
@@ -387,7 +390,11 @@ func (sp *StorageProvider) canSeeFuseMount(ctx context.Context, cid string) bool
 	if err != nil {
 		return false
 	}
-	err = exec.Command("sudo", "timeout", "1s", "ls", "-la", testMountPath).Run()
+	_, err = system.RunCommand(ctx, system.Command{
+		Name:    "sudo",
+		Args:    []string{"timeout", "1s", "ls", "-la", testMountPath},
+		Timeout: fuseMountCheckTimeout,
+	})
 	return err == nil
 }
 
@@ -439,11 +446,14 @@ func createMountDir() (string, error) {
 }
 
 func cleanupMountDir(mountDir string) error {
-	return multierr.Combine(
-		// mountDir is only generated from os.MkdirTemp in createMountDir, so not user input
-		exec.Command("sudo", "umount", filepath.Join(mountDir, "data")).Run(), //nolint:gosec
-		exec.Command("sudo", "umount", filepath.Join(mountDir, "ipns")).Run(), //nolint:gosec
-	)
+	umount := func(dir string) error {
+		_, err := system.RunCommand(context.Background(), system.Command{
+			Name: "sudo",
+			Args: []string{"umount", filepath.Join(mountDir, dir)},
+		})
+		return err
+	}
+	return multierr.Combine(umount("data"), umount("ipns"))
 }
 
 func getMountDirFromContainer(c *dockertypes.Container) string {
@@ -0,0 +1,186 @@
+package localdirectory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/storage"
+	"github.com/filecoin-project/bacalhau/pkg/system"
+	"github.com/rs/zerolog/log"
+)
+
+// URLScheme is the scheme of the URLs of local directories, e.g.
+// local://census for the directory the node calls census, or
+// local:///data/census for a path under one of the paths it allows.
+const URLScheme = "local://"
+
+// StorageConfig is the directories on the node that jobs can read, e.g. the
+// private datasets of the owner of the node. Jobs can only read what is
+// listed here, and only ever read-only, as executors mount inputs.
+type StorageConfig struct {
+	// Directories by the name jobs read them with.
+	Directories map[string]string
+	// Paths that jobs can read the directories under by their path on the
+	// host, e.g. a checkout of a dataset during local development.
+	AllowedPaths []string
+}
+
+// StorageProvider mounts the local directories of a node into jobs, so that
+// jobs can run over data that never leaves the node.
+type StorageProvider struct {
+	directories  map[string]string
+	allowedPaths []string
+}
+
+func NewStorage(config StorageConfig) (*StorageProvider, error) {
+	driver := &StorageProvider{directories: map[string]string{}}
+	for name, path := range config.Directories {
+		resolved, err := resolveDirectory(path)
+		if err != nil {
+			return nil, fmt.Errorf("local directory %s: %w", name, err)
+		}
+		driver.directories[name] = resolved
+	}
+	for _, path := range config.AllowedPaths {
+		resolved, err := resolveDirectory(path)
+		if err != nil {
+			return nil, fmt.Errorf("allowed path %s: %w", path, err)
+		}
+		driver.allowedPaths = append(driver.allowedPaths, resolved)
+	}
+	log.Debug().
+		Int("Directories", len(driver.directories)).
+		Strs("AllowedPaths", driver.allowedPaths).
+		Msg("Local directory driver created")
+	return driver, nil
+}
+
+// resolveDirectory returns the absolute path of a directory without
+// symlinks, so that paths under it can't be made to point out of it.
+func resolveDirectory(path string) (string, error) {
+	absolute, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	resolved, err := filepath.EvalSymlinks(absolute)
+	if err != nil {
+		return "", err
+	}
+	if info, err := os.Stat(resolved); err != nil {
+		return "", err
+	} else if !info.IsDir() {
+		return "", fmt.Errorf("%s is not a directory", path)
+	}
+	return resolved, nil
+}
+
+// ParseURL returns the name of the local directory of a URL, or its path on
+// the host if it has one instead.
+func ParseURL(url string) (name string, hostPath string, err error) {
+	value := strings.TrimPrefix(url, URLScheme)
+	if value != url && value != "/" && strings.HasPrefix(value, "/") {
+		return "", filepath.Clean(value), nil
+	}
+	if value != url && value != "" && !strings.Contains(value, "/") {
+		return value, "", nil
+	}
+	return "", "", fmt.Errorf("invalid local directory URL %q, must be %s<name> or %s/<path>", url, URLScheme, URLScheme)
+}
+
+// IsInstalled returns true if the node has any local directories.
+func (driver *StorageProvider) IsInstalled(context.Context) (bool, error) {
+	return len(driver.directories) > 0 || len(driver.allowedPaths) > 0, nil
+}
+
+func (driver *StorageProvider) HasStorageLocally(ctx context.Context, volume model.StorageSpec) (bool, error) {
+	_, err := driver.getPathToVolume(volume)
+	return err == nil, nil
+}
+
+func (driver *StorageProvider) GetVolumeSize(ctx context.Context, volume model.StorageSpec) (uint64, error) {
+	ctx, span := system.GetTracer().Start(ctx, "pkg/storage/localdirectory.GetVolumeSize")
+	defer span.End()
+
+	localPath, err := driver.getPathToVolume(volume)
+	if err != nil {
+		return 0, err
+	}
+	var size uint64
+	err = filepath.WalkDir(localPath, func(_ string, entry os.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		size += uint64(info.Size())
+		return nil
+	})
+	return size, err
+}
+
+// PrepareStorage bind mounts the directory, which executors mount read-only
+// like any other input.
+func (driver *StorageProvider) PrepareStorage(
+	ctx context.Context,
+	storageSpec model.StorageSpec,
+) (storage.StorageVolume, error) {
+	localPath, err := driver.getPathToVolume(storageSpec)
+	if err != nil {
+		return storage.StorageVolume{}, err
+	}
+	return storage.StorageVolume{
+		Type:   storage.StorageVolumeConnectorBind,
+		Source: localPath,
+		Target: storageSpec.Path,
+	}, nil
+}
+
+func (driver *StorageProvider) CleanupStorage(context.Context, model.StorageSpec, storage.StorageVolume) error {
+	return nil
+}
+
+func (driver *StorageProvider) Upload(context.Context, string) (model.StorageSpec, error) {
+	return model.StorageSpec{}, fmt.Errorf("not implemented")
+}
+
+func (driver *StorageProvider) Explode(_ context.Context, spec model.StorageSpec) ([]model.StorageSpec, error) {
+	return []model.StorageSpec{spec}, nil
+}
+
+func (driver *StorageProvider) getPathToVolume(volume model.StorageSpec) (string, error) {
+	name, hostPath, err := ParseURL(volume.URL)
+	if err != nil {
+		return "", err
+	}
+	if hostPath == "" {
+		localPath, ok := driver.directories[name]
+		if !ok {
+			return "", errors.New("this node has no local directory called " + name)
+		}
+		return localPath, nil
+	}
+
+	// the path is resolved first, so that symlinks can't escape the paths
+	// that are allowed
+	resolved, err := filepath.EvalSymlinks(hostPath)
+	if err != nil {
+		return "", fmt.Errorf("this node cannot read %s: %w", hostPath, err)
+	}
+	for _, allowed := range driver.allowedPaths {
+		relative, err := filepath.Rel(allowed, resolved)
+		if err == nil && relative != ".." && !strings.HasPrefix(relative, ".."+string(filepath.Separator)) {
+			return resolved, nil
+		}
+	}
+	return "", fmt.Errorf("this node does not allow jobs to read %s", hostPath)
+}
+
+// Compile time interface check:
+var _ storage.Storage = (*StorageProvider)(nil)
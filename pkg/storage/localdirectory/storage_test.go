@@ -0,0 +1,101 @@
+//go:build unit || !integration
+
+package localdirectory
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorageProvider(t *testing.T) {
+	ctx := context.Background()
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "people.csv"), []byte("name,age\nada,36\n"), os.ModePerm))
+
+	driver, err := NewStorage(StorageConfig{Directories: map[string]string{"census": dir}})
+	require.NoError(t, err)
+	installed, err := driver.IsInstalled(ctx)
+	require.NoError(t, err)
+	require.True(t, installed)
+
+	spec := model.StorageSpec{StorageSource: model.StorageSourceLocalDirectory, URL: "local://census", Path: "/inputs"}
+	hasStorage, err := driver.HasStorageLocally(ctx, spec)
+	require.NoError(t, err)
+	require.True(t, hasStorage)
+
+	size, err := driver.GetVolumeSize(ctx, spec)
+	require.NoError(t, err)
+	require.Equal(t, uint64(16), size)
+
+	volume, err := driver.PrepareStorage(ctx, spec)
+	require.NoError(t, err)
+	require.Equal(t, storage.StorageVolumeConnectorBind, volume.Type)
+	require.Equal(t, dir, volume.Source)
+	require.Equal(t, "/inputs", volume.Target)
+
+	for _, url := range []string{"local://payroll", "local://census/../payroll", "local://", "census", "local://" + dir} {
+		spec.URL = url
+		hasStorage, err = driver.HasStorageLocally(ctx, spec)
+		require.NoError(t, err)
+		require.False(t, hasStorage, url)
+		_, err = driver.PrepareStorage(ctx, spec)
+		require.Error(t, err, url)
+	}
+}
+
+func TestNewStorageInvalidDirectory(t *testing.T) {
+	_, err := NewStorage(StorageConfig{Directories: map[string]string{"census": filepath.Join(t.TempDir(), "missing")}})
+	require.Error(t, err)
+
+	driver, err := NewStorage(StorageConfig{})
+	require.NoError(t, err)
+	installed, err := driver.IsInstalled(context.Background())
+	require.NoError(t, err)
+	require.False(t, installed)
+}
+
+func TestStorageProviderAllowedPaths(t *testing.T) {
+	ctx := context.Background()
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+	allowed := filepath.Join(root, "datasets")
+	secret := filepath.Join(root, "secret")
+	require.NoError(t, os.MkdirAll(filepath.Join(allowed, "census"), os.ModePerm))
+	require.NoError(t, os.MkdirAll(secret, os.ModePerm))
+	require.NoError(t, os.Symlink(secret, filepath.Join(allowed, "escape")))
+
+	driver, err := NewStorage(StorageConfig{AllowedPaths: []string{allowed}})
+	require.NoError(t, err)
+	installed, err := driver.IsInstalled(ctx)
+	require.NoError(t, err)
+	require.True(t, installed)
+
+	for _, path := range []string{allowed, filepath.Join(allowed, "census")} {
+		spec := model.StorageSpec{StorageSource: model.StorageSourceLocalDirectory, URL: "local://" + path, Path: "/inputs"}
+		volume, err := driver.PrepareStorage(ctx, spec)
+		require.NoError(t, err, path)
+		require.Equal(t, path, volume.Source)
+	}
+
+	for _, path := range []string{
+		secret,
+		filepath.Join(allowed, "..", "secret"),
+		filepath.Join(allowed, "escape"),
+		filepath.Join(allowed, "missing"),
+		allowed + "-other",
+	} {
+		spec := model.StorageSpec{StorageSource: model.StorageSourceLocalDirectory, URL: "local://" + path}
+		hasStorage, err := driver.HasStorageLocally(ctx, spec)
+		require.NoError(t, err)
+		require.False(t, hasStorage, path)
+		_, err = driver.PrepareStorage(ctx, spec)
+		require.Error(t, err, path)
+	}
+}
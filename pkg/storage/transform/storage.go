@@ -0,0 +1,159 @@
+package transform
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/filecoin-project/bacalhau/pkg/config"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/storage"
+	"github.com/filecoin-project/bacalhau/pkg/system"
+)
+
+// Decrypter decrypts data with the private key of the compute node, and is
+// used to unwrap the data keys of encrypted inputs.
+type Decrypter func(ctx context.Context, data []byte) ([]byte, error)
+
+// StorageProvider wraps another storage provider so that the inputs it
+// prepares have their transforms applied before they are mounted.
+type StorageProvider struct {
+	provider  storage.StorageProvider
+	localDir  string
+	decrypter Decrypter
+}
+
+func NewStorageProvider(
+	cm *system.CleanupManager,
+	provider storage.StorageProvider,
+	decrypter Decrypter,
+) (*StorageProvider, error) {
+	dir, err := os.MkdirTemp(config.GetStoragePath(), "bacalhau-transform")
+	if err != nil {
+		return nil, err
+	}
+
+	cm.RegisterCallback(func() error {
+		if err := os.RemoveAll(dir); err != nil {
+			return fmt.Errorf("unable to clean up input transform directory: %w", err)
+		}
+		return nil
+	})
+
+	return &StorageProvider{
+		provider:  provider,
+		localDir:  dir,
+		decrypter: decrypter,
+	}, nil
+}
+
+func (p *StorageProvider) GetStorage(ctx context.Context, storageType model.StorageSourceType) (storage.Storage, error) {
+	inner, err := p.provider.GetStorage(ctx, storageType)
+	if err != nil {
+		return nil, err
+	}
+	return &transformingStorage{
+		Storage:  inner,
+		provider: p,
+	}, nil
+}
+
+// transformingStorage applies the transforms of a storage spec to the
+// volume prepared by the storage it wraps.
+type transformingStorage struct {
+	storage.Storage
+	provider *StorageProvider
+}
+
+func (s *transformingStorage) PrepareStorage(ctx context.Context, storageSpec model.StorageSpec) (storage.StorageVolume, error) {
+	volume, err := s.Storage.PrepareStorage(ctx, storageSpec)
+	if err != nil || len(storageSpec.Transforms) == 0 {
+		return volume, err
+	}
+
+	ctx, span := system.Span(ctx, "storage/transform", "PrepareStorage")
+	defer span.End()
+
+	if volume.Type != storage.StorageVolumeConnectorBind {
+		return storage.StorageVolume{}, fmt.Errorf(
+			"input %s can't be transformed, its volume is not bound from a local path", storageSpec.Name)
+	}
+
+	dir, err := os.MkdirTemp(s.provider.localDir, "*")
+	if err != nil {
+		return storage.StorageVolume{}, err
+	}
+
+	source := volume.Source
+	for i, transform := range storageSpec.Transforms {
+		target := filepath.Join(dir, strconv.Itoa(i))
+		if err = s.provider.apply(ctx, transform, source, target); err != nil {
+			_ = os.RemoveAll(dir)
+			return storage.StorageVolume{}, fmt.Errorf("error applying transform %s to input %s: %w",
+				transform, storageSpec.Name, err)
+		}
+		// only the output of the last transform is mounted
+		if source != volume.Source {
+			if err = removeTransformed(ctx, source); err != nil {
+				_ = os.RemoveAll(dir)
+				return storage.StorageVolume{}, err
+			}
+		}
+		source = target
+	}
+
+	// the fetched data isn't mounted, so it is no longer needed
+	if err = s.Storage.CleanupStorage(ctx, storageSpec, volume); err != nil {
+		_ = os.RemoveAll(dir)
+		return storage.StorageVolume{}, err
+	}
+	return storage.StorageVolume{
+		Type:   storage.StorageVolumeConnectorBind,
+		Source: source,
+		Target: volume.Target,
+	}, nil
+}
+
+// removeTransformed removes the output of a transform once the next one has
+// been applied to it, and gives back the space it took to the disk budget.
+func removeTransformed(ctx context.Context, path string) error {
+	var size uint64
+	err := filepath.WalkDir(path, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.Type().IsRegular() {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		size += uint64(info.Size())
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err = os.RemoveAll(path); err != nil {
+		return err
+	}
+	storage.DiskBudgetFromContext(ctx).Release(size)
+	return nil
+}
+
+func (s *transformingStorage) CleanupStorage(
+	ctx context.Context,
+	storageSpec model.StorageSpec,
+	volume storage.StorageVolume,
+) error {
+	if len(storageSpec.Transforms) == 0 {
+		return s.Storage.CleanupStorage(ctx, storageSpec, volume)
+	}
+	// the fetched data was cleaned up once it had been transformed
+	return os.RemoveAll(filepath.Dir(volume.Source))
+}
+
+// Compile time interface checks:
+var _ storage.StorageProvider = (*StorageProvider)(nil)
+var _ storage.Storage = (*transformingStorage)(nil)
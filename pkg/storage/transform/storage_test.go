@@ -0,0 +1,201 @@
+//go:build unit || !integration
+
+package transform
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/storage"
+	noop_storage "github.com/filecoin-project/bacalhau/pkg/storage/noop"
+	"github.com/filecoin-project/bacalhau/pkg/system"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestStorage(t *testing.T, source string, decrypter Decrypter) storage.Storage {
+	ctx := context.Background()
+	noopStorage, err := noop_storage.NewNoopStorage(ctx, system.NewCleanupManager(), noop_storage.StorageConfig{
+		ExternalHooks: noop_storage.StorageConfigExternalHooks{
+			PrepareStorage: func(ctx context.Context, storageSpec model.StorageSpec) (storage.StorageVolume, error) {
+				return storage.StorageVolume{
+					Type:   storage.StorageVolumeConnectorBind,
+					Source: source,
+					Target: storageSpec.Path,
+				}, nil
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	provider := &StorageProvider{
+		provider:  noop_storage.NewNoopStorageProvider(noopStorage),
+		localDir:  t.TempDir(),
+		decrypter: decrypter,
+	}
+	s, err := provider.GetStorage(ctx, model.StorageSourceIPFS)
+	require.NoError(t, err)
+	return s
+}
+
+func TestGunzipUntar(t *testing.T) {
+	var archive bytes.Buffer
+	gz := gzip.NewWriter(&archive)
+	tw := tar.NewWriter(gz)
+	for name, contents := range map[string]string{
+		"data/a.txt":     "a",
+		"data/sub/b.txt": "b",
+		"../escape.txt":  "escape",
+	} {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(contents)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write([]byte(contents))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+
+	source := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(source, "archive.tar.gz"), archive.Bytes(), 0644))
+
+	s := newTestStorage(t, source, nil)
+	volume, err := s.PrepareStorage(context.Background(), model.StorageSpec{
+		Path:       "/inputs",
+		Transforms: []model.InputTransform{model.InputTransformGunzip, model.InputTransformUntar},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "/inputs", volume.Target)
+
+	for name, contents := range map[string]string{
+		"archive/data/a.txt":     "a",
+		"archive/data/sub/b.txt": "b",
+		"archive/escape.txt":     "escape",
+	} {
+		data, err := os.ReadFile(filepath.Join(volume.Source, name))
+		require.NoError(t, err)
+		require.Equal(t, contents, string(data))
+	}
+}
+
+func TestDiskBudget(t *testing.T) {
+	var archive bytes.Buffer
+	gz := gzip.NewWriter(&archive)
+	tw := tar.NewWriter(gz)
+	contents := make([]byte, 1024*1024)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "zeros", Mode: 0644, Size: int64(len(contents)), Typeflag: tar.TypeReg}))
+	_, err := tw.Write(contents)
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+
+	source := filepath.Join(t.TempDir(), "archive.tar.gz")
+	require.NoError(t, os.WriteFile(source, archive.Bytes(), 0644))
+	spec := model.StorageSpec{
+		Path:       "/inputs",
+		Transforms: []model.InputTransform{model.InputTransformGunzip, model.InputTransformUntar},
+	}
+
+	// a small input can't be inflated past the disk reserved for the job
+	ctx := storage.ContextWithDiskBudget(context.Background(), storage.NewDiskBudget(512*1024))
+	_, err = newTestStorage(t, source, nil).PrepareStorage(ctx, spec)
+	require.ErrorContains(t, err, "more than the 512.0 KB of disk reserved for the job")
+
+	// while the tar it was decompressed to only counts until it is extracted
+	budget := storage.NewDiskBudget(2*uint64(len(contents)) + 512*1024)
+	ctx = storage.ContextWithDiskBudget(context.Background(), budget)
+	volume, err := newTestStorage(t, source, nil).PrepareStorage(ctx, spec)
+	require.NoError(t, err)
+	data, err := os.ReadFile(filepath.Join(volume.Source, "zeros"))
+	require.NoError(t, err)
+	require.Equal(t, contents, data)
+	require.NoError(t, budget.Use(uint64(len(contents))+256*1024))
+}
+
+func TestUnzstdFile(t *testing.T) {
+	var compressed bytes.Buffer
+	zw, err := zstd.NewWriter(&compressed)
+	require.NoError(t, err)
+	_, err = zw.Write([]byte("hello"))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	source := filepath.Join(t.TempDir(), "hello.zst")
+	require.NoError(t, os.WriteFile(source, compressed.Bytes(), 0644))
+
+	s := newTestStorage(t, source, nil)
+	volume, err := s.PrepareStorage(context.Background(), model.StorageSpec{
+		Path:       "/inputs/hello",
+		Transforms: []model.InputTransform{model.InputTransformUnzstd},
+	})
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(volume.Source)
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(data))
+}
+
+// the size of the tag AES-GCM adds to each chunk, which is all there is to
+// an empty last chunk
+const gcmTagSize = 16
+
+func TestDecrypt(t *testing.T) {
+	ctx := context.Background()
+	// wrap data keys by reversing them, which is enough to check that the
+	// key is unwrapped before use
+	reverse := func(data []byte) []byte {
+		reversed := make([]byte, len(data))
+		for i, b := range data {
+			reversed[len(data)-1-i] = b
+		}
+		return reversed
+	}
+	encrypter := func(_ context.Context, data, _ []byte) ([]byte, error) {
+		return reverse(data), nil
+	}
+	decrypter := func(_ context.Context, data []byte) ([]byte, error) {
+		return reverse(data), nil
+	}
+
+	spec := model.StorageSpec{
+		Path:       "/inputs",
+		Transforms: []model.InputTransform{model.InputTransformDecrypt},
+	}
+	// inputs are sealed in chunks, the last of which may be empty
+	for _, secret := range [][]byte{
+		[]byte("secret"),
+		bytes.Repeat([]byte("secret"), encryptedChunkSize/2),
+		bytes.Repeat([]byte("s"), 2*encryptedChunkSize),
+	} {
+		sealed, err := EncryptInput(ctx, secret, nil, encrypter)
+		require.NoError(t, err)
+		source := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(source, "secret.txt.enc"), sealed, 0644))
+
+		volume, err := newTestStorage(t, source, decrypter).PrepareStorage(ctx, spec)
+		require.NoError(t, err)
+		data, err := os.ReadFile(filepath.Join(volume.Source, "secret.txt"))
+		require.NoError(t, err)
+		require.Equal(t, secret, data)
+
+		// nor can the input be truncated, even to a whole number of chunks
+		require.NoError(t, os.WriteFile(filepath.Join(source, "secret.txt.enc"), sealed[:len(sealed)-gcmTagSize], 0644))
+		_, err = newTestStorage(t, source, decrypter).PrepareStorage(ctx, spec)
+		require.Error(t, err)
+	}
+
+	sealed, err := EncryptInput(ctx, []byte("secret"), nil, encrypter)
+	require.NoError(t, err)
+	source := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(source, "secret.txt.enc"), sealed, 0644))
+
+	// a node that can't unwrap the key can't decrypt the input
+	_, err = newTestStorage(t, source, func(_ context.Context, data []byte) ([]byte, error) {
+		return data, nil
+	}).PrepareStorage(ctx, spec)
+	require.Error(t, err)
+}
@@ -0,0 +1,337 @@
+package transform
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/storage"
+	"github.com/filecoin-project/bacalhau/pkg/storage/util"
+	"github.com/klauspost/compress/zstd"
+	"github.com/rs/zerolog/log"
+)
+
+// fileTransform writes the transformed contents of the file at source to
+// target, which may be a file or a directory.
+type fileTransform func(ctx context.Context, source *os.File, target string) error
+
+// apply applies a transform to source, which is either a file or a directory
+// in which case the transform is applied to each file in it, and writes the
+// result to target.
+func (p *StorageProvider) apply(ctx context.Context, transform model.InputTransform, source, target string) error {
+	var fn fileTransform
+	var suffixes []string
+	switch transform {
+	case model.InputTransformGunzip:
+		fn, suffixes = gunzip, []string{".gz", ".gzip"}
+	case model.InputTransformUnzstd:
+		fn, suffixes = unzstd, []string{".zst", ".zstd"}
+	case model.InputTransformUntar:
+		fn, suffixes = untar, []string{".tar"}
+	case model.InputTransformDecrypt:
+		if p.decrypter == nil {
+			return errors.New("this node can't decrypt inputs")
+		}
+		fn, suffixes = p.decrypt, []string{".enc"}
+	default:
+		return fmt.Errorf("unknown input transform %q", transform)
+	}
+
+	info, err := os.Stat(source)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return applyToFile(ctx, fn, source, target)
+	}
+
+	return filepath.WalkDir(source, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relative, err := filepath.Rel(source, path)
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return os.MkdirAll(filepath.Join(target, relative), util.OS_ALL_RWX)
+		}
+		if !entry.Type().IsRegular() {
+			log.Ctx(ctx).Debug().Msgf("not transforming %s as it is not a regular file", path)
+			return nil
+		}
+		return applyToFile(ctx, fn, path, filepath.Join(target, trimSuffixes(relative, suffixes)))
+	})
+}
+
+func applyToFile(ctx context.Context, fn fileTransform, source, target string) error {
+	file, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err = fn(ctx, file, target); err != nil {
+		return fmt.Errorf("%s: %w", filepath.Base(source), err)
+	}
+	return nil
+}
+
+func trimSuffixes(name string, suffixes []string) string {
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(name, suffix) && len(name) > len(suffix) {
+			return strings.TrimSuffix(name, suffix)
+		}
+	}
+	return name
+}
+
+// writeFile writes what r reads to target, failing once that exceeds the disk
+// budget of the job, so that e.g. a decompression bomb can't fill the disk.
+func writeFile(ctx context.Context, target string, r io.Reader, mode fs.FileMode) error {
+	file, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, mode)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(storage.DiskBudgetFromContext(ctx).Writer(file), r); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func gunzip(ctx context.Context, source *os.File, target string) error {
+	reader, err := gzip.NewReader(source)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	return writeFile(ctx, target, reader, util.OS_USER_RW|util.OS_ALL_R)
+}
+
+func unzstd(ctx context.Context, source *os.File, target string) error {
+	reader, err := zstd.NewReader(source)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	return writeFile(ctx, target, reader, util.OS_USER_RW|util.OS_ALL_R)
+}
+
+// untar extracts the regular files and directories of a tar file into the
+// target directory. Other entries, such as links, are skipped as they could
+// point outside of the input.
+func untar(ctx context.Context, source *os.File, target string) error {
+	if err := os.MkdirAll(target, util.OS_ALL_RWX); err != nil {
+		return err
+	}
+	reader := tar.NewReader(source)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		path := filepath.Join(target, filepath.Clean("/"+header.Name))
+		if path == target {
+			continue
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(path, util.OS_ALL_RWX)
+		case tar.TypeReg:
+			if err = os.MkdirAll(filepath.Dir(path), util.OS_ALL_RWX); err == nil {
+				err = writeFile(ctx, path, reader, header.FileInfo().Mode().Perm()|util.OS_ALL_R)
+			}
+		default:
+			log.Ctx(ctx).Debug().Msgf("not extracting %s as it is not a regular file or directory", header.Name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Encrypted inputs start with the length of the wrapped data key as a
+// big-endian uint16, followed by the wrapped key and the AES-GCM nonce. The
+// data follows in chunks that are sealed one by one, so that they can be
+// opened as they are read: each with the nonce XORed with the index of the
+// chunk, and with whether it is the last chunk as additional data, so that
+// chunks can't be reordered, nor the input be truncated. Only the last chunk
+// is shorter than encryptedChunkSize, even if that leaves it empty.
+const wrappedKeyLengthSize = 2
+
+// Data keys are for AES-256.
+const dataKeySize = 32
+
+const encryptedChunkSize = 64 * 1024
+
+// Encrypter encrypts data with the given public key, see Transport.Encrypt.
+type Encrypter func(ctx context.Context, data, publicKeyBytes []byte) ([]byte, error)
+
+// EncryptInput seals data for the decrypt transform, with a random data key
+// that is wrapped with the public key of the compute node that will run the
+// job.
+func EncryptInput(ctx context.Context, data, publicKeyBytes []byte, encrypter Encrypter) ([]byte, error) {
+	key := make([]byte, dataKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	wrappedKey, err := encrypter(ctx, key, publicKeyBytes)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrappedKey) > math.MaxUint16 {
+		return nil, errors.New("wrapped data key is too long")
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	chunks := len(data)/encryptedChunkSize + 1
+	sealed := make([]byte, wrappedKeyLengthSize, wrappedKeyLengthSize+len(wrappedKey)+len(nonce)+len(data)+chunks*gcm.Overhead())
+	binary.BigEndian.PutUint16(sealed, uint16(len(wrappedKey)))
+	sealed = append(sealed, wrappedKey...)
+	sealed = append(sealed, nonce...)
+	for index := 0; index < chunks; index++ {
+		start := index * encryptedChunkSize
+		end := start + encryptedChunkSize
+		last := index == chunks-1
+		if last {
+			end = len(data)
+		}
+		sealed = gcm.Seal(sealed, chunkNonce(nonce, uint64(index)), data[start:end], chunkData(last))
+	}
+	return sealed, nil
+}
+
+// decrypt unwraps the data key of an encrypted input with the node's private
+// key, and uses it to open the sealed chunks of data as they are read.
+func (p *StorageProvider) decrypt(ctx context.Context, source *os.File, target string) error {
+	reader := bufio.NewReader(source)
+	var length [wrappedKeyLengthSize]byte
+	if _, err := io.ReadFull(reader, length[:]); err != nil {
+		return errEncryptedInputTooShort(err)
+	}
+	wrappedKey := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(reader, wrappedKey); err != nil {
+		return errEncryptedInputTooShort(err)
+	}
+	key, err := p.decrypter(ctx, wrappedKey)
+	if err != nil {
+		return fmt.Errorf("error unwrapping data key: %w", err)
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = io.ReadFull(reader, nonce); err != nil {
+		return errEncryptedInputTooShort(err)
+	}
+	return writeFile(ctx, target, &openingReader{
+		source: reader,
+		gcm:    gcm,
+		nonce:  nonce,
+		chunk:  make([]byte, encryptedChunkSize+gcm.Overhead()),
+	}, util.OS_USER_RW|util.OS_ALL_R)
+}
+
+func errEncryptedInputTooShort(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return errors.New("encrypted input is too short")
+	}
+	return err
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// chunkNonce returns the nonce the chunk at index is sealed with.
+func chunkNonce(nonce []byte, index uint64) []byte {
+	chunk := make([]byte, len(nonce))
+	copy(chunk, nonce)
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], index)
+	for i, b := range counter {
+		chunk[len(chunk)-len(counter)+i] ^= b
+	}
+	return chunk
+}
+
+// chunkData returns the additional data a chunk is sealed with.
+func chunkData(last bool) []byte {
+	if last {
+		return []byte{1}
+	}
+	return []byte{0}
+}
+
+// openingReader reads the data of an encrypted input, opening its chunks as
+// they are needed.
+type openingReader struct {
+	source    io.Reader
+	gcm       cipher.AEAD
+	nonce     []byte
+	index     uint64
+	chunk     []byte
+	plaintext []byte
+	last      bool
+}
+
+func (r *openingReader) Read(p []byte) (int, error) {
+	for len(r.plaintext) == 0 {
+		if r.last {
+			return 0, io.EOF
+		}
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.plaintext)
+	r.plaintext = r.plaintext[n:]
+	return n, nil
+}
+
+func (r *openingReader) open() error {
+	n, err := io.ReadFull(r.source, r.chunk)
+	// only the last chunk is shorter than a full one
+	r.last = err == io.EOF || err == io.ErrUnexpectedEOF
+	if err != nil && !r.last {
+		return err
+	}
+	r.plaintext, err = r.gcm.Open(r.chunk[:0], chunkNonce(r.nonce, r.index), r.chunk[:n], chunkData(r.last))
+	if err != nil {
+		return fmt.Errorf("error opening chunk %d of encrypted input: %w", r.index, err)
+	}
+	r.index++
+	return nil
+}
@@ -3,16 +3,18 @@ package system
 import (
 	"context"
 	"errors"
+	"fmt"
 	"os"
 	"path"
 	"runtime"
 	"runtime/pprof"
+	"sort"
+	"strings"
 	"time"
 
-	realsync "sync"
-
 	"github.com/filecoin-project/bacalhau/pkg/util/closer"
 	sync "github.com/lukemarsden/golang-mutex-tracer"
+	"go.uber.org/multierr"
 
 	"github.com/rs/zerolog/log"
 )
@@ -22,14 +24,62 @@ const SleepBeforeCleanup = time.Millisecond * 100
 // CleanupManager provides utilities for ensuring that sub-goroutines can
 // clean up their resources before the main goroutine exits. Can be used to
 // register callbacks for long-running system processes.
+//
+// Handlers run concurrently, except that those ordered with Before or After
+// wait for the handlers they are ordered against to finish, or to time out,
+// so that e.g. a server stops serving requests before the stores it serves
+// them from are closed.
 type CleanupManager struct {
-	wg realsync.WaitGroup
-
 	fnsMutex sync.Mutex
-	fns      []func() error
+	handlers []cleanupHandler
 	fnsDone  bool
 }
 
+type cleanupHandler struct {
+	fn      func(context.Context) error
+	name    string
+	before  []string
+	after   []string
+	timeout time.Duration
+}
+
+func (h cleanupHandler) String() string {
+	if h.name == "" {
+		return "clean-up callback"
+	}
+	return "clean-up callback " + h.name
+}
+
+// CleanupOption configures a clean-up handler.
+type CleanupOption func(*cleanupHandler)
+
+// WithName names the handler, for other handlers to be ordered against it
+// and for its errors to say which handler failed. Several handlers can have
+// the same name, e.g. those of several instances of a component, and are
+// ordered together.
+func WithName(name string) CleanupOption {
+	return func(h *cleanupHandler) { h.name = name }
+}
+
+// Before makes the handler run before the handlers with the names, which
+// wait for it to finish.
+func Before(names ...string) CleanupOption {
+	return func(h *cleanupHandler) { h.before = append(h.before, names...) }
+}
+
+// After makes the handler wait for the handlers with the names to finish
+// before it runs.
+func After(names ...string) CleanupOption {
+	return func(h *cleanupHandler) { h.after = append(h.after, names...) }
+}
+
+// WithTimeout bounds how long the handler is waited for. Its context is
+// done once the timeout is reached, and the handlers ordered after it run
+// whether it has returned or not.
+func WithTimeout(timeout time.Duration) CleanupOption {
+	return func(h *cleanupHandler) { h.timeout = timeout }
+}
+
 // NewCleanupManager returns a new CleanupManager instance.
 func NewCleanupManager() *CleanupManager {
 	c := &CleanupManager{}
@@ -41,7 +91,14 @@ func NewCleanupManager() *CleanupManager {
 }
 
 // RegisterCallback registers a clean-up function.
-func (cm *CleanupManager) RegisterCallback(fn func() error) {
+func (cm *CleanupManager) RegisterCallback(fn func() error, opts ...CleanupOption) {
+	cm.RegisterCallbackWithContext(func(context.Context) error { return fn() }, opts...)
+}
+
+// RegisterCallbackWithContext registers a clean-up function that is given
+// the context of the clean-up, which is done once the timeout of the
+// handler is reached or the clean-up is cancelled.
+func (cm *CleanupManager) RegisterCallbackWithContext(fn func(context.Context) error, opts ...CleanupOption) {
 	cm.fnsMutex.Lock()
 	defer cm.fnsMutex.Unlock()
 
@@ -50,13 +107,26 @@ func (cm *CleanupManager) RegisterCallback(fn func() error) {
 		return
 	}
 
-	cm.wg.Add(1)
-	cm.fns = append(cm.fns, fn)
+	handler := cleanupHandler{fn: fn}
+	for _, opt := range opts {
+		opt(&handler)
+	}
+	cm.handlers = append(cm.handlers, handler)
 }
 
-// Cleanup runs all registered clean-up functions in sub-goroutines and
-// waits for them all to complete before exiting.
+// Cleanup runs all registered clean-up functions and waits for them all to
+// complete before exiting, logging the errors they return.
 func (cm *CleanupManager) Cleanup() {
+	err := cm.CleanupWithContext(context.Background())
+	for _, handlerErr := range multierr.Errors(err) {
+		log.Error().Msgf("Error during clean-up callback: %v", handlerErr)
+	}
+}
+
+// CleanupWithContext runs all registered clean-up functions, and waits for
+// them to complete or time out. The errors of all the handlers are returned,
+// except those of handlers that were cancelled.
+func (cm *CleanupManager) CleanupWithContext(ctx context.Context) error {
 	// we sleep a tiny bit here because some tests run so quickly
 	// that there are RegisterCallback calls happening
 	// after we have been called
@@ -79,25 +149,119 @@ func (cm *CleanupManager) Cleanup() {
 	}
 
 	cm.fnsMutex.Lock()
-	defer cm.fnsMutex.Unlock()
-
 	if cm.fnsDone {
+		cm.fnsMutex.Unlock()
 		log.Warn().Msg("CleanupManager: Cleanup called again after already called")
-		return
+		return nil
 	}
+	cm.fnsDone = true
+	handlers := cm.handlers
+	cm.fnsMutex.Unlock()
 
-	for i := 0; i < len(cm.fns); i++ {
-		go func(fn func() error) {
-			defer cm.wg.Done()
+	waitFor, orderErr := cleanupOrder(handlers)
+	done := make([]chan struct{}, len(handlers))
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+	errs := make([]error, len(handlers))
+	for i := range handlers {
+		go func(i int) {
+			defer close(done[i])
+			for _, j := range waitFor[i] {
+				<-done[j]
+			}
+			errs[i] = runCleanupHandler(ctx, handlers[i])
+		}(i)
+	}
+	for i := range done {
+		<-done[i]
+	}
+	return multierr.Combine(append([]error{orderErr}, errs...)...)
+}
 
-			if err := fn(); err != nil {
-				if !errors.Is(err, context.Canceled) {
-					log.Error().Msgf("Error during clean-up callback: %v", err)
-				}
+// runCleanupHandler runs the handler until it returns or its context is
+// done, whichever is first.
+func runCleanupHandler(ctx context.Context, handler cleanupHandler) error {
+	if handler.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, handler.timeout)
+		defer cancel()
+	}
+	result := make(chan error, 1)
+	go func() {
+		result <- handler.fn(ctx)
+	}()
+	select {
+	case err := <-result:
+		if err != nil && !errors.Is(err, context.Canceled) {
+			return fmt.Errorf("%s: %w", handler, err)
+		}
+		return nil
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("%s did not finish in time: %w", handler, ctx.Err())
+		}
+		return nil
+	}
+}
+
+// cleanupOrder returns the handlers each handler waits for before it runs.
+// Handlers ordered in a cycle, and those ordered after them, only wait for
+// the handlers that aren't, which the returned error reports.
+func cleanupOrder(handlers []cleanupHandler) ([][]int, error) {
+	byName := map[string][]int{}
+	for i, handler := range handlers {
+		if handler.name != "" {
+			byName[handler.name] = append(byName[handler.name], i)
+		}
+	}
+	waitFor := make([][]int, len(handlers))
+	for i, handler := range handlers {
+		for _, name := range handler.before {
+			for _, j := range byName[name] {
+				waitFor[j] = append(waitFor[j], i)
 			}
-		}(cm.fns[i])
+		}
+		for _, name := range handler.after {
+			waitFor[i] = append(waitFor[i], byName[name]...)
+		}
 	}
 
-	cm.wg.Wait()
-	cm.fnsDone = true
+	// remove the waits of the handlers that can't run until the handlers
+	// they wait for can, which are waiting for each other
+	ordered := make([]bool, len(handlers))
+	for progress := true; progress; {
+		progress = false
+		for i := range handlers {
+			if ordered[i] {
+				continue
+			}
+			ready := true
+			for _, j := range waitFor[i] {
+				ready = ready && ordered[j] && j != i
+			}
+			if ready {
+				ordered[i] = true
+				progress = true
+			}
+		}
+	}
+	var cycle []string
+	for i, handler := range handlers {
+		if !ordered[i] {
+			cycle = append(cycle, handler.String())
+			var waits []int
+			for _, j := range waitFor[i] {
+				if ordered[j] {
+					waits = append(waits, j)
+				}
+			}
+			waitFor[i] = waits
+		}
+	}
+	if len(cycle) > 0 {
+		sort.Strings(cycle)
+		return waitFor, fmt.Errorf("clean-up callbacks ordered in or after a cycle ran out of order: %s", strings.Join(cycle, ", "))
+	}
+	return waitFor, nil
 }
@@ -3,11 +3,17 @@
 package system
 
 import (
+	"context"
+	"errors"
+	realsync "sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/filecoin-project/bacalhau/pkg/logger"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
+	"go.uber.org/multierr"
 )
 
 type SystemCleanupSuite struct {
@@ -38,3 +44,54 @@ func (suite *SystemCleanupSuite) TestCleanupManager() {
 	cm.Cleanup()
 	require.True(suite.T(), clean, "cleanup handler failed to run registered functions")
 }
+
+func (suite *SystemCleanupSuite) TestCleanupOrder() {
+	var mutex realsync.Mutex
+	var order []string
+	record := func(name string) func() error {
+		return func() error {
+			mutex.Lock()
+			defer mutex.Unlock()
+			order = append(order, name)
+			return nil
+		}
+	}
+
+	cm := NewCleanupManager()
+	cm.RegisterCallback(record("store"), WithName("store"))
+	cm.RegisterCallback(record("server"), WithName("server"), Before("store"))
+	cm.RegisterCallback(record("tracing"), WithName("tracing"), After("store", "server"))
+	require.NoError(suite.T(), cm.CleanupWithContext(context.Background()))
+	require.Equal(suite.T(), []string{"server", "store", "tracing"}, order)
+}
+
+func (suite *SystemCleanupSuite) TestCleanupErrors() {
+	ran := false
+	cm := NewCleanupManager()
+	cm.RegisterCallback(func() error { return errors.New("failed to close") }, WithName("store"))
+	cm.RegisterCallback(func() error { return context.Canceled })
+	cm.RegisterCallbackWithContext(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}, WithName("server"), WithTimeout(10*time.Millisecond))
+	cm.RegisterCallback(func() error {
+		ran = true
+		return nil
+	}, After("server"))
+
+	err := cm.CleanupWithContext(context.Background())
+	require.Len(suite.T(), multierr.Errors(err), 2)
+	require.ErrorContains(suite.T(), err, "clean-up callback store: failed to close")
+	require.ErrorContains(suite.T(), err, "clean-up callback server did not finish in time")
+	require.True(suite.T(), ran, "handlers ordered after one that timed out still run")
+}
+
+func (suite *SystemCleanupSuite) TestCleanupCycle() {
+	var ran atomic.Int32
+	cm := NewCleanupManager()
+	cm.RegisterCallback(func() error { ran.Add(1); return nil }, WithName("a"), After("b"))
+	cm.RegisterCallback(func() error { ran.Add(1); return nil }, WithName("b"), After("a"))
+	err := cm.CleanupWithContext(context.Background())
+	require.ErrorContains(suite.T(), err, "clean-up callback a, clean-up callback b")
+	require.Equal(suite.T(), int32(2), ran.Load())
+}
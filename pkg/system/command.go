@@ -0,0 +1,181 @@
+package system
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/c2h5oh/datasize"
+)
+
+// DefaultCommandTimeout is how long a command run by RunCommand may take
+// unless it says otherwise.
+const DefaultCommandTimeout = time.Minute
+
+// DefaultCommandMaxOutput is how much of each of the stdout and stderr of a
+// command RunCommand keeps unless it says otherwise.
+const DefaultCommandMaxOutput = 1 * datasize.MB
+
+// DefaultCommandEnv are the variables of the environment of this process that
+// commands are run with, unless they say otherwise. Anything else, such as
+// credentials, is scrubbed.
+var DefaultCommandEnv = []string{"PATH", "HOME", "USER", "LANG", "LC_ALL", "TMPDIR"}
+
+// Command is a command for RunCommand to run.
+type Command struct {
+	Name string
+	Args []string
+	// Dir is the working directory of the command, or that of this process
+	// if empty.
+	Dir string
+	// Env are extra variables to run the command with, as KEY=value.
+	Env []string
+	// InheritEnv are the names of the variables of the environment of this
+	// process that the command is run with, DefaultCommandEnv if nil.
+	InheritEnv []string
+	Stdin      io.Reader
+	// Stdout and Stderr, if set, are streamed all of the output of the
+	// command as it runs, whatever MaxOutput.
+	Stdout io.Writer
+	Stderr io.Writer
+	// Timeout is how long the command may run, DefaultCommandTimeout if zero.
+	Timeout time.Duration
+	// MaxOutput is how much of each of stdout and stderr is kept in the
+	// result, DefaultCommandMaxOutput if zero. The rest is dropped.
+	MaxOutput datasize.ByteSize
+}
+
+func (c Command) String() string {
+	return strings.Join(append([]string{c.Name}, c.Args...), " ")
+}
+
+// CommandResult is what a command run by RunCommand output.
+type CommandResult struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+	// Whether some of the output was dropped for being over MaxOutput.
+	Truncated bool
+	Duration  time.Duration
+}
+
+// CommandError is returned by RunCommand for commands that couldn't be run,
+// timed out or exited with a non-zero code.
+type CommandError struct {
+	Command string
+	// ExitCode is -1 if the command didn't exit by itself.
+	ExitCode int
+	TimedOut bool
+	Stderr   string
+	Err      error
+}
+
+func (e *CommandError) Error() string {
+	msg := fmt.Sprintf("%s failed: %s", e.Command, e.Err)
+	if e.TimedOut {
+		msg = fmt.Sprintf("%s timed out: %s", e.Command, e.Err)
+	}
+	if stderr := strings.TrimSpace(e.Stderr); stderr != "" {
+		msg += ": " + stderr
+	}
+	return msg
+}
+
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+// RunCommand runs a command to completion, with a scrubbed environment, and
+// returns its output capped to MaxOutput. The command is killed once the
+// context is done or its timeout is reached.
+func RunCommand(ctx context.Context, command Command) (CommandResult, error) {
+	timeout := command.Timeout
+	if timeout == 0 {
+		timeout = DefaultCommandTimeout
+	}
+	maxOutput := command.MaxOutput
+	if maxOutput == 0 {
+		maxOutput = DefaultCommandMaxOutput
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	stdout := &cappedBuffer{max: int(maxOutput)}
+	stderr := &cappedBuffer{max: int(maxOutput)}
+	cmd := exec.CommandContext(ctx, command.Name, command.Args...) //nolint:gosec // callers decide what to run
+	cmd.Dir = command.Dir
+	cmd.Env = commandEnv(command)
+	cmd.Stdin = command.Stdin
+	cmd.Stdout = teeWriter(stdout, command.Stdout)
+	cmd.Stderr = teeWriter(stderr, command.Stderr)
+
+	start := time.Now()
+	err := cmd.Run()
+	result := CommandResult{
+		Stdout:    stdout.String(),
+		Stderr:    stderr.String(),
+		ExitCode:  cmd.ProcessState.ExitCode(),
+		Truncated: stdout.truncated || stderr.truncated,
+		Duration:  time.Since(start),
+	}
+	if err != nil {
+		commandErr := &CommandError{
+			Command:  command.String(),
+			ExitCode: result.ExitCode,
+			Stderr:   result.Stderr,
+			Err:      err,
+		}
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			commandErr.TimedOut = true
+			commandErr.Err = fmt.Errorf("%w after %s", ctx.Err(), timeout)
+		}
+		return result, commandErr
+	}
+	return result, nil
+}
+
+func commandEnv(command Command) []string {
+	inherit := command.InheritEnv
+	if inherit == nil {
+		inherit = DefaultCommandEnv
+	}
+	env := []string{}
+	for _, name := range inherit {
+		if value, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+value)
+		}
+	}
+	return append(env, command.Env...)
+}
+
+func teeWriter(buffer io.Writer, stream io.Writer) io.Writer {
+	if stream == nil {
+		return buffer
+	}
+	return io.MultiWriter(buffer, stream)
+}
+
+// cappedBuffer keeps the first max bytes written to it and drops the rest,
+// without failing the writes so that the command isn't killed by SIGPIPE.
+type cappedBuffer struct {
+	bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if room := b.max - b.Len(); room < len(p) {
+		b.truncated = true
+		if room > 0 {
+			b.Buffer.Write(p[:room])
+		}
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
@@ -0,0 +1,86 @@
+//go:build unit || !integration
+
+package system
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunCommand(t *testing.T) {
+	result, err := RunCommand(context.Background(), Command{
+		Name:  "sh",
+		Args:  []string{"-c", "cat; echo error >&2"},
+		Stdin: strings.NewReader("hello"),
+	})
+	require.NoError(t, err)
+	require.Equal(t, "hello", result.Stdout)
+	require.Equal(t, "error\n", result.Stderr)
+	require.Equal(t, 0, result.ExitCode)
+	require.False(t, result.Truncated)
+}
+
+func TestRunCommandExitCode(t *testing.T) {
+	result, err := RunCommand(context.Background(), Command{
+		Name: "sh",
+		Args: []string{"-c", "echo oops >&2; exit 3"},
+	})
+	var commandErr *CommandError
+	require.ErrorAs(t, err, &commandErr)
+	require.Equal(t, 3, commandErr.ExitCode)
+	require.Equal(t, 3, result.ExitCode)
+	require.False(t, commandErr.TimedOut)
+	require.Contains(t, err.Error(), "oops")
+}
+
+func TestRunCommandTimeout(t *testing.T) {
+	start := time.Now()
+	_, err := RunCommand(context.Background(), Command{
+		Name:    "sleep",
+		Args:    []string{"10"},
+		Timeout: 50 * time.Millisecond,
+	})
+	var commandErr *CommandError
+	require.ErrorAs(t, err, &commandErr)
+	require.True(t, commandErr.TimedOut)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestRunCommandTruncatesOutput(t *testing.T) {
+	var streamed strings.Builder
+	result, err := RunCommand(context.Background(), Command{
+		Name:      "sh",
+		Args:      []string{"-c", "printf 0123456789"},
+		MaxOutput: 4,
+		Stdout:    &streamed,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "0123", result.Stdout)
+	require.True(t, result.Truncated)
+	require.Equal(t, "0123456789", streamed.String())
+}
+
+func TestRunCommandScrubsEnvironment(t *testing.T) {
+	t.Setenv("BACALHAU_TEST_SECRET", "secret")
+	result, err := RunCommand(context.Background(), Command{
+		Name: "sh",
+		Args: []string{"-c", "echo \"$BACALHAU_TEST_SECRET|$EXTRA\""},
+		Env:  []string{"EXTRA=extra"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "|extra\n", result.Stdout)
+}
+
+func TestRunCommandNotFound(t *testing.T) {
+	_, err := RunCommand(context.Background(), Command{Name: "bacalhau-no-such-command"})
+	var commandErr *CommandError
+	require.ErrorAs(t, err, &commandErr)
+	require.Equal(t, -1, commandErr.ExitCode)
+	require.ErrorIs(t, err, exec.ErrNotFound)
+}
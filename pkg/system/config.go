@@ -24,8 +24,9 @@ const (
 )
 
 var (
-	globalClientID  string          // global cache of client ID
-	globalUserIDKey *rsa.PrivateKey // global cache of user ID key
+	globalClientID          string             // global cache of client ID
+	globalUserIDKey         *rsa.PrivateKey    // global cache of user ID key
+	globalRetiredUserIDKeys []retiredUserIDKey // global cache of rotated out user ID keys
 )
 
 // InitConfig ensures that a bacalhau config file exists and loads it.
@@ -41,12 +42,6 @@ func InitConfig() error {
 		return fmt.Errorf("failed to init config file: %w", err)
 	}
 
-	userIDKey, err := ensureUserIDKey(configDir)
-	if err != nil {
-		return fmt.Errorf("failed to init user ID key file: %w", err)
-	}
-	viper.SetDefault("user-id-key", userIDKey) // rsa key for identifying user
-
 	// Settings and initialisation for viper:
 	viper.SetConfigFile(configFile) // provided or created config file
 	viper.SetConfigType("yaml")     // config is always a yaml file
@@ -57,6 +52,14 @@ func InitConfig() error {
 		return fmt.Errorf("failed to load config file: %w", err)
 	}
 
+	// The user ID key is created after loading the config as it is encrypted
+	// with user-id-key-passphrase if that is set:
+	userIDKey, err := ensureUserIDKey(configDir)
+	if err != nil {
+		return fmt.Errorf("failed to init user ID key file: %w", err)
+	}
+	viper.SetDefault("user-id-key", userIDKey) // rsa key for identifying user
+
 	// Cache user ID key related data so we don't have to constantly load
 	// it from disk, and so that we fail fast if something is wrong:
 	globalUserIDKey, err = loadUserIDKey()
@@ -64,6 +67,11 @@ func InitConfig() error {
 		return fmt.Errorf("failed to load user ID key: %w", err)
 	}
 
+	globalRetiredUserIDKeys, err = loadRetiredUserIDKeys(viper.GetString("user-id-key"))
+	if err != nil {
+		return fmt.Errorf("failed to load retired user ID keys: %w", err)
+	}
+
 	globalClientID, err = loadClientID()
 	if err != nil {
 		return fmt.Errorf("failed to load client ID: %w", err)
@@ -101,11 +109,16 @@ func SignForClient(msg []byte) (string, error) {
 		panic("must call InitConfig() before calling SignForClient()")
 	}
 
+	return sign(globalUserIDKey, msg)
+}
+
+// sign signs a message with the given private key.
+func sign(key *rsa.PrivateKey, msg []byte) (string, error) {
 	hash := sigHash.New()
 	hash.Write(msg)
 	hashBytes := hash.Sum(nil)
 
-	sig, err := rsa.SignPKCS1v15(rand.Reader, globalUserIDKey, sigHash, hashBytes)
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, sigHash, hashBytes)
 	if err != nil {
 		return "", fmt.Errorf("failed to sign message: %w", err)
 	}
@@ -113,7 +126,8 @@ func SignForClient(msg []byte) (string, error) {
 	return base64.StdEncoding.EncodeToString(sig), nil
 }
 
-// VerifyForClient verifies a signed message with the user's public ID key.
+// VerifyForClient verifies a signed message with the user's public ID key,
+// or with one of the user's rotated out keys that has not expired yet.
 // NOTE: must be called after InitConfig() or system will panic.
 func VerifyForClient(msg []byte, sig string) (bool, error) {
 	if globalUserIDKey == nil {
@@ -130,7 +144,15 @@ func VerifyForClient(msg []byte, sig string) (bool, error) {
 	}
 
 	// A successful verification is indicated by a nil return:
-	return rsa.VerifyPKCS1v15(&globalUserIDKey.PublicKey, sigHash, hashBytes, sigBytes) == nil, nil
+	if rsa.VerifyPKCS1v15(&globalUserIDKey.PublicKey, sigHash, hashBytes, sigBytes) == nil {
+		return true, nil
+	}
+	for _, retiredKey := range activeRetiredUserIDKeys() {
+		if rsa.VerifyPKCS1v15(retiredKey.key, sigHash, hashBytes, sigBytes) == nil {
+			return true, nil
+		}
+	}
+	return false, nil
 }
 
 // Verify verifies a signed message with the given encoding of a public key.
@@ -154,7 +176,8 @@ func Verify(msg []byte, sig, publicKey string) error {
 	return rsa.VerifyPKCS1v15(key, sigHash, hashBytes, sigBytes)
 }
 
-// GetClientID returns a hash identifying a user based on their ID key.
+// GetClientID returns a hash identifying a user based on their first ID key,
+// which stays the same when the key is rotated.
 // NOTE: must be called after InitConfig() or system will panic.
 func GetClientID() string {
 	if globalClientID == "" {
@@ -185,6 +208,17 @@ func PublicKeyMatchesID(publicKey, clientID string) (bool, error) {
 	return clientID == convertToClientID(pkey), nil
 }
 
+// ClientIDFromPublicKey returns the client ID of the given base64-encoded
+// public key:
+func ClientIDFromPublicKey(publicKey string) (string, error) {
+	pkey, err := decodePublicKey(publicKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode public key: %w", err)
+	}
+
+	return convertToClientID(pkey), nil
+}
+
 // ensureDefaultConfigDir ensures that a bacalhau config dir exists.
 func ensureConfigDir() (string, error) {
 	configDir := os.Getenv("BACALHAU_DIR")
@@ -248,25 +282,8 @@ func ensureUserIDKey(configDir string) (string, error) {
 				return "", fmt.Errorf("failed to generate private key: %w", err)
 			}
 
-			keyBytes := x509.MarshalPKCS1PrivateKey(key)
-			keyBlock := pem.Block{
-				Type:  "RSA PRIVATE KEY",
-				Bytes: keyBytes,
-			}
-
-			var file *os.File
-			file, err = os.Create(keyFile)
-			if err != nil {
-				return "", fmt.Errorf("failed to create key file: %w", err)
-			}
-			if err = pem.Encode(file, &keyBlock); err != nil {
-				return "", fmt.Errorf("failed to encode key file: %w", err)
-			}
-			if err = file.Close(); err != nil {
-				return "", fmt.Errorf("failed to close key file: %w", err)
-			}
-			if err = os.Chmod(keyFile, util.OS_USER_RWX); err != nil {
-				return "", fmt.Errorf("failed to set permission on key file: %w", err)
+			if err = writeUserIDKey(keyFile, key); err != nil {
+				return "", err
 			}
 		} else {
 			return "", fmt.Errorf("failed to stat user ID key '%s': %w",
@@ -300,6 +317,11 @@ func loadUserIDKey() (*rsa.PrivateKey, error) {
 		return nil, fmt.Errorf("failed to decode user ID key file")
 	}
 
+	keyBlock, err = decodeUserIDKeyBlock(keyBlock)
+	if err != nil {
+		return nil, err
+	}
+
 	// TODO: Add support for both rsa _and_ ecdsa private keys, see cryto.PrivateKey.
 	//       Since we have access to the private key we can hack it by signing a
 	//       message twice and comparing them, rather than verifying directly.
@@ -316,8 +338,14 @@ func loadUserIDKey() (*rsa.PrivateKey, error) {
 	return key, nil
 }
 
-// loadClientID loads a hash identifying a user based on their ID key.
+// loadClientID loads a hash identifying a user based on their first ID key,
+// the first retired key if the key has been rotated.
+// NOTE: must be called after the retired user ID keys are loaded.
 func loadClientID() (string, error) {
+	if len(globalRetiredUserIDKeys) > 0 {
+		return convertToClientID(globalRetiredUserIDKeys[0].key), nil
+	}
+
 	key, err := loadUserIDKey()
 	if err != nil {
 		return "", fmt.Errorf("failed to load user ID key: %w", err)
@@ -3,9 +3,13 @@
 package system
 
 import (
+	"os"
 	"testing"
+	"time"
 
 	"github.com/filecoin-project/bacalhau/pkg/logger"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 )
@@ -82,3 +86,84 @@ func (suite *SystemConfigSuite) TestPublicKeyMatchesID() {
 	require.NoError(suite.T(), err)
 	require.True(suite.T(), ok)
 }
+
+func (suite *SystemConfigSuite) TestRotateUserIDKey() {
+	msg := []byte("Hello, world!")
+	oldSig, err := SignForClient(msg)
+	require.NoError(suite.T(), err)
+	oldID := GetClientID()
+	oldFingerprint := GetClientPublicKeyFingerprint()
+
+	require.NoError(suite.T(), RotateUserIDKey(time.Hour))
+	require.Equal(suite.T(), oldID, GetClientID())
+	require.NotEqual(suite.T(), oldFingerprint, GetClientPublicKeyFingerprint())
+
+	newSig, err := SignForClient(msg)
+	require.NoError(suite.T(), err)
+	for _, sig := range []string{oldSig, newSig} {
+		ok, err := VerifyForClient(msg, sig)
+		require.NoError(suite.T(), err)
+		require.True(suite.T(), ok)
+	}
+
+	// the rotation survives reloading the config
+	require.NoError(suite.T(), InitConfig())
+	require.Equal(suite.T(), oldID, GetClientID())
+	ok, err := VerifyForClient(msg, oldSig)
+	require.NoError(suite.T(), err)
+	require.True(suite.T(), ok)
+
+	// keys are no longer accepted once their grace period is over
+	require.NoError(suite.T(), RotateUserIDKey(0))
+	ok, err = VerifyForClient(msg, newSig)
+	require.NoError(suite.T(), err)
+	require.False(suite.T(), ok)
+	ok, err = VerifyForClient(msg, oldSig)
+	require.NoError(suite.T(), err)
+	require.True(suite.T(), ok)
+}
+
+func (suite *SystemConfigSuite) TestVerifyClientKey() {
+	id := GetClientID()
+	firstKey := GetClientPublicKey()
+	require.NoError(suite.T(), VerifyClientKey(id, firstKey, nil))
+
+	require.NoError(suite.T(), RotateUserIDKey(time.Hour))
+	require.NoError(suite.T(), RotateUserIDKey(time.Hour))
+	rotations := GetClientKeyRotations()
+	require.Len(suite.T(), rotations, 2)
+	require.Equal(suite.T(), firstKey, rotations[0].PreviousPublicKey)
+	require.NoError(suite.T(), VerifyClientKey(id, GetClientPublicKey(), rotations))
+
+	// the rotations must lead from the key of the client ID to the key
+	require.Error(suite.T(), VerifyClientKey(id, GetClientPublicKey(), nil))
+	require.Error(suite.T(), VerifyClientKey(id, GetClientPublicKey(), rotations[1:]))
+	require.Error(suite.T(), VerifyClientKey(id, firstKey, rotations))
+
+	// and each must be endorsed by the key before
+	forged := append([]model.ClientKeyRotation{}, rotations...)
+	forged[1].Expires = forged[1].Expires.Add(time.Hour)
+	require.Error(suite.T(), VerifyClientKey(id, GetClientPublicKey(), forged))
+}
+
+func (suite *SystemConfigSuite) TestEncryptedUserIDKey() {
+	suite.T().Setenv("BACALHAU_USER_ID_KEY_PASSPHRASE", "correct horse")
+	require.NoError(suite.T(), InitConfigForTesting(suite.T()))
+	id := GetClientID()
+
+	keyBytes, err := os.ReadFile(viper.GetString("user-id-key"))
+	require.NoError(suite.T(), err)
+	require.Contains(suite.T(), string(keyBytes), encryptedUserIDKeyBlockType)
+
+	require.NoError(suite.T(), InitConfig())
+	require.Equal(suite.T(), id, GetClientID())
+
+	suite.T().Setenv("BACALHAU_USER_ID_KEY_PASSPHRASE", "battery staple")
+	require.Error(suite.T(), InitConfig())
+}
+
+func (suite *SystemConfigSuite) TestPublicKeyFingerprint() {
+	fingerprint, err := PublicKeyFingerprint(GetClientPublicKey())
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), GetClientPublicKeyFingerprint(), fingerprint)
+}
@@ -9,17 +9,28 @@ const (
 	Production
 )
 
-// EnvironmentData captures data for a particular environment.
+// EnvironmentData captures data for a particular environment. Networks
+// defined in the config file have the same fields, named after the tags.
 type EnvironmentData struct {
 	// APIHost is the hostname of an environment's public API servers.
-	APIHost string
+	APIHost string `mapstructure:"api-host"`
 
 	// APIPort is the port that an environment serves the public API on.
-	APIPort int
+	APIPort int `mapstructure:"api-port"`
 
 	// IPFSSwarmAddresses lists the swarm addresses of an environment's IPFS
 	// nodes, for bootstrapping new local nodes.
-	IPFSSwarmAddresses []string
+	IPFSSwarmAddresses []string `mapstructure:"ipfs-swarm-addresses"`
+
+	// BootstrapAddresses lists the libp2p addresses of the nodes new nodes
+	// of an environment connect to. /dnsaddr addresses are looked up each
+	// time they are connected to, so the nodes behind them can change.
+	BootstrapAddresses []string `mapstructure:"bootstrap-addresses"`
+
+	// BootstrapRefreshURL is where nodes of an environment fetch more
+	// bootstrap addresses from, one per line, as they run. Not fetched if
+	// empty.
+	BootstrapRefreshURL string `mapstructure:"bootstrap-refresh-url"`
 }
 
 // Envs is a list of environment data for various environments:
@@ -32,6 +43,11 @@ var Envs = map[EnvironmentType]EnvironmentData{
 			"/ip4/35.245.61.251/tcp/1235/p2p/QmXaXu9N5GNetatsvwnTfQqNtSeKAD6uCmarbh3LMRYAcF",
 			"/ip4/35.245.251.239/tcp/1235/p2p/QmYgxZiySj3MRkwLSL4X2MF5F9f2PMhAE3LV49XkfNL1o3",
 		},
+		BootstrapAddresses: []string{
+			"/ip4/35.245.115.191/tcp/1235/p2p/QmdZQ7ZbhnvWY1J12XYKGHApJ6aufKyLNSvf8jZBrBaAVL",
+			"/ip4/35.245.61.251/tcp/1235/p2p/QmXaXu9N5GNetatsvwnTfQqNtSeKAD6uCmarbh3LMRYAcF",
+			"/ip4/35.245.251.239/tcp/1235/p2p/QmYgxZiySj3MRkwLSL4X2MF5F9f2PMhAE3LV49XkfNL1o3",
+		},
 	},
 
 	// TODO: fill these in
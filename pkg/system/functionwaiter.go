@@ -2,40 +2,146 @@ package system
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math/rand"
 	"time"
 
 	"github.com/rs/zerolog/log"
 )
 
+// RetryableError marks an error returned by the handler of a FunctionWaiter
+// as transient, so that the waiter tries again rather than giving up.
+type RetryableError struct {
+	Err error
+}
+
+// NewRetryableError wraps err so that FunctionWaiter retries on it.
+func NewRetryableError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &RetryableError{Err: err}
+}
+
+func (e *RetryableError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RetryableError) Unwrap() error {
+	return e.Err
+}
+
+// IsRetryableError returns whether err, or any error it wraps, is a
+// RetryableError.
+func IsRetryableError(err error) bool {
+	var retryable *RetryableError
+	return errors.As(err, &retryable)
+}
+
+// FunctionWaiter calls a handler until it returns true, an error that is not
+// retryable, or MaxAttempts is reached, sleeping between attempts.
+//
+// The delay between attempts starts at Delay and is multiplied by Backoff
+// after each attempt, up to MaxDelay. A Backoff of zero or one keeps the
+// delay fixed. Jitter is the fraction of the delay by which each sleep is
+// randomly lengthened or shortened, so that many waiters don't try at once.
 type FunctionWaiter struct {
 	Name        string
 	MaxAttempts int
 	Delay       time.Duration
-	Handler     func() (bool, error)
+	Backoff     float64
+	MaxDelay    time.Duration
+	Jitter      float64
+	// Handler is called on each attempt, unless HandlerWithContext is set.
+	Handler func() (bool, error)
+	// HandlerWithContext is called on each attempt with the context of Wait.
+	HandlerWithContext func(ctx context.Context) (bool, error)
+	// IsRetryable says whether an error returned by the handler is worth
+	// another attempt. By default only errors wrapped with NewRetryableError
+	// are, and any other error ends the wait.
+	IsRetryable func(err error) bool
 }
 
 func (waiter *FunctionWaiter) Wait(ctx context.Context) error {
-	currentAttempts := 0
+	isRetryable := waiter.IsRetryable
+	if isRetryable == nil {
+		isRetryable = IsRetryableError
+	}
 
+	currentAttempts := 0
+	delay := waiter.Delay
 	for {
 		if ctx.Err() != nil {
 			return ctx.Err()
 		}
-		result, err := waiter.Handler()
-		if err != nil {
+		result, err := waiter.attempt(ctx)
+		if err != nil && !isRetryable(err) {
 			return err
 		}
-		if result {
+		if err == nil && result {
 			return nil
 		}
 
 		currentAttempts++
 		if currentAttempts >= waiter.MaxAttempts {
-			log.Ctx(ctx).Warn().Str("name", waiter.Name).Int("max", waiter.MaxAttempts).Msg("max attempts reached")
+			log.Ctx(ctx).Warn().Str("name", waiter.Name).Int("max", waiter.MaxAttempts).Err(err).Msg("max attempts reached")
+			if err != nil {
+				return fmt.Errorf("%s max attempts reached: %d: %w", waiter.Name, waiter.MaxAttempts, err)
+			}
 			return fmt.Errorf("%s max attempts reached: %d", waiter.Name, waiter.MaxAttempts)
 		}
+		if err != nil {
+			log.Ctx(ctx).Debug().Str("name", waiter.Name).Err(err).Msg("retrying after error")
+		}
 
-		time.Sleep(waiter.Delay)
+		timer := time.NewTimer(waiter.jitter(delay))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+		delay = waiter.nextDelay(delay)
+	}
+}
+
+func (waiter *FunctionWaiter) attempt(ctx context.Context) (bool, error) {
+	if waiter.HandlerWithContext != nil {
+		return waiter.HandlerWithContext(ctx)
+	}
+	return waiter.Handler()
+}
+
+func (waiter *FunctionWaiter) nextDelay(delay time.Duration) time.Duration {
+	return NextDelay(delay, waiter.Backoff, waiter.MaxDelay)
+}
+
+func (waiter *FunctionWaiter) jitter(delay time.Duration) time.Duration {
+	return Jitter(delay, waiter.Jitter)
+}
+
+// NextDelay returns delay multiplied by backoff, up to maxDelay. A backoff of
+// zero or one keeps the delay as it is.
+func NextDelay(delay time.Duration, backoff float64, maxDelay time.Duration) time.Duration {
+	if backoff > 1 {
+		delay = time.Duration(float64(delay) * backoff)
+	}
+	if maxDelay > 0 && delay > maxDelay {
+		delay = maxDelay
+	}
+	return delay
+}
+
+// Jitter randomly lengthens or shortens delay by up to the given fraction of
+// it, so that many waiters don't try at once.
+func Jitter(delay time.Duration, fraction float64) time.Duration {
+	if fraction <= 0 || delay <= 0 {
+		return delay
+	}
+	spread := time.Duration(float64(delay) * fraction)
+	if spread <= 0 {
+		return delay
 	}
+	return delay - spread + time.Duration(rand.Int63n(int64(2*spread))) //nolint:gosec // no need for a secure random number here
 }
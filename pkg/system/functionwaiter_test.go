@@ -0,0 +1,103 @@
+//go:build unit || !integration
+
+package system
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFunctionWaiterRetriesRetryableErrors(t *testing.T) {
+	attempts := 0
+	waiter := &FunctionWaiter{
+		Name:        "test",
+		MaxAttempts: 5,
+		Delay:       time.Millisecond,
+		Handler: func() (bool, error) {
+			attempts++
+			if attempts < 3 {
+				return false, NewRetryableError(errors.New("transient"))
+			}
+			return true, nil
+		},
+	}
+	require.NoError(t, waiter.Wait(context.Background()))
+	require.Equal(t, 3, attempts)
+}
+
+func TestFunctionWaiterStopsOnFatalErrors(t *testing.T) {
+	attempts := 0
+	fatal := errors.New("fatal")
+	waiter := &FunctionWaiter{
+		Name:        "test",
+		MaxAttempts: 5,
+		Delay:       time.Millisecond,
+		Handler: func() (bool, error) {
+			attempts++
+			return false, fatal
+		},
+	}
+	require.ErrorIs(t, waiter.Wait(context.Background()), fatal)
+	require.Equal(t, 1, attempts)
+}
+
+func TestFunctionWaiterMaxAttemptsKeepsLastError(t *testing.T) {
+	transient := errors.New("transient")
+	waiter := &FunctionWaiter{
+		Name:        "test",
+		MaxAttempts: 3,
+		Delay:       time.Millisecond,
+		IsRetryable: func(err error) bool { return true },
+		Handler: func() (bool, error) {
+			return false, transient
+		},
+	}
+	err := waiter.Wait(context.Background())
+	require.ErrorIs(t, err, transient)
+	require.Contains(t, err.Error(), "max attempts reached: 3")
+}
+
+func TestFunctionWaiterCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	waiter := &FunctionWaiter{
+		Name:        "test",
+		MaxAttempts: 5,
+		Delay:       time.Hour,
+		HandlerWithContext: func(ctx context.Context) (bool, error) {
+			cancel()
+			return false, nil
+		},
+	}
+	start := time.Now()
+	require.ErrorIs(t, waiter.Wait(ctx), context.Canceled)
+	require.Less(t, time.Since(start), time.Second)
+}
+
+func TestFunctionWaiterBackoff(t *testing.T) {
+	waiter := &FunctionWaiter{
+		Delay:    10 * time.Millisecond,
+		Backoff:  2,
+		MaxDelay: 50 * time.Millisecond,
+	}
+	var delays []time.Duration
+	delay := waiter.Delay
+	for i := 0; i < 4; i++ {
+		delay = waiter.nextDelay(delay)
+		delays = append(delays, delay)
+	}
+	require.Equal(t, []time.Duration{
+		20 * time.Millisecond, 40 * time.Millisecond, 50 * time.Millisecond, 50 * time.Millisecond,
+	}, delays)
+
+	waiter.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		d := waiter.jitter(time.Second)
+		require.GreaterOrEqual(t, d, 500*time.Millisecond)
+		require.Less(t, d, 1500*time.Millisecond)
+	}
+	require.Equal(t, time.Duration(0), Jitter(0, 0.5))
+}
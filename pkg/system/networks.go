@@ -0,0 +1,94 @@
+package system
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	// NetworkConfigKey selects the network to use, by name. It can also be
+	// set with BACALHAU_NETWORK or the --network flag.
+	NetworkConfigKey = "network"
+
+	// NetworksConfigKey holds the networks defined in the config file, as a
+	// map of names to EnvironmentData, e.g.:
+	//
+	//	networks:
+	//	  lab:
+	//	    api-host: bacalhau.lab.example.org
+	//	    api-port: 1234
+	//	    bootstrap-addresses:
+	//	      - /dnsaddr/bootstrap.lab.example.org
+	NetworksConfigKey = "networks"
+
+	// DefaultNetwork is used when no network is selected.
+	DefaultNetwork = "production"
+)
+
+// builtinNetworks names the environments in Envs, which networks defined in
+// the config file with the same name replace.
+var builtinNetworks = map[string]EnvironmentType{
+	"production":  Production,
+	"staging":     Staging,
+	"development": Development,
+}
+
+// GetNetwork returns the network with the given name, from the config file
+// or else from the built-in environments.
+func GetNetwork(name string) (EnvironmentData, error) {
+	networks, err := configNetworks()
+	if err != nil {
+		return EnvironmentData{}, err
+	}
+	if network, ok := networks[name]; ok {
+		return network, nil
+	}
+	if envType, ok := builtinNetworks[name]; ok {
+		return Envs[envType], nil
+	}
+	return EnvironmentData{}, fmt.Errorf("unknown network %q, expected one of %s", name, strings.Join(NetworkNames(), ", "))
+}
+
+// GetSelectedNetwork returns the name of the network selected with
+// NetworkConfigKey, or DefaultNetwork if none is.
+func GetSelectedNetwork() string {
+	if name := viper.GetString(NetworkConfigKey); name != "" {
+		return name
+	}
+	return DefaultNetwork
+}
+
+// SelectedNetwork returns the network selected with NetworkConfigKey.
+func SelectedNetwork() (EnvironmentData, error) {
+	return GetNetwork(GetSelectedNetwork())
+}
+
+// NetworkNames returns the names of all the networks that can be selected.
+func NetworkNames() []string {
+	names := []string{}
+	for name := range builtinNetworks {
+		names = append(names, name)
+	}
+	networks, _ := configNetworks()
+	for name := range networks {
+		if _, ok := builtinNetworks[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func configNetworks() (map[string]EnvironmentData, error) {
+	networks := map[string]EnvironmentData{}
+	if !viper.IsSet(NetworksConfigKey) {
+		return networks, nil
+	}
+	if err := viper.UnmarshalKey(NetworksConfigKey, &networks); err != nil {
+		return nil, fmt.Errorf("invalid %s in config: %w", NetworksConfigKey, err)
+	}
+	return networks, nil
+}
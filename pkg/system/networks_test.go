@@ -0,0 +1,53 @@
+//go:build unit || !integration
+
+package system
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetNetwork(t *testing.T) {
+	t.Cleanup(func() {
+		viper.Set(NetworksConfigKey, nil)
+		viper.Set(NetworkConfigKey, "")
+	})
+	viper.Set(NetworksConfigKey, map[string]interface{}{
+		"lab": map[string]interface{}{
+			"api-host":            "bacalhau.lab.example.org",
+			"api-port":            4321,
+			"bootstrap-addresses": []string{"/dnsaddr/bootstrap.lab.example.org"},
+		},
+		"staging": map[string]interface{}{
+			"api-host": "bacalhau.staging.example.org",
+		},
+	})
+
+	network, err := GetNetwork("lab")
+	require.NoError(t, err)
+	require.Equal(t, EnvironmentData{
+		APIHost:            "bacalhau.lab.example.org",
+		APIPort:            4321,
+		BootstrapAddresses: []string{"/dnsaddr/bootstrap.lab.example.org"},
+	}, network)
+
+	// networks in the config replace the built-in ones
+	network, err = GetNetwork("staging")
+	require.NoError(t, err)
+	require.Equal(t, "bacalhau.staging.example.org", network.APIHost)
+
+	network, err = GetNetwork("production")
+	require.NoError(t, err)
+	require.Equal(t, Envs[Production], network)
+
+	_, err = GetNetwork("moon")
+	require.ErrorContains(t, err, "development, lab, production, staging")
+
+	require.Equal(t, DefaultNetwork, GetSelectedNetwork())
+	viper.Set(NetworkConfigKey, "lab")
+	network, err = SelectedNetwork()
+	require.NoError(t, err)
+	require.Equal(t, 4321, network.APIPort)
+}
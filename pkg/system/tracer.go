@@ -11,6 +11,7 @@ import (
 
 	_ "github.com/filecoin-project/bacalhau/pkg/logger"
 	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/storage/util"
 	"github.com/filecoin-project/bacalhau/pkg/version"
 	"github.com/joho/godotenv"
 	"github.com/spf13/viper"
@@ -159,11 +160,48 @@ func setViperFromLegacyHoneycombValues() {
 	})
 }
 
+// otelTraceProvider creates a provider that exports sampled spans to an OTLP
+// collector at trace_endpoint and/or to the local file at trace_file.
 func otelTraceProvider() (*sdktrace.TracerProvider, error) {
-	if !viper.IsSet("trace_endpoint") {
-		return nil, fmt.Errorf("no trace endpoint configured")
+	if !viper.IsSet("trace_endpoint") && !viper.IsSet("trace_file") {
+		return nil, fmt.Errorf("no trace endpoint or file configured")
 	}
 
+	sampler, err := newTraceSampler()
+	if err != nil {
+		return nil, err
+	}
+	providerOptions := []sdktrace.TracerProviderOption{
+		sdktrace.WithSampler(sampler),
+		sdktrace.WithResource(
+			resource.NewWithAttributes(
+				semconv.SchemaURL,
+				semconv.ServiceNameKey.String("bacalhau"),
+			),
+		),
+	}
+
+	var exp sdktrace.SpanExporter
+	if viper.IsSet("trace_endpoint") {
+		exp, err = otlpExporter()
+		if err != nil {
+			return nil, err
+		}
+		providerOptions = append(providerOptions, sdktrace.WithSyncer(exp)) // TODO: use WithBatcher in prod
+	}
+
+	if viper.IsSet("trace_file") {
+		exp, err = fileExporter(viper.GetString("trace_file"))
+		if err != nil {
+			return nil, err
+		}
+		providerOptions = append(providerOptions, sdktrace.WithBatcher(exp))
+	}
+
+	return sdktrace.NewTracerProvider(providerOptions...), nil
+}
+
+func otlpExporter() (sdktrace.SpanExporter, error) {
 	options := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(viper.GetString("trace_endpoint"))}
 
 	if viper.IsSet("trace_insecure") && viper.GetBool("trace_insecure") {
@@ -178,20 +216,36 @@ func otelTraceProvider() (*sdktrace.TracerProvider, error) {
 	}
 
 	// The context passed in to the exporter is only passed to the client and used when connecting to the endpoint
-	exp, err := otlptrace.New(context.Background(), otlptracegrpc.NewClient(options...))
+	return otlptrace.New(context.Background(), otlptracegrpc.NewClient(options...))
+}
+
+// fileExporter appends spans to a local file, one JSON object per span.
+func fileExporter(path string) (sdktrace.SpanExporter, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, util.OS_USER_RW)
 	if err != nil {
+		return nil, fmt.Errorf("failed to open trace file: %w", err)
+	}
+
+	exp, err := stdouttrace.New(stdouttrace.WithWriter(file))
+	if err != nil {
+		_ = file.Close()
 		return nil, err
 	}
+	return &closingExporter{SpanExporter: exp, closer: file}, nil
+}
 
-	return sdktrace.NewTracerProvider(
-		sdktrace.WithSyncer(exp), // TODO: use WithBatcher in prod
-		sdktrace.WithResource(
-			resource.NewWithAttributes(
-				semconv.SchemaURL,
-				semconv.ServiceNameKey.String("bacalhau"),
-			),
-		),
-	), nil
+// closingExporter closes the writer of an exporter when it is shut down.
+type closingExporter struct {
+	sdktrace.SpanExporter
+	closer io.Closer
+}
+
+func (e *closingExporter) Shutdown(ctx context.Context) error {
+	err := e.SpanExporter.Shutdown(ctx)
+	if closeErr := e.closer.Close(); err == nil {
+		err = closeErr
+	}
+	return err
 }
 
 func loggerTraceProvider() (*sdktrace.TracerProvider, error) {
@@ -0,0 +1,77 @@
+package system
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel/baggage"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// traceSampler decides which spans are exported:
+//   - spans of the jobs in jobs are always sampled, so that the traces of a
+//     single problematic job can be captured
+//   - spans whose parent was sampled are sampled, so traces are kept whole
+//   - otherwise spans are sampled by trace ID at the ratio of their operation,
+//     or at the default ratio
+type traceSampler struct {
+	defaultSampler    sdktrace.Sampler
+	operationSamplers map[string]sdktrace.Sampler
+	jobs              map[string]bool
+}
+
+// newTraceSampler creates a sampler from the trace_sample_ratio,
+// trace_sample_operations and trace_sample_jobs config.
+func newTraceSampler() (sdktrace.Sampler, error) {
+	ratio := 1.0
+	if viper.IsSet("trace_sample_ratio") {
+		ratio = viper.GetFloat64("trace_sample_ratio")
+	}
+
+	operationSamplers := map[string]sdktrace.Sampler{}
+	for operation, value := range viper.GetStringMapString("trace_sample_operations") {
+		operationRatio, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sample ratio for operation %s: %w", operation, err)
+		}
+		operationSamplers[operation] = sdktrace.TraceIDRatioBased(operationRatio)
+	}
+
+	jobs := map[string]bool{}
+	for _, jobID := range viper.GetStringSlice("trace_sample_jobs") {
+		jobs[jobID] = true
+	}
+
+	return &traceSampler{
+		defaultSampler:    sdktrace.TraceIDRatioBased(ratio),
+		operationSamplers: operationSamplers,
+		jobs:              jobs,
+	}, nil
+}
+
+func (s *traceSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	parent := oteltrace.SpanContextFromContext(p.ParentContext)
+	jobID := baggage.FromContext(p.ParentContext).Member(model.TracerAttributeNameJobID).Value()
+	if parent.IsSampled() || (jobID != "" && s.jobs[jobID]) {
+		return sdktrace.SamplingResult{
+			Decision:   sdktrace.RecordAndSample,
+			Tracestate: parent.TraceState(),
+		}
+	}
+
+	if sampler, ok := s.operationSamplers[p.Name]; ok {
+		return sampler.ShouldSample(p)
+	}
+	return s.defaultSampler.ShouldSample(p)
+}
+
+func (s *traceSampler) Description() string {
+	return fmt.Sprintf("TraceSampler{default:%s,operations:%d,jobs:%d}",
+		s.defaultSampler.Description(), len(s.operationSamplers), len(s.jobs))
+}
+
+// compile-time interface check
+var _ sdktrace.Sampler = (*traceSampler)(nil)
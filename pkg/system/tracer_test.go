@@ -4,8 +4,11 @@ package system
 
 import (
 	"context"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -33,6 +36,55 @@ func TestTracer(t *testing.T) {
 	require.Equal(t, "service/span2", sr.traces[1].Name())
 }
 
+func setTraceConfig(t *testing.T, key string, value interface{}) {
+	viper.Set(key, value)
+	t.Cleanup(func() {
+		viper.Set(key, nil)
+	})
+}
+
+func TestTraceSampler(t *testing.T) {
+	setTraceConfig(t, "trace_sample_ratio", 0)
+	setTraceConfig(t, "trace_sample_operations", map[string]string{"service/important": "1"})
+	setTraceConfig(t, "trace_sample_jobs", []string{"job-1"})
+
+	sampler, err := newTraceSampler()
+	require.NoError(t, err)
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSampler(sampler))
+	tracer := tp.Tracer("test")
+
+	ctx := context.Background()
+	_, span := tracer.Start(ctx, "service/other")
+	require.False(t, span.SpanContext().IsSampled())
+
+	ctx, span = tracer.Start(ctx, "service/important")
+	require.True(t, span.SpanContext().IsSampled())
+	// children of sampled spans are sampled
+	_, span = tracer.Start(ctx, "service/other")
+	require.True(t, span.SpanContext().IsSampled())
+
+	// spans of sampled jobs are sampled
+	_, span = tracer.Start(AddJobIDToBaggage(context.Background(), "job-2"), "service/other")
+	require.False(t, span.SpanContext().IsSampled())
+	_, span = tracer.Start(AddJobIDToBaggage(context.Background(), "job-1"), "service/other")
+	require.True(t, span.SpanContext().IsSampled())
+}
+
+func TestTraceFile(t *testing.T) {
+	traceFile := filepath.Join(t.TempDir(), "traces.json")
+	setTraceConfig(t, "trace_file", traceFile)
+
+	tp, err := otelTraceProvider()
+	require.NoError(t, err)
+	_, span := tp.Tracer("test").Start(context.Background(), "service/span")
+	span.End()
+	require.NoError(t, tp.Shutdown(context.Background()))
+
+	data, err := os.ReadFile(traceFile)
+	require.NoError(t, err)
+	require.Contains(t, string(data), "service/span")
+}
+
 // SpanRecorder is an implementation of sdktrace.SpanProcessor that records
 // spans as they are created.
 type SpanRecorder struct {
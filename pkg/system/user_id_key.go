@@ -0,0 +1,390 @@
+package system
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/storage/util"
+	"github.com/spf13/viper"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	userIDKeyBlockType          = "RSA PRIVATE KEY"
+	encryptedUserIDKeyBlockType = "ENCRYPTED RSA PRIVATE KEY"
+	retiredUserIDKeyBlockType   = "RSA PUBLIC KEY"
+
+	// PEM headers of encrypted and retired keys
+	saltHeader      = "Salt"
+	nonceHeader     = "Nonce"
+	expiresHeader   = "Expires"
+	signatureHeader = "Signature"
+
+	// scrypt parameters for deriving the key that encrypts the user ID key,
+	// as recommended for interactive logins
+	scryptN      = 1 << 15
+	scryptR      = 8
+	scryptP      = 1
+	scryptKeyLen = 32
+	saltSize     = 16
+)
+
+// retiredUserIDKey is a user ID key that has been rotated out, but whose
+// signatures are still accepted until it expires. The retired keys of a user
+// are kept in the order they were rotated out in, even once they expire, as
+// the first one is the key the client ID derives from.
+type retiredUserIDKey struct {
+	key     *rsa.PublicKey
+	expires time.Time
+	// signature of the key that replaced this one and of the expiry, signed
+	// with this key
+	signature string
+}
+
+// RotateUserIDKey replaces the user's ID key with a new one, endorsed by the
+// old one so that the client ID stays the same. Messages signed with the old
+// key are still verified by VerifyForClient, and by the requester nodes, until
+// gracePeriod has passed.
+// NOTE: must be called after InitConfig() or system will panic.
+func RotateUserIDKey(gracePeriod time.Duration) error {
+	if globalUserIDKey == nil {
+		panic("must call InitConfig() before calling RotateUserIDKey()")
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, bitsPerKey)
+	if err != nil {
+		return fmt.Errorf("failed to generate private key: %w", err)
+	}
+
+	expires := time.Now().Add(gracePeriod).UTC().Truncate(time.Second)
+	signature, err := sign(globalUserIDKey, keyRotationMessage(encodePublicKey(&key.PublicKey), expires))
+	if err != nil {
+		return fmt.Errorf("failed to endorse new user ID key: %w", err)
+	}
+
+	keyFile := viper.GetString("user-id-key")
+	retiredKeys := append(globalRetiredUserIDKeys, retiredUserIDKey{ //nolint:gocritic
+		key:       &globalUserIDKey.PublicKey,
+		expires:   expires,
+		signature: signature,
+	})
+	if err = writeRetiredUserIDKeys(retiredUserIDKeysFile(keyFile), retiredKeys); err != nil {
+		return fmt.Errorf("failed to retire user ID key: %w", err)
+	}
+	if err = writeUserIDKey(keyFile, key); err != nil {
+		return err
+	}
+
+	globalUserIDKey = key
+	globalRetiredUserIDKeys = retiredKeys
+	return nil
+}
+
+// GetClientKeyRotations returns the rotations of the user's ID key, from the
+// key the client ID derives from to the current one, which requester nodes
+// need to accept the current key for the client ID.
+// NOTE: must be called after InitConfig() or system will panic.
+func GetClientKeyRotations() []model.ClientKeyRotation {
+	if globalUserIDKey == nil {
+		panic("must call InitConfig() before calling GetClientKeyRotations()")
+	}
+
+	var rotations []model.ClientKeyRotation
+	for i, retiredKey := range globalRetiredUserIDKeys {
+		next := &globalUserIDKey.PublicKey
+		if i+1 < len(globalRetiredUserIDKeys) {
+			next = globalRetiredUserIDKeys[i+1].key
+		}
+		rotations = append(rotations, model.ClientKeyRotation{
+			PreviousPublicKey: encodePublicKey(retiredKey.key),
+			PublicKey:         encodePublicKey(next),
+			Expires:           retiredKey.expires,
+			Signature:         retiredKey.signature,
+		})
+	}
+	return rotations
+}
+
+// VerifyClientKey checks that the given base64-encoded public key is a key
+// of the client with the given ID: either the key the ID derives from, or a
+// key the rotations lead to from it, each endorsed by the one before.
+// Whether the rotated out keys have expired is up to the caller.
+func VerifyClientKey(clientID, publicKey string, rotations []model.ClientKeyRotation) error {
+	firstKey := publicKey
+	if len(rotations) > 0 {
+		firstKey = rotations[0].PreviousPublicKey
+	}
+	ok, err := PublicKeyMatchesID(firstKey, clientID)
+	if err != nil {
+		return fmt.Errorf("error verifying client ID: %w", err)
+	}
+	if !ok {
+		return fmt.Errorf("client's public key does not match client ID")
+	}
+
+	for i, rotation := range rotations {
+		next := publicKey
+		if i+1 < len(rotations) {
+			next = rotations[i+1].PreviousPublicKey
+		}
+		if rotation.PublicKey != next {
+			return fmt.Errorf("rotation %d of client's key does not lead to the next key", i)
+		}
+		err = Verify(keyRotationMessage(rotation.PublicKey, rotation.Expires), rotation.Signature, rotation.PreviousPublicKey)
+		if err != nil {
+			return fmt.Errorf("rotation %d of client's key is not signed by the previous key: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// keyRotationMessage is what the previous key signs to endorse a new key.
+func keyRotationMessage(publicKey string, expires time.Time) []byte {
+	return []byte(publicKey + "\n" + expires.UTC().Format(time.RFC3339))
+}
+
+// GetClientKeyInfo returns the client ID, public key and fingerprint of the
+// user's current ID key.
+// NOTE: must be called after InitConfig() or system will panic.
+func GetClientKeyInfo() model.ClientKeyInfo {
+	return model.ClientKeyInfo{
+		ClientID:    GetClientID(),
+		PublicKey:   GetClientPublicKey(),
+		Fingerprint: GetClientPublicKeyFingerprint(),
+	}
+}
+
+// GetClientPublicKeyFingerprint returns the fingerprint of the user's public
+// ID key, for allowlisting the user without sharing the whole key.
+// NOTE: must be called after InitConfig() or system will panic.
+func GetClientPublicKeyFingerprint() string {
+	if globalUserIDKey == nil {
+		panic("must call InitConfig() before calling GetClientPublicKeyFingerprint()")
+	}
+
+	return fingerprint(&globalUserIDKey.PublicKey)
+}
+
+// PublicKeyFingerprint returns the fingerprint of the given base64-encoded
+// public key, see GetClientPublicKeyFingerprint.
+func PublicKeyFingerprint(publicKey string) (string, error) {
+	key, err := decodePublicKey(publicKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode public key: %w", err)
+	}
+
+	return fingerprint(key), nil
+}
+
+func fingerprint(key *rsa.PublicKey) string {
+	hash := sha256.Sum256(x509.MarshalPKCS1PublicKey(key))
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(hash[:])
+}
+
+// userIDKeyPassphrase returns the passphrase the user ID key is encrypted
+// with, if any.
+func userIDKeyPassphrase() string {
+	return viper.GetString("user-id-key-passphrase")
+}
+
+// writeUserIDKey atomically writes a user ID key to a file, encrypted with
+// the configured passphrase if there is one.
+func writeUserIDKey(keyFile string, key *rsa.PrivateKey) error {
+	keyBlock := &pem.Block{
+		Type:  userIDKeyBlockType,
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	}
+	if passphrase := userIDKeyPassphrase(); passphrase != "" {
+		var err error
+		keyBlock, err = encryptUserIDKeyBlock(keyBlock, passphrase)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt key file: %w", err)
+		}
+	}
+
+	if err := writeFileAtomically(keyFile, pem.EncodeToMemory(keyBlock)); err != nil {
+		return fmt.Errorf("failed to write key file: %w", err)
+	}
+	return nil
+}
+
+// decodeUserIDKeyBlock returns the plain PKCS1 key block of a user ID key,
+// decrypting it with the configured passphrase if it is encrypted.
+func decodeUserIDKeyBlock(keyBlock *pem.Block) (*pem.Block, error) {
+	if keyBlock.Type != encryptedUserIDKeyBlockType {
+		return keyBlock, nil
+	}
+
+	passphrase := userIDKeyPassphrase()
+	if passphrase == "" {
+		return nil, fmt.Errorf("user ID key is encrypted but user-id-key-passphrase is not set")
+	}
+	return decryptUserIDKeyBlock(keyBlock, passphrase)
+}
+
+func encryptUserIDKeyBlock(keyBlock *pem.Block, passphrase string) (*pem.Block, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	gcm, err := newUserIDKeyCipher(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return &pem.Block{
+		Type: encryptedUserIDKeyBlockType,
+		Headers: map[string]string{
+			saltHeader:  base64.StdEncoding.EncodeToString(salt),
+			nonceHeader: base64.StdEncoding.EncodeToString(nonce),
+		},
+		Bytes: gcm.Seal(nil, nonce, keyBlock.Bytes, nil),
+	}, nil
+}
+
+func decryptUserIDKeyBlock(keyBlock *pem.Block, passphrase string) (*pem.Block, error) {
+	salt, err := base64.StdEncoding.DecodeString(keyBlock.Headers[saltHeader])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode salt: %w", err)
+	}
+	nonce, err := base64.StdEncoding.DecodeString(keyBlock.Headers[nonceHeader])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode nonce: %w", err)
+	}
+	gcm, err := newUserIDKeyCipher(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != gcm.NonceSize() {
+		return nil, fmt.Errorf("invalid nonce")
+	}
+
+	keyBytes, err := gcm.Open(nil, nonce, keyBlock.Bytes, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt user ID key, is the passphrase correct?")
+	}
+	return &pem.Block{Type: userIDKeyBlockType, Bytes: keyBytes}, nil
+}
+
+func newUserIDKeyCipher(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, scryptKeyLen)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// retiredUserIDKeysFile returns the file the public keys of rotated out user
+// ID keys are kept in, next to the user ID key.
+func retiredUserIDKeysFile(keyFile string) string {
+	return keyFile + ".retired"
+}
+
+// loadRetiredUserIDKeys loads the retired user ID keys, in the order they were
+// rotated out in.
+func loadRetiredUserIDKeys(keyFile string) ([]retiredUserIDKey, error) {
+	data, err := os.ReadFile(retiredUserIDKeysFile(keyFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var retiredKeys []retiredUserIDKey
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != retiredUserIDKeyBlockType {
+			continue
+		}
+		expires, err := time.Parse(time.RFC3339, block.Headers[expiresHeader])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse expiry of retired key: %w", err)
+		}
+		key, err := x509.ParsePKCS1PublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse retired key: %w", err)
+		}
+		retiredKeys = append(retiredKeys, retiredUserIDKey{
+			key:       key,
+			expires:   expires,
+			signature: block.Headers[signatureHeader],
+		})
+	}
+	return retiredKeys, nil
+}
+
+func writeRetiredUserIDKeys(file string, retiredKeys []retiredUserIDKey) error {
+	var buf bytes.Buffer
+	for _, retiredKey := range retiredKeys {
+		err := pem.Encode(&buf, &pem.Block{
+			Type: retiredUserIDKeyBlockType,
+			Headers: map[string]string{
+				expiresHeader:   retiredKey.expires.UTC().Format(time.RFC3339),
+				signatureHeader: retiredKey.signature,
+			},
+			Bytes: x509.MarshalPKCS1PublicKey(retiredKey.key),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return writeFileAtomically(file, buf.Bytes())
+}
+
+// activeRetiredUserIDKeys returns the retired user ID keys that have not
+// expired yet.
+func activeRetiredUserIDKeys() []retiredUserIDKey {
+	var active []retiredUserIDKey
+	for _, retiredKey := range globalRetiredUserIDKeys {
+		if time.Now().Before(retiredKey.expires) {
+			active = append(active, retiredKey)
+		}
+	}
+	return active
+}
+
+// writeFileAtomically writes data to a file readable only by the user, so
+// that a key is never left half written.
+func writeFileAtomically(file string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(file), "."+filepath.Base(file)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(tmp.Name(), util.OS_USER_RW); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
@@ -0,0 +1,37 @@
+package compute
+
+import (
+	"context"
+
+	"github.com/filecoin-project/bacalhau/pkg/compute/frontend"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+)
+
+func (s *ComputeSuite) TestExplainBid() {
+	ctx := context.Background()
+	nodeID := s.T().Name()
+
+	s.Run("would bid", func() {
+		response, err := s.node.Frontend.ExplainBid(ctx, frontend.ExplainBidRequest{Job: generateJob()})
+		s.NoError(err)
+		s.True(response.Bid)
+		s.Empty(response.Reasons)
+		s.Equal(nodeID, response.NodeID)
+	})
+
+	s.Run("every reason not to bid", func() {
+		s.config.JobSelectionPolicy.RejectStatelessJobs = true
+		s.setupNode()
+
+		job := addResourceUsage(generateJob(), model.ResourceUsageData{CPU: s.config.JobResourceLimits.CPU + 1})
+		response, err := s.node.Frontend.ExplainBid(ctx, frontend.ExplainBidRequest{Job: job})
+		s.NoError(err)
+		s.False(response.Bid)
+		s.Contains(response.Reasons, "job requirements exceed max allowed per job")
+		s.Contains(response.Reasons, "stateless jobs not accepted")
+
+		// nothing was bid on
+		_, err = s.node.ExecutionStore.GetExecutions(ctx, model.GetShardID(job.ID, 0))
+		s.Error(err)
+	})
+}
@@ -34,7 +34,7 @@ func addResourceUsage(job model.Job, usage model.ResourceUsageData) model.Job {
 		CPU:    fmt.Sprintf("%f", usage.CPU),
 		Memory: fmt.Sprintf("%d", usage.Memory),
 		Disk:   fmt.Sprintf("%d", usage.Disk),
-		GPU:    fmt.Sprintf("%d", usage.GPU),
+		GPU:    fmt.Sprintf("%f", usage.GPU),
 	}
 	return job
 }
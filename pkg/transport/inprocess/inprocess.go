@@ -29,6 +29,7 @@ type InProcessTransport struct {
 	// (a bit like the libp2p transport where every event is written to the
 	// event handlers of every node)
 	publishHandler func(ctx context.Context, ev model.JobEvent) error
+	sequencer      *transport.EventSequencer
 }
 
 /*
@@ -45,6 +46,7 @@ func NewInprocessTransport() (*InProcessTransport, error) {
 	res := &InProcessTransport{
 		id:                 hostID.String(),
 		subscribeFunctions: []transport.SubscribeFn{},
+		sequencer:          transport.NewEventSequencer(),
 	}
 	res.mutex.EnableTracerWithOpts(sync.Opts{
 		Threshold: 10 * time.Millisecond,
@@ -83,6 +85,7 @@ func (t *InProcessTransport) GetEvents() []model.JobEvent {
 */
 
 func (t *InProcessTransport) Publish(ctx context.Context, ev model.JobEvent) error {
+	ev = t.sequencer.Sequence(ev)
 	if t.publishHandler != nil {
 		// we have been given an external function to call with our event
 		return t.publishHandler(ctx, ev)
@@ -114,13 +117,14 @@ func (t *InProcessTransport) applyEvent(ctx context.Context, ev model.JobEvent)
 	t.mutex.Lock()
 	defer t.mutex.Unlock()
 	ctx = logger.ContextWithNodeIDLogger(context.Background(), t.HostID())
+	ctx = logger.ContextWithJobShardLogger(ctx, ev.JobID, ev.ShardIndex)
 	t.seenEvents = append(t.seenEvents, ev)
 	for _, fn := range t.subscribeFunctions {
 		fnToCall := fn
 		go func() {
 			err := fnToCall(ctx, ev)
 			if err != nil {
-				log.Error().Msgf("error in handle event: %s\n%+v", err, ev)
+				log.Ctx(ctx).Error().Msgf("error in handle event: %s\n%+v", err, ev)
 			}
 		}()
 	}
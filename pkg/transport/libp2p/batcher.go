@@ -0,0 +1,249 @@
+package libp2p
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	realsync "sync"
+	"time"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/rs/zerolog/log"
+)
+
+// BatchingConfig controls how outgoing job events are grouped before they
+// are gossiped. Batching trades a little latency for fewer pubsub messages,
+// which matters on busy networks where every message is amplified by
+// gossipsub.
+type BatchingConfig struct {
+	// Maximum number of events in a single message. A value of 1 or less
+	// disables batching.
+	MaxBatchSize int
+	// Maximum size in bytes of a batched message. Batches that would grow
+	// larger are sent as individual events instead.
+	MaxBatchBytes int
+	// How long an event can wait for the batch to fill up before the batch
+	// is sent anyway.
+	FlushInterval time.Duration
+}
+
+// Batching is disabled by default so that events are delivered as soon as
+// they are published.
+var DefaultBatchingConfig = BatchingConfig{
+	MaxBatchSize:  1,
+	MaxBatchBytes: 512 * 1024,
+	FlushInterval: 50 * time.Millisecond,
+}
+
+func (c BatchingConfig) Enabled() bool {
+	return c.MaxBatchSize > 1
+}
+
+// a batch of events on the wire. Nodes that predate batching cannot read
+// these, so batching should only be enabled once the whole network has been
+// upgraded.
+type jobEventBatch struct {
+	SentTime        time.Time          `json:"sent_time"`
+	Events          []jobEventEnvelope `json:"batch"`
+	ProtocolVersion string             `json:"protocol_version,omitempty"`
+}
+
+// compactableEvents are events that fully replace whatever was sent before
+// them for the same job, so only the latest one in a batch needs to go out.
+var compactableEvents = map[model.JobEventType]bool{
+	model.JobEventDealUpdated: true,
+}
+
+// eventBatcher groups job events into batches, dropping exact duplicates
+// and compacting superseded updates, and hands each batch to publishFn.
+//
+// Batched events are published after add returns, so errors publishing them
+// are logged and counted rather than returned to the caller.
+type eventBatcher struct {
+	config    BatchingConfig
+	publishFn func(ctx context.Context, data []byte) error
+	// the context timer flushes are published with
+	ctx context.Context
+
+	mutex     realsync.Mutex
+	pending   []jobEventEnvelope
+	seen      map[string]bool
+	compacted map[string]int
+	timer     *time.Timer
+	stopped   bool
+	// the number of batches taken from pending so far
+	flushes uint64
+
+	// batches are published without holding mutex, in the order they were
+	// taken from pending
+	publishMutex realsync.Mutex
+	publishCond  *realsync.Cond
+	published    uint64
+	failures     int
+}
+
+func newEventBatcher(
+	ctx context.Context,
+	config BatchingConfig,
+	publishFn func(ctx context.Context, data []byte) error,
+) *eventBatcher {
+	b := &eventBatcher{
+		config:    config,
+		publishFn: publishFn,
+		ctx:       ctx,
+		seen:      map[string]bool{},
+		compacted: map[string]int{},
+	}
+	b.publishCond = realsync.NewCond(&b.publishMutex)
+	return b
+}
+
+func (b *eventBatcher) add(ctx context.Context, envelope jobEventEnvelope) error {
+	// catch events that could never be sent before accepting them
+	if _, err := model.JSONMarshalWithMax(envelope); err != nil {
+		return err
+	}
+
+	b.mutex.Lock()
+	if b.stopped {
+		b.mutex.Unlock()
+		return fmt.Errorf("cannot publish event %s: transport is shut down", envelope.JobEvent.EventName)
+	}
+
+	if key, ok := dedupKey(envelope.JobEvent); ok {
+		if b.seen[key] {
+			b.mutex.Unlock()
+			log.Ctx(ctx).Trace().Msgf("dropping duplicate event %s for job %s", envelope.JobEvent.EventName, envelope.JobEvent.JobID)
+			return nil
+		}
+		b.seen[key] = true
+	}
+
+	if compactableEvents[envelope.JobEvent.EventName] {
+		key := envelope.JobEvent.JobID + "/" + envelope.JobEvent.EventName.String()
+		if index, ok := b.compacted[key]; ok {
+			// the newer event goes to the back of the batch so that it stays
+			// behind anything published after the event it replaces
+			b.pending = append(b.pending[:index], b.pending[index+1:]...)
+			for otherKey, otherIndex := range b.compacted {
+				if otherIndex > index {
+					b.compacted[otherKey] = otherIndex - 1
+				}
+			}
+		}
+		b.compacted[key] = len(b.pending)
+	}
+
+	b.pending = append(b.pending, envelope)
+	if len(b.pending) >= b.config.MaxBatchSize {
+		b.flushLocked(ctx)
+		return nil
+	}
+	if b.timer == nil {
+		b.timer = time.AfterFunc(b.config.FlushInterval, func() {
+			b.flush(b.ctx)
+		})
+	}
+	b.mutex.Unlock()
+	return nil
+}
+
+func (b *eventBatcher) flush(ctx context.Context) {
+	b.mutex.Lock()
+	b.flushLocked(ctx)
+}
+
+// stop publishes any pending events and rejects events added afterwards.
+func (b *eventBatcher) stop(ctx context.Context) {
+	b.mutex.Lock()
+	b.stopped = true
+	b.flushLocked(ctx)
+}
+
+// failureCount returns the number of events that could not be published.
+func (b *eventBatcher) failureCount() int {
+	b.publishMutex.Lock()
+	defer b.publishMutex.Unlock()
+	return b.failures
+}
+
+// flushLocked takes the pending events and publishes them. It must be called
+// with mutex held, and releases it before publishing.
+func (b *eventBatcher) flushLocked(ctx context.Context) {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	events := b.pending
+	if len(events) == 0 {
+		b.mutex.Unlock()
+		return
+	}
+	b.pending = nil
+	b.seen = map[string]bool{}
+	b.compacted = map[string]int{}
+	turn := b.flushes
+	b.flushes++
+	b.mutex.Unlock()
+
+	b.publishMutex.Lock()
+	defer b.publishMutex.Unlock()
+	for b.published != turn {
+		b.publishCond.Wait()
+	}
+	b.failures += b.publish(ctx, events)
+	b.published++
+	b.publishCond.Broadcast()
+}
+
+// publish sends events as one batch, or individually if there is only one
+// or the batch would be too large. It returns the number of events that
+// could not be published.
+func (b *eventBatcher) publish(ctx context.Context, events []jobEventEnvelope) int {
+	if len(events) > 1 {
+		bs, err := model.JSONMarshalWithMax(jobEventBatch{
+			SentTime:        time.Now(),
+			Events:          events,
+			ProtocolVersion: ProtocolVersion,
+		})
+		if err == nil && len(bs) <= b.config.MaxBatchBytes {
+			if err = b.publishFn(ctx, bs); err != nil {
+				log.Ctx(ctx).Error().Err(err).Msgf("error publishing batch of %d events", len(events))
+				return len(events)
+			}
+			return 0
+		}
+		log.Ctx(ctx).Debug().Msgf("batch of %d events too large, publishing them individually", len(events))
+	}
+
+	failed := 0
+	for _, envelope := range events {
+		envelope.SentTime = time.Now()
+		bs, err := model.JSONMarshalWithMax(envelope)
+		if err == nil {
+			err = b.publishFn(ctx, bs)
+		}
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Msgf("error publishing event %s", envelope.JobEvent.EventName)
+			failed++
+		}
+	}
+	return failed
+}
+
+// dedupKey identifies an event by its content, ignoring the time it was
+// created at and its sequence number so that retried publishes of the same
+// event are caught too.
+func dedupKey(event model.JobEvent) (string, bool) {
+	event.EventTime = time.Time{}
+	event.SequenceNumber = 0
+	event.SequenceEpoch = 0
+	bs, err := json.Marshal(event)
+	if err != nil {
+		return "", false
+	}
+	sum := sha256.Sum256(bs)
+	return hex.EncodeToString(sum[:]), true
+}
@@ -0,0 +1,143 @@
+//go:build unit || !integration
+
+package libp2p
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/stretchr/testify/require"
+)
+
+type publishedMessages struct {
+	messages []jobEventMessage
+}
+
+func (p *publishedMessages) publish(ctx context.Context, data []byte) error {
+	message := jobEventMessage{}
+	if err := json.Unmarshal(data, &message); err != nil {
+		return err
+	}
+	p.messages = append(p.messages, message)
+	return nil
+}
+
+func testEnvelope(eventName model.JobEventType, deal model.Deal) jobEventEnvelope {
+	return jobEventEnvelope{
+		JobEvent: model.JobEvent{
+			JobID:        "job-1",
+			SourceNodeID: "QmNodeOne",
+			EventName:    eventName,
+			Deal:         deal,
+			EventTime:    time.Now(),
+		},
+	}
+}
+
+func addEvent(t *testing.T, batcher *eventBatcher, ctx context.Context, envelope jobEventEnvelope) {
+	require.NoError(t, batcher.add(ctx, envelope))
+}
+
+func TestBatcherFlushesWhenFull(t *testing.T) {
+	published := &publishedMessages{}
+	batcher := newEventBatcher(context.Background(), BatchingConfig{
+		MaxBatchSize:  3,
+		MaxBatchBytes: DefaultBatchingConfig.MaxBatchBytes,
+		FlushInterval: time.Hour,
+	}, published.publish)
+
+	ctx := context.Background()
+	addEvent(t, batcher, ctx, testEnvelope(model.JobEventBid, model.Deal{}))
+	// an identical event is dropped
+	addEvent(t, batcher, ctx, testEnvelope(model.JobEventBid, model.Deal{}))
+	// deal updates replace each other
+	addEvent(t, batcher, ctx, testEnvelope(model.JobEventDealUpdated, model.Deal{Concurrency: 1}))
+	addEvent(t, batcher, ctx, testEnvelope(model.JobEventDealUpdated, model.Deal{Concurrency: 2}))
+	require.Empty(t, published.messages)
+
+	addEvent(t, batcher, ctx, testEnvelope(model.JobEventBidAccepted, model.Deal{}))
+	require.Len(t, published.messages, 1)
+
+	batch := published.messages[0].Batch
+	require.Len(t, batch, 3)
+	require.Equal(t, model.JobEventBid, batch[0].JobEvent.EventName)
+	require.Equal(t, model.JobEventDealUpdated, batch[1].JobEvent.EventName)
+	require.Equal(t, 2, batch[1].JobEvent.Deal.Concurrency)
+	require.Equal(t, model.JobEventBidAccepted, batch[2].JobEvent.EventName)
+}
+
+func TestBatcherFlushesSingleEventAsEnvelope(t *testing.T) {
+	published := &publishedMessages{}
+	batcher := newEventBatcher(context.Background(), BatchingConfig{
+		MaxBatchSize:  10,
+		MaxBatchBytes: DefaultBatchingConfig.MaxBatchBytes,
+		FlushInterval: time.Hour,
+	}, published.publish)
+
+	ctx := context.Background()
+	addEvent(t, batcher, ctx, testEnvelope(model.JobEventBid, model.Deal{}))
+	batcher.flush(ctx)
+
+	require.Len(t, published.messages, 1)
+	require.Empty(t, published.messages[0].Batch)
+	require.Equal(t, model.JobEventBid, published.messages[0].JobEvent.EventName)
+}
+
+func TestBatcherKeepsCompactedEventsInOrder(t *testing.T) {
+	published := &publishedMessages{}
+	batcher := newEventBatcher(context.Background(), BatchingConfig{
+		MaxBatchSize:  10,
+		MaxBatchBytes: DefaultBatchingConfig.MaxBatchBytes,
+		FlushInterval: time.Hour,
+	}, published.publish)
+
+	ctx := context.Background()
+	addEvent(t, batcher, ctx, testEnvelope(model.JobEventDealUpdated, model.Deal{Concurrency: 1}))
+	addEvent(t, batcher, ctx, testEnvelope(model.JobEventBid, model.Deal{}))
+	addEvent(t, batcher, ctx, testEnvelope(model.JobEventDealUpdated, model.Deal{Concurrency: 2}))
+	addEvent(t, batcher, ctx, testEnvelope(model.JobEventBidAccepted, model.Deal{}))
+	batcher.flush(ctx)
+
+	require.Len(t, published.messages, 1)
+	batch := published.messages[0].Batch
+	require.Len(t, batch, 3)
+	require.Equal(t, model.JobEventBid, batch[0].JobEvent.EventName)
+	require.Equal(t, model.JobEventDealUpdated, batch[1].JobEvent.EventName)
+	require.Equal(t, 2, batch[1].JobEvent.Deal.Concurrency)
+	require.Equal(t, model.JobEventBidAccepted, batch[2].JobEvent.EventName)
+}
+
+func TestBatcherPublishesWithoutBlockingAdd(t *testing.T) {
+	publishing := make(chan struct{})
+	release := make(chan struct{})
+	var once sync.Once
+	batcher := newEventBatcher(context.Background(), BatchingConfig{
+		MaxBatchSize:  2,
+		MaxBatchBytes: DefaultBatchingConfig.MaxBatchBytes,
+		FlushInterval: time.Hour,
+	}, func(ctx context.Context, data []byte) error {
+		once.Do(func() { close(publishing) })
+		<-release
+		return fmt.Errorf("network is down")
+	})
+
+	ctx := context.Background()
+	addEvent(t, batcher, ctx, testEnvelope(model.JobEventBid, model.Deal{}))
+	go func() {
+		_ = batcher.add(ctx, testEnvelope(model.JobEventBidAccepted, model.Deal{}))
+	}()
+	<-publishing
+
+	// the batch is being published, events can still be added
+	addEvent(t, batcher, ctx, testEnvelope(model.JobEventResultsProposed, model.Deal{}))
+	close(release)
+
+	batcher.stop(ctx)
+	require.Equal(t, 3, batcher.failureCount())
+	require.Error(t, batcher.add(ctx, testEnvelope(model.JobEventResultsAccepted, model.Deal{})))
+}
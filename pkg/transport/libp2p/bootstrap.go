@@ -0,0 +1,234 @@
+package libp2p
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	realsync "sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	madns "github.com/multiformats/go-multiaddr-dns"
+	"github.com/rs/zerolog/log"
+)
+
+// maxDNSAddrDepth is how many dnsaddr records pointing at other dnsaddr
+// records are followed, to stop loops.
+const maxDNSAddrDepth = 4
+
+// maxBootstrapListSize is the largest bootstrap list fetched from a URL.
+const maxBootstrapListSize = 1 << 20
+
+// BootstrapConfig says where the bootstrap peers of a node come from besides
+// those it is given, so that the peers of an environment can be changed
+// without releasing new binaries.
+type BootstrapConfig struct {
+	// The URL of a list of bootstrap peers, one multiaddr per line, which is
+	// added to the peers given to the transport. Lines starting with # are
+	// ignored. The list isn't fetched if empty.
+	RefreshURL string
+	// How often the list is fetched again.
+	RefreshInterval time.Duration
+}
+
+var DefaultBootstrapConfig = BootstrapConfig{
+	RefreshInterval: 10 * time.Minute,
+}
+
+// resolver resolves the DNS components of multiaddrs.
+type resolver interface {
+	Resolve(ctx context.Context, maddr multiaddr.Multiaddr) ([]multiaddr.Multiaddr, error)
+}
+
+// bootstrapList holds the peers a node keeps connecting to. Those with
+// /dnsaddr addresses are looked up in DNS each time they are connected to,
+// and the list fetched from the refresh URL is kept until it is fetched again.
+type bootstrapList struct {
+	static     []multiaddr.Multiaddr
+	config     BootstrapConfig
+	client     *http.Client
+	resolver   resolver
+	mutex      realsync.Mutex
+	fetched    []multiaddr.Multiaddr
+	lastFetch  time.Time
+	fetchedAny bool
+}
+
+func newBootstrapList(static []multiaddr.Multiaddr, config BootstrapConfig) *bootstrapList {
+	return &bootstrapList{
+		static:   static,
+		config:   config,
+		client:   &http.Client{Timeout: 30 * time.Second}, //nolint:gomnd
+		resolver: madns.DefaultResolver,
+	}
+}
+
+// isEmpty returns whether the node has no bootstrap peers at all, and so is
+// on its own by design.
+func (b *bootstrapList) isEmpty() bool {
+	return len(b.static) == 0 && b.config.RefreshURL == ""
+}
+
+// peers returns the bootstrap peers, fetching the list from the refresh URL
+// first if it is due. Peers that can't be resolved are left out, and the
+// errors resolving them returned.
+func (b *bootstrapList) peers(ctx context.Context) ([]peer.AddrInfo, []error) {
+	var errs []error
+	addrs := append([]multiaddr.Multiaddr{}, b.static...)
+	fetched, err := b.refresh(ctx)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	addrs = append(addrs, fetched...)
+
+	resolved, resolveErrs := resolveDNSAddrs(ctx, b.resolver, addrs)
+	errs = append(errs, resolveErrs...)
+
+	infos, err := addrInfos(resolved)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	return infos, errs
+}
+
+// refresh returns the list fetched from the refresh URL, fetching it again if
+// it is older than the refresh interval. The last list fetched is kept if it
+// can't be fetched.
+func (b *bootstrapList) refresh(ctx context.Context) ([]multiaddr.Multiaddr, error) {
+	if b.config.RefreshURL == "" {
+		return nil, nil
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.fetchedAny && time.Since(b.lastFetch) < b.config.RefreshInterval {
+		return b.fetched, nil
+	}
+	fetched, err := fetchBootstrapList(ctx, b.client, b.config.RefreshURL)
+	b.lastFetch = time.Now()
+	if err != nil {
+		return b.fetched, err
+	}
+	if !b.fetchedAny || len(fetched) != len(b.fetched) {
+		log.Ctx(ctx).Debug().Msgf("fetched %d bootstrap peers from %s", len(fetched), b.config.RefreshURL)
+	}
+	b.fetched = fetched
+	b.fetchedAny = true
+	return fetched, nil
+}
+
+func fetchBootstrapList(ctx context.Context, client *http.Client, url string) ([]multiaddr.Multiaddr, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bootstrap list URL %s: %w", url, err)
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching bootstrap list from %s: %w", url, err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error fetching bootstrap list from %s: %s", url, res.Status)
+	}
+	addrs, err := parseBootstrapList(io.LimitReader(res.Body, maxBootstrapListSize))
+	if err != nil {
+		return nil, fmt.Errorf("invalid bootstrap list from %s: %w", url, err)
+	}
+	return addrs, nil
+}
+
+// parseBootstrapList reads a list of multiaddrs, one per line. Blank lines
+// and lines starting with # are ignored.
+func parseBootstrapList(r io.Reader) ([]multiaddr.Multiaddr, error) {
+	var addrs []multiaddr.Multiaddr
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		addr, err := multiaddr.NewMultiaddr(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs, scanner.Err()
+}
+
+// resolveDNSAddrs replaces the /dnsaddr addresses with those their TXT
+// records list, following records that point at other /dnsaddr addresses.
+// Other addresses, including /dns ones that libp2p resolves when dialing,
+// are returned as they are.
+func resolveDNSAddrs(ctx context.Context, r resolver, addrs []multiaddr.Multiaddr) ([]multiaddr.Multiaddr, []error) {
+	var resolved []multiaddr.Multiaddr
+	var errs []error
+	for _, addr := range addrs {
+		addrs, err := resolveDNSAddr(ctx, r, addr, 0)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		resolved = append(resolved, addrs...)
+	}
+	return resolved, errs
+}
+
+func resolveDNSAddr(ctx context.Context, r resolver, addr multiaddr.Multiaddr, depth int) ([]multiaddr.Multiaddr, error) {
+	if !isDNSAddr(addr) {
+		return []multiaddr.Multiaddr{addr}, nil
+	}
+	if depth >= maxDNSAddrDepth {
+		return nil, fmt.Errorf("error resolving %s: too many nested dnsaddr records", addr)
+	}
+	addrs, err := r.Resolve(ctx, addr)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving %s: %w", addr, err)
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("error resolving %s: no dnsaddr records", addr)
+	}
+	var resolved []multiaddr.Multiaddr
+	for _, a := range addrs {
+		more, err := resolveDNSAddr(ctx, r, a, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		resolved = append(resolved, more...)
+	}
+	return resolved, nil
+}
+
+func isDNSAddr(addr multiaddr.Multiaddr) bool {
+	found := false
+	multiaddr.ForEach(addr, func(c multiaddr.Component) bool {
+		found = c.Protocol().Code == multiaddr.P_DNSADDR
+		return !found
+	})
+	return found
+}
+
+// addrInfos groups the addresses by the peer they are of, as a peer with a
+// /dnsaddr address usually has several.
+func addrInfos(addrs []multiaddr.Multiaddr) ([]peer.AddrInfo, error) {
+	var valid []multiaddr.Multiaddr
+	var errs []string
+	for _, addr := range addrs {
+		if _, err := peer.AddrInfoFromP2pAddr(addr); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", addr, err))
+			continue
+		}
+		valid = append(valid, addr)
+	}
+	infos, err := peer.AddrInfosFromP2pAddrs(valid...)
+	if err != nil {
+		return nil, err
+	}
+	if len(errs) > 0 {
+		return infos, fmt.Errorf("invalid bootstrap peer addresses: %s", strings.Join(errs, ", "))
+	}
+	return infos, nil
+}
@@ -0,0 +1,102 @@
+//go:build unit || !integration
+
+package libp2p
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	madns "github.com/multiformats/go-multiaddr-dns"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	bootstrapPeerA = "/ip4/10.0.0.1/tcp/1235/p2p/QmdZQ7ZbhnvWY1J12XYKGHApJ6aufKyLNSvf8jZBrBaAVL"
+	bootstrapPeerB = "/ip4/10.0.0.2/tcp/1235/p2p/QmXaXu9N5GNetatsvwnTfQqNtSeKAD6uCmarbh3LMRYAcF"
+	bootstrapPeerC = "/ip4/10.0.0.3/tcp/1235/p2p/QmYgxZiySj3MRkwLSL4X2MF5F9f2PMhAE3LV49XkfNL1o3"
+)
+
+func mockResolver(t *testing.T, txt map[string][]string) resolver {
+	r, err := madns.NewResolver(madns.WithDefaultResolver(&madns.MockResolver{TXT: txt}))
+	require.NoError(t, err)
+	return r
+}
+
+func multiaddrs(t *testing.T, addrs ...string) []multiaddr.Multiaddr {
+	var result []multiaddr.Multiaddr
+	for _, addr := range addrs {
+		result = append(result, multiaddr.StringCast(addr))
+	}
+	return result
+}
+
+func TestResolveDNSAddrs(t *testing.T) {
+	r := mockResolver(t, map[string][]string{
+		"_dnsaddr.bootstrap.example.org": {"dnsaddr=" + bootstrapPeerA, "dnsaddr=/dnsaddr/more.example.org"},
+		"_dnsaddr.more.example.org":      {"dnsaddr=" + bootstrapPeerB},
+		"_dnsaddr.loop.example.org":      {"dnsaddr=/dnsaddr/loop.example.org"},
+	})
+
+	resolved, errs := resolveDNSAddrs(context.Background(), r,
+		multiaddrs(t, "/dnsaddr/bootstrap.example.org", bootstrapPeerC, "/dnsaddr/loop.example.org", "/dnsaddr/missing.example.org"))
+	require.Len(t, errs, 2)
+	require.Equal(t, multiaddrs(t, bootstrapPeerA, bootstrapPeerB, bootstrapPeerC), resolved)
+}
+
+func TestBootstrapListRefresh(t *testing.T) {
+	list := "# bootstrap peers\n" + bootstrapPeerB + "\n\n"
+	fetches := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		if fetches == 3 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprint(w, list)
+	}))
+	defer server.Close()
+
+	b := newBootstrapList(multiaddrs(t, bootstrapPeerA), BootstrapConfig{RefreshURL: server.URL, RefreshInterval: time.Hour})
+	infos, errs := b.peers(context.Background())
+	require.Empty(t, errs)
+	require.Len(t, infos, 2)
+
+	// the list isn't fetched again until it is due
+	list = bootstrapPeerC
+	infos, _ = b.peers(context.Background())
+	require.Len(t, infos, 2)
+	require.Contains(t, peerIDs(infos), "QmXaXu9N5GNetatsvwnTfQqNtSeKAD6uCmarbh3LMRYAcF")
+	require.Equal(t, 1, fetches)
+
+	b.lastFetch = time.Time{}
+	infos, _ = b.peers(context.Background())
+	require.Contains(t, peerIDs(infos), "QmYgxZiySj3MRkwLSL4X2MF5F9f2PMhAE3LV49XkfNL1o3")
+
+	// the last list is kept when it can't be fetched
+	b.lastFetch = time.Time{}
+	infos, errs = b.peers(context.Background())
+	require.Len(t, errs, 1)
+	require.Contains(t, peerIDs(infos), "QmYgxZiySj3MRkwLSL4X2MF5F9f2PMhAE3LV49XkfNL1o3")
+}
+
+// peerIDs returns the IDs of the peers, which addrInfos returns in no
+// particular order.
+func peerIDs(infos []peer.AddrInfo) []string {
+	ids := make([]string, len(infos))
+	for i, info := range infos {
+		ids[i] = info.ID.String()
+	}
+	return ids
+}
+
+func TestParseBootstrapList(t *testing.T) {
+	_, err := parseBootstrapList(strings.NewReader(bootstrapPeerA + "\nnot an address\n"))
+	require.ErrorContains(t, err, "line 2")
+}
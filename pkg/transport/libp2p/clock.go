@@ -0,0 +1,72 @@
+package libp2p
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/system"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/log"
+)
+
+// MaxClockSkew is how far the time an event was sent at can be from the time
+// it arrived before the clock of the sender is taken to be off. The times of
+// events from such nodes are moved onto the clock of this node, so that a node
+// minutes off NTP doesn't put its events before or after those of others.
+const MaxClockSkew = 5 * time.Second
+
+// clockSkewWarningInterval is how often the skew of each node is logged.
+const clockSkewWarningInterval = time.Minute
+
+// clockSkewDetector compares the time events were sent at with the time they
+// arrive at, to find the nodes whose clocks are off ours.
+type clockSkewDetector struct {
+	nodeID     string
+	mutex      sync.Mutex
+	lastWarned map[string]time.Time
+}
+
+func newClockSkewDetector(nodeID string) *clockSkewDetector {
+	return &clockSkewDetector{
+		nodeID:     nodeID,
+		lastWarned: map[string]time.Time{},
+	}
+}
+
+// correct returns the event of the envelope, with its time moved onto the
+// clock of this node if the clock of the sender is too far off it.
+func (d *clockSkewDetector) correct(ctx context.Context, envelope jobEventEnvelope, now time.Time) model.JobEvent {
+	event := envelope.JobEvent
+	if envelope.SentTime.IsZero() {
+		return event
+	}
+	// the delay of the network counts towards the skew of senders behind us,
+	// which is why some slack is given before correcting
+	skew := envelope.SentTime.Sub(now)
+	metric := clockSkew.With(prometheus.Labels{"node_id": d.nodeID, "source_node_id": event.SourceNodeID})
+	if skew > -MaxClockSkew && skew < MaxClockSkew {
+		metric.Set(0)
+		return event
+	}
+	metric.Set(skew.Seconds())
+	if d.shouldWarn(event.SourceNodeID, now) {
+		log.Ctx(ctx).Warn().Msgf("[%s=>%s] clock of sender is %s off ours, correcting the times of its events",
+			system.GetShortID(event.SourceNodeID), system.GetShortID(d.nodeID), skew)
+	}
+	if !event.EventTime.IsZero() {
+		event.EventTime = event.EventTime.Add(-skew)
+	}
+	return event
+}
+
+func (d *clockSkewDetector) shouldWarn(sourceNodeID string, now time.Time) bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if now.Sub(d.lastWarned[sourceNodeID]) < clockSkewWarningInterval {
+		return false
+	}
+	d.lastWarned[sourceNodeID] = now
+	return true
+}
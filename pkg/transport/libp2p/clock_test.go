@@ -0,0 +1,36 @@
+//go:build unit || !integration
+
+package libp2p
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClockSkewCorrection(t *testing.T) {
+	now := time.Now()
+	eventTime := now.Add(-time.Second)
+	for _, testCase := range []struct {
+		name     string
+		skew     time.Duration
+		expected time.Time
+	}{
+		{"in sync", 0, eventTime},
+		{"within tolerance", MaxClockSkew / 2, eventTime},
+		{"sender ahead", 3 * time.Minute, eventTime.Add(-3 * time.Minute)},
+		{"sender behind", -3 * time.Minute, eventTime.Add(3 * time.Minute)},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			detector := newClockSkewDetector("receiver")
+			event := detector.correct(context.Background(), jobEventEnvelope{
+				SentTime: now.Add(testCase.skew),
+				JobEvent: model.JobEvent{SourceNodeID: "sender", EventTime: eventTime},
+			}, now)
+			require.WithinDuration(t, testCase.expected, event.EventTime, 0)
+		})
+	}
+}
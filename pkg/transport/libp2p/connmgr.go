@@ -0,0 +1,134 @@
+package libp2p
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	realsync "sync"
+	"time"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/connmgr"
+	"github.com/libp2p/go-libp2p/core/peer"
+	libp2pconnmgr "github.com/libp2p/go-libp2p/p2p/net/connmgr"
+	"github.com/rs/zerolog/log"
+)
+
+// ConnectionManagerConfig sets how many connections a node keeps open. Once
+// the number of connections goes above HighWater, the least useful ones are
+// closed until LowWater is reached. Connections to peers we are running a
+// shard with are never closed.
+type ConnectionManagerConfig struct {
+	LowWater    int
+	HighWater   int
+	GracePeriod time.Duration
+}
+
+// DefaultConnectionManagerConfig matches the libp2p defaults.
+var DefaultConnectionManagerConfig = ConnectionManagerConfig{
+	LowWater:    160,
+	HighWater:   192,
+	GracePeriod: time.Minute,
+}
+
+func (c ConnectionManagerConfig) options() ([]libp2p.Option, error) {
+	if c.LowWater > c.HighWater {
+		return nil, fmt.Errorf("connection manager low water (%d) cannot be higher than high water (%d)", c.LowWater, c.HighWater)
+	}
+	manager, err := libp2pconnmgr.NewConnManager(c.LowWater, c.HighWater, libp2pconnmgr.WithGracePeriod(c.GracePeriod))
+	if err != nil {
+		return nil, err
+	}
+	return []libp2p.Option{libp2p.ConnectionManager(manager)}, nil
+}
+
+// shardProtector protects the connections to the peers we have in-flight
+// shards with from being pruned by the connection manager, so that a long
+// running job is not cut off from its requester half way through.
+type shardProtector struct {
+	hostID    peer.ID
+	manager   connmgr.ConnManager
+	mutex     realsync.Mutex
+	protected map[string]peer.ID
+}
+
+func newShardProtector(hostID peer.ID, manager connmgr.ConnManager) *shardProtector {
+	return &shardProtector{
+		hostID:    hostID,
+		manager:   manager,
+		protected: map[string]peer.ID{},
+	}
+}
+
+func (p *shardProtector) handleEvent(ctx context.Context, event model.JobEvent) {
+	switch {
+	case event.EventName == model.JobEventBidAccepted:
+		// the requester accepts the bid of the target compute node
+		p.protect(ctx, event.JobID, event.ShardIndex, event.SourceNodeID, event.TargetNodeID)
+	case event.EventName == model.JobEventError && event.TargetNodeID == "":
+		p.unprotectJob(ctx, event.JobID)
+	case event.EventName.IsIgnorable(),
+		event.EventName == model.JobEventResultsRejected,
+		event.EventName == model.JobEventBidCancelled:
+		computeNodeID := event.SourceNodeID
+		if event.TargetNodeID != "" {
+			computeNodeID = event.TargetNodeID
+		}
+		p.unprotect(ctx, shardTag(event.JobID, event.ShardIndex, computeNodeID))
+	}
+}
+
+func (p *shardProtector) protect(ctx context.Context, jobID string, shardIndex int, requesterNodeID, computeNodeID string) {
+	var otherNodeID string
+	switch p.hostID.String() {
+	case requesterNodeID:
+		otherNodeID = computeNodeID
+	case computeNodeID:
+		otherNodeID = requesterNodeID
+	default:
+		return
+	}
+	peerID, err := peer.Decode(otherNodeID)
+	if err != nil || peerID == p.hostID {
+		return
+	}
+
+	tag := shardTag(jobID, shardIndex, computeNodeID)
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.protected[tag] = peerID
+	p.manager.Protect(peerID, tag)
+	log.Ctx(ctx).Trace().Msgf("protecting connection to %s for %s", peerID, tag)
+}
+
+func (p *shardProtector) unprotect(ctx context.Context, tag string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.unprotectLocked(ctx, tag)
+}
+
+func (p *shardProtector) unprotectJob(ctx context.Context, jobID string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	prefix := jobID + ":"
+	for tag := range p.protected {
+		if strings.HasPrefix(tag, prefix) {
+			p.unprotectLocked(ctx, tag)
+		}
+	}
+}
+
+func (p *shardProtector) unprotectLocked(ctx context.Context, tag string) {
+	peerID, ok := p.protected[tag]
+	if !ok {
+		return
+	}
+	delete(p.protected, tag)
+	p.manager.Unprotect(peerID, tag)
+	log.Ctx(ctx).Trace().Msgf("no longer protecting connection to %s for %s", peerID, tag)
+}
+
+func shardTag(jobID string, shardIndex int, computeNodeID string) string {
+	return fmt.Sprintf("%s:%s", model.GetShardID(jobID, shardIndex), computeNodeID)
+}
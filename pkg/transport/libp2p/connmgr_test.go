@@ -0,0 +1,125 @@
+//go:build unit || !integration
+
+package libp2p
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/libp2p/go-libp2p/core/connmgr"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/test"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeConnManager records which peers are protected under which tags.
+type fakeConnManager struct {
+	connmgr.NullConnMgr
+	protected map[peer.ID]map[string]bool
+}
+
+func newFakeConnManager() *fakeConnManager {
+	return &fakeConnManager{protected: map[peer.ID]map[string]bool{}}
+}
+
+func (m *fakeConnManager) Protect(id peer.ID, tag string) {
+	if m.protected[id] == nil {
+		m.protected[id] = map[string]bool{}
+	}
+	m.protected[id][tag] = true
+}
+
+func (m *fakeConnManager) Unprotect(id peer.ID, tag string) bool {
+	delete(m.protected[id], tag)
+	return len(m.protected[id]) > 0
+}
+
+func (m *fakeConnManager) IsProtected(id peer.ID, tag string) bool {
+	if tag == "" {
+		return len(m.protected[id]) > 0
+	}
+	return m.protected[id][tag]
+}
+
+func TestShardProtector(t *testing.T) {
+	host := test.RandPeerIDFatal(t)
+	requester := test.RandPeerIDFatal(t)
+	otherCompute := test.RandPeerIDFatal(t)
+
+	bidAccepted := func(jobID string, shardIndex int, computeNode peer.ID) model.JobEvent {
+		return model.JobEvent{
+			JobID:        jobID,
+			ShardIndex:   shardIndex,
+			EventName:    model.JobEventBidAccepted,
+			SourceNodeID: requester.String(),
+			TargetNodeID: computeNode.String(),
+		}
+	}
+
+	for _, endEvent := range []model.JobEventType{
+		model.JobEventBidRejected,
+		model.JobEventComputeError,
+		model.JobEventResultsPublished,
+	} {
+		t.Run(endEvent.String(), func(t *testing.T) {
+			ctx := context.Background()
+			manager := newFakeConnManager()
+			protector := newShardProtector(host, manager)
+
+			protector.handleEvent(ctx, bidAccepted("job-1", 0, host))
+			require.True(t, manager.IsProtected(requester, ""))
+
+			endOfShard := model.JobEvent{
+				JobID:        "job-1",
+				ShardIndex:   0,
+				EventName:    endEvent,
+				SourceNodeID: host.String(),
+			}
+			if endEvent == model.JobEventBidRejected {
+				// the requester rejects the bid of the compute node
+				endOfShard.SourceNodeID = requester.String()
+				endOfShard.TargetNodeID = host.String()
+			}
+			protector.handleEvent(ctx, endOfShard)
+			require.False(t, manager.IsProtected(requester, ""))
+		})
+	}
+
+	t.Run("job error", func(t *testing.T) {
+		ctx := context.Background()
+		manager := newFakeConnManager()
+		protector := newShardProtector(requester, manager)
+
+		protector.handleEvent(ctx, bidAccepted("job-1", 0, host))
+		protector.handleEvent(ctx, bidAccepted("job-1", 1, otherCompute))
+		protector.handleEvent(ctx, bidAccepted("job-2", 0, host))
+		require.True(t, manager.IsProtected(otherCompute, ""))
+
+		protector.handleEvent(ctx, model.JobEvent{
+			JobID:        "job-1",
+			EventName:    model.JobEventError,
+			SourceNodeID: requester.String(),
+		})
+		require.False(t, manager.IsProtected(otherCompute, ""))
+		require.True(t, manager.IsProtected(host, shardTag("job-2", 0, host.String())))
+		require.False(t, manager.IsProtected(host, shardTag("job-1", 0, host.String())))
+	})
+
+	t.Run("other nodes", func(t *testing.T) {
+		manager := newFakeConnManager()
+		protector := newShardProtector(host, manager)
+
+		protector.handleEvent(context.Background(), bidAccepted("job-1", 0, otherCompute))
+		require.Empty(t, manager.protected)
+	})
+}
+
+func TestConnectionManagerConfigRejectsLowAboveHigh(t *testing.T) {
+	_, err := ConnectionManagerConfig{LowWater: 10, HighWater: 5}.options()
+	require.Error(t, err)
+
+	opts, err := DefaultConnectionManagerConfig.options()
+	require.NoError(t, err)
+	require.Len(t, opts, 1)
+}
@@ -0,0 +1,263 @@
+package libp2p
+
+import (
+	"context"
+	"fmt"
+	"io"
+	realsync "sync"
+	"time"
+
+	"github.com/filecoin-project/bacalhau/pkg/logger"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/system"
+	"github.com/libp2p/go-libp2p"
+	dht "github.com/libp2p/go-libp2p-kad-dht"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/libp2p/go-libp2p/core/routing"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/rs/zerolog/log"
+)
+
+// JobEventProtocol is the stream protocol used to send the events of targeted
+// jobs straight to the nodes taking part in them, bypassing gossip.
+const JobEventProtocol protocol.ID = "/bacalhau/job-event/1.0.0"
+
+// The DHT used to find the addresses of target nodes we are not connected
+// to. It is kept apart from the IPFS DHT so that it only holds bacalhau nodes.
+const dhtProtocolPrefix protocol.ID = "/bacalhau"
+
+// How long sending an event to a single target node may take, including
+// finding and dialing it.
+const directSendTimeout = 10 * time.Second
+
+// How long the nodes of a targeted job are remembered if the job never
+// reports an error. Later events of the job are gossiped.
+const targetedJobTTL = 24 * time.Hour
+
+type targetedJob struct {
+	peers     []peer.ID
+	createdAt time.Time
+}
+
+// dhtRoutingOption makes the host find peers it has no addresses for through
+// a DHT bootstrapped from peers, so that targeted jobs can reach nodes we are
+// not connected to yet.
+func dhtRoutingOption(ctx context.Context, cm *system.CleanupManager, peers []multiaddr.Multiaddr) (libp2p.Option, error) {
+	bootstrapPeers, err := peer.AddrInfosFromP2pAddrs(peers...)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bootstrap peer address: %w", err)
+	}
+	return libp2p.Routing(func(h host.Host) (routing.PeerRouting, error) {
+		kadDHT, err := dht.New(ctx, h,
+			dht.Mode(dht.ModeAutoServer),
+			dht.ProtocolPrefix(dhtProtocolPrefix),
+			dht.BootstrapPeers(bootstrapPeers...),
+		)
+		if err != nil {
+			return nil, err
+		}
+		cm.RegisterCallback(kadDHT.Close)
+		return kadDHT, kadDHT.Bootstrap(ctx)
+	}), nil
+}
+
+// isTargetedEvent returns the peers an event should be sent to directly, or
+// false if the event should be gossiped as usual.
+func (t *LibP2PTransport) isTargetedEvent(event model.JobEvent) ([]peer.ID, bool, error) {
+	if event.EventName == model.JobEventCreated && len(event.Deal.TargetNodes) > 0 {
+		peers, err := t.addTargetedJob(event)
+		return peers, true, err
+	}
+
+	t.targetedJobsMutex.RLock()
+	defer t.targetedJobsMutex.RUnlock()
+	job, ok := t.targetedJobs[event.JobID]
+	return job.peers, ok, nil
+}
+
+// addTargetedJob remembers the nodes taking part in a targeted job - the
+// requester and the target nodes - so that the events that follow are sent
+// to them too. The nodes of a job are never changed once known.
+func (t *LibP2PTransport) addTargetedJob(event model.JobEvent) ([]peer.ID, error) {
+	peers := []peer.ID{}
+	for _, nodeID := range append([]string{event.SourceNodeID}, event.Deal.TargetNodes...) {
+		peerID, err := peer.Decode(nodeID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid target node %q: %w", nodeID, err)
+		}
+		if peerID != t.host.ID() {
+			peers = append(peers, peerID)
+		}
+	}
+
+	t.targetedJobsMutex.Lock()
+	defer t.targetedJobsMutex.Unlock()
+	now := time.Now()
+	for jobID, job := range t.targetedJobs {
+		if now.Sub(job.createdAt) > targetedJobTTL {
+			delete(t.targetedJobs, jobID)
+		}
+	}
+	if _, ok := t.targetedJobs[event.JobID]; ok {
+		return nil, fmt.Errorf("job %s is already targeted", event.JobID)
+	}
+	t.targetedJobs[event.JobID] = targetedJob{peers: peers, createdAt: now}
+	return peers, nil
+}
+
+// isTargetedJobPeer returns whether peerID takes part in a targeted job.
+func (t *LibP2PTransport) isTargetedJobPeer(jobID string, peerID peer.ID) bool {
+	t.targetedJobsMutex.RLock()
+	defer t.targetedJobsMutex.RUnlock()
+	for _, p := range t.targetedJobs[jobID].peers {
+		if p == peerID {
+			return true
+		}
+	}
+	return false
+}
+
+// removeTargetedJob forgets the nodes of a targeted job once it has ended.
+func (t *LibP2PTransport) removeTargetedJob(jobID string) {
+	t.targetedJobsMutex.Lock()
+	defer t.targetedJobsMutex.Unlock()
+	delete(t.targetedJobs, jobID)
+}
+
+func (t *LibP2PTransport) publishDirect(ctx context.Context, peers []peer.ID, envelope jobEventEnvelope) error {
+	ctx, span := system.GetTracer().Start(ctx, "pkg/transport/libp2p.publishDirect")
+	defer span.End()
+
+	bs, err := model.JSONMarshalWithMax(envelope)
+	if err != nil {
+		return err
+	}
+
+	// we don't hear our own direct messages back, so deliver locally as well
+	publicKey, err := crypto.MarshalPublicKey(t.privateKey.GetPublic())
+	if err != nil {
+		return err
+	}
+	go t.readEnvelope(logger.ContextWithNodeIDLogger(context.Background(), t.HostID()), publicKey, envelope)
+
+	// send to every node at once so that an unreachable one doesn't hold up
+	// the others
+	errs := make([]error, len(peers))
+	var wg realsync.WaitGroup
+	for i, peerID := range peers {
+		wg.Add(1)
+		go func(i int, peerID peer.ID) {
+			defer wg.Done()
+			errs[i] = t.sendDirect(ctx, peerID, bs)
+			if errs[i] != nil {
+				log.Ctx(ctx).Warn().Msgf("Error sending event %s to %s: %s", envelope.JobEvent.EventName, peerID, errs[i])
+			}
+		}(i, peerID)
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, err := range errs {
+		if err != nil {
+			failed++
+		}
+	}
+	if failed == len(peers) && len(peers) > 0 {
+		return fmt.Errorf("failed to send event %s to any of the target nodes: %s", envelope.JobEvent.EventName, errs)
+	}
+	return nil
+}
+
+func (t *LibP2PTransport) sendDirect(ctx context.Context, peerID peer.ID, data []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, directSendTimeout)
+	defer cancel()
+
+	stream, err := t.host.NewStream(ctx, peerID, JobEventProtocol)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+	deadline, _ := ctx.Deadline()
+	if err = stream.SetDeadline(deadline); err != nil {
+		_ = stream.Reset()
+		return err
+	}
+	if _, err = stream.Write(data); err != nil {
+		_ = stream.Reset()
+		return err
+	}
+	return stream.CloseWrite()
+}
+
+func (t *LibP2PTransport) handleDirectStream(stream network.Stream) {
+	defer stream.Close()
+	ctx := logger.ContextWithNodeIDLogger(context.Background(), t.HostID())
+
+	data, err := io.ReadAll(io.LimitReader(stream, int64(model.MaxSerializedStringInput)+1))
+	if err != nil {
+		log.Ctx(ctx).Error().Msgf("error reading direct event from %s: %v", stream.Conn().RemotePeer(), err)
+		_ = stream.Reset()
+		return
+	}
+
+	header := versionHeader{}
+	_ = model.JSONUnmarshalWithMax(data, &header)
+	if t.versions.dropIncompatible(ctx, stream.Conn().RemotePeer(), header.ProtocolVersion) {
+		return
+	}
+
+	payload := jobEventEnvelope{}
+	if err = model.JSONUnmarshalWithMax(data, &payload); err != nil {
+		log.Ctx(ctx).Error().Msgf("error unmarshalling direct event sent with protocol version %q: %v",
+			header.ProtocolVersion, err)
+		return
+	}
+
+	// direct events are always sent by the node they come from
+	remotePeer := stream.Conn().RemotePeer()
+	if payload.JobEvent.SourceNodeID != remotePeer.String() {
+		log.Ctx(ctx).Warn().Msgf("ignoring direct event %s from %s claiming to come from %s",
+			payload.JobEvent.EventName, remotePeer, payload.JobEvent.SourceNodeID)
+		return
+	}
+
+	senderKey, err := crypto.MarshalPublicKey(stream.Conn().RemotePublicKey())
+	if err != nil {
+		log.Ctx(ctx).Error().Msgf("error reading public key of %s: %v", remotePeer, err)
+		return
+	}
+
+	jobID := payload.JobEvent.JobID
+	if payload.JobEvent.EventName == model.JobEventCreated {
+		if !t.isTargetNode(payload.JobEvent.Deal) {
+			log.Ctx(ctx).Warn().Msgf("ignoring direct job %s from %s that does not target this node", jobID, remotePeer)
+			return
+		}
+		if t.jobFilter.isKnown(ctx, jobID) {
+			log.Ctx(ctx).Warn().Msgf("ignoring direct job %s from %s, the job already exists", jobID, remotePeer)
+			return
+		}
+		if _, err = t.addTargetedJob(payload.JobEvent); err != nil {
+			log.Ctx(ctx).Warn().Msgf("ignoring direct job %s from %s: %v", jobID, remotePeer, err)
+			return
+		}
+	} else if !t.isTargetedJobPeer(jobID, remotePeer) {
+		log.Ctx(ctx).Warn().Msgf("ignoring direct event %s from %s which is not part of job %s",
+			payload.JobEvent.EventName, remotePeer, jobID)
+		return
+	}
+	t.readEnvelope(ctx, senderKey, payload)
+}
+
+func (t *LibP2PTransport) isTargetNode(deal model.Deal) bool {
+	for _, nodeID := range deal.TargetNodes {
+		if nodeID == t.HostID() {
+			return true
+		}
+	}
+	return false
+}
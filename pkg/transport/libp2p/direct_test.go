@@ -0,0 +1,165 @@
+//go:build unit || !integration
+
+package libp2p
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/system"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/phayes/freeport"
+	"github.com/stretchr/testify/require"
+)
+
+// receivedEvents collects the events a transport hands to its subscribers.
+type receivedEvents chan model.JobEvent
+
+func (r receivedEvents) subscribe(ctx context.Context, event model.JobEvent) error {
+	r <- event
+	return nil
+}
+
+func (r receivedEvents) next(t *testing.T) model.JobEvent {
+	select {
+	case event := <-r:
+		return event
+	case <-time.After(10 * time.Second):
+		require.FailNow(t, "timed out waiting for event")
+		return model.JobEvent{}
+	}
+}
+
+func (r receivedEvents) none(t *testing.T) {
+	select {
+	case event := <-r:
+		require.FailNow(t, "unexpected event", "%s for job %s", event.EventName, event.JobID)
+	case <-time.After(500 * time.Millisecond):
+	}
+}
+
+func startTestTransport(t *testing.T, cm *system.CleanupManager, peers ...*LibP2PTransport) (*LibP2PTransport, receivedEvents) {
+	ctx := context.Background()
+	port, err := freeport.GetFreePort()
+	require.NoError(t, err)
+
+	peerAddrs := []multiaddr.Multiaddr{}
+	for _, p := range peers {
+		addrs, err := p.HostAddrs()
+		require.NoError(t, err)
+		peerAddrs = append(peerAddrs, addrs...)
+	}
+	transport, err := NewTransport(ctx, cm, port, peerAddrs)
+	require.NoError(t, err)
+
+	events := make(receivedEvents, 16)
+	transport.Subscribe(ctx, events.subscribe)
+	require.NoError(t, transport.Start(ctx))
+	for _, p := range peers {
+		require.Eventually(t, func() bool {
+			return transport.host.Network().Connectedness(p.host.ID()) == network.Connected
+		}, 10*time.Second, 50*time.Millisecond)
+	}
+	return transport, events
+}
+
+func TestIsTargetedEvent(t *testing.T) {
+	cm := system.NewCleanupManager()
+	defer cm.Cleanup()
+	requester, _ := startTestTransport(t, cm)
+	compute, _ := startTestTransport(t, cm)
+
+	created := model.JobEvent{
+		JobID:        "job-1",
+		EventName:    model.JobEventCreated,
+		SourceNodeID: requester.HostID(),
+		Deal:         model.Deal{Concurrency: 1, TargetNodes: []string{compute.HostID()}},
+	}
+	peers, targeted, err := requester.isTargetedEvent(created)
+	require.NoError(t, err)
+	require.True(t, targeted)
+	require.Equal(t, []peer.ID{compute.host.ID()}, peers)
+
+	// the events that follow go to the same nodes
+	peers, targeted, err = requester.isTargetedEvent(model.JobEvent{JobID: "job-1", EventName: model.JobEventBidAccepted})
+	require.NoError(t, err)
+	require.True(t, targeted)
+	require.Equal(t, []peer.ID{compute.host.ID()}, peers)
+
+	// the nodes of a job can't be changed
+	_, _, err = requester.isTargetedEvent(created)
+	require.Error(t, err)
+
+	_, targeted, err = requester.isTargetedEvent(model.JobEvent{JobID: "job-2", EventName: model.JobEventBid})
+	require.NoError(t, err)
+	require.False(t, targeted)
+}
+
+func TestDirectEvents(t *testing.T) {
+	cm := system.NewCleanupManager()
+	defer cm.Cleanup()
+	ctx := context.Background()
+
+	requester, requesterEvents := startTestTransport(t, cm)
+	compute, computeEvents := startTestTransport(t, cm, requester)
+	other, otherEvents := startTestTransport(t, cm, requester, compute)
+
+	require.NoError(t, requester.Publish(ctx, model.JobEvent{
+		JobID:        "job-1",
+		EventName:    model.JobEventCreated,
+		SourceNodeID: requester.HostID(),
+		Spec:         model.Spec{Engine: model.EngineNoop},
+		Deal:         model.Deal{Concurrency: 1, TargetNodes: []string{compute.HostID()}},
+	}))
+	require.Equal(t, model.JobEventCreated, requesterEvents.next(t).EventName)
+	require.Equal(t, model.JobEventCreated, computeEvents.next(t).EventName)
+
+	require.NoError(t, compute.Publish(ctx, model.JobEvent{
+		JobID:        "job-1",
+		EventName:    model.JobEventBid,
+		SourceNodeID: compute.HostID(),
+	}))
+	require.Equal(t, model.JobEventBid, computeEvents.next(t).EventName)
+	require.Equal(t, model.JobEventBid, requesterEvents.next(t).EventName)
+
+	// nodes outside of the job don't hear about it
+	otherEvents.none(t)
+
+	send := func(from *LibP2PTransport, to *LibP2PTransport, event model.JobEvent) {
+		bs, err := model.JSONMarshalWithMax(jobEventEnvelope{JobEvent: event, SentTime: time.Now()})
+		require.NoError(t, err)
+		require.NoError(t, from.sendDirect(ctx, to.host.ID(), bs))
+	}
+
+	// events claiming to come from another node are dropped
+	send(other, compute, model.JobEvent{
+		JobID:        "job-1",
+		EventName:    model.JobEventBidAccepted,
+		SourceNodeID: requester.HostID(),
+		TargetNodeID: compute.HostID(),
+	})
+	// as are events from nodes that are not part of the job
+	send(other, compute, model.JobEvent{
+		JobID:        "job-1",
+		EventName:    model.JobEventBidAccepted,
+		SourceNodeID: other.HostID(),
+		TargetNodeID: compute.HostID(),
+	})
+	// and attempts to take over the job
+	send(other, compute, model.JobEvent{
+		JobID:        "job-1",
+		EventName:    model.JobEventCreated,
+		SourceNodeID: other.HostID(),
+		Deal:         model.Deal{Concurrency: 1, TargetNodes: []string{compute.HostID()}},
+	})
+	computeEvents.none(t)
+
+	peers, targeted, err := compute.isTargetedEvent(model.JobEvent{JobID: "job-1", EventName: model.JobEventBid})
+	require.NoError(t, err)
+	require.True(t, targeted)
+	require.Equal(t, []peer.ID{requester.host.ID()}, peers)
+}
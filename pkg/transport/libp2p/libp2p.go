@@ -16,9 +16,11 @@ import (
 	sync "github.com/lukemarsden/golang-mutex-tracer"
 
 	"github.com/filecoin-project/bacalhau/pkg/config"
+	"github.com/filecoin-project/bacalhau/pkg/localdb"
 	"github.com/filecoin-project/bacalhau/pkg/model"
 	"github.com/filecoin-project/bacalhau/pkg/system"
 	"github.com/filecoin-project/bacalhau/pkg/transport"
+	"github.com/filecoin-project/bacalhau/pkg/version"
 	"github.com/libp2p/go-libp2p"
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
 	"github.com/libp2p/go-libp2p/core/crypto"
@@ -26,12 +28,19 @@ import (
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/peerstore"
 	"github.com/multiformats/go-multiaddr"
+	madns "github.com/multiformats/go-multiaddr-dns"
 	"github.com/rs/zerolog/log"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/propagation"
 )
 
 const JobEventChannel = "bacalhau-job-event"
+
+// New jobs are announced on a topic per engine so that nodes can choose to
+// only hear about jobs they could run. All other events stay on
+// JobEventChannel, where new jobs are also announced for nodes that predate
+// the per-engine topics unless legacy announcements are disabled.
+const JobAnnouncementChannelPrefix = "bacalhau-job-announce/"
 const ContinuouslyConnectPeersLoopDelaySeconds = 10
 
 type LibP2PTransport struct {
@@ -41,15 +50,52 @@ type LibP2PTransport struct {
 	subscribeFunctions   []transport.SubscribeFn
 	mutex                sync.RWMutex
 	host                 host.Host
-	peers                []multiaddr.Multiaddr
+	bootstrap            *bootstrapList
 	pubSub               *pubsub.PubSub
 	jobEventTopic        *pubsub.Topic
 	jobEventSubscription *pubsub.Subscription
 	privateKey           crypto.PrivKey
 	shutdownChan         chan bool
+	batcher              *eventBatcher
+	announcementTopics   map[model.Engine]*pubsub.Topic
+	// whether new jobs are announced on JobEventChannel too
+	legacyAnnouncements bool
+	jobFilter           *jobFilter
+	// the nodes taking part in targeted jobs, which are sent events directly
+	targetedJobs      map[string]targetedJob
+	targetedJobsMutex realsync.RWMutex
+	reachability      reachabilityTracker
+	shardProtector    *shardProtector
+	sequencer         *transport.EventSequencer
+	clockSkew         *clockSkewDetector
+	versions          *peerVersionTracker
+}
+
+// TransportConfig holds the libp2p settings of a node that can be tuned
+// from the command line.
+type TransportConfig struct {
+	NAT               NATConfig
+	ConnectionManager ConnectionManagerConfig
+	Bootstrap         BootstrapConfig
+}
+
+var DefaultTransportConfig = TransportConfig{
+	NAT:               DefaultNATConfig,
+	ConnectionManager: DefaultConnectionManagerConfig,
+	Bootstrap:         DefaultBootstrapConfig,
 }
 
 func NewTransport(ctx context.Context, cm *system.CleanupManager, port int, peers []multiaddr.Multiaddr) (*LibP2PTransport, error) {
+	return NewTransportWithConfig(ctx, cm, port, peers, DefaultTransportConfig)
+}
+
+func NewTransportWithConfig(
+	ctx context.Context,
+	cm *system.CleanupManager,
+	port int,
+	peers []multiaddr.Multiaddr,
+	transportConfig TransportConfig,
+) (*LibP2PTransport, error) {
 	prvKey, err := config.GetPrivateKey(fmt.Sprintf("private_key.%d", port))
 	if err != nil {
 		return nil, err
@@ -61,12 +107,45 @@ func NewTransport(ctx context.Context, cm *system.CleanupManager, port int, peer
 		return nil, err
 	}
 
-	return NewTransportFromOptions(ctx, cm,
-		peers,
+	// the relays and the DHT are given the bootstrap peers as they are when
+	// the node starts, so those with /dnsaddr addresses are looked up now
+	resolvedPeers, errs := resolveDNSAddrs(ctx, madns.DefaultResolver, peers)
+	for _, resolveErr := range errs {
+		log.Ctx(ctx).Warn().Err(resolveErr).Msg("error resolving bootstrap peer")
+	}
+
+	natOpts, err := transportConfig.NAT.options(resolvedPeers)
+	if err != nil {
+		return nil, err
+	}
+	connectionManagerOpts, err := transportConfig.ConnectionManager.options()
+	if err != nil {
+		return nil, err
+	}
+
+	routingOpt, err := dhtRoutingOption(ctx, cm, resolvedPeers)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []libp2p.Option{
 		libp2p.ListenAddrs(sourceMultiAddr),
-		libp2p.Identity(prvKey))
+		libp2p.Identity(prvKey),
+		routingOpt,
+	}
+	opts = append(opts, natOpts...)
+	opts = append(opts, connectionManagerOpts...)
+	libp2pTransport, err := NewTransportFromOptions(ctx, cm, peers, opts...)
+	if err != nil {
+		return nil, err
+	}
+	libp2pTransport.bootstrap.config = transportConfig.Bootstrap
+	return libp2pTransport, nil
 }
 
+// NewTransportFromOptions creates a transport from raw libp2p options. Unless
+// opts include a connection manager, libp2p's no-op one is used: connections
+// are never pruned, so protecting those of in-flight shards has no effect.
 func NewTransportFromOptions(ctx context.Context,
 	cm *system.CleanupManager,
 	peers []multiaddr.Multiaddr, opts ...libp2p.Option) (*LibP2PTransport, error) {
@@ -84,6 +163,12 @@ func NewTransportFromOptions(ctx context.Context,
 		return nil, err
 	}
 
+	// tell peers which version of the events this node speaks when they
+	// identify it, unless the options say otherwise
+	opts = append([]libp2p.Option{
+		libp2p.ProtocolVersion(protocolVersionPrefix + ProtocolVersion),
+		libp2p.UserAgent("bacalhau/" + version.GITVERSION),
+	}, opts...)
 	h, err := libp2p.New(opts...)
 	if err != nil {
 		return nil, err
@@ -133,16 +218,39 @@ func NewTransportFromOptions(ctx context.Context,
 		return nil, err
 	}
 
+	announcementTopics := map[model.Engine]*pubsub.Topic{}
+	for _, engine := range model.EngineTypes() {
+		announcementTopics[engine], err = ps.Join(JobAnnouncementChannelPrefix + engine.String())
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	libp2pTransport := &LibP2PTransport{
 		cm:                   cm,
 		subscribeFunctions:   []transport.SubscribeFn{},
 		host:                 h,
-		peers:                peers,
+		bootstrap:            newBootstrapList(peers, DefaultBootstrapConfig),
 		privateKey:           prvKey,
 		pubSub:               ps,
 		jobEventTopic:        jobEventTopic,
 		jobEventSubscription: jobEventSubscription,
 		shutdownChan:         make(chan bool),
+		announcementTopics:   announcementTopics,
+		legacyAnnouncements:  true,
+		jobFilter:            newJobFilter(h.ID().String()),
+		targetedJobs:         map[string]targetedJob{},
+		shardProtector:       newShardProtector(h.ID(), h.ConnManager()),
+		sequencer:            transport.NewEventSequencer(),
+		clockSkew:            newClockSkewDetector(h.ID().String()),
+		versions:             newPeerVersionTracker(h),
+	}
+	h.SetStreamHandler(JobEventProtocol, libp2pTransport.handleDirectStream)
+	if err = libp2pTransport.reachability.start(ctx, h); err != nil {
+		return nil, err
+	}
+	if err = libp2pTransport.versions.start(ctx); err != nil {
+		return nil, err
 	}
 
 	libp2pTransport.mutex.EnableTracerWithOpts(sync.Opts{
@@ -158,6 +266,46 @@ func NewTransportFromOptions(ctx context.Context,
 
 */
 
+// SubscribeToEngines limits the job announcements this node receives to
+// jobs for the given engines. Events about other jobs are dropped on arrival,
+// so the local view of the network only contains jobs for these engines.
+// Jobs are only filtered by engine, not by labels or the resources they ask
+// for. localDB is used to recognise the jobs the node already holds, such as
+// those replayed from its event log after a restart. It must be called
+// before the transport is started.
+func (t *LibP2PTransport) SubscribeToEngines(engines []model.Engine, localDB localdb.LocalDB) {
+	t.jobFilter.subscribe(engines, func(ctx context.Context, jobID string) bool {
+		_, err := localDB.GetJob(ctx, jobID)
+		return err == nil
+	})
+}
+
+// DisableLegacyAnnouncements stops announcing new jobs on JobEventChannel.
+// Nodes that predate the per-engine announcement topics will no longer hear
+// about jobs from this node, so it should only be used once the whole
+// network has been upgraded. It must be called before the transport is
+// started.
+func (t *LibP2PTransport) DisableLegacyAnnouncements() {
+	t.legacyAnnouncements = false
+}
+
+// EnableBatching groups outgoing events into batches according to
+// batchingConfig. It must be called before the transport is started.
+//
+// Batched events are sent after Publish returns, so Publish only reports
+// events that could never be sent. Errors sending a batch are logged and
+// counted instead. Created events are never batched.
+func (t *LibP2PTransport) EnableBatching(batchingConfig BatchingConfig) {
+	if !batchingConfig.Enabled() {
+		t.batcher = nil
+		return
+	}
+	ctx := logger.ContextWithNodeIDLogger(context.Background(), t.HostID())
+	t.batcher = newEventBatcher(ctx, batchingConfig, func(ctx context.Context, data []byte) error {
+		return t.jobEventTopic.Publish(ctx, data)
+	})
+}
+
 func (t *LibP2PTransport) HostID() string {
 	return t.host.ID().String()
 }
@@ -178,6 +326,24 @@ func (t *LibP2PTransport) GetPeers(ctx context.Context) (map[string][]peer.ID, e
 	return response, nil
 }
 
+// PeerVersions implements transport.VersionReporter.
+func (t *LibP2PTransport) PeerVersions() model.DebugInfoProvider {
+	return t.versions
+}
+
+// CheckConnection implements transport.ConnectionChecker. A node that was
+// given peers to connect to isn't connected to the network until it is
+// connected to at least one peer, while a node without any is on its own.
+func (t *LibP2PTransport) CheckConnection(ctx context.Context) error {
+	if t.bootstrap.isEmpty() {
+		return nil
+	}
+	if len(t.host.Network().Peers()) == 0 {
+		return fmt.Errorf("not connected to any peers, and could not connect to any of the bootstrap peers")
+	}
+	return nil
+}
+
 func (t *LibP2PTransport) Start(ctx context.Context) error {
 	ctx, span := system.GetTracer().Start(ctx, "pkg/transport/libp2p.Start")
 	ctx = logger.ContextWithNodeIDLogger(ctx, t.HostID())
@@ -215,7 +381,17 @@ func (t *LibP2PTransport) Start(ctx context.Context) error {
 		}
 	}()
 
-	go t.listenForEvents(ctx)
+	go t.listenForEvents(ctx, t.jobEventSubscription)
+	for engine, topic := range t.announcementTopics {
+		if !t.jobFilter.isSubscribedToEngine(engine) {
+			continue
+		}
+		subscription, err := topic.Subscribe()
+		if err != nil {
+			return err
+		}
+		go t.listenForEvents(ctx, subscription)
+	}
 
 	log.Ctx(ctx).Trace().Msg("Libp2p transport has started")
 
@@ -226,6 +402,14 @@ func (t *LibP2PTransport) Shutdown(ctx context.Context) error {
 	ctx, span := system.GetTracer().Start(ctx, "pkg/transport/libp2p.Shutdown")
 	defer span.End()
 
+	// send out any events still waiting in a batch
+	if t.batcher != nil {
+		t.batcher.stop(ctx)
+		if failures := t.batcher.failureCount(); failures > 0 {
+			log.Ctx(ctx).Warn().Msgf("%d batched events could not be published", failures)
+		}
+	}
+
 	// stop ticker
 	log.Ctx(ctx).Debug().Msgf("Sending shutdown signal to reconnect loop")
 	t.shutdownChan <- true
@@ -245,15 +429,34 @@ func (t *LibP2PTransport) Shutdown(ctx context.Context) error {
 func (t *LibP2PTransport) Publish(ctx context.Context, event model.JobEvent) error {
 	ctx, span := system.GetTracer().Start(ctx, "pkg/transport/libp2p.Publish")
 	defer span.End()
+	ctx = logger.ContextWithJobShardLogger(ctx, event.JobID, event.ShardIndex)
 
 	traceData := propagation.MapCarrier{}
 	otel.GetTextMapPropagator().Inject(ctx, &traceData)
 
-	bs, err := model.JSONMarshalWithMax(jobEventEnvelope{
-		JobEvent:  event,
-		TraceData: traceData,
-		SentTime:  time.Now(),
-	})
+	event = t.sequencer.Sequence(event)
+	envelope := jobEventEnvelope{
+		JobEvent:        event,
+		TraceData:       traceData,
+		SentTime:        time.Now(),
+		ProtocolVersion: ProtocolVersion,
+	}
+	peers, targeted, err := t.isTargetedEvent(event)
+	if err != nil {
+		return err
+	}
+	if targeted {
+		return t.publishDirect(ctx, peers, envelope)
+	}
+	if event.EventName == model.JobEventCreated {
+		return t.publishAnnouncement(ctx, envelope)
+	}
+	if t.batcher != nil {
+		log.Ctx(ctx).Trace().Msgf("Batching event %s", event.EventName.String())
+		return t.batcher.add(ctx, envelope)
+	}
+
+	bs, err := model.JSONMarshalWithMax(envelope)
 	if err != nil {
 		return err
 	}
@@ -322,6 +525,38 @@ func (t *LibP2PTransport) Decrypt(ctx context.Context, data []byte) ([]byte, err
 	)
 }
 
+func (t *LibP2PTransport) publishAnnouncement(ctx context.Context, envelope jobEventEnvelope) error {
+	engine := envelope.JobEvent.Spec.Engine
+	topic, ok := t.announcementTopics[engine]
+	if !ok {
+		return fmt.Errorf("no announcement topic for engine %s", engine)
+	}
+
+	bs, err := model.JSONMarshalWithMax(envelope)
+	if err != nil {
+		return err
+	}
+
+	if !t.legacyAnnouncements && !t.jobFilter.isSubscribedToEngine(engine) {
+		// we won't hear our own announcement back from the network, so hand
+		// it to the local subscribers directly
+		publicKey, err := crypto.MarshalPublicKey(t.privateKey.GetPublic())
+		if err != nil {
+			return err
+		}
+		go t.readEnvelope(logger.ContextWithNodeIDLogger(context.Background(), t.HostID()), publicKey, envelope)
+	}
+
+	log.Ctx(ctx).Trace().Msgf("Announcing job %s on %s", envelope.JobEvent.JobID, topic.String())
+	if err = topic.Publish(ctx, bs); err != nil {
+		return err
+	}
+	if t.legacyAnnouncements {
+		return t.jobEventTopic.Publish(ctx, bs)
+	}
+	return nil
+}
+
 /*
   libp2p
 */
@@ -330,26 +565,25 @@ func (t *LibP2PTransport) connectToPeers(ctx context.Context) error {
 	ctx, span := system.GetTracer().Start(ctx, "pkg/transport/libp2p.Subscribe")
 	defer span.End()
 
-	if len(t.peers) == 0 {
+	if t.bootstrap.isEmpty() {
 		return nil
 	}
 
-	errors := []error{}
-	for _, peerAddress := range t.peers {
-		// Extract the peer ID from the multiaddr.
-		info, err := peer.AddrInfoFromP2pAddr(peerAddress)
-		if err != nil {
-			errors = append(errors, err)
-			log.Ctx(ctx).Warn().Msgf("Error parsing peer address: %s", err)
+	infos, errors := t.bootstrap.peers(ctx)
+	for _, err := range errors {
+		log.Ctx(ctx).Warn().Msgf("Error finding bootstrap peers: %s", err)
+	}
+	for _, info := range infos {
+		if info.ID == t.host.ID() {
 			continue
 		}
 		t.host.Peerstore().AddAddrs(info.ID, info.Addrs, peerstore.PermanentAddrTTL)
-		err = t.host.Connect(ctx, *info)
+		err := t.host.Connect(ctx, info)
 		if err != nil {
 			errors = append(errors, err)
 			log.Ctx(ctx).Warn().Msgf("Error connecting to peer %s: %s, continuing...", info.ID, err)
 		} else {
-			log.Ctx(ctx).Trace().Msgf("Libp2p transport connected to: %s", peerAddress)
+			log.Ctx(ctx).Trace().Msgf("Libp2p transport connected to: %s", info)
 		}
 	}
 	if len(errors) > 0 {
@@ -371,19 +605,56 @@ type jobEventEnvelope struct {
 	SentTime  time.Time              `json:"sent_time"`
 	JobEvent  model.JobEvent         `json:"job_event"`
 	TraceData propagation.MapCarrier `json:"trace_data"`
+	// the version of the protocol the event was sent with, empty for nodes
+	// that predate versioning
+	ProtocolVersion string `json:"protocol_version,omitempty"`
+}
+
+// a message on the wire is either a single envelope or a batch of them
+type jobEventMessage struct {
+	jobEventEnvelope
+	Batch []jobEventEnvelope `json:"batch,omitempty"`
 }
 
 func (t *LibP2PTransport) readMessage(msg *pubsub.Message) {
 	// TODO: we would enforce the claims to SourceNodeID here
 	// i.e. msg.ReceivedFrom() should match msg.Data.JobEvent.SourceNodeID
 	ctx := logger.ContextWithNodeIDLogger(context.Background(), t.HostID())
-	payload := jobEventEnvelope{}
+	header := versionHeader{}
+	_ = model.JSONUnmarshalWithMax(msg.Data, &header)
+	if t.versions.dropIncompatible(ctx, msg.ReceivedFrom, header.ProtocolVersion) {
+		return
+	}
+
+	payload := jobEventMessage{}
 	err := model.JSONUnmarshalWithMax(msg.Data, &payload)
 	if err != nil {
-		log.Ctx(ctx).Error().Msgf("error unmarshalling libp2p event: %v", err)
+		log.Ctx(ctx).Error().Msgf("error unmarshalling libp2p event sent with protocol version %q: %v",
+			header.ProtocolVersion, err)
 		return
 	}
 
+	if len(payload.Batch) == 0 {
+		t.readEnvelope(ctx, msg.Key, payload.jobEventEnvelope)
+		return
+	}
+
+	log.Ctx(ctx).Trace().Msgf("Received batch of %d events", len(payload.Batch))
+	// events within a batch are handled in the order they were published
+	for _, envelope := range payload.Batch {
+		envelope.SentTime = payload.SentTime
+		t.readEnvelope(ctx, msg.Key, envelope)
+	}
+}
+
+func (t *LibP2PTransport) readEnvelope(ctx context.Context, senderKey []byte, payload jobEventEnvelope) {
+	for _, event := range t.jobFilter.filter(ctx, senderKey, payload) {
+		t.handleEnvelope(ctx, event.senderKey, event.envelope)
+	}
+}
+
+func (t *LibP2PTransport) handleEnvelope(ctx context.Context, senderKey []byte, payload jobEventEnvelope) {
+	ctx = logger.ContextWithJobShardLogger(ctx, payload.JobEvent.JobID, payload.JobEvent.ShardIndex)
 	now := time.Now()
 	then := payload.SentTime
 	latency := now.Sub(then)
@@ -391,36 +662,41 @@ func (t *LibP2PTransport) readMessage(msg *pubsub.Message) {
 	if latencyMilli > 500 { //nolint:gomnd
 		log.Ctx(ctx).Warn().Msgf(
 			"[%s=>%s] VERY High message latency: %d ms (%s)",
-			payload.JobEvent.SourceNodeID[:model.ShortIDLength],
-			t.host.ID().String()[:model.ShortIDLength],
+			system.GetShortID(payload.JobEvent.SourceNodeID),
+			system.GetShortID(t.host.ID().String()),
 			latencyMilli, payload.JobEvent.EventName.String(),
 		)
 	} else if latencyMilli > 50 { //nolint:gomnd
 		log.Ctx(ctx).Warn().Msgf(
 			"[%s=>%s] High message latency: %d ms (%s)",
-			payload.JobEvent.SourceNodeID[:model.ShortIDLength],
-			t.host.ID().String()[:model.ShortIDLength],
+			system.GetShortID(payload.JobEvent.SourceNodeID),
+			system.GetShortID(t.host.ID().String()),
 			latencyMilli, payload.JobEvent.EventName.String(),
 		)
 	} else {
 		log.Ctx(ctx).Trace().Msgf(
 			"[%s=>%s] Message latency: %d ms (%s)",
-			payload.JobEvent.SourceNodeID[:model.ShortIDLength],
-			t.host.ID().String()[:model.ShortIDLength],
+			system.GetShortID(payload.JobEvent.SourceNodeID),
+			system.GetShortID(t.host.ID().String()),
 			latencyMilli, payload.JobEvent.EventName.String(),
 		)
 	}
 
 	log.Ctx(ctx).Trace().Msgf("Received event %s: %+v", payload.JobEvent.EventName.String(), payload)
 
+	t.shardProtector.handleEvent(ctx, payload.JobEvent)
+	if payload.JobEvent.EventName == model.JobEventError && payload.JobEvent.TargetNodeID == "" {
+		t.removeTargetedJob(payload.JobEvent.JobID)
+	}
+
 	// Notify all the listeners in this process of the event:
 	jobCtx := otel.GetTextMapPropagator().Extract(ctx, payload.TraceData)
 
-	ev := payload.JobEvent
+	ev := t.clockSkew.correct(ctx, payload, now)
 	// NOTE: Do not use msg.ReceivedFrom as the original sender, it's not. It's
 	// the node which gossiped the message to us, which might be different.
 	// (was: ev.SourceNodeID = msg.ReceivedFrom.String())
-	ev.SenderPublicKey = msg.Key
+	ev.SenderPublicKey = senderKey
 
 	var wg realsync.WaitGroup
 	func() {
@@ -441,9 +717,9 @@ func (t *LibP2PTransport) readMessage(msg *pubsub.Message) {
 	wg.Wait()
 }
 
-func (t *LibP2PTransport) listenForEvents(ctx context.Context) {
+func (t *LibP2PTransport) listenForEvents(ctx context.Context, subscription *pubsub.Subscription) {
 	for {
-		msg, err := t.jobEventSubscription.Next(ctx)
+		msg, err := subscription.Next(ctx)
 		if err != nil {
 			if err == context.Canceled || err == context.DeadlineExceeded {
 				log.Ctx(ctx).Trace().Msgf("libp2p transport shutting down: %v", err)
@@ -459,3 +735,5 @@ func (t *LibP2PTransport) listenForEvents(ctx context.Context) {
 
 // Compile-time interface check:
 var _ transport.Transport = (*LibP2PTransport)(nil)
+var _ transport.ConnectionChecker = (*LibP2PTransport)(nil)
+var _ transport.VersionReporter = (*LibP2PTransport)(nil)
@@ -33,6 +33,31 @@ func (suite *Libp2pTransportSuite) SetupTest() {
 	logger.ConfigureTestLogging(suite.T())
 }
 
+func (suite *Libp2pTransportSuite) TestCheckConnection() {
+	cm := system.NewCleanupManager()
+	defer cm.Cleanup()
+	ctx := context.Background()
+
+	firstPort, err := freeport.GetFreePort()
+	require.NoError(suite.T(), err)
+	secondPort, err := freeport.GetFreePort()
+	require.NoError(suite.T(), err)
+
+	// a node without peers is on its own
+	first, err := NewTransport(ctx, cm, firstPort, []multiaddr.Multiaddr{})
+	require.NoError(suite.T(), err)
+	require.NoError(suite.T(), first.CheckConnection(ctx))
+
+	addr, err := multiaddr.NewMultiaddr(fmt.Sprintf("/ip4/127.0.0.1/tcp/%d/p2p/%s", firstPort, first.HostID()))
+	require.NoError(suite.T(), err)
+	second, err := NewTransport(ctx, cm, secondPort, []multiaddr.Multiaddr{addr})
+	require.NoError(suite.T(), err)
+	require.ErrorContains(suite.T(), second.CheckConnection(ctx), "not connected to any peers")
+
+	require.NoError(suite.T(), second.connectToPeers(ctx))
+	require.NoError(suite.T(), second.CheckConnection(ctx))
+}
+
 func (suite *Libp2pTransportSuite) TestEncryption() {
 	TestData := "hello encryption my old friend"
 	cm := system.NewCleanupManager()
@@ -0,0 +1,17 @@
+package libp2p
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Prometheus metrics for monitoring the events received over libp2p:
+var (
+	clockSkew = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "transport_clock_skew_seconds",
+			Help: "How far ahead of the clock of the node the clock of a node sending it events is, when past the tolerated skew.",
+		},
+		[]string{"node_id", "source_node_id"},
+	)
+)
@@ -0,0 +1,181 @@
+package libp2p
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	realsync "sync"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/event"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/p2p/host/autorelay"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/rs/zerolog/log"
+)
+
+// NATConfig controls how a node that is not directly reachable, for example
+// one behind a home router, makes itself reachable to the rest of the network.
+type NATConfig struct {
+	// Accept and make connections through circuit relays.
+	EnableRelay bool
+	// Act as a circuit relay v2 for other nodes when we are publicly reachable.
+	EnableRelayService bool
+	// Reserve a slot with a relay and advertise the relayed address when we
+	// detect that we are not publicly reachable.
+	EnableAutoRelay bool
+	// Relays to use for AutoRelay. The bootstrap peers are used if empty.
+	StaticRelays []multiaddr.Multiaddr
+	// Upgrade relayed connections to direct ones using hole punching.
+	EnableHolePunching bool
+	// Ask the router to open a port for us using UPnP or NAT-PMP.
+	EnableNATPortMap bool
+	// Help other nodes find out whether they are reachable.
+	EnableNATService bool
+}
+
+// DefaultNATConfig lets publicly reachable nodes help the others: they act
+// as relays and tell other nodes whether they are reachable. libp2p only
+// starts the relay service once the node finds out it is publicly reachable,
+// and that can only happen if some of its peers run the NAT service.
+var DefaultNATConfig = NATConfig{
+	EnableRelay:        true,
+	EnableRelayService: true,
+	EnableNATService:   true,
+}
+
+func (c NATConfig) options(peers []multiaddr.Multiaddr) ([]libp2p.Option, error) {
+	opts := []libp2p.Option{}
+	if c.EnableRelay {
+		opts = append(opts, libp2p.EnableRelay())
+	} else {
+		opts = append(opts, libp2p.DisableRelay())
+	}
+	if c.EnableRelayService {
+		opts = append(opts, libp2p.EnableRelayService())
+	}
+	if c.EnableAutoRelay {
+		if !c.EnableRelay {
+			return nil, fmt.Errorf("auto relay cannot be enabled when relaying is disabled")
+		}
+		relayAddrs := c.StaticRelays
+		if len(relayAddrs) == 0 {
+			relayAddrs = peers
+		}
+		if len(relayAddrs) == 0 {
+			return nil, fmt.Errorf("auto relay needs at least one static relay or bootstrap peer")
+		}
+		relays, err := peer.AddrInfosFromP2pAddrs(relayAddrs...)
+		if err != nil {
+			return nil, fmt.Errorf("invalid relay address: %w", err)
+		}
+		opts = append(opts, libp2p.EnableAutoRelay(autorelay.WithStaticRelays(relays)))
+	}
+	if c.EnableHolePunching {
+		opts = append(opts, libp2p.EnableHolePunching())
+	}
+	if c.EnableNATPortMap {
+		opts = append(opts, libp2p.NATPortMap())
+	}
+	if c.EnableNATService {
+		opts = append(opts, libp2p.EnableNATService())
+	}
+	return opts, nil
+}
+
+// NATStatus describes how reachable this node is from the rest of the network.
+type NATStatus struct {
+	// Reachability as detected by AutoNAT: Unknown, Public or Private.
+	Reachability string `json:"Reachability"`
+	// The addresses we are listening on.
+	ListenAddrs []string `json:"ListenAddrs"`
+	// The addresses we advertise to other peers, including relayed ones.
+	AdvertisedAddrs []string `json:"AdvertisedAddrs"`
+	// Whether we have a reservation with a relay.
+	Relayed bool `json:"Relayed"`
+	// The number of peers we are connected to.
+	ConnectedPeers int `json:"ConnectedPeers"`
+}
+
+// reachabilityTracker keeps track of the reachability AutoNAT reports for
+// the host.
+type reachabilityTracker struct {
+	mutex        realsync.RWMutex
+	reachability network.Reachability
+}
+
+func (r *reachabilityTracker) start(ctx context.Context, h host.Host) error {
+	sub, err := h.EventBus().Subscribe(new(event.EvtLocalReachabilityChanged))
+	if err != nil {
+		return err
+	}
+	go func() {
+		defer sub.Close()
+		for {
+			select {
+			case evt, ok := <-sub.Out():
+				if !ok {
+					return
+				}
+				reachability := evt.(event.EvtLocalReachabilityChanged).Reachability
+				log.Ctx(ctx).Debug().Msgf("libp2p reachability changed to %s", reachability)
+				r.mutex.Lock()
+				r.reachability = reachability
+				r.mutex.Unlock()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func (r *reachabilityTracker) get() network.Reachability {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.reachability
+}
+
+// NATStatus reports how reachable this node currently is.
+func (t *LibP2PTransport) NATStatus() NATStatus {
+	status := NATStatus{
+		Reachability:    t.reachability.get().String(),
+		ListenAddrs:     []string{},
+		AdvertisedAddrs: []string{},
+		ConnectedPeers:  len(t.host.Network().Peers()),
+	}
+	for _, addr := range t.host.Network().ListenAddresses() {
+		status.ListenAddrs = append(status.ListenAddrs, addr.String())
+	}
+	for _, addr := range t.host.Addrs() {
+		status.AdvertisedAddrs = append(status.AdvertisedAddrs, addr.String())
+		if _, err := addr.ValueForProtocol(multiaddr.P_CIRCUIT); err == nil {
+			status.Relayed = true
+		}
+	}
+	// libp2p doesn't keep the addresses in any order
+	sort.Strings(status.ListenAddrs)
+	sort.Strings(status.AdvertisedAddrs)
+	return status
+}
+
+// GetDebugInfo implements model.DebugInfoProvider
+func (t *LibP2PTransport) GetDebugInfo() (model.DebugInfo, error) {
+	status, err := json.Marshal(t.NATStatus())
+	if err != nil {
+		return model.DebugInfo{}, err
+	}
+	return model.DebugInfo{
+		Component: NATDebugInfoComponent,
+		Info:      string(status),
+	}, nil
+}
+
+// The name under which the NAT status shows up in the node debug info.
+const NATDebugInfoComponent = "libp2p-nat"
+
+var _ model.DebugInfoProvider = (*LibP2PTransport)(nil)
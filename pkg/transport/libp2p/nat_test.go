@@ -0,0 +1,96 @@
+//go:build unit || !integration
+
+package libp2p
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/filecoin-project/bacalhau/pkg/system"
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/test"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNATConfigOptions(t *testing.T) {
+	relayAddr, err := multiaddr.NewMultiaddr(fmt.Sprintf("/ip4/127.0.0.1/tcp/1235/p2p/%s", test.RandPeerIDFatal(t)))
+	require.NoError(t, err)
+
+	for _, testCase := range []struct {
+		name   string
+		config NATConfig
+		peers  []multiaddr.Multiaddr
+		valid  bool
+	}{
+		{name: "default", config: DefaultNATConfig, valid: true},
+		{name: "no relay", config: NATConfig{}, valid: true},
+		{
+			name:   "auto relay with bootstrap peers",
+			config: NATConfig{EnableRelay: true, EnableAutoRelay: true},
+			peers:  []multiaddr.Multiaddr{relayAddr},
+			valid:  true,
+		},
+		{
+			name:   "auto relay with static relays",
+			config: NATConfig{EnableRelay: true, EnableAutoRelay: true, StaticRelays: []multiaddr.Multiaddr{relayAddr}},
+			valid:  true,
+		},
+		{
+			name:   "auto relay without relays",
+			config: NATConfig{EnableRelay: true, EnableAutoRelay: true},
+		},
+		{
+			name:   "auto relay with relaying disabled",
+			config: NATConfig{EnableAutoRelay: true},
+			peers:  []multiaddr.Multiaddr{relayAddr},
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			opts, err := testCase.config.options(testCase.peers)
+			if !testCase.valid {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.NotEmpty(t, opts)
+		})
+	}
+}
+
+func TestNATStatus(t *testing.T) {
+	cm := system.NewCleanupManager()
+	defer cm.Cleanup()
+
+	transport, err := NewTransportFromOptions(context.Background(), cm, nil,
+		libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"),
+	)
+	require.NoError(t, err)
+	cm.RegisterCallback(transport.host.Close)
+
+	status := transport.NATStatus()
+	require.Equal(t, network.ReachabilityUnknown.String(), status.Reachability)
+	// relaying is enabled by default, so the node also listens for relayed
+	// connections on /p2p-circuit
+	require.Contains(t, status.ListenAddrs, "/p2p-circuit")
+	require.Len(t, status.ListenAddrs, 2)
+	tcpListening := false
+	for _, addr := range status.ListenAddrs {
+		tcpListening = tcpListening || strings.HasPrefix(addr, "/ip4/127.0.0.1/tcp/")
+	}
+	require.True(t, tcpListening)
+	require.False(t, status.Relayed)
+	require.Zero(t, status.ConnectedPeers)
+
+	debugInfo, err := transport.GetDebugInfo()
+	require.NoError(t, err)
+	require.Equal(t, NATDebugInfoComponent, debugInfo.Component)
+
+	reported := NATStatus{}
+	require.NoError(t, json.Unmarshal([]byte(debugInfo.Info), &reported))
+	require.Equal(t, status, reported)
+}
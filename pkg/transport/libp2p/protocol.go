@@ -0,0 +1,289 @@
+package libp2p
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	realsync "sync"
+	"time"
+
+	"github.com/Masterminds/semver"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/libp2p/go-libp2p/core/event"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/rs/zerolog/log"
+)
+
+// ProtocolVersion is the version of the events nodes send each other. The
+// major version changes when nodes can no longer read the events of nodes
+// with an older one, the minor version when events gain fields that nodes
+// with an older one ignore.
+const ProtocolVersion = "1.1.0"
+
+// legacyProtocolVersion is the version spoken by nodes that predate
+// versioned events, which don't say which version they speak.
+const legacyProtocolVersion = "1.0.0"
+
+// protocolVersionPrefix marks the protocol version libp2p identify reports
+// for bacalhau nodes, to tell them apart from other libp2p peers.
+const protocolVersionPrefix = "bacalhau/"
+
+// The name under which the versions of the peers show up in the node debug info.
+const VersionDebugInfoComponent = "libp2p-versions"
+
+// incompatibleWarningInterval is how often events dropped from each peer
+// speaking an incompatible version are logged. Incompatible peers are also
+// forgotten once they have been disconnected for that long.
+const incompatibleWarningInterval = time.Minute
+
+// versionHeader is the part of every message on the wire that can be read
+// whatever version it was sent with.
+type versionHeader struct {
+	ProtocolVersion string `json:"protocol_version,omitempty"`
+}
+
+// isCompatibleVersion returns whether events sent with the protocol version
+// can be read by this node. Messages without a version come from nodes that
+// predate versioning, and are compatible.
+func isCompatibleVersion(protocolVersion string) (bool, error) {
+	if protocolVersion == "" {
+		protocolVersion = legacyProtocolVersion
+	}
+	theirs, err := semver.NewVersion(protocolVersion)
+	if err != nil {
+		return false, fmt.Errorf("invalid protocol version %q: %w", protocolVersion, err)
+	}
+	ours := semver.MustParse(ProtocolVersion)
+	return theirs.Major() == ours.Major(), nil
+}
+
+// PeerVersion is what a peer said about itself when it was identified.
+type PeerVersion struct {
+	// The version of the events the peer sends, if it is a bacalhau node.
+	ProtocolVersion string `json:"ProtocolVersion"`
+	// The version of bacalhau the peer runs.
+	AgentVersion string `json:"AgentVersion"`
+	// Whether this node can read the events the peer sends.
+	Compatible bool `json:"Compatible"`
+}
+
+// PeerVersions reports the versions of the peers of a node.
+type PeerVersions struct {
+	ProtocolVersion string                 `json:"ProtocolVersion"`
+	Peers           map[string]PeerVersion `json:"Peers"`
+	// Warnings about the peers running other versions than this node.
+	Warnings []string `json:"Warnings"`
+}
+
+// peerVersionTracker keeps track of the versions the peers of the host say
+// they run, and disconnects from those whose events it can't read.
+type peerVersionTracker struct {
+	host host.Host
+	now  func() time.Time
+
+	mutex realsync.RWMutex
+	peers map[peer.ID]PeerVersion
+	// when the incompatible peers that are kept to warn about them were
+	// disconnected
+	disconnectedAt map[peer.ID]time.Time
+	// when events dropped from each sender were last logged
+	lastWarned map[string]time.Time
+	lastPruned time.Time
+}
+
+func newPeerVersionTracker(h host.Host) *peerVersionTracker {
+	return &peerVersionTracker{
+		host:           h,
+		now:            time.Now,
+		peers:          map[peer.ID]PeerVersion{},
+		disconnectedAt: map[peer.ID]time.Time{},
+		lastWarned:     map[string]time.Time{},
+	}
+}
+
+func (v *peerVersionTracker) start(ctx context.Context) error {
+	sub, err := v.host.EventBus().Subscribe([]interface{}{
+		new(event.EvtPeerIdentificationCompleted),
+		new(event.EvtPeerConnectednessChanged),
+	})
+	if err != nil {
+		return err
+	}
+	go func() {
+		defer sub.Close()
+		for {
+			select {
+			case evt, ok := <-sub.Out():
+				if !ok {
+					return
+				}
+				switch evt := evt.(type) {
+				case event.EvtPeerIdentificationCompleted:
+					v.identified(ctx, evt.Peer)
+				case event.EvtPeerConnectednessChanged:
+					if evt.Connectedness != network.Connected {
+						v.disconnected(evt.Peer)
+					}
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// identified records the versions of a peer once libp2p has identified it.
+// Bacalhau nodes whose events this node can't read are disconnected from, so
+// that they don't silently fail to take part in jobs.
+func (v *peerVersionTracker) identified(ctx context.Context, peerID peer.ID) {
+	protocolVersion := v.peerstoreString(peerID, "ProtocolVersion")
+	if !strings.HasPrefix(protocolVersion, protocolVersionPrefix) {
+		// not a bacalhau node, or one that predates versioning
+		return
+	}
+	protocolVersion = strings.TrimPrefix(protocolVersion, protocolVersionPrefix)
+	compatible, err := isCompatibleVersion(protocolVersion)
+	if err != nil {
+		log.Ctx(ctx).Warn().Msgf("peer %s: %s", peerID, err)
+	}
+
+	v.mutex.Lock()
+	v.peers[peerID] = PeerVersion{
+		ProtocolVersion: protocolVersion,
+		AgentVersion:    v.peerstoreString(peerID, "AgentVersion"),
+		Compatible:      compatible,
+	}
+	delete(v.disconnectedAt, peerID)
+	v.mutex.Unlock()
+
+	if !compatible {
+		log.Ctx(ctx).Warn().Msgf("disconnecting from peer %s which speaks protocol version %s, incompatible with %s",
+			peerID, protocolVersion, ProtocolVersion)
+		if err = v.host.Network().ClosePeer(peerID); err != nil {
+			log.Ctx(ctx).Debug().Err(err).Msgf("error disconnecting from peer %s", peerID)
+		}
+	} else if protocolVersion != ProtocolVersion {
+		log.Ctx(ctx).Debug().Msgf("peer %s speaks protocol version %s, this node %s", peerID, protocolVersion, ProtocolVersion)
+	}
+}
+
+// disconnected forgets a peer once it is disconnected. Incompatible peers are
+// kept to warn about them, until they have been gone for
+// incompatibleWarningInterval.
+func (v *peerVersionTracker) disconnected(peerID peer.ID) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	now := v.now()
+	if peerVersion, ok := v.peers[peerID]; ok && !peerVersion.Compatible {
+		v.disconnectedAt[peerID] = now
+	} else {
+		delete(v.peers, peerID)
+	}
+	v.prune(now)
+}
+
+// prune forgets the incompatible peers that have been disconnected for
+// incompatibleWarningInterval, and when events were last dropped from senders
+// that long ago, at most once per interval. The caller must hold mutex.
+func (v *peerVersionTracker) prune(now time.Time) {
+	if now.Sub(v.lastPruned) < incompatibleWarningInterval {
+		return
+	}
+	for peerID, disconnectedAt := range v.disconnectedAt {
+		if now.Sub(disconnectedAt) >= incompatibleWarningInterval {
+			delete(v.peers, peerID)
+			delete(v.disconnectedAt, peerID)
+			delete(v.lastWarned, peerID.String())
+		}
+	}
+	for sender, lastWarned := range v.lastWarned {
+		if now.Sub(lastWarned) >= incompatibleWarningInterval {
+			delete(v.lastWarned, sender)
+		}
+	}
+	v.lastPruned = now
+}
+
+func (v *peerVersionTracker) peerstoreString(peerID peer.ID, key string) string {
+	value, err := v.host.Peerstore().Get(peerID, key)
+	if err != nil {
+		return ""
+	}
+	s, _ := value.(string)
+	return s
+}
+
+// dropIncompatible reports whether a message sent with the protocol version
+// can't be read by this node, logging it at most once a minute per sender.
+func (v *peerVersionTracker) dropIncompatible(ctx context.Context, sender peer.ID, protocolVersion string) bool {
+	compatible, err := isCompatibleVersion(protocolVersion)
+	if compatible {
+		return false
+	}
+	v.mutex.Lock()
+	now := v.now()
+	warn := now.Sub(v.lastWarned[sender.String()]) >= incompatibleWarningInterval
+	if warn {
+		v.prune(now)
+		v.lastWarned[sender.String()] = now
+	}
+	v.mutex.Unlock()
+	if warn {
+		if err != nil {
+			log.Ctx(ctx).Warn().Msgf("dropping events from %s: %s", sender, err)
+		} else {
+			log.Ctx(ctx).Warn().Msgf("dropping events from %s sent with protocol version %s, incompatible with %s",
+				sender, protocolVersion, ProtocolVersion)
+		}
+	}
+	return true
+}
+
+// Versions returns the versions of the connected bacalhau peers, with
+// warnings for those running other versions than this node.
+func (v *peerVersionTracker) Versions() PeerVersions {
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+	versions := PeerVersions{
+		ProtocolVersion: ProtocolVersion,
+		Peers:           map[string]PeerVersion{},
+		Warnings:        []string{},
+	}
+	counts := map[string]int{}
+	for peerID, peerVersion := range v.peers {
+		versions.Peers[peerID.String()] = peerVersion
+		if peerVersion.ProtocolVersion != ProtocolVersion {
+			counts[peerVersion.ProtocolVersion]++
+		}
+	}
+	for protocolVersion, count := range counts {
+		compatible, _ := isCompatibleVersion(protocolVersion)
+		description := "compatible"
+		if !compatible {
+			description = "incompatible"
+		}
+		versions.Warnings = append(versions.Warnings, fmt.Sprintf(
+			"%d peers speak protocol version %s, %s with %s", count, protocolVersion, description, ProtocolVersion))
+	}
+	sort.Strings(versions.Warnings)
+	return versions
+}
+
+// GetDebugInfo implements model.DebugInfoProvider
+func (v *peerVersionTracker) GetDebugInfo() (model.DebugInfo, error) {
+	versions, err := json.Marshal(v.Versions())
+	if err != nil {
+		return model.DebugInfo{}, err
+	}
+	return model.DebugInfo{
+		Component: VersionDebugInfoComponent,
+		Info:      string(versions),
+	}, nil
+}
+
+var _ model.DebugInfoProvider = (*peerVersionTracker)(nil)
@@ -0,0 +1,104 @@
+//go:build unit || !integration
+
+package libp2p
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/system"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsCompatibleVersion(t *testing.T) {
+	for _, testCase := range []struct {
+		version    string
+		compatible bool
+	}{
+		{"", true},
+		{ProtocolVersion, true},
+		{"1.0.0", true},
+		{"1.9.2", true},
+		{"2.0.0", false},
+		{"0.9.0", false},
+	} {
+		compatible, err := isCompatibleVersion(testCase.version)
+		require.NoError(t, err)
+		require.Equal(t, testCase.compatible, compatible, testCase.version)
+	}
+
+	_, err := isCompatibleVersion("not-a-version")
+	require.Error(t, err)
+}
+
+func TestPeerVersions(t *testing.T) {
+	cm := system.NewCleanupManager()
+	defer cm.Cleanup()
+	ctx := context.Background()
+
+	requester, _ := startTestTransport(t, cm)
+	compute, computeEvents := startTestTransport(t, cm, requester)
+
+	require.Eventually(t, func() bool {
+		_, ok := requester.versions.Versions().Peers[compute.HostID()]
+		return ok
+	}, 10*time.Second, 50*time.Millisecond)
+	versions := requester.versions.Versions()
+	require.Equal(t, ProtocolVersion, versions.Peers[compute.HostID()].ProtocolVersion)
+	require.True(t, versions.Peers[compute.HostID()].Compatible)
+	require.Empty(t, versions.Warnings)
+
+	require.NoError(t, requester.Publish(ctx, model.JobEvent{
+		JobID:        "job-1",
+		EventName:    model.JobEventCreated,
+		SourceNodeID: requester.HostID(),
+		Spec:         model.Spec{Engine: model.EngineNoop},
+		Deal:         model.Deal{Concurrency: 1, TargetNodes: []string{compute.HostID()}},
+	}))
+	require.Equal(t, model.JobEventCreated, computeEvents.next(t).EventName)
+
+	// events sent with a protocol version the node can't read are dropped
+	bs, err := model.JSONMarshalWithMax(jobEventEnvelope{
+		JobEvent: model.JobEvent{
+			JobID:        "job-1",
+			EventName:    model.JobEventBidAccepted,
+			SourceNodeID: requester.HostID(),
+			TargetNodeID: compute.HostID(),
+		},
+		SentTime:        time.Now(),
+		ProtocolVersion: "2.0.0",
+	})
+	require.NoError(t, err)
+	require.NoError(t, requester.sendDirect(ctx, compute.host.ID(), bs))
+	computeEvents.none(t)
+}
+
+func TestPeerVersionTrackerForgetsDisconnectedPeers(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	v := newPeerVersionTracker(nil)
+	v.now = func() time.Time { return now }
+
+	compatible, incompatible := peer.ID("compatible"), peer.ID("incompatible")
+	v.peers[compatible] = PeerVersion{ProtocolVersion: ProtocolVersion, Compatible: true}
+	v.peers[incompatible] = PeerVersion{ProtocolVersion: "2.0.0"}
+	require.True(t, v.dropIncompatible(ctx, incompatible, "2.0.0"))
+	require.True(t, v.dropIncompatible(ctx, "gossiping-sender", "2.0.0"))
+
+	// compatible peers are forgotten as soon as they disconnect, incompatible
+	// ones are kept to warn about them for a while
+	v.disconnected(compatible)
+	v.disconnected(incompatible)
+	require.NotContains(t, v.Versions().Peers, compatible.String())
+	require.Contains(t, v.Versions().Peers, incompatible.String())
+	require.Len(t, v.Versions().Warnings, 1)
+
+	now = now.Add(incompatibleWarningInterval)
+	v.disconnected("other-peer")
+	require.Empty(t, v.Versions().Peers)
+	require.Empty(t, v.disconnectedAt)
+	require.Empty(t, v.lastWarned)
+}
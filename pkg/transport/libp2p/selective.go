@@ -0,0 +1,184 @@
+package libp2p
+
+import (
+	"context"
+	realsync "sync"
+	"time"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// How long events for a job we have not seen announced are held back
+	// waiting for its announcement, which travels on a different topic.
+	earlyEventTTL = 30 * time.Second
+	// Upper bounds on the events held back, per job and in total.
+	maxEarlyEventsPerJob = 64
+	maxEarlyEvents       = 4096
+	// How long an announcement is remembered so that the copy gossiped on
+	// the other topic is dropped.
+	announcementDedupTTL = 10 * time.Minute
+	// How long the jobs of engines we are not subscribed to are remembered,
+	// so that their events are dropped straight away.
+	ignoredJobTTL = time.Hour
+	// How often the maps above are pruned.
+	jobFilterPruneInterval = 10 * time.Second
+)
+
+// isKnownJobFn reports whether the node already holds a job, for example
+// because it was announced before the node restarted.
+type isKnownJobFn func(ctx context.Context, jobID string) bool
+
+type earlyEvent struct {
+	envelope   jobEventEnvelope
+	senderKey  []byte
+	receivedAt time.Time
+}
+
+// jobFilter decides which incoming events are handed to the subscribers.
+//
+// Job announcements are gossiped on both the per-engine topics and the main
+// event topic, so the second copy of each announcement is dropped. When
+// subscribed to some engines only, announcements for other engines are
+// dropped along with the events of those jobs. Events for a job whose
+// announcement has not arrived yet are held back until it does, or until
+// earlyEventTTL passes.
+type jobFilter struct {
+	hostID     string
+	engines    map[model.Engine]bool
+	isKnownJob isKnownJobFn
+	now        func() time.Time
+
+	mutex      realsync.Mutex
+	announced  map[string]time.Time
+	ignored    map[string]time.Time
+	early      map[string][]earlyEvent
+	earlyCount int
+	lastPruned time.Time
+}
+
+func newJobFilter(hostID string) *jobFilter {
+	return &jobFilter{
+		hostID:    hostID,
+		now:       time.Now,
+		announced: map[string]time.Time{},
+		ignored:   map[string]time.Time{},
+		early:     map[string][]earlyEvent{},
+	}
+}
+
+// subscribe limits the jobs let through to those of the given engines, nil
+// meaning all of them.
+func (f *jobFilter) subscribe(engines []model.Engine, isKnownJob isKnownJobFn) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.isKnownJob = isKnownJob
+	if len(engines) == 0 {
+		f.engines = nil
+		return
+	}
+	f.engines = map[model.Engine]bool{}
+	for _, engine := range engines {
+		f.engines[engine] = true
+	}
+}
+
+// isKnown returns whether a job has been announced or is held by the node.
+func (f *jobFilter) isKnown(ctx context.Context, jobID string) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if _, ok := f.announced[jobID]; ok {
+		return true
+	}
+	return f.isKnownJob != nil && f.isKnownJob(ctx, jobID)
+}
+
+func (f *jobFilter) isSubscribedToEngine(engine model.Engine) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.engines == nil || f.engines[engine]
+}
+
+// filter returns the events to hand to the subscribers following the arrival
+// of an event, in the order they should be handled.
+func (f *jobFilter) filter(ctx context.Context, senderKey []byte, envelope jobEventEnvelope) []earlyEvent {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	now := f.now()
+	f.pruneLocked(ctx, now)
+
+	event := envelope.JobEvent
+	arrived := earlyEvent{envelope: envelope, senderKey: senderKey, receivedAt: now}
+
+	if event.EventName == model.JobEventCreated {
+		if _, ok := f.announced[event.JobID]; ok {
+			log.Ctx(ctx).Trace().Msgf("ignoring duplicate announcement of job %s", event.JobID)
+			return nil
+		}
+		if f.engines != nil && !f.engines[event.Spec.Engine] && event.SourceNodeID != f.hostID {
+			log.Ctx(ctx).Trace().Msgf("ignoring job %s for engine %s we are not subscribed to", event.JobID, event.Spec.Engine)
+			f.ignored[event.JobID] = now
+			f.dropEarlyLocked(event.JobID)
+			return nil
+		}
+		f.announced[event.JobID] = now
+		events := append([]earlyEvent{arrived}, f.early[event.JobID]...)
+		f.dropEarlyLocked(event.JobID)
+		return events
+	}
+
+	if f.engines == nil {
+		return []earlyEvent{arrived}
+	}
+	if _, ok := f.ignored[event.JobID]; ok {
+		return nil
+	}
+	if _, ok := f.announced[event.JobID]; ok {
+		return []earlyEvent{arrived}
+	}
+	if f.isKnownJob != nil && f.isKnownJob(ctx, event.JobID) {
+		return []earlyEvent{arrived}
+	}
+
+	if len(f.early[event.JobID]) >= maxEarlyEventsPerJob || f.earlyCount >= maxEarlyEvents {
+		log.Ctx(ctx).Warn().Msgf("dropping event %s for unannounced job %s, too many events held back",
+			event.EventName, event.JobID)
+		return nil
+	}
+	log.Ctx(ctx).Trace().Msgf("holding back event %s until job %s is announced", event.EventName, event.JobID)
+	f.early[event.JobID] = append(f.early[event.JobID], arrived)
+	f.earlyCount++
+	return nil
+}
+
+func (f *jobFilter) dropEarlyLocked(jobID string) {
+	f.earlyCount -= len(f.early[jobID])
+	delete(f.early, jobID)
+}
+
+func (f *jobFilter) pruneLocked(ctx context.Context, now time.Time) {
+	if now.Sub(f.lastPruned) < jobFilterPruneInterval {
+		return
+	}
+	f.lastPruned = now
+
+	for jobID, announcedAt := range f.announced {
+		if now.Sub(announcedAt) > announcementDedupTTL {
+			delete(f.announced, jobID)
+		}
+	}
+	for jobID, ignoredAt := range f.ignored {
+		if now.Sub(ignoredAt) > ignoredJobTTL {
+			delete(f.ignored, jobID)
+		}
+	}
+	for jobID, events := range f.early {
+		// events are held in arrival order, so the first is the oldest
+		if now.Sub(events[0].receivedAt) > earlyEventTTL {
+			log.Ctx(ctx).Debug().Msgf("dropping %d events for job %s that was never announced", len(events), jobID)
+			f.dropEarlyLocked(jobID)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+//go:build unit || !integration
+
+package libp2p
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/stretchr/testify/require"
+)
+
+const testHostID = "QmHostNodeID"
+
+func jobEnvelope(jobID string, eventName model.JobEventType, engine model.Engine) jobEventEnvelope {
+	return jobEventEnvelope{
+		JobEvent: model.JobEvent{
+			JobID:        jobID,
+			SourceNodeID: "QmOtherNodeID",
+			EventName:    eventName,
+			Spec:         model.Spec{Engine: engine},
+		},
+	}
+}
+
+func filteredEventNames(events []earlyEvent) []model.JobEventType {
+	names := []model.JobEventType{}
+	for _, event := range events {
+		names = append(names, event.envelope.JobEvent.EventName)
+	}
+	return names
+}
+
+func TestJobFilterDropsDuplicateAnnouncements(t *testing.T) {
+	ctx := context.Background()
+	filter := newJobFilter(testHostID)
+
+	created := jobEnvelope("job-1", model.JobEventCreated, model.EngineDocker)
+	require.Len(t, filter.filter(ctx, nil, created), 1)
+	// the same announcement gossiped on the other topic
+	require.Empty(t, filter.filter(ctx, nil, created))
+	require.Len(t, filter.filter(ctx, nil, jobEnvelope("job-1", model.JobEventBid, model.EngineDocker)), 1)
+}
+
+func TestJobFilterDropsOtherEngines(t *testing.T) {
+	ctx := context.Background()
+	filter := newJobFilter(testHostID)
+	filter.subscribe([]model.Engine{model.EngineWasm}, nil)
+
+	require.True(t, filter.isSubscribedToEngine(model.EngineWasm))
+	require.False(t, filter.isSubscribedToEngine(model.EngineDocker))
+
+	require.Empty(t, filter.filter(ctx, nil, jobEnvelope("job-1", model.JobEventCreated, model.EngineDocker)))
+	require.Empty(t, filter.filter(ctx, nil, jobEnvelope("job-1", model.JobEventBid, model.EngineDocker)))
+	require.Empty(t, filter.early)
+
+	// our own jobs always get through
+	ownJob := jobEnvelope("job-2", model.JobEventCreated, model.EngineDocker)
+	ownJob.JobEvent.SourceNodeID = testHostID
+	require.Len(t, filter.filter(ctx, nil, ownJob), 1)
+}
+
+func TestJobFilterHoldsBackEarlyEvents(t *testing.T) {
+	ctx := context.Background()
+	filter := newJobFilter(testHostID)
+	filter.subscribe([]model.Engine{model.EngineWasm}, nil)
+
+	require.Empty(t, filter.filter(ctx, nil, jobEnvelope("job-1", model.JobEventBid, model.EngineWasm)))
+	require.Empty(t, filter.filter(ctx, nil, jobEnvelope("job-1", model.JobEventBidAccepted, model.EngineWasm)))
+
+	events := filter.filter(ctx, nil, jobEnvelope("job-1", model.JobEventCreated, model.EngineWasm))
+	require.Equal(t, []model.JobEventType{
+		model.JobEventCreated,
+		model.JobEventBid,
+		model.JobEventBidAccepted,
+	}, filteredEventNames(events))
+	require.Empty(t, filter.early)
+	require.Zero(t, filter.earlyCount)
+}
+
+func TestJobFilterExpiresEarlyEvents(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	filter := newJobFilter(testHostID)
+	filter.now = func() time.Time { return now }
+	filter.subscribe([]model.Engine{model.EngineWasm}, nil)
+
+	require.Empty(t, filter.filter(ctx, nil, jobEnvelope("job-1", model.JobEventBid, model.EngineWasm)))
+	require.Equal(t, 1, filter.earlyCount)
+
+	now = now.Add(earlyEventTTL + jobFilterPruneInterval)
+	require.Empty(t, filter.filter(ctx, nil, jobEnvelope("job-2", model.JobEventBid, model.EngineWasm)))
+	require.NotContains(t, filter.early, "job-1")
+	require.Equal(t, 1, filter.earlyCount)
+
+	// announcements are forgotten too, after which jobs are looked up
+	filter.filter(ctx, nil, jobEnvelope("job-3", model.JobEventCreated, model.EngineWasm))
+	now = now.Add(announcementDedupTTL + jobFilterPruneInterval)
+	filter.filter(ctx, nil, jobEnvelope("job-4", model.JobEventCreated, model.EngineWasm))
+	require.NotContains(t, filter.announced, "job-3")
+}
+
+func TestJobFilterLetsThroughKnownJobs(t *testing.T) {
+	ctx := context.Background()
+	filter := newJobFilter(testHostID)
+	filter.subscribe([]model.Engine{model.EngineWasm}, func(ctx context.Context, jobID string) bool {
+		return jobID == "replayed-job"
+	})
+
+	require.Len(t, filter.filter(ctx, nil, jobEnvelope("replayed-job", model.JobEventBidAccepted, model.EngineWasm)), 1)
+	require.Empty(t, filter.filter(ctx, nil, jobEnvelope("other-job", model.JobEventBidAccepted, model.EngineWasm)))
+}
@@ -0,0 +1,90 @@
+package transport
+
+import (
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+)
+
+// sequenceRetention is how long the numbering of the events of a job is kept
+// after its last event. Events sent after that are numbered from 1 again,
+// with a new epoch.
+const sequenceRetention = time.Hour
+
+type jobSequence struct {
+	epoch     int64
+	number    uint64
+	lastEvent time.Time
+}
+
+// EventSequencer numbers the events a node publishes for each job, so that
+// the nodes receiving them can tell the order they were sent in without
+// relying on the clock of the sender.
+//
+// The numbering of a job starts at 1 with an epoch, the time it started at,
+// and is dropped once the node sends a terminal event for the job or sends
+// none for a while. Numbering the job again, or after the node restarts,
+// starts with a later epoch, which puts the events after those numbered
+// before as long as the clock of the node doesn't go back in between.
+type EventSequencer struct {
+	mutex     sync.Mutex
+	sequences map[string]*jobSequence
+	lastEpoch int64
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewEventSequencer() *EventSequencer {
+	return &EventSequencer{
+		sequences: map[string]*jobSequence{},
+		now:       time.Now,
+	}
+}
+
+// Sequence returns the event with the next sequence number of its job. Events
+// that already have a sequence number, such as those being republished, are
+// returned as is.
+func (s *EventSequencer) Sequence(event model.JobEvent) model.JobEvent {
+	if event.SequenceNumber != 0 {
+		return event
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := s.now()
+	s.sweep(now)
+
+	sequence, ok := s.sequences[event.JobID]
+	if !ok {
+		// epochs only go forward, even if two jobs start at the same time
+		epoch := now.UnixNano()
+		if epoch <= s.lastEpoch {
+			epoch = s.lastEpoch + 1
+		}
+		s.lastEpoch = epoch
+		sequence = &jobSequence{epoch: epoch}
+		s.sequences[event.JobID] = sequence
+	}
+	sequence.number++
+	sequence.lastEvent = now
+	event.SequenceEpoch = sequence.epoch
+	event.SequenceNumber = sequence.number
+	if event.EventName.IsTerminal() {
+		delete(s.sequences, event.JobID)
+	}
+	return event
+}
+
+// sweep drops the numbering of the jobs that had no events for the
+// retention.
+func (s *EventSequencer) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sequenceRetention {
+		return
+	}
+	s.lastSweep = now
+	for jobID, sequence := range s.sequences {
+		if now.Sub(sequence.lastEvent) >= sequenceRetention {
+			delete(s.sequences, jobID)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+//go:build unit || !integration
+
+package transport
+
+import (
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventSequencer(t *testing.T) {
+	sequencer := NewEventSequencer()
+	first := sequencer.Sequence(model.JobEvent{JobID: "a"})
+	require.Equal(t, uint64(1), first.SequenceNumber)
+	require.NotZero(t, first.SequenceEpoch)
+	second := sequencer.Sequence(model.JobEvent{JobID: "a"})
+	require.Equal(t, uint64(2), second.SequenceNumber)
+	require.Equal(t, first.SequenceEpoch, second.SequenceEpoch)
+	other := sequencer.Sequence(model.JobEvent{JobID: "b"})
+	require.Equal(t, uint64(1), other.SequenceNumber)
+	require.Greater(t, other.SequenceEpoch, first.SequenceEpoch)
+	// republished events keep their number
+	require.Equal(t, uint64(1), sequencer.Sequence(model.JobEvent{JobID: "a", SequenceNumber: 1}).SequenceNumber)
+	require.Equal(t, uint64(3), sequencer.Sequence(model.JobEvent{JobID: "a"}).SequenceNumber)
+}
+
+func TestEventSequencerRestart(t *testing.T) {
+	before := NewEventSequencer()
+	var last model.JobEvent
+	for i := 0; i < 3; i++ {
+		last = before.Sequence(model.JobEvent{JobID: "a"})
+	}
+
+	// the numbering starts over, with a later epoch
+	after := NewEventSequencer()
+	event := after.Sequence(model.JobEvent{JobID: "a"})
+	require.Equal(t, uint64(1), event.SequenceNumber)
+	require.Greater(t, event.SequenceEpoch, last.SequenceEpoch)
+}
+
+func TestEventSequencerDropsJobs(t *testing.T) {
+	now := time.Now()
+	sequencer := NewEventSequencer()
+	sequencer.now = func() time.Time { return now }
+
+	first := sequencer.Sequence(model.JobEvent{JobID: "a"})
+	terminal := sequencer.Sequence(model.JobEvent{JobID: "a", EventName: model.JobEventResultsPublished})
+	require.Equal(t, uint64(2), terminal.SequenceNumber)
+	require.Empty(t, sequencer.sequences)
+	// events after the terminal one go after it
+	event := sequencer.Sequence(model.JobEvent{JobID: "a"})
+	require.Equal(t, uint64(1), event.SequenceNumber)
+	require.Greater(t, event.SequenceEpoch, first.SequenceEpoch)
+
+	sequencer.Sequence(model.JobEvent{JobID: "idle"})
+	now = now.Add(sequenceRetention)
+	sequencer.Sequence(model.JobEvent{JobID: "b"})
+	require.Len(t, sequencer.sequences, 1)
+	require.Contains(t, sequencer.sequences, "b")
+}
@@ -15,6 +15,7 @@ import (
 	sync "github.com/lukemarsden/golang-mutex-tracer"
 
 	"github.com/filecoin-project/bacalhau/pkg/config"
+	"github.com/filecoin-project/bacalhau/pkg/logger"
 	"github.com/filecoin-project/bacalhau/pkg/model"
 	"github.com/filecoin-project/bacalhau/pkg/system"
 	"github.com/filecoin-project/bacalhau/pkg/transport"
@@ -34,6 +35,7 @@ type SimulatorTransport struct {
 	websocketMutex     sync.Mutex
 	privateKey         crypto.PrivKey
 	subscriptionMutex  sync.RWMutex
+	sequencer          *transport.EventSequencer
 }
 
 func NewTransport(
@@ -54,6 +56,7 @@ func NewTransport(
 		url:                url,
 		subscribeFunctions: []transport.SubscribeFn{},
 		privateKey:         prvKey,
+		sequencer:          transport.NewEventSequencer(),
 	}, nil
 }
 
@@ -105,7 +108,7 @@ func (t *SimulatorTransport) Shutdown(ctx context.Context) error {
 }
 
 func (t *SimulatorTransport) Publish(ctx context.Context, ev model.JobEvent) error {
-	return t.writeJobEvent(ctx, ev)
+	return t.writeJobEvent(ctx, t.sequencer.Sequence(ev))
 }
 
 func (t *SimulatorTransport) Subscribe(ctx context.Context, fn transport.SubscribeFn) {
@@ -194,7 +197,8 @@ func (t *SimulatorTransport) writeJobEvent(ctx context.Context, event model.JobE
 		return fmt.Errorf("websocket not connected")
 	}
 
-	log.Debug().Msgf("Sending event %s: %s", event.EventName.String(), string(bs))
+	ctx = logger.ContextWithJobShardLogger(ctx, event.JobID, event.ShardIndex)
+	log.Ctx(ctx).Debug().Msgf("Sending event %s: %s", event.EventName.String(), string(bs))
 	return t.websocket.WriteMessage(websocket.TextMessage, bs)
 }
 
@@ -203,22 +207,24 @@ func (t *SimulatorTransport) readMessage(payload *jobEventEnvelope) {
 	then := payload.SentTime
 	latency := now.Sub(then)
 	latencyMilli := int64(latency / time.Millisecond)
+	ctx := logger.ContextWithNodeIDLogger(context.Background(), t.id)
+	ctx = logger.ContextWithJobShardLogger(ctx, payload.JobEvent.JobID, payload.JobEvent.ShardIndex)
 	if latencyMilli > 500 { //nolint:gomnd
-		log.Warn().Msgf(
+		log.Ctx(ctx).Warn().Msgf(
 			"[%s=>%s] VERY High message latency: %d ms (%s)",
 			payload.JobEvent.SourceNodeID[:8],
 			t.id[:8],
 			latencyMilli, payload.JobEvent.EventName.String(),
 		)
 	} else if latencyMilli > 50 { //nolint:gomnd
-		log.Warn().Msgf(
+		log.Ctx(ctx).Warn().Msgf(
 			"[%s=>%s] High message latency: %d ms (%s)",
 			payload.JobEvent.SourceNodeID[:8],
 			t.id[:8],
 			latencyMilli, payload.JobEvent.EventName.String(),
 		)
 	} else {
-		log.Trace().Msgf(
+		log.Ctx(ctx).Trace().Msgf(
 			"[%s=>%s] Message latency: %d ms (%s)",
 			payload.JobEvent.SourceNodeID[:8],
 			t.id[:8],
@@ -226,7 +232,7 @@ func (t *SimulatorTransport) readMessage(payload *jobEventEnvelope) {
 		)
 	}
 
-	log.Trace().Msgf("Received event %s: %+v", payload.JobEvent.EventName.String(), payload)
+	log.Ctx(ctx).Trace().Msgf("Received event %s: %+v", payload.JobEvent.EventName.String(), payload)
 	ev := payload.JobEvent
 
 	var wg realsync.WaitGroup
@@ -238,9 +244,9 @@ func (t *SimulatorTransport) readMessage(payload *jobEventEnvelope) {
 			wg.Add(1)
 			go func(f transport.SubscribeFn) {
 				defer wg.Done()
-				err := f(context.Background(), ev)
+				err := f(ctx, ev)
 				if err != nil {
-					log.Error().Msgf("error in handle event: %s\n%+v", err, ev)
+					log.Ctx(ctx).Error().Msgf("error in handle event: %s\n%+v", err, ev)
 				}
 			}(fn)
 		}
@@ -35,7 +35,9 @@ type Transport interface {
 	/// EVENT HANDLING
 	/////////////////////////////////////////////////////////////
 
-	// This emits an event across the network to other nodes
+	// This emits an event across the network to other nodes. Transports
+	// that send events asynchronously, such as libp2p with batching
+	// enabled, only return errors for events that could never be sent.
 	Publish(ctx context.Context, ev model.JobEvent) error
 
 	// Subscribe registers a callback for updates about any change to a job
@@ -61,3 +63,19 @@ type ListResponse struct {
 type VersionResponse struct {
 	VersionInfo model.BuildVersionInfo
 }
+
+// ConnectionChecker is implemented by transports that can tell whether they
+// are connected to the rest of the network.
+type ConnectionChecker interface {
+	// CheckConnection returns why the node can't reach other nodes, or nil
+	// if it can or isn't meant to.
+	CheckConnection(ctx context.Context) error
+}
+
+// VersionReporter is implemented by transports that know which versions of
+// the protocol the nodes they're connected to speak.
+type VersionReporter interface {
+	// PeerVersions reports the versions of the connected nodes in the node
+	// debug info, with warnings when they differ from this node's.
+	PeerVersions() model.DebugInfoProvider
+}
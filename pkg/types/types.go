@@ -1,5 +1,11 @@
 package types
 
+import (
+	"time"
+
+	"github.com/filecoin-project/bacalhau/pkg/selftest"
+)
+
 // TODO: migrate all of these API types to publicapi
 
 type ResultsList struct {
@@ -11,6 +17,18 @@ type ResultsList struct {
 // Struct to report from the healthz endpoint
 type HealthInfo struct {
 	DiskFreeSpace FreeSpace `json:"FreeSpace"`
+	// Nil if the node doesn't use an IPFS daemon
+	IPFS *IPFSStatus `json:"IPFS,omitempty"`
+	// The checks of the node's environment, only run when asked for with
+	// /healthz?deep=true
+	SelfTest *selftest.Report `json:"SelfTest,omitempty"`
+}
+
+// Whether the node's IPFS daemon could be reached when it was last checked
+type IPFSStatus struct {
+	Available   bool      `json:"Available"`
+	LastChecked time.Time `json:"LastChecked"`
+	Error       string    `json:"Error,omitempty"`
 }
 
 type FreeSpace struct {
@@ -0,0 +1,127 @@
+package manifest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+)
+
+// FileName is the name of the manifest in the results of a shard.
+const FileName = "manifest.json"
+
+const filePerm fs.FileMode = 0644
+
+// Write lists every file in the results directory with its size and sha256
+// in a manifest in it, and returns what it lists.
+func Write(resultsDir string) ([]model.ResultFile, error) {
+	files, err := list(resultsDir)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.MarshalIndent(files, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err = os.WriteFile(filepath.Join(resultsDir, FileName), append(data, '\n'), filePerm); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// Verify checks that the files in the downloaded results directory are the
+// ones listed in its manifest. Results without a manifest, from nodes that
+// didn't write one, are not checked.
+func Verify(resultsDir string) error {
+	data, err := os.ReadFile(filepath.Join(resultsDir, FileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var expected []model.ResultFile
+	if err = json.Unmarshal(data, &expected); err != nil {
+		return fmt.Errorf("invalid results manifest: %w", err)
+	}
+	files, err := list(resultsDir)
+	if err != nil {
+		return err
+	}
+
+	found := make(map[string]model.ResultFile, len(files))
+	for _, file := range files {
+		found[file.Path] = file
+	}
+	var problems []string
+	for _, want := range expected {
+		got, ok := found[want.Path]
+		delete(found, want.Path)
+		switch {
+		case !ok:
+			problems = append(problems, want.Path+" is missing")
+		case got.Size != want.Size:
+			problems = append(problems, fmt.Sprintf("%s is %d bytes, not %d", want.Path, got.Size, want.Size))
+		case got.SHA256 != want.SHA256:
+			problems = append(problems, want.Path+" doesn't match its sha256")
+		}
+	}
+	for _, file := range files {
+		if _, ok := found[file.Path]; ok {
+			problems = append(problems, file.Path+" is not in the manifest")
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("the results don't match their manifest: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// list returns the regular files in the directory other than its manifest,
+// in lexical order.
+func list(dir string) ([]model.ResultFile, error) {
+	files := []model.ResultFile{}
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.Type().IsRegular() {
+			return err
+		}
+		relative, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		relative = filepath.ToSlash(relative)
+		if relative == FileName {
+			return nil
+		}
+		file, err := hash(path)
+		if err != nil {
+			return err
+		}
+		file.Path = relative
+		files = append(files, file)
+		return nil
+	})
+	return files, err
+}
+
+func hash(path string) (model.ResultFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return model.ResultFile{}, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return model.ResultFile{}, err
+	}
+	return model.ResultFile{Size: size, SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
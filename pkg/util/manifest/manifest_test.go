@@ -0,0 +1,57 @@
+//go:build unit || !integration
+
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/stretchr/testify/require"
+)
+
+func writeResults(t *testing.T) string {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "stdout"), []byte("hello\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "exitCode"), []byte("0"), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "outputs", "sub"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "outputs", "sub", "empty"), nil, 0644))
+	return dir
+}
+
+func TestWrite(t *testing.T) {
+	dir := writeResults(t)
+	files, err := Write(dir)
+	require.NoError(t, err)
+	require.Equal(t, []model.ResultFile{
+		{Path: "exitCode", Size: 1, SHA256: "5feceb66ffc86f38d952786c6d696c79c2dbc239dd4e91b46729d73a27fb57e9"},
+		{Path: "outputs/sub/empty", Size: 0, SHA256: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+		{Path: "stdout", Size: 6, SHA256: "5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03"},
+	}, files)
+	require.FileExists(t, filepath.Join(dir, FileName))
+
+	// the manifest doesn't list itself when it is written again
+	again, err := Write(dir)
+	require.NoError(t, err)
+	require.Equal(t, files, again)
+	require.NoError(t, Verify(dir))
+}
+
+func TestVerify(t *testing.T) {
+	require.NoError(t, Verify(t.TempDir()), "results without a manifest aren't checked")
+
+	dir := writeResults(t)
+	_, err := Write(dir)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "stdout"), []byte("hullo\n"), 0644))
+	require.ErrorContains(t, Verify(dir), "stdout doesn't match its sha256")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "stdout"), []byte("hi\n"), 0644))
+	require.ErrorContains(t, Verify(dir), "stdout is 3 bytes, not 6")
+	require.NoError(t, os.Remove(filepath.Join(dir, "stdout")))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "extra"), nil, 0644))
+	err = Verify(dir)
+	require.ErrorContains(t, err, "stdout is missing")
+	require.ErrorContains(t, err, "extra is not in the manifest")
+}
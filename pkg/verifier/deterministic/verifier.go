@@ -150,7 +150,6 @@ func (deterministicVerifier *DeterministicVerifier) verifyShard(
 
 	largestGroupHash := ""
 	largestGroupSize := 0
-	isVoidResult := false
 	groupSizeCounts := map[int]int{}
 	hashGroups := deterministicVerifier.getHashGroups(ctx, shard, shardStates)
 
@@ -162,30 +161,41 @@ func (deterministicVerifier *DeterministicVerifier) verifyShard(
 		groupSizeCounts[len(group)]++
 	}
 
-	// this means there is a draw for the largest group size
-	if groupSizeCounts[largestGroupSize] > 1 {
-		isVoidResult = true
-	}
-
-	// this means there is only a single result
-	if len(hashGroups) == 1 && largestGroupSize == 1 {
-		isVoidResult = true
+	totalResults := 0
+	for _, group := range hashGroups {
+		totalResults += len(group)
 	}
 
-	// this means that the winning group size does not
-	// meet the confidence threshold
-	if confidence > 0 && largestGroupSize < confidence {
-		isVoidResult = true
-	}
-
-	// the winning hash must not be empty string
-	if largestGroupHash == "" {
-		isVoidResult = true
+	voidReason := ""
+	if groupSizeCounts[largestGroupSize] > 1 {
+		// this means there is a draw for the largest group size
+		voidReason = fmt.Sprintf("no quorum, as %d groups of %d nodes disagree", groupSizeCounts[largestGroupSize], largestGroupSize)
+	} else if len(hashGroups) == 1 && largestGroupSize == 1 {
+		// this means there is only a single result
+		voidReason = "no quorum, as there is only a single result to compare"
+	} else if confidence > 0 && largestGroupSize < confidence {
+		// this means that the winning group size does not
+		// meet the confidence threshold
+		voidReason = fmt.Sprintf("no quorum, as at most %d of %d nodes agree and the confidence is %d",
+			largestGroupSize, totalResults, confidence)
+	} else if largestGroupHash == "" {
+		// the winning hash must not be empty string
+		voidReason = "no quorum, as most nodes proposed no valid result"
 	}
 
-	if !isVoidResult {
-		for _, passedVerificationResult := range hashGroups[largestGroupHash] {
-			passedVerificationResult.Verified = true
+	for hash, group := range hashGroups {
+		for _, verificationResult := range group {
+			switch {
+			case voidReason != "":
+				verificationResult.Reason = voidReason
+			case hash == largestGroupHash:
+				verificationResult.Verified = true
+				verificationResult.Reason = fmt.Sprintf("the result agrees with the quorum of %d of %d nodes",
+					largestGroupSize, totalResults)
+			default:
+				verificationResult.Reason = fmt.Sprintf("the result disagrees with the quorum of %d of %d nodes",
+					largestGroupSize, totalResults)
+			}
 		}
 	}
 
@@ -225,5 +235,59 @@ func (deterministicVerifier *DeterministicVerifier) VerifyShard(
 	return shardResults, nil
 }
 
+// VerifyPublishedResults checks that the nodes whose results agreed also
+// published the same results, as the same results have the same CID. The
+// CID most nodes published is verified, unless there is a draw. Results
+// published without a CID, e.g. asynchronously, can't be compared and are
+// verified.
+func (deterministicVerifier *DeterministicVerifier) VerifyPublishedResults(
+	ctx context.Context,
+	shard model.JobShard,
+	publishedResults []verifier.PublishedResult,
+) ([]verifier.VerifierResult, error) {
+	//nolint:ineffassign,staticcheck
+	ctx, span := system.GetTracer().Start(ctx, "pkg/verifier/deterministic.VerifyPublishedResults")
+	defer span.End()
+
+	cidCounts := map[string]int{}
+	for _, result := range publishedResults {
+		if result.PublishedResult.CID != "" {
+			cidCounts[result.PublishedResult.CID]++
+		}
+	}
+	largestCID, largestCount, draw := "", 0, false
+	for cid, count := range cidCounts {
+		if count > largestCount {
+			largestCID, largestCount, draw = cid, count, false
+		} else if count == largestCount {
+			draw = true
+		}
+	}
+
+	results := make([]verifier.VerifierResult, 0, len(publishedResults))
+	for _, publishedResult := range publishedResults {
+		result := verifier.VerifierResult{
+			JobID:      shard.Job.ID,
+			NodeID:     publishedResult.NodeID,
+			ShardIndex: publishedResult.ShardIndex,
+		}
+		switch cid := publishedResult.PublishedResult.CID; {
+		case cid == "":
+			result.Verified = true
+			result.Reason = "the results were published without a CID to compare"
+		case draw:
+			result.Reason = fmt.Sprintf("no quorum, as %d nodes published different CIDs", len(cidCounts))
+		case cid == largestCID:
+			result.Verified = true
+			result.Reason = fmt.Sprintf("the published CID agrees with %d of %d nodes", largestCount, len(publishedResults))
+		default:
+			result.Reason = fmt.Sprintf("the published CID %s disagrees with the CID %s of %d of %d nodes",
+				cid, largestCID, largestCount, len(publishedResults))
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
 // Compile-time check that deterministicVerifier implements the correct interface:
-var _ verifier.Verifier = (*DeterministicVerifier)(nil)
+var _ verifier.PublishedResultsVerifier = (*DeterministicVerifier)(nil)
@@ -0,0 +1,155 @@
+//go:build unit || !integration
+
+package deterministic
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/verifier"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestVerifier returns a verifier whose proposals are the hashes in the
+// clear.
+func newTestVerifier() *DeterministicVerifier {
+	return &DeterministicVerifier{
+		decrypter: func(_ context.Context, data []byte) ([]byte, error) {
+			return data, nil
+		},
+	}
+}
+
+func verifyingState(nodeID, hash string) model.JobShardState {
+	return model.JobShardState{
+		NodeID:               nodeID,
+		State:                model.JobStateVerifying,
+		VerificationProposal: []byte(hash),
+	}
+}
+
+func verifiedByNode(results []verifier.VerifierResult) map[string]bool {
+	verified := map[string]bool{}
+	for _, result := range results {
+		verified[result.NodeID] = result.Verified
+	}
+	return verified
+}
+
+func TestVerifyShardQuorum(t *testing.T) {
+	shard := model.JobShard{Job: &model.Job{ID: "job-id"}}
+	results, err := newTestVerifier().verifyShard(context.Background(), shard, []model.JobShardState{
+		verifyingState("a", "hash"),
+		verifyingState("b", "hash"),
+		verifyingState("c", "other"),
+		{NodeID: "d", State: model.JobStateError},
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string]bool{"a": true, "b": true, "c": false}, verifiedByNode(results))
+	for _, result := range results {
+		if result.NodeID == "c" {
+			require.Equal(t, "the result disagrees with the quorum of 2 of 3 nodes", result.Reason)
+		} else {
+			require.Equal(t, "the result agrees with the quorum of 2 of 3 nodes", result.Reason)
+		}
+	}
+}
+
+func TestVerifyShardNoQuorum(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		confidence int
+		states     []model.JobShardState
+		reason     string
+	}{
+		{
+			name:   "draw",
+			states: []model.JobShardState{verifyingState("a", "hash"), verifyingState("b", "other")},
+			reason: "no quorum, as 2 groups of 1 nodes disagree",
+		},
+		{
+			name:   "single result",
+			states: []model.JobShardState{verifyingState("a", "hash")},
+			reason: "no quorum, as there is only a single result to compare",
+		},
+		{
+			name:       "below confidence",
+			confidence: 3,
+			states: []model.JobShardState{
+				verifyingState("a", "hash"), verifyingState("b", "hash"), verifyingState("c", "other"),
+			},
+			reason: "no quorum, as at most 2 of 3 nodes agree and the confidence is 3",
+		},
+		{
+			name:   "no valid result",
+			states: []model.JobShardState{verifyingState("a", ""), verifyingState("b", "")},
+			reason: "no quorum, as most nodes proposed no valid result",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			shard := model.JobShard{Job: &model.Job{ID: "job-id", Deal: model.Deal{Confidence: test.confidence}}}
+			results, err := newTestVerifier().verifyShard(context.Background(), shard, test.states)
+			require.NoError(t, err)
+			require.Len(t, results, len(test.states))
+			for _, result := range results {
+				require.False(t, result.Verified)
+				require.Equal(t, test.reason, result.Reason)
+			}
+		})
+	}
+}
+
+func publishedResult(nodeID, cid string) verifier.PublishedResult {
+	return verifier.PublishedResult{
+		NodeID:          nodeID,
+		PublishedResult: model.StorageSpec{StorageSource: model.StorageSourceIPFS, CID: cid},
+	}
+}
+
+func TestVerifyPublishedResults(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		results  []verifier.PublishedResult
+		verified map[string]bool
+		reasons  map[string]string
+	}{
+		{
+			name:     "majority",
+			results:  []verifier.PublishedResult{publishedResult("a", "cid"), publishedResult("b", "cid"), publishedResult("c", "other")},
+			verified: map[string]bool{"a": true, "b": true, "c": false},
+			reasons: map[string]string{
+				"a": "the published CID agrees with 2 of 3 nodes",
+				"c": "the published CID other disagrees with the CID cid of 2 of 3 nodes",
+			},
+		},
+		{
+			name:     "draw",
+			results:  []verifier.PublishedResult{publishedResult("a", "cid"), publishedResult("b", "other")},
+			verified: map[string]bool{"a": false, "b": false},
+			reasons:  map[string]string{"a": "no quorum, as 2 nodes published different CIDs"},
+		},
+		{
+			name:     "no CID",
+			results:  []verifier.PublishedResult{publishedResult("a", ""), publishedResult("b", "cid")},
+			verified: map[string]bool{"a": true, "b": true},
+			reasons: map[string]string{
+				"a": "the results were published without a CID to compare",
+				"b": "the published CID agrees with 1 of 2 nodes",
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			shard := model.JobShard{Job: &model.Job{ID: "job-id"}}
+			results, err := newTestVerifier().VerifyPublishedResults(context.Background(), shard, test.results)
+			require.NoError(t, err)
+			require.Equal(t, test.verified, verifiedByNode(results))
+			for _, result := range results {
+				require.Equal(t, "job-id", result.JobID)
+				if reason, ok := test.reasons[result.NodeID]; ok {
+					require.Equal(t, reason, result.Reason)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package optimistic
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/filecoin-project/bacalhau/pkg/job"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/system"
+	"github.com/filecoin-project/bacalhau/pkg/verifier"
+	"github.com/filecoin-project/bacalhau/pkg/verifier/results"
+	"golang.org/x/mod/sumdb/dirhash"
+)
+
+// OptimisticVerifier provisionally accepts the results of a shard without
+// comparing them, for clients to challenge within the challenge period of
+// the job. A challenge re-executes the shard on another node, and is upheld
+// if the hash of its results differs from the challenged ones, so jobs
+// verified optimistically must be deterministic.
+type OptimisticVerifier struct {
+	stateResolver *job.StateResolver
+	results       *results.Results
+	encrypter     verifier.EncrypterFunction
+	decrypter     verifier.DecrypterFunction
+}
+
+func NewOptimisticVerifier(
+	_ context.Context, cm *system.CleanupManager,
+	resolver *job.StateResolver,
+	encrypter verifier.EncrypterFunction,
+	decrypter verifier.DecrypterFunction,
+) (*OptimisticVerifier, error) {
+	results, err := results.NewResults()
+	if err != nil {
+		return nil, err
+	}
+
+	cm.RegisterCallback(func() error {
+		if err := results.Close(); err != nil {
+			return fmt.Errorf("unable to remove results folder: %w", err)
+		}
+		return nil
+	})
+	return &OptimisticVerifier{
+		stateResolver: resolver,
+		results:       results,
+		encrypter:     encrypter,
+		decrypter:     decrypter,
+	}, nil
+}
+
+func (optimisticVerifier *OptimisticVerifier) IsInstalled(context.Context) (bool, error) {
+	return true, nil
+}
+
+func (optimisticVerifier *OptimisticVerifier) GetShardResultPath(
+	_ context.Context,
+	shard model.JobShard,
+) (string, error) {
+	return optimisticVerifier.results.EnsureShardResultsDir(shard.Job.ID, shard.Index)
+}
+
+// GetShardProposal proposes the hash of the results, encrypted for the
+// requester node, which compares it with the hash of a re-execution if the
+// results are challenged.
+func (optimisticVerifier *OptimisticVerifier) GetShardProposal(
+	ctx context.Context,
+	shard model.JobShard,
+	shardResultPath string,
+) ([]byte, error) {
+	j, err := optimisticVerifier.stateResolver.GetJob(ctx, shard.Job.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(j.RequesterPublicKey) == 0 {
+		return nil, fmt.Errorf("no RequesterPublicKey found in the job")
+	}
+	dirHash, err := dirhash.HashDir(shardResultPath, "results", dirhash.Hash1)
+	if err != nil {
+		return nil, err
+	}
+	return optimisticVerifier.encrypter(ctx, []byte(dirHash), j.RequesterPublicKey)
+}
+
+// each shard must have >= concurrency states
+// and they must be either JobStateError or JobStateVerifying
+func (optimisticVerifier *OptimisticVerifier) IsExecutionComplete(
+	ctx context.Context,
+	shard model.JobShard,
+) (bool, error) {
+	return optimisticVerifier.stateResolver.CheckShardStates(ctx, shard, func(
+		shardStates []model.JobShardState,
+		concurrency int,
+	) (bool, error) {
+		return optimisticVerifier.results.CheckShardStates(shardStates, concurrency)
+	})
+}
+
+// VerifyShard provisionally accepts all the results.
+func (optimisticVerifier *OptimisticVerifier) VerifyShard(
+	ctx context.Context,
+	shard model.JobShard,
+) ([]verifier.VerifierResult, error) {
+	ctx, span := system.GetTracer().Start(ctx, "pkg/verifier/optimistic.VerifyShard")
+	defer span.End()
+
+	jobState, err := optimisticVerifier.stateResolver.GetJobState(ctx, shard.Job.ID)
+	if err != nil {
+		return nil, err
+	}
+	shardStates := job.GetStatesForShardIndex(jobState, shard.Index)
+	if len(shardStates) == 0 {
+		return nil, fmt.Errorf("job (%s) has no shard state for shard index %d", shard.Job.ID, shard.Index)
+	}
+
+	reason := "provisionally accepted, the results can be challenged"
+	if period := shard.Job.Spec.GetChallengePeriod(); period > 0 {
+		reason = fmt.Sprintf("%s until %s", reason, time.Now().Add(period).Format(time.RFC3339))
+	}
+	results := []verifier.VerifierResult{}
+	for _, shardState := range shardStates { //nolint:gocritic
+		if shardState.State != model.JobStateVerifying {
+			continue
+		}
+		results = append(results, verifier.VerifierResult{
+			JobID:      shard.Job.ID,
+			NodeID:     shardState.NodeID,
+			ShardIndex: shardState.ShardIndex,
+			Verified:   true,
+			Reason:     reason,
+		})
+	}
+	return results, nil
+}
+
+// VerifyChallenge upholds a challenge if the hash of the challenged results
+// differs from the hash of the results of the re-execution.
+func (optimisticVerifier *OptimisticVerifier) VerifyChallenge(
+	ctx context.Context,
+	shard model.JobShard,
+	challenged verifier.PublishedResult,
+	reexecution verifier.PublishedResult,
+) (verifier.ChallengeOutcome, error) {
+	ctx, span := system.GetTracer().Start(ctx, "pkg/verifier/optimistic.VerifyChallenge")
+	defer span.End()
+
+	reexecutionHash, err := optimisticVerifier.decrypter(ctx, reexecution.VerificationProposal)
+	if err != nil {
+		return verifier.ChallengeOutcome{}, fmt.Errorf("the re-execution on node %s proposed no valid result: %w",
+			reexecution.NodeID, err)
+	}
+	challengedHash, err := optimisticVerifier.decrypter(ctx, challenged.VerificationProposal)
+	if err != nil {
+		return verifier.ChallengeOutcome{
+			Upheld: true,
+			Reason: fmt.Sprintf("node %s proposed no valid result for shard %d", challenged.NodeID, challenged.ShardIndex),
+		}, nil
+	}
+
+	if string(challengedHash) != string(reexecutionHash) {
+		return verifier.ChallengeOutcome{
+			Upheld: true,
+			Reason: fmt.Sprintf("the results of node %s hash to %s, and the re-execution on node %s hashes to %s",
+				challenged.NodeID, challengedHash, reexecution.NodeID, reexecutionHash),
+		}, nil
+	}
+	return verifier.ChallengeOutcome{
+		Reason: fmt.Sprintf("the results of node %s hash to %s, as does the re-execution on node %s",
+			challenged.NodeID, challengedHash, reexecution.NodeID),
+	}, nil
+}
+
+// Compile-time check that OptimisticVerifier implements the correct interface:
+var _ verifier.ChallengeableVerifier = (*OptimisticVerifier)(nil)
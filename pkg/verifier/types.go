@@ -14,6 +14,20 @@ type VerifierResult struct {
 	NodeID     string
 	ShardIndex int
 	Verified   bool
+	// why the result was or wasn't verified, sent to the compute node
+	Reason string
+}
+
+// PublishedResult is what a compute node whose result was accepted
+// published for a shard.
+type PublishedResult struct {
+	NodeID     string
+	ShardIndex int
+	// where the node published the results, which has no CID if the job
+	// publishes asynchronously
+	PublishedResult model.StorageSpec
+	// what the node proposed for the results to be verified, e.g. their hash
+	VerificationProposal []byte
 }
 
 // Returns a verifier that can be used to verify a job.
@@ -76,3 +90,47 @@ type Verifier interface {
 		shard model.JobShard,
 	) ([]VerifierResult, error)
 }
+
+// PublishedResultsVerifier is a Verifier that also verifies the results of
+// a shard after the compute nodes whose results were accepted published
+// them, with where all of them published them. For example, it can compare
+// the results across nodes, or have an external service check them.
+type PublishedResultsVerifier interface {
+	Verifier
+
+	// requester node
+	//
+	// the shard only completes if at least one of the published results is
+	// verified - the others are reported as dissenting from the quorum
+	VerifyPublishedResults(
+		ctx context.Context,
+		shard model.JobShard,
+		results []PublishedResult,
+	) ([]VerifierResult, error)
+}
+
+// ChallengeOutcome is what re-executing a shard showed about the results a
+// node published for it that were challenged.
+type ChallengeOutcome struct {
+	// whether the re-execution disproved the challenged results
+	Upheld bool
+	Reason string
+}
+
+// ChallengeableVerifier is a Verifier that accepts results provisionally,
+// for clients to challenge them within the challenge period of the job, by
+// re-executing the shard on another node.
+type ChallengeableVerifier interface {
+	Verifier
+
+	// requester node
+	//
+	// compare the challenged results with the results of the re-execution,
+	// as a proof of whether the challenged node misreported its results
+	VerifyChallenge(
+		ctx context.Context,
+		shard model.JobShard,
+		challenged PublishedResult,
+		reexecution PublishedResult,
+	) (ChallengeOutcome, error)
+}
@@ -9,6 +9,8 @@ import (
 	"github.com/filecoin-project/bacalhau/pkg/verifier"
 	"github.com/filecoin-project/bacalhau/pkg/verifier/deterministic"
 	"github.com/filecoin-project/bacalhau/pkg/verifier/noop"
+	"github.com/filecoin-project/bacalhau/pkg/verifier/optimistic"
+	"github.com/filecoin-project/bacalhau/pkg/verifier/webhook"
 )
 
 func NewStandardVerifiers(
@@ -38,9 +40,33 @@ func NewStandardVerifiers(
 		return nil, err
 	}
 
+	webhookVerifier, err := webhook.NewWebhookVerifier(
+		ctx,
+		cm,
+		resolver,
+		encrypter,
+		decrypter,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	optimisticVerifier, err := optimistic.NewOptimisticVerifier(
+		ctx,
+		cm,
+		resolver,
+		encrypter,
+		decrypter,
+	)
+	if err != nil {
+		return nil, err
+	}
+
 	return verifier.NewMappedVerifierProvider(map[model.Verifier]verifier.Verifier{
 		model.VerifierNoop:          noopVerifier,
 		model.VerifierDeterministic: deterministicVerifier,
+		model.VerifierWebhook:       webhookVerifier,
+		model.VerifierOptimistic:    optimisticVerifier,
 	}), nil
 }
 
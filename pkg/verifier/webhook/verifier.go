@@ -0,0 +1,307 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/filecoin-project/bacalhau/pkg/job"
+	"github.com/filecoin-project/bacalhau/pkg/model"
+	"github.com/filecoin-project/bacalhau/pkg/system"
+	"github.com/filecoin-project/bacalhau/pkg/verifier"
+	"github.com/filecoin-project/bacalhau/pkg/verifier/results"
+	"golang.org/x/mod/sumdb/dirhash"
+)
+
+// DefaultTimeout is how long to wait for a webhook to decide, if the job
+// doesn't say.
+const DefaultTimeout = 5 * time.Minute
+
+// IPFSGateway is the gateway the download URLs of results published to IPFS
+// point at.
+var IPFSGateway = "https://ipfs.io"
+
+const (
+	// SignatureHeader has a base64-encoded signature of the body of the
+	// request, signed by the requester node.
+	SignatureHeader = "X-Bacalhau-Signature"
+
+	// PublicKeyHeader has the base64-encoded public key of the requester
+	// node, to check the signature with.
+	PublicKeyHeader = "X-Bacalhau-Public-Key"
+)
+
+// Request is what the webhook verifier POSTs to the webhook of a job for
+// each of its shards.
+type Request struct {
+	JobID      string   `json:"JobID"`
+	ShardIndex int      `json:"ShardIndex"`
+	ClientID   string   `json:"ClientID"`
+	Results    []Result `json:"Results"`
+}
+
+// Result is what a compute node published for a shard.
+type Result struct {
+	NodeID          string            `json:"NodeID"`
+	PublishedResult model.StorageSpec `json:"PublishedResult"`
+	// The hash of the results the node proposed, as computed by dirhash
+	ResultsHash string `json:"ResultsHash,omitempty"`
+	// Where the results can be downloaded from, if the job asks for it
+	DownloadURL string `json:"DownloadURL,omitempty"`
+}
+
+// Response is what the webhook replies with. Results it makes no decision
+// on are rejected.
+type Response struct {
+	Decisions []Decision `json:"Decisions"`
+}
+
+type Decision struct {
+	NodeID   string `json:"NodeID"`
+	Accepted bool   `json:"Accepted"`
+	Reason   string `json:"Reason,omitempty"`
+}
+
+// WebhookVerifier accepts all the results of a shard, and once they are
+// published has the webhook of the job decide which of them to keep, e.g.
+// after checking their schema or evaluating a model they contain.
+type WebhookVerifier struct {
+	stateResolver *job.StateResolver
+	results       *results.Results
+	encrypter     verifier.EncrypterFunction
+	decrypter     verifier.DecrypterFunction
+	client        *http.Client
+}
+
+func NewWebhookVerifier(
+	_ context.Context, cm *system.CleanupManager,
+	resolver *job.StateResolver,
+	encrypter verifier.EncrypterFunction,
+	decrypter verifier.DecrypterFunction,
+) (*WebhookVerifier, error) {
+	results, err := results.NewResults()
+	if err != nil {
+		return nil, err
+	}
+
+	cm.RegisterCallback(func() error {
+		if err := results.Close(); err != nil {
+			return fmt.Errorf("unable to remove results folder: %w", err)
+		}
+		return nil
+	})
+	return &WebhookVerifier{
+		stateResolver: resolver,
+		results:       results,
+		encrypter:     encrypter,
+		decrypter:     decrypter,
+		client:        &http.Client{},
+	}, nil
+}
+
+func (webhookVerifier *WebhookVerifier) IsInstalled(context.Context) (bool, error) {
+	return true, nil
+}
+
+func (webhookVerifier *WebhookVerifier) GetShardResultPath(
+	_ context.Context,
+	shard model.JobShard,
+) (string, error) {
+	return webhookVerifier.results.EnsureShardResultsDir(shard.Job.ID, shard.Index)
+}
+
+// GetShardProposal proposes the hash of the results, encrypted for the
+// requester node like the deterministic verifier does, which sends it to
+// the webhook.
+func (webhookVerifier *WebhookVerifier) GetShardProposal(
+	ctx context.Context,
+	shard model.JobShard,
+	shardResultPath string,
+) ([]byte, error) {
+	j, err := webhookVerifier.stateResolver.GetJob(ctx, shard.Job.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(j.RequesterPublicKey) == 0 {
+		return nil, fmt.Errorf("no RequesterPublicKey found in the job")
+	}
+	dirHash, err := dirhash.HashDir(shardResultPath, "results", dirhash.Hash1)
+	if err != nil {
+		return nil, err
+	}
+	return webhookVerifier.encrypter(ctx, []byte(dirHash), j.RequesterPublicKey)
+}
+
+// each shard must have >= concurrency states
+// and they must be either JobStateError or JobStateVerifying
+func (webhookVerifier *WebhookVerifier) IsExecutionComplete(
+	ctx context.Context,
+	shard model.JobShard,
+) (bool, error) {
+	return webhookVerifier.stateResolver.CheckShardStates(ctx, shard, func(
+		shardStates []model.JobShardState,
+		concurrency int,
+	) (bool, error) {
+		return webhookVerifier.results.CheckShardStates(shardStates, concurrency)
+	})
+}
+
+// VerifyShard accepts all the results, for the nodes to publish them for
+// the webhook to decide on.
+func (webhookVerifier *WebhookVerifier) VerifyShard(
+	ctx context.Context,
+	shard model.JobShard,
+) ([]verifier.VerifierResult, error) {
+	ctx, span := system.GetTracer().Start(ctx, "pkg/verifier/webhook.VerifyShard")
+	defer span.End()
+
+	jobState, err := webhookVerifier.stateResolver.GetJobState(ctx, shard.Job.ID)
+	if err != nil {
+		return nil, err
+	}
+	shardStates := job.GetStatesForShardIndex(jobState, shard.Index)
+	if len(shardStates) == 0 {
+		return nil, fmt.Errorf("job (%s) has no shard state for shard index %d", shard.Job.ID, shard.Index)
+	}
+
+	results := []verifier.VerifierResult{}
+	for _, shardState := range shardStates { //nolint:gocritic
+		if shardState.State != model.JobStateVerifying {
+			continue
+		}
+		results = append(results, verifier.VerifierResult{
+			JobID:      shard.Job.ID,
+			NodeID:     shardState.NodeID,
+			ShardIndex: shardState.ShardIndex,
+			Verified:   true,
+			Reason:     "the webhook of the job decides on the results once they are published",
+		})
+	}
+	return results, nil
+}
+
+// VerifyPublishedResults POSTs the published results to the webhook of the
+// job, signed by the requester node, and waits for it to accept or reject
+// each of them until the timeout of the webhook.
+func (webhookVerifier *WebhookVerifier) VerifyPublishedResults(
+	ctx context.Context,
+	shard model.JobShard,
+	publishedResults []verifier.PublishedResult,
+) ([]verifier.VerifierResult, error) {
+	ctx, span := system.GetTracer().Start(ctx, "pkg/verifier/webhook.VerifyPublishedResults")
+	defer span.End()
+
+	webhook := shard.Job.Spec.VerificationWebhook
+	if webhook.URL == "" {
+		return nil, fmt.Errorf("job %s has no verification webhook", shard.Job.ID)
+	}
+	timeout := webhook.GetTimeout()
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	request := Request{
+		JobID:      shard.Job.ID,
+		ShardIndex: shard.Index,
+		ClientID:   shard.Job.ClientID,
+		Results:    make([]Result, 0, len(publishedResults)),
+	}
+	for _, publishedResult := range publishedResults {
+		result := Result{
+			NodeID:          publishedResult.NodeID,
+			PublishedResult: publishedResult.PublishedResult,
+		}
+		if len(publishedResult.VerificationProposal) > 0 {
+			// leave the hash out rather than fail if the node proposed one
+			// that can't be decrypted
+			if hash, err := webhookVerifier.decrypter(ctx, publishedResult.VerificationProposal); err == nil {
+				result.ResultsHash = string(hash)
+			}
+		}
+		if webhook.IncludeDownloadURL {
+			result.DownloadURL = downloadURL(publishedResult.PublishedResult)
+		}
+		request.Results = append(request.Results, result)
+	}
+
+	response, err := webhookVerifier.post(ctx, webhook.URL, request)
+	if err != nil {
+		return nil, fmt.Errorf("verification webhook %s: %w", webhook.URL, err)
+	}
+
+	decisions := map[string]Decision{}
+	for _, decision := range response.Decisions {
+		decisions[decision.NodeID] = decision
+	}
+	results := make([]verifier.VerifierResult, 0, len(publishedResults))
+	for _, publishedResult := range publishedResults {
+		result := verifier.VerifierResult{
+			JobID:      shard.Job.ID,
+			NodeID:     publishedResult.NodeID,
+			ShardIndex: publishedResult.ShardIndex,
+			Reason:     "the webhook made no decision on the results",
+		}
+		if decision, ok := decisions[publishedResult.NodeID]; ok {
+			result.Verified = decision.Accepted
+			result.Reason = decision.Reason
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func (webhookVerifier *WebhookVerifier) post(ctx context.Context, url string, request Request) (Response, error) {
+	var response Response
+	body, err := json.Marshal(request)
+	if err != nil {
+		return response, err
+	}
+	signature, err := system.SignForClient(body)
+	if err != nil {
+		return response, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return response, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, signature)
+	req.Header.Set(PublicKeyHeader, system.GetClientPublicKey())
+
+	res, err := webhookVerifier.client.Do(req)
+	if err != nil {
+		return response, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(res.Body, 1024)) //nolint:gomnd
+		return response, fmt.Errorf("responded with %s: %s", res.Status, strings.TrimSpace(string(message)))
+	}
+	if err = json.NewDecoder(res.Body).Decode(&response); err != nil {
+		return response, fmt.Errorf("invalid response: %w", err)
+	}
+	return response, nil
+}
+
+// downloadURL returns where the published results can be downloaded from.
+func downloadURL(publishedResult model.StorageSpec) string {
+	switch {
+	case publishedResult.URL != "":
+		return publishedResult.URL
+	case publishedResult.CID != "":
+		return fmt.Sprintf("%s/ipfs/%s", strings.TrimSuffix(IPFSGateway, "/"), publishedResult.CID)
+	default:
+		return ""
+	}
+}
+
+// Compile-time check that WebhookVerifier implements the correct interface:
+var _ verifier.PublishedResultsVerifier = (*WebhookVerifier)(nil)
@@ -8,6 +8,7 @@ import (
 	"os"
 	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -18,9 +19,47 @@ type Server struct {
 	EndPort   int
 }
 
+// the groups nodes are coloured by in the graph
+const (
+	groupUnknown = iota // only seen as the peer of another node
+	groupRequester
+	groupCompute
+	groupRequesterCompute
+	groupStale // polled before, but hasn't answered for staleAfter
+)
+
+// how long a node can go without answering before it is shown as stale
+const staleAfter = 30 * time.Second
+
+// the topic the links between nodes are read from
+const jobEventTopic = "bacalhau-job-event"
+
 type Node struct {
-	ID    string `json:"id"`
-	Group int    `json:"group"`
+	ID      string   `json:"id"`
+	Group   int      `json:"group"`
+	Roles   []string `json:"roles"`
+	Version string   `json:"version"`
+	// whether the node answered within staleAfter
+	Alive    bool      `json:"alive"`
+	LastSeen time.Time `json:"lastSeen,omitempty"`
+	// whether the node answers but isn't connected to any other node
+	Partitioned bool `json:"partitioned"`
+	// what the page shows when hovering over the node
+	Title string `json:"title"`
+}
+
+// nodeInfo is what a node says about itself under NodeInfo in /debug
+type nodeInfo struct {
+	ID      string   `json:"ID"`
+	Roles   []string `json:"Roles"`
+	Version string   `json:"Version"`
+}
+
+// nodeState is what the last successful poll of a node found
+type nodeState struct {
+	peers    []string
+	info     nodeInfo
+	lastSeen time.Time
 }
 
 type Link struct {
@@ -33,7 +72,47 @@ type Result struct {
 	Links []Link `json:"links"`
 }
 
-func updateResult(theMap map[string][]string) Result {
+func nodeGroup(roles []string) int {
+	requester, compute := false, false
+	for _, role := range roles {
+		requester = requester || role == "requester"
+		compute = compute || role == "compute"
+	}
+	switch {
+	case requester && compute:
+		return groupRequesterCompute
+	case requester:
+		return groupRequester
+	case compute:
+		return groupCompute
+	default:
+		return groupUnknown
+	}
+}
+
+// nodeTitle describes the node as the page shows it when hovering over it.
+func nodeTitle(node Node) string {
+	if node.LastSeen.IsZero() {
+		return node.ID + "\nonly seen as a peer"
+	}
+	lines := []string{node.ID}
+	if len(node.Roles) > 0 {
+		lines = append(lines, strings.Join(node.Roles, ", "))
+	}
+	if node.Version != "" {
+		lines = append(lines, node.Version)
+	}
+	lastSeen := "last seen " + node.LastSeen.UTC().Format(time.RFC3339)
+	switch {
+	case !node.Alive:
+		lastSeen = "stale, " + lastSeen
+	case node.Partitioned:
+		lastSeen = "partitioned, " + lastSeen
+	}
+	return strings.Join(append(lines, lastSeen), "\n")
+}
+
+func updateResult(theMap map[string]nodeState, now time.Time) Result {
 	result := Result{}
 
 	// keys of theMap
@@ -44,16 +123,110 @@ func updateResult(theMap map[string][]string) Result {
 	// sort keys
 	sort.Strings(keys)
 
+	// peers that were never polled themselves still show up in the graph
+	seen := map[string]bool{}
+	for _, node := range keys {
+		seen[node] = true
+	}
+	unknown := []string{}
+
 	for _, node := range keys {
-		links := theMap[node]
-		result.Nodes = append(result.Nodes, Node{ID: node, Group: 0})
-		for _, link := range links {
+		state := theMap[node]
+		alive := now.Sub(state.lastSeen) < staleAfter
+		group := nodeGroup(state.info.Roles)
+		if !alive {
+			group = groupStale
+		}
+		n := Node{
+			ID:          node,
+			Group:       group,
+			Roles:       state.info.Roles,
+			Version:     state.info.Version,
+			Alive:       alive,
+			LastSeen:    state.lastSeen,
+			Partitioned: alive && len(state.peers) == 0,
+		}
+		n.Title = nodeTitle(n)
+		result.Nodes = append(result.Nodes, n)
+		for _, link := range state.peers {
 			result.Links = append(result.Links, Link{Source: node, Target: link})
+			if !seen[link] {
+				seen[link] = true
+				unknown = append(unknown, link)
+			}
 		}
 	}
+	sort.Strings(unknown)
+	for _, node := range unknown {
+		n := Node{ID: node, Group: groupUnknown}
+		n.Title = nodeTitle(n)
+		result.Nodes = append(result.Nodes, n)
+	}
 	return result
 }
 
+func getJSON(url string, v interface{}) error {
+	resp, err := http.Get(url) //nolint:gosec,noctx
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// getNodeInfo reads the roles and version of a node from its debug info.
+// Nodes that predate NodeInfo have neither.
+func getNodeInfo(addr string) (nodeInfo, error) {
+	info := nodeInfo{}
+	debugInfo := map[string]json.RawMessage{}
+	if err := getJSON(addr+"/debug", &debugInfo); err != nil {
+		return info, err
+	}
+	raw, ok := debugInfo["NodeInfo"]
+	if !ok {
+		return info, nil
+	}
+	var encoded string
+	if err := json.Unmarshal(raw, &encoded); err != nil {
+		return info, err
+	}
+	err := json.Unmarshal([]byte(encoded), &info)
+	return info, err
+}
+
+// pollNode asks the node at addr for its ID, its peers and what it says
+// about itself, and records them in theMap as seen at now. A node whose node
+// info can't be read keeps what it said about itself before.
+func pollNode(addr string, theMap map[string]nodeState, theMutex *sync.Mutex, now time.Time) error {
+	newID := ""
+	if err := getJSON(addr+"/id", &newID); err != nil {
+		return err
+	}
+
+	newList := map[string][]string{}
+	if err := getJSON(addr+"/peers", &newList); err != nil {
+		return err
+	}
+	sort.Strings(newList[jobEventTopic])
+
+	info, err := getNodeInfo(addr)
+
+	theMutex.Lock()
+	defer theMutex.Unlock()
+	if err != nil {
+		info = theMap[newID].info
+	}
+	theMap[newID] = nodeState{
+		peers:    newList[jobEventTopic],
+		info:     info,
+		lastSeen: now,
+	}
+	return err
+}
+
 func main() {
 	fmt.Printf("Hello\n")
 
@@ -85,7 +258,7 @@ func main() {
 
 	fmt.Printf("servers: %+v\n", servers)
 
-	theMap := map[string][]string{}
+	theMap := map[string]nodeState{}
 	theResult := Result{}
 	// for each server, a list of servers it is connected to
 	var theMutex sync.Mutex
@@ -93,43 +266,19 @@ func main() {
 		for {
 			for _, server := range servers {
 				for port := server.StartPort; port <= server.EndPort; port++ {
-					addr := fmt.Sprintf("http://%s:%d/", server.Address, port)
-					resp, err := http.Get(addr + "/id")
-					if err != nil {
+					addr := fmt.Sprintf("http://%s:%d", server.Address, port)
+					if err := pollNode(addr, theMap, &theMutex, time.Now()); err != nil {
 						log.Print(err)
-						continue
 					}
-					newID := ""
-					err = json.NewDecoder(resp.Body).Decode(&newID)
-					if err != nil {
-						log.Print(err)
-						continue
-					}
-					resp.Body.Close()
-
-					resp, err = http.Get(addr + "/peers")
-					if err != nil {
-						log.Print(err)
-						continue
-					}
-					newList := map[string][]string{}
-					err = json.NewDecoder(resp.Body).Decode(&newList)
-					if err != nil {
-						log.Print(err)
-						continue
-					}
-					resp.Body.Close()
-
-					func() {
-						theMutex.Lock()
-						defer theMutex.Unlock()
-						theMap[newID] = newList["bacalhau-job-event"]
-						sort.Strings(theMap[newID])
-
-						theResult = updateResult(theMap)
-					}()
 				}
 			}
+			func() {
+				// recomputed on every round, so nodes that stop answering
+				// turn stale
+				theMutex.Lock()
+				defer theMutex.Unlock()
+				theResult = updateResult(theMap, time.Now())
+			}()
 			time.Sleep(1 * time.Second)
 		}
 	}()
@@ -0,0 +1,129 @@
+//go:build unit || !integration
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPollNode(t *testing.T) {
+	debug := `{"NodeInfo":"{\"ID\":\"QmA\",\"Roles\":[\"requester\",\"compute\"],\"Version\":\"v0.3.0\"}"}`
+	mux := http.NewServeMux()
+	mux.HandleFunc("/id", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`"QmA"`))
+	})
+	mux.HandleFunc("/peers", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"bacalhau-job-event":["QmC","QmB"],"other":["QmD"]}`))
+	})
+	mux.HandleFunc("/debug", func(w http.ResponseWriter, r *http.Request) {
+		if debug == "" {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(debug))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	theMap := map[string]nodeState{}
+	var theMutex sync.Mutex
+	seen := time.Date(2022, 11, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, pollNode(server.URL, theMap, &theMutex, seen))
+	expected := nodeState{
+		peers:    []string{"QmB", "QmC"},
+		info:     nodeInfo{ID: "QmA", Roles: []string{"requester", "compute"}, Version: "v0.3.0"},
+		lastSeen: seen,
+	}
+	require.Equal(t, map[string]nodeState{"QmA": expected}, theMap)
+
+	// the node is still seen when its debug info can't be read, and keeps
+	// what it said about itself before
+	debug = ""
+	seen = seen.Add(time.Minute)
+	require.Error(t, pollNode(server.URL, theMap, &theMutex, seen))
+	expected.lastSeen = seen
+	require.Equal(t, map[string]nodeState{"QmA": expected}, theMap)
+
+	// nodes that predate the node info have no roles or version
+	debug = `{}`
+	require.NoError(t, pollNode(server.URL, theMap, &theMutex, seen))
+	require.Equal(t, nodeInfo{}, theMap["QmA"].info)
+}
+
+func TestUpdateResult(t *testing.T) {
+	now := time.Date(2022, 11, 1, 0, 0, 0, 0, time.UTC)
+	theMap := map[string]nodeState{
+		"QmA": {
+			peers:    []string{"QmB", "QmD"},
+			info:     nodeInfo{ID: "QmA", Roles: []string{"requester", "compute"}, Version: "v0.3.0"},
+			lastSeen: now.Add(-time.Second),
+		},
+		"QmB": {
+			peers:    []string{"QmA"},
+			info:     nodeInfo{ID: "QmB", Roles: []string{"compute"}, Version: "v0.2.0"},
+			lastSeen: now.Add(-staleAfter),
+		},
+		"QmC": {
+			info:     nodeInfo{ID: "QmC", Roles: []string{"requester"}, Version: "v0.3.0"},
+			lastSeen: now,
+		},
+	}
+
+	result := updateResult(theMap, now)
+	require.Equal(t, []Node{
+		{
+			ID:       "QmA",
+			Group:    groupRequesterCompute,
+			Roles:    []string{"requester", "compute"},
+			Version:  "v0.3.0",
+			Alive:    true,
+			LastSeen: now.Add(-time.Second),
+			Title:    "QmA\nrequester, compute\nv0.3.0\nlast seen 2022-10-31T23:59:59Z",
+		},
+		{
+			ID:       "QmB",
+			Group:    groupStale,
+			Roles:    []string{"compute"},
+			Version:  "v0.2.0",
+			LastSeen: now.Add(-staleAfter),
+			Title:    "QmB\ncompute\nv0.2.0\nstale, last seen 2022-10-31T23:59:30Z",
+		},
+		{
+			ID:          "QmC",
+			Group:       groupRequester,
+			Roles:       []string{"requester"},
+			Version:     "v0.3.0",
+			Alive:       true,
+			LastSeen:    now,
+			Partitioned: true,
+			Title:       "QmC\nrequester\nv0.3.0\npartitioned, last seen 2022-11-01T00:00:00Z",
+		},
+		{ID: "QmD", Group: groupUnknown, Title: "QmD\nonly seen as a peer"},
+	}, result.Nodes)
+	require.Equal(t, []Link{
+		{Source: "QmA", Target: "QmB"},
+		{Source: "QmA", Target: "QmD"},
+		{Source: "QmB", Target: "QmA"},
+	}, result.Links)
+
+	// the fields are served to the page under the names it reads
+	encoded, err := json.Marshal(result.Nodes[0])
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"id": "QmA",
+		"group": 3,
+		"roles": ["requester", "compute"],
+		"version": "v0.3.0",
+		"alive": true,
+		"lastSeen": "2022-10-31T23:59:59Z",
+		"partitioned": false,
+		"title": "QmA\nrequester, compute\nv0.3.0\nlast seen 2022-10-31T23:59:59Z"
+	}`, string(encoded))
+}